@@ -0,0 +1,197 @@
+// Package agent runs Shelley's agent loop in-process, without the HTTP
+// server or the database. A program supplies the model (any llm.Service)
+// and the tools (such as claudetool.NewToolSet(...).Tools()), then runs the
+// agent a turn at a time:
+//
+//	a, err := agent.New(agent.Config{LLM: service, Tools: tools, System: prompt})
+//	turn, err := a.Run(ctx, "Fix the failing test")
+//	fmt.Println(turn.Text())
+//
+// The exported identifiers of this package are a stable interface: they
+// won't be removed or change meaning, though fields may be added to Config
+// and Turn. The loop package it's built on is Shelley's implementation and
+// may change.
+package agent
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"sync"
+
+	"shelley.exe.dev/llm"
+	"shelley.exe.dev/loop"
+)
+
+// Config configures an Agent.
+type Config struct {
+	// LLM is the model the agent talks to. It's required.
+	LLM llm.Service
+	// Tools are the tools the agent may call.
+	Tools []*llm.Tool
+	// System is the system prompt, if any.
+	System string
+	// History continues an earlier conversation, such as the History of
+	// another Agent.
+	History []llm.Message
+	// WorkingDir is the directory the tools work in. It's only used to
+	// notice git changes; the tools are configured separately.
+	WorkingDir string
+	// OnMessage, if set, is called with each message the agent adds to the
+	// conversation, as it's added: its responses, the results of the
+	// tools it calls, and summaries replacing the history when it's
+	// compacted. It's called from the goroutine running the turn.
+	OnMessage func(ctx context.Context, message llm.Message, usage llm.Usage)
+	// Logger receives the loop's logs. It defaults to slog.Default().
+	Logger *slog.Logger
+}
+
+// Agent is a conversation with an agent. Its methods are safe for
+// concurrent use; turns run one at a time.
+type Agent struct {
+	loop      *loop.Loop
+	onMessage func(ctx context.Context, message llm.Message, usage llm.Usage)
+
+	turnMu sync.Mutex // held while a turn runs
+	mu     sync.Mutex
+	turn   *Turn
+}
+
+// Turn is the outcome of one turn of the agent.
+type Turn struct {
+	// Messages are the messages the agent added to the conversation
+	// during the turn, after the user's: its responses and tool results.
+	Messages []llm.Message
+	// Usage is the total usage of the turn's LLM requests.
+	Usage llm.Usage
+}
+
+// New returns an agent configured by cfg.
+func New(cfg Config) (*Agent, error) {
+	if cfg.LLM == nil {
+		return nil, errors.New("agent: no LLM configured")
+	}
+	a := &Agent{onMessage: cfg.OnMessage}
+	var system []llm.SystemContent
+	if cfg.System != "" {
+		system = []llm.SystemContent{{Type: "text", Text: cfg.System}}
+	}
+	a.loop = loop.NewLoop(loop.Config{
+		LLM:           cfg.LLM,
+		History:       cfg.History,
+		Tools:         cfg.Tools,
+		RecordMessage: a.record,
+		Logger:        cfg.Logger,
+		System:        system,
+		WorkingDir:    cfg.WorkingDir,
+		RecordSummary: a.recordSummary,
+	})
+	return a, nil
+}
+
+// Run sends text to the agent as the user and returns once the agent's
+// turn has ended.
+func (a *Agent) Run(ctx context.Context, text string) (*Turn, error) {
+	return a.Send(ctx, llm.UserStringMessage(text))
+}
+
+// Send adds a user message to the conversation and returns once the
+// agent's turn has ended. If another turn is running, Send waits for it
+// first. If the turn fails, for example because ctx is cancelled or the
+// LLM request fails, Send returns the error along with the part of the
+// turn that ran.
+func (a *Agent) Send(ctx context.Context, message llm.Message) (*Turn, error) {
+	a.turnMu.Lock()
+	defer a.turnMu.Unlock()
+
+	turn := &Turn{}
+	a.mu.Lock()
+	a.turn = turn
+	a.mu.Unlock()
+
+	a.loop.QueueUserMessage(message)
+	err := a.loop.ProcessOneTurn(ctx)
+	a.mu.Lock()
+	a.turn = nil
+	a.mu.Unlock()
+	if err != nil {
+		return turn, err
+	}
+	a.loop.MaybeCompact(ctx)
+	return turn, nil
+}
+
+// History returns the messages of the conversation so far, including the
+// user's. Pass it as Config.History to continue the conversation later.
+func (a *Agent) History() []llm.Message {
+	return a.loop.GetHistory()
+}
+
+// Usage returns the total usage of the agent's LLM requests.
+func (a *Agent) Usage() llm.Usage {
+	return a.loop.GetUsage()
+}
+
+// SetTools replaces the tools offered to the agent from its next request.
+func (a *Agent) SetTools(tools []*llm.Tool) {
+	a.loop.SetTools(tools)
+}
+
+// record is the loop's RecordMessage hook.
+func (a *Agent) record(ctx context.Context, message llm.Message, usage llm.Usage) error {
+	a.mu.Lock()
+	if a.turn != nil {
+		a.turn.Messages = append(a.turn.Messages, message)
+		a.turn.Usage.Add(usage)
+	}
+	a.mu.Unlock()
+	if a.onMessage != nil {
+		a.onMessage(ctx, message, usage)
+	}
+	return nil
+}
+
+// recordSummary passes a compaction summary, which may come in the middle
+// of a turn, to OnMessage without adding it to the turn.
+func (a *Agent) recordSummary(ctx context.Context, message llm.Message, usage llm.Usage, kept int) error {
+	if a.onMessage != nil {
+		a.onMessage(ctx, message, usage)
+	}
+	return nil
+}
+
+// Text returns the text of the agent's last response in the turn.
+func (t *Turn) Text() string {
+	for i := len(t.Messages) - 1; i >= 0; i-- {
+		msg := t.Messages[i]
+		if msg.Role != llm.MessageRoleAssistant {
+			continue
+		}
+		var texts []string
+		for _, content := range msg.Content {
+			if content.Type == llm.ContentTypeText && content.Text != "" {
+				texts = append(texts, content.Text)
+			}
+		}
+		return strings.Join(texts, "\n")
+	}
+	return ""
+}
+
+// ToolCalls returns the tool calls the agent made during the turn, in
+// order.
+func (t *Turn) ToolCalls() []llm.Content {
+	var calls []llm.Content
+	for _, msg := range t.Messages {
+		if msg.Role != llm.MessageRoleAssistant {
+			continue
+		}
+		for _, content := range msg.Content {
+			if content.Type == llm.ContentTypeToolUse {
+				calls = append(calls, content)
+			}
+		}
+	}
+	return calls
+}
@@ -0,0 +1,155 @@
+package agent_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"shelley.exe.dev/agent"
+	"shelley.exe.dev/llm"
+	"shelley.exe.dev/loop"
+)
+
+// echoTool is a bash stand-in that echoes the command it's given.
+var echoTool = &llm.Tool{
+	Name:        "bash",
+	Description: "Runs a command",
+	InputSchema: llm.MustSchema(`{"type": "object", "properties": {"command": {"type": "string"}}}`),
+	Run: func(ctx context.Context, input json.RawMessage) llm.ToolOut {
+		var req struct {
+			Command string `json:"command"`
+		}
+		if err := json.Unmarshal(input, &req); err != nil {
+			return llm.ErrorToolOut(err)
+		}
+		return llm.ToolOut{LLMContent: llm.TextContent("ran " + req.Command)}
+	},
+}
+
+func TestAgent(t *testing.T) {
+	var mu sync.Mutex
+	var seen []llm.MessageRole
+	a, err := agent.New(agent.Config{
+		LLM:    loop.NewPredictableService(),
+		Tools:  []*llm.Tool{echoTool},
+		System: "You are a test.",
+		OnMessage: func(ctx context.Context, message llm.Message, usage llm.Usage) {
+			mu.Lock()
+			seen = append(seen, message.Role)
+			mu.Unlock()
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	turn, err := a.Run(ctx, "echo: hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if turn.Text() != "hello" || len(turn.Messages) != 1 || len(turn.ToolCalls()) != 0 {
+		t.Errorf("unexpected turn %+v", turn)
+	}
+
+	turn, err = a.Run(ctx, "bash: ls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	calls := turn.ToolCalls()
+	if len(calls) != 1 || calls[0].ToolName != "bash" || string(calls[0].ToolInput) != `{"command":"ls"}` {
+		t.Errorf("unexpected tool calls %+v", calls)
+	}
+	// The agent's response, the tool result, and the final response
+	if len(turn.Messages) != 3 || turn.Messages[1].Content[0].ToolResult[0].Text != "ran ls" {
+		t.Errorf("unexpected messages %+v", turn.Messages)
+	}
+	if turn.Usage.OutputTokens == 0 || a.Usage().OutputTokens <= turn.Usage.OutputTokens {
+		t.Errorf("unexpected usage: turn %+v, total %+v", turn.Usage, a.Usage())
+	}
+
+	mu.Lock()
+	want := []llm.MessageRole{llm.MessageRoleAssistant, llm.MessageRoleAssistant, llm.MessageRoleUser, llm.MessageRoleAssistant}
+	if fmt.Sprint(seen) != fmt.Sprint(want) {
+		t.Errorf("OnMessage saw %v, want %v", seen, want)
+	}
+	mu.Unlock()
+
+	// The history continues the conversation in another agent
+	history := a.History()
+	if len(history) != 6 || history[0].Content[0].Text != "echo: hello" {
+		t.Fatalf("unexpected history %+v", history)
+	}
+	service := loop.NewPredictableService()
+	b, err := agent.New(agent.Config{LLM: service, History: history})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Run(ctx, "echo: again"); err != nil {
+		t.Fatal(err)
+	}
+	if req := service.GetLastRequest(); req == nil || len(req.Messages) != 7 {
+		t.Errorf("expected the request to carry the history, got %+v", req)
+	}
+}
+
+func TestAgentConcurrentTurns(t *testing.T) {
+	a, err := agent.New(agent.Config{LLM: loop.NewPredictableService()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for i := range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			text := fmt.Sprintf("echo: turn %d", i)
+			turn, err := a.Run(context.Background(), text)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if got := turn.Text(); got != strings.TrimPrefix(text, "echo: ") {
+				t.Errorf("turn for %q got %q", text, got)
+			}
+		}()
+	}
+	wg.Wait()
+	if got := len(a.History()); got != 10 {
+		t.Errorf("expected 10 messages in the history, got %d", got)
+	}
+}
+
+func TestAgentRequiresLLM(t *testing.T) {
+	if _, err := agent.New(agent.Config{}); err == nil {
+		t.Error("expected an error without an LLM")
+	}
+}
+
+func ExampleAgent() {
+	a, err := agent.New(agent.Config{
+		LLM:   loop.NewPredictableService(),
+		Tools: []*llm.Tool{echoTool},
+		OnMessage: func(ctx context.Context, message llm.Message, usage llm.Usage) {
+			for _, call := range message.Content {
+				if call.Type == llm.ContentTypeToolUse {
+					fmt.Printf("calling %s %s\n", call.ToolName, call.ToolInput)
+				}
+			}
+		},
+	})
+	if err != nil {
+		panic(err)
+	}
+	turn, err := a.Run(context.Background(), "bash: date")
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(turn.Text())
+	// Output:
+	// calling bash {"command":"date"}
+	// edit predictable.go to add a response for that one...
+}
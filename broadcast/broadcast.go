@@ -0,0 +1,103 @@
+// Package broadcast carries messages between the Shelley servers sharing a
+// database, so that what one server publishes reaches the clients connected
+// to any of them. Servers in one process can share a Memory broadcaster;
+// servers on different hosts use Redis or NATS, see Open.
+package broadcast
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sync"
+)
+
+// DefaultChannel is the Redis channel or NATS subject messages are published
+// on, unless the URL given to Open names another.
+const DefaultChannel = "shelley.conversations"
+
+// Broadcaster publishes messages to every server subscribed to it, the
+// publisher included.
+type Broadcaster interface {
+	// Publish sends a message to the subscribers.
+	Publish(ctx context.Context, data []byte) error
+	// Subscribe calls receive with each message published until ctx is done,
+	// when it returns nil, or until it loses its connection.
+	Subscribe(ctx context.Context, receive func(data []byte)) error
+	// Close releases the broadcaster's connections.
+	Close() error
+}
+
+// Open returns the broadcaster a URL describes:
+//
+//	redis://[user:password@]host[:port][?channel=name]  (rediss:// for TLS)
+//	nats://[user:password@]host[:port][?subject=name]
+func Open(rawURL string) (Broadcaster, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid broadcast URL: %w", err)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("broadcast URL %q has no host", rawURL)
+	}
+	switch u.Scheme {
+	case "redis", "rediss":
+		return newRedis(u), nil
+	case "nats":
+		return newNATS(u), nil
+	}
+	return nil, fmt.Errorf("unsupported broadcast URL %q (want redis://, rediss:// or nats://)", rawURL)
+}
+
+// channel returns the channel a URL names in its query, or DefaultChannel.
+func channel(u *url.URL, param string) string {
+	if name := u.Query().Get(param); name != "" {
+		return name
+	}
+	return DefaultChannel
+}
+
+// Memory is a Broadcaster for servers in the same process.
+type Memory struct {
+	mu          sync.Mutex
+	subscribers map[int]func([]byte)
+	next        int
+}
+
+// NewMemory returns an in-memory broadcaster.
+func NewMemory() *Memory {
+	return &Memory{subscribers: make(map[int]func([]byte))}
+}
+
+// Publish calls every subscriber with the message before it returns.
+func (m *Memory) Publish(ctx context.Context, data []byte) error {
+	m.mu.Lock()
+	receivers := make([]func([]byte), 0, len(m.subscribers))
+	for _, receive := range m.subscribers {
+		receivers = append(receivers, receive)
+	}
+	m.mu.Unlock()
+	for _, receive := range receivers {
+		receive(data)
+	}
+	return nil
+}
+
+// Subscribe calls receive with each message published until ctx is done.
+func (m *Memory) Subscribe(ctx context.Context, receive func([]byte)) error {
+	m.mu.Lock()
+	id := m.next
+	m.next++
+	m.subscribers[id] = receive
+	m.mu.Unlock()
+
+	<-ctx.Done()
+	m.mu.Lock()
+	delete(m.subscribers, id)
+	m.mu.Unlock()
+	return nil
+}
+
+// Close does nothing.
+func (m *Memory) Close() error {
+	return nil
+}
@@ -0,0 +1,226 @@
+package broadcast
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// roundTrip subscribes to b, publishes a message once the subscription is
+// up and returns what the subscriber received.
+func roundTrip(t *testing.T, b Broadcaster, subscribed <-chan struct{}) string {
+	t.Helper()
+	ctx, cancel := context.WithCancel(t.Context())
+	received := make(chan string, 1)
+	done := make(chan error, 1)
+	go func() {
+		done <- b.Subscribe(ctx, func(data []byte) { received <- string(data) })
+	}()
+	select {
+	case <-subscribed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the subscription")
+	}
+	if err := b.Publish(t.Context(), []byte("hello\r\nworld")); err != nil {
+		t.Fatal(err)
+	}
+	var got string
+	select {
+	case got = <-received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the message")
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("expected the subscription to end cleanly, got %v", err)
+	}
+	return got
+}
+
+func TestMemory(t *testing.T) {
+	m := NewMemory()
+	subscribed := make(chan struct{})
+	go func() {
+		for {
+			m.mu.Lock()
+			n := len(m.subscribers)
+			m.mu.Unlock()
+			if n > 0 {
+				close(subscribed)
+				return
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}()
+	if got := roundTrip(t, m, subscribed); got != "hello\r\nworld" {
+		t.Errorf("got %q", got)
+	}
+	if len(m.subscribers) != 0 {
+		t.Errorf("expected the subscriber removed, got %d", len(m.subscribers))
+	}
+}
+
+func TestOpen(t *testing.T) {
+	for _, bad := range []string{"http://localhost", "redis://", "nats:///subject"} {
+		if _, err := Open(bad); err == nil {
+			t.Errorf("expected %q to be refused", bad)
+		}
+	}
+	b, err := Open("redis://:secret@example.com?channel=updates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := b.(*Redis); r.addr != "example.com:6379" || r.password != "secret" || r.channel != "updates" {
+		t.Errorf("unexpected Redis broadcaster %+v", r)
+	}
+	b, err = Open("nats://example.com:4333")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := b.(*NATS); n.addr != "example.com:4333" || n.subject != DefaultChannel {
+		t.Errorf("unexpected NATS broadcaster %+v", n)
+	}
+}
+
+// fakeServer accepts connections and serves each with handle.
+func fakeServer(t *testing.T, handle func(net.Conn)) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			t.Cleanup(func() { conn.Close() })
+			go handle(conn)
+		}
+	}()
+	return l.Addr().String()
+}
+
+func TestRedis(t *testing.T) {
+	var mu sync.Mutex
+	var subscribers []net.Conn
+	subscribed := make(chan struct{})
+	addr := fakeServer(t, func(conn net.Conn) {
+		r := bufio.NewReader(conn)
+		authed := false
+		for {
+			var args []string
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			n, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+			for range n {
+				line, _ = r.ReadString('\n')
+				size, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+				buf := make([]byte, size+2)
+				io.ReadFull(r, buf)
+				args = append(args, string(buf[:size]))
+			}
+			switch {
+			case args[0] == "AUTH" && args[1] == "secret":
+				authed = true
+				io.WriteString(conn, "+OK\r\n")
+			case !authed:
+				io.WriteString(conn, "-NOAUTH Authentication required.\r\n")
+			case args[0] == "SUBSCRIBE":
+				fmt.Fprintf(conn, "*3\r\n$9\r\nsubscribe\r\n$%d\r\n%s\r\n:1\r\n", len(args[1]), args[1])
+				mu.Lock()
+				subscribers = append(subscribers, conn)
+				mu.Unlock()
+				close(subscribed)
+			case args[0] == "PUBLISH":
+				mu.Lock()
+				for _, sub := range subscribers {
+					fmt.Fprintf(sub, "*3\r\n$7\r\nmessage\r\n$%d\r\n%s\r\n$%d\r\n%s\r\n", len(args[1]), args[1], len(args[2]), args[2])
+				}
+				fmt.Fprintf(conn, ":%d\r\n", len(subscribers))
+				mu.Unlock()
+			}
+		}
+	})
+
+	b, err := Open("redis://:secret@" + addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	if got := roundTrip(t, b, subscribed); got != "hello\r\nworld" {
+		t.Errorf("got %q", got)
+	}
+
+	unauthed, _ := Open("redis://" + addr)
+	if err := unauthed.Publish(t.Context(), []byte("x")); err == nil || !strings.Contains(err.Error(), "NOAUTH") {
+		t.Errorf("expected the server's error, got %v", err)
+	}
+}
+
+func TestNATS(t *testing.T) {
+	var mu sync.Mutex
+	subscribers := map[net.Conn]string{}
+	subscribed := make(chan struct{})
+	addr := fakeServer(t, func(conn net.Conn) {
+		io.WriteString(conn, "INFO {\"server_id\":\"fake\"}\r\n")
+		r := bufio.NewReader(conn)
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			fields := strings.Fields(line)
+			switch fields[0] {
+			case "CONNECT":
+				if !strings.Contains(line, `"pass":"secret"`) {
+					io.WriteString(conn, "-ERR 'Authorization Violation'\r\n")
+					return
+				}
+				// Subscribers answer the server's pings
+				io.WriteString(conn, "PING\r\n")
+			case "PING":
+				io.WriteString(conn, "PONG\r\n")
+			case "SUB":
+				mu.Lock()
+				subscribers[conn] = fields[2]
+				mu.Unlock()
+				close(subscribed)
+			case "PUB":
+				size, _ := strconv.Atoi(fields[2])
+				buf := make([]byte, size+2)
+				io.ReadFull(r, buf)
+				mu.Lock()
+				for sub, sid := range subscribers {
+					fmt.Fprintf(sub, "MSG %s %s %d\r\n%s\r\n", fields[1], sid, size, buf[:size])
+				}
+				mu.Unlock()
+			}
+		}
+	})
+
+	b, err := Open("nats://shelley:secret@" + addr + "?subject=updates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	if got := roundTrip(t, b, subscribed); got != "hello\r\nworld" {
+		t.Errorf("got %q", got)
+	}
+
+	unauthed, _ := Open("nats://" + addr)
+	if err := unauthed.Publish(t.Context(), []byte("x")); err == nil || !strings.Contains(err.Error(), "Authorization Violation") {
+		t.Errorf("expected the server's error, got %v", err)
+	}
+}
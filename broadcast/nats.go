@@ -0,0 +1,209 @@
+package broadcast
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NATS broadcasts through a NATS server, speaking just enough of its client
+// protocol to publish and subscribe to one subject.
+type NATS struct {
+	addr     string
+	user     string
+	password string
+	subject  string
+
+	mu   sync.Mutex
+	conn *natsConn // for publishing, dialed on first use
+}
+
+func newNATS(u *url.URL) *NATS {
+	n := &NATS{
+		addr:    u.Host,
+		subject: channel(u, "subject"),
+	}
+	if u.Port() == "" {
+		n.addr = net.JoinHostPort(u.Hostname(), "4222")
+	}
+	if u.User != nil {
+		n.user = u.User.Username()
+		n.password, _ = u.User.Password()
+	}
+	return n
+}
+
+// Publish sends a message on the subject and waits for the server to
+// acknowledge it, redialing once if the connection was lost since the last
+// message.
+func (n *NATS) Publish(ctx context.Context, data []byte) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for attempt := 0; ; attempt++ {
+		if n.conn == nil {
+			conn, err := n.dial(ctx)
+			if err != nil {
+				return err
+			}
+			n.conn = conn
+		}
+		err := n.conn.publish(ctx, n.subject, data)
+		if err == nil {
+			return nil
+		}
+		n.conn.Close()
+		n.conn = nil
+		if attempt > 0 {
+			return fmt.Errorf("nats publish: %w", err)
+		}
+	}
+}
+
+// Subscribe calls receive with each message on the subject until ctx is done
+// or the connection is lost.
+func (n *NATS) Subscribe(ctx context.Context, receive func([]byte)) error {
+	conn, err := n.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	if _, err := fmt.Fprintf(conn, "SUB %s 1\r\n", n.subject); err != nil {
+		return fmt.Errorf("nats subscribe: %w", err)
+	}
+	for {
+		data, err := conn.next()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("nats subscribe: %w", err)
+		}
+		receive(data)
+	}
+}
+
+// Close closes the publishing connection.
+func (n *NATS) Close() error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.conn == nil {
+		return nil
+	}
+	err := n.conn.Close()
+	n.conn = nil
+	return err
+}
+
+// dial connects to the server, reads its INFO and sends CONNECT.
+func (n *NATS) dial(ctx context.Context) (*natsConn, error) {
+	nc, err := (&net.Dialer{Timeout: dialTimeout}).DialContext(ctx, "tcp", n.addr)
+	if err != nil {
+		return nil, fmt.Errorf("nats: %w", err)
+	}
+	conn := &natsConn{Conn: nc, r: bufio.NewReader(nc)}
+	nc.SetDeadline(time.Now().Add(dialTimeout))
+	line, err := conn.r.ReadString('\n')
+	if err == nil && !strings.HasPrefix(line, "INFO ") {
+		err = fmt.Errorf("unexpected greeting %q", strings.TrimSpace(line))
+	}
+	if err == nil {
+		connect, _ := json.Marshal(map[string]any{
+			"verbose":  false,
+			"pedantic": false,
+			"name":     "shelley",
+			"user":     n.user,
+			"pass":     n.password,
+		})
+		_, err = fmt.Fprintf(conn, "CONNECT %s\r\n", connect)
+	}
+	if err != nil {
+		nc.Close()
+		return nil, fmt.Errorf("nats: %w", err)
+	}
+	nc.SetDeadline(time.Time{})
+	return conn, nil
+}
+
+type natsConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+// publish sends a message followed by a PING, and waits for the PONG that
+// shows the server processed it.
+func (c *natsConn) publish(ctx context.Context, subject string, data []byte) error {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(dialTimeout)
+	}
+	c.SetDeadline(deadline)
+	defer c.SetDeadline(time.Time{})
+	if _, err := fmt.Fprintf(c, "PUB %s %d\r\n%s\r\nPING\r\n", subject, len(data), data); err != nil {
+		return err
+	}
+	for {
+		line, err := c.r.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		op, args, _ := strings.Cut(strings.TrimRight(line, "\r\n"), " ")
+		switch strings.ToUpper(op) {
+		case "PONG":
+			return nil
+		case "PING":
+			if _, err := io.WriteString(c, "PONG\r\n"); err != nil {
+				return err
+			}
+		case "-ERR":
+			return errors.New(strings.Trim(args, "' "))
+		}
+	}
+}
+
+// next returns the payload of the next message, answering the server's
+// PINGs on the way.
+func (c *natsConn) next() ([]byte, error) {
+	for {
+		line, err := c.r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		op, args, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(op) {
+		case "PING":
+			if _, err := io.WriteString(c, "PONG\r\n"); err != nil {
+				return nil, err
+			}
+		case "-ERR":
+			return nil, errors.New(strings.Trim(args, "' "))
+		case "MSG":
+			// MSG <subject> <sid> [reply-to] <#bytes>
+			fields := strings.Fields(args)
+			if len(fields) < 3 {
+				return nil, fmt.Errorf("malformed message %q", line)
+			}
+			size, err := strconv.Atoi(fields[len(fields)-1])
+			if err != nil {
+				return nil, fmt.Errorf("malformed message %q", line)
+			}
+			buf := make([]byte, size+2)
+			if _, err := io.ReadFull(c.r, buf); err != nil {
+				return nil, err
+			}
+			return buf[:size], nil
+		}
+	}
+}
@@ -0,0 +1,227 @@
+package broadcast
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// dialTimeout bounds connecting to a Redis or NATS server.
+const dialTimeout = 10 * time.Second
+
+// Redis broadcasts through a Redis server's PUBLISH and SUBSCRIBE, speaking
+// just enough of its protocol (RESP) for those.
+type Redis struct {
+	addr     string
+	tls      bool
+	user     string
+	password string
+	channel  string
+
+	mu   sync.Mutex
+	conn *redisConn // for publishing, dialed on first use
+}
+
+func newRedis(u *url.URL) *Redis {
+	r := &Redis{
+		addr:    u.Host,
+		tls:     u.Scheme == "rediss",
+		channel: channel(u, "channel"),
+	}
+	if u.Port() == "" {
+		r.addr = net.JoinHostPort(u.Hostname(), "6379")
+	}
+	if u.User != nil {
+		r.user = u.User.Username()
+		r.password, _ = u.User.Password()
+		if r.password == "" {
+			// redis://secret@host names just a password
+			r.user, r.password = "", r.user
+		}
+	}
+	return r
+}
+
+// Publish sends a message on the channel, redialing once if the connection
+// was lost since the last message.
+func (r *Redis) Publish(ctx context.Context, data []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for attempt := 0; ; attempt++ {
+		if r.conn == nil {
+			conn, err := r.dial(ctx)
+			if err != nil {
+				return err
+			}
+			r.conn = conn
+		}
+		_, err := r.conn.do(ctx, "PUBLISH", r.channel, string(data))
+		if err == nil {
+			return nil
+		}
+		r.conn.Close()
+		r.conn = nil
+		var replyErr redisError
+		if attempt > 0 || errors.As(err, &replyErr) {
+			return fmt.Errorf("redis publish: %w", err)
+		}
+	}
+}
+
+// Subscribe calls receive with each message on the channel until ctx is done
+// or the connection is lost.
+func (r *Redis) Subscribe(ctx context.Context, receive func([]byte)) error {
+	conn, err := r.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	if err := conn.send("SUBSCRIBE", r.channel); err != nil {
+		return fmt.Errorf("redis subscribe: %w", err)
+	}
+	for {
+		reply, err := conn.read()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("redis subscribe: %w", err)
+		}
+		// Pushes are ["subscribe", channel, count] and ["message", channel, data]
+		fields, ok := reply.([]any)
+		if !ok || len(fields) != 3 || fields[0] != "message" {
+			continue
+		}
+		if data, ok := fields[2].(string); ok {
+			receive([]byte(data))
+		}
+	}
+}
+
+// Close closes the publishing connection.
+func (r *Redis) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.conn == nil {
+		return nil
+	}
+	err := r.conn.Close()
+	r.conn = nil
+	return err
+}
+
+// dial connects to the server and authenticates, if the URL has credentials.
+func (r *Redis) dial(ctx context.Context) (*redisConn, error) {
+	dialer := &net.Dialer{Timeout: dialTimeout}
+	var nc net.Conn
+	var err error
+	if r.tls {
+		nc, err = (&tls.Dialer{NetDialer: dialer}).DialContext(ctx, "tcp", r.addr)
+	} else {
+		nc, err = dialer.DialContext(ctx, "tcp", r.addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("redis: %w", err)
+	}
+	conn := &redisConn{Conn: nc, r: bufio.NewReader(nc)}
+	if r.password != "" {
+		args := []string{"AUTH", r.password}
+		if r.user != "" {
+			args = []string{"AUTH", r.user, r.password}
+		}
+		if _, err := conn.do(ctx, args...); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("redis auth: %w", err)
+		}
+	}
+	return conn, nil
+}
+
+// redisError is an error reply from the server.
+type redisError string
+
+func (e redisError) Error() string { return string(e) }
+
+type redisConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+// do sends a command and reads its reply.
+func (c *redisConn) do(ctx context.Context, args ...string) (any, error) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(dialTimeout)
+	}
+	c.SetDeadline(deadline)
+	defer c.SetDeadline(time.Time{})
+	if err := c.send(args...); err != nil {
+		return nil, err
+	}
+	return c.read()
+}
+
+// send writes a command as an array of bulk strings.
+func (c *redisConn) send(args ...string) error {
+	buf := fmt.Appendf(nil, "*%d\r\n", len(args))
+	for _, arg := range args {
+		buf = fmt.Appendf(buf, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	_, err := c.Write(buf)
+	return err
+}
+
+// read reads a reply: a string, an integer, nil, a redisError, or a slice of
+// those.
+func (c *redisConn) read() (any, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, fmt.Errorf("malformed reply %q", line)
+	}
+	kind, rest := line[0], line[1:len(line)-2]
+	switch kind {
+	case '+':
+		return rest, nil
+	case '-':
+		return nil, redisError(rest)
+	case ':':
+		return strconv.ParseInt(rest, 10, 64)
+	case '$':
+		n, err := strconv.Atoi(rest)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(c.r, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(rest)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		items := make([]any, n)
+		for i := range items {
+			if items[i], err = c.read(); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("unexpected reply %q", line)
+}
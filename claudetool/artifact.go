@@ -0,0 +1,117 @@
+package claudetool
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"shelley.exe.dev/filepolicy"
+	"shelley.exe.dev/llm"
+	"shelley.exe.dev/remote"
+)
+
+// PublishArtifactTool publishes a file, such as a build output, as an
+// artifact of the conversation that the user can download.
+type PublishArtifactTool struct {
+	// WorkingDir is the shared mutable working directory.
+	WorkingDir *MutableWorkingDir
+	// Policy, if set, decides which files may be published. It isn't
+	// applied to remote files.
+	Policy *filepolicy.Policy
+	// Remote, if set, is the shell of the host or container whose files the tool publishes.
+	Remote remote.Shell
+	// Publish stores content as an artifact named name, and returns a
+	// description of it for the agent. It enforces the size limits.
+	Publish func(ctx context.Context, name string, content io.Reader) (string, error)
+}
+
+const (
+	publishArtifactName        = "publish_artifact"
+	publishArtifactDescription = `Publish a file as an artifact of this conversation, for the user to download:
+a binary, a report, an archive of several files.
+
+Use this when the user asked for a build output or something they will want
+to keep, not for files that are only part of the work. Artifacts have a size
+limit and expire after a while; zip or tar several files into one first.
+`
+	publishArtifactInputSchema = `{
+  "type": "object",
+  "required": ["path"],
+  "properties": {
+    "path": {
+      "type": "string",
+      "description": "The file to publish (absolute or relative to the working directory)"
+    },
+    "name": {
+      "type": "string",
+      "description": "The file name the user downloads it as; defaults to the file's name"
+    }
+  }
+}`
+)
+
+type publishArtifactInput struct {
+	Path string `json:"path"`
+	Name string `json:"name"`
+}
+
+// Tool returns an llm.Tool for publishing artifacts.
+func (p *PublishArtifactTool) Tool() *llm.Tool {
+	return &llm.Tool{
+		Name:        publishArtifactName,
+		Description: publishArtifactDescription,
+		InputSchema: llm.MustSchema(publishArtifactInputSchema),
+		Run:         p.Run,
+	}
+}
+
+// Run executes the publish_artifact tool.
+func (p *PublishArtifactTool) Run(ctx context.Context, m json.RawMessage) llm.ToolOut {
+	var req publishArtifactInput
+	if err := json.Unmarshal(m, &req); err != nil {
+		return llm.ErrorfToolOut("failed to parse publish_artifact input: %w", err)
+	}
+	if req.Path == "" {
+		return llm.ErrorfToolOut("path is required")
+	}
+	path := req.Path
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(p.WorkingDir.Get(), path)
+	}
+	name := filepath.Base(strings.TrimSpace(req.Name))
+	if name == "." || name == string(filepath.Separator) {
+		name = filepath.Base(path)
+	}
+
+	var content io.Reader
+	if p.Remote != nil {
+		data, err := p.Remote.ReadFile(ctx, path)
+		if err != nil {
+			return llm.ErrorfToolOut("failed to read %s: %w", path, err)
+		}
+		content = bytes.NewReader(data)
+	} else {
+		if err := p.Policy.CheckRead(path); err != nil {
+			return llm.ErrorfToolOut("failed to read %s: %w", path, err)
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return llm.ErrorfToolOut("failed to read %s: %w", path, err)
+		}
+		defer f.Close()
+		if info, err := f.Stat(); err != nil || !info.Mode().IsRegular() {
+			return llm.ErrorfToolOut("%s is not a file", path)
+		}
+		content = f
+	}
+
+	result, err := p.Publish(ctx, name, content)
+	if err != nil {
+		return llm.ErrorfToolOut("failed to publish %s: %w", path, err)
+	}
+	return llm.ToolOut{LLMContent: llm.TextContent(result)}
+}
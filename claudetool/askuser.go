@@ -0,0 +1,76 @@
+package claudetool
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"shelley.exe.dev/llm"
+)
+
+// AskUserTool lets the agent ask the user a question mid-turn and wait for the answer.
+type AskUserTool struct {
+	// Ask shows the question to the user and blocks until they answer or ctx is done.
+	Ask func(ctx context.Context, question Question) (string, error)
+}
+
+// Question is a question the agent asks the user.
+type Question struct {
+	Question string `json:"question"`
+	// Options are suggested answers; the user may still answer freely.
+	Options []string `json:"options,omitempty"`
+}
+
+const (
+	askUserName        = "ask_user"
+	askUserDescription = `Ask the user a clarifying question and wait for their answer.
+
+Use this when the request is ambiguous and a wrong guess would waste significant work,
+or when a decision is the user's to make. Do not ask about things you can find out
+yourself by reading code or running commands. Ask one focused question at a time.
+
+If there are a few likely answers, list them as options; the user may still answer freely.
+`
+	askUserInputSchema = `{
+  "type": "object",
+  "required": ["question"],
+  "properties": {
+    "question": {
+      "type": "string",
+      "description": "The question to ask"
+    },
+    "options": {
+      "type": "array",
+      "items": {"type": "string"},
+      "description": "Suggested answers"
+    }
+  }
+}`
+)
+
+// Tool returns an llm.Tool for asking the user questions.
+func (a *AskUserTool) Tool() *llm.Tool {
+	return &llm.Tool{
+		Name:        askUserName,
+		Description: askUserDescription,
+		InputSchema: llm.MustSchema(askUserInputSchema),
+		Run:         a.Run,
+	}
+}
+
+// Run executes the ask_user tool.
+func (a *AskUserTool) Run(ctx context.Context, m json.RawMessage) llm.ToolOut {
+	var q Question
+	if err := json.Unmarshal(m, &q); err != nil {
+		return llm.ErrorfToolOut("failed to parse ask_user input: %w", err)
+	}
+	q.Question = strings.TrimSpace(q.Question)
+	if q.Question == "" {
+		return llm.ErrorfToolOut("question is required")
+	}
+	answer, err := a.Ask(ctx, q)
+	if err != nil {
+		return llm.ErrorfToolOut("the user did not answer: %w", err)
+	}
+	return llm.ToolOut{LLMContent: llm.TextContent(answer)}
+}
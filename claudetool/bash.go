@@ -14,11 +14,13 @@ import (
 	"slices"
 	"strings"
 	"sync"
-	"syscall"
 	"time"
+	"unicode/utf8"
 
 	"shelley.exe.dev/claudetool/bashkit"
 	"shelley.exe.dev/llm"
+	"shelley.exe.dev/remote"
+	"shelley.exe.dev/sandbox"
 )
 
 // PermissionCallback is a function type for checking if a command is allowed to run
@@ -32,10 +34,34 @@ type BashTool struct {
 	EnableJITInstall bool
 	// Timeouts holds the configurable timeout values (uses defaults if nil)
 	Timeouts *Timeouts
+	// Output limits how much of a foreground command's output is returned
+	// (uses defaults if nil)
+	Output *OutputLimits
 	// WorkingDir is the shared mutable working directory.
 	WorkingDir *MutableWorkingDir
 	// LLMProvider provides access to LLM services for tool validation
 	LLMProvider LLMServiceProvider
+	// RunDir, if set, holds records of running foreground commands so that
+	// their outcome can be recovered after a server restart (see LoadBashRun)
+	RunDir string
+	// TrackChanges, if set, is called before a foreground command runs in
+	// dir. The function it returns is called once the command has exited,
+	// so the caller can record the files the command changed.
+	TrackChanges func(ctx context.Context, dir string) func()
+	// Sandbox, if set, runs commands under bubblewrap with this policy.
+	Sandbox *sandbox.Policy
+	// Remote, if set, runs commands through this shell, on another host or
+	// in a container, in the working directory there. It takes precedence
+	// over Sandbox.
+	Remote remote.Shell
+	// OnOutput, if set, is called with a foreground command's output as the
+	// command writes it, so it can be shown while the command runs.
+	OnOutput func(ctx context.Context, chunk string)
+	// Env holds KEY=value variables added to the environment of commands.
+	Env []string
+	// Processes, if set, records the process groups of running foreground
+	// commands, so that they can be reaped if the server crashes.
+	Processes *ProcessRegistry
 }
 
 const (
@@ -54,6 +80,57 @@ type Timeouts struct {
 	Background time.Duration // background commands (e.g., servers, long-running processes)
 }
 
+// OutputLimits controls how much of a foreground command's output is
+// returned to the agent. Longer output is cut down to MaxBytes, keeping the
+// part named by Truncate, and marked as truncated. Without MaxBytes, output
+// over DefaultMaxOutputBytes is cut down to 2*defaultSnipBytes, the first and
+// last 4kB by default.
+type OutputLimits struct {
+	MaxBytes int    // zero means the defaults above
+	Truncate string // TruncateHeadTail (default), TruncateHead or TruncateTail
+}
+
+const (
+	DefaultMaxOutputBytes = 128 * 1024
+	defaultSnipBytes      = 4 * 1024
+	// maxOutputBytesLimit caps max_output_bytes requested by the agent.
+	maxOutputBytesLimit = 1024 * 1024
+
+	TruncateHeadTail = "head_tail" // keep the start and the end
+	TruncateHead     = "head"      // keep the start
+	TruncateTail     = "tail"      // keep the end
+)
+
+// ValidTruncate reports whether s names a truncation strategy.
+func ValidTruncate(s string) bool {
+	return s == TruncateHeadTail || s == TruncateHead || s == TruncateTail
+}
+
+// maxBytes returns l's output limit, or DefaultMaxOutputBytes if unset.
+func (l *OutputLimits) maxBytes() int {
+	if l == nil || l.MaxBytes <= 0 {
+		return DefaultMaxOutputBytes
+	}
+	return l.MaxBytes
+}
+
+// keepBytes returns how much of output over l's limit is kept: the limit, or
+// 2*defaultSnipBytes if unset.
+func (l *OutputLimits) keepBytes() int {
+	if l == nil || l.MaxBytes <= 0 {
+		return 2 * defaultSnipBytes
+	}
+	return l.MaxBytes
+}
+
+// truncate returns l's truncation strategy, or TruncateHeadTail if unset.
+func (l *OutputLimits) truncate() string {
+	if l == nil || l.Truncate == "" {
+		return TruncateHeadTail
+	}
+	return l.Truncate
+}
+
 // Fast returns t's fast timeout, or DefaultFastTimeout if t is nil.
 func (t *Timeouts) fast() time.Duration {
 	if t == nil {
@@ -82,7 +159,7 @@ func (t *Timeouts) background() time.Duration {
 func (b *BashTool) Tool() *llm.Tool {
 	return &llm.Tool{
 		Name:        bashName,
-		Description: fmt.Sprintf(strings.TrimSpace(bashDescription), b.getWorkingDir()),
+		Description: fmt.Sprintf(strings.TrimSpace(bashDescription), b.Output.maxBytes(), b.Output.keepBytes(), b.getWorkingDir()),
 		InputSchema: llm.MustSchema(bashInputSchema),
 		Run:         b.Run,
 	}
@@ -104,8 +181,14 @@ Use background for servers/demos that need to stay running.
 
 MUST set slow_ok=true for potentially slow commands: builds, downloads,
 installs, tests, or any other substantive operation.
+Set timeout_seconds if a command needs a specific time limit.
+
+Output over %d bytes is truncated to %d bytes, keeping its start and end.
+Set max_output_bytes and truncate ("head", "tail" or "head_tail") to see
+more or a different part, or redirect output to a file and search it.
 
 To change the working directory persistently, use the change_dir tool.
+To list files, use the list_files tool rather than find or ls -R.
 
 IMPORTANT: Keep commands concise. The command input must be less than 60k tokens.
 For complex scripts, write them to a file first and then execute the file.
@@ -129,6 +212,19 @@ For complex scripts, write them to a file first and then execute the file.
     "background": {
       "type": "boolean",
       "description": "Execute in background"
+    },
+    "timeout_seconds": {
+      "type": "integer",
+      "description": "Time limit in seconds, overriding the default"
+    },
+    "max_output_bytes": {
+      "type": "integer",
+      "description": "Output size limit in bytes, overriding the default"
+    },
+    "truncate": {
+      "type": "string",
+      "enum": ["head_tail", "head", "tail"],
+      "description": "Part of long output to keep"
     }
   }
 }
@@ -136,9 +232,12 @@ For complex scripts, write them to a file first and then execute the file.
 )
 
 type bashInput struct {
-	Command    string `json:"command"`
-	SlowOK     bool   `json:"slow_ok,omitempty"`
-	Background bool   `json:"background,omitempty"`
+	Command        string `json:"command"`
+	SlowOK         bool   `json:"slow_ok,omitempty"`
+	Background     bool   `json:"background,omitempty"`
+	TimeoutSeconds int    `json:"timeout_seconds,omitempty"`
+	MaxOutputBytes int    `json:"max_output_bytes,omitempty"`
+	Truncate       string `json:"truncate,omitempty"`
 }
 
 type BackgroundResult struct {
@@ -151,8 +250,12 @@ func (r *BackgroundResult) XMLish() string {
 		r.PID, r.OutFile, r.PID)
 }
 
+// timeout returns the command's time limit. A timeout_seconds override is
+// capped at the background timeout.
 func (i *bashInput) timeout(t *Timeouts) time.Duration {
 	switch {
+	case i.TimeoutSeconds > 0:
+		return min(time.Duration(i.TimeoutSeconds)*time.Second, t.background())
 	case i.Background:
 		return t.background()
 	case i.SlowOK:
@@ -162,6 +265,28 @@ func (i *bashInput) timeout(t *Timeouts) time.Duration {
 	}
 }
 
+// outputLimits returns the output limits for the command, applying its
+// overrides to l.
+func (i *bashInput) outputLimits(l *OutputLimits) (*OutputLimits, error) {
+	limits := &OutputLimits{Truncate: l.truncate()}
+	if l != nil {
+		limits.MaxBytes = l.MaxBytes
+	}
+	if i.MaxOutputBytes < 0 {
+		return nil, fmt.Errorf("max_output_bytes must be positive")
+	}
+	if i.MaxOutputBytes > 0 {
+		limits.MaxBytes = min(i.MaxOutputBytes, maxOutputBytesLimit)
+	}
+	if i.Truncate != "" {
+		if !ValidTruncate(i.Truncate) {
+			return nil, fmt.Errorf("invalid truncate %q: must be head_tail, head or tail", i.Truncate)
+		}
+		limits.Truncate = i.Truncate
+	}
+	return limits, nil
+}
+
 func (b *BashTool) Run(ctx context.Context, m json.RawMessage) llm.ToolOut {
 	var req bashInput
 	if err := json.Unmarshal(m, &req); err != nil {
@@ -170,7 +295,7 @@ func (b *BashTool) Run(ctx context.Context, m json.RawMessage) llm.ToolOut {
 
 	// Check that the working directory exists
 	wd := b.getWorkingDir()
-	if _, err := os.Stat(wd); err != nil {
+	if err := b.statWorkingDir(ctx, wd); err != nil {
 		if os.IsNotExist(err) {
 			return llm.ErrorfToolOut("working directory does not exist: %s (use change_dir to switch to a valid directory)", wd)
 		}
@@ -183,6 +308,11 @@ func (b *BashTool) Run(ctx context.Context, m json.RawMessage) llm.ToolOut {
 		return llm.ErrorToolOut(err)
 	}
 
+	limits, err := req.outputLimits(b.Output)
+	if err != nil {
+		return llm.ErrorToolOut(err)
+	}
+
 	// Custom permission callback if set
 	if b.CheckPermission != nil {
 		if err := b.CheckPermission(req.Command); err != nil {
@@ -213,15 +343,16 @@ func (b *BashTool) Run(ctx context.Context, m json.RawMessage) llm.ToolOut {
 	}
 
 	// For foreground commands, use executeBash
-	out, execErr := b.executeBash(ctx, req, timeout)
+	if b.TrackChanges != nil {
+		defer b.TrackChanges(ctx, wd)()
+	}
+	out, execErr := b.executeBash(ctx, req, timeout, limits)
 	if execErr != nil {
 		return llm.ErrorToolOut(execErr)
 	}
 	return llm.ToolOut{LLMContent: llm.TextContent(out)}
 }
 
-const maxBashOutputLength = 131072
-
 func (b *BashTool) makeBashCommand(ctx context.Context, command string, out io.Writer) *exec.Cmd {
 	cmd := exec.CommandContext(ctx, "bash", "-c", command)
 	// Use shared WorkingDir if available, then context, then Pwd fallback
@@ -229,16 +360,7 @@ func (b *BashTool) makeBashCommand(ctx context.Context, command string, out io.W
 	cmd.Stdin = nil
 	cmd.Stdout = out
 	cmd.Stderr = out
-	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true} // set up for killing the process group
-	cmd.Cancel = func() error {
-		if cmd.Process == nil {
-			// Process hasn't started yet.
-			// Not sure whether this is possible in practice,
-			// but it is possible in theory, and it doesn't hurt to handle it gracefully.
-			return nil
-		}
-		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL) // kill entire process group
-	}
+	inProcessGroup(cmd)
 	cmd.WaitDelay = 15 * time.Second // prevent indefinite hangs when child processes keep pipes open
 	// Remove SKETCH_MODEL_URL, SKETCH_PUB_KEY, SKETCH_MODEL_API_KEY,
 	// and any other future SKETCH_ goodies from the environment.
@@ -248,10 +370,63 @@ func (b *BashTool) makeBashCommand(ctx context.Context, command string, out io.W
 	})
 	env = append(env, "SKETCH=1")          // signal that this has been run by Sketch, sometimes useful for scripts
 	env = append(env, "EDITOR=/bin/false") // interactive editors won't work
+	env = append(env, b.Env...)
 	cmd.Env = env
 	return cmd
 }
 
+// RunCommand runs command as the tool runs foreground commands, in the
+// working directory with the tool's environment, sandbox or remote shell,
+// but without its output limits, and returns its combined output. The slow
+// timeout applies. It is for commands the agent didn't ask for, such as a
+// project's setup commands.
+func (b *BashTool) RunCommand(ctx context.Context, command string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, b.Timeouts.slow())
+	defer cancel()
+	var out bytes.Buffer
+	cmd := b.makeBashCommand(ctx, command, &out)
+	b.wrap(cmd)
+	if err := cmd.Start(); err != nil {
+		return "", err
+	}
+	if err := b.Processes.add(cmd.Process.Pid, command); err != nil {
+		slog.WarnContext(ctx, "failed to register command", "error", err)
+	}
+	err := cmd.Wait()
+	b.Processes.remove(cmd.Process.Pid)
+	if ctx.Err() == context.DeadlineExceeded {
+		err = fmt.Errorf("timed out after %s", b.Timeouts.slow())
+	}
+	return out.String(), err
+}
+
+// wrap makes cmd, once its environment is complete, run on the remote host
+// or in the sandbox, if either is set. A failed wrap is reported by
+// cmd.Start, so the command never runs unsandboxed or on this machine.
+func (b *BashTool) wrap(cmd *exec.Cmd) {
+	var err error
+	switch {
+	case b.Remote != nil:
+		err = b.Remote.Wrap(cmd)
+	case b.Sandbox != nil:
+		err = b.Sandbox.Wrap(cmd)
+	}
+	if err != nil {
+		cmd.Err = err
+	}
+}
+
+// statWorkingDir checks that the working directory exists, on the remote
+// host if there is one.
+func (b *BashTool) statWorkingDir(ctx context.Context, wd string) error {
+	if b.Remote != nil {
+		_, err := b.Remote.IsDir(ctx, wd)
+		return err
+	}
+	_, err := os.Stat(wd)
+	return err
+}
+
 func cmdWait(cmd *exec.Cmd) error {
 	err := cmd.Wait()
 	// We used to kill the process group here, but it's not clear that
@@ -261,24 +436,71 @@ func cmdWait(cmd *exec.Cmd) error {
 	return err
 }
 
-func (b *BashTool) executeBash(ctx context.Context, req bashInput, timeout time.Duration) (string, error) {
+func (b *BashTool) executeBash(ctx context.Context, req bashInput, timeout time.Duration, limits *OutputLimits) (string, error) {
 	execCtx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
+	run, err := startRunRecord(b.RunDir, ToolUseID(ctx), req.Command)
+	if err != nil {
+		slog.WarnContext(ctx, "failed to record bash run", "error", err)
+	}
+
 	output := new(bytes.Buffer)
-	cmd := b.makeBashCommand(execCtx, req.Command, output)
+	var w io.Writer = output
+	if run != nil {
+		defer run.remove()
+		w = run.out
+	}
+	if b.OnOutput != nil {
+		send := func(chunk string) { b.OnOutput(ctx, chunk) }
+		if run != nil {
+			// Follow the record's output file, so the command still writes
+			// straight to a file and background children can't hold up Wait
+			if stream, err := tailOutputStream(run.out.Name(), send); err != nil {
+				slog.WarnContext(ctx, "failed to stream bash output", "error", err)
+			} else {
+				defer stream.Close()
+			}
+		} else {
+			stream := newOutputStream(send)
+			defer stream.Close()
+			w = io.MultiWriter(w, stream)
+		}
+	}
+	cmd := b.makeBashCommand(execCtx, req.Command, w)
 	// TODO: maybe detect simple interactive git rebase commands and auto-background them?
 	// Would need to hint to the agent what is happening.
 	// We might also be able to do this for other simple interactive commands that use EDITOR.
 	cmd.Env = append(cmd.Env, `GIT_SEQUENCE_EDITOR=echo "To do an interactive rebase, run it as a background task and check the output file." && exit 1`)
+	b.wrap(cmd)
+	if run != nil {
+		// The record is kept here, so the exit status is recorded outside the wrap
+		run.wrap(cmd)
+	}
 	if err := cmd.Start(); err != nil {
 		return "", fmt.Errorf("command failed: %w", err)
 	}
+	if run != nil {
+		if err := run.started(cmd.Process.Pid); err != nil {
+			slog.WarnContext(ctx, "failed to record bash pid", "error", err)
+		}
+	}
+	if err := b.Processes.add(cmd.Process.Pid, req.Command); err != nil {
+		slog.WarnContext(ctx, "failed to register command", "error", err)
+	}
 
-	err := cmdWait(cmd)
+	err = cmdWait(cmd)
+	b.Processes.remove(cmd.Process.Pid)
 
 	out := output.String()
-	out = formatForegroundBashOutput(out)
+	size := len(out)
+	if run != nil {
+		var readErr error
+		if out, size, readErr = run.output(limits.maxBytes()); readErr != nil {
+			return "", fmt.Errorf("failed to read command output: %w", readErr)
+		}
+	}
+	out = formatBashOutput(out, size, limits)
 
 	if execCtx.Err() == context.DeadlineExceeded {
 		return "", fmt.Errorf("[command timed out after %s, showing output until timeout]\n%s", timeout, out)
@@ -290,16 +512,53 @@ func (b *BashTool) executeBash(ctx context.Context, req bashInput, timeout time.
 	return out, nil
 }
 
-// formatForegroundBashOutput formats the output of a foreground bash command for display to the agent.
-func formatForegroundBashOutput(out string) string {
-	if len(out) > maxBashOutputLength {
-		const snipSize = 4096
-		out = fmt.Sprintf("[output truncated in middle: got %v, max is %v]\n%s\n\n[snip]\n\n%s",
-			humanizeBytes(len(out)), humanizeBytes(maxBashOutputLength),
-			out[:snipSize], out[len(out)-snipSize:],
-		)
+// formatForegroundBashOutput formats the output of a foreground bash command
+// for display to the agent, truncating it to the limits.
+func formatForegroundBashOutput(out string, limits *OutputLimits) string {
+	return formatBashOutput(out, len(out), limits)
+}
+
+// formatBashOutput is formatForegroundBashOutput for output of size bytes, of
+// which out may hold only the first and last maximum bytes, as read by
+// readOutput.
+func formatBashOutput(out string, size int, limits *OutputLimits) string {
+	if size <= limits.maxBytes() {
+		return out
+	}
+	keep := limits.keepBytes()
+	const hint = "set max_output_bytes or truncate, or redirect output to a file and search it"
+	switch limits.truncate() {
+	case TruncateHead:
+		head := headBytes(out, keep)
+		return fmt.Sprintf("%s\n\n[output truncated: showing the first %s of %s; %s]",
+			head, humanizeBytes(len(head)), humanizeBytes(size), hint)
+	case TruncateTail:
+		tail := tailBytes(out, keep)
+		return fmt.Sprintf("[output truncated: showing the last %s of %s; %s]\n%s",
+			humanizeBytes(len(tail)), humanizeBytes(size), hint, tail)
+	default:
+		head, tail := headBytes(out, keep/2), tailBytes(out, keep-keep/2)
+		return fmt.Sprintf("[output truncated in middle: showing the first %s and last %s of %s; %s]\n%s\n\n[... %s omitted ...]\n\n%s",
+			humanizeBytes(len(head)), humanizeBytes(len(tail)), humanizeBytes(size), hint,
+			head, humanizeBytes(size-len(head)-len(tail)), tail)
+	}
+}
+
+// headBytes returns at most the first n bytes of s, without splitting a UTF-8 sequence.
+func headBytes(s string, n int) string {
+	for n > 0 && n < len(s) && !utf8.RuneStart(s[n]) {
+		n--
 	}
-	return out
+	return s[:n]
+}
+
+// tailBytes returns at most the last n bytes of s, without splitting a UTF-8 sequence.
+func tailBytes(s string, n int) string {
+	start := len(s) - n
+	for start < len(s) && !utf8.RuneStart(s[start]) {
+		start++
+	}
+	return s[start:]
 }
 
 func humanizeBytes(bytes int) string {
@@ -318,6 +577,10 @@ func humanizeBytes(bytes int) string {
 
 // executeBackgroundBash executes a command in the background and returns the pid and output file locations
 func (b *BashTool) executeBackgroundBash(ctx context.Context, req bashInput, timeout time.Duration) (*BackgroundResult, error) {
+	if b.Remote != nil {
+		// The PID and output file would be this machine's, out of the agent's reach
+		return nil, fmt.Errorf("background commands are not supported in a remote workspace; run the command with nohup, redirecting its output to a file")
+	}
 	// Create temp output files
 	tmpDir, err := os.MkdirTemp("", "sketch-bg-")
 	if err != nil {
@@ -335,6 +598,7 @@ func (b *BashTool) executeBackgroundBash(ctx context.Context, req bashInput, tim
 	execCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), timeout) // detach from tool use context
 	cmd := b.makeBashCommand(execCtx, req.Command, out)
 	cmd.Env = append(cmd.Env, `GIT_SEQUENCE_EDITOR=python3 -c "import os, sys, signal, threading; print(f\"Send USR1 to pid {os.getpid()} after editing {sys.argv[1]}\", flush=True); signal.signal(signal.SIGUSR1, lambda *_: sys.exit(0)); threading.Event().wait()"`)
+	b.wrap(cmd)
 
 	if err := cmd.Start(); err != nil {
 		cancel()
@@ -546,6 +810,7 @@ func (b *BashTool) installPackage(ctx context.Context, cmd, packageName, package
 	if updateCmd != "" {
 		slog.InfoContext(ctx, "updating package cache", "command", updateCmd)
 		updateCmdExec := exec.CommandContext(ctx, "sh", "-c", updateCmd)
+		inProcessGroup(updateCmdExec)
 		updateOutput, err := updateCmdExec.CombinedOutput()
 		if err != nil {
 			slog.WarnContext(ctx, "package cache update failed, proceeding with install anyway", "error", err, "output", string(updateOutput))
@@ -554,6 +819,7 @@ func (b *BashTool) installPackage(ctx context.Context, cmd, packageName, package
 
 	// Execute the install command
 	cmdExec := exec.CommandContext(ctx, "sh", "-c", installCmd)
+	inProcessGroup(cmdExec)
 	output, err := cmdExec.CombinedOutput()
 	if err != nil {
 		return fmt.Errorf("failed to install %s: %w\nOutput: %s", packageName, err, string(output))
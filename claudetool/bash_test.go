@@ -3,12 +3,17 @@ package claudetool
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"syscall"
 	"testing"
 	"time"
+
+	"shelley.exe.dev/remote"
+	"shelley.exe.dev/sandbox"
 )
 
 func TestBashSlowOk(t *testing.T) {
@@ -186,7 +191,7 @@ func TestExecuteBash(t *testing.T) {
 			Command: "echo 'Success'",
 		}
 
-		output, err := bashTool.executeBash(ctx, req, 5*time.Second)
+		output, err := bashTool.executeBash(ctx, req, 5*time.Second, nil)
 		if err != nil {
 			t.Fatalf("Unexpected error: %v", err)
 		}
@@ -203,7 +208,7 @@ func TestExecuteBash(t *testing.T) {
 			Command: "echo $SKETCH",
 		}
 
-		output, err := bashTool.executeBash(ctx, req, 5*time.Second)
+		output, err := bashTool.executeBash(ctx, req, 5*time.Second, nil)
 		if err != nil {
 			t.Fatalf("Unexpected error: %v", err)
 		}
@@ -220,7 +225,7 @@ func TestExecuteBash(t *testing.T) {
 			Command: "echo 'Error message' >&2 && echo 'Success'",
 		}
 
-		output, err := bashTool.executeBash(ctx, req, 5*time.Second)
+		output, err := bashTool.executeBash(ctx, req, 5*time.Second, nil)
 		if err != nil {
 			t.Fatalf("Unexpected error: %v", err)
 		}
@@ -237,7 +242,7 @@ func TestExecuteBash(t *testing.T) {
 			Command: "echo 'Error message' >&2 && exit 1",
 		}
 
-		_, err := bashTool.executeBash(ctx, req, 5*time.Second)
+		_, err := bashTool.executeBash(ctx, req, 5*time.Second, nil)
 		if err == nil {
 			t.Errorf("Expected error for failed command, got none")
 		} else if !strings.Contains(err.Error(), "Error message") {
@@ -252,7 +257,7 @@ func TestExecuteBash(t *testing.T) {
 		}
 
 		start := time.Now()
-		_, err := bashTool.executeBash(ctx, req, 100*time.Millisecond)
+		_, err := bashTool.executeBash(ctx, req, 100*time.Millisecond, nil)
 		elapsed := time.Since(start)
 
 		// Command should time out after ~100ms, not wait for full 1 second
@@ -268,6 +273,100 @@ func TestExecuteBash(t *testing.T) {
 	})
 }
 
+func TestBashSandbox(t *testing.T) {
+	ctx := context.Background()
+	tempDir := t.TempDir()
+	bashTool := &BashTool{
+		WorkingDir: NewMutableWorkingDir(tempDir),
+		Sandbox:    &sandbox.Policy{Writable: []string{tempDir}},
+	}
+
+	if !sandbox.Available() {
+		// Commands must not run unsandboxed
+		_, err := bashTool.executeBash(ctx, bashInput{Command: "touch ran"}, 5*time.Second, nil)
+		if err == nil || !strings.Contains(err.Error(), "sandbox unavailable") {
+			t.Errorf("expected sandbox unavailable error, got %v", err)
+		}
+		if _, err := os.Stat(filepath.Join(tempDir, "ran")); !os.IsNotExist(err) {
+			t.Errorf("command ran without a sandbox: %v", err)
+		}
+		return
+	}
+
+	if _, err := bashTool.executeBash(ctx, bashInput{Command: "echo ok > inside.txt"}, 5*time.Second, nil); err != nil {
+		t.Errorf("write inside the workspace failed: %v", err)
+	}
+	if _, err := bashTool.executeBash(ctx, bashInput{Command: "echo no > ../outside-" + filepath.Base(tempDir)}, 5*time.Second, nil); err == nil {
+		t.Error("write outside the workspace succeeded")
+	}
+	if _, err := bashTool.executeBash(ctx, bashInput{Command: "echo ok > /tmp/scratch && cat /tmp/scratch"}, 5*time.Second, nil); err != nil {
+		t.Errorf("write to /tmp failed: %v", err)
+	}
+}
+
+// fakeSSH puts an ssh on PATH that logs its destination to the returned
+// file and runs the script locally.
+func fakeSSH(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	log := filepath.Join(dir, "log")
+	script := "#!/bin/sh\nwhile [ \"$1\" != -- ]; do shift; done\necho \"$2\" >> " + log + "\nexec sh -c \"$3\"\n"
+	if err := os.WriteFile(filepath.Join(dir, "ssh"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return log
+}
+
+func TestBashRemote(t *testing.T) {
+	log := fakeSSH(t)
+	ctx := WithToolUseID(context.Background(), "toolu_remote")
+	work := t.TempDir()
+	bashTool := &BashTool{
+		WorkingDir: NewMutableWorkingDir(work),
+		RunDir:     t.TempDir(),
+		Remote:     (&remote.Host{Target: "build-box"}).Shell(),
+	}
+
+	out, err := bashTool.executeBash(ctx, bashInput{Command: "pwd; echo $SKETCH"}, 5*time.Second, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != work+"\n1\n" {
+		t.Errorf("unexpected output %q", out)
+	}
+	if _, err := bashTool.executeBash(ctx, bashInput{Command: "exit 3"}, 5*time.Second, nil); err == nil || !strings.Contains(err.Error(), "exit status 3") {
+		t.Errorf("expected the remote exit status, got %v", err)
+	}
+	if data, err := os.ReadFile(log); err != nil || strings.Count(string(data), "build-box\n") != 2 {
+		t.Errorf("expected both commands to run over ssh, got %q, %v", data, err)
+	}
+
+	if _, err := bashTool.executeBackgroundBash(ctx, bashInput{Command: "sleep 1"}, time.Minute); err == nil {
+		t.Error("expected background commands to be refused")
+	}
+	bashTool.WorkingDir.Set(filepath.Join(work, "missing"))
+	if out := bashTool.Run(ctx, json.RawMessage(`{"command":"true"}`)); out.Error == nil || !strings.Contains(out.Error.Error(), "does not exist") {
+		t.Errorf("expected a missing working directory error, got %v", out.Error)
+	}
+}
+
+func TestBashRunCommand(t *testing.T) {
+	work := t.TempDir()
+	bashTool := &BashTool{
+		WorkingDir: NewMutableWorkingDir(work),
+		Env:        []string{"GREETING=hello there"},
+	}
+	out, err := bashTool.RunCommand(context.Background(), `pwd; echo "$GREETING"`)
+	if err != nil || out != work+"\nhello there\n" {
+		t.Errorf("RunCommand = %q, %v", out, err)
+	}
+	out, err = bashTool.RunCommand(context.Background(), "echo oops >&2; exit 2")
+	if err == nil || out != "oops\n" {
+		t.Errorf("expected the failure and its output, got %q, %v", out, err)
+	}
+}
+
 func TestBackgroundBash(t *testing.T) {
 	bashTool := &BashTool{WorkingDir: NewMutableWorkingDir("/")}
 	tool := bashTool.Tool()
@@ -525,6 +624,84 @@ func TestBashTimeout(t *testing.T) {
 			t.Errorf("Expected custom timeout to be %v, got %v", expectedCustom, customTimeout)
 		}
 	})
+
+	t.Run("Per-Call Timeout", func(t *testing.T) {
+		override := bashInput{Command: "make test", TimeoutSeconds: 90}
+		if got := override.timeout(nil); got != 90*time.Second {
+			t.Errorf("Expected timeout_seconds to set the timeout to 90s, got %v", got)
+		}
+		capped := bashInput{Command: "make test", TimeoutSeconds: 7200}
+		if got := capped.timeout(&Timeouts{Fast: time.Second, Slow: time.Minute, Background: time.Hour}); got != time.Hour {
+			t.Errorf("Expected timeout_seconds to be capped at the background timeout, got %v", got)
+		}
+	})
+}
+
+func TestFormatForegroundBashOutput(t *testing.T) {
+	out := strings.Repeat("a", 10) + strings.Repeat("b", 10)
+	tests := []struct {
+		name     string
+		limits   *OutputLimits
+		contains []string
+		excludes []string
+	}{
+		{"short output is unchanged", &OutputLimits{MaxBytes: 20}, []string{out}, []string{"truncated"}},
+		{"head_tail keeps both ends", &OutputLimits{MaxBytes: 4}, []string{"truncated in middle", "aa\n", "\nbb", "16B omitted"}, nil},
+		{"head keeps the start", &OutputLimits{MaxBytes: 4, Truncate: TruncateHead}, []string{"aaaa\n", "first 4B of 20B"}, []string{"bb"}},
+		{"tail keeps the end", &OutputLimits{MaxBytes: 4, Truncate: TruncateTail}, []string{"\nbbbb", "last 4B of 20B"}, []string{"aa"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := formatForegroundBashOutput(out, tt.limits)
+			for _, want := range tt.contains {
+				if !strings.Contains(got, want) {
+					t.Errorf("expected %q in %q", want, got)
+				}
+			}
+			for _, unwanted := range tt.excludes {
+				if strings.Contains(got, unwanted) {
+					t.Errorf("did not expect %q in %q", unwanted, got)
+				}
+			}
+		})
+	}
+
+	// Truncation does not split multi-byte characters
+	got := formatForegroundBashOutput(strings.Repeat("é", 10), &OutputLimits{MaxBytes: 5, Truncate: TruncateHead})
+	if !strings.HasPrefix(got, "éé\n") {
+		t.Errorf("expected two whole characters, got %q", got)
+	}
+
+	// Without limits, output up to DefaultMaxOutputBytes is unchanged, and
+	// longer output keeps its first and last 4kB
+	short := strings.Repeat("x", DefaultMaxOutputBytes)
+	if got := formatForegroundBashOutput(short, nil); got != short {
+		t.Errorf("expected %d bytes of output unchanged, got %d", len(short), len(got))
+	}
+	long := "START" + strings.Repeat("x", 2*DefaultMaxOutputBytes) + "END"
+	got = formatForegroundBashOutput(long, nil)
+	if !strings.Contains(got, "START"+strings.Repeat("x", 4096-5)+"\n") || !strings.Contains(got, "\n"+strings.Repeat("x", 4096-3)+"END") || len(got) > 9000 {
+		t.Errorf("expected the first and last 4kB, got %d bytes", len(got))
+	}
+}
+
+func TestBashOutputOverrides(t *testing.T) {
+	tool := &BashTool{WorkingDir: NewMutableWorkingDir(t.TempDir()), Output: &OutputLimits{MaxBytes: 4}}
+
+	result := tool.Run(context.Background(), json.RawMessage(`{"command": "seq 100", "max_output_bytes": 1000}`))
+	if result.Error != nil || strings.Contains(result.LLMContent[0].Text, "truncated") {
+		t.Errorf("expected max_output_bytes to raise the limit, got %v %v", result.Error, result.LLMContent)
+	}
+
+	result = tool.Run(context.Background(), json.RawMessage(`{"command": "seq 100", "truncate": "tail"}`))
+	if result.Error != nil || !strings.HasSuffix(result.LLMContent[0].Text, "100\n") {
+		t.Errorf("expected the end of the output, got %v %v", result.Error, result.LLMContent)
+	}
+
+	result = tool.Run(context.Background(), json.RawMessage(`{"command": "seq 100", "truncate": "middle"}`))
+	if result.Error == nil {
+		t.Error("expected an error for an invalid truncate value")
+	}
 }
 
 // waitForFile waits for a file to exist and be non-empty or times out
@@ -568,3 +745,57 @@ func waitForProcessDeath(t *testing.T, pid int) {
 		}
 	}
 }
+
+func TestBashOutputStreaming(t *testing.T) {
+	for _, withRunDir := range []bool{false, true} {
+		t.Run(fmt.Sprintf("runDir=%v", withRunDir), func(t *testing.T) {
+			var mu sync.Mutex
+			var chunks []string
+			tool := &BashTool{
+				WorkingDir: NewMutableWorkingDir(t.TempDir()),
+				OnOutput: func(ctx context.Context, chunk string) {
+					mu.Lock()
+					defer mu.Unlock()
+					chunks = append(chunks, chunk)
+				},
+			}
+			ctx := context.Background()
+			if withRunDir {
+				tool.RunDir = t.TempDir()
+				ctx = WithToolUseID(ctx, "toolu_stream")
+			}
+
+			result := tool.Run(ctx, json.RawMessage(`{"command": "echo first; sleep 0.6; echo second"}`))
+			if result.Error != nil {
+				t.Fatal(result.Error)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if len(chunks) < 2 {
+				t.Fatalf("expected output in several chunks, got %q", chunks)
+			}
+			if chunks[0] != "first\n" || strings.Join(chunks, "") != "first\nsecond\n" {
+				t.Errorf("unexpected chunks %q", chunks)
+			}
+		})
+	}
+}
+
+func TestCompleteUTF8(t *testing.T) {
+	e := []byte("é") // two bytes
+	tests := []struct {
+		in   []byte
+		want int
+	}{
+		{[]byte("abc"), 3},
+		{append([]byte("ab"), e...), 4},
+		{append([]byte("ab"), e[0]), 2},
+		{nil, 0},
+	}
+	for _, tt := range tests {
+		if got := completeUTF8(tt.in); got != tt.want {
+			t.Errorf("completeUTF8(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package claudetool
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// Foreground bash commands run with a run directory (BashTool.RunDir) leave a
+// record there while they execute: the command, the PID, the combined output,
+// and the exit status once the command finishes. The record is removed when
+// the tool returns, so one that is still present after a restart belongs to a
+// command that was interrupted — and which may nonetheless have completed,
+// since commands run in their own process group and write to a file rather
+// than a pipe back to Shelley.
+
+const (
+	runCommandFile = "command"
+	runPIDFile     = "pid"
+	runOutputFile  = "output"
+	runExitFile    = "exit"
+)
+
+// bashRunWrapper runs the command in its remaining arguments and records its
+// exit status in $1, preserving the exit status.
+const bashRunWrapper = `"${@:2}"; status=$?; echo $status > "$1"; exit $status`
+
+// bashRunRecord is the in-progress record for one foreground command.
+type bashRunRecord struct {
+	dir     string
+	command string
+	out     *os.File
+}
+
+// runRecordDir returns the directory holding the record for toolUseID.
+func runRecordDir(runDir, toolUseID string) (string, error) {
+	if toolUseID == "" || strings.ContainsAny(toolUseID, `/\`) || toolUseID == "." || toolUseID == ".." {
+		return "", fmt.Errorf("invalid tool use ID %q", toolUseID)
+	}
+	return filepath.Join(runDir, toolUseID), nil
+}
+
+// startRunRecord creates the record for a foreground command.
+// It returns nil if runDir is unset or the tool use ID is unknown.
+func startRunRecord(runDir, toolUseID, command string) (*bashRunRecord, error) {
+	if runDir == "" || toolUseID == "" {
+		return nil, nil
+	}
+	dir, err := runRecordDir(runDir, toolUseID)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create run directory: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, runCommandFile), []byte(command), 0o600); err != nil {
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("failed to record command: %w", err)
+	}
+	out, err := os.Create(filepath.Join(dir, runOutputFile))
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("failed to create output file: %w", err)
+	}
+	return &bashRunRecord{dir: dir, command: command, out: out}, nil
+}
+
+// wrap makes cmd, which may already be wrapped to run in a sandbox or on
+// another host, record its exit status in the run directory.
+func (r *bashRunRecord) wrap(cmd *exec.Cmd) {
+	bash, err := exec.LookPath("bash")
+	if err != nil {
+		cmd.Err = err
+		return
+	}
+	cmd.Args = append([]string{"bash", "-c", bashRunWrapper, "bash", filepath.Join(r.dir, runExitFile), cmd.Path}, cmd.Args[1:]...)
+	cmd.Path = bash
+}
+
+// started records the PID of the running command.
+func (r *bashRunRecord) started(pid int) error {
+	return os.WriteFile(filepath.Join(r.dir, runPIDFile), []byte(strconv.Itoa(pid)), 0o600)
+}
+
+// output returns what the command wrote, reading at most its first and last
+// maxBytes, and its size; see readOutput.
+func (r *bashRunRecord) output(maxBytes int) (string, int, error) {
+	return readOutput(r.out.Name(), maxBytes)
+}
+
+// readOutput reads a command's output file for formatBashOutput without
+// reading it whole: if it's larger than maxBytes, only its first and last
+// maxBytes are returned, joined. It also returns the output's size.
+func readOutput(path string, maxBytes int) (string, int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return "", 0, err
+	}
+	size := info.Size()
+	if size <= int64(2*maxBytes) {
+		data, err := io.ReadAll(f)
+		return string(data), len(data), err
+	}
+	buf := make([]byte, 2*maxBytes)
+	if _, err := io.ReadFull(f, buf[:maxBytes]); err != nil {
+		return "", 0, err
+	}
+	if _, err := f.ReadAt(buf[maxBytes:], size-int64(maxBytes)); err != nil {
+		return "", 0, err
+	}
+	return string(buf), int(size), nil
+}
+
+// remove deletes the record once the tool has returned.
+func (r *bashRunRecord) remove() {
+	r.out.Close()
+	os.RemoveAll(r.dir)
+}
+
+// BashRun describes a foreground bash command found in a run directory.
+type BashRun struct {
+	Command string
+	PID     int
+	// Output is what the command wrote, or if that's more than
+	// DefaultMaxOutputBytes, its first and last DefaultMaxOutputBytes.
+	Output string
+	// OutputSize is the size of everything the command wrote.
+	OutputSize int
+	// OutFile is the file the command writes its output to.
+	OutFile string
+	// ExitCode is the command's exit status, or nil if it did not finish.
+	ExitCode *int
+	// Running reports whether the command's process is still alive.
+	Running bool
+}
+
+// LoadBashRun reads the record left by the foreground bash command run for toolUseID.
+// It returns an error satisfying errors.Is(err, fs.ErrNotExist) if there is none.
+func LoadBashRun(runDir, toolUseID string) (*BashRun, error) {
+	dir, err := runRecordDir(runDir, toolUseID)
+	if err != nil {
+		return nil, err
+	}
+	command, err := os.ReadFile(filepath.Join(dir, runCommandFile))
+	if err != nil {
+		return nil, err
+	}
+	run := &BashRun{Command: string(command), OutFile: filepath.Join(dir, runOutputFile)}
+	if output, size, err := readOutput(run.OutFile, DefaultMaxOutputBytes); err == nil {
+		run.Output, run.OutputSize = output, size
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, runExitFile)); err == nil {
+		if code, err := strconv.Atoi(strings.TrimSpace(string(data))); err == nil {
+			run.ExitCode = &code
+		}
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, runPIDFile)); err == nil {
+		if pid, err := strconv.Atoi(strings.TrimSpace(string(data))); err == nil && pid > 0 {
+			run.PID = pid
+			run.Running = run.ExitCode == nil && processAlive(pid)
+		}
+	}
+	return run, nil
+}
+
+// RemoveBashRun deletes the record for toolUseID, if any.
+func RemoveBashRun(runDir, toolUseID string) error {
+	dir, err := runRecordDir(runDir, toolUseID)
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(dir)
+}
+
+// processAlive reports whether a process with the given PID exists.
+func processAlive(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
+
+// RecoveryText describes the outcome of an interrupted command for the agent,
+// reporting whether it should be treated as a tool error.
+func (r *BashRun) RecoveryText() (string, bool) {
+	out := formatBashOutput(r.Output, r.OutputSize, nil)
+	switch {
+	case r.ExitCode != nil && *r.ExitCode == 0:
+		return fmt.Sprintf("[command completed while the server was restarting]\n%s", out), false
+	case r.ExitCode != nil:
+		return fmt.Sprintf("[command failed with exit status %d while the server was restarting]\n%s", *r.ExitCode, out), true
+	case r.Running:
+		return fmt.Sprintf("[the server restarted while this command was running; it is still running as pid %d with output going to %s. To stop it: `kill -9 -%d`]\nOutput so far:\n%s", r.PID, r.OutFile, r.PID, out), true
+	default:
+		return fmt.Sprintf("[command was interrupted by a server restart before it finished]\nOutput so far:\n%s", out), true
+	}
+}
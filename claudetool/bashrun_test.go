@@ -0,0 +1,133 @@
+package claudetool
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBashRunRecordRemovedAfterRun(t *testing.T) {
+	runDir := t.TempDir()
+	bashTool := &BashTool{WorkingDir: NewMutableWorkingDir("/"), RunDir: runDir}
+	ctx := WithToolUseID(context.Background(), "toolu_1")
+
+	out := bashTool.Run(ctx, json.RawMessage(`{"command":"echo hello; echo oops >&2"}`))
+	if out.Error != nil {
+		t.Fatalf("unexpected error: %v", out.Error)
+	}
+	if got := out.LLMContent[0].Text; got != "hello\noops\n" {
+		t.Errorf("output = %q, want %q", got, "hello\noops\n")
+	}
+
+	out = bashTool.Run(WithToolUseID(context.Background(), "toolu_2"), json.RawMessage(`{"command":"echo before; exit 3"}`))
+	if out.Error == nil || !strings.Contains(out.Error.Error(), "exit status 3") || !strings.Contains(out.Error.Error(), "before") {
+		t.Errorf("expected exit status 3 with output, got %v", out.Error)
+	}
+
+	entries, err := os.ReadDir(runDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("expected run records to be removed, found %d", len(entries))
+	}
+}
+
+func TestLoadBashRun(t *testing.T) {
+	runDir := t.TempDir()
+
+	// Simulate a command whose tool call never returned: run it with a
+	// record but don't remove the record afterwards.
+	run, err := startRunRecord(runDir, "toolu_done", "echo finished; exit 2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command("bash", "-c", run.command)
+	cmd.Stdout = run.out
+	cmd.Stderr = run.out
+	run.wrap(cmd)
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if err := run.started(cmd.Process.Pid); err != nil {
+		t.Fatal(err)
+	}
+	cmd.Wait()
+	run.out.Close()
+
+	got, err := LoadBashRun(runDir, "toolu_done")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Command != "echo finished; exit 2" || got.Output != "finished\n" {
+		t.Errorf("unexpected run: %+v", got)
+	}
+	if got.ExitCode == nil || *got.ExitCode != 2 {
+		t.Errorf("ExitCode = %v, want 2", got.ExitCode)
+	}
+	if got.Running {
+		t.Error("finished command reported as running")
+	}
+	text, isError := got.RecoveryText()
+	if !isError || !strings.Contains(text, "exit status 2") || !strings.Contains(text, "finished") {
+		t.Errorf("unexpected recovery text (error=%v): %s", isError, text)
+	}
+
+	// A record without an exit status is a command killed along with the server
+	dir := filepath.Join(runDir, "toolu_killed")
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(dir, runCommandFile), []byte("sleep 100"), 0o600)
+	os.WriteFile(filepath.Join(dir, runOutputFile), []byte("partial"), 0o600)
+	got, err = LoadBashRun(runDir, "toolu_killed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ExitCode != nil || got.Running {
+		t.Errorf("unexpected run: %+v", got)
+	}
+	if text, isError := got.RecoveryText(); !isError || !strings.Contains(text, "interrupted") || !strings.Contains(text, "partial") {
+		t.Errorf("unexpected recovery text (error=%v): %s", isError, text)
+	}
+
+	if err := RemoveBashRun(runDir, "toolu_killed"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadBashRun(runDir, "toolu_killed"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected not-exist error after removal, got %v", err)
+	}
+	if _, err := LoadBashRun(runDir, "../escape"); err == nil {
+		t.Error("expected error for tool use ID containing a path separator")
+	}
+}
+
+func TestLoadBashRunLargeOutput(t *testing.T) {
+	runDir := t.TempDir()
+	dir := filepath.Join(runDir, "toolu_large")
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	output := "START\n" + strings.Repeat("x", 10*DefaultMaxOutputBytes) + "\nEND"
+	os.WriteFile(filepath.Join(dir, runCommandFile), []byte("yes"), 0o600)
+	os.WriteFile(filepath.Join(dir, runOutputFile), []byte(output), 0o600)
+
+	// Only the ends of the output are read
+	got, err := LoadBashRun(runDir, "toolu_large")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Output) != 2*DefaultMaxOutputBytes || got.OutputSize != len(output) {
+		t.Errorf("read %d bytes of output of size %d, want %d of %d", len(got.Output), got.OutputSize, 2*DefaultMaxOutputBytes, len(output))
+	}
+	text, _ := got.RecoveryText()
+	if !strings.Contains(text, "START") || !strings.HasSuffix(text, "END") || !strings.Contains(text, humanizeBytes(len(output))) {
+		t.Errorf("unexpected recovery text: %.200s", text)
+	}
+}
@@ -0,0 +1,280 @@
+package claudetool
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"shelley.exe.dev/llm"
+	"shelley.exe.dev/remote"
+)
+
+// BenchmarkTool runs Go benchmarks on a base commit and on the working
+// tree and compares the two.
+type BenchmarkTool struct {
+	// Bash runs the benchmarks, in its working directory and with its
+	// environment, sandbox or remote shell.
+	Bash *BashTool
+}
+
+const (
+	benchmarkName        = "benchmark"
+	benchmarkDescription = `Compare Go benchmarks before and after the changes in the working tree.
+
+Runs go test -bench on the base commit (default HEAD), checked out in a
+temporary git worktree so the working tree is left alone, then on the working
+tree, and reports the change in each metric (ns/op, B/op, allocs/op, ...).
+benchstat is used when it is installed, for confidence intervals and
+significance; otherwise the means are compared. Use it to check that a
+performance change is real, and run enough iterations (count) for noisy
+benchmarks.
+`
+	benchmarkInputSchema = `{
+  "type": "object",
+  "properties": {
+    "packages": {
+      "type": "array",
+      "items": {"type": "string"},
+      "description": "Packages to benchmark, relative to the working directory (default: .)"
+    },
+    "bench": {
+      "type": "string",
+      "description": "Regular expression selecting the benchmarks, as for go test -bench (default: .)"
+    },
+    "count": {
+      "type": "integer",
+      "description": "How many times to run each benchmark on each side (default 6, benchstat's minimum for significance)"
+    },
+    "benchtime": {
+      "type": "string",
+      "description": "go test -benchtime, such as 2s or 1000x"
+    },
+    "base": {
+      "type": "string",
+      "description": "The commit to compare against (default: HEAD)"
+    }
+  }
+}`
+
+	// benchmarkSection starts each part of the benchmark script's output.
+	benchmarkSection = "--- benchmark: "
+)
+
+type benchmarkInput struct {
+	Packages  []string `json:"packages"`
+	Bench     string   `json:"bench"`
+	Count     int      `json:"count"`
+	Benchtime string   `json:"benchtime"`
+	Base      string   `json:"base"`
+}
+
+// Tool returns an llm.Tool for comparing benchmarks.
+func (b *BenchmarkTool) Tool() *llm.Tool {
+	return &llm.Tool{
+		Name:        benchmarkName,
+		Description: benchmarkDescription,
+		InputSchema: llm.MustSchema(benchmarkInputSchema),
+		Run:         b.Run,
+	}
+}
+
+// Run executes the benchmark tool.
+func (b *BenchmarkTool) Run(ctx context.Context, m json.RawMessage) llm.ToolOut {
+	var req benchmarkInput
+	if err := json.Unmarshal(m, &req); err != nil {
+		return llm.ErrorfToolOut("failed to parse benchmark input: %w", err)
+	}
+	if strings.HasPrefix(req.Base, "-") {
+		return llm.ErrorfToolOut("invalid base %q", req.Base)
+	}
+
+	out, _ := b.Bash.RunCommand(ctx, benchmarkScript(req))
+	if ctx.Err() != nil {
+		return llm.ErrorToolOut(ctx.Err())
+	}
+	sections := splitBenchmarkOutput(out)
+	if msg, ok := sections["error"]; ok {
+		return llm.ErrorfToolOut("%s", strings.TrimSpace(msg))
+	}
+	before, after := sections["before"], sections["after"]
+	beforeResults, afterResults := parseBenchmarks(before), parseBenchmarks(after)
+	if len(afterResults) == 0 {
+		return llm.ErrorfToolOut("no benchmarks ran on the working tree:\n%s", lastLines(strings.TrimSpace(after), testErrorLines))
+	}
+
+	var text strings.Builder
+	fmt.Fprintf(&text, "Benchmarks at %s (before) and in the working tree (after):\n\n", cmp.Or(req.Base, "HEAD"))
+	if len(beforeResults) == 0 {
+		fmt.Fprintf(&text, "No benchmarks ran before the change, so there is nothing to compare against:\n%s\n\n", indent(lastLines(strings.TrimSpace(before), testErrorLines)))
+	}
+	if stat := strings.TrimSpace(sections["benchstat"]); stat != "" && len(beforeResults) > 0 {
+		text.WriteString(stat)
+	} else {
+		text.WriteString(compareBenchmarks(beforeResults, afterResults))
+		if len(beforeResults) > 0 {
+			text.WriteString("\n\nbenchstat isn't installed, so these are plain means without significance tests; `go install golang.org/x/perf/cmd/benchstat@latest` adds them.")
+		}
+	}
+	return llm.ToolOut{LLMContent: llm.TextContent(strings.TrimRight(text.String(), "\n"))}
+}
+
+// benchmarkScript returns a shell script that runs the benchmarks in a
+// worktree of the base commit and in the working tree, printing each
+// run's output and benchstat's comparison after a benchmarkSection line.
+func benchmarkScript(req benchmarkInput) string {
+	args := []string{"go", "test", "-run=^$", "-bench=" + cmp.Or(req.Bench, "."), "-count=" + strconv.Itoa(cmp.Or(req.Count, 6))}
+	if req.Benchtime != "" {
+		args = append(args, "-benchtime="+req.Benchtime)
+	}
+	if len(req.Packages) == 0 {
+		args = append(args, ".")
+	}
+	args = append(args, req.Packages...)
+	var quoted []string
+	for _, arg := range args {
+		quoted = append(quoted, remote.Quote(arg))
+	}
+	gotest := strings.Join(quoted, " ")
+	base := remote.Quote(cmp.Or(req.Base, "HEAD"))
+
+	return `section() { echo; echo '` + benchmarkSection + `'"$1"; }
+prefix=$(git rev-parse --show-prefix 2>/dev/null) || { section error; echo "the working directory is not in a git repository"; exit 1; }
+work=$(mktemp -d); results=$(mktemp -d)
+trap 'git worktree remove --force "$work" >/dev/null 2>&1; rm -rf "$work" "$results"' EXIT
+git worktree add --detach "$work" ` + base + ` >"$results/worktree" 2>&1 || { section error; echo "failed to check out" ` + base + `; cat "$results/worktree"; exit 1; }
+(cd "$work/$prefix" && ` + gotest + `) >"$results/before" 2>&1
+` + gotest + ` >"$results/after" 2>&1
+section before; cat "$results/before"
+section after; cat "$results/after"
+if command -v benchstat >/dev/null 2>&1; then
+  section benchstat; (cd "$results" && benchstat before after 2>&1)
+fi`
+}
+
+// splitBenchmarkOutput splits the output of benchmarkScript into its sections.
+func splitBenchmarkOutput(out string) map[string]string {
+	sections := make(map[string]string)
+	parts := strings.Split("\n"+out, "\n"+benchmarkSection)
+	for _, part := range parts[1:] {
+		name, body, _ := strings.Cut(part, "\n")
+		sections[name] = body
+	}
+	return sections
+}
+
+// benchmarkResult is the mean of each metric of a benchmark over its runs.
+type benchmarkResult struct {
+	name    string
+	units   []string // in the order go test printed them
+	metrics map[string]float64
+	runs    int
+}
+
+// benchmarkLineRe matches a benchmark result line: the name, the number of
+// iterations and the value-unit pairs of the metrics.
+var benchmarkLineRe = regexp.MustCompile(`^(Benchmark\S*)\s+\d+\s+(.+)$`)
+
+// parseBenchmarks parses go test -bench output, averaging repeated runs.
+// Benchmarks are named with their package if there are several.
+func parseBenchmarks(out string) []*benchmarkResult {
+	var results []*benchmarkResult
+	byName := make(map[string]*benchmarkResult)
+	pkg := ""
+	pkgs := make(map[string]bool)
+	type run struct {
+		name   string
+		fields []string
+	}
+	var runs []run
+	for line := range strings.SplitSeq(out, "\n") {
+		if p, ok := strings.CutPrefix(line, "pkg: "); ok {
+			pkg = strings.TrimSpace(p)
+			pkgs[pkg] = true
+			continue
+		}
+		match := benchmarkLineRe.FindStringSubmatch(strings.TrimSpace(line))
+		if match == nil {
+			continue
+		}
+		runs = append(runs, run{name: pkg + " " + match[1], fields: strings.Fields(match[2])})
+	}
+	for _, r := range runs {
+		name := r.name
+		if len(pkgs) <= 1 {
+			_, name, _ = strings.Cut(name, " ")
+		}
+		res := byName[name]
+		if res == nil {
+			res = &benchmarkResult{name: name, metrics: make(map[string]float64)}
+			byName[name] = res
+			results = append(results, res)
+		}
+		res.runs++
+		for i := 0; i+1 < len(r.fields); i += 2 {
+			v, err := strconv.ParseFloat(r.fields[i], 64)
+			if err != nil {
+				continue
+			}
+			unit := r.fields[i+1]
+			if _, ok := res.metrics[unit]; !ok {
+				res.units = append(res.units, unit)
+			}
+			// Keep a running mean.
+			res.metrics[unit] += (v - res.metrics[unit]) / float64(res.runs)
+		}
+	}
+	return results
+}
+
+// compareBenchmarks formats a table of the mean of each metric before and
+// after, with the change.
+func compareBenchmarks(before, after []*benchmarkResult) string {
+	old := make(map[string]*benchmarkResult)
+	for _, r := range before {
+		old[r.name] = r
+	}
+	var b strings.Builder
+	tw := tabwriter.NewWriter(&b, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "benchmark\tmetric\tbefore\tafter\tdelta\t")
+	for _, r := range after {
+		for _, unit := range r.units {
+			newV := r.metrics[unit]
+			oldV, delta := "-", "new"
+			if o, ok := old[r.name]; ok {
+				if v, ok := o.metrics[unit]; ok {
+					oldV = formatBenchValue(v)
+					delta = "~"
+					if v != 0 {
+						delta = fmt.Sprintf("%+.2f%%", 100*(newV-v)/v)
+					}
+				}
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t\n", r.name, unit, oldV, formatBenchValue(newV), delta)
+		}
+	}
+	for _, r := range before {
+		if !containsBenchmark(after, r.name) {
+			fmt.Fprintf(tw, "%s\t\t\t\tremoved\t\n", r.name)
+		}
+	}
+	tw.Flush()
+	return strings.TrimRight(b.String(), "\n")
+}
+
+func containsBenchmark(results []*benchmarkResult, name string) bool {
+	for _, r := range results {
+		if r.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func formatBenchValue(v float64) string {
+	return strconv.FormatFloat(v, 'g', 4, 64)
+}
@@ -0,0 +1,96 @@
+package claudetool
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseBenchmarks(t *testing.T) {
+	before := `goos: linux
+goarch: amd64
+pkg: example.com/m
+cpu: Some CPU
+BenchmarkSum-8        	 1000000	      1000 ns/op	      64 B/op	       2 allocs/op
+BenchmarkSum-8        	 1000000	      1200 ns/op	      64 B/op	       2 allocs/op
+BenchmarkGone-8       	 1000000	        10 ns/op
+PASS
+ok  	example.com/m	2.345s
+`
+	after := `pkg: example.com/m
+BenchmarkSum-8        	 2000000	       550 ns/op	       0 B/op	       0 allocs/op
+BenchmarkSum/sub-8    	 2000000	        50 ns/op
+PASS
+`
+	results := parseBenchmarks(before)
+	if len(results) != 2 || results[0].name != "BenchmarkSum-8" || results[0].runs != 2 {
+		t.Fatalf("expected two benchmarks with two runs of the first, got %+v", results)
+	}
+	if got := results[0].metrics["ns/op"]; got != 1100 {
+		t.Errorf("expected a mean of 1100 ns/op, got %v", got)
+	}
+	if strings.Join(results[0].units, " ") != "ns/op B/op allocs/op" {
+		t.Errorf("expected the units in order, got %v", results[0].units)
+	}
+
+	table := compareBenchmarks(results, parseBenchmarks(after))
+	for _, want := range []string{"-50.00%", "-100.00%", "BenchmarkSum/sub-8", "new", "BenchmarkGone-8", "removed"} {
+		if !strings.Contains(table, want) {
+			t.Errorf("expected %q in the comparison:\n%s", want, table)
+		}
+	}
+
+	multi := "pkg: example.com/a\nBenchmarkX-8 10 5 ns/op\npkg: example.com/b\nBenchmarkX-8 10 7 ns/op\n"
+	if results := parseBenchmarks(multi); len(results) != 2 || results[1].name != "example.com/b BenchmarkX-8" {
+		t.Errorf("expected benchmarks named with their packages, got %+v", results)
+	}
+}
+
+func TestBenchmarkTool(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go not found")
+	}
+	dir := t.TempDir()
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("go.mod", "module example.com/m\n\ngo 1.22\n")
+	write("m_test.go", "package m\n\nimport \"testing\"\n\nfunc BenchmarkOld(b *testing.B) {\n\tfor range b.N {\n\t}\n}\n")
+	git("init", "-q")
+	git("add", ".")
+	git("commit", "-q", "-m", "initial")
+	write("new_test.go", "package m\n\nimport \"testing\"\n\nfunc BenchmarkNew(b *testing.B) {\n\tfor range b.N {\n\t}\n}\n")
+
+	// Don't use a module file meant for this repository.
+	tool := &BenchmarkTool{Bash: &BashTool{WorkingDir: NewMutableWorkingDir(dir), Env: []string{"GOFLAGS="}}}
+	out := tool.Run(context.Background(), json.RawMessage(`{"count": 1, "benchtime": "1x"}`))
+	if out.Error != nil {
+		t.Fatal(out.Error)
+	}
+	text := out.LLMContent[0].Text
+	if !strings.Contains(text, "BenchmarkOld") || !strings.Contains(text, "BenchmarkNew") {
+		t.Errorf("expected both benchmarks to be reported, got:\n%s", text)
+	}
+	if data, _ := exec.Command("git", "-C", dir, "worktree", "list").Output(); strings.Count(string(data), "\n") != 1 {
+		t.Errorf("expected the base worktree to be removed, got:\n%s", data)
+	}
+
+	if out := tool.Run(context.Background(), json.RawMessage(`{"base": "nosuchref"}`)); out.Error == nil || !strings.Contains(out.Error.Error(), "failed to check out") {
+		t.Errorf("expected an unknown base to be an error, got %v", out.Error)
+	}
+}
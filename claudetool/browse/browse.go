@@ -18,6 +18,7 @@ import (
 	"github.com/chromedp/cdproto/runtime"
 	"github.com/chromedp/chromedp"
 	"github.com/google/uuid"
+	"shelley.exe.dev/filepolicy"
 	"shelley.exe.dev/llm"
 	"shelley.exe.dev/llm/imageutil"
 )
@@ -48,6 +49,10 @@ type BrowseTools struct {
 	idleTimer   *time.Timer
 	// Max image dimension for resizing (0 means use default)
 	maxImageDimension int
+
+	// FilePolicy, if set, decides which files read_image and
+	// browser_visual_diff may read, other than the screenshots.
+	FilePolicy *filepolicy.Policy
 }
 
 // NewBrowseTools creates a new set of browser automation tools.
@@ -419,31 +424,7 @@ func (b *BrowseTools) screenshotRun(ctx context.Context, m json.RawMessage) llm.
 		return llm.ErrorfToolOut("invalid input: %w", err)
 	}
 
-	// Try to get a browser context; if unavailable, return an error
-	browserCtx, err := b.GetBrowserContext()
-	if err != nil {
-		return llm.ErrorToolOut(err)
-	}
-
-	// Create a timeout context for this operation
-	timeoutCtx, cancel := context.WithTimeout(browserCtx, parseTimeout(input.Timeout))
-	defer cancel()
-
-	var buf []byte
-	var actions []chromedp.Action
-
-	if input.Selector != "" {
-		// Take screenshot of specific element
-		actions = append(actions,
-			chromedp.WaitReady(input.Selector),
-			chromedp.Screenshot(input.Selector, &buf, chromedp.NodeVisible),
-		)
-	} else {
-		// Take full page screenshot
-		actions = append(actions, chromedp.CaptureScreenshot(&buf))
-	}
-
-	err = chromedp.Run(timeoutCtx, actions...)
+	buf, err := b.captureScreenshot(input.Selector, input.Timeout)
 	if err != nil {
 		return llm.ErrorToolOut(err)
 	}
@@ -498,6 +479,39 @@ func (b *BrowseTools) screenshotRun(ctx context.Context, m json.RawMessage) llm.
 	}, Display: display}
 }
 
+// captureScreenshot captures the page, or the element matching selector if
+// it is set, as PNG data.
+func (b *BrowseTools) captureScreenshot(selector, timeout string) ([]byte, error) {
+	// Try to get a browser context; if unavailable, return an error
+	browserCtx, err := b.GetBrowserContext()
+	if err != nil {
+		return nil, err
+	}
+
+	// Create a timeout context for this operation
+	timeoutCtx, cancel := context.WithTimeout(browserCtx, parseTimeout(timeout))
+	defer cancel()
+
+	var buf []byte
+	var actions []chromedp.Action
+
+	if selector != "" {
+		// Take screenshot of specific element
+		actions = append(actions,
+			chromedp.WaitReady(selector),
+			chromedp.Screenshot(selector, &buf, chromedp.NodeVisible),
+		)
+	} else {
+		// Take full page screenshot
+		actions = append(actions, chromedp.CaptureScreenshot(&buf))
+	}
+
+	if err := chromedp.Run(timeoutCtx, actions...); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
 // GetTools returns browser tools, optionally filtering out screenshot-related tools
 func (b *BrowseTools) GetTools(includeScreenshotTools bool) []*llm.Tool {
 	tools := []*llm.Tool{
@@ -512,6 +526,7 @@ func (b *BrowseTools) GetTools(includeScreenshotTools bool) []*llm.Tool {
 	if includeScreenshotTools {
 		tools = append(tools, b.NewScreenshotTool())
 		tools = append(tools, b.NewReadImageTool())
+		tools = append(tools, b.NewVisualDiffTool())
 	}
 
 	return tools
@@ -571,6 +586,17 @@ func (b *BrowseTools) NewReadImageTool() *llm.Tool {
 	}
 }
 
+// readFile reads a file the agent named, if FilePolicy allows it. The
+// screenshots and baselines the tools store can always be read.
+func (b *BrowseTools) readFile(path string) ([]byte, error) {
+	if !strings.HasPrefix(filepath.Clean(path), ScreenshotDir+string(filepath.Separator)) {
+		if err := b.FilePolicy.CheckRead(path); err != nil {
+			return nil, err
+		}
+	}
+	return os.ReadFile(path)
+}
+
 func (b *BrowseTools) readImageRun(ctx context.Context, m json.RawMessage) llm.ToolOut {
 	var input readImageInput
 	if err := json.Unmarshal(m, &input); err != nil {
@@ -583,7 +609,7 @@ func (b *BrowseTools) readImageRun(ctx context.Context, m json.RawMessage) llm.T
 	}
 
 	// Read the file
-	imageData, err := os.ReadFile(input.Path)
+	imageData, err := b.readFile(input.Path)
 	if err != nil {
 		return llm.ErrorfToolOut("failed to read image file: %w", err)
 	}
@@ -79,8 +79,8 @@ func TestGetTools(t *testing.T) {
 	// Test with screenshot tools included
 	t.Run("with screenshots", func(t *testing.T) {
 		toolsWithScreenshots := tools.GetTools(true)
-		if len(toolsWithScreenshots) != 7 {
-			t.Errorf("expected 7 tools with screenshots, got %d", len(toolsWithScreenshots))
+		if len(toolsWithScreenshots) != 8 {
+			t.Errorf("expected 8 tools with screenshots, got %d", len(toolsWithScreenshots))
 		}
 
 		// Check tool naming convention
@@ -3,6 +3,7 @@ package browse
 import (
 	"context"
 
+	"shelley.exe.dev/filepolicy"
 	"shelley.exe.dev/llm"
 )
 
@@ -10,8 +11,10 @@ import (
 // It also returns a cleanup function that should be called when done to properly close the browser.
 // The browser will be initialized lazily when a browser tool is first used.
 // maxImageDimension is the max pixel dimension for images (0 uses default of 2000).
-func RegisterBrowserTools(ctx context.Context, supportsScreenshots bool, maxImageDimension int) ([]*llm.Tool, func()) {
+// policy, if set, decides which files the tools may read.
+func RegisterBrowserTools(ctx context.Context, supportsScreenshots bool, maxImageDimension int, policy *filepolicy.Policy) ([]*llm.Tool, func()) {
 	browserTools := NewBrowseTools(ctx, 0, maxImageDimension)
+	browserTools.FilePolicy = policy
 
 	return browserTools.GetTools(supportsScreenshots), func() {
 		browserTools.Close()
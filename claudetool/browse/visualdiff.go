@@ -0,0 +1,350 @@
+package browse
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/jpeg"
+	"image/png"
+	"math"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"shelley.exe.dev/llm"
+	"shelley.exe.dev/llm/imageutil"
+)
+
+// BaselineDir is the directory where named visual diff baselines are stored
+const BaselineDir = ScreenshotDir + "/baselines"
+
+// defaultDiffThreshold is how far apart, per color channel, two pixels may be
+// before they count as changed. It absorbs anti-aliasing and compression noise.
+const defaultDiffThreshold = 16
+
+// ssimBlock is the side of the square windows the structural similarity is computed over
+const ssimBlock = 8
+
+var baselineNameRe = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// VisualDiffTool definition
+type visualDiffInput struct {
+	Before         string `json:"before,omitempty"`
+	Baseline       string `json:"baseline,omitempty"`
+	After          string `json:"after,omitempty"`
+	Selector       string `json:"selector,omitempty"`
+	Threshold      *int   `json:"threshold,omitempty"`
+	UpdateBaseline bool   `json:"update_baseline,omitempty"`
+	Timeout        string `json:"timeout,omitempty"`
+}
+
+// NewVisualDiffTool creates a tool for comparing screenshots
+func (b *BrowseTools) NewVisualDiffTool() *llm.Tool {
+	return &llm.Tool{
+		Name: "browser_visual_diff",
+		Description: `Compare two screenshots to verify a UI change, or check for regressions against a stored baseline.
+
+Compares "before" (an image path, or a named baseline) with "after" (an image path, or a fresh screenshot of the current page when omitted).
+Reports how many pixels changed, where, and the structural similarity (SSIM, 1.0 means identical), and returns a diff image with changed pixels in red.
+The first comparison against a baseline that does not exist yet stores the screenshot as that baseline.`,
+		InputSchema: json.RawMessage(`{
+			"type": "object",
+			"properties": {
+				"before": {
+					"type": "string",
+					"description": "Path to the image to compare against, such as a screenshot taken before the change"
+				},
+				"baseline": {
+					"type": "string",
+					"description": "Name of a stored baseline to compare against instead of before, such as \"settings-dialog\""
+				},
+				"after": {
+					"type": "string",
+					"description": "Path to the image to compare (default: a screenshot of the current page)"
+				},
+				"selector": {
+					"type": "string",
+					"description": "CSS selector of the element to screenshot when after is omitted (optional)"
+				},
+				"threshold": {
+					"type": "integer",
+					"description": "Per-channel difference (0-255) above which a pixel counts as changed (default: 16)"
+				},
+				"update_baseline": {
+					"type": "boolean",
+					"description": "Replace the baseline with the after image once compared"
+				},
+				"timeout": {
+					"type": "string",
+					"description": "Timeout as a Go duration string (default: 15s)"
+				}
+			}
+		}`),
+		Run: b.visualDiffRun,
+	}
+}
+
+func (b *BrowseTools) visualDiffRun(ctx context.Context, m json.RawMessage) llm.ToolOut {
+	var input visualDiffInput
+	if err := json.Unmarshal(m, &input); err != nil {
+		return llm.ErrorfToolOut("invalid input: %w", err)
+	}
+	if (input.Before == "") == (input.Baseline == "") {
+		return llm.ErrorfToolOut("exactly one of before and baseline is required")
+	}
+	threshold := defaultDiffThreshold
+	if input.Threshold != nil {
+		threshold = *input.Threshold
+	}
+	if threshold < 0 || threshold > 255 {
+		return llm.ErrorfToolOut("threshold must be between 0 and 255, got %d", threshold)
+	}
+
+	afterPath := input.After
+	var afterData []byte
+	var err error
+	if afterPath != "" {
+		afterData, err = b.readFile(afterPath)
+		if err != nil {
+			return llm.ErrorfToolOut("failed to read after image: %w", err)
+		}
+	} else {
+		afterData, err = b.captureScreenshot(input.Selector, input.Timeout)
+		if err != nil {
+			return llm.ErrorToolOut(err)
+		}
+		id := b.SaveScreenshot(afterData)
+		if id == "" {
+			return llm.ErrorToolOut(fmt.Errorf("failed to save screenshot"))
+		}
+		afterPath = GetScreenshotPath(id)
+	}
+
+	beforePath := input.Before
+	if input.Baseline != "" {
+		if !baselineNameRe.MatchString(input.Baseline) {
+			return llm.ErrorfToolOut("invalid baseline name %q: use letters, digits, '.', '-' and '_'", input.Baseline)
+		}
+		beforePath = filepath.Join(BaselineDir, input.Baseline+".png")
+		if _, err := os.Stat(beforePath); errors.Is(err, os.ErrNotExist) {
+			if err := saveBaseline(beforePath, afterData); err != nil {
+				return llm.ErrorToolOut(err)
+			}
+			return llm.ToolOut{LLMContent: llm.TextContent(fmt.Sprintf(
+				"Baseline %q did not exist; stored %s as the baseline (%s). Compare against it after making changes.",
+				input.Baseline, afterPath, beforePath))}
+		}
+	}
+
+	beforeData, err := b.readFile(beforePath)
+	if err != nil {
+		return llm.ErrorfToolOut("failed to read before image: %w", err)
+	}
+	before, _, err := image.Decode(bytes.NewReader(beforeData))
+	if err != nil {
+		return llm.ErrorfToolOut("failed to decode %s: %w", beforePath, err)
+	}
+	after, _, err := image.Decode(bytes.NewReader(afterData))
+	if err != nil {
+		return llm.ErrorfToolOut("failed to decode %s: %w", afterPath, err)
+	}
+
+	result := diffImages(before, after, threshold)
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, result.Image); err != nil {
+		return llm.ErrorfToolOut("failed to encode diff image: %w", err)
+	}
+	id := b.SaveScreenshot(buf.Bytes())
+	if id == "" {
+		return llm.ErrorToolOut(fmt.Errorf("failed to save diff image"))
+	}
+	diffPath := GetScreenshotPath(id)
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Compared %s (before) with %s (after)\n", beforePath, afterPath)
+	bb, ab := before.Bounds(), after.Bounds()
+	if bb.Size() == ab.Size() {
+		fmt.Fprintf(&sb, "Size: %dx%d\n", ab.Dx(), ab.Dy())
+	} else {
+		fmt.Fprintf(&sb, "Size: %dx%d before, %dx%d after (pixels outside either image count as changed)\n", bb.Dx(), bb.Dy(), ab.Dx(), ab.Dy())
+	}
+	fmt.Fprintf(&sb, "Changed pixels: %d of %d (%.2f%%) at threshold %d\n", result.Changed, result.Total, result.Percent(), threshold)
+	if result.Changed > 0 {
+		r := result.Region
+		fmt.Fprintf(&sb, "Changed region: x=%d..%d, y=%d..%d\n", r.Min.X, r.Max.X-1, r.Min.Y, r.Max.Y-1)
+	}
+	fmt.Fprintf(&sb, "Structural similarity (SSIM): %.4f\n", result.SSIM)
+	fmt.Fprintf(&sb, "Diff image saved as %s (changed pixels in red)", diffPath)
+
+	if input.Baseline != "" && input.UpdateBaseline {
+		if err := saveBaseline(beforePath, afterData); err != nil {
+			return llm.ErrorToolOut(err)
+		}
+		fmt.Fprintf(&sb, "\nBaseline %q updated from %s", input.Baseline, afterPath)
+	}
+
+	// Resize image if needed to fit within model's image dimension limits
+	imageData := buf.Bytes()
+	format := "png"
+	if b.maxImageDimension > 0 {
+		var resized bool
+		imageData, format, resized, err = imageutil.ResizeImage(imageData, b.maxImageDimension)
+		if err != nil {
+			return llm.ErrorToolOut(fmt.Errorf("failed to resize diff image: %w", err))
+		}
+		if resized {
+			sb.WriteString(" [resized]")
+		}
+	}
+
+	display := map[string]any{
+		"type": "screenshot",
+		"id":   id,
+		"url":  "/api/read?path=" + url.QueryEscape(diffPath),
+		"path": diffPath,
+	}
+
+	return llm.ToolOut{LLMContent: []llm.Content{
+		{
+			Type: llm.ContentTypeText,
+			Text: sb.String(),
+		},
+		{
+			Type:      llm.ContentTypeText,
+			MediaType: "image/" + format,
+			Data:      base64.StdEncoding.EncodeToString(imageData),
+		},
+	}, Display: display}
+}
+
+// saveBaseline stores data as the baseline at path.
+func saveBaseline(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create baseline directory: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("failed to save baseline: %w", err)
+	}
+	return nil
+}
+
+// imageDiff is the result of comparing two images.
+type imageDiff struct {
+	// Changed is the number of pixels that differ by more than the threshold,
+	// including pixels covered by only one of the images.
+	Changed int
+	// Total is the number of pixels compared: the area covering both images.
+	Total int
+	// Region is the bounding box of the changed pixels.
+	Region image.Rectangle
+	// SSIM is the mean structural similarity of the area the images share.
+	SSIM float64
+	// Image shows the after image faded, with changed pixels in red.
+	Image *image.RGBA
+}
+
+// Percent returns the share of changed pixels as a percentage.
+func (d imageDiff) Percent() float64 {
+	if d.Total == 0 {
+		return 0
+	}
+	return 100 * float64(d.Changed) / float64(d.Total)
+}
+
+var diffColor = color.RGBA{R: 255, A: 255}
+
+// diffImages compares before and after pixel by pixel, aligned at their
+// top-left corners.
+func diffImages(before, after image.Image, threshold int) imageDiff {
+	bb, ab := before.Bounds(), after.Bounds()
+	w, h := max(bb.Dx(), ab.Dx()), max(bb.Dy(), ab.Dy())
+	d := imageDiff{Total: w * h, Image: image.NewRGBA(image.Rect(0, 0, w, h))}
+
+	for y := range h {
+		for x := range w {
+			inBefore := x < bb.Dx() && y < bb.Dy()
+			inAfter := x < ab.Dx() && y < ab.Dy()
+			changed := !inBefore || !inAfter
+			if !changed {
+				changed = pixelDistance(before.At(bb.Min.X+x, bb.Min.Y+y), after.At(ab.Min.X+x, ab.Min.Y+y)) > threshold
+			}
+			if changed {
+				d.Changed++
+				d.Region = d.Region.Union(image.Rect(x, y, x+1, y+1))
+				d.Image.SetRGBA(x, y, diffColor)
+				continue
+			}
+			// Fade unchanged pixels so the changes stand out.
+			l := 255 - (255-luma(after.At(ab.Min.X+x, ab.Min.Y+y)))/3
+			d.Image.SetRGBA(x, y, color.RGBA{R: l, G: l, B: l, A: 255})
+		}
+	}
+
+	d.SSIM = ssim(before, after)
+	return d
+}
+
+// pixelDistance returns the largest difference between the 8-bit channels of two colors.
+func pixelDistance(a, b color.Color) int {
+	ar, ag, ab, aa := a.RGBA()
+	br, bg, bb, ba := b.RGBA()
+	dist := 0
+	for _, pair := range [][2]uint32{{ar, br}, {ag, bg}, {ab, bb}, {aa, ba}} {
+		dist = max(dist, int(pair[0]>>8)-int(pair[1]>>8), int(pair[1]>>8)-int(pair[0]>>8))
+	}
+	return dist
+}
+
+// luma returns the 8-bit gray level of c.
+func luma(c color.Color) uint8 {
+	return color.GrayModel.Convert(c).(color.Gray).Y
+}
+
+// ssim returns the mean structural similarity of the gray levels of before
+// and after, over ssimBlock-sized windows of the area they share. Identical
+// images score 1.
+func ssim(before, after image.Image) float64 {
+	bb, ab := before.Bounds(), after.Bounds()
+	w, h := min(bb.Dx(), ab.Dx()), min(bb.Dy(), ab.Dy())
+	if w == 0 || h == 0 {
+		return 0
+	}
+
+	const (
+		c1 = (0.01 * 255) * (0.01 * 255)
+		c2 = (0.03 * 255) * (0.03 * 255)
+	)
+	var sum float64
+	var windows int
+	for y0 := 0; y0 < h; y0 += ssimBlock {
+		for x0 := 0; x0 < w; x0 += ssimBlock {
+			var sx, sy, sxx, syy, sxy, n float64
+			for y := y0; y < min(y0+ssimBlock, h); y++ {
+				for x := x0; x < min(x0+ssimBlock, w); x++ {
+					px := float64(luma(before.At(bb.Min.X+x, bb.Min.Y+y)))
+					py := float64(luma(after.At(ab.Min.X+x, ab.Min.Y+y)))
+					sx += px
+					sy += py
+					sxx += px * px
+					syy += py * py
+					sxy += px * py
+					n++
+				}
+			}
+			mx, my := sx/n, sy/n
+			vx, vy := sxx/n-mx*mx, syy/n-my*my
+			cov := sxy/n - mx*my
+			sum += ((2*mx*my + c1) * (2*cov + c2)) / ((mx*mx + my*my + c1) * (vx + vy + c2))
+			windows++
+		}
+	}
+	return math.Min(1, sum/float64(windows))
+}
@@ -0,0 +1,135 @@
+package browse
+
+import (
+	"context"
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func solidImage(w, h int, c color.Color) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := range h {
+		for x := range w {
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+func TestDiffImages(t *testing.T) {
+	white := color.RGBA{255, 255, 255, 255}
+	before := solidImage(32, 16, white)
+
+	d := diffImages(before, solidImage(32, 16, white), defaultDiffThreshold)
+	if d.Changed != 0 || d.SSIM != 1 {
+		t.Errorf("expected identical images to match, got %d changed, SSIM %f", d.Changed, d.SSIM)
+	}
+
+	after := solidImage(32, 16, white)
+	for y := 4; y < 8; y++ {
+		for x := 10; x < 20; x++ {
+			after.Set(x, y, color.RGBA{0, 0, 0, 255})
+		}
+	}
+	// A difference below the threshold is noise.
+	after.Set(0, 0, color.RGBA{250, 250, 250, 255})
+
+	d = diffImages(before, after, defaultDiffThreshold)
+	if d.Changed != 40 || d.Total != 512 {
+		t.Errorf("expected 40 of 512 pixels changed, got %d of %d", d.Changed, d.Total)
+	}
+	if want := image.Rect(10, 4, 20, 8); d.Region != want {
+		t.Errorf("expected changed region %v, got %v", want, d.Region)
+	}
+	if d.SSIM >= 1 || d.SSIM <= 0 {
+		t.Errorf("expected SSIM between 0 and 1, got %f", d.SSIM)
+	}
+	if got := d.Image.RGBAAt(12, 5); got != diffColor {
+		t.Errorf("expected changed pixels highlighted, got %v", got)
+	}
+	if got := d.Image.RGBAAt(0, 0); got == diffColor {
+		t.Error("expected pixels within the threshold not to be highlighted")
+	}
+
+	d = diffImages(before, solidImage(32, 20, white), defaultDiffThreshold)
+	if d.Changed != 32*4 {
+		t.Errorf("expected the extra rows to count as changed, got %d", d.Changed)
+	}
+}
+
+func TestVisualDiffTool(t *testing.T) {
+	ctx := context.Background()
+	tools := NewBrowseTools(ctx, 0, 0)
+	t.Cleanup(func() {
+		tools.Close()
+	})
+	tool := tools.NewVisualDiffTool()
+
+	dir := t.TempDir()
+	writePNG := func(name string, img image.Image) string {
+		path := filepath.Join(dir, name)
+		f, err := os.Create(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		if err := png.Encode(f, img); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	beforePath := writePNG("before.png", solidImage(20, 10, color.White))
+	afterPath := writePNG("after.png", solidImage(20, 10, color.Black))
+
+	run := func(input visualDiffInput) (string, int) {
+		t.Helper()
+		m, _ := json.Marshal(input)
+		out := tool.Run(ctx, m)
+		if out.Error != nil {
+			t.Fatalf("visual diff failed: %v", out.Error)
+		}
+		return out.LLMContent[0].Text, len(out.LLMContent)
+	}
+
+	text, n := run(visualDiffInput{Before: beforePath, After: afterPath})
+	if !strings.Contains(text, "Changed pixels: 200 of 200 (100.00%)") {
+		t.Errorf("unexpected report:\n%s", text)
+	}
+	if n != 2 {
+		t.Errorf("expected the report and the diff image, got %d contents", n)
+	}
+
+	baseline := "test-" + uuid.New().String()
+	t.Cleanup(func() {
+		os.Remove(filepath.Join(BaselineDir, baseline+".png"))
+	})
+	if text, _ := run(visualDiffInput{Baseline: baseline, After: beforePath}); !strings.Contains(text, "stored") {
+		t.Errorf("expected the first run to store the baseline, got %q", text)
+	}
+	if text, _ := run(visualDiffInput{Baseline: baseline, After: beforePath}); !strings.Contains(text, "Changed pixels: 0 of 200") {
+		t.Errorf("expected no changes against the baseline, got:\n%s", text)
+	}
+	run(visualDiffInput{Baseline: baseline, After: afterPath, UpdateBaseline: true})
+	if text, _ := run(visualDiffInput{Baseline: baseline, After: afterPath}); !strings.Contains(text, "Changed pixels: 0 of 200") {
+		t.Errorf("expected the updated baseline to match, got:\n%s", text)
+	}
+
+	for _, input := range []visualDiffInput{
+		{After: afterPath},
+		{Before: beforePath, Baseline: baseline, After: afterPath},
+		{Baseline: "../escape", After: afterPath},
+	} {
+		m, _ := json.Marshal(input)
+		if out := tool.Run(ctx, m); out.Error == nil {
+			t.Errorf("expected %+v to be rejected", input)
+		}
+	}
+}
@@ -9,6 +9,7 @@ import (
 
 	"shelley.exe.dev/gitstate"
 	"shelley.exe.dev/llm"
+	"shelley.exe.dev/remote"
 )
 
 // ChangeDirTool changes the working directory for bash commands.
@@ -18,6 +19,8 @@ type ChangeDirTool struct {
 	// OnChange is called after the working directory changes successfully.
 	// This can be used to persist the change to a database.
 	OnChange func(newDir string)
+	// Remote, if set, is the shell of the host or container whose directories the tool changes to.
+	Remote remote.Shell
 }
 
 const (
@@ -78,14 +81,14 @@ func (c *ChangeDirTool) Run(ctx context.Context, m json.RawMessage) llm.ToolOut
 	targetPath = filepath.Clean(targetPath)
 
 	// Validate the directory exists
-	info, err := os.Stat(targetPath)
+	isDir, err := c.isDir(ctx, targetPath)
 	if err != nil {
 		if os.IsNotExist(err) {
 			return llm.ErrorfToolOut("directory does not exist: %s", targetPath)
 		}
 		return llm.ErrorfToolOut("failed to stat path: %w", err)
 	}
-	if !info.IsDir() {
+	if !isDir {
 		return llm.ErrorfToolOut("path is not a directory: %s", targetPath)
 	}
 
@@ -98,7 +101,12 @@ func (c *ChangeDirTool) Run(ctx context.Context, m json.RawMessage) llm.ToolOut
 	}
 
 	// Check git status for the new directory
-	state := gitstate.GetGitState(targetPath)
+	var state *gitstate.GitState
+	if c.Remote != nil {
+		state = gitstate.GetGitStateWith(c.Remote.Git, targetPath)
+	} else {
+		state = gitstate.GetGitState(targetPath)
+	}
 	var resultText string
 	if state.IsRepo {
 		resultText = fmt.Sprintf("Changed working directory to: %s\n\nGit repository detected (root: %s)", targetPath, state.Worktree)
@@ -110,3 +118,15 @@ func (c *ChangeDirTool) Run(ctx context.Context, m json.RawMessage) llm.ToolOut
 		LLMContent: llm.TextContent(resultText),
 	}
 }
+
+// isDir reports whether path is a directory, on the remote host if there is one.
+func (c *ChangeDirTool) isDir(ctx context.Context, path string) (bool, error) {
+	if c.Remote != nil {
+		return c.Remote.IsDir(ctx, path)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return false, err
+	}
+	return info.IsDir(), nil
+}
@@ -1,84 +1,258 @@
 package claudetool
 
 import (
+	"bytes"
+	"cmp"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"os"
+	"io"
+	"log/slog"
 	"os/exec"
-	"path/filepath"
+	"slices"
+	"strings"
 	"syscall"
+	"time"
 
 	"shelley.exe.dev/llm"
 )
 
-// DeploySelfTool deploys the current Shelley build to the exe.dev VM.
-// It runs `make install-binary` in the background, which handles stopping
-// the service, copying the binary, and restarting.
-type DeploySelfTool struct{}
-
-const deploySelfInputSchema = `{
-	"type": "object",
-	"properties": {
-		"source_binary": {
-			"type": "string",
-			"description": "Path to the built binary to deploy (e.g., /home/exedev/shelley/bin/shelley-linux)"
-		}
-	},
-	"required": ["source_binary"]
-}`
+// DeployTarget is a named way of deploying the project, configured in the
+// server's settings or the project's .shelley.yaml.
+type DeployTarget struct {
+	Name string `json:"name"`
+	// Command is the shell command that deploys.
+	Command string `json:"command"`
+	// Dir is the directory Command runs in; empty means the working
+	// directory.
+	Dir string `json:"dir,omitempty"`
+	// Env holds KEY=value variables added to Command's environment.
+	Env []string `json:"env,omitempty"`
+	// Production targets are deployed to only once the user confirms.
+	Production bool `json:"production,omitempty"`
+	// Detach runs Command in a new session without waiting for it, so that
+	// it survives the server: for deploys that restart Shelley itself.
+	Detach bool `json:"detach,omitempty"`
+	// HealthURL, if set, must answer with a 2xx status within HealthTimeout
+	// of Command finishing for the deploy to succeed. Detached deploys
+	// default to the server's own.
+	HealthURL     string        `json:"health_url,omitempty"`
+	HealthTimeout time.Duration `json:"health_timeout,omitempty"`
+	// Rollback is the shell command that undoes a deploy that fails its
+	// health check. For a detached deploy, $SHELLEY_PREVIOUS_BINARY is the
+	// binary it replaced.
+	Rollback string `json:"rollback,omitempty"`
+}
+
+// DeployTool deploys to the configured targets. Commands run like the bash
+// tool's foreground commands, with its environment, sandbox or remote shell.
+type DeployTool struct {
+	Bash    *BashTool
+	Targets []DeployTarget
+	// Ask asks the user to confirm deploys to production targets. If nil,
+	// they are refused.
+	Ask func(ctx context.Context, question Question) (string, error)
+	// RunDir holds the records of detached deploys, which are verified by
+	// a watcher (see WatchDeploy). If empty, they aren't verified.
+	RunDir string
+	// SelfHealthURL is the server's own health URL, which detached deploys
+	// are verified with unless their target has one.
+	SelfHealthURL string
+}
+
+const (
+	deployName = "deploy"
+	// deployConfirm is the answer that confirms a production deploy.
+	deployConfirm = "Deploy"
+	// deployOutputBytes is how much of the end of a deploy's output the
+	// agent is shown.
+	deployOutputBytes = 8 * 1024
+)
+
+type deployInput struct {
+	Target string `json:"target"`
+}
+
+// Tool returns an llm.Tool for deploying to t's targets.
+func (t *DeployTool) Tool() *llm.Tool {
+	var desc strings.Builder
+	desc.WriteString(`Deploy the project with one of its configured deploy targets.
+
+Only deploy when the user asked you to, and build and test first. Deploys to
+production targets wait for the user to confirm.
 
-func (t *DeploySelfTool) Tool() *llm.Tool {
+Targets:
+`)
+	names := make([]string, len(t.Targets))
+	for i, target := range t.Targets {
+		names[i] = target.Name
+		fmt.Fprintf(&desc, "- %s: runs `%s`", target.Name, target.Command)
+		if target.Dir != "" {
+			fmt.Fprintf(&desc, " in %s", target.Dir)
+		}
+		if target.Production {
+			desc.WriteString(" (production)")
+		}
+		if target.Detach {
+			desc.WriteString(" (restarts Shelley: the connection will be lost. After calling it, do NOT call any other tools; end your turn and tell the user the service will restart shortly)")
+		}
+		desc.WriteString("\n")
+	}
+	schema, _ := json.Marshal(map[string]any{
+		"type":     "object",
+		"required": []string{"target"},
+		"properties": map[string]any{
+			"target": map[string]any{
+				"type":        "string",
+				"enum":        names,
+				"description": "The deploy target",
+			},
+		},
+	})
 	return &llm.Tool{
-		Name:        "deploy_self",
-		Description: "Deploy a new Shelley build to the exe.dev VM. This will stop the current Shelley service, copy the new binary, and restart the service. The source binary must already be built (e.g., via 'make build-linux'). The connection will be lost during deployment. IMPORTANT: After calling this tool, do NOT call any other tools. Immediately end your turn and tell the user that the service will restart shortly, and if assets have changed, the page will reload automatically.",
-		InputSchema: llm.MustSchema(deploySelfInputSchema),
-		Run:         t.run,
+		Name:        deployName,
+		Description: desc.String(),
+		InputSchema: llm.MustSchema(string(schema)),
+		Run:         t.Run,
 	}
 }
 
-type deploySelfParams struct {
-	SourceBinary string `json:"source_binary"`
-}
+// Run executes the deploy tool.
+func (t *DeployTool) Run(ctx context.Context, m json.RawMessage) llm.ToolOut {
+	var req deployInput
+	if err := json.Unmarshal(m, &req); err != nil {
+		return llm.ErrorfToolOut("failed to parse deploy input: %w", err)
+	}
+	i := slices.IndexFunc(t.Targets, func(target DeployTarget) bool { return target.Name == req.Target })
+	if i < 0 {
+		return llm.ErrorfToolOut("unknown deploy target %q", req.Target)
+	}
+	target := t.Targets[i]
+	if target.Detach && t.Bash.Remote != nil {
+		return llm.ErrorfToolOut("deploy target %s restarts Shelley, which doesn't run on the remote host", target.Name)
+	}
+	if target.Detach && t.Bash.Sandbox != nil {
+		return llm.ErrorfToolOut("deploy target %s restarts Shelley, which can't be done from the sandbox", target.Name)
+	}
 
-func (t *DeploySelfTool) run(ctx context.Context, input json.RawMessage) llm.ToolOut {
-	var params deploySelfParams
-	if err := json.Unmarshal(input, &params); err != nil {
-		return llm.ToolOut{Error: fmt.Errorf("failed to parse params: %v", err)}
+	if target.Production {
+		if err := t.confirm(ctx, target); err != nil {
+			return llm.ErrorToolOut(err)
+		}
 	}
 
-	if params.SourceBinary == "" {
-		return llm.ToolOut{Error: fmt.Errorf("source_binary is required")}
+	if target.Detach {
+		// Setsid creates a new session so the deploy survives when Shelley
+		// dies
+		cmd := t.command(context.Background(), target, nil)
+		cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+		msg := fmt.Sprintf("Deploy to %s started: running `%s` in %s. The service will restart shortly and the connection will be lost.", target.Name, target.Command, cmd.Dir)
+		if toolUseID := ToolUseID(ctx); t.RunDir != "" && toolUseID != "" {
+			if err := t.startWatchedDeploy(toolUseID, target, cmd); err != nil {
+				return llm.ErrorfToolOut("failed to start deploy: %w", err)
+			}
+			msg += " Once it's done, the outcome will be added to this conversation."
+			if healthURL := t.healthURL(target); healthURL != "" {
+				msg = fmt.Sprintf("%s The deploy is verified with %s", msg, healthURL)
+				if target.Rollback != "" {
+					msg += ", and rolled back if it fails"
+				}
+				msg += "."
+			}
+			return llm.ToolOut{LLMContent: llm.TextContent(msg)}
+		}
+		// Unverified, with its output discarded
+		if err := cmd.Start(); err != nil {
+			return llm.ErrorfToolOut("failed to start deploy: %w", err)
+		}
+		go cmd.Wait()
+		return llm.ToolOut{LLMContent: llm.TextContent(msg)}
 	}
 
-	// Verify source binary exists
-	if _, err := os.Stat(params.SourceBinary); err != nil {
-		return llm.ToolOut{Error: fmt.Errorf("source binary not found: %v", err)}
+	ctx, cancel := context.WithTimeout(ctx, t.Bash.Timeouts.slow())
+	defer cancel()
+	var out bytes.Buffer
+	cmd := t.command(ctx, target, &out)
+	if err := cmd.Start(); err != nil {
+		return llm.ErrorfToolOut("failed to start deploy: %w", err)
+	}
+	if err := t.Bash.Processes.add(cmd.Process.Pid, target.Command); err != nil {
+		slog.WarnContext(ctx, "failed to register command", "error", err)
+	}
+	err := cmd.Wait()
+	t.Bash.Processes.remove(cmd.Process.Pid)
+	if ctx.Err() == context.DeadlineExceeded {
+		err = fmt.Errorf("timed out after %s", t.Bash.Timeouts.slow())
 	}
 
-	// Find the project directory (parent of bin/)
-	// source_binary is like /home/exedev/shelley-3/bin/shelley-linux
-	projectDir := filepath.Dir(filepath.Dir(params.SourceBinary))
+	output := out.String()
+	if len(output) > deployOutputBytes {
+		output = "[...]\n" + tailBytes(output, deployOutputBytes)
+	}
+	if err != nil {
+		return llm.ErrorfToolOut("deploy to %s failed: %w\n%s", target.Name, err, output)
+	}
+	if target.HealthURL != "" {
+		if err := t.verify(ctx, target); err != nil {
+			return llm.ErrorfToolOut("deploy to %s failed its health check: %w\nDeploy output:\n%s", target.Name, err, output)
+		}
+		output = fmt.Sprintf("%s answered.\n%s", target.HealthURL, output)
+	}
+	return llm.ToolOut{LLMContent: llm.TextContent(fmt.Sprintf("Deployed to %s.\n%s", target.Name, output))}
+}
 
-	// Verify Makefile exists
-	makefilePath := filepath.Join(projectDir, "Makefile")
-	if _, err := os.Stat(makefilePath); err != nil {
-		return llm.ToolOut{Error: fmt.Errorf("Makefile not found in %s", projectDir)}
+// verify waits for a deploy to target to answer at its health URL, rolling
+// it back if it doesn't in time.
+func (t *DeployTool) verify(ctx context.Context, target DeployTarget) error {
+	timeout := cmp.Or(target.HealthTimeout, DefaultDeployHealthTimeout)
+	err := waitHealthy(ctx, target.HealthURL, timeout)
+	if err == nil || target.Rollback == "" {
+		return err
+	}
+	err = fmt.Errorf("%s did not answer within %s: %w", target.HealthURL, timeout, err)
+	var out bytes.Buffer
+	cmd := t.command(ctx, DeployTarget{Command: target.Rollback, Dir: target.Dir, Env: target.Env}, &out)
+	if rollbackErr := cmd.Run(); rollbackErr != nil {
+		return fmt.Errorf("%w; rollback `%s` failed too: %v\n%s", err, target.Rollback, rollbackErr, tailBytes(out.String(), min(out.Len(), deployOutputBytes)))
 	}
+	return fmt.Errorf("%w; rolled back with `%s`", err, target.Rollback)
+}
 
-	// Run `make install-binary SHELLEY_DEPLOY=1` in a new session.
-	// Setsid creates a new session so the process survives when shelley dies.
-	// SHELLEY_DEPLOY=1 tells make to wait 0.5s before stopping the socket,
-	// allowing this response to be sent first.
-	cmd := exec.Command("make", "-C", projectDir, "install-binary", "SHELLEY_DEPLOY=1")
-	cmd.SysProcAttr = &syscall.SysProcAttr{
-		Setsid: true,
+// healthURL returns the URL that verifies a deploy to target, if any.
+func (t *DeployTool) healthURL(target DeployTarget) string {
+	if target.HealthURL == "" && target.Detach {
+		return t.SelfHealthURL
 	}
+	return target.HealthURL
+}
 
-	if err := cmd.Start(); err != nil {
-		return llm.ToolOut{Error: fmt.Errorf("failed to start deploy: %v", err)}
+// command returns the command that deploys to target, ready to start.
+func (t *DeployTool) command(ctx context.Context, target DeployTarget, out io.Writer) *exec.Cmd {
+	cmd := t.Bash.makeBashCommand(ctx, target.Command, out)
+	if target.Dir != "" {
+		cmd.Dir = target.Dir
 	}
+	cmd.Env = append(cmd.Env, target.Env...)
+	t.Bash.wrap(cmd)
+	return cmd
+}
 
-	msg := fmt.Sprintf("Deploy started. Running 'make install-binary' in %s. The service will restart shortly and the connection will be lost.", projectDir)
-	return llm.ToolOut{LLMContent: llm.TextContent(msg)}
+// confirm asks the user to confirm a deploy to a production target, and
+// returns an error unless they do.
+func (t *DeployTool) confirm(ctx context.Context, target DeployTarget) error {
+	if t.Ask == nil {
+		return fmt.Errorf("deploy target %s is a production target, and deploying to it needs the user's confirmation, which can't be asked for here", target.Name)
+	}
+	answer, err := t.Ask(ctx, Question{
+		Question: fmt.Sprintf("Deploy to production target %s? This runs `%s`.", target.Name, target.Command),
+		Options:  []string{deployConfirm, "Cancel"},
+	})
+	if err != nil {
+		return fmt.Errorf("the user did not confirm the deploy: %w", err)
+	}
+	if !strings.EqualFold(strings.TrimSpace(answer), deployConfirm) {
+		return errors.New("the user did not confirm the deploy: " + answer)
+	}
+	return nil
 }
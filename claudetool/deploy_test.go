@@ -0,0 +1,94 @@
+package claudetool
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"shelley.exe.dev/llm"
+)
+
+func TestDeployTool(t *testing.T) {
+	work, infra := t.TempDir(), t.TempDir()
+	var asked []Question
+	answer := "Cancel"
+	tool := &DeployTool{
+		Bash: &BashTool{WorkingDir: NewMutableWorkingDir(work)},
+		Targets: []DeployTarget{
+			{Name: "staging", Command: `echo "deploying $STAGE from $(pwd)"`, Dir: infra, Env: []string{"STAGE=staging"}},
+			{Name: "production", Command: "touch deployed", Production: true},
+			{Name: "broken", Command: "echo oops; exit 2"},
+		},
+		Ask: func(ctx context.Context, q Question) (string, error) {
+			asked = append(asked, q)
+			return answer, nil
+		},
+	}
+	run := func(target string) llm.ToolOut {
+		t.Helper()
+		data, _ := json.Marshal(map[string]string{"target": target})
+		return tool.Run(context.Background(), data)
+	}
+
+	if desc := tool.Tool().Description; !strings.Contains(desc, "- production: runs `touch deployed` (production)") {
+		t.Errorf("expected the targets described, got %q", desc)
+	}
+
+	out := run("staging")
+	if out.Error != nil {
+		t.Fatalf("deploy failed: %v", out.Error)
+	}
+	if got := out.LLMContent[0].Text; !strings.Contains(got, "deploying staging from "+infra) {
+		t.Errorf("expected the target's dir and environment, got %q", got)
+	}
+	if len(asked) != 0 {
+		t.Errorf("expected no confirmation for a staging deploy, got %v", asked)
+	}
+
+	if out := run("broken"); out.Error == nil || !strings.Contains(out.Error.Error(), "oops") {
+		t.Errorf("expected a failed deploy with its output, got %+v", out)
+	}
+	if out := run("nowhere"); out.Error == nil {
+		t.Error("expected an unknown target to fail")
+	}
+
+	// Production deploys run only once confirmed
+	deployed := filepath.Join(work, "deployed")
+	if out := run("production"); out.Error == nil || len(asked) != 1 {
+		t.Errorf("expected a declined production deploy, got %+v after %d questions", out, len(asked))
+	}
+	if _, err := os.Stat(deployed); err == nil {
+		t.Fatal("expected a declined deploy not to run")
+	}
+	answer = "deploy"
+	if out := run("production"); out.Error != nil {
+		t.Fatalf("confirmed deploy failed: %v", out.Error)
+	}
+	if _, err := os.Stat(deployed); err != nil {
+		t.Errorf("expected the confirmed deploy to run: %v", err)
+	}
+
+	// A deploy that doesn't come up is rolled back
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "down", http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+	tool.Targets = append(tool.Targets, DeployTarget{Name: "checked", Command: "true", HealthURL: down.URL, HealthTimeout: time.Second, Rollback: "touch rolled-back"})
+	if out := run("checked"); out.Error == nil || !strings.Contains(out.Error.Error(), "rolled back") {
+		t.Errorf("expected a failed health check and a rollback, got %+v", out)
+	}
+	if _, err := os.Stat(filepath.Join(work, "rolled-back")); err != nil {
+		t.Errorf("expected the rollback command to run: %v", err)
+	}
+
+	tool.Ask = nil
+	if out := run("production"); out.Error == nil || !strings.Contains(out.Error.Error(), "confirmation") {
+		t.Errorf("expected production deploys refused without a way to confirm, got %+v", out)
+	}
+}
@@ -0,0 +1,326 @@
+package claudetool
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+)
+
+// A deploy is verified by waiting for its target's health URL to answer,
+// and rolled back with the target's rollback command if it doesn't in time.
+// A deploy that restarts Shelley can't be verified by the server that starts
+// it, which it stops. Instead the server copies its own binary, which the
+// deploy will replace, and runs the copy as a watcher in a session of its
+// own (shelley deploy-watch). The watcher runs the deploy, verifies it, rolls
+// it back to the copy if need be, and writes the outcome to a record in the
+// conversation's deploy directory (DeployTool.RunDir), which the server that
+// comes up reports in the conversation.
+
+const (
+	deployRecordFile   = "record.json"
+	deployPreviousFile = "previous"
+	deployOutputFile   = "output"
+)
+
+// DefaultDeployHealthTimeout is how long a deploy's health URL has to answer
+// when its target doesn't say.
+const DefaultDeployHealthTimeout = time.Minute
+
+// deployWatcherStartup is how long a watcher has to record its PID.
+const deployWatcherStartup = 10 * time.Second
+
+// deployHealthInterval is how often the health URL is polled.
+const deployHealthInterval = time.Second
+
+// PreviousBinaryEnv names the variable that holds the path of the binary a
+// deploy that restarts Shelley replaces, for its rollback command.
+const PreviousBinaryEnv = "SHELLEY_PREVIOUS_BINARY"
+
+// DeployStatus is the outcome of a verified deploy.
+type DeployStatus string
+
+const (
+	DeployRunning        DeployStatus = "running"
+	DeployHealthy        DeployStatus = "healthy"
+	DeployFailed         DeployStatus = "failed"    // the deploy command failed
+	DeployUnhealthy      DeployStatus = "unhealthy" // and there's no rollback command
+	DeployRolledBack     DeployStatus = "rolled_back"
+	DeployRollbackFailed DeployStatus = "rollback_failed"
+)
+
+// DeployRecord is the record of a deploy run by a watcher.
+type DeployRecord struct {
+	Target DeployTarget `json:"target"`
+	// HealthURL is the URL that answers once the deploy is up.
+	HealthURL string `json:"health_url"`
+	// Watcher is the PID of the watcher, recorded once it starts.
+	Watcher int          `json:"watcher"`
+	Started time.Time    `json:"started"`
+	Status  DeployStatus `json:"status"`
+	// Detail describes the outcome, with the end of the commands' output
+	// if they failed.
+	Detail   string     `json:"detail,omitempty"`
+	Finished *time.Time `json:"finished,omitempty"`
+}
+
+// Done reports whether the deploy has an outcome, or never will because its
+// watcher is gone, or never started.
+func (r *DeployRecord) Done() bool {
+	switch {
+	case r.Status != DeployRunning:
+		return true
+	case r.Watcher == 0:
+		return time.Since(r.Started) > deployWatcherStartup
+	default:
+		return !processAlive(r.Watcher)
+	}
+}
+
+// Report describes the outcome of the deploy for the conversation.
+func (r *DeployRecord) Report() string {
+	var outcome string
+	switch r.Status {
+	case DeployHealthy:
+		outcome = "succeeded"
+	case DeployFailed:
+		outcome = "failed"
+	case DeployUnhealthy:
+		outcome = "failed its health check, and the target has no rollback command"
+	case DeployRolledBack:
+		outcome = "failed its health check and was rolled back"
+	case DeployRollbackFailed:
+		outcome = "failed its health check, and rolling it back failed too"
+	default:
+		outcome = "has no outcome: its watcher exited without recording one"
+	}
+	text := fmt.Sprintf("Deploy to %s %s.", r.Target.Name, outcome)
+	if r.Detail != "" {
+		text += "\n" + r.Detail
+	}
+	return text
+}
+
+// LoadDeployRecord reads the record of the deploy run for toolUseID.
+// It returns an error satisfying errors.Is(err, fs.ErrNotExist) if there is none.
+func LoadDeployRecord(runDir, toolUseID string) (*DeployRecord, error) {
+	dir, err := runRecordDir(runDir, toolUseID)
+	if err != nil {
+		return nil, err
+	}
+	return readDeployRecord(filepath.Join(dir, deployRecordFile))
+}
+
+// RemoveDeployRecord deletes the record of the deploy run for toolUseID,
+// with the binary it replaced.
+func RemoveDeployRecord(runDir, toolUseID string) error {
+	dir, err := runRecordDir(runDir, toolUseID)
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(dir)
+}
+
+func readDeployRecord(path string) (*DeployRecord, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var record DeployRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &record, nil
+}
+
+// writeDeployRecord replaces the record at path, so that it's never read
+// half written.
+func writeDeployRecord(path string, record *DeployRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// startWatchedDeploy starts a watcher that deploys to target, which
+// restarts Shelley, keeping the deploy's record in the tool's run directory.
+func (t *DeployTool) startWatchedDeploy(toolUseID string, target DeployTarget, cmd *exec.Cmd) error {
+	dir, err := runRecordDir(t.RunDir, toolUseID)
+	if err != nil {
+		return err
+	}
+	return StartDeployWatcher(dir, target, t.healthURL(target), cmd)
+}
+
+// StartDeployWatcher starts a watcher that deploys to target, which restarts
+// Shelley, from a copy of the running binary, keeping the deploy's record in
+// dir and verifying it with healthURL. cmd is the deploy command as it would
+// run, whose directory, environment and session the watcher runs with.
+func StartDeployWatcher(dir string, target DeployTarget, healthURL string, cmd *exec.Cmd) error {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("failed to create deploy directory: %w", err)
+	}
+	self, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	previous := filepath.Join(dir, deployPreviousFile)
+	if err := copyExecutable(self, previous); err != nil {
+		os.RemoveAll(dir)
+		return fmt.Errorf("failed to copy the running binary: %w", err)
+	}
+	path := filepath.Join(dir, deployRecordFile)
+	record := &DeployRecord{Target: target, HealthURL: healthURL, Started: time.Now(), Status: DeployRunning}
+	if err := writeDeployRecord(path, record); err != nil {
+		os.RemoveAll(dir)
+		return fmt.Errorf("failed to record deploy: %w", err)
+	}
+
+	cmd.Path = previous
+	cmd.Args = []string{previous, "deploy-watch", path}
+	cmd.Env = append(cmd.Env, PreviousBinaryEnv+"="+previous)
+	// The record is the watcher's from here on
+	if err := cmd.Start(); err != nil {
+		os.RemoveAll(dir)
+		return err
+	}
+	go cmd.Wait()
+	return nil
+}
+
+// WatchDeploy runs the deploy recorded at path, verifies it, rolls it back
+// if it's unhealthy, and records the outcome. It is the deploy-watch command
+// of the watcher, run in the deploy's directory and environment.
+func WatchDeploy(path string) error {
+	record, err := readDeployRecord(path)
+	if err != nil {
+		return err
+	}
+	record.Watcher = os.Getpid()
+	if err := writeDeployRecord(path, record); err != nil {
+		return err
+	}
+	// Let the deploy tool's response reach the browser before the deploy
+	// stops the server
+	time.Sleep(500 * time.Millisecond)
+
+	out, err := os.Create(filepath.Join(filepath.Dir(path), deployOutputFile))
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	record.Status, record.Detail = verifyDeploy(context.Background(), record.Target, record.HealthURL, out)
+	now := time.Now()
+	record.Finished = &now
+	return writeDeployRecord(path, record)
+}
+
+// verifyDeploy runs target's deploy command, waits for healthURL to answer,
+// and runs its rollback command if it doesn't, writing their output to out.
+// It returns the outcome and a description of it.
+func verifyDeploy(ctx context.Context, target DeployTarget, healthURL string, out io.ReadWriteSeeker) (DeployStatus, string) {
+	run := func(command string) error {
+		cmd := exec.CommandContext(ctx, "bash", "-c", command)
+		cmd.Dir = target.Dir
+		cmd.Env = append(os.Environ(), target.Env...)
+		cmd.Stdout = out
+		cmd.Stderr = out
+		return cmd.Run()
+	}
+	output := func() string {
+		out.Seek(0, io.SeekStart)
+		data, _ := io.ReadAll(out)
+		if len(data) > deployOutputBytes {
+			return "[...]\n" + tailBytes(string(data), deployOutputBytes)
+		}
+		return string(data)
+	}
+	timeout := cmp.Or(target.HealthTimeout, DefaultDeployHealthTimeout)
+
+	if err := run(target.Command); err != nil {
+		return DeployFailed, fmt.Sprintf("`%s`: %v\n%s", target.Command, err, output())
+	}
+	if healthURL == "" {
+		return DeployHealthy, ""
+	}
+	healthErr := waitHealthy(ctx, healthURL, timeout)
+	if healthErr == nil {
+		return DeployHealthy, fmt.Sprintf("%s answered.", healthURL)
+	}
+	detail := fmt.Sprintf("%s did not answer within %s: %v", healthURL, timeout, healthErr)
+	if target.Rollback == "" {
+		return DeployUnhealthy, detail
+	}
+	if err := run(target.Rollback); err != nil {
+		return DeployRollbackFailed, fmt.Sprintf("%s\nRollback `%s`: %v\n%s", detail, target.Rollback, err, output())
+	}
+	if err := waitHealthy(ctx, healthURL, timeout); err != nil {
+		return DeployRollbackFailed, fmt.Sprintf("%s\nAfter rolling back with `%s`, %s still did not answer: %v", detail, target.Rollback, healthURL, err)
+	}
+	return DeployRolledBack, fmt.Sprintf("%s\nRolled back with `%s`.", detail, target.Rollback)
+}
+
+// waitHealthy polls url until it answers with a 2xx status, or timeout has
+// passed, returning the last error.
+func waitHealthy(ctx context.Context, url string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	client := &http.Client{Timeout: 5 * time.Second}
+	for {
+		err := checkHealth(ctx, client, url)
+		if err == nil {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(deployHealthInterval):
+		}
+	}
+}
+
+func checkHealth(ctx context.Context, client *http.Client, url string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("status %s", resp.Status)
+	}
+	return nil
+}
+
+// copyExecutable copies the executable src to dst.
+func copyExecutable(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o700)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,101 @@
+package claudetool
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerifyDeploy(t *testing.T) {
+	dir := t.TempDir()
+	healthy := filepath.Join(dir, "healthy")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := os.Stat(healthy); err != nil {
+			http.Error(w, "down", http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	for _, tt := range []struct {
+		name   string
+		target DeployTarget
+		want   DeployStatus
+	}{
+		{"healthy", DeployTarget{Command: "touch healthy"}, DeployHealthy},
+		{"failed", DeployTarget{Command: "echo broken; exit 1", Rollback: "touch healthy"}, DeployFailed},
+		{"unhealthy", DeployTarget{Command: "true"}, DeployUnhealthy},
+		{"rolled back", DeployTarget{Command: "true", Rollback: "touch healthy"}, DeployRolledBack},
+		{"rollback failed", DeployTarget{Command: "true", Rollback: "echo no luck; exit 1"}, DeployRollbackFailed},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Remove(healthy)
+			tt.target.Dir = dir
+			tt.target.HealthTimeout = 1500 * time.Millisecond
+			out, err := os.Create(filepath.Join(t.TempDir(), "output"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer out.Close()
+			status, detail := verifyDeploy(context.Background(), tt.target, server.URL, out)
+			if status != tt.want {
+				t.Errorf("expected %s, got %s: %s", tt.want, status, detail)
+			}
+			if status == DeployFailed && !strings.Contains(detail, "broken") {
+				t.Errorf("expected the command's output, got %q", detail)
+			}
+		})
+	}
+}
+
+func TestWatchDeploy(t *testing.T) {
+	runDir := t.TempDir()
+	dir := filepath.Join(runDir, "toolu_1")
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, deployRecordFile)
+	record := &DeployRecord{Target: DeployTarget{Name: "self", Command: "echo deployed", Dir: dir}, Started: time.Now(), Status: DeployRunning}
+	if err := writeDeployRecord(path, record); err != nil {
+		t.Fatal(err)
+	}
+	if record.Done() {
+		t.Error("expected a deploy whose watcher hasn't started yet not to be done")
+	}
+
+	if err := WatchDeploy(path); err != nil {
+		t.Fatal(err)
+	}
+	record, err := LoadDeployRecord(runDir, "toolu_1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.Status != DeployHealthy || record.Watcher != os.Getpid() || record.Finished == nil || !record.Done() {
+		t.Errorf("unexpected record %+v", record)
+	}
+	if got := record.Report(); got != "Deploy to self succeeded." {
+		t.Errorf("unexpected report %q", got)
+	}
+	output, _ := os.ReadFile(filepath.Join(dir, deployOutputFile))
+	if string(output) != "deployed\n" {
+		t.Errorf("expected the deploy's output recorded, got %q", output)
+	}
+
+	// A watcher that died without an outcome is done too
+	data, _ := json.Marshal(DeployRecord{Watcher: 1 << 30, Status: DeployRunning})
+	if err := json.Unmarshal(data, record); err != nil || !record.Done() || !strings.Contains(record.Report(), "no outcome") {
+		t.Errorf("expected a record without a live watcher to be done, got %+v", record)
+	}
+
+	if err := RemoveDeployRecord(runDir, "toolu_1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("expected the record removed, got %v", err)
+	}
+}
@@ -0,0 +1,91 @@
+package claudetool
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"shelley.exe.dev/llm"
+)
+
+// DocSearchResult is a chunk of repository documentation matching a query.
+type DocSearchResult struct {
+	Path      string // relative to the repository root
+	StartLine int
+	Content   string
+	Score     float64 // cosine similarity, higher is closer
+}
+
+// DocSearchTool retrieves relevant documentation and code comments from the
+// repository containing the working directory.
+type DocSearchTool struct {
+	// WorkingDir is the shared mutable working directory.
+	WorkingDir *MutableWorkingDir
+	// Search indexes the repository containing workingDir if needed and
+	// returns the chunks most similar to query, best first.
+	Search func(ctx context.Context, workingDir, query string) ([]DocSearchResult, error)
+}
+
+const (
+	searchDocsName        = "search_docs"
+	searchDocsDescription = `Search this repository's documentation (README, docs, markdown) and code comments by meaning.
+
+Returns the most relevant excerpts with file paths and line numbers.
+Prefer this over reading large documentation files end to end; read the file
+itself when you need the full context around an excerpt.
+The index is updated in the background, so it may briefly miss recent changes, and the first search in a repository may find it still being built.
+`
+	searchDocsInputSchema = `{
+  "type": "object",
+  "required": ["query"],
+  "properties": {
+    "query": {
+      "type": "string",
+      "description": "What you want to know, phrased as a question or description"
+    }
+  }
+}`
+)
+
+type searchDocsInput struct {
+	Query string `json:"query"`
+}
+
+// Tool returns an llm.Tool for searching repository documentation.
+func (d *DocSearchTool) Tool() *llm.Tool {
+	return &llm.Tool{
+		Name:        searchDocsName,
+		Description: searchDocsDescription,
+		InputSchema: llm.MustSchema(searchDocsInputSchema),
+		Run:         d.Run,
+	}
+}
+
+// Run executes the search_docs tool.
+func (d *DocSearchTool) Run(ctx context.Context, m json.RawMessage) llm.ToolOut {
+	var req searchDocsInput
+	if err := json.Unmarshal(m, &req); err != nil {
+		return llm.ErrorfToolOut("failed to parse search_docs input: %w", err)
+	}
+	query := strings.TrimSpace(req.Query)
+	if query == "" {
+		return llm.ErrorfToolOut("query is required")
+	}
+	results, err := d.Search(ctx, d.WorkingDir.Get(), query)
+	if err != nil {
+		return llm.ErrorfToolOut("search_docs failed: %w", err)
+	}
+	if len(results) == 0 {
+		return llm.ToolOut{LLMContent: llm.TextContent("No documentation found in this repository.")}
+	}
+
+	var sb strings.Builder
+	for i, res := range results {
+		if i > 0 {
+			sb.WriteString("\n")
+		}
+		fmt.Fprintf(&sb, "<excerpt path=%q line=\"%d\" score=\"%.2f\">\n%s\n</excerpt>\n", res.Path, res.StartLine, res.Score, res.Content)
+	}
+	return llm.ToolOut{LLMContent: llm.TextContent(sb.String())}
+}
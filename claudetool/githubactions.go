@@ -0,0 +1,444 @@
+package claudetool
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os/exec"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"shelley.exe.dev/llm"
+	"shelley.exe.dev/remote"
+)
+
+// GitHubActionsTool triggers GitHub Actions workflows, or finds the runs a
+// push started, waits for them to finish and summarizes the failed jobs'
+// logs. It uses the gh CLI.
+type GitHubActionsTool struct {
+	// Bash runs gh and git, in its working directory and with its
+	// environment, sandbox or remote shell. Progress is sent to its OnOutput.
+	Bash *BashTool
+	// Repo, if set, is the owner/repo the runs are in, instead of the
+	// repository of the working directory.
+	Repo string
+	// PollInterval is how often the runs are checked; 15 seconds if zero.
+	PollInterval time.Duration
+}
+
+const (
+	githubActionsName        = "github_actions"
+	githubActionsDescription = `Run or watch GitHub Actions CI and get a summary of what failed.
+
+Without workflow or run_id, waits for the workflow runs of a commit (default
+HEAD), such as the ones a git push starts, so push first. With workflow,
+triggers that workflow_dispatch workflow on ref and waits for its run. With
+run_id, waits for that run. When the runs finish, returns each run's result
+and, for failed jobs, the failed steps with their error annotations and the
+end of their logs. The result is an error when a run failed. Use it after
+pushing to iterate until CI passes. Requires the gh CLI to be authenticated.
+`
+	githubActionsInputSchema = `{
+  "type": "object",
+  "properties": {
+    "commit": {
+      "type": "string",
+      "description": "The commit whose runs to watch (default: HEAD)"
+    },
+    "workflow": {
+      "type": "string",
+      "description": "A workflow file name (such as ci.yml), name or ID to trigger"
+    },
+    "ref": {
+      "type": "string",
+      "description": "The branch or tag to run the triggered workflow on (default: the current branch)"
+    },
+    "inputs": {
+      "type": "object",
+      "additionalProperties": {"type": "string"},
+      "description": "Inputs for the triggered workflow"
+    },
+    "run_id": {
+      "type": "integer",
+      "description": "A run to watch, such as one that is still running from an earlier call"
+    },
+    "timeout_minutes": {
+      "type": "integer",
+      "description": "How long to wait for the runs to finish (default 30)"
+    }
+  }
+}`
+
+	// githubRunAppearTimeout is how long to wait for runs to be created
+	// after a push or trigger.
+	githubRunAppearTimeout = 2 * time.Minute
+	// githubLogLines is how many log lines are kept for each failed job.
+	githubLogLines = 40
+	// githubRunFields are the fields requested for runs from gh.
+	githubRunFields = "databaseId,workflowName,displayTitle,event,status,conclusion,url"
+)
+
+type githubActionsInput struct {
+	Commit         string            `json:"commit"`
+	Workflow       string            `json:"workflow"`
+	Ref            string            `json:"ref"`
+	Inputs         map[string]string `json:"inputs"`
+	RunID          int64             `json:"run_id"`
+	TimeoutMinutes int               `json:"timeout_minutes"`
+}
+
+// githubRun is a workflow run, as gh reports it.
+type githubRun struct {
+	ID         int64       `json:"databaseId"`
+	Workflow   string      `json:"workflowName"`
+	Title      string      `json:"displayTitle"`
+	Event      string      `json:"event"`
+	Status     string      `json:"status"`
+	Conclusion string      `json:"conclusion"`
+	URL        string      `json:"url"`
+	Jobs       []githubJob `json:"jobs,omitempty"`
+}
+
+type githubJob struct {
+	Name       string `json:"name"`
+	Conclusion string `json:"conclusion"`
+	URL        string `json:"url"`
+	Steps      []struct {
+		Name       string `json:"name"`
+		Conclusion string `json:"conclusion"`
+	} `json:"steps"`
+}
+
+// githubFailed reports whether a completed run or job failed. Skipped and
+// neutral don't count.
+func githubFailed(conclusion string) bool {
+	return !slices.Contains([]string{"success", "skipped", "neutral"}, conclusion)
+}
+
+// Tool returns an llm.Tool for running and watching GitHub Actions.
+func (g *GitHubActionsTool) Tool() *llm.Tool {
+	return &llm.Tool{
+		Name:        githubActionsName,
+		Description: githubActionsDescription,
+		InputSchema: llm.MustSchema(githubActionsInputSchema),
+		Run:         g.Run,
+	}
+}
+
+// Run executes the GitHub Actions tool.
+func (g *GitHubActionsTool) Run(ctx context.Context, m json.RawMessage) llm.ToolOut {
+	var req githubActionsInput
+	if err := json.Unmarshal(m, &req); err != nil {
+		return llm.ErrorfToolOut("failed to parse github_actions input: %w", err)
+	}
+	for _, arg := range []string{req.Commit, req.Workflow, req.Ref} {
+		if strings.HasPrefix(arg, "-") {
+			return llm.ErrorfToolOut("invalid argument %q", arg)
+		}
+	}
+	if req.RunID != 0 && (req.Workflow != "" || req.Commit != "") || req.Workflow != "" && req.Commit != "" {
+		return llm.ErrorfToolOut("set at most one of commit, workflow and run_id")
+	}
+	deadline := time.Now().Add(time.Duration(cmp.Or(req.TimeoutMinutes, 30)) * time.Minute)
+
+	var what string
+	var list func(ctx context.Context) ([]githubRun, error)
+	switch {
+	case req.Workflow != "":
+		id, err := g.trigger(ctx, req)
+		if err != nil {
+			return llm.ErrorToolOut(err)
+		}
+		what = fmt.Sprintf("workflow %s (run %d)", req.Workflow, id)
+		list = g.viewRun(id)
+	case req.RunID != 0:
+		what = fmt.Sprintf("run %d", req.RunID)
+		list = g.viewRun(req.RunID)
+	default:
+		out, err := g.Bash.RunCommand(ctx, "git rev-parse --verify "+remote.Quote(cmp.Or(req.Commit, "HEAD")+"^{commit}"))
+		if err != nil {
+			return llm.ErrorfToolOut("failed to resolve %s: %s", cmp.Or(req.Commit, "HEAD"), strings.TrimSpace(out))
+		}
+		sha := strings.TrimSpace(out)
+		what = "commit " + sha[:min(len(sha), 12)]
+		list = func(ctx context.Context) ([]githubRun, error) {
+			var runs []githubRun
+			err := g.ghJSON(ctx, &runs, "run", "list", "--commit", sha, "--json", githubRunFields)
+			return runs, err
+		}
+	}
+
+	runs, err := g.wait(ctx, what, list, deadline)
+	if err != nil {
+		return llm.ErrorToolOut(err)
+	}
+	if len(runs) == 0 {
+		return llm.ErrorfToolOut("no workflow runs for %s appeared within %s; check that it was pushed and that the repository has workflows for it", what, githubRunAppearTimeout)
+	}
+
+	var running, failed []githubRun
+	for i, run := range runs {
+		switch {
+		case run.Status != "completed":
+			running = append(running, run)
+		case githubFailed(run.Conclusion):
+			// The jobs say which steps failed, the logs why.
+			var view githubRun
+			if err := g.ghJSON(ctx, &view, "run", "view", strconv.FormatInt(run.ID, 10), "--json", "jobs"); err == nil {
+				runs[i].Jobs = view.Jobs
+			}
+			failed = append(failed, runs[i])
+		}
+	}
+	logs := make(map[int64]map[string][]string)
+	for _, run := range failed {
+		out, _ := g.gh(ctx, "run", "view", strconv.FormatInt(run.ID, 10), "--log-failed")
+		logs[run.ID] = summarizeGitHubLog(out, githubLogLines)
+	}
+
+	text := formatGitHubRuns(what, runs, logs)
+	switch {
+	case len(running) > 0:
+		hint := "call again to keep waiting"
+		if len(runs) == 1 {
+			hint = fmt.Sprintf("call again with run_id %d to keep waiting", runs[0].ID)
+		}
+		return llm.ToolOut{Error: fmt.Errorf("%s\n\nStill running after the timeout; %s.", text, hint)}
+	case len(failed) > 0:
+		return llm.ToolOut{Error: errors.New(text)}
+	}
+	return llm.ToolOut{LLMContent: llm.TextContent(text)}
+}
+
+// trigger starts req's workflow with workflow_dispatch and returns the ID
+// of the run it created.
+func (g *GitHubActionsTool) trigger(ctx context.Context, req githubActionsInput) (int64, error) {
+	ref := req.Ref
+	if ref == "" {
+		out, err := g.Bash.RunCommand(ctx, "git symbolic-ref --short HEAD")
+		if err != nil {
+			return 0, fmt.Errorf("HEAD is not on a branch; set ref to the branch or tag to run %s on", req.Workflow)
+		}
+		ref = strings.TrimSpace(out)
+	}
+
+	// gh doesn't print the run it creates, so look for a new one.
+	listArgs := []string{"run", "list", "--workflow", req.Workflow, "--event", "workflow_dispatch", "--limit", "20", "--json", "databaseId"}
+	var before []githubRun
+	if err := g.ghJSON(ctx, &before, listArgs...); err != nil {
+		return 0, err
+	}
+	args := []string{"workflow", "run", req.Workflow, "--ref", ref}
+	for _, k := range slices.Sorted(maps.Keys(req.Inputs)) {
+		args = append(args, "-f", k+"="+req.Inputs[k])
+	}
+	if _, err := g.gh(ctx, args...); err != nil {
+		return 0, err
+	}
+
+	appear := time.Now().Add(githubRunAppearTimeout)
+	for {
+		var after []githubRun
+		if err := g.ghJSON(ctx, &after, listArgs...); err != nil {
+			return 0, err
+		}
+		for _, run := range after {
+			if !slices.ContainsFunc(before, func(r githubRun) bool { return r.ID == run.ID }) {
+				return run.ID, nil
+			}
+		}
+		if time.Now().After(appear) {
+			return 0, fmt.Errorf("triggered %s on %s, but no run appeared within %s", req.Workflow, ref, githubRunAppearTimeout)
+		}
+		if err := g.sleep(ctx); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// viewRun returns a function listing the run with the given ID.
+func (g *GitHubActionsTool) viewRun(id int64) func(ctx context.Context) ([]githubRun, error) {
+	return func(ctx context.Context) ([]githubRun, error) {
+		var run githubRun
+		err := g.ghJSON(ctx, &run, "run", "view", strconv.FormatInt(id, 10), "--json", githubRunFields)
+		return []githubRun{run}, err
+	}
+}
+
+// wait lists the runs until they have all completed or the deadline
+// passes, sending progress as it changes. It returns no runs if none
+// appear in time.
+func (g *GitHubActionsTool) wait(ctx context.Context, what string, list func(context.Context) ([]githubRun, error), deadline time.Time) ([]githubRun, error) {
+	appear := time.Now().Add(githubRunAppearTimeout)
+	progress := ""
+	for {
+		runs, err := list(ctx)
+		if err != nil {
+			return nil, err
+		}
+		done := 0
+		for _, run := range runs {
+			if run.Status == "completed" {
+				done++
+			}
+		}
+		if len(runs) > 0 && done == len(runs) {
+			return runs, nil
+		}
+		if len(runs) == 0 && time.Now().After(appear) || time.Now().After(deadline) {
+			return runs, nil
+		}
+		if p := fmt.Sprintf("%s: %d of %d workflow runs completed\n", what, done, len(runs)); p != progress && len(runs) > 0 {
+			progress = p
+			if g.Bash.OnOutput != nil {
+				g.Bash.OnOutput(ctx, p)
+			}
+		}
+		if err := g.sleep(ctx); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (g *GitHubActionsTool) sleep(ctx context.Context) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(cmp.Or(g.PollInterval, 15*time.Second)):
+		return nil
+	}
+}
+
+// gh runs the gh CLI with args and returns its output.
+func (g *GitHubActionsTool) gh(ctx context.Context, args ...string) (string, error) {
+	if g.Repo != "" {
+		args = append(slices.Clip(args), "--repo", g.Repo)
+	}
+	quoted := []string{"gh"}
+	for _, arg := range args {
+		quoted = append(quoted, remote.Quote(arg))
+	}
+	out, err := g.Bash.RunCommand(ctx, strings.Join(quoted, " "))
+	var exitErr *exec.ExitError
+	switch {
+	case errors.As(err, &exitErr) && exitErr.ExitCode() == 127:
+		return out, errors.New("the gh CLI is not installed; install it and authenticate with gh auth login")
+	case err != nil:
+		return out, fmt.Errorf("gh %s %s failed: %v\n%s", args[0], args[1], err, lastLines(strings.TrimSpace(out), testErrorLines))
+	}
+	return out, nil
+}
+
+// ghJSON runs gh with args and decodes its JSON output into v.
+func (g *GitHubActionsTool) ghJSON(ctx context.Context, v any, args ...string) error {
+	out, err := g.gh(ctx, args...)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal([]byte(out), v); err != nil {
+		return fmt.Errorf("failed to parse the output of gh %s %s: %w", args[0], args[1], err)
+	}
+	return nil
+}
+
+var (
+	ansiEscapeRe   = regexp.MustCompile(`\x1b\[[0-9;]*[A-Za-z]`)
+	logTimestampRe = regexp.MustCompile(`^\x{FEFF}?\d{4}-\d\d-\d\dT[\d:.]+Z ?`)
+)
+
+// summarizeGitHubLog condenses the output of gh run view --log-failed,
+// lines of job name, step name and timestamped message separated by tabs,
+// to the last n lines of each job. Error annotations are always kept.
+func summarizeGitHubLog(out string, n int) map[string][]string {
+	lines := make(map[string][]string)
+	errs := make(map[string][]string)
+	for line := range strings.SplitSeq(out, "\n") {
+		job, rest, ok := strings.Cut(strings.TrimRight(line, "\r"), "\t")
+		if !ok {
+			continue
+		}
+		_, msg, _ := strings.Cut(rest, "\t")
+		msg = ansiEscapeRe.ReplaceAllString(logTimestampRe.ReplaceAllString(msg, ""), "")
+		switch {
+		case msg == "##[endgroup]":
+			continue
+		case strings.HasPrefix(msg, "##[group]"):
+			msg = "$ " + strings.TrimPrefix(msg, "##[group]")
+		case strings.HasPrefix(msg, "##[error]"):
+			msg = "error: " + strings.TrimPrefix(msg, "##[error]")
+			errs[job] = append(errs[job], msg)
+		}
+		lines[job] = append(lines[job], msg)
+	}
+	for job, l := range lines {
+		tail := l[max(0, len(l)-n):]
+		var kept []string
+		for _, e := range errs[job] {
+			if !slices.Contains(tail, e) {
+				kept = append(kept, e)
+			}
+		}
+		if omitted := len(l) - len(tail); omitted > 0 {
+			kept = append(kept, fmt.Sprintf("[%d lines omitted]", omitted))
+		}
+		lines[job] = append(kept, tail...)
+	}
+	return lines
+}
+
+// formatGitHubRuns describes the runs' results, with the failed jobs and
+// their logs.
+func formatGitHubRuns(what string, runs []githubRun, logs map[int64]map[string][]string) string {
+	var b strings.Builder
+	nfailed := 0
+	for _, run := range runs {
+		if run.Status == "completed" && githubFailed(run.Conclusion) {
+			nfailed++
+		}
+	}
+	if nfailed > 0 {
+		fmt.Fprintf(&b, "CI for %s: %d of %d workflow runs failed.", what, nfailed, len(runs))
+	} else {
+		fmt.Fprintf(&b, "CI for %s: %d workflow runs, none failed.", what, len(runs))
+	}
+	for _, run := range runs {
+		result := run.Conclusion
+		if run.Status != "completed" {
+			result = run.Status
+		}
+		fmt.Fprintf(&b, "\n\n%s (%s, run %d): %s\n%s", cmp.Or(run.Workflow, run.Title), run.Event, run.ID, result, run.URL)
+		for _, job := range run.Jobs {
+			if !githubFailed(job.Conclusion) {
+				continue
+			}
+			var steps []string
+			for _, step := range job.Steps {
+				if step.Conclusion == "failure" {
+					steps = append(steps, fmt.Sprintf("%q", step.Name))
+				}
+			}
+			fmt.Fprintf(&b, "\n\n  Job %q: %s", job.Name, job.Conclusion)
+			if len(steps) > 0 {
+				fmt.Fprintf(&b, " at step %s", strings.Join(steps, ", "))
+			}
+			if job.URL != "" {
+				b.WriteString("\n  " + job.URL)
+			}
+			if l := logs[run.ID][job.Name]; len(l) > 0 {
+				b.WriteString("\n" + indent(strings.Join(l, "\n")))
+			}
+		}
+		if len(run.Jobs) == 0 && len(logs[run.ID]) > 0 {
+			for _, job := range slices.Sorted(maps.Keys(logs[run.ID])) {
+				fmt.Fprintf(&b, "\n\n  Job %q:\n%s", job, indent(strings.Join(logs[run.ID][job], "\n")))
+			}
+		}
+	}
+	return b.String()
+}
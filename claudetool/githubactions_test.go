@@ -0,0 +1,105 @@
+package claudetool
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSummarizeGitHubLog(t *testing.T) {
+	log := "test\tSet up job\t2024-05-01T12:00:00.0000000Z Runner version 2.316\n" +
+		"test\tRun go test\t2024-05-01T12:00:01.0000000Z ##[group]Run go test ./...\n" +
+		"test\tRun go test\t2024-05-01T12:00:01.0000000Z go test ./...\n" +
+		"test\tRun go test\t2024-05-01T12:00:01.0000000Z ##[endgroup]\n" +
+		"test\tRun go test\t2024-05-01T12:00:05.0000000Z \x1b[31m--- FAIL: TestAdd (0.00s)\x1b[0m\n" +
+		"test\tRun go test\t2024-05-01T12:00:05.0000000Z ##[error]Process completed with exit code 1.\n" +
+		"lint\tRun lint\t2024-05-01T12:00:02.0000000Z ##[error]main.go:3:1: unused (unused)\n" +
+		"lint\tRun lint\t2024-05-01T12:00:02.0000000Z line 1\n" +
+		"lint\tRun lint\t2024-05-01T12:00:02.0000000Z line 2\n"
+
+	logs := summarizeGitHubLog(log, 10)
+	want := []string{"Runner version 2.316", "$ Run go test ./...", "go test ./...", "--- FAIL: TestAdd (0.00s)", "error: Process completed with exit code 1."}
+	if strings.Join(logs["test"], "\n") != strings.Join(want, "\n") {
+		t.Errorf("unexpected test job log:\n%s", strings.Join(logs["test"], "\n"))
+	}
+
+	// Error annotations are kept even when they are not in the tail.
+	logs = summarizeGitHubLog(log, 1)
+	want = []string{"error: main.go:3:1: unused (unused)", "[2 lines omitted]", "line 2"}
+	if strings.Join(logs["lint"], "\n") != strings.Join(want, "\n") {
+		t.Errorf("unexpected lint job log:\n%s", strings.Join(logs["lint"], "\n"))
+	}
+}
+
+// fakeGH is a gh that reports run 7 of the commit in progress until it
+// has been listed twice, then failed.
+const fakeGH = `#!/bin/sh
+state="$(dirname "$0")/listed"
+case "$1 $2" in
+"run list")
+	echo x >>"$state"
+	if [ "$(wc -l <"$state")" -lt 2 ]; then status=in_progress conclusion=""; else status=completed conclusion=failure; fi
+	echo '[{"databaseId":7,"workflowName":"CI","event":"push","status":"'$status'","conclusion":"'$conclusion'","url":"https://github.com/o/r/actions/runs/7"}]'
+	;;
+"run view")
+	case "$4" in
+	--log-failed) printf 'test\tRun go test\t2024-05-01T12:00:05.0000000Z --- FAIL: TestAdd\n' ;;
+	*) echo '{"jobs":[{"name":"build","conclusion":"success"},{"name":"test","conclusion":"failure","url":"https://github.com/o/r/actions/runs/7/job/1","steps":[{"name":"Run go test","conclusion":"failure"}]}]}' ;;
+	esac
+	;;
+*)
+	echo "unexpected gh $*" >&2
+	exit 1
+	;;
+esac
+`
+
+func TestGitHubActionsTool(t *testing.T) {
+	dir := t.TempDir()
+	for _, args := range [][]string{{"init", "-q"}, {"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "--allow-empty", "-m", "initial"}} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	bin := t.TempDir()
+	if err := os.WriteFile(filepath.Join(bin, "gh"), []byte(fakeGH), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	var progress []string
+	tool := &GitHubActionsTool{
+		Bash: &BashTool{
+			WorkingDir: NewMutableWorkingDir(dir),
+			Env:        []string{"PATH=" + bin + ":" + os.Getenv("PATH")},
+			OnOutput:   func(ctx context.Context, chunk string) { progress = append(progress, chunk) },
+		},
+		PollInterval: time.Millisecond,
+	}
+	out := tool.Run(context.Background(), json.RawMessage(`{}`))
+	if out.Error == nil {
+		t.Fatal("expected the failed run to be an error")
+	}
+	text := out.Error.Error()
+	for _, want := range []string{"1 of 1 workflow runs failed", "CI (push, run 7): failure", `Job "test": failure at step "Run go test"`, "    --- FAIL: TestAdd"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("expected %q in:\n%s", want, text)
+		}
+	}
+	if strings.Contains(text, `"build"`) {
+		t.Errorf("expected only the failed job, got:\n%s", text)
+	}
+	if len(progress) != 1 || !strings.Contains(progress[0], "0 of 1 workflow runs completed") {
+		t.Errorf("expected progress while the run was in progress, got %q", progress)
+	}
+
+	if out := tool.Run(context.Background(), json.RawMessage(`{"run_id": 7, "workflow": "ci.yml"}`)); out.Error == nil {
+		t.Error("expected run_id with workflow to be rejected")
+	}
+}
@@ -10,6 +10,8 @@ import (
 	"strings"
 
 	"shelley.exe.dev/llm"
+	"shelley.exe.dev/remote"
+	"shelley.exe.dev/sandbox"
 )
 
 // LLMServiceProvider defines the interface for getting LLM services
@@ -22,6 +24,11 @@ type LLMServiceProvider interface {
 type KeywordTool struct {
 	llmProvider LLMServiceProvider
 	workingDir  *MutableWorkingDir
+	// Remote, if set, is the shell of the host or container whose files the tool searches.
+	Remote remote.Shell
+	// Sandbox, if set, runs the search under bubblewrap with this policy,
+	// so it sees the files sandboxed commands see.
+	Sandbox *sandbox.Policy
 }
 
 // NewKeywordTool creates a new keyword tool with the given LLM provider
@@ -111,7 +118,7 @@ func (k *KeywordTool) keywordRun(ctx context.Context, m json.RawMessage) llm.Too
 		return llm.ErrorToolOut(err)
 	}
 	wd := k.workingDir.Get()
-	root, err := FindRepoRoot(wd)
+	root, err := k.findRepoRoot(wd)
 	if err == nil {
 		wd = root
 	}
@@ -120,7 +127,7 @@ func (k *KeywordTool) keywordRun(ctx context.Context, m json.RawMessage) llm.Too
 	// first remove stopwords
 	var keep []string
 	for _, term := range input.SearchTerms {
-		out, err := ripgrep(ctx, wd, []string{term})
+		out, err := k.ripgrep(ctx, wd, []string{term})
 		if err != nil {
 			return llm.ErrorToolOut(err)
 		}
@@ -139,7 +146,7 @@ func (k *KeywordTool) keywordRun(ctx context.Context, m json.RawMessage) llm.Too
 	var out string
 	for {
 		var err error
-		out, err = ripgrep(ctx, wd, keep)
+		out, err = k.ripgrep(ctx, wd, keep)
 		if err != nil {
 			return llm.ErrorToolOut(err)
 		}
@@ -195,13 +202,36 @@ func (k *KeywordTool) keywordRun(ctx context.Context, m json.RawMessage) llm.Too
 	return llm.ToolOut{LLMContent: llm.TextContent(resp.Content[0].Text)}
 }
 
-func ripgrep(ctx context.Context, wd string, terms []string) (string, error) {
+// findRepoRoot is FindRepoRoot, run on the remote host if there is one.
+func (k *KeywordTool) findRepoRoot(wd string) (string, error) {
+	if k.Remote == nil {
+		return FindRepoRoot(wd)
+	}
+	out, err := k.Remote.Git(wd, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return "", fmt.Errorf("failed to find git repository root: %w", err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+func (k *KeywordTool) ripgrep(ctx context.Context, wd string, terms []string) (string, error) {
 	args := []string{"-C", "10", "-i", "--line-number", "--with-filename"}
 	for _, term := range terms {
 		args = append(args, "-e", term)
 	}
 	cmd := exec.CommandContext(ctx, "rg", args...)
 	cmd.Dir = wd
+	inProcessGroup(cmd)
+	switch {
+	case k.Remote != nil:
+		if err := k.Remote.Wrap(cmd); err != nil {
+			return "", err
+		}
+	case k.Sandbox != nil:
+		if err := k.Sandbox.Wrap(cmd); err != nil {
+			return "", err
+		}
+	}
 	out, err := cmd.CombinedOutput()
 	if err != nil {
 		// ripgrep returns exit code 1 when no matches are found, which is not an error for us
@@ -0,0 +1,303 @@
+package claudetool
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"shelley.exe.dev/llm"
+	"shelley.exe.dev/remote"
+)
+
+// LintTool runs a project's linters and formatters and returns their
+// findings as a list of diagnostics, optionally fixing what they can first.
+type LintTool struct {
+	// Bash runs the linters, in its working directory and with its
+	// environment, sandbox or remote shell. Its TrackChanges records the
+	// files fixes change.
+	Bash *BashTool
+}
+
+const (
+	lintName        = "lint"
+	lintDescription = `Run the project's linters and formatters and get their findings as diagnostics
+with file and line.
+
+Supports golangci-lint, gofmt, prettier and ruff. Without linters, the ones
+the project is configured for are run: golangci-lint with a .golangci config,
+gofmt with a go.mod, prettier with a prettier config or dependency, and ruff
+with a ruff config. Set fix to apply the fixes the tools can make (gofmt -w,
+prettier --write, ruff --fix and ruff format, golangci-lint --fix) before
+checking again. Run it after making changes, before calling the work done.
+`
+	lintInputSchema = `{
+  "type": "object",
+  "properties": {
+    "linters": {
+      "type": "array",
+      "items": {"type": "string", "enum": ["golangci-lint", "gofmt", "prettier", "ruff"]},
+      "description": "The linters to run; detected from the project's configuration when omitted"
+    },
+    "paths": {
+      "type": "array",
+      "items": {"type": "string"},
+      "description": "Files or directories to check, relative to the working directory; defaults to all (./... for golangci-lint)"
+    },
+    "fix": {
+      "type": "boolean",
+      "description": "Apply automatic fixes, then report what remains"
+    }
+  }
+}`
+
+	// lintMaxDiagnostics is how many diagnostics the tool returns.
+	lintMaxDiagnostics = 200
+)
+
+type lintInput struct {
+	Linters []string `json:"linters"`
+	Paths   []string `json:"paths"`
+	Fix     bool     `json:"fix"`
+}
+
+// lintDiagnostic is a problem reported by a linter. Line and Column are 0
+// for problems with the whole file, such as formatting.
+type lintDiagnostic struct {
+	Linter  string `json:"linter"`
+	File    string `json:"file"`
+	Line    int    `json:"line,omitempty"`
+	Column  int    `json:"column,omitempty"`
+	Message string `json:"message"`
+}
+
+// lintResult is the outcome of a lint run. It is also the tool's display data.
+type lintResult struct {
+	Type        string           `json:"type"`
+	Linters     []string         `json:"linters"`
+	Fixed       bool             `json:"fixed,omitempty"`
+	Diagnostics []lintDiagnostic `json:"diagnostics"`
+	Errors      []string         `json:"errors,omitempty"`
+}
+
+// linter describes how to run one linter. The commands are run with the
+// paths to check appended.
+type linter struct {
+	check        string
+	fix          string
+	defaultPaths string
+	parse        func(out string) []lintDiagnostic
+}
+
+var linters = map[string]linter{
+	"golangci-lint": {
+		check:        "golangci-lint run",
+		fix:          "golangci-lint run --fix",
+		defaultPaths: "./...",
+		parse:        parseLineDiagnostics,
+	},
+	"gofmt": {
+		check:        "gofmt -l",
+		fix:          "gofmt -w",
+		defaultPaths: ".",
+		parse:        fileDiagnostics("", "not formatted; gofmt -w fixes it"),
+	},
+	"prettier": {
+		check:        "npx --no-install prettier --check",
+		fix:          "npx --no-install prettier --write",
+		defaultPaths: ".",
+		parse:        fileDiagnostics("[warn] ", "not formatted; prettier --write fixes it"),
+	},
+	"ruff": {
+		// Both commands are given the paths.
+		check:        "ruff check --output-format=concise {} ; ruff format --check",
+		fix:          "ruff check --fix {} ; ruff format",
+		defaultPaths: ".",
+		parse:        fileDiagnostics("Would reformat: ", "not formatted; ruff format fixes it"),
+	},
+}
+
+// detectLintersScript prints the linters the working directory is
+// configured for, one per line.
+const detectLintersScript = `ls .golangci.yml .golangci.yaml .golangci.toml .golangci.json >/dev/null 2>&1 && echo golangci-lint
+[ -f go.mod ] && echo gofmt
+{ ls .prettierrc* prettier.config.* >/dev/null 2>&1 || grep -q '"prettier"' package.json 2>/dev/null; } && echo prettier
+{ [ -f ruff.toml ] || [ -f .ruff.toml ] || grep -q '^\[tool\.ruff' pyproject.toml 2>/dev/null; } && echo ruff
+true`
+
+// Tool returns an llm.Tool for linting.
+func (l *LintTool) Tool() *llm.Tool {
+	return &llm.Tool{
+		Name:        lintName,
+		Description: lintDescription,
+		InputSchema: llm.MustSchema(lintInputSchema),
+		Run:         l.Run,
+	}
+}
+
+// Run executes the lint tool.
+func (l *LintTool) Run(ctx context.Context, m json.RawMessage) llm.ToolOut {
+	var req lintInput
+	if err := json.Unmarshal(m, &req); err != nil {
+		return llm.ErrorfToolOut("failed to parse lint input: %w", err)
+	}
+	names := req.Linters
+	if len(names) == 0 {
+		out, _ := l.Bash.RunCommand(ctx, detectLintersScript)
+		names = strings.Fields(out)
+		if len(names) == 0 {
+			return llm.ErrorfToolOut("found no linter configuration in %s; set linters to run them anyway", l.Bash.getWorkingDir())
+		}
+	}
+	for _, name := range names {
+		if _, ok := linters[name]; !ok {
+			return llm.ErrorfToolOut("unsupported linter %q; use golangci-lint, gofmt, prettier or ruff", name)
+		}
+	}
+
+	if req.Fix && l.Bash.TrackChanges != nil {
+		defer l.Bash.TrackChanges(ctx, l.Bash.getWorkingDir())()
+	}
+
+	result := &lintResult{Type: lintName, Linters: names, Fixed: req.Fix, Diagnostics: []lintDiagnostic{}}
+	for _, name := range names {
+		lt := linters[name]
+		if req.Fix {
+			// Whatever the fix couldn't handle shows up in the check.
+			l.Bash.RunCommand(ctx, lintCommand(lt.fix, lt.defaultPaths, req.Paths))
+		}
+		out, err := l.Bash.RunCommand(ctx, lintCommand(lt.check, lt.defaultPaths, req.Paths))
+		if ctx.Err() != nil {
+			return llm.ErrorToolOut(ctx.Err())
+		}
+		diags := lt.parse(out)
+		for i := range diags {
+			diags[i].Linter = name
+		}
+		result.Diagnostics = append(result.Diagnostics, diags...)
+
+		// Linters exit non-zero when they find problems; only a failure
+		// without any is an error.
+		var exitErr *exec.ExitError
+		switch {
+		case errors.As(err, &exitErr) && exitErr.ExitCode() == 127:
+			result.Errors = append(result.Errors, name+" is not installed")
+		case err != nil && len(diags) == 0:
+			result.Errors = append(result.Errors, fmt.Sprintf("%s: %v\n%s", name, err, lastLines(out, testErrorLines)))
+		}
+	}
+
+	text := result.String()
+	if len(result.Errors) > 0 && len(result.Errors) == len(names) {
+		return llm.ToolOut{Error: errors.New(text), Display: result}
+	}
+	return llm.ToolOut{LLMContent: llm.TextContent(text), Display: result}
+}
+
+// lintCommand returns command with paths, or defaultPaths if there are
+// none, substituted for each {} and appended.
+func lintCommand(command, defaultPaths string, paths []string) string {
+	args := defaultPaths
+	if len(paths) > 0 {
+		var quoted []string
+		for _, p := range paths {
+			quoted = append(quoted, remote.Quote(p))
+		}
+		args = strings.Join(quoted, " ")
+	}
+	return strings.ReplaceAll(command, "{}", args) + " " + args
+}
+
+// lineDiagnosticRe matches the file:line:column: message lines of
+// golangci-lint, ruff and most compilers.
+var lineDiagnosticRe = regexp.MustCompile(`^(\S+?):(\d+):(?:(\d+):)? (.+)$`)
+
+// parseLineDiagnostics parses file:line:column: message lines, ignoring
+// any others, such as source excerpts and summaries.
+func parseLineDiagnostics(out string) []lintDiagnostic {
+	var diags []lintDiagnostic
+	for line := range strings.SplitSeq(out, "\n") {
+		match := lineDiagnosticRe.FindStringSubmatch(strings.TrimRight(line, "\r"))
+		if match == nil {
+			continue
+		}
+		d := lintDiagnostic{File: match[1], Message: match[4]}
+		d.Line, _ = strconv.Atoi(match[2])
+		d.Column, _ = strconv.Atoi(match[3])
+		diags = append(diags, d)
+	}
+	return diags
+}
+
+// fileDiagnostics returns a parser for tools that list the files with
+// problems one per line after prefix, reporting message for each, and
+// report other problems as file:line:column: message lines.
+func fileDiagnostics(prefix, message string) func(string) []lintDiagnostic {
+	return func(out string) []lintDiagnostic {
+		diags := parseLineDiagnostics(out)
+		for line := range strings.SplitSeq(out, "\n") {
+			line = strings.TrimSpace(line)
+			file, ok := strings.CutPrefix(line, prefix)
+			// Skip summaries, such as prettier's "Code style issues found
+			// in 2 files. Run Prettier with --write to fix."
+			if !ok || file == "" || lineDiagnosticRe.MatchString(line) || strings.HasPrefix(file, "Code style issues") {
+				continue
+			}
+			diags = append(diags, lintDiagnostic{File: file, Message: message})
+		}
+		return diags
+	}
+}
+
+// String formats the result for the agent, grouped by file.
+func (r *lintResult) String() string {
+	var b strings.Builder
+	verb := "Checked"
+	if r.Fixed {
+		verb = "Fixed what could be fixed, then checked"
+	}
+	fmt.Fprintf(&b, "%s with %s: ", verb, strings.Join(r.Linters, ", "))
+	if len(r.Diagnostics) == 0 {
+		b.WriteString("no problems found.")
+	} else {
+		fmt.Fprintf(&b, "%d problems.", len(r.Diagnostics))
+	}
+	for _, e := range r.Errors {
+		b.WriteString("\n\nError: " + e)
+	}
+
+	diags := r.Diagnostics[:min(len(r.Diagnostics), lintMaxDiagnostics)]
+	var files []string
+	byFile := make(map[string][]lintDiagnostic)
+	for _, d := range diags {
+		if _, ok := byFile[d.File]; !ok {
+			files = append(files, d.File)
+		}
+		byFile[d.File] = append(byFile[d.File], d)
+	}
+	for _, file := range files {
+		b.WriteString("\n\n" + file + ":")
+		fileDiags := byFile[file]
+		slices.SortStableFunc(fileDiags, func(a, b lintDiagnostic) int { return a.Line - b.Line })
+		for _, d := range fileDiags {
+			pos := ""
+			if d.Line > 0 {
+				pos = strconv.Itoa(d.Line)
+				if d.Column > 0 {
+					pos += ":" + strconv.Itoa(d.Column)
+				}
+				pos += " "
+			}
+			fmt.Fprintf(&b, "\n  %s%s (%s)", pos, d.Message, d.Linter)
+		}
+	}
+	if len(r.Diagnostics) > len(diags) {
+		fmt.Fprintf(&b, "\n\n[%d more problems omitted; check fewer paths to see them]", len(r.Diagnostics)-len(diags))
+	}
+	return b.String()
+}
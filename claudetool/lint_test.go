@@ -0,0 +1,102 @@
+package claudetool
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLintParsers(t *testing.T) {
+	golangci := `server/handlers.go:120:5: ineffectual assignment to err (ineffassign)
+	err = nil
+	^
+db/db.go:33:2: Error return value of ` + "`rows.Close`" + ` is not checked (errcheck)
+2 issues:
+* errcheck: 1
+* ineffassign: 1
+`
+	diags := linters["golangci-lint"].parse(golangci)
+	if len(diags) != 2 {
+		t.Fatalf("expected 2 diagnostics, got %+v", diags)
+	}
+	if d := diags[0]; d.File != "server/handlers.go" || d.Line != 120 || d.Column != 5 || d.Message != "ineffectual assignment to err (ineffassign)" {
+		t.Errorf("unexpected diagnostic %+v", d)
+	}
+
+	ruff := "app/main.py:1:8: F401 [*] `os` imported but unused\nFound 1 error.\n[*] 1 fixable with the `--fix` option.\nWould reformat: app/util.py\n1 file would be reformatted\n"
+	diags = linters["ruff"].parse(ruff)
+	if len(diags) != 2 || diags[0].Line != 1 || diags[1].File != "app/util.py" || diags[1].Line != 0 {
+		t.Errorf("expected a lint and a formatting diagnostic, got %+v", diags)
+	}
+
+	prettier := "Checking formatting...\n[warn] src/App.tsx\n[warn] Code style issues found in the above file. Run Prettier with --write to fix.\n"
+	diags = linters["prettier"].parse(prettier)
+	if len(diags) != 1 || diags[0].File != "src/App.tsx" {
+		t.Errorf("expected src/App.tsx to need formatting, got %+v", diags)
+	}
+
+	if got := lintCommand(linters["ruff"].check, ".", []string{"a b.py"}); got != "ruff check --output-format=concise 'a b.py' ; ruff format --check 'a b.py'" {
+		t.Errorf("unexpected command %q", got)
+	}
+}
+
+func TestLintTool(t *testing.T) {
+	if _, err := exec.LookPath("gofmt"); err != nil {
+		t.Skip("gofmt not found")
+	}
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod":    "module example.com/m\n",
+		"ok.go":     "package m\n\nfunc OK() {}\n",
+		"ugly.go":   "package m\nfunc  Ugly( ) {   }\n",
+		"broken.go": "package m\n\nfunc {\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var tracked string
+	tool := &LintTool{Bash: &BashTool{
+		WorkingDir:   NewMutableWorkingDir(dir),
+		TrackChanges: func(ctx context.Context, dir string) func() { tracked = dir; return func() {} },
+	}}
+
+	out := tool.Run(context.Background(), json.RawMessage(`{}`))
+	if out.Error != nil {
+		t.Fatal(out.Error)
+	}
+	result := out.Display.(*lintResult)
+	if len(result.Linters) != 1 || result.Linters[0] != "gofmt" {
+		t.Errorf("expected gofmt to be detected, got %v", result.Linters)
+	}
+	text := out.LLMContent[0].Text
+	if len(result.Diagnostics) != 2 || !strings.Contains(text, "ugly.go:\n  not formatted") || !strings.Contains(text, "broken.go:\n  3:6 ") {
+		t.Errorf("expected ugly.go and broken.go to be reported, got:\n%s", text)
+	}
+	if tracked != "" {
+		t.Error("expected no change tracking without fix")
+	}
+
+	out = tool.Run(context.Background(), json.RawMessage(`{"fix": true, "paths": ["ugly.go"]}`))
+	if out.Error != nil {
+		t.Fatal(out.Error)
+	}
+	if text := out.LLMContent[0].Text; !strings.HasSuffix(text, "no problems found.") {
+		t.Errorf("expected the fix to leave no problems, got:\n%s", text)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "ugly.go")); string(data) != "package m\n\nfunc Ugly() {}\n" {
+		t.Errorf("expected ugly.go to be formatted, got %q", data)
+	}
+	if tracked != dir {
+		t.Errorf("expected the fix to be tracked in %s, got %q", dir, tracked)
+	}
+
+	if out := tool.Run(context.Background(), json.RawMessage(`{"linters": ["eslint"]}`)); out.Error == nil {
+		t.Error("expected an unsupported linter to be an error")
+	}
+}
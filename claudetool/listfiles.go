@@ -0,0 +1,300 @@
+package claudetool
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"shelley.exe.dev/filepolicy"
+	"shelley.exe.dev/llm"
+	"shelley.exe.dev/remote"
+)
+
+// ListFilesTool lists the files under a directory as a tree, leaving out
+// the ones git ignores.
+type ListFilesTool struct {
+	// WorkingDir is the shared mutable working directory.
+	WorkingDir *MutableWorkingDir
+	// Policy, if set, decides which directories may be listed; files it
+	// denies are left out. It isn't applied to remote files.
+	Policy *filepolicy.Policy
+	// Remote, if set, is the shell of the host or container whose files the
+	// tool lists. Their sizes aren't shown, and only git repositories can
+	// be listed.
+	Remote remote.Shell
+}
+
+const (
+	listFilesName        = "list_files"
+	listFilesDescription = `List the files under a directory as a compact tree, with sizes.
+
+Files ignored by git (.gitignore, .git/info/exclude and the global excludes)
+are left out, as are .git itself and, outside git repositories, hidden
+directories, node_modules and vendor. Prefer this to find or ls -R.
+
+patterns filters files by glob: a pattern without a slash matches file
+names ("*.go"), one with a slash matches paths relative to the listed
+directory ("cmd/**/*.go", where ** matches any number of directories).
+max_depth collapses deeper directories into a summary line.
+`
+	listFilesInputSchema = `{
+  "type": "object",
+  "properties": {
+    "path": {
+      "type": "string",
+      "description": "The directory to list (absolute or relative to the working directory); defaults to the working directory"
+    },
+    "patterns": {
+      "type": "array",
+      "items": {"type": "string"},
+      "description": "Only list files matching one of these globs"
+    },
+    "max_depth": {
+      "type": "integer",
+      "description": "Show directories this deep at most, summarizing the ones below"
+    }
+  }
+}`
+
+	// listFilesMaxLines is how many lines of tree the tool returns.
+	listFilesMaxLines = 500
+)
+
+type listFilesInput struct {
+	Path     string   `json:"path"`
+	Patterns []string `json:"patterns"`
+	MaxDepth int      `json:"max_depth"`
+}
+
+// Tool returns an llm.Tool for listing files.
+func (l *ListFilesTool) Tool() *llm.Tool {
+	return &llm.Tool{
+		Name:        listFilesName,
+		Description: listFilesDescription,
+		InputSchema: llm.MustSchema(listFilesInputSchema),
+		Run:         l.Run,
+	}
+}
+
+// listedFile is a file found by the tool, with its path relative to the
+// listed directory. Size is -1 if it isn't known.
+type listedFile struct {
+	path string
+	size int64
+}
+
+// Run executes the list_files tool.
+func (l *ListFilesTool) Run(ctx context.Context, m json.RawMessage) llm.ToolOut {
+	var req listFilesInput
+	if err := json.Unmarshal(m, &req); err != nil {
+		return llm.ErrorfToolOut("failed to parse list_files input: %w", err)
+	}
+	for _, pattern := range req.Patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return llm.ErrorfToolOut("invalid pattern %q: %w", pattern, err)
+		}
+	}
+
+	dir := l.WorkingDir.Get()
+	if req.Path != "" {
+		if filepath.IsAbs(req.Path) {
+			dir = filepath.Clean(req.Path)
+		} else {
+			dir = filepath.Join(dir, req.Path)
+		}
+	}
+
+	files, err := l.list(ctx, dir)
+	if err != nil {
+		return llm.ErrorToolOut(err)
+	}
+	if l.Remote == nil {
+		files = slices.DeleteFunc(files, func(f listedFile) bool { return l.Policy.Denied(filepath.Join(dir, f.path)) })
+	}
+	if len(req.Patterns) > 0 {
+		files = slices.DeleteFunc(files, func(f listedFile) bool {
+			return !slices.ContainsFunc(req.Patterns, func(pattern string) bool { return filepolicy.MatchGlob(pattern, f.path) })
+		})
+	}
+	if len(files) == 0 {
+		return llm.ToolOut{LLMContent: llm.TextContent(fmt.Sprintf("No files found in %s.", dir))}
+	}
+	return llm.ToolOut{LLMContent: llm.TextContent(renderFileTree(dir, files, req.MaxDepth))}
+}
+
+// list returns the files under dir that git doesn't ignore, sorted.
+func (l *ListFilesTool) list(ctx context.Context, dir string) ([]listedFile, error) {
+	args := []string{"ls-files", "-z", "--cached", "--others", "--exclude-standard"}
+	if l.Remote != nil {
+		if _, err := l.Remote.IsDir(ctx, dir); err != nil {
+			return nil, err
+		}
+		out, err := l.Remote.Git(dir, args...)
+		if err != nil {
+			return nil, fmt.Errorf("%s is not in a git repository, which list_files needs in a remote workspace; use bash instead", dir)
+		}
+		var files []listedFile
+		for _, name := range splitNul(out) {
+			files = append(files, listedFile{path: name, size: -1})
+		}
+		slices.SortFunc(files, func(a, b listedFile) int { return strings.Compare(a.path, b.path) })
+		return slices.CompactFunc(files, func(a, b listedFile) bool { return a.path == b.path }), nil
+	}
+
+	if err := l.Policy.CheckRead(dir); err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("not a directory: %s", dir)
+	}
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	inProcessGroup(cmd)
+	out, err := cmd.Output()
+	if err != nil {
+		return walkFiles(dir)
+	}
+	var files []listedFile
+	for _, name := range splitNul(out) {
+		// Tracked files may have been deleted, and submodules are directories
+		info, err := os.Lstat(filepath.Join(dir, name))
+		if err != nil || info.IsDir() {
+			continue
+		}
+		files = append(files, listedFile{path: name, size: info.Size()})
+	}
+	slices.SortFunc(files, func(a, b listedFile) int { return strings.Compare(a.path, b.path) })
+	// A file with merge conflicts is listed once per stage
+	return slices.CompactFunc(files, func(a, b listedFile) bool { return a.path == b.path }), nil
+}
+
+// walkFiles returns the files under dir, which isn't in a git repository,
+// skipping hidden directories and dependency directories.
+func walkFiles(dir string) ([]listedFile, error) {
+	var files []listedFile
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil // Continue on errors
+		}
+		if d.IsDir() {
+			if p != dir && (strings.HasPrefix(d.Name(), ".") || d.Name() == "node_modules" || d.Name() == "vendor") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		rel, _ := filepath.Rel(dir, p)
+		files = append(files, listedFile{path: filepath.ToSlash(rel), size: info.Size()})
+		return nil
+	})
+	return files, err
+}
+
+func splitNul(out []byte) []string {
+	var names []string
+	for _, name := range bytes.Split(out, []byte{0}) {
+		if len(name) > 0 {
+			names = append(names, string(name))
+		}
+	}
+	return names
+}
+
+// fileTree is a directory of the listing, or a file if children is nil.
+type fileTree struct {
+	name     string
+	size     int64 // -1 if unknown
+	files    int
+	children []*fileTree
+}
+
+func (t *fileTree) add(elems []string, size int64) {
+	t.files++
+	if t.size >= 0 {
+		t.size += size
+	}
+	if size < 0 {
+		t.size = -1
+	}
+	if len(elems) == 1 {
+		t.children = append(t.children, &fileTree{name: elems[0], size: size, files: 1})
+		return
+	}
+	var child *fileTree
+	if n := len(t.children); n > 0 && t.children[n-1].children != nil && t.children[n-1].name == elems[0] {
+		child = t.children[n-1]
+	} else {
+		child = &fileTree{name: elems[0], children: []*fileTree{}}
+		t.children = append(t.children, child)
+	}
+	child.add(elems[1:], size)
+}
+
+// renderFileTree renders files, sorted by path, as an indented tree under
+// dir, with directories deeper than maxDepth (if positive) summarized.
+func renderFileTree(dir string, files []listedFile, maxDepth int) string {
+	root := &fileTree{name: dir, children: []*fileTree{}}
+	for _, f := range files {
+		root.add(strings.Split(f.path, "/"), f.size)
+	}
+
+	var lines []string
+	var render func(t *fileTree, depth int)
+	render = func(t *fileTree, depth int) {
+		// Directories first, like most file browsers
+		dirs := slices.DeleteFunc(slices.Clone(t.children), func(c *fileTree) bool { return c.children == nil })
+		regular := slices.DeleteFunc(slices.Clone(t.children), func(c *fileTree) bool { return c.children != nil })
+		indent := strings.Repeat("  ", depth)
+		for _, c := range dirs {
+			lines = append(lines, fmt.Sprintf("%s%s/ (%s)", indent, c.name, treeSummary(c)))
+			if maxDepth <= 0 || depth+1 < maxDepth {
+				render(c, depth+1)
+			}
+		}
+		for _, c := range regular {
+			if c.size < 0 {
+				lines = append(lines, indent+c.name)
+			} else {
+				lines = append(lines, fmt.Sprintf("%s%s %s", indent, c.name, humanizeBytes(int(c.size))))
+			}
+		}
+	}
+	render(root, 0)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s (%s)\n", dir, treeSummary(root))
+	if len(lines) > listFilesMaxLines {
+		b.WriteString(strings.Join(lines[:listFilesMaxLines], "\n"))
+		fmt.Fprintf(&b, "\n[%d more lines not shown; narrow the listing with path, patterns or max_depth]\n", len(lines)-listFilesMaxLines)
+	} else {
+		b.WriteString(strings.Join(lines, "\n"))
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+func treeSummary(t *fileTree) string {
+	noun := "files"
+	if t.files == 1 {
+		noun = "file"
+	}
+	if t.size < 0 {
+		return fmt.Sprintf("%d %s", t.files, noun)
+	}
+	return fmt.Sprintf("%d %s, %s", t.files, noun, humanizeBytes(int(t.size)))
+}
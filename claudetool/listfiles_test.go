@@ -0,0 +1,77 @@
+package claudetool
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestListFilesTool(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		".gitignore":                "node_modules/\n*.log\n",
+		"main.go":                   "package main\n",
+		"debug.log":                 "noise",
+		"cmd/tool/tool.go":          "package tool\n",
+		"cmd/tool/README.md":        "# tool\n",
+		"node_modules/dep/index.js": "module.exports = 1\n",
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tool := &ListFilesTool{WorkingDir: NewMutableWorkingDir(dir)}
+	run := func(input listFilesInput) string {
+		t.Helper()
+		data, _ := json.Marshal(input)
+		out := tool.Run(context.Background(), data)
+		if out.Error != nil {
+			t.Fatalf("unexpected error: %v", out.Error)
+		}
+		return out.LLMContent[0].Text
+	}
+
+	// Outside a repository, dependency directories are skipped
+	if out := run(listFilesInput{}); strings.Contains(out, "index.js") || !strings.Contains(out, "debug.log") {
+		t.Errorf("unexpected listing:\n%s", out)
+	}
+
+	if out, err := exec.Command("git", "init", dir).CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v\n%s", err, out)
+	}
+	out := run(listFilesInput{})
+	want := dir + ` (4 files, 53B)
+cmd/ (2 files, 20B)
+  tool/ (2 files, 20B)
+    README.md 7B
+    tool.go 13B
+.gitignore 20B
+main.go 13B
+`
+	if out != want {
+		t.Errorf("unexpected listing:\n%s\nwant:\n%s", out, want)
+	}
+
+	if out := run(listFilesInput{MaxDepth: 1}); strings.Contains(out, "tool/") || !strings.Contains(out, "cmd/ (2 files, 20B)") {
+		t.Errorf("expected cmd to be summarized:\n%s", out)
+	}
+	if out := run(listFilesInput{Path: "cmd", Patterns: []string{"*.go"}}); strings.Contains(out, "README") || !strings.Contains(out, "tool.go") {
+		t.Errorf("expected only the Go files under cmd:\n%s", out)
+	}
+	if out := run(listFilesInput{Patterns: []string{"*.rs"}}); !strings.HasPrefix(out, "No files found") {
+		t.Errorf("expected no files, got:\n%s", out)
+	}
+
+	data, _ := json.Marshal(listFilesInput{Path: "missing"})
+	if out := tool.Run(context.Background(), data); out.Error == nil {
+		t.Error("expected an error for a missing directory")
+	}
+}
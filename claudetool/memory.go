@@ -0,0 +1,68 @@
+package claudetool
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"shelley.exe.dev/llm"
+)
+
+// RememberTool saves durable notes that are shown in future conversations for the same project.
+type RememberTool struct {
+	// Save persists the memory. It is called with the trimmed content.
+	Save func(ctx context.Context, content string) error
+}
+
+const (
+	rememberName        = "remember"
+	rememberDescription = `Save a durable note about this project for future conversations.
+
+Memories are keyed by project (git origin, or working directory outside git) and are
+included in the system prompt of every new conversation for that project.
+
+Use this for stable facts worth knowing next time: user preferences, project conventions,
+non-obvious build or test commands, pitfalls you discovered. Do not save transient task
+state or anything already recorded in the repository. Keep each memory short and self-contained.
+`
+	rememberInputSchema = `{
+  "type": "object",
+  "required": ["content"],
+  "properties": {
+    "content": {
+      "type": "string",
+      "description": "The note to remember"
+    }
+  }
+}`
+)
+
+type rememberInput struct {
+	Content string `json:"content"`
+}
+
+// Tool returns an llm.Tool for saving memories.
+func (r *RememberTool) Tool() *llm.Tool {
+	return &llm.Tool{
+		Name:        rememberName,
+		Description: rememberDescription,
+		InputSchema: llm.MustSchema(rememberInputSchema),
+		Run:         r.Run,
+	}
+}
+
+// Run executes the remember tool.
+func (r *RememberTool) Run(ctx context.Context, m json.RawMessage) llm.ToolOut {
+	var req rememberInput
+	if err := json.Unmarshal(m, &req); err != nil {
+		return llm.ErrorfToolOut("failed to parse remember input: %w", err)
+	}
+	content := strings.TrimSpace(req.Content)
+	if content == "" {
+		return llm.ErrorfToolOut("content is required")
+	}
+	if err := r.Save(ctx, content); err != nil {
+		return llm.ErrorfToolOut("failed to save memory: %w", err)
+	}
+	return llm.ToolOut{LLMContent: llm.TextContent("remembered")}
+}
@@ -0,0 +1,120 @@
+package claudetool
+
+import (
+	"io"
+	"os"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// outputStreamInterval is how often a running command's new output is delivered.
+const outputStreamInterval = 250 * time.Millisecond
+
+// outputStream passes a command's output to send in batches, so it can be
+// watched while the command runs. The output is either written to the
+// stream or, for commands writing to a file, read from that file. Batches
+// don't split UTF-8 sequences.
+type outputStream struct {
+	send func(chunk string)
+	file *os.File // file being followed, if any
+
+	mu      sync.Mutex
+	buf     []byte
+	done    chan struct{}
+	stopped chan struct{}
+}
+
+func newOutputStream(send func(chunk string)) *outputStream {
+	return startOutputStream(send, nil)
+}
+
+// tailOutputStream returns an outputStream that follows the file at path.
+func tailOutputStream(path string, send func(chunk string)) (*outputStream, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return startOutputStream(send, file), nil
+}
+
+func startOutputStream(send func(chunk string), file *os.File) *outputStream {
+	s := &outputStream{
+		send:    send,
+		file:    file,
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	go s.run()
+	return s
+}
+
+func (s *outputStream) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	s.buf = append(s.buf, p...)
+	s.mu.Unlock()
+	return len(p), nil
+}
+
+// Close delivers the remaining output and stops the stream.
+func (s *outputStream) Close() error {
+	close(s.done)
+	<-s.stopped
+	return nil
+}
+
+func (s *outputStream) run() {
+	defer close(s.stopped)
+	if s.file != nil {
+		defer s.file.Close()
+	}
+	ticker := time.NewTicker(outputStreamInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.flush(false)
+		case <-s.done:
+			s.flush(true)
+			return
+		}
+	}
+}
+
+// flush sends the buffered output. Unless final, an incomplete UTF-8
+// sequence at the end is held back for the next batch.
+func (s *outputStream) flush(final bool) {
+	if s.file != nil {
+		// Reads continue where the last one stopped
+		if data, err := io.ReadAll(s.file); err == nil {
+			s.Write(data)
+		}
+	}
+
+	s.mu.Lock()
+	n := len(s.buf)
+	if !final {
+		n = completeUTF8(s.buf)
+	}
+	chunk := string(s.buf[:n])
+	s.buf = append(s.buf[:0], s.buf[n:]...)
+	s.mu.Unlock()
+
+	if chunk != "" {
+		s.send(chunk)
+	}
+}
+
+// completeUTF8 returns the length of the longest prefix of b that doesn't
+// end in an incomplete UTF-8 sequence.
+func completeUTF8(b []byte) int {
+	for i := len(b) - 1; i >= 0 && i >= len(b)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(b[i]) {
+			if utf8.FullRune(b[i:]) {
+				return len(b)
+			}
+			return i
+		}
+	}
+	return len(b)
+}
@@ -14,7 +14,10 @@ import (
 	"strings"
 
 	"github.com/pkg/diff"
+	"shelley.exe.dev/filepolicy"
 	"shelley.exe.dev/llm"
+	"shelley.exe.dev/remote"
+	"shelley.exe.dev/sandbox"
 	"sketch.dev/claudetool/editbuf"
 	"sketch.dev/claudetool/patchkit"
 )
@@ -25,6 +28,14 @@ import (
 // and returns a new, possibly altered tool output.
 type PatchCallback func(input PatchInput, output llm.ToolOut) llm.ToolOut
 
+// FileEdit describes a change made to a file, with enough content to undo it.
+type FileEdit struct {
+	Path string
+	// Before is the file's content before the edit, or nil if the edit created the file.
+	Before []byte
+	After  []byte
+}
+
 // PatchTool specifies an llm.Tool for patching files.
 // PatchTools are not concurrency-safe.
 type PatchTool struct {
@@ -39,6 +50,16 @@ type PatchTool struct {
 	// NB: The actual implementation of the patch tool is unchanged,
 	// this flag merely extends the description and input schema to include the clipboard operations.
 	ClipboardEnabled bool
+	// OnEdit, if set, is called after each successful write.
+	// Errors are logged and do not fail the patch.
+	OnEdit func(ctx context.Context, edit FileEdit) error
+	// Sandbox, if set, limits the files the tool may write to its writable directories.
+	Sandbox *sandbox.Policy
+	// Policy decides which files the tool may read and write; nil still
+	// keeps it out of .git directories. It isn't applied to remote files.
+	Policy *filepolicy.Policy
+	// Remote, if set, is the shell of the host or container whose files the tool patches.
+	Remote remote.Shell
 	// clipboards stores clipboard name -> text
 	clipboards map[string]string
 }
@@ -48,6 +69,37 @@ func (p *PatchTool) getWorkingDir() string {
 	return p.WorkingDir.Get()
 }
 
+// readFile reads the file at path, on the remote host if there is one.
+func (p *PatchTool) readFile(ctx context.Context, path string) ([]byte, error) {
+	if p.Remote != nil {
+		return p.Remote.ReadFile(ctx, path)
+	}
+	if err := p.Policy.CheckRead(path); err != nil {
+		return nil, err
+	}
+	return os.ReadFile(path)
+}
+
+// writeFile writes the patched file, creating its directory if needed.
+func (p *PatchTool) writeFile(ctx context.Context, path string, data []byte) error {
+	if p.Remote != nil {
+		if err := p.Remote.WriteFile(ctx, path, data); err != nil {
+			return fmt.Errorf("failed to write patched contents to file %q: %w", path, err)
+		}
+		return nil
+	}
+	if err := p.Policy.CheckWrite(path, len(data)); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("failed to create directory %q: %w", filepath.Dir(path), err)
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write patched contents to file %q: %w", path, err)
+	}
+	return nil
+}
+
 // Tool returns an llm.Tool based on p.
 func (p *PatchTool) Tool() *llm.Tool {
 	description := PatchBaseDescription + PatchUsageNotes
@@ -355,9 +407,12 @@ func (p *PatchTool) patchRun(ctx context.Context, input *PatchInput) llm.ToolOut
 	if len(input.Patches) == 0 {
 		return llm.ErrorToolOut(fmt.Errorf("no patches provided"))
 	}
+	if p.Sandbox != nil && !p.Sandbox.CanWrite(input.Path) {
+		return llm.ErrorfToolOut("sandbox: %s is outside the writable directories %s", input.Path, strings.Join(p.Sandbox.Writable, ", "))
+	}
 	// TODO: check whether the file is autogenerated, and if so, require a "force" flag to modify it.
 
-	orig, err := os.ReadFile(input.Path)
+	orig, err := p.readFile(ctx, input.Path)
 	// If the file doesn't exist, we can still apply patches
 	// that don't require finding existing text.
 	switch {
@@ -372,6 +427,7 @@ func (p *PatchTool) patchRun(ctx context.Context, input *PatchInput) llm.ToolOut
 	case err != nil:
 		return llm.ErrorfToolOut("failed to read file %q: %w", input.Path, err)
 	}
+	existed := err == nil
 
 	likelyGoFile := strings.HasSuffix(input.Path, ".go")
 
@@ -524,11 +580,17 @@ func (p *PatchTool) patchRun(ctx context.Context, input *PatchInput) llm.ToolOut
 	if err != nil {
 		return llm.ErrorToolOut(err)
 	}
-	if err := os.MkdirAll(filepath.Dir(input.Path), 0o700); err != nil {
-		return llm.ErrorfToolOut("failed to create directory %q: %w", filepath.Dir(input.Path), err)
+	if err := p.writeFile(ctx, input.Path, patched); err != nil {
+		return llm.ErrorToolOut(err)
 	}
-	if err := os.WriteFile(input.Path, patched, 0o600); err != nil {
-		return llm.ErrorfToolOut("failed to write patched contents to file %q: %w", input.Path, err)
+	if p.OnEdit != nil {
+		edit := FileEdit{Path: input.Path, After: patched}
+		if existed {
+			edit.Before = append([]byte{}, orig...)
+		}
+		if err := p.OnEdit(ctx, edit); err != nil {
+			slog.WarnContext(ctx, "failed to record file edit", "path", input.Path, "error", err)
+		}
 	}
 
 	response := new(strings.Builder)
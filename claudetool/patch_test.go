@@ -8,7 +8,10 @@ import (
 	"strings"
 	"testing"
 
+	"shelley.exe.dev/filepolicy"
 	"shelley.exe.dev/llm"
+	"shelley.exe.dev/remote"
+	"shelley.exe.dev/sandbox"
 )
 
 func TestPatchTool_BasicOperations(t *testing.T) {
@@ -546,6 +549,128 @@ func TestPatchTool_RelativePaths(t *testing.T) {
 	}
 }
 
+func TestPatchTool_OnEdit(t *testing.T) {
+	tempDir := t.TempDir()
+	var edits []FileEdit
+	patch := &PatchTool{
+		WorkingDir: NewMutableWorkingDir(tempDir),
+		OnEdit: func(ctx context.Context, edit FileEdit) error {
+			edits = append(edits, edit)
+			return nil
+		},
+	}
+	ctx := context.Background()
+	testFile := filepath.Join(tempDir, "edit.txt")
+
+	run := func(patches ...PatchRequest) {
+		t.Helper()
+		msg, _ := json.Marshal(PatchInput{Path: testFile, Patches: patches})
+		patch.Run(ctx, msg)
+	}
+	run(PatchRequest{Operation: "overwrite", NewText: "one\n"})
+	run(PatchRequest{Operation: "replace", OldText: "one", NewText: "two"})
+	run(PatchRequest{Operation: "replace", OldText: "missing", NewText: "three"})
+
+	if len(edits) != 2 {
+		t.Fatalf("expected 2 recorded edits, got %d", len(edits))
+	}
+	if edits[0].Path != testFile || edits[0].Before != nil || string(edits[0].After) != "one\n" {
+		t.Errorf("unexpected edit creating the file: %+v", edits[0])
+	}
+	if string(edits[1].Before) != "one\n" || string(edits[1].After) != "two\n" {
+		t.Errorf("unexpected replace edit: %+v", edits[1])
+	}
+}
+
+func TestPatchTool_Sandbox(t *testing.T) {
+	tempDir := t.TempDir()
+	work := filepath.Join(tempDir, "work")
+	if err := os.Mkdir(work, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	patch := &PatchTool{
+		WorkingDir: NewMutableWorkingDir(work),
+		Sandbox:    &sandbox.Policy{Writable: []string{work}},
+	}
+	ctx := context.Background()
+
+	run := func(path string) llm.ToolOut {
+		t.Helper()
+		msg, _ := json.Marshal(PatchInput{Path: path, Patches: []PatchRequest{{Operation: "overwrite", NewText: "hi\n"}}})
+		return patch.Run(ctx, msg)
+	}
+	if out := run("inside.txt"); out.Error != nil {
+		t.Errorf("write inside the workspace failed: %v", out.Error)
+	}
+	if out := run("../outside.txt"); out.Error == nil || !strings.Contains(out.Error.Error(), "sandbox") {
+		t.Errorf("expected sandbox error writing outside the workspace, got %v", out.Error)
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, "outside.txt")); !os.IsNotExist(err) {
+		t.Errorf("outside.txt was written: %v", err)
+	}
+}
+
+func TestPatchTool_Policy(t *testing.T) {
+	work := t.TempDir()
+	patch := &PatchTool{
+		WorkingDir: NewMutableWorkingDir(work),
+		Policy:     &filepolicy.Policy{DeniedGlobs: []string{".env"}, MaxBytes: 10},
+	}
+	ctx := context.Background()
+
+	run := func(path, text string) llm.ToolOut {
+		t.Helper()
+		msg, _ := json.Marshal(PatchInput{Path: path, Patches: []PatchRequest{{Operation: "overwrite", NewText: text}}})
+		return patch.Run(ctx, msg)
+	}
+	if out := run("main.go", "hi\n"); out.Error != nil {
+		t.Errorf("allowed write failed: %v", out.Error)
+	}
+	for _, tc := range []struct{ path, text string }{
+		{".git/hooks/pre-commit", "hi\n"},
+		{"config/.env", "hi\n"},
+		{"big.txt", "more than ten bytes\n"},
+	} {
+		if out := run(tc.path, tc.text); out.Error == nil || !strings.Contains(out.Error.Error(), "write denied") {
+			t.Errorf("expected writing %s to be denied, got %v", tc.path, out.Error)
+		}
+		if _, err := os.Stat(filepath.Join(work, tc.path)); !os.IsNotExist(err) {
+			t.Errorf("%s was written: %v", tc.path, err)
+		}
+	}
+}
+
+func TestPatchTool_Remote(t *testing.T) {
+	log := fakeSSH(t)
+	work := t.TempDir()
+	patch := &PatchTool{
+		WorkingDir: NewMutableWorkingDir(work),
+		Remote:     (&remote.Host{Target: "build-box"}).Shell(),
+	}
+	ctx := context.Background()
+
+	run := func(req PatchRequest) llm.ToolOut {
+		t.Helper()
+		msg, _ := json.Marshal(PatchInput{Path: "sub/file.txt", Patches: []PatchRequest{req}})
+		return patch.Run(ctx, msg)
+	}
+	if out := run(PatchRequest{Operation: "replace", OldText: "a", NewText: "b"}); out.Error == nil || !strings.Contains(out.Error.Error(), "does not exist") {
+		t.Errorf("expected a missing file error, got %v", out.Error)
+	}
+	if out := run(PatchRequest{Operation: "overwrite", NewText: "one\n"}); out.Error != nil {
+		t.Fatal(out.Error)
+	}
+	if out := run(PatchRequest{Operation: "replace", OldText: "one", NewText: "two"}); out.Error != nil {
+		t.Fatal(out.Error)
+	}
+	if data, err := os.ReadFile(filepath.Join(work, "sub/file.txt")); err != nil || string(data) != "two\n" {
+		t.Errorf("unexpected file %q, %v", data, err)
+	}
+	if data, err := os.ReadFile(log); err != nil || !strings.Contains(string(data), "build-box") {
+		t.Errorf("expected the file to be patched over ssh, got %q, %v", data, err)
+	}
+}
+
 // Benchmark basic patch operations
 func BenchmarkPatchTool_BasicOperations(b *testing.B) {
 	tempDir := b.TempDir()
@@ -0,0 +1,156 @@
+package claudetool
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"strings"
+
+	"shelley.exe.dev/llm"
+)
+
+// Plan is the agent's plan for a task, proposed with the propose_plan tool
+// and approved by the user before the agent changes anything.
+type Plan struct {
+	Summary string     `json:"summary"`
+	Steps   []PlanStep `json:"steps"`
+	Risks   []string   `json:"risks,omitempty"`
+}
+
+// PlanStep is a step of a plan and its progress.
+type PlanStep struct {
+	Description string   `json:"description"`
+	Files       []string `json:"files,omitempty"`
+	// Status is pending, in_progress, done or skipped.
+	Status string `json:"status"`
+	Note   string `json:"note,omitempty"`
+}
+
+// ProposePlanTool records the plan the agent proposes.
+type ProposePlanTool struct {
+	// Propose records the plan and returns a note for the agent.
+	Propose func(ctx context.Context, plan Plan) (string, error)
+}
+
+// UpdatePlanStepTool records the agent's progress through an approved plan.
+type UpdatePlanStepTool struct {
+	// Update records the status of step, numbered from 1, and returns a
+	// note for the agent.
+	Update func(ctx context.Context, step int, status, note string) (string, error)
+}
+
+const (
+	proposePlanName        = "propose_plan"
+	proposePlanDescription = `Propose a plan for the task for the user to approve before you change anything.
+
+Investigate first, then propose concrete, ordered steps, each with the files it
+touches, and the risks: what could break, what you are unsure of. Tools that
+change files or run commands are unavailable until the user approves the plan.
+After proposing it, stop and wait for approval or feedback; proposing again
+replaces the plan and needs approval again.
+`
+	proposePlanInputSchema = `{
+  "type": "object",
+  "required": ["summary", "steps"],
+  "properties": {
+    "summary": {
+      "type": "string",
+      "description": "The approach, in a few sentences"
+    },
+    "steps": {
+      "type": "array",
+      "items": {
+        "type": "object",
+        "required": ["description"],
+        "properties": {
+          "description": {"type": "string", "description": "What the step does"},
+          "files": {"type": "array", "items": {"type": "string"}, "description": "Files the step creates or changes"}
+        }
+      }
+    },
+    "risks": {
+      "type": "array",
+      "items": {"type": "string"}
+    }
+  }
+}`
+
+	updatePlanStepName        = "update_plan_step"
+	updatePlanStepDescription = `Record progress on a step of the approved plan: mark it in_progress when you
+start it and done when it is finished, or skipped, with a note saying why.
+Steps are numbered from 1.
+`
+	updatePlanStepInputSchema = `{
+  "type": "object",
+  "required": ["step", "status"],
+  "properties": {
+    "step": {"type": "integer"},
+    "status": {"type": "string", "enum": ["in_progress", "done", "skipped"]},
+    "note": {"type": "string", "description": "What happened, e.g. a deviation from the plan"}
+  }
+}`
+)
+
+// Tool returns an llm.Tool for proposing a plan.
+func (p *ProposePlanTool) Tool() *llm.Tool {
+	return &llm.Tool{
+		Name:        proposePlanName,
+		Description: proposePlanDescription,
+		InputSchema: llm.MustSchema(proposePlanInputSchema),
+		Run:         p.Run,
+	}
+}
+
+// Run executes the propose_plan tool.
+func (p *ProposePlanTool) Run(ctx context.Context, m json.RawMessage) llm.ToolOut {
+	var plan Plan
+	if err := json.Unmarshal(m, &plan); err != nil {
+		return llm.ErrorfToolOut("failed to parse propose_plan input: %w", err)
+	}
+	plan.Summary = strings.TrimSpace(plan.Summary)
+	if plan.Summary == "" || len(plan.Steps) == 0 {
+		return llm.ErrorfToolOut("a plan needs a summary and at least one step")
+	}
+	for i := range plan.Steps {
+		if strings.TrimSpace(plan.Steps[i].Description) == "" {
+			return llm.ErrorfToolOut("step %d needs a description", i+1)
+		}
+		plan.Steps[i].Status = "pending"
+		plan.Steps[i].Note = ""
+	}
+	note, err := p.Propose(ctx, plan)
+	if err != nil {
+		return llm.ErrorfToolOut("failed to propose plan: %w", err)
+	}
+	return llm.ToolOut{LLMContent: llm.TextContent(note)}
+}
+
+// Tool returns an llm.Tool for recording progress on a plan.
+func (u *UpdatePlanStepTool) Tool() *llm.Tool {
+	return &llm.Tool{
+		Name:        updatePlanStepName,
+		Description: updatePlanStepDescription,
+		InputSchema: llm.MustSchema(updatePlanStepInputSchema),
+		Run:         u.Run,
+	}
+}
+
+// Run executes the update_plan_step tool.
+func (u *UpdatePlanStepTool) Run(ctx context.Context, m json.RawMessage) llm.ToolOut {
+	var input struct {
+		Step   int    `json:"step"`
+		Status string `json:"status"`
+		Note   string `json:"note"`
+	}
+	if err := json.Unmarshal(m, &input); err != nil {
+		return llm.ErrorfToolOut("failed to parse update_plan_step input: %w", err)
+	}
+	if !slices.Contains([]string{"in_progress", "done", "skipped"}, input.Status) {
+		return llm.ErrorfToolOut("status must be in_progress, done or skipped, not %q", input.Status)
+	}
+	note, err := u.Update(ctx, input.Step, input.Status, strings.TrimSpace(input.Note))
+	if err != nil {
+		return llm.ErrorfToolOut("failed to update plan: %w", err)
+	}
+	return llm.ToolOut{LLMContent: llm.TextContent(note)}
+}
@@ -0,0 +1,98 @@
+package claudetool
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// The ports a process group listens on are found in /proc: the sockets its
+// processes have open, matched against the listening TCP sockets. On other
+// systems none are found.
+
+// tcpListen is the state of a listening socket in /proc/net/tcp.
+const tcpListen = "0A"
+
+// listeningPorts returns the TCP ports the processes in process group pgid
+// are listening on, in increasing order.
+func listeningPorts(pgid int) []int {
+	sockets := groupSockets(pgid)
+	if len(sockets) == 0 {
+		return nil
+	}
+	var ports []int
+	for _, table := range []string{"/proc/net/tcp", "/proc/net/tcp6"} {
+		f, err := os.Open(table)
+		if err != nil {
+			continue
+		}
+		scanner := bufio.NewScanner(f)
+		scanner.Scan() // header
+		for scanner.Scan() {
+			// sl local_address rem_address st tx_queue:rx_queue tr:tm->when retrnsmt uid timeout inode
+			fields := strings.Fields(scanner.Text())
+			if len(fields) < 10 || fields[3] != tcpListen || !sockets[fields[9]] {
+				continue
+			}
+			_, hexPort, ok := strings.Cut(fields[1], ":")
+			if !ok {
+				continue
+			}
+			if port, err := strconv.ParseInt(hexPort, 16, 32); err == nil && !slices.Contains(ports, int(port)) {
+				ports = append(ports, int(port))
+			}
+		}
+		f.Close()
+	}
+	slices.Sort(ports)
+	return ports
+}
+
+// groupSockets returns the inodes of the sockets open in process group pgid.
+func groupSockets(pgid int) map[string]bool {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil
+	}
+	sockets := make(map[string]bool)
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil || processGroup(pid) != pgid {
+			continue
+		}
+		fdDir := filepath.Join("/proc", entry.Name(), "fd")
+		fds, err := os.ReadDir(fdDir)
+		if err != nil {
+			continue
+		}
+		for _, fd := range fds {
+			link, err := os.Readlink(filepath.Join(fdDir, fd.Name()))
+			if inode, ok := strings.CutPrefix(link, "socket:["); err == nil && ok {
+				sockets[strings.TrimSuffix(inode, "]")] = true
+			}
+		}
+	}
+	return sockets
+}
+
+// processGroup returns the process group of pid, or 0 if it isn't known.
+func processGroup(pid int) int {
+	data, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "stat"))
+	if err != nil {
+		return 0
+	}
+	// pid (comm) state ppid pgrp ...; comm may contain spaces and parentheses
+	i := strings.LastIndexByte(string(data), ')')
+	if i < 0 {
+		return 0
+	}
+	fields := strings.Fields(string(data[i+1:]))
+	if len(fields) < 3 {
+		return 0
+	}
+	pgrp, _ := strconv.Atoi(fields[2])
+	return pgrp
+}
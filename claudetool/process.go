@@ -0,0 +1,425 @@
+package claudetool
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"shelley.exe.dev/llm"
+)
+
+// ProcessManager runs the long-running processes a conversation starts with
+// the process tool, such as dev servers and watchers. They are detached from
+// the turn that started them and run until they exit or are stopped. Their
+// combined output goes to a log file that can be tailed. Like foreground
+// commands, they are recorded in the BashTool's ProcessRegistry, so they
+// don't outlive a server that crashes.
+type ProcessManager struct {
+	mu     sync.Mutex
+	procs  []*managedProcess
+	nextID int
+}
+
+// ManagedProcess describes a process started with the process tool.
+type ManagedProcess struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Command   string    `json:"command"`
+	Dir       string    `json:"dir"`
+	PID       int       `json:"pid"`
+	LogFile   string    `json:"log_file"`
+	StartedAt time.Time `json:"started_at"`
+	Running   bool      `json:"running"`
+	// Ports are the TCP ports the running process listens on.
+	Ports []int `json:"ports,omitempty"`
+	// ExitedAt and ExitCode are set once the process has exited; ExitCode
+	// is -1 if it was killed by a signal.
+	ExitedAt *time.Time `json:"exited_at,omitempty"`
+	ExitCode *int       `json:"exit_code,omitempty"`
+	// Stopped reports whether it was stopped with the tool.
+	Stopped bool `json:"stopped,omitempty"`
+}
+
+// managedProcess is a running or exited process and its state.
+type managedProcess struct {
+	ManagedProcess
+	cancel context.CancelFunc
+	done   chan struct{}
+	output *processOutput
+}
+
+const (
+	processName        = "process"
+	processDescription = `Manage long-running processes, such as dev servers, watchers and
+databases, that keep running after your turn ends.
+
+action "start" runs command with bash in the working directory, detached
+from the turn, and returns the process's ID. Its output goes to a log file.
+action "list" lists the processes started in this conversation.
+action "logs" returns the last lines of a process's output.
+action "stop" stops a process and everything it started: SIGTERM, then
+SIGKILL if it hasn't exited within a few seconds.
+
+Prefer this to backgrounding with bash for anything you need to check on or
+stop later.
+`
+	processInputSchema = `{
+  "type": "object",
+  "required": ["action"],
+  "properties": {
+    "action": {
+      "type": "string",
+      "enum": ["start", "list", "logs", "stop"]
+    },
+    "command": {
+      "type": "string",
+      "description": "The shell command to start (start)"
+    },
+    "name": {
+      "type": "string",
+      "description": "A short name for the process, such as \"dev server\" (start)"
+    },
+    "id": {
+      "type": "string",
+      "description": "The process's ID (logs, stop)"
+    },
+    "lines": {
+      "type": "integer",
+      "description": "How many of the last lines of output to return (logs; default 50)"
+    }
+  }
+}`
+
+	// processStartupWait is how long start waits to catch a process that
+	// fails straight away.
+	processStartupWait = time.Second
+	// processLogLines is how many lines logs returns by default.
+	processLogLines = 50
+	// processLogBytes caps what logs returns.
+	processLogBytes = 32 * 1024
+)
+
+type processInput struct {
+	Action  string `json:"action"`
+	Command string `json:"command"`
+	Name    string `json:"name"`
+	ID      string `json:"id"`
+	Lines   int    `json:"lines"`
+}
+
+// ProcessTool is the process tool, which starts processes with Bash and
+// keeps track of them in Processes.
+type ProcessTool struct {
+	// Bash runs the processes, in its working directory and with its
+	// environment and sandbox.
+	Bash      *BashTool
+	Processes *ProcessManager
+	// PreviewPath, if set, is where the server proxies requests to the
+	// port of the newest running process that listens on one.
+	PreviewPath string
+}
+
+// Tool returns an llm.Tool for managing long-running processes.
+func (p *ProcessTool) Tool() *llm.Tool {
+	return &llm.Tool{
+		Name:        processName,
+		Description: processDescription,
+		InputSchema: llm.MustSchema(processInputSchema),
+		Run:         p.Run,
+	}
+}
+
+// Run executes the process tool.
+func (p *ProcessTool) Run(ctx context.Context, m json.RawMessage) llm.ToolOut {
+	var req processInput
+	if err := json.Unmarshal(m, &req); err != nil {
+		return llm.ErrorfToolOut("failed to parse process input: %w", err)
+	}
+	switch req.Action {
+	case "start":
+		return p.start(ctx, req)
+	case "list":
+		return llm.ToolOut{LLMContent: llm.TextContent(formatProcesses(p.Processes.List()))}
+	case "logs":
+		logs, err := p.Processes.Logs(req.ID, cmp.Or(req.Lines, processLogLines))
+		if err != nil {
+			return llm.ErrorToolOut(err)
+		}
+		return llm.ToolOut{LLMContent: llm.TextContent(logs)}
+	case "stop":
+		proc, err := p.Processes.Stop(req.ID)
+		if err != nil {
+			return llm.ErrorToolOut(err)
+		}
+		return llm.ToolOut{LLMContent: llm.TextContent("stopped " + formatProcess(proc))}
+	}
+	return llm.ErrorfToolOut("unknown action %q", req.Action)
+}
+
+// start starts req's command and reports on it once it has had a moment to
+// fail.
+func (p *ProcessTool) start(ctx context.Context, req processInput) llm.ToolOut {
+	if strings.TrimSpace(req.Command) == "" {
+		return llm.ErrorfToolOut("command is required to start a process")
+	}
+	if p.Bash.Remote != nil {
+		// The log file would be this machine's, out of the agent's reach
+		return llm.ErrorfToolOut("processes can't be managed in a remote workspace; run the command with nohup, redirecting its output to a file")
+	}
+	if p.Bash.CheckPermission != nil {
+		if err := p.Bash.CheckPermission(req.Command); err != nil {
+			return llm.ErrorToolOut(err)
+		}
+	}
+	proc, err := p.Processes.start(p.Bash, req.Name, req.Command)
+	if err != nil {
+		return llm.ErrorToolOut(err)
+	}
+
+	select {
+	case <-proc.done:
+	case <-time.After(processStartupWait):
+	case <-ctx.Done():
+	}
+	info := p.Processes.info(proc)
+	logs, _ := p.Processes.Logs(info.ID, processLogLines)
+	if !info.Running {
+		return llm.ErrorfToolOut("%s\n%s", formatProcess(info), logs)
+	}
+	text := fmt.Sprintf("started %s\nOutput so far:\n%s", formatProcess(info), logs)
+	if p.PreviewPath != "" {
+		text += fmt.Sprintf("\n\nOnce it listens on a port, the user can preview it at %s (serve it with relative links, or with %s as its base path)", p.PreviewPath, p.PreviewPath)
+	}
+	return llm.ToolOut{LLMContent: llm.TextContent(text)}
+}
+
+// start runs command with bash, detached from any turn, and tracks it.
+func (pm *ProcessManager) start(bash *BashTool, name, command string) (*managedProcess, error) {
+	logDir, err := os.MkdirTemp("", "shelley-process-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create log directory: %w", err)
+	}
+	logFile := filepath.Join(logDir, "output")
+	out, err := os.Create(logFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create log file: %w", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cmd := bash.makeBashCommand(ctx, command, out)
+	bash.wrap(cmd)
+	if err := cmd.Start(); err != nil {
+		cancel()
+		out.Close()
+		os.RemoveAll(logDir)
+		return nil, fmt.Errorf("failed to start process: %w", err)
+	}
+	if err := bash.Processes.add(cmd.Process.Pid, command); err != nil {
+		// Not fatal: it just won't be reaped if the server crashes
+		fmt.Fprintf(out, "[failed to register process: %v]\n", err)
+	}
+
+	pm.mu.Lock()
+	pm.nextID++
+	proc := &managedProcess{
+		ManagedProcess: ManagedProcess{
+			ID:        fmt.Sprintf("p%d", pm.nextID),
+			Name:      strings.TrimSpace(name),
+			Command:   command,
+			Dir:       cmd.Dir,
+			PID:       cmd.Process.Pid,
+			LogFile:   logFile,
+			StartedAt: time.Now(),
+			Running:   true,
+		},
+		cancel: cancel,
+		done:   make(chan struct{}),
+		output: newProcessOutput(),
+	}
+	pm.procs = append(pm.procs, proc)
+	pm.mu.Unlock()
+	stream, err := tailOutputStream(logFile, proc.output.write)
+	if err != nil {
+		fmt.Fprintf(out, "[failed to follow output: %v]\n", err)
+	}
+
+	go func() {
+		cmdWait(cmd)
+		bash.Processes.remove(cmd.Process.Pid)
+		out.Close()
+		if stream != nil {
+			stream.Close()
+		}
+		cancel()
+		code := -1
+		if cmd.ProcessState != nil {
+			code = cmd.ProcessState.ExitCode()
+		}
+		now := time.Now()
+		pm.mu.Lock()
+		proc.Running = false
+		proc.ExitedAt = &now
+		proc.ExitCode = &code
+		pm.mu.Unlock()
+		proc.output.close()
+		close(proc.done)
+	}()
+	return proc, nil
+}
+
+// List returns the processes, running or not, in the order they were
+// started.
+func (pm *ProcessManager) List() []ManagedProcess {
+	pm.mu.Lock()
+	list := make([]ManagedProcess, 0, len(pm.procs))
+	for _, proc := range pm.procs {
+		list = append(list, proc.ManagedProcess)
+	}
+	pm.mu.Unlock()
+	for i := range list {
+		if list[i].Running {
+			list[i].Ports = listeningPorts(list[i].PID)
+		}
+	}
+	return list
+}
+
+// PreviewPort returns the lowest port that the newest running process that
+// listens on any listens on.
+func (pm *ProcessManager) PreviewPort() (int, bool) {
+	list := pm.List()
+	for i := len(list) - 1; i >= 0; i-- {
+		if len(list[i].Ports) > 0 {
+			return list[i].Ports[0], true
+		}
+	}
+	return 0, false
+}
+
+// info returns a snapshot of proc.
+func (pm *ProcessManager) info(proc *managedProcess) ManagedProcess {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+	return proc.ManagedProcess
+}
+
+// find returns the process with the given ID.
+func (pm *ProcessManager) find(id string) (*managedProcess, error) {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+	for _, proc := range pm.procs {
+		if proc.ID == id {
+			return proc, nil
+		}
+	}
+	return nil, fmt.Errorf("no process with ID %q; list the processes to see their IDs", id)
+}
+
+// Process returns the process with the given ID.
+func (pm *ProcessManager) Process(id string) (ManagedProcess, error) {
+	proc, err := pm.find(id)
+	if err != nil {
+		return ManagedProcess{}, err
+	}
+	return pm.info(proc), nil
+}
+
+// Follow returns the latest output of the process with the given ID and a
+// channel of its output from then on. The channel is closed when the process
+// exits, or if the follower falls behind. stop stops following.
+func (pm *ProcessManager) Follow(id string) (history string, output <-chan string, stop func(), err error) {
+	proc, err := pm.find(id)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	history, output, stop = proc.output.follow()
+	return history, output, stop, nil
+}
+
+// Logs returns the last lines of the output of the process with the given
+// ID.
+func (pm *ProcessManager) Logs(id string, lines int) (string, error) {
+	proc, err := pm.find(id)
+	if err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(proc.LogFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to read log: %w", err)
+	}
+	if lines <= 0 {
+		lines = processLogLines
+	}
+	all := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+	if len(all) > lines {
+		all = all[len(all)-lines:]
+	}
+	logs := strings.Join(all, "\n")
+	if len(logs) > processLogBytes {
+		logs = tailBytes(logs, processLogBytes)
+	}
+	return logs, nil
+}
+
+// Stop terminates the process with the given ID and everything it started,
+// and waits for it to exit.
+func (pm *ProcessManager) Stop(id string) (ManagedProcess, error) {
+	proc, err := pm.find(id)
+	if err != nil {
+		return ManagedProcess{}, err
+	}
+	pm.mu.Lock()
+	if proc.Running {
+		proc.Stopped = true
+	}
+	pm.mu.Unlock()
+	proc.cancel()
+	select {
+	case <-proc.done:
+	case <-time.After(killGracePeriod + time.Second):
+		return pm.info(proc), fmt.Errorf("process %s did not exit after SIGKILL", id)
+	}
+	return pm.info(proc), nil
+}
+
+// formatProcess describes a process in one line.
+func formatProcess(proc ManagedProcess) string {
+	var b strings.Builder
+	b.WriteString(proc.ID)
+	if proc.Name != "" {
+		fmt.Fprintf(&b, " (%s)", proc.Name)
+	}
+	fmt.Fprintf(&b, ": pid %d, ", proc.PID)
+	switch {
+	case proc.Running:
+		fmt.Fprintf(&b, "running for %s", time.Since(proc.StartedAt).Round(time.Second))
+	case proc.Stopped:
+		b.WriteString("stopped")
+	default:
+		fmt.Fprintf(&b, "exited with status %d", *proc.ExitCode)
+	}
+	if len(proc.Ports) > 0 {
+		fmt.Fprintf(&b, ", listening on %v", proc.Ports)
+	}
+	fmt.Fprintf(&b, ", log %s: %s", proc.LogFile, proc.Command)
+	return b.String()
+}
+
+// formatProcesses lists processes, one per line.
+func formatProcesses(procs []ManagedProcess) string {
+	if len(procs) == 0 {
+		return "no processes have been started"
+	}
+	var b strings.Builder
+	for _, proc := range procs {
+		b.WriteString(formatProcess(proc))
+		b.WriteString("\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,129 @@
+package claudetool
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"shelley.exe.dev/llm"
+)
+
+func TestProcessTool(t *testing.T) {
+	dir := t.TempDir()
+	registry := NewProcessRegistry(t.TempDir())
+	pm := &ProcessManager{}
+	tool := &ProcessTool{Bash: &BashTool{WorkingDir: NewMutableWorkingDir(dir), Processes: registry}, Processes: pm}
+	run := func(input map[string]any) llm.ToolOut {
+		t.Helper()
+		data, _ := json.Marshal(input)
+		return tool.Run(context.Background(), data)
+	}
+	text := func(out llm.ToolOut) string {
+		if out.Error != nil {
+			return out.Error.Error()
+		}
+		return out.LLMContent[0].Text
+	}
+
+	out := run(map[string]any{"action": "start", "name": "server", "command": "echo listening; sleep 30"})
+	if out.Error != nil {
+		t.Fatalf("start failed: %v", out.Error)
+	}
+	if got := text(out); !strings.Contains(got, "p1 (server)") || !strings.Contains(got, "listening") {
+		t.Errorf("expected the process and its output so far, got %q", got)
+	}
+	procs := pm.List()
+	if len(procs) != 1 || !procs[0].Running || procs[0].Dir != dir {
+		t.Fatalf("expected one running process, got %+v", procs)
+	}
+	if entries, _ := os.ReadDir(registry.dir); len(entries) != 1 {
+		t.Errorf("expected the process to be registered, got %v", entries)
+	}
+
+	if got := text(run(map[string]any{"action": "list"})); !strings.Contains(got, "running") || !strings.Contains(got, "sleep 30") {
+		t.Errorf("unexpected list: %q", got)
+	}
+	if got := text(run(map[string]any{"action": "logs", "id": "p1", "lines": 1})); got != "listening" {
+		t.Errorf("unexpected logs: %q", got)
+	}
+
+	start := time.Now()
+	out = run(map[string]any{"action": "stop", "id": "p1"})
+	if out.Error != nil {
+		t.Fatalf("stop failed: %v", out.Error)
+	}
+	if elapsed := time.Since(start); elapsed > killGracePeriod {
+		t.Errorf("expected sleep to exit on SIGTERM, took %s", elapsed)
+	}
+	if procs := pm.List(); procs[0].Running || !procs[0].Stopped || procs[0].ExitedAt == nil {
+		t.Errorf("expected the process to be stopped, got %+v", procs[0])
+	}
+	if entries, _ := os.ReadDir(registry.dir); len(entries) != 0 {
+		t.Errorf("expected the stopped process to be unregistered, got %v", entries)
+	}
+
+	// A process that fails straight away is reported as an error
+	out = run(map[string]any{"action": "start", "command": "echo oops > " + filepath.Join(dir, "f") + "; echo broken; exit 3"})
+	if out.Error == nil || !strings.Contains(out.Error.Error(), "exited with status 3") || !strings.Contains(out.Error.Error(), "broken") {
+		t.Errorf("expected the failed start to be reported, got %+v", out)
+	}
+
+	for _, input := range []map[string]any{
+		{"action": "start"},
+		{"action": "logs", "id": "p9"},
+		{"action": "stop", "id": "p9"},
+		{"action": "restart"},
+	} {
+		if out := run(input); out.Error == nil {
+			t.Errorf("%v: expected an error", input)
+		}
+	}
+}
+
+func TestProcessOutput(t *testing.T) {
+	o := newProcessOutput()
+	o.write("before\n")
+	history, output, stop := o.follow()
+	defer stop()
+	if history != "before\n" {
+		t.Errorf("unexpected history %q", history)
+	}
+	o.write("after\n")
+	if got := <-output; got != "after\n" {
+		t.Errorf("expected new output, got %q", got)
+	}
+
+	// A follower that falls behind is dropped
+	_, slow, stopSlow := o.follow()
+	for range processFollowerBuffer + 1 {
+		o.write("x")
+	}
+	n := 0
+	for range slow {
+		n++
+	}
+	if n != processFollowerBuffer {
+		t.Errorf("expected the slow follower to get %d chunks before being dropped, got %d", processFollowerBuffer, n)
+	}
+	stopSlow()
+
+	o.write(strings.Repeat("y", processOutputHistory))
+	if history, _, stop := o.follow(); len(history) != processOutputHistory || strings.Contains(history, "before") {
+		t.Errorf("expected the history to keep only the latest %d bytes, got %d", processOutputHistory, len(history))
+	} else {
+		stop()
+	}
+
+	o.close()
+	for range output {
+		// The output before the exit is still delivered
+	}
+	_, late, _ := o.follow()
+	if _, ok := <-late; ok {
+		t.Error("expected following an exited process to end at once")
+	}
+}
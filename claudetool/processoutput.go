@@ -0,0 +1,77 @@
+package claudetool
+
+import "sync"
+
+// processOutputHistory is how much of a managed process's latest output is
+// kept for new followers.
+const processOutputHistory = 64 * 1024
+
+// processFollowerBuffer is how many chunks of output a follower can fall
+// behind by before it is dropped.
+const processFollowerBuffer = 256
+
+// processOutput keeps the latest output of a managed process, read from its
+// log file as it is written, and passes new output to its followers.
+type processOutput struct {
+	mu        sync.Mutex
+	history   []byte
+	followers map[chan string]bool
+	closed    bool
+}
+
+func newProcessOutput() *processOutput {
+	return &processOutput{followers: make(map[chan string]bool)}
+}
+
+// write adds a chunk of output. Followers that have fallen too far behind
+// are dropped, closing their channels; they can follow again to catch up
+// from the history.
+func (o *processOutput) write(chunk string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.history = append(o.history, chunk...)
+	if len(o.history) > processOutputHistory {
+		o.history = append(o.history[:0], tailBytes(string(o.history), processOutputHistory)...)
+	}
+	for ch := range o.followers {
+		select {
+		case ch <- chunk:
+		default:
+			delete(o.followers, ch)
+			close(ch)
+		}
+	}
+}
+
+// close ends the output once the process has exited, closing the followers'
+// channels.
+func (o *processOutput) close() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.closed = true
+	for ch := range o.followers {
+		close(ch)
+	}
+	clear(o.followers)
+}
+
+// follow returns the output so far, and a channel of the output that
+// follows, which is closed when the process exits. stop stops following.
+func (o *processOutput) follow() (history string, output <-chan string, stop func()) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	ch := make(chan string, processFollowerBuffer)
+	if o.closed {
+		close(ch)
+		return string(o.history), ch, func() {}
+	}
+	o.followers[ch] = true
+	return string(o.history), ch, func() {
+		o.mu.Lock()
+		defer o.mu.Unlock()
+		if o.followers[ch] {
+			delete(o.followers, ch)
+			close(ch)
+		}
+	}
+}
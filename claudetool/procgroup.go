@@ -0,0 +1,153 @@
+package claudetool
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Commands spawned by tools run in their own process group, so that
+// cancelling one stops everything it started, not just its shell: the group
+// is sent SIGTERM, then SIGKILL once killGracePeriod has passed. While a
+// foreground command or a process started with the process tool runs, its
+// group is recorded in a ProcessRegistry, so the groups a crashed server left
+// behind can be reaped by the next one. Children a command leaves running
+// after it exits, and commands run in the background, are meant to outlive
+// it and are left alone.
+
+// killGracePeriod is how long a cancelled command's process group has to
+// exit after SIGTERM before it is killed.
+const killGracePeriod = 5 * time.Second
+
+// bootIDFile identifies the current boot, so that process groups recorded
+// before a reboot aren't mistaken for new ones with the same ID.
+const bootIDFile = "/proc/sys/kernel/random/boot_id"
+
+// inProcessGroup makes cmd run in its own process group, which is
+// terminated when cmd's context is done.
+func inProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		if cmd.Process == nil {
+			// Process hasn't started yet.
+			// Not sure whether this is possible in practice,
+			// but it is possible in theory, and it doesn't hurt to handle it gracefully.
+			return nil
+		}
+		return terminateProcessGroup(cmd.Process.Pid)
+	}
+}
+
+// terminateProcessGroup sends SIGTERM to the process group pgid, and SIGKILL
+// once the grace period has passed, in case anything in it ignored SIGTERM.
+func terminateProcessGroup(pgid int) error {
+	if err := syscall.Kill(-pgid, syscall.SIGTERM); err != nil {
+		return err
+	}
+	time.AfterFunc(killGracePeriod, func() {
+		syscall.Kill(-pgid, syscall.SIGKILL)
+	})
+	return nil
+}
+
+// ProcessRegistry records the process groups of running commands in a
+// directory, one file per group.
+type ProcessRegistry struct {
+	dir string
+}
+
+// processRecord is the record of a running process group.
+type processRecord struct {
+	Command string `json:"command"`
+	// Owner is the PID of the server that started the command.
+	Owner   int       `json:"owner"`
+	BootID  string    `json:"boot_id,omitempty"`
+	Started time.Time `json:"started"`
+}
+
+// NewProcessRegistry returns a registry kept in dir, which is created when
+// the first group is recorded.
+func NewProcessRegistry(dir string) *ProcessRegistry {
+	return &ProcessRegistry{dir: dir}
+}
+
+// add records the running process group pgid. A nil registry records
+// nothing.
+func (r *ProcessRegistry) add(pgid int, command string) error {
+	if r == nil {
+		return nil
+	}
+	if err := os.MkdirAll(r.dir, 0o700); err != nil {
+		return fmt.Errorf("failed to create process registry: %w", err)
+	}
+	data, err := json.Marshal(processRecord{Command: command, Owner: os.Getpid(), BootID: bootID(), Started: time.Now()})
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(r.dir, strconv.Itoa(pgid)), data, 0o600)
+}
+
+// remove forgets the process group pgid.
+func (r *ProcessRegistry) remove(pgid int) {
+	if r != nil {
+		os.Remove(filepath.Join(r.dir, strconv.Itoa(pgid)))
+	}
+}
+
+// Reap kills the process groups recorded by servers that are no longer
+// running, and returns their commands. Groups recorded before the last
+// reboot are gone, and only their records are removed.
+func (r *ProcessRegistry) Reap() ([]string, error) {
+	entries, err := os.ReadDir(r.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	boot := bootID()
+	var reaped []string
+	for _, entry := range entries {
+		pgid, err := strconv.Atoi(entry.Name())
+		if err != nil || pgid <= 1 {
+			continue
+		}
+		path := filepath.Join(r.dir, entry.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		var record processRecord
+		if json.Unmarshal(data, &record) != nil {
+			os.Remove(path)
+			continue
+		}
+		if record.Owner == os.Getpid() || (record.Owner > 0 && processAlive(record.Owner)) {
+			// Its server is still running, and will see to it
+			continue
+		}
+		if record.BootID == boot && processAlive(-pgid) {
+			if err := syscall.Kill(-pgid, syscall.SIGKILL); err == nil {
+				reaped = append(reaped, record.Command)
+			}
+		}
+		os.Remove(path)
+	}
+	return reaped, nil
+}
+
+// bootID returns the ID of the current boot, or "" if it isn't known.
+func bootID() string {
+	data, err := os.ReadFile(bootIDFile)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
@@ -0,0 +1,107 @@
+package claudetool
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestCancelTerminatesProcessGroup(t *testing.T) {
+	dir := t.TempDir()
+	// The shell and the child it leaves running both get SIGTERM
+	script := `trap 'echo term > parent; exit 0' TERM
+sh -c 'trap "echo term > child; exit 0" TERM; while :; do sleep 0.05; done' &
+echo started > ready
+wait`
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	b := &BashTool{WorkingDir: NewMutableWorkingDir(dir)}
+	var out bytes.Buffer
+	cmd := b.makeBashCommand(ctx, script, &out)
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		if _, err := os.Stat(filepath.Join(dir, "ready")); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("command never started")
+		}
+	}
+	cancel()
+	cmd.Wait()
+
+	for _, name := range []string{"parent", "child"} {
+		var data []byte
+		for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+			if data, _ = os.ReadFile(filepath.Join(dir, name)); len(data) > 0 {
+				break
+			}
+		}
+		if strings.TrimSpace(string(data)) != "term" {
+			t.Errorf("%s didn't get SIGTERM", name)
+		}
+	}
+}
+
+func TestProcessRegistryReap(t *testing.T) {
+	registry := NewProcessRegistry(t.TempDir())
+	if reaped, err := registry.Reap(); err != nil || len(reaped) != 0 {
+		t.Fatalf("empty registry: reaped %v, %v", reaped, err)
+	}
+
+	// A process group left behind by a server that has exited
+	orphan := exec.Command("sleep", "30")
+	orphan.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if err := orphan.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer orphan.Process.Kill()
+	exited := exec.Command("true")
+	if err := exited.Run(); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := json.Marshal(processRecord{Command: "sleep 30", Owner: exited.Process.Pid, BootID: bootID(), Started: time.Now()})
+	if err := os.WriteFile(filepath.Join(registry.dir, strconv.Itoa(orphan.Process.Pid)), data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// A process group of this server's, which it is still running
+	mine := exec.Command("sleep", "30")
+	mine.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if err := mine.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer mine.Process.Kill()
+	if err := registry.add(mine.Process.Pid, "sleep 30 # mine"); err != nil {
+		t.Fatal(err)
+	}
+
+	reaped, err := registry.Reap()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(reaped, []string{"sleep 30"}) {
+		t.Errorf("expected the orphan to be reaped, got %v", reaped)
+	}
+	if err := orphan.Wait(); err == nil {
+		t.Error("expected the orphan to be killed")
+	}
+	if !processAlive(mine.Process.Pid) {
+		t.Error("reaped a process group of a running server")
+	}
+	entries, _ := os.ReadDir(registry.dir)
+	if len(entries) != 1 || entries[0].Name() != strconv.Itoa(mine.Process.Pid) {
+		t.Errorf("expected only the running group's record to be left, got %v", entries)
+	}
+}
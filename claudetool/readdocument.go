@@ -0,0 +1,106 @@
+package claudetool
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"shelley.exe.dev/filepolicy"
+	"shelley.exe.dev/llm"
+	"shelley.exe.dev/remote"
+)
+
+// ReadDocumentTool reads a PDF and passes it to the model as a document,
+// which models that support documents read natively, images and all.
+type ReadDocumentTool struct {
+	// WorkingDir is the shared mutable working directory.
+	WorkingDir *MutableWorkingDir
+	// Policy, if set, decides which files may be read. It isn't applied to
+	// remote files.
+	Policy *filepolicy.Policy
+	// Remote, if set, is the shell of the host or container whose files the tool reads.
+	Remote remote.Shell
+}
+
+const (
+	readDocumentName        = "read_document"
+	readDocumentDescription = `Read a PDF document, such as a spec, paper or invoice, including its layout,
+tables and figures.
+
+Prefer this to extracting the text with a command. Models that can't read
+documents get a note instead, and should fall back to pdftotext or similar.
+`
+	readDocumentInputSchema = `{
+  "type": "object",
+  "required": ["path"],
+  "properties": {
+    "path": {
+      "type": "string",
+      "description": "The PDF to read (absolute or relative to the working directory)"
+    }
+  }
+}`
+
+	// maxDocumentBytes is the largest document the tool reads, the limit
+	// of the Anthropic API.
+	maxDocumentBytes = 32 << 20
+)
+
+type readDocumentInput struct {
+	Path string `json:"path"`
+}
+
+// Tool returns an llm.Tool for reading documents.
+func (r *ReadDocumentTool) Tool() *llm.Tool {
+	return &llm.Tool{
+		Name:        readDocumentName,
+		Description: readDocumentDescription,
+		InputSchema: llm.MustSchema(readDocumentInputSchema),
+		Run:         r.Run,
+	}
+}
+
+// Run executes the read_document tool.
+func (r *ReadDocumentTool) Run(ctx context.Context, m json.RawMessage) llm.ToolOut {
+	var req readDocumentInput
+	if err := json.Unmarshal(m, &req); err != nil {
+		return llm.ErrorfToolOut("failed to parse read_document input: %w", err)
+	}
+	if req.Path == "" {
+		return llm.ErrorfToolOut("path is required")
+	}
+	path := req.Path
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(r.WorkingDir.Get(), path)
+	}
+
+	var data []byte
+	var err error
+	if r.Remote != nil {
+		data, err = r.Remote.ReadFile(ctx, path)
+	} else if err = r.Policy.CheckRead(path); err == nil {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return llm.ErrorfToolOut("failed to read document: %w", err)
+	}
+	if mediaType := http.DetectContentType(data); mediaType != llm.DocumentMediaType {
+		return llm.ErrorfToolOut("%s is not a PDF (detected %s)", path, mediaType)
+	}
+	if len(data) > maxDocumentBytes {
+		return llm.ErrorfToolOut("%s is %s, larger than the %s limit; extract the pages you need first", path, humanizeBytes(len(data)), humanizeBytes(maxDocumentBytes))
+	}
+
+	return llm.ToolOut{LLMContent: []llm.Content{
+		llm.StringContent(fmt.Sprintf("Document from %s (%s)", path, humanizeBytes(len(data)))),
+		{
+			Type:      llm.ContentTypeText,
+			MediaType: llm.DocumentMediaType,
+			Data:      base64.StdEncoding.EncodeToString(data),
+		},
+	}}
+}
@@ -0,0 +1,61 @@
+package claudetool
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"shelley.exe.dev/filepolicy"
+	"shelley.exe.dev/llm"
+)
+
+func TestReadDocumentTool(t *testing.T) {
+	dir := t.TempDir()
+	pdf := []byte("%PDF-1.4\n1 0 obj\n<< /Type /Catalog >>\nendobj\n%%EOF\n")
+	if err := os.WriteFile(filepath.Join(dir, "spec.pdf"), pdf, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("not a pdf\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tool := &ReadDocumentTool{WorkingDir: NewMutableWorkingDir(dir)}
+
+	run := func(path string) llm.ToolOut {
+		input, _ := json.Marshal(readDocumentInput{Path: path})
+		return tool.Run(context.Background(), input)
+	}
+
+	out := run("spec.pdf")
+	if out.Error != nil {
+		t.Fatalf("unexpected error: %v", out.Error)
+	}
+	if len(out.LLMContent) != 2 || !strings.Contains(out.LLMContent[0].Text, filepath.Join(dir, "spec.pdf")) {
+		t.Fatalf("expected a description and the document, got %+v", out.LLMContent)
+	}
+	doc := out.LLMContent[1]
+	if !doc.IsDocument() || doc.Data != base64.StdEncoding.EncodeToString(pdf) {
+		t.Errorf("expected the PDF as document content, got %+v", doc)
+	}
+
+	if out := run("notes.txt"); out.Error == nil || !strings.Contains(out.Error.Error(), "not a PDF") {
+		t.Errorf("expected a text file to be refused, got %v", out.Error)
+	}
+	if out := run("missing.pdf"); out.Error == nil {
+		t.Error("expected an error for a missing file")
+	}
+
+	// The file policy applies to reads
+	tool.Policy = &filepolicy.Policy{DeniedGlobs: []string{"*.pdf"}}
+	if out := run("spec.pdf"); !errors.Is(out.Error, filepolicy.ErrDenied) {
+		t.Errorf("expected a denied file to be refused, got %v", out.Error)
+	}
+	tool.Policy = &filepolicy.Policy{AllowedRoots: []string{t.TempDir()}}
+	if out := run("spec.pdf"); !errors.Is(out.Error, filepolicy.ErrDenied) {
+		t.Errorf("expected a file outside the allowed roots to be refused, got %v", out.Error)
+	}
+}
@@ -0,0 +1,91 @@
+package claudetool
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"shelley.exe.dev/llm"
+)
+
+// RecallResult is a past message or memory matching a recall query.
+type RecallResult struct {
+	Source         string // "message" or "memory"
+	ConversationID string // empty for memories saved outside a conversation
+	Content        string
+	Score          float64 // cosine similarity, higher is closer
+}
+
+// RecallTool searches past conversations and memories by meaning.
+type RecallTool struct {
+	// Search returns the results most similar to query, best first.
+	Search func(ctx context.Context, query string) ([]RecallResult, error)
+}
+
+const (
+	recallName        = "recall"
+	recallDescription = `Search past conversations and saved memories by meaning.
+
+Use this to find relevant earlier work before redoing it: how a problem was solved,
+why a decision was made, what a command or file was used for.
+Phrase the query as a description of what you are looking for, not as keywords.
+Results from the current conversation are excluded.
+`
+	recallInputSchema = `{
+  "type": "object",
+  "required": ["query"],
+  "properties": {
+    "query": {
+      "type": "string",
+      "description": "What to look for"
+    }
+  }
+}`
+)
+
+type recallInput struct {
+	Query string `json:"query"`
+}
+
+// Tool returns an llm.Tool for semantic recall.
+func (r *RecallTool) Tool() *llm.Tool {
+	return &llm.Tool{
+		Name:        recallName,
+		Description: recallDescription,
+		InputSchema: llm.MustSchema(recallInputSchema),
+		Run:         r.Run,
+	}
+}
+
+// Run executes the recall tool.
+func (r *RecallTool) Run(ctx context.Context, m json.RawMessage) llm.ToolOut {
+	var req recallInput
+	if err := json.Unmarshal(m, &req); err != nil {
+		return llm.ErrorfToolOut("failed to parse recall input: %w", err)
+	}
+	query := strings.TrimSpace(req.Query)
+	if query == "" {
+		return llm.ErrorfToolOut("query is required")
+	}
+	results, err := r.Search(ctx, query)
+	if err != nil {
+		return llm.ErrorfToolOut("recall failed: %w", err)
+	}
+	if len(results) == 0 {
+		return llm.ToolOut{LLMContent: llm.TextContent("No matches found.")}
+	}
+
+	var sb strings.Builder
+	for i, res := range results {
+		if i > 0 {
+			sb.WriteString("\n")
+		}
+		fmt.Fprintf(&sb, "<result source=%q", res.Source)
+		if res.ConversationID != "" {
+			fmt.Fprintf(&sb, " conversation_id=%q", res.ConversationID)
+		}
+		fmt.Fprintf(&sb, " score=\"%.2f\">\n%s\n</result>\n", res.Score, res.Content)
+	}
+	return llm.ToolOut{LLMContent: llm.TextContent(sb.String())}
+}
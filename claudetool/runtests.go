@@ -0,0 +1,521 @@
+package claudetool
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"shelley.exe.dev/llm"
+	"shelley.exe.dev/remote"
+)
+
+// RunTestsTool runs a project's tests and returns a summary of the results
+// instead of their raw output.
+type RunTestsTool struct {
+	// Bash runs the test command, in its working directory and with its
+	// environment, sandbox or remote shell.
+	Bash *BashTool
+}
+
+const (
+	runTestsName        = "run_tests"
+	runTestsDescription = `Run tests and get a structured summary: counts, the failed tests with their
+messages, build or collection errors, and the slowest tests.
+
+Supports go test, pytest and jest; framework is detected from the working
+directory (go.mod, package.json, pytest config) when omitted. Prefer this to
+running the tests with bash: the summary is much shorter than the raw output.
+The result is an error when any test fails.
+`
+	runTestsInputSchema = `{
+  "type": "object",
+  "properties": {
+    "framework": {
+      "type": "string",
+      "enum": ["go", "pytest", "jest"],
+      "description": "The test runner to use; detected when omitted"
+    },
+    "args": {
+      "type": "array",
+      "items": {"type": "string"},
+      "description": "Packages, paths and flags passed to the runner, such as [\"./server/...\", \"-run\", \"TestLogin\"]; go defaults to ./..."
+    },
+    "slowest": {
+      "type": "integer",
+      "description": "How many of the slowest tests to list (default 5, 0 for none)"
+    }
+  }
+}`
+
+	// testFailureLines is how many lines of output are kept for each failure.
+	testFailureLines = 30
+	// testErrorLines is how many lines of output are kept for build and
+	// collection errors.
+	testErrorLines = 60
+	// testReportMarker separates the runner's output from its report file.
+	testReportMarker = "--- run_tests report ---"
+)
+
+type runTestsInput struct {
+	Framework string   `json:"framework"`
+	Args      []string `json:"args"`
+	Slowest   *int     `json:"slowest"`
+}
+
+// testCase is the outcome of one test.
+type testCase struct {
+	Name    string  `json:"name"`
+	Package string  `json:"package,omitempty"`
+	Status  string  `json:"status"` // pass, fail or skip
+	Seconds float64 `json:"seconds"`
+	Output  string  `json:"output,omitempty"`
+}
+
+// testSummary is the parsed result of a test run. It is also the tool's
+// display data.
+type testSummary struct {
+	Type      string     `json:"type"`
+	Framework string     `json:"framework"`
+	Command   string     `json:"command"`
+	Passed    int        `json:"passed"`
+	Failed    int        `json:"failed"`
+	Skipped   int        `json:"skipped"`
+	Seconds   float64    `json:"seconds"`
+	Failures  []testCase `json:"failures,omitempty"`
+	Errors    []string   `json:"errors,omitempty"`
+	Slowest   []testCase `json:"slowest,omitempty"`
+	tests     []testCase
+}
+
+// ok reports whether the run passed.
+func (s *testSummary) ok() bool {
+	return s.Failed == 0 && len(s.Errors) == 0
+}
+
+// add records the outcome of a test.
+func (s *testSummary) add(tc testCase) {
+	switch tc.Status {
+	case "pass":
+		s.Passed++
+	case "fail":
+		s.Failed++
+	case "skip":
+		s.Skipped++
+	}
+	s.tests = append(s.tests, tc)
+}
+
+// Tool returns an llm.Tool for running tests.
+func (r *RunTestsTool) Tool() *llm.Tool {
+	return &llm.Tool{
+		Name:        runTestsName,
+		Description: runTestsDescription,
+		InputSchema: llm.MustSchema(runTestsInputSchema),
+		Run:         r.Run,
+	}
+}
+
+// Run executes the run_tests tool.
+func (r *RunTestsTool) Run(ctx context.Context, m json.RawMessage) llm.ToolOut {
+	var req runTestsInput
+	if err := json.Unmarshal(m, &req); err != nil {
+		return llm.ErrorfToolOut("failed to parse run_tests input: %w", err)
+	}
+	slowest := 5
+	if req.Slowest != nil {
+		slowest = max(*req.Slowest, 0)
+	}
+
+	framework := req.Framework
+	if framework == "" {
+		out, _ := r.Bash.RunCommand(ctx, detectTestFrameworkScript)
+		framework = strings.TrimSpace(out)
+		if framework == "" {
+			return llm.ErrorfToolOut("could not detect the test framework in %s; set framework to go, pytest or jest", r.Bash.getWorkingDir())
+		}
+	}
+
+	command, err := testCommand(framework, req.Args)
+	if err != nil {
+		return llm.ErrorToolOut(err)
+	}
+	start := time.Now()
+	out, runErr := r.Bash.RunCommand(ctx, command)
+	if ctx.Err() != nil {
+		return llm.ErrorToolOut(ctx.Err())
+	}
+
+	var summary *testSummary
+	switch framework {
+	case "go":
+		summary = parseGoTestJSON(out)
+	case "pytest":
+		output, report := splitTestReport(out)
+		summary, err = parseJUnitXML(report)
+		if err != nil {
+			summary = &testSummary{Errors: []string{lastLines(output, testErrorLines)}}
+		}
+	case "jest":
+		output, report := splitTestReport(out)
+		summary, err = parseJestJSON(report)
+		if err != nil {
+			summary = &testSummary{Errors: []string{lastLines(output, testErrorLines)}}
+		}
+	}
+	summary.Type = runTestsName
+	summary.Framework = framework
+	summary.Command = command
+	if summary.Seconds == 0 {
+		summary.Seconds = time.Since(start).Seconds()
+	}
+	// A failed run that reported nothing else, such as a missing runner,
+	// still fails.
+	if runErr != nil && summary.ok() {
+		summary.Errors = append(summary.Errors, fmt.Sprintf("%s: %v\n%s", framework, runErr, lastLines(out, testErrorLines)))
+	}
+	summary.finish(slowest)
+
+	text := summary.String()
+	if !summary.ok() {
+		return llm.ToolOut{Error: errors.New(text), Display: summary}
+	}
+	return llm.ToolOut{LLMContent: llm.TextContent(text), Display: summary}
+}
+
+// detectTestFrameworkScript prints the test framework of the working
+// directory, or nothing.
+const detectTestFrameworkScript = `if [ -f go.mod ]; then echo go
+elif [ -f package.json ] && grep -q '"jest"' package.json; then echo jest
+elif [ -f pytest.ini ] || [ -f conftest.py ] || [ -f pyproject.toml ] || [ -f setup.cfg ] || [ -f tox.ini ]; then echo pytest
+fi`
+
+// testCommand returns the shell command that runs framework's tests with
+// args. pytest and jest write their reports to a file, which the command
+// prints after testReportMarker.
+func testCommand(framework string, args []string) (string, error) {
+	var quoted []string
+	for _, arg := range args {
+		quoted = append(quoted, remote.Quote(arg))
+	}
+	rest := strings.Join(quoted, " ")
+	var command string
+	switch framework {
+	case "go":
+		return strings.TrimSpace("go test -json " + cmp.Or(rest, "./...")), nil
+	case "pytest":
+		command = `python3 -m pytest -q --junitxml="$report" ` + rest
+	case "jest":
+		command = `npx --no-install jest --json --outputFile="$report" ` + rest
+	default:
+		return "", fmt.Errorf("unsupported framework %q; use go, pytest or jest", framework)
+	}
+	return fmt.Sprintf(`report=$(mktemp); %s; status=$?; echo; echo %s; cat "$report"; rm -f "$report"; exit $status`,
+		strings.TrimSpace(command), remote.Quote(testReportMarker)), nil
+}
+
+// splitTestReport splits the output of a command from testCommand into the
+// runner's output and its report.
+func splitTestReport(out string) (output, report string) {
+	i := strings.LastIndex(out, "\n"+testReportMarker+"\n")
+	if i < 0 {
+		return out, ""
+	}
+	return out[:i], out[i+len(testReportMarker)+2:]
+}
+
+// goTestEvent is a line of go test -json output (see go doc test2json).
+type goTestEvent struct {
+	Action  string
+	Package string
+	Test    string
+	Elapsed float64
+	Output  string
+}
+
+// parseGoTestJSON parses the output of go test -json. Lines that aren't
+// events, such as build errors on older Go versions, are reported as errors.
+func parseGoTestJSON(out string) *testSummary {
+	s := &testSummary{}
+	type key struct{ pkg, test string }
+	outputs := make(map[key]*strings.Builder)
+	var stray, build strings.Builder
+	failedPkgs := make(map[string]bool)
+
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	scanner.Buffer(make([]byte, 0, 64<<10), 16<<20)
+	for scanner.Scan() {
+		line := scanner.Text()
+		var ev goTestEvent
+		if !strings.HasPrefix(line, "{") || json.Unmarshal([]byte(line), &ev) != nil {
+			if strings.TrimSpace(line) != "" {
+				stray.WriteString(line + "\n")
+			}
+			continue
+		}
+		k := key{ev.Package, ev.Test}
+		switch ev.Action {
+		case "output":
+			b := outputs[k]
+			if b == nil {
+				b = &strings.Builder{}
+				outputs[k] = b
+			}
+			b.WriteString(ev.Output)
+		case "build-output":
+			build.WriteString(ev.Output)
+		case "pass", "fail", "skip":
+			if ev.Test == "" {
+				s.Seconds += ev.Elapsed
+				if ev.Action == "fail" {
+					failedPkgs[ev.Package] = true
+				}
+				continue
+			}
+			tc := testCase{Name: ev.Test, Package: ev.Package, Status: ev.Action, Seconds: ev.Elapsed}
+			if ev.Action == "fail" {
+				if b := outputs[k]; b != nil {
+					tc.Output = cleanGoTestOutput(b.String())
+				}
+			}
+			s.add(tc)
+		}
+	}
+
+	// A package can fail without a failing test, when it doesn't build or
+	// a test binary crashes outside of a test.
+	for _, pkg := range slices.Sorted(maps.Keys(failedPkgs)) {
+		if slices.ContainsFunc(s.tests, func(tc testCase) bool { return tc.Package == pkg && tc.Status == "fail" }) {
+			continue
+		}
+		msg := "package " + pkg + " failed"
+		if b := outputs[key{pkg, ""}]; b != nil {
+			msg += ":\n" + lastLines(cleanGoTestOutput(b.String()), testErrorLines)
+		}
+		s.Errors = append(s.Errors, msg)
+	}
+	if build.Len() > 0 {
+		s.Errors = append(s.Errors, lastLines(build.String(), testErrorLines))
+	}
+	if stray.Len() > 0 {
+		s.Errors = append(s.Errors, lastLines(stray.String(), testErrorLines))
+	}
+	return s
+}
+
+// cleanGoTestOutput removes go test's progress lines from a test's output.
+func cleanGoTestOutput(out string) string {
+	var lines []string
+	for line := range strings.SplitSeq(strings.TrimRight(out, "\n"), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "=== ") || strings.HasPrefix(trimmed, "--- FAIL") || trimmed == "FAIL" || strings.HasPrefix(trimmed, "FAIL\t") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// junitSuites is a JUnit XML report, as written by pytest --junitxml.
+type junitSuites struct {
+	Suites []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Time  float64     `xml:"time,attr"`
+	Cases []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	ClassName string        `xml:"classname,attr"`
+	Name      string        `xml:"name,attr"`
+	Time      float64       `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure"`
+	Error     *junitFailure `xml:"error"`
+	Skipped   *junitFailure `xml:"skipped"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// parseJUnitXML parses a JUnit XML report, whose root is either
+// <testsuites> or a single <testsuite>.
+func parseJUnitXML(report string) (*testSummary, error) {
+	var suites junitSuites
+	if err := xml.Unmarshal([]byte(report), &suites); err != nil {
+		return nil, fmt.Errorf("failed to parse JUnit report: %w", err)
+	}
+	if len(suites.Suites) == 0 {
+		var suite junitSuite
+		if err := xml.Unmarshal([]byte(report), &suite); err != nil {
+			return nil, fmt.Errorf("failed to parse JUnit report: %w", err)
+		}
+		suites.Suites = []junitSuite{suite}
+	}
+
+	s := &testSummary{}
+	for _, suite := range suites.Suites {
+		s.Seconds += suite.Time
+		for _, c := range suite.Cases {
+			tc := testCase{Name: c.Name, Package: c.ClassName, Status: "pass", Seconds: c.Time}
+			switch {
+			case c.Failure != nil, c.Error != nil:
+				f := cmp.Or(c.Failure, c.Error)
+				tc.Status = "fail"
+				tc.Output = strings.TrimSpace(cmp.Or(f.Text, f.Message))
+			case c.Skipped != nil:
+				tc.Status = "skip"
+			}
+			s.add(tc)
+		}
+	}
+	return s, nil
+}
+
+// jestReport is the report written by jest --json.
+type jestReport struct {
+	TestResults []struct {
+		Name             string `json:"name"`
+		Status           string `json:"status"`
+		Message          string `json:"message"`
+		StartTime        int64  `json:"startTime"`
+		EndTime          int64  `json:"endTime"`
+		AssertionResults []struct {
+			FullName        string   `json:"fullName"`
+			Status          string   `json:"status"`
+			Duration        *float64 `json:"duration"`
+			FailureMessages []string `json:"failureMessages"`
+		} `json:"assertionResults"`
+	} `json:"testResults"`
+}
+
+// parseJestJSON parses the report written by jest --json.
+func parseJestJSON(report string) (*testSummary, error) {
+	var r jestReport
+	if err := json.Unmarshal([]byte(report), &r); err != nil {
+		return nil, fmt.Errorf("failed to parse jest report: %w", err)
+	}
+	s := &testSummary{}
+	for _, file := range r.TestResults {
+		if file.EndTime > file.StartTime {
+			s.Seconds += float64(file.EndTime-file.StartTime) / 1000
+		}
+		failed := false
+		for _, a := range file.AssertionResults {
+			tc := testCase{Name: a.FullName, Package: file.Name}
+			if a.Duration != nil {
+				tc.Seconds = *a.Duration / 1000
+			}
+			switch a.Status {
+			case "passed":
+				tc.Status = "pass"
+			case "failed":
+				tc.Status = "fail"
+				tc.Output = strings.TrimSpace(strings.Join(a.FailureMessages, "\n"))
+				failed = true
+			default: // pending, skipped, todo, disabled
+				tc.Status = "skip"
+			}
+			s.add(tc)
+		}
+		// A test file that fails to run has no failed tests, only a message.
+		if file.Status == "failed" && !failed {
+			s.Errors = append(s.Errors, file.Name+":\n"+lastLines(strings.TrimSpace(file.Message), testErrorLines))
+		}
+	}
+	return s, nil
+}
+
+// finish fills in the failures and the n slowest tests.
+func (s *testSummary) finish(n int) {
+	for _, tc := range s.tests {
+		if tc.Status != "fail" {
+			continue
+		}
+		// A failed subtest fails its parents; report the subtest alone.
+		if slices.ContainsFunc(s.tests, func(sub testCase) bool {
+			return sub.Status == "fail" && sub.Package == tc.Package && strings.HasPrefix(sub.Name, tc.Name+"/")
+		}) {
+			continue
+		}
+		tc.Output = lastLines(tc.Output, testFailureLines)
+		s.Failures = append(s.Failures, tc)
+	}
+
+	byTime := slices.Clone(s.tests)
+	slices.SortStableFunc(byTime, func(a, b testCase) int { return cmp.Compare(b.Seconds, a.Seconds) })
+	for _, tc := range byTime[:min(n, len(byTime))] {
+		if tc.Seconds == 0 {
+			break
+		}
+		tc.Output = ""
+		s.Slowest = append(s.Slowest, tc)
+	}
+}
+
+// String formats the summary for the agent.
+func (s *testSummary) String() string {
+	var b strings.Builder
+	status := "PASS"
+	if !s.ok() {
+		status = "FAIL"
+	}
+	fmt.Fprintf(&b, "%s: %d passed, %d failed, %d skipped in %s (%s)\n", status, s.Passed, s.Failed, s.Skipped, formatSeconds(s.Seconds), s.Command)
+	if len(s.Errors) > 0 {
+		b.WriteString("\nErrors:\n")
+		for _, e := range s.Errors {
+			b.WriteString(indent(e) + "\n")
+		}
+	}
+	if len(s.Failures) > 0 {
+		b.WriteString("\nFailed tests:\n")
+		for _, tc := range s.Failures {
+			fmt.Fprintf(&b, "- %s (%s)\n", tc.qualifiedName(), formatSeconds(tc.Seconds))
+			if tc.Output != "" {
+				b.WriteString(indent(tc.Output) + "\n")
+			}
+		}
+	}
+	if len(s.Slowest) > 0 {
+		b.WriteString("\nSlowest tests:\n")
+		for _, tc := range s.Slowest {
+			fmt.Fprintf(&b, "- %s (%s)\n", tc.qualifiedName(), formatSeconds(tc.Seconds))
+		}
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+func (tc testCase) qualifiedName() string {
+	if tc.Package == "" {
+		return tc.Name
+	}
+	return tc.Package + " " + tc.Name
+}
+
+func formatSeconds(s float64) string {
+	return strconv.FormatFloat(s, 'f', 2, 64) + "s"
+}
+
+// indent indents each line of s by four spaces.
+func indent(s string) string {
+	return "    " + strings.ReplaceAll(s, "\n", "\n    ")
+}
+
+// lastLines returns the last n lines of s, noting how many were left out.
+func lastLines(s string, n int) string {
+	lines := strings.Split(strings.TrimRight(s, "\n"), "\n")
+	if len(lines) <= n {
+		return strings.Join(lines, "\n")
+	}
+	return fmt.Sprintf("[%d lines omitted]\n", len(lines)-n) + strings.Join(lines[len(lines)-n:], "\n")
+}
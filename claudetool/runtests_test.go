@@ -0,0 +1,160 @@
+package claudetool
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseGoTestJSON(t *testing.T) {
+	out := `{"Action":"start","Package":"example.com/m"}
+{"Action":"run","Package":"example.com/m","Test":"TestOK"}
+{"Action":"output","Package":"example.com/m","Test":"TestOK","Output":"=== RUN   TestOK\n"}
+{"Action":"pass","Package":"example.com/m","Test":"TestOK","Elapsed":1.5}
+{"Action":"run","Package":"example.com/m","Test":"TestTable"}
+{"Action":"run","Package":"example.com/m","Test":"TestTable/empty"}
+{"Action":"output","Package":"example.com/m","Test":"TestTable/empty","Output":"=== RUN   TestTable/empty\n"}
+{"Action":"output","Package":"example.com/m","Test":"TestTable/empty","Output":"    m_test.go:12: got 1, want 0\n"}
+{"Action":"output","Package":"example.com/m","Test":"TestTable/empty","Output":"    --- FAIL: TestTable/empty (0.00s)\n"}
+{"Action":"fail","Package":"example.com/m","Test":"TestTable/empty","Elapsed":0}
+{"Action":"fail","Package":"example.com/m","Test":"TestTable","Elapsed":0.01}
+{"Action":"skip","Package":"example.com/m","Test":"TestSkip","Elapsed":0}
+{"Action":"fail","Package":"example.com/m","Elapsed":1.6}
+{"Action":"output","Package":"example.com/broken","Output":"FAIL\texample.com/broken [build failed]\n"}
+{"Action":"fail","Package":"example.com/broken","Elapsed":0}
+# example.com/broken
+broken/b.go:3:1: syntax error: non-declaration statement outside function body
+`
+	s := parseGoTestJSON(out)
+	s.finish(1)
+	if s.Passed != 1 || s.Failed != 2 || s.Skipped != 1 {
+		t.Errorf("expected 1 passed, 2 failed, 1 skipped, got %d, %d, %d", s.Passed, s.Failed, s.Skipped)
+	}
+	if len(s.Failures) != 1 || s.Failures[0].Name != "TestTable/empty" || s.Failures[0].Output != "    m_test.go:12: got 1, want 0" {
+		t.Errorf("expected only the failed subtest with its message, got %+v", s.Failures)
+	}
+	if len(s.Errors) != 2 || !strings.Contains(s.Errors[0], "example.com/broken") || !strings.Contains(s.Errors[1], "syntax error") {
+		t.Errorf("expected the broken package and its build error, got %q", s.Errors)
+	}
+	if len(s.Slowest) != 1 || s.Slowest[0].Name != "TestOK" {
+		t.Errorf("expected TestOK to be the slowest, got %+v", s.Slowest)
+	}
+	if s.ok() {
+		t.Error("expected the run to fail")
+	}
+}
+
+func TestParseJUnitXML(t *testing.T) {
+	report := `<?xml version="1.0" encoding="utf-8"?>
+<testsuites><testsuite name="pytest" errors="0" failures="1" skipped="1" tests="3" time="0.42">
+<testcase classname="tests.test_math" name="test_add" time="0.001" />
+<testcase classname="tests.test_math" name="test_div" time="0.3"><failure message="ZeroDivisionError: division by zero">def test_div():
+&gt;       1 / 0
+E       ZeroDivisionError: division by zero</failure></testcase>
+<testcase classname="tests.test_math" name="test_slow" time="0.1"><skipped type="pytest.skip" message="slow" /></testcase>
+</testsuite></testsuites>`
+	s, err := parseJUnitXML(report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.finish(5)
+	if s.Passed != 1 || s.Failed != 1 || s.Skipped != 1 || s.Seconds != 0.42 {
+		t.Errorf("unexpected counts %+v", s)
+	}
+	if len(s.Failures) != 1 || !strings.Contains(s.Failures[0].Output, "E       ZeroDivisionError") {
+		t.Errorf("expected test_div's traceback, got %+v", s.Failures)
+	}
+	if _, err := parseJUnitXML(""); err == nil {
+		t.Error("expected an empty report to be an error")
+	}
+}
+
+func TestParseJestJSON(t *testing.T) {
+	report := `{"success":false,"testResults":[
+{"name":"/app/src/sum.test.js","status":"failed","message":"","startTime":1000,"endTime":1250,"assertionResults":[
+{"fullName":"sum adds","status":"passed","duration":3,"failureMessages":[]},
+{"fullName":"sum rounds","status":"failed","duration":5,"failureMessages":["Expected: 3\nReceived: 2.9"]},
+{"fullName":"sum later","status":"todo","duration":null,"failureMessages":[]}]},
+{"name":"/app/src/broken.test.js","status":"failed","message":"SyntaxError: Unexpected token","startTime":0,"endTime":0,"assertionResults":[]}]}`
+	s, err := parseJestJSON(report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.finish(5)
+	if s.Passed != 1 || s.Failed != 1 || s.Skipped != 1 || s.Seconds != 0.25 {
+		t.Errorf("unexpected counts %+v", s)
+	}
+	if len(s.Failures) != 1 || s.Failures[0].Output != "Expected: 3\nReceived: 2.9" {
+		t.Errorf("expected the failure message, got %+v", s.Failures)
+	}
+	if len(s.Errors) != 1 || !strings.Contains(s.Errors[0], "SyntaxError") {
+		t.Errorf("expected the file that failed to run, got %q", s.Errors)
+	}
+}
+
+func TestSplitTestReport(t *testing.T) {
+	command, err := testCommand("pytest", []string{"-k", "it's"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(command, `'-k' 'it'\''s'`) {
+		t.Errorf("expected the arguments to be quoted, got %s", command)
+	}
+	output, report := splitTestReport("collected 1 item\n\n" + testReportMarker + "\n<testsuites/>")
+	if output != "collected 1 item\n" || report != "<testsuites/>" {
+		t.Errorf("unexpected split %q, %q", output, report)
+	}
+	if _, err := testCommand("mocha", nil); err == nil {
+		t.Error("expected an unsupported framework to be an error")
+	}
+}
+
+func TestRunTestsTool(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go not found")
+	}
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod": "module example.com/m\n\ngo 1.21\n",
+		"m_test.go": `package m
+
+import "testing"
+
+func TestPass(t *testing.T) {}
+
+func TestFail(t *testing.T) { t.Error("boom") }
+`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Clear GOFLAGS, which may point at a module file for the repository.
+	tool := &RunTestsTool{Bash: &BashTool{WorkingDir: NewMutableWorkingDir(dir), Env: []string{"GOFLAGS="}}}
+
+	out := tool.Run(context.Background(), json.RawMessage(`{}`))
+	if out.Error == nil {
+		t.Fatal("expected failing tests to be an error")
+	}
+	text := out.Error.Error()
+	if !strings.HasPrefix(text, "FAIL: 1 passed, 1 failed, 0 skipped") || !strings.Contains(text, "example.com/m TestFail") || !strings.Contains(text, "boom") {
+		t.Errorf("unexpected summary:\n%s", text)
+	}
+	summary, ok := out.Display.(*testSummary)
+	if !ok || summary.Framework != "go" || len(summary.Failures) != 1 {
+		t.Errorf("expected the summary as display data, got %+v", out.Display)
+	}
+
+	out = tool.Run(context.Background(), json.RawMessage(`{"args": ["-run", "TestPass", "."]}`))
+	if out.Error != nil {
+		t.Fatalf("expected the passing test to pass: %v", out.Error)
+	}
+	if text := out.LLMContent[0].Text; !strings.HasPrefix(text, "PASS: 1 passed, 0 failed") {
+		t.Errorf("unexpected summary:\n%s", text)
+	}
+}
@@ -36,3 +36,18 @@ func SessionID(ctx context.Context) string {
 	sessionID, _ := ctx.Value(sessionIDCtxKey).(string)
 	return sessionID
 }
+
+type toolUseIDCtxKeyType string
+
+const toolUseIDCtxKey toolUseIDCtxKeyType = "toolUseID"
+
+// WithToolUseID records the ID of the tool_use block a tool is running for.
+func WithToolUseID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, toolUseIDCtxKey, id)
+}
+
+// ToolUseID returns the ID of the tool_use block being run, or "" if unknown.
+func ToolUseID(ctx context.Context) string {
+	id, _ := ctx.Value(toolUseIDCtxKey).(string)
+	return id
+}
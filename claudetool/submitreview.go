@@ -0,0 +1,114 @@
+package claudetool
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"strings"
+
+	"shelley.exe.dev/llm"
+)
+
+// Review is a code review of a pull request, submitted by the agent with
+// the submit_review tool.
+type Review struct {
+	Summary string `json:"summary"`
+	// Verdict is approve, request_changes or comment.
+	Verdict  string          `json:"verdict"`
+	Comments []ReviewComment `json:"comments"`
+}
+
+// ReviewComment is a review comment on a line of the pull request's new
+// version of a file.
+type ReviewComment struct {
+	Path string `json:"path"`
+	Line int    `json:"line"`
+	// Severity is blocker, major, minor or nit.
+	Severity string `json:"severity"`
+	Body     string `json:"body"`
+}
+
+// SubmitReviewTool records the agent's review of a pull request.
+type SubmitReviewTool struct {
+	// Submit records the review and returns a note for the agent, such as
+	// where it was posted.
+	Submit func(ctx context.Context, review Review) (string, error)
+}
+
+const (
+	submitReviewName        = "submit_review"
+	submitReviewDescription = `Submit your review of the pull request: a summary, a verdict and comments on
+specific lines.
+
+Comment on lines of the new version of files, using the line numbers on the
++ and context side of the diff. Make each comment one concrete, actionable
+point, with a severity: blocker (must fix: bugs, security, data loss), major,
+minor or nit. Leave out praise and restating what the change does. Call it
+once, when the review is complete; calling it again replaces the review.
+`
+	submitReviewInputSchema = `{
+  "type": "object",
+  "required": ["summary", "verdict"],
+  "properties": {
+    "summary": {
+      "type": "string",
+      "description": "The overall assessment, in a few sentences"
+    },
+    "verdict": {
+      "type": "string",
+      "enum": ["approve", "request_changes", "comment"]
+    },
+    "comments": {
+      "type": "array",
+      "items": {
+        "type": "object",
+        "required": ["path", "line", "severity", "body"],
+        "properties": {
+          "path": {"type": "string", "description": "The file, relative to the repository root"},
+          "line": {"type": "integer", "description": "The line in the new version of the file"},
+          "severity": {"type": "string", "enum": ["blocker", "major", "minor", "nit"]},
+          "body": {"type": "string", "description": "The comment, in Markdown"}
+        }
+      }
+    }
+  }
+}`
+)
+
+// Tool returns an llm.Tool for submitting a review.
+func (s *SubmitReviewTool) Tool() *llm.Tool {
+	return &llm.Tool{
+		Name:        submitReviewName,
+		Description: submitReviewDescription,
+		InputSchema: llm.MustSchema(submitReviewInputSchema),
+		Run:         s.Run,
+	}
+}
+
+// Run executes the submit_review tool.
+func (s *SubmitReviewTool) Run(ctx context.Context, m json.RawMessage) llm.ToolOut {
+	var review Review
+	if err := json.Unmarshal(m, &review); err != nil {
+		return llm.ErrorfToolOut("failed to parse submit_review input: %w", err)
+	}
+	review.Summary = strings.TrimSpace(review.Summary)
+	if review.Summary == "" {
+		return llm.ErrorfToolOut("summary is required")
+	}
+	if !slices.Contains([]string{"approve", "request_changes", "comment"}, review.Verdict) {
+		return llm.ErrorfToolOut("verdict must be approve, request_changes or comment, not %q", review.Verdict)
+	}
+	for i, c := range review.Comments {
+		switch {
+		case c.Path == "" || c.Line < 1 || strings.TrimSpace(c.Body) == "":
+			return llm.ErrorfToolOut("comment %d needs a path, a line and a body", i+1)
+		case !slices.Contains([]string{"blocker", "major", "minor", "nit"}, c.Severity):
+			return llm.ErrorfToolOut("comment %d: severity must be blocker, major, minor or nit, not %q", i+1, c.Severity)
+		}
+	}
+	note, err := s.Submit(ctx, review)
+	if err != nil {
+		return llm.ErrorfToolOut("failed to submit review: %w", err)
+	}
+	return llm.ToolOut{LLMContent: llm.TextContent(note)}
+}
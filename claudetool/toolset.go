@@ -2,11 +2,16 @@ package claudetool
 
 import (
 	"context"
+	"io"
+	"slices"
 	"strings"
 	"sync"
 
 	"shelley.exe.dev/claudetool/browse"
+	"shelley.exe.dev/filepolicy"
 	"shelley.exe.dev/llm"
+	"shelley.exe.dev/remote"
+	"shelley.exe.dev/sandbox"
 )
 
 // WorkingDir is a thread-safe mutable working directory.
@@ -50,6 +55,84 @@ type ToolSetConfig struct {
 	// OnWorkingDirChange is called when the working directory changes.
 	// This can be used to persist the change to a database.
 	OnWorkingDirChange func(newDir string)
+	// SaveMemory persists a note for future conversations in the same project.
+	// If nil, the remember tool is not offered.
+	SaveMemory func(ctx context.Context, content string) error
+	// Recall searches past conversations and memories by meaning.
+	// If nil, the recall tool is not offered.
+	Recall func(ctx context.Context, query string) ([]RecallResult, error)
+	// SearchDocs searches the documentation of the repository containing workingDir.
+	// If nil, the search_docs tool is not offered.
+	SearchDocs func(ctx context.Context, workingDir, query string) ([]DocSearchResult, error)
+	// BashRunDir holds records of running bash commands so that their outcome
+	// can be recovered after a restart. If empty, no records are kept.
+	BashRunDir string
+	// Processes, if set, records the process groups of running commands,
+	// so that they can be reaped if the server crashes.
+	Processes *ProcessRegistry
+	// ProcessManager keeps track of the conversation's long-running
+	// processes. If nil, the process tool is not offered.
+	ProcessManager *ProcessManager
+	// PreviewPath is where the server proxies requests to the
+	// conversation's processes, if it does.
+	PreviewPath string
+	// AllowedTools, if non-nil, restricts the set to tools with these names.
+	AllowedTools []string
+	// DisabledTools removes the tools with these names from the set.
+	DisabledTools []string
+	// BashTimeouts overrides the bash tool's default timeouts.
+	BashTimeouts *Timeouts
+	// BashOutput overrides the bash tool's default output limits.
+	BashOutput *OutputLimits
+	// OnToolOutput is called with the output of running tools as they
+	// produce it; the tool use ID is in ctx (see ToolUseID).
+	OnToolOutput func(ctx context.Context, chunk string)
+	// AskUser, if set, enables the ask_user tool, which calls it to put a
+	// question to the user and wait for the answer.
+	AskUser func(ctx context.Context, question Question) (string, error)
+	// SubmitReview, if set, enables the submit_review tool, which calls it
+	// with the agent's review of a pull request.
+	SubmitReview func(ctx context.Context, review Review) (string, error)
+	// ProposePlan and UpdatePlanStep, if set, enable the propose_plan and
+	// update_plan_step tools of a conversation in plan mode.
+	ProposePlan    func(ctx context.Context, plan Plan) (string, error)
+	UpdatePlanStep func(ctx context.Context, step int, status, note string) (string, error)
+	// PublishArtifact, if set, enables the publish_artifact tool, which
+	// calls it to store a file as an artifact of the conversation.
+	PublishArtifact func(ctx context.Context, name string, content io.Reader) (string, error)
+	// OnFileEdit is called after the patch tool changes a file.
+	// It can be used to record edits so they can be undone.
+	OnFileEdit func(ctx context.Context, edit FileEdit) error
+	// TrackBashChanges is passed to the bash tool as TrackChanges, to
+	// record the files each foreground command changes.
+	TrackBashChanges func(ctx context.Context, dir string) func()
+	// Sandbox, if set, runs bash commands and keyword searches under
+	// bubblewrap and keeps the patch tool within the policy's writable
+	// directories. The server also confines FilePolicy to the directories
+	// the sandbox mounts.
+	Sandbox *sandbox.Policy
+	// FilePolicy, if set, decides which files the patch tool may write and
+	// which files the file tools (patch, list_files, read_document,
+	// publish_artifact and read_image) may read.
+	FilePolicy *filepolicy.Policy
+	// Remote, if set, runs bash commands, file patches, searches and
+	// directory changes through this shell, on another host over SSH or in
+	// a dev container, with WorkingDir a directory there. It takes
+	// precedence over Sandbox.
+	Remote remote.Shell
+	// BashEnv holds KEY=value variables added to the environment of bash
+	// commands.
+	BashEnv []string
+	// DeployTargets are the targets of the deploy tool, which is offered
+	// if there are any. Deploys to production targets are confirmed with
+	// AskUser.
+	DeployTargets []DeployTarget
+	// DeployRunDir holds the records of deploys that restart Shelley, which
+	// are verified by a watcher. If empty, they aren't verified.
+	DeployRunDir string
+	// SelfHealthURL is the server's own health URL, which verifies deploys
+	// that restart it.
+	SelfHealthURL string
 }
 
 // ToolSet holds a set of tools for a single conversation.
@@ -58,6 +141,7 @@ type ToolSet struct {
 	tools   []*llm.Tool
 	cleanup func()
 	wd      *MutableWorkingDir
+	bash    *BashTool
 }
 
 // Tools returns the tools in this set.
@@ -77,6 +161,12 @@ func (ts *ToolSet) WorkingDir() *MutableWorkingDir {
 	return ts.wd
 }
 
+// RunCommand runs a shell command the way the bash tool would (see
+// BashTool.RunCommand), even if the bash tool isn't offered.
+func (ts *ToolSet) RunCommand(ctx context.Context, command string) (string, error) {
+	return ts.bash.RunCommand(ctx, command)
+}
+
 // NewToolSet creates a new set of tools for a conversation.
 // isStrongModel returns true for models that can handle complex tool schemas.
 func isStrongModel(modelID string) bool {
@@ -94,7 +184,16 @@ func NewToolSet(ctx context.Context, cfg ToolSetConfig) *ToolSet {
 	bashTool := &BashTool{
 		WorkingDir:       wd,
 		LLMProvider:      cfg.LLMProvider,
-		EnableJITInstall: cfg.EnableJITInstall,
+		Timeouts:         cfg.BashTimeouts,
+		Output:           cfg.BashOutput,
+		OnOutput:         cfg.OnToolOutput,
+		EnableJITInstall: cfg.EnableJITInstall && cfg.Sandbox == nil && cfg.Remote == nil, // installs would run outside the sandbox, or here
+		RunDir:           cfg.BashRunDir,
+		TrackChanges:     cfg.TrackBashChanges,
+		Sandbox:          cfg.Sandbox,
+		Remote:           cfg.Remote,
+		Env:              cfg.BashEnv,
+		Processes:        cfg.Processes,
 	}
 
 	// Use simplified patch schema for weaker models, full schema for sonnet/opus
@@ -103,16 +202,38 @@ func NewToolSet(ctx context.Context, cfg ToolSetConfig) *ToolSet {
 		Simplified:       simplified,
 		WorkingDir:       wd,
 		ClipboardEnabled: true,
+		OnEdit:           cfg.OnFileEdit,
+		Sandbox:          cfg.Sandbox,
+		Policy:           cfg.FilePolicy,
+		Remote:           cfg.Remote,
 	}
 
 	keywordTool := NewKeywordToolWithWorkingDir(cfg.LLMProvider, wd)
+	keywordTool.Remote = cfg.Remote
+	keywordTool.Sandbox = cfg.Sandbox
 
 	changeDirTool := &ChangeDirTool{
 		WorkingDir: wd,
 		OnChange:   cfg.OnWorkingDirChange,
+		Remote:     cfg.Remote,
+	}
+
+	listFilesTool := &ListFilesTool{
+		WorkingDir: wd,
+		Policy:     cfg.FilePolicy,
+		Remote:     cfg.Remote,
+	}
+
+	readDocumentTool := &ReadDocumentTool{
+		WorkingDir: wd,
+		Policy:     cfg.FilePolicy,
+		Remote:     cfg.Remote,
 	}
 
-	deploySelfTool := &DeploySelfTool{}
+	runTestsTool := &RunTestsTool{Bash: bashTool}
+	lintTool := &LintTool{Bash: bashTool}
+	benchmarkTool := &BenchmarkTool{Bash: bashTool}
+	githubActionsTool := &GitHubActionsTool{Bash: bashTool}
 
 	tools := []*llm.Tool{
 		Think,
@@ -120,7 +241,62 @@ func NewToolSet(ctx context.Context, cfg ToolSetConfig) *ToolSet {
 		patchTool.Tool(),
 		keywordTool.Tool(),
 		changeDirTool.Tool(),
-		deploySelfTool.Tool(),
+		listFilesTool.Tool(),
+		readDocumentTool.Tool(),
+		runTestsTool.Tool(),
+		lintTool.Tool(),
+		benchmarkTool.Tool(),
+		githubActionsTool.Tool(),
+	}
+
+	if cfg.SaveMemory != nil {
+		rememberTool := &RememberTool{Save: cfg.SaveMemory}
+		tools = append(tools, rememberTool.Tool())
+	}
+
+	if cfg.AskUser != nil {
+		askUserTool := &AskUserTool{Ask: cfg.AskUser}
+		tools = append(tools, askUserTool.Tool())
+	}
+
+	if cfg.SubmitReview != nil {
+		submitReviewTool := &SubmitReviewTool{Submit: cfg.SubmitReview}
+		tools = append(tools, submitReviewTool.Tool())
+	}
+
+	if cfg.ProposePlan != nil {
+		proposePlanTool := &ProposePlanTool{Propose: cfg.ProposePlan}
+		tools = append(tools, proposePlanTool.Tool())
+	}
+
+	if cfg.UpdatePlanStep != nil {
+		updatePlanStepTool := &UpdatePlanStepTool{Update: cfg.UpdatePlanStep}
+		tools = append(tools, updatePlanStepTool.Tool())
+	}
+
+	if cfg.PublishArtifact != nil {
+		publishArtifactTool := &PublishArtifactTool{WorkingDir: wd, Policy: cfg.FilePolicy, Remote: cfg.Remote, Publish: cfg.PublishArtifact}
+		tools = append(tools, publishArtifactTool.Tool())
+	}
+
+	if cfg.ProcessManager != nil {
+		processTool := &ProcessTool{Bash: bashTool, Processes: cfg.ProcessManager, PreviewPath: cfg.PreviewPath}
+		tools = append(tools, processTool.Tool())
+	}
+
+	if len(cfg.DeployTargets) > 0 {
+		deployTool := &DeployTool{Bash: bashTool, Targets: cfg.DeployTargets, Ask: cfg.AskUser, RunDir: cfg.DeployRunDir, SelfHealthURL: cfg.SelfHealthURL}
+		tools = append(tools, deployTool.Tool())
+	}
+
+	if cfg.Recall != nil {
+		recallTool := &RecallTool{Search: cfg.Recall}
+		tools = append(tools, recallTool.Tool())
+	}
+
+	if cfg.SearchDocs != nil {
+		docSearchTool := &DocSearchTool{WorkingDir: wd, Search: cfg.SearchDocs}
+		tools = append(tools, docSearchTool.Tool())
 	}
 
 	var cleanup func()
@@ -132,16 +308,28 @@ func NewToolSet(ctx context.Context, cfg ToolSetConfig) *ToolSet {
 				maxImageDimension = svc.MaxImageDimension()
 			}
 		}
-		browserTools, browserCleanup := browse.RegisterBrowserTools(ctx, true, maxImageDimension)
+		browserTools, browserCleanup := browse.RegisterBrowserTools(ctx, true, maxImageDimension, cfg.FilePolicy)
 		if len(browserTools) > 0 {
 			tools = append(tools, browserTools...)
 		}
 		cleanup = browserCleanup
 	}
 
+	if cfg.AllowedTools != nil {
+		tools = slices.DeleteFunc(tools, func(t *llm.Tool) bool {
+			return !slices.Contains(cfg.AllowedTools, t.Name)
+		})
+	}
+	if cfg.DisabledTools != nil {
+		tools = slices.DeleteFunc(tools, func(t *llm.Tool) bool {
+			return slices.Contains(cfg.DisabledTools, t.Name)
+		})
+	}
+
 	return &ToolSet{
 		tools:   tools,
 		cleanup: cleanup,
 		wd:      wd,
+		bash:    bashTool,
 	}
 }
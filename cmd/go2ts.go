@@ -54,12 +54,14 @@ func TS() *go2ts.Go2TS {
 			db.MessageTypeError,
 			db.MessageTypeSystem,
 			db.MessageTypeGitInfo,
+			db.MessageTypeSummary,
 		},
 	)
 
 	// Database struct types
 	generator.AddMultiple(
 		generated.Conversation{},
+		generated.Project{},
 		llm.Usage{},
 	)
 
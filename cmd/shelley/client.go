@@ -0,0 +1,536 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"shelley.exe.dev/db"
+	"shelley.exe.dev/db/generated"
+	"shelley.exe.dev/llm"
+	"shelley.exe.dev/server"
+)
+
+// defaultServerURL is where the client commands find the server unless
+// -url or SHELLEY_URL says otherwise.
+const defaultServerURL = "http://localhost:9000"
+
+// maxStreamLine bounds a single event of a conversation stream, which holds
+// whole messages including tool output.
+const maxStreamLine = 64 << 20
+
+// apiClient talks to a running server's HTTP API.
+type apiClient struct {
+	baseURL string
+	header  http.Header
+	http    *http.Client
+}
+
+// clientFlags registers the flags shared by the client commands on fs. The
+// returned function builds the client once fs has been parsed.
+func clientFlags(fs *flag.FlagSet) func() (*apiClient, error) {
+	defaultURL := os.Getenv("SHELLEY_URL")
+	if defaultURL == "" {
+		defaultURL = defaultServerURL
+	}
+	serverURL := fs.String("url", defaultURL, "URL of the Shelley server (default from SHELLEY_URL)")
+	header := fs.String("header", "", "Header to send with each request as 'Name: value', for servers run with -require-header")
+	return func() (*apiClient, error) {
+		c := &apiClient{
+			baseURL: strings.TrimRight(*serverURL, "/"),
+			header:  http.Header{},
+			http:    &http.Client{},
+		}
+		if *header != "" {
+			name, value, ok := strings.Cut(*header, ":")
+			if !ok || strings.TrimSpace(name) == "" {
+				return nil, fmt.Errorf("-header: expected 'Name: value', got %q", *header)
+			}
+			c.header.Set(strings.TrimSpace(name), strings.TrimSpace(value))
+		}
+		return c, nil
+	}
+}
+
+// do sends a request to path, encoding body as JSON if it isn't nil, and
+// decodes a successful response into out if it isn't nil.
+func (c *apiClient) do(ctx context.Context, method, path string, body, out any) error {
+	resp, err := c.request(ctx, method, path, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("%s %s: decode response: %w", method, path, err)
+	}
+	return nil
+}
+
+// request sends a request and returns the response if it succeeded. The
+// caller must close its body.
+func (c *apiClient) request(ctx context.Context, method, path string, body any) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range c.header {
+		req.Header[name] = values
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	// The server refuses state-changing requests without it (CSRF protection)
+	req.Header.Set("X-Shelley-Request", "1")
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, fmt.Errorf("%s %s: %s: %s", method, path, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return resp, nil
+}
+
+// conversationPath returns the API path of a conversation's endpoint.
+func conversationPath(id, endpoint string) string {
+	return "/api/conversation/" + url.PathEscape(id) + "/" + endpoint
+}
+
+// newConversation starts a conversation with msg and returns its ID.
+func (c *apiClient) newConversation(ctx context.Context, req server.ChatRequest) (string, error) {
+	var resp struct {
+		ConversationID string `json:"conversation_id"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/api/conversations/new", req, &resp); err != nil {
+		return "", err
+	}
+	return resp.ConversationID, nil
+}
+
+// send adds a message to a conversation. It reports whether the message was
+// queued behind the agent's current turn instead of being handed to it.
+func (c *apiClient) send(ctx context.Context, id string, req server.ChatRequest) (queued bool, err error) {
+	var resp struct {
+		Status string `json:"status"`
+	}
+	if err := c.do(ctx, http.MethodPost, conversationPath(id, "chat"), req, &resp); err != nil {
+		return false, err
+	}
+	return resp.Status == "queued", nil
+}
+
+// stream calls fn with each update of a conversation's event stream until
+// fn returns false, the stream ends, or ctx is done.
+func (c *apiClient) stream(ctx context.Context, id string, fn func(server.StreamResponse) bool) error {
+	resp, err := c.request(ctx, http.MethodGet, conversationPath(id, "stream"), nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), maxStreamLine)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+		if !ok {
+			continue
+		}
+		var update server.StreamResponse
+		if err := json.Unmarshal([]byte(data), &update); err != nil {
+			return fmt.Errorf("decode stream update: %w", err)
+		}
+		if !fn(update) {
+			return nil
+		}
+	}
+	if err := scanner.Err(); err != nil && ctx.Err() == nil {
+		return err
+	}
+	return nil
+}
+
+// tailOptions control which messages tail prints and when it stops.
+type tailOptions struct {
+	// Last is how many of the existing messages to print; -1 prints them all.
+	Last int
+	// Reply prints only what follows the latest user message, leaving out
+	// user messages, as the reply to a message just sent.
+	Reply bool
+	// Queued means the message just sent waits behind the current turn, so
+	// the reply only starts once it's handed to the agent.
+	Queued bool
+	// Follow keeps printing after the agent's turn ends.
+	Follow bool
+}
+
+// tail prints a conversation's messages to w as they arrive. Unless
+// opts.Follow is set, it returns once the agent is no longer working.
+func (c *apiClient) tail(ctx context.Context, w io.Writer, id string, opts tailOptions) error {
+	first := true
+	started := !opts.Queued
+	return c.stream(ctx, id, func(update server.StreamResponse) bool {
+		if first {
+			first = false
+			messages := update.Messages
+			if opts.Queued && !update.AgentWorking {
+				// The queued message was handed to the agent before the
+				// stream started
+				started = true
+			}
+			if opts.Reply {
+				messages = nil
+				if started {
+					messages = update.Messages[lastUserMessage(update.Messages)+1:]
+				}
+			} else if opts.Last >= 0 && len(messages) > opts.Last {
+				messages = messages[len(messages)-opts.Last:]
+			}
+			for _, msg := range messages {
+				printMessage(w, msg, !opts.Reply)
+			}
+			return opts.Follow || !started || update.AgentWorking
+		}
+		if len(update.Messages) == 0 {
+			// Tool output, the queue, or a change of title
+			return true
+		}
+		for _, msg := range update.Messages {
+			if !started && userText(msg) != "" {
+				started = true
+				continue
+			}
+			if started || !opts.Reply {
+				printMessage(w, msg, !opts.Reply)
+			}
+		}
+		return opts.Follow || !started || update.AgentWorking
+	})
+}
+
+// lastUserMessage returns the index of the last message the user wrote,
+// or -1.
+func lastUserMessage(messages []server.APIMessage) int {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if userText(messages[i]) != "" {
+			return i
+		}
+	}
+	return -1
+}
+
+// decodeMessage returns the LLM message a stored message holds.
+func decodeMessage(msg server.APIMessage) (llm.Message, bool) {
+	var message llm.Message
+	if msg.LlmData == nil || json.Unmarshal([]byte(*msg.LlmData), &message) != nil {
+		return message, false
+	}
+	return message, true
+}
+
+// userText returns the text of a message the user wrote, or "" for other
+// messages, including the tool results sent on the user's behalf.
+func userText(msg server.APIMessage) string {
+	if msg.Type != string(db.MessageTypeUser) {
+		return ""
+	}
+	message, ok := decodeMessage(msg)
+	if !ok {
+		return ""
+	}
+	var texts []string
+	for _, content := range message.Content {
+		if content.Type == llm.ContentTypeText && content.Text != "" {
+			texts = append(texts, content.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+// printMessage writes the readable parts of a message to w: text, tools
+// called, and errors. User messages are printed quoted if showUser is set.
+func printMessage(w io.Writer, msg server.APIMessage, showUser bool) {
+	message, ok := decodeMessage(msg)
+	if !ok {
+		return
+	}
+	switch db.MessageType(msg.Type) {
+	case db.MessageTypeUser:
+		if text := userText(msg); showUser && text != "" {
+			fmt.Fprintf(w, "> %s\n", strings.ReplaceAll(text, "\n", "\n> "))
+		}
+	case db.MessageTypeAgent:
+		for _, content := range message.Content {
+			switch content.Type {
+			case llm.ContentTypeText:
+				if text := strings.TrimSpace(content.Text); text != "" {
+					fmt.Fprintln(w, text)
+				}
+			case llm.ContentTypeToolUse:
+				fmt.Fprintf(w, "[%s]\n", toolLine(content.ToolName, content.ToolInput))
+			}
+		}
+	case db.MessageTypeError:
+		for _, content := range message.Content {
+			if content.Type == llm.ContentTypeText && content.Text != "" {
+				fmt.Fprintf(w, "error: %s\n", content.Text)
+			}
+		}
+	}
+}
+
+// toolLine summarizes a tool call on one line: its name and the input
+// field that says most about it.
+func toolLine(name string, input json.RawMessage) string {
+	summary := string(input)
+	var fields map[string]any
+	if json.Unmarshal(input, &fields) == nil {
+		for _, key := range []string{"command", "path", "query", "question", "url"} {
+			if v, ok := fields[key].(string); ok && v != "" {
+				summary = v
+				break
+			}
+		}
+	}
+	summary = strings.Join(strings.Fields(summary), " ")
+	if len(summary) > 100 {
+		summary = strings.ToValidUTF8(summary[:100], "") + "..."
+	}
+	return name + ": " + summary
+}
+
+// listConversations writes a table of the most recently updated
+// conversations to w, or their JSON if asJSON is set.
+func (c *apiClient) listConversations(ctx context.Context, w io.Writer, limit int, query string, asJSON bool) error {
+	params := url.Values{"limit": {strconv.Itoa(limit)}}
+	if query != "" {
+		params.Set("q", query)
+	}
+	var conversations []generated.Conversation
+	if err := c.do(ctx, http.MethodGet, "/api/conversations?"+params.Encode(), nil, &conversations); err != nil {
+		return err
+	}
+	if asJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(conversations)
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tSLUG\tSTATUS\tUPDATED\tCWD")
+	for _, conv := range conversations {
+		status := "idle"
+		switch {
+		case conv.AgentWorking:
+			status = "working"
+		case conv.AgentError:
+			status = "error"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", conv.ConversationID, valueOr(conv.Slug, "-"), status,
+			conv.UpdatedAt.Local().Format(time.DateTime), valueOr(conv.Cwd, "-"))
+	}
+	return tw.Flush()
+}
+
+// stop cancels the agent's current turn and drops queued messages.
+func (c *apiClient) stop(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodPost, conversationPath(id, "cancel"), nil, nil)
+}
+
+func valueOr(s *string, fallback string) string {
+	if s == nil || *s == "" {
+		return fallback
+	}
+	return *s
+}
+
+// messageArg returns the message given on the command line, reading it
+// from stdin if it's missing or "-".
+func messageArg(args []string) (string, error) {
+	msg := strings.Join(args, " ")
+	if msg == "" || msg == "-" {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return "", fmt.Errorf("read message from stdin: %w", err)
+		}
+		msg = string(data)
+	}
+	msg = strings.TrimSpace(msg)
+	if msg == "" {
+		return "", errors.New("message is empty")
+	}
+	return msg, nil
+}
+
+// clientContext returns a context cancelled by an interrupt, so a command
+// waiting on a stream exits cleanly on Ctrl-C.
+func clientContext() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), os.Interrupt)
+}
+
+func exitOnError(err error) {
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// runNew starts a conversation and prints its ID.
+// Usage: shelley new [flags] <message>
+func runNew(args []string) {
+	fs := flag.NewFlagSet("new", flag.ExitOnError)
+	newClient := clientFlags(fs)
+	model := fs.String("model", "", "Model to use (default: the server's default model)")
+	cwd := fs.String("cwd", "", "Working directory of the conversation on the server")
+	wait := fs.Bool("wait", false, "Print the agent's reply and wait for its turn to end")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: shelley new [flags] <message>\n\n")
+		fmt.Fprintf(fs.Output(), "Starts a conversation and prints its ID. The message is read from stdin if omitted or '-'.\n\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	c, err := newClient()
+	exitOnError(err)
+	msg, err := messageArg(fs.Args())
+	exitOnError(err)
+	ctx, cancel := clientContext()
+	defer cancel()
+
+	id, err := c.newConversation(ctx, server.ChatRequest{Message: msg, Model: *model, Cwd: *cwd})
+	exitOnError(err)
+	if !*wait {
+		fmt.Println(id)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "Conversation %s\n", id)
+	exitOnError(c.tail(ctx, os.Stdout, id, tailOptions{Reply: true}))
+}
+
+// runSend adds a message to a conversation.
+// Usage: shelley send [flags] <conversation-id> <message>
+func runSend(args []string) {
+	fs := flag.NewFlagSet("send", flag.ExitOnError)
+	newClient := clientFlags(fs)
+	model := fs.String("model", "", "Model to use (default: the conversation's model)")
+	queue := fs.Bool("queue", false, "If the agent is working, queue the message until its turn ends")
+	wait := fs.Bool("wait", false, "Print the agent's reply and wait for its turn to end")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: shelley send [flags] <conversation-id> <message>\n\n")
+		fmt.Fprintf(fs.Output(), "Sends a message to a conversation. The message is read from stdin if omitted or '-'.\n\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() < 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	c, err := newClient()
+	exitOnError(err)
+	id := fs.Arg(0)
+	msg, err := messageArg(fs.Args()[1:])
+	exitOnError(err)
+	ctx, cancel := clientContext()
+	defer cancel()
+
+	queued, err := c.send(ctx, id, server.ChatRequest{Message: msg, Model: *model, Queue: *queue})
+	exitOnError(err)
+	if queued {
+		fmt.Fprintln(os.Stderr, "Queued until the agent's turn ends")
+	}
+	if *wait {
+		exitOnError(c.tail(ctx, os.Stdout, id, tailOptions{Reply: true, Queued: queued}))
+	}
+}
+
+// runTail prints a conversation's latest messages and those that follow.
+// Usage: shelley tail [flags] <conversation-id>
+func runTail(args []string) {
+	fs := flag.NewFlagSet("tail", flag.ExitOnError)
+	newClient := clientFlags(fs)
+	last := fs.Int("n", 10, "Number of existing messages to print (-1 for all)")
+	follow := fs.Bool("f", false, "Keep printing messages after the agent's turn ends")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: shelley tail [flags] <conversation-id>\n\n")
+		fmt.Fprintf(fs.Output(), "Prints a conversation's latest messages, then new ones until the agent's turn ends.\n\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	c, err := newClient()
+	exitOnError(err)
+	ctx, cancel := clientContext()
+	defer cancel()
+
+	exitOnError(c.tail(ctx, os.Stdout, fs.Arg(0), tailOptions{Last: *last, Follow: *follow}))
+}
+
+// runLs lists conversations, most recently updated first.
+// Usage: shelley ls [flags]
+func runLs(args []string) {
+	fs := flag.NewFlagSet("ls", flag.ExitOnError)
+	newClient := clientFlags(fs)
+	limit := fs.Int("n", 20, "Maximum number of conversations to list")
+	query := fs.String("q", "", "Only list conversations matching this search")
+	asJSON := fs.Bool("json", false, "Print the conversations as JSON")
+	fs.Parse(args)
+
+	c, err := newClient()
+	exitOnError(err)
+	ctx, cancel := clientContext()
+	defer cancel()
+
+	exitOnError(c.listConversations(ctx, os.Stdout, *limit, *query, *asJSON))
+}
+
+// runStop cancels the agent's current turn in a conversation.
+// Usage: shelley stop [flags] <conversation-id>
+func runStop(args []string) {
+	fs := flag.NewFlagSet("stop", flag.ExitOnError)
+	newClient := clientFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: shelley stop [flags] <conversation-id>\n\n")
+		fmt.Fprintf(fs.Output(), "Cancels the agent's current turn and drops queued messages.\n\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	c, err := newClient()
+	exitOnError(err)
+	ctx, cancel := clientContext()
+	defer cancel()
+
+	exitOnError(c.stop(ctx, fs.Arg(0)))
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"shelley.exe.dev/claudetool"
+	"shelley.exe.dev/db"
+	"shelley.exe.dev/server"
+)
+
+// newTestClient starts a server using the predictable model and returns a
+// client for it.
+func newTestClient(t *testing.T) *apiClient {
+	t.Helper()
+	database, err := db.New(db.Config{DSN: filepath.Join(t.TempDir(), "test.db")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.Close() })
+	if err := database.Migrate(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	llmManager := server.NewLLMServiceManager(&server.LLMConfig{Logger: logger}, nil)
+	svr := server.NewServer(database, llmManager, claudetool.ToolSetConfig{WorkingDir: t.TempDir()}, logger, true, "", "predictable", "", nil)
+	mux := http.NewServeMux()
+	svr.RegisterRoutes(mux)
+	ts := httptest.NewServer(server.CSRFMiddleware()(mux))
+	t.Cleanup(ts.Close)
+	return &apiClient{baseURL: ts.URL, header: http.Header{}, http: ts.Client()}
+}
+
+func TestClientCommands(t *testing.T) {
+	c := newTestClient(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	id, err := c.newConversation(ctx, server.ChatRequest{Message: "echo: hello", Model: "predictable"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err := c.tail(ctx, &out, id, tailOptions{Reply: true}); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSpace(out.String()); got != "hello" {
+		t.Errorf("reply to new: got %q", got)
+	}
+
+	// Only the reply to the latest message is printed, tools included
+	if queued, err := c.send(ctx, id, server.ChatRequest{Message: "bash: echo hi"}); err != nil || queued {
+		t.Fatalf("send: queued %v, err %v", queued, err)
+	}
+	out.Reset()
+	if err := c.tail(ctx, &out, id, tailOptions{Reply: true}); err != nil {
+		t.Fatal(err)
+	}
+	if got := out.String(); !strings.Contains(got, "[bash: echo hi]") || strings.Contains(got, "hello") {
+		t.Errorf("reply to send: got %q", got)
+	}
+
+	// tail quotes what the user wrote
+	out.Reset()
+	if err := c.tail(ctx, &out, id, tailOptions{Last: -1}); err != nil {
+		t.Fatal(err)
+	}
+	if got := out.String(); !strings.HasPrefix(got, "> echo: hello\nhello\n> bash: echo hi\n") {
+		t.Errorf("tail: got %q", got)
+	}
+
+	out.Reset()
+	if err := c.listConversations(ctx, &out, 10, "", false); err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Split(strings.TrimSpace(out.String()), "\n"); len(lines) != 2 || !strings.HasPrefix(lines[1], id) || !strings.Contains(lines[1], "idle") {
+		t.Errorf("ls: got %q", out.String())
+	}
+
+	if err := c.stop(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.send(ctx, id, server.ChatRequest{}); err == nil || !strings.Contains(err.Error(), "Message is required") {
+		t.Errorf("expected the server's error, got %v", err)
+	}
+}
+
+func TestClientStopsWorkingAgent(t *testing.T) {
+	c := newTestClient(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	id, err := c.newConversation(ctx, server.ChatRequest{Message: "bash: sleep 30", Model: "predictable"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	var out bytes.Buffer
+	go func() { done <- c.tail(ctx, &out, id, tailOptions{Reply: true}) }()
+
+	// Wait for the tool to start before stopping it
+	err = c.stream(ctx, id, func(update server.StreamResponse) bool {
+		for _, msg := range update.Messages {
+			if msg.LlmData != nil && strings.Contains(*msg.LlmData, `"ToolName":"bash"`) {
+				return false
+			}
+		}
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.stop(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("tail didn't return after the turn was cancelled")
+	}
+	if !strings.Contains(out.String(), "[Operation cancelled]") {
+		t.Errorf("expected the cancellation in the reply, got %q", out.String())
+	}
+}
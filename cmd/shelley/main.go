@@ -14,11 +14,15 @@ import (
 	"strings"
 	"time"
 
+	"shelley.exe.dev/broadcast"
 	"shelley.exe.dev/claudetool"
 	"shelley.exe.dev/db"
+	"shelley.exe.dev/embed"
 	"shelley.exe.dev/models"
+	"shelley.exe.dev/sandbox"
 	"shelley.exe.dev/server"
 	"shelley.exe.dev/templates"
+	"shelley.exe.dev/transcribe"
 	"shelley.exe.dev/ui"
 	"shelley.exe.dev/version"
 )
@@ -31,6 +35,7 @@ type GlobalConfig struct {
 	ConfigPath      string
 	TerminalURL     string
 	DefaultModel    string
+	DBPool          db.PoolConfig
 }
 
 func main() {
@@ -38,6 +43,11 @@ func main() {
 	var global GlobalConfig
 	defaultModelID := models.Default().ID
 	flag.StringVar(&global.DBPath, "db", "shelley.db", "Path to SQLite database file")
+	flag.StringVar(&global.DBPool.JournalMode, "db-journal-mode", db.DefaultJournalMode, "SQLite journal mode of the database")
+	flag.StringVar(&global.DBPool.Synchronous, "db-synchronous", db.DefaultSynchronous, "SQLite synchronous level of the database: off, normal, full or extra")
+	flag.DurationVar(&global.DBPool.BusyTimeout, "db-busy-timeout", db.DefaultBusyTimeout, "How long a database write waits for another process's before failing")
+	flag.IntVar(&global.DBPool.Readers, "db-readers", db.DefaultReaders, "Number of read-only database connections")
+	flag.IntVar(&global.DBPool.Writers, "db-writers", db.DefaultWriters, "Number of database connections that write")
 	flag.BoolVar(&global.Debug, "debug", false, "Enable debug logging")
 	flag.StringVar(&global.Model, "model", defaultModelID, "LLM model to use (use 'predictable' for testing)")
 	flag.BoolVar(&global.PredictableOnly, "predictable-only", false, "Use only the predictable service, ignoring all other models")
@@ -52,7 +62,15 @@ func main() {
 		fmt.Fprintf(flag.CommandLine.Output(), "\nCommands:\n")
 		fmt.Fprintf(flag.CommandLine.Output(), "  serve [flags]                 Start the web server\n")
 		fmt.Fprintf(flag.CommandLine.Output(), "  unpack-template <name> <dir>  Unpack a project template to a directory\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  stdio [flags]                 Run a conversation over JSON lines on stdin and stdout\n")
 		fmt.Fprintf(flag.CommandLine.Output(), "  version                       Print version information as JSON\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  migrate up|down|status [N]    Apply, revert or report the database migrations\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "\nClient commands (talk to a running server, see -url):\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  new <message>                 Start a conversation and print its ID\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  send <id> <message>           Send a message to a conversation\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  tail <id>                     Print a conversation's messages as they arrive\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  ls                            List conversations\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  stop <id>                     Cancel the agent's current turn\n")
 		fmt.Fprintf(flag.CommandLine.Output(), "\nUse '%s <command> -h' for command-specific help\n", os.Args[0])
 	}
 
@@ -78,10 +96,26 @@ func main() {
 		runServe(global, args[1:])
 	case "unpack-template":
 		runUnpackTemplate(args[1:])
+	case "stdio":
+		runStdio(global, args[1:])
 	case "version":
 		runVersion()
+	case "migrate":
+		runMigrate(global, args[1:])
 	case "deploy-daemon":
 		runDeployDaemon(args[1:])
+	case "deploy-watch":
+		runDeployWatch(args[1:])
+	case "new":
+		runNew(args[1:])
+	case "send":
+		runSend(args[1:])
+	case "tail":
+		runTail(args[1:])
+	case "ls":
+		runLs(args[1:])
+	case "stop":
+		runStop(args[1:])
 	default:
 		fmt.Fprintf(os.Stderr, "Unknown command: %s\n", command)
 		flag.Usage()
@@ -94,11 +128,50 @@ func runServe(global GlobalConfig, args []string) {
 	port := fs.String("port", "9000", "Port to listen on")
 	systemdActivation := fs.Bool("systemd-activation", false, "Use systemd socket activation (listen on fd from systemd)")
 	requireHeader := fs.String("require-header", "", "Require this header on all API requests (e.g., X-Exedev-Userid)")
+	recovery := fs.String("recovery", string(server.RecoveryAuto), "What to do with conversations interrupted by a restart: auto (resume), manual (resume via API), or off")
+	sandboxEnabled := fs.Bool("sandbox", false, "Run the tools of new conversations under bubblewrap, seeing only the workspace and system directories and writing only to the workspace (conversations can override)")
+	sandboxNetwork := fs.Bool("sandbox-network", false, "Allow network access from sandboxed tools")
+	sandboxWritable := fs.String("sandbox-writable", "", "Comma-separated directories sandboxed tools can write to besides the workspace")
+	sandboxReadable := fs.String("sandbox-readable", "", "Comma-separated directories sandboxed tools can read besides the workspace and system directories")
+	grpcAddr := fs.String("grpc-addr", "", "Also serve the gRPC API on this address (e.g., localhost:9001)")
+	shutdownGrace := fs.Duration("shutdown-grace", server.DefaultShutdownGrace, "How long turns in flight get to finish when the server is stopped (0 to not wait)")
+	broadcastURL := fs.String("broadcast", "", "Share conversation updates with the other servers using the same database through Redis (redis://host:6379) or NATS (nats://host:4222)")
+	toolResultBlobSize := fs.Int("tool-result-blob-size", server.DefaultToolResultBlobSize, "Store tool results larger than this many bytes apart from their messages, which keep a preview (0 to keep them in the messages)")
+	artifactMaxSize := fs.Int64("artifact-max-size", server.DefaultArtifactMaxSize, "Largest artifact, in bytes, the agent can publish to a conversation")
+	artifactQuota := fs.Int64("artifact-quota", server.DefaultArtifactQuota, "Most bytes of artifacts a conversation can have")
+	artifactRetention := fs.Duration("artifact-retention", server.DefaultArtifactRetention, "How long published artifacts are kept")
 	fs.Parse(args)
 
+	recoveryPolicy, err := server.ParseRecoveryPolicy(*recovery)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	var broadcaster broadcast.Broadcaster
+	if *broadcastURL != "" {
+		if broadcaster, err = broadcast.Open(*broadcastURL); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: -broadcast: %v\n", err)
+			os.Exit(1)
+		}
+		defer broadcaster.Close()
+	}
+
+	if *sandboxEnabled && !sandbox.Available() {
+		fmt.Fprintf(os.Stderr, "Error: -sandbox: %v\n", sandbox.ErrUnavailable)
+		os.Exit(1)
+	}
+	sandboxOpts := server.SandboxOptions{Enabled: *sandboxEnabled, AllowNetwork: *sandboxNetwork}
+	if *sandboxWritable != "" {
+		sandboxOpts.WritablePaths = strings.Split(*sandboxWritable, ",")
+	}
+	if *sandboxReadable != "" {
+		sandboxOpts.ReadablePaths = strings.Split(*sandboxReadable, ",")
+	}
+
 	logger := setupLogging(global.Debug)
 
-	database := setupDatabase(global.DBPath, logger)
+	database := setupDatabase(global.DBPath, global.DBPool, logger)
 	defer database.Close()
 
 	// Set the database path for system prompt generation
@@ -128,8 +201,17 @@ func runServe(global GlobalConfig, args []string) {
 	// Create server
 	svr := server.NewServer(database, llmManager, toolSetConfig, logger, global.PredictableOnly, llmConfig.TerminalURL, llmConfig.DefaultModel, *requireHeader, llmConfig.Links)
 	svr.SetAssetHash(assetHash)
+	svr.SetEmbedder(setupEmbedder(llmConfig, global.PredictableOnly))
+	svr.SetTranscriber(setupTranscriber(llmConfig, global.PredictableOnly))
+	svr.SetRecoveryPolicy(recoveryPolicy)
+	svr.SetDefaultSandbox(sandboxOpts)
+	svr.SetGRPCAddr(*grpcAddr)
+	svr.SetShutdownGrace(*shutdownGrace)
+	svr.SetBroadcaster(broadcaster)
+	svr.SetToolResultBlobSize(*toolResultBlobSize)
+	svr.SetArtifactLimits(*artifactMaxSize, *artifactQuota)
+	svr.SetArtifactRetention(*artifactRetention)
 
-	var err error
 	if *systemdActivation {
 		listener, listenerErr := systemdListener()
 		if listenerErr != nil {
@@ -160,8 +242,8 @@ func setupLogging(debug bool) *slog.Logger {
 	return logger
 }
 
-func setupDatabase(dbPath string, logger *slog.Logger) *db.DB {
-	database, err := db.New(db.Config{DSN: dbPath})
+func setupDatabase(dbPath string, pool db.PoolConfig, logger *slog.Logger) *db.DB {
+	database, err := db.New(db.Config{DSN: dbPath, PoolConfig: pool})
 	if err != nil {
 		logger.Error("Failed to initialize database", "error", err)
 		os.Exit(1)
@@ -238,6 +320,20 @@ func runUnpackTemplate(args []string) {
 	fmt.Printf("Template %q unpacked to %s\n", templateName, destDir)
 }
 
+// runDeployWatch runs a deploy that restarts Shelley, started by the deploy
+// tool, verifies it, and rolls it back if it's unhealthy.
+// Usage: shelley deploy-watch <record>
+func runDeployWatch(args []string) {
+	if len(args) != 1 {
+		fmt.Fprintf(os.Stderr, "Usage: shelley deploy-watch <record>\n")
+		os.Exit(1)
+	}
+	if err := claudetool.WatchDeploy(args[0]); err != nil {
+		fmt.Fprintf(os.Stderr, "Deploy watch failed: %v\n", err)
+		os.Exit(1)
+	}
+}
+
 // runDeployDaemon stops the service, copies the binary, and restarts the service.
 // Usage: shelley deploy-daemon <source-binary>
 func runDeployDaemon(args []string) {
@@ -248,7 +344,7 @@ func runDeployDaemon(args []string) {
 
 	sourceBinary := args[0]
 
-	// Brief delay to allow the deploy_self tool response to be sent to the browser
+	// Brief delay to allow the deploy tool's response to be sent to the browser
 	// before we kill the service
 	time.Sleep(500 * time.Millisecond)
 
@@ -337,6 +433,38 @@ func setupToolSetConfig(llmProvider claudetool.LLMServiceProvider) claudetool.To
 	}
 }
 
+// setupEmbedder picks the embedding provider for semantic search.
+// Returns nil, disabling semantic search, when OpenAI is not configured.
+func setupEmbedder(llmCfg *server.LLMConfig, predictableOnly bool) embed.Provider {
+	if predictableOnly {
+		return &embed.Hash{}
+	}
+	if llmCfg.OpenAIAPIKey == "" {
+		return nil
+	}
+	p := &embed.OpenAI{APIKey: llmCfg.OpenAIAPIKey}
+	if llmCfg.Gateway != "" {
+		p.URL = llmCfg.Gateway + "/_/gateway/openai/v1"
+	}
+	return p
+}
+
+// setupTranscriber picks the transcription provider for voice input.
+// Returns nil, disabling voice input, when OpenAI is not configured.
+func setupTranscriber(llmCfg *server.LLMConfig, predictableOnly bool) transcribe.Provider {
+	if predictableOnly {
+		return transcribe.Text{}
+	}
+	if llmCfg.OpenAIAPIKey == "" {
+		return nil
+	}
+	p := &transcribe.OpenAI{APIKey: llmCfg.OpenAIAPIKey}
+	if llmCfg.Gateway != "" {
+		p.URL = llmCfg.Gateway + "/_/gateway/openai/v1"
+	}
+	return p
+}
+
 // buildLLMConfig constructs LLMConfig from environment variables and optional config file
 func buildLLMConfig(logger *slog.Logger, configPath, terminalURL, defaultModel string) *server.LLMConfig {
 	llmCfg := &server.LLMConfig{
@@ -347,6 +475,9 @@ func buildLLMConfig(logger *slog.Logger, configPath, terminalURL, defaultModel s
 		TerminalURL:     terminalURL,
 		DefaultModel:    defaultModel,
 		Logger:          logger,
+
+		PredictableFixture:     os.Getenv("PREDICTABLE_FIXTURE"),
+		PredictableRecordModel: os.Getenv("PREDICTABLE_RECORD_MODEL"),
 	}
 
 	if configPath != "" {
@@ -359,10 +490,11 @@ func buildLLMConfig(logger *slog.Logger, configPath, terminalURL, defaultModel s
 		}
 
 		var cfg struct {
-			LLMGateway   string        `json:"llm_gateway"`
-			TerminalURL  string        `json:"terminal_url"`
-			DefaultModel string        `json:"default_model"`
-			Links        []server.Link `json:"links"`
+			LLMGateway   string                   `json:"llm_gateway"`
+			TerminalURL  string                   `json:"terminal_url"`
+			DefaultModel string                   `json:"default_model"`
+			Links        []server.Link            `json:"links"`
+			ModelLimits  map[string]models.Limits `json:"model_limits"`
 		}
 		if err := json.Unmarshal(data, &cfg); err != nil {
 			logger.Warn("Failed to parse config file", "path", configPath, "error", err)
@@ -406,6 +538,11 @@ func buildLLMConfig(logger *slog.Logger, configPath, terminalURL, defaultModel s
 			llmCfg.Links = cfg.Links
 			logger.Info("Loaded links from config", "count", len(cfg.Links))
 		}
+
+		if len(cfg.ModelLimits) > 0 {
+			llmCfg.ModelLimits = cfg.ModelLimits
+			logger.Info("Loaded model limits from config", "count", len(cfg.ModelLimits))
+		}
 	}
 
 	return llmCfg
@@ -83,6 +83,35 @@ func TestCLICommands(t *testing.T) {
 		// If no error or different error, that's also fine for this basic test
 		t.Logf("Serve command output: %s", string(output))
 	})
+
+	t.Run("migrate", func(t *testing.T) {
+		dbPath := filepath.Join(t.TempDir(), "shelley.db")
+		migrate := func(args ...string) string {
+			t.Helper()
+			output, err := exec.Command(binary, append([]string{"-db", dbPath, "migrate"}, args...)...).CombinedOutput()
+			if err != nil {
+				t.Fatalf("migrate %v: %v: %s", args, err, output)
+			}
+			return string(output)
+		}
+		if out := migrate("up", "130"); !strings.Contains(out, "Schema version 130") {
+			t.Errorf("expected version 130, got %s", out)
+		}
+		if out := migrate("up"); strings.Contains(out, "Schema version 130") || !strings.Contains(out, " 0 pending") {
+			t.Errorf("expected every migration applied, got %s", out)
+		}
+		migrate("down", "130")
+		if out := migrate("down"); !strings.Contains(out, "Schema version 129") {
+			t.Errorf("expected the latest migration reverted, got %s", out)
+		}
+		out := migrate("status")
+		if !strings.Contains(out, "129-add-pr-reviews.sql (reversible)") || !strings.Contains(out, "130  pending") {
+			t.Errorf("unexpected status: %s", out)
+		}
+		if output, err := exec.Command(binary, "-db", dbPath, "migrate", "down", "0").CombinedOutput(); err == nil || !strings.Contains(string(output), "no down path") {
+			t.Errorf("expected an irreversible migration refused, got %v: %s", err, output)
+		}
+	})
 }
 
 func TestSystemdListenerErrors(t *testing.T) {
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"shelley.exe.dev/db"
+)
+
+const migrateUsage = `Usage: shelley [-db path] migrate <command>
+
+Commands:
+  up [N]     Apply the pending migrations, up to migration N if given
+  down [N]   Revert the migrations above N, or the latest one if N isn't given,
+             and store compressed messages as plain text
+  status     Print the schema version and each migration's state
+
+Before running an older Shelley, revert the migrations it doesn't have with
+the newer one: "shelley migrate down N", N being the older one's latest,
+even if that's the current version. Builds from before message compression
+can't read compressed messages; the newer one compresses them again when it
+serves. Stop the server first.
+`
+
+// runMigrate applies, reverts or reports the database migrations, without
+// serving.
+func runMigrate(global GlobalConfig, args []string) {
+	if len(args) == 0 || len(args) > 2 {
+		fmt.Fprint(os.Stderr, migrateUsage)
+		os.Exit(2)
+	}
+	database, err := db.New(db.Config{DSN: global.DBPath, PoolConfig: global.DBPool})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer database.Close()
+	if err := migrate(context.Background(), database, args, os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func migrate(ctx context.Context, database *db.DB, args []string, out io.Writer) error {
+	to := -1
+	if len(args) == 2 {
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 0 {
+			return fmt.Errorf("invalid migration number %q", args[1])
+		}
+		to = n
+	}
+
+	status, err := database.MigrationStatus(ctx)
+	if err != nil {
+		return err
+	}
+	switch args[0] {
+	case "up":
+		if to < 0 {
+			to = status.Latest
+		}
+		err = database.MigrateUp(ctx, to)
+	case "down":
+		if to < 0 {
+			// Down to the migration applied before the latest
+			to = 0
+			for _, m := range status.Migrations {
+				if m.Applied && m.Number < status.Version {
+					to = m.Number
+				}
+			}
+		}
+		if err = database.MigrateDown(ctx, to); err == nil {
+			var n int
+			n, err = database.DecompressMessages(ctx)
+			if n > 0 {
+				fmt.Fprintf(out, "Decompressed %d messages for older builds\n", n)
+			}
+		}
+	case "status":
+		if to >= 0 {
+			return fmt.Errorf("status takes no migration number")
+		}
+	default:
+		return fmt.Errorf("unknown migrate command %q (want up, down or status)", args[0])
+	}
+	if err != nil {
+		return err
+	}
+
+	if status, err = database.MigrationStatus(ctx); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Schema version %d; this build's latest is %d, %d pending\n", status.Version, status.Latest, status.Pending)
+	if args[0] != "status" {
+		return nil
+	}
+	for _, m := range status.Migrations {
+		state := "pending"
+		switch {
+		case m.Unknown:
+			state = "applied by a newer build"
+		case m.AppliedAt != nil:
+			state = "applied " + m.AppliedAt.Format("2006-01-02 15:04:05")
+		case m.Applied:
+			state = "applied"
+		}
+		reversible := ""
+		if m.Reversible {
+			reversible = " (reversible)"
+		}
+		fmt.Fprintf(out, "%03d  %-30s  %s%s\n", m.Number, state, m.Name, reversible)
+	}
+	return nil
+}
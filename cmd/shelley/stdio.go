@@ -0,0 +1,330 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+
+	"shelley.exe.dev/agent"
+	"shelley.exe.dev/claudetool"
+	"shelley.exe.dev/llm"
+	"shelley.exe.dev/models"
+	"shelley.exe.dev/server"
+)
+
+// maxStdioLine bounds a single input line of the stdio protocol.
+const maxStdioLine = 16 << 20
+
+// errInputClosed fails questions the agent asks after stdin is closed, as
+// nobody is left to answer them.
+var errInputClosed = errors.New("input closed; the user can't answer")
+
+// stdioInput is a line read from stdin in stdio mode.
+type stdioInput struct {
+	// Type is "user" for a message to the agent or "answer" for the answer
+	// to the question the agent is waiting on.
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// stdioEvent is a line written to stdout in stdio mode. Type says which of
+// the other fields are set:
+//
+//   - "init": Model, Cwd and Tools, once at startup
+//   - "text": Text the agent wrote
+//   - "tool_use": ID, Name and Input of a tool call
+//   - "tool_result": ID, Output and IsError of a tool call
+//   - "question": ID of the ask_user call, Text and Options; answer it
+//     with an "answer" line
+//   - "result": Usage of the turn that ended, and Error if it failed
+//   - "error": Error, for input that couldn't be handled
+type stdioEvent struct {
+	Type    string          `json:"type"`
+	Model   string          `json:"model,omitempty"`
+	Cwd     string          `json:"cwd,omitempty"`
+	Tools   []string        `json:"tools,omitempty"`
+	Text    string          `json:"text,omitempty"`
+	ID      string          `json:"id,omitempty"`
+	Name    string          `json:"name,omitempty"`
+	Input   json.RawMessage `json:"input,omitempty"`
+	Output  string          `json:"output,omitempty"`
+	IsError bool            `json:"is_error,omitempty"`
+	Options []string        `json:"options,omitempty"`
+	Usage   *llm.Usage      `json:"usage,omitempty"`
+	Error   string          `json:"error,omitempty"`
+}
+
+// stdioSession runs one conversation for the stdio protocol. Messages that
+// arrive while the agent works are held until its turn ends, so each turn
+// ends with exactly one result event.
+type stdioSession struct {
+	agent *agent.Agent
+
+	outMu sync.Mutex
+	out   *json.Encoder
+
+	mu      sync.Mutex
+	busy    bool
+	pending []llm.Content
+	answer  chan string
+	closed  bool
+	idle    chan struct{}
+}
+
+// newStdioSession returns a session writing its events to out. The caller
+// creates the agent with the session's onMessage and askUser hooks and
+// sets it before serving.
+func newStdioSession(out io.Writer) *stdioSession {
+	return &stdioSession{out: json.NewEncoder(out), idle: make(chan struct{})}
+}
+
+func (s *stdioSession) emit(event stdioEvent) {
+	s.outMu.Lock()
+	defer s.outMu.Unlock()
+	s.out.Encode(event)
+}
+
+// serve handles the lines of in until it's exhausted, then waits for the
+// agent to finish its turn.
+func (s *stdioSession) serve(ctx context.Context, in io.Reader) error {
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 64*1024), maxStdioLine)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var input stdioInput
+		if err := json.Unmarshal([]byte(line), &input); err != nil {
+			s.emit(stdioEvent{Type: "error", Error: "invalid JSON: " + err.Error()})
+			continue
+		}
+		if err := s.handle(ctx, input); err != nil {
+			s.emit(stdioEvent{Type: "error", Error: err.Error()})
+		}
+	}
+	s.close()
+	select {
+	case <-s.idle:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return scanner.Err()
+}
+
+func (s *stdioSession) handle(ctx context.Context, input stdioInput) error {
+	if strings.TrimSpace(input.Text) == "" {
+		return fmt.Errorf("%q input without text", input.Type)
+	}
+	switch input.Type {
+	case "user":
+		s.send(ctx, input.Text)
+		return nil
+	case "answer":
+		s.mu.Lock()
+		answer := s.answer
+		s.answer = nil
+		s.mu.Unlock()
+		if answer == nil {
+			return errors.New("no question is waiting for an answer")
+		}
+		answer <- input.Text
+		return nil
+	default:
+		return fmt.Errorf("unknown input type %q", input.Type)
+	}
+}
+
+// send starts a turn with a message, or holds the message until the
+// current turn ends.
+func (s *stdioSession) send(ctx context.Context, text string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.busy {
+		s.pending = append(s.pending, llm.StringContent(text))
+		return
+	}
+	s.busy = true
+	go s.run(ctx, llm.UserStringMessage(text))
+}
+
+// run runs turns, starting with msg, until no messages are pending.
+func (s *stdioSession) run(ctx context.Context, msg llm.Message) {
+	for {
+		turn, err := s.agent.Send(ctx, msg)
+		result := stdioEvent{Type: "result", Usage: &turn.Usage}
+		if err != nil {
+			result.Error = err.Error()
+		}
+		s.emit(result)
+
+		s.mu.Lock()
+		if len(s.pending) == 0 {
+			s.busy = false
+			if s.closed {
+				close(s.idle)
+			}
+			s.mu.Unlock()
+			return
+		}
+		msg = llm.Message{Role: llm.MessageRoleUser, Content: s.pending}
+		s.pending = nil
+		s.mu.Unlock()
+	}
+}
+
+// close notes that no more input will come: pending and future questions
+// fail, and idle is closed once the agent isn't working.
+func (s *stdioSession) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	if s.answer != nil {
+		close(s.answer)
+		s.answer = nil
+	}
+	if !s.busy {
+		close(s.idle)
+	}
+}
+
+// onMessage is the agent's OnMessage hook. It emits the message's events;
+// a failed request is reported by the turn's result instead.
+func (s *stdioSession) onMessage(ctx context.Context, message llm.Message, usage llm.Usage) {
+	for _, content := range message.Content {
+		switch content.Type {
+		case llm.ContentTypeText:
+			if message.Role == llm.MessageRoleAssistant && content.Text != "" && !strings.HasPrefix(content.Text, "LLM request failed:") {
+				s.emit(stdioEvent{Type: "text", Text: content.Text})
+			}
+		case llm.ContentTypeToolUse:
+			s.emit(stdioEvent{Type: "tool_use", ID: content.ID, Name: content.ToolName, Input: content.ToolInput})
+		case llm.ContentTypeToolResult:
+			s.emit(stdioEvent{Type: "tool_result", ID: content.ToolUseID, Output: toolResultText(content.ToolResult), IsError: content.ToolError})
+		}
+	}
+}
+
+// askUser is the AskUser hook of the session's tools. It emits the
+// question and waits for an "answer" line.
+func (s *stdioSession) askUser(ctx context.Context, question claudetool.Question) (string, error) {
+	answer := make(chan string, 1)
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return "", errInputClosed
+	}
+	s.answer = answer
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		if s.answer == answer {
+			s.answer = nil
+		}
+		s.mu.Unlock()
+	}()
+
+	s.emit(stdioEvent{Type: "question", ID: claudetool.ToolUseID(ctx), Text: question.Question, Options: question.Options})
+	select {
+	case a, ok := <-answer:
+		if !ok {
+			return "", errInputClosed
+		}
+		return a, nil
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// toolResultText joins the text of a tool's result.
+func toolResultText(contents []llm.Content) string {
+	var texts []string
+	for _, content := range contents {
+		if content.Type == llm.ContentTypeText {
+			texts = append(texts, content.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+// runStdio runs a conversation over stdin and stdout, one JSON object per
+// line, for programs that embed Shelley as a subprocess.
+// Usage: shelley stdio [flags]
+func runStdio(global GlobalConfig, args []string) {
+	fs := flag.NewFlagSet("stdio", flag.ExitOnError)
+	cwd := fs.String("cwd", "", "Working directory of the conversation (default: the current directory)")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: shelley [global-flags] stdio [flags]\n\n")
+		fmt.Fprintf(fs.Output(), "Reads messages as JSON lines on stdin, like {\"type\": \"user\", \"text\": \"...\"},\n")
+		fmt.Fprintf(fs.Output(), "and writes the agent's text, tool calls and results as JSON lines on stdout.\n")
+		fmt.Fprintf(fs.Output(), "Exits when stdin is closed and the agent's turn has ended. Logs go to stderr.\n\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if *cwd == "" {
+		wd, err := os.Getwd()
+		exitOnError(err)
+		*cwd = wd
+	}
+
+	// Stdout carries the protocol
+	logLevel := slog.LevelWarn
+	if global.Debug {
+		logLevel = slog.LevelDebug
+	}
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: logLevel}))
+	slog.SetDefault(logger)
+
+	modelID := global.Model
+	if global.PredictableOnly {
+		modelID = "predictable"
+	}
+	llmConfig := buildLLMConfig(logger, global.ConfigPath, global.TerminalURL, global.DefaultModel)
+	llmManager := server.NewLLMServiceManager(llmConfig, models.NewLLMRequestHistory(10))
+	service, err := llmManager.GetService(modelID)
+	exitOnError(err)
+
+	systemPrompt, err := server.GenerateSystemPrompt(*cwd, nil)
+	exitOnError(err)
+
+	ctx, cancel := clientContext()
+	defer cancel()
+
+	session := newStdioSession(os.Stdout)
+	toolSetConfig := setupToolSetConfig(llmManager)
+	toolSetConfig.WorkingDir = *cwd
+	toolSetConfig.ModelID = modelID
+	toolSetConfig.EnableBrowser = false
+	toolSetConfig.AskUser = session.askUser
+	toolSet := claudetool.NewToolSet(ctx, toolSetConfig)
+	defer toolSet.Cleanup()
+
+	session.agent, err = agent.New(agent.Config{
+		LLM:        service,
+		Tools:      toolSet.Tools(),
+		System:     systemPrompt,
+		WorkingDir: *cwd,
+		OnMessage:  session.onMessage,
+		Logger:     logger,
+	})
+	exitOnError(err)
+
+	var names []string
+	for _, tool := range toolSet.Tools() {
+		names = append(names, tool.Name)
+	}
+	session.emit(stdioEvent{Type: "init", Model: modelID, Cwd: *cwd, Tools: names})
+
+	if err := session.serve(ctx, os.Stdin); err != nil && !errors.Is(err, context.Canceled) {
+		exitOnError(err)
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"shelley.exe.dev/agent"
+	"shelley.exe.dev/claudetool"
+	"shelley.exe.dev/llm"
+	"shelley.exe.dev/loop"
+)
+
+func TestStdioSession(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	session := newStdioSession(outW)
+	toolSet := claudetool.NewToolSet(ctx, claudetool.ToolSetConfig{WorkingDir: t.TempDir(), AskUser: session.askUser})
+	defer toolSet.Cleanup()
+	var err error
+	session.agent, err = agent.New(agent.Config{
+		LLM:       loop.NewPredictableService(),
+		Tools:     toolSet.Tools(),
+		OnMessage: session.onMessage,
+		Logger:    slog.New(slog.DiscardHandler),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- session.serve(ctx, inR)
+		outW.Close()
+	}()
+	events := make(chan stdioEvent, 100)
+	go func() {
+		scanner := bufio.NewScanner(outR)
+		for scanner.Scan() {
+			var event stdioEvent
+			if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+				t.Errorf("invalid event %q: %v", scanner.Text(), err)
+			}
+			events <- event
+		}
+		close(events)
+	}()
+	write := func(line string) {
+		t.Helper()
+		if _, err := io.WriteString(inW, line+"\n"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// next returns the events up to and including one of type typ
+	next := func(typ string) []stdioEvent {
+		t.Helper()
+		var got []stdioEvent
+		for {
+			select {
+			case event, ok := <-events:
+				if !ok {
+					t.Fatalf("output ended waiting for %q; got %+v", typ, got)
+				}
+				got = append(got, event)
+				if event.Type == typ {
+					return got
+				}
+			case <-ctx.Done():
+				t.Fatalf("timed out waiting for %q; got %+v", typ, got)
+			}
+		}
+	}
+
+	write(`{"type": "user", "text": "echo: hello"}`)
+	if got := next("result"); len(got) != 2 || got[0].Type != "text" || got[0].Text != "hello" || got[1].Usage == nil {
+		t.Errorf("unexpected events %+v", got)
+	}
+
+	write(`{"type": "user", "text": "bash: echo hi"}`)
+	got := next("result")
+	var use, result stdioEvent
+	for _, event := range got {
+		switch event.Type {
+		case "tool_use":
+			use = event
+		case "tool_result":
+			result = event
+		}
+	}
+	if use.Name != "bash" || string(use.Input) != `{"command":"echo hi"}` || result.ID != use.ID || result.Output != "hi\n" {
+		t.Errorf("unexpected tool events %+v", got)
+	}
+
+	write(`not json`)
+	write(`{"type": "answer", "text": "yes"}`)
+	if got := next("error"); len(got) != 1 {
+		t.Errorf("unexpected events %+v", got)
+	}
+	if got := next("error"); got[0].Error != "no question is waiting for an answer" {
+		t.Errorf("unexpected error %+v", got[0])
+	}
+
+	// Questions wait for an answer line
+	write(`{"type": "user", "text": "ask: Deploy now? | yes | no"}`)
+	got = next("question")
+	if question := got[len(got)-1]; question.Text != "Deploy now?" || len(question.Options) != 2 || question.ID == "" {
+		t.Errorf("unexpected question %+v", question)
+	}
+	// A message sent mid-turn waits for the turn to end
+	write(`{"type": "user", "text": "echo: after"}`)
+	write(`{"type": "answer", "text": "no"}`)
+	got = next("result")
+	for _, event := range got {
+		if event.Type == "tool_result" && event.Output != "no" {
+			t.Errorf("question wasn't answered: %+v", event)
+		}
+		if event.Text == "after" {
+			t.Errorf("message sent mid-turn was handled in the same turn: %+v", got)
+		}
+	}
+	if got := next("result"); got[0].Text != "after" {
+		t.Errorf("unexpected events %+v", got)
+	}
+
+	// Closing the input ends the session once the agent is idle
+	inW.Close()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-ctx.Done():
+		t.Fatal("session didn't end after the input closed")
+	}
+	for event := range events {
+		t.Errorf("unexpected event after the input closed: %+v", event)
+	}
+}
+
+// failingService is an LLM whose requests fail.
+type failingService struct{}
+
+func (failingService) Do(context.Context, *llm.Request) (*llm.Response, error) {
+	return nil, errors.New("boom")
+}
+func (failingService) TokenContextWindow() int { return 100000 }
+func (failingService) MaxImageDimension() int  { return 0 }
+
+func TestStdioSessionFailedTurn(t *testing.T) {
+	var buf bytes.Buffer
+	session := newStdioSession(&buf)
+	var err error
+	session.agent, err = agent.New(agent.Config{LLM: failingService{}, OnMessage: session.onMessage, Logger: slog.New(slog.DiscardHandler)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := session.serve(context.Background(), strings.NewReader(`{"type": "user", "text": "hi"}`)); err != nil {
+		t.Fatal(err)
+	}
+	var event stdioEvent
+	if err := json.Unmarshal(buf.Bytes(), &event); err != nil {
+		t.Fatalf("expected a single result event, got %q: %v", buf.String(), err)
+	}
+	if event.Type != "result" || !strings.Contains(event.Error, "boom") {
+		t.Errorf("unexpected event %+v", event)
+	}
+}
@@ -0,0 +1,92 @@
+package db
+
+import (
+	"context"
+	"fmt"
+
+	"shelley.exe.dev/db/compressed"
+	"shelley.exe.dev/db/generated"
+)
+
+// compressBatch is how many messages CompressMessages rewrites per
+// transaction, so that it doesn't hold up the other writers for long.
+const compressBatch = 100
+
+// CompressMessages compresses the LLM data of the messages stored before it
+// was compressed on write, returning how many it rewrote. The space freed
+// is reused by new rows; VACUUM gives it back to the file system.
+func (db *DB) CompressMessages(ctx context.Context) (int, error) {
+	var total int
+	after := ""
+	for {
+		var n int
+		err := db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+			q := generated.New(tx.Conn())
+			rows, err := q.ListUncompressedMessages(ctx, generated.ListUncompressedMessagesParams{
+				MessageID: after,
+				MinSize:   compressed.Threshold,
+				Limit:     compressBatch,
+			})
+			if err != nil {
+				return err
+			}
+			for _, row := range rows {
+				after = row.MessageID
+				err := q.UpdateMessageLLMData(ctx, generated.UpdateMessageLLMDataParams{LlmData: row.LlmData, MessageID: row.MessageID})
+				if err != nil {
+					return err
+				}
+			}
+			n = len(rows)
+			return nil
+		})
+		if err != nil {
+			return total, fmt.Errorf("failed to compress messages: %w", err)
+		}
+		total += n
+		if n < compressBatch {
+			return total, nil
+		}
+	}
+}
+
+// DecompressMessages stores the compressed LLM data of messages as plain
+// text again, returning how many it rewrote, so that builds from before
+// compression can read them. Serving compresses them again.
+func (db *DB) DecompressMessages(ctx context.Context) (int, error) {
+	var total int
+	after := ""
+	for {
+		var n int
+		err := db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+			q := generated.New(tx.Conn())
+			rows, err := q.ListCompressedMessages(ctx, generated.ListCompressedMessagesParams{
+				MessageID: after,
+				Limit:     compressBatch,
+			})
+			if err != nil {
+				return err
+			}
+			for _, row := range rows {
+				after = row.MessageID
+				var data string
+				if row.LlmData != nil {
+					data = string(*row.LlmData)
+				}
+				err := q.UpdateMessageLLMDataText(ctx, generated.UpdateMessageLLMDataTextParams{LlmData: data, MessageID: row.MessageID})
+				if err != nil {
+					return err
+				}
+			}
+			n = len(rows)
+			return nil
+		})
+		if err != nil {
+			return total, fmt.Errorf("failed to decompress messages: %w", err)
+		}
+		total += n
+		if n < compressBatch {
+			return total, nil
+		}
+	}
+}
@@ -0,0 +1,101 @@
+// Package compressed stores large text columns compressed.
+//
+// A Text is written as is below Threshold, and compressed with zstd above
+// it. It's stored compressed as a BLOB starting with a zero byte, which no
+// JSON text starts with, followed by a byte naming the codec; reading it back
+// decompresses it, so the rest of the code sees plain text either way.
+package compressed
+
+import (
+	"bytes"
+	"compress/flate"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Threshold is the size in bytes from which a Text is stored compressed.
+// Smaller values gain too little to be worth it.
+const Threshold = 4 << 10
+
+// Codecs, as the second byte of a compressed value names them. Values
+// written with deflate by earlier builds are still read.
+const (
+	codecDeflate = 0x01
+	codecZstd    = 0x02
+)
+
+// Text is a text column that's compressed in the database above Threshold.
+type Text string
+
+// The encoder and decoder are safe for concurrent use with EncodeAll and
+// DecodeAll.
+var (
+	encoder, _ = zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedDefault), zstd.WithEncoderConcurrency(1))
+	decoder, _ = zstd.NewReader(nil, zstd.WithDecoderConcurrency(0))
+)
+
+// Value implements driver.Valuer.
+func (t Text) Value() (driver.Value, error) {
+	if len(t) < Threshold {
+		return string(t), nil
+	}
+	data := encoder.EncodeAll([]byte(t), append(make([]byte, 0, len(t)/4), 0, codecZstd))
+	// Incompressible text stays as it is
+	if len(data) >= len(t) {
+		return string(t), nil
+	}
+	return data, nil
+}
+
+// Scan implements sql.Scanner.
+func (t *Text) Scan(src any) error {
+	switch src := src.(type) {
+	case nil:
+		*t = ""
+	case string:
+		*t = Text(src)
+	case []byte:
+		if !IsCompressed(src) {
+			*t = Text(src)
+			return nil
+		}
+		data, err := decompress(src[1], src[2:])
+		if err != nil {
+			return err
+		}
+		*t = Text(data)
+	default:
+		return errors.New("compressed: unsupported column type")
+	}
+	return nil
+}
+
+// decompress returns data, compressed with codec, decompressed.
+func decompress(codec byte, data []byte) ([]byte, error) {
+	switch codec {
+	case codecZstd:
+		data, err := decoder.DecodeAll(data, nil)
+		if err != nil {
+			return nil, fmt.Errorf("compressed: %w", err)
+		}
+		return data, nil
+	case codecDeflate:
+		r := flate.NewReader(bytes.NewReader(data))
+		defer r.Close()
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return nil, fmt.Errorf("compressed: %w", err)
+		}
+		return data, nil
+	}
+	return nil, fmt.Errorf("compressed: unknown codec %#x", codec)
+}
+
+// IsCompressed reports whether a stored value is compressed.
+func IsCompressed(data []byte) bool {
+	return len(data) >= 2 && data[0] == 0
+}
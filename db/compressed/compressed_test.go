@@ -0,0 +1,87 @@
+package compressed
+
+import (
+	"bytes"
+	"compress/flate"
+	"strings"
+	"testing"
+)
+
+func TestText(t *testing.T) {
+	large := Text(strings.Repeat(`{"Type":2,"Text":"output"}`, Threshold/10))
+	for _, tt := range []struct {
+		name       string
+		text       Text
+		compressed bool
+	}{
+		{"small", `{"Role":0}`, false},
+		{"large", large, true},
+		{"incompressible", Text(incompressible(Threshold)), false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := tt.text.Value()
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, isBytes := v.([]byte)
+			if isBytes != tt.compressed {
+				t.Fatalf("expected compressed %v, got %T", tt.compressed, v)
+			}
+			if isBytes && (!IsCompressed(data) || len(data) >= len(tt.text)/4) {
+				t.Errorf("expected %d bytes compressed well, got %d", len(tt.text), len(data))
+			}
+			if isBytes && data[1] != codecZstd {
+				t.Errorf("expected zstd, got codec %#x", data[1])
+			}
+
+			var got Text
+			if err := got.Scan(v); err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.text {
+				t.Errorf("expected the text back, got %d bytes", len(got))
+			}
+		})
+	}
+
+	var got Text
+	if err := got.Scan([]byte{0, 0x7f, 1}); err == nil {
+		t.Error("expected an unknown codec to be refused")
+	}
+	if err := got.Scan([]byte(`{"plain":true}`)); err != nil || got != `{"plain":true}` {
+		t.Errorf("expected plain bytes as they are, got %q, %v", got, err)
+	}
+}
+
+func TestTextDeflate(t *testing.T) {
+	text := Text(strings.Repeat(`{"Type":2,"Text":"output"}`, Threshold/10))
+	var buf bytes.Buffer
+	buf.Write([]byte{0, codecDeflate})
+	w, err := flate.NewWriter(&buf, flate.BestSpeed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte(text))
+	w.Close()
+
+	var got Text
+	if err := got.Scan(buf.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	if got != text {
+		t.Errorf("expected the text stored with deflate back, got %d bytes", len(got))
+	}
+}
+
+// incompressible returns n bytes that don't compress.
+func incompressible(n int) string {
+	b := make([]byte, n)
+	x := uint32(2463534242)
+	for i := range b {
+		x ^= x << 13
+		x ^= x >> 17
+		x ^= x << 5
+		b[i] = byte(x >> 24)
+	}
+	return string(b)
+}
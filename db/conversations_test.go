@@ -163,6 +163,67 @@ func TestConversationService_List(t *testing.T) {
 	// may be identical, so we just verify we got the expected count
 }
 
+func TestConversationService_Pinned(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var ids []string
+	for _, slug := range []string{"pin-a", "pin-b", "pin-c"} {
+		conv, err := db.CreateConversation(ctx, stringPtr(slug), true, nil, nil, nil)
+		if err != nil {
+			t.Fatalf("Failed to create test conversation %s: %v", slug, err)
+		}
+		ids = append(ids, conv.ConversationID)
+	}
+
+	// Pin the oldest conversation; it should be listed first
+	if _, err := db.SetConversationPinned(ctx, ids[0], true); err != nil {
+		t.Fatalf("SetConversationPinned() error = %v", err)
+	}
+	// Make the others newer
+	err := db.Pool().Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		_, err := tx.Exec("UPDATE conversations SET updated_at = datetime('now', '+1 hour') WHERE conversation_id != ?", ids[0])
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Failed to bump updated_at: %v", err)
+	}
+
+	conversations, err := db.ListConversations(ctx, 10, 0)
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(conversations) != 3 || conversations[0].ConversationID != ids[0] || !conversations[0].Pinned {
+		t.Errorf("Expected pinned conversation first, got %+v", conversations)
+	}
+
+	results, err := db.SearchConversations(ctx, "pin-", 10, 0)
+	if err != nil {
+		t.Fatalf("Search() error = %v", err)
+	}
+	if len(results) != 3 || results[0].ConversationID != ids[0] {
+		t.Errorf("Expected pinned conversation first in search results")
+	}
+
+	unpinned, err := db.SetConversationPinned(ctx, ids[0], false)
+	if err != nil {
+		t.Fatalf("SetConversationPinned() error = %v", err)
+	}
+	if unpinned.Pinned {
+		t.Error("Expected conversation to be unpinned")
+	}
+	conversations, err = db.ListConversations(ctx, 10, 0)
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if conversations[len(conversations)-1].ConversationID != ids[0] {
+		t.Errorf("Expected unpinned conversation to sort by updated_at")
+	}
+}
+
 func TestConversationService_Search(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.Close()
@@ -372,3 +433,56 @@ func TestConversationService_SlugUniquenessWhenNotNull(t *testing.T) {
 		t.Errorf("Expected UNIQUE constraint error, got: %v", err)
 	}
 }
+
+func TestConversationLeases(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	ctx := context.Background()
+	conv, err := db.CreateConversation(ctx, nil, true, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := conv.ConversationID
+
+	acquire := func(owner string, ttl time.Duration) bool {
+		t.Helper()
+		ok, err := db.AcquireConversationLease(ctx, id, owner, ttl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ok
+	}
+	if !acquire("a", time.Minute) {
+		t.Fatal("expected a free conversation leased")
+	}
+	if !acquire("a", time.Minute) {
+		t.Error("expected the owner to renew its lease")
+	}
+	if acquire("b", time.Minute) {
+		t.Error("expected another owner refused while the lease lasts")
+	}
+	if lease, err := db.GetConversationLease(ctx, id); err != nil || lease.Owner != "a" {
+		t.Errorf("expected a to hold the lease, got %+v, %v", lease, err)
+	}
+
+	// Releasing someone else's lease does nothing
+	if err := db.ReleaseConversationLease(ctx, id, "b"); err != nil || acquire("b", time.Minute) {
+		t.Errorf("expected b unable to release a's lease (%v)", err)
+	}
+	if err := db.ReleaseOwnerLeases(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+	if !acquire("b", -time.Second) {
+		t.Error("expected a released lease taken")
+	}
+	// b's lease has expired
+	if !acquire("a", time.Minute) {
+		t.Error("expected an expired lease taken over")
+	}
+	if err := db.ReleaseConversationLease(ctx, id, "a"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.GetConversationLease(ctx, id); err == nil {
+		t.Error("expected no lease once released")
+	}
+}
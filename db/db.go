@@ -9,17 +9,16 @@ import (
 	"database/sql"
 	"embed"
 	"encoding/json"
-	"errors"
 	"fmt"
-	"log/slog"
+	"math"
 	"os"
 	"path/filepath"
-	"regexp"
-	"sort"
-	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/google/uuid"
+	"shelley.exe.dev/db/compressed"
 	"shelley.exe.dev/db/generated"
 
 	_ "github.com/tursodatabase/go-libsql"
@@ -41,11 +40,15 @@ func generateConversationID() (string, error) {
 // DB wraps the database connection pool and provides high-level operations
 type DB struct {
 	pool *Pool
+	// vectorIndexes holds the dimensions EnsureEmbeddingIndex has created
+	// the vector index of.
+	vectorIndexes sync.Map
 }
 
 // Config holds database configuration
 type Config struct {
 	DSN string // Data Source Name for SQLite database
+	PoolConfig
 }
 
 // New creates a new database connection with the given configuration
@@ -66,7 +69,6 @@ func New(cfg Config) (*DB, error) {
 		}
 	}
 
-	// Create connection pool with 10 readers for multi-pane UI support
 	// libSQL requires file: prefix for local files
 	dsn := cfg.DSN
 	if !strings.HasPrefix(dsn, "file:") && !strings.HasPrefix(dsn, "libsql:") && !strings.HasPrefix(dsn, "http") {
@@ -78,7 +80,7 @@ func New(cfg Config) (*DB, error) {
 		dsn += "&_foreign_keys=on"
 	}
 
-	pool, err := NewPool(dsn, 10)
+	pool, err := NewPool(dsn, cfg.PoolConfig)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create connection pool: %w", err)
 	}
@@ -95,89 +97,7 @@ func (db *DB) Close() error {
 
 // Migrate runs the database migrations
 func (db *DB) Migrate(ctx context.Context) error {
-	// Read all migration files
-	entries, err := schemaFS.ReadDir("schema")
-	if err != nil {
-		return fmt.Errorf("failed to read schema directory: %w", err)
-	}
-
-	// Filter and validate migration files
-	var migrations []string
-	migrationPattern := regexp.MustCompile(`^(\d{3})-.*\.sql$`)
-	for _, entry := range entries {
-		if entry.IsDir() {
-			continue
-		}
-		if !migrationPattern.MatchString(entry.Name()) {
-			continue
-		}
-		migrations = append(migrations, entry.Name())
-	}
-
-	// Sort migrations by number
-	sort.Strings(migrations)
-
-	// Get executed migrations
-	executedMigrations := make(map[int]bool)
-	var tableName string
-	err = db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
-		row := rx.QueryRow("SELECT name FROM sqlite_master WHERE type='table' AND name='migrations'")
-		return row.Scan(&tableName)
-	})
-
-	if err == nil {
-		// Migrations table exists, load executed migrations
-		err = db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
-			rows, err := rx.Query("SELECT migration_number FROM migrations")
-			if err != nil {
-				return fmt.Errorf("failed to query executed migrations: %w", err)
-			}
-			defer rows.Close()
-
-			for rows.Next() {
-				var migrationNumber int
-				if err := rows.Scan(&migrationNumber); err != nil {
-					return fmt.Errorf("failed to scan migration number: %w", err)
-				}
-				executedMigrations[migrationNumber] = true
-			}
-			return rows.Err()
-		})
-		if err != nil {
-			return fmt.Errorf("failed to load executed migrations: %w", err)
-		}
-	} else if !errors.Is(err, sql.ErrNoRows) {
-		// Migrations table doesn't exist - executedMigrations remains empty
-		slog.Info("migrations table not found, running all migrations")
-	}
-
-	// Run any migrations that haven't been executed
-	for _, migration := range migrations {
-		// Extract migration number from filename (e.g., "001-base.sql" -> 001)
-		matches := migrationPattern.FindStringSubmatch(migration)
-		if len(matches) != 2 {
-			return fmt.Errorf("invalid migration filename format: %s", migration)
-		}
-
-		migrationNumber, err := strconv.Atoi(matches[1])
-		if err != nil {
-			return fmt.Errorf("failed to parse migration number from %s: %w", migration, err)
-		}
-
-		if !executedMigrations[migrationNumber] {
-			slog.Info("running migration", "file", migration, "number", migrationNumber)
-			if err := db.executeMigration(ctx, migration); err != nil {
-				return fmt.Errorf("failed to execute migration %s: %w", migration, err)
-			}
-
-			err = db.pool.Exec(ctx, "INSERT INTO migrations (migration_number, migration_name) VALUES (?, ?)", migrationNumber, migration)
-			if err != nil {
-				return fmt.Errorf("failed to record migration %s in migrations table: %w", migration, err)
-			}
-		}
-	}
-
-	return nil
+	return db.MigrateUp(ctx, math.MaxInt)
 }
 
 // executeMigration executes a single migration file
@@ -354,6 +274,124 @@ func (db *DB) UpdateConversationCwd(ctx context.Context, conversationID, cwd str
 	})
 }
 
+// UpdateConversationUserID records the user who started a conversation
+func (db *DB) UpdateConversationUserID(ctx context.Context, conversationID, userID string) error {
+	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		return q.UpdateConversationUserID(ctx, generated.UpdateConversationUserIDParams{
+			UserID:         &userID,
+			ConversationID: conversationID,
+		})
+	})
+}
+
+// UpdateConversationSandbox records a conversation's sandbox options (JSON)
+func (db *DB) UpdateConversationSandbox(ctx context.Context, conversationID, sandbox string) error {
+	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		return q.UpdateConversationSandbox(ctx, generated.UpdateConversationSandboxParams{
+			Sandbox:        &sandbox,
+			ConversationID: conversationID,
+		})
+	})
+}
+
+// UpdateConversationRemote records the SSH host a conversation's tools run on (JSON)
+func (db *DB) UpdateConversationRemote(ctx context.Context, conversationID, remote string) error {
+	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		return q.UpdateConversationRemote(ctx, generated.UpdateConversationRemoteParams{
+			Remote:         &remote,
+			ConversationID: conversationID,
+		})
+	})
+}
+
+// UpdateConversationDevcontainer records the workspace folder whose dev
+// container a conversation's tools run in
+func (db *DB) UpdateConversationDevcontainer(ctx context.Context, conversationID, folder string) error {
+	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		return q.UpdateConversationDevcontainer(ctx, generated.UpdateConversationDevcontainerParams{
+			Devcontainer:   &folder,
+			ConversationID: conversationID,
+		})
+	})
+}
+
+// UpdateConversationProject records the project a conversation belongs to
+func (db *DB) UpdateConversationProject(ctx context.Context, conversationID, projectID string) error {
+	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		return q.UpdateConversationProject(ctx, generated.UpdateConversationProjectParams{
+			ProjectID:      &projectID,
+			ConversationID: conversationID,
+		})
+	})
+}
+
+// UpdateConversationGuidanceFiles records the guidance files loaded into a
+// conversation's system prompt (JSON array)
+func (db *DB) UpdateConversationGuidanceFiles(ctx context.Context, conversationID, files string) error {
+	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		return q.UpdateConversationGuidanceFiles(ctx, generated.UpdateConversationGuidanceFilesParams{
+			GuidanceFiles:  &files,
+			ConversationID: conversationID,
+		})
+	})
+}
+
+// UpdateConversationAllowedTools records the tools a conversation may use
+// (JSON array), or clears the restriction if allowedTools is nil
+func (db *DB) UpdateConversationAllowedTools(ctx context.Context, conversationID string, allowedTools *string) error {
+	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		return q.UpdateConversationAllowedTools(ctx, generated.UpdateConversationAllowedToolsParams{
+			AllowedTools:   allowedTools,
+			ConversationID: conversationID,
+		})
+	})
+}
+
+// UpdateConversationNotifyEvents records the events to send notifications
+// about for a conversation (JSON array), or clears the override if
+// notifyEvents is nil
+func (db *DB) UpdateConversationNotifyEvents(ctx context.Context, conversationID string, notifyEvents *string) error {
+	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		return q.UpdateConversationNotifyEvents(ctx, generated.UpdateConversationNotifyEventsParams{
+			NotifyEvents:   notifyEvents,
+			ConversationID: conversationID,
+		})
+	})
+}
+
+// UpdateConversationSampling records the sampling parameters of a
+// conversation's LLM requests (JSON object), or clears them if sampling is
+// nil
+func (db *DB) UpdateConversationSampling(ctx context.Context, conversationID string, sampling *string) error {
+	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		return q.UpdateConversationSampling(ctx, generated.UpdateConversationSamplingParams{
+			Sampling:       sampling,
+			ConversationID: conversationID,
+		})
+	})
+}
+
+// UpdateConversationThinking records the reasoning asked of a
+// conversation's models (JSON object), or clears it if thinking is nil
+func (db *DB) UpdateConversationThinking(ctx context.Context, conversationID string, thinking *string) error {
+	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		return q.UpdateConversationThinking(ctx, generated.UpdateConversationThinkingParams{
+			Thinking:       thinking,
+			ConversationID: conversationID,
+		})
+	})
+}
+
 // UpdateConversationCwdAndGitOrigin updates both the working directory and git origin for a conversation
 func (db *DB) UpdateConversationCwdAndGitOrigin(ctx context.Context, conversationID, cwd, gitOrigin string) error {
 	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
@@ -383,6 +421,7 @@ const (
 	MessageTypeSystem  MessageType = "system"
 	MessageTypeError   MessageType = "error"
 	MessageTypeGitInfo MessageType = "gitinfo" // user-visible only, not sent to LLM
+	MessageTypeSummary MessageType = "summary" // replaces all earlier messages when sent to LLM
 )
 
 // CreateMessageParams contains parameters for creating a message
@@ -395,26 +434,21 @@ type CreateMessageParams struct {
 	DisplayData    interface{} // Will be JSON marshalled, tool-specific display content
 }
 
-// CreateMessage creates a new message
-func (db *DB) CreateMessage(ctx context.Context, params CreateMessageParams) (*generated.Message, error) {
-	messageID := uuid.New().String()
-
-	// Marshal JSON fields
-	var llmDataJSON, userDataJSON, usageDataJSON, displayDataJSON *string
-
+// marshal returns the message's JSON fields.
+func (params CreateMessageParams) marshal() (llmDataJSON *compressed.Text, userDataJSON, usageDataJSON, displayDataJSON *string, err error) {
 	if params.LLMData != nil {
 		data, err := json.Marshal(params.LLMData)
 		if err != nil {
-			return nil, fmt.Errorf("failed to marshal LLM data: %w", err)
+			return nil, nil, nil, nil, fmt.Errorf("failed to marshal LLM data: %w", err)
 		}
-		str := string(data)
+		str := compressed.Text(data)
 		llmDataJSON = &str
 	}
 
 	if params.UserData != nil {
 		data, err := json.Marshal(params.UserData)
 		if err != nil {
-			return nil, fmt.Errorf("failed to marshal user data: %w", err)
+			return nil, nil, nil, nil, fmt.Errorf("failed to marshal user data: %w", err)
 		}
 		str := string(data)
 		userDataJSON = &str
@@ -423,7 +457,7 @@ func (db *DB) CreateMessage(ctx context.Context, params CreateMessageParams) (*g
 	if params.UsageData != nil {
 		data, err := json.Marshal(params.UsageData)
 		if err != nil {
-			return nil, fmt.Errorf("failed to marshal usage data: %w", err)
+			return nil, nil, nil, nil, fmt.Errorf("failed to marshal usage data: %w", err)
 		}
 		str := string(data)
 		usageDataJSON = &str
@@ -432,14 +466,25 @@ func (db *DB) CreateMessage(ctx context.Context, params CreateMessageParams) (*g
 	if params.DisplayData != nil {
 		data, err := json.Marshal(params.DisplayData)
 		if err != nil {
-			return nil, fmt.Errorf("failed to marshal display data: %w", err)
+			return nil, nil, nil, nil, fmt.Errorf("failed to marshal display data: %w", err)
 		}
 		str := string(data)
 		displayDataJSON = &str
 	}
+	return llmDataJSON, userDataJSON, usageDataJSON, displayDataJSON, nil
+}
+
+// CreateMessage creates a new message
+func (db *DB) CreateMessage(ctx context.Context, params CreateMessageParams) (*generated.Message, error) {
+	messageID := uuid.New().String()
+
+	llmDataJSON, userDataJSON, usageDataJSON, displayDataJSON, err := params.marshal()
+	if err != nil {
+		return nil, err
+	}
 
 	var message generated.Message
-	err := db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+	err = db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
 		q := generated.New(tx.Conn())
 
 		// Get next sequence_id for this conversation
@@ -458,11 +503,67 @@ func (db *DB) CreateMessage(ctx context.Context, params CreateMessageParams) (*g
 			UsageData:      usageDataJSON,
 			DisplayData:    displayDataJSON,
 		})
-		return err
+		if err != nil {
+			return err
+		}
+		return setUploadRefs(ctx, q, messageID, uploadIDs((*string)(llmDataJSON), userDataJSON, displayDataJSON))
+	})
+	return &message, err
+}
+
+// ReplaceMessage replaces the contents of a message and moves it to the
+// end of its conversation, for a message whose row was created before it
+// was complete.
+func (db *DB) ReplaceMessage(ctx context.Context, messageID string, params CreateMessageParams) (*generated.Message, error) {
+	llmDataJSON, userDataJSON, usageDataJSON, displayDataJSON, err := params.marshal()
+	if err != nil {
+		return nil, err
+	}
+
+	var message generated.Message
+	err = db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		sequenceID, err := q.GetNextSequenceID(ctx, params.ConversationID)
+		if err != nil {
+			return fmt.Errorf("failed to get next sequence ID: %w", err)
+		}
+		message, err = q.ReplaceMessage(ctx, generated.ReplaceMessageParams{
+			SequenceID:  sequenceID,
+			Type:        string(params.Type),
+			LlmData:     llmDataJSON,
+			UserData:    userDataJSON,
+			UsageData:   usageDataJSON,
+			DisplayData: displayDataJSON,
+			MessageID:   messageID,
+		})
+		if err != nil {
+			return err
+		}
+		return setUploadRefs(ctx, q, messageID, uploadIDs((*string)(llmDataJSON), userDataJSON, displayDataJSON))
 	})
 	return &message, err
 }
 
+// UpdateMessageLLMData replaces the LLM data of a message.
+func (db *DB) UpdateMessageLLMData(ctx context.Context, messageID string, llmData any) error {
+	data, err := json.Marshal(llmData)
+	if err != nil {
+		return fmt.Errorf("failed to marshal LLM data: %w", err)
+	}
+	str := compressed.Text(data)
+	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		message, err := q.GetMessage(ctx, messageID)
+		if err != nil {
+			return err
+		}
+		if err := q.UpdateMessageLLMData(ctx, generated.UpdateMessageLLMDataParams{LlmData: &str, MessageID: messageID}); err != nil {
+			return err
+		}
+		return setUploadRefs(ctx, q, messageID, uploadIDs((*string)(&str), message.UserData, message.DisplayData))
+	})
+}
+
 // GetMessageByID retrieves a message by its ID
 func (db *DB) GetMessageByID(ctx context.Context, messageID string) (*generated.Message, error) {
 	var message generated.Message
@@ -611,10 +712,29 @@ func (db *DB) UnarchiveConversation(ctx context.Context, conversationID string)
 	return &conversation, err
 }
 
+// SetConversationPinned pins or unpins a conversation. Pinned conversations
+// are listed before the rest.
+func (db *DB) SetConversationPinned(ctx context.Context, conversationID string, pinned bool) (*generated.Conversation, error) {
+	var conversation generated.Conversation
+	err := db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		var err error
+		conversation, err = q.UpdateConversationPinned(ctx, generated.UpdateConversationPinnedParams{
+			Pinned:         pinned,
+			ConversationID: conversationID,
+		})
+		return err
+	})
+	return &conversation, err
+}
+
 // DeleteConversation deletes a conversation and all its messages
 func (db *DB) DeleteConversation(ctx context.Context, conversationID string) error {
 	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
 		q := generated.New(tx.Conn())
+		if err := q.DeleteConversationEmbeddings(ctx, &conversationID); err != nil {
+			return fmt.Errorf("failed to delete embeddings: %w", err)
+		}
 		// Delete messages first (foreign key constraint)
 		if err := q.DeleteConversationMessages(ctx, conversationID); err != nil {
 			return fmt.Errorf("failed to delete messages: %w", err)
@@ -622,3 +742,930 @@ func (db *DB) DeleteConversation(ctx context.Context, conversationID string) err
 		return q.DeleteConversation(ctx, conversationID)
 	})
 }
+
+// RollbackConversation soft-deletes every message after sequenceID, along with
+// their checkpoints and embeddings, leaving the conversation idle.
+func (db *DB) RollbackConversation(ctx context.Context, conversationID string, sequenceID int64) error {
+	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		if err := q.SoftDeleteMessagesAfter(ctx, generated.SoftDeleteMessagesAfterParams{
+			ConversationID: conversationID,
+			SequenceID:     sequenceID,
+		}); err != nil {
+			return fmt.Errorf("failed to delete messages: %w", err)
+		}
+		if err := q.DeleteCheckpointsAfter(ctx, generated.DeleteCheckpointsAfterParams{
+			ConversationID: conversationID,
+			SequenceID:     sequenceID,
+		}); err != nil {
+			return fmt.Errorf("failed to delete checkpoints: %w", err)
+		}
+		if err := q.DeleteDeletedMessageEmbeddings(ctx, conversationID); err != nil {
+			return fmt.Errorf("failed to delete embeddings: %w", err)
+		}
+		return q.UpdateConversationAgentWorking(ctx, generated.UpdateConversationAgentWorkingParams{
+			AgentWorking:   false,
+			ConversationID: conversationID,
+		})
+	})
+}
+
+// DeleteMessage soft-deletes a single message, along with its embeddings.
+func (db *DB) DeleteMessage(ctx context.Context, conversationID string, sequenceID int64) error {
+	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		if err := q.SoftDeleteMessage(ctx, generated.SoftDeleteMessageParams{
+			ConversationID: conversationID,
+			SequenceID:     sequenceID,
+		}); err != nil {
+			return fmt.Errorf("failed to delete message: %w", err)
+		}
+		if err := q.DeleteDeletedMessageEmbeddings(ctx, conversationID); err != nil {
+			return fmt.Errorf("failed to delete embeddings: %w", err)
+		}
+		return nil
+	})
+}
+
+// Memory methods
+
+// MemorySource records who saved a memory
+type MemorySource string
+
+const (
+	MemorySourceAgent MemorySource = "agent"
+	MemorySourceUser  MemorySource = "user"
+)
+
+func generateMemoryID() (string, error) {
+	text := rand.Text()
+	if len(text) < 8 {
+		return "", fmt.Errorf("rand.Text() returned insufficient characters: %d", len(text))
+	}
+	return "m" + text[:8], nil
+}
+
+// CreateMemory saves a memory for a project. conversationID may be nil.
+func (db *DB) CreateMemory(ctx context.Context, project, content string, source MemorySource, conversationID *string) (*generated.Memory, error) {
+	memoryID, err := generateMemoryID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate memory ID: %w", err)
+	}
+	var memory generated.Memory
+	err = db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		memory, err = q.CreateMemory(ctx, generated.CreateMemoryParams{
+			MemoryID:       memoryID,
+			Project:        project,
+			Content:        content,
+			Source:         string(source),
+			ConversationID: conversationID,
+		})
+		return err
+	})
+	return &memory, err
+}
+
+// ListMemories retrieves memories, limited to a project unless project is empty
+func (db *DB) ListMemories(ctx context.Context, project string) ([]generated.Memory, error) {
+	var memories []generated.Memory
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		var err error
+		if project == "" {
+			memories, err = q.ListMemories(ctx)
+		} else {
+			memories, err = q.ListMemoriesByProject(ctx, project)
+		}
+		return err
+	})
+	return memories, err
+}
+
+// UpdateMemory replaces the content of a memory
+func (db *DB) UpdateMemory(ctx context.Context, memoryID, content string) (*generated.Memory, error) {
+	var memory generated.Memory
+	err := db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		var err error
+		memory, err = q.UpdateMemory(ctx, generated.UpdateMemoryParams{
+			Content:  content,
+			MemoryID: memoryID,
+		})
+		if err != nil {
+			return err
+		}
+		return q.DeleteSourceEmbeddings(ctx, generated.DeleteSourceEmbeddingsParams{
+			SourceType: string(EmbeddingSourceMemory),
+			SourceID:   memoryID,
+		})
+	})
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("memory not found: %s: %w", memoryID, err)
+	}
+	return &memory, err
+}
+
+// DeleteMemory deletes a memory
+func (db *DB) DeleteMemory(ctx context.Context, memoryID string) error {
+	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		if err := q.DeleteSourceEmbeddings(ctx, generated.DeleteSourceEmbeddingsParams{
+			SourceType: string(EmbeddingSourceMemory),
+			SourceID:   memoryID,
+		}); err != nil {
+			return fmt.Errorf("failed to delete embeddings: %w", err)
+		}
+		return q.DeleteMemory(ctx, memoryID)
+	})
+}
+
+// Project methods
+
+// ProjectParams contains the settings of a project
+type ProjectParams struct {
+	Name     string
+	RootPath string
+	// DefaultModel, AllowedTools (a JSON array) and GitOrigin may be nil.
+	DefaultModel *string
+	AllowedTools *string
+	GitOrigin    *string
+}
+
+// CreateProject creates a project
+func (db *DB) CreateProject(ctx context.Context, params ProjectParams) (*generated.Project, error) {
+	text := rand.Text()
+	if len(text) < 8 {
+		return nil, fmt.Errorf("rand.Text() returned insufficient characters: %d", len(text))
+	}
+	var project generated.Project
+	err := db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		var err error
+		project, err = q.CreateProject(ctx, generated.CreateProjectParams{
+			ProjectID:    "p" + text[:8],
+			Name:         params.Name,
+			RootPath:     params.RootPath,
+			DefaultModel: params.DefaultModel,
+			AllowedTools: params.AllowedTools,
+			GitOrigin:    params.GitOrigin,
+		})
+		return err
+	})
+	return &project, err
+}
+
+// GetProject retrieves a project by ID
+func (db *DB) GetProject(ctx context.Context, projectID string) (*generated.Project, error) {
+	var project generated.Project
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		var err error
+		project, err = q.GetProject(ctx, projectID)
+		return err
+	})
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("project not found: %s: %w", projectID, err)
+	}
+	return &project, err
+}
+
+// ListProjects retrieves all projects, ordered by name
+func (db *DB) ListProjects(ctx context.Context) ([]generated.Project, error) {
+	var projects []generated.Project
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		var err error
+		projects, err = q.ListProjects(ctx)
+		return err
+	})
+	return projects, err
+}
+
+// UpdateProject replaces the settings of a project
+func (db *DB) UpdateProject(ctx context.Context, projectID string, params ProjectParams) (*generated.Project, error) {
+	var project generated.Project
+	err := db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		var err error
+		project, err = q.UpdateProject(ctx, generated.UpdateProjectParams{
+			Name:         params.Name,
+			RootPath:     params.RootPath,
+			DefaultModel: params.DefaultModel,
+			AllowedTools: params.AllowedTools,
+			GitOrigin:    params.GitOrigin,
+			ProjectID:    projectID,
+		})
+		return err
+	})
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("project not found: %s: %w", projectID, err)
+	}
+	return &project, err
+}
+
+// DeleteProject deletes a project. Its conversations are kept, without a project.
+func (db *DB) DeleteProject(ctx context.Context, projectID string) error {
+	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		if err := q.ClearConversationsProject(ctx, &projectID); err != nil {
+			return fmt.Errorf("failed to detach conversations: %w", err)
+		}
+		return q.DeleteProject(ctx, projectID)
+	})
+}
+
+// EmbeddingSource identifies what an embedding was computed from
+type EmbeddingSource string
+
+const (
+	EmbeddingSourceMessage EmbeddingSource = "message"
+	EmbeddingSourceMemory  EmbeddingSource = "memory"
+)
+
+// File edit methods
+
+// FileEditSource records what made a file edit
+type FileEditSource string
+
+const (
+	FileEditSourcePatch     FileEditSource = "patch"
+	FileEditSourceWriteFile FileEditSource = "write_file"
+	FileEditSourceBash      FileEditSource = "bash"
+)
+
+// CreateFileEditParams contains parameters for recording a file edit
+type CreateFileEditParams struct {
+	ConversationID *string
+	ToolUseID      *string
+	Source         FileEditSource
+	Path           string
+	// Before is nil if the edit created the file.
+	Before []byte
+	// After is nil if the edit deleted the file.
+	After []byte
+}
+
+// CreateFileEdit records a file edit. Edits made in a conversation are placed
+// after the conversation's latest message.
+func (db *DB) CreateFileEdit(ctx context.Context, params CreateFileEditParams) (*generated.FileEdit, error) {
+	text := rand.Text()
+	if len(text) < 8 {
+		return nil, fmt.Errorf("rand.Text() returned insufficient characters: %d", len(text))
+	}
+	before, after := params.Before, params.After
+	if before == nil {
+		before = []byte{}
+	}
+	if after == nil {
+		after = []byte{}
+	}
+	var edit generated.FileEdit
+	err := db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		var sequenceID *int64
+		if params.ConversationID != nil {
+			latest, err := q.GetLatestMessage(ctx, *params.ConversationID)
+			if err != nil && err != sql.ErrNoRows {
+				return fmt.Errorf("failed to get latest message: %w", err)
+			}
+			if err == nil {
+				sequenceID = &latest.SequenceID
+			}
+		}
+		var err error
+		edit, err = q.CreateFileEdit(ctx, generated.CreateFileEditParams{
+			EditID:         "e" + text[:8],
+			ConversationID: params.ConversationID,
+			SequenceID:     sequenceID,
+			ToolUseID:      params.ToolUseID,
+			Source:         string(params.Source),
+			Path:           params.Path,
+			NewFile:        params.Before == nil,
+			DeletedFile:    params.After == nil,
+			BeforeContent:  before,
+			AfterContent:   after,
+		})
+		return err
+	})
+	return &edit, err
+}
+
+// Share methods
+
+// CreateConversationShare mints a share token for a conversation. userID may be nil.
+func (db *DB) CreateConversationShare(ctx context.Context, conversationID string, userID *string) (*generated.ConversationShare, error) {
+	token := rand.Text()
+	var share generated.ConversationShare
+	err := db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		var err error
+		share, err = q.CreateConversationShare(ctx, generated.CreateConversationShareParams{
+			Token:          token,
+			ConversationID: conversationID,
+			UserID:         userID,
+		})
+		return err
+	})
+	return &share, err
+}
+
+// GetConversationShare looks up a share by its token
+func (db *DB) GetConversationShare(ctx context.Context, token string) (*generated.ConversationShare, error) {
+	var share generated.ConversationShare
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		var err error
+		share, err = q.GetConversationShare(ctx, token)
+		return err
+	})
+	return &share, err
+}
+
+// ListConversationShares lists a conversation's shares, newest first
+func (db *DB) ListConversationShares(ctx context.Context, conversationID string) ([]generated.ConversationShare, error) {
+	var shares []generated.ConversationShare
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		var err error
+		shares, err = q.ListConversationShares(ctx, conversationID)
+		return err
+	})
+	return shares, err
+}
+
+// RevokeConversationShare deletes a conversation's share, returning
+// sql.ErrNoRows if the conversation has no share with that token.
+func (db *DB) RevokeConversationShare(ctx context.Context, conversationID, token string) error {
+	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		n, err := q.DeleteConversationShare(ctx, generated.DeleteConversationShareParams{
+			Token:          token,
+			ConversationID: conversationID,
+		})
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return sql.ErrNoRows
+		}
+		return nil
+	})
+}
+
+// Bridge methods
+
+// GetBridgeThread returns the mapping for a chat platform thread, or
+// sql.ErrNoRows if the thread has no conversation yet.
+func (db *DB) GetBridgeThread(ctx context.Context, platform, threadID string) (*generated.BridgeThread, error) {
+	var thread generated.BridgeThread
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		var err error
+		thread, err = q.GetBridgeThread(ctx, generated.GetBridgeThreadParams{
+			Platform: platform,
+			ThreadID: threadID,
+		})
+		return err
+	})
+	return &thread, err
+}
+
+// GetBridgeThreadByConversation returns the thread a conversation was
+// started from, or sql.ErrNoRows if it wasn't started from a chat platform.
+func (db *DB) GetBridgeThreadByConversation(ctx context.Context, conversationID string) (*generated.BridgeThread, error) {
+	var thread generated.BridgeThread
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		var err error
+		thread, err = q.GetBridgeThreadByConversation(ctx, conversationID)
+		return err
+	})
+	return &thread, err
+}
+
+// CreateBridgeThread maps a chat platform thread to a conversation.
+func (db *DB) CreateBridgeThread(ctx context.Context, platform, threadID, conversationID string) (*generated.BridgeThread, error) {
+	var thread generated.BridgeThread
+	err := db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		var err error
+		thread, err = q.CreateBridgeThread(ctx, generated.CreateBridgeThreadParams{
+			Platform:       platform,
+			ThreadID:       threadID,
+			ConversationID: conversationID,
+		})
+		return err
+	})
+	return &thread, err
+}
+
+// DeleteBridgeThread unmaps a chat platform thread, so its next message
+// starts a new conversation. The conversation itself is kept.
+func (db *DB) DeleteBridgeThread(ctx context.Context, platform, threadID string) error {
+	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		return q.DeleteBridgeThread(ctx, generated.DeleteBridgeThreadParams{
+			Platform: platform,
+			ThreadID: threadID,
+		})
+	})
+}
+
+// CI auto-fix methods
+
+// SetCIAutofix turns auto-fix on for a conversation's pull request,
+// starting its attempts and budget over if it was already on.
+func (db *DB) SetCIAutofix(ctx context.Context, conversationID, prURL string, maxAttempts int64, maxCostUSD float64) (*generated.CiAutofix, error) {
+	var autofix generated.CiAutofix
+	err := db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		var err error
+		autofix, err = q.SetCIAutofix(ctx, generated.SetCIAutofixParams{
+			ConversationID: conversationID,
+			PrUrl:          prURL,
+			MaxAttempts:    maxAttempts,
+			MaxCostUsd:     maxCostUSD,
+		})
+		return err
+	})
+	return &autofix, err
+}
+
+// GetCIAutofix returns a conversation's auto-fix state, or sql.ErrNoRows if
+// auto-fix was never turned on.
+func (db *DB) GetCIAutofix(ctx context.Context, conversationID string) (*generated.CiAutofix, error) {
+	var autofix generated.CiAutofix
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		var err error
+		autofix, err = q.GetCIAutofix(ctx, conversationID)
+		return err
+	})
+	return &autofix, err
+}
+
+// ListWatchingCIAutofix lists the conversations still watching their pull
+// requests' checks.
+func (db *DB) ListWatchingCIAutofix(ctx context.Context) ([]generated.CiAutofix, error) {
+	var autofixes []generated.CiAutofix
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		var err error
+		autofixes, err = q.ListWatchingCIAutofix(ctx)
+		return err
+	})
+	return autofixes, err
+}
+
+// UpdateCIAutofix records a conversation's auto-fix attempts, the head
+// commit last sent to the agent, and its status.
+func (db *DB) UpdateCIAutofix(ctx context.Context, conversationID string, attempts int64, lastSHA, status string) error {
+	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		return q.UpdateCIAutofix(ctx, generated.UpdateCIAutofixParams{
+			Attempts:       attempts,
+			LastSha:        lastSHA,
+			Status:         status,
+			ConversationID: conversationID,
+		})
+	})
+}
+
+// DeleteCIAutofix turns auto-fix off for a conversation.
+func (db *DB) DeleteCIAutofix(ctx context.Context, conversationID string) error {
+	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		return q.DeleteCIAutofix(ctx, conversationID)
+	})
+}
+
+// GetCIAutofixCost returns what a conversation has cost since auto-fix was
+// turned on, in US dollars.
+func (db *DB) GetCIAutofixCost(ctx context.Context, conversationID string) (float64, error) {
+	var cost float64
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		var err error
+		cost, err = q.GetCIAutofixCost(ctx, conversationID)
+		return err
+	})
+	return cost, err
+}
+
+// Conversation lease methods
+
+// AcquireConversationLease takes or renews owner's lease of a conversation
+// for ttl. It reports false if another owner holds a lease that hasn't
+// expired.
+func (db *DB) AcquireConversationLease(ctx context.Context, conversationID, owner string, ttl time.Duration) (bool, error) {
+	var acquired bool
+	err := db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		now := time.Now()
+		n, err := q.AcquireConversationLease(ctx, generated.AcquireConversationLeaseParams{
+			ConversationID: conversationID,
+			Owner:          owner,
+			ExpiresAt:      now.Add(ttl).UnixMilli(),
+			Now:            now.UnixMilli(),
+		})
+		acquired = n > 0
+		return err
+	})
+	return acquired, err
+}
+
+// GetConversationLease returns a conversation's lease, or sql.ErrNoRows if
+// nobody holds one.
+func (db *DB) GetConversationLease(ctx context.Context, conversationID string) (*generated.ConversationLease, error) {
+	var lease generated.ConversationLease
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		var err error
+		lease, err = q.GetConversationLease(ctx, conversationID)
+		return err
+	})
+	return &lease, err
+}
+
+// ReleaseConversationLease gives up owner's lease of a conversation, if it
+// holds it.
+func (db *DB) ReleaseConversationLease(ctx context.Context, conversationID, owner string) error {
+	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		return q.ReleaseConversationLease(ctx, generated.ReleaseConversationLeaseParams{ConversationID: conversationID, Owner: owner})
+	})
+}
+
+// ReleaseOwnerLeases gives up every lease owner holds.
+func (db *DB) ReleaseOwnerLeases(ctx context.Context, owner string) error {
+	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		return generated.New(tx.Conn()).ReleaseOwnerLeases(ctx, owner)
+	})
+}
+
+// Pull request review methods
+
+// CreatePRReview makes a conversation the review of a pull request at
+// headSHA. If post is set, the review is posted to GitHub when submitted.
+func (db *DB) CreatePRReview(ctx context.Context, conversationID, prURL, headSHA string, post bool) (*generated.PrReview, error) {
+	var review generated.PrReview
+	err := db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		var err error
+		review, err = q.CreatePRReview(ctx, generated.CreatePRReviewParams{
+			ConversationID: conversationID,
+			PrUrl:          prURL,
+			HeadSha:        headSHA,
+			Post:           post,
+		})
+		return err
+	})
+	return &review, err
+}
+
+// GetPRReview returns the pull request a conversation reviews, or
+// sql.ErrNoRows if it isn't a review conversation.
+func (db *DB) GetPRReview(ctx context.Context, conversationID string) (*generated.PrReview, error) {
+	var review generated.PrReview
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		var err error
+		review, err = q.GetPRReview(ctx, conversationID)
+		return err
+	})
+	return &review, err
+}
+
+// UpdatePRReview records the review the agent submitted, replacing any
+// earlier one and forgetting where that was posted.
+func (db *DB) UpdatePRReview(ctx context.Context, conversationID, review string) error {
+	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		return q.UpdatePRReview(ctx, generated.UpdatePRReviewParams{
+			Review:         &review,
+			ConversationID: conversationID,
+		})
+	})
+}
+
+// UpdatePRReviewPosted records where a conversation's review was posted.
+func (db *DB) UpdatePRReviewPosted(ctx context.Context, conversationID, postedURL string) error {
+	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		return q.UpdatePRReviewPosted(ctx, generated.UpdatePRReviewPostedParams{
+			PostedUrl:      &postedURL,
+			ConversationID: conversationID,
+		})
+	})
+}
+
+// Conversation plan methods
+
+// CreateConversationPlan puts a conversation in plan mode. allowedTools is
+// the allowlist to restore when the plan is approved, nil for every tool.
+func (db *DB) CreateConversationPlan(ctx context.Context, conversationID string, allowedTools *string) (*generated.ConversationPlan, error) {
+	var plan generated.ConversationPlan
+	err := db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		var err error
+		plan, err = q.CreateConversationPlan(ctx, generated.CreateConversationPlanParams{
+			ConversationID: conversationID,
+			AllowedTools:   allowedTools,
+		})
+		return err
+	})
+	return &plan, err
+}
+
+// GetConversationPlan returns a conversation's plan, or sql.ErrNoRows if it
+// wasn't started in plan mode.
+func (db *DB) GetConversationPlan(ctx context.Context, conversationID string) (*generated.ConversationPlan, error) {
+	var plan generated.ConversationPlan
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		var err error
+		plan, err = q.GetConversationPlan(ctx, conversationID)
+		return err
+	})
+	return &plan, err
+}
+
+// UpdateConversationPlan records a conversation's plan and its status.
+func (db *DB) UpdateConversationPlan(ctx context.Context, conversationID, status, plan string) error {
+	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		return q.UpdateConversationPlan(ctx, generated.UpdateConversationPlanParams{
+			Status:         status,
+			Plan:           &plan,
+			ConversationID: conversationID,
+		})
+	})
+}
+
+// Model comparison methods
+
+// ModelComparisonParams describes a new model comparison.
+type ModelComparisonParams struct {
+	ConversationID string
+	Message        string
+	ModelA         string
+	ConversationA  string
+	ModelB         string
+	ConversationB  string
+}
+
+// CreateModelComparison records a comparison of two models' responses to
+// a turn of a conversation, each in a shadow conversation.
+func (db *DB) CreateModelComparison(ctx context.Context, params ModelComparisonParams) (*generated.ModelComparison, error) {
+	text := rand.Text()
+	if len(text) < 8 {
+		return nil, fmt.Errorf("rand.Text() returned insufficient characters: %d", len(text))
+	}
+	var comparison generated.ModelComparison
+	err := db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		var err error
+		comparison, err = q.CreateModelComparison(ctx, generated.CreateModelComparisonParams{
+			ComparisonID:   "x" + text[:8],
+			ConversationID: params.ConversationID,
+			Message:        params.Message,
+			ModelA:         params.ModelA,
+			ConversationA:  params.ConversationA,
+			ModelB:         params.ModelB,
+			ConversationB:  params.ConversationB,
+		})
+		return err
+	})
+	return &comparison, err
+}
+
+// GetModelComparison retrieves a model comparison by ID
+func (db *DB) GetModelComparison(ctx context.Context, comparisonID string) (*generated.ModelComparison, error) {
+	var comparison generated.ModelComparison
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		var err error
+		comparison, err = q.GetModelComparison(ctx, comparisonID)
+		return err
+	})
+	return &comparison, err
+}
+
+// ListModelComparisons returns the latest model comparisons, newest first.
+func (db *DB) ListModelComparisons(ctx context.Context, limit int64) ([]generated.ModelComparison, error) {
+	var comparisons []generated.ModelComparison
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		var err error
+		comparisons, err = q.ListModelComparisons(ctx, limit)
+		return err
+	})
+	return comparisons, err
+}
+
+// UpdateModelComparisonWinner records the model the user preferred, or
+// "tie".
+func (db *DB) UpdateModelComparisonWinner(ctx context.Context, comparisonID, winner string) error {
+	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		return q.UpdateModelComparisonWinner(ctx, generated.UpdateModelComparisonWinnerParams{
+			Winner:       &winner,
+			ComparisonID: comparisonID,
+		})
+	})
+}
+
+// Message feedback methods
+
+// MessageFeedbackParams describes feedback on an agent message. Rating is
+// "up", "down", or nil for a comment alone.
+type MessageFeedbackParams struct {
+	MessageID      string
+	ConversationID string
+	Model          *string
+	Rating         *string
+	Comment        *string
+}
+
+// UpsertMessageFeedback records feedback on a message. A nil rating or
+// comment keeps the one the message already has.
+func (db *DB) UpsertMessageFeedback(ctx context.Context, params MessageFeedbackParams) (*generated.MessageFeedback, error) {
+	var feedback generated.MessageFeedback
+	err := db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		var err error
+		feedback, err = q.UpsertMessageFeedback(ctx, generated.UpsertMessageFeedbackParams{
+			MessageID:      params.MessageID,
+			ConversationID: params.ConversationID,
+			Model:          params.Model,
+			Rating:         params.Rating,
+			Comment:        params.Comment,
+		})
+		return err
+	})
+	return &feedback, err
+}
+
+// ListConversationFeedback returns the feedback on a conversation's messages.
+func (db *DB) ListConversationFeedback(ctx context.Context, conversationID string) ([]generated.MessageFeedback, error) {
+	var feedback []generated.MessageFeedback
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		var err error
+		feedback, err = q.ListConversationFeedback(ctx, conversationID)
+		return err
+	})
+	return feedback, err
+}
+
+// ListMessageFeedback returns the latest feedback across conversations,
+// most recently updated first.
+func (db *DB) ListMessageFeedback(ctx context.Context, limit int64) ([]generated.MessageFeedback, error) {
+	var feedback []generated.MessageFeedback
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		var err error
+		feedback, err = q.ListMessageFeedback(ctx, limit)
+		return err
+	})
+	return feedback, err
+}
+
+// DeleteMessageFeedback deletes the feedback on a message. It returns
+// sql.ErrNoRows if the message has none.
+func (db *DB) DeleteMessageFeedback(ctx context.Context, conversationID, messageID string) error {
+	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		n, err := q.DeleteMessageFeedback(ctx, generated.DeleteMessageFeedbackParams{
+			MessageID:      messageID,
+			ConversationID: conversationID,
+		})
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return sql.ErrNoRows
+		}
+		return nil
+	})
+}
+
+// LLM request log methods
+
+// LLMRequestParams describes a logged LLM request. The bodies are the
+// provider's HTTP request and response, if the provider reported them;
+// LLMRequest and LLMResponse are the provider-neutral request and
+// response as JSON.
+type LLMRequestParams struct {
+	ConversationID string
+	MessageID      string
+	Model          string
+	Provider       string
+	URL            string
+	RequestBody    []byte
+	ResponseBody   []byte
+	StatusCode     int
+	Error          string
+	DurationMs     int64
+	LLMRequest     []byte
+	LLMResponse    []byte
+}
+
+// CreateLLMRequest logs an LLM request and the agent message it produced.
+func (db *DB) CreateLLMRequest(ctx context.Context, params LLMRequestParams) (*generated.LlmRequest, error) {
+	optional := func(b []byte) *string {
+		if b == nil {
+			return nil
+		}
+		s := string(b)
+		return &s
+	}
+	arg := generated.CreateLLMRequestParams{
+		ConversationID: &params.ConversationID,
+		MessageID:      &params.MessageID,
+		Model:          params.Model,
+		Provider:       params.Provider,
+		Url:            params.URL,
+		RequestBody:    optional(params.RequestBody),
+		ResponseBody:   optional(params.ResponseBody),
+		DurationMs:     &params.DurationMs,
+		LlmRequest:     optional(params.LLMRequest),
+		LlmResponse:    optional(params.LLMResponse),
+	}
+	if params.StatusCode != 0 {
+		statusCode := int64(params.StatusCode)
+		arg.StatusCode = &statusCode
+	}
+	if params.Error != "" {
+		arg.Error = &params.Error
+	}
+	var request generated.LlmRequest
+	err := db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		var err error
+		request, err = q.CreateLLMRequest(ctx, arg)
+		return err
+	})
+	return &request, err
+}
+
+// GetLLMRequestByMessageID retrieves the logged LLM request that produced
+// an agent message.
+func (db *DB) GetLLMRequestByMessageID(ctx context.Context, messageID string) (*generated.LlmRequest, error) {
+	var request generated.LlmRequest
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		var err error
+		request, err = q.GetLLMRequestByMessageID(ctx, &messageID)
+		return err
+	})
+	return &request, err
+}
+
+// Custom model methods
+
+// UpsertCustomModel records a custom model, replacing any with the same ID.
+func (db *DB) UpsertCustomModel(ctx context.Context, modelID, url, apiKey, modelName string) (*generated.CustomModel, error) {
+	var model generated.CustomModel
+	err := db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		var err error
+		model, err = q.UpsertCustomModel(ctx, generated.UpsertCustomModelParams{
+			ModelID:   modelID,
+			Url:       url,
+			ApiKey:    apiKey,
+			ModelName: modelName,
+		})
+		return err
+	})
+	return &model, err
+}
+
+// ListCustomModels returns the custom models in the order they were added.
+func (db *DB) ListCustomModels(ctx context.Context) ([]generated.CustomModel, error) {
+	var models []generated.CustomModel
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		var err error
+		models, err = q.ListCustomModels(ctx)
+		return err
+	})
+	return models, err
+}
+
+// DeleteCustomModel deletes a custom model. It returns sql.ErrNoRows if
+// there is none with the ID.
+func (db *DB) DeleteCustomModel(ctx context.Context, modelID string) error {
+	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		n, err := q.DeleteCustomModel(ctx, modelID)
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return sql.ErrNoRows
+		}
+		return nil
+	})
+}
@@ -46,6 +46,21 @@ func TestNew(t *testing.T) {
 			cfg:     Config{DSN: ""},
 			wantErr: true,
 		},
+		{
+			name:    "unknown journal mode",
+			cfg:     Config{DSN: t.TempDir() + "/test.db", PoolConfig: PoolConfig{JournalMode: "fast"}},
+			wantErr: true,
+		},
+		{
+			name:    "unknown synchronous level",
+			cfg:     Config{DSN: t.TempDir() + "/test.db", PoolConfig: PoolConfig{Synchronous: "sometimes"}},
+			wantErr: true,
+		},
+		{
+			name:    "negative readers",
+			cfg:     Config{DSN: t.TempDir() + "/test.db", PoolConfig: PoolConfig{Readers: -1}},
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -62,6 +77,86 @@ func TestNew(t *testing.T) {
 	}
 }
 
+func TestPoolConfig(t *testing.T) {
+	ctx := context.Background()
+	path := t.TempDir() + "/test.db"
+	db, err := New(Config{DSN: path, PoolConfig: PoolConfig{Synchronous: "FULL", BusyTimeout: 3 * time.Second, Readers: 2, Writers: 2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if len(db.pool.readers) != 2 || len(db.pool.writer) != 2 {
+		t.Errorf("expected 2 readers and 2 writers, got %d and %d", len(db.pool.readers), len(db.pool.writer))
+	}
+	var journalMode string
+	var synchronous, busyTimeout int
+	err = db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		if err := rx.QueryRow("PRAGMA journal_mode").Scan(&journalMode); err != nil {
+			return err
+		}
+		if err := rx.QueryRow("PRAGMA synchronous").Scan(&synchronous); err != nil {
+			return err
+		}
+		return rx.QueryRow("PRAGMA busy_timeout").Scan(&busyTimeout)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if journalMode != "wal" || synchronous != 2 || busyTimeout != 3000 {
+		t.Errorf("got journal_mode %s, synchronous %d, busy_timeout %d", journalMode, synchronous, busyTimeout)
+	}
+
+	// A write waits for another process's, for up to the busy timeout
+	patient, err := New(Config{DSN: path, PoolConfig: PoolConfig{BusyTimeout: 3 * time.Second}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer patient.Close()
+	impatient, err := New(Config{DSN: path, PoolConfig: PoolConfig{BusyTimeout: 10 * time.Millisecond}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer impatient.Close()
+	locked := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+			if _, err := tx.Exec("CREATE TABLE t (x INTEGER)"); err != nil {
+				return err
+			}
+			close(locked)
+			<-release
+			return nil
+		})
+	}()
+	<-locked
+	if err := impatient.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error { return nil }); err == nil || !strings.Contains(err.Error(), "Tx begin") {
+		t.Errorf("expected the write to fail past a short busy timeout, got %v", err)
+	}
+	waited := make(chan error)
+	go func() {
+		waited <- patient.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+			_, err := tx.Exec("INSERT INTO t VALUES (1)")
+			return err
+		})
+	}()
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if err := <-waited; err != nil {
+		t.Errorf("expected the write to wait for the other, got %v", err)
+	}
+
+	// The busy write gave its connection back to the pool
+	ctx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	if err := impatient.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error { return nil }); err != nil {
+		t.Errorf("expected the pool to write after a busy write, got %v", err)
+	}
+}
+
 func TestDB_Migrate(t *testing.T) {
 	tmpDir := t.TempDir()
 	db, err := New(Config{DSN: tmpDir + "/test.db"})
@@ -108,6 +203,108 @@ func TestDB_Migrate(t *testing.T) {
 	}
 }
 
+func TestDB_MigrateDown(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	ctx := context.Background()
+	status := func() MigrationStatus {
+		t.Helper()
+		status, err := db.MigrationStatus(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return status
+	}
+
+	latest := status()
+	if latest.Version != latest.Latest || latest.Pending != 0 || latest.Version < 137 {
+		t.Fatalf("expected every migration applied, got version %d of %d, %d pending", latest.Version, latest.Latest, latest.Pending)
+	}
+
+	// Data the down paths have to carry or drop
+	conv, err := db.CreateConversation(ctx, nil, true, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, typ := range []MessageType{MessageTypeUser, MessageTypeSummary, MessageTypeAgent} {
+		if _, err := db.CreateMessage(ctx, CreateMessageParams{ConversationID: conv.ConversationID, Type: typ, LLMData: map[string]string{"text": "hi"}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.pool.Exec(ctx, `INSERT INTO user_settings (user_id, data) VALUES ('', '{"ui": {"theme": "dark"}}')`); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.MigrateDown(ctx, 125); err != nil {
+		t.Fatal(err)
+	}
+	if s := status(); s.Version != 125 || s.Pending != latest.Latest-125 {
+		t.Errorf("expected version 125, got %d with %d pending", s.Version, s.Pending)
+	}
+	if err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		_, err := rx.Query("SELECT project_id FROM conversations")
+		return err
+	}); err == nil {
+		t.Error("expected the conversations' project_id column dropped")
+	}
+
+	// Down to the schema before 107
+	if err := db.MigrateDown(ctx, 106); err != nil {
+		t.Fatal(err)
+	}
+	if s := status(); s.Version != 106 {
+		t.Errorf("expected version 106, got %d", s.Version)
+	}
+	var messages int
+	var settings string
+	if err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		if err := rx.QueryRow("SELECT count(*) FROM messages").Scan(&messages); err != nil {
+			return err
+		}
+		return rx.QueryRow("SELECT data FROM settings WHERE id = 1").Scan(&settings)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if messages != 2 {
+		t.Errorf("expected the summary message deleted, got %d messages", messages)
+	}
+	if !strings.Contains(settings, `"theme":"dark"`) {
+		t.Errorf("expected the UI settings back in the server-wide settings, got %s", settings)
+	}
+
+	// Migrations without a down path stop the way down
+	if err := db.MigrateDown(ctx, 0); err == nil || !strings.Contains(err.Error(), "no down path") {
+		t.Errorf("expected an irreversible migration refused, got %v", err)
+	}
+	if s := status(); s.Version != 106 {
+		t.Errorf("expected version 106 still, got %d", s.Version)
+	}
+
+	if err := db.MigrateUp(ctx, 130); err != nil {
+		t.Fatal(err)
+	}
+	if s := status(); s.Version != 130 {
+		t.Errorf("expected version 130, got %d", s.Version)
+	}
+	if err := db.Migrate(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.CreateConversation(ctx, nil, true, nil, nil, nil); err != nil {
+		t.Errorf("expected the schema usable after migrating back up, got %v", err)
+	}
+
+	// A migration applied by a newer build
+	if err := db.pool.Exec(ctx, "INSERT INTO migrations (migration_number, migration_name) VALUES (999, '999-future.sql')"); err != nil {
+		t.Fatal(err)
+	}
+	s := status()
+	if last := s.Migrations[len(s.Migrations)-1]; s.Version != 999 || !last.Unknown || last.AppliedAt == nil {
+		t.Errorf("expected the unknown migration reported, got version %d, %+v", s.Version, last)
+	}
+	if err := db.MigrateDown(ctx, 130); err == nil || !strings.Contains(err.Error(), "unknown to this build") {
+		t.Errorf("expected an unknown migration refused, got %v", err)
+	}
+}
+
 func TestDB_WithTx(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.Close()
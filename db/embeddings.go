@@ -0,0 +1,162 @@
+package db
+
+import (
+	"context"
+	"fmt"
+)
+
+// Embeddings are searched with libSQL's vector index, which needs the
+// dimension in the column type, so the vectors of each dimension are copied
+// to their own table, embedding_vectors_<dims>, created the first time
+// vectors of that dimension are stored or searched. Triggers on embeddings,
+// which stays the record of what's embedded, keep the copies in step.
+
+// vectorCandidates is how many times the limit SearchEmbeddings first asks
+// the index for, as the nearest vectors may be of other models or of the
+// conversation excluded.
+const vectorCandidates = 4
+
+// EnsureEmbeddingIndex creates the vector table and index for vectors of dims
+// float32s if they don't exist yet, copying the vectors stored before.
+func (db *DB) EnsureEmbeddingIndex(ctx context.Context, dims int) error {
+	if dims <= 0 {
+		return fmt.Errorf("invalid vector dimension %d", dims)
+	}
+	if _, ok := db.vectorIndexes.Load(dims); ok {
+		return nil
+	}
+	table := vectorTable(dims)
+	err := db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		for _, stmt := range []string{
+			fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+				source_type TEXT NOT NULL,
+				source_id TEXT NOT NULL,
+				model TEXT NOT NULL,
+				vector F32_BLOB(%d) NOT NULL,
+				PRIMARY KEY (source_type, source_id, model)
+			)`, table, dims),
+			fmt.Sprintf(`CREATE INDEX IF NOT EXISTS idx_%s ON %s(libsql_vector_idx(vector, 'metric=cosine'))`, table, table),
+			// A replaced embedding may have no vector, or one of another
+			// dimension, so the copy is deleted before it's inserted again
+			fmt.Sprintf(`CREATE TRIGGER IF NOT EXISTS %s_insert AFTER INSERT ON embeddings
+			BEGIN
+				DELETE FROM %s WHERE source_type = NEW.source_type AND source_id = NEW.source_id AND model = NEW.model;
+				INSERT INTO %s (source_type, source_id, model, vector)
+				SELECT NEW.source_type, NEW.source_id, NEW.model, NEW.vector
+				WHERE length(NEW.vector) = %d;
+			END`, table, table, table, 4*dims),
+			fmt.Sprintf(`CREATE TRIGGER IF NOT EXISTS %s_delete AFTER DELETE ON embeddings
+			BEGIN
+				DELETE FROM %s WHERE source_type = OLD.source_type AND source_id = OLD.source_id AND model = OLD.model;
+			END`, table, table),
+			fmt.Sprintf(`INSERT OR IGNORE INTO %s (source_type, source_id, model, vector)
+			SELECT source_type, source_id, model, vector FROM embeddings
+			WHERE length(vector) = %d`, table, 4*dims),
+		} {
+			if _, err := tx.Exec(stmt); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create the vector index for %d dimensions: %w", dims, err)
+	}
+	db.vectorIndexes.Store(dims, struct{}{})
+	return nil
+}
+
+// vectorTable names the table of the vectors of dims float32s.
+func vectorTable(dims int) string {
+	return fmt.Sprintf("embedding_vectors_%d", dims)
+}
+
+// SearchEmbeddingsParams are the parameters of SearchEmbeddings.
+type SearchEmbeddingsParams struct {
+	// Query is the vector searched for, as embed.Encode encodes it.
+	Query []byte
+	Model string
+	// ExcludeConversationID is the conversation whose messages are left out,
+	// if not empty.
+	ExcludeConversationID string
+	Limit                 int64
+}
+
+// EmbeddingMatch is an embedding SearchEmbeddings found.
+type EmbeddingMatch struct {
+	SourceType     string
+	SourceID       string
+	ConversationID *string
+	Content        string
+	// Distance is the cosine distance from the query, 0 for the same direction.
+	Distance float64
+}
+
+// SearchEmbeddings returns the embeddings of the model nearest to the query,
+// nearest first, looking them up in the vector index. The nearest vectors
+// of the dimension are fetched until enough of them are of the model and
+// not of the conversation excluded. The index is approximate, and can lose
+// vectors of a small graph others were deleted from, so once that would
+// fetch them all they're ranked exactly instead.
+func (db *DB) SearchEmbeddings(ctx context.Context, params SearchEmbeddingsParams) ([]EmbeddingMatch, error) {
+	if len(params.Query)%4 != 0 {
+		return nil, fmt.Errorf("invalid query vector of %d bytes", len(params.Query))
+	}
+	dims := len(params.Query) / 4
+	if err := db.EnsureEmbeddingIndex(ctx, dims); err != nil {
+		return nil, err
+	}
+	table := vectorTable(dims)
+	query := func(from string) string {
+		return fmt.Sprintf(`SELECT e.source_type, e.source_id, e.conversation_id, e.content,
+			CAST(vector_distance_cos(v.vector, vector32(?1)) AS REAL) AS distance
+		FROM %s
+		JOIN embeddings e ON e.source_type = v.source_type AND e.source_id = v.source_id AND e.model = v.model
+		WHERE v.model = ?3
+		  AND (e.conversation_id IS NULL OR e.conversation_id != ?4)
+		ORDER BY distance ASC
+		LIMIT ?5`, from)
+	}
+	nearest := query(fmt.Sprintf("vector_top_k('idx_%s', vector32(?1), ?2) AS top JOIN %s v ON v.rowid = top.id", table, table))
+	all := query(table + " v")
+
+	var matches []EmbeddingMatch
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		var total int64
+		if err := rx.QueryRow("SELECT count(*) FROM " + table).Scan(&total); err != nil {
+			return err
+		}
+		for k := params.Limit * vectorCandidates; ; k *= vectorCandidates {
+			q := nearest
+			if k >= total {
+				q = all
+			}
+			rows, err := rx.Query(q, params.Query, k, params.Model, params.ExcludeConversationID, params.Limit)
+			if err != nil {
+				return err
+			}
+			matches = matches[:0]
+			for rows.Next() {
+				var m EmbeddingMatch
+				if err := rows.Scan(&m.SourceType, &m.SourceID, &m.ConversationID, &m.Content, &m.Distance); err != nil {
+					rows.Close()
+					return err
+				}
+				matches = append(matches, m)
+			}
+			if err := rows.Close(); err != nil {
+				return err
+			}
+			if err := rows.Err(); err != nil {
+				return err
+			}
+			if int64(len(matches)) >= params.Limit || k >= total {
+				return nil
+			}
+		}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to search embeddings: %w", err)
+	}
+	return matches, nil
+}
@@ -0,0 +1,88 @@
+package db
+
+import (
+	"context"
+	"testing"
+
+	"shelley.exe.dev/db/generated"
+	"shelley.exe.dev/embed"
+)
+
+func TestSearchEmbeddings(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	ctx := context.Background()
+
+	store := func(sourceID, conversationID, model string, vector []float32) {
+		t.Helper()
+		params := generated.CreateEmbeddingParams{
+			SourceType: string(EmbeddingSourceMessage),
+			SourceID:   sourceID,
+			Model:      model,
+			Content:    sourceID,
+		}
+		if conversationID != "" {
+			params.ConversationID = &conversationID
+		}
+		if vector != nil {
+			params.Vector = embed.Encode(vector)
+		}
+		if err := db.QueriesTx(ctx, func(q *generated.Queries) error {
+			return q.CreateEmbedding(ctx, params)
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	search := func(model, exclude string, limit int64) []string {
+		t.Helper()
+		matches, err := db.SearchEmbeddings(ctx, SearchEmbeddingsParams{
+			Query:                 embed.Encode([]float32{1, 0, 0}),
+			Model:                 model,
+			ExcludeConversationID: exclude,
+			Limit:                 limit,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ids []string
+		for _, m := range matches {
+			ids = append(ids, m.SourceID)
+		}
+		return ids
+	}
+
+	// Vectors stored before the index exists are indexed when it's created
+	store("near", "c1", "a", []float32{1, 0.1, 0})
+	if err := db.EnsureEmbeddingIndex(ctx, 3); err != nil {
+		t.Fatal(err)
+	}
+	store("far", "c1", "a", []float32{0.5, 1, 0})
+	store("other-conversation", "c2", "a", []float32{1, 0.2, 0})
+	store("empty", "c1", "a", nil)
+	// Nearer vectors of other models take the first candidates
+	for i, id := range []string{"b1", "b2", "b3", "b4", "b5", "b6", "b7", "b8"} {
+		store(id, "c1", "b", []float32{1, 0, 0.01 * float32(i)})
+	}
+	store("other-dimension", "c1", "a", []float32{1, 0})
+
+	if got := search("b", "", 2); len(got) != 2 || got[0] != "b1" || got[1] != "b2" {
+		t.Errorf("expected b1 and b2, got %v", got)
+	}
+	if got := search("a", "", 2); len(got) != 2 || got[0] != "near" || got[1] != "other-conversation" {
+		t.Errorf("expected near and other-conversation, got %v", got)
+	}
+	if got := search("a", "c2", 3); len(got) != 2 || got[0] != "near" || got[1] != "far" {
+		t.Errorf("expected near and far without c2, got %v", got)
+	}
+
+	// Replacing or deleting an embedding updates the index
+	store("near", "c1", "a", nil)
+	if err := db.QueriesTx(ctx, func(q *generated.Queries) error {
+		return q.DeleteConversationEmbeddings(ctx, stringPtr("c2"))
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if got := search("a", "", 3); len(got) != 1 || got[0] != "far" {
+		t.Errorf("expected only far, got %v", got)
+	}
+}
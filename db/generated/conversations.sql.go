@@ -13,7 +13,7 @@ const archiveConversation = `-- name: ArchiveConversation :one
 UPDATE conversations
 SET archived = TRUE, updated_at = CURRENT_TIMESTAMP
 WHERE conversation_id = ?
-RETURNING conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, agent_working, context_window_size, agent_error, github_urls, git_origin, model_id
+RETURNING conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, agent_working, context_window_size, agent_error, github_urls, git_origin, model_id, user_id, tools, sandbox, allowed_tools, pinned, notify_events, remote, devcontainer, project_id, guidance_files, sampling, thinking
 `
 
 func (q *Queries) ArchiveConversation(ctx context.Context, conversationID string) (Conversation, error) {
@@ -34,6 +34,18 @@ func (q *Queries) ArchiveConversation(ctx context.Context, conversationID string
 		&i.GithubUrls,
 		&i.GitOrigin,
 		&i.ModelID,
+		&i.UserID,
+		&i.Tools,
+		&i.Sandbox,
+		&i.AllowedTools,
+		&i.Pinned,
+		&i.NotifyEvents,
+		&i.Remote,
+		&i.Devcontainer,
+		&i.ProjectID,
+		&i.GuidanceFiles,
+		&i.Sampling,
+		&i.Thinking,
 	)
 	return i, err
 }
@@ -63,7 +75,7 @@ func (q *Queries) CountConversations(ctx context.Context) (int64, error) {
 const createConversation = `-- name: CreateConversation :one
 INSERT INTO conversations (conversation_id, slug, user_initiated, cwd, git_origin, model_id)
 VALUES (?, ?, ?, ?, ?, ?)
-RETURNING conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, agent_working, context_window_size, agent_error, github_urls, git_origin, model_id
+RETURNING conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, agent_working, context_window_size, agent_error, github_urls, git_origin, model_id, user_id, tools, sandbox, allowed_tools, pinned, notify_events, remote, devcontainer, project_id, guidance_files, sampling, thinking
 `
 
 type CreateConversationParams struct {
@@ -100,6 +112,18 @@ func (q *Queries) CreateConversation(ctx context.Context, arg CreateConversation
 		&i.GithubUrls,
 		&i.GitOrigin,
 		&i.ModelID,
+		&i.UserID,
+		&i.Tools,
+		&i.Sandbox,
+		&i.AllowedTools,
+		&i.Pinned,
+		&i.NotifyEvents,
+		&i.Remote,
+		&i.Devcontainer,
+		&i.ProjectID,
+		&i.GuidanceFiles,
+		&i.Sampling,
+		&i.Thinking,
 	)
 	return i, err
 }
@@ -115,7 +139,7 @@ func (q *Queries) DeleteConversation(ctx context.Context, conversationID string)
 }
 
 const getConversation = `-- name: GetConversation :one
-SELECT conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, agent_working, context_window_size, agent_error, github_urls, git_origin, model_id FROM conversations
+SELECT conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, agent_working, context_window_size, agent_error, github_urls, git_origin, model_id, user_id, tools, sandbox, allowed_tools, pinned, notify_events, remote, devcontainer, project_id, guidance_files, sampling, thinking FROM conversations
 WHERE conversation_id = ?
 `
 
@@ -137,14 +161,26 @@ func (q *Queries) GetConversation(ctx context.Context, conversationID string) (C
 		&i.GithubUrls,
 		&i.GitOrigin,
 		&i.ModelID,
+		&i.UserID,
+		&i.Tools,
+		&i.Sandbox,
+		&i.AllowedTools,
+		&i.Pinned,
+		&i.NotifyEvents,
+		&i.Remote,
+		&i.Devcontainer,
+		&i.ProjectID,
+		&i.GuidanceFiles,
+		&i.Sampling,
+		&i.Thinking,
 	)
 	return i, err
 }
 
 const listAllActiveConversations = `-- name: ListAllActiveConversations :many
-SELECT conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, agent_working, context_window_size, agent_error, github_urls, git_origin, model_id FROM conversations
+SELECT conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, agent_working, context_window_size, agent_error, github_urls, git_origin, model_id, user_id, tools, sandbox, allowed_tools, pinned, notify_events, remote, devcontainer, project_id, guidance_files, sampling, thinking FROM conversations
 WHERE archived = FALSE
-ORDER BY updated_at DESC
+ORDER BY pinned DESC, updated_at DESC
 `
 
 func (q *Queries) ListAllActiveConversations(ctx context.Context) ([]Conversation, error) {
@@ -171,6 +207,18 @@ func (q *Queries) ListAllActiveConversations(ctx context.Context) ([]Conversatio
 			&i.GithubUrls,
 			&i.GitOrigin,
 			&i.ModelID,
+			&i.UserID,
+			&i.Tools,
+			&i.Sandbox,
+			&i.AllowedTools,
+			&i.Pinned,
+			&i.NotifyEvents,
+			&i.Remote,
+			&i.Devcontainer,
+			&i.ProjectID,
+			&i.GuidanceFiles,
+			&i.Sampling,
+			&i.Thinking,
 		); err != nil {
 			return nil, err
 		}
@@ -186,7 +234,7 @@ func (q *Queries) ListAllActiveConversations(ctx context.Context) ([]Conversatio
 }
 
 const listArchivedConversations = `-- name: ListArchivedConversations :many
-SELECT conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, agent_working, context_window_size, agent_error, github_urls, git_origin, model_id FROM conversations
+SELECT conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, agent_working, context_window_size, agent_error, github_urls, git_origin, model_id, user_id, tools, sandbox, allowed_tools, pinned, notify_events, remote, devcontainer, project_id, guidance_files, sampling, thinking FROM conversations
 WHERE archived = TRUE
 ORDER BY updated_at DESC
 LIMIT ? OFFSET ?
@@ -221,6 +269,18 @@ func (q *Queries) ListArchivedConversations(ctx context.Context, arg ListArchive
 			&i.GithubUrls,
 			&i.GitOrigin,
 			&i.ModelID,
+			&i.UserID,
+			&i.Tools,
+			&i.Sandbox,
+			&i.AllowedTools,
+			&i.Pinned,
+			&i.NotifyEvents,
+			&i.Remote,
+			&i.Devcontainer,
+			&i.ProjectID,
+			&i.GuidanceFiles,
+			&i.Sampling,
+			&i.Thinking,
 		); err != nil {
 			return nil, err
 		}
@@ -236,9 +296,9 @@ func (q *Queries) ListArchivedConversations(ctx context.Context, arg ListArchive
 }
 
 const listConversations = `-- name: ListConversations :many
-SELECT conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, agent_working, context_window_size, agent_error, github_urls, git_origin, model_id FROM conversations
+SELECT conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, agent_working, context_window_size, agent_error, github_urls, git_origin, model_id, user_id, tools, sandbox, allowed_tools, pinned, notify_events, remote, devcontainer, project_id, guidance_files, sampling, thinking FROM conversations
 WHERE archived = FALSE
-ORDER BY updated_at DESC
+ORDER BY pinned DESC, updated_at DESC
 LIMIT ? OFFSET ?
 `
 
@@ -271,6 +331,83 @@ func (q *Queries) ListConversations(ctx context.Context, arg ListConversationsPa
 			&i.GithubUrls,
 			&i.GitOrigin,
 			&i.ModelID,
+			&i.UserID,
+			&i.Tools,
+			&i.Sandbox,
+			&i.AllowedTools,
+			&i.Pinned,
+			&i.NotifyEvents,
+			&i.Remote,
+			&i.Devcontainer,
+			&i.ProjectID,
+			&i.GuidanceFiles,
+			&i.Sampling,
+			&i.Thinking,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listInterruptedConversations = `-- name: ListInterruptedConversations :many
+SELECT c.conversation_id, c.slug, c.user_initiated, c.created_at, c.updated_at, c.cwd, c.archived, c.parent_conversation_id, c.agent_working, c.context_window_size, c.agent_error, c.github_urls, c.git_origin, c.model_id, c.user_id, c.tools, c.sandbox, c.allowed_tools, c.pinned, c.notify_events, c.remote, c.devcontainer, c.project_id, c.guidance_files, c.sampling, c.thinking FROM conversations c
+WHERE c.archived = FALSE AND (c.agent_working = TRUE OR (
+  SELECT m.type FROM messages m
+  WHERE m.conversation_id = c.conversation_id AND m.deleted_at IS NULL
+    AND m.type NOT IN ('gitinfo', 'summary', 'system')
+  ORDER BY m.sequence_id DESC
+  LIMIT 1
+) = 'user')
+ORDER BY c.updated_at DESC
+`
+
+// Conversations the agent may be working on: those agent_working says so,
+// and those whose latest message is the user's, in case the flag wasn't
+// updated after it.
+func (q *Queries) ListInterruptedConversations(ctx context.Context) ([]Conversation, error) {
+	rows, err := q.db.QueryContext(ctx, listInterruptedConversations)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Conversation{}
+	for rows.Next() {
+		var i Conversation
+		if err := rows.Scan(
+			&i.ConversationID,
+			&i.Slug,
+			&i.UserInitiated,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Cwd,
+			&i.Archived,
+			&i.ParentConversationID,
+			&i.AgentWorking,
+			&i.ContextWindowSize,
+			&i.AgentError,
+			&i.GithubUrls,
+			&i.GitOrigin,
+			&i.ModelID,
+			&i.UserID,
+			&i.Tools,
+			&i.Sandbox,
+			&i.AllowedTools,
+			&i.Pinned,
+			&i.NotifyEvents,
+			&i.Remote,
+			&i.Devcontainer,
+			&i.ProjectID,
+			&i.GuidanceFiles,
+			&i.Sampling,
+			&i.Thinking,
 		); err != nil {
 			return nil, err
 		}
@@ -286,7 +423,7 @@ func (q *Queries) ListConversations(ctx context.Context, arg ListConversationsPa
 }
 
 const searchArchivedConversations = `-- name: SearchArchivedConversations :many
-SELECT conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, agent_working, context_window_size, agent_error, github_urls, git_origin, model_id FROM conversations
+SELECT conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, agent_working, context_window_size, agent_error, github_urls, git_origin, model_id, user_id, tools, sandbox, allowed_tools, pinned, notify_events, remote, devcontainer, project_id, guidance_files, sampling, thinking FROM conversations
 WHERE slug LIKE '%' || ? || '%' AND archived = TRUE
 ORDER BY updated_at DESC
 LIMIT ? OFFSET ?
@@ -322,6 +459,18 @@ func (q *Queries) SearchArchivedConversations(ctx context.Context, arg SearchArc
 			&i.GithubUrls,
 			&i.GitOrigin,
 			&i.ModelID,
+			&i.UserID,
+			&i.Tools,
+			&i.Sandbox,
+			&i.AllowedTools,
+			&i.Pinned,
+			&i.NotifyEvents,
+			&i.Remote,
+			&i.Devcontainer,
+			&i.ProjectID,
+			&i.GuidanceFiles,
+			&i.Sampling,
+			&i.Thinking,
 		); err != nil {
 			return nil, err
 		}
@@ -337,9 +486,9 @@ func (q *Queries) SearchArchivedConversations(ctx context.Context, arg SearchArc
 }
 
 const searchConversations = `-- name: SearchConversations :many
-SELECT conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, agent_working, context_window_size, agent_error, github_urls, git_origin, model_id FROM conversations
+SELECT conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, agent_working, context_window_size, agent_error, github_urls, git_origin, model_id, user_id, tools, sandbox, allowed_tools, pinned, notify_events, remote, devcontainer, project_id, guidance_files, sampling, thinking FROM conversations
 WHERE slug LIKE '%' || ? || '%' AND archived = FALSE
-ORDER BY updated_at DESC
+ORDER BY pinned DESC, updated_at DESC
 LIMIT ? OFFSET ?
 `
 
@@ -373,6 +522,18 @@ func (q *Queries) SearchConversations(ctx context.Context, arg SearchConversatio
 			&i.GithubUrls,
 			&i.GitOrigin,
 			&i.ModelID,
+			&i.UserID,
+			&i.Tools,
+			&i.Sandbox,
+			&i.AllowedTools,
+			&i.Pinned,
+			&i.NotifyEvents,
+			&i.Remote,
+			&i.Devcontainer,
+			&i.ProjectID,
+			&i.GuidanceFiles,
+			&i.Sampling,
+			&i.Thinking,
 		); err != nil {
 			return nil, err
 		}
@@ -391,7 +552,7 @@ const unarchiveConversation = `-- name: UnarchiveConversation :one
 UPDATE conversations
 SET archived = FALSE, updated_at = CURRENT_TIMESTAMP
 WHERE conversation_id = ?
-RETURNING conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, agent_working, context_window_size, agent_error, github_urls, git_origin, model_id
+RETURNING conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, agent_working, context_window_size, agent_error, github_urls, git_origin, model_id, user_id, tools, sandbox, allowed_tools, pinned, notify_events, remote, devcontainer, project_id, guidance_files, sampling, thinking
 `
 
 func (q *Queries) UnarchiveConversation(ctx context.Context, conversationID string) (Conversation, error) {
@@ -412,6 +573,18 @@ func (q *Queries) UnarchiveConversation(ctx context.Context, conversationID stri
 		&i.GithubUrls,
 		&i.GitOrigin,
 		&i.ModelID,
+		&i.UserID,
+		&i.Tools,
+		&i.Sandbox,
+		&i.AllowedTools,
+		&i.Pinned,
+		&i.NotifyEvents,
+		&i.Remote,
+		&i.Devcontainer,
+		&i.ProjectID,
+		&i.GuidanceFiles,
+		&i.Sampling,
+		&i.Thinking,
 	)
 	return i, err
 }
@@ -468,7 +641,7 @@ const updateConversationCwd = `-- name: UpdateConversationCwd :one
 UPDATE conversations
 SET cwd = ?, updated_at = CURRENT_TIMESTAMP
 WHERE conversation_id = ?
-RETURNING conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, agent_working, context_window_size, agent_error, github_urls, git_origin, model_id
+RETURNING conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, agent_working, context_window_size, agent_error, github_urls, git_origin, model_id, user_id, tools, sandbox, allowed_tools, pinned, notify_events, remote, devcontainer, project_id, guidance_files, sampling, thinking
 `
 
 type UpdateConversationCwdParams struct {
@@ -494,6 +667,18 @@ func (q *Queries) UpdateConversationCwd(ctx context.Context, arg UpdateConversat
 		&i.GithubUrls,
 		&i.GitOrigin,
 		&i.ModelID,
+		&i.UserID,
+		&i.Tools,
+		&i.Sandbox,
+		&i.AllowedTools,
+		&i.Pinned,
+		&i.NotifyEvents,
+		&i.Remote,
+		&i.Devcontainer,
+		&i.ProjectID,
+		&i.GuidanceFiles,
+		&i.Sampling,
+		&i.Thinking,
 	)
 	return i, err
 }
@@ -502,7 +687,7 @@ const updateConversationCwdAndGitOrigin = `-- name: UpdateConversationCwdAndGitO
 UPDATE conversations
 SET cwd = ?, git_origin = ?, updated_at = CURRENT_TIMESTAMP
 WHERE conversation_id = ?
-RETURNING conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, agent_working, context_window_size, agent_error, github_urls, git_origin, model_id
+RETURNING conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, agent_working, context_window_size, agent_error, github_urls, git_origin, model_id, user_id, tools, sandbox, allowed_tools, pinned, notify_events, remote, devcontainer, project_id, guidance_files, sampling, thinking
 `
 
 type UpdateConversationCwdAndGitOriginParams struct {
@@ -529,10 +714,38 @@ func (q *Queries) UpdateConversationCwdAndGitOrigin(ctx context.Context, arg Upd
 		&i.GithubUrls,
 		&i.GitOrigin,
 		&i.ModelID,
+		&i.UserID,
+		&i.Tools,
+		&i.Sandbox,
+		&i.AllowedTools,
+		&i.Pinned,
+		&i.NotifyEvents,
+		&i.Remote,
+		&i.Devcontainer,
+		&i.ProjectID,
+		&i.GuidanceFiles,
+		&i.Sampling,
+		&i.Thinking,
 	)
 	return i, err
 }
 
+const updateConversationDevcontainer = `-- name: UpdateConversationDevcontainer :exec
+UPDATE conversations
+SET devcontainer = ?
+WHERE conversation_id = ?
+`
+
+type UpdateConversationDevcontainerParams struct {
+	Devcontainer   *string `json:"devcontainer"`
+	ConversationID string  `json:"conversation_id"`
+}
+
+func (q *Queries) UpdateConversationDevcontainer(ctx context.Context, arg UpdateConversationDevcontainerParams) error {
+	_, err := q.db.ExecContext(ctx, updateConversationDevcontainer, arg.Devcontainer, arg.ConversationID)
+	return err
+}
+
 const updateConversationGitHubUrls = `-- name: UpdateConversationGitHubUrls :exec
 UPDATE conversations
 SET github_urls = ?, updated_at = CURRENT_TIMESTAMP
@@ -549,6 +762,22 @@ func (q *Queries) UpdateConversationGitHubUrls(ctx context.Context, arg UpdateCo
 	return err
 }
 
+const updateConversationGuidanceFiles = `-- name: UpdateConversationGuidanceFiles :exec
+UPDATE conversations
+SET guidance_files = ?
+WHERE conversation_id = ?
+`
+
+type UpdateConversationGuidanceFilesParams struct {
+	GuidanceFiles  *string `json:"guidance_files"`
+	ConversationID string  `json:"conversation_id"`
+}
+
+func (q *Queries) UpdateConversationGuidanceFiles(ctx context.Context, arg UpdateConversationGuidanceFilesParams) error {
+	_, err := q.db.ExecContext(ctx, updateConversationGuidanceFiles, arg.GuidanceFiles, arg.ConversationID)
+	return err
+}
+
 const updateConversationModelID = `-- name: UpdateConversationModelID :exec
 UPDATE conversations
 SET model_id = ?, updated_at = CURRENT_TIMESTAMP
@@ -565,11 +794,105 @@ func (q *Queries) UpdateConversationModelID(ctx context.Context, arg UpdateConve
 	return err
 }
 
+const updateConversationNotifyEvents = `-- name: UpdateConversationNotifyEvents :exec
+UPDATE conversations
+SET notify_events = ?
+WHERE conversation_id = ?
+`
+
+type UpdateConversationNotifyEventsParams struct {
+	NotifyEvents   *string `json:"notify_events"`
+	ConversationID string  `json:"conversation_id"`
+}
+
+func (q *Queries) UpdateConversationNotifyEvents(ctx context.Context, arg UpdateConversationNotifyEventsParams) error {
+	_, err := q.db.ExecContext(ctx, updateConversationNotifyEvents, arg.NotifyEvents, arg.ConversationID)
+	return err
+}
+
+const updateConversationPinned = `-- name: UpdateConversationPinned :one
+UPDATE conversations
+SET pinned = ?
+WHERE conversation_id = ?
+RETURNING conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, agent_working, context_window_size, agent_error, github_urls, git_origin, model_id, user_id, tools, sandbox, allowed_tools, pinned, notify_events, remote, devcontainer, project_id, guidance_files, sampling, thinking
+`
+
+type UpdateConversationPinnedParams struct {
+	Pinned         bool   `json:"pinned"`
+	ConversationID string `json:"conversation_id"`
+}
+
+func (q *Queries) UpdateConversationPinned(ctx context.Context, arg UpdateConversationPinnedParams) (Conversation, error) {
+	row := q.db.QueryRowContext(ctx, updateConversationPinned, arg.Pinned, arg.ConversationID)
+	var i Conversation
+	err := row.Scan(
+		&i.ConversationID,
+		&i.Slug,
+		&i.UserInitiated,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Cwd,
+		&i.Archived,
+		&i.ParentConversationID,
+		&i.AgentWorking,
+		&i.ContextWindowSize,
+		&i.AgentError,
+		&i.GithubUrls,
+		&i.GitOrigin,
+		&i.ModelID,
+		&i.UserID,
+		&i.Tools,
+		&i.Sandbox,
+		&i.AllowedTools,
+		&i.Pinned,
+		&i.NotifyEvents,
+		&i.Remote,
+		&i.Devcontainer,
+		&i.ProjectID,
+		&i.GuidanceFiles,
+		&i.Sampling,
+		&i.Thinking,
+	)
+	return i, err
+}
+
+const updateConversationProject = `-- name: UpdateConversationProject :exec
+UPDATE conversations
+SET project_id = ?
+WHERE conversation_id = ?
+`
+
+type UpdateConversationProjectParams struct {
+	ProjectID      *string `json:"project_id"`
+	ConversationID string  `json:"conversation_id"`
+}
+
+func (q *Queries) UpdateConversationProject(ctx context.Context, arg UpdateConversationProjectParams) error {
+	_, err := q.db.ExecContext(ctx, updateConversationProject, arg.ProjectID, arg.ConversationID)
+	return err
+}
+
+const updateConversationRemote = `-- name: UpdateConversationRemote :exec
+UPDATE conversations
+SET remote = ?
+WHERE conversation_id = ?
+`
+
+type UpdateConversationRemoteParams struct {
+	Remote         *string `json:"remote"`
+	ConversationID string  `json:"conversation_id"`
+}
+
+func (q *Queries) UpdateConversationRemote(ctx context.Context, arg UpdateConversationRemoteParams) error {
+	_, err := q.db.ExecContext(ctx, updateConversationRemote, arg.Remote, arg.ConversationID)
+	return err
+}
+
 const updateConversationSlug = `-- name: UpdateConversationSlug :one
 UPDATE conversations
 SET slug = ?, updated_at = CURRENT_TIMESTAMP
 WHERE conversation_id = ?
-RETURNING conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, agent_working, context_window_size, agent_error, github_urls, git_origin, model_id
+RETURNING conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, agent_working, context_window_size, agent_error, github_urls, git_origin, model_id, user_id, tools, sandbox, allowed_tools, pinned, notify_events, remote, devcontainer, project_id, guidance_files, sampling, thinking
 `
 
 type UpdateConversationSlugParams struct {
@@ -595,6 +918,18 @@ func (q *Queries) UpdateConversationSlug(ctx context.Context, arg UpdateConversa
 		&i.GithubUrls,
 		&i.GitOrigin,
 		&i.ModelID,
+		&i.UserID,
+		&i.Tools,
+		&i.Sandbox,
+		&i.AllowedTools,
+		&i.Pinned,
+		&i.NotifyEvents,
+		&i.Remote,
+		&i.Devcontainer,
+		&i.ProjectID,
+		&i.GuidanceFiles,
+		&i.Sampling,
+		&i.Thinking,
 	)
 	return i, err
 }
@@ -609,3 +944,99 @@ func (q *Queries) UpdateConversationTimestamp(ctx context.Context, conversationI
 	_, err := q.db.ExecContext(ctx, updateConversationTimestamp, conversationID)
 	return err
 }
+
+const updateConversationUserID = `-- name: UpdateConversationUserID :exec
+UPDATE conversations
+SET user_id = ?
+WHERE conversation_id = ?
+`
+
+type UpdateConversationUserIDParams struct {
+	UserID         *string `json:"user_id"`
+	ConversationID string  `json:"conversation_id"`
+}
+
+func (q *Queries) UpdateConversationUserID(ctx context.Context, arg UpdateConversationUserIDParams) error {
+	_, err := q.db.ExecContext(ctx, updateConversationUserID, arg.UserID, arg.ConversationID)
+	return err
+}
+
+const updateConversationAllowedTools = `-- name: UpdateConversationAllowedTools :exec
+UPDATE conversations
+SET allowed_tools = ?
+WHERE conversation_id = ?
+`
+
+type UpdateConversationAllowedToolsParams struct {
+	AllowedTools   *string `json:"allowed_tools"`
+	ConversationID string  `json:"conversation_id"`
+}
+
+func (q *Queries) UpdateConversationAllowedTools(ctx context.Context, arg UpdateConversationAllowedToolsParams) error {
+	_, err := q.db.ExecContext(ctx, updateConversationAllowedTools, arg.AllowedTools, arg.ConversationID)
+	return err
+}
+
+const updateConversationSampling = `-- name: UpdateConversationSampling :exec
+UPDATE conversations
+SET sampling = ?
+WHERE conversation_id = ?
+`
+
+type UpdateConversationSamplingParams struct {
+	Sampling       *string `json:"sampling"`
+	ConversationID string  `json:"conversation_id"`
+}
+
+func (q *Queries) UpdateConversationSampling(ctx context.Context, arg UpdateConversationSamplingParams) error {
+	_, err := q.db.ExecContext(ctx, updateConversationSampling, arg.Sampling, arg.ConversationID)
+	return err
+}
+
+const updateConversationSandbox = `-- name: UpdateConversationSandbox :exec
+UPDATE conversations
+SET sandbox = ?
+WHERE conversation_id = ?
+`
+
+type UpdateConversationSandboxParams struct {
+	Sandbox        *string `json:"sandbox"`
+	ConversationID string  `json:"conversation_id"`
+}
+
+func (q *Queries) UpdateConversationSandbox(ctx context.Context, arg UpdateConversationSandboxParams) error {
+	_, err := q.db.ExecContext(ctx, updateConversationSandbox, arg.Sandbox, arg.ConversationID)
+	return err
+}
+
+const updateConversationThinking = `-- name: UpdateConversationThinking :exec
+UPDATE conversations
+SET thinking = ?
+WHERE conversation_id = ?
+`
+
+type UpdateConversationThinkingParams struct {
+	Thinking       *string `json:"thinking"`
+	ConversationID string  `json:"conversation_id"`
+}
+
+func (q *Queries) UpdateConversationThinking(ctx context.Context, arg UpdateConversationThinkingParams) error {
+	_, err := q.db.ExecContext(ctx, updateConversationThinking, arg.Thinking, arg.ConversationID)
+	return err
+}
+
+const updateConversationTools = `-- name: UpdateConversationTools :exec
+UPDATE conversations
+SET tools = ?
+WHERE conversation_id = ?
+`
+
+type UpdateConversationToolsParams struct {
+	Tools          *string `json:"tools"`
+	ConversationID string  `json:"conversation_id"`
+}
+
+func (q *Queries) UpdateConversationTools(ctx context.Context, arg UpdateConversationToolsParams) error {
+	_, err := q.db.ExecContext(ctx, updateConversationTools, arg.Tools, arg.ConversationID)
+	return err
+}
@@ -7,11 +7,13 @@ package generated
 
 import (
 	"context"
+
+	"shelley.exe.dev/db/compressed"
 )
 
 const countMessagesByType = `-- name: CountMessagesByType :one
 SELECT COUNT(*) FROM messages
-WHERE conversation_id = ? AND type = ?
+WHERE conversation_id = ? AND type = ? AND deleted_at IS NULL
 `
 
 type CountMessagesByTypeParams struct {
@@ -28,7 +30,7 @@ func (q *Queries) CountMessagesByType(ctx context.Context, arg CountMessagesByTy
 
 const countMessagesInConversation = `-- name: CountMessagesInConversation :one
 SELECT COUNT(*) FROM messages
-WHERE conversation_id = ?
+WHERE conversation_id = ? AND deleted_at IS NULL
 `
 
 func (q *Queries) CountMessagesInConversation(ctx context.Context, conversationID string) (int64, error) {
@@ -41,18 +43,18 @@ func (q *Queries) CountMessagesInConversation(ctx context.Context, conversationI
 const createMessage = `-- name: CreateMessage :one
 INSERT INTO messages (message_id, conversation_id, sequence_id, type, llm_data, user_data, usage_data, display_data)
 VALUES (?, ?, ?, ?, ?, ?, ?, ?)
-RETURNING message_id, conversation_id, sequence_id, type, llm_data, user_data, usage_data, created_at, display_data
+RETURNING message_id, conversation_id, sequence_id, type, llm_data, user_data, usage_data, created_at, display_data, deleted_at
 `
 
 type CreateMessageParams struct {
-	MessageID      string  `json:"message_id"`
-	ConversationID string  `json:"conversation_id"`
-	SequenceID     int64   `json:"sequence_id"`
-	Type           string  `json:"type"`
-	LlmData        *string `json:"llm_data"`
-	UserData       *string `json:"user_data"`
-	UsageData      *string `json:"usage_data"`
-	DisplayData    *string `json:"display_data"`
+	MessageID      string           `json:"message_id"`
+	ConversationID string           `json:"conversation_id"`
+	SequenceID     int64            `json:"sequence_id"`
+	Type           string           `json:"type"`
+	LlmData        *compressed.Text `json:"llm_data"`
+	UserData       *string          `json:"user_data"`
+	UsageData      *string          `json:"usage_data"`
+	DisplayData    *string          `json:"display_data"`
 }
 
 func (q *Queries) CreateMessage(ctx context.Context, arg CreateMessageParams) (Message, error) {
@@ -77,6 +79,7 @@ func (q *Queries) CreateMessage(ctx context.Context, arg CreateMessageParams) (M
 		&i.UsageData,
 		&i.CreatedAt,
 		&i.DisplayData,
+		&i.DeletedAt,
 	)
 	return i, err
 }
@@ -102,8 +105,8 @@ func (q *Queries) DeleteMessage(ctx context.Context, messageID string) error {
 }
 
 const getLatestMessage = `-- name: GetLatestMessage :one
-SELECT message_id, conversation_id, sequence_id, type, llm_data, user_data, usage_data, created_at, display_data FROM messages
-WHERE conversation_id = ?
+SELECT message_id, conversation_id, sequence_id, type, llm_data, user_data, usage_data, created_at, display_data, deleted_at FROM messages
+WHERE conversation_id = ? AND deleted_at IS NULL
 ORDER BY sequence_id DESC
 LIMIT 1
 `
@@ -121,12 +124,13 @@ func (q *Queries) GetLatestMessage(ctx context.Context, conversationID string) (
 		&i.UsageData,
 		&i.CreatedAt,
 		&i.DisplayData,
+		&i.DeletedAt,
 	)
 	return i, err
 }
 
 const getMessage = `-- name: GetMessage :one
-SELECT message_id, conversation_id, sequence_id, type, llm_data, user_data, usage_data, created_at, display_data FROM messages
+SELECT message_id, conversation_id, sequence_id, type, llm_data, user_data, usage_data, created_at, display_data, deleted_at FROM messages
 WHERE message_id = ?
 `
 
@@ -143,6 +147,7 @@ func (q *Queries) GetMessage(ctx context.Context, messageID string) (Message, er
 		&i.UsageData,
 		&i.CreatedAt,
 		&i.DisplayData,
+		&i.DeletedAt,
 	)
 	return i, err
 }
@@ -160,9 +165,96 @@ func (q *Queries) GetNextSequenceID(ctx context.Context, conversationID string)
 	return column_1, err
 }
 
+const listCompressedMessages = `-- name: ListCompressedMessages :many
+SELECT message_id, llm_data FROM messages
+WHERE message_id > ? AND typeof(llm_data) = 'blob'
+ORDER BY message_id
+LIMIT ?
+`
+
+type ListCompressedMessagesParams struct {
+	MessageID string `json:"message_id"`
+	Limit     int64  `json:"limit"`
+}
+
+type ListCompressedMessagesRow struct {
+	MessageID string           `json:"message_id"`
+	LlmData   *compressed.Text `json:"llm_data"`
+}
+
+func (q *Queries) ListCompressedMessages(ctx context.Context, arg ListCompressedMessagesParams) ([]ListCompressedMessagesRow, error) {
+	rows, err := q.db.QueryContext(ctx, listCompressedMessages, arg.MessageID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListCompressedMessagesRow{}
+	for rows.Next() {
+		var i ListCompressedMessagesRow
+		if err := rows.Scan(&i.MessageID, &i.LlmData); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listMessageData = `-- name: ListMessageData :many
+SELECT message_id, llm_data, user_data, display_data FROM messages
+WHERE message_id > ?
+ORDER BY message_id
+LIMIT ?
+`
+
+type ListMessageDataParams struct {
+	MessageID string `json:"message_id"`
+	Limit     int64  `json:"limit"`
+}
+
+type ListMessageDataRow struct {
+	MessageID   string           `json:"message_id"`
+	LlmData     *compressed.Text `json:"llm_data"`
+	UserData    *string          `json:"user_data"`
+	DisplayData *string          `json:"display_data"`
+}
+
+func (q *Queries) ListMessageData(ctx context.Context, arg ListMessageDataParams) ([]ListMessageDataRow, error) {
+	rows, err := q.db.QueryContext(ctx, listMessageData, arg.MessageID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListMessageDataRow{}
+	for rows.Next() {
+		var i ListMessageDataRow
+		if err := rows.Scan(
+			&i.MessageID,
+			&i.LlmData,
+			&i.UserData,
+			&i.DisplayData,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const listMessages = `-- name: ListMessages :many
-SELECT message_id, conversation_id, sequence_id, type, llm_data, user_data, usage_data, created_at, display_data FROM messages
-WHERE conversation_id = ?
+SELECT message_id, conversation_id, sequence_id, type, llm_data, user_data, usage_data, created_at, display_data, deleted_at FROM messages
+WHERE conversation_id = ? AND deleted_at IS NULL
 ORDER BY sequence_id ASC
 `
 
@@ -185,6 +277,101 @@ func (q *Queries) ListMessages(ctx context.Context, conversationID string) ([]Me
 			&i.UsageData,
 			&i.CreatedAt,
 			&i.DisplayData,
+			&i.DeletedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listMessagesAfter = `-- name: ListMessagesAfter :many
+SELECT message_id, conversation_id, sequence_id, type, llm_data, user_data, usage_data, created_at, display_data, deleted_at FROM messages
+WHERE conversation_id = ? AND sequence_id > ? AND deleted_at IS NULL
+ORDER BY sequence_id ASC
+LIMIT ?
+`
+
+type ListMessagesAfterParams struct {
+	ConversationID string `json:"conversation_id"`
+	SequenceID     int64  `json:"sequence_id"`
+	Limit          int64  `json:"limit"`
+}
+
+func (q *Queries) ListMessagesAfter(ctx context.Context, arg ListMessagesAfterParams) ([]Message, error) {
+	rows, err := q.db.QueryContext(ctx, listMessagesAfter, arg.ConversationID, arg.SequenceID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Message{}
+	for rows.Next() {
+		var i Message
+		if err := rows.Scan(
+			&i.MessageID,
+			&i.ConversationID,
+			&i.SequenceID,
+			&i.Type,
+			&i.LlmData,
+			&i.UserData,
+			&i.UsageData,
+			&i.CreatedAt,
+			&i.DisplayData,
+			&i.DeletedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listMessagesBefore = `-- name: ListMessagesBefore :many
+SELECT message_id, conversation_id, sequence_id, type, llm_data, user_data, usage_data, created_at, display_data, deleted_at FROM messages
+WHERE conversation_id = ? AND sequence_id < ? AND deleted_at IS NULL
+ORDER BY sequence_id DESC
+LIMIT ?
+`
+
+type ListMessagesBeforeParams struct {
+	ConversationID string `json:"conversation_id"`
+	SequenceID     int64  `json:"sequence_id"`
+	Limit          int64  `json:"limit"`
+}
+
+func (q *Queries) ListMessagesBefore(ctx context.Context, arg ListMessagesBeforeParams) ([]Message, error) {
+	rows, err := q.db.QueryContext(ctx, listMessagesBefore, arg.ConversationID, arg.SequenceID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Message{}
+	for rows.Next() {
+		var i Message
+		if err := rows.Scan(
+			&i.MessageID,
+			&i.ConversationID,
+			&i.SequenceID,
+			&i.Type,
+			&i.LlmData,
+			&i.UserData,
+			&i.UsageData,
+			&i.CreatedAt,
+			&i.DisplayData,
+			&i.DeletedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -200,8 +387,8 @@ func (q *Queries) ListMessages(ctx context.Context, conversationID string) ([]Me
 }
 
 const listMessagesByType = `-- name: ListMessagesByType :many
-SELECT message_id, conversation_id, sequence_id, type, llm_data, user_data, usage_data, created_at, display_data FROM messages
-WHERE conversation_id = ? AND type = ?
+SELECT message_id, conversation_id, sequence_id, type, llm_data, user_data, usage_data, created_at, display_data, deleted_at FROM messages
+WHERE conversation_id = ? AND type = ? AND deleted_at IS NULL
 ORDER BY sequence_id ASC
 `
 
@@ -229,6 +416,51 @@ func (q *Queries) ListMessagesByType(ctx context.Context, arg ListMessagesByType
 			&i.UsageData,
 			&i.CreatedAt,
 			&i.DisplayData,
+			&i.DeletedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listMessagesForContext = `-- name: ListMessagesForContext :many
+SELECT message_id, conversation_id, sequence_id, type, llm_data, user_data, usage_data, created_at, display_data, deleted_at FROM messages
+WHERE conversation_id = ? AND deleted_at IS NULL
+  AND (type = 'system' OR sequence_id >= COALESCE((
+    SELECT MAX(s.sequence_id) FROM messages s
+    WHERE s.conversation_id = messages.conversation_id AND s.type = 'summary' AND s.deleted_at IS NULL
+  ), 0))
+ORDER BY sequence_id ASC
+`
+
+func (q *Queries) ListMessagesForContext(ctx context.Context, conversationID string) ([]Message, error) {
+	rows, err := q.db.QueryContext(ctx, listMessagesForContext, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Message{}
+	for rows.Next() {
+		var i Message
+		if err := rows.Scan(
+			&i.MessageID,
+			&i.ConversationID,
+			&i.SequenceID,
+			&i.Type,
+			&i.LlmData,
+			&i.UserData,
+			&i.UsageData,
+			&i.CreatedAt,
+			&i.DisplayData,
+			&i.DeletedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -244,8 +476,8 @@ func (q *Queries) ListMessagesByType(ctx context.Context, arg ListMessagesByType
 }
 
 const listMessagesPaginated = `-- name: ListMessagesPaginated :many
-SELECT message_id, conversation_id, sequence_id, type, llm_data, user_data, usage_data, created_at, display_data FROM messages
-WHERE conversation_id = ?
+SELECT message_id, conversation_id, sequence_id, type, llm_data, user_data, usage_data, created_at, display_data, deleted_at FROM messages
+WHERE conversation_id = ? AND deleted_at IS NULL
 ORDER BY sequence_id ASC
 LIMIT ? OFFSET ?
 `
@@ -275,6 +507,7 @@ func (q *Queries) ListMessagesPaginated(ctx context.Context, arg ListMessagesPag
 			&i.UsageData,
 			&i.CreatedAt,
 			&i.DisplayData,
+			&i.DeletedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -290,8 +523,8 @@ func (q *Queries) ListMessagesPaginated(ctx context.Context, arg ListMessagesPag
 }
 
 const listMessagesSince = `-- name: ListMessagesSince :many
-SELECT message_id, conversation_id, sequence_id, type, llm_data, user_data, usage_data, created_at, display_data FROM messages
-WHERE conversation_id = ? AND sequence_id > ?
+SELECT message_id, conversation_id, sequence_id, type, llm_data, user_data, usage_data, created_at, display_data, deleted_at FROM messages
+WHERE conversation_id = ? AND sequence_id > ? AND deleted_at IS NULL
 ORDER BY sequence_id ASC
 `
 
@@ -319,6 +552,7 @@ func (q *Queries) ListMessagesSince(ctx context.Context, arg ListMessagesSincePa
 			&i.UsageData,
 			&i.CreatedAt,
 			&i.DisplayData,
+			&i.DeletedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -332,3 +566,197 @@ func (q *Queries) ListMessagesSince(ctx context.Context, arg ListMessagesSincePa
 	}
 	return items, nil
 }
+
+const listMessagesSinceLastAgent = `-- name: ListMessagesSinceLastAgent :many
+SELECT message_id, conversation_id, sequence_id, type, llm_data, user_data, usage_data, created_at, display_data, deleted_at FROM messages
+WHERE conversation_id = ? AND deleted_at IS NULL
+  AND sequence_id >= COALESCE((
+    SELECT MAX(a.sequence_id) FROM messages a
+    WHERE a.conversation_id = messages.conversation_id AND a.type = 'agent' AND a.deleted_at IS NULL
+  ), 0)
+ORDER BY sequence_id ASC
+`
+
+// The latest agent message and those after it, or every message if the
+// agent hasn't answered yet.
+func (q *Queries) ListMessagesSinceLastAgent(ctx context.Context, conversationID string) ([]Message, error) {
+	rows, err := q.db.QueryContext(ctx, listMessagesSinceLastAgent, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Message{}
+	for rows.Next() {
+		var i Message
+		if err := rows.Scan(
+			&i.MessageID,
+			&i.ConversationID,
+			&i.SequenceID,
+			&i.Type,
+			&i.LlmData,
+			&i.UserData,
+			&i.UsageData,
+			&i.CreatedAt,
+			&i.DisplayData,
+			&i.DeletedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUncompressedMessages = `-- name: ListUncompressedMessages :many
+SELECT message_id, llm_data FROM messages
+WHERE message_id > ? AND typeof(llm_data) = 'text' AND length(CAST(llm_data AS BLOB)) >= ?
+ORDER BY message_id
+LIMIT ?
+`
+
+type ListUncompressedMessagesParams struct {
+	MessageID string `json:"message_id"`
+	MinSize   int64  `json:"min_size"`
+	Limit     int64  `json:"limit"`
+}
+
+type ListUncompressedMessagesRow struct {
+	MessageID string           `json:"message_id"`
+	LlmData   *compressed.Text `json:"llm_data"`
+}
+
+func (q *Queries) ListUncompressedMessages(ctx context.Context, arg ListUncompressedMessagesParams) ([]ListUncompressedMessagesRow, error) {
+	rows, err := q.db.QueryContext(ctx, listUncompressedMessages, arg.MessageID, arg.MinSize, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListUncompressedMessagesRow{}
+	for rows.Next() {
+		var i ListUncompressedMessagesRow
+		if err := rows.Scan(&i.MessageID, &i.LlmData); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const replaceMessage = `-- name: ReplaceMessage :one
+UPDATE messages
+SET sequence_id = ?, type = ?, llm_data = ?, user_data = ?, usage_data = ?, display_data = ?
+WHERE message_id = ?
+RETURNING message_id, conversation_id, sequence_id, type, llm_data, user_data, usage_data, created_at, display_data, deleted_at
+`
+
+type ReplaceMessageParams struct {
+	SequenceID  int64            `json:"sequence_id"`
+	Type        string           `json:"type"`
+	LlmData     *compressed.Text `json:"llm_data"`
+	UserData    *string          `json:"user_data"`
+	UsageData   *string          `json:"usage_data"`
+	DisplayData *string          `json:"display_data"`
+	MessageID   string           `json:"message_id"`
+}
+
+func (q *Queries) ReplaceMessage(ctx context.Context, arg ReplaceMessageParams) (Message, error) {
+	row := q.db.QueryRowContext(ctx, replaceMessage,
+		arg.SequenceID,
+		arg.Type,
+		arg.LlmData,
+		arg.UserData,
+		arg.UsageData,
+		arg.DisplayData,
+		arg.MessageID,
+	)
+	var i Message
+	err := row.Scan(
+		&i.MessageID,
+		&i.ConversationID,
+		&i.SequenceID,
+		&i.Type,
+		&i.LlmData,
+		&i.UserData,
+		&i.UsageData,
+		&i.CreatedAt,
+		&i.DisplayData,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const softDeleteMessage = `-- name: SoftDeleteMessage :exec
+UPDATE messages
+SET deleted_at = CURRENT_TIMESTAMP
+WHERE conversation_id = ? AND sequence_id = ? AND deleted_at IS NULL
+`
+
+type SoftDeleteMessageParams struct {
+	ConversationID string `json:"conversation_id"`
+	SequenceID     int64  `json:"sequence_id"`
+}
+
+func (q *Queries) SoftDeleteMessage(ctx context.Context, arg SoftDeleteMessageParams) error {
+	_, err := q.db.ExecContext(ctx, softDeleteMessage, arg.ConversationID, arg.SequenceID)
+	return err
+}
+
+const softDeleteMessagesAfter = `-- name: SoftDeleteMessagesAfter :exec
+UPDATE messages
+SET deleted_at = CURRENT_TIMESTAMP
+WHERE conversation_id = ? AND sequence_id > ? AND deleted_at IS NULL
+`
+
+type SoftDeleteMessagesAfterParams struct {
+	ConversationID string `json:"conversation_id"`
+	SequenceID     int64  `json:"sequence_id"`
+}
+
+func (q *Queries) SoftDeleteMessagesAfter(ctx context.Context, arg SoftDeleteMessagesAfterParams) error {
+	_, err := q.db.ExecContext(ctx, softDeleteMessagesAfter, arg.ConversationID, arg.SequenceID)
+	return err
+}
+
+const updateMessageLLMData = `-- name: UpdateMessageLLMData :exec
+UPDATE messages
+SET llm_data = ?
+WHERE message_id = ?
+`
+
+type UpdateMessageLLMDataParams struct {
+	LlmData   *compressed.Text `json:"llm_data"`
+	MessageID string           `json:"message_id"`
+}
+
+func (q *Queries) UpdateMessageLLMData(ctx context.Context, arg UpdateMessageLLMDataParams) error {
+	_, err := q.db.ExecContext(ctx, updateMessageLLMData, arg.LlmData, arg.MessageID)
+	return err
+}
+
+const updateMessageLLMDataText = `-- name: UpdateMessageLLMDataText :exec
+UPDATE messages
+SET llm_data = CAST(? AS TEXT)
+WHERE message_id = ?
+`
+
+type UpdateMessageLLMDataTextParams struct {
+	LlmData   string `json:"llm_data"`
+	MessageID string `json:"message_id"`
+}
+
+func (q *Queries) UpdateMessageLLMDataText(ctx context.Context, arg UpdateMessageLLMDataTextParams) error {
+	_, err := q.db.ExecContext(ctx, updateMessageLLMDataText, arg.LlmData, arg.MessageID)
+	return err
+}
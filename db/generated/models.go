@@ -6,8 +6,48 @@ package generated
 
 import (
 	"time"
+
+	"shelley.exe.dev/db/compressed"
 )
 
+type Artifact struct {
+	ArtifactID     string    `json:"artifact_id"`
+	ConversationID string    `json:"conversation_id"`
+	Name           string    `json:"name"`
+	ContentType    string    `json:"content_type"`
+	Size           int64     `json:"size"`
+	Sha256         string    `json:"sha256"`
+	CreatedAt      time.Time `json:"created_at"`
+	ExpiresAt      int64     `json:"expires_at"`
+}
+
+type BridgeThread struct {
+	Platform       string    `json:"platform"`
+	ThreadID       string    `json:"thread_id"`
+	ConversationID string    `json:"conversation_id"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+type Checkpoint struct {
+	ConversationID string    `json:"conversation_id"`
+	SequenceID     int64     `json:"sequence_id"`
+	Cwd            string    `json:"cwd"`
+	GitCommit      string    `json:"git_commit"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+type CiAutofix struct {
+	ConversationID string    `json:"conversation_id"`
+	PrUrl          string    `json:"pr_url"`
+	MaxAttempts    int64     `json:"max_attempts"`
+	MaxCostUsd     float64   `json:"max_cost_usd"`
+	Attempts       int64     `json:"attempts"`
+	LastSha        string    `json:"last_sha"`
+	Status         string    `json:"status"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
 type Conversation struct {
 	ConversationID       string    `json:"conversation_id"`
 	Slug                 *string   `json:"slug"`
@@ -23,6 +63,85 @@ type Conversation struct {
 	GithubUrls           *string   `json:"github_urls"`
 	GitOrigin            *string   `json:"git_origin"`
 	ModelID              *string   `json:"model_id"`
+	UserID               *string   `json:"user_id"`
+	Tools                *string   `json:"tools"`
+	Sandbox              *string   `json:"sandbox"`
+	AllowedTools         *string   `json:"allowed_tools"`
+	Pinned               bool      `json:"pinned"`
+	NotifyEvents         *string   `json:"notify_events"`
+	Remote               *string   `json:"remote"`
+	Devcontainer         *string   `json:"devcontainer"`
+	ProjectID            *string   `json:"project_id"`
+	GuidanceFiles        *string   `json:"guidance_files"`
+	Sampling             *string   `json:"sampling"`
+	Thinking             *string   `json:"thinking"`
+}
+
+type ConversationLease struct {
+	ConversationID string `json:"conversation_id"`
+	Owner          string `json:"owner"`
+	ExpiresAt      int64  `json:"expires_at"`
+}
+
+type ConversationPlan struct {
+	ConversationID string    `json:"conversation_id"`
+	Status         string    `json:"status"`
+	Plan           *string   `json:"plan"`
+	AllowedTools   *string   `json:"allowed_tools"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+type ConversationShare struct {
+	Token          string    `json:"token"`
+	ConversationID string    `json:"conversation_id"`
+	UserID         *string   `json:"user_id"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+type CustomModel struct {
+	ModelID   string    `json:"model_id"`
+	Url       string    `json:"url"`
+	ApiKey    string    `json:"api_key"`
+	ModelName string    `json:"model_name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type DocChunk struct {
+	Root       string    `json:"root"`
+	Model      string    `json:"model"`
+	Path       string    `json:"path"`
+	ChunkIndex int64     `json:"chunk_index"`
+	StartLine  int64     `json:"start_line"`
+	FileHash   string    `json:"file_hash"`
+	Content    string    `json:"content"`
+	Vector     []byte    `json:"vector"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type Embedding struct {
+	SourceType     string    `json:"source_type"`
+	SourceID       string    `json:"source_id"`
+	ConversationID *string   `json:"conversation_id"`
+	Model          string    `json:"model"`
+	Content        string    `json:"content"`
+	Vector         []byte    `json:"vector"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+type FileEdit struct {
+	EditID         string     `json:"edit_id"`
+	ConversationID *string    `json:"conversation_id"`
+	SequenceID     *int64     `json:"sequence_id"`
+	ToolUseID      *string    `json:"tool_use_id"`
+	Source         string     `json:"source"`
+	Path           string     `json:"path"`
+	NewFile        bool       `json:"new_file"`
+	BeforeContent  []byte     `json:"before_content"`
+	AfterContent   []byte     `json:"after_content"`
+	RevertedAt     *time.Time `json:"reverted_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+	DeletedFile    bool       `json:"deleted_file"`
 }
 
 type LlmRequest struct {
@@ -37,18 +156,42 @@ type LlmRequest struct {
 	Error          *string   `json:"error"`
 	DurationMs     *int64    `json:"duration_ms"`
 	CreatedAt      time.Time `json:"created_at"`
+	MessageID      *string   `json:"message_id"`
+	LlmRequest     *string   `json:"llm_request"`
+	LlmResponse    *string   `json:"llm_response"`
+}
+
+type Memory struct {
+	MemoryID       string    `json:"memory_id"`
+	Project        string    `json:"project"`
+	Content        string    `json:"content"`
+	Source         string    `json:"source"`
+	ConversationID *string   `json:"conversation_id"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
 }
 
 type Message struct {
+	MessageID      string           `json:"message_id"`
+	ConversationID string           `json:"conversation_id"`
+	SequenceID     int64            `json:"sequence_id"`
+	Type           string           `json:"type"`
+	LlmData        *compressed.Text `json:"llm_data"`
+	UserData       *string          `json:"user_data"`
+	UsageData      *string          `json:"usage_data"`
+	CreatedAt      time.Time        `json:"created_at"`
+	DisplayData    *string          `json:"display_data"`
+	DeletedAt      *time.Time       `json:"deleted_at"`
+}
+
+type MessageFeedback struct {
 	MessageID      string    `json:"message_id"`
 	ConversationID string    `json:"conversation_id"`
-	SequenceID     int64     `json:"sequence_id"`
-	Type           string    `json:"type"`
-	LlmData        *string   `json:"llm_data"`
-	UserData       *string   `json:"user_data"`
-	UsageData      *string   `json:"usage_data"`
+	Model          *string   `json:"model"`
+	Rating         *string   `json:"rating"`
+	Comment        *string   `json:"comment"`
 	CreatedAt      time.Time `json:"created_at"`
-	DisplayData    *string   `json:"display_data"`
+	UpdatedAt      time.Time `json:"updated_at"`
 }
 
 type Migration struct {
@@ -57,8 +200,73 @@ type Migration struct {
 	ExecutedAt      *time.Time `json:"executed_at"`
 }
 
+type ModelComparison struct {
+	ComparisonID   string    `json:"comparison_id"`
+	ConversationID string    `json:"conversation_id"`
+	Message        string    `json:"message"`
+	ModelA         string    `json:"model_a"`
+	ConversationA  string    `json:"conversation_a"`
+	ModelB         string    `json:"model_b"`
+	ConversationB  string    `json:"conversation_b"`
+	Winner         *string   `json:"winner"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+type PrReview struct {
+	ConversationID string    `json:"conversation_id"`
+	PrUrl          string    `json:"pr_url"`
+	HeadSha        string    `json:"head_sha"`
+	Post           bool      `json:"post"`
+	Review         *string   `json:"review"`
+	PostedUrl      *string   `json:"posted_url"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+type Project struct {
+	ProjectID    string    `json:"project_id"`
+	Name         string    `json:"name"`
+	RootPath     string    `json:"root_path"`
+	DefaultModel *string   `json:"default_model"`
+	AllowedTools *string   `json:"allowed_tools"`
+	GitOrigin    *string   `json:"git_origin"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
 type Setting struct {
 	ID        int64     `json:"id"`
 	Data      string    `json:"data"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
+
+type SettingsHistory struct {
+	ID        int64     `json:"id"`
+	Data      string    `json:"data"`
+	UserID    *string   `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type ToolResultBlob struct {
+	ConversationID string          `json:"conversation_id"`
+	Hash           string          `json:"hash"`
+	Content        compressed.Text `json:"content"`
+	Size           int64           `json:"size"`
+	CreatedAt      time.Time       `json:"created_at"`
+}
+
+type UploadRef struct {
+	UploadID  string `json:"upload_id"`
+	MessageID string `json:"message_id"`
+}
+
+type UploadRefsBackfill struct {
+	ID             int64  `json:"id"`
+	AfterMessageID string `json:"after_message_id"`
+}
+
+type UserSetting struct {
+	UserID    string    `json:"user_id"`
+	Data      string    `json:"data"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
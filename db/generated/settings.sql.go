@@ -9,6 +9,20 @@ import (
 	"context"
 )
 
+const createSettingsHistory = `-- name: CreateSettingsHistory :exec
+INSERT INTO settings_history (data, user_id) VALUES (?, ?)
+`
+
+type CreateSettingsHistoryParams struct {
+	Data   string  `json:"data"`
+	UserID *string `json:"user_id"`
+}
+
+func (q *Queries) CreateSettingsHistory(ctx context.Context, arg CreateSettingsHistoryParams) error {
+	_, err := q.db.ExecContext(ctx, createSettingsHistory, arg.Data, arg.UserID)
+	return err
+}
+
 const getSettings = `-- name: GetSettings :one
 SELECT data FROM settings WHERE id = 1
 `
@@ -20,6 +34,65 @@ func (q *Queries) GetSettings(ctx context.Context) (string, error) {
 	return data, err
 }
 
+const getSettingsHistory = `-- name: GetSettingsHistory :one
+SELECT id, data, user_id, created_at FROM settings_history WHERE id = ?
+`
+
+func (q *Queries) GetSettingsHistory(ctx context.Context, id int64) (SettingsHistory, error) {
+	row := q.db.QueryRowContext(ctx, getSettingsHistory, id)
+	var i SettingsHistory
+	err := row.Scan(
+		&i.ID,
+		&i.Data,
+		&i.UserID,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getUserSettings = `-- name: GetUserSettings :one
+SELECT data FROM user_settings WHERE user_id = ?
+`
+
+func (q *Queries) GetUserSettings(ctx context.Context, userID string) (string, error) {
+	row := q.db.QueryRowContext(ctx, getUserSettings, userID)
+	var data string
+	err := row.Scan(&data)
+	return data, err
+}
+
+const listSettingsHistory = `-- name: ListSettingsHistory :many
+SELECT id, data, user_id, created_at FROM settings_history ORDER BY id DESC LIMIT ?
+`
+
+func (q *Queries) ListSettingsHistory(ctx context.Context, limit int64) ([]SettingsHistory, error) {
+	rows, err := q.db.QueryContext(ctx, listSettingsHistory, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []SettingsHistory{}
+	for rows.Next() {
+		var i SettingsHistory
+		if err := rows.Scan(
+			&i.ID,
+			&i.Data,
+			&i.UserID,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const updateSettings = `-- name: UpdateSettings :exec
 UPDATE settings SET data = ?, updated_at = CURRENT_TIMESTAMP WHERE id = 1
 `
@@ -28,3 +101,18 @@ func (q *Queries) UpdateSettings(ctx context.Context, data string) error {
 	_, err := q.db.ExecContext(ctx, updateSettings, data)
 	return err
 }
+
+const upsertUserSettings = `-- name: UpsertUserSettings :exec
+INSERT INTO user_settings (user_id, data) VALUES (?, ?)
+ON CONFLICT (user_id) DO UPDATE SET data = excluded.data, updated_at = CURRENT_TIMESTAMP
+`
+
+type UpsertUserSettingsParams struct {
+	UserID string `json:"user_id"`
+	Data   string `json:"data"`
+}
+
+func (q *Queries) UpsertUserSettings(ctx context.Context, arg UpsertUserSettingsParams) error {
+	_, err := q.db.ExecContext(ctx, upsertUserSettings, arg.UserID, arg.Data)
+	return err
+}
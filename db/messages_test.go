@@ -205,6 +205,57 @@ func TestMessageService_ListByConversation(t *testing.T) {
 	}
 }
 
+func TestMessageService_ListForContext(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	conv, err := db.CreateConversation(ctx, stringPtr("test-conversation"), true, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("Failed to create test conversation: %v", err)
+	}
+
+	list := func() []string {
+		t.Helper()
+		var messages []generated.Message
+		err := db.Queries(ctx, func(q *generated.Queries) error {
+			var err error
+			messages, err = q.ListMessagesForContext(ctx, conv.ConversationID)
+			return err
+		})
+		if err != nil {
+			t.Fatalf("ListMessagesForContext() error = %v", err)
+		}
+		var types []string
+		for _, msg := range messages {
+			types = append(types, msg.Type)
+		}
+		return types
+	}
+	create := func(types ...MessageType) {
+		t.Helper()
+		for _, msgType := range types {
+			if _, err := db.CreateMessage(ctx, CreateMessageParams{ConversationID: conv.ConversationID, Type: msgType}); err != nil {
+				t.Fatalf("Failed to create test message: %v", err)
+			}
+		}
+	}
+
+	// Without a summary, the whole conversation is context
+	create(MessageTypeSystem, MessageTypeUser, MessageTypeAgent)
+	if got := strings.Join(list(), ","); got != "system,user,agent" {
+		t.Errorf("Expected the whole conversation, got %s", got)
+	}
+
+	// The latest summary replaces everything before it but the system prompt
+	create(MessageTypeSummary, MessageTypeUser, MessageTypeAgent, MessageTypeSummary, MessageTypeUser)
+	if got := strings.Join(list(), ","); got != "system,summary,user" {
+		t.Errorf("Expected the system prompt and the latest summary on, got %s", got)
+	}
+}
+
 func TestMessageService_ListByType(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.Close()
@@ -455,3 +506,262 @@ func TestMessageService_CountByType(t *testing.T) {
 		t.Errorf("Expected 1 tool message, got %d", toolCount)
 	}
 }
+
+func TestRollbackConversation(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	conv, err := db.CreateConversation(ctx, stringPtr("test-conversation"), true, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("Failed to create test conversation: %v", err)
+	}
+	for i := 0; i < 4; i++ {
+		msg, err := db.CreateMessage(ctx, CreateMessageParams{
+			ConversationID: conv.ConversationID,
+			Type:           MessageTypeUser,
+			LLMData:        map[string]interface{}{"index": i},
+		})
+		if err != nil {
+			t.Fatalf("Failed to create test message %d: %v", i, err)
+		}
+		err = db.QueriesTx(ctx, func(q *generated.Queries) error {
+			return q.CreateCheckpoint(ctx, generated.CreateCheckpointParams{
+				ConversationID: conv.ConversationID,
+				SequenceID:     msg.SequenceID,
+				Cwd:            "/tmp",
+				GitCommit:      "commit",
+			})
+		})
+		if err != nil {
+			t.Fatalf("Failed to create checkpoint %d: %v", i, err)
+		}
+	}
+
+	if err := db.RollbackConversation(ctx, conv.ConversationID, 2); err != nil {
+		t.Fatalf("RollbackConversation() error = %v", err)
+	}
+
+	var messages []generated.Message
+	var checkpoints []generated.Checkpoint
+	var nextSeq int64
+	err = db.Queries(ctx, func(q *generated.Queries) error {
+		var err error
+		if messages, err = q.ListMessages(ctx, conv.ConversationID); err != nil {
+			return err
+		}
+		if checkpoints, err = q.ListCheckpoints(ctx, conv.ConversationID); err != nil {
+			return err
+		}
+		nextSeq, err = q.GetNextSequenceID(ctx, conv.ConversationID)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 2 || messages[1].SequenceID != 2 {
+		t.Errorf("Expected messages 1-2 to remain, got %d messages", len(messages))
+	}
+	if len(checkpoints) != 2 {
+		t.Errorf("Expected 2 checkpoints to remain, got %d", len(checkpoints))
+	}
+	// Sequence IDs of rolled-back messages are not reused
+	if nextSeq != 5 {
+		t.Errorf("Expected next sequence ID 5, got %d", nextSeq)
+	}
+}
+
+func TestDeleteMessage(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	conv, err := db.CreateConversation(ctx, stringPtr("test-conversation"), true, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("Failed to create test conversation: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := db.CreateMessage(ctx, CreateMessageParams{
+			ConversationID: conv.ConversationID,
+			Type:           MessageTypeUser,
+			LLMData:        map[string]interface{}{"index": i},
+		}); err != nil {
+			t.Fatalf("Failed to create test message %d: %v", i, err)
+		}
+	}
+
+	if err := db.DeleteMessage(ctx, conv.ConversationID, 2); err != nil {
+		t.Fatalf("DeleteMessage() error = %v", err)
+	}
+
+	var messages []generated.Message
+	err = db.Queries(ctx, func(q *generated.Queries) error {
+		var err error
+		messages, err = q.ListMessages(ctx, conv.ConversationID)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 2 || messages[0].SequenceID != 1 || messages[1].SequenceID != 3 {
+		t.Errorf("Expected messages 1 and 3 to remain, got %d messages", len(messages))
+	}
+}
+
+func TestMessageCompression(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	conv, err := db.CreateConversation(ctx, stringPtr("test-conversation"), true, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("Failed to create test conversation: %v", err)
+	}
+	output := strings.Repeat("line of tool output\n", 1000)
+	storage := func(messageID string) string {
+		t.Helper()
+		var typ string
+		err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+			return rx.QueryRow("SELECT typeof(llm_data) FROM messages WHERE message_id = ?", messageID).Scan(&typ)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return typ
+	}
+	readBack := func(messageID string) {
+		t.Helper()
+		msg, err := db.GetMessageByID(ctx, messageID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var data map[string]string
+		if err := json.Unmarshal([]byte(*msg.LlmData), &data); err != nil || data["output"] != output {
+			t.Errorf("expected the LLM data back, got %v", err)
+		}
+	}
+
+	// Large messages are stored compressed, small ones as they are
+	small, err := db.CreateMessage(ctx, CreateMessageParams{ConversationID: conv.ConversationID, Type: MessageTypeUser, LLMData: map[string]string{"text": "hi"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	large, err := db.CreateMessage(ctx, CreateMessageParams{ConversationID: conv.ConversationID, Type: MessageTypeTool, LLMData: map[string]string{"output": output}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := storage(small.MessageID); got != "text" {
+		t.Errorf("expected a small message stored as text, got %s", got)
+	}
+	if got := storage(large.MessageID); got != "blob" {
+		t.Errorf("expected a large message stored compressed, got %s", got)
+	}
+	readBack(large.MessageID)
+
+	// Messages stored before compression are compressed in place
+	data, _ := json.Marshal(map[string]string{"output": output})
+	if err := db.pool.Exec(ctx, "UPDATE messages SET llm_data = ? WHERE message_id = ?", string(data), large.MessageID); err != nil {
+		t.Fatal(err)
+	}
+	n, err := db.CompressMessages(ctx)
+	if err != nil || n != 1 {
+		t.Fatalf("expected one message compressed, got %d, %v", n, err)
+	}
+	if got := storage(large.MessageID); got != "blob" {
+		t.Errorf("expected the message compressed, got %s", got)
+	}
+	readBack(large.MessageID)
+	if n, err := db.CompressMessages(ctx); err != nil || n != 0 {
+		t.Errorf("expected nothing left to compress, got %d, %v", n, err)
+	}
+
+	// Decompressing stores them as plain JSON again, for older builds
+	if n, err := db.DecompressMessages(ctx); err != nil || n != 1 {
+		t.Fatalf("expected one message decompressed, got %d, %v", n, err)
+	}
+	if got := storage(large.MessageID); got != "text" {
+		t.Errorf("expected the message decompressed, got %s", got)
+	}
+	var stored string
+	err = db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		return rx.QueryRow("SELECT json_extract(llm_data, '$.output') FROM messages WHERE message_id = ?", large.MessageID).Scan(&stored)
+	})
+	if err != nil || stored != output {
+		t.Errorf("expected plain JSON stored, got %v", err)
+	}
+}
+
+func TestUploadRefs(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	ctx := t.Context()
+
+	conv, err := db.CreateConversation(ctx, stringPtr("uploads"), true, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const a = "upload_0123456789abcdef"
+	b := "upload_" + strings.Repeat("ab", 32)
+	msg, err := db.CreateMessage(ctx, CreateMessageParams{
+		ConversationID: conv.ConversationID,
+		Type:           MessageTypeUser,
+		LLMData:        map[string]string{"text": "see /tmp/" + a + ".png and " + a + ".png"},
+		DisplayData:    map[string]string{"path": "/tmp/" + b + ".txt"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.CreateMessage(ctx, CreateMessageParams{
+		ConversationID: conv.ConversationID,
+		Type:           MessageTypeAgent,
+		LLMData:        map[string]string{"text": "looked at " + a},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	refs, err := db.UploadRefCounts(ctx)
+	if err != nil || len(refs) != 2 || refs[a] != 2 || refs[b] != 1 {
+		t.Fatalf("expected references counted per message, got %v (%v)", refs, err)
+	}
+
+	// Changing a message's data changes its references
+	if err := db.UpdateMessageLLMData(ctx, msg.MessageID, map[string]string{"text": "nothing"}); err != nil {
+		t.Fatal(err)
+	}
+	if refs, _ := db.UploadRefCounts(ctx); refs[a] != 1 || refs[b] != 1 {
+		t.Errorf("expected the updated message's references replaced, got %v", refs)
+	}
+
+	// Messages stored before references were recorded are indexed once
+	if err := db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		if _, err := tx.Exec("DELETE FROM upload_refs"); err != nil {
+			return err
+		}
+		_, err := tx.Exec("INSERT OR REPLACE INTO upload_refs_backfill (id) VALUES (1)")
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := db.IndexUploadRefs(ctx); err != nil || n != 2 {
+		t.Fatalf("expected 2 messages indexed, got %d (%v)", n, err)
+	}
+	if n, err := db.IndexUploadRefs(ctx); err != nil || n != 0 {
+		t.Errorf("expected nothing left to index, got %d (%v)", n, err)
+	}
+	if refs, _ := db.UploadRefCounts(ctx); refs[a] != 1 || refs[b] != 1 {
+		t.Errorf("expected the references indexed, got %v", refs)
+	}
+
+	// And references go with their messages
+	if err := db.DeleteConversation(ctx, conv.ConversationID); err != nil {
+		t.Fatal(err)
+	}
+	if refs, _ := db.UploadRefCounts(ctx); len(refs) != 0 {
+		t.Errorf("expected no references left, got %v", refs)
+	}
+}
@@ -0,0 +1,238 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Migrations are the files in schema/ named NNN-name.sql, run in order of
+// their number and recorded in the migrations table. A migration can be
+// reverted if it has a down path, NNN-name.down.sql, which undoes it (and
+// which sqlc ignores).
+
+var migrationPattern = regexp.MustCompile(`^(\d{3})-.*\.sql$`)
+
+// migrationFile is a migration this build embeds.
+type migrationFile struct {
+	number int
+	name   string // the up file's name
+	down   string // the down file's name, if there is one
+}
+
+// Migration describes a migration, applied or not.
+type Migration struct {
+	Number int    `json:"number"`
+	Name   string `json:"name"`
+	// Applied says whether the migration was run. AppliedAt is when.
+	Applied   bool       `json:"applied"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+	// Reversible says whether the migration has a down path.
+	Reversible bool `json:"reversible"`
+	// Unknown is set on migrations applied by a newer build, which this one
+	// can't revert; a newer build should revert them before a downgrade.
+	Unknown bool `json:"unknown,omitempty"`
+}
+
+// MigrationStatus describes the database schema's version.
+type MigrationStatus struct {
+	// Version is the number of the latest migration applied.
+	Version int `json:"version"`
+	// Latest is the number of the latest migration this build has.
+	Latest int `json:"latest"`
+	// Pending counts the migrations this build has that aren't applied.
+	Pending    int         `json:"pending"`
+	Migrations []Migration `json:"migrations"`
+}
+
+// migrationFiles returns the embedded migrations in order.
+func migrationFiles() ([]migrationFile, error) {
+	entries, err := schemaFS.ReadDir("schema")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema directory: %w", err)
+	}
+	downs := make(map[string]string)
+	var files []migrationFile
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !migrationPattern.MatchString(name) {
+			continue
+		}
+		if up, ok := strings.CutSuffix(name, ".down.sql"); ok {
+			downs[up+".sql"] = name
+			continue
+		}
+		number, err := strconv.Atoi(migrationPattern.FindStringSubmatch(name)[1])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse migration number from %s: %w", name, err)
+		}
+		files = append(files, migrationFile{number: number, name: name})
+	}
+	for i := range files {
+		files[i].down = downs[files[i].name]
+	}
+	slices.SortFunc(files, func(a, b migrationFile) int { return a.number - b.number })
+	return files, nil
+}
+
+// appliedMigrations returns the migrations recorded as run, by number. It's
+// empty before the first migration creates the migrations table.
+func (db *DB) appliedMigrations(ctx context.Context) (map[int]Migration, error) {
+	applied := make(map[int]Migration)
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		var tableName string
+		err := rx.QueryRow("SELECT name FROM sqlite_master WHERE type='table' AND name='migrations'").Scan(&tableName)
+		if errors.Is(err, sql.ErrNoRows) {
+			slog.Info("migrations table not found, running all migrations")
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		rows, err := rx.Query("SELECT migration_number, migration_name, executed_at FROM migrations")
+		if err != nil {
+			return fmt.Errorf("failed to query executed migrations: %w", err)
+		}
+		defer rows.Close()
+		for rows.Next() {
+			m := Migration{Applied: true}
+			if err := rows.Scan(&m.Number, &m.Name, &m.AppliedAt); err != nil {
+				return fmt.Errorf("failed to scan migration: %w", err)
+			}
+			applied[m.Number] = m
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load executed migrations: %w", err)
+	}
+	return applied, nil
+}
+
+// MigrateUp runs the migrations not yet applied, up to and including the
+// one numbered to.
+func (db *DB) MigrateUp(ctx context.Context, to int) error {
+	files, err := migrationFiles()
+	if err != nil {
+		return err
+	}
+	applied, err := db.appliedMigrations(ctx)
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		if file.number > to {
+			break
+		}
+		if _, ok := applied[file.number]; ok {
+			continue
+		}
+		slog.Info("running migration", "file", file.name, "number", file.number)
+		if err := db.executeMigration(ctx, file.name); err != nil {
+			return fmt.Errorf("failed to execute migration %s: %w", file.name, err)
+		}
+		err = db.pool.Exec(ctx, "INSERT INTO migrations (migration_number, migration_name) VALUES (?, ?)", file.number, file.name)
+		if err != nil {
+			return fmt.Errorf("failed to record migration %s in migrations table: %w", file.name, err)
+		}
+	}
+	return nil
+}
+
+// MigrateDown reverts the applied migrations numbered above to, latest
+// first. It stops at the first one without a down path, leaving the
+// database at that migration.
+func (db *DB) MigrateDown(ctx context.Context, to int) error {
+	files, err := migrationFiles()
+	if err != nil {
+		return err
+	}
+	byNumber := make(map[int]migrationFile, len(files))
+	for _, file := range files {
+		byNumber[file.number] = file
+	}
+	applied, err := db.appliedMigrations(ctx)
+	if err != nil {
+		return err
+	}
+	numbers := make([]int, 0, len(applied))
+	for number := range applied {
+		if number > to {
+			numbers = append(numbers, number)
+		}
+	}
+	slices.Sort(numbers)
+	slices.Reverse(numbers)
+
+	for _, number := range numbers {
+		file, ok := byNumber[number]
+		if !ok {
+			return fmt.Errorf("migration %03d (%s) is unknown to this build; revert it with the build that applied it", number, applied[number].Name)
+		}
+		if file.down == "" {
+			return fmt.Errorf("migration %s can't be reverted: it has no down path", file.name)
+		}
+		content, err := schemaFS.ReadFile("schema/" + file.down)
+		if err != nil {
+			return fmt.Errorf("failed to read migration file %s: %w", file.down, err)
+		}
+		slog.Info("reverting migration", "file", file.name, "number", file.number)
+		err = db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+			for _, stmt := range splitSQLStatements(string(content)) {
+				if _, err := tx.Exec(stmt); err != nil {
+					return err
+				}
+			}
+			_, err := tx.Exec("DELETE FROM migrations WHERE migration_number = ?", file.number)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("failed to revert migration %s: %w", file.name, err)
+		}
+	}
+	return nil
+}
+
+// MigrationStatus returns the migrations this build has and those applied.
+func (db *DB) MigrationStatus(ctx context.Context) (MigrationStatus, error) {
+	files, err := migrationFiles()
+	if err != nil {
+		return MigrationStatus{}, err
+	}
+	applied, err := db.appliedMigrations(ctx)
+	if err != nil {
+		return MigrationStatus{}, err
+	}
+
+	var status MigrationStatus
+	for _, file := range files {
+		m := Migration{Number: file.number, Name: file.name, Reversible: file.down != ""}
+		if a, ok := applied[file.number]; ok {
+			m.Applied, m.AppliedAt = true, a.AppliedAt
+			delete(applied, file.number)
+		} else {
+			status.Pending++
+		}
+		status.Migrations = append(status.Migrations, m)
+		status.Latest = max(status.Latest, file.number)
+	}
+	for _, m := range applied {
+		m.Unknown = true
+		status.Migrations = append(status.Migrations, m)
+	}
+	slices.SortFunc(status.Migrations, func(a, b Migration) int { return a.Number - b.Number })
+	for _, m := range status.Migrations {
+		if m.Applied {
+			status.Version = max(status.Version, m.Number)
+		}
+	}
+	return status, nil
+}
@@ -2,10 +2,12 @@
 package db
 
 import (
+	"cmp"
 	"context"
 	"database/sql"
 	"fmt"
 	"runtime"
+	"slices"
 	"strings"
 	"time"
 )
@@ -16,17 +18,88 @@ import (
 // the semantics do not match SQLite well.
 //
 // Instead, we choose a single connection to use for writing (because
-// SQLite is single-writer) and use the rest as readers.
+// SQLite is single-writer) and use the rest as readers. See PoolConfig
+// for the number of each.
 type Pool struct {
 	db      *sql.DB
 	writer  chan *sql.Conn
 	readers chan *sql.Conn
 }
 
-func NewPool(dataSourceName string, readerCount int) (*Pool, error) {
+// Defaults for the PoolConfig fields left empty.
+const (
+	DefaultJournalMode = "wal"
+	DefaultSynchronous = "normal"
+	DefaultBusyTimeout = 5 * time.Second
+	DefaultReaders     = 10
+	DefaultWriters     = 1
+)
+
+// PoolConfig tunes a Pool's connections. Zero values mean the defaults.
+type PoolConfig struct {
+	// JournalMode is SQLite's journal_mode. WAL lets readers read while a
+	// write is in progress.
+	JournalMode string
+	// Synchronous is SQLite's synchronous level: off, normal, full or extra.
+	// Normal is durable with WAL except for the last writes on power loss.
+	Synchronous string
+	// BusyTimeout is how long a connection waits for a lock another holds,
+	// such as another process's write, before failing with SQLITE_BUSY.
+	BusyTimeout time.Duration
+	// Readers is the number of read-only connections.
+	Readers int
+	// Writers is the number of write connections. SQLite runs one write at
+	// a time: with one connection, writes wait their turn in the pool; with
+	// more, they wait on SQLite's lock for up to BusyTimeout.
+	Writers int
+}
+
+var (
+	journalModes      = []string{"delete", "truncate", "persist", "memory", "wal", "off"}
+	synchronousLevels = []string{"off", "normal", "full", "extra"}
+)
+
+// withDefaults returns the config with its empty fields set to the defaults,
+// or an error if a field is invalid.
+func (c PoolConfig) withDefaults() (PoolConfig, error) {
+	c.JournalMode = strings.ToLower(cmp.Or(c.JournalMode, DefaultJournalMode))
+	if !slices.Contains(journalModes, c.JournalMode) {
+		return c, fmt.Errorf("unknown journal mode %q (want one of %s)", c.JournalMode, strings.Join(journalModes, ", "))
+	}
+	c.Synchronous = strings.ToLower(cmp.Or(c.Synchronous, DefaultSynchronous))
+	if !slices.Contains(synchronousLevels, c.Synchronous) {
+		return c, fmt.Errorf("unknown synchronous level %q (want one of %s)", c.Synchronous, strings.Join(synchronousLevels, ", "))
+	}
+	if c.BusyTimeout < 0 || c.Readers < 0 || c.Writers < 0 {
+		return c, fmt.Errorf("busy timeout and connection counts can't be negative")
+	}
+	c.BusyTimeout = cmp.Or(c.BusyTimeout, DefaultBusyTimeout)
+	c.Readers = cmp.Or(c.Readers, DefaultReaders)
+	c.Writers = cmp.Or(c.Writers, DefaultWriters)
+	return c, nil
+}
+
+// pragmas returns the PRAGMAs to apply to each connection. busy_timeout
+// comes first, so that changing the journal mode waits for other processes.
+func (c PoolConfig) pragmas() []string {
+	return []string{
+		fmt.Sprintf("PRAGMA busy_timeout = %d;", c.BusyTimeout.Milliseconds()),
+		"PRAGMA journal_mode = " + c.JournalMode + ";",
+		"PRAGMA synchronous = " + c.Synchronous + ";",
+		"PRAGMA foreign_keys = ON;",
+		"PRAGMA mmap_size = 1073741824;", // 1GB - use OS page cache for reads
+		"PRAGMA cache_size = -65536;",    // 64MB (negative = KB)
+	}
+}
+
+func NewPool(dataSourceName string, cfg PoolConfig) (*Pool, error) {
 	if dataSourceName == ":memory:" {
 		return nil, fmt.Errorf(":memory: is not supported (because multiple conns are needed); use a temp file")
 	}
+	cfg, err := cfg.withDefaults()
+	if err != nil {
+		return nil, fmt.Errorf("NewPool: %w", err)
+	}
 	// TODO: a caller could override PRAGMA query_only.
 	// Consider opening two *sql.DBs, one configured as read-only,
 	// to ensure read-only transactions are always such.
@@ -34,17 +107,11 @@ func NewPool(dataSourceName string, readerCount int) (*Pool, error) {
 	if err != nil {
 		return nil, fmt.Errorf("NewPool: %w", err)
 	}
-	numConns := readerCount + 1
-	if err := InitPoolDB(db, numConns); err != nil {
-		return nil, fmt.Errorf("NewPool: %w", err)
-	}
-
-	// Performance PRAGMAs to apply to each connection
-	perfPragmas := []string{
-		"PRAGMA mmap_size = 1073741824;",  // 1GB - use OS page cache for reads
-		"PRAGMA cache_size = -65536;",     // 64MB (negative = KB)
-	}
+	numConns := cfg.Readers + cfg.Writers
+	InitPoolDB(db, numConns)
 
+	// The PRAGMAs are applied to the connections the pool keeps, since most
+	// of them only last as long as the connection
 	var conns []*sql.Conn
 	for i := 0; i < numConns; i++ {
 		conn, err := db.Conn(context.Background())
@@ -52,7 +119,7 @@ func NewPool(dataSourceName string, readerCount int) (*Pool, error) {
 			db.Close()
 			return nil, fmt.Errorf("NewPool: %w", err)
 		}
-		for _, pragma := range perfPragmas {
+		for _, pragma := range cfg.pragmas() {
 			// Use QueryContext because PRAGMAs return rows
 			rows, err := conn.QueryContext(context.Background(), pragma)
 			if err != nil {
@@ -70,11 +137,13 @@ func NewPool(dataSourceName string, readerCount int) (*Pool, error) {
 
 	p := &Pool{
 		db:      db,
-		writer:  make(chan *sql.Conn, 1),
-		readers: make(chan *sql.Conn, readerCount),
+		writer:  make(chan *sql.Conn, cfg.Writers),
+		readers: make(chan *sql.Conn, cfg.Readers),
+	}
+	for _, conn := range conns[:cfg.Writers] {
+		p.writer <- conn
 	}
-	p.writer <- conns[0]
-	for _, conn := range conns[1:] {
+	for _, conn := range conns[cfg.Writers:] {
 		if _, err := conn.ExecContext(context.Background(), "PRAGMA query_only=1;"); err != nil {
 			db.Close()
 			return nil, fmt.Errorf("NewPool query_only: %w", err)
@@ -86,43 +155,11 @@ func NewPool(dataSourceName string, readerCount int) (*Pool, error) {
 }
 
 // InitPoolDB fixes the database/sql pool to a set of fixed connections.
-func InitPoolDB(db *sql.DB, numConns int) error {
+func InitPoolDB(db *sql.DB, numConns int) {
 	db.SetMaxIdleConns(numConns)
 	db.SetMaxOpenConns(numConns)
 	db.SetConnMaxLifetime(-1)
 	db.SetConnMaxIdleTime(-1)
-
-	initQueries := []string{
-		"PRAGMA journal_mode=wal;",
-		"PRAGMA busy_timeout=1000;",
-		"PRAGMA foreign_keys=ON;",
-	}
-
-	var conns []*sql.Conn
-	for i := 0; i < numConns; i++ {
-		conn, err := db.Conn(context.Background())
-		if err != nil {
-			db.Close()
-			return fmt.Errorf("InitPoolDB: %w", err)
-		}
-		for _, q := range initQueries {
-			// Use QueryContext for PRAGMAs that return rows (like journal_mode)
-			rows, err := conn.QueryContext(context.Background(), q)
-			if err != nil {
-				db.Close()
-				return fmt.Errorf("InitPoolDB %d: %w", i, err)
-			}
-			rows.Close()
-		}
-		conns = append(conns, conn)
-	}
-	for _, conn := range conns {
-		if err := conn.Close(); err != nil {
-			db.Close()
-			return fmt.Errorf("InitPoolDB: %w", err)
-		}
-	}
-	return nil
 }
 
 func (p *Pool) Close() error {
@@ -182,7 +219,7 @@ func (p *Pool) Tx(ctx context.Context, fn func(ctx context.Context, tx *Tx) erro
 	// If the context is closed, we want BEGIN to succeed and then
 	// we roll it back later.
 	if _, err := conn.ExecContext(context.WithoutCancel(ctx), "BEGIN IMMEDIATE;"); err != nil {
-		if strings.Contains(err.Error(), "SQLITE_BUSY") {
+		if isBusy(err) {
 			p.writer <- conn
 			return fmt.Errorf("Tx begin: %w", err)
 		}
@@ -230,7 +267,7 @@ func (p *Pool) Rx(ctx context.Context, fn func(ctx context.Context, rx *Rx) erro
 	// If the context is closed, we want BEGIN to succeed and then
 	// we roll it back later.
 	if _, err := conn.ExecContext(context.WithoutCancel(ctx), "BEGIN;"); err != nil {
-		if strings.Contains(err.Error(), "SQLITE_BUSY") {
+		if isBusy(err) {
 			p.readers <- conn
 			return fmt.Errorf("Rx begin: %w", err)
 		}
@@ -265,7 +302,7 @@ func (p *Pool) rollback(ctx context.Context, txType string, txErr error, conn *s
 		// In good operation, we should never see any of these.
 		//
 		// TODO: confirm this check works on all sqlite drivers.
-		if !strings.Contains(err.Error(), "SQLITE_BUSY") {
+		if !isBusy(err) {
 			conn.Close()
 			p.db.Close()
 		}
@@ -274,6 +311,14 @@ func (p *Pool) rollback(ctx context.Context, txType string, txErr error, conn *s
 	return txErr
 }
 
+// isBusy reports whether err is SQLITE_BUSY, which libSQL reports as
+// "database is locked": another connection held a lock for longer than the
+// busy timeout. The connection is still fine.
+func isBusy(err error) bool {
+	msg := err.Error()
+	return strings.Contains(msg, "SQLITE_BUSY") || strings.Contains(msg, "database is locked")
+}
+
 type Tx struct {
 	*Rx
 	Now time.Time
@@ -0,0 +1,29 @@
+-- name: CreateArtifact :one
+INSERT INTO artifacts (artifact_id, conversation_id, name, content_type, size, sha256, expires_at)
+VALUES (?, ?, ?, ?, ?, ?, ?)
+RETURNING *;
+
+-- name: GetArtifact :one
+SELECT * FROM artifacts
+WHERE conversation_id = ? AND artifact_id = ?;
+
+-- name: ListArtifacts :many
+SELECT * FROM artifacts
+WHERE conversation_id = ?
+ORDER BY created_at, artifact_id;
+
+-- name: GetArtifactsSize :one
+-- Returns the total size of a conversation's artifacts.
+SELECT CAST(COALESCE(SUM(size), 0) AS INTEGER) AS total_size FROM artifacts
+WHERE conversation_id = ?;
+
+-- name: ListArtifactIDs :many
+SELECT artifact_id FROM artifacts;
+
+-- name: DeleteArtifact :exec
+DELETE FROM artifacts
+WHERE conversation_id = ? AND artifact_id = ?;
+
+-- name: DeleteExpiredArtifacts :execrows
+DELETE FROM artifacts
+WHERE expires_at <= ?;
@@ -0,0 +1,18 @@
+-- name: CreateBridgeThread :one
+INSERT INTO bridge_threads (platform, thread_id, conversation_id)
+VALUES (?, ?, ?)
+RETURNING *;
+
+-- name: DeleteBridgeThread :exec
+DELETE FROM bridge_threads
+WHERE platform = ? AND thread_id = ?;
+
+-- name: GetBridgeThread :one
+SELECT * FROM bridge_threads
+WHERE platform = ? AND thread_id = ?;
+
+-- name: GetBridgeThreadByConversation :one
+SELECT * FROM bridge_threads
+WHERE conversation_id = ?
+ORDER BY created_at DESC
+LIMIT 1;
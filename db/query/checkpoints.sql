@@ -0,0 +1,22 @@
+-- name: CreateCheckpoint :exec
+INSERT OR REPLACE INTO checkpoints (conversation_id, sequence_id, cwd, git_commit)
+VALUES (?, ?, ?, ?);
+
+-- name: GetCheckpointAfter :one
+SELECT * FROM checkpoints
+WHERE conversation_id = ? AND sequence_id > ?
+ORDER BY sequence_id ASC
+LIMIT 1;
+
+-- name: ListCheckpoints :many
+SELECT * FROM checkpoints
+WHERE conversation_id = ?
+ORDER BY sequence_id ASC;
+
+-- name: DeleteCheckpointsAfter :exec
+DELETE FROM checkpoints
+WHERE conversation_id = ? AND sequence_id > ?;
+
+-- name: DeleteCheckpoints :exec
+DELETE FROM checkpoints
+WHERE conversation_id = ?;
@@ -0,0 +1,39 @@
+-- name: SetCIAutofix :one
+-- Turns auto-fix on for a conversation, starting its attempts and budget over.
+INSERT INTO ci_autofix (conversation_id, pr_url, max_attempts, max_cost_usd)
+VALUES (?, ?, ?, ?)
+ON CONFLICT (conversation_id) DO UPDATE SET
+    pr_url = excluded.pr_url,
+    max_attempts = excluded.max_attempts,
+    max_cost_usd = excluded.max_cost_usd,
+    attempts = 0,
+    last_sha = '',
+    status = 'watching',
+    created_at = CURRENT_TIMESTAMP,
+    updated_at = CURRENT_TIMESTAMP
+RETURNING *;
+
+-- name: GetCIAutofix :one
+SELECT * FROM ci_autofix
+WHERE conversation_id = ?;
+
+-- name: ListWatchingCIAutofix :many
+SELECT * FROM ci_autofix
+WHERE status = 'watching'
+ORDER BY created_at ASC;
+
+-- name: UpdateCIAutofix :exec
+UPDATE ci_autofix
+SET attempts = ?, last_sha = ?, status = ?, updated_at = CURRENT_TIMESTAMP
+WHERE conversation_id = ?;
+
+-- name: DeleteCIAutofix :exec
+DELETE FROM ci_autofix
+WHERE conversation_id = ?;
+
+-- name: GetCIAutofixCost :one
+-- What a conversation has cost since auto-fix was turned on.
+SELECT CAST(COALESCE(SUM(json_extract(m.usage_data, '$.cost_usd')), 0) AS REAL) AS cost_usd
+FROM messages m
+JOIN ci_autofix a ON a.conversation_id = m.conversation_id
+WHERE m.conversation_id = ? AND m.usage_data IS NOT NULL AND m.created_at >= a.created_at;
@@ -0,0 +1,21 @@
+-- name: AcquireConversationLease :execrows
+-- Takes or renews a conversation's lease for owner, unless another owner
+-- holds it and it hasn't expired by now.
+INSERT INTO conversation_leases (conversation_id, owner, expires_at)
+VALUES (sqlc.arg(conversation_id), sqlc.arg(owner), sqlc.arg(expires_at))
+ON CONFLICT (conversation_id) DO UPDATE SET
+    owner = excluded.owner,
+    expires_at = excluded.expires_at
+WHERE conversation_leases.owner = excluded.owner OR conversation_leases.expires_at <= sqlc.arg(now);
+
+-- name: GetConversationLease :one
+SELECT * FROM conversation_leases
+WHERE conversation_id = ?;
+
+-- name: ReleaseConversationLease :exec
+DELETE FROM conversation_leases
+WHERE conversation_id = ? AND owner = ?;
+
+-- name: ReleaseOwnerLeases :exec
+DELETE FROM conversation_leases
+WHERE owner = ?;
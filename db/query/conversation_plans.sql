@@ -0,0 +1,13 @@
+-- name: CreateConversationPlan :one
+INSERT INTO conversation_plans (conversation_id, allowed_tools)
+VALUES (?, ?)
+RETURNING *;
+
+-- name: GetConversationPlan :one
+SELECT * FROM conversation_plans
+WHERE conversation_id = ?;
+
+-- name: UpdateConversationPlan :exec
+UPDATE conversation_plans
+SET status = ?, plan = ?, updated_at = CURRENT_TIMESTAMP
+WHERE conversation_id = ?;
@@ -8,6 +8,61 @@ UPDATE conversations
 SET model_id = ?, updated_at = CURRENT_TIMESTAMP
 WHERE conversation_id = ?;
 
+-- name: UpdateConversationUserID :exec
+UPDATE conversations
+SET user_id = ?
+WHERE conversation_id = ?;
+
+-- name: UpdateConversationAllowedTools :exec
+UPDATE conversations
+SET allowed_tools = ?
+WHERE conversation_id = ?;
+
+-- name: UpdateConversationGuidanceFiles :exec
+UPDATE conversations
+SET guidance_files = ?
+WHERE conversation_id = ?;
+
+-- name: UpdateConversationNotifyEvents :exec
+UPDATE conversations
+SET notify_events = ?
+WHERE conversation_id = ?;
+
+-- name: UpdateConversationDevcontainer :exec
+UPDATE conversations
+SET devcontainer = ?
+WHERE conversation_id = ?;
+
+-- name: UpdateConversationProject :exec
+UPDATE conversations
+SET project_id = ?
+WHERE conversation_id = ?;
+
+-- name: UpdateConversationRemote :exec
+UPDATE conversations
+SET remote = ?
+WHERE conversation_id = ?;
+
+-- name: UpdateConversationSampling :exec
+UPDATE conversations
+SET sampling = ?
+WHERE conversation_id = ?;
+
+-- name: UpdateConversationSandbox :exec
+UPDATE conversations
+SET sandbox = ?
+WHERE conversation_id = ?;
+
+-- name: UpdateConversationThinking :exec
+UPDATE conversations
+SET thinking = ?
+WHERE conversation_id = ?;
+
+-- name: UpdateConversationTools :exec
+UPDATE conversations
+SET tools = ?
+WHERE conversation_id = ?;
+
 -- name: GetConversation :one
 SELECT * FROM conversations
 WHERE conversation_id = ?;
@@ -15,13 +70,27 @@ WHERE conversation_id = ?;
 -- name: ListConversations :many
 SELECT * FROM conversations
 WHERE archived = FALSE
-ORDER BY updated_at DESC
+ORDER BY pinned DESC, updated_at DESC
 LIMIT ? OFFSET ?;
 
 -- name: ListAllActiveConversations :many
 SELECT * FROM conversations
 WHERE archived = FALSE
-ORDER BY updated_at DESC;
+ORDER BY pinned DESC, updated_at DESC;
+
+-- name: ListInterruptedConversations :many
+-- Conversations the agent may be working on: those agent_working says so,
+-- and those whose latest message is the user's, in case the flag wasn't
+-- updated after it.
+SELECT * FROM conversations c
+WHERE c.archived = FALSE AND (c.agent_working = TRUE OR (
+  SELECT m.type FROM messages m
+  WHERE m.conversation_id = c.conversation_id AND m.deleted_at IS NULL
+    AND m.type NOT IN ('gitinfo', 'summary', 'system')
+  ORDER BY m.sequence_id DESC
+  LIMIT 1
+) = 'user')
+ORDER BY c.updated_at DESC;
 
 -- name: ListArchivedConversations :many
 SELECT * FROM conversations
@@ -32,7 +101,7 @@ LIMIT ? OFFSET ?;
 -- name: SearchConversations :many
 SELECT * FROM conversations
 WHERE slug LIKE '%' || ? || '%' AND archived = FALSE
-ORDER BY updated_at DESC
+ORDER BY pinned DESC, updated_at DESC
 LIMIT ? OFFSET ?;
 
 -- name: SearchArchivedConversations :many
@@ -41,6 +110,12 @@ WHERE slug LIKE '%' || ? || '%' AND archived = TRUE
 ORDER BY updated_at DESC
 LIMIT ? OFFSET ?;
 
+-- name: UpdateConversationPinned :one
+UPDATE conversations
+SET pinned = ?
+WHERE conversation_id = ?
+RETURNING *;
+
 -- name: UpdateConversationSlug :one
 UPDATE conversations
 SET slug = ?, updated_at = CURRENT_TIMESTAMP
@@ -0,0 +1,16 @@
+-- name: DeleteCustomModel :execrows
+DELETE FROM custom_models
+WHERE model_id = ?;
+
+-- name: ListCustomModels :many
+SELECT * FROM custom_models
+ORDER BY created_at, model_id;
+
+-- name: UpsertCustomModel :one
+INSERT INTO custom_models (model_id, url, api_key, model_name)
+VALUES (?, ?, ?, ?)
+ON CONFLICT (model_id) DO UPDATE SET
+    url = excluded.url,
+    api_key = excluded.api_key,
+    model_name = excluded.model_name
+RETURNING *;
@@ -0,0 +1,19 @@
+-- name: CreateDocChunk :exec
+INSERT INTO doc_chunks (root, model, path, chunk_index, start_line, file_hash, content, vector)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?);
+
+-- name: ListDocFileHashes :many
+SELECT DISTINCT path, file_hash FROM doc_chunks
+WHERE root = ? AND model = ?;
+
+-- name: DeleteDocFileChunks :exec
+DELETE FROM doc_chunks
+WHERE root = ? AND model = ? AND path = ?;
+
+-- name: SearchDocChunks :many
+SELECT path, start_line, content,
+       CAST(vector_distance_cos(vector, sqlc.arg(query)) AS REAL) AS distance
+FROM doc_chunks
+WHERE root = sqlc.arg(root) AND model = sqlc.arg(model)
+ORDER BY distance ASC
+LIMIT sqlc.arg(limit);
@@ -0,0 +1,39 @@
+-- name: CreateEmbedding :exec
+INSERT OR REPLACE INTO embeddings (source_type, source_id, conversation_id, model, content, vector)
+VALUES (?, ?, ?, ?, ?, ?);
+
+-- name: ListUnembeddedMessages :many
+SELECT m.* FROM messages m
+WHERE m.type IN ('user', 'agent')
+  AND m.deleted_at IS NULL
+  AND NOT EXISTS (
+    SELECT 1 FROM embeddings e
+    WHERE e.source_type = 'message' AND e.source_id = m.message_id AND e.model = ?
+  )
+ORDER BY m.created_at ASC
+LIMIT ?;
+
+-- name: ListUnembeddedMemories :many
+SELECT mem.* FROM memories mem
+WHERE NOT EXISTS (
+    SELECT 1 FROM embeddings e
+    WHERE e.source_type = 'memory' AND e.source_id = mem.memory_id AND e.model = ?
+  )
+ORDER BY mem.created_at ASC
+LIMIT ?;
+
+-- name: DeleteSourceEmbeddings :exec
+DELETE FROM embeddings
+WHERE source_type = ? AND source_id = ?;
+
+-- name: DeleteConversationEmbeddings :exec
+DELETE FROM embeddings
+WHERE conversation_id = ?;
+
+-- name: DeleteDeletedMessageEmbeddings :exec
+DELETE FROM embeddings
+WHERE source_type = 'message'
+  AND source_id IN (
+    SELECT message_id FROM messages
+    WHERE conversation_id = ? AND deleted_at IS NOT NULL
+  );
@@ -0,0 +1,18 @@
+-- name: CreateFileEdit :one
+INSERT INTO file_edits (edit_id, conversation_id, sequence_id, tool_use_id, source, path, new_file, deleted_file, before_content, after_content)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+RETURNING *;
+
+-- name: GetFileEdit :one
+SELECT * FROM file_edits
+WHERE edit_id = ?;
+
+-- name: ListFileEdits :many
+SELECT * FROM file_edits
+WHERE conversation_id = ?
+ORDER BY rowid ASC;
+
+-- name: MarkFileEditReverted :exec
+UPDATE file_edits
+SET reverted_at = CURRENT_TIMESTAMP
+WHERE edit_id = ?;
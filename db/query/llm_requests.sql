@@ -0,0 +1,10 @@
+-- name: CreateLLMRequest :one
+INSERT INTO llm_requests (conversation_id, message_id, model, provider, url, request_body, response_body, status_code, error, duration_ms, llm_request, llm_response)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+RETURNING *;
+
+-- name: GetLLMRequestByMessageID :one
+SELECT * FROM llm_requests
+WHERE message_id = ?
+ORDER BY id DESC
+LIMIT 1;
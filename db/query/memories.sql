@@ -0,0 +1,27 @@
+-- name: CreateMemory :one
+INSERT INTO memories (memory_id, project, content, source, conversation_id)
+VALUES (?, ?, ?, ?, ?)
+RETURNING *;
+
+-- name: GetMemory :one
+SELECT * FROM memories
+WHERE memory_id = ?;
+
+-- name: ListMemories :many
+SELECT * FROM memories
+ORDER BY project, created_at ASC;
+
+-- name: ListMemoriesByProject :many
+SELECT * FROM memories
+WHERE project = ?
+ORDER BY created_at ASC;
+
+-- name: UpdateMemory :one
+UPDATE memories
+SET content = ?, updated_at = CURRENT_TIMESTAMP
+WHERE memory_id = ?
+RETURNING *;
+
+-- name: DeleteMemory :exec
+DELETE FROM memories
+WHERE memory_id = ?;
@@ -0,0 +1,23 @@
+-- name: DeleteMessageFeedback :execrows
+DELETE FROM message_feedback
+WHERE message_id = ? AND conversation_id = ?;
+
+-- name: ListConversationFeedback :many
+SELECT * FROM message_feedback
+WHERE conversation_id = ?
+ORDER BY created_at, message_id;
+
+-- name: ListMessageFeedback :many
+SELECT * FROM message_feedback
+ORDER BY updated_at DESC, message_id
+LIMIT ?;
+
+-- name: UpsertMessageFeedback :one
+INSERT INTO message_feedback (message_id, conversation_id, model, rating, comment)
+VALUES (?, ?, ?, ?, ?)
+ON CONFLICT (message_id) DO UPDATE SET
+    model = excluded.model,
+    rating = COALESCE(excluded.rating, message_feedback.rating),
+    comment = COALESCE(excluded.comment, message_feedback.comment),
+    updated_at = CURRENT_TIMESTAMP
+RETURNING *;
@@ -14,23 +14,44 @@ WHERE message_id = ?;
 
 -- name: ListMessages :many
 SELECT * FROM messages
-WHERE conversation_id = ?
+WHERE conversation_id = ? AND deleted_at IS NULL
 ORDER BY sequence_id ASC;
 
 -- name: ListMessagesPaginated :many
 SELECT * FROM messages
-WHERE conversation_id = ?
+WHERE conversation_id = ? AND deleted_at IS NULL
 ORDER BY sequence_id ASC
 LIMIT ? OFFSET ?;
 
+-- name: ListMessagesBefore :many
+SELECT * FROM messages
+WHERE conversation_id = ? AND sequence_id < ? AND deleted_at IS NULL
+ORDER BY sequence_id DESC
+LIMIT ?;
+
+-- name: ListMessagesAfter :many
+SELECT * FROM messages
+WHERE conversation_id = ? AND sequence_id > ? AND deleted_at IS NULL
+ORDER BY sequence_id ASC
+LIMIT ?;
+
+-- name: ListMessagesForContext :many
+SELECT * FROM messages
+WHERE conversation_id = ? AND deleted_at IS NULL
+  AND (type = 'system' OR sequence_id >= COALESCE((
+    SELECT MAX(s.sequence_id) FROM messages s
+    WHERE s.conversation_id = messages.conversation_id AND s.type = 'summary' AND s.deleted_at IS NULL
+  ), 0))
+ORDER BY sequence_id ASC;
+
 -- name: ListMessagesByType :many
 SELECT * FROM messages
-WHERE conversation_id = ? AND type = ?
+WHERE conversation_id = ? AND type = ? AND deleted_at IS NULL
 ORDER BY sequence_id ASC;
 
 -- name: GetLatestMessage :one
 SELECT * FROM messages
-WHERE conversation_id = ?
+WHERE conversation_id = ? AND deleted_at IS NULL
 ORDER BY sequence_id DESC
 LIMIT 1;
 
@@ -44,13 +65,68 @@ WHERE conversation_id = ?;
 
 -- name: CountMessagesInConversation :one
 SELECT COUNT(*) FROM messages
-WHERE conversation_id = ?;
+WHERE conversation_id = ? AND deleted_at IS NULL;
 
 -- name: CountMessagesByType :one
 SELECT COUNT(*) FROM messages
-WHERE conversation_id = ? AND type = ?;
+WHERE conversation_id = ? AND type = ? AND deleted_at IS NULL;
 
 -- name: ListMessagesSince :many
 SELECT * FROM messages
-WHERE conversation_id = ? AND sequence_id > ?
+WHERE conversation_id = ? AND sequence_id > ? AND deleted_at IS NULL
 ORDER BY sequence_id ASC;
+
+-- name: ListMessagesSinceLastAgent :many
+-- The latest agent message and those after it, or every message if the
+-- agent hasn't answered yet.
+SELECT * FROM messages
+WHERE conversation_id = ? AND deleted_at IS NULL
+  AND sequence_id >= COALESCE((
+    SELECT MAX(a.sequence_id) FROM messages a
+    WHERE a.conversation_id = messages.conversation_id AND a.type = 'agent' AND a.deleted_at IS NULL
+  ), 0)
+ORDER BY sequence_id ASC;
+
+-- name: SoftDeleteMessage :exec
+UPDATE messages
+SET deleted_at = CURRENT_TIMESTAMP
+WHERE conversation_id = ? AND sequence_id = ? AND deleted_at IS NULL;
+
+-- name: SoftDeleteMessagesAfter :exec
+UPDATE messages
+SET deleted_at = CURRENT_TIMESTAMP
+WHERE conversation_id = ? AND sequence_id > ? AND deleted_at IS NULL;
+
+-- name: ReplaceMessage :one
+UPDATE messages
+SET sequence_id = ?, type = ?, llm_data = ?, user_data = ?, usage_data = ?, display_data = ?
+WHERE message_id = ?
+RETURNING *;
+
+-- name: UpdateMessageLLMData :exec
+UPDATE messages
+SET llm_data = ?
+WHERE message_id = ?;
+
+-- name: ListUncompressedMessages :many
+SELECT message_id, llm_data FROM messages
+WHERE message_id > ? AND typeof(llm_data) = 'text' AND length(CAST(llm_data AS BLOB)) >= sqlc.arg(min_size)
+ORDER BY message_id
+LIMIT ?;
+
+-- name: ListCompressedMessages :many
+SELECT message_id, llm_data FROM messages
+WHERE message_id > ? AND typeof(llm_data) = 'blob'
+ORDER BY message_id
+LIMIT ?;
+
+-- name: UpdateMessageLLMDataText :exec
+UPDATE messages
+SET llm_data = CAST(sqlc.arg(llm_data) AS TEXT)
+WHERE message_id = sqlc.arg(message_id);
+
+-- name: ListMessageData :many
+SELECT message_id, llm_data, user_data, display_data FROM messages
+WHERE message_id > ?
+ORDER BY message_id
+LIMIT ?;
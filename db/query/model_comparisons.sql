@@ -0,0 +1,18 @@
+-- name: CreateModelComparison :one
+INSERT INTO model_comparisons (comparison_id, conversation_id, message, model_a, conversation_a, model_b, conversation_b)
+VALUES (?, ?, ?, ?, ?, ?, ?)
+RETURNING *;
+
+-- name: GetModelComparison :one
+SELECT * FROM model_comparisons
+WHERE comparison_id = ?;
+
+-- name: ListModelComparisons :many
+SELECT * FROM model_comparisons
+ORDER BY created_at DESC, comparison_id
+LIMIT ?;
+
+-- name: UpdateModelComparisonWinner :exec
+UPDATE model_comparisons
+SET winner = ?
+WHERE comparison_id = ?;
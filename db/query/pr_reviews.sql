@@ -0,0 +1,18 @@
+-- name: CreatePRReview :one
+INSERT INTO pr_reviews (conversation_id, pr_url, head_sha, post)
+VALUES (?, ?, ?, ?)
+RETURNING *;
+
+-- name: GetPRReview :one
+SELECT * FROM pr_reviews
+WHERE conversation_id = ?;
+
+-- name: UpdatePRReview :exec
+UPDATE pr_reviews
+SET review = ?, posted_url = NULL, updated_at = CURRENT_TIMESTAMP
+WHERE conversation_id = ?;
+
+-- name: UpdatePRReviewPosted :exec
+UPDATE pr_reviews
+SET posted_url = ?, updated_at = CURRENT_TIMESTAMP
+WHERE conversation_id = ?;
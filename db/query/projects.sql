@@ -0,0 +1,27 @@
+-- name: CreateProject :one
+INSERT INTO projects (project_id, name, root_path, default_model, allowed_tools, git_origin)
+VALUES (?, ?, ?, ?, ?, ?)
+RETURNING *;
+
+-- name: GetProject :one
+SELECT * FROM projects
+WHERE project_id = ?;
+
+-- name: ListProjects :many
+SELECT * FROM projects
+ORDER BY name, created_at ASC;
+
+-- name: UpdateProject :one
+UPDATE projects
+SET name = ?, root_path = ?, default_model = ?, allowed_tools = ?, git_origin = ?, updated_at = CURRENT_TIMESTAMP
+WHERE project_id = ?
+RETURNING *;
+
+-- name: DeleteProject :exec
+DELETE FROM projects
+WHERE project_id = ?;
+
+-- name: ClearConversationsProject :exec
+UPDATE conversations
+SET project_id = NULL
+WHERE project_id = ?;
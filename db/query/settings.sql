@@ -3,3 +3,19 @@ SELECT data FROM settings WHERE id = 1;
 
 -- name: UpdateSettings :exec
 UPDATE settings SET data = ?, updated_at = CURRENT_TIMESTAMP WHERE id = 1;
+
+-- name: GetUserSettings :one
+SELECT data FROM user_settings WHERE user_id = ?;
+
+-- name: UpsertUserSettings :exec
+INSERT INTO user_settings (user_id, data) VALUES (?, ?)
+ON CONFLICT (user_id) DO UPDATE SET data = excluded.data, updated_at = CURRENT_TIMESTAMP;
+
+-- name: CreateSettingsHistory :exec
+INSERT INTO settings_history (data, user_id) VALUES (?, ?);
+
+-- name: GetSettingsHistory :one
+SELECT * FROM settings_history WHERE id = ?;
+
+-- name: ListSettingsHistory :many
+SELECT * FROM settings_history ORDER BY id DESC LIMIT ?;
@@ -0,0 +1,17 @@
+-- name: CreateConversationShare :one
+INSERT INTO conversation_shares (token, conversation_id, user_id)
+VALUES (?, ?, ?)
+RETURNING *;
+
+-- name: GetConversationShare :one
+SELECT * FROM conversation_shares
+WHERE token = ?;
+
+-- name: ListConversationShares :many
+SELECT * FROM conversation_shares
+WHERE conversation_id = ?
+ORDER BY created_at DESC;
+
+-- name: DeleteConversationShare :execrows
+DELETE FROM conversation_shares
+WHERE token = ? AND conversation_id = ?;
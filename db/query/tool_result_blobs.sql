@@ -0,0 +1,15 @@
+-- name: PutToolResultBlob :exec
+INSERT INTO tool_result_blobs (conversation_id, hash, content, size)
+VALUES (?, ?, ?, ?)
+ON CONFLICT (conversation_id, hash) DO NOTHING;
+
+-- name: GetToolResultBlob :one
+SELECT * FROM tool_result_blobs
+WHERE conversation_id = ? AND hash = ?;
+
+-- name: CopyToolResultBlobs :exec
+-- Copies a conversation's blobs to another, for the messages copied to it.
+INSERT INTO tool_result_blobs (conversation_id, hash, content, size, created_at)
+SELECT sqlc.arg(to_conversation_id), hash, content, size, created_at FROM tool_result_blobs
+WHERE conversation_id = sqlc.arg(from_conversation_id)
+ON CONFLICT (conversation_id, hash) DO NOTHING;
@@ -0,0 +1,23 @@
+-- name: InsertUploadRef :exec
+INSERT INTO upload_refs (upload_id, message_id)
+VALUES (?, ?)
+ON CONFLICT (upload_id, message_id) DO NOTHING;
+
+-- name: DeleteMessageUploadRefs :exec
+DELETE FROM upload_refs
+WHERE message_id = ?;
+
+-- name: CountUploadRefs :many
+SELECT upload_id, COUNT(*) AS refs FROM upload_refs
+GROUP BY upload_id;
+
+-- name: GetUploadRefsBackfill :one
+SELECT after_message_id FROM upload_refs_backfill
+WHERE id = 1;
+
+-- name: UpdateUploadRefsBackfill :exec
+UPDATE upload_refs_backfill SET after_message_id = ?
+WHERE id = 1;
+
+-- name: DeleteUploadRefsBackfill :exec
+DELETE FROM upload_refs_backfill;
@@ -0,0 +1,21 @@
+-- name: ListUsage :many
+-- Usage per conversation, day (UTC) and model, for messages that reported usage.
+SELECT m.conversation_id,
+       c.slug,
+       CAST(COALESCE(c.user_id, '') AS TEXT) AS user_id,
+       CAST(date(m.created_at) AS TEXT) AS day,
+       CAST(COALESCE(NULLIF(json_extract(m.usage_data, '$.model'), ''), c.model_id, '') AS TEXT) AS model,
+       CAST(COUNT(*) AS INTEGER) AS requests,
+       CAST(SUM(COALESCE(json_extract(m.usage_data, '$.input_tokens'), 0)) AS INTEGER) AS input_tokens,
+       CAST(SUM(COALESCE(json_extract(m.usage_data, '$.cache_creation_input_tokens'), 0)) AS INTEGER) AS cache_creation_input_tokens,
+       CAST(SUM(COALESCE(json_extract(m.usage_data, '$.cache_read_input_tokens'), 0)) AS INTEGER) AS cache_read_input_tokens,
+       CAST(SUM(COALESCE(json_extract(m.usage_data, '$.output_tokens'), 0)) AS INTEGER) AS output_tokens,
+       CAST(SUM(COALESCE(json_extract(m.usage_data, '$.cost_usd'), 0)) AS REAL) AS cost_usd
+FROM messages m
+JOIN conversations c ON c.conversation_id = m.conversation_id
+WHERE m.usage_data IS NOT NULL
+  AND (json_extract(m.usage_data, '$.input_tokens') > 0 OR json_extract(m.usage_data, '$.output_tokens') > 0)
+  AND date(m.created_at) >= sqlc.arg(from_day)
+  AND date(m.created_at) <= sqlc.arg(to_day)
+GROUP BY m.conversation_id, day, model
+ORDER BY day ASC, m.conversation_id ASC;
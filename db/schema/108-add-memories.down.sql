@@ -0,0 +1,2 @@
+-- Reverts 108-add-memories.sql.
+DROP TABLE memories;
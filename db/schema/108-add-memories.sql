@@ -0,0 +1,15 @@
+-- Memories table
+-- Durable notes saved by the agent or the user, keyed by project (git origin or cwd)
+-- and injected into the system prompt of new conversations for that project.
+
+CREATE TABLE memories (
+    memory_id TEXT PRIMARY KEY,
+    project TEXT NOT NULL,
+    content TEXT NOT NULL,
+    source TEXT NOT NULL CHECK (source IN ('agent', 'user')),
+    conversation_id TEXT,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_memories_project ON memories(project);
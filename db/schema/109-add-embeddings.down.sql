@@ -0,0 +1,2 @@
+-- Reverts 109-add-embeddings.sql.
+DROP TABLE embeddings;
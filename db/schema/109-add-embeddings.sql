@@ -0,0 +1,18 @@
+-- Embeddings table
+-- Vectors for semantic search over messages and memories, stored as
+-- little-endian float32 BLOBs and ranked with libSQL's vector_distance_cos.
+-- Sources with no text get a row with a NULL vector so they are not re-embedded.
+
+CREATE TABLE embeddings (
+    source_type TEXT NOT NULL CHECK (source_type IN ('message', 'memory')),
+    source_id TEXT NOT NULL,
+    conversation_id TEXT,
+    model TEXT NOT NULL,
+    content TEXT NOT NULL,
+    vector BLOB,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (source_type, source_id, model)
+);
+
+CREATE INDEX idx_embeddings_model ON embeddings(model);
+CREATE INDEX idx_embeddings_conversation_id ON embeddings(conversation_id);
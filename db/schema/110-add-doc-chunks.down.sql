@@ -0,0 +1,2 @@
+-- Reverts 110-add-doc-chunks.sql.
+DROP TABLE doc_chunks;
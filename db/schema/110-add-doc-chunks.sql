@@ -0,0 +1,17 @@
+-- Documentation chunks
+-- Embedded pieces of a repository's docs and code comments, keyed by repository
+-- root so the agent can retrieve them instead of re-reading large files.
+-- file_hash lets the indexer skip files that have not changed.
+
+CREATE TABLE doc_chunks (
+    root TEXT NOT NULL,
+    model TEXT NOT NULL,
+    path TEXT NOT NULL,
+    chunk_index INTEGER NOT NULL,
+    start_line INTEGER NOT NULL,
+    file_hash TEXT NOT NULL,
+    content TEXT NOT NULL,
+    vector BLOB NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (root, model, path, chunk_index)
+);
@@ -0,0 +1,3 @@
+-- Reverts 111-add-user-id.sql.
+DROP INDEX idx_conversations_user_id;
+ALTER TABLE conversations DROP COLUMN user_id;
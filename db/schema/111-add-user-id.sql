@@ -0,0 +1,5 @@
+-- Add user_id column to record which user started a conversation.
+-- Populated from the header configured with --require-header (e.g. X-Exedev-Userid).
+ALTER TABLE conversations ADD COLUMN user_id TEXT;
+
+CREATE INDEX idx_conversations_user_id ON conversations(user_id);
@@ -0,0 +1,2 @@
+-- Reverts 112-add-conversation-tools.sql.
+ALTER TABLE conversations DROP COLUMN tools;
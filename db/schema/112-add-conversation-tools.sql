@@ -0,0 +1,3 @@
+-- Add tools column recording the tool names offered to a conversation's loop
+-- (JSON array), so recovery after a restart can rebuild the same toolset.
+ALTER TABLE conversations ADD COLUMN tools TEXT;
@@ -0,0 +1,6 @@
+-- Reverts 113-add-checkpoints.sql. Rolled back messages are deleted, since
+-- older builds would show them again. The snapshot refs are left in the
+-- repositories.
+DROP TABLE checkpoints;
+DELETE FROM messages WHERE deleted_at IS NOT NULL;
+ALTER TABLE messages DROP COLUMN deleted_at;
@@ -0,0 +1,15 @@
+-- Rolling a conversation back soft-deletes the messages after the chosen one,
+-- so they drop out of the conversation but sequence IDs are never reused.
+ALTER TABLE messages ADD COLUMN deleted_at DATETIME;
+
+-- Workspace snapshots (git commits under refs/shelley/<conversation_id>) taken
+-- as each user message was sent, so a rollback can also restore the files.
+CREATE TABLE checkpoints (
+    conversation_id TEXT NOT NULL,
+    sequence_id INTEGER NOT NULL,
+    cwd TEXT NOT NULL,
+    git_commit TEXT NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (conversation_id, sequence_id),
+    FOREIGN KEY (conversation_id) REFERENCES conversations(conversation_id) ON DELETE CASCADE
+);
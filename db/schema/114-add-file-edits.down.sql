@@ -0,0 +1,2 @@
+-- Reverts 114-add-file-edits.sql.
+DROP TABLE file_edits;
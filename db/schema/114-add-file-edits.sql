@@ -0,0 +1,21 @@
+-- File edits
+-- Content of a file before and after each change made by the patch tool or
+-- the write-file handler, so an edit (or every edit from a turn) can be
+-- reverted without relying on git.
+
+CREATE TABLE file_edits (
+    edit_id TEXT PRIMARY KEY,
+    conversation_id TEXT,            -- NULL for edits made outside a conversation
+    sequence_id INTEGER,             -- latest message in the conversation when the edit was made
+    tool_use_id TEXT,
+    source TEXT NOT NULL,            -- 'patch' or 'write_file'
+    path TEXT NOT NULL,
+    new_file BOOLEAN NOT NULL,       -- the edit created the file
+    before_content BLOB NOT NULL,
+    after_content BLOB NOT NULL,
+    reverted_at DATETIME,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (conversation_id) REFERENCES conversations(conversation_id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_file_edits_conversation ON file_edits(conversation_id, sequence_id);
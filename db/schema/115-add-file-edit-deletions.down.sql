@@ -0,0 +1,4 @@
+-- Reverts 115-add-file-edit-deletions.sql. Edits deleting files are
+-- deleted, since older builds would revert them wrongly.
+DELETE FROM file_edits WHERE deleted_file;
+ALTER TABLE file_edits DROP COLUMN deleted_file;
@@ -0,0 +1,2 @@
+-- Edits recorded from bash commands can delete files
+ALTER TABLE file_edits ADD COLUMN deleted_file BOOLEAN NOT NULL DEFAULT FALSE;
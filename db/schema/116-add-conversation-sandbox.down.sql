@@ -0,0 +1,2 @@
+-- Reverts 116-add-conversation-sandbox.sql.
+ALTER TABLE conversations DROP COLUMN sandbox;
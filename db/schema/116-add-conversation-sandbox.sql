@@ -0,0 +1,3 @@
+-- Add sandbox column holding a conversation's sandbox options (JSON), so its
+-- tools are sandboxed the same way after a restart.
+ALTER TABLE conversations ADD COLUMN sandbox TEXT;
@@ -0,0 +1,2 @@
+-- Reverts 117-add-conversation-allowed-tools.sql.
+ALTER TABLE conversations DROP COLUMN allowed_tools;
@@ -0,0 +1,3 @@
+-- Add allowed_tools column restricting the tools offered to a conversation
+-- (JSON array of tool names). NULL offers every tool.
+ALTER TABLE conversations ADD COLUMN allowed_tools TEXT;
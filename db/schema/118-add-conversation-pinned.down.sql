@@ -0,0 +1,2 @@
+-- Reverts 118-add-conversation-pinned.sql.
+ALTER TABLE conversations DROP COLUMN pinned;
@@ -0,0 +1,2 @@
+-- Add pinned flag so conversations can be kept at the top of the list
+ALTER TABLE conversations ADD COLUMN pinned BOOLEAN NOT NULL DEFAULT FALSE;
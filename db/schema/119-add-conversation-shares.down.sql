@@ -0,0 +1,2 @@
+-- Reverts 119-add-conversation-shares.sql. Shared links stop working.
+DROP TABLE conversation_shares;
@@ -0,0 +1,13 @@
+-- Conversation shares
+-- Unguessable tokens that expose a read-only view of a conversation at
+-- /share/<token>. Revoking a share deletes its row.
+
+CREATE TABLE conversation_shares (
+    token TEXT PRIMARY KEY,
+    conversation_id TEXT NOT NULL,
+    user_id TEXT,                    -- user who created the share, if known
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (conversation_id) REFERENCES conversations(conversation_id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_conversation_shares_conversation_id ON conversation_shares(conversation_id);
@@ -0,0 +1,13 @@
+-- Reverts 120-add-user-settings.sql. The anonymous user's UI settings go
+-- back into the server-wide settings; other users' are lost.
+UPDATE settings
+SET data = json_set(data, '$.ui', (
+    SELECT json(json_extract(data, '$.ui')) FROM user_settings
+    WHERE user_id = '' AND json_valid(data) AND json_type(data, '$.ui') = 'object'
+))
+WHERE id = 1 AND json_valid(data) AND EXISTS (
+    SELECT 1 FROM user_settings
+    WHERE user_id = '' AND json_valid(data) AND json_type(data, '$.ui') = 'object'
+);
+
+DROP TABLE user_settings;
@@ -0,0 +1,17 @@
+-- Per-user settings
+-- UI preferences and the default model, stored as a JSON blob per user.
+-- user_id is '' when the server doesn't identify users.
+
+CREATE TABLE user_settings (
+    user_id TEXT PRIMARY KEY,
+    data TEXT NOT NULL DEFAULT '{}',
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- UI settings used to be server-wide; keep them for the anonymous user
+INSERT INTO user_settings (user_id, data)
+SELECT '', json_object('ui', json(json_extract(data, '$.ui')))
+FROM settings
+WHERE id = 1 AND json_valid(data) AND json_type(data, '$.ui') = 'object';
+
+UPDATE settings SET data = json_remove(data, '$.ui') WHERE id = 1 AND json_valid(data);
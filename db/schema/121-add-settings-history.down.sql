@@ -0,0 +1,2 @@
+-- Reverts 121-add-settings-history.sql.
+DROP TABLE settings_history;
@@ -0,0 +1,13 @@
+-- Settings history
+-- Every version of the server-wide settings, newest last, so a bad change
+-- can be reverted. user_id is who saved it, if known.
+
+CREATE TABLE settings_history (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    data TEXT NOT NULL,
+    user_id TEXT,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Start with the current settings so the first change can be undone
+INSERT INTO settings_history (data) SELECT data FROM settings WHERE id = 1;
@@ -0,0 +1,2 @@
+-- Reverts 122-add-conversation-notify-events.sql.
+ALTER TABLE conversations DROP COLUMN notify_events;
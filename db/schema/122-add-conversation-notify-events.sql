@@ -0,0 +1,3 @@
+-- Events to send notifications about for this conversation (JSON array of
+-- notify kinds), overriding the global setting. NULL means follow it.
+ALTER TABLE conversations ADD COLUMN notify_events TEXT;
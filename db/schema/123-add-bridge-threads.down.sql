@@ -0,0 +1,3 @@
+-- Reverts 123-add-bridge-threads.sql. Chat threads no longer continue
+-- their conversations.
+DROP TABLE bridge_threads;
@@ -0,0 +1,14 @@
+-- Bridge threads
+-- Maps a thread on a chat platform (Slack, ...) to the conversation its
+-- messages are sent to. thread_id is in the platform's own format.
+
+CREATE TABLE bridge_threads (
+    platform TEXT NOT NULL,
+    thread_id TEXT NOT NULL,
+    conversation_id TEXT NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (platform, thread_id),
+    FOREIGN KEY (conversation_id) REFERENCES conversations(conversation_id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_bridge_threads_conversation_id ON bridge_threads(conversation_id);
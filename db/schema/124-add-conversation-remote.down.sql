@@ -0,0 +1,3 @@
+-- Reverts 124-add-conversation-remote.sql. Remote conversations' tools
+-- run on the server.
+ALTER TABLE conversations DROP COLUMN remote;
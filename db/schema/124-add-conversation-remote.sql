@@ -0,0 +1,3 @@
+-- The SSH host a conversation's tools run on (JSON), if its workspace is
+-- remote. NULL means the tools run on the server.
+ALTER TABLE conversations ADD COLUMN remote TEXT;
@@ -0,0 +1,3 @@
+-- Reverts 125-add-conversation-devcontainer.sql. Dev container
+-- conversations' tools run on the server.
+ALTER TABLE conversations DROP COLUMN devcontainer;
@@ -0,0 +1,3 @@
+-- The workspace folder whose dev container a conversation's tools run in.
+-- NULL means they run on the server (or its remote host).
+ALTER TABLE conversations ADD COLUMN devcontainer TEXT;
@@ -0,0 +1,4 @@
+-- Reverts 126-add-projects.sql.
+DROP INDEX idx_conversations_project_id;
+ALTER TABLE conversations DROP COLUMN project_id;
+DROP TABLE projects;
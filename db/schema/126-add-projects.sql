@@ -0,0 +1,18 @@
+-- Projects table
+-- A named workspace root whose settings new conversations working in it
+-- start from. Conversations in a project's root (or below it) reference it.
+
+CREATE TABLE projects (
+    project_id TEXT PRIMARY KEY,
+    name TEXT NOT NULL,
+    root_path TEXT NOT NULL UNIQUE,
+    default_model TEXT,
+    allowed_tools TEXT, -- JSON array of tool names; NULL means every tool
+    git_origin TEXT,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+ALTER TABLE conversations ADD COLUMN project_id TEXT;
+
+CREATE INDEX idx_conversations_project_id ON conversations(project_id);
@@ -0,0 +1,2 @@
+-- Reverts 127-add-conversation-guidance-files.sql.
+ALTER TABLE conversations DROP COLUMN guidance_files;
@@ -0,0 +1,3 @@
+-- The guidance files (AGENTS.md, CLAUDE.md, ...) whose contents were loaded
+-- into a conversation's system prompt, as a JSON array of paths.
+ALTER TABLE conversations ADD COLUMN guidance_files TEXT;
@@ -0,0 +1,2 @@
+-- Reverts 128-add-ci-autofix.sql.
+DROP TABLE ci_autofix;
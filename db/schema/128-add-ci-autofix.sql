@@ -0,0 +1,19 @@
+-- CI auto-fix
+-- A conversation watching a pull request's checks. When they fail on a new
+-- head commit, the failures are sent to the agent as a new turn to fix,
+-- at most max_attempts times and while the conversation has cost less than
+-- max_cost_usd (0 for no limit) since auto-fix was turned on. status is
+-- watching, or why watching stopped: attempts, budget or closed.
+
+CREATE TABLE ci_autofix (
+    conversation_id TEXT PRIMARY KEY,
+    pr_url TEXT NOT NULL,
+    max_attempts INTEGER NOT NULL,
+    max_cost_usd REAL NOT NULL DEFAULT 0,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_sha TEXT NOT NULL DEFAULT '',  -- head commit whose failures were last sent
+    status TEXT NOT NULL DEFAULT 'watching',
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (conversation_id) REFERENCES conversations(conversation_id) ON DELETE CASCADE
+);
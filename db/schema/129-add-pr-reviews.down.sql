@@ -0,0 +1,2 @@
+-- Reverts 129-add-pr-reviews.sql.
+DROP TABLE pr_reviews;
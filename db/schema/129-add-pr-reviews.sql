@@ -0,0 +1,17 @@
+-- Pull request reviews
+-- A review conversation reads a pull request and submits a structured
+-- review with the submit_review tool. The review is stored here as JSON,
+-- and posted to GitHub when the agent submits it if post is set, or later
+-- on request.
+
+CREATE TABLE pr_reviews (
+    conversation_id TEXT PRIMARY KEY,
+    pr_url TEXT NOT NULL,
+    head_sha TEXT NOT NULL,          -- the commit reviewed, which comments are posted against
+    post BOOLEAN NOT NULL DEFAULT FALSE,
+    review TEXT,                     -- the submitted review, once there is one
+    posted_url TEXT,                 -- the review on GitHub, once posted
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (conversation_id) REFERENCES conversations(conversation_id) ON DELETE CASCADE
+);
@@ -0,0 +1,2 @@
+-- Reverts 130-add-conversation-plans.sql.
+DROP TABLE conversation_plans;
@@ -0,0 +1,16 @@
+-- Conversation plans
+-- A conversation started in plan mode first has the agent propose a plan
+-- with the propose_plan tool. Until the user approves it the conversation
+-- is offered only read-only tools; allowed_tools keeps the allowlist to
+-- restore on approval. The plan, with each step's progress, is stored as
+-- JSON.
+
+CREATE TABLE conversation_plans (
+    conversation_id TEXT PRIMARY KEY,
+    status TEXT NOT NULL DEFAULT 'drafting',  -- drafting, proposed or approved
+    plan TEXT,                                -- the proposed plan, once there is one
+    allowed_tools TEXT,                       -- the allowlist before planning, NULL for every tool
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (conversation_id) REFERENCES conversations(conversation_id) ON DELETE CASCADE
+);
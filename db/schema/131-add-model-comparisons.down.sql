@@ -0,0 +1,2 @@
+-- Reverts 131-add-model-comparisons.sql.
+DROP TABLE model_comparisons;
@@ -0,0 +1,20 @@
+-- Model comparisons
+-- A comparison sends a conversation's next turn to two models, each in a
+-- shadow conversation: an archived copy of the conversation restricted to
+-- read-only tools. The shadows hold the responses; winner records the
+-- model the user preferred.
+
+CREATE TABLE model_comparisons (
+    comparison_id TEXT PRIMARY KEY,
+    conversation_id TEXT NOT NULL,   -- the conversation compared
+    message TEXT NOT NULL,
+    model_a TEXT NOT NULL,
+    conversation_a TEXT NOT NULL,
+    model_b TEXT NOT NULL,
+    conversation_b TEXT NOT NULL,
+    winner TEXT,                     -- model_a, model_b or 'tie', once the user picks
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (conversation_id) REFERENCES conversations(conversation_id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_model_comparisons_created_at ON model_comparisons(created_at);
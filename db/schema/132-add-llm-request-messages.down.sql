@@ -0,0 +1,5 @@
+-- Reverts 132-add-llm-request-messages.sql.
+DROP INDEX idx_llm_requests_message_id;
+ALTER TABLE llm_requests DROP COLUMN llm_response;
+ALTER TABLE llm_requests DROP COLUMN llm_request;
+ALTER TABLE llm_requests DROP COLUMN message_id;
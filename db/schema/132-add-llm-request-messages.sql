@@ -0,0 +1,9 @@
+-- The debug log of LLM requests links each request to the agent message
+-- its response was recorded as. llm_request and llm_response are the
+-- provider-neutral request and response, which can be replayed against
+-- another model; request_body and response_body stay the provider's own.
+ALTER TABLE llm_requests ADD COLUMN message_id TEXT;
+ALTER TABLE llm_requests ADD COLUMN llm_request TEXT;
+ALTER TABLE llm_requests ADD COLUMN llm_response TEXT;
+
+CREATE INDEX idx_llm_requests_message_id ON llm_requests(message_id);
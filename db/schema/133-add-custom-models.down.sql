@@ -0,0 +1,2 @@
+-- Reverts 133-add-custom-models.sql.
+DROP TABLE custom_models;
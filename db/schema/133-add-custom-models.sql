@@ -0,0 +1,12 @@
+-- Custom models
+-- Models served by OpenAI-compatible endpoints (vLLM, LM Studio,
+-- llama.cpp server, ...), registered at runtime and loaded into the LLM
+-- manager when the server starts.
+
+CREATE TABLE custom_models (
+    model_id TEXT PRIMARY KEY,    -- the model's ID in Shelley
+    url TEXT NOT NULL,            -- base URL of the endpoint
+    api_key TEXT NOT NULL DEFAULT '',
+    model_name TEXT NOT NULL,     -- the model the endpoint is asked for
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
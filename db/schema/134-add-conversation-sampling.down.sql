@@ -0,0 +1,2 @@
+-- Reverts 134-add-conversation-sampling.sql.
+ALTER TABLE conversations DROP COLUMN sampling;
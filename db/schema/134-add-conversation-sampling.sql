@@ -0,0 +1,4 @@
+-- The sampling parameters (temperature, top_p, max output tokens, stop
+-- sequences) sent with a conversation's LLM requests, as a JSON object.
+-- NULL leaves them to the model's defaults.
+ALTER TABLE conversations ADD COLUMN sampling TEXT;
@@ -0,0 +1,2 @@
+-- Reverts 135-add-conversation-thinking.sql.
+ALTER TABLE conversations DROP COLUMN thinking;
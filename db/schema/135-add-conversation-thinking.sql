@@ -0,0 +1,4 @@
+-- The reasoning asked of the models of a conversation that take it (a
+-- thinking effort or budget), as a JSON object. NULL leaves it to the
+-- model.
+ALTER TABLE conversations ADD COLUMN thinking TEXT;
@@ -0,0 +1,2 @@
+-- Reverts 136-add-message-feedback.sql.
+DROP TABLE message_feedback;
@@ -0,0 +1,19 @@
+-- Message feedback
+-- A thumbs up or down and/or a comment on an agent message, with the
+-- model that generated it, for evaluating prompts and models later.
+-- Feedback outlives soft deletion of its message.
+
+CREATE TABLE message_feedback (
+    message_id TEXT PRIMARY KEY,     -- the agent message
+    conversation_id TEXT NOT NULL,
+    model TEXT,                      -- the model that generated the message, if known
+    rating TEXT,                     -- 'up' or 'down', or NULL for a comment alone
+    comment TEXT,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (message_id) REFERENCES messages(message_id) ON DELETE CASCADE,
+    FOREIGN KEY (conversation_id) REFERENCES conversations(conversation_id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_message_feedback_conversation_id ON message_feedback(conversation_id);
+CREATE INDEX idx_message_feedback_updated_at ON message_feedback(updated_at);
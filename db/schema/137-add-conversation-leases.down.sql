@@ -0,0 +1,2 @@
+-- Reverts 137-add-conversation-leases.sql.
+DROP TABLE conversation_leases;
@@ -0,0 +1,15 @@
+-- Conversation leases
+-- When several Shelley instances share the database, the one holding a
+-- conversation's lease is the only one running its agent loop and
+-- recovering it. Instances renew the leases of the conversations they run
+-- and release them when they stop; an instance that dies loses its leases
+-- once they expire.
+
+CREATE TABLE conversation_leases (
+    conversation_id TEXT PRIMARY KEY,
+    owner TEXT NOT NULL,           -- the instance holding the lease
+    expires_at INTEGER NOT NULL,   -- Unix milliseconds
+    FOREIGN KEY (conversation_id) REFERENCES conversations(conversation_id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_conversation_leases_owner ON conversation_leases(owner);
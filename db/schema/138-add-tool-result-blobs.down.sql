@@ -0,0 +1,3 @@
+-- Reverts 138-add-tool-result-blobs.sql. Messages that reference blobs
+-- keep only their previews.
+DROP TABLE tool_result_blobs;
@@ -0,0 +1,14 @@
+-- Tool result blobs
+-- Tool results above a size are stored here, by the SHA-256 of their text,
+-- and their messages keep only a preview and the hash. They're kept per
+-- conversation, and deleted with it.
+
+CREATE TABLE tool_result_blobs (
+    conversation_id TEXT NOT NULL,
+    hash TEXT NOT NULL,            -- hex SHA-256 of content
+    content TEXT NOT NULL,         -- compressed, see db/compressed
+    size INTEGER NOT NULL,         -- length of content in bytes
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (conversation_id, hash),
+    FOREIGN KEY (conversation_id) REFERENCES conversations(conversation_id) ON DELETE CASCADE
+);
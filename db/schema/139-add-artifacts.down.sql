@@ -0,0 +1,3 @@
+-- Reverts 139-add-artifacts.sql. The artifacts' files are removed by
+-- newer builds only.
+DROP TABLE artifacts;
@@ -0,0 +1,20 @@
+-- Artifacts
+-- Build outputs the agent publishes to a conversation, for the user to
+-- download. Their content is stored in files named by artifact_id, apart
+-- from the database; rows are deleted with their conversation or once they
+-- expire, and the server removes the files left without a row.
+
+CREATE TABLE artifacts (
+    artifact_id TEXT PRIMARY KEY,
+    conversation_id TEXT NOT NULL,
+    name TEXT NOT NULL,            -- file name it's downloaded as
+    content_type TEXT NOT NULL,
+    size INTEGER NOT NULL,         -- in bytes
+    sha256 TEXT NOT NULL,          -- hex SHA-256 of the content
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at INTEGER NOT NULL,   -- Unix milliseconds
+    FOREIGN KEY (conversation_id) REFERENCES conversations(conversation_id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_artifacts_conversation ON artifacts(conversation_id, created_at);
+CREATE INDEX idx_artifacts_expires_at ON artifacts(expires_at);
@@ -0,0 +1,4 @@
+-- Reverts 140-add-upload-refs.sql. Collecting uploads searches the
+-- messages again.
+DROP TABLE upload_refs_backfill;
+DROP TABLE upload_refs;
@@ -0,0 +1,24 @@
+-- Upload references
+-- The uploads each message mentions, by ID (upload_ and the hash that
+-- names the file), recorded when the message is written, so that an
+-- upload's references are counted rather than found by searching every
+-- message. They go with their message.
+
+CREATE TABLE upload_refs (
+    upload_id TEXT NOT NULL,
+    message_id TEXT NOT NULL,
+    PRIMARY KEY (upload_id, message_id),
+    FOREIGN KEY (message_id) REFERENCES messages(message_id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_upload_refs_message_id ON upload_refs(message_id);
+
+-- The messages stored before references were recorded are indexed by
+-- DB.IndexUploadRefs, in message_id order. It records here how far it got,
+-- and deletes the row once done.
+CREATE TABLE upload_refs_backfill (
+    id INTEGER PRIMARY KEY CHECK (id = 1),
+    after_message_id TEXT NOT NULL DEFAULT ''
+);
+
+INSERT INTO upload_refs_backfill (id) VALUES (1);
@@ -0,0 +1,114 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+
+	"shelley.exe.dev/db/generated"
+)
+
+// uploadRefsBatch is how many messages IndexUploadRefs indexes per
+// transaction.
+const uploadRefsBatch = 500
+
+// UploadIDPattern matches the IDs of uploaded files, their names without
+// the extension: upload_ and the SHA-256 of the content, or 16 hex digits
+// for files uploaded before they were named by content.
+var UploadIDPattern = regexp.MustCompile(`upload_(?:[0-9a-f]{64}|[0-9a-f]{16})`)
+
+// uploadIDs returns the IDs of the uploads data mentions, without
+// duplicates.
+func uploadIDs(data ...*string) []string {
+	var ids []string
+	for _, d := range data {
+		if d != nil {
+			ids = append(ids, UploadIDPattern.FindAllString(*d, -1)...)
+		}
+	}
+	slices.Sort(ids)
+	return slices.Compact(ids)
+}
+
+// setUploadRefs records that the message mentions the uploads ids, and no
+// others.
+func setUploadRefs(ctx context.Context, q *generated.Queries, messageID string, ids []string) error {
+	if err := q.DeleteMessageUploadRefs(ctx, messageID); err != nil {
+		return fmt.Errorf("failed to delete upload references: %w", err)
+	}
+	for _, id := range ids {
+		if err := q.InsertUploadRef(ctx, generated.InsertUploadRefParams{UploadID: id, MessageID: messageID}); err != nil {
+			return fmt.Errorf("failed to record upload reference: %w", err)
+		}
+	}
+	return nil
+}
+
+// IndexUploadRefs records the uploads mentioned by the messages stored
+// before references were recorded on write, returning how many messages it
+// indexed. Once they all are, it only checks that they are.
+func (db *DB) IndexUploadRefs(ctx context.Context) (int, error) {
+	var total int
+	for {
+		var n int
+		done := false
+		err := db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+			q := generated.New(tx.Conn())
+			after, err := q.GetUploadRefsBackfill(ctx)
+			if errors.Is(err, sql.ErrNoRows) {
+				done = true
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			rows, err := q.ListMessageData(ctx, generated.ListMessageDataParams{MessageID: after, Limit: uploadRefsBatch})
+			if err != nil {
+				return err
+			}
+			for _, row := range rows {
+				after = row.MessageID
+				ids := uploadIDs((*string)(row.LlmData), row.UserData, row.DisplayData)
+				for _, id := range ids {
+					if err := q.InsertUploadRef(ctx, generated.InsertUploadRefParams{UploadID: id, MessageID: row.MessageID}); err != nil {
+						return err
+					}
+				}
+			}
+			n = len(rows)
+			if n < uploadRefsBatch {
+				done = true
+				return q.DeleteUploadRefsBackfill(ctx)
+			}
+			return q.UpdateUploadRefsBackfill(ctx, after)
+		})
+		if err != nil {
+			return total, fmt.Errorf("failed to index upload references: %w", err)
+		}
+		total += n
+		if done {
+			return total, nil
+		}
+	}
+}
+
+// UploadRefCounts returns how many messages mention each upload that any
+// message does, by upload ID. Call IndexUploadRefs first, or messages
+// stored before references were recorded may be missed.
+func (db *DB) UploadRefCounts(ctx context.Context) (map[string]int64, error) {
+	counts := make(map[string]int64)
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		rows, err := generated.New(rx.Conn()).CountUploadRefs(ctx)
+		if err != nil {
+			return err
+		}
+		for _, row := range rows {
+			counts[row.UploadID] = row.Refs
+		}
+		return nil
+	})
+	return counts, err
+}
@@ -0,0 +1,168 @@
+// Package devcontainer runs a conversation's tools inside a project's dev
+// container (https://containers.dev), so they get the project's toolchain
+// rather than whatever is installed on the server.
+//
+// Containers are built and started with the devcontainer CLI, and scripts
+// run in them with docker exec. Besides the mounts its configuration asks
+// for, a container gets the workspace folder mounted at the folder's own
+// path, so that paths on the server and in the container agree.
+package devcontainer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"shelley.exe.dev/remote"
+)
+
+// ErrUnavailable is returned when a container is needed but the devcontainer
+// CLI or docker is not installed.
+var ErrUnavailable = errors.New("dev container unavailable: the devcontainer CLI and docker must be installed")
+
+// upTimeout bounds building and starting a container.
+const upTimeout = 30 * time.Minute
+
+// Available reports whether the devcontainer CLI and docker are installed.
+func Available() bool {
+	for _, name := range []string{"devcontainer", "docker"} {
+		if _, err := exec.LookPath(name); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// Find returns the path of the dev container configuration of the
+// workspace folder dir, or "" if it has none.
+func Find(dir string) string {
+	for _, name := range []string{".devcontainer/devcontainer.json", ".devcontainer.json"} {
+		path := filepath.Join(dir, name)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path
+		}
+	}
+	return ""
+}
+
+// Container is the dev container of a workspace folder. It is built and
+// started when first used.
+type Container struct {
+	// Folder is the workspace folder on this machine.
+	Folder string
+
+	mu   sync.Mutex
+	id   string
+	user string
+}
+
+var (
+	containersMu sync.Mutex
+	containers   = map[string]*Container{}
+)
+
+// For returns the container of the workspace folder, which is shared by
+// every conversation working in it.
+func For(folder string) *Container {
+	containersMu.Lock()
+	defer containersMu.Unlock()
+	c, ok := containers[folder]
+	if !ok {
+		c = &Container{Folder: folder}
+		containers[folder] = c
+	}
+	return c
+}
+
+// upResult is the outcome devcontainer up prints as the last line of its
+// output.
+type upResult struct {
+	Outcome     string `json:"outcome"`
+	Message     string `json:"message"`
+	Description string `json:"description"`
+	ContainerID string `json:"containerId"`
+	RemoteUser  string `json:"remoteUser"`
+}
+
+// Up builds and starts the container, unless it has been already, and
+// returns its ID. Callers wait for a build in progress.
+func (c *Container) Up(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.id != "" {
+		return c.id, nil
+	}
+	if !Available() {
+		return "", ErrUnavailable
+	}
+	if strings.Contains(c.Folder, ",") {
+		// The CLI's mount syntax can't express it
+		return "", fmt.Errorf("dev container: workspace folder %q contains a comma", c.Folder)
+	}
+
+	cmd := exec.CommandContext(ctx, "devcontainer", "up",
+		"--workspace-folder", c.Folder,
+		"--mount", "type=bind,source="+c.Folder+",target="+c.Folder)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	var result upResult
+	if jsonErr := json.Unmarshal([]byte(lines[len(lines)-1]), &result); jsonErr != nil || result.Outcome != "success" {
+		msg := strings.TrimSpace(result.Message + " " + result.Description)
+		if msg == "" {
+			msg = lastLine(stderr.String())
+		}
+		if err == nil {
+			err = errors.New("unexpected output")
+		}
+		return "", fmt.Errorf("dev container: failed to start %s: %w: %s", c.Folder, err, msg)
+	}
+
+	// A container started without the folder mount (by an editor, say) is
+	// reused as it is, and paths in it would not match
+	check := exec.CommandContext(ctx, "docker", "exec", result.ContainerID, "test", "-d", c.Folder)
+	if err := check.Run(); err != nil {
+		return "", fmt.Errorf("dev container %.12s does not have %s mounted at the same path; remove it (devcontainer up --remove-existing-container) so it can be recreated", result.ContainerID, c.Folder)
+	}
+	c.id, c.user = result.ContainerID, result.RemoteUser
+	return c.id, nil
+}
+
+// lastLine returns the last non-empty line of s.
+func lastLine(s string) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	return lines[len(lines)-1]
+}
+
+// Shell returns the shell that runs scripts in the container as its
+// remote user, building and starting the container first if needed.
+func (c *Container) Shell() remote.Shell {
+	return func(script string) ([]string, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), upTimeout)
+		defer cancel()
+		id, err := c.Up(ctx)
+		if err != nil {
+			return nil, err
+		}
+		docker, err := exec.LookPath("docker")
+		if err != nil {
+			return nil, ErrUnavailable
+		}
+		argv := []string{docker, "exec", "-i"}
+		c.mu.Lock()
+		if c.user != "" {
+			argv = append(argv, "-u", c.user)
+		}
+		c.mu.Unlock()
+		return append(argv, id, "sh", "-c", script), nil
+	}
+}
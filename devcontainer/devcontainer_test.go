@@ -0,0 +1,101 @@
+package devcontainer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeCLI puts a devcontainer CLI on PATH that prints upOutput, and a
+// docker that runs exec'd commands locally and logs them to the returned
+// file.
+func fakeCLI(t *testing.T, upOutput string) string {
+	t.Helper()
+	dir := t.TempDir()
+	log := filepath.Join(dir, "log")
+	scripts := map[string]string{
+		"devcontainer": "#!/bin/sh\necho building >&2\necho '" + upOutput + "'\n",
+		"docker": "#!/bin/sh\necho \"$*\" >> " + log + "\nshift\n" +
+			"while case \"$1\" in -*) true;; *) false;; esac; do [ \"$1\" = -u ] && shift; shift; done\n" +
+			"shift\nexec \"$@\"\n",
+	}
+	for name, script := range scripts {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(script), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return log
+}
+
+func TestFind(t *testing.T) {
+	dir := t.TempDir()
+	if got := Find(dir); got != "" {
+		t.Errorf("expected no configuration, got %s", got)
+	}
+	if err := os.WriteFile(filepath.Join(dir, ".devcontainer.json"), []byte("{}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got := Find(dir); got != filepath.Join(dir, ".devcontainer.json") {
+		t.Errorf("unexpected configuration %s", got)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, ".devcontainer"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, ".devcontainer", "devcontainer.json"), []byte("{}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got := Find(dir); got != filepath.Join(dir, ".devcontainer", "devcontainer.json") {
+		t.Errorf("expected .devcontainer/devcontainer.json to take precedence, got %s", got)
+	}
+}
+
+func TestContainer(t *testing.T) {
+	log := fakeCLI(t, `{"outcome":"success","containerId":"abc123","remoteUser":"vscode","remoteWorkspaceFolder":"/workspaces/x"}`)
+	folder := t.TempDir()
+	c := &Container{Folder: folder}
+	ctx := context.Background()
+
+	id, err := c.Up(ctx)
+	if err != nil || id != "abc123" {
+		t.Fatalf("Up = %q, %v", id, err)
+	}
+	shell := c.Shell()
+	if err := shell.WriteFile(ctx, filepath.Join(folder, "hello.txt"), []byte("hi\n")); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(folder, "hello.txt"))
+	if err != nil || string(data) != "hi\n" {
+		t.Errorf("unexpected file %q, %v", data, err)
+	}
+	logged, _ := os.ReadFile(log)
+	if !strings.Contains(string(logged), "exec -i -u vscode abc123 sh -c") {
+		t.Errorf("expected scripts to run in the container as its user, got %q", logged)
+	}
+
+	if For(folder) != For(folder) || For(folder) == For(t.TempDir()) {
+		t.Error("expected one container per folder")
+	}
+}
+
+func TestContainerFailure(t *testing.T) {
+	fakeCLI(t, `{"outcome":"error","message":"Command failed: docker build","description":"An error occurred building the image."}`)
+	c := &Container{Folder: t.TempDir()}
+	_, err := c.Up(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "docker build") {
+		t.Errorf("expected the CLI's message, got %v", err)
+	}
+	if _, err := c.Shell()("true"); err == nil {
+		t.Error("expected the shell to fail without a container")
+	}
+}
+
+func TestContainerWithoutMount(t *testing.T) {
+	fakeCLI(t, `{"outcome":"success","containerId":"abc123"}`)
+	c := &Container{Folder: filepath.Join(t.TempDir(), "missing")}
+	if _, err := c.Up(context.Background()); err == nil || !strings.Contains(err.Error(), "same path") {
+		t.Errorf("expected an error for a container without the folder, got %v", err)
+	}
+}
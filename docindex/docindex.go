@@ -0,0 +1,274 @@
+// Package docindex extracts documentation and code comments from a repository
+// and splits them into chunks suitable for embedding.
+package docindex
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// ChunkSize is the target maximum size of a chunk in bytes.
+	ChunkSize = 1500
+	// maxFileSize skips generated files, data dumps and the like.
+	maxFileSize = 512 * 1024
+	// maxFiles bounds the work done for very large repositories.
+	maxFiles = 5000
+	// minCommentLen drops trivial comments like "// increment i".
+	minCommentLen = 80
+)
+
+// File is an indexable file in a repository.
+type File struct {
+	Path    string // relative to the repository root, slash-separated
+	Hash    string // sha256 of the content
+	Content []byte
+}
+
+// Chunk is a piece of a file to embed.
+type Chunk struct {
+	StartLine int // 1-based line in the file where the chunk starts
+	Content   string
+}
+
+// Root returns the repository root containing dir, or dir itself outside git.
+func Root(dir string) string {
+	cmd := exec.Command("git", "rev-parse", "--show-toplevel")
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		return dir
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// Scan returns the documentation and source files under root.
+// In a git repository it uses git ls-files, so ignored files are skipped.
+func Scan(root string) ([]File, error) {
+	paths, err := listFiles(root)
+	if err != nil {
+		return nil, err
+	}
+	var files []File
+	for _, p := range paths {
+		if len(files) >= maxFiles {
+			break
+		}
+		if kind(p) == "" {
+			continue
+		}
+		full := filepath.Join(root, filepath.FromSlash(p))
+		info, err := os.Lstat(full)
+		if err != nil || !info.Mode().IsRegular() || info.Size() > maxFileSize {
+			continue
+		}
+		content, err := os.ReadFile(full)
+		if err != nil {
+			return nil, err
+		}
+		if bytes.IndexByte(content, 0) >= 0 {
+			continue // binary
+		}
+		sum := sha256.Sum256(content)
+		files = append(files, File{Path: p, Hash: hex.EncodeToString(sum[:]), Content: content})
+	}
+	return files, nil
+}
+
+func listFiles(root string) ([]string, error) {
+	cmd := exec.Command("git", "ls-files", "-z")
+	cmd.Dir = root
+	if out, err := cmd.Output(); err == nil {
+		var paths []string
+		for _, p := range strings.Split(string(out), "\x00") {
+			if p != "" {
+				paths = append(paths, p)
+			}
+		}
+		return paths, nil
+	}
+
+	var paths []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			name := d.Name()
+			if path != root && (strings.HasPrefix(name, ".") || name == "node_modules" || name == "vendor") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		paths = append(paths, filepath.ToSlash(rel))
+		return nil
+	})
+	return paths, err
+}
+
+// kind classifies a path as "doc", "slash" (// and /* */ comments),
+// "hash" (# comments), or "" if it is not indexed.
+func kind(path string) string {
+	lower := strings.ToLower(path)
+	base := filepath.Base(lower)
+	if strings.HasPrefix(base, "readme") {
+		return "doc"
+	}
+	switch filepath.Ext(lower) {
+	case ".md", ".markdown", ".mdx", ".rst", ".adoc", ".txt":
+		return "doc"
+	case ".go", ".ts", ".tsx", ".js", ".jsx", ".rs", ".java", ".kt", ".swift", ".c", ".h", ".cc", ".cpp", ".hpp", ".cs", ".scala", ".proto":
+		return "slash"
+	case ".py", ".rb", ".sh", ".bash":
+		return "hash"
+	}
+	return ""
+}
+
+// Chunks splits a file into chunks. Documentation files are chunked whole;
+// for source files only comment blocks are kept.
+func (f File) Chunks() []Chunk {
+	switch kind(f.Path) {
+	case "doc":
+		return chunkParagraphs(paragraphs(string(f.Content)))
+	case "slash", "hash":
+		return chunkParagraphs(comments(string(f.Content), kind(f.Path)))
+	}
+	return nil
+}
+
+// paragraph is a run of text starting at a line.
+type paragraph struct {
+	line int
+	text string
+}
+
+// paragraphs splits text on blank lines.
+func paragraphs(text string) []paragraph {
+	var out []paragraph
+	var cur []string
+	start := 0
+	flush := func() {
+		if len(cur) > 0 {
+			out = append(out, paragraph{line: start, text: strings.Join(cur, "\n")})
+			cur = nil
+		}
+	}
+	for i, line := range strings.Split(text, "\n") {
+		if strings.TrimSpace(line) == "" {
+			flush()
+			continue
+		}
+		if len(cur) == 0 {
+			start = i + 1
+		}
+		cur = append(cur, strings.TrimRight(line, " \t\r"))
+	}
+	flush()
+	return out
+}
+
+// comments extracts comment blocks from source code, with markers stripped.
+// Short blocks are dropped since they rarely carry documentation.
+func comments(text, k string) []paragraph {
+	var out []paragraph
+	var cur []string
+	start := 0
+	inBlock := false
+	flush := func() {
+		if joined := strings.TrimSpace(strings.Join(cur, "\n")); len(joined) >= minCommentLen {
+			out = append(out, paragraph{line: start, text: joined})
+		}
+		cur = nil
+	}
+	for i, line := range strings.Split(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case inBlock:
+			if end := strings.Index(trimmed, "*/"); end >= 0 {
+				cur = append(cur, strings.TrimPrefix(strings.TrimSpace(trimmed[:end]), "*"))
+				inBlock = false
+				flush()
+				continue
+			}
+			cur = append(cur, strings.TrimSpace(strings.TrimPrefix(trimmed, "*")))
+		case k == "slash" && strings.HasPrefix(trimmed, "//"):
+			if len(cur) == 0 {
+				start = i + 1
+			}
+			cur = append(cur, strings.TrimSpace(strings.TrimPrefix(trimmed, "//")))
+		case k == "slash" && strings.HasPrefix(trimmed, "/*"):
+			flush()
+			start = i + 1
+			body := strings.TrimPrefix(strings.TrimPrefix(trimmed, "/*"), "*")
+			if end := strings.Index(body, "*/"); end >= 0 {
+				cur = append(cur, strings.TrimSpace(body[:end]))
+				flush()
+				continue
+			}
+			cur = append(cur, strings.TrimSpace(body))
+			inBlock = true
+		case k == "hash" && strings.HasPrefix(trimmed, "#") && !strings.HasPrefix(trimmed, "#!"):
+			if len(cur) == 0 {
+				start = i + 1
+			}
+			cur = append(cur, strings.TrimSpace(strings.TrimPrefix(trimmed, "#")))
+		default:
+			flush()
+		}
+	}
+	flush()
+	return out
+}
+
+// chunkParagraphs packs consecutive paragraphs into chunks of at most
+// ChunkSize bytes, splitting paragraphs that are larger on their own.
+func chunkParagraphs(paras []paragraph) []Chunk {
+	var chunks []Chunk
+	var cur strings.Builder
+	start := 0
+	flush := func() {
+		if cur.Len() > 0 {
+			chunks = append(chunks, Chunk{StartLine: start, Content: cur.String()})
+			cur.Reset()
+		}
+	}
+	for _, p := range paras {
+		if cur.Len() > 0 && cur.Len()+2+len(p.text) > ChunkSize {
+			flush()
+		}
+		if cur.Len() == 0 {
+			start = p.line
+		} else {
+			cur.WriteString("\n\n")
+		}
+		text := p.text
+		for len(text) > ChunkSize {
+			cut := strings.LastIndexByte(text[:ChunkSize], '\n')
+			if cut <= 0 {
+				cut = ChunkSize
+				for cut > 0 && !utf8.RuneStart(text[cut]) {
+					cut--
+				}
+			}
+			cur.WriteString(text[:cut])
+			flush()
+			text = strings.TrimLeft(text[cut:], "\n")
+			start = p.line + strings.Count(p.text[:len(p.text)-len(text)], "\n")
+		}
+		cur.WriteString(text)
+	}
+	flush()
+	return chunks
+}
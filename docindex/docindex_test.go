@@ -0,0 +1,98 @@
+package docindex
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestChunksDoc(t *testing.T) {
+	para := strings.Repeat("word ", 200) // 1000 bytes
+	f := File{Path: "docs/guide.md", Content: []byte("# Title\n\nintro\n\n" + para + "\n\n" + para + "\n")}
+	chunks := f.Chunks()
+	if len(chunks) != 2 {
+		t.Fatalf("expected 2 chunks, got %d", len(chunks))
+	}
+	if chunks[0].StartLine != 1 || !strings.HasPrefix(chunks[0].Content, "# Title\n\nintro") {
+		t.Errorf("unexpected first chunk: line %d %q", chunks[0].StartLine, chunks[0].Content[:20])
+	}
+	if chunks[1].StartLine != 7 {
+		t.Errorf("expected second chunk at line 7, got %d", chunks[1].StartLine)
+	}
+	for _, c := range chunks {
+		if len(c.Content) > ChunkSize {
+			t.Errorf("chunk exceeds ChunkSize: %d", len(c.Content))
+		}
+	}
+}
+
+func TestChunksSplitsLongParagraph(t *testing.T) {
+	line := strings.Repeat("x", 99)
+	var lines []string
+	for range 40 {
+		lines = append(lines, line)
+	}
+	f := File{Path: "notes.txt", Content: []byte(strings.Join(lines, "\n"))}
+	chunks := f.Chunks()
+	if len(chunks) != 3 {
+		t.Fatalf("expected 3 chunks, got %d", len(chunks))
+	}
+	if chunks[1].StartLine != 16 {
+		t.Errorf("expected second chunk at line 16, got %d", chunks[1].StartLine)
+	}
+}
+
+func TestChunksComments(t *testing.T) {
+	src := `package x
+
+// Frobnicate turns widgets into gadgets. It is safe for concurrent use
+// and never allocates when the widget is already a gadget.
+func Frobnicate() {
+	i++ // short
+}
+
+/*
+ * The retry policy backs off exponentially, capped at thirty seconds, because
+ * the upstream rate limiter penalizes bursts.
+ */
+`
+	chunks := File{Path: "x.go", Content: []byte(src)}.Chunks()
+	if len(chunks) != 1 {
+		t.Fatalf("expected 1 chunk, got %d", len(chunks))
+	}
+	c := chunks[0].Content
+	if chunks[0].StartLine != 3 || !strings.HasPrefix(c, "Frobnicate turns") || !strings.Contains(c, "The retry policy") || strings.Contains(c, "short") || strings.Contains(c, "//") {
+		t.Errorf("unexpected chunk at line %d: %q", chunks[0].StartLine, c)
+	}
+}
+
+func TestScanWithoutGit(t *testing.T) {
+	root := t.TempDir()
+	write := func(p, content string) {
+		full := filepath.Join(root, p)
+		if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("README.md", "hello")
+	write("main.go", "package main")
+	write("image.png", "not indexed")
+	write("node_modules/dep/README.md", "skipped")
+	write(".hidden/notes.md", "skipped")
+
+	files, err := Scan(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	for _, f := range files {
+		paths = append(paths, f.Path)
+	}
+	if strings.Join(paths, ",") != "README.md,main.go" {
+		t.Errorf("unexpected files: %v", paths)
+	}
+}
@@ -0,0 +1,126 @@
+// Package embed computes text embeddings for semantic search.
+package embed
+
+import (
+	"cmp"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"net/http"
+	"strings"
+	"unicode"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// Provider turns texts into embedding vectors.
+// Vectors from the same Model must all have the same dimension.
+type Provider interface {
+	// Model identifies the embedding model; vectors from different models are not comparable.
+	Model() string
+	// Embed returns one vector per input text, in order.
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// OpenAI embeds texts with an OpenAI-compatible /embeddings endpoint.
+type OpenAI struct {
+	HTTPC     *http.Client          // defaults to http.DefaultClient if nil
+	APIKey    string                // required
+	URL       string                // optional, overrides the OpenAI base URL
+	ModelName openai.EmbeddingModel // defaults to text-embedding-3-small
+}
+
+var _ Provider = (*OpenAI)(nil)
+
+func (o *OpenAI) Model() string {
+	return string(cmp.Or(o.ModelName, openai.SmallEmbedding3))
+}
+
+func (o *OpenAI) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	config := openai.DefaultConfig(o.APIKey)
+	if o.URL != "" {
+		config.BaseURL = o.URL
+	}
+	config.HTTPClient = cmp.Or(o.HTTPC, http.DefaultClient)
+	client := openai.NewClientWithConfig(config)
+
+	resp, err := client.CreateEmbeddings(ctx, openai.EmbeddingRequest{
+		Input: texts,
+		Model: openai.EmbeddingModel(o.Model()),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create embeddings: %w", err)
+	}
+	if len(resp.Data) != len(texts) {
+		return nil, fmt.Errorf("expected %d embeddings, got %d", len(texts), len(resp.Data))
+	}
+	vectors := make([][]float32, len(texts))
+	for _, d := range resp.Data {
+		if d.Index < 0 || d.Index >= len(texts) {
+			return nil, fmt.Errorf("embedding index %d out of range", d.Index)
+		}
+		vectors[d.Index] = d.Embedding
+	}
+	return vectors, nil
+}
+
+// Hash is a local, deterministic provider that hashes words into a fixed number
+// of buckets. It needs no network access and captures word overlap only, which
+// makes it suitable for tests and predictable-only mode.
+type Hash struct {
+	Dims int // defaults to 256
+}
+
+var _ Provider = (*Hash)(nil)
+
+func (h *Hash) dims() int {
+	return cmp.Or(h.Dims, 256)
+}
+
+func (h *Hash) Model() string {
+	return fmt.Sprintf("hash-%d", h.dims())
+}
+
+func (h *Hash) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		v := make([]float32, h.dims())
+		words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})
+		for _, w := range words {
+			f := fnv.New32a()
+			f.Write([]byte(w))
+			v[f.Sum32()%uint32(len(v))]++
+		}
+		normalize(v)
+		vectors[i] = v
+	}
+	return vectors, nil
+}
+
+func normalize(v []float32) {
+	var sum float64
+	for _, x := range v {
+		sum += float64(x) * float64(x)
+	}
+	if sum == 0 {
+		return
+	}
+	norm := float32(math.Sqrt(sum))
+	for i := range v {
+		v[i] /= norm
+	}
+}
+
+// Encode serializes a vector as little-endian float32s, the layout SQLite
+// vector functions (vector_distance_cos) read from a BLOB.
+func Encode(v []float32) []byte {
+	b := make([]byte, 4*len(v))
+	for i, x := range v {
+		binary.LittleEndian.PutUint32(b[4*i:], math.Float32bits(x))
+	}
+	return b
+}
@@ -0,0 +1,46 @@
+package embed
+
+import (
+	"context"
+	"encoding/binary"
+	"math"
+	"testing"
+)
+
+func TestHashSimilarity(t *testing.T) {
+	h := &Hash{}
+	vs, err := h.Embed(context.Background(), []string{
+		"fix the sqlite migration",
+		"The SQLite migration needed a fix",
+		"deploy requires VPN",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vs) != 3 || len(vs[0]) != 256 {
+		t.Fatalf("unexpected vectors: %d x %d", len(vs), len(vs[0]))
+	}
+	dot := func(a, b []float32) float32 {
+		var s float32
+		for i := range a {
+			s += a[i] * b[i]
+		}
+		return s
+	}
+	if near, far := dot(vs[0], vs[1]), dot(vs[0], vs[2]); near <= far {
+		t.Errorf("expected related texts to be closer: near=%v far=%v", near, far)
+	}
+	if self := dot(vs[0], vs[0]); math.Abs(float64(self)-1) > 1e-5 {
+		t.Errorf("expected unit vector, got norm^2 %v", self)
+	}
+}
+
+func TestEncode(t *testing.T) {
+	b := Encode([]float32{1, -2.5})
+	if len(b) != 8 {
+		t.Fatalf("expected 8 bytes, got %d", len(b))
+	}
+	if got := math.Float32frombits(binary.LittleEndian.Uint32(b[4:])); got != -2.5 {
+		t.Errorf("expected -2.5, got %v", got)
+	}
+}
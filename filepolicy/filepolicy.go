@@ -0,0 +1,264 @@
+// Package filepolicy decides which files may be written and read, so that
+// files saved from the UI and files written or read by tools are held to the
+// same rules: the directories they are confined to, paths that may never be
+// touched, and how large a written file may be. Symbolic links are resolved
+// before any check, and nothing may ever be written inside a .git directory.
+package filepolicy
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// DefaultMaxBytes is the largest file that may be written unless the
+// policy says otherwise.
+const DefaultMaxBytes = 10 << 20
+
+// ErrDenied is returned for a write the policy doesn't allow.
+var ErrDenied = errors.New("write denied")
+
+// Policy describes which files may be written. A nil *Policy allows
+// anything up to DefaultMaxBytes outside .git directories.
+type Policy struct {
+	// AllowedRoots are the directories files may be written under. If
+	// empty, RequireRepository decides.
+	AllowedRoots []string
+	// RequireRepository, when AllowedRoots is empty, confines writes to the
+	// working trees of git repositories. Otherwise any directory is allowed.
+	// Reads aren't confined to them.
+	RequireRepository bool
+	// DeniedGlobs are paths that may not be written or read. A glob without a
+	// slash matches any element of the path (".env", "*.pem"); one with a
+	// slash matches the whole path, with ** for any number of directories
+	// ("/srv/**/secrets/*").
+	DeniedGlobs []string
+	// MaxBytes is the largest file that may be written; zero means
+	// DefaultMaxBytes.
+	MaxBytes int
+}
+
+// Validate checks that the roots are absolute and the globs well formed.
+func (p *Policy) Validate() error {
+	if p == nil {
+		return nil
+	}
+	for _, root := range p.AllowedRoots {
+		if !filepath.IsAbs(root) {
+			return fmt.Errorf("allowed root %q is not an absolute path", root)
+		}
+	}
+	for _, glob := range p.DeniedGlobs {
+		if _, err := path.Match(glob, ""); err != nil || glob == "" {
+			return fmt.Errorf("invalid denied glob %q", glob)
+		}
+	}
+	if p.MaxBytes < 0 {
+		return fmt.Errorf("maxBytes must not be negative")
+	}
+	return nil
+}
+
+// Limit returns the largest file that may be written.
+func (p *Policy) Limit() int {
+	if p == nil || p.MaxBytes == 0 {
+		return DefaultMaxBytes
+	}
+	return p.MaxBytes
+}
+
+// CheckWrite returns an error wrapping ErrDenied unless size bytes may be
+// written to name, an absolute path. Symbolic links in name and the roots
+// are resolved first, so neither can be used to get around the policy.
+func (p *Policy) CheckWrite(name string, size int) error {
+	if size > p.Limit() {
+		return fmt.Errorf("%w: the content is larger than the %d-byte limit", ErrDenied, p.Limit())
+	}
+	resolved := resolve(name)
+	for _, name := range []string{name, resolved} {
+		if slices.Contains(strings.Split(filepath.ToSlash(name), "/"), ".git") {
+			return fmt.Errorf("%w: %s is inside a .git directory", ErrDenied, name)
+		}
+	}
+	if p == nil {
+		return nil
+	}
+
+	roots := p.AllowedRoots
+	if len(roots) == 0 && p.RequireRepository {
+		root, err := gitRoot(existingDir(resolved))
+		if err != nil {
+			return fmt.Errorf("%w: %s is not in a git repository", ErrDenied, name)
+		}
+		roots = []string{root}
+	}
+	return p.check(name, resolved, roots)
+}
+
+// CheckRead returns an error wrapping ErrDenied unless name, an absolute
+// path, may be read: it must be within AllowedRoots, if there are any, and
+// match none of DeniedGlobs. Symbolic links are resolved first, as for
+// CheckWrite.
+func (p *Policy) CheckRead(name string) error {
+	if p == nil {
+		return nil
+	}
+	return p.check(name, resolve(name), p.AllowedRoots)
+}
+
+// Within returns a copy of p that also confines writes and reads to roots,
+// absolute directories, as for a sandbox that only mounts them. Where none
+// of p's AllowedRoots overlaps roots, nothing may be written or read.
+func (p *Policy) Within(roots []string) *Policy {
+	var q Policy
+	if p != nil {
+		q = *p
+		q.DeniedGlobs = slices.Clone(p.DeniedGlobs)
+	}
+	both := roots
+	if len(q.AllowedRoots) > 0 {
+		both = nil
+		for _, a := range q.AllowedRoots {
+			for _, b := range roots {
+				switch {
+				case within(resolve(a), resolve(b)):
+					both = append(both, a)
+				case within(resolve(b), resolve(a)):
+					both = append(both, b)
+				}
+			}
+		}
+	}
+	q.AllowedRoots = both
+	if len(both) == 0 {
+		q.DeniedGlobs = append(q.DeniedGlobs, "/**")
+	}
+	return &q
+}
+
+// Denied reports whether name, an absolute path, matches one of
+// DeniedGlobs. Unlike CheckRead it doesn't resolve symbolic links, so it is
+// cheap enough for every file of a listing.
+func (p *Policy) Denied(name string) bool {
+	if p == nil {
+		return false
+	}
+	return slices.ContainsFunc(p.DeniedGlobs, func(glob string) bool { return deniedGlobMatches(glob, filepath.ToSlash(name)) })
+}
+
+// check returns an error wrapping ErrDenied if name, resolved to resolved,
+// is outside roots or matches one of DeniedGlobs.
+func (p *Policy) check(name, resolved string, roots []string) error {
+	if len(roots) > 0 && !slices.ContainsFunc(roots, func(root string) bool { return within(resolved, resolve(root)) }) {
+		return fmt.Errorf("%w: %s is outside the directories files may be used in", ErrDenied, name)
+	}
+
+	for _, glob := range p.DeniedGlobs {
+		for _, name := range []string{name, resolved} {
+			if deniedGlobMatches(glob, filepath.ToSlash(name)) {
+				return fmt.Errorf("%w: %s matches the denied pattern %q", ErrDenied, name, glob)
+			}
+		}
+	}
+	return nil
+}
+
+// deniedGlobMatches reports whether glob, as in Policy.DeniedGlobs, matches
+// the slash-separated absolute path name.
+func deniedGlobMatches(glob, name string) bool {
+	if strings.Contains(glob, "/") {
+		return MatchGlob(glob, name)
+	}
+	return slices.ContainsFunc(strings.Split(name, "/"), func(elem string) bool {
+		ok, _ := path.Match(glob, elem)
+		return ok
+	})
+}
+
+// MatchGlob reports whether name, a slash-separated path, matches
+// pattern. A pattern without a slash is matched against the base name, and
+// a "**" element matches any number of directories.
+func MatchGlob(pattern, name string) bool {
+	if !strings.Contains(pattern, "/") {
+		ok, _ := path.Match(pattern, path.Base(name))
+		return ok
+	}
+	return matchElems(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchElems(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchElems(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// resolve returns name with the symbolic links in the part of it that
+// exists resolved.
+func resolve(name string) string {
+	var rest []string
+	dir := filepath.Clean(name)
+	for {
+		if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+			return filepath.Join(append([]string{resolved}, rest...)...)
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return filepath.Clean(name)
+		}
+		rest = append([]string{filepath.Base(dir)}, rest...)
+		dir = parent
+	}
+}
+
+// within reports whether name is root or inside it.
+func within(name, root string) bool {
+	rel, err := filepath.Rel(root, name)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, "../")
+}
+
+// existingDir returns the deepest existing directory containing name.
+func existingDir(name string) string {
+	dir := filepath.Dir(name)
+	for {
+		if info, err := os.Stat(dir); err == nil && info.IsDir() || errors.Is(err, fs.ErrPermission) {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return dir
+		}
+		dir = parent
+	}
+}
+
+// gitRoot returns the top of the working tree containing dir.
+func gitRoot(dir string) (string, error) {
+	cmd := exec.Command("git", "rev-parse", "--show-toplevel")
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
@@ -0,0 +1,143 @@
+package filepolicy
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestMatchGlob(t *testing.T) {
+	for _, tc := range []struct {
+		pattern, name string
+		want          bool
+	}{
+		{"*.go", "main.go", true},
+		{"*.go", "cmd/tool/main.go", true},
+		{"*.go", "main.ts", false},
+		{"cmd/*.go", "cmd/main.go", true},
+		{"cmd/*.go", "cmd/tool/main.go", false},
+		{"cmd/**/*.go", "cmd/main.go", true},
+		{"cmd/**/*.go", "cmd/tool/x/main.go", true},
+		{"**/testdata/*", "a/b/testdata/in.txt", true},
+		{"cmd/**", "cmd/tool/main.go", true},
+		{"cmd/**", "ui/main.go", false},
+	} {
+		if got := MatchGlob(tc.pattern, tc.name); got != tc.want {
+			t.Errorf("MatchGlob(%q, %q) = %v, want %v", tc.pattern, tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestCheckWrite(t *testing.T) {
+	repo := t.TempDir()
+	if out, err := exec.Command("git", "init", repo).CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v\n%s", err, out)
+	}
+	outside := t.TempDir()
+	if err := os.Symlink(outside, filepath.Join(repo, "link")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(repo, ".git"), filepath.Join(outside, "gitdir")); err != nil {
+		t.Fatal(err)
+	}
+
+	var unrestricted *Policy
+	repository := &Policy{RequireRepository: true}
+	roots := &Policy{
+		AllowedRoots: []string{repo},
+		DeniedGlobs:  []string{".env", "*.pem", repo + "/**/secrets/*"},
+		MaxBytes:     10,
+	}
+	for _, tc := range []struct {
+		policy *Policy
+		name   string
+		size   int
+		allow  bool
+	}{
+		{unrestricted, filepath.Join(outside, "file.go"), 100, true},
+		{unrestricted, filepath.Join(repo, ".git", "config"), 100, false},
+		{unrestricted, filepath.Join(outside, "gitdir", "hooks", "pre-commit"), 1, false},
+		{unrestricted, filepath.Join(outside, "file.go"), DefaultMaxBytes + 1, false},
+		{repository, filepath.Join(repo, "main.go"), 100, true},
+		{repository, filepath.Join(repo, "new", "dir", "file.go"), 100, true},
+		{repository, filepath.Join(repo, ".git", "config"), 100, false},
+		{repository, filepath.Join(outside, "file.go"), 100, false},
+		{repository, filepath.Join(repo, "link", "file.go"), 100, false},
+		{roots, filepath.Join(repo, "main.go"), 10, true},
+		{roots, filepath.Join(repo, "main.go"), 11, false},
+		{roots, filepath.Join(repo, "link", "file.go"), 1, false},
+		{roots, filepath.Join(repo, "config", ".env"), 1, false},
+		{roots, filepath.Join(repo, "certs", "key.pem"), 1, false},
+		{roots, filepath.Join(repo, "a", "b", "secrets", "token"), 1, false},
+		{roots, filepath.Join(repo, "secrets.go"), 1, true},
+		{&Policy{AllowedRoots: []string{outside}, RequireRepository: true}, filepath.Join(outside, "file.go"), 1, true},
+	} {
+		err := tc.policy.CheckWrite(tc.name, tc.size)
+		if tc.allow && err != nil {
+			t.Errorf("%s (%d bytes): expected it to be allowed, got %v", tc.name, tc.size, err)
+		}
+		if !tc.allow && !errors.Is(err, ErrDenied) {
+			t.Errorf("%s (%d bytes): expected it to be denied, got %v", tc.name, tc.size, err)
+		}
+	}
+
+	// Reads are held to the roots and globs, but not to repositories or .git
+	for _, tc := range []struct {
+		policy *Policy
+		name   string
+		allow  bool
+	}{
+		{unrestricted, filepath.Join(outside, "file.go"), true},
+		{repository, filepath.Join(outside, "file.go"), true},
+		{roots, filepath.Join(repo, ".git", "config"), true},
+		{roots, filepath.Join(repo, "link", "file.go"), false},
+		{roots, filepath.Join(repo, "config", ".env"), false},
+	} {
+		err := tc.policy.CheckRead(tc.name)
+		if tc.allow && err != nil {
+			t.Errorf("reading %s: expected it to be allowed, got %v", tc.name, err)
+		}
+		if !tc.allow && !errors.Is(err, ErrDenied) {
+			t.Errorf("reading %s: expected it to be denied, got %v", tc.name, err)
+		}
+	}
+	if !roots.Denied(filepath.Join(repo, "key.pem")) || roots.Denied(filepath.Join(repo, "main.go")) || unrestricted.Denied("/x/.env") {
+		t.Error("Denied doesn't match the denied globs")
+	}
+
+	for _, invalid := range []*Policy{
+		{AllowedRoots: []string{"relative"}},
+		{DeniedGlobs: []string{"[unclosed"}},
+		{MaxBytes: -1},
+	} {
+		if err := invalid.Validate(); err == nil {
+			t.Errorf("expected %+v to be invalid", invalid)
+		}
+	}
+}
+
+func TestWithin(t *testing.T) {
+	dir := t.TempDir()
+	work := filepath.Join(dir, "work")
+	for _, tc := range []struct {
+		policy *Policy
+		name   string
+		allow  bool
+	}{
+		{nil, filepath.Join(work, "a.go"), true},
+		{nil, filepath.Join(dir, "a.go"), false},
+		{&Policy{AllowedRoots: []string{dir}}, filepath.Join(work, "a.go"), true},
+		{&Policy{AllowedRoots: []string{dir}}, filepath.Join(dir, "a.go"), false},
+		{&Policy{AllowedRoots: []string{filepath.Join(work, "sub")}}, filepath.Join(work, "a.go"), false},
+		{&Policy{AllowedRoots: []string{filepath.Join(work, "sub")}}, filepath.Join(work, "sub", "a.go"), true},
+		{&Policy{AllowedRoots: []string{filepath.Join(dir, "other")}}, filepath.Join(work, "a.go"), false},
+		{&Policy{DeniedGlobs: []string{".env"}}, filepath.Join(work, ".env"), false},
+	} {
+		err := tc.policy.Within([]string{work}).CheckRead(tc.name)
+		if allowed := err == nil; allowed != tc.allow {
+			t.Errorf("%+v Within(%s).CheckRead(%s) = %v, want allowed %v", tc.policy, work, tc.name, err, tc.allow)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package gitstate
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// A ChangeTracker finds the files changed in a worktree while it tracks, such
+// as by a command. Rather than snapshotting the worktree, it asks git status
+// which files differ from HEAD before and after, and only reads those, so it
+// costs little in large repositories and writes nothing to them.
+
+// maxTrackedBytes is the most file content a ChangeTracker keeps from when
+// it started. Files past it aren't tracked.
+const maxTrackedBytes = 64 << 20
+
+// Change is a file that changed while a ChangeTracker tracked.
+type Change struct {
+	// Path is the absolute path of the file.
+	Path string
+	// Before is the file's content before, or nil if it didn't exist.
+	Before []byte
+	// After is the file's content after, or nil if it doesn't exist.
+	After []byte
+}
+
+// ChangeTracker tracks the changes to a worktree; see TrackChanges.
+type ChangeTracker struct {
+	root    string
+	head    string // HEAD when tracking started, "" if there was no commit yet
+	maxSize int64
+	dirty   map[string]fileContent // files that differed from HEAD then, by path within root
+}
+
+// fileContent is a file's content, if it's tracked.
+type fileContent struct {
+	data    []byte // nil if the file doesn't exist
+	skipped bool   // the file is too large, or not a regular file
+}
+
+// TrackChanges starts tracking the changes to the worktree containing dir.
+// Files larger than maxSize are left out.
+func TrackChanges(dir string, maxSize int64) (*ChangeTracker, error) {
+	root, err := gitOutput(dir, nil, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrNotRepo, dir)
+	}
+	t := &ChangeTracker{root: root, maxSize: maxSize, dirty: make(map[string]fileContent)}
+	t.head, _ = gitOutput(root, nil, "rev-parse", "--verify", "--quiet", "HEAD")
+	paths, err := t.status()
+	if err != nil {
+		return nil, err
+	}
+	budget := int64(maxTrackedBytes)
+	for _, path := range paths {
+		content := t.read(path)
+		if budget -= int64(len(content.data)); budget < 0 {
+			content = fileContent{skipped: true}
+		}
+		t.dirty[path] = content
+	}
+	return t, nil
+}
+
+// Changes returns the files that changed since tracking started, by path.
+// Files that differed from HEAD then and don't now are included, as are
+// those that a change of HEAD, such as a checkout, changed.
+func (t *ChangeTracker) Changes() ([]Change, error) {
+	paths, err := t.status()
+	if err != nil {
+		return nil, err
+	}
+	for path := range t.dirty {
+		paths = append(paths, path)
+	}
+	head, _ := gitOutput(t.root, nil, "rev-parse", "--verify", "--quiet", "HEAD")
+	if head != t.head && t.head != "" && head != "" {
+		out, err := gitRaw(t.root, nil, "diff", "--name-only", "-z", "--no-renames", t.head, head)
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, strings.Split(strings.TrimSuffix(out, "\x00"), "\x00")...)
+	}
+	slices.Sort(paths)
+	paths = slices.Compact(paths)
+
+	var changes []Change
+	for _, path := range paths {
+		if path == "" {
+			continue
+		}
+		before, ok := t.dirty[path]
+		if !ok {
+			before = t.committed(path)
+		}
+		after := t.read(path)
+		if before.skipped || after.skipped {
+			continue
+		}
+		if (before.data == nil) == (after.data == nil) && bytes.Equal(before.data, after.data) {
+			continue
+		}
+		changes = append(changes, Change{Path: filepath.Join(t.root, path), Before: before.data, After: after.data})
+	}
+	return changes, nil
+}
+
+// status returns the paths of the files that differ from HEAD, tracked or
+// not, without refreshing the index.
+func (t *ChangeTracker) status() ([]string, error) {
+	out, err := gitRaw(t.root, []string{"GIT_OPTIONAL_LOCKS=0"}, "status", "--porcelain", "-z", "--untracked-files=all", "--no-renames")
+	if err != nil {
+		return nil, err
+	}
+	// Each entry is "XY path"
+	var paths []string
+	for _, entry := range strings.Split(out, "\x00") {
+		if len(entry) > 3 {
+			paths = append(paths, entry[3:])
+		}
+	}
+	return paths, nil
+}
+
+// read returns the content of the file at path within the worktree.
+func (t *ChangeTracker) read(path string) fileContent {
+	info, err := os.Lstat(filepath.Join(t.root, path))
+	switch {
+	case os.IsNotExist(err):
+		return fileContent{}
+	case err != nil, !info.Mode().IsRegular(), info.Size() > t.maxSize:
+		return fileContent{skipped: true}
+	}
+	data, err := os.ReadFile(filepath.Join(t.root, path))
+	if err != nil {
+		return fileContent{skipped: true}
+	}
+	if data == nil {
+		data = []byte{}
+	}
+	return fileContent{data: data}
+}
+
+// committed returns the content of the file at path at the HEAD tracking
+// started at.
+func (t *ChangeTracker) committed(path string) fileContent {
+	if t.head == "" {
+		return fileContent{}
+	}
+	object := t.head + ":" + path
+	size, err := gitOutput(t.root, nil, "cat-file", "-s", object)
+	if err != nil {
+		// Not in the commit
+		return fileContent{}
+	}
+	var n int64
+	if _, err := fmt.Sscan(size, &n); err != nil || n > t.maxSize {
+		return fileContent{skipped: true}
+	}
+	data, err := gitRaw(t.root, nil, "cat-file", "blob", object)
+	if err != nil {
+		return fileContent{skipped: true}
+	}
+	return fileContent{data: append([]byte{}, data...)}
+}
@@ -0,0 +1,100 @@
+package gitstate
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTrackChanges(t *testing.T) {
+	dir := t.TempDir()
+	runGit(t, dir, "init")
+	runGit(t, dir, "config", "user.email", "test@example.com")
+	runGit(t, dir, "config", "user.name", "Test")
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for name, content := range map[string]string{"keep.txt": "same", "edit.txt": "old", "gone.txt": "bye", "dirty.txt": "v1", "big.txt": "small", ".gitignore": "ignored.txt\n"} {
+		write(name, content)
+	}
+	runGit(t, dir, "add", ".")
+	runGit(t, dir, "commit", "-m", "initial")
+	write("dirty.txt", "v2")
+	write("scratch.txt", "scratch")
+
+	tracker, err := TrackChanges(dir, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	write("edit.txt", "new")
+	write("added.txt", "hello")
+	write("dirty.txt", "v1")
+	write("scratch.txt", "scratch")
+	write("ignored.txt", "secret")
+	write("big.txt", "far more than sixteen bytes")
+	os.Remove(filepath.Join(dir, "gone.txt"))
+
+	changes, err := tracker.Changes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]string)
+	for _, c := range changes {
+		rel, err := filepath.Rel(dir, c.Path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		describe := func(b []byte) string {
+			if b == nil {
+				return "<none>"
+			}
+			return string(b)
+		}
+		got[rel] = describe(c.Before) + " -> " + describe(c.After)
+	}
+	want := map[string]string{
+		"added.txt": "<none> -> hello",
+		"edit.txt":  "old -> new",
+		"gone.txt":  "bye -> <none>",
+		"dirty.txt": "v2 -> v1",
+	}
+	if len(got) != len(want) {
+		t.Errorf("got changes %v, want %v", got, want)
+	}
+	for name, w := range want {
+		if got[name] != w {
+			t.Errorf("%s: got %q, want %q", name, got[name], w)
+		}
+	}
+
+	// Files a commit and checkout change are found too
+	tracker, err = TrackChanges(dir, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, dir, "add", ".")
+	runGit(t, dir, "commit", "-m", "second")
+	runGit(t, dir, "checkout", "-q", "HEAD~1", "--", "keep.txt", "edit.txt")
+	runGit(t, dir, "commit", "-m", "third")
+	if changes, err := tracker.Changes(); err != nil || len(changes) != 1 || filepath.Base(changes[0].Path) != "edit.txt" || string(changes[0].After) != "old" {
+		t.Errorf("expected edit.txt changed back, got %+v, %v", changes, err)
+	}
+
+	// Tracking writes nothing to the repository
+	if status := runGitOutput(t, dir, "status", "--porcelain"); status != "" {
+		t.Errorf("expected a clean worktree, got:\n%s", status)
+	}
+	if refs := runGitOutput(t, dir, "for-each-ref", "refs/shelley"); refs != "" {
+		t.Errorf("expected no refs, got:\n%s", refs)
+	}
+}
+
+func TestTrackChangesNotARepo(t *testing.T) {
+	if _, err := TrackChanges(t.TempDir(), 1<<20); !errors.Is(err, ErrNotRepo) {
+		t.Errorf("expected ErrNotRepo, got %v", err)
+	}
+}
@@ -27,17 +27,32 @@ type GitState struct {
 	IsRepo bool
 }
 
+// Runner runs git with args in dir and returns its standard output.
+type Runner func(dir string, args ...string) ([]byte, error)
+
+// localGit runs git on this machine. If dir is empty, it uses the current
+// working directory.
+func localGit(dir string, args ...string) ([]byte, error) {
+	cmd := exec.Command("git", args...)
+	if dir != "" {
+		cmd.Dir = dir
+	}
+	return cmd.Output()
+}
+
 // GetGitState returns the git state for the given directory.
 // If dir is empty, uses the current working directory.
 func GetGitState(dir string) *GitState {
+	return GetGitStateWith(localGit, dir)
+}
+
+// GetGitStateWith is like GetGitState, but runs git with run, which may
+// run it on another machine.
+func GetGitStateWith(run Runner, dir string) *GitState {
 	state := &GitState{}
 
 	// Get the worktree root (this works for both regular repos and worktrees)
-	cmd := exec.Command("git", "rev-parse", "--show-toplevel")
-	if dir != "" {
-		cmd.Dir = dir
-	}
-	output, err := cmd.Output()
+	output, err := run(dir, "rev-parse", "--show-toplevel")
 	if err != nil {
 		// Not in a git repository
 		return state
@@ -46,32 +61,20 @@ func GetGitState(dir string) *GitState {
 	state.Worktree = strings.TrimSpace(string(output))
 
 	// Get the current commit hash (short form)
-	cmd = exec.Command("git", "rev-parse", "--short", "HEAD")
-	if dir != "" {
-		cmd.Dir = dir
-	}
-	output, err = cmd.Output()
+	output, err = run(dir, "rev-parse", "--short", "HEAD")
 	if err == nil {
 		state.Commit = strings.TrimSpace(string(output))
 	}
 
 	// Get the commit subject line
-	cmd = exec.Command("git", "log", "-1", "--format=%s")
-	if dir != "" {
-		cmd.Dir = dir
-	}
-	output, err = cmd.Output()
+	output, err = run(dir, "log", "-1", "--format=%s")
 	if err == nil {
 		state.Subject = strings.TrimSpace(string(output))
 	}
 
 	// Get the current branch name
 	// First try symbolic-ref for normal branches
-	cmd = exec.Command("git", "symbolic-ref", "--short", "HEAD")
-	if dir != "" {
-		cmd.Dir = dir
-	}
-	output, err = cmd.Output()
+	output, err = run(dir, "symbolic-ref", "--short", "HEAD")
 	if err == nil {
 		state.Branch = strings.TrimSpace(string(output))
 	}
@@ -114,11 +117,12 @@ func (g *GitState) String() string {
 // GetGitOrigin returns the git remote origin URL for the given directory.
 // Returns empty string if not in a git repository or no origin is configured.
 func GetGitOrigin(dir string) string {
-	cmd := exec.Command("git", "remote", "get-url", "origin")
-	if dir != "" {
-		cmd.Dir = dir
-	}
-	output, err := cmd.Output()
+	return GetGitOriginWith(localGit, dir)
+}
+
+// GetGitOriginWith is like GetGitOrigin, but runs git with run.
+func GetGitOriginWith(run Runner, dir string) string {
+	output, err := run(dir, "remote", "get-url", "origin")
 	if err != nil {
 		return ""
 	}
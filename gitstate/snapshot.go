@@ -0,0 +1,220 @@
+package gitstate
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Snapshots record the full state of a worktree (tracked and untracked files,
+// honoring .gitignore) as a commit, without touching the user's index, HEAD or
+// branches. Each snapshot's parent is the previous snapshot under the same ref,
+// which keeps the chain reachable and safe from garbage collection.
+
+// ErrNotRepo is returned by Snapshot when the directory is not in a git worktree.
+var ErrNotRepo = errors.New("not a git repository")
+
+// snapshotIdent is the author and committer of snapshot commits.
+var snapshotIdent = []string{
+	"GIT_AUTHOR_NAME=Shelley",
+	"GIT_AUTHOR_EMAIL=shelley@exe.dev",
+	"GIT_COMMITTER_NAME=Shelley",
+	"GIT_COMMITTER_EMAIL=shelley@exe.dev",
+}
+
+// Snapshot records the worktree containing dir as a commit, advances ref to
+// it and returns its hash.
+func Snapshot(dir, ref, message string) (string, error) {
+	root, err := gitOutput(dir, nil, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", ErrNotRepo, dir)
+	}
+
+	index, err := snapshotIndex(root, ref)
+	if err != nil {
+		return "", err
+	}
+	env := []string{"GIT_INDEX_FILE=" + index}
+
+	if _, err := gitOutput(root, env, "add", "-A"); err != nil {
+		return "", err
+	}
+	tree, err := gitOutput(root, env, "write-tree")
+	if err != nil {
+		return "", err
+	}
+	args := []string{"commit-tree", tree, "-m", message}
+	if parent, err := gitOutput(root, nil, "rev-parse", "--verify", "--quiet", ref+"^{commit}"); err == nil {
+		args = append(args, "-p", parent)
+	}
+	commit, err := gitOutput(root, snapshotIdent, args...)
+	if err != nil {
+		return "", err
+	}
+	if _, err := gitOutput(root, nil, "update-ref", ref, commit); err != nil {
+		return "", err
+	}
+	return commit, nil
+}
+
+// DeleteSnapshots deletes ref and the index kept for its snapshots in the
+// worktree containing dir. The snapshot commits, and the files only they
+// hold, are left to git's garbage collection.
+func DeleteSnapshots(dir, ref string) error {
+	root, err := gitOutput(dir, nil, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrNotRepo, dir)
+	}
+	if _, err := gitOutput(root, nil, "update-ref", "-d", ref); err != nil {
+		return err
+	}
+	index, err := snapshotIndexPath(root, ref)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(index); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// RestoreSnapshot makes the worktree containing dir match the snapshot commit:
+// files in the snapshot are written out and files not in it are removed.
+// Ignored files, the index, HEAD and branches are left alone.
+func RestoreSnapshot(dir, commit string) error {
+	root, err := gitOutput(dir, nil, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return err
+	}
+
+	index, cleanup, err := tempIndex()
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	env := []string{"GIT_INDEX_FILE=" + index}
+
+	if _, err := gitOutput(root, env, "read-tree", commit); err != nil {
+		return err
+	}
+	wanted, err := gitRaw(root, env, "ls-files", "-z")
+	if err != nil {
+		return err
+	}
+	present, err := gitRaw(root, nil, "ls-files", "-z", "--cached", "--others", "--exclude-standard")
+	if err != nil {
+		return err
+	}
+
+	keep := make(map[string]bool)
+	for _, name := range strings.Split(wanted, "\x00") {
+		keep[name] = true
+	}
+	for _, name := range strings.Split(present, "\x00") {
+		if name == "" || keep[name] {
+			continue
+		}
+		if err := os.Remove(filepath.Join(root, name)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove %s: %w", name, err)
+		}
+	}
+
+	if _, err := gitOutput(root, env, "checkout-index", "--all", "--force"); err != nil {
+		return err
+	}
+	return nil
+}
+
+// snapshotIndex returns the index file used for snapshots under ref. It is
+// kept between snapshots so unchanged files, tracked or not, needn't be
+// rehashed, and starts as a copy of the real index.
+func snapshotIndex(root, ref string) (string, error) {
+	index, err := snapshotIndexPath(root, ref)
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(index); err == nil {
+		return index, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(index), 0o755); err != nil {
+		return "", fmt.Errorf("failed to create snapshot index directory: %w", err)
+	}
+
+	realIndex, err := gitOutput(root, nil, "rev-parse", "--git-path", "index")
+	if err != nil {
+		return "", err
+	}
+	if !filepath.IsAbs(realIndex) {
+		realIndex = filepath.Join(root, realIndex)
+	}
+	if err := copyFile(realIndex, index); err != nil && !os.IsNotExist(err) {
+		return "", fmt.Errorf("failed to copy index: %w", err)
+	}
+	return index, nil
+}
+
+// snapshotIndexPath returns the path of the index file used for snapshots
+// under ref.
+func snapshotIndexPath(root, ref string) (string, error) {
+	index, err := gitOutput(root, nil, "rev-parse", "--git-path", "shelley/"+strings.ReplaceAll(ref, "/", "-")+".index")
+	if err != nil {
+		return "", err
+	}
+	if !filepath.IsAbs(index) {
+		index = filepath.Join(root, index)
+	}
+	return index, nil
+}
+
+// tempIndex returns the path of an empty scratch index file.
+func tempIndex() (string, func(), error) {
+	tmpDir, err := os.MkdirTemp("", "shelley-index-")
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create temp index: %w", err)
+	}
+	return filepath.Join(tmpDir, "index"), func() { os.RemoveAll(tmpDir) }, nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// gitOutput runs git in dir with extra environment variables and returns its trimmed output.
+func gitOutput(dir string, env []string, args ...string) (string, error) {
+	output, err := gitRaw(dir, env, args...)
+	return strings.TrimSpace(output), err
+}
+
+// gitRaw is like gitOutput but returns the output untrimmed, for NUL-separated lists.
+func gitRaw(dir string, env []string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	if env != nil {
+		cmd.Env = append(os.Environ(), env...)
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return string(output), nil
+}
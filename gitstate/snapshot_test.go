@@ -0,0 +1,116 @@
+package gitstate
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSnapshotAndRestore(t *testing.T) {
+	dir := t.TempDir()
+	runGit(t, dir, "init")
+	runGit(t, dir, "config", "user.email", "test@example.com")
+	runGit(t, dir, "config", "user.name", "Test")
+
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	read := func(name string) string {
+		t.Helper()
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return "<missing>"
+		}
+		return string(data)
+	}
+
+	write("tracked.txt", "v1")
+	write(".gitignore", "ignored.txt\n")
+	runGit(t, dir, "add", ".")
+	runGit(t, dir, "commit", "-m", "initial")
+	write("untracked.txt", "scratch")
+	write("ignored.txt", "secret")
+	head := runGitOutput(t, dir, "rev-parse", "HEAD")
+
+	const ref = "refs/shelley/test"
+	first, err := Snapshot(dir, ref, "checkpoint 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The agent edits, deletes and creates files, and commits some of it
+	write("tracked.txt", "v2")
+	os.Remove(filepath.Join(dir, "untracked.txt"))
+	write("sub/new.txt", "new")
+	runGit(t, dir, "add", "tracked.txt")
+	runGit(t, dir, "commit", "-m", "agent change")
+
+	second, err := Snapshot(dir, ref, "checkpoint 2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parent := strings.TrimSpace(runGitOutput(t, dir, "rev-parse", second+"^")); parent != first {
+		t.Errorf("second snapshot parent = %s, want %s", parent, first)
+	}
+	if got := strings.TrimSpace(runGitOutput(t, dir, "rev-parse", ref)); got != second {
+		t.Errorf("ref = %s, want %s", got, second)
+	}
+
+	if err := RestoreSnapshot(filepath.Join(dir, "sub"), first); err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]string{
+		"tracked.txt":   "v1",
+		"untracked.txt": "scratch",
+		"ignored.txt":   "secret",
+		"sub/new.txt":   "<missing>",
+	} {
+		if got := read(name); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+
+	// Snapshots leave the user's HEAD and index alone
+	if got := runGitOutput(t, dir, "rev-parse", "HEAD"); got == head {
+		t.Error("expected HEAD to keep the agent's commit")
+	}
+	if status := runGitOutput(t, dir, "status", "--porcelain"); !strings.Contains(status, "tracked.txt") {
+		t.Errorf("expected restored tracked.txt to show as modified, got:\n%s", status)
+	}
+
+	if err := RestoreSnapshot(dir, second); err != nil {
+		t.Fatal(err)
+	}
+	if got := read("sub/new.txt"); got != "new" {
+		t.Errorf("sub/new.txt = %q after restoring second snapshot", got)
+	}
+	if got := read("untracked.txt"); got != "<missing>" {
+		t.Errorf("untracked.txt = %q after restoring second snapshot", got)
+	}
+
+	if err := DeleteSnapshots(dir, ref); err != nil {
+		t.Fatal(err)
+	}
+	if out, err := gitOutput(dir, nil, "rev-parse", "--verify", "--quiet", ref); err == nil {
+		t.Errorf("expected the ref deleted, got %s", out)
+	}
+	if index, _ := snapshotIndexPath(dir, ref); index == "" {
+		t.Error("no snapshot index path")
+	} else if _, err := os.Stat(index); !os.IsNotExist(err) {
+		t.Errorf("expected the snapshot index removed, got %v", err)
+	}
+}
+
+func TestSnapshotNotARepo(t *testing.T) {
+	if _, err := Snapshot(t.TempDir(), "refs/shelley/test", "checkpoint"); !errors.Is(err, ErrNotRepo) {
+		t.Errorf("expected ErrNotRepo, got %v", err)
+	}
+}
@@ -0,0 +1,6 @@
+// Package grpcapi holds the protobuf definitions of Shelley's gRPC API and
+// the code generated from them. The server implements it in
+// server.GRPCServer; see shelley.proto for the service.
+package grpcapi
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative shelley.proto
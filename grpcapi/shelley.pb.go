@@ -0,0 +1,2157 @@
+// The gRPC API of the Shelley server. It mirrors the REST API under /api/
+// for services that prefer gRPC: conversations, their messages and tools,
+// and a server stream of each conversation's updates in place of the SSE
+// stream.
+//
+// Regenerate shelley.pb.go and shelley_grpc.pb.go after changing this file:
+//
+//	go generate ./grpcapi
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.8
+// 	protoc        v5.29.0
+// source: shelley.proto
+
+package grpcapi
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type Role int32
+
+const (
+	Role_ROLE_UNSPECIFIED Role = 0
+	Role_ROLE_USER        Role = 1
+	Role_ROLE_ASSISTANT   Role = 2
+)
+
+// Enum value maps for Role.
+var (
+	Role_name = map[int32]string{
+		0: "ROLE_UNSPECIFIED",
+		1: "ROLE_USER",
+		2: "ROLE_ASSISTANT",
+	}
+	Role_value = map[string]int32{
+		"ROLE_UNSPECIFIED": 0,
+		"ROLE_USER":        1,
+		"ROLE_ASSISTANT":   2,
+	}
+)
+
+func (x Role) Enum() *Role {
+	p := new(Role)
+	*p = x
+	return p
+}
+
+func (x Role) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Role) Descriptor() protoreflect.EnumDescriptor {
+	return file_shelley_proto_enumTypes[0].Descriptor()
+}
+
+func (Role) Type() protoreflect.EnumType {
+	return &file_shelley_proto_enumTypes[0]
+}
+
+func (x Role) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Role.Descriptor instead.
+func (Role) EnumDescriptor() ([]byte, []int) {
+	return file_shelley_proto_rawDescGZIP(), []int{0}
+}
+
+type ContentType int32
+
+const (
+	ContentType_CONTENT_TYPE_UNSPECIFIED       ContentType = 0
+	ContentType_CONTENT_TYPE_TEXT              ContentType = 1
+	ContentType_CONTENT_TYPE_THINKING          ContentType = 2
+	ContentType_CONTENT_TYPE_REDACTED_THINKING ContentType = 3
+	ContentType_CONTENT_TYPE_TOOL_USE          ContentType = 4
+	ContentType_CONTENT_TYPE_TOOL_RESULT       ContentType = 5
+)
+
+// Enum value maps for ContentType.
+var (
+	ContentType_name = map[int32]string{
+		0: "CONTENT_TYPE_UNSPECIFIED",
+		1: "CONTENT_TYPE_TEXT",
+		2: "CONTENT_TYPE_THINKING",
+		3: "CONTENT_TYPE_REDACTED_THINKING",
+		4: "CONTENT_TYPE_TOOL_USE",
+		5: "CONTENT_TYPE_TOOL_RESULT",
+	}
+	ContentType_value = map[string]int32{
+		"CONTENT_TYPE_UNSPECIFIED":       0,
+		"CONTENT_TYPE_TEXT":              1,
+		"CONTENT_TYPE_THINKING":          2,
+		"CONTENT_TYPE_REDACTED_THINKING": 3,
+		"CONTENT_TYPE_TOOL_USE":          4,
+		"CONTENT_TYPE_TOOL_RESULT":       5,
+	}
+)
+
+func (x ContentType) Enum() *ContentType {
+	p := new(ContentType)
+	*p = x
+	return p
+}
+
+func (x ContentType) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (ContentType) Descriptor() protoreflect.EnumDescriptor {
+	return file_shelley_proto_enumTypes[1].Descriptor()
+}
+
+func (ContentType) Type() protoreflect.EnumType {
+	return &file_shelley_proto_enumTypes[1]
+}
+
+func (x ContentType) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use ContentType.Descriptor instead.
+func (ContentType) EnumDescriptor() ([]byte, []int) {
+	return file_shelley_proto_rawDescGZIP(), []int{1}
+}
+
+type Conversation struct {
+	state                protoimpl.MessageState `protogen:"open.v1"`
+	ConversationId       string                 `protobuf:"bytes,1,opt,name=conversation_id,json=conversationId,proto3" json:"conversation_id,omitempty"`
+	Slug                 string                 `protobuf:"bytes,2,opt,name=slug,proto3" json:"slug,omitempty"`
+	Cwd                  string                 `protobuf:"bytes,3,opt,name=cwd,proto3" json:"cwd,omitempty"`
+	Model                string                 `protobuf:"bytes,4,opt,name=model,proto3" json:"model,omitempty"`
+	ParentConversationId string                 `protobuf:"bytes,5,opt,name=parent_conversation_id,json=parentConversationId,proto3" json:"parent_conversation_id,omitempty"`
+	Archived             bool                   `protobuf:"varint,6,opt,name=archived,proto3" json:"archived,omitempty"`
+	Pinned               bool                   `protobuf:"varint,7,opt,name=pinned,proto3" json:"pinned,omitempty"`
+	AgentWorking         bool                   `protobuf:"varint,8,opt,name=agent_working,json=agentWorking,proto3" json:"agent_working,omitempty"`
+	AgentError           bool                   `protobuf:"varint,9,opt,name=agent_error,json=agentError,proto3" json:"agent_error,omitempty"`
+	ContextWindowSize    int64                  `protobuf:"varint,10,opt,name=context_window_size,json=contextWindowSize,proto3" json:"context_window_size,omitempty"`
+	CreatedAt            *timestamppb.Timestamp `protobuf:"bytes,11,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	UpdatedAt            *timestamppb.Timestamp `protobuf:"bytes,12,opt,name=updated_at,json=updatedAt,proto3" json:"updated_at,omitempty"`
+	ProjectId            string                 `protobuf:"bytes,13,opt,name=project_id,json=projectId,proto3" json:"project_id,omitempty"`
+	// The guidance files (AGENTS.md and the like) in the system prompt
+	GuidanceFiles []string `protobuf:"bytes,14,rep,name=guidance_files,json=guidanceFiles,proto3" json:"guidance_files,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Conversation) Reset() {
+	*x = Conversation{}
+	mi := &file_shelley_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Conversation) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Conversation) ProtoMessage() {}
+
+func (x *Conversation) ProtoReflect() protoreflect.Message {
+	mi := &file_shelley_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Conversation.ProtoReflect.Descriptor instead.
+func (*Conversation) Descriptor() ([]byte, []int) {
+	return file_shelley_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Conversation) GetConversationId() string {
+	if x != nil {
+		return x.ConversationId
+	}
+	return ""
+}
+
+func (x *Conversation) GetSlug() string {
+	if x != nil {
+		return x.Slug
+	}
+	return ""
+}
+
+func (x *Conversation) GetCwd() string {
+	if x != nil {
+		return x.Cwd
+	}
+	return ""
+}
+
+func (x *Conversation) GetModel() string {
+	if x != nil {
+		return x.Model
+	}
+	return ""
+}
+
+func (x *Conversation) GetParentConversationId() string {
+	if x != nil {
+		return x.ParentConversationId
+	}
+	return ""
+}
+
+func (x *Conversation) GetArchived() bool {
+	if x != nil {
+		return x.Archived
+	}
+	return false
+}
+
+func (x *Conversation) GetPinned() bool {
+	if x != nil {
+		return x.Pinned
+	}
+	return false
+}
+
+func (x *Conversation) GetAgentWorking() bool {
+	if x != nil {
+		return x.AgentWorking
+	}
+	return false
+}
+
+func (x *Conversation) GetAgentError() bool {
+	if x != nil {
+		return x.AgentError
+	}
+	return false
+}
+
+func (x *Conversation) GetContextWindowSize() int64 {
+	if x != nil {
+		return x.ContextWindowSize
+	}
+	return 0
+}
+
+func (x *Conversation) GetCreatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return nil
+}
+
+func (x *Conversation) GetUpdatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.UpdatedAt
+	}
+	return nil
+}
+
+func (x *Conversation) GetProjectId() string {
+	if x != nil {
+		return x.ProjectId
+	}
+	return ""
+}
+
+func (x *Conversation) GetGuidanceFiles() []string {
+	if x != nil {
+		return x.GuidanceFiles
+	}
+	return nil
+}
+
+type Content struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Type     ContentType            `protobuf:"varint,1,opt,name=type,proto3,enum=shelley.v1.ContentType" json:"type,omitempty"`
+	Text     string                 `protobuf:"bytes,2,opt,name=text,proto3" json:"text,omitempty"`
+	Thinking string                 `protobuf:"bytes,3,opt,name=thinking,proto3" json:"thinking,omitempty"`
+	// For CONTENT_TYPE_TOOL_USE
+	Id            string `protobuf:"bytes,4,opt,name=id,proto3" json:"id,omitempty"`
+	ToolName      string `protobuf:"bytes,5,opt,name=tool_name,json=toolName,proto3" json:"tool_name,omitempty"`
+	ToolInputJson string `protobuf:"bytes,6,opt,name=tool_input_json,json=toolInputJson,proto3" json:"tool_input_json,omitempty"`
+	// For CONTENT_TYPE_TOOL_RESULT
+	ToolUseId     string     `protobuf:"bytes,7,opt,name=tool_use_id,json=toolUseId,proto3" json:"tool_use_id,omitempty"`
+	ToolError     bool       `protobuf:"varint,8,opt,name=tool_error,json=toolError,proto3" json:"tool_error,omitempty"`
+	ToolResult    []*Content `protobuf:"bytes,9,rep,name=tool_result,json=toolResult,proto3" json:"tool_result,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Content) Reset() {
+	*x = Content{}
+	mi := &file_shelley_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Content) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Content) ProtoMessage() {}
+
+func (x *Content) ProtoReflect() protoreflect.Message {
+	mi := &file_shelley_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Content.ProtoReflect.Descriptor instead.
+func (*Content) Descriptor() ([]byte, []int) {
+	return file_shelley_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Content) GetType() ContentType {
+	if x != nil {
+		return x.Type
+	}
+	return ContentType_CONTENT_TYPE_UNSPECIFIED
+}
+
+func (x *Content) GetText() string {
+	if x != nil {
+		return x.Text
+	}
+	return ""
+}
+
+func (x *Content) GetThinking() string {
+	if x != nil {
+		return x.Thinking
+	}
+	return ""
+}
+
+func (x *Content) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Content) GetToolName() string {
+	if x != nil {
+		return x.ToolName
+	}
+	return ""
+}
+
+func (x *Content) GetToolInputJson() string {
+	if x != nil {
+		return x.ToolInputJson
+	}
+	return ""
+}
+
+func (x *Content) GetToolUseId() string {
+	if x != nil {
+		return x.ToolUseId
+	}
+	return ""
+}
+
+func (x *Content) GetToolError() bool {
+	if x != nil {
+		return x.ToolError
+	}
+	return false
+}
+
+func (x *Content) GetToolResult() []*Content {
+	if x != nil {
+		return x.ToolResult
+	}
+	return nil
+}
+
+type Usage struct {
+	state                    protoimpl.MessageState `protogen:"open.v1"`
+	InputTokens              uint64                 `protobuf:"varint,1,opt,name=input_tokens,json=inputTokens,proto3" json:"input_tokens,omitempty"`
+	CacheCreationInputTokens uint64                 `protobuf:"varint,2,opt,name=cache_creation_input_tokens,json=cacheCreationInputTokens,proto3" json:"cache_creation_input_tokens,omitempty"`
+	CacheReadInputTokens     uint64                 `protobuf:"varint,3,opt,name=cache_read_input_tokens,json=cacheReadInputTokens,proto3" json:"cache_read_input_tokens,omitempty"`
+	OutputTokens             uint64                 `protobuf:"varint,4,opt,name=output_tokens,json=outputTokens,proto3" json:"output_tokens,omitempty"`
+	CostUsd                  float64                `protobuf:"fixed64,5,opt,name=cost_usd,json=costUsd,proto3" json:"cost_usd,omitempty"`
+	Model                    string                 `protobuf:"bytes,6,opt,name=model,proto3" json:"model,omitempty"`
+	unknownFields            protoimpl.UnknownFields
+	sizeCache                protoimpl.SizeCache
+}
+
+func (x *Usage) Reset() {
+	*x = Usage{}
+	mi := &file_shelley_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Usage) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Usage) ProtoMessage() {}
+
+func (x *Usage) ProtoReflect() protoreflect.Message {
+	mi := &file_shelley_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Usage.ProtoReflect.Descriptor instead.
+func (*Usage) Descriptor() ([]byte, []int) {
+	return file_shelley_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Usage) GetInputTokens() uint64 {
+	if x != nil {
+		return x.InputTokens
+	}
+	return 0
+}
+
+func (x *Usage) GetCacheCreationInputTokens() uint64 {
+	if x != nil {
+		return x.CacheCreationInputTokens
+	}
+	return 0
+}
+
+func (x *Usage) GetCacheReadInputTokens() uint64 {
+	if x != nil {
+		return x.CacheReadInputTokens
+	}
+	return 0
+}
+
+func (x *Usage) GetOutputTokens() uint64 {
+	if x != nil {
+		return x.OutputTokens
+	}
+	return 0
+}
+
+func (x *Usage) GetCostUsd() float64 {
+	if x != nil {
+		return x.CostUsd
+	}
+	return 0
+}
+
+func (x *Usage) GetModel() string {
+	if x != nil {
+		return x.Model
+	}
+	return ""
+}
+
+type Message struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	MessageId      string                 `protobuf:"bytes,1,opt,name=message_id,json=messageId,proto3" json:"message_id,omitempty"`
+	ConversationId string                 `protobuf:"bytes,2,opt,name=conversation_id,json=conversationId,proto3" json:"conversation_id,omitempty"`
+	SequenceId     int64                  `protobuf:"varint,3,opt,name=sequence_id,json=sequenceId,proto3" json:"sequence_id,omitempty"`
+	// user, agent, tool, error, gitinfo or summary, as in the REST API.
+	// Tool results are user messages.
+	Type      string                 `protobuf:"bytes,4,opt,name=type,proto3" json:"type,omitempty"`
+	Role      Role                   `protobuf:"varint,5,opt,name=role,proto3,enum=shelley.v1.Role" json:"role,omitempty"`
+	Content   []*Content             `protobuf:"bytes,6,rep,name=content,proto3" json:"content,omitempty"`
+	Usage     *Usage                 `protobuf:"bytes,7,opt,name=usage,proto3" json:"usage,omitempty"`
+	EndOfTurn bool                   `protobuf:"varint,8,opt,name=end_of_turn,json=endOfTurn,proto3" json:"end_of_turn,omitempty"`
+	CreatedAt *timestamppb.Timestamp `protobuf:"bytes,9,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	// user_data_json and display_data_json are the JSON documents the REST
+	// API calls user_data and display_data.
+	UserDataJson    string `protobuf:"bytes,10,opt,name=user_data_json,json=userDataJson,proto3" json:"user_data_json,omitempty"`
+	DisplayDataJson string `protobuf:"bytes,11,opt,name=display_data_json,json=displayDataJson,proto3" json:"display_data_json,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *Message) Reset() {
+	*x = Message{}
+	mi := &file_shelley_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Message) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Message) ProtoMessage() {}
+
+func (x *Message) ProtoReflect() protoreflect.Message {
+	mi := &file_shelley_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Message.ProtoReflect.Descriptor instead.
+func (*Message) Descriptor() ([]byte, []int) {
+	return file_shelley_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *Message) GetMessageId() string {
+	if x != nil {
+		return x.MessageId
+	}
+	return ""
+}
+
+func (x *Message) GetConversationId() string {
+	if x != nil {
+		return x.ConversationId
+	}
+	return ""
+}
+
+func (x *Message) GetSequenceId() int64 {
+	if x != nil {
+		return x.SequenceId
+	}
+	return 0
+}
+
+func (x *Message) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *Message) GetRole() Role {
+	if x != nil {
+		return x.Role
+	}
+	return Role_ROLE_UNSPECIFIED
+}
+
+func (x *Message) GetContent() []*Content {
+	if x != nil {
+		return x.Content
+	}
+	return nil
+}
+
+func (x *Message) GetUsage() *Usage {
+	if x != nil {
+		return x.Usage
+	}
+	return nil
+}
+
+func (x *Message) GetEndOfTurn() bool {
+	if x != nil {
+		return x.EndOfTurn
+	}
+	return false
+}
+
+func (x *Message) GetCreatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return nil
+}
+
+func (x *Message) GetUserDataJson() string {
+	if x != nil {
+		return x.UserDataJson
+	}
+	return ""
+}
+
+func (x *Message) GetDisplayDataJson() string {
+	if x != nil {
+		return x.DisplayDataJson
+	}
+	return ""
+}
+
+type ToolOutput struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	ToolUseId string                 `protobuf:"bytes,1,opt,name=tool_use_id,json=toolUseId,proto3" json:"tool_use_id,omitempty"`
+	Output    string                 `protobuf:"bytes,2,opt,name=output,proto3" json:"output,omitempty"`
+	// Set if output is the output so far, possibly cut to its end, rather
+	// than what followed the last update.
+	Replace       bool `protobuf:"varint,3,opt,name=replace,proto3" json:"replace,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ToolOutput) Reset() {
+	*x = ToolOutput{}
+	mi := &file_shelley_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ToolOutput) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ToolOutput) ProtoMessage() {}
+
+func (x *ToolOutput) ProtoReflect() protoreflect.Message {
+	mi := &file_shelley_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ToolOutput.ProtoReflect.Descriptor instead.
+func (*ToolOutput) Descriptor() ([]byte, []int) {
+	return file_shelley_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *ToolOutput) GetToolUseId() string {
+	if x != nil {
+		return x.ToolUseId
+	}
+	return ""
+}
+
+func (x *ToolOutput) GetOutput() string {
+	if x != nil {
+		return x.Output
+	}
+	return ""
+}
+
+func (x *ToolOutput) GetReplace() bool {
+	if x != nil {
+		return x.Replace
+	}
+	return false
+}
+
+type QueuedMessage struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Message       string                 `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *QueuedMessage) Reset() {
+	*x = QueuedMessage{}
+	mi := &file_shelley_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *QueuedMessage) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*QueuedMessage) ProtoMessage() {}
+
+func (x *QueuedMessage) ProtoReflect() protoreflect.Message {
+	mi := &file_shelley_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use QueuedMessage.ProtoReflect.Descriptor instead.
+func (*QueuedMessage) Descriptor() ([]byte, []int) {
+	return file_shelley_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *QueuedMessage) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *QueuedMessage) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+type Event struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Messages []*Message             `protobuf:"bytes,1,rep,name=messages,proto3" json:"messages,omitempty"`
+	// Unset on events carrying only tool_output or queue.
+	Conversation      *Conversation `protobuf:"bytes,2,opt,name=conversation,proto3" json:"conversation,omitempty"`
+	AgentWorking      bool          `protobuf:"varint,3,opt,name=agent_working,json=agentWorking,proto3" json:"agent_working,omitempty"`
+	ContextWindowSize uint64        `protobuf:"varint,4,opt,name=context_window_size,json=contextWindowSize,proto3" json:"context_window_size,omitempty"`
+	ToolOutput        *ToolOutput   `protobuf:"bytes,5,opt,name=tool_output,json=toolOutput,proto3" json:"tool_output,omitempty"`
+	// Set, possibly empty, when the queued messages change.
+	Queue         *MessageQueue `protobuf:"bytes,6,opt,name=queue,proto3" json:"queue,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Event) Reset() {
+	*x = Event{}
+	mi := &file_shelley_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Event) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Event) ProtoMessage() {}
+
+func (x *Event) ProtoReflect() protoreflect.Message {
+	mi := &file_shelley_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Event.ProtoReflect.Descriptor instead.
+func (*Event) Descriptor() ([]byte, []int) {
+	return file_shelley_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *Event) GetMessages() []*Message {
+	if x != nil {
+		return x.Messages
+	}
+	return nil
+}
+
+func (x *Event) GetConversation() *Conversation {
+	if x != nil {
+		return x.Conversation
+	}
+	return nil
+}
+
+func (x *Event) GetAgentWorking() bool {
+	if x != nil {
+		return x.AgentWorking
+	}
+	return false
+}
+
+func (x *Event) GetContextWindowSize() uint64 {
+	if x != nil {
+		return x.ContextWindowSize
+	}
+	return 0
+}
+
+func (x *Event) GetToolOutput() *ToolOutput {
+	if x != nil {
+		return x.ToolOutput
+	}
+	return nil
+}
+
+func (x *Event) GetQueue() *MessageQueue {
+	if x != nil {
+		return x.Queue
+	}
+	return nil
+}
+
+type MessageQueue struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Messages      []*QueuedMessage       `protobuf:"bytes,1,rep,name=messages,proto3" json:"messages,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MessageQueue) Reset() {
+	*x = MessageQueue{}
+	mi := &file_shelley_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MessageQueue) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MessageQueue) ProtoMessage() {}
+
+func (x *MessageQueue) ProtoReflect() protoreflect.Message {
+	mi := &file_shelley_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MessageQueue.ProtoReflect.Descriptor instead.
+func (*MessageQueue) Descriptor() ([]byte, []int) {
+	return file_shelley_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *MessageQueue) GetMessages() []*QueuedMessage {
+	if x != nil {
+		return x.Messages
+	}
+	return nil
+}
+
+type Sandbox struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Enabled       bool                   `protobuf:"varint,1,opt,name=enabled,proto3" json:"enabled,omitempty"`
+	AllowNetwork  bool                   `protobuf:"varint,2,opt,name=allow_network,json=allowNetwork,proto3" json:"allow_network,omitempty"`
+	WritablePaths []string               `protobuf:"bytes,3,rep,name=writable_paths,json=writablePaths,proto3" json:"writable_paths,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Sandbox) Reset() {
+	*x = Sandbox{}
+	mi := &file_shelley_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Sandbox) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Sandbox) ProtoMessage() {}
+
+func (x *Sandbox) ProtoReflect() protoreflect.Message {
+	mi := &file_shelley_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Sandbox.ProtoReflect.Descriptor instead.
+func (*Sandbox) Descriptor() ([]byte, []int) {
+	return file_shelley_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *Sandbox) GetEnabled() bool {
+	if x != nil {
+		return x.Enabled
+	}
+	return false
+}
+
+func (x *Sandbox) GetAllowNetwork() bool {
+	if x != nil {
+		return x.AllowNetwork
+	}
+	return false
+}
+
+func (x *Sandbox) GetWritablePaths() []string {
+	if x != nil {
+		return x.WritablePaths
+	}
+	return nil
+}
+
+type Remote struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The ssh destination: [user@]host, or a Host from ~/.ssh/config.
+	Host string `protobuf:"bytes,1,opt,name=host,proto3" json:"host,omitempty"`
+	Port int32  `protobuf:"varint,2,opt,name=port,proto3" json:"port,omitempty"`
+	// The private key, on the server, to authenticate with.
+	IdentityFile  string `protobuf:"bytes,3,opt,name=identity_file,json=identityFile,proto3" json:"identity_file,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Remote) Reset() {
+	*x = Remote{}
+	mi := &file_shelley_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Remote) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Remote) ProtoMessage() {}
+
+func (x *Remote) ProtoReflect() protoreflect.Message {
+	mi := &file_shelley_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Remote.ProtoReflect.Descriptor instead.
+func (*Remote) Descriptor() ([]byte, []int) {
+	return file_shelley_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *Remote) GetHost() string {
+	if x != nil {
+		return x.Host
+	}
+	return ""
+}
+
+func (x *Remote) GetPort() int32 {
+	if x != nil {
+		return x.Port
+	}
+	return 0
+}
+
+func (x *Remote) GetIdentityFile() string {
+	if x != nil {
+		return x.IdentityFile
+	}
+	return ""
+}
+
+type ListConversationsRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Defaults to 5000.
+	Limit  int32 `protobuf:"varint,1,opt,name=limit,proto3" json:"limit,omitempty"`
+	Offset int32 `protobuf:"varint,2,opt,name=offset,proto3" json:"offset,omitempty"`
+	// Searches the conversations' slugs and messages.
+	Query         string `protobuf:"bytes,3,opt,name=query,proto3" json:"query,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListConversationsRequest) Reset() {
+	*x = ListConversationsRequest{}
+	mi := &file_shelley_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListConversationsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListConversationsRequest) ProtoMessage() {}
+
+func (x *ListConversationsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_shelley_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListConversationsRequest.ProtoReflect.Descriptor instead.
+func (*ListConversationsRequest) Descriptor() ([]byte, []int) {
+	return file_shelley_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *ListConversationsRequest) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+func (x *ListConversationsRequest) GetOffset() int32 {
+	if x != nil {
+		return x.Offset
+	}
+	return 0
+}
+
+func (x *ListConversationsRequest) GetQuery() string {
+	if x != nil {
+		return x.Query
+	}
+	return ""
+}
+
+type ListConversationsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Conversations []*Conversation        `protobuf:"bytes,1,rep,name=conversations,proto3" json:"conversations,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListConversationsResponse) Reset() {
+	*x = ListConversationsResponse{}
+	mi := &file_shelley_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListConversationsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListConversationsResponse) ProtoMessage() {}
+
+func (x *ListConversationsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_shelley_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListConversationsResponse.ProtoReflect.Descriptor instead.
+func (*ListConversationsResponse) Descriptor() ([]byte, []int) {
+	return file_shelley_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *ListConversationsResponse) GetConversations() []*Conversation {
+	if x != nil {
+		return x.Conversations
+	}
+	return nil
+}
+
+type GetConversationRequest struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	ConversationId string                 `protobuf:"bytes,1,opt,name=conversation_id,json=conversationId,proto3" json:"conversation_id,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *GetConversationRequest) Reset() {
+	*x = GetConversationRequest{}
+	mi := &file_shelley_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetConversationRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetConversationRequest) ProtoMessage() {}
+
+func (x *GetConversationRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_shelley_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetConversationRequest.ProtoReflect.Descriptor instead.
+func (*GetConversationRequest) Descriptor() ([]byte, []int) {
+	return file_shelley_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *GetConversationRequest) GetConversationId() string {
+	if x != nil {
+		return x.ConversationId
+	}
+	return ""
+}
+
+type GetConversationResponse struct {
+	state             protoimpl.MessageState `protogen:"open.v1"`
+	Conversation      *Conversation          `protobuf:"bytes,1,opt,name=conversation,proto3" json:"conversation,omitempty"`
+	Messages          []*Message             `protobuf:"bytes,2,rep,name=messages,proto3" json:"messages,omitempty"`
+	AgentWorking      bool                   `protobuf:"varint,3,opt,name=agent_working,json=agentWorking,proto3" json:"agent_working,omitempty"`
+	ContextWindowSize uint64                 `protobuf:"varint,4,opt,name=context_window_size,json=contextWindowSize,proto3" json:"context_window_size,omitempty"`
+	unknownFields     protoimpl.UnknownFields
+	sizeCache         protoimpl.SizeCache
+}
+
+func (x *GetConversationResponse) Reset() {
+	*x = GetConversationResponse{}
+	mi := &file_shelley_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetConversationResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetConversationResponse) ProtoMessage() {}
+
+func (x *GetConversationResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_shelley_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetConversationResponse.ProtoReflect.Descriptor instead.
+func (*GetConversationResponse) Descriptor() ([]byte, []int) {
+	return file_shelley_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *GetConversationResponse) GetConversation() *Conversation {
+	if x != nil {
+		return x.Conversation
+	}
+	return nil
+}
+
+func (x *GetConversationResponse) GetMessages() []*Message {
+	if x != nil {
+		return x.Messages
+	}
+	return nil
+}
+
+func (x *GetConversationResponse) GetAgentWorking() bool {
+	if x != nil {
+		return x.AgentWorking
+	}
+	return false
+}
+
+func (x *GetConversationResponse) GetContextWindowSize() uint64 {
+	if x != nil {
+		return x.ContextWindowSize
+	}
+	return 0
+}
+
+type NewConversationRequest struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Message string                 `protobuf:"bytes,1,opt,name=message,proto3" json:"message,omitempty"`
+	// Defaults to the project's default model, then the user's.
+	Model string `protobuf:"bytes,2,opt,name=model,proto3" json:"model,omitempty"`
+	Cwd   string `protobuf:"bytes,3,opt,name=cwd,proto3" json:"cwd,omitempty"`
+	// Overrides the server's default sandbox options.
+	Sandbox *Sandbox `protobuf:"bytes,4,opt,name=sandbox,proto3" json:"sandbox,omitempty"`
+	// Restrict the conversation's tools, as in SetConversationTools.
+	AllowedTools []string `protobuf:"bytes,5,rep,name=allowed_tools,json=allowedTools,proto3" json:"allowed_tools,omitempty"`
+	ToolPreset   string   `protobuf:"bytes,6,opt,name=tool_preset,json=toolPreset,proto3" json:"tool_preset,omitempty"`
+	// Runs the conversation's tools on another host over SSH, in cwd there.
+	Remote *Remote `protobuf:"bytes,7,opt,name=remote,proto3" json:"remote,omitempty"`
+	// Runs the conversation's tools in the dev container configured in cwd.
+	Devcontainer bool `protobuf:"varint,8,opt,name=devcontainer,proto3" json:"devcontainer,omitempty"`
+	// Starts the conversation in a project, whose root and defaults apply
+	// where the request leaves cwd, model and tools unset.
+	ProjectId     string `protobuf:"bytes,9,opt,name=project_id,json=projectId,proto3" json:"project_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *NewConversationRequest) Reset() {
+	*x = NewConversationRequest{}
+	mi := &file_shelley_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *NewConversationRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*NewConversationRequest) ProtoMessage() {}
+
+func (x *NewConversationRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_shelley_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use NewConversationRequest.ProtoReflect.Descriptor instead.
+func (*NewConversationRequest) Descriptor() ([]byte, []int) {
+	return file_shelley_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *NewConversationRequest) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *NewConversationRequest) GetModel() string {
+	if x != nil {
+		return x.Model
+	}
+	return ""
+}
+
+func (x *NewConversationRequest) GetCwd() string {
+	if x != nil {
+		return x.Cwd
+	}
+	return ""
+}
+
+func (x *NewConversationRequest) GetSandbox() *Sandbox {
+	if x != nil {
+		return x.Sandbox
+	}
+	return nil
+}
+
+func (x *NewConversationRequest) GetAllowedTools() []string {
+	if x != nil {
+		return x.AllowedTools
+	}
+	return nil
+}
+
+func (x *NewConversationRequest) GetToolPreset() string {
+	if x != nil {
+		return x.ToolPreset
+	}
+	return ""
+}
+
+func (x *NewConversationRequest) GetRemote() *Remote {
+	if x != nil {
+		return x.Remote
+	}
+	return nil
+}
+
+func (x *NewConversationRequest) GetDevcontainer() bool {
+	if x != nil {
+		return x.Devcontainer
+	}
+	return false
+}
+
+func (x *NewConversationRequest) GetProjectId() string {
+	if x != nil {
+		return x.ProjectId
+	}
+	return ""
+}
+
+type NewConversationResponse struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	ConversationId string                 `protobuf:"bytes,1,opt,name=conversation_id,json=conversationId,proto3" json:"conversation_id,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *NewConversationResponse) Reset() {
+	*x = NewConversationResponse{}
+	mi := &file_shelley_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *NewConversationResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*NewConversationResponse) ProtoMessage() {}
+
+func (x *NewConversationResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_shelley_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use NewConversationResponse.ProtoReflect.Descriptor instead.
+func (*NewConversationResponse) Descriptor() ([]byte, []int) {
+	return file_shelley_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *NewConversationResponse) GetConversationId() string {
+	if x != nil {
+		return x.ConversationId
+	}
+	return ""
+}
+
+type SendMessageRequest struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	ConversationId string                 `protobuf:"bytes,1,opt,name=conversation_id,json=conversationId,proto3" json:"conversation_id,omitempty"`
+	Message        string                 `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	// Defaults to the conversation's model.
+	Model string `protobuf:"bytes,3,opt,name=model,proto3" json:"model,omitempty"`
+	// Holds the message until the agent's turn ends, if it's working,
+	// instead of handing it to the agent mid-turn.
+	Queue         bool `protobuf:"varint,4,opt,name=queue,proto3" json:"queue,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SendMessageRequest) Reset() {
+	*x = SendMessageRequest{}
+	mi := &file_shelley_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SendMessageRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SendMessageRequest) ProtoMessage() {}
+
+func (x *SendMessageRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_shelley_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SendMessageRequest.ProtoReflect.Descriptor instead.
+func (*SendMessageRequest) Descriptor() ([]byte, []int) {
+	return file_shelley_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *SendMessageRequest) GetConversationId() string {
+	if x != nil {
+		return x.ConversationId
+	}
+	return ""
+}
+
+func (x *SendMessageRequest) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *SendMessageRequest) GetModel() string {
+	if x != nil {
+		return x.Model
+	}
+	return ""
+}
+
+func (x *SendMessageRequest) GetQueue() bool {
+	if x != nil {
+		return x.Queue
+	}
+	return false
+}
+
+type SendMessageResponse struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	Queued bool                   `protobuf:"varint,1,opt,name=queued,proto3" json:"queued,omitempty"`
+	// The conversation's queued messages, if the message was queued.
+	Queue         *MessageQueue `protobuf:"bytes,2,opt,name=queue,proto3" json:"queue,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SendMessageResponse) Reset() {
+	*x = SendMessageResponse{}
+	mi := &file_shelley_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SendMessageResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SendMessageResponse) ProtoMessage() {}
+
+func (x *SendMessageResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_shelley_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SendMessageResponse.ProtoReflect.Descriptor instead.
+func (*SendMessageResponse) Descriptor() ([]byte, []int) {
+	return file_shelley_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *SendMessageResponse) GetQueued() bool {
+	if x != nil {
+		return x.Queued
+	}
+	return false
+}
+
+func (x *SendMessageResponse) GetQueue() *MessageQueue {
+	if x != nil {
+		return x.Queue
+	}
+	return nil
+}
+
+type CancelTurnRequest struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	ConversationId string                 `protobuf:"bytes,1,opt,name=conversation_id,json=conversationId,proto3" json:"conversation_id,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *CancelTurnRequest) Reset() {
+	*x = CancelTurnRequest{}
+	mi := &file_shelley_proto_msgTypes[18]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CancelTurnRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CancelTurnRequest) ProtoMessage() {}
+
+func (x *CancelTurnRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_shelley_proto_msgTypes[18]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CancelTurnRequest.ProtoReflect.Descriptor instead.
+func (*CancelTurnRequest) Descriptor() ([]byte, []int) {
+	return file_shelley_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *CancelTurnRequest) GetConversationId() string {
+	if x != nil {
+		return x.ConversationId
+	}
+	return ""
+}
+
+type CancelTurnResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// False if the conversation wasn't active.
+	Cancelled     bool `protobuf:"varint,1,opt,name=cancelled,proto3" json:"cancelled,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CancelTurnResponse) Reset() {
+	*x = CancelTurnResponse{}
+	mi := &file_shelley_proto_msgTypes[19]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CancelTurnResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CancelTurnResponse) ProtoMessage() {}
+
+func (x *CancelTurnResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_shelley_proto_msgTypes[19]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CancelTurnResponse.ProtoReflect.Descriptor instead.
+func (*CancelTurnResponse) Descriptor() ([]byte, []int) {
+	return file_shelley_proto_rawDescGZIP(), []int{19}
+}
+
+func (x *CancelTurnResponse) GetCancelled() bool {
+	if x != nil {
+		return x.Cancelled
+	}
+	return false
+}
+
+type AnswerQuestionRequest struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	ConversationId string                 `protobuf:"bytes,1,opt,name=conversation_id,json=conversationId,proto3" json:"conversation_id,omitempty"`
+	ToolUseId      string                 `protobuf:"bytes,2,opt,name=tool_use_id,json=toolUseId,proto3" json:"tool_use_id,omitempty"`
+	Answer         string                 `protobuf:"bytes,3,opt,name=answer,proto3" json:"answer,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *AnswerQuestionRequest) Reset() {
+	*x = AnswerQuestionRequest{}
+	mi := &file_shelley_proto_msgTypes[20]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AnswerQuestionRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AnswerQuestionRequest) ProtoMessage() {}
+
+func (x *AnswerQuestionRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_shelley_proto_msgTypes[20]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AnswerQuestionRequest.ProtoReflect.Descriptor instead.
+func (*AnswerQuestionRequest) Descriptor() ([]byte, []int) {
+	return file_shelley_proto_rawDescGZIP(), []int{20}
+}
+
+func (x *AnswerQuestionRequest) GetConversationId() string {
+	if x != nil {
+		return x.ConversationId
+	}
+	return ""
+}
+
+func (x *AnswerQuestionRequest) GetToolUseId() string {
+	if x != nil {
+		return x.ToolUseId
+	}
+	return ""
+}
+
+func (x *AnswerQuestionRequest) GetAnswer() string {
+	if x != nil {
+		return x.Answer
+	}
+	return ""
+}
+
+type AnswerQuestionResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AnswerQuestionResponse) Reset() {
+	*x = AnswerQuestionResponse{}
+	mi := &file_shelley_proto_msgTypes[21]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AnswerQuestionResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AnswerQuestionResponse) ProtoMessage() {}
+
+func (x *AnswerQuestionResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_shelley_proto_msgTypes[21]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AnswerQuestionResponse.ProtoReflect.Descriptor instead.
+func (*AnswerQuestionResponse) Descriptor() ([]byte, []int) {
+	return file_shelley_proto_rawDescGZIP(), []int{21}
+}
+
+type GetConversationToolsRequest struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	ConversationId string                 `protobuf:"bytes,1,opt,name=conversation_id,json=conversationId,proto3" json:"conversation_id,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *GetConversationToolsRequest) Reset() {
+	*x = GetConversationToolsRequest{}
+	mi := &file_shelley_proto_msgTypes[22]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetConversationToolsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetConversationToolsRequest) ProtoMessage() {}
+
+func (x *GetConversationToolsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_shelley_proto_msgTypes[22]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetConversationToolsRequest.ProtoReflect.Descriptor instead.
+func (*GetConversationToolsRequest) Descriptor() ([]byte, []int) {
+	return file_shelley_proto_rawDescGZIP(), []int{22}
+}
+
+func (x *GetConversationToolsRequest) GetConversationId() string {
+	if x != nil {
+		return x.ConversationId
+	}
+	return ""
+}
+
+type SetConversationToolsRequest struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	ConversationId string                 `protobuf:"bytes,1,opt,name=conversation_id,json=conversationId,proto3" json:"conversation_id,omitempty"`
+	// The tools to allow. Unless preset is set, an empty list allows none.
+	AllowedTools []string `protobuf:"bytes,2,rep,name=allowed_tools,json=allowedTools,proto3" json:"allowed_tools,omitempty"`
+	// Takes the place of allowed_tools: "all" lifts the restriction and
+	// "read_only" allows the tools that can't change the workspace.
+	Preset        string `protobuf:"bytes,3,opt,name=preset,proto3" json:"preset,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SetConversationToolsRequest) Reset() {
+	*x = SetConversationToolsRequest{}
+	mi := &file_shelley_proto_msgTypes[23]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SetConversationToolsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SetConversationToolsRequest) ProtoMessage() {}
+
+func (x *SetConversationToolsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_shelley_proto_msgTypes[23]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SetConversationToolsRequest.ProtoReflect.Descriptor instead.
+func (*SetConversationToolsRequest) Descriptor() ([]byte, []int) {
+	return file_shelley_proto_rawDescGZIP(), []int{23}
+}
+
+func (x *SetConversationToolsRequest) GetConversationId() string {
+	if x != nil {
+		return x.ConversationId
+	}
+	return ""
+}
+
+func (x *SetConversationToolsRequest) GetAllowedTools() []string {
+	if x != nil {
+		return x.AllowedTools
+	}
+	return nil
+}
+
+func (x *SetConversationToolsRequest) GetPreset() string {
+	if x != nil {
+		return x.Preset
+	}
+	return ""
+}
+
+type ConversationTools struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// False if every tool is allowed.
+	Restricted    bool     `protobuf:"varint,1,opt,name=restricted,proto3" json:"restricted,omitempty"`
+	AllowedTools  []string `protobuf:"bytes,2,rep,name=allowed_tools,json=allowedTools,proto3" json:"allowed_tools,omitempty"`
+	Available     []string `protobuf:"bytes,3,rep,name=available,proto3" json:"available,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ConversationTools) Reset() {
+	*x = ConversationTools{}
+	mi := &file_shelley_proto_msgTypes[24]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ConversationTools) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ConversationTools) ProtoMessage() {}
+
+func (x *ConversationTools) ProtoReflect() protoreflect.Message {
+	mi := &file_shelley_proto_msgTypes[24]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ConversationTools.ProtoReflect.Descriptor instead.
+func (*ConversationTools) Descriptor() ([]byte, []int) {
+	return file_shelley_proto_rawDescGZIP(), []int{24}
+}
+
+func (x *ConversationTools) GetRestricted() bool {
+	if x != nil {
+		return x.Restricted
+	}
+	return false
+}
+
+func (x *ConversationTools) GetAllowedTools() []string {
+	if x != nil {
+		return x.AllowedTools
+	}
+	return nil
+}
+
+func (x *ConversationTools) GetAvailable() []string {
+	if x != nil {
+		return x.Available
+	}
+	return nil
+}
+
+type StreamEventsRequest struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	ConversationId string                 `protobuf:"bytes,1,opt,name=conversation_id,json=conversationId,proto3" json:"conversation_id,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *StreamEventsRequest) Reset() {
+	*x = StreamEventsRequest{}
+	mi := &file_shelley_proto_msgTypes[25]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StreamEventsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StreamEventsRequest) ProtoMessage() {}
+
+func (x *StreamEventsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_shelley_proto_msgTypes[25]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StreamEventsRequest.ProtoReflect.Descriptor instead.
+func (*StreamEventsRequest) Descriptor() ([]byte, []int) {
+	return file_shelley_proto_rawDescGZIP(), []int{25}
+}
+
+func (x *StreamEventsRequest) GetConversationId() string {
+	if x != nil {
+		return x.ConversationId
+	}
+	return ""
+}
+
+var File_shelley_proto protoreflect.FileDescriptor
+
+const file_shelley_proto_rawDesc = "" +
+	"\n" +
+	"\rshelley.proto\x12\n" +
+	"shelley.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"\x8f\x04\n" +
+	"\fConversation\x12'\n" +
+	"\x0fconversation_id\x18\x01 \x01(\tR\x0econversationId\x12\x12\n" +
+	"\x04slug\x18\x02 \x01(\tR\x04slug\x12\x10\n" +
+	"\x03cwd\x18\x03 \x01(\tR\x03cwd\x12\x14\n" +
+	"\x05model\x18\x04 \x01(\tR\x05model\x124\n" +
+	"\x16parent_conversation_id\x18\x05 \x01(\tR\x14parentConversationId\x12\x1a\n" +
+	"\barchived\x18\x06 \x01(\bR\barchived\x12\x16\n" +
+	"\x06pinned\x18\a \x01(\bR\x06pinned\x12#\n" +
+	"\ragent_working\x18\b \x01(\bR\fagentWorking\x12\x1f\n" +
+	"\vagent_error\x18\t \x01(\bR\n" +
+	"agentError\x12.\n" +
+	"\x13context_window_size\x18\n" +
+	" \x01(\x03R\x11contextWindowSize\x129\n" +
+	"\n" +
+	"created_at\x18\v \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAt\x129\n" +
+	"\n" +
+	"updated_at\x18\f \x01(\v2\x1a.google.protobuf.TimestampR\tupdatedAt\x12\x1d\n" +
+	"\n" +
+	"project_id\x18\r \x01(\tR\tprojectId\x12%\n" +
+	"\x0eguidance_files\x18\x0e \x03(\tR\rguidanceFiles\"\xb0\x02\n" +
+	"\aContent\x12+\n" +
+	"\x04type\x18\x01 \x01(\x0e2\x17.shelley.v1.ContentTypeR\x04type\x12\x12\n" +
+	"\x04text\x18\x02 \x01(\tR\x04text\x12\x1a\n" +
+	"\bthinking\x18\x03 \x01(\tR\bthinking\x12\x0e\n" +
+	"\x02id\x18\x04 \x01(\tR\x02id\x12\x1b\n" +
+	"\ttool_name\x18\x05 \x01(\tR\btoolName\x12&\n" +
+	"\x0ftool_input_json\x18\x06 \x01(\tR\rtoolInputJson\x12\x1e\n" +
+	"\vtool_use_id\x18\a \x01(\tR\ttoolUseId\x12\x1d\n" +
+	"\n" +
+	"tool_error\x18\b \x01(\bR\ttoolError\x124\n" +
+	"\vtool_result\x18\t \x03(\v2\x13.shelley.v1.ContentR\n" +
+	"toolResult\"\xf6\x01\n" +
+	"\x05Usage\x12!\n" +
+	"\finput_tokens\x18\x01 \x01(\x04R\vinputTokens\x12=\n" +
+	"\x1bcache_creation_input_tokens\x18\x02 \x01(\x04R\x18cacheCreationInputTokens\x125\n" +
+	"\x17cache_read_input_tokens\x18\x03 \x01(\x04R\x14cacheReadInputTokens\x12#\n" +
+	"\routput_tokens\x18\x04 \x01(\x04R\foutputTokens\x12\x19\n" +
+	"\bcost_usd\x18\x05 \x01(\x01R\acostUsd\x12\x14\n" +
+	"\x05model\x18\x06 \x01(\tR\x05model\"\xb1\x03\n" +
+	"\aMessage\x12\x1d\n" +
+	"\n" +
+	"message_id\x18\x01 \x01(\tR\tmessageId\x12'\n" +
+	"\x0fconversation_id\x18\x02 \x01(\tR\x0econversationId\x12\x1f\n" +
+	"\vsequence_id\x18\x03 \x01(\x03R\n" +
+	"sequenceId\x12\x12\n" +
+	"\x04type\x18\x04 \x01(\tR\x04type\x12$\n" +
+	"\x04role\x18\x05 \x01(\x0e2\x10.shelley.v1.RoleR\x04role\x12-\n" +
+	"\acontent\x18\x06 \x03(\v2\x13.shelley.v1.ContentR\acontent\x12'\n" +
+	"\x05usage\x18\a \x01(\v2\x11.shelley.v1.UsageR\x05usage\x12\x1e\n" +
+	"\vend_of_turn\x18\b \x01(\bR\tendOfTurn\x129\n" +
+	"\n" +
+	"created_at\x18\t \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAt\x12$\n" +
+	"\x0euser_data_json\x18\n" +
+	" \x01(\tR\fuserDataJson\x12*\n" +
+	"\x11display_data_json\x18\v \x01(\tR\x0fdisplayDataJson\"^\n" +
+	"\n" +
+	"ToolOutput\x12\x1e\n" +
+	"\vtool_use_id\x18\x01 \x01(\tR\ttoolUseId\x12\x16\n" +
+	"\x06output\x18\x02 \x01(\tR\x06output\x12\x18\n" +
+	"\areplace\x18\x03 \x01(\bR\areplace\"9\n" +
+	"\rQueuedMessage\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x18\n" +
+	"\amessage\x18\x02 \x01(\tR\amessage\"\xb4\x02\n" +
+	"\x05Event\x12/\n" +
+	"\bmessages\x18\x01 \x03(\v2\x13.shelley.v1.MessageR\bmessages\x12<\n" +
+	"\fconversation\x18\x02 \x01(\v2\x18.shelley.v1.ConversationR\fconversation\x12#\n" +
+	"\ragent_working\x18\x03 \x01(\bR\fagentWorking\x12.\n" +
+	"\x13context_window_size\x18\x04 \x01(\x04R\x11contextWindowSize\x127\n" +
+	"\vtool_output\x18\x05 \x01(\v2\x16.shelley.v1.ToolOutputR\n" +
+	"toolOutput\x12.\n" +
+	"\x05queue\x18\x06 \x01(\v2\x18.shelley.v1.MessageQueueR\x05queue\"E\n" +
+	"\fMessageQueue\x125\n" +
+	"\bmessages\x18\x01 \x03(\v2\x19.shelley.v1.QueuedMessageR\bmessages\"o\n" +
+	"\aSandbox\x12\x18\n" +
+	"\aenabled\x18\x01 \x01(\bR\aenabled\x12#\n" +
+	"\rallow_network\x18\x02 \x01(\bR\fallowNetwork\x12%\n" +
+	"\x0ewritable_paths\x18\x03 \x03(\tR\rwritablePaths\"U\n" +
+	"\x06Remote\x12\x12\n" +
+	"\x04host\x18\x01 \x01(\tR\x04host\x12\x12\n" +
+	"\x04port\x18\x02 \x01(\x05R\x04port\x12#\n" +
+	"\ridentity_file\x18\x03 \x01(\tR\fidentityFile\"^\n" +
+	"\x18ListConversationsRequest\x12\x14\n" +
+	"\x05limit\x18\x01 \x01(\x05R\x05limit\x12\x16\n" +
+	"\x06offset\x18\x02 \x01(\x05R\x06offset\x12\x14\n" +
+	"\x05query\x18\x03 \x01(\tR\x05query\"[\n" +
+	"\x19ListConversationsResponse\x12>\n" +
+	"\rconversations\x18\x01 \x03(\v2\x18.shelley.v1.ConversationR\rconversations\"A\n" +
+	"\x16GetConversationRequest\x12'\n" +
+	"\x0fconversation_id\x18\x01 \x01(\tR\x0econversationId\"\xdd\x01\n" +
+	"\x17GetConversationResponse\x12<\n" +
+	"\fconversation\x18\x01 \x01(\v2\x18.shelley.v1.ConversationR\fconversation\x12/\n" +
+	"\bmessages\x18\x02 \x03(\v2\x13.shelley.v1.MessageR\bmessages\x12#\n" +
+	"\ragent_working\x18\x03 \x01(\bR\fagentWorking\x12.\n" +
+	"\x13context_window_size\x18\x04 \x01(\x04R\x11contextWindowSize\"\xbe\x02\n" +
+	"\x16NewConversationRequest\x12\x18\n" +
+	"\amessage\x18\x01 \x01(\tR\amessage\x12\x14\n" +
+	"\x05model\x18\x02 \x01(\tR\x05model\x12\x10\n" +
+	"\x03cwd\x18\x03 \x01(\tR\x03cwd\x12-\n" +
+	"\asandbox\x18\x04 \x01(\v2\x13.shelley.v1.SandboxR\asandbox\x12#\n" +
+	"\rallowed_tools\x18\x05 \x03(\tR\fallowedTools\x12\x1f\n" +
+	"\vtool_preset\x18\x06 \x01(\tR\n" +
+	"toolPreset\x12*\n" +
+	"\x06remote\x18\a \x01(\v2\x12.shelley.v1.RemoteR\x06remote\x12\"\n" +
+	"\fdevcontainer\x18\b \x01(\bR\fdevcontainer\x12\x1d\n" +
+	"\n" +
+	"project_id\x18\t \x01(\tR\tprojectId\"B\n" +
+	"\x17NewConversationResponse\x12'\n" +
+	"\x0fconversation_id\x18\x01 \x01(\tR\x0econversationId\"\x83\x01\n" +
+	"\x12SendMessageRequest\x12'\n" +
+	"\x0fconversation_id\x18\x01 \x01(\tR\x0econversationId\x12\x18\n" +
+	"\amessage\x18\x02 \x01(\tR\amessage\x12\x14\n" +
+	"\x05model\x18\x03 \x01(\tR\x05model\x12\x14\n" +
+	"\x05queue\x18\x04 \x01(\bR\x05queue\"]\n" +
+	"\x13SendMessageResponse\x12\x16\n" +
+	"\x06queued\x18\x01 \x01(\bR\x06queued\x12.\n" +
+	"\x05queue\x18\x02 \x01(\v2\x18.shelley.v1.MessageQueueR\x05queue\"<\n" +
+	"\x11CancelTurnRequest\x12'\n" +
+	"\x0fconversation_id\x18\x01 \x01(\tR\x0econversationId\"2\n" +
+	"\x12CancelTurnResponse\x12\x1c\n" +
+	"\tcancelled\x18\x01 \x01(\bR\tcancelled\"x\n" +
+	"\x15AnswerQuestionRequest\x12'\n" +
+	"\x0fconversation_id\x18\x01 \x01(\tR\x0econversationId\x12\x1e\n" +
+	"\vtool_use_id\x18\x02 \x01(\tR\ttoolUseId\x12\x16\n" +
+	"\x06answer\x18\x03 \x01(\tR\x06answer\"\x18\n" +
+	"\x16AnswerQuestionResponse\"F\n" +
+	"\x1bGetConversationToolsRequest\x12'\n" +
+	"\x0fconversation_id\x18\x01 \x01(\tR\x0econversationId\"\x83\x01\n" +
+	"\x1bSetConversationToolsRequest\x12'\n" +
+	"\x0fconversation_id\x18\x01 \x01(\tR\x0econversationId\x12#\n" +
+	"\rallowed_tools\x18\x02 \x03(\tR\fallowedTools\x12\x16\n" +
+	"\x06preset\x18\x03 \x01(\tR\x06preset\"v\n" +
+	"\x11ConversationTools\x12\x1e\n" +
+	"\n" +
+	"restricted\x18\x01 \x01(\bR\n" +
+	"restricted\x12#\n" +
+	"\rallowed_tools\x18\x02 \x03(\tR\fallowedTools\x12\x1c\n" +
+	"\tavailable\x18\x03 \x03(\tR\tavailable\">\n" +
+	"\x13StreamEventsRequest\x12'\n" +
+	"\x0fconversation_id\x18\x01 \x01(\tR\x0econversationId*?\n" +
+	"\x04Role\x12\x14\n" +
+	"\x10ROLE_UNSPECIFIED\x10\x00\x12\r\n" +
+	"\tROLE_USER\x10\x01\x12\x12\n" +
+	"\x0eROLE_ASSISTANT\x10\x02*\xba\x01\n" +
+	"\vContentType\x12\x1c\n" +
+	"\x18CONTENT_TYPE_UNSPECIFIED\x10\x00\x12\x15\n" +
+	"\x11CONTENT_TYPE_TEXT\x10\x01\x12\x19\n" +
+	"\x15CONTENT_TYPE_THINKING\x10\x02\x12\"\n" +
+	"\x1eCONTENT_TYPE_REDACTED_THINKING\x10\x03\x12\x19\n" +
+	"\x15CONTENT_TYPE_TOOL_USE\x10\x04\x12\x1c\n" +
+	"\x18CONTENT_TYPE_TOOL_RESULT\x10\x052\x9f\x06\n" +
+	"\aShelley\x12`\n" +
+	"\x11ListConversations\x12$.shelley.v1.ListConversationsRequest\x1a%.shelley.v1.ListConversationsResponse\x12Z\n" +
+	"\x0fGetConversation\x12\".shelley.v1.GetConversationRequest\x1a#.shelley.v1.GetConversationResponse\x12Z\n" +
+	"\x0fNewConversation\x12\".shelley.v1.NewConversationRequest\x1a#.shelley.v1.NewConversationResponse\x12N\n" +
+	"\vSendMessage\x12\x1e.shelley.v1.SendMessageRequest\x1a\x1f.shelley.v1.SendMessageResponse\x12K\n" +
+	"\n" +
+	"CancelTurn\x12\x1d.shelley.v1.CancelTurnRequest\x1a\x1e.shelley.v1.CancelTurnResponse\x12W\n" +
+	"\x0eAnswerQuestion\x12!.shelley.v1.AnswerQuestionRequest\x1a\".shelley.v1.AnswerQuestionResponse\x12^\n" +
+	"\x14GetConversationTools\x12'.shelley.v1.GetConversationToolsRequest\x1a\x1d.shelley.v1.ConversationTools\x12^\n" +
+	"\x14SetConversationTools\x12'.shelley.v1.SetConversationToolsRequest\x1a\x1d.shelley.v1.ConversationTools\x12D\n" +
+	"\fStreamEvents\x12\x1f.shelley.v1.StreamEventsRequest\x1a\x11.shelley.v1.Event0\x01B\x19Z\x17shelley.exe.dev/grpcapib\x06proto3"
+
+var (
+	file_shelley_proto_rawDescOnce sync.Once
+	file_shelley_proto_rawDescData []byte
+)
+
+func file_shelley_proto_rawDescGZIP() []byte {
+	file_shelley_proto_rawDescOnce.Do(func() {
+		file_shelley_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_shelley_proto_rawDesc), len(file_shelley_proto_rawDesc)))
+	})
+	return file_shelley_proto_rawDescData
+}
+
+var file_shelley_proto_enumTypes = make([]protoimpl.EnumInfo, 2)
+var file_shelley_proto_msgTypes = make([]protoimpl.MessageInfo, 26)
+var file_shelley_proto_goTypes = []any{
+	(Role)(0),                           // 0: shelley.v1.Role
+	(ContentType)(0),                    // 1: shelley.v1.ContentType
+	(*Conversation)(nil),                // 2: shelley.v1.Conversation
+	(*Content)(nil),                     // 3: shelley.v1.Content
+	(*Usage)(nil),                       // 4: shelley.v1.Usage
+	(*Message)(nil),                     // 5: shelley.v1.Message
+	(*ToolOutput)(nil),                  // 6: shelley.v1.ToolOutput
+	(*QueuedMessage)(nil),               // 7: shelley.v1.QueuedMessage
+	(*Event)(nil),                       // 8: shelley.v1.Event
+	(*MessageQueue)(nil),                // 9: shelley.v1.MessageQueue
+	(*Sandbox)(nil),                     // 10: shelley.v1.Sandbox
+	(*Remote)(nil),                      // 11: shelley.v1.Remote
+	(*ListConversationsRequest)(nil),    // 12: shelley.v1.ListConversationsRequest
+	(*ListConversationsResponse)(nil),   // 13: shelley.v1.ListConversationsResponse
+	(*GetConversationRequest)(nil),      // 14: shelley.v1.GetConversationRequest
+	(*GetConversationResponse)(nil),     // 15: shelley.v1.GetConversationResponse
+	(*NewConversationRequest)(nil),      // 16: shelley.v1.NewConversationRequest
+	(*NewConversationResponse)(nil),     // 17: shelley.v1.NewConversationResponse
+	(*SendMessageRequest)(nil),          // 18: shelley.v1.SendMessageRequest
+	(*SendMessageResponse)(nil),         // 19: shelley.v1.SendMessageResponse
+	(*CancelTurnRequest)(nil),           // 20: shelley.v1.CancelTurnRequest
+	(*CancelTurnResponse)(nil),          // 21: shelley.v1.CancelTurnResponse
+	(*AnswerQuestionRequest)(nil),       // 22: shelley.v1.AnswerQuestionRequest
+	(*AnswerQuestionResponse)(nil),      // 23: shelley.v1.AnswerQuestionResponse
+	(*GetConversationToolsRequest)(nil), // 24: shelley.v1.GetConversationToolsRequest
+	(*SetConversationToolsRequest)(nil), // 25: shelley.v1.SetConversationToolsRequest
+	(*ConversationTools)(nil),           // 26: shelley.v1.ConversationTools
+	(*StreamEventsRequest)(nil),         // 27: shelley.v1.StreamEventsRequest
+	(*timestamppb.Timestamp)(nil),       // 28: google.protobuf.Timestamp
+}
+var file_shelley_proto_depIdxs = []int32{
+	28, // 0: shelley.v1.Conversation.created_at:type_name -> google.protobuf.Timestamp
+	28, // 1: shelley.v1.Conversation.updated_at:type_name -> google.protobuf.Timestamp
+	1,  // 2: shelley.v1.Content.type:type_name -> shelley.v1.ContentType
+	3,  // 3: shelley.v1.Content.tool_result:type_name -> shelley.v1.Content
+	0,  // 4: shelley.v1.Message.role:type_name -> shelley.v1.Role
+	3,  // 5: shelley.v1.Message.content:type_name -> shelley.v1.Content
+	4,  // 6: shelley.v1.Message.usage:type_name -> shelley.v1.Usage
+	28, // 7: shelley.v1.Message.created_at:type_name -> google.protobuf.Timestamp
+	5,  // 8: shelley.v1.Event.messages:type_name -> shelley.v1.Message
+	2,  // 9: shelley.v1.Event.conversation:type_name -> shelley.v1.Conversation
+	6,  // 10: shelley.v1.Event.tool_output:type_name -> shelley.v1.ToolOutput
+	9,  // 11: shelley.v1.Event.queue:type_name -> shelley.v1.MessageQueue
+	7,  // 12: shelley.v1.MessageQueue.messages:type_name -> shelley.v1.QueuedMessage
+	2,  // 13: shelley.v1.ListConversationsResponse.conversations:type_name -> shelley.v1.Conversation
+	2,  // 14: shelley.v1.GetConversationResponse.conversation:type_name -> shelley.v1.Conversation
+	5,  // 15: shelley.v1.GetConversationResponse.messages:type_name -> shelley.v1.Message
+	10, // 16: shelley.v1.NewConversationRequest.sandbox:type_name -> shelley.v1.Sandbox
+	11, // 17: shelley.v1.NewConversationRequest.remote:type_name -> shelley.v1.Remote
+	9,  // 18: shelley.v1.SendMessageResponse.queue:type_name -> shelley.v1.MessageQueue
+	12, // 19: shelley.v1.Shelley.ListConversations:input_type -> shelley.v1.ListConversationsRequest
+	14, // 20: shelley.v1.Shelley.GetConversation:input_type -> shelley.v1.GetConversationRequest
+	16, // 21: shelley.v1.Shelley.NewConversation:input_type -> shelley.v1.NewConversationRequest
+	18, // 22: shelley.v1.Shelley.SendMessage:input_type -> shelley.v1.SendMessageRequest
+	20, // 23: shelley.v1.Shelley.CancelTurn:input_type -> shelley.v1.CancelTurnRequest
+	22, // 24: shelley.v1.Shelley.AnswerQuestion:input_type -> shelley.v1.AnswerQuestionRequest
+	24, // 25: shelley.v1.Shelley.GetConversationTools:input_type -> shelley.v1.GetConversationToolsRequest
+	25, // 26: shelley.v1.Shelley.SetConversationTools:input_type -> shelley.v1.SetConversationToolsRequest
+	27, // 27: shelley.v1.Shelley.StreamEvents:input_type -> shelley.v1.StreamEventsRequest
+	13, // 28: shelley.v1.Shelley.ListConversations:output_type -> shelley.v1.ListConversationsResponse
+	15, // 29: shelley.v1.Shelley.GetConversation:output_type -> shelley.v1.GetConversationResponse
+	17, // 30: shelley.v1.Shelley.NewConversation:output_type -> shelley.v1.NewConversationResponse
+	19, // 31: shelley.v1.Shelley.SendMessage:output_type -> shelley.v1.SendMessageResponse
+	21, // 32: shelley.v1.Shelley.CancelTurn:output_type -> shelley.v1.CancelTurnResponse
+	23, // 33: shelley.v1.Shelley.AnswerQuestion:output_type -> shelley.v1.AnswerQuestionResponse
+	26, // 34: shelley.v1.Shelley.GetConversationTools:output_type -> shelley.v1.ConversationTools
+	26, // 35: shelley.v1.Shelley.SetConversationTools:output_type -> shelley.v1.ConversationTools
+	8,  // 36: shelley.v1.Shelley.StreamEvents:output_type -> shelley.v1.Event
+	28, // [28:37] is the sub-list for method output_type
+	19, // [19:28] is the sub-list for method input_type
+	19, // [19:19] is the sub-list for extension type_name
+	19, // [19:19] is the sub-list for extension extendee
+	0,  // [0:19] is the sub-list for field type_name
+}
+
+func init() { file_shelley_proto_init() }
+func file_shelley_proto_init() {
+	if File_shelley_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_shelley_proto_rawDesc), len(file_shelley_proto_rawDesc)),
+			NumEnums:      2,
+			NumMessages:   26,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_shelley_proto_goTypes,
+		DependencyIndexes: file_shelley_proto_depIdxs,
+		EnumInfos:         file_shelley_proto_enumTypes,
+		MessageInfos:      file_shelley_proto_msgTypes,
+	}.Build()
+	File_shelley_proto = out.File
+	file_shelley_proto_goTypes = nil
+	file_shelley_proto_depIdxs = nil
+}
@@ -0,0 +1,466 @@
+// The gRPC API of the Shelley server. It mirrors the REST API under /api/
+// for services that prefer gRPC: conversations, their messages and tools,
+// and a server stream of each conversation's updates in place of the SSE
+// stream.
+//
+// Regenerate shelley.pb.go and shelley_grpc.pb.go after changing this file:
+//
+//	go generate ./grpcapi
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             v5.29.0
+// source: shelley.proto
+
+package grpcapi
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Shelley_ListConversations_FullMethodName    = "/shelley.v1.Shelley/ListConversations"
+	Shelley_GetConversation_FullMethodName      = "/shelley.v1.Shelley/GetConversation"
+	Shelley_NewConversation_FullMethodName      = "/shelley.v1.Shelley/NewConversation"
+	Shelley_SendMessage_FullMethodName          = "/shelley.v1.Shelley/SendMessage"
+	Shelley_CancelTurn_FullMethodName           = "/shelley.v1.Shelley/CancelTurn"
+	Shelley_AnswerQuestion_FullMethodName       = "/shelley.v1.Shelley/AnswerQuestion"
+	Shelley_GetConversationTools_FullMethodName = "/shelley.v1.Shelley/GetConversationTools"
+	Shelley_SetConversationTools_FullMethodName = "/shelley.v1.Shelley/SetConversationTools"
+	Shelley_StreamEvents_FullMethodName         = "/shelley.v1.Shelley/StreamEvents"
+)
+
+// ShelleyClient is the client API for Shelley service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type ShelleyClient interface {
+	// ListConversations lists conversations, most recently updated first.
+	ListConversations(ctx context.Context, in *ListConversationsRequest, opts ...grpc.CallOption) (*ListConversationsResponse, error)
+	// GetConversation returns a conversation and all of its messages.
+	GetConversation(ctx context.Context, in *GetConversationRequest, opts ...grpc.CallOption) (*GetConversationResponse, error)
+	// NewConversation starts a conversation with its first message.
+	NewConversation(ctx context.Context, in *NewConversationRequest, opts ...grpc.CallOption) (*NewConversationResponse, error)
+	// SendMessage sends a message to a conversation's agent.
+	SendMessage(ctx context.Context, in *SendMessageRequest, opts ...grpc.CallOption) (*SendMessageResponse, error)
+	// CancelTurn stops the agent's turn and drops queued messages.
+	CancelTurn(ctx context.Context, in *CancelTurnRequest, opts ...grpc.CallOption) (*CancelTurnResponse, error)
+	// AnswerQuestion answers a question the agent asked with the ask_user
+	// tool.
+	AnswerQuestion(ctx context.Context, in *AnswerQuestionRequest, opts ...grpc.CallOption) (*AnswerQuestionResponse, error)
+	// GetConversationTools returns the tools a conversation may use.
+	GetConversationTools(ctx context.Context, in *GetConversationToolsRequest, opts ...grpc.CallOption) (*ConversationTools, error)
+	// SetConversationTools changes the tools a conversation may use. A
+	// working agent is offered the new set from its next request.
+	SetConversationTools(ctx context.Context, in *SetConversationToolsRequest, opts ...grpc.CallOption) (*ConversationTools, error)
+	// StreamEvents streams a conversation's updates. The first event holds
+	// the conversation and all of its messages; later ones hold new messages
+	// and changes to the conversation, output of running tools, and the
+	// queued messages.
+	StreamEvents(ctx context.Context, in *StreamEventsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Event], error)
+}
+
+type shelleyClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewShelleyClient(cc grpc.ClientConnInterface) ShelleyClient {
+	return &shelleyClient{cc}
+}
+
+func (c *shelleyClient) ListConversations(ctx context.Context, in *ListConversationsRequest, opts ...grpc.CallOption) (*ListConversationsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListConversationsResponse)
+	err := c.cc.Invoke(ctx, Shelley_ListConversations_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *shelleyClient) GetConversation(ctx context.Context, in *GetConversationRequest, opts ...grpc.CallOption) (*GetConversationResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetConversationResponse)
+	err := c.cc.Invoke(ctx, Shelley_GetConversation_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *shelleyClient) NewConversation(ctx context.Context, in *NewConversationRequest, opts ...grpc.CallOption) (*NewConversationResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(NewConversationResponse)
+	err := c.cc.Invoke(ctx, Shelley_NewConversation_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *shelleyClient) SendMessage(ctx context.Context, in *SendMessageRequest, opts ...grpc.CallOption) (*SendMessageResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SendMessageResponse)
+	err := c.cc.Invoke(ctx, Shelley_SendMessage_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *shelleyClient) CancelTurn(ctx context.Context, in *CancelTurnRequest, opts ...grpc.CallOption) (*CancelTurnResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CancelTurnResponse)
+	err := c.cc.Invoke(ctx, Shelley_CancelTurn_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *shelleyClient) AnswerQuestion(ctx context.Context, in *AnswerQuestionRequest, opts ...grpc.CallOption) (*AnswerQuestionResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(AnswerQuestionResponse)
+	err := c.cc.Invoke(ctx, Shelley_AnswerQuestion_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *shelleyClient) GetConversationTools(ctx context.Context, in *GetConversationToolsRequest, opts ...grpc.CallOption) (*ConversationTools, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ConversationTools)
+	err := c.cc.Invoke(ctx, Shelley_GetConversationTools_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *shelleyClient) SetConversationTools(ctx context.Context, in *SetConversationToolsRequest, opts ...grpc.CallOption) (*ConversationTools, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ConversationTools)
+	err := c.cc.Invoke(ctx, Shelley_SetConversationTools_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *shelleyClient) StreamEvents(ctx context.Context, in *StreamEventsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Event], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Shelley_ServiceDesc.Streams[0], Shelley_StreamEvents_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[StreamEventsRequest, Event]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Shelley_StreamEventsClient = grpc.ServerStreamingClient[Event]
+
+// ShelleyServer is the server API for Shelley service.
+// All implementations must embed UnimplementedShelleyServer
+// for forward compatibility.
+type ShelleyServer interface {
+	// ListConversations lists conversations, most recently updated first.
+	ListConversations(context.Context, *ListConversationsRequest) (*ListConversationsResponse, error)
+	// GetConversation returns a conversation and all of its messages.
+	GetConversation(context.Context, *GetConversationRequest) (*GetConversationResponse, error)
+	// NewConversation starts a conversation with its first message.
+	NewConversation(context.Context, *NewConversationRequest) (*NewConversationResponse, error)
+	// SendMessage sends a message to a conversation's agent.
+	SendMessage(context.Context, *SendMessageRequest) (*SendMessageResponse, error)
+	// CancelTurn stops the agent's turn and drops queued messages.
+	CancelTurn(context.Context, *CancelTurnRequest) (*CancelTurnResponse, error)
+	// AnswerQuestion answers a question the agent asked with the ask_user
+	// tool.
+	AnswerQuestion(context.Context, *AnswerQuestionRequest) (*AnswerQuestionResponse, error)
+	// GetConversationTools returns the tools a conversation may use.
+	GetConversationTools(context.Context, *GetConversationToolsRequest) (*ConversationTools, error)
+	// SetConversationTools changes the tools a conversation may use. A
+	// working agent is offered the new set from its next request.
+	SetConversationTools(context.Context, *SetConversationToolsRequest) (*ConversationTools, error)
+	// StreamEvents streams a conversation's updates. The first event holds
+	// the conversation and all of its messages; later ones hold new messages
+	// and changes to the conversation, output of running tools, and the
+	// queued messages.
+	StreamEvents(*StreamEventsRequest, grpc.ServerStreamingServer[Event]) error
+	mustEmbedUnimplementedShelleyServer()
+}
+
+// UnimplementedShelleyServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedShelleyServer struct{}
+
+func (UnimplementedShelleyServer) ListConversations(context.Context, *ListConversationsRequest) (*ListConversationsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListConversations not implemented")
+}
+func (UnimplementedShelleyServer) GetConversation(context.Context, *GetConversationRequest) (*GetConversationResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetConversation not implemented")
+}
+func (UnimplementedShelleyServer) NewConversation(context.Context, *NewConversationRequest) (*NewConversationResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method NewConversation not implemented")
+}
+func (UnimplementedShelleyServer) SendMessage(context.Context, *SendMessageRequest) (*SendMessageResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SendMessage not implemented")
+}
+func (UnimplementedShelleyServer) CancelTurn(context.Context, *CancelTurnRequest) (*CancelTurnResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CancelTurn not implemented")
+}
+func (UnimplementedShelleyServer) AnswerQuestion(context.Context, *AnswerQuestionRequest) (*AnswerQuestionResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AnswerQuestion not implemented")
+}
+func (UnimplementedShelleyServer) GetConversationTools(context.Context, *GetConversationToolsRequest) (*ConversationTools, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetConversationTools not implemented")
+}
+func (UnimplementedShelleyServer) SetConversationTools(context.Context, *SetConversationToolsRequest) (*ConversationTools, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetConversationTools not implemented")
+}
+func (UnimplementedShelleyServer) StreamEvents(*StreamEventsRequest, grpc.ServerStreamingServer[Event]) error {
+	return status.Errorf(codes.Unimplemented, "method StreamEvents not implemented")
+}
+func (UnimplementedShelleyServer) mustEmbedUnimplementedShelleyServer() {}
+func (UnimplementedShelleyServer) testEmbeddedByValue()                 {}
+
+// UnsafeShelleyServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ShelleyServer will
+// result in compilation errors.
+type UnsafeShelleyServer interface {
+	mustEmbedUnimplementedShelleyServer()
+}
+
+func RegisterShelleyServer(s grpc.ServiceRegistrar, srv ShelleyServer) {
+	// If the following call pancis, it indicates UnimplementedShelleyServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Shelley_ServiceDesc, srv)
+}
+
+func _Shelley_ListConversations_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListConversationsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShelleyServer).ListConversations(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Shelley_ListConversations_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShelleyServer).ListConversations(ctx, req.(*ListConversationsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Shelley_GetConversation_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetConversationRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShelleyServer).GetConversation(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Shelley_GetConversation_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShelleyServer).GetConversation(ctx, req.(*GetConversationRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Shelley_NewConversation_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(NewConversationRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShelleyServer).NewConversation(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Shelley_NewConversation_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShelleyServer).NewConversation(ctx, req.(*NewConversationRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Shelley_SendMessage_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SendMessageRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShelleyServer).SendMessage(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Shelley_SendMessage_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShelleyServer).SendMessage(ctx, req.(*SendMessageRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Shelley_CancelTurn_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CancelTurnRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShelleyServer).CancelTurn(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Shelley_CancelTurn_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShelleyServer).CancelTurn(ctx, req.(*CancelTurnRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Shelley_AnswerQuestion_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AnswerQuestionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShelleyServer).AnswerQuestion(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Shelley_AnswerQuestion_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShelleyServer).AnswerQuestion(ctx, req.(*AnswerQuestionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Shelley_GetConversationTools_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetConversationToolsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShelleyServer).GetConversationTools(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Shelley_GetConversationTools_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShelleyServer).GetConversationTools(ctx, req.(*GetConversationToolsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Shelley_SetConversationTools_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetConversationToolsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShelleyServer).SetConversationTools(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Shelley_SetConversationTools_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShelleyServer).SetConversationTools(ctx, req.(*SetConversationToolsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Shelley_StreamEvents_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamEventsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ShelleyServer).StreamEvents(m, &grpc.GenericServerStream[StreamEventsRequest, Event]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Shelley_StreamEventsServer = grpc.ServerStreamingServer[Event]
+
+// Shelley_ServiceDesc is the grpc.ServiceDesc for Shelley service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Shelley_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "shelley.v1.Shelley",
+	HandlerType: (*ShelleyServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListConversations",
+			Handler:    _Shelley_ListConversations_Handler,
+		},
+		{
+			MethodName: "GetConversation",
+			Handler:    _Shelley_GetConversation_Handler,
+		},
+		{
+			MethodName: "NewConversation",
+			Handler:    _Shelley_NewConversation_Handler,
+		},
+		{
+			MethodName: "SendMessage",
+			Handler:    _Shelley_SendMessage_Handler,
+		},
+		{
+			MethodName: "CancelTurn",
+			Handler:    _Shelley_CancelTurn_Handler,
+		},
+		{
+			MethodName: "AnswerQuestion",
+			Handler:    _Shelley_AnswerQuestion_Handler,
+		},
+		{
+			MethodName: "GetConversationTools",
+			Handler:    _Shelley_GetConversationTools_Handler,
+		},
+		{
+			MethodName: "SetConversationTools",
+			Handler:    _Shelley_SetConversationTools_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamEvents",
+			Handler:       _Shelley_StreamEvents_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "shelley.proto",
+}
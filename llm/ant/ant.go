@@ -11,6 +11,7 @@ import (
 	"log/slog"
 	"math/rand/v2"
 	"net/http"
+	"slices"
 	"strings"
 	"testing"
 	"time"
@@ -74,6 +75,20 @@ func (s *Service) TokenContextWindow() int {
 	}
 }
 
+// Capabilities reports the model's capabilities and list price.
+func (s *Service) Capabilities() llm.Capabilities {
+	caps := llm.Capabilities{Vision: true, Tools: true, Thinking: true}
+	switch cmp.Or(s.Model, DefaultModel) {
+	case Claude37Sonnet, Claude4Sonnet, Claude45Sonnet:
+		caps.Pricing = &llm.Pricing{Input: 3, Output: 15, CacheRead: 0.3, CacheWrite: 3.75}
+	case Claude45Haiku:
+		caps.Pricing = &llm.Pricing{Input: 1, Output: 5, CacheRead: 0.1, CacheWrite: 1.25}
+	case Claude45Opus:
+		caps.Pricing = &llm.Pricing{Input: 5, Output: 25, CacheRead: 0.5, CacheWrite: 6.25}
+	}
+	return caps
+}
+
 // MaxImageDimension returns the maximum allowed image dimension for multi-image requests.
 // Anthropic enforces a 2000 pixel limit when multiple images are in a conversation.
 func (s *Service) MaxImageDimension() int {
@@ -113,7 +128,7 @@ type content struct {
 	// is somewhat acceptable but hard to read.
 	Text      *string         `json:"text,omitempty"`
 	MediaType string          `json:"media_type,omitempty"` // for image
-	Source    json.RawMessage `json:"source,omitempty"`     // for image
+	Source    json.RawMessage `json:"source,omitempty"`     // for image or document
 
 	// for thinking
 	Thinking  string `json:"thinking,omitempty"`
@@ -231,10 +246,17 @@ type request struct {
 	Tools         []*tool         `json:"tools,omitempty"`
 	Stream        bool            `json:"stream,omitempty"`
 	System        []systemContent `json:"system,omitempty"`
-	Temperature   float64         `json:"temperature,omitempty"`
+	Temperature   *float64        `json:"temperature,omitempty"`
 	TopK          int             `json:"top_k,omitempty"`
-	TopP          float64         `json:"top_p,omitempty"`
+	TopP          *float64        `json:"top_p,omitempty"`
 	StopSequences []string        `json:"stop_sequences,omitempty"`
+	Thinking      *thinking       `json:"thinking,omitempty"`
+}
+
+// https://docs.anthropic.com/en/docs/build-with-claude/extended-thinking
+type thinking struct {
+	Type         string `json:"type"` // "enabled"
+	BudgetTokens int    `json:"budget_tokens"`
 }
 
 func mapped[Slice ~[]E, E, T any](s Slice, f func(E) T) []T {
@@ -322,9 +344,12 @@ func fromLLMContent(c llm.Content) content {
 	// Set fields based on content type to avoid sending invalid fields
 	switch c.Type {
 	case llm.ContentTypeText:
-		// Images are represented as text with MediaType and Data
+		// Images and documents are represented as text with MediaType and Data
 		if c.MediaType != "" {
 			d.Type = "image"
+			if c.IsDocument() {
+				d.Type = "document"
+			}
 			d.Source = json.RawMessage(fmt.Sprintf(`{"type":"base64","media_type":"%s","data":"%s"}`,
 				c.MediaType, c.Data))
 		} else {
@@ -367,6 +392,17 @@ func fromLLMMessage(msg llm.Message) message {
 	}
 }
 
+// withoutForeignThinking returns msg without thinking that Anthropic
+// didn't sign, such as another provider's reasoning after a model switch.
+// Anthropic rejects it.
+func withoutForeignThinking(msg llm.Message) llm.Message {
+	msg.Content = slices.DeleteFunc(slices.Clone(msg.Content), func(c llm.Content) bool {
+		return c.Type == llm.ContentTypeThinking && c.Signature == "" ||
+			c.Type == llm.ContentTypeRedactedThinking && c.Data == ""
+	})
+	return msg
+}
+
 func fromLLMToolChoice(tc *llm.ToolChoice) *toolChoice {
 	if tc == nil {
 		return nil
@@ -396,14 +432,78 @@ func fromLLMSystem(s llm.SystemContent) systemContent {
 }
 
 func (s *Service) fromLLMRequest(r *llm.Request) *request {
-	return &request{
+	messages := mapped(r.Messages, withoutForeignThinking)
+	req := &request{
 		Model:      cmp.Or(s.Model, DefaultModel),
-		Messages:   mapped(r.Messages, fromLLMMessage),
+		Messages:   mapped(messages, fromLLMMessage),
 		MaxTokens:  cmp.Or(s.MaxTokens, DefaultMaxTokens),
 		ToolChoice: fromLLMToolChoice(r.ToolChoice),
 		Tools:      mapped(r.Tools, fromLLMTool),
 		System:     mapped(r.System, fromLLMSystem),
 	}
+	if sampling := r.Sampling; sampling != nil {
+		// Anthropic's temperatures go up to 1, and recent models take
+		// either a temperature or top_p, not both
+		if sampling.Temperature != nil {
+			temperature := min(*sampling.Temperature, 1)
+			req.Temperature = &temperature
+		} else {
+			req.TopP = sampling.TopP
+		}
+		req.MaxTokens = cmp.Or(sampling.MaxTokens, req.MaxTokens)
+		req.StopSequences = sampling.StopSequences
+	}
+	if rf := r.ResponseFormat; rf != nil {
+		// Anthropic has no JSON mode, so the model is made to call a tool
+		// whose input is the response
+		req.Tools = append(req.Tools, &tool{
+			Name:        rf.Name,
+			Description: "Respond by calling this tool with your response.",
+			InputSchema: rf.Schema,
+		})
+		req.ToolChoice = &toolChoice{Type: fromLLMToolChoiceType[llm.ToolChoiceTypeTool], Name: rf.Name}
+	}
+	if r.Thinking != nil && r.ResponseFormat == nil && thinkingAllowed(r.ToolChoice, messages) {
+		budget := r.Thinking.Budget()
+		req.Thinking = &thinking{Type: "enabled", BudgetTokens: budget}
+		// The budget counts against max_tokens, which must exceed it
+		if req.MaxTokens <= budget {
+			req.MaxTokens = budget + cmp.Or(s.MaxTokens, DefaultMaxTokens)
+		}
+		// Thinking works with neither a temperature nor a low top_p
+		req.Temperature, req.TopP = nil, nil
+	}
+	return req
+}
+
+// thinkingAllowed reports whether Anthropic accepts thinking with a
+// request. It doesn't with forced tool use, nor in the middle of a turn
+// whose tool call was made without thinking.
+func thinkingAllowed(tc *llm.ToolChoice, messages []llm.Message) bool {
+	if tc != nil && (tc.Type == llm.ToolChoiceTypeAny || tc.Type == llm.ToolChoiceTypeTool) {
+		return false
+	}
+	// Walk back past tool results to the message that asked for them
+	for i := len(messages) - 1; i >= 0; i-- {
+		msg := messages[i]
+		switch {
+		case msg.Role == llm.MessageRoleAssistant:
+			return !hasContent(msg, llm.ContentTypeToolUse) ||
+				hasContent(msg, llm.ContentTypeThinking) || hasContent(msg, llm.ContentTypeRedactedThinking)
+		case !hasContent(msg, llm.ContentTypeToolResult):
+			return true // the turn starts here
+		}
+	}
+	return true
+}
+
+func hasContent(msg llm.Message, t llm.ContentType) bool {
+	for _, c := range msg.Content {
+		if c.Type == t {
+			return true
+		}
+	}
+	return false
 }
 
 func toLLMUsage(u usage) llm.Usage {
@@ -458,10 +558,23 @@ func toLLMResponse(r *response) *llm.Response {
 	}
 }
 
+// formattedResponse turns the call to the response format's tool into
+// the text content of the response, as if it had been written out.
+func formattedResponse(r *llm.Response, name string) {
+	for _, c := range r.Content {
+		if c.Type == llm.ContentTypeToolUse && c.ToolName == name {
+			r.Content = []llm.Content{{Type: llm.ContentTypeText, Text: string(c.ToolInput)}}
+			r.StopReason = llm.StopReasonEndTurn
+			return
+		}
+	}
+}
+
 // Do sends a request to Anthropic.
 func (s *Service) Do(ctx context.Context, ir *llm.Request) (*llm.Response, error) {
 	startTime := time.Now()
 	request := s.fromLLMRequest(ir)
+	request.Stream = llm.StreamsText(ctx)
 	var payload []byte
 	var err error
 	if s.DumpLLM || testing.Testing() {
@@ -491,6 +604,9 @@ func (s *Service) Do(ctx context.Context, ir *llm.Request) (*llm.Response, error
 		if s.HTTPRecorder != nil {
 			s.HTTPRecorder(url, payload, lastResponseBody, lastStatusCode, finalErr, time.Since(startTime))
 		}
+		if lastStatusCode != 0 {
+			llm.RecordHTTP(ctx, llm.HTTPExchange{URL: url, Request: payload, Response: lastResponseBody, StatusCode: lastStatusCode})
+		}
 	}()
 
 	// retry loop
@@ -527,9 +643,17 @@ func (s *Service) Do(ctx context.Context, ir *llm.Request) (*llm.Response, error
 			errs = errors.Join(errs, err)
 			continue
 		}
-		buf, err := io.ReadAll(resp.Body)
+		var streamed *response
+		var buf []byte
+		if request.Stream && resp.StatusCode == http.StatusOK {
+			streamed, buf, err = readStream(ctx, resp.Body)
+		} else {
+			buf, err = io.ReadAll(resp.Body)
+		}
 		resp.Body.Close()
 		if err != nil {
+			lastResponseBody = buf
+			lastStatusCode = resp.StatusCode
 			errs = errors.Join(errs, err)
 			continue
 		}
@@ -546,8 +670,9 @@ func (s *Service) Do(ctx context.Context, ir *llm.Request) (*llm.Response, error
 				}
 			}
 			var response response
-			err = json.NewDecoder(bytes.NewReader(buf)).Decode(&response)
-			if err != nil {
+			if streamed != nil {
+				response = *streamed
+			} else if err := json.NewDecoder(bytes.NewReader(buf)).Decode(&response); err != nil {
 				return nil, errors.Join(errs, err)
 			}
 			// Calculate and set the cost_usd field
@@ -555,6 +680,9 @@ func (s *Service) Do(ctx context.Context, ir *llm.Request) (*llm.Response, error
 
 			endTime := time.Now()
 			result := toLLMResponse(&response)
+			if ir.ResponseFormat != nil {
+				formattedResponse(result, ir.ResponseFormat.Name)
+			}
 			result.StartTime = &startTime
 			result.EndTime = &endTime
 			return result, nil
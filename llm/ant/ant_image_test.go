@@ -76,3 +76,30 @@ func TestAnthropicImageToolResult(t *testing.T) {
 		t.Errorf("Expected data to be '/9j/4AAQSkZJRg...', got '%s'", source["data"])
 	}
 }
+
+func TestAnthropicDocumentToolResult(t *testing.T) {
+	toolResult := llm.Content{
+		Type:      llm.ContentTypeToolResult,
+		ToolUseID: "toolu_01",
+		ToolResult: []llm.Content{
+			{Type: llm.ContentTypeText, Text: "Document from /tmp/spec.pdf"},
+			{Type: llm.ContentTypeText, MediaType: llm.DocumentMediaType, Data: "JVBERi0xLjQK"},
+		},
+	}
+
+	anthropicContent := fromLLMContent(toolResult)
+	if len(anthropicContent.ToolResult) != 2 {
+		t.Fatalf("Expected 2 content items, got %d", len(anthropicContent.ToolResult))
+	}
+	doc := anthropicContent.ToolResult[1]
+	if doc.Type != "document" || doc.Text != nil {
+		t.Errorf("Expected a document block without text, got %+v", doc)
+	}
+	var source map[string]any
+	if err := json.Unmarshal(doc.Source, &source); err != nil {
+		t.Fatalf("Failed to unmarshal document source: %v", err)
+	}
+	if source["type"] != "base64" || source["media_type"] != "application/pdf" || source["data"] != "JVBERi0xLjQK" {
+		t.Errorf("Unexpected document source %v", source)
+	}
+}
@@ -0,0 +1,99 @@
+package ant
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"shelley.exe.dev/llm"
+)
+
+func TestSamplingRequest(t *testing.T) {
+	s := &Service{}
+	zero, topP := 0.0, 0.9
+	req := s.fromLLMRequest(&llm.Request{
+		Messages: []llm.Message{llm.UserStringMessage("hi")},
+		Sampling: &llm.Sampling{Temperature: &zero, TopP: &topP, MaxTokens: 100, StopSequences: []string{"END"}},
+	})
+	data, err := json.Marshal(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Anthropic takes a temperature or top_p, not both
+	for _, want := range []string{`"temperature":0`, `"max_tokens":100`, `"stop_sequences":["END"]`} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("expected %s in %s", want, data)
+		}
+	}
+	if strings.Contains(string(data), "top_p") {
+		t.Errorf("expected top_p to be left out with a temperature, got %s", data)
+	}
+
+	two := 2.0
+	req = s.fromLLMRequest(&llm.Request{Sampling: &llm.Sampling{Temperature: &two}})
+	if req.Temperature == nil || *req.Temperature != 1 || req.MaxTokens != DefaultMaxTokens {
+		t.Errorf("expected the temperature to be capped at 1 and the default max tokens, got %v and %d", req.Temperature, req.MaxTokens)
+	}
+}
+
+func TestThinkingRequest(t *testing.T) {
+	s := &Service{}
+	zero := 0.0
+	req := s.fromLLMRequest(&llm.Request{
+		Messages: []llm.Message{llm.UserStringMessage("hi")},
+		Sampling: &llm.Sampling{Temperature: &zero},
+		Thinking: &llm.Thinking{Effort: "low"},
+	})
+	if req.Thinking == nil || req.Thinking.BudgetTokens != 4096 || req.MaxTokens <= 4096 || req.Temperature != nil {
+		t.Errorf("expected a low thinking budget within max_tokens and no temperature, got %+v", req)
+	}
+
+	// A tool call made without thinking can't be continued with it
+	toolTurn := []llm.Message{
+		llm.UserStringMessage("list files"),
+		{Role: llm.MessageRoleAssistant, Content: []llm.Content{{Type: llm.ContentTypeToolUse, ID: "1", ToolName: "bash"}}},
+		{Role: llm.MessageRoleUser, Content: []llm.Content{{Type: llm.ContentTypeToolResult, ToolUseID: "1"}}},
+	}
+	if req := s.fromLLMRequest(&llm.Request{Messages: toolTurn, Thinking: &llm.Thinking{Effort: "low"}}); req.Thinking != nil {
+		t.Error("expected no thinking in the middle of a turn without it")
+	}
+	toolTurn[1].Content = append([]llm.Content{{Type: llm.ContentTypeThinking, Thinking: "hmm", Signature: "sig"}}, toolTurn[1].Content...)
+	if req := s.fromLLMRequest(&llm.Request{Messages: toolTurn, Thinking: &llm.Thinking{Effort: "low"}}); req.Thinking == nil {
+		t.Error("expected thinking to continue a turn with it")
+	}
+	if req := s.fromLLMRequest(&llm.Request{ToolChoice: &llm.ToolChoice{Type: llm.ToolChoiceTypeTool, Name: "x"}, Thinking: &llm.Thinking{Effort: "low"}}); req.Thinking != nil {
+		t.Error("expected no thinking with forced tool use")
+	}
+
+	// Thinking Anthropic didn't sign is dropped, and doesn't count
+	toolTurn[1].Content[0].Signature = ""
+	req = s.fromLLMRequest(&llm.Request{Messages: toolTurn, Thinking: &llm.Thinking{Effort: "low"}})
+	if req.Thinking != nil || len(req.Messages[1].Content) != 1 || req.Messages[1].Content[0].Type != "tool_use" {
+		t.Errorf("expected unsigned thinking to be dropped, got %+v", req.Messages[1])
+	}
+}
+
+func TestResponseFormatRequest(t *testing.T) {
+	s := &Service{}
+	format := &llm.ResponseFormat{Name: "slug", Schema: llm.MustSchema(`{"type": "object", "properties": {"slug": {"type": "string"}}}`)}
+	req := s.fromLLMRequest(&llm.Request{
+		Messages:       []llm.Message{llm.UserStringMessage("name this")},
+		Thinking:       &llm.Thinking{Effort: "low"},
+		ResponseFormat: format,
+	})
+	if len(req.Tools) != 1 || req.Tools[0].Name != "slug" || req.ToolChoice == nil || req.ToolChoice.Name != "slug" {
+		t.Fatalf("expected a forced call to the slug tool, got %+v", req)
+	}
+	if req.Thinking != nil {
+		t.Error("expected no thinking with a response format")
+	}
+
+	resp := &llm.Response{
+		StopReason: llm.StopReasonToolUse,
+		Content:    []llm.Content{{Type: llm.ContentTypeToolUse, ToolName: "slug", ToolInput: json.RawMessage(`{"slug":"fix-tests"}`)}},
+	}
+	formattedResponse(resp, "slug")
+	if resp.StopReason != llm.StopReasonEndTurn || resp.JSONText() != `{"slug":"fix-tests"}` {
+		t.Errorf("expected the tool input as text, got %+v", resp)
+	}
+}
@@ -0,0 +1,129 @@
+package ant
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"shelley.exe.dev/llm"
+)
+
+// streamEvent is a server-sent event of a streamed response.
+// https://docs.anthropic.com/en/docs/build-with-claude/streaming
+type streamEvent struct {
+	Type         string    `json:"type"`
+	Message      *response `json:"message,omitempty"`       // message_start
+	Index        int       `json:"index"`                   // content_block_*
+	ContentBlock *content  `json:"content_block,omitempty"` // content_block_start
+	Delta        struct {
+		Type         string  `json:"type"`
+		Text         string  `json:"text"`
+		PartialJSON  string  `json:"partial_json"`
+		Thinking     string  `json:"thinking"`
+		Signature    string  `json:"signature"`
+		StopReason   string  `json:"stop_reason"`   // message_delta
+		StopSequence *string `json:"stop_sequence"` // message_delta
+	} `json:"delta"`
+	Usage *usage `json:"usage,omitempty"` // message_delta
+	Error *struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+// readStream puts a response together from the events of a streamed one,
+// passing its text so far to the text stream of ctx as it arrives. It also
+// returns the events as read, for recording.
+func readStream(ctx context.Context, r io.Reader) (*response, []byte, error) {
+	var raw bytes.Buffer
+	scanner := bufio.NewScanner(io.TeeReader(r, &raw))
+	scanner.Buffer(make([]byte, 0, 64*1024), 16<<20)
+
+	var resp *response
+	var inputs []string      // tool inputs so far, by content index
+	var text strings.Builder // text of the response so far
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+		if !ok {
+			continue
+		}
+		var event streamEvent
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			return nil, raw.Bytes(), fmt.Errorf("invalid stream event: %w", err)
+		}
+		if event.Type == "error" && event.Error != nil {
+			return nil, raw.Bytes(), fmt.Errorf("stream error: %s: %s", event.Error.Type, event.Error.Message)
+		}
+		if event.Type == "message_start" {
+			resp = event.Message
+			continue
+		}
+		if resp == nil {
+			continue // ping
+		}
+
+		switch event.Type {
+		case "content_block_start":
+			if event.ContentBlock == nil || event.Index != len(resp.Content) {
+				return nil, raw.Bytes(), fmt.Errorf("unexpected content block %d", event.Index)
+			}
+			block := *event.ContentBlock
+			if block.Type == "tool_use" {
+				block.ToolInput = nil // sent in deltas
+			}
+			resp.Content = append(resp.Content, block)
+			inputs = append(inputs, "")
+		case "content_block_delta":
+			if event.Index >= len(resp.Content) {
+				return nil, raw.Bytes(), fmt.Errorf("delta for unknown content block %d", event.Index)
+			}
+			c := &resp.Content[event.Index]
+			switch event.Delta.Type {
+			case "text_delta":
+				t := event.Delta.Text
+				if c.Text != nil {
+					t = *c.Text + t
+				}
+				c.Text = &t
+				text.WriteString(event.Delta.Text)
+				llm.StreamText(ctx, text.String())
+			case "input_json_delta":
+				inputs[event.Index] += event.Delta.PartialJSON
+			case "thinking_delta":
+				c.Thinking += event.Delta.Thinking
+			case "signature_delta":
+				c.Signature += event.Delta.Signature
+			}
+		case "content_block_stop":
+			if event.Index >= len(resp.Content) {
+				return nil, raw.Bytes(), fmt.Errorf("end of unknown content block %d", event.Index)
+			}
+			c := &resp.Content[event.Index]
+			if c.Type == "tool_use" {
+				c.ToolInput = json.RawMessage(cmp.Or(inputs[event.Index], "{}"))
+			}
+			if c.Type == "text" && text.Len() > 0 {
+				text.WriteString("\n\n")
+			}
+		case "message_delta":
+			resp.StopReason = event.Delta.StopReason
+			resp.StopSequence = event.Delta.StopSequence
+			if u := event.Usage; u != nil {
+				resp.Usage.OutputTokens = u.OutputTokens
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, raw.Bytes(), err
+	}
+	if resp == nil || resp.StopReason == "" {
+		return nil, raw.Bytes(), errors.New("stream ended before the response did")
+	}
+	return resp, raw.Bytes(), nil
+}
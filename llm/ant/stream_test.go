@@ -0,0 +1,95 @@
+package ant
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"shelley.exe.dev/llm"
+)
+
+const testStream = `event: message_start
+data: {"type":"message_start","message":{"id":"msg_1","type":"message","role":"assistant","model":"claude","content":[],"stop_reason":null,"usage":{"input_tokens":10,"output_tokens":1}}}
+
+event: ping
+data: {"type":"ping"}
+
+event: content_block_start
+data: {"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Listing "}}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"files."}}
+
+event: content_block_stop
+data: {"type":"content_block_stop","index":0}
+
+event: content_block_start
+data: {"type":"content_block_start","index":1,"content_block":{"type":"tool_use","id":"toolu_1","name":"bash","input":{}}}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"{\"command\":"}}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":" \"ls\"}"}}
+
+event: content_block_stop
+data: {"type":"content_block_stop","index":1}
+
+event: message_delta
+data: {"type":"message_delta","delta":{"stop_reason":"tool_use","stop_sequence":null},"usage":{"output_tokens":25}}
+
+event: message_stop
+data: {"type":"message_stop"}
+
+`
+
+func TestStreamedResponse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if !strings.Contains(string(body), `"stream": true`) {
+			t.Errorf("expected a streamed request, got %s", body)
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, testStream)
+	}))
+	defer server.Close()
+
+	var streamed []string
+	ctx := llm.WithTextStream(context.Background(), func(text string) {
+		streamed = append(streamed, text)
+	})
+	s := &Service{URL: server.URL, APIKey: "test"}
+	resp, err := s.Do(ctx, &llm.Request{Messages: []llm.Message{llm.UserStringMessage("list files")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !slices.Equal(streamed, []string{"Listing ", "Listing files."}) {
+		t.Errorf("unexpected streamed text: %q", streamed)
+	}
+	if resp.StopReason != llm.StopReasonToolUse || resp.Usage.InputTokens != 10 || resp.Usage.OutputTokens != 25 {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+	if len(resp.Content) != 2 || resp.Content[0].Text != "Listing files." {
+		t.Fatalf("unexpected content: %+v", resp.Content)
+	}
+	var input struct{ Command string }
+	if err := json.Unmarshal(resp.Content[1].ToolInput, &input); err != nil || input.Command != "ls" {
+		t.Errorf("unexpected tool input %s: %v", resp.Content[1].ToolInput, err)
+	}
+}
+
+func TestStreamEndedEarly(t *testing.T) {
+	cut := testStream[:strings.Index(testStream, "event: message_delta")]
+	if _, _, err := readStream(context.Background(), strings.NewReader(cut)); err == nil {
+		t.Error("expected an error for a stream that ends before the response")
+	}
+}
@@ -188,7 +188,14 @@ func (s *Service) buildGeminiRequest(req *llm.Request) (*gemini.Request, error)
 		// Map each content item to Gemini's format
 		for _, c := range msg.Content {
 			switch c.Type {
-			case llm.ContentTypeText, llm.ContentTypeThinking, llm.ContentTypeRedactedThinking:
+			case llm.ContentTypeThinking, llm.ContentTypeRedactedThinking:
+				// Other providers' thinking means nothing to Gemini
+				continue
+			case llm.ContentTypeText:
+				if c.IsDocument() {
+					content.Parts = append(content.Parts, documentPart(c))
+					continue
+				}
 				// Simple text content
 				content.Parts = append(content.Parts, gemini.Part{
 					Text: c.Text,
@@ -277,6 +284,12 @@ func (s *Service) buildGeminiRequest(req *llm.Request) (*gemini.Request, error)
 						Response: response,
 					},
 				})
+				// Function responses are text only; documents follow as parts of their own
+				for _, result := range c.ToolResult {
+					if result.IsDocument() {
+						content.Parts = append(content.Parts, documentPart(result))
+					}
+				}
 			}
 		}
 
@@ -295,10 +308,37 @@ func (s *Service) buildGeminiRequest(req *llm.Request) (*gemini.Request, error)
 		}
 	}
 
+	if sampling := req.Sampling; sampling != nil {
+		gemReq.GenerationConfig = &gemini.GenerationConfig{
+			Temperature:     sampling.Temperature,
+			TopP:            sampling.TopP,
+			MaxOutputTokens: sampling.MaxTokens,
+			StopSequences:   sampling.StopSequences,
+		}
+	}
+
+	if rf := req.ResponseFormat; rf != nil {
+		var schemaJSON map[string]any
+		if err := json.Unmarshal(rf.Schema, &schemaJSON); err != nil {
+			return nil, fmt.Errorf("invalid response format schema: %w", err)
+		}
+		schema := convertJSONSchemaToGeminiSchema(schemaJSON)
+		if gemReq.GenerationConfig == nil {
+			gemReq.GenerationConfig = &gemini.GenerationConfig{}
+		}
+		gemReq.GenerationConfig.ResponseMimeType = "application/json"
+		gemReq.GenerationConfig.ResponseSchema = &schema
+	}
+
 	return gemReq, nil
 }
 
 // convertGeminiResponsesToContent converts a Gemini response to llm.Content
+// documentPart returns document content c as inline data, which Gemini reads natively.
+func documentPart(c llm.Content) gemini.Part {
+	return gemini.Part{InlineData: &gemini.Blob{MimeType: c.MediaType, Data: c.Data}}
+}
+
 func convertGeminiResponseToContent(res *gemini.Response) []llm.Content {
 	if res == nil || len(res.Candidates) == 0 || len(res.Candidates[0].Content.Parts) == 0 {
 		return []llm.Content{{
@@ -461,6 +501,12 @@ func (s *Service) TokenContextWindow() int {
 	}
 }
 
+// Capabilities reports the model's capabilities. Gemini's price depends
+// on the prompt's length, so none is given.
+func (s *Service) Capabilities() llm.Capabilities {
+	return llm.Capabilities{Vision: true, Tools: true}
+}
+
 // MaxImageDimension returns the maximum allowed image dimension.
 // TODO: determine actual Gemini image dimension limits
 func (s *Service) MaxImageDimension() int {
@@ -555,6 +601,12 @@ func (s *Service) Do(ctx context.Context, ir *llm.Request) (*llm.Response, error
 			// Log the structured Gemini response
 			if resJSON, err := json.MarshalIndent(gemRes, "", "  "); err == nil {
 				slog.DebugContext(ctx, "gemini_response_json", "response", string(resJSON))
+				// The URL leaves out the API key that goes with it
+				if reqJSON, err := json.Marshal(gemReq); err == nil {
+					endpoint := cmp.Or(s.URL, "https://generativelanguage.googleapis.com/v1beta")
+					url := fmt.Sprintf("%s/models/%s:generateContent", endpoint, cmp.Or(s.Model, DefaultModel))
+					llm.RecordHTTP(ctx, llm.HTTPExchange{URL: url, Request: reqJSON, Response: resJSON, StatusCode: http.StatusOK})
+				}
 				if s.DumpLLM {
 					if err := llm.DumpToFile("response", "", resJSON); err != nil {
 						slog.WarnContext(ctx, "failed to dump gemini response to file", "error", err)
@@ -72,6 +72,46 @@ func TestBuildGeminiRequest(t *testing.T) {
 	}
 }
 
+func TestBuildGeminiRequestDocument(t *testing.T) {
+	service := &Service{Model: DefaultModel, APIKey: "test-api-key"}
+	req := &llm.Request{
+		Messages: []llm.Message{
+			{
+				Role: llm.MessageRoleAssistant,
+				Content: []llm.Content{
+					{Type: llm.ContentTypeToolUse, ID: "call_1", ToolName: "read_document", ToolInput: json.RawMessage(`{"path":"/tmp/spec.pdf"}`)},
+				},
+			},
+			{
+				Role: llm.MessageRoleUser,
+				Content: []llm.Content{{
+					Type:      llm.ContentTypeToolResult,
+					ToolUseID: "call_1",
+					ToolResult: []llm.Content{
+						{Type: llm.ContentTypeText, Text: "Document from /tmp/spec.pdf"},
+						{Type: llm.ContentTypeText, MediaType: llm.DocumentMediaType, Data: "JVBERi0xLjQK"},
+					},
+				}},
+			},
+		},
+	}
+
+	gemReq, err := service.buildGeminiRequest(req)
+	if err != nil {
+		t.Fatalf("Failed to build Gemini request: %v", err)
+	}
+	parts := gemReq.Contents[1].Parts
+	if len(parts) != 2 || parts[0].FunctionResponse == nil {
+		t.Fatalf("Expected a function response and the document, got %+v", parts)
+	}
+	if parts[0].FunctionResponse.Response["result"] != "Document from /tmp/spec.pdf" {
+		t.Errorf("Expected the text result in the function response, got %v", parts[0].FunctionResponse.Response)
+	}
+	if blob := parts[1].InlineData; blob == nil || blob.MimeType != "application/pdf" || blob.Data != "JVBERi0xLjQK" {
+		t.Errorf("Expected the document as inline data, got %+v", parts[1])
+	}
+}
+
 func TestConvertToolSchemas(t *testing.T) {
 	// Create a simple tool with a JSON schema
 	schema := `{
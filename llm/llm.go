@@ -2,7 +2,9 @@
 package llm
 
 import (
+	"cmp"
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"log/slog"
@@ -37,6 +39,41 @@ func UseSimplifiedPatch(svc Service) bool {
 	return false
 }
 
+// Capabilities describes what a model accepts and what it costs.
+type Capabilities struct {
+	// Vision reports whether the model accepts images.
+	Vision bool `json:"vision"`
+	// Tools reports whether the model can call tools.
+	Tools bool `json:"tools"`
+	// Thinking reports whether the model takes a Request's Thinking.
+	Thinking bool `json:"thinking"`
+	// Pricing is nil if the price is unknown.
+	Pricing *Pricing `json:"pricing,omitempty"`
+}
+
+// Pricing is what a model costs, in USD per million tokens.
+type Pricing struct {
+	Input      float64 `json:"input"`
+	Output     float64 `json:"output"`
+	CacheRead  float64 `json:"cache_read,omitempty"`
+	CacheWrite float64 `json:"cache_write,omitempty"`
+}
+
+type CapabilityReporter interface {
+	// Capabilities reports what the service's model accepts and costs.
+	Capabilities() Capabilities
+}
+
+// ModelCapabilities returns what svc's model accepts and costs. Services
+// that don't report it are assumed to call tools, which the agent needs,
+// and to take no images.
+func ModelCapabilities(svc Service) Capabilities {
+	if cr, ok := svc.(CapabilityReporter); ok {
+		return cr.Capabilities()
+	}
+	return Capabilities{Tools: true}
+}
+
 // MustSchema validates that schema is a valid JSON schema and returns it as a json.RawMessage.
 // It panics if the schema is invalid.
 // The schema must have at least type="object" and a properties key.
@@ -65,6 +102,100 @@ type Request struct {
 	ToolChoice *ToolChoice
 	Tools      []*Tool
 	System     []SystemContent
+	Sampling   *Sampling `json:",omitempty"` // nil leaves sampling to the service
+	Thinking   *Thinking `json:",omitempty"` // nil leaves reasoning to the service
+	// ResponseFormat, if set, constrains the response to JSON, which is
+	// returned as its text content.
+	ResponseFormat *ResponseFormat `json:",omitempty"`
+}
+
+// ResponseFormat asks for a response that is a JSON object matching
+// Schema. Providers that can't constrain their output to a schema get it
+// with a tool call the model is made to make instead. OpenAI's strict
+// mode needs every property required and additionalProperties false.
+type ResponseFormat struct {
+	Name   string          `json:"name"` // letters, digits, underscores and hyphens
+	Schema json.RawMessage `json:"schema"`
+}
+
+// Sampling overrides how a model samples its response. Unset fields keep
+// the service's defaults; a Temperature of 0 asks for the most
+// deterministic output the provider offers.
+type Sampling struct {
+	Temperature   *float64 `json:"temperature,omitempty"`
+	TopP          *float64 `json:"top_p,omitempty"`
+	MaxTokens     int      `json:"max_tokens,omitempty"` // maximum output tokens
+	StopSequences []string `json:"stop_sequences,omitempty"`
+}
+
+// Thinking asks a model that reasons before answering to reason more or
+// less. Some providers take a level of effort (OpenAI) and others a token
+// budget (Anthropic); each is derived from the other if it isn't set.
+type Thinking struct {
+	Effort       string `json:"effort,omitempty"` // "low", "medium" or "high"
+	BudgetTokens int    `json:"budget_tokens,omitempty"`
+}
+
+// Thinking budgets for each level of effort. Anthropic takes no less than
+// 1024 tokens.
+var thinkingBudgets = map[string]int{"low": 4096, "medium": 16384, "high": 32768}
+
+// Validate checks that the effort is known and the budget big enough.
+func (t *Thinking) Validate() error {
+	if t.Effort == "" && t.BudgetTokens == 0 {
+		return fmt.Errorf("thinking needs an effort or a budget")
+	}
+	if _, ok := thinkingBudgets[t.Effort]; t.Effort != "" && !ok {
+		return fmt.Errorf("unknown thinking effort %q: must be low, medium or high", t.Effort)
+	}
+	if t.BudgetTokens != 0 && t.BudgetTokens < 1024 {
+		return fmt.Errorf("thinking budget must be at least 1024 tokens")
+	}
+	return nil
+}
+
+// Budget returns the token budget, derived from the effort if unset.
+func (t *Thinking) Budget() int {
+	if t.BudgetTokens != 0 {
+		return t.BudgetTokens
+	}
+	return cmp.Or(thinkingBudgets[t.Effort], thinkingBudgets["medium"])
+}
+
+// Level returns the effort, derived from the budget if unset.
+func (t *Thinking) Level() string {
+	switch {
+	case t.Effort != "":
+		return t.Effort
+	case t.BudgetTokens < thinkingBudgets["medium"]:
+		return "low"
+	case t.BudgetTokens < thinkingBudgets["high"]:
+		return "medium"
+	default:
+		return "high"
+	}
+}
+
+// Validate checks that the parameters are in the ranges providers accept.
+func (s *Sampling) Validate() error {
+	if s.Temperature != nil && (*s.Temperature < 0 || *s.Temperature > 2) {
+		return fmt.Errorf("temperature must be between 0 and 2")
+	}
+	if s.TopP != nil && (*s.TopP <= 0 || *s.TopP > 1) {
+		return fmt.Errorf("top_p must be greater than 0 and at most 1")
+	}
+	if s.MaxTokens < 0 {
+		return fmt.Errorf("max_tokens must not be negative")
+	}
+	if len(s.StopSequences) > 4 {
+		return fmt.Errorf("at most 4 stop sequences are supported")
+	}
+	for _, stop := range s.StopSequences {
+		if stop == "" {
+			return fmt.Errorf("stop sequences must not be empty")
+		}
+	}
+	return nil
 }
 
 // Message represents a message in the conversation.
@@ -73,6 +204,9 @@ type Message struct {
 	Content   []Content   `json:"Content"`
 	ToolUse   *ToolUse    `json:"ToolUse,omitempty"` // use to control whether/which tool to use
 	EndOfTurn bool        `json:"EndOfTurn"`         // true if this message completes the agent's turn (no tool calls to make)
+	// Thinking, on a user message, overrides the Request's Thinking for the
+	// turn the message starts.
+	Thinking *Thinking `json:"Thinking,omitempty"`
 }
 
 // ToolUse represents a tool use in the message content.
@@ -134,7 +268,7 @@ type Content struct {
 	Type ContentType
 	Text string
 
-	// Media type for image content
+	// Media type for image and document content
 	MediaType string
 
 	// for thinking
@@ -158,13 +292,51 @@ type Content struct {
 	// Display is content to be displayed to the user, copied from ToolOut
 	Display any
 
+	// Blob is set on the text of a tool result too large to store in its
+	// message: Text is then a preview, and Blob the hash under which the
+	// whole text is stored; added externally; not sent to the LLM
+	Blob string `json:",omitempty"`
+
 	Cache bool
 }
 
+// TurnStart returns the index of the user message that starts the last
+// turn of messages, the last one that isn't just tool results, or -1.
+func TurnStart(messages []Message) int {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role != MessageRoleUser {
+			continue
+		}
+		for _, c := range messages[i].Content {
+			if c.Type != ContentTypeToolResult {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
 func StringContent(s string) Content {
 	return Content{Type: ContentTypeText, Text: s}
 }
 
+// DocumentMediaType is the media type of document content. Like images,
+// documents are text content with MediaType set and the base64-encoded
+// file in Data.
+const DocumentMediaType = "application/pdf"
+
+// IsDocument reports whether c is document content.
+func (c Content) IsDocument() bool {
+	return c.Type == ContentTypeText && c.MediaType == DocumentMediaType
+}
+
+// DocumentFallback returns text content to send in place of document
+// content c to providers that can't read documents.
+func DocumentFallback(c Content) Content {
+	size := base64.StdEncoding.DecodedLen(len(c.Data))
+	return StringContent(fmt.Sprintf("[A PDF document of about %d bytes was attached here, but this model can't read documents. Extract its text with a command such as pdftotext instead.]", size))
+}
+
 // ContentsAttr returns contents as a slog.Attr.
 // It is meant for logging.
 func ContentsAttr(contents []Content) slog.Attr {
@@ -181,7 +353,7 @@ func ContentsAttr(contents []Content) slog.Attr {
 			attrs = append(attrs, slog.Any("tool_result", content.ToolResult))
 			attrs = append(attrs, slog.Bool("tool_error", content.ToolError))
 		case ContentTypeThinking:
-			attrs = append(attrs, slog.String("thinking", content.Text))
+			attrs = append(attrs, slog.String("thinking", content.Thinking))
 		default:
 			attrs = append(attrs, slog.String("unknown_content_type", content.Type.String()))
 			attrs = append(attrs, slog.Any("text", content)) // just log it all raw, better to have too much than not enough
@@ -243,6 +415,24 @@ func (m *Response) ToMessage() Message {
 	}
 }
 
+// JSONText returns the response's first text content, with surrounding
+// whitespace and any markdown code fence removed, for decoding a
+// response to a request with a ResponseFormat.
+func (m *Response) JSONText() string {
+	for _, c := range m.Content {
+		if c.Type != ContentTypeText {
+			continue
+		}
+		text := strings.TrimSpace(c.Text)
+		if fenced, ok := strings.CutPrefix(text, "```"); ok {
+			fenced = strings.TrimPrefix(fenced, "json")
+			text = strings.TrimSpace(strings.TrimSuffix(fenced, "```"))
+		}
+		return text
+	}
+	return ""
+}
+
 func CostUSDFromResponse(headers http.Header) float64 {
 	h := headers.Get("Skaband-Cost-Microcents")
 	if h == "" {
@@ -266,6 +456,8 @@ type Usage struct {
 	CacheReadInputTokens     uint64     `json:"cache_read_input_tokens"`
 	OutputTokens             uint64     `json:"output_tokens"`
 	CostUSD                  float64    `json:"cost_usd"`
+	ReasoningTokens          uint64     `json:"reasoning_tokens,omitempty"` // output tokens spent reasoning, where the provider reports them
+	Thinking                 *Thinking  `json:"thinking,omitempty"`         // reasoning asked of a model that takes it
 	Model                    string     `json:"model,omitempty"`
 	StartTime                *time.Time `json:"start_time,omitempty"`
 	EndTime                  *time.Time `json:"end_time,omitempty"`
@@ -276,6 +468,7 @@ func (u *Usage) Add(other Usage) {
 	u.CacheCreationInputTokens += other.CacheCreationInputTokens
 	u.CacheReadInputTokens += other.CacheReadInputTokens
 	u.OutputTokens += other.OutputTokens
+	u.ReasoningTokens += other.ReasoningTokens
 	u.CostUSD += other.CostUSD
 }
 
@@ -343,6 +536,59 @@ func ErrorfToolOut(format string, args ...any) ToolOut {
 	return ErrorToolOut(fmt.Errorf(format, args...))
 }
 
+// HTTPExchange is an HTTP request to a provider and its response, as sent
+// and received.
+type HTTPExchange struct {
+	URL        string
+	Request    []byte
+	Response   []byte
+	StatusCode int
+}
+
+type httpRecorderKeyType string
+
+var httpRecorderKey httpRecorderKeyType
+
+// WithHTTPRecorder returns a context in which services pass each HTTP
+// request they make to a provider, and its response, to record.
+// Services that retry call record for each attempt.
+func WithHTTPRecorder(ctx context.Context, record func(HTTPExchange)) context.Context {
+	return context.WithValue(ctx, httpRecorderKey, record)
+}
+
+// RecordHTTP passes exchange to the recorder of ctx, if it has one.
+func RecordHTTP(ctx context.Context, exchange HTTPExchange) {
+	if record, ok := ctx.Value(httpRecorderKey).(func(HTTPExchange)); ok {
+		record(exchange)
+	}
+}
+
+type textStreamKeyType string
+
+var textStreamKey textStreamKeyType
+
+// WithTextStream returns a context in which services that can stream their
+// responses pass the text of each response so far to stream, as it is
+// generated. The text starts over when a service retries a request.
+func WithTextStream(ctx context.Context, stream func(text string)) context.Context {
+	return context.WithValue(ctx, textStreamKey, stream)
+}
+
+// StreamsText reports whether ctx has a text stream, so that services only
+// stream responses someone is waiting for.
+func StreamsText(ctx context.Context) bool {
+	_, ok := ctx.Value(textStreamKey).(func(string))
+	return ok
+}
+
+// StreamText passes the text of a response so far to the text stream of
+// ctx, if it has one.
+func StreamText(ctx context.Context, text string) {
+	if stream, ok := ctx.Value(textStreamKey).(func(string)); ok {
+		stream(text)
+	}
+}
+
 // DumpToFile writes LLM communication content to a timestamped file in ~/.cache/sketch/.
 // For requests, it includes the URL followed by the content. For responses, it only includes the content.
 // The typ parameter is used as a prefix in the filename ("request", "response").
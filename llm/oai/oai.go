@@ -7,6 +7,7 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"math"
 	"math/rand/v2"
 	"net/http"
 	"strings"
@@ -409,11 +410,20 @@ var (
 	}
 )
 
+// contentText returns the text sent to OpenAI for c. Documents can't be
+// sent here, so they are replaced by llm.DocumentFallback.
+func contentText(c llm.Content) string {
+	if c.IsDocument() {
+		return llm.DocumentFallback(c).Text
+	}
+	return c.Text
+}
+
 // fromLLMContent converts llm.Content to the format expected by OpenAI.
 func fromLLMContent(c llm.Content) (string, []openai.ToolCall) {
 	switch c.Type {
 	case llm.ContentTypeText:
-		return c.Text, nil
+		return contentText(c), nil
 	case llm.ContentTypeToolUse:
 		// For OpenAI, tool use is sent as a null content with tool_calls in the message
 		return "", []openai.ToolCall{
@@ -435,15 +445,17 @@ func fromLLMContent(c llm.Content) (string, []openai.ToolCall) {
 			// Collect all text from content objects
 			texts := make([]string, 0, len(c.ToolResult))
 			for _, result := range c.ToolResult {
-				if result.Text != "" {
-					texts = append(texts, result.Text)
+				if text := contentText(result); text != "" {
+					texts = append(texts, text)
 				}
 			}
 			resultText = strings.Join(texts, "\n")
 		}
 		return resultText, nil
+	case llm.ContentTypeThinking, llm.ContentTypeRedactedThinking:
+		// Providers that return reasoning_content reject it in requests
+		return "", nil
 	default:
-		// For thinking or other types, convert to text
 		return c.Text, nil
 	}
 }
@@ -473,8 +485,8 @@ func fromLLMMessage(msg llm.Message) []openai.ChatCompletionMessage {
 		// Collect all text from content objects
 		var texts []string
 		for _, result := range tr.ToolResult {
-			if strings.TrimSpace(result.Text) != "" {
-				texts = append(texts, result.Text)
+			if text := contentText(result); strings.TrimSpace(text) != "" {
+				texts = append(texts, text)
 			}
 		}
 		toolResultContent := strings.Join(texts, "\n")
@@ -542,6 +554,18 @@ func (m Model) requiresMaxCompletionTokens() bool {
 	}
 }
 
+// reasons reports whether the model is one of OpenAI's reasoning models,
+// GPT-5 included, which take a reasoning effort.
+func (m Model) reasons() bool {
+	return m.IsReasoningModel || strings.HasPrefix(m.ModelName, "gpt-5")
+}
+
+// takesSamplingParameters reports whether the model accepts a temperature
+// and top_p. Reasoning models reject them.
+func (m Model) takesSamplingParameters() bool {
+	return !m.reasons()
+}
+
 // fromLLMToolChoice converts llm.ToolChoice to the format expected by OpenAI.
 func fromLLMToolChoice(tc *llm.ToolChoice) any {
 	if tc == nil {
@@ -647,6 +671,11 @@ func toLLMContents(msg openai.ChatCompletionMessage) []llm.Content {
 		return []llm.Content{toToolResultLLMContent(msg)}
 	}
 
+	// Reasoning models such as DeepSeek R1 return their reasoning separately
+	if msg.ReasoningContent != "" {
+		contents = append(contents, llm.Content{Type: llm.ContentTypeThinking, Thinking: msg.ReasoningContent})
+	}
+
 	// If there's text content, add it
 	if msg.Content != "" {
 		contents = append(contents, toRawLLMContent(msg.Content))
@@ -683,6 +712,9 @@ func (s *Service) toLLMUsage(au openai.Usage, headers http.Header) llm.Usage {
 		CacheCreationInputTokens: in,
 		OutputTokens:             out,
 	}
+	if au.CompletionTokensDetails != nil {
+		u.ReasoningTokens = uint64(au.CompletionTokensDetails.ReasoningTokens)
+	}
 	u.CostUSD = llm.CostUSDFromResponse(headers)
 	return u
 }
@@ -767,6 +799,48 @@ func (s *Service) TokenContextWindow() int {
 	}
 }
 
+// Capabilities reports the model's capabilities and list price.
+func (s *Service) Capabilities() llm.Capabilities {
+	return modelCapabilities(cmp.Or(s.Model, DefaultModel))
+}
+
+// modelCapabilities returns a model's capabilities and list price. Models
+// without a listed price are assumed to take no images.
+func modelCapabilities(model Model) llm.Capabilities {
+	caps := llm.Capabilities{Vision: true, Tools: true, Thinking: model.reasons()}
+	switch model.ModelName {
+	case "gpt-5.1", "gpt-5.1-codex":
+		caps.Pricing = &llm.Pricing{Input: 1.25, Output: 10, CacheRead: 0.125}
+	case "gpt-5.1-mini":
+		caps.Pricing = &llm.Pricing{Input: 0.25, Output: 2, CacheRead: 0.025}
+	case "gpt-5.1-nano":
+		caps.Pricing = &llm.Pricing{Input: 0.05, Output: 0.4, CacheRead: 0.005}
+	case "gpt-4.1-2025-04-14":
+		caps.Pricing = &llm.Pricing{Input: 2, Output: 8, CacheRead: 0.5}
+	case "gpt-4.1-mini-2025-04-14":
+		caps.Pricing = &llm.Pricing{Input: 0.4, Output: 1.6, CacheRead: 0.1}
+	case "gpt-4.1-nano-2025-04-14":
+		caps.Pricing = &llm.Pricing{Input: 0.1, Output: 0.4, CacheRead: 0.025}
+	case "gpt-4o-2024-08-06":
+		caps.Pricing = &llm.Pricing{Input: 2.5, Output: 10, CacheRead: 1.25}
+	case "gpt-4o-mini-2024-07-18":
+		caps.Pricing = &llm.Pricing{Input: 0.15, Output: 0.6, CacheRead: 0.075}
+	case "o3-2025-04-16":
+		caps.Pricing = &llm.Pricing{Input: 2, Output: 8, CacheRead: 0.5}
+	case "o4-mini-2025-04-16":
+		caps.Pricing = &llm.Pricing{Input: 1.1, Output: 4.4, CacheRead: 0.275}
+	case "accounts/fireworks/models/qwen3-coder-480b-a35b-instruct":
+		caps.Vision = false
+		caps.Pricing = &llm.Pricing{Input: 0.45, Output: 1.8}
+	case "accounts/fireworks/models/glm-4p6":
+		caps.Vision = false
+		caps.Pricing = &llm.Pricing{Input: 0.55, Output: 2.19}
+	default:
+		caps.Vision = false
+	}
+	return caps
+}
+
 // MaxImageDimension returns the maximum allowed image dimension.
 // TODO: determine actual OpenAI image dimension limits
 func (s *Service) MaxImageDimension() int {
@@ -818,10 +892,38 @@ func (s *Service) Do(ctx context.Context, ir *llm.Request) (*llm.Response, error
 		Tools:      tools,
 		ToolChoice: fromLLMToolChoice(ir.ToolChoice), // TODO: make fromLLMToolChoice return an error when a perfect translation is not possible
 	}
+	maxTokens := cmp.Or(s.MaxTokens, DefaultMaxTokens)
+	if sampling := ir.Sampling; sampling != nil {
+		maxTokens = cmp.Or(sampling.MaxTokens, maxTokens)
+		req.Stop = sampling.StopSequences
+		if model.takesSamplingParameters() {
+			if sampling.Temperature != nil {
+				// The client drops a zero temperature, which would leave
+				// the default of 1
+				req.Temperature = max(float32(*sampling.Temperature), math.SmallestNonzeroFloat32)
+			}
+			if sampling.TopP != nil {
+				req.TopP = float32(*sampling.TopP)
+			}
+		}
+	}
+	if ir.Thinking != nil && model.reasons() {
+		req.ReasoningEffort = ir.Thinking.Level()
+	}
+	if rf := ir.ResponseFormat; rf != nil {
+		req.ResponseFormat = &openai.ChatCompletionResponseFormat{
+			Type: openai.ChatCompletionResponseFormatTypeJSONSchema,
+			JSONSchema: &openai.ChatCompletionResponseFormatJSONSchema{
+				Name:   rf.Name,
+				Schema: rf.Schema,
+				Strict: true,
+			},
+		}
+	}
 	if model.requiresMaxCompletionTokens() {
-		req.MaxCompletionTokens = cmp.Or(s.MaxTokens, DefaultMaxTokens)
+		req.MaxCompletionTokens = maxTokens
 	} else {
-		req.MaxTokens = cmp.Or(s.MaxTokens, DefaultMaxTokens)
+		req.MaxTokens = maxTokens
 	}
 	// Construct the full URL for logging and debugging
 	fullURL := baseURL + "/chat/completions"
@@ -854,6 +956,11 @@ func (s *Service) Do(ctx context.Context, ir *llm.Request) (*llm.Response, error
 
 		// Handle successful response
 		if err == nil {
+			// The client sends req as JSON, so this is what went over the wire
+			if reqJSON, jsonErr := json.Marshal(req); jsonErr == nil {
+				respJSON, _ := json.Marshal(resp)
+				llm.RecordHTTP(ctx, llm.HTTPExchange{URL: fullURL, Request: reqJSON, Response: respJSON, StatusCode: http.StatusOK})
+			}
 			// Dump response if enabled
 			if s.DumpLLM {
 				if respJSON, jsonErr := json.MarshalIndent(resp, "", "  "); jsonErr == nil {
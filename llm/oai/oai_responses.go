@@ -11,6 +11,7 @@ import (
 	"log/slog"
 	"math/rand/v2"
 	"net/http"
+	"slices"
 	"strings"
 	"time"
 
@@ -41,6 +42,20 @@ type responsesRequest struct {
 	ToolChoice      any                  `json:"tool_choice,omitempty"`
 	MaxOutputTokens int                  `json:"max_output_tokens,omitempty"`
 	Reasoning       *responsesReasoning  `json:"reasoning,omitempty"`
+	Temperature     *float64             `json:"temperature,omitempty"`
+	TopP            *float64             `json:"top_p,omitempty"`
+	Text            *responsesText       `json:"text,omitempty"`
+}
+
+type responsesText struct {
+	Format responsesFormat `json:"format"`
+}
+
+type responsesFormat struct {
+	Type   string          `json:"type"` // "json_schema"
+	Name   string          `json:"name"`
+	Schema json.RawMessage `json:"schema"`
+	Strict bool            `json:"strict"`
 }
 
 type responsesReasoning struct {
@@ -48,13 +63,21 @@ type responsesReasoning struct {
 }
 
 type responsesInputItem struct {
-	Type      string             `json:"type"`                // "message", "function_call", "function_call_output"
-	Role      string             `json:"role,omitempty"`      // for messages: "user", "assistant"
-	Content   []responsesContent `json:"content,omitempty"`   // for messages
-	CallID    string             `json:"call_id,omitempty"`   // for function_call and function_call_output
-	Name      string             `json:"name,omitempty"`      // for function_call
-	Arguments string             `json:"arguments,omitempty"` // for function_call
-	Output    string             `json:"output,omitempty"`    // for function_call_output
+	Type      string              `json:"type"`                        // "message", "function_call", "function_call_output"
+	Role      string              `json:"role,omitempty"`              // for messages: "user", "assistant"
+	Content   []responsesContent  `json:"content,omitempty"`           // for messages
+	CallID    string              `json:"call_id,omitempty"`           // for function_call and function_call_output
+	Name      string              `json:"name,omitempty"`              // for function_call
+	Arguments string              `json:"arguments,omitempty"`         // for function_call
+	Output    string              `json:"output,omitempty"`            // for function_call_output
+	ID        string              `json:"id,omitempty"`                // for reasoning
+	Summary   *[]responsesSummary `json:"summary,omitempty"`           // for reasoning; required, if empty
+	Encrypted string              `json:"encrypted_content,omitempty"` // for reasoning
+}
+
+type responsesSummary struct {
+	Type string `json:"type"` // "summary_text"
+	Text string `json:"text"`
 }
 
 type responsesContent struct {
@@ -85,11 +108,12 @@ type responsesOutputItem struct {
 	Type      string             `json:"type"`           // "message", "reasoning", "function_call"
 	Role      string             `json:"role,omitempty"` // for messages: "assistant"
 	Status    string             `json:"status,omitempty"`
-	Content   []responsesContent `json:"content,omitempty"`   // for messages
-	CallID    string             `json:"call_id,omitempty"`   // for function_call
-	Name      string             `json:"name,omitempty"`      // for function_call
-	Arguments string             `json:"arguments,omitempty"` // for function_call
-	Summary   []string           `json:"summary,omitempty"`   // for reasoning
+	Content   []responsesContent `json:"content,omitempty"`           // for messages
+	CallID    string             `json:"call_id,omitempty"`           // for function_call
+	Name      string             `json:"name,omitempty"`              // for function_call
+	Arguments string             `json:"arguments,omitempty"`         // for function_call
+	Summary   []responsesSummary `json:"summary,omitempty"`           // for reasoning
+	Encrypted string             `json:"encrypted_content,omitempty"` // for reasoning
 }
 
 type responsesUsage struct {
@@ -136,8 +160,8 @@ func fromLLMMessageResponses(msg llm.Message) []responsesInputItem {
 		// Collect all text from content objects
 		var texts []string
 		for _, result := range tr.ToolResult {
-			if strings.TrimSpace(result.Text) != "" {
-				texts = append(texts, result.Text)
+			if text := contentText(result); strings.TrimSpace(text) != "" {
+				texts = append(texts, text)
 			}
 		}
 		toolResultContent := strings.Join(texts, "\n")
@@ -165,15 +189,30 @@ func fromLLMMessageResponses(msg llm.Message) []responsesInputItem {
 
 		for _, c := range regularContent {
 			switch c.Type {
+			case llm.ContentTypeThinking:
+				// Only OpenAI's own reasoning items, which have IDs, go back,
+				// ahead of what they led to
+				if msg.Role == llm.MessageRoleAssistant && strings.HasPrefix(c.ID, "rs_") {
+					summary := []responsesSummary{}
+					if c.Thinking != "" {
+						summary = append(summary, responsesSummary{Type: "summary_text", Text: c.Thinking})
+					}
+					items = append(items, responsesInputItem{
+						Type:      "reasoning",
+						ID:        c.ID,
+						Summary:   &summary,
+						Encrypted: c.Data,
+					})
+				}
 			case llm.ContentTypeText:
-				if c.Text != "" {
+				if text := contentText(c); text != "" {
 					contentType := "input_text"
 					if msg.Role == llm.MessageRoleAssistant {
 						contentType = "output_text"
 					}
 					messageContent = append(messageContent, responsesContent{
 						Type: contentType,
-						Text: c.Text,
+						Text: text,
 					})
 				}
 			case llm.ContentTypeToolUse:
@@ -207,6 +246,14 @@ func fromLLMMessageResponses(msg llm.Message) []responsesInputItem {
 	return items
 }
 
+// withoutThinking returns msg without its thinking content.
+func withoutThinking(msg llm.Message) llm.Message {
+	msg.Content = slices.DeleteFunc(slices.Clone(msg.Content), func(c llm.Content) bool {
+		return c.Type == llm.ContentTypeThinking || c.Type == llm.ContentTypeRedactedThinking
+	})
+	return msg
+}
+
 // fromLLMToolResponses converts llm.Tool to Responses API tool format
 func fromLLMToolResponses(t *llm.Tool) responsesTool {
 	return responsesTool{
@@ -278,14 +325,18 @@ func (s *ResponsesService) toLLMResponseFromResponses(resp *responsesResponse, h
 				}
 			}
 		case "reasoning":
-			// Convert reasoning to thinking content
-			if len(item.Summary) > 0 {
-				summaryText := strings.Join(item.Summary, "\n")
-				contents = append(contents, llm.Content{
-					Type: llm.ContentTypeThinking,
-					Text: summaryText,
-				})
+			// Keep reasoning as thinking content, summary or not, so that
+			// it is passed back with the function calls it led to
+			var summary []string
+			for _, s := range item.Summary {
+				summary = append(summary, s.Text)
 			}
+			contents = append(contents, llm.Content{
+				ID:       item.ID,
+				Type:     llm.ContentTypeThinking,
+				Thinking: strings.Join(summary, "\n"),
+				Data:     item.Encrypted,
+			})
 		case "function_call":
 			// Convert function call to tool use
 			contents = append(contents, llm.Content{
@@ -330,6 +381,9 @@ func (s *ResponsesService) toLLMUsageFromResponses(usage responsesUsage, headers
 		CacheCreationInputTokens: in,
 		OutputTokens:             out,
 	}
+	if usage.OutputTokensDetails != nil {
+		u.ReasoningTokens = uint64(usage.OutputTokensDetails.ReasoningTokens)
+	}
 	u.CostUSD = llm.CostUSDFromResponse(headers)
 	return u
 }
@@ -351,6 +405,11 @@ func (s *ResponsesService) TokenContextWindow() int {
 	}
 }
 
+// Capabilities reports the model's capabilities and list price.
+func (s *ResponsesService) Capabilities() llm.Capabilities {
+	return modelCapabilities(cmp.Or(s.Model, DefaultModel))
+}
+
 // MaxImageDimension returns the maximum allowed image dimension.
 // TODO: determine actual OpenAI image dimension limits
 func (s *ResponsesService) MaxImageDimension() int {
@@ -369,8 +428,13 @@ func (s *ResponsesService) Do(ctx context.Context, ir *llm.Request) (*llm.Respon
 		allInput = append(allInput, sysItems...)
 	}
 
-	// Add regular messages
-	for _, msg := range ir.Messages {
+	// Add regular messages. Reasoning is only passed back within the turn
+	// it was made in; OpenAI drops it from earlier turns anyway.
+	turnStart := llm.TurnStart(ir.Messages)
+	for i, msg := range ir.Messages {
+		if i < turnStart {
+			msg = withoutThinking(msg)
+		}
 		items := fromLLMMessageResponses(msg)
 		allInput = append(allInput, items...)
 	}
@@ -389,6 +453,23 @@ func (s *ResponsesService) Do(ctx context.Context, ir *llm.Request) (*llm.Respon
 		MaxOutputTokens: cmp.Or(s.MaxTokens, DefaultMaxTokens),
 	}
 
+	if ir.Thinking != nil && model.reasons() {
+		req.Reasoning = &responsesReasoning{Effort: ir.Thinking.Level()}
+	}
+
+	if rf := ir.ResponseFormat; rf != nil {
+		req.Text = &responsesText{Format: responsesFormat{Type: "json_schema", Name: rf.Name, Schema: rf.Schema, Strict: true}}
+	}
+
+	// The Responses API has no stop sequences
+	if sampling := ir.Sampling; sampling != nil {
+		req.MaxOutputTokens = cmp.Or(sampling.MaxTokens, req.MaxOutputTokens)
+		if model.takesSamplingParameters() {
+			req.Temperature = sampling.Temperature
+			req.TopP = sampling.TopP
+		}
+	}
+
 	// Add tool choice if specified
 	if ir.ToolChoice != nil {
 		req.ToolChoice = fromLLMToolChoice(ir.ToolChoice)
@@ -453,6 +534,7 @@ func (s *ResponsesService) Do(ctx context.Context, ir *llm.Request) (*llm.Respon
 		if err != nil {
 			return nil, fmt.Errorf("failed to read response body: %w", err)
 		}
+		llm.RecordHTTP(ctx, llm.HTTPExchange{URL: fullURL, Request: reqJSON, Response: body, StatusCode: httpResp.StatusCode})
 
 		// Handle non-200 responses
 		if httpResp.StatusCode != http.StatusOK {
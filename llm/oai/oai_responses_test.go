@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"os"
+	"strings"
 	"testing"
 
 	"shelley.exe.dev/llm"
@@ -123,6 +124,29 @@ func TestFromLLMMessageResponses(t *testing.T) {
 	}
 }
 
+func TestDocumentFallback(t *testing.T) {
+	msg := llm.Message{
+		Role: llm.MessageRoleUser,
+		Content: []llm.Content{{
+			Type:      llm.ContentTypeToolResult,
+			ToolUseID: "call_123",
+			ToolResult: []llm.Content{
+				{Type: llm.ContentTypeText, Text: "Document from /tmp/spec.pdf"},
+				{Type: llm.ContentTypeText, MediaType: llm.DocumentMediaType, Data: "JVBERi0xLjQK"},
+			},
+		}},
+	}
+
+	items := fromLLMMessageResponses(msg)
+	if len(items) != 1 || !strings.Contains(items[0].Output, "Document from /tmp/spec.pdf") || !strings.Contains(items[0].Output, "can't read documents") {
+		t.Errorf("expected the document to be replaced by a note, got %+v", items)
+	}
+	messages := fromLLMMessage(msg)
+	if len(messages) != 1 || !strings.Contains(messages[0].Content, "can't read documents") {
+		t.Errorf("expected the document to be replaced by a note, got %+v", messages)
+	}
+}
+
 func TestFromLLMToolResponses(t *testing.T) {
 	tool := &llm.Tool{
 		Name:        "test_tool",
@@ -241,7 +265,7 @@ func TestToLLMResponseFromResponses(t *testing.T) {
 				Output: []responsesOutputItem{
 					{
 						Type:    "reasoning",
-						Summary: []string{"Let me think", "about this"},
+						Summary: []responsesSummary{{Type: "summary_text", Text: "Let me think"}, {Type: "summary_text", Text: "about this"}},
 					},
 					{
 						Type: "message",
@@ -277,6 +301,46 @@ func TestToLLMResponseFromResponses(t *testing.T) {
 	}
 }
 
+func TestResponsesReasoningRoundTrip(t *testing.T) {
+	svc := &ResponsesService{Model: GPT5Codex}
+	resp := svc.toLLMResponseFromResponses(&responsesResponse{
+		Output: []responsesOutputItem{
+			{Type: "reasoning", ID: "rs_1", Summary: []responsesSummary{{Type: "summary_text", Text: "Check the weather"}}, Encrypted: "opaque"},
+			{Type: "function_call", CallID: "call_1", Name: "get_weather", Arguments: `{}`},
+		},
+	}, nil)
+	thinking := resp.Content[0]
+	if thinking.Type != llm.ContentTypeThinking || thinking.ID != "rs_1" || thinking.Thinking != "Check the weather" || thinking.Data != "opaque" {
+		t.Fatalf("unexpected thinking content: %+v", thinking)
+	}
+
+	items := fromLLMMessageResponses(resp.ToMessage())
+	if len(items) != 2 {
+		t.Fatalf("expected reasoning and function call, got %d items", len(items))
+	}
+	if items[0].Type != "reasoning" || items[0].ID != "rs_1" || items[0].Encrypted != "opaque" {
+		t.Errorf("unexpected reasoning item: %+v", items[0])
+	}
+	if items[0].Summary == nil || len(*items[0].Summary) != 1 || (*items[0].Summary)[0].Text != "Check the weather" {
+		t.Errorf("unexpected reasoning summary: %+v", items[0].Summary)
+	}
+	if items[1].Type != "function_call" {
+		t.Errorf("expected function call after reasoning, got %q", items[1].Type)
+	}
+
+	// Thinking from other providers is not sent
+	items = fromLLMMessageResponses(llm.Message{
+		Role: llm.MessageRoleAssistant,
+		Content: []llm.Content{
+			{Type: llm.ContentTypeThinking, Thinking: "hmm", Signature: "sig"},
+			{Type: llm.ContentTypeText, Text: "Hi"},
+		},
+	})
+	if len(items) != 1 || items[0].Type != "message" {
+		t.Errorf("expected only the message, got %+v", items)
+	}
+}
+
 func TestResponsesServiceTokenContextWindow(t *testing.T) {
 	tests := []struct {
 		model    Model
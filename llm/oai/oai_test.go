@@ -1,6 +1,11 @@
 package oai
 
-import "testing"
+import (
+	"testing"
+
+	"github.com/sashabaranov/go-openai"
+	"shelley.exe.dev/llm"
+)
 
 func TestRequiresMaxCompletionTokens(t *testing.T) {
 	tests := []struct {
@@ -101,3 +106,34 @@ func TestRequestParameterGeneration(t *testing.T) {
 		})
 	}
 }
+
+func TestTakesSamplingParameters(t *testing.T) {
+	for _, tt := range []struct {
+		model    Model
+		expected bool
+	}{
+		{GPT41, true},
+		{GPT4oMini, true},
+		{Qwen3CoderFireworks, true},
+		{GPT5, false},
+		{GPT5Mini, false},
+		{O3, false},
+	} {
+		if got := tt.model.takesSamplingParameters(); got != tt.expected {
+			t.Errorf("%s: takesSamplingParameters() = %v, expected %v", tt.model.UserName, got, tt.expected)
+		}
+	}
+}
+
+func TestReasoningContent(t *testing.T) {
+	contents := toLLMContents(openai.ChatCompletionMessage{Role: "assistant", Content: "42", ReasoningContent: "6 times 7"})
+	if len(contents) != 2 || contents[0].Type != llm.ContentTypeThinking || contents[0].Thinking != "6 times 7" {
+		t.Fatalf("expected thinking before text, got %+v", contents)
+	}
+
+	// Reasoning is not sent back
+	msgs := fromLLMMessage(llm.Message{Role: llm.MessageRoleAssistant, Content: contents})
+	if len(msgs) != 1 || msgs[0].Content != "42" {
+		t.Errorf("expected only the text to be sent, got %+v", msgs)
+	}
+}
@@ -0,0 +1,20 @@
+package llm
+
+import "testing"
+
+func TestJSONText(t *testing.T) {
+	tests := []struct {
+		text, expected string
+	}{
+		{`{"slug": "a"}`, `{"slug": "a"}`},
+		{"  {\"slug\": \"a\"}\n", `{"slug": "a"}`},
+		{"```json\n{\"slug\": \"a\"}\n```", `{"slug": "a"}`},
+		{"```\n{}\n```", `{}`},
+	}
+	for _, tt := range tests {
+		resp := &Response{Content: []Content{{Type: ContentTypeThinking, Thinking: "hmm"}, {Type: ContentTypeText, Text: tt.text}}}
+		if got := resp.JSONText(); got != tt.expected {
+			t.Errorf("JSONText() of %q = %q, expected %q", tt.text, got, tt.expected)
+		}
+	}
+}
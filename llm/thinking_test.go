@@ -0,0 +1,32 @@
+package llm
+
+import "testing"
+
+func TestThinking(t *testing.T) {
+	tests := []struct {
+		thinking Thinking
+		budget   int
+		level    string
+		valid    bool
+	}{
+		{Thinking{Effort: "low"}, 4096, "low", true},
+		{Thinking{Effort: "high"}, 32768, "high", true},
+		{Thinking{BudgetTokens: 2000}, 2000, "low", true},
+		{Thinking{BudgetTokens: 20000}, 20000, "medium", true},
+		{Thinking{Effort: "low", BudgetTokens: 50000}, 50000, "low", true},
+		{Thinking{BudgetTokens: 100}, 100, "low", false},
+		{Thinking{Effort: "max"}, 16384, "max", false},
+		{Thinking{}, 16384, "low", false},
+	}
+	for _, tt := range tests {
+		if got := tt.thinking.Budget(); got != tt.budget {
+			t.Errorf("%+v: Budget() = %d, want %d", tt.thinking, got, tt.budget)
+		}
+		if got := tt.thinking.Level(); got != tt.level {
+			t.Errorf("%+v: Level() = %q, want %q", tt.thinking, got, tt.level)
+		}
+		if err := tt.thinking.Validate(); (err == nil) != tt.valid {
+			t.Errorf("%+v: Validate() = %v, want valid %v", tt.thinking, err, tt.valid)
+		}
+	}
+}
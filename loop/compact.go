@@ -0,0 +1,319 @@
+package loop
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"shelley.exe.dev/llm"
+)
+
+// compactionThreshold is the fraction of the model's context window at which
+// the loop summarizes the conversation, at the end of a turn or before a
+// request in the middle of one.
+const compactionThreshold = 0.8
+
+// keptShare is the fraction of the model's context window the most recent
+// turns may take to be kept word for word when the rest is summarized.
+const keptShare = 0.25
+
+// charsPerToken is roughly how many characters of text make a token.
+const charsPerToken = 4
+
+// maxTranscriptToolText limits how much of a single tool input or result is
+// included in the transcript handed to the summarizer.
+const maxTranscriptToolText = 2000
+
+// SummaryPrompt is the instruction sent to the summary model, followed by the transcript.
+const SummaryPrompt = `Summarize the following conversation between a user and an AI coding agent so that the agent can continue the work without the original messages.
+
+Include:
+- The user's goals and any explicit instructions or preferences
+- Key decisions, findings, and the current state of the work
+- Files, commands, and identifiers that matter going forward
+- Anything left unfinished
+
+Respond with only the summary.`
+
+// SummaryPrefix starts the text of every summary message placed into history.
+const SummaryPrefix = "[Earlier messages in this conversation were compacted. Summary of the conversation so far:]"
+
+// summaryRequestPrefix introduces the request that started the current
+// turn, when the turn is too long to keep and is summarized too.
+const summaryRequestPrefix = "[The user's latest request, word for word:]"
+
+// ErrLoopBusy is returned by Compact when a turn is in progress.
+var ErrLoopBusy = errors.New("conversation loop is busy")
+
+// ErrNothingToCompact is returned by Compact when there is no history to summarize.
+var ErrNothingToCompact = errors.New("nothing to compact")
+
+// Compact summarizes the conversation history with the summary model and
+// replaces the in-memory history with a summary message followed by the most
+// recent turns, which are kept word for word. The summary is recorded with
+// Config.RecordSummary; the original messages are left untouched in storage.
+// Compact only runs between turns.
+func (l *Loop) Compact(ctx context.Context) error {
+	l.mu.Lock()
+	if l.processing || len(l.messageQueue) > 0 {
+		l.mu.Unlock()
+		return ErrLoopBusy
+	}
+	l.processing = true
+	l.mu.Unlock()
+
+	defer func() {
+		l.mu.Lock()
+		l.processing = false
+		l.mu.Unlock()
+	}()
+	return l.compact(ctx)
+}
+
+// compact does the work of Compact, in a turn or between turns; the caller
+// keeps other turns and compactions from running.
+func (l *Loop) compact(ctx context.Context) error {
+	l.mu.Lock()
+	history := append([]llm.Message(nil), l.history...)
+	window := l.llm.TokenContextWindow()
+	summaryLLM := l.summaryLLM
+	if summaryLLM == nil {
+		summaryLLM = l.llm
+	}
+	l.mu.Unlock()
+
+	if len(history) == 0 || (len(history) == 1 && isSummaryMessage(history[0])) {
+		return ErrNothingToCompact
+	}
+	if l.recordSummary == nil {
+		return fmt.Errorf("no summary recorder configured")
+	}
+
+	split := keptTurnsStart(history, int(float64(window)*keptShare))
+	if split == 1 && isSummaryMessage(history[0]) {
+		// Summarizing the summary alone wouldn't make the history shorter
+		split = len(history)
+	}
+	kept := history[split:]
+	// If no turn is kept, say because the current one is too long, the
+	// request that started the last turn is kept in the summary
+	var request *llm.Message
+	if i := llm.TurnStart(history); len(kept) == 0 && i >= 0 && !isSummaryMessage(history[i]) {
+		request = &history[i]
+	}
+
+	// Leave half the summary model's window for the prompt and output.
+	transcript := buildTranscript(history[:split], summaryLLM.TokenContextWindow()*charsPerToken/2)
+	req := &llm.Request{
+		Messages: []llm.Message{{
+			Role: llm.MessageRoleUser,
+			Content: []llm.Content{
+				{Type: llm.ContentTypeText, Text: SummaryPrompt + "\n\n<transcript>\n" + transcript + "</transcript>"},
+			},
+		}},
+	}
+
+	summaryCtx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+	defer cancel()
+
+	resp, err := summaryLLM.Do(summaryCtx, req)
+	if err != nil {
+		return fmt.Errorf("failed to summarize conversation: %w", err)
+	}
+
+	var summary strings.Builder
+	for _, c := range resp.Content {
+		if c.Type == llm.ContentTypeText {
+			summary.WriteString(c.Text)
+		}
+	}
+	if strings.TrimSpace(summary.String()) == "" {
+		return fmt.Errorf("summary model returned an empty summary")
+	}
+
+	summaryMessage := llm.Message{
+		Role: llm.MessageRoleUser,
+		Content: []llm.Content{
+			{Type: llm.ContentTypeText, Text: SummaryPrefix + "\n\n" + strings.TrimSpace(summary.String())},
+		},
+	}
+	if request != nil {
+		summaryMessage.Content = append(summaryMessage.Content, llm.StringContent(summaryRequestPrefix))
+		summaryMessage.Content = append(summaryMessage.Content, request.Content...)
+		summaryMessage.Thinking = request.Thinking
+	}
+
+	usage := resp.Usage
+	usage.Model = resp.Model
+	usage.StartTime = resp.StartTime
+	usage.EndTime = resp.EndTime
+	if err := l.recordSummary(ctx, summaryMessage, usage, len(kept)); err != nil {
+		return fmt.Errorf("failed to record summary: %w", err)
+	}
+
+	l.mu.Lock()
+	l.history = append([]llm.Message{summaryMessage}, kept...)
+	l.contextSize = 0
+	l.mu.Unlock()
+
+	l.logger.Info("compacted conversation history", "messages", split, "kept", len(kept), "summary_length", summary.Len())
+	return nil
+}
+
+// MaybeCompact compacts the history if the last response used most of the context window.
+// Go calls it after each turn; callers driving turns with ProcessOneTurn may call it themselves.
+func (l *Loop) MaybeCompact(ctx context.Context) {
+	l.mu.Lock()
+	contextSize := l.contextSize
+	window := l.llm.TokenContextWindow()
+	l.mu.Unlock()
+
+	if !nearlyFull(contextSize, window) {
+		return
+	}
+
+	l.logger.Info("context window nearly full, compacting", "context_size", contextSize, "window", window)
+	if err := l.Compact(ctx); err != nil && !errors.Is(err, ErrLoopBusy) {
+		l.logger.Error("failed to compact conversation", "error", err)
+	}
+}
+
+// compactInTurn compacts the history before a request in the middle of a
+// turn if the request would use most of the context window: what the last
+// response used, plus an estimate of what was added since, such as tool
+// results.
+func (l *Loop) compactInTurn(ctx context.Context) {
+	l.mu.Lock()
+	since := 0
+	if l.contextSize > 0 {
+		since = lastResponse(l.history) + 1
+	}
+	size := l.contextSize + uint64(estimateTokens(l.history[since:]))
+	window := l.llm.TokenContextWindow()
+	l.mu.Unlock()
+
+	if !nearlyFull(size, window) {
+		return
+	}
+
+	l.logger.Info("context window nearly full in turn, compacting", "estimated_size", size, "window", window)
+	if err := l.compact(ctx); err != nil && !errors.Is(err, ErrNothingToCompact) {
+		l.logger.Error("failed to compact conversation", "error", err)
+	}
+}
+
+// nearlyFull reports whether size tokens use enough of a context window of
+// window tokens to compact.
+func nearlyFull(size uint64, window int) bool {
+	return window > 0 && size >= uint64(float64(window)*compactionThreshold)
+}
+
+// lastResponse returns the index of the last assistant message of history,
+// or -1.
+func lastResponse(history []llm.Message) int {
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].Role == llm.MessageRoleAssistant {
+			return i
+		}
+	}
+	return -1
+}
+
+// keptTurnsStart returns the index of the first message of the most recent
+// turns of history that together take at most budget tokens, leaving at
+// least one turn to summarize, or len(history) if not even the last turn
+// fits.
+func keptTurnsStart(history []llm.Message, budget int) int {
+	start := len(history)
+	for i := llm.TurnStart(history); i > 0; i = llm.TurnStart(history[:i]) {
+		if estimateTokens(history[i:]) > budget {
+			break
+		}
+		start = i
+	}
+	return start
+}
+
+// estimateTokens roughly estimates how many tokens msgs take, from the
+// length of their text, tool calls and tool results.
+func estimateTokens(msgs []llm.Message) int {
+	var n int
+	for _, msg := range msgs {
+		for _, c := range msg.Content {
+			n += contentLen(c)
+		}
+	}
+	return n / charsPerToken
+}
+
+func contentLen(c llm.Content) int {
+	n := len(c.Text) + len(c.Thinking) + len(c.ToolInput)
+	for _, r := range c.ToolResult {
+		n += contentLen(r)
+	}
+	return n
+}
+
+// isSummaryMessage reports whether msg was produced by Compact.
+func isSummaryMessage(msg llm.Message) bool {
+	return msg.Role == llm.MessageRoleUser && len(msg.Content) > 0 &&
+		strings.HasPrefix(msg.Content[0].Text, SummaryPrefix)
+}
+
+// buildTranscript renders history as plain text for the summary model.
+// If the transcript exceeds maxLen bytes, the oldest part is dropped.
+func buildTranscript(history []llm.Message, maxLen int) string {
+	var b strings.Builder
+	for _, msg := range history {
+		for _, c := range msg.Content {
+			switch c.Type {
+			case llm.ContentTypeText:
+				if msg.Role == llm.MessageRoleUser {
+					fmt.Fprintf(&b, "User: %s\n\n", c.Text)
+				} else {
+					fmt.Fprintf(&b, "Agent: %s\n\n", c.Text)
+				}
+			case llm.ContentTypeToolUse:
+				fmt.Fprintf(&b, "Agent called %s: %s\n\n", c.ToolName, truncateText(string(c.ToolInput), maxTranscriptToolText))
+			case llm.ContentTypeToolResult:
+				var result strings.Builder
+				for _, r := range c.ToolResult {
+					if r.Type == llm.ContentTypeText {
+						result.WriteString(r.Text)
+					}
+				}
+				label := "Tool result"
+				if c.ToolError {
+					label = "Tool error"
+				}
+				fmt.Fprintf(&b, "%s: %s\n\n", label, truncateText(result.String(), maxTranscriptToolText))
+			}
+		}
+	}
+
+	transcript := b.String()
+	if maxLen > 0 && len(transcript) > maxLen {
+		// Cut between characters
+		start := len(transcript) - maxLen
+		for start < len(transcript) && !utf8.RuneStart(transcript[start]) {
+			start++
+		}
+		transcript = "[...earlier messages omitted...]\n\n" + transcript[start:]
+	}
+	return transcript
+}
+
+// truncateText returns at most the first n bytes of s, cut between
+// characters, marked as truncated.
+func truncateText(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n] + "... [truncated]"
+}
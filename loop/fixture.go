@@ -0,0 +1,187 @@
+package loop
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+
+	"shelley.exe.dev/llm"
+)
+
+// A fixture is a file of LLM requests and the responses a real provider
+// gave them. A PredictableService in record mode passes requests to the
+// provider and writes each exchange to the fixture; in replay mode it
+// answers from the fixture instead, so tests and bug reproductions that
+// were recorded against a provider run without its API key.
+
+// Fixture is the content of a fixture file.
+type Fixture struct {
+	Exchanges []FixtureExchange `json:"exchanges"`
+}
+
+// FixtureExchange is a recorded request and the provider's response, or
+// the error it failed with.
+type FixtureExchange struct {
+	Request  FixtureRequest `json:"request"`
+	Response *llm.Response  `json:"response,omitempty"`
+	Error    string         `json:"error,omitempty"`
+}
+
+// FixtureRequest is a recorded request. Tools are recorded by name.
+type FixtureRequest struct {
+	System   []llm.SystemContent `json:"system,omitempty"`
+	Messages []llm.Message       `json:"messages"`
+	Tools    []string            `json:"tools,omitempty"`
+}
+
+// fixture is the record or replay state of a PredictableService.
+type fixture struct {
+	path string
+	// upstream is the provider requests are recorded from; nil when
+	// replaying.
+	upstream llm.Service
+
+	mu        sync.Mutex
+	exchanges []FixtureExchange
+	used      []bool
+}
+
+// NewRecordingService returns a PredictableService in record mode: it
+// passes requests to upstream and writes each request and response to
+// the fixture file at path, replacing what was there.
+func NewRecordingService(upstream llm.Service, path string) *PredictableService {
+	svc := NewPredictableService()
+	svc.tokenContextWindow = upstream.TokenContextWindow()
+	svc.fixture = &fixture{path: path, upstream: upstream}
+	return svc
+}
+
+// NewReplayService returns a PredictableService in replay mode: it
+// answers requests with the responses recorded in the fixture file at
+// path. A request gets the first unused response recorded for a request
+// ending with the same text, or else the next unused response, so
+// replay holds up when volatile content like temporary paths differs
+// from the recording.
+func NewReplayService(path string) (*PredictableService, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f Fixture
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("invalid fixture %s: %w", path, err)
+	}
+	svc := NewPredictableService()
+	svc.fixture = &fixture{path: path, exchanges: f.Exchanges, used: make([]bool, len(f.Exchanges))}
+	return svc, nil
+}
+
+// do answers a request by recording or replaying it.
+func (f *fixture) do(ctx context.Context, req *llm.Request) (*llm.Response, error) {
+	if f.upstream == nil {
+		return f.replay(req)
+	}
+	resp, err := f.upstream.Do(ctx, req)
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		// Cancellations are the caller's doing, not the provider's
+		return resp, err
+	}
+	exchange := FixtureExchange{Request: fixtureRequest(req), Response: resp}
+	if err != nil {
+		exchange.Error = err.Error()
+	}
+	// Keep a deep copy, since the caller may go on to change the request
+	recorded, merr := json.Marshal(exchange)
+	if merr == nil {
+		exchange = FixtureExchange{}
+		merr = json.Unmarshal(recorded, &exchange)
+	}
+	if merr != nil {
+		return nil, fmt.Errorf("failed to encode fixture: %w", merr)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.exchanges = append(f.exchanges, exchange)
+	data, merr := json.MarshalIndent(Fixture{Exchanges: f.exchanges}, "", "  ")
+	if merr != nil {
+		return nil, fmt.Errorf("failed to encode fixture: %w", merr)
+	}
+	if werr := os.WriteFile(f.path, append(data, '\n'), 0o644); werr != nil {
+		return nil, fmt.Errorf("failed to write fixture: %w", werr)
+	}
+	return resp, err
+}
+
+// replay returns the recorded response for req.
+func (f *fixture) replay(req *llm.Request) (*llm.Response, error) {
+	key := lastText(req.Messages)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	i := -1
+	for j, exchange := range f.exchanges {
+		if !f.used[j] && lastText(exchange.Request.Messages) == key {
+			i = j
+			break
+		}
+	}
+	if i < 0 {
+		i = slices.Index(f.used, false)
+	}
+	if i < 0 {
+		return nil, fmt.Errorf("fixture %s has no response left for %q", f.path, truncate(key, 80))
+	}
+	f.used[i] = true
+
+	exchange := f.exchanges[i]
+	if exchange.Error != "" {
+		return nil, errors.New(exchange.Error)
+	}
+	resp := *exchange.Response
+	resp.Content = slices.Clone(resp.Content)
+	return &resp, nil
+}
+
+// fixtureRequest is req as recorded.
+func fixtureRequest(req *llm.Request) FixtureRequest {
+	r := FixtureRequest{System: req.System, Messages: req.Messages}
+	for _, tool := range req.Tools {
+		r.Tools = append(r.Tools, tool.Name)
+	}
+	return r
+}
+
+// lastText returns the text of the last message's text content and tool
+// results.
+func lastText(messages []llm.Message) string {
+	if len(messages) == 0 {
+		return ""
+	}
+	var texts []string
+	for _, c := range messages[len(messages)-1].Content {
+		switch c.Type {
+		case llm.ContentTypeText:
+			texts = append(texts, c.Text)
+		case llm.ContentTypeToolResult:
+			for _, result := range c.ToolResult {
+				if result.Type == llm.ContentTypeText {
+					texts = append(texts, result.Text)
+				}
+			}
+		}
+	}
+	return strings.TrimSpace(strings.Join(texts, "\n"))
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
@@ -0,0 +1,50 @@
+package loop
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"shelley.exe.dev/llm"
+)
+
+func TestFixtureRecordReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fixture.json")
+	ctx := context.Background()
+	request := func(text string) *llm.Request {
+		return &llm.Request{
+			Messages: []llm.Message{llm.UserStringMessage(text)},
+			Tools:    []*llm.Tool{{Name: "bash"}},
+		}
+	}
+
+	// The upstream stands in for a real provider
+	recorder := NewRecordingService(NewPredictableService(), path)
+	for _, text := range []string{"echo: one", "bash: ls", "error: overloaded", "echo: two"} {
+		recorder.Do(ctx, request(text))
+	}
+
+	replayer, err := NewReplayService(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Requests are matched by their text, whatever the order
+	resp, err := replayer.Do(ctx, request("bash: ls"))
+	if err != nil || len(resp.Content) != 2 || resp.Content[1].ToolName != "bash" {
+		t.Fatalf("expected the recorded bash call, got %+v, %v", resp, err)
+	}
+	if _, err := replayer.Do(ctx, request("error: overloaded")); err == nil || !strings.Contains(err.Error(), "overloaded") {
+		t.Errorf("expected the recorded error, got %v", err)
+	}
+	// Unmatched requests get the next unused response
+	if resp, err := replayer.Do(ctx, request("echo: changed")); err != nil || resp.Content[0].Text != "one" {
+		t.Errorf("expected the first unused response, got %+v, %v", resp, err)
+	}
+	if resp, err := replayer.Do(ctx, request("echo: two")); err != nil || resp.Content[0].Text != "two" {
+		t.Errorf("expected the recorded response, got %+v, %v", resp, err)
+	}
+	if _, err := replayer.Do(ctx, request("echo: one")); err == nil {
+		t.Error("expected an error once the fixture is used up")
+	}
+}
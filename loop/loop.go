@@ -1,7 +1,9 @@
 package loop
 
 import (
+	"cmp"
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"strings"
@@ -16,6 +18,11 @@ import (
 // MessageRecordFunc is called to record new messages to persistent storage
 type MessageRecordFunc func(ctx context.Context, message llm.Message, usage llm.Usage) error
 
+// SummaryRecordFunc records the summary message produced by compaction. The
+// summary replaces the history before the last kept messages, which are
+// kept word for word after it.
+type SummaryRecordFunc func(ctx context.Context, message llm.Message, usage llm.Usage, kept int) error
+
 // GitStateChangeFunc is called when the git state changes at the end of a turn.
 // This is used to record user-visible notifications about git changes.
 type GitStateChangeFunc func(ctx context.Context, state *gitstate.GitState)
@@ -35,6 +42,22 @@ type Config struct {
 	// If set, this is called at end of turn to check for git state changes.
 	// If nil, Config.WorkingDir is used as a static value.
 	GetWorkingDir func() string
+	// GetGitState returns the git state of a directory, if the tools run
+	// somewhere gitstate.GetGitState can't see. If nil, that is used.
+	GetGitState func(dir string) *gitstate.GitState
+	// SummaryLLM is used to summarize history when compacting.
+	// If nil, LLM is used.
+	SummaryLLM llm.Service
+	// RecordSummary records the summary message produced by compaction.
+	RecordSummary SummaryRecordFunc
+	// Setup, if set, is called when the loop starts, before it processes
+	// any message. Text it returns is added to the system prompt.
+	Setup func(ctx context.Context) string
+	// Sampling, if set, is sent with each request to the LLM.
+	Sampling *llm.Sampling
+	// Thinking, if set, is sent with each request to the LLM, unless the
+	// user message starting the turn has its own.
+	Thinking *llm.Thinking
 }
 
 // Loop manages a conversation turn with an LLM including tool execution and message recording.
@@ -54,9 +77,22 @@ type Loop struct {
 	onGitStateChange GitStateChangeFunc
 	getWorkingDir    func() string
 	lastGitState     *gitstate.GitState
+	getGitState      func(dir string) *gitstate.GitState
 	resumeRequested  bool
+	summaryLLM       llm.Service
+	recordSummary    SummaryRecordFunc
+	setup            func(ctx context.Context) string
+	sampling         *llm.Sampling
+	thinking         *llm.Thinking
+	contextSize      uint64 // context window used by the most recent response
+	processing       bool   // a turn or compaction is in progress
+	// runningTools cancels the running tool calls, by tool use ID
+	runningTools map[string]context.CancelCauseFunc
 }
 
+// errToolCancelled is the result of a tool call cancelled with CancelTool.
+var errToolCancelled = errors.New("tool execution cancelled by user")
+
 // NewLoop creates a new Loop instance with the provided configuration
 func NewLoop(config Config) *Loop {
 	logger := config.Logger
@@ -69,7 +105,11 @@ func NewLoop(config Config) *Loop {
 	if config.GetWorkingDir != nil {
 		workingDir = config.GetWorkingDir()
 	}
-	initialGitState := gitstate.GetGitState(workingDir)
+	getGitState := config.GetGitState
+	if getGitState == nil {
+		getGitState = gitstate.GetGitState
+	}
+	initialGitState := getGitState(workingDir)
 
 	return &Loop{
 		llm:              config.LLM,
@@ -84,6 +124,12 @@ func NewLoop(config Config) *Loop {
 		onGitStateChange: config.OnGitStateChange,
 		getWorkingDir:    config.GetWorkingDir,
 		lastGitState:     initialGitState,
+		getGitState:      getGitState,
+		summaryLLM:       config.SummaryLLM,
+		recordSummary:    config.RecordSummary,
+		sampling:         config.Sampling,
+		thinking:         config.Thinking,
+		setup:            config.Setup,
 	}
 }
 
@@ -105,6 +151,58 @@ func (l *Loop) TriggerResume() {
 	l.logger.Info("resume requested for interrupted conversation")
 }
 
+// CancelTool cancels a running tool call, which ends with an error result
+// while the turn carries on. It reports whether the call was running.
+func (l *Loop) CancelTool(toolUseID string) bool {
+	l.mu.Lock()
+	cancel, ok := l.runningTools[toolUseID]
+	l.mu.Unlock()
+	if ok {
+		l.logger.Info("cancelling tool call", "id", toolUseID)
+		cancel(errToolCancelled)
+	}
+	return ok
+}
+
+// SetTools replaces the tools offered to the LLM, starting with its next request.
+func (l *Loop) SetTools(tools []*llm.Tool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.tools = tools
+}
+
+// SetSampling replaces the sampling parameters, starting with the next
+// request.
+func (l *Loop) SetSampling(sampling *llm.Sampling) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.sampling = sampling
+}
+
+// SetThinking replaces the thinking asked for, starting with the next
+// request.
+func (l *Loop) SetThinking(thinking *llm.Thinking) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.thinking = thinking
+}
+
+// turnThinking returns the thinking of the user message that started the
+// current turn, or else thinking.
+func turnThinking(messages []llm.Message, thinking *llm.Thinking) *llm.Thinking {
+	if i := llm.TurnStart(messages); i >= 0 {
+		return cmp.Or(messages[i].Thinking, thinking)
+	}
+	return thinking
+}
+
+// AddSystem appends text to the system prompt, starting with the next request.
+func (l *Loop) AddSystem(text string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.system = append(l.system, llm.SystemContent{Type: "text", Text: text})
+}
+
 // GetUsage returns the total usage accumulated by this loop
 func (l *Loop) GetUsage() llm.Usage {
 	l.mu.Lock()
@@ -139,6 +237,12 @@ func (l *Loop) Go(ctx context.Context) error {
 
 	l.logger.Info("starting conversation loop", "tools", len(l.tools))
 
+	if l.setup != nil {
+		if text := l.setup(ctx); text != "" {
+			l.AddSystem(text)
+		}
+	}
+
 	for {
 		select {
 		case <-ctx.Done():
@@ -147,11 +251,16 @@ func (l *Loop) Go(ctx context.Context) error {
 		default:
 		}
 
-		// Process any queued messages or resume requests
+		// Process any queued messages or resume requests, unless a compaction is running
 		l.mu.Lock()
-		hasQueuedMessages := len(l.messageQueue) > 0
-		resumeRequested := l.resumeRequested
-		l.resumeRequested = false
+		hasQueuedMessages := len(l.messageQueue) > 0 && !l.processing
+		resumeRequested := l.resumeRequested && !l.processing
+		if resumeRequested {
+			l.resumeRequested = false
+		}
+		if hasQueuedMessages || resumeRequested {
+			l.processing = true
+		}
 		if hasQueuedMessages {
 			// Add queued messages to history (they are already recorded to DB by ConversationManager)
 			for _, msg := range l.messageQueue {
@@ -168,7 +277,11 @@ func (l *Loop) Go(ctx context.Context) error {
 			} else {
 				l.logger.Debug("processing queued messages", "count", 1)
 			}
-			if err := l.processLLMRequest(ctx); err != nil {
+			err := l.processLLMRequest(ctx)
+			l.mu.Lock()
+			l.processing = false
+			l.mu.Unlock()
+			if err != nil {
 				l.logger.Error("failed to process LLM request", "error", err)
 				time.Sleep(time.Second) // Wait before retrying
 				continue
@@ -178,6 +291,7 @@ func (l *Loop) Go(ctx context.Context) error {
 			} else {
 				l.logger.Debug("finished processing queued messages")
 			}
+			l.MaybeCompact(ctx)
 		} else {
 			// No queued messages, wait a bit
 			select {
@@ -214,10 +328,14 @@ func (l *Loop) ProcessOneTurn(ctx context.Context) error {
 
 // processLLMRequest sends a request to the LLM and handles the response
 func (l *Loop) processLLMRequest(ctx context.Context) error {
+	l.compactInTurn(ctx)
+
 	l.mu.Lock()
 	messages := append([]llm.Message(nil), l.history...)
 	tools := l.tools
 	system := l.system
+	sampling := l.sampling
+	thinking := turnThinking(l.history, l.thinking)
 	llmService := l.llm
 	l.mu.Unlock()
 
@@ -250,6 +368,8 @@ func (l *Loop) processLLMRequest(ctx context.Context) error {
 		Messages: messages,
 		Tools:    tools,
 		System:   system,
+		Sampling: sampling,
+		Thinking: thinking,
 	}
 
 	// Insert missing tool results if the previous message had tool_use blocks
@@ -267,6 +387,7 @@ func (l *Loop) processLLMRequest(ctx context.Context) error {
 	llmCtx, cancel := context.WithTimeout(ctx, 5*time.Minute)
 	defer cancel()
 
+	requestStart := time.Now()
 	resp, err := llmService.Do(llmCtx, req)
 	if err != nil {
 		// Check if this is a "model does not exist" error and we have a fallback
@@ -276,6 +397,7 @@ func (l *Loop) processLLMRequest(ctx context.Context) error {
 			// Try with fallback LLM
 			resp, err = l.fallbackLLM.Do(llmCtx, req)
 			if err == nil {
+				llmService = l.fallbackLLM
 				// Fallback succeeded, switch to fallback LLM for future requests
 				l.mu.Lock()
 				l.llm = l.fallbackLLM
@@ -307,6 +429,7 @@ func (l *Loop) processLLMRequest(ctx context.Context) error {
 	// Update total usage
 	l.mu.Lock()
 	l.totalUsage.Add(resp.Usage)
+	l.contextSize = resp.Usage.ContextWindowUsed()
 	l.mu.Unlock()
 
 	// Convert response to message and add to history
@@ -320,6 +443,15 @@ func (l *Loop) processLLMRequest(ctx context.Context) error {
 	usageWithMeta.Model = resp.Model
 	usageWithMeta.StartTime = resp.StartTime
 	usageWithMeta.EndTime = resp.EndTime
+	// Not every provider times its requests; the conversation timeline
+	// needs every call timed
+	if usageWithMeta.StartTime == nil || usageWithMeta.EndTime == nil {
+		requestEnd := time.Now()
+		usageWithMeta.StartTime, usageWithMeta.EndTime = &requestStart, &requestEnd
+	}
+	if thinking != nil && llm.ModelCapabilities(llmService).Thinking {
+		usageWithMeta.Thinking = thinking
+	}
 	if err := l.recordMessage(ctx, assistantMessage, usageWithMeta); err != nil {
 		l.logger.Error("failed to record assistant message", "error", err)
 	}
@@ -356,7 +488,7 @@ func (l *Loop) checkGitStateChange(ctx context.Context) {
 	}
 
 	// Get current git state
-	currentState := gitstate.GetGitState(workingDir)
+	currentState := l.getGitState(workingDir)
 
 	// Compare with last known state
 	l.mu.Lock()
@@ -413,6 +545,9 @@ func (l *Loop) handleMaxTokensTruncation(ctx context.Context) error {
 // handleToolCalls processes tool calls from the LLM response
 func (l *Loop) handleToolCalls(ctx context.Context, content []llm.Content) error {
 	var toolResults []llm.Content
+	l.mu.Lock()
+	tools := l.tools
+	l.mu.Unlock()
 
 	for _, c := range content {
 		if c.Type != llm.ContentTypeToolUse {
@@ -423,7 +558,7 @@ func (l *Loop) handleToolCalls(ctx context.Context, content []llm.Content) error
 
 		// Find the tool
 		var tool *llm.Tool
-		for _, t := range l.tools {
+		for _, t := range tools {
 			if t.Name == c.ToolName {
 				tool = t
 				break
@@ -444,13 +579,33 @@ func (l *Loop) handleToolCalls(ctx context.Context, content []llm.Content) error
 		}
 
 		// Execute the tool with working directory set in context
-		toolCtx := ctx
+		toolCtx := claudetool.WithToolUseID(ctx, c.ID)
 		if l.workingDir != "" {
-			toolCtx = claudetool.WithWorkingDir(ctx, l.workingDir)
+			toolCtx = claudetool.WithWorkingDir(toolCtx, l.workingDir)
 		}
+		toolCtx, cancelTool := context.WithCancelCause(toolCtx)
+		l.mu.Lock()
+		if l.runningTools == nil {
+			l.runningTools = make(map[string]context.CancelCauseFunc)
+		}
+		l.runningTools[c.ID] = cancelTool
+		l.mu.Unlock()
 		startTime := time.Now()
 		result := tool.Run(toolCtx, c.ToolInput)
 		endTime := time.Now()
+		l.mu.Lock()
+		delete(l.runningTools, c.ID)
+		l.mu.Unlock()
+		if errors.Is(context.Cause(toolCtx), errToolCancelled) {
+			// Whatever the tool made of its cancellation, the model should
+			// know the user interrupted it
+			if result.Error != nil {
+				result.Error = fmt.Errorf("%w: %w", errToolCancelled, result.Error)
+			} else {
+				result.Error = errToolCancelled
+			}
+		}
+		cancelTool(nil)
 
 		var toolResultContent []llm.Content
 		if result.Error != nil {
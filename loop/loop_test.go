@@ -7,10 +7,12 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
 	"testing"
 	"time"
+	"unicode/utf8"
 
 	"shelley.exe.dev/claudetool"
 	"shelley.exe.dev/gitstate"
@@ -329,6 +331,86 @@ func TestLoopWithTools(t *testing.T) {
 	}
 }
 
+func TestCancelTool(t *testing.T) {
+	started := make(chan string, 1)
+	testTool := &llm.Tool{
+		Name:        "bash",
+		Description: "A test bash tool that hangs",
+		InputSchema: llm.MustSchema(`{"type": "object", "properties": {"command": {"type": "string"}}}`),
+		Run: func(ctx context.Context, input json.RawMessage) llm.ToolOut {
+			started <- claudetool.ToolUseID(ctx)
+			<-ctx.Done()
+			return llm.ToolOut{Error: ctx.Err()}
+		},
+	}
+
+	var mu sync.Mutex
+	var recorded []llm.Message
+	loop := NewLoop(Config{
+		LLM:     NewPredictableService(),
+		History: []llm.Message{},
+		Tools:   []*llm.Tool{testTool},
+		RecordMessage: func(ctx context.Context, message llm.Message, usage llm.Usage) error {
+			mu.Lock()
+			defer mu.Unlock()
+			recorded = append(recorded, message)
+			return nil
+		},
+	})
+	loop.QueueUserMessage(llm.Message{
+		Role:    llm.MessageRoleUser,
+		Content: []llm.Content{{Type: llm.ContentTypeText, Text: "bash: npm install"}},
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	go loop.Go(ctx)
+
+	var id string
+	select {
+	case id = <-started:
+	case <-ctx.Done():
+		t.Fatal("tool never started")
+	}
+	if loop.CancelTool("nope") {
+		t.Error("cancelled a tool call that isn't running")
+	}
+	if !loop.CancelTool(id) {
+		t.Fatal("expected the running tool call to be cancelled")
+	}
+
+	// The turn goes on: the model gets the interrupted result and answers it
+	for {
+		mu.Lock()
+		n := len(recorded)
+		done := n > 0 && recorded[n-1].EndOfTurn
+		mu.Unlock()
+		if done {
+			break
+		}
+		if ctx.Err() != nil {
+			t.Fatal("turn didn't end after the tool call was cancelled")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	var result *llm.Content
+	for _, msg := range recorded {
+		for i, c := range msg.Content {
+			if c.Type == llm.ContentTypeToolResult {
+				result = &msg.Content[i]
+			}
+		}
+	}
+	if result == nil || !result.ToolError || result.ToolUseID != id {
+		t.Fatalf("expected an error result for %s, got %+v", id, result)
+	}
+	if text := result.ToolResult[0].Text; !strings.Contains(text, "cancelled by user") {
+		t.Errorf("expected the result to say the user cancelled it, got %q", text)
+	}
+}
+
 func TestGetHistory(t *testing.T) {
 	initialHistory := []llm.Message{
 		{Role: llm.MessageRoleUser, Content: []llm.Content{{Type: llm.ContentTypeText, Text: "Hello"}}},
@@ -1239,3 +1321,171 @@ func TestMaxTokensTruncation(t *testing.T) {
 		t.Errorf("expected error message to suggest smaller changes, got %q", secondMsg.Content[0].Text)
 	}
 }
+
+func TestCompactNothingToCompact(t *testing.T) {
+	loop := NewLoop(Config{LLM: NewPredictableService()})
+	if err := loop.Compact(context.Background()); err != ErrNothingToCompact {
+		t.Fatalf("expected ErrNothingToCompact, got %v", err)
+	}
+}
+
+func TestAutoCompaction(t *testing.T) {
+	service := NewPredictableService()
+	service.tokenContextWindow = 10 // any response exceeds the compaction threshold
+
+	summaryService := NewPredictableService()
+	summaries := make(chan llm.Message, 2)
+	loop := NewLoop(Config{
+		LLM:        service,
+		SummaryLLM: summaryService,
+		History: []llm.Message{
+			{Role: llm.MessageRoleUser, Content: []llm.Content{{Type: llm.ContentTypeText, Text: "earlier question"}}},
+			{Role: llm.MessageRoleAssistant, Content: []llm.Content{{Type: llm.ContentTypeText, Text: "earlier answer"}}},
+		},
+		RecordMessage: func(ctx context.Context, message llm.Message, usage llm.Usage) error {
+			return nil
+		},
+		RecordSummary: func(ctx context.Context, message llm.Message, usage llm.Usage, kept int) error {
+			summaries <- message
+			return nil
+		},
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	go loop.Go(ctx)
+
+	loop.QueueUserMessage(llm.Message{
+		Role:    llm.MessageRoleUser,
+		Content: []llm.Content{{Type: llm.ContentTypeText, Text: "echo: hi"}},
+	})
+
+	var summary llm.Message
+	select {
+	case summary = <-summaries:
+	case <-ctx.Done():
+		t.Fatal("timed out waiting for compaction")
+	}
+	if !strings.HasPrefix(summary.Content[0].Text, SummaryPrefix) {
+		t.Errorf("expected summary to start with prefix, got %q", summary.Content[0].Text)
+	}
+
+	// The summary request carries the transcript of the compacted turns
+	req := summaryService.GetRecentRequests()[0]
+	if !strings.Contains(req.Messages[0].Content[0].Text, "User: earlier question") {
+		t.Errorf("expected transcript in summary request, got %q", req.Messages[0].Content[0].Text)
+	}
+
+	cancel()
+	history := loop.GetHistory()
+	if len(history) == 0 || !isSummaryMessage(history[0]) {
+		t.Fatalf("expected history to start with a summary, got %d messages", len(history))
+	}
+}
+
+func TestCompactKeepsRecentTurns(t *testing.T) {
+	service := NewPredictableService()
+	service.tokenContextWindow = 1000 // keeps up to 1000 bytes of turns
+	var kept int
+	loop := NewLoop(Config{
+		LLM: service,
+		History: []llm.Message{
+			llm.UserStringMessage("old question " + strings.Repeat("x", 1000)),
+			{Role: llm.MessageRoleAssistant, Content: []llm.Content{llm.StringContent("old answer")}},
+			llm.UserStringMessage("recent question"),
+			{Role: llm.MessageRoleAssistant, Content: []llm.Content{llm.StringContent("recent answer")}},
+		},
+		RecordSummary: func(ctx context.Context, message llm.Message, usage llm.Usage, n int) error {
+			kept = n
+			return nil
+		},
+	})
+	if err := loop.Compact(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	history := loop.GetHistory()
+	if kept != 2 || len(history) != 3 || !isSummaryMessage(history[0]) || history[1].Content[0].Text != "recent question" || history[2].Content[0].Text != "recent answer" {
+		t.Fatalf("expected the summary and the recent turn, got %d kept and %+v", kept, history)
+	}
+	transcript := service.GetLastRequest().Messages[0].Content[0].Text
+	if !strings.Contains(transcript, "old question") || strings.Contains(transcript, "recent question") {
+		t.Errorf("expected only the old turn summarized, got %q", transcript)
+	}
+}
+
+func TestCompactInTurn(t *testing.T) {
+	service := NewPredictableService()
+	service.tokenContextWindow = 1000
+	summaryService := NewPredictableService()
+	var kept []int
+	loop := NewLoop(Config{
+		LLM:        service,
+		SummaryLLM: summaryService,
+		History: []llm.Message{
+			llm.UserStringMessage("old question " + strings.Repeat("x", 4000)),
+			{Role: llm.MessageRoleAssistant, Content: []llm.Content{llm.StringContent("old answer")}},
+		},
+		RecordMessage: func(ctx context.Context, message llm.Message, usage llm.Usage) error {
+			return nil
+		},
+		RecordSummary: func(ctx context.Context, message llm.Message, usage llm.Usage, n int) error {
+			kept = append(kept, n)
+			return nil
+		},
+	})
+
+	// The history is compacted before the turn's first request
+	loop.QueueUserMessage(llm.UserStringMessage("echo: hi"))
+	if err := loop.ProcessOneTurn(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	req := service.GetRecentRequests()[0]
+	if !slices.Equal(kept, []int{1}) || len(req.Messages) != 2 || !isSummaryMessage(req.Messages[0]) || req.Messages[1].Content[0].Text != "echo: hi" {
+		t.Fatalf("expected the summary and the request, got %d kept and %+v", kept, req.Messages)
+	}
+
+	// A turn too long to keep is summarized too, but for its request
+	request := "echo: " + strings.Repeat("y", 4000)
+	loop.QueueUserMessage(llm.UserStringMessage(request))
+	service.ClearRequests()
+	if err := loop.ProcessOneTurn(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	req = service.GetRecentRequests()[0]
+	if len(kept) != 2 || kept[1] != 0 || len(req.Messages) != 1 || !isSummaryMessage(req.Messages[0]) {
+		t.Fatalf("expected only the summary, got %d kept and %d messages", kept, len(req.Messages))
+	}
+	content := req.Messages[0].Content
+	if len(content) != 3 || content[1].Text != summaryRequestPrefix || content[2].Text != request {
+		t.Errorf("expected the request in the summary, got %+v", content)
+	}
+}
+
+func TestTruncateTranscript(t *testing.T) {
+	if got := truncateText(strings.Repeat("é", 10), 5); got != "éé... [truncated]" {
+		t.Errorf("truncateText: got %q", got)
+	}
+	history := []llm.Message{llm.UserStringMessage(strings.Repeat("é", 100))}
+	if got := buildTranscript(history, 51); !utf8.ValidString(got) {
+		t.Errorf("buildTranscript cut a character: %q", got)
+	}
+}
+
+func TestTurnThinking(t *testing.T) {
+	conversation := &llm.Thinking{Effort: "low"}
+	message := &llm.Thinking{Effort: "high"}
+	toolResult := llm.Message{Role: llm.MessageRoleUser, Content: []llm.Content{{Type: llm.ContentTypeToolResult, ToolUseID: "1"}}}
+	toolUse := llm.Message{Role: llm.MessageRoleAssistant, Content: []llm.Content{{Type: llm.ContentTypeToolUse, ID: "1"}}}
+
+	start := llm.UserStringMessage("think hard")
+	start.Thinking = message
+	if got := turnThinking([]llm.Message{start, toolUse, toolResult}, conversation); got != message {
+		t.Errorf("expected the message's thinking for its whole turn, got %+v", got)
+	}
+	if got := turnThinking([]llm.Message{start, toolUse, toolResult, llm.UserStringMessage("next")}, conversation); got != conversation {
+		t.Errorf("expected the conversation's thinking for the next turn, got %+v", got)
+	}
+	if got := turnThinking(nil, nil); got != nil {
+		t.Errorf("expected no thinking, got %+v", got)
+	}
+}
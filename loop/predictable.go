@@ -21,8 +21,16 @@ import (
 //   - "echo: <text>" - echoes the text back
 //   - "bash: <command>" - triggers bash tool with command
 //   - "think: <thoughts>" - triggers think tool
+//   - "remember: <note>" - triggers remember tool
+//   - "ask: <question> | <option> | ..." - triggers ask_user tool
 //   - "delay: <seconds>" - delays response by specified seconds
+//   - "stream: <text>" - streams the text a word at a time, 100ms apart
+//   - SummaryPrompt - returns a canned summary for compaction
 //   - See Do() method for complete list of supported patterns
+//
+// In record mode (NewRecordingService) it instead passes requests to a real
+// provider and records them to a fixture file, and in replay mode
+// (NewReplayService) it answers from such a file.
 type PredictableService struct {
 	// TokenContextWindow size
 	tokenContextWindow int
@@ -30,6 +38,8 @@ type PredictableService struct {
 	// Recent requests for testing inspection
 	recentRequests []*llm.Request
 	responseDelay  time.Duration
+	// fixture, if set, records or replays a real provider's responses
+	fixture *fixture
 }
 
 // NewPredictableService creates a new predictable LLM service
@@ -54,9 +64,21 @@ func (s *PredictableService) TokenContextWindow() int {
 
 // MaxImageDimension returns the maximum allowed image dimension.
 func (s *PredictableService) MaxImageDimension() int {
+	if s.fixture != nil && s.fixture.upstream != nil {
+		return s.fixture.upstream.MaxImageDimension()
+	}
 	return 2000
 }
 
+// Capabilities reports the upstream's capabilities when recording. The
+// predictable model itself takes images and calls tools for free.
+func (s *PredictableService) Capabilities() llm.Capabilities {
+	if s.fixture != nil && s.fixture.upstream != nil {
+		return llm.ModelCapabilities(s.fixture.upstream)
+	}
+	return llm.Capabilities{Vision: true, Tools: true, Thinking: true, Pricing: &llm.Pricing{}}
+}
+
 // Do processes a request and returns a predictable response based on the input text
 func (s *PredictableService) Do(ctx context.Context, req *llm.Request) (*llm.Response, error) {
 	// Store request for testing inspection
@@ -77,6 +99,10 @@ func (s *PredictableService) Do(ctx context.Context, req *llm.Request) (*llm.Res
 		}
 	}
 
+	if s.fixture != nil {
+		return s.fixture.do(ctx, req)
+	}
+
 	// Calculate input token count based on the request content
 	inputTokens := s.countRequestTokens(req)
 
@@ -149,6 +175,16 @@ func (s *PredictableService) Do(ctx context.Context, req *llm.Request) (*llm.Res
 			return s.makeThinkToolResponse(thoughts, inputTokens), nil
 		}
 
+		if strings.HasPrefix(inputText, "remember: ") {
+			note := strings.TrimPrefix(inputText, "remember: ")
+			return s.makeRememberToolResponse(note, inputTokens), nil
+		}
+
+		if strings.HasPrefix(inputText, "ask: ") {
+			parts := strings.Split(strings.TrimPrefix(inputText, "ask: "), " | ")
+			return s.makeAskUserToolResponse(parts[0], parts[1:], inputTokens), nil
+		}
+
 		if strings.HasPrefix(inputText, "patch: ") {
 			filePath := strings.TrimPrefix(inputText, "patch: ")
 			return s.makePatchToolResponse(filePath, inputTokens), nil
@@ -159,11 +195,30 @@ func (s *PredictableService) Do(ctx context.Context, req *llm.Request) (*llm.Res
 			return nil, fmt.Errorf("predictable error: %s", errorMsg)
 		}
 
+		if strings.HasPrefix(inputText, SummaryPrompt) {
+			// Compaction request: summarize by counting transcript lines
+			lines := strings.Count(inputText, "\n\n")
+			return s.makeResponse(fmt.Sprintf("The user and agent worked together (%d transcript entries).", lines), inputTokens), nil
+		}
+
 		if strings.HasPrefix(inputText, "screenshot: ") {
 			selector := strings.TrimSpace(strings.TrimPrefix(inputText, "screenshot: "))
 			return s.makeScreenshotToolResponse(selector, inputTokens), nil
 		}
 
+		if strings.HasPrefix(inputText, "stream: ") {
+			words := strings.Fields(strings.TrimPrefix(inputText, "stream: "))
+			for i := range words {
+				select {
+				case <-time.After(100 * time.Millisecond):
+				case <-ctx.Done():
+					return nil, ctx.Err()
+				}
+				llm.StreamText(ctx, strings.Join(words[:i+1], " "))
+			}
+			return s.makeResponse(strings.Join(words, " "), inputTokens), nil
+		}
+
 		if strings.HasPrefix(inputText, "delay: ") {
 			delayStr := strings.TrimPrefix(inputText, "delay: ")
 			delaySeconds, err := strconv.ParseFloat(delayStr, 64)
@@ -297,6 +352,68 @@ func (s *PredictableService) makeThinkToolResponse(thoughts string, inputTokens
 	}
 }
 
+// makeRememberToolResponse creates a response that calls the remember tool
+func (s *PredictableService) makeRememberToolResponse(note string, inputTokens uint64) *llm.Response {
+	toolInputBytes, _ := json.Marshal(map[string]string{"content": note})
+	responseText := "I'll remember that."
+	outputTokens := uint64(len(responseText)/4 + len(toolInputBytes)/4)
+	if outputTokens == 0 {
+		outputTokens = 1
+	}
+	return &llm.Response{
+		ID:    fmt.Sprintf("pred-remember-%d", time.Now().UnixNano()),
+		Type:  "message",
+		Role:  llm.MessageRoleAssistant,
+		Model: "predictable-v1",
+		Content: []llm.Content{
+			{Type: llm.ContentTypeText, Text: responseText},
+			{
+				ID:        fmt.Sprintf("tool_%d", time.Now().UnixNano()%1000),
+				Type:      llm.ContentTypeToolUse,
+				ToolName:  "remember",
+				ToolInput: json.RawMessage(toolInputBytes),
+			},
+		},
+		StopReason: llm.StopReasonToolUse,
+		Usage: llm.Usage{
+			InputTokens:  inputTokens,
+			OutputTokens: outputTokens,
+			CostUSD:      0.002,
+		},
+	}
+}
+
+// makeAskUserToolResponse creates a response that calls the ask_user tool
+func (s *PredictableService) makeAskUserToolResponse(question string, options []string, inputTokens uint64) *llm.Response {
+	toolInputBytes, _ := json.Marshal(map[string]any{"question": question, "options": options})
+	responseText := "I need to ask you something."
+	outputTokens := uint64(len(responseText)/4 + len(toolInputBytes)/4)
+	if outputTokens == 0 {
+		outputTokens = 1
+	}
+	return &llm.Response{
+		ID:    fmt.Sprintf("pred-ask-%d", time.Now().UnixNano()),
+		Type:  "message",
+		Role:  llm.MessageRoleAssistant,
+		Model: "predictable-v1",
+		Content: []llm.Content{
+			{Type: llm.ContentTypeText, Text: responseText},
+			{
+				ID:        fmt.Sprintf("tool_%d", time.Now().UnixNano()%1000),
+				Type:      llm.ContentTypeToolUse,
+				ToolName:  "ask_user",
+				ToolInput: json.RawMessage(toolInputBytes),
+			},
+		},
+		StopReason: llm.StopReasonToolUse,
+		Usage: llm.Usage{
+			InputTokens:  inputTokens,
+			OutputTokens: outputTokens,
+			CostUSD:      0.002,
+		},
+	}
+}
+
 // makePatchToolResponse creates a response that calls the patch tool
 func (s *PredictableService) makePatchToolResponse(filePath string, inputTokens uint64) *llm.Response {
 	// Properly marshal the patch data to avoid JSON escaping issues
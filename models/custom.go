@@ -0,0 +1,76 @@
+package models
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+
+	"shelley.exe.dev/llm/oai"
+)
+
+// CustomModel is a model served by an OpenAI-compatible endpoint that is
+// registered at runtime, such as a vLLM, LM Studio or llama.cpp server.
+type CustomModel struct {
+	// ID identifies the model in Shelley.
+	ID string
+	// URL is the base URL of the endpoint, e.g. "http://localhost:1234/v1".
+	URL string
+	// APIKey is optional; local servers often need none.
+	APIKey string
+	// ModelName is the model the endpoint is asked for.
+	ModelName string
+}
+
+// Validate checks that the model is complete and doesn't shadow a
+// built-in model.
+func (c CustomModel) Validate() error {
+	if c.ID == "" || c.URL == "" || c.ModelName == "" {
+		return fmt.Errorf("a custom model needs an ID, a URL and a model name")
+	}
+	if ByID(c.ID) != nil {
+		return fmt.Errorf("%s is a built-in model", c.ID)
+	}
+	u, err := url.Parse(c.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid URL %q: must be an http or https URL", c.URL)
+	}
+	return nil
+}
+
+// AddCustomModel makes a custom model available at once, replacing any
+// custom model with the same ID.
+func (m *Manager) AddCustomModel(c CustomModel) error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+	svc := &oai.Service{
+		Model:  oai.Model{UserName: c.ID, ModelName: c.ModelName, URL: c.URL},
+		APIKey: c.APIKey,
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.services[c.ID] = svc
+	if limits := limitsFor(m.limits, c.ID); !limits.IsZero() {
+		m.limiters[c.ID] = newLimiter(limits)
+	}
+	if !slices.Contains(m.custom, c.ID) {
+		m.custom = append(m.custom, c.ID)
+	}
+	return nil
+}
+
+// RemoveCustomModel makes a custom model unavailable. It reports whether
+// there was one with the ID.
+func (m *Manager) RemoveCustomModel(id string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := slices.Index(m.custom, id)
+	if i < 0 {
+		return false
+	}
+	m.custom = slices.Delete(m.custom, i, i+1)
+	delete(m.services, id)
+	delete(m.limiters, id)
+	return true
+}
@@ -0,0 +1,207 @@
+package models
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"shelley.exe.dev/llm"
+)
+
+// DefaultLimitsKey is the Config.Limits key that applies to models without their own entry.
+const DefaultLimitsKey = "*"
+
+// Limits caps the request rate to a single model. Zero values mean unlimited.
+type Limits struct {
+	// MaxConcurrent is the maximum number of in-flight requests
+	MaxConcurrent int `json:"max_concurrent,omitempty"`
+	// RequestsPerMinute is the maximum number of requests started in any one-minute window
+	RequestsPerMinute int `json:"requests_per_minute,omitempty"`
+	// TokensPerMinute is the maximum number of input and output tokens reported in any one-minute window.
+	// Token usage is only known once a response arrives, so this cap delays new requests
+	// rather than rejecting ones already in flight.
+	TokensPerMinute int `json:"tokens_per_minute,omitempty"`
+}
+
+// IsZero reports whether no limits are set
+func (l Limits) IsZero() bool {
+	return l.MaxConcurrent <= 0 && l.RequestsPerMinute <= 0 && l.TokensPerMinute <= 0
+}
+
+// limitsFor returns the configured limits for a model, falling back to the default entry
+func limitsFor(limits map[string]Limits, modelID string) Limits {
+	if l, ok := limits[modelID]; ok {
+		return l
+	}
+	return limits[DefaultLimitsKey]
+}
+
+const rateWindow = time.Minute
+
+type tokenRecord struct {
+	at     time.Time
+	tokens uint64
+}
+
+// limiter enforces Limits for one model.
+// Waiters are admitted strictly in arrival order, so a burst from one
+// conversation can't starve requests queued earlier by others.
+type limiter struct {
+	limits Limits
+	now    func() time.Time
+
+	mu       sync.Mutex
+	active   int
+	queue    []chan struct{}
+	requests []time.Time
+	tokens   []tokenRecord
+}
+
+func newLimiter(limits Limits) *limiter {
+	return &limiter{limits: limits, now: time.Now}
+}
+
+// acquire blocks until a request may start, returning a function that must be
+// called with the response usage once the request finishes.
+func (l *limiter) acquire(ctx context.Context) (func(llm.Usage), error) {
+	wake := make(chan struct{}, 1)
+	l.mu.Lock()
+	l.queue = append(l.queue, wake)
+	for {
+		var wait time.Duration
+		if l.queue[0] == wake {
+			var ok bool
+			if ok, wait = l.admit(); ok {
+				l.queue = l.queue[1:]
+				l.signalLocked()
+				l.mu.Unlock()
+				return l.release, nil
+			}
+		}
+		l.mu.Unlock()
+
+		var timer *time.Timer
+		var expired <-chan time.Time
+		if wait > 0 {
+			timer = time.NewTimer(wait)
+			expired = timer.C
+		}
+		select {
+		case <-wake:
+		case <-expired:
+		case <-ctx.Done():
+			if timer != nil {
+				timer.Stop()
+			}
+			l.mu.Lock()
+			for i, ch := range l.queue {
+				if ch == wake {
+					l.queue = append(l.queue[:i], l.queue[i+1:]...)
+					break
+				}
+			}
+			l.signalLocked()
+			l.mu.Unlock()
+			return nil, ctx.Err()
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		l.mu.Lock()
+	}
+}
+
+// admit reports whether a request may start now, recording it if so.
+// Otherwise it returns how long until a rate window frees up, or zero if the
+// caller must wait for an in-flight request to finish.
+func (l *limiter) admit() (bool, time.Duration) {
+	now := l.now()
+	cutoff := now.Add(-rateWindow)
+	for len(l.requests) > 0 && !l.requests[0].After(cutoff) {
+		l.requests = l.requests[1:]
+	}
+	var used uint64
+	for len(l.tokens) > 0 && !l.tokens[0].at.After(cutoff) {
+		l.tokens = l.tokens[1:]
+	}
+	for _, r := range l.tokens {
+		used += r.tokens
+	}
+
+	if l.limits.MaxConcurrent > 0 && l.active >= l.limits.MaxConcurrent {
+		return false, 0
+	}
+	if l.limits.RequestsPerMinute > 0 && len(l.requests) >= l.limits.RequestsPerMinute {
+		return false, l.requests[0].Sub(cutoff)
+	}
+	if l.limits.TokensPerMinute > 0 && used >= uint64(l.limits.TokensPerMinute) {
+		return false, l.tokens[0].at.Sub(cutoff)
+	}
+
+	l.active++
+	l.requests = append(l.requests, now)
+	return true, 0
+}
+
+// release records a finished request and wakes the next waiter
+func (l *limiter) release(usage llm.Usage) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.active--
+	if tokens := usage.TotalInputTokens() + usage.OutputTokens; tokens > 0 {
+		l.tokens = append(l.tokens, tokenRecord{at: l.now(), tokens: tokens})
+	}
+	l.signalLocked()
+}
+
+// signalLocked wakes the waiter at the head of the queue. l.mu must be held.
+func (l *limiter) signalLocked() {
+	if len(l.queue) == 0 {
+		return
+	}
+	select {
+	case l.queue[0] <- struct{}{}:
+	default:
+	}
+}
+
+// limitedService wraps an llm.Service so requests wait for the model's limiter
+type limitedService struct {
+	service llm.Service
+	limiter *limiter
+}
+
+// Do waits for capacity before calling the underlying service
+func (s *limitedService) Do(ctx context.Context, request *llm.Request) (*llm.Response, error) {
+	release, err := s.limiter.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	response, err := s.service.Do(ctx, request)
+	var usage llm.Usage
+	if response != nil {
+		usage = response.Usage
+	}
+	release(usage)
+	return response, err
+}
+
+// TokenContextWindow delegates to the underlying service
+func (s *limitedService) TokenContextWindow() int {
+	return s.service.TokenContextWindow()
+}
+
+// MaxImageDimension delegates to the underlying service
+func (s *limitedService) MaxImageDimension() int {
+	return s.service.MaxImageDimension()
+}
+
+// UseSimplifiedPatch delegates to the underlying service if it supports it
+func (s *limitedService) UseSimplifiedPatch() bool {
+	return llm.UseSimplifiedPatch(s.service)
+}
+
+// Capabilities delegates to the underlying service
+func (s *limitedService) Capabilities() llm.Capabilities {
+	return llm.ModelCapabilities(s.service)
+}
@@ -0,0 +1,181 @@
+package models
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"shelley.exe.dev/llm"
+)
+
+func TestLimiterMaxConcurrent(t *testing.T) {
+	l := newLimiter(Limits{MaxConcurrent: 1})
+
+	release, err := l.acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	acquired := make(chan struct{})
+	go func() {
+		r, err := l.acquire(context.Background())
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		close(acquired)
+		r(llm.Usage{})
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("second request started while first was in flight")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	release(llm.Usage{})
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatal("second request did not start after release")
+	}
+}
+
+func TestLimiterFIFO(t *testing.T) {
+	l := newLimiter(Limits{MaxConcurrent: 1})
+	release, err := l.acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	var order []int
+	var wg sync.WaitGroup
+	for i := range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r, err := l.acquire(context.Background())
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			mu.Lock()
+			order = append(order, i)
+			mu.Unlock()
+			r(llm.Usage{})
+		}()
+		// Wait for the goroutine to enqueue so arrival order is deterministic
+		for {
+			l.mu.Lock()
+			n := len(l.queue)
+			l.mu.Unlock()
+			if n == i+1 {
+				break
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	release(llm.Usage{})
+	wg.Wait()
+	for i, got := range order {
+		if got != i {
+			t.Fatalf("admission order = %v, want arrival order", order)
+		}
+	}
+}
+
+func TestLimiterRequestsPerMinute(t *testing.T) {
+	now := time.Unix(1000, 0)
+	l := newLimiter(Limits{RequestsPerMinute: 2})
+	l.now = func() time.Time { return now }
+
+	for range 2 {
+		if ok, _ := l.admit(); !ok {
+			t.Fatal("expected request within limit to be admitted")
+		}
+	}
+	ok, wait := l.admit()
+	if ok {
+		t.Fatal("expected third request to be rate limited")
+	}
+	if wait != time.Minute {
+		t.Errorf("wait = %v, want %v", wait, time.Minute)
+	}
+
+	now = now.Add(time.Minute)
+	if ok, _ := l.admit(); !ok {
+		t.Fatal("expected request to be admitted once the window passed")
+	}
+}
+
+func TestLimiterTokensPerMinute(t *testing.T) {
+	now := time.Unix(1000, 0)
+	l := newLimiter(Limits{TokensPerMinute: 100})
+	l.now = func() time.Time { return now }
+
+	if ok, _ := l.admit(); !ok {
+		t.Fatal("expected first request to be admitted")
+	}
+	l.release(llm.Usage{InputTokens: 80, OutputTokens: 30})
+
+	now = now.Add(10 * time.Second)
+	ok, wait := l.admit()
+	if ok {
+		t.Fatal("expected request to wait for token budget")
+	}
+	if wait != 50*time.Second {
+		t.Errorf("wait = %v, want 50s", wait)
+	}
+
+	now = now.Add(50 * time.Second)
+	if ok, _ := l.admit(); !ok {
+		t.Fatal("expected request to be admitted once tokens aged out")
+	}
+}
+
+func TestLimiterContextCancel(t *testing.T) {
+	l := newLimiter(Limits{MaxConcurrent: 1})
+	release, err := l.acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release(llm.Usage{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := l.acquire(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("acquire error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.queue) != 0 {
+		t.Errorf("cancelled waiter left in queue")
+	}
+}
+
+func TestManagerAppliesLimits(t *testing.T) {
+	m, err := NewManager(&Config{Limits: map[string]Limits{DefaultLimitsKey: {MaxConcurrent: 2}}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc, err := m.GetService("predictable")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := svc.(*limitedService); !ok {
+		t.Fatalf("GetService returned %T, want *limitedService", svc)
+	}
+	resp, err := svc.Do(context.Background(), &llm.Request{Messages: []llm.Message{{Role: llm.MessageRoleUser, Content: []llm.Content{{Type: llm.ContentTypeText, Text: "echo: hi"}}}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Content) == 0 {
+		t.Fatal("expected response content")
+	}
+	if m.limiters["predictable"].active != 0 {
+		t.Error("limiter slot not released after request")
+	}
+}
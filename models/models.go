@@ -54,6 +54,16 @@ type Config struct {
 	// If set, model-specific suffixes will be appended
 	Gateway string
 
+	// Limits caps requests per model ID; the DefaultLimitsKey entry applies to
+	// models without their own entry (optional)
+	Limits map[string]Limits
+
+	// PredictableFixture makes the predictable model replay the fixture
+	// file at this path, or, with PredictableRecordModel, record one from
+	// that model (optional)
+	PredictableFixture     string
+	PredictableRecordModel string
+
 	Logger *slog.Logger
 }
 
@@ -226,7 +236,22 @@ func All() []Model {
 			Description:     "Deterministic test model (no API key)",
 			RequiredEnvVars: []string{},
 			Factory: func(config *Config) (llm.Service, error) {
-				return loop.NewPredictableService(), nil
+				path, id := config.PredictableFixture, config.PredictableRecordModel
+				if path == "" {
+					return loop.NewPredictableService(), nil
+				}
+				if id == "" {
+					return loop.NewReplayService(path)
+				}
+				model := ByID(id)
+				if model == nil || model.ID == "predictable" {
+					return nil, fmt.Errorf("cannot record fixtures from model %q", id)
+				}
+				upstream, err := model.Factory(config)
+				if err != nil {
+					return nil, err
+				}
+				return loop.NewRecordingService(upstream, path), nil
 			},
 		},
 	}
@@ -259,7 +284,11 @@ func Default() Model {
 
 // Manager manages LLM services for all configured models
 type Manager struct {
+	mu       sync.RWMutex // guards services, limiters and custom, which change as custom models are added
 	services map[string]llm.Service
+	limiters map[string]*limiter
+	limits   map[string]Limits
+	custom   []string // IDs of the custom models, in the order they were added
 	logger   *slog.Logger
 	history  *LLMRequestHistory
 }
@@ -402,10 +431,17 @@ func (l *loggingService) UseSimplifiedPatch() bool {
 	return false
 }
 
+// Capabilities delegates to the underlying service
+func (l *loggingService) Capabilities() llm.Capabilities {
+	return llm.ModelCapabilities(l.service)
+}
+
 // NewManager creates a new Manager with all models configured
 func NewManager(cfg *Config, history *LLMRequestHistory) (*Manager, error) {
 	manager := &Manager{
 		services: make(map[string]llm.Service),
+		limiters: make(map[string]*limiter),
+		limits:   cfg.Limits,
 		logger:   cfg.Logger,
 		history:  history,
 	}
@@ -417,6 +453,9 @@ func NewManager(cfg *Config, history *LLMRequestHistory) (*Manager, error) {
 			continue
 		}
 		manager.services[model.ID] = svc
+		if limits := limitsFor(cfg.Limits, model.ID); !limits.IsZero() {
+			manager.limiters[model.ID] = newLimiter(limits)
+		}
 	}
 
 	return manager, nil
@@ -424,7 +463,11 @@ func NewManager(cfg *Config, history *LLMRequestHistory) (*Manager, error) {
 
 // GetService returns the LLM service for the given model ID, wrapped with logging
 func (m *Manager) GetService(modelID string) (llm.Service, error) {
-	if svc, ok := m.services[modelID]; ok {
+	m.mu.RLock()
+	svc, ok := m.services[modelID]
+	lim, limited := m.limiters[modelID]
+	m.mu.RUnlock()
+	if ok {
 		// Set HTTP recorder on ant.Service if we have history
 		if antSvc, ok := svc.(*ant.Service); ok && m.history != nil {
 			antSvc.HTTPRecorder = func(url string, requestBody, responseBody []byte, statusCode int, err error, duration time.Duration) {
@@ -445,12 +488,16 @@ func (m *Manager) GetService(modelID string) (llm.Service, error) {
 		}
 		// Wrap with logging if we have a logger
 		if m.logger != nil {
-			return &loggingService{
+			svc = &loggingService{
 				service: svc,
 				logger:  m.logger,
 				modelID: modelID,
 				history: m.history,
-			}, nil
+			}
+		}
+		// Queue behind the model's limits outside of logging, so logged durations exclude waiting
+		if limited {
+			svc = &limitedService{service: svc, limiter: lim}
 		}
 		return svc, nil
 	}
@@ -464,7 +511,9 @@ func (m *Manager) GetHistory() *LLMRequestHistory {
 
 // GetAvailableModels returns a list of available model IDs in the same order as All()
 func (m *Manager) GetAvailableModels() []string {
-	// Return IDs in the same order as All() for consistency
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	// Return IDs in the same order as All() for consistency, then the custom models
 	all := All()
 	var ids []string
 	for _, model := range all {
@@ -472,11 +521,13 @@ func (m *Manager) GetAvailableModels() []string {
 			ids = append(ids, model.ID)
 		}
 	}
-	return ids
+	return append(ids, m.custom...)
 }
 
 // HasModel reports whether the manager has a service for the given model ID
 func (m *Manager) HasModel(modelID string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
 	_, ok := m.services[modelID]
 	return ok
 }
@@ -0,0 +1,83 @@
+// Package notify sends notifications about conversations, such as an agent
+// finishing its turn, through services like ntfy, Pushover, email and Slack.
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Kind is the kind of event a notification is about.
+type Kind string
+
+const (
+	// KindTurnEnd is sent when the agent finishes its turn.
+	KindTurnEnd Kind = "turn_end"
+	// KindError is sent when the agent's turn ends with an error.
+	KindError Kind = "error"
+	// KindQuestion is sent when the agent is waiting for the user to answer
+	// a question before it carries on.
+	KindQuestion Kind = "question"
+)
+
+// Kinds lists every kind of event.
+var Kinds = []Kind{KindTurnEnd, KindError, KindQuestion}
+
+// Event is something to notify the user about.
+type Event struct {
+	Kind           Kind
+	ConversationID string
+	// Title is the conversation's title, if it has one.
+	Title string
+	// Message is the text of the notification, e.g. the agent's reply.
+	Message string
+	// URL links to the conversation, if the server's address is known.
+	URL string
+}
+
+// Subject returns a one-line summary of the event.
+func (e Event) Subject() string {
+	title := e.Title
+	if title == "" {
+		title = e.ConversationID
+	}
+	switch e.Kind {
+	case KindError:
+		return fmt.Sprintf("Shelley: %s failed", title)
+	case KindQuestion:
+		return fmt.Sprintf("Shelley: %s needs your answer", title)
+	default:
+		return fmt.Sprintf("Shelley: %s finished", title)
+	}
+}
+
+// Notifier delivers notifications through one service.
+type Notifier interface {
+	Notify(ctx context.Context, event Event) error
+}
+
+// Send delivers event through each notifier, returning their errors joined.
+func Send(ctx context.Context, notifiers []Notifier, event Event) error {
+	var errs []error
+	for _, n := range notifiers {
+		if err := n.Notify(ctx, event); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// httpClient sends the requests of the HTTP-based notifiers.
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// checkResponse returns an error if resp isn't a success, and closes its body.
+func checkResponse(service string, resp *http.Response) error {
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("%s: %s", service, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// recorder is an HTTP server that records the last request it received.
+type recorder struct {
+	*httptest.Server
+	req  *http.Request
+	body string
+}
+
+func newRecorder(t *testing.T, status int) *recorder {
+	r := &recorder{}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		r.req, r.body = req, string(body)
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(r.Close)
+	return r
+}
+
+var testEvent = Event{
+	Kind:           KindTurnEnd,
+	ConversationID: "c123",
+	Title:          "fix-tests",
+	Message:        "All tests pass now.",
+	URL:            "https://shelley.example.com/c/c123",
+}
+
+func TestNtfy(t *testing.T) {
+	r := newRecorder(t, http.StatusOK)
+	n := &Ntfy{Server: r.URL, Topic: "my-topic", Token: "tk"}
+	if err := n.Notify(context.Background(), testEvent); err != nil {
+		t.Fatal(err)
+	}
+	if r.req.URL.Path != "/my-topic" || r.body != testEvent.Message {
+		t.Errorf("unexpected request to %s with body %q", r.req.URL.Path, r.body)
+	}
+	if got := r.req.Header.Get("Title"); got != "Shelley: fix-tests finished" {
+		t.Errorf("Title = %q", got)
+	}
+	if r.req.Header.Get("Click") != testEvent.URL || r.req.Header.Get("Authorization") != "Bearer tk" {
+		t.Errorf("unexpected headers %v", r.req.Header)
+	}
+}
+
+func TestPushover(t *testing.T) {
+	r := newRecorder(t, http.StatusOK)
+	defer func(u string) { pushoverURL = u }(pushoverURL)
+	pushoverURL = r.URL
+
+	p := &Pushover{Token: "app", User: "me"}
+	if err := p.Notify(context.Background(), testEvent); err != nil {
+		t.Fatal(err)
+	}
+	form, err := url.ParseQuery(r.body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if form.Get("token") != "app" || form.Get("user") != "me" || form.Get("message") != testEvent.Message || form.Get("url") != testEvent.URL {
+		t.Errorf("unexpected form %v", form)
+	}
+}
+
+func TestSlack(t *testing.T) {
+	r := newRecorder(t, http.StatusOK)
+	s := &Slack{WebhookURL: r.URL}
+	if err := s.Notify(context.Background(), testEvent); err != nil {
+		t.Fatal(err)
+	}
+	var payload map[string]string
+	if err := json.Unmarshal([]byte(r.body), &payload); err != nil {
+		t.Fatal(err)
+	}
+	want := "*<https://shelley.example.com/c/c123|Shelley: fix-tests finished>*\nAll tests pass now."
+	if payload["text"] != want {
+		t.Errorf("text = %q, want %q", payload["text"], want)
+	}
+}
+
+func TestEmailMessage(t *testing.T) {
+	e := &Email{From: "shelley@example.com", To: []string{"a@example.com", "b@example.com"}}
+	msg := string(e.message(Event{Kind: KindQuestion, ConversationID: "c1", Message: "Which branch?\nmain or dev"}))
+	for _, want := range []string{
+		"To: a@example.com, b@example.com\r\n",
+		"Subject: Shelley: c1 needs your answer\r\n",
+		"\r\n\r\nWhich branch?\r\nmain or dev\r\n",
+	} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("message missing %q:\n%s", want, msg)
+		}
+	}
+}
+
+func TestSendJoinsErrors(t *testing.T) {
+	ok := newRecorder(t, http.StatusOK)
+	failing := newRecorder(t, http.StatusForbidden)
+	err := Send(context.Background(), []Notifier{
+		&Slack{WebhookURL: failing.URL},
+		&Ntfy{Server: ok.URL, Topic: "t"},
+	}, testEvent)
+	if err == nil || !strings.Contains(err.Error(), "slack: 403") {
+		t.Errorf("expected the slack error, got %v", err)
+	}
+	if ok.req == nil {
+		t.Error("expected the other notifier to still be sent")
+	}
+}
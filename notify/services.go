@@ -0,0 +1,169 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Ntfy publishes notifications to an ntfy topic (https://ntfy.sh).
+type Ntfy struct {
+	// Server is the ntfy server; empty means https://ntfy.sh.
+	Server string `json:"server,omitempty"`
+	Topic  string `json:"topic"`
+	// Token is an access token for protected topics.
+	Token string `json:"token,omitempty" secret:"true"`
+}
+
+// Notify implements Notifier.
+func (n *Ntfy) Notify(ctx context.Context, event Event) error {
+	server := n.Server
+	if server == "" {
+		server = "https://ntfy.sh"
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimRight(server, "/")+"/"+url.PathEscape(n.Topic), strings.NewReader(event.Message))
+	if err != nil {
+		return fmt.Errorf("ntfy: %w", err)
+	}
+	req.Header.Set("Title", event.Subject())
+	if event.URL != "" {
+		req.Header.Set("Click", event.URL)
+	}
+	if event.Kind == KindError || event.Kind == KindQuestion {
+		req.Header.Set("Priority", "high")
+	}
+	if n.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+n.Token)
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("ntfy: %w", err)
+	}
+	return checkResponse("ntfy", resp)
+}
+
+// pushoverURL is the Pushover messages API.
+var pushoverURL = "https://api.pushover.net/1/messages.json"
+
+// Pushover sends notifications through Pushover (https://pushover.net).
+type Pushover struct {
+	// Token is the application's API token.
+	Token string `json:"token" secret:"true"`
+	// User is the user or group key to notify.
+	User string `json:"user" secret:"true"`
+}
+
+// Notify implements Notifier.
+func (p *Pushover) Notify(ctx context.Context, event Event) error {
+	form := url.Values{
+		"token":   {p.Token},
+		"user":    {p.User},
+		"title":   {event.Subject()},
+		"message": {event.Message},
+	}
+	if event.URL != "" {
+		form.Set("url", event.URL)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, pushoverURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("pushover: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("pushover: %w", err)
+	}
+	return checkResponse("pushover", resp)
+}
+
+// Slack posts notifications to a Slack incoming webhook.
+type Slack struct {
+	WebhookURL string `json:"webhookUrl" secret:"true"`
+}
+
+// Notify implements Notifier.
+func (s *Slack) Notify(ctx context.Context, event Event) error {
+	text := "*" + event.Subject() + "*"
+	if event.URL != "" {
+		text = fmt.Sprintf("*<%s|%s>*", event.URL, event.Subject())
+	}
+	if event.Message != "" {
+		text += "\n" + event.Message
+	}
+	body, err := json.Marshal(map[string]string{"text": text})
+	if err != nil {
+		return fmt.Errorf("slack: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("slack: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("slack: %w", err)
+	}
+	return checkResponse("slack", resp)
+}
+
+// Email sends notifications by email through an SMTP server.
+type Email struct {
+	Host string `json:"host"`
+	// Port is the SMTP port; zero means 587.
+	Port int `json:"port,omitempty"`
+	// Username and Password authenticate to the server, if set.
+	Username string   `json:"username,omitempty"`
+	Password string   `json:"password,omitempty" secret:"true"`
+	From     string   `json:"from"`
+	To       []string `json:"to"`
+}
+
+// Notify implements Notifier.
+func (e *Email) Notify(ctx context.Context, event Event) error {
+	port := e.Port
+	if port == 0 {
+		port = 587
+	}
+	var auth smtp.Auth
+	if e.Username != "" {
+		auth = smtp.PlainAuth("", e.Username, e.Password, e.Host)
+	}
+	// net/smtp doesn't take a context, so honour cancellation around it
+	done := make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(e.Host+":"+strconv.Itoa(port), auth, e.From, e.To, e.message(event))
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			return fmt.Errorf("email: %w", err)
+		}
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("email: %w", ctx.Err())
+	}
+}
+
+// message formats event as an email.
+func (e *Email) message(event Event) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", e.From)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(e.To, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", strings.ReplaceAll(event.Subject(), "\n", " "))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(event.Message, "\n", "\r\n"))
+	if event.URL != "" {
+		b.WriteString("\r\n\r\n" + event.URL)
+	}
+	b.WriteString("\r\n")
+	return b.Bytes()
+}
@@ -0,0 +1,215 @@
+// Package projectconfig reads a repository's .shelley.yaml, which carries
+// the project's conventions for conversations working in it:
+//
+//	# Added to the system prompt
+//	prompt: |
+//	  Run make check before committing.
+//	# Tools conversations may use, unless they or their project say otherwise
+//	allowed_tools: [bash, patch, keyword_search]
+//	# Added to the environment of bash commands
+//	env:
+//	  GOFLAGS: -mod=mod
+//	# Run in the working directory before the agent's first turn
+//	setup:
+//	  - npm ci
+//	# Targets of the deploy tool
+//	deploy:
+//	  staging:
+//	    command: make deploy
+//	    env:
+//	      STAGE: staging
+//	  production:
+//	    command: make deploy
+//	    dir: infra      # relative to the file's directory, the default
+//	    env:
+//	      STAGE: production
+//	    production: true # the user must confirm each deploy
+//	    # Must answer within health_timeout (default 1m) of the command
+//	    # finishing, or the rollback command runs
+//	    health_url: https://example.com/healthz
+//	    health_timeout: 2m
+//	    rollback: make rollback
+package projectconfig
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FileName is the name of the configuration file.
+const FileName = ".shelley.yaml"
+
+// Config is the contents of a .shelley.yaml.
+type Config struct {
+	// Path is the file the configuration was read from.
+	Path string `yaml:"-"`
+	// Prompt is added to the system prompt.
+	Prompt string `yaml:"prompt"`
+	// AllowedTools, if non-nil, restricts the tools of new conversations.
+	AllowedTools []string `yaml:"allowed_tools"`
+	// Env is added to the environment of bash commands.
+	Env map[string]string `yaml:"env"`
+	// Setup holds shell commands run, in order, before the agent's first
+	// turn in a new conversation.
+	Setup []string `yaml:"setup"`
+	// Deploy holds the targets of the deploy tool, by name.
+	Deploy map[string]DeployTarget `yaml:"deploy"`
+}
+
+// DeployTarget is a way of deploying the project.
+type DeployTarget struct {
+	Command string `yaml:"command"`
+	// Dir is where Command runs, relative to the configuration file's
+	// directory.
+	Dir string            `yaml:"dir"`
+	Env map[string]string `yaml:"env"`
+	// Production targets are deployed to only once the user confirms.
+	Production bool `yaml:"production"`
+	// Detach runs Command without waiting for it, for deploys that
+	// restart Shelley itself.
+	Detach bool `yaml:"detach"`
+	// HealthURL must answer with a 2xx status within HealthTimeout, a Go
+	// duration, of Command finishing, or Rollback runs.
+	HealthURL     string `yaml:"health_url"`
+	HealthTimeout string `yaml:"health_timeout"`
+	Rollback      string `yaml:"rollback"`
+}
+
+// Find returns the path of the .shelley.yaml that applies to dir: the one
+// in dir or the nearest parent, looking no further up than the root of
+// the git repository dir is in. It returns "" if there is none.
+func Find(dir string) string {
+	for dir != "" {
+		path := filepath.Join(dir, FileName)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path
+		}
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return ""
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+	return ""
+}
+
+// Load reads the configuration that applies to dir (see Find). It returns
+// nil if there is none.
+func Load(dir string) (*Config, error) {
+	path := Find(dir)
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	cfg.Path = path
+	return cfg, nil
+}
+
+// Parse parses the contents of a .shelley.yaml. Unknown keys are errors, so
+// that misspelled settings aren't silently ignored.
+func Parse(data []byte) (*Config, error) {
+	cfg := &Config{}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	if err := CheckEnv(cfg.Env); err != nil {
+		return nil, err
+	}
+	for _, command := range cfg.Setup {
+		if strings.TrimSpace(command) == "" {
+			return nil, errors.New("empty setup command")
+		}
+	}
+	for name, target := range cfg.Deploy {
+		if err := CheckDeployTargetName(name); err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(target.Command) == "" {
+			return nil, fmt.Errorf("deploy target %s has no command", name)
+		}
+		if err := CheckEnv(target.Env); err != nil {
+			return nil, fmt.Errorf("deploy target %s: %w", name, err)
+		}
+		if _, err := ParseHealthTimeout(target.HealthTimeout); err != nil {
+			return nil, fmt.Errorf("deploy target %s: %w", name, err)
+		}
+	}
+	return cfg, nil
+}
+
+// CheckEnv returns an error if env has an invalid variable name.
+func CheckEnv(env map[string]string) error {
+	for key := range env {
+		if key == "" || strings.ContainsAny(key, "= \t\n") {
+			return fmt.Errorf("invalid environment variable name %q", key)
+		}
+	}
+	return nil
+}
+
+// CheckDeployTargetName returns an error if name can't name a deploy target.
+func CheckDeployTargetName(name string) error {
+	if name == "" || strings.ContainsFunc(name, func(r rune) bool {
+		return !(r == '-' || r == '_' || r == '.' || '0' <= r && r <= '9' || 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z')
+	}) {
+		return fmt.Errorf("invalid deploy target name %q: use letters, digits, '-', '_' and '.'", name)
+	}
+	return nil
+}
+
+// ParseHealthTimeout parses the health timeout of a deploy target; empty
+// means the default, zero.
+func ParseHealthTimeout(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid health timeout %q: must be a positive duration such as 30s", s)
+	}
+	return d, nil
+}
+
+// Environ returns Env as KEY=value strings, sorted by key.
+func (c *Config) Environ() []string {
+	return Environ(c.Env)
+}
+
+// Environ returns env as KEY=value strings, sorted by key.
+func Environ(env map[string]string) []string {
+	vars := make([]string, 0, len(env))
+	for key, value := range env {
+		vars = append(vars, key+"="+value)
+	}
+	sort.Strings(vars)
+	return vars
+}
+
+// DeployDir returns the directory target's command runs in.
+func (c *Config) DeployDir(target DeployTarget) string {
+	if filepath.IsAbs(target.Dir) {
+		return target.Dir
+	}
+	return filepath.Join(filepath.Dir(c.Path), target.Dir)
+}
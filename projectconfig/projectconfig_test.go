@@ -0,0 +1,95 @@
+package projectconfig
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	cfg, err := Parse([]byte(`
+prompt: |
+  Use tabs.
+allowed_tools: [bash, patch]
+env:
+  B: two words
+  A: "1"
+setup:
+  - make deps
+deploy:
+  prod:
+    command: make deploy
+    dir: infra
+    env: {STAGE: production}
+    production: true
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Prompt != "Use tabs.\n" || !slices.Equal(cfg.AllowedTools, []string{"bash", "patch"}) || !slices.Equal(cfg.Setup, []string{"make deps"}) {
+		t.Errorf("unexpected config %+v", cfg)
+	}
+	if prod := cfg.Deploy["prod"]; prod.Command != "make deploy" || !prod.Production || prod.Env["STAGE"] != "production" {
+		t.Errorf("unexpected deploy target %+v", prod)
+	}
+	cfg.Path = "/repo/.shelley.yaml"
+	if dir := cfg.DeployDir(cfg.Deploy["prod"]); dir != "/repo/infra" {
+		t.Errorf("expected the deploy dir relative to the file, got %q", dir)
+	}
+	if env := cfg.Environ(); !slices.Equal(env, []string{"A=1", "B=two words"}) {
+		t.Errorf("unexpected environment %q", env)
+	}
+
+	if cfg, err := Parse(nil); err != nil || cfg.AllowedTools != nil {
+		t.Errorf("expected an empty file to be an empty config, got %+v, %v", cfg, err)
+	}
+	for name, data := range map[string]string{
+		"unknown key":       "promt: hi\n",
+		"bad variable":      "env:\n  A=B: c\n",
+		"empty command":     "setup: ['  ']\n",
+		"wrong type":        "setup: make\n",
+		"no deploy command": "deploy:\n  prod:\n    dir: infra\n",
+		"bad deploy name":   "deploy:\n  a b:\n    command: make\n",
+	} {
+		if _, err := Parse([]byte(data)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	repo := t.TempDir()
+	sub := filepath.Join(repo, "a", "b")
+	for _, dir := range []string{filepath.Join(repo, ".git"), sub} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if cfg, err := Load(sub); cfg != nil || err != nil {
+		t.Errorf("expected no config, got %+v, %v", cfg, err)
+	}
+	// Above the repository root doesn't count
+	if err := os.WriteFile(filepath.Join(filepath.Dir(repo), FileName), []byte("prompt: outside\n"), 0o644); err == nil {
+		defer os.Remove(filepath.Join(filepath.Dir(repo), FileName))
+		if cfg, err := Load(sub); cfg != nil || err != nil {
+			t.Errorf("expected a file above the repository to be ignored, got %+v, %v", cfg, err)
+		}
+	}
+
+	if err := os.WriteFile(filepath.Join(repo, FileName), []byte("prompt: root\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := Load(sub)
+	if err != nil || cfg.Prompt != "root" || cfg.Path != filepath.Join(repo, FileName) {
+		t.Errorf("expected the root's config, got %+v, %v", cfg, err)
+	}
+
+	if err := os.WriteFile(filepath.Join(sub, FileName), []byte("setup: [true\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(sub); err == nil || !strings.Contains(err.Error(), filepath.Join(sub, FileName)) {
+		t.Errorf("expected an error naming the nearer file, got %v", err)
+	}
+}
@@ -0,0 +1,88 @@
+// Package redact removes secrets from text, such as conversations exported
+// as training data. It recognizes common credential formats, assignments
+// to secret-looking names, and any literal values it is given.
+package redact
+
+import (
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Placeholder replaces each secret.
+const Placeholder = "[REDACTED]"
+
+// minValueLen is the shortest literal value that is redacted; shorter ones
+// would match too much ordinary text.
+const minValueLen = 8
+
+// patterns match secrets wholesale.
+var patterns = []*regexp.Regexp{
+	regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`),
+	regexp.MustCompile(`\bsk-[A-Za-z0-9_-]{20,}`),                                        // OpenAI, Anthropic
+	regexp.MustCompile(`\bAKIA[0-9A-Z]{16}\b`),                                           // AWS access key IDs
+	regexp.MustCompile(`\bgh[pousr]_[A-Za-z0-9]{36,}\b`),                                 // GitHub tokens
+	regexp.MustCompile(`\bgithub_pat_[A-Za-z0-9_]{22,}\b`),                               // GitHub fine-grained tokens
+	regexp.MustCompile(`\bxox[abprs]-[A-Za-z0-9-]{10,}`),                                 // Slack tokens
+	regexp.MustCompile(`\bAIza[0-9A-Za-z_-]{35}\b`),                                      // Google API keys
+	regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{10,}\.eyJ[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]+`), // JWTs
+}
+
+// assignment matches a value assigned to a secret-looking name, as in
+// "API_KEY=...", "password: ..." or "Authorization: Bearer ...". Only the
+// value, the last group, is redacted.
+var assignment = regexp.MustCompile(`(?i)((?:api[_-]?key|secret|token|passw(?:or)?d|authorization)["']?\s*[:=]\s*["']?(?:bearer\s+)?)([^\s"',;]{8,})`)
+
+// Redactor removes secrets from text.
+type Redactor struct {
+	values []string
+}
+
+// New returns a Redactor that also removes the given literal values.
+// Values shorter than eight characters are ignored.
+func New(values ...string) *Redactor {
+	r := &Redactor{}
+	for _, v := range values {
+		if len(v) >= minValueLen && !slices.Contains(r.values, v) {
+			r.values = append(r.values, v)
+		}
+	}
+	// Longest first, so a value containing another is removed whole
+	slices.SortFunc(r.values, func(a, b string) int { return len(b) - len(a) })
+	return r
+}
+
+// String returns s with its secrets replaced by Placeholder.
+func (r *Redactor) String(s string) string {
+	for _, v := range r.values {
+		s = strings.ReplaceAll(s, v, Placeholder)
+	}
+	for _, p := range patterns {
+		s = p.ReplaceAllString(s, Placeholder)
+	}
+	return assignment.ReplaceAllStringFunc(s, func(m string) string {
+		groups := assignment.FindStringSubmatch(m)
+		if groups[2] == Placeholder {
+			return m
+		}
+		return groups[1] + Placeholder
+	})
+}
+
+// EnvSecrets returns the values of environment variables whose names look
+// like they hold secrets, such as ANTHROPIC_API_KEY or GITHUB_TOKEN.
+func EnvSecrets() []string {
+	var values []string
+	for _, kv := range os.Environ() {
+		name, value, _ := strings.Cut(kv, "=")
+		name = strings.ToUpper(name)
+		for _, word := range []string{"KEY", "TOKEN", "SECRET", "PASSWORD"} {
+			if strings.Contains(name, word) {
+				values = append(values, value)
+				break
+			}
+		}
+	}
+	return values
+}
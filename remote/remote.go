@@ -0,0 +1,223 @@
+// Package remote runs tool commands and file operations somewhere other
+// than this machine, through a Shell, so that a conversation's workspace can
+// live on a bigger machine than the server.
+//
+// Host provides the Shell of another host over SSH. It uses the system's ssh
+// client, so ~/.ssh/config, keys and agents work as they do in a terminal,
+// and commands to the same host share one connection. Authentication must
+// not prompt: a host that asks for a password fails. Stopping a command
+// closes its SSH session; remote processes that ignore the closed
+// connection may outlive it.
+package remote
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ErrUnavailable is returned when a command must run on a Host but ssh is not installed.
+var ErrUnavailable = errors.New("remote workspace unavailable: ssh is not installed")
+
+// Exit statuses the file operation scripts use to report a missing path or
+// a path that isn't a directory.
+const (
+	exitNotExist = 44
+	exitNotDir   = 45
+)
+
+// Host is a host that runs commands over SSH.
+type Host struct {
+	// Target is the ssh destination: [user@]host, or a Host from ~/.ssh/config.
+	Target string
+	// Port overrides the ssh port, if set.
+	Port int
+	// IdentityFile is the private key to authenticate with, if set.
+	IdentityFile string
+}
+
+// A Shell runs shell scripts somewhere other than this machine. It returns
+// the command line that runs script there, with the command's standard
+// streams connected to the script's.
+type Shell func(script string) ([]string, error)
+
+// Available reports whether ssh is installed.
+func Available() bool {
+	_, err := exec.LookPath("ssh")
+	return err == nil
+}
+
+// Args returns the ssh arguments that run the shell script on the host.
+func (h *Host) Args(script string) []string {
+	args := []string{
+		"-T",
+		"-o", "BatchMode=yes",
+		"-o", "ControlMaster=auto",
+		"-o", "ControlPath=" + filepath.Join(os.TempDir(), "shelley-ssh-%C"),
+		"-o", "ControlPersist=10m",
+	}
+	if h.Port != 0 {
+		args = append(args, "-p", strconv.Itoa(h.Port))
+	}
+	if h.IdentityFile != "" {
+		args = append(args, "-i", h.IdentityFile)
+	}
+	return append(args, "--", h.Target, script)
+}
+
+// Shell returns the Shell that runs scripts on the host.
+func (h *Host) Shell() Shell {
+	return func(script string) ([]string, error) {
+		ssh, err := exec.LookPath("ssh")
+		if err != nil {
+			return nil, ErrUnavailable
+		}
+		return append([]string{ssh}, h.Args(script)...), nil
+	}
+}
+
+// Wrap rewrites cmd, which must not have been started, to run through the
+// shell in cmd.Dir. Of cmd.Env, only the variables it adds to or changes in
+// the server's environment are passed on, as the rest describe the server.
+func (sh Shell) Wrap(cmd *exec.Cmd) error {
+	var env []string
+	if cmd.Env != nil {
+		local := os.Environ()
+		for _, kv := range cmd.Env {
+			if !slices.Contains(local, kv) {
+				env = append(env, kv)
+			}
+		}
+	}
+	argv, err := sh(script(cmd.Dir, env, cmd.Args))
+	if err != nil {
+		return err
+	}
+	cmd.Path = argv[0]
+	cmd.Args = argv
+	// The directory is the shell's, and may not exist here
+	cmd.Dir = ""
+	return nil
+}
+
+// script returns a shell script that runs argv in dir with env added to
+// the environment.
+func script(dir string, env, argv []string) string {
+	var b strings.Builder
+	if dir != "" {
+		b.WriteString("cd " + Quote(dir) + " && ")
+	}
+	b.WriteString("exec")
+	if len(env) > 0 {
+		b.WriteString(" env")
+		for _, kv := range env {
+			b.WriteString(" " + Quote(kv))
+		}
+	}
+	for _, arg := range argv {
+		b.WriteString(" " + Quote(arg))
+	}
+	return b.String()
+}
+
+// Quote quotes s for a POSIX shell.
+func Quote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// run runs the script through the shell with stdin as its input and
+// returns its output. A failing script's error includes what it wrote to
+// stderr.
+func (sh Shell) run(ctx context.Context, script string, stdin []byte) ([]byte, error) {
+	argv, err := sh(script)
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return out, fmt.Errorf("%w: %s", err, msg)
+		}
+		return out, err
+	}
+	return out, nil
+}
+
+// pathError wraps an error of the file operation scripts, which exit with
+// exitNotExist if the path doesn't exist.
+func pathError(op, name string, err error) error {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == exitNotExist {
+		err = fs.ErrNotExist
+	}
+	return &fs.PathError{Op: op, Path: name, Err: err}
+}
+
+// ReadFile returns the contents of the file name. If it doesn't exist, the
+// error satisfies errors.Is(err, fs.ErrNotExist).
+func (sh Shell) ReadFile(ctx context.Context, name string) ([]byte, error) {
+	q := Quote(name)
+	data, err := sh.run(ctx, fmt.Sprintf("test -e %s || exit %d; exec cat -- %s", q, exitNotExist, q), nil)
+	if err != nil {
+		return nil, pathError("open", name, err)
+	}
+	return data, nil
+}
+
+// WriteFile writes data to the file name, creating it and its directory if
+// needed.
+func (sh Shell) WriteFile(ctx context.Context, name string, data []byte) error {
+	script := fmt.Sprintf("mkdir -p -- %s && cat > %s", Quote(path.Dir(name)), Quote(name))
+	if _, err := sh.run(ctx, script, data); err != nil {
+		return pathError("write", name, err)
+	}
+	return nil
+}
+
+// IsDir reports whether name is a directory. If it doesn't exist, the error
+// satisfies errors.Is(err, fs.ErrNotExist).
+func (sh Shell) IsDir(ctx context.Context, name string) (bool, error) {
+	q := Quote(name)
+	_, err := sh.run(ctx, fmt.Sprintf("test -d %s && exit 0; test -e %s && exit %d; exit %d", q, q, exitNotDir, exitNotExist), nil)
+	if err == nil {
+		return true, nil
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == exitNotDir {
+		return false, nil
+	}
+	return false, pathError("stat", name, err)
+}
+
+// Home returns the home directory of the user the shell runs as.
+func (sh Shell) Home(ctx context.Context) (string, error) {
+	out, err := sh.run(ctx, `printf '%s' "$HOME"`, nil)
+	if err != nil {
+		return "", err
+	}
+	if len(out) == 0 {
+		return "", errors.New("HOME is not set")
+	}
+	return string(out), nil
+}
+
+// Git runs git with args in dir and returns its output. It has the
+// signature of gitstate.Runner.
+func (sh Shell) Git(dir string, args ...string) ([]byte, error) {
+	return sh.run(context.Background(), script(dir, nil, append([]string{"git"}, args...)), nil)
+}
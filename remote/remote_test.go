@@ -0,0 +1,124 @@
+package remote
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeSSH puts an ssh on PATH that runs the script locally, ignoring the
+// options and destination.
+func fakeSSH(t *testing.T) {
+	t.Helper()
+	dir := t.TempDir()
+	script := "#!/bin/sh\nwhile [ \"$1\" != -- ]; do shift; done\nshift 2\nexec sh -c \"$1\"\n"
+	if err := os.WriteFile(filepath.Join(dir, "ssh"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func TestArgs(t *testing.T) {
+	h := &Host{Target: "me@box", Port: 2222, IdentityFile: "/keys/id"}
+	joined := strings.Join(h.Args("ls"), " ")
+	for _, want := range []string{"BatchMode=yes", "ControlMaster=auto", "-p 2222", "-i /keys/id", "-- me@box ls"} {
+		if !strings.Contains(joined, want) {
+			t.Errorf("args %q missing %q", joined, want)
+		}
+	}
+	if joined := strings.Join((&Host{Target: "box"}).Args("ls"), " "); strings.Contains(joined, "-p ") || strings.Contains(joined, "-i ") {
+		t.Errorf("unexpected args %q", joined)
+	}
+}
+
+func TestScript(t *testing.T) {
+	got := script("/work/it's", []string{"A=1 2"}, []string{"bash", "-c", "echo $A"})
+	want := `cd '/work/it'\''s' && exec env 'A=1 2' 'bash' '-c' 'echo $A'`
+	if got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestWrap(t *testing.T) {
+	fakeSSH(t)
+	dir := t.TempDir()
+	sh := (&Host{Target: "box"}).Shell()
+
+	cmd := exec.Command("bash", "-c", `pwd; echo "$GREETING"`)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GREETING=hello there")
+	if err := sh.Wrap(cmd); err != nil {
+		t.Fatal(err)
+	}
+	if cmd.Dir != "" || filepath.Base(cmd.Path) != "ssh" {
+		t.Errorf("unexpected command %s in %q", cmd.Path, cmd.Dir)
+	}
+	if strings.Contains(strings.Join(cmd.Args, " "), "PATH=") {
+		t.Errorf("expected only the added variables to be passed on, got %q", cmd.Args)
+	}
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(out); got != dir+"\nhello there\n" {
+		t.Errorf("unexpected output %q", got)
+	}
+}
+
+func TestFiles(t *testing.T) {
+	fakeSSH(t)
+	ctx := context.Background()
+	dir := t.TempDir()
+	sh := (&Host{Target: "box"}).Shell()
+
+	name := filepath.Join(dir, "a b", "new.txt")
+	if _, err := sh.ReadFile(ctx, name); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected a missing file to be ErrNotExist, got %v", err)
+	}
+	if err := sh.WriteFile(ctx, name, []byte("it's here\n")); err != nil {
+		t.Fatal(err)
+	}
+	data, err := sh.ReadFile(ctx, name)
+	if err != nil || string(data) != "it's here\n" {
+		t.Errorf("ReadFile = %q, %v", data, err)
+	}
+
+	for path, want := range map[string]bool{dir: true, name: false} {
+		if got, err := sh.IsDir(ctx, path); err != nil || got != want {
+			t.Errorf("IsDir(%s) = %v, %v; want %v", path, got, err, want)
+		}
+	}
+	if _, err := sh.IsDir(ctx, filepath.Join(dir, "nope")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected a missing directory to be ErrNotExist, got %v", err)
+	}
+
+	t.Setenv("HOME", dir)
+	if home, err := sh.Home(ctx); err != nil || home != dir {
+		t.Errorf("Home = %q, %v; want %s", home, err, dir)
+	}
+}
+
+func TestGit(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	fakeSSH(t)
+	dir := t.TempDir()
+	if out, err := exec.Command("git", "init", "-q", dir).CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v: %s", err, out)
+	}
+	out, err := (&Host{Target: "box"}).Shell().Git(dir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := filepath.EvalSymlinks(strings.TrimSpace(string(out))); got != dir {
+		if want, _ := filepath.EvalSymlinks(dir); got != want {
+			t.Errorf("got toplevel %q, want %q", got, dir)
+		}
+	}
+}
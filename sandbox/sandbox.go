@@ -0,0 +1,150 @@
+// Package sandbox runs tool commands under bubblewrap (bwrap), so that they
+// only see the directories they are given and the system directories
+// programs need to run, can only write to the former, and cannot reach the
+// network unless allowed. Commands get their own /proc and cannot see the
+// server's processes.
+//
+// bubblewrap is Linux-only. Where it is not installed, sandboxed commands
+// fail rather than run unrestricted.
+package sandbox
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// ErrUnavailable is returned when a command must be sandboxed but bwrap is not installed.
+var ErrUnavailable = errors.New("sandbox unavailable: bwrap (bubblewrap) is not installed")
+
+// Policy describes what sandboxed commands can reach.
+type Policy struct {
+	// Writable lists the directories commands can write to, usually
+	// starting with the workspace.
+	Writable []string
+	// Readable lists further directories commands can read. Outside the
+	// writable and readable directories, commands only see SystemDirs.
+	Readable []string
+	// Tmp is mounted at /tmp, so files there outlive a single command.
+	// If empty, each command gets an empty /tmp.
+	Tmp string
+	// Network allows network access.
+	Network bool
+}
+
+// SystemDirs are the directories and files mounted read-only in every
+// sandbox, where they exist, so that programs and their libraries run and
+// can resolve hosts and users. They leave out home directories, the rest of
+// /etc and anything else, such as Shelley's database, that commands have no
+// business reading.
+var SystemDirs = []string{
+	"/usr", "/bin", "/sbin", "/lib", "/lib32", "/lib64", "/libx32", "/opt",
+	"/etc/alternatives", "/etc/bash.bashrc", "/etc/ca-certificates", "/etc/gitconfig",
+	"/etc/group", "/etc/host.conf", "/etc/hostname", "/etc/hosts", "/etc/inputrc",
+	"/etc/ld.so.cache", "/etc/ld.so.conf", "/etc/ld.so.conf.d", "/etc/localtime",
+	"/etc/mime.types", "/etc/nsswitch.conf", "/etc/passwd", "/etc/pki", "/etc/profile",
+	"/etc/protocols", "/etc/resolv.conf", "/etc/services", "/etc/ssl", "/etc/timezone",
+}
+
+// Available reports whether bwrap is installed.
+func Available() bool {
+	_, err := exec.LookPath("bwrap")
+	return err == nil
+}
+
+// Args returns the bwrap arguments that run argv in dir under the policy.
+func (p *Policy) Args(dir string, argv []string) []string {
+	args := []string{
+		"--die-with-parent",
+		"--unshare-pid",
+		"--proc", "/proc",
+		"--dev", "/dev",
+	}
+	for _, dir := range SystemDirs {
+		args = append(args, "--ro-bind-try", dir, dir)
+	}
+	for _, r := range p.Readable {
+		args = append(args, "--ro-bind-try", r, r)
+	}
+	if !p.Network {
+		args = append(args, "--unshare-net")
+	}
+	if p.Tmp != "" {
+		args = append(args, "--bind", p.Tmp, "/tmp")
+	} else {
+		args = append(args, "--tmpfs", "/tmp")
+	}
+	for _, w := range p.Writable {
+		args = append(args, "--bind-try", w, w)
+	}
+	if dir != "" {
+		args = append(args, "--chdir", dir)
+	}
+	args = append(args, "--")
+	return append(args, argv...)
+}
+
+// Wrap rewrites cmd, which must not have been started, to run under the
+// policy. It returns ErrUnavailable if bwrap is not installed.
+func (p *Policy) Wrap(cmd *exec.Cmd) error {
+	bwrap, err := exec.LookPath("bwrap")
+	if err != nil {
+		return ErrUnavailable
+	}
+	cmd.Args = append([]string{"bwrap"}, p.Args(cmd.Dir, cmd.Args)...)
+	cmd.Path = bwrap
+	return nil
+}
+
+// Roots returns the directories commands can see besides SystemDirs and
+// /tmp: the writable directories, then the readable ones.
+func (p *Policy) Roots() []string {
+	return append(slices.Clone(p.Writable), p.Readable...)
+}
+
+// CanWrite reports whether path lies within one of the writable directories.
+// Symlinks are resolved as far as the path exists.
+func (p *Policy) CanWrite(path string) bool {
+	path = resolve(path)
+	for _, dir := range p.Writable {
+		dir = resolve(dir)
+		if path == dir || strings.HasPrefix(path, dir+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolve returns the absolute, symlink-free form of path, resolving its
+// longest existing prefix.
+func resolve(path string) string {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+	var rest []string
+	for {
+		if real, err := filepath.EvalSymlinks(path); err == nil {
+			return filepath.Join(append([]string{real}, rest...)...)
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return filepath.Join(append([]string{path}, rest...)...)
+		}
+		rest = append([]string{filepath.Base(path)}, rest...)
+		path = parent
+	}
+}
+
+// TmpDir returns a directory under the system temp directory to mount at
+// /tmp for the given key, creating it if needed.
+func TmpDir(key string) (string, error) {
+	dir := filepath.Join(os.TempDir(), "shelley-sandbox-"+key)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
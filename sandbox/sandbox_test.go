@@ -0,0 +1,99 @@
+package sandbox
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestArgs(t *testing.T) {
+	p := &Policy{Writable: []string{"/work"}, Readable: []string{"/data"}}
+	args := p.Args("/work/sub", []string{"bash", "-c", "ls"})
+	joined := strings.Join(args, " ")
+	if strings.Contains(joined, "--ro-bind / /") {
+		t.Errorf("args %q expose the whole filesystem", joined)
+	}
+	for _, want := range []string{"--unshare-pid", "--proc /proc", "--ro-bind-try /usr /usr", "--ro-bind-try /data /data", "--unshare-net", "--tmpfs /tmp", "--bind-try /work /work", "--chdir /work/sub", "-- bash -c ls"} {
+		if !strings.Contains(joined, want) {
+			t.Errorf("args %q missing %q", joined, want)
+		}
+	}
+
+	p = &Policy{Tmp: "/var/tmp/x", Network: true}
+	args = p.Args("", []string{"true"})
+	if slices.Contains(args, "--unshare-net") || slices.Contains(args, "--chdir") {
+		t.Errorf("unexpected args %q", args)
+	}
+	if !strings.Contains(strings.Join(args, " "), "--bind /var/tmp/x /tmp") {
+		t.Errorf("expected /tmp bind, got %q", args)
+	}
+}
+
+func TestCanWrite(t *testing.T) {
+	dir := t.TempDir()
+	work := filepath.Join(dir, "work")
+	if err := os.Mkdir(work, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("/etc", filepath.Join(work, "escape")); err != nil {
+		t.Fatal(err)
+	}
+	p := &Policy{Writable: []string{work}}
+
+	for path, want := range map[string]bool{
+		work:                                 true,
+		filepath.Join(work, "a/b/new.txt"):   true,
+		filepath.Join(work, "../other.txt"):  false,
+		filepath.Join(dir, "workshop/x.txt"): false,
+		filepath.Join(work, "escape/passwd"): false,
+		"/etc/passwd":                        false,
+	} {
+		if got := p.CanWrite(path); got != want {
+			t.Errorf("CanWrite(%s) = %v, want %v", path, got, want)
+		}
+	}
+}
+
+func TestWrap(t *testing.T) {
+	if !Available() {
+		t.Skip("bwrap not installed")
+	}
+	dir := t.TempDir()
+	work := filepath.Join(dir, "work")
+	if err := os.Mkdir(work, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	p := &Policy{Writable: []string{work}}
+
+	run := func(script string) error {
+		cmd := exec.Command("bash", "-c", script)
+		cmd.Dir = work
+		if err := p.Wrap(cmd); err != nil {
+			t.Fatal(err)
+		}
+		out, err := cmd.CombinedOutput()
+		t.Logf("%s: %s", script, out)
+		return err
+	}
+	if err := run("echo ok > inside.txt"); err != nil {
+		t.Errorf("write inside workspace failed: %v", err)
+	}
+	if err := run("echo no > ../outside.txt"); err == nil {
+		t.Error("write outside workspace succeeded")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "outside.txt")); err == nil {
+		t.Error("outside.txt was created")
+	}
+	if err := os.WriteFile(filepath.Join(dir, "secret.txt"), []byte("secret"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := run("cat ../secret.txt"); err == nil {
+		t.Error("read outside workspace succeeded")
+	}
+	if err := run(`test "$(ls /proc | grep -c '^[0-9]')" -lt 5`); err != nil {
+		t.Error("the server's processes are visible")
+	}
+}
@@ -74,6 +74,13 @@ func TestAgentWorking(t *testing.T) {
 			},
 			want: false,
 		},
+		{
+			name: "only the system prompt, as left by truncation",
+			messages: []APIMessage{
+				{Type: string(db.MessageTypeSystem)},
+			},
+			want: false,
+		},
 		{
 			name: "gitinfo after agent not end_of_turn should indicate working",
 			messages: []APIMessage{
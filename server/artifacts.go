@@ -0,0 +1,334 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"shelley.exe.dev/db/generated"
+)
+
+// Artifacts are files the agent publishes to a conversation with the
+// publish_artifact tool, such as build outputs, for the user to download
+// from /api/conversations/<id>/artifacts. Their rows are in the artifacts
+// table and their content in files under the artifact root, named by
+// artifact ID. Each artifact and each conversation's artifacts together
+// have a size limit, and artifacts expire after the retention period;
+// runArtifactRetention deletes the expired ones and removes the files of
+// those deleted with their conversation.
+
+// DefaultArtifactMaxSize is the largest artifact in bytes, unless
+// SetArtifactLimits says otherwise.
+const DefaultArtifactMaxSize = 100 << 20
+
+// DefaultArtifactQuota is the most bytes of artifacts a conversation has,
+// unless SetArtifactLimits says otherwise.
+const DefaultArtifactQuota = 1 << 30
+
+// DefaultArtifactRetention is how long artifacts are kept, unless
+// SetArtifactRetention says otherwise.
+const DefaultArtifactRetention = 7 * 24 * time.Hour
+
+// artifactRetentionInterval is how often expired artifacts are deleted.
+const artifactRetentionInterval = time.Hour
+
+// APIArtifact is an artifact as the API returns it.
+type APIArtifact struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	SHA256      string    `json:"sha256"`
+	CreatedAt   time.Time `json:"created_at"`
+	ExpiresAt   time.Time `json:"expires_at"`
+	URL         string    `json:"url"` // where it's downloaded
+}
+
+// SetArtifactLimits sets the largest artifact and the most bytes of
+// artifacts a conversation has.
+func (s *Server) SetArtifactLimits(maxSize, quota int64) {
+	s.artifactMaxSize = maxSize
+	s.artifactQuota = quota
+}
+
+// SetArtifactRetention sets how long artifacts are kept.
+func (s *Server) SetArtifactRetention(retention time.Duration) {
+	s.artifactRetention = retention
+}
+
+// artifactPath returns where an artifact's content is stored.
+func (s *Server) artifactPath(artifactID string) string {
+	return filepath.Join(s.artifactRoot, artifactID)
+}
+
+// artifactURL returns where an artifact is downloaded.
+func artifactURL(conversationID, artifactID string) string {
+	return fmt.Sprintf("/api/conversations/%s/artifacts/%s", conversationID, artifactID)
+}
+
+func toAPIArtifact(artifact generated.Artifact) APIArtifact {
+	return APIArtifact{
+		ID:          artifact.ArtifactID,
+		Name:        artifact.Name,
+		ContentType: artifact.ContentType,
+		Size:        artifact.Size,
+		SHA256:      artifact.Sha256,
+		CreatedAt:   artifact.CreatedAt,
+		ExpiresAt:   time.UnixMilli(artifact.ExpiresAt),
+		URL:         artifactURL(artifact.ConversationID, artifact.ArtifactID),
+	}
+}
+
+// newArtifactID returns a random artifact ID.
+func newArtifactID() string {
+	return "a" + strings.ToLower(rand.Text()[:12])
+}
+
+// publishArtifact returns the function the publish_artifact tool of a
+// conversation calls.
+func (s *Server) publishArtifact(conversationID string) func(ctx context.Context, name string, content io.Reader) (string, error) {
+	return func(ctx context.Context, name string, content io.Reader) (string, error) {
+		artifact, err := s.storeArtifact(ctx, conversationID, name, content)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Published %s (%d bytes) for the user to download at %s until %s.",
+			artifact.Name, artifact.Size, artifact.URL, artifact.ExpiresAt.Format(time.DateOnly)), nil
+	}
+}
+
+// storeArtifact stores content as an artifact of the conversation, unless it
+// would go over the size limits.
+func (s *Server) storeArtifact(ctx context.Context, conversationID, name string, content io.Reader) (APIArtifact, error) {
+	var used int64
+	err := s.db.Queries(ctx, func(q *generated.Queries) error {
+		var err error
+		used, err = q.GetArtifactsSize(ctx, conversationID)
+		return err
+	})
+	if err != nil {
+		return APIArtifact{}, err
+	}
+	if used >= s.artifactQuota {
+		return APIArtifact{}, fmt.Errorf("the conversation's artifacts already take up their %d byte quota", s.artifactQuota)
+	}
+
+	// Written apart until it has a row, under a name runArtifactRetention skips
+	if err := os.MkdirAll(s.artifactRoot, 0o755); err != nil {
+		return APIArtifact{}, err
+	}
+	tmp, err := os.CreateTemp(s.artifactRoot, ".receiving-*")
+	if err != nil {
+		return APIArtifact{}, err
+	}
+	defer os.Remove(tmp.Name())
+	hash := sha256.New()
+	head := &prefixWriter{n: 512}
+	size, err := io.Copy(io.MultiWriter(tmp, hash, head), io.LimitReader(content, s.artifactMaxSize+1))
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return APIArtifact{}, err
+	}
+	if size > s.artifactMaxSize {
+		return APIArtifact{}, fmt.Errorf("it's over the %d byte artifact size limit", s.artifactMaxSize)
+	}
+	if used+size > s.artifactQuota {
+		return APIArtifact{}, fmt.Errorf("it would take the conversation's artifacts over their %d byte quota", s.artifactQuota)
+	}
+
+	contentType := mime.TypeByExtension(filepath.Ext(name))
+	if contentType == "" {
+		contentType = http.DetectContentType(head.data)
+	}
+	var artifact generated.Artifact
+	err = s.db.QueriesTx(ctx, func(q *generated.Queries) error {
+		var err error
+		artifact, err = q.CreateArtifact(ctx, generated.CreateArtifactParams{
+			ArtifactID:     newArtifactID(),
+			ConversationID: conversationID,
+			Name:           name,
+			ContentType:    contentType,
+			Size:           size,
+			Sha256:         hex.EncodeToString(hash.Sum(nil)),
+			ExpiresAt:      time.Now().Add(s.artifactRetention).UnixMilli(),
+		})
+		return err
+	})
+	if err != nil {
+		return APIArtifact{}, fmt.Errorf("failed to record artifact: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.artifactPath(artifact.ArtifactID)); err != nil {
+		s.deleteArtifact(context.WithoutCancel(ctx), conversationID, artifact.ArtifactID)
+		return APIArtifact{}, err
+	}
+	return toAPIArtifact(artifact), nil
+}
+
+// prefixWriter keeps the first n bytes written to it.
+type prefixWriter struct {
+	n    int
+	data []byte
+}
+
+func (w *prefixWriter) Write(p []byte) (int, error) {
+	if room := w.n - len(w.data); room > 0 {
+		w.data = append(w.data, p[:min(room, len(p))]...)
+	}
+	return len(p), nil
+}
+
+// deleteArtifact deletes an artifact's row and removes its content.
+func (s *Server) deleteArtifact(ctx context.Context, conversationID, artifactID string) error {
+	err := s.db.QueriesTx(ctx, func(q *generated.Queries) error {
+		return q.DeleteArtifact(ctx, generated.DeleteArtifactParams{ConversationID: conversationID, ArtifactID: artifactID})
+	})
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(s.artifactPath(artifactID)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// runArtifactRetention deletes expired artifacts periodically until ctx is
+// done.
+func (s *Server) runArtifactRetention(ctx context.Context) {
+	ticker := time.NewTicker(artifactRetentionInterval)
+	defer ticker.Stop()
+	for {
+		if err := s.expireArtifacts(ctx); err != nil && ctx.Err() == nil {
+			s.logger.Warn("Failed to delete expired artifacts", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// expireArtifacts deletes the expired artifacts' rows, then removes the
+// content of the artifacts without one.
+func (s *Server) expireArtifacts(ctx context.Context) error {
+	var ids []string
+	err := s.db.QueriesTx(ctx, func(q *generated.Queries) error {
+		if _, err := q.DeleteExpiredArtifacts(ctx, time.Now().UnixMilli()); err != nil {
+			return err
+		}
+		var err error
+		ids, err = q.ListArtifactIDs(ctx)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	entries, err := os.ReadDir(s.artifactRoot)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	kept := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		kept[id] = true
+	}
+	for _, entry := range entries {
+		if kept[entry.Name()] || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		if err := os.Remove(filepath.Join(s.artifactRoot, entry.Name())); err != nil && !errors.Is(err, os.ErrNotExist) {
+			s.logger.Warn("Failed to remove artifact", "artifactID", entry.Name(), "error", err)
+		}
+	}
+	return nil
+}
+
+// handleArtifacts handles GET /api/conversations/<id>/artifacts, which lists
+// a conversation's artifacts.
+func (s *Server) handleArtifacts(w http.ResponseWriter, r *http.Request, conversationID string) {
+	ctx := r.Context()
+	if _, err := s.db.GetConversationByID(ctx, conversationID); err != nil {
+		http.Error(w, "Conversation not found", http.StatusNotFound)
+		return
+	}
+	var artifacts []generated.Artifact
+	err := s.db.Queries(ctx, func(q *generated.Queries) error {
+		var err error
+		artifacts, err = q.ListArtifacts(ctx, conversationID)
+		return err
+	})
+	if err != nil {
+		s.logger.Error("Failed to list artifacts", "conversationID", conversationID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	now := time.Now().UnixMilli()
+	result := []APIArtifact{}
+	for _, artifact := range artifacts {
+		if artifact.ExpiresAt > now {
+			result = append(result, toAPIArtifact(artifact))
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// handleArtifact handles GET and DELETE
+// /api/conversations/<id>/artifacts/<artifact>, which download and delete
+// an artifact.
+func (s *Server) handleArtifact(w http.ResponseWriter, r *http.Request, conversationID, artifactID string) {
+	ctx := r.Context()
+	var artifact generated.Artifact
+	err := s.db.Queries(ctx, func(q *generated.Queries) error {
+		var err error
+		artifact, err = q.GetArtifact(ctx, generated.GetArtifactParams{ConversationID: conversationID, ArtifactID: artifactID})
+		return err
+	})
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && artifact.ExpiresAt <= time.Now().UnixMilli()) {
+		http.Error(w, "Artifact not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		s.logger.Error("Failed to get artifact", "artifactID", artifactID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if r.Method == http.MethodDelete {
+		if err := s.deleteArtifact(ctx, conversationID, artifactID); err != nil {
+			s.logger.Error("Failed to delete artifact", "artifactID", artifactID, "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	f, err := os.Open(s.artifactPath(artifactID))
+	if err != nil {
+		s.logger.Error("Failed to open artifact", "artifactID", artifactID, "error", err)
+		http.Error(w, "Artifact not found", http.StatusNotFound)
+		return
+	}
+	defer f.Close()
+	w.Header().Set("Content-Type", artifact.ContentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": artifact.Name}))
+	w.Header().Set("ETag", `"`+artifact.Sha256+`"`)
+	http.ServeContent(w, r, artifact.Name, artifact.CreatedAt, f)
+}
@@ -0,0 +1,86 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestArtifacts(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+	h.server.artifactRoot = t.TempDir()
+	h.server.SetArtifactLimits(10, 15)
+	h.NewConversation("echo: hi", "")
+	h.WaitResponse()
+	ctx := t.Context()
+
+	publish := h.server.publishArtifact(h.convID)
+	result, err := publish(ctx, "report.txt", strings.NewReader("12345678"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(result, "/api/conversations/"+h.convID+"/artifacts/") {
+		t.Errorf("expected the download URL, got %q", result)
+	}
+	if _, err := publish(ctx, "big.bin", strings.NewReader("12345678901")); err == nil || !strings.Contains(err.Error(), "size limit") {
+		t.Errorf("expected an artifact over the size limit refused, got %v", err)
+	}
+	if _, err := publish(ctx, "more.txt", strings.NewReader("1234567890")); err == nil || !strings.Contains(err.Error(), "quota") {
+		t.Errorf("expected artifacts over the quota refused, got %v", err)
+	}
+
+	// It's listed
+	w := httptest.NewRecorder()
+	h.server.handleArtifacts(w, httptest.NewRequest("GET", "/", nil), h.convID)
+	var artifacts []APIArtifact
+	if err := json.Unmarshal(w.Body.Bytes(), &artifacts); err != nil {
+		t.Fatalf("failed to decode %s", w.Body.String())
+	}
+	if len(artifacts) != 1 || artifacts[0].Name != "report.txt" || artifacts[0].Size != 8 || !strings.HasPrefix(artifacts[0].ContentType, "text/plain") {
+		t.Fatalf("expected report.txt listed, got %+v", artifacts)
+	}
+	id := artifacts[0].ID
+
+	// And downloaded
+	w = httptest.NewRecorder()
+	h.server.handleArtifact(w, httptest.NewRequest("GET", "/", nil), h.convID, id)
+	if w.Code != http.StatusOK || w.Body.String() != "12345678" || !strings.Contains(w.Header().Get("Content-Disposition"), `filename=report.txt`) {
+		t.Errorf("expected report.txt downloaded, got %d %q %q", w.Code, w.Body.String(), w.Header().Get("Content-Disposition"))
+	}
+	w = httptest.NewRecorder()
+	h.server.handleArtifact(w, httptest.NewRequest("GET", "/", nil), "other", id)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected another conversation's artifact not found, got %d", w.Code)
+	}
+
+	// Until it expires, when its content is removed
+	h.server.SetArtifactRetention(-time.Minute)
+	if _, err := publish(ctx, "old.txt", strings.NewReader("old")); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.server.expireArtifacts(ctx); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := os.ReadDir(h.server.artifactRoot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != id {
+		t.Errorf("expected only %s kept, got %v", id, entries)
+	}
+
+	// Or is deleted
+	w = httptest.NewRecorder()
+	h.server.handleArtifact(w, httptest.NewRequest("DELETE", "/", nil), h.convID, id)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", w.Code)
+	}
+	if _, err := os.Stat(h.server.artifactPath(id)); !os.IsNotExist(err) {
+		t.Errorf("expected its content removed, got %v", err)
+	}
+}
@@ -0,0 +1,119 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"shelley.exe.dev/claudetool"
+)
+
+// errNoPendingQuestion is returned when answering a question nobody is waiting on.
+var errNoPendingQuestion = errors.New("no question is waiting for an answer")
+
+// AnswerRequest is the body of POST /api/conversation/<id>/answer.
+type AnswerRequest struct {
+	ToolUseID string `json:"tool_use_id"`
+	Answer    string `json:"answer"`
+}
+
+// askUser is the AskUser hook of the manager's tools. The loop waits until
+// the question is answered through AnswerQuestion or the turn is cancelled.
+func (cm *ConversationManager) askUser(ctx context.Context, question claudetool.Question) (string, error) {
+	toolUseID := claudetool.ToolUseID(ctx)
+	if toolUseID == "" {
+		return "", errors.New("missing tool use ID")
+	}
+
+	answer := make(chan string, 1)
+	cm.mu.Lock()
+	if cm.questions == nil {
+		cm.questions = make(map[string]chan string)
+	}
+	cm.questions[toolUseID] = answer
+	cm.mu.Unlock()
+	defer func() {
+		cm.mu.Lock()
+		delete(cm.questions, toolUseID)
+		cm.mu.Unlock()
+	}()
+
+	cm.logger.Info("Waiting for the user to answer a question", "toolUseID", toolUseID)
+	if cm.onQuestion != nil {
+		go cm.onQuestion(question)
+	}
+	select {
+	case a := <-answer:
+		return a, nil
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// AnswerQuestion delivers the user's answer to the ask_user call toolUseID.
+func (cm *ConversationManager) AnswerQuestion(toolUseID, answer string) error {
+	cm.mu.Lock()
+	ch, ok := cm.questions[toolUseID]
+	delete(cm.questions, toolUseID)
+	cm.mu.Unlock()
+	if !ok {
+		return errNoPendingQuestion
+	}
+	ch <- answer
+	return nil
+}
+
+// pendingQuestion returns the tool use ID of a question waiting for an
+// answer, if there is one.
+func (cm *ConversationManager) pendingQuestion() (string, bool) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	for toolUseID := range cm.questions {
+		return toolUseID, true
+	}
+	return "", false
+}
+
+// handleAnswer handles POST /conversation/<id>/answer, which answers a
+// question the agent asked with the ask_user tool.
+func (s *Server) handleAnswer(w http.ResponseWriter, r *http.Request, conversationID string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req AnswerRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if req.ToolUseID == "" || req.Answer == "" {
+		http.Error(w, "tool_use_id and answer are required", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.answerQuestion(conversationID, req.ToolUseID, req.Answer); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "answered"})
+}
+
+// answerQuestion answers the question the agent asked in a conversation
+// with the ask_user call toolUseID.
+func (s *Server) answerQuestion(conversationID, toolUseID, answer string) error {
+	s.mu.Lock()
+	manager, ok := s.activeConversations[conversationID]
+	s.mu.Unlock()
+	if !ok {
+		return errNoPendingQuestion
+	}
+	if err := manager.AnswerQuestion(toolUseID, answer); err != nil {
+		return err
+	}
+	manager.Touch()
+	return nil
+}
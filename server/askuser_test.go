@@ -0,0 +1,57 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func (h *TestHarness) answer(body string) *httptest.ResponseRecorder {
+	h.t.Helper()
+	w := httptest.NewRecorder()
+	h.server.handleAnswer(w, httptest.NewRequest("POST", "/api/conversation/"+h.convID+"/answer", strings.NewReader(body)), h.convID)
+	return w
+}
+
+func TestAskUser(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+
+	h.NewConversation("ask: Which database? | sqlite | postgres", t.TempDir())
+	h.server.mu.Lock()
+	manager := h.server.activeConversations[h.convID]
+	h.server.mu.Unlock()
+
+	// Wait for the agent to ask
+	var toolUseID string
+	deadline := time.Now().Add(h.timeout)
+	for toolUseID == "" {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the question")
+		}
+		manager.mu.Lock()
+		for id := range manager.questions {
+			toolUseID = id
+		}
+		manager.mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if w := h.answer(`{"tool_use_id": "nope", "answer": "sqlite"}`); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for a question nobody asked, got %d", w.Code)
+	}
+	if w := h.answer(`{"tool_use_id": "` + toolUseID + `"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 without an answer, got %d", w.Code)
+	}
+	if w := h.answer(`{"tool_use_id": "` + toolUseID + `", "answer": "postgres"}`); w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if result := h.WaitToolResult(); result != "postgres" {
+		t.Errorf("expected the answer as the tool result, got %q", result)
+	}
+	if w := h.answer(`{"tool_use_id": "` + toolUseID + `", "answer": "again"}`); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for an answered question, got %d", w.Code)
+	}
+}
@@ -0,0 +1,490 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"shelley.exe.dev/claudetool"
+	"shelley.exe.dev/db"
+	"shelley.exe.dev/db/generated"
+	"shelley.exe.dev/gitstate"
+	"shelley.exe.dev/llm"
+)
+
+const (
+	// maxBridgeTools caps the tool calls listed in a reply.
+	maxBridgeTools = 10
+	// maxBridgeToolSummary caps each tool call's summary.
+	maxBridgeToolSummary = 80
+	// bridgeSeenTTL is how long a chat message is remembered so a
+	// redelivered event isn't sent to the agent twice.
+	bridgeSeenTTL = 10 * time.Minute
+	// botSettingsPoll is how often bots that connect to their platform
+	// check the settings while they aren't configured.
+	botSettingsPoll = 30 * time.Second
+)
+
+// bridgeHTTPClient is used to post replies to chat platforms.
+var bridgeHTTPClient = &http.Client{Timeout: 30 * time.Second}
+
+// BotSettings configures the chat bots, which run conversations from
+// messages in chat apps and post the agent's replies back.
+type BotSettings struct {
+	Slack    *SlackBotSettings    `json:"slack,omitempty"`
+	Discord  *DiscordBotSettings  `json:"discord,omitempty"`
+	Telegram *TelegramBotSettings `json:"telegram,omitempty"`
+}
+
+// BotConversationSettings are how a bot starts conversations.
+type BotConversationSettings struct {
+	// Cwd is the working directory of conversations started from chat.
+	Cwd string `json:"cwd,omitempty"`
+	// Model is the model they use; empty means the server default.
+	Model string `json:"model,omitempty"`
+}
+
+func (b *BotSettings) validate(hasModel func(string) bool) error {
+	if b == nil {
+		return nil
+	}
+	if err := b.Slack.validate(hasModel); err != nil {
+		return err
+	}
+	if err := b.Discord.validate(hasModel); err != nil {
+		return err
+	}
+	return b.Telegram.validate(hasModel)
+}
+
+// bridgePoster returns the poster for platform, or nil if its bot isn't
+// configured.
+func (s *Server) bridgePoster(bots *BotSettings, platform string) bridgePoster {
+	if bots == nil {
+		return nil
+	}
+	switch platform {
+	case slackPlatform:
+		if bots.Slack != nil {
+			return &slackPoster{token: bots.Slack.BotToken}
+		}
+	case discordPlatform:
+		if bots.Discord != nil {
+			return &discordPoster{token: bots.Discord.Token, status: &s.discordStatus}
+		}
+	case telegramPlatform:
+		if bots.Telegram != nil {
+			return &telegramPoster{token: bots.Telegram.Token, status: &s.telegramStatus}
+		}
+	}
+	return nil
+}
+
+func (c BotConversationSettings) validate(hasModel func(string) bool) error {
+	if c.Cwd != "" && !filepath.IsAbs(c.Cwd) {
+		return fmt.Errorf("bot cwd must be an absolute path: %s", c.Cwd)
+	}
+	if c.Model != "" && !hasModel(c.Model) {
+		return fmt.Errorf("unknown bot model: %s", c.Model)
+	}
+	return nil
+}
+
+// bridgeReply is a message posted to a chat thread.
+type bridgeReply struct {
+	// Text is the agent's last reply, the error that ended the turn, or a
+	// question.
+	Text string
+	// Tools summarizes the turn's tool calls, one line each.
+	Tools []string
+	Error bool
+	// Question is set when the agent asked something with ask_user, and
+	// Options are its suggested answers.
+	Question bool
+	Options  []string
+	// URL links to the conversation, if the base URL is configured.
+	URL string
+	// Seq is the sequence ID of the last message the reply covers, which
+	// orders status updates against the reply that ends the turn.
+	Seq int64
+}
+
+// bridgePoster posts replies to a chat platform. threadID is in the
+// platform's own format.
+type bridgePoster interface {
+	postReply(ctx context.Context, threadID string, reply bridgeReply) error
+}
+
+// bridgeStatusPoster is implemented by platforms that show the agent's
+// progress while it works, updated as it calls tools. The status is
+// replaced by the reply when the turn ends.
+type bridgeStatusPoster interface {
+	postStatus(ctx context.Context, threadID string, status bridgeReply) error
+}
+
+// bridgeStatus tracks the status message of each thread's running turn,
+// for platforms that show one.
+type bridgeStatus struct {
+	mu   sync.Mutex
+	ids  map[string]string // thread ID to status message ID
+	seqs map[string]int64  // thread ID to Seq of the last turn's reply
+}
+
+// show posts the status of a thread's turn with post, or updates it with
+// edit once posted, unless the reply covering seq has been posted.
+func (b *bridgeStatus) show(threadID string, seq int64, post func() (string, error), edit func(id string) error) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if seq <= b.seqs[threadID] {
+		// The turn already ended
+		return nil
+	}
+	if id, ok := b.ids[threadID]; ok {
+		return edit(id)
+	}
+	id, err := post()
+	if id != "" {
+		if b.ids == nil {
+			b.ids = make(map[string]string)
+		}
+		b.ids[threadID] = id
+	}
+	return err
+}
+
+// end removes a thread's status message with remove and posts its reply
+// with post. Statuses up to seq are ignored from then on.
+func (b *bridgeStatus) end(threadID string, seq int64, remove func(id string) error, post func() error) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if seq > b.seqs[threadID] {
+		if b.seqs == nil {
+			b.seqs = make(map[string]int64)
+		}
+		b.seqs[threadID] = seq
+	}
+	if id, ok := b.ids[threadID]; ok {
+		delete(b.ids, threadID)
+		if err := remove(id); err != nil {
+			return err
+		}
+	}
+	return post()
+}
+
+// bridgeMessage sends a message from a chat thread to the thread's
+// conversation, starting one if the thread is new. If the agent is waiting
+// on a question the message answers it, and if the agent is busy it is
+// queued for the end of the turn. It returns the conversation ID.
+func (s *Server) bridgeMessage(ctx context.Context, platform, threadID, text string, opts BotConversationSettings) (string, error) {
+	conversationID, modelID, err := s.bridgeConversation(ctx, platform, threadID, opts)
+	if err != nil {
+		return "", err
+	}
+	llmService, err := s.llmManager.GetService(modelID)
+	if err != nil {
+		return "", fmt.Errorf("unsupported model %s: %w", modelID, err)
+	}
+	manager, err := s.getOrCreateConversationManager(ctx, conversationID)
+	if err != nil {
+		return "", err
+	}
+
+	if toolUseID, ok := manager.pendingQuestion(); ok {
+		if err := manager.AnswerQuestion(toolUseID, text); err == nil {
+			manager.Touch()
+			return conversationID, nil
+		}
+	}
+	if _, queued, err := s.queueUserMessage(ctx, manager, text, modelID, nil); err != nil || queued {
+		return conversationID, err
+	}
+
+	if err := s.acceptMessage(ctx, manager, llmService, modelID, text, nil); err != nil {
+		return "", err
+	}
+	return conversationID, nil
+}
+
+// bridgeConversation returns the conversation a chat thread is mapped to
+// and its model, creating the conversation if the thread is new.
+func (s *Server) bridgeConversation(ctx context.Context, platform, threadID string, opts BotConversationSettings) (string, string, error) {
+	s.bridgeMu.Lock()
+	defer s.bridgeMu.Unlock()
+
+	thread, err := s.db.GetBridgeThread(ctx, platform, threadID)
+	if err == nil {
+		conversation, err := s.db.GetConversationByID(ctx, thread.ConversationID)
+		if err != nil {
+			return "", "", err
+		}
+		modelID := opts.Model
+		if conversation.ModelID != nil {
+			modelID = *conversation.ModelID
+		}
+		return conversation.ConversationID, s.bridgeModel(modelID), nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return "", "", err
+	}
+
+	modelID := s.bridgeModel(opts.Model)
+	var cwdPtr, gitOriginPtr *string
+	if opts.Cwd != "" {
+		cwdPtr = &opts.Cwd
+		if origin := gitstate.GetGitOrigin(opts.Cwd); origin != "" {
+			gitOriginPtr = &origin
+		}
+	}
+	conversation, err := s.db.CreateConversation(ctx, nil, true, cwdPtr, gitOriginPtr, &modelID)
+	if err != nil {
+		return "", "", err
+	}
+	if _, err := s.db.CreateBridgeThread(ctx, platform, threadID, conversation.ConversationID); err != nil {
+		return "", "", err
+	}
+	return conversation.ConversationID, modelID, nil
+}
+
+// bridgeModel returns modelID, or the server default if it is empty.
+func (s *Server) bridgeModel(modelID string) string {
+	if modelID == "" {
+		modelID = s.defaultModel
+	}
+	if modelID == "" {
+		// As for conversations started in the UI
+		modelID = "qwen3-coder-fireworks"
+	}
+	return modelID
+}
+
+// bridgeManager returns the active manager of a chat thread's conversation,
+// or nil if the thread has no conversation or it isn't running.
+func (s *Server) bridgeManager(ctx context.Context, platform, threadID string) (*ConversationManager, error) {
+	thread, err := s.db.GetBridgeThread(ctx, platform, threadID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.activeConversations[thread.ConversationID], nil
+}
+
+// bridgeStop cancels the agent's turn in a chat thread's conversation,
+// dropping any queued messages.
+func (s *Server) bridgeStop(ctx context.Context, platform, threadID string) error {
+	manager, err := s.bridgeManager(ctx, platform, threadID)
+	if manager == nil {
+		return err
+	}
+	manager.removeQueued("")
+	return manager.CancelConversation(ctx)
+}
+
+// bridgeAnswer answers the question the agent is waiting on in a chat
+// thread's conversation, if there is one.
+func (s *Server) bridgeAnswer(ctx context.Context, platform, threadID, answer string) error {
+	manager, err := s.bridgeManager(ctx, platform, threadID)
+	if manager == nil {
+		return err
+	}
+	toolUseID, ok := manager.pendingQuestion()
+	if !ok {
+		return nil
+	}
+	if err := manager.AnswerQuestion(toolUseID, answer); err != nil && !errors.Is(err, errNoPendingQuestion) {
+		return err
+	}
+	manager.Touch()
+	return nil
+}
+
+// bridgeSeen reports whether a chat message was already handled, recording
+// it if not. key identifies the message on its platform.
+func (s *Server) bridgeSeen(key string) bool {
+	s.bridgeMu.Lock()
+	defer s.bridgeMu.Unlock()
+	now := time.Now()
+	for k, seen := range s.bridgeSeenAt {
+		if now.Sub(seen) > bridgeSeenTTL {
+			delete(s.bridgeSeenAt, k)
+		}
+	}
+	if _, ok := s.bridgeSeenAt[key]; ok {
+		return true
+	}
+	if s.bridgeSeenAt == nil {
+		s.bridgeSeenAt = make(map[string]time.Time)
+	}
+	s.bridgeSeenAt[key] = now
+	return false
+}
+
+// bridgeThread returns the chat thread a conversation was started from and
+// a poster for its platform, or a nil poster if there is none.
+func (s *Server) bridgeThread(ctx context.Context, conversationID string) (*generated.BridgeThread, bridgePoster, Settings) {
+	thread, err := s.db.GetBridgeThreadByConversation(ctx, conversationID)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			s.logger.Warn("Failed to get chat thread", "conversationID", conversationID, "error", err)
+		}
+		return nil, nil, Settings{}
+	}
+	settings, err := GetSettings(ctx, s.db)
+	if err != nil {
+		s.logger.Warn("Failed to get settings for chat reply", "error", err)
+		return nil, nil, Settings{}
+	}
+	return thread, s.bridgePoster(settings.Bots, thread.Platform), settings
+}
+
+// bridgeMessages lists a conversation's messages for a chat reply.
+func (s *Server) bridgeMessages(ctx context.Context, conversationID string) ([]generated.Message, error) {
+	var messages []generated.Message
+	err := s.db.Queries(ctx, func(q *generated.Queries) error {
+		var err error
+		messages, err = q.ListMessages(ctx, conversationID)
+		return err
+	})
+	return messages, err
+}
+
+// bridgeTurnEnd posts the turn that just ended to the conversation's chat
+// thread, if it has one.
+func (s *Server) bridgeTurnEnd(ctx context.Context, conversationID string) {
+	thread, poster, settings := s.bridgeThread(ctx, conversationID)
+	if poster == nil {
+		return
+	}
+	messages, err := s.bridgeMessages(ctx, conversationID)
+	if err != nil {
+		s.logger.Warn("Failed to list messages for chat reply", "conversationID", conversationID, "error", err)
+		return
+	}
+	reply := turnReply(messages)
+	reply.URL = conversationURL(settings, conversationID)
+	if err := poster.postReply(ctx, thread.ThreadID, reply); err != nil {
+		s.logger.Warn("Failed to post chat reply", "conversationID", conversationID, "platform", thread.Platform, "error", err)
+	}
+}
+
+// bridgeProgress updates the status shown in the conversation's chat thread
+// after the agent calls a tool, if the platform shows one.
+func (s *Server) bridgeProgress(ctx context.Context, conversationID string) {
+	thread, poster, _ := s.bridgeThread(ctx, conversationID)
+	statusPoster, ok := poster.(bridgeStatusPoster)
+	if !ok {
+		return
+	}
+	messages, err := s.bridgeMessages(ctx, conversationID)
+	if err != nil {
+		s.logger.Warn("Failed to list messages for chat status", "conversationID", conversationID, "error", err)
+		return
+	}
+	status := turnReply(messages)
+	if len(status.Tools) == 0 {
+		return
+	}
+	if err := statusPoster.postStatus(ctx, thread.ThreadID, status); err != nil {
+		s.logger.Warn("Failed to post chat status", "conversationID", conversationID, "platform", thread.Platform, "error", err)
+	}
+}
+
+// bridgeQuestion posts a question the agent asked to the conversation's chat
+// thread, if it has one. The next message in the thread answers it.
+func (s *Server) bridgeQuestion(ctx context.Context, conversationID string, question claudetool.Question) {
+	thread, poster, settings := s.bridgeThread(ctx, conversationID)
+	if poster == nil {
+		return
+	}
+	reply := bridgeReply{
+		Text:     question.Question,
+		Question: true,
+		Options:  question.Options,
+		URL:      conversationURL(settings, conversationID),
+	}
+	if err := poster.postReply(ctx, thread.ThreadID, reply); err != nil {
+		s.logger.Warn("Failed to post chat question", "conversationID", conversationID, "platform", thread.Platform, "error", err)
+	}
+}
+
+// conversationURL links to a conversation if the base URL is configured.
+func conversationURL(settings Settings, conversationID string) string {
+	if settings.Notifications == nil || settings.Notifications.BaseURL == "" {
+		return ""
+	}
+	return strings.TrimRight(settings.Notifications.BaseURL, "/") + "/c/" + conversationID
+}
+
+// turnReply summarizes the last turn of a conversation: the agent's last
+// text, or the error that ended it, and its tool calls.
+func turnReply(messages []generated.Message) bridgeReply {
+	var reply bridgeReply
+	var tools []string
+	for _, msg := range messages {
+		reply.Seq = msg.SequenceID
+		var m llm.Message
+		if msg.LlmData != nil {
+			json.Unmarshal([]byte(*msg.LlmData), &m)
+		}
+		switch db.MessageType(msg.Type) {
+		case db.MessageTypeUser:
+			if isUserText(m) {
+				// A new turn
+				reply, tools = bridgeReply{Seq: msg.SequenceID}, nil
+			}
+		case db.MessageTypeAgent:
+			if text := strings.TrimSpace(messageText(msg)); text != "" {
+				reply.Text = text
+				reply.Error = false
+			}
+			for _, c := range m.Content {
+				if c.Type == llm.ContentTypeToolUse {
+					tools = append(tools, toolSummary(c.ToolName, c.ToolInput))
+				}
+			}
+		case db.MessageTypeError:
+			reply.Text = strings.TrimSpace(messageText(msg))
+			reply.Error = true
+		}
+	}
+	if len(tools) > maxBridgeTools {
+		tools = append(tools[:maxBridgeTools], fmt.Sprintf("...and %d more", len(tools)-maxBridgeTools))
+	}
+	reply.Tools = tools
+	return reply
+}
+
+// toolSummary describes a tool call in one line: its name and its main
+// argument, or its input if it has none of the usual ones.
+func toolSummary(name string, input json.RawMessage) string {
+	summary := string(input)
+	var fields map[string]any
+	if json.Unmarshal(input, &fields) == nil {
+		for _, key := range []string{"command", "path", "query", "question", "url"} {
+			if v, ok := fields[key].(string); ok && v != "" {
+				summary = v
+				break
+			}
+		}
+	}
+	return name + ": " + truncateText(strings.Join(strings.Fields(summary), " "), maxBridgeToolSummary)
+}
+
+// truncateText shortens text to at most n bytes, marking the cut.
+func truncateText(text string, n int) string {
+	if len(text) <= n {
+		return text
+	}
+	return strings.ToValidUTF8(text[:n], "") + "..."
+}
@@ -0,0 +1,87 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"shelley.exe.dev/broadcast"
+	"shelley.exe.dev/db/generated"
+)
+
+// broadcastRetryInterval is how long the server waits before subscribing
+// again after losing its broadcaster's connection.
+const broadcastRetryInterval = 5 * time.Second
+
+// conversationBroadcast is a conversation update as servers sharing a
+// broadcaster send it to each other.
+type conversationBroadcast struct {
+	// Origin is the instance ID of the server that published it, which
+	// delivered it to its own clients already.
+	Origin       string                 `json:"origin"`
+	Conversation generated.Conversation `json:"conversation"`
+}
+
+// SetBroadcaster sets how conversation updates reach the clients of the
+// other servers sharing the database. Without one, they only reach this
+// server's clients.
+func (s *Server) SetBroadcaster(b broadcast.Broadcaster) {
+	s.broadcaster = b
+}
+
+// publishConversation delivers a conversation update to the clients of this
+// server, and through the broadcaster to those of the other servers.
+func (s *Server) publishConversation(ctx context.Context, conversation generated.Conversation) {
+	s.deliverConversation(conversation)
+	if s.broadcaster == nil {
+		return
+	}
+	data, err := json.Marshal(conversationBroadcast{Origin: s.instanceID, Conversation: conversation})
+	if err != nil {
+		s.logger.Error("Failed to encode conversation broadcast", "conversationID", conversation.ConversationID, "error", err)
+		return
+	}
+	if err := s.broadcaster.Publish(ctx, data); err != nil {
+		s.logger.Warn("Failed to broadcast conversation update", "conversationID", conversation.ConversationID, "error", err)
+	}
+}
+
+// deliverConversation sends a conversation update to this server's clients.
+func (s *Server) deliverConversation(conversation generated.Conversation) {
+	s.mu.Lock()
+	s.metaSeq++
+	seq := s.metaSeq
+	s.mu.Unlock()
+
+	s.metaSubPub.Publish(seq, conversation)
+}
+
+// receiveBroadcasts delivers the conversation updates other servers publish
+// to this server's clients, until ctx is done.
+func (s *Server) receiveBroadcasts(ctx context.Context) {
+	if s.broadcaster == nil {
+		return
+	}
+	receive := func(data []byte) {
+		var update conversationBroadcast
+		if err := json.Unmarshal(data, &update); err != nil {
+			s.logger.Warn("Ignoring malformed conversation broadcast", "error", err)
+			return
+		}
+		if update.Origin != s.instanceID {
+			s.deliverConversation(update.Conversation)
+		}
+	}
+	for {
+		err := s.broadcaster.Subscribe(ctx, receive)
+		if ctx.Err() != nil {
+			return
+		}
+		s.logger.Warn("Lost conversation broadcasts; subscribing again", "error", err, "retry", broadcastRetryInterval)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(broadcastRetryInterval):
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"shelley.exe.dev/broadcast"
+	"shelley.exe.dev/db/generated"
+)
+
+func TestConversationBroadcast(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+	hub := broadcast.NewMemory()
+	other := h.otherInstance()
+	h.server.SetBroadcaster(hub)
+	other.SetBroadcaster(hub)
+	h.NewConversation("echo: hi", t.TempDir())
+	h.WaitResponse()
+
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	go h.server.receiveBroadcasts(ctx)
+	go other.receiveBroadcasts(ctx)
+
+	received := make(chan generated.Conversation, 100)
+	next := other.metaSubPub.Subscribe(ctx, 0)
+	go func() {
+		for {
+			conversation, ok := next()
+			if !ok {
+				return
+			}
+			received <- conversation
+		}
+	}()
+
+	// Publish until the other server's subscription is up
+	var got generated.Conversation
+	for deadline := time.Now().Add(h.timeout); got.ConversationID == ""; {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the update to reach the other server")
+		}
+		h.server.broadcastConversationUpdate(ctx, h.convID)
+		select {
+		case got = <-received:
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+	if got.ConversationID != h.convID {
+		t.Errorf("expected an update of %s, got %s", h.convID, got.ConversationID)
+	}
+
+	// A server doesn't deliver its own updates again when the broadcaster
+	// echoes them; the hub delivers in order, so the echo would come first
+	h.server.mu.Lock()
+	local := h.server.metaSubPub.Subscribe(ctx, h.server.metaSeq)
+	h.server.mu.Unlock()
+	for _, update := range []conversationBroadcast{
+		{Origin: h.server.instanceID, Conversation: generated.Conversation{ConversationID: "echo"}},
+		{Origin: other.instanceID, Conversation: generated.Conversation{ConversationID: "other"}},
+	} {
+		data, _ := json.Marshal(update)
+		hub.Publish(ctx, data)
+	}
+	for {
+		conversation, ok := local()
+		if !ok {
+			t.Fatal("subscription ended")
+		}
+		if conversation.ConversationID == "echo" {
+			t.Fatal("expected the server's own update not delivered again")
+		}
+		if conversation.ConversationID == "other" {
+			break
+		}
+	}
+}
@@ -0,0 +1,381 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"shelley.exe.dev/claudetool"
+	"shelley.exe.dev/db/generated"
+	"shelley.exe.dev/notify"
+)
+
+// A conversation with CI auto-fix on watches its pull request's checks.
+// When they have all finished on a new head commit and some failed, the
+// failures, with the logs of failed GitHub Actions jobs, are sent to the
+// agent as a new turn asking it to fix them and push. Each head commit is
+// sent once, and watching stops when the pull request is closed or the
+// conversation runs out of attempts or budget.
+
+const (
+	// ciAutofixPoll is how often pull requests' checks are looked at.
+	ciAutofixPoll = time.Minute
+	// defaultCIAutofixAttempts is how many fixes the agent is asked for
+	// when the request doesn't say.
+	defaultCIAutofixAttempts = 3
+
+	ciAutofixWatching = "watching"
+	ciAutofixAttempts = "attempts"
+	ciAutofixBudget   = "budget"
+	ciAutofixClosed   = "closed"
+)
+
+// githubPullPattern matches a pull request URL, capturing the repository
+// and the pull request's number.
+var githubPullPattern = regexp.MustCompile(`^https://github\.com/([^/]+/[^/]+)/pull/(\d+)$`)
+
+// CIAutofixRequest is the body of POST /api/conversation/<id>/ci-autofix,
+// which turns auto-fix on, starting its attempts and budget over.
+type CIAutofixRequest struct {
+	// PRURL is the pull request to watch; the conversation's most recent
+	// linked pull request if empty.
+	PRURL string `json:"prUrl,omitempty"`
+	// MaxAttempts is how many times the agent is asked for a fix; 3 if 0.
+	MaxAttempts int64 `json:"maxAttempts,omitempty"`
+	// MaxCostUSD stops auto-fix once the conversation has cost this much
+	// since it was turned on; 0 for no limit.
+	MaxCostUSD float64 `json:"maxCostUsd,omitempty"`
+}
+
+// CIAutofixStatus is a conversation's auto-fix state. Status is "watching",
+// or why watching stopped: "attempts", "budget" or "closed".
+type CIAutofixStatus struct {
+	PRURL       string    `json:"prUrl"`
+	MaxAttempts int64     `json:"maxAttempts"`
+	MaxCostUSD  float64   `json:"maxCostUsd,omitempty"`
+	Attempts    int64     `json:"attempts"`
+	LastSHA     string    `json:"lastSha,omitempty"`
+	Status      string    `json:"status"`
+	CostUSD     float64   `json:"costUsd"`
+	Since       time.Time `json:"since"`
+}
+
+// prChecks is a pull request's state and checks, as gh pr view reports them.
+type prChecks struct {
+	State      string    `json:"state"`
+	HeadRefOid string    `json:"headRefOid"`
+	Checks     []prCheck `json:"statusCheckRollup"`
+}
+
+// prCheck is a check run (GitHub Actions and other apps) or a commit status.
+type prCheck struct {
+	Typename   string `json:"__typename"`
+	Name       string `json:"name"`
+	Status     string `json:"status"`
+	Conclusion string `json:"conclusion"`
+	DetailsURL string `json:"detailsUrl"`
+	// Commit statuses
+	Context   string `json:"context"`
+	State     string `json:"state"`
+	TargetURL string `json:"targetUrl"`
+}
+
+func (c prCheck) pending() bool {
+	if c.Typename == "StatusContext" {
+		return c.State == "PENDING" || c.State == "EXPECTED"
+	}
+	return c.Status != "COMPLETED"
+}
+
+// failed reports whether a finished check failed. Cancelled runs, usually
+// superseded by a newer push, don't count.
+func (c prCheck) failed() bool {
+	if c.Typename == "StatusContext" {
+		return c.State == "FAILURE" || c.State == "ERROR"
+	}
+	return slices.Contains([]string{"FAILURE", "TIMED_OUT", "STARTUP_FAILURE", "ACTION_REQUIRED"}, c.Conclusion)
+}
+
+func (c prCheck) name() string {
+	if c.Typename == "StatusContext" {
+		return c.Context
+	}
+	return c.Name
+}
+
+func (c prCheck) url() string {
+	if c.Typename == "StatusContext" {
+		return c.TargetURL
+	}
+	return c.DetailsURL
+}
+
+// actionsRunPattern matches a GitHub Actions job URL, capturing the run ID.
+var actionsRunPattern = regexp.MustCompile(`/actions/runs/(\d+)`)
+
+func ciAutofixStatus(a *generated.CiAutofix, cost float64) CIAutofixStatus {
+	return CIAutofixStatus{
+		PRURL:       a.PrUrl,
+		MaxAttempts: a.MaxAttempts,
+		MaxCostUSD:  a.MaxCostUsd,
+		Attempts:    a.Attempts,
+		LastSHA:     a.LastSha,
+		Status:      a.Status,
+		CostUSD:     cost,
+		Since:       a.CreatedAt,
+	}
+}
+
+// handleCIAutofix handles GET, POST and DELETE /conversation/<id>/ci-autofix.
+func (s *Server) handleCIAutofix(w http.ResponseWriter, r *http.Request, conversationID string) {
+	ctx := r.Context()
+	conversation, err := s.db.GetConversationByID(ctx, conversationID)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Conversation not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		s.logger.Error("Failed to get conversation", "conversationID", conversationID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	var autofix *generated.CiAutofix
+	switch r.Method {
+	case http.MethodGet:
+		autofix, err = s.db.GetCIAutofix(ctx, conversationID)
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "CI auto-fix is off", http.StatusNotFound)
+			return
+		}
+	case http.MethodPost:
+		var req CIAutofixRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+		if req.PRURL == "" {
+			req.PRURL = latestPullURL(conversation)
+		}
+		if !githubPullPattern.MatchString(req.PRURL) {
+			http.Error(w, "prUrl must be a GitHub pull request URL; the conversation has no linked pull request", http.StatusBadRequest)
+			return
+		}
+		if req.MaxAttempts < 0 || req.MaxCostUSD < 0 {
+			http.Error(w, "maxAttempts and maxCostUsd can't be negative", http.StatusBadRequest)
+			return
+		}
+		if req.MaxAttempts == 0 {
+			req.MaxAttempts = defaultCIAutofixAttempts
+		}
+		autofix, err = s.db.SetCIAutofix(ctx, conversationID, req.PRURL, req.MaxAttempts, req.MaxCostUSD)
+	case http.MethodDelete:
+		if err := s.db.DeleteCIAutofix(ctx, conversationID); err != nil {
+			s.logger.Error("Failed to turn off CI auto-fix", "conversationID", conversationID, "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err != nil {
+		s.logger.Error("Failed to get CI auto-fix", "conversationID", conversationID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	cost, err := s.db.GetCIAutofixCost(ctx, conversationID)
+	if err != nil {
+		s.logger.Warn("Failed to get CI auto-fix cost", "conversationID", conversationID, "error", err)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ciAutofixStatus(autofix, cost))
+}
+
+// latestPullURL returns the pull request most recently linked to the
+// conversation, or "" if there is none.
+func latestPullURL(conversation *generated.Conversation) string {
+	if conversation.GithubUrls == nil {
+		return ""
+	}
+	var urls []string
+	json.Unmarshal([]byte(*conversation.GithubUrls), &urls)
+	for _, url := range slices.Backward(urls) {
+		if githubPullPattern.MatchString(url) {
+			return url
+		}
+	}
+	return ""
+}
+
+// runCIAutofix checks the watched pull requests every ciAutofixPoll until
+// ctx is done.
+func (s *Server) runCIAutofix(ctx context.Context) {
+	ticker := time.NewTicker(ciAutofixPoll)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		autofixes, err := s.db.ListWatchingCIAutofix(ctx)
+		if err != nil {
+			s.logger.Warn("Failed to list CI auto-fix conversations", "error", err)
+			continue
+		}
+		for _, autofix := range autofixes {
+			if err := s.checkCIAutofix(ctx, &autofix); err != nil {
+				s.logger.Warn("CI auto-fix check failed", "conversationID", autofix.ConversationID, "pr", autofix.PrUrl, "error", err)
+			}
+		}
+	}
+}
+
+// checkCIAutofix looks at a watched pull request's checks and, if they
+// failed on a head commit the agent hasn't been sent yet, sends it the
+// failures, or stops watching if the pull request is closed or a limit is
+// reached. Nothing happens while checks are running or the agent is busy.
+func (s *Server) checkCIAutofix(ctx context.Context, autofix *generated.CiAutofix) error {
+	conversationID := autofix.ConversationID
+	conversation, err := s.db.GetConversationByID(ctx, conversationID)
+	if err != nil {
+		return err
+	}
+	cwd := os.TempDir()
+	if conversation.Cwd != nil && *conversation.Cwd != "" {
+		cwd = *conversation.Cwd
+	}
+
+	cmd := exec.CommandContext(ctx, "gh", "pr", "view", autofix.PrUrl, "--json", "state,headRefOid,statusCheckRollup")
+	cmd.Dir = cwd
+	out, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			err = fmt.Errorf("%w: %s", err, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return fmt.Errorf("gh pr view: %w", err)
+	}
+	var pr prChecks
+	if err := json.Unmarshal(out, &pr); err != nil {
+		return fmt.Errorf("failed to parse gh pr view output: %w", err)
+	}
+
+	if pr.State == "MERGED" || pr.State == "CLOSED" {
+		return s.db.UpdateCIAutofix(ctx, conversationID, autofix.Attempts, autofix.LastSha, ciAutofixClosed)
+	}
+	if pr.HeadRefOid == "" || pr.HeadRefOid == autofix.LastSha || slices.ContainsFunc(pr.Checks, prCheck.pending) {
+		return nil
+	}
+	var failed []prCheck
+	for _, check := range pr.Checks {
+		if check.failed() {
+			failed = append(failed, check)
+		}
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+	if busy, err := s.agentBusyNow(ctx, conversationID); err != nil || busy {
+		return err
+	}
+
+	stop := ""
+	if autofix.Attempts >= autofix.MaxAttempts {
+		stop = fmt.Sprintf("CI auto-fix stopped: checks still failing on %s after %d attempts", autofix.PrUrl, autofix.Attempts)
+		autofix.Status = ciAutofixAttempts
+	} else if autofix.MaxCostUsd > 0 {
+		cost, err := s.db.GetCIAutofixCost(ctx, conversationID)
+		if err != nil {
+			return err
+		}
+		if cost >= autofix.MaxCostUsd {
+			stop = fmt.Sprintf("CI auto-fix stopped: checks still failing on %s and the conversation has cost $%.2f of its $%.2f budget", autofix.PrUrl, cost, autofix.MaxCostUsd)
+			autofix.Status = ciAutofixBudget
+		}
+	}
+	if stop != "" {
+		if err := s.db.UpdateCIAutofix(ctx, conversationID, autofix.Attempts, autofix.LastSha, autofix.Status); err != nil {
+			return err
+		}
+		s.notifyConversation(ctx, conversationID, notify.KindError, stop)
+		return nil
+	}
+
+	// Record the attempt first, so a failure to send isn't retried forever.
+	autofix.Attempts++
+	if err := s.db.UpdateCIAutofix(ctx, conversationID, autofix.Attempts, pr.HeadRefOid, ciAutofixWatching); err != nil {
+		return err
+	}
+	repo := githubPullPattern.FindStringSubmatch(autofix.PrUrl)[1]
+	text := ciAutofixMessage(autofix, pr.HeadRefOid, failed, s.actionsFailures(ctx, cwd, repo, failed))
+
+	modelID := s.bridgeModel("")
+	if conversation.ModelID != nil {
+		modelID = *conversation.ModelID
+	}
+	llmService, err := s.llmManager.GetService(modelID)
+	if err != nil {
+		return fmt.Errorf("unsupported model %s: %w", modelID, err)
+	}
+	manager, err := s.getOrCreateConversationManager(ctx, conversationID)
+	if err != nil {
+		return err
+	}
+	return s.acceptMessage(ctx, manager, llmService, modelID, text, nil)
+}
+
+// actionsFailures summarizes the failed GitHub Actions runs among checks,
+// with the github_actions tool's report of each.
+func (s *Server) actionsFailures(ctx context.Context, cwd, repo string, checks []prCheck) []string {
+	tool := &claudetool.GitHubActionsTool{
+		Bash: &claudetool.BashTool{WorkingDir: claudetool.NewMutableWorkingDir(cwd)},
+		Repo: repo,
+	}
+	var runs []string
+	var reports []string
+	for _, check := range checks {
+		match := actionsRunPattern.FindStringSubmatch(check.url())
+		if match == nil || slices.Contains(runs, match[1]) {
+			continue
+		}
+		runs = append(runs, match[1])
+		out := tool.Run(ctx, json.RawMessage(`{"run_id": `+match[1]+`, "timeout_minutes": 1}`))
+		switch {
+		case out.Error != nil:
+			reports = append(reports, out.Error.Error())
+		case len(out.LLMContent) > 0:
+			reports = append(reports, out.LLMContent[0].Text)
+		}
+	}
+	return reports
+}
+
+// ciAutofixMessage is the turn sent to the agent to fix failed checks.
+func ciAutofixMessage(autofix *generated.CiAutofix, sha string, failed []prCheck, reports []string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "CI failed on %s at %s. Fix the failures, then commit and push to the pull request's branch. ", autofix.PrUrl, sha[:min(len(sha), 12)])
+	fmt.Fprintf(&b, "(CI auto-fix attempt %d of %d.)\n\nFailed checks:", autofix.Attempts, autofix.MaxAttempts)
+	for _, check := range failed {
+		fmt.Fprintf(&b, "\n- %s", check.name())
+		if url := check.url(); url != "" {
+			b.WriteString(": " + url)
+		}
+	}
+	for _, report := range reports {
+		b.WriteString("\n\n" + report)
+	}
+	return b.String()
+}
@@ -0,0 +1,155 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"shelley.exe.dev/db"
+	"shelley.exe.dev/db/generated"
+)
+
+// fakeCIGH is a gh that reports the pull request in pr.json next to it,
+// and logs for GitHub Actions run 42.
+const fakeCIGH = `#!/bin/sh
+dir=$(dirname "$0")
+case "$1 $2" in
+"pr view") cat "$dir/pr.json" ;;
+"run view")
+	case "$4" in
+	--json)
+		if [ "$5" = jobs ]; then
+			echo '{"jobs":[{"name":"test","conclusion":"failure","steps":[{"name":"Run go test","conclusion":"failure"}]}]}'
+		else
+			echo '{"databaseId":42,"workflowName":"CI","event":"pull_request","status":"completed","conclusion":"failure"}'
+		fi
+		;;
+	--log-failed) printf 'test\tRun go test\t2024-05-01T12:00:05.0000000Z --- FAIL: TestAdd\n' ;;
+	esac
+	;;
+*) exit 1 ;;
+esac
+`
+
+func TestCIAutofix(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+	bin := t.TempDir()
+	if err := os.WriteFile(filepath.Join(bin, "gh"), []byte(fakeCIGH), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+":"+os.Getenv("PATH"))
+	setPR := func(state, sha, checks string) {
+		t.Helper()
+		pr := `{"state":"` + state + `","headRefOid":"` + sha + `","statusCheckRollup":[` + checks + `]}`
+		if err := os.WriteFile(filepath.Join(bin, "pr.json"), []byte(pr), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	h.NewConversation("echo: hello", t.TempDir())
+	h.WaitResponse()
+	id := h.ConversationID()
+
+	mux := http.NewServeMux()
+	h.server.RegisterRoutes(mux)
+	do := func(method, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/api/conversation/"+id+"/ci-autofix", strings.NewReader(body))
+		req.Header.Set("X-Shelley-Request", "1")
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w
+	}
+	if w := do("GET", ""); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 while auto-fix is off, got %d", w.Code)
+	}
+	if w := do("POST", `{}`); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 without a linked pull request, got %d", w.Code)
+	}
+	w := do("POST", `{"prUrl": "https://github.com/o/r/pull/7", "maxAttempts": 1}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var status CIAutofixStatus
+	json.Unmarshal(w.Body.Bytes(), &status)
+	if status.Status != ciAutofixWatching || status.MaxAttempts != 1 {
+		t.Errorf("expected auto-fix to be watching, got %+v", status)
+	}
+
+	ctx := context.Background()
+	check := func() *generated.CiAutofix {
+		t.Helper()
+		autofix, err := h.db.GetCIAutofix(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := h.server.checkCIAutofix(ctx, autofix); err != nil {
+			t.Fatal(err)
+		}
+		autofix, _ = h.db.GetCIAutofix(ctx, id)
+		return autofix
+	}
+	fixRequests := func() []string {
+		t.Helper()
+		var messages []generated.Message
+		h.db.Queries(ctx, func(q *generated.Queries) error {
+			var err error
+			messages, err = q.ListMessages(ctx, id)
+			return err
+		})
+		var texts []string
+		for _, msg := range messages {
+			if msg.Type == string(db.MessageTypeUser) && msg.LlmData != nil && strings.Contains(string(*msg.LlmData), "CI failed on") {
+				texts = append(texts, string(*msg.LlmData))
+			}
+		}
+		return texts
+	}
+
+	failedChecks := `{"__typename":"CheckRun","name":"test","status":"COMPLETED","conclusion":"FAILURE","detailsUrl":"https://github.com/o/r/actions/runs/42/job/1"},` +
+		`{"__typename":"StatusContext","context":"ci/other","state":"FAILURE","targetUrl":"https://ci.example.com/1"}`
+	setPR("OPEN", "aaaa", failedChecks+`,{"__typename":"CheckRun","name":"lint","status":"IN_PROGRESS"}`)
+	if check(); len(fixRequests()) != 0 {
+		t.Error("expected no fix request while checks are running")
+	}
+
+	setPR("OPEN", "aaaa", failedChecks)
+	autofix := check()
+	requests := fixRequests()
+	if len(requests) != 1 || autofix.Attempts != 1 || autofix.LastSha != "aaaa" {
+		t.Fatalf("expected one fix request, got %d with %+v", len(requests), autofix)
+	}
+	for _, want := range []string{"https://github.com/o/r/pull/7", "attempt 1 of 1", "ci/other: https://ci.example.com/1", "--- FAIL: TestAdd"} {
+		if !strings.Contains(requests[0], want) {
+			t.Errorf("expected %q in the fix request: %s", want, requests[0])
+		}
+	}
+	h.WaitResponse()
+
+	if check(); len(fixRequests()) != 1 {
+		t.Error("expected the same head commit not to be sent twice")
+	}
+
+	setPR("OPEN", "bbbb", failedChecks)
+	if autofix := check(); autofix.Status != ciAutofixAttempts || len(fixRequests()) != 1 {
+		t.Errorf("expected auto-fix to stop after its attempts, got %+v", autofix)
+	}
+
+	do("POST", `{"prUrl": "https://github.com/o/r/pull/7"}`)
+	setPR("MERGED", "bbbb", "")
+	if autofix := check(); autofix.Status != ciAutofixClosed {
+		t.Errorf("expected auto-fix to stop when the pull request is merged, got %+v", autofix)
+	}
+
+	if w := do("DELETE", ""); w.Code != http.StatusNoContent {
+		t.Errorf("expected 204, got %d", w.Code)
+	}
+	if w := do("GET", ""); w.Code != http.StatusNotFound {
+		t.Errorf("expected auto-fix to be off, got %d", w.Code)
+	}
+}
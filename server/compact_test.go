@@ -0,0 +1,169 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"shelley.exe.dev/db"
+	"shelley.exe.dev/llm"
+	"shelley.exe.dev/loop"
+)
+
+func (h *TestHarness) compact() *httptest.ResponseRecorder {
+	h.t.Helper()
+	req := httptest.NewRequest("POST", "/api/conversation/"+h.convID+"/compact", nil)
+	w := httptest.NewRecorder()
+	h.server.handleCompactConversation(w, req, h.convID)
+	return w
+}
+
+func TestCompactConversation(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+
+	h.NewConversation("echo: first message", "")
+	h.WaitResponse()
+	h.Chat("echo: second message")
+	h.WaitResponse()
+
+	// The loop may still be finishing the turn after the response is recorded.
+	var w *httptest.ResponseRecorder
+	deadline := time.Now().Add(h.timeout)
+	for {
+		w = h.compact()
+		if w.Code != http.StatusConflict || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	summaries, err := h.db.ListMessagesByType(context.Background(), h.convID, db.MessageTypeSummary)
+	if err != nil {
+		t.Fatalf("failed to list summary messages: %v", err)
+	}
+	if len(summaries) != 1 {
+		t.Fatalf("expected 1 summary message, got %d", len(summaries))
+	}
+
+	// Original messages are kept for display
+	users, err := h.db.ListMessagesByType(context.Background(), h.convID, db.MessageTypeUser)
+	if err != nil {
+		t.Fatalf("failed to list user messages: %v", err)
+	}
+	if len(users) != 2 {
+		t.Fatalf("expected 2 user messages to be kept, got %d", len(users))
+	}
+
+	getReq := httptest.NewRequest("GET", "/api/conversation/"+h.convID, nil)
+	getW := httptest.NewRecorder()
+	h.server.handleGetConversation(getW, getReq, h.convID)
+	var resp StreamResponse
+	if err := json.Unmarshal(getW.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to parse conversation: %v", err)
+	}
+	if resp.AgentWorking {
+		t.Error("expected agent not to be working after compaction")
+	}
+
+	h.Chat("echo: third message")
+	if got := h.WaitResponse(); got != "third message" {
+		t.Fatalf("expected echo of third message, got %q", got)
+	}
+
+	req := h.llm.GetLastRequest()
+	if req == nil || len(req.Messages) == 0 {
+		t.Fatal("expected an LLM request after compaction")
+	}
+	first := req.Messages[0]
+	if first.Role != llm.MessageRoleUser || !strings.HasPrefix(first.Content[0].Text, loop.SummaryPrefix) {
+		t.Fatalf("expected history to start with the summary, got %+v", first)
+	}
+	for _, msg := range req.Messages {
+		for _, c := range msg.Content {
+			if strings.Contains(c.Text, "first message") {
+				t.Fatalf("compacted message was sent to the LLM: %q", c.Text)
+			}
+		}
+	}
+}
+
+func TestCompactConversationRehydrates(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+
+	h.NewConversation("echo: first message", "")
+	h.WaitResponse()
+
+	deadline := time.Now().Add(h.timeout)
+	for {
+		w := h.compact()
+		if w.Code == http.StatusOK {
+			break
+		}
+		if w.Code != http.StatusConflict || time.Now().After(deadline) {
+			t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	manager := NewConversationManager(h.convID, h.db, h.server.logger, h.server.toolSetConfig, nil, nil, "")
+	if err := manager.Hydrate(context.Background()); err != nil {
+		t.Fatalf("Hydrate failed: %v", err)
+	}
+	if len(manager.history) != 1 || !strings.HasPrefix(manager.history[0].Content[0].Text, loop.SummaryPrefix) {
+		t.Fatalf("expected hydrated history to be just the summary, got %d messages", len(manager.history))
+	}
+}
+
+func TestCompactConversationKeepsTurnsAfterReload(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+
+	h.NewConversation("echo: first message", "")
+	h.WaitResponse()
+	h.Chat("echo: second message")
+	h.WaitResponse()
+
+	deadline := time.Now().Add(h.timeout)
+	for {
+		w := h.compact()
+		if w.Code == http.StatusOK {
+			break
+		}
+		if w.Code != http.StatusConflict || time.Now().After(deadline) {
+			t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	summaries, err := h.db.ListMessagesByType(context.Background(), h.convID, db.MessageTypeSummary)
+	if err != nil || len(summaries) != 1 || summaryKept(summaries[0]) == 0 {
+		t.Fatalf("expected a summary keeping the last turn, got %+v (%v)", summaries, err)
+	}
+
+	// The kept turn is still sent once the conversation is reloaded
+	h.server.mu.Lock()
+	h.server.activeConversations[h.convID].stopLoop()
+	delete(h.server.activeConversations, h.convID)
+	h.server.mu.Unlock()
+	h.Chat("echo: third message")
+	h.WaitResponse()
+
+	var texts []string
+	for _, msg := range h.llm.GetLastRequest().Messages {
+		for _, c := range msg.Content {
+			texts = append(texts, c.Text)
+		}
+	}
+	all := strings.Join(texts, "\n")
+	if !strings.HasPrefix(texts[0], loop.SummaryPrefix) || !strings.Contains(all, "second message") || strings.Contains(all, "first message") {
+		t.Fatalf("expected the summary and the kept turn, got %q", all)
+	}
+}
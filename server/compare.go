@@ -0,0 +1,319 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"shelley.exe.dev/db"
+	"shelley.exe.dev/db/generated"
+	"shelley.exe.dev/llm"
+)
+
+// A model comparison sends a conversation's next turn to two models at
+// once, each in a shadow conversation: an archived copy of the
+// conversation that may only use read-only tools, so neither changes the
+// workspace. The original conversation is left as it was. The user can
+// record which response was better, and the tally of those picks helps
+// choose a default model.
+
+// maxListedComparisons caps GET /api/comparisons.
+const maxListedComparisons = 100
+
+// CompareSettings configures model comparisons.
+type CompareSettings struct {
+	// Models are the two models a comparison uses unless the request
+	// names them.
+	Models []string `json:"models,omitempty"`
+}
+
+// validate checks that the comparison models are two available models.
+func (c *CompareSettings) validate(hasModel func(string) bool) error {
+	if c == nil || len(c.Models) == 0 {
+		return nil
+	}
+	if len(c.Models) != 2 {
+		return fmt.Errorf("compare needs two models, not %d", len(c.Models))
+	}
+	for _, model := range c.Models {
+		if !hasModel(model) {
+			return fmt.Errorf("unknown compare model: %s", model)
+		}
+	}
+	return nil
+}
+
+// CompareRequest is the body of POST /api/conversation/<id>/compare.
+type CompareRequest struct {
+	Message string `json:"message"`
+	// Models defaults to the compare settings' models.
+	Models []string `json:"models,omitempty"`
+}
+
+// ComparisonSide is one model's response in a comparison.
+type ComparisonSide struct {
+	Model          string `json:"model"`
+	ConversationID string `json:"conversation_id"`
+	Working        bool   `json:"working"`
+	// Response is the model's last text of the turn, or the error that
+	// ended it.
+	Response string    `json:"response"`
+	Error    bool      `json:"error,omitempty"`
+	Tools    []string  `json:"tools,omitempty"`
+	Usage    llm.Usage `json:"usage"`
+}
+
+// ComparisonResponse is a model comparison. Sides is left out of lists.
+type ComparisonResponse struct {
+	ComparisonID   string           `json:"comparison_id"`
+	ConversationID string           `json:"conversation_id"`
+	Message        string           `json:"message"`
+	Winner         string           `json:"winner,omitempty"`
+	CreatedAt      time.Time        `json:"created_at"`
+	Sides          []ComparisonSide `json:"sides,omitempty"`
+}
+
+// ComparisonsResponse lists the latest comparisons and how often each
+// model was picked as the winner among them.
+type ComparisonsResponse struct {
+	Comparisons []ComparisonResponse `json:"comparisons"`
+	Wins        map[string]int       `json:"wins"`
+}
+
+// handleCompare handles POST /conversation/<id>/compare.
+func (s *Server) handleCompare(w http.ResponseWriter, r *http.Request, conversationID string) {
+	ctx := r.Context()
+	var req CompareRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if req.Message == "" {
+		http.Error(w, "Message is required", http.StatusBadRequest)
+		return
+	}
+	if req.Models == nil {
+		settings, err := GetSettings(ctx, s.db)
+		if err != nil {
+			http.Error(w, "Failed to load settings", http.StatusInternalServerError)
+			return
+		}
+		if settings.Compare != nil {
+			req.Models = settings.Compare.Models
+		}
+	}
+	if len(req.Models) != 2 {
+		http.Error(w, "A comparison needs two models; name them in the request or the compare settings", http.StatusBadRequest)
+		return
+	}
+	services := make([]llm.Service, 2)
+	for i, model := range req.Models {
+		service, err := s.llmManager.GetService(model)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Unsupported model: %s", model), http.StatusBadRequest)
+			return
+		}
+		services[i] = service
+	}
+
+	conversation, err := s.db.GetConversationByID(ctx, conversationID)
+	if err != nil {
+		http.Error(w, "Conversation not found", http.StatusNotFound)
+		return
+	}
+	messages, err := s.checkAgentIdle(ctx, conversationID)
+	if errors.Is(err, errAgentBusy) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		s.logger.Error("Failed to list messages", "conversationID", conversationID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	shadows := make([]string, 2)
+	for i, model := range req.Models {
+		shadows[i], err = s.createShadowConversation(ctx, conversation, messages, model)
+		if err != nil {
+			s.logger.Error("Failed to create shadow conversation", "conversationID", conversationID, "error", err)
+			http.Error(w, "Failed to create the comparison", http.StatusInternalServerError)
+			return
+		}
+	}
+	comparison, err := s.db.CreateModelComparison(ctx, db.ModelComparisonParams{
+		ConversationID: conversationID,
+		Message:        req.Message,
+		ModelA:         req.Models[0],
+		ConversationA:  shadows[0],
+		ModelB:         req.Models[1],
+		ConversationB:  shadows[1],
+	})
+	if err != nil {
+		s.logger.Error("Failed to record comparison", "conversationID", conversationID, "error", err)
+		http.Error(w, "Failed to create the comparison", http.StatusInternalServerError)
+		return
+	}
+
+	for i, model := range req.Models {
+		manager, err := s.getOrCreateConversationManager(ctx, shadows[i])
+		if err == nil {
+			err = s.acceptMessage(ctx, manager, services[i], model, req.Message, nil)
+		}
+		if err != nil {
+			http.Error(w, "Failed to send the message to "+model, http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(s.comparisonResponse(ctx, comparison, true))
+}
+
+// createShadowConversation copies a conversation's messages into a new,
+// archived conversation that uses model and may only use read-only tools.
+func (s *Server) createShadowConversation(ctx context.Context, conversation *generated.Conversation, messages []generated.Message, model string) (string, error) {
+	shadow, err := s.db.CreateConversation(ctx, nil, true, conversation.Cwd, conversation.GitOrigin, &model)
+	if err != nil {
+		return "", err
+	}
+	if err := s.copyMessages(ctx, shadow.ConversationID, messages); err != nil {
+		return "", err
+	}
+	if err := s.copyConversationWorkspace(ctx, conversation, shadow.ConversationID); err != nil {
+		return "", err
+	}
+	var allowed []string
+	if conversation.AllowedTools != nil {
+		if err := json.Unmarshal([]byte(*conversation.AllowedTools), &allowed); err != nil {
+			return "", err
+		}
+	}
+	if err := s.setConversationAllowedTools(ctx, shadow.ConversationID, readOnlyAllowed(allowed, s.toolNames())); err != nil {
+		return "", err
+	}
+	if _, err := s.db.ArchiveConversation(ctx, shadow.ConversationID); err != nil {
+		return "", err
+	}
+	return shadow.ConversationID, nil
+}
+
+// comparisonResponse describes a comparison, with each model's response
+// if withSides is set.
+func (s *Server) comparisonResponse(ctx context.Context, comparison *generated.ModelComparison, withSides bool) ComparisonResponse {
+	resp := ComparisonResponse{
+		ComparisonID:   comparison.ComparisonID,
+		ConversationID: comparison.ConversationID,
+		Message:        comparison.Message,
+		CreatedAt:      comparison.CreatedAt,
+	}
+	if comparison.Winner != nil {
+		resp.Winner = *comparison.Winner
+	}
+	if !withSides {
+		return resp
+	}
+	for _, side := range [][2]string{
+		{comparison.ModelA, comparison.ConversationA},
+		{comparison.ModelB, comparison.ConversationB},
+	} {
+		resp.Sides = append(resp.Sides, s.comparisonSide(ctx, side[0], side[1]))
+	}
+	return resp
+}
+
+// comparisonSide summarizes a shadow conversation's latest turn.
+func (s *Server) comparisonSide(ctx context.Context, model, conversationID string) ComparisonSide {
+	side := ComparisonSide{Model: model, ConversationID: conversationID}
+	var messages []generated.Message
+	if err := s.db.Queries(ctx, func(q *generated.Queries) error {
+		var err error
+		messages, err = q.ListMessages(ctx, conversationID)
+		return err
+	}); err != nil {
+		s.logger.Warn("Failed to list comparison messages", "conversationID", conversationID, "error", err)
+		return side
+	}
+	side.Working = s.agentBusy(conversationID, messages)
+	reply := turnReply(messages)
+	side.Response, side.Error, side.Tools = reply.Text, reply.Error, reply.Tools
+	for _, msg := range messages {
+		var m llm.Message
+		if msg.LlmData != nil {
+			json.Unmarshal([]byte(*msg.LlmData), &m)
+		}
+		if msg.Type == string(db.MessageTypeUser) && isUserText(m) {
+			side.Usage = llm.Usage{}
+		}
+		if msg.UsageData != nil {
+			var usage llm.Usage
+			if json.Unmarshal([]byte(*msg.UsageData), &usage) == nil {
+				side.Usage.Add(usage)
+			}
+		}
+	}
+	return side
+}
+
+// handleComparisons handles GET /api/comparisons.
+func (s *Server) handleComparisons(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	comparisons, err := s.db.ListModelComparisons(ctx, maxListedComparisons)
+	if err != nil {
+		s.logger.Error("Failed to list comparisons", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	resp := ComparisonsResponse{Comparisons: []ComparisonResponse{}, Wins: map[string]int{}}
+	for i := range comparisons {
+		resp.Comparisons = append(resp.Comparisons, s.comparisonResponse(ctx, &comparisons[i], false))
+		if winner := comparisons[i].Winner; winner != nil && *winner != "tie" {
+			resp.Wins[*winner]++
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// handleComparison handles GET /api/comparisons/{id}, and POST
+// /api/comparisons/{id}/winner with {"winner": model} to record the
+// model whose response was better, or "tie".
+func (s *Server) handleComparison(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	comparison, err := s.db.GetModelComparison(ctx, r.PathValue("id"))
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Comparison not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		s.logger.Error("Failed to get comparison", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if r.Method == http.MethodPost {
+		var req struct {
+			Winner string `json:"winner"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+		if req.Winner != comparison.ModelA && req.Winner != comparison.ModelB && req.Winner != "tie" {
+			http.Error(w, fmt.Sprintf("winner must be %s, %s or tie", comparison.ModelA, comparison.ModelB), http.StatusBadRequest)
+			return
+		}
+		if err := s.db.UpdateModelComparisonWinner(ctx, comparison.ComparisonID, req.Winner); err != nil {
+			s.logger.Error("Failed to record comparison winner", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		comparison.Winner = &req.Winner
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.comparisonResponse(ctx, comparison, true))
+}
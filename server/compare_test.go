@@ -0,0 +1,95 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestModelComparison(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+	h.NewConversation("echo: hello", t.TempDir())
+	h.WaitResponse()
+
+	mux := http.NewServeMux()
+	h.server.RegisterRoutes(mux)
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("X-Shelley-Request", "1")
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := do("POST", "/api/conversation/"+h.convID+"/compare", `{"message": "echo: which?"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 without models to compare, got %d", w.Code)
+	}
+	w := do("POST", "/api/conversation/"+h.convID+"/compare", `{"message": "echo: which?", "models": ["predictable", "other"]}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var comparison ComparisonResponse
+	json.Unmarshal(w.Body.Bytes(), &comparison)
+	if len(comparison.Sides) != 2 || comparison.Sides[0].Model != "predictable" || comparison.Sides[1].Model != "other" {
+		t.Fatalf("unexpected comparison: %+v", comparison)
+	}
+
+	// Wait for both models to respond
+	deadline := time.Now().Add(h.timeout)
+	for {
+		w = do("GET", "/api/comparisons/"+comparison.ComparisonID, "")
+		json.Unmarshal(w.Body.Bytes(), &comparison)
+		if !comparison.Sides[0].Working && !comparison.Sides[1].Working &&
+			comparison.Sides[0].Response != "" && comparison.Sides[1].Response != "" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for the responses: %+v", comparison)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	for _, side := range comparison.Sides {
+		if !strings.Contains(side.Response, "which?") || side.Usage.OutputTokens == 0 {
+			t.Errorf("unexpected response from %s: %+v", side.Model, side)
+		}
+	}
+
+	ctx := context.Background()
+	if h.userTexts("which?") != 0 {
+		t.Error("expected the compared conversation to be left as it was")
+	}
+	shadow, err := h.db.GetConversationByID(ctx, comparison.Sides[1].ConversationID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tools []string
+	if shadow.AllowedTools != nil {
+		json.Unmarshal([]byte(*shadow.AllowedTools), &tools)
+	}
+	if !shadow.Archived || *shadow.ModelID != "other" || !slices.Contains(tools, "keyword_search") || slices.Contains(tools, "bash") {
+		t.Errorf("expected an archived, read-only shadow using the model, got %+v with tools %v", shadow, tools)
+	}
+	shadowHarness := *h
+	shadowHarness.convID = shadow.ConversationID
+	if shadowHarness.userTexts("hello") != 1 {
+		t.Error("expected the shadow to continue the conversation's history")
+	}
+
+	if w := do("POST", "/api/comparisons/"+comparison.ComparisonID+"/winner", `{"winner": "nobody"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a model outside the comparison, got %d", w.Code)
+	}
+	if w := do("POST", "/api/comparisons/"+comparison.ComparisonID+"/winner", `{"winner": "other"}`); w.Code != http.StatusOK {
+		t.Errorf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var list ComparisonsResponse
+	json.Unmarshal(do("GET", "/api/comparisons", "").Body.Bytes(), &list)
+	if len(list.Comparisons) != 1 || list.Comparisons[0].Winner != "other" || list.Wins["other"] != 1 {
+		t.Errorf("unexpected comparisons: %+v", list)
+	}
+}
@@ -0,0 +1,168 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"shelley.exe.dev/db/generated"
+	"shelley.exe.dev/llm"
+)
+
+// readOnlyTools are the tools in the "read_only" preset: they can look
+// around the workspace but not change it or run commands.
+var readOnlyTools = []string{"think", "keyword_search", "change_dir", "list_files", "recall", "search_docs", "read_image", "read_document", "ask_user"}
+
+// ConversationToolsRequest is the body of POST /api/conversation/<id>/tools.
+// Preset, if set, takes the place of AllowedTools: "all" lifts the
+// restriction and "read_only" allows readOnlyTools.
+type ConversationToolsRequest struct {
+	AllowedTools []string `json:"allowed_tools"`
+	Preset       string   `json:"preset,omitempty"`
+}
+
+// ConversationToolsResponse describes the tools a conversation may use.
+type ConversationToolsResponse struct {
+	// AllowedTools is nil if every tool is allowed.
+	AllowedTools []string `json:"allowed_tools"`
+	Available    []string `json:"available"`
+}
+
+// conversationAllowedTools returns the tools a conversation is restricted
+// to, or nil if it may use every tool.
+func conversationAllowedTools(conv generated.Conversation) []string {
+	if conv.AllowedTools == nil {
+		return nil
+	}
+	var tools []string
+	if err := json.Unmarshal([]byte(*conv.AllowedTools), &tools); err != nil {
+		return nil
+	}
+	return tools
+}
+
+// filterTools returns the tools named in allowed, or all of them if allowed is nil.
+func filterTools(tools []*llm.Tool, allowed []string) []*llm.Tool {
+	if allowed == nil {
+		return tools
+	}
+	var filtered []*llm.Tool
+	for _, tool := range tools {
+		if slices.Contains(allowed, tool.Name) {
+			filtered = append(filtered, tool)
+		}
+	}
+	return filtered
+}
+
+// readOnlyAllowed returns the read-only tools among allowed, nil meaning
+// available.
+func readOnlyAllowed(allowed, available []string) []string {
+	tools := []string{}
+	for _, name := range readOnlyTools {
+		if slices.Contains(available, name) && (allowed == nil || slices.Contains(allowed, name)) {
+			tools = append(tools, name)
+		}
+	}
+	return tools
+}
+
+// resolveAllowedTools checks a tool selection against the available tools
+// and returns the allowlist to store, nil meaning every tool.
+func resolveAllowedTools(allowed []string, preset string, available []string) ([]string, error) {
+	switch preset {
+	case "":
+	case "all":
+		return nil, nil
+	case "read_only":
+		return readOnlyAllowed(nil, available), nil
+	default:
+		return nil, fmt.Errorf("unknown tool preset: %s", preset)
+	}
+	if allowed == nil {
+		return nil, nil
+	}
+	for _, name := range allowed {
+		if !slices.Contains(available, name) {
+			return nil, fmt.Errorf("unknown tool: %s", name)
+		}
+	}
+	return allowed, nil
+}
+
+// handleConversationTools handles GET and POST /conversation/<id>/tools.
+// POST changes the tools the conversation may use; a running agent is
+// offered the new set from its next request.
+func (s *Server) handleConversationTools(w http.ResponseWriter, r *http.Request, conversationID string) {
+	ctx := r.Context()
+	var conversation generated.Conversation
+	err := s.db.Queries(ctx, func(q *generated.Queries) error {
+		var err error
+		conversation, err = q.GetConversation(ctx, conversationID)
+		return err
+	})
+	if err != nil {
+		http.Error(w, "Conversation not found", http.StatusNotFound)
+		return
+	}
+	available := s.toolNames()
+
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(ConversationToolsResponse{
+			AllowedTools: conversationAllowedTools(conversation),
+			Available:    available,
+		})
+	case http.MethodPost:
+		var req ConversationToolsRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+		allowed, err := resolveAllowedTools(req.AllowedTools, req.Preset, available)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := s.setConversationAllowedTools(ctx, conversationID, allowed); err != nil {
+			s.logger.Error("Failed to update conversation tools", "conversationID", conversationID, "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(ConversationToolsResponse{
+			AllowedTools: allowed,
+			Available:    available,
+		})
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// setConversationAllowedTools stores a conversation's allowlist and passes
+// it to its manager, if one is active.
+func (s *Server) setConversationAllowedTools(ctx context.Context, conversationID string, allowed []string) error {
+	var data *string
+	if allowed != nil {
+		encoded, err := json.Marshal(allowed)
+		if err != nil {
+			return err
+		}
+		str := string(encoded)
+		data = &str
+	}
+	if err := s.db.UpdateConversationAllowedTools(ctx, conversationID, data); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	manager, ok := s.activeConversations[conversationID]
+	s.mu.Unlock()
+	if ok {
+		manager.SetAllowedTools(allowed)
+	}
+	return nil
+}
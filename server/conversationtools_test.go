@@ -0,0 +1,80 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestConversationTools(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+
+	body, _ := json.Marshal(ChatRequest{
+		Message:    "echo: hello",
+		Model:      "predictable",
+		ToolPreset: "read_only",
+	})
+	w := httptest.NewRecorder()
+	h.server.handleNewConversation(w, httptest.NewRequest("POST", "/api/conversations/new", strings.NewReader(string(body))))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		ConversationID string `json:"conversation_id"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	h.convID = resp.ConversationID
+	h.WaitResponse()
+
+	offered := func() []string {
+		conv, err := h.db.GetConversationByID(context.Background(), h.convID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return conversationTools(*conv)
+	}
+	tools := offered()
+	if len(tools) == 0 || slices.Contains(tools, "bash") || slices.Contains(tools, "patch") {
+		t.Fatalf("expected only read-only tools to be offered, got %v", tools)
+	}
+
+	post := func(req ConversationToolsRequest) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(req)
+		w := httptest.NewRecorder()
+		h.server.handleConversationTools(w, httptest.NewRequest("POST", "/api/conversation/"+h.convID+"/tools", strings.NewReader(string(body))), h.convID)
+		return w
+	}
+
+	if w := post(ConversationToolsRequest{AllowedTools: []string{"bash", "nonexistent"}}); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for unknown tool, got %d: %s", w.Code, w.Body.String())
+	}
+
+	if w := post(ConversationToolsRequest{AllowedTools: []string{"bash", "think"}}); w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if tools := offered(); !slices.Equal(tools, []string{"bash", "think"}) && !slices.Equal(tools, []string{"think", "bash"}) {
+		t.Errorf("expected the running loop to be offered bash and think, got %v", tools)
+	}
+
+	w = httptest.NewRecorder()
+	h.server.handleConversationTools(w, httptest.NewRequest("GET", "/api/conversation/"+h.convID+"/tools", nil), h.convID)
+	var got ConversationToolsResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.AllowedTools) != 2 || !slices.Contains(got.Available, "patch") {
+		t.Errorf("unexpected tools response: %+v", got)
+	}
+
+	if w := post(ConversationToolsRequest{Preset: "all"}); w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if tools := offered(); !slices.Contains(tools, "patch") {
+		t.Errorf("expected every tool to be offered, got %v", tools)
+	}
+}
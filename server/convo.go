@@ -2,18 +2,23 @@ package server
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log/slog"
+	"slices"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"shelley.exe.dev/claudetool"
 	"shelley.exe.dev/db"
 	"shelley.exe.dev/db/generated"
+	"shelley.exe.dev/devcontainer"
 	"shelley.exe.dev/gitstate"
 	"shelley.exe.dev/llm"
 	"shelley.exe.dev/loop"
+	"shelley.exe.dev/projectconfig"
 	"shelley.exe.dev/subpub"
 )
 
@@ -35,14 +40,55 @@ type ConversationManager struct {
 	logger         *slog.Logger
 	toolSetConfig  claudetool.ToolSetConfig
 	toolSet        *claudetool.ToolSet // created per-conversation when loop starts
-	llmManager     LLMProvider          // for getting fallback LLM service
-	defaultModel   string               // default model to fallback to
+	llmManager     LLMProvider         // for getting fallback LLM service
+	defaultModel   string              // default model to fallback to
 
 	subpub *subpub.SubPub[StreamResponse]
 
 	hydrated              bool
 	hasConversationEvents bool
 	cwd                   string // working directory for tools
+	sandbox               *SandboxOptions
+	remote                *RemoteWorkspace          // nil if the tools run on the server
+	devcontainer          string                    // workspace folder whose dev container the tools run in
+	allowedTools          []string                  // nil offers every tool
+	sampling              *llm.Sampling             // nil leaves sampling to the model
+	thinking              *llm.Thinking             // nil leaves reasoning to the model
+	questions             map[string]chan string    // answers awaited by ask_user, by tool use ID
+	onQuestion            func(claudetool.Question) // called when ask_user starts waiting
+	draining              *atomic.Bool              // set while the server shuts down, when turns are refused
+	llmExchange           *llmExchange              // latest LLM request, kept by the LLM debug log
+
+	// onGitStateChange is called when a turn leaves the working directory's
+	// git state changed, e.g. after a commit or checkout
+	onGitStateChange func(*gitstate.GitState)
+
+	// beforeTool is called before each tool call, and may wait
+	beforeTool func(ctx context.Context)
+
+	// restoredTools, if set, are offered to the next loop instead of the
+	// allowed tools, and recovering is set while that loop's resumed turn
+	// runs (see restoreTools)
+	restoredTools []string
+	recovering    bool
+
+	// resuming is set while the server resumes the conversation after a
+	// restart (see beginResume)
+	resuming bool
+
+	// The conversation's lease, held while its loop exists (see leases.go)
+	acquireLease func(context.Context) error
+	releaseLease func()
+	hydratedSeq  int64 // latest message sequence ID loaded by Hydrate
+
+	outputMu    sync.Mutex
+	toolOutputs map[string]string // output so far of running tools, by tool use ID
+
+	queueMu sync.Mutex
+	queue   []QueuedMessage // messages waiting for the turn to end
+
+	partialMu sync.Mutex
+	partial   *partialMessage // the message the current response streams to
 }
 
 // NewConversationManager constructs a manager with dependencies but defers hydration until needed.
@@ -53,7 +99,7 @@ func NewConversationManager(conversationID string, database *db.DB, baseLogger *
 	}
 	logger = logger.With("conversationID", conversationID)
 
-	return &ConversationManager{
+	cm := &ConversationManager{
 		conversationID: conversationID,
 		db:             database,
 		lastActivity:   time.Now(),
@@ -64,6 +110,9 @@ func NewConversationManager(conversationID string, database *db.DB, baseLogger *
 		llmManager:     llmManager,
 		defaultModel:   defaultModel,
 	}
+	cm.toolSetConfig.OnToolOutput = cm.publishToolOutput
+	cm.toolSetConfig.AskUser = cm.askUser
+	return cm
 }
 
 // Hydrate loads conversation state from the database, generating a system prompt if missing.
@@ -81,18 +130,41 @@ func (cm *ConversationManager) Hydrate(ctx context.Context) error {
 		return fmt.Errorf("conversation not found: %w", err)
 	}
 
+	// Only the system prompt and what follows the latest summary make up
+	// the context; a summary replaces everything before it but the
+	// messages it keeps
 	var messages []generated.Message
 	err = cm.db.Queries(ctx, func(q *generated.Queries) error {
 		var err error
-		messages, err = q.ListMessages(ctx, cm.conversationID)
-		return err
+		messages, err = q.ListMessagesForContext(ctx, cm.conversationID)
+		if err != nil {
+			return err
+		}
+		i := slices.IndexFunc(messages, func(m generated.Message) bool {
+			return m.Type == string(db.MessageTypeSummary)
+		})
+		if i < 0 {
+			return nil
+		}
+		kept, err := keptMessages(ctx, q, messages[i])
+		if err != nil {
+			return err
+		}
+		messages = slices.Concat(messages[:i], kept, messages[i:])
+		return nil
 	})
 	if err != nil {
 		return fmt.Errorf("failed to get conversation history: %w", err)
 	}
 
+	// Load cwd from conversation if available
+	cwd := ""
+	if conversation.Cwd != nil {
+		cwd = *conversation.Cwd
+	}
+
 	if conversation.UserInitiated && !hasSystemMessage(messages) {
-		systemMsg, err := cm.createSystemPrompt(ctx)
+		systemMsg, err := cm.createSystemPrompt(ctx, cwd)
 		if err != nil {
 			return err
 		}
@@ -102,20 +174,31 @@ func (cm *ConversationManager) Hydrate(ctx context.Context) error {
 	}
 
 	history, system := cm.partitionMessages(messages)
-
-	// Load cwd from conversation if available
-	cwd := ""
-	if conversation.Cwd != nil {
-		cwd = *conversation.Cwd
+	if err := expandToolResults(ctx, cm.db, cm.conversationID, history); err != nil {
+		return fmt.Errorf("failed to load tool results: %w", err)
+	}
+	var seq int64
+	for _, msg := range messages {
+		seq = max(seq, msg.SequenceID)
 	}
 
 	cm.mu.Lock()
+	cm.hydratedSeq = seq
 	cm.history = history
 	cm.system = system
 	cm.hasConversationEvents = len(history) > 0
 	cm.lastActivity = time.Now()
 	cm.hydrated = true
 	cm.cwd = cwd
+	cm.sandbox = conversationSandbox(*conversation)
+	cm.remote = conversationRemote(*conversation)
+	cm.devcontainer = ""
+	if conversation.Devcontainer != nil {
+		cm.devcontainer = *conversation.Devcontainer
+	}
+	cm.allowedTools = conversationAllowedTools(*conversation)
+	cm.sampling = conversationSampling(*conversation)
+	cm.thinking = conversationThinking(*conversation)
 	cm.mu.Unlock()
 
 	cm.logSystemPromptState(system, len(messages))
@@ -130,6 +213,12 @@ func (cm *ConversationManager) AcceptUserMessage(ctx context.Context, service ll
 	if service == nil {
 		return false, fmt.Errorf("llm service is required")
 	}
+	if cm.draining != nil && cm.draining.Load() {
+		return false, errShuttingDown
+	}
+	if err := cm.claim(ctx); err != nil {
+		return false, err
+	}
 
 	if err := cm.Hydrate(ctx); err != nil {
 		return false, err
@@ -145,11 +234,17 @@ func (cm *ConversationManager) AcceptUserMessage(ctx context.Context, service ll
 	loopInstance := cm.loop
 	cm.lastActivity = time.Now()
 	recordMessage := cm.recordMessage
+	recovering, allowedTools := cm.recovering, cm.allowedTools
+	cm.recovering = false
 	cm.mu.Unlock()
 
 	if loopInstance == nil {
 		return false, fmt.Errorf("conversation loop not initialized")
 	}
+	// The tools restored on recovery were for the resumed turn only
+	if recovering {
+		cm.SetAllowedTools(allowedTools)
+	}
 
 	// Record the user message to the database immediately so it appears in the UI,
 	// even if the loop is busy processing a previous request
@@ -165,6 +260,34 @@ func (cm *ConversationManager) AcceptUserMessage(ctx context.Context, service ll
 	return isFirst, nil
 }
 
+// claim takes the conversation's lease before its loop starts, so that no
+// other instance sharing the database runs it. If messages were added since
+// the conversation was loaded, e.g. by the instance that held it, it's
+// loaded again.
+func (cm *ConversationManager) claim(ctx context.Context) error {
+	cm.mu.Lock()
+	running := cm.loop != nil
+	hydrated := cm.hydrated
+	seq := cm.hydratedSeq
+	cm.mu.Unlock()
+	if running || cm.acquireLease == nil {
+		return nil
+	}
+	if err := cm.acquireLease(ctx); err != nil {
+		return err
+	}
+	if !hydrated {
+		return nil
+	}
+	latest, err := cm.db.GetLatestMessage(ctx, cm.conversationID)
+	if err == nil && latest.SequenceID > seq {
+		cm.mu.Lock()
+		cm.hydrated = false
+		cm.mu.Unlock()
+	}
+	return nil
+}
+
 // Touch updates last activity timestamp.
 func (cm *ConversationManager) Touch() {
 	cm.mu.Lock()
@@ -181,8 +304,13 @@ func hasSystemMessage(messages []generated.Message) bool {
 	return false
 }
 
-func (cm *ConversationManager) createSystemPrompt(ctx context.Context) (*generated.Message, error) {
-	systemPrompt, err := GenerateSystemPrompt(cm.cwd)
+func (cm *ConversationManager) createSystemPrompt(ctx context.Context, cwd string) (*generated.Message, error) {
+	memories, err := loadMemories(ctx, cm.db, cwd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load memories: %w", err)
+	}
+
+	systemPrompt, guidanceFiles, err := generateSystemPrompt(cwd, memories)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate system prompt: %w", err)
 	}
@@ -213,6 +341,8 @@ func (cm *ConversationManager) createSystemPrompt(ctx context.Context) (*generat
 		cm.logger.Warn("Failed to update conversation timestamp after system prompt", "error", err)
 	}
 
+	cm.recordGuidanceFiles(ctx, guidanceFiles)
+
 	cm.logger.Info("Stored system prompt", "length", len(systemPrompt))
 	return created, nil
 }
@@ -226,6 +356,10 @@ func (cm *ConversationManager) partitionMessages(messages []generated.Message) (
 		if msg.Type == string(db.MessageTypeGitInfo) {
 			continue
 		}
+		// Partial messages are what was generated of responses that failed
+		if isPartial(msg) {
+			continue
+		}
 
 		llmMsg, err := convertToLLMMessage(msg)
 		if err != nil {
@@ -233,6 +367,13 @@ func (cm *ConversationManager) partitionMessages(messages []generated.Message) (
 			continue
 		}
 
+		// A summary replaces everything before it but the messages it keeps
+		if msg.Type == string(db.MessageTypeSummary) {
+			kept := history[len(history)-min(summaryKept(msg), len(history)):]
+			history = append([]llm.Message{llmMsg}, kept...)
+			continue
+		}
+
 		if msg.Type == string(db.MessageTypeSystem) {
 			for _, content := range llmMsg.Content {
 				if content.Type == llm.ContentTypeText && content.Text != "" {
@@ -277,6 +418,14 @@ func (cm *ConversationManager) ensureLoop(service llm.Service, modelID string) e
 	recordMessage := cm.recordMessage
 	logger := cm.logger
 	cwd := cm.cwd
+	sandboxOpts := cm.sandbox
+	remoteWS := cm.remote
+	devcontainerFolder := cm.devcontainer
+	allowedTools := cm.allowedTools
+	restoredTools := cm.restoredTools
+	cm.restoredTools = nil
+	sampling := cm.sampling
+	thinking := cm.thinking
 	toolSetConfig := cm.toolSetConfig
 	conversationID := cm.conversationID
 	db := cm.db
@@ -285,16 +434,109 @@ func (cm *ConversationManager) ensureLoop(service llm.Service, modelID string) e
 	// Create tools for this conversation with the conversation's working directory
 	toolSetConfig.WorkingDir = cwd
 	toolSetConfig.ModelID = modelID
+	if sandboxOpts != nil {
+		toolSetConfig.Sandbox = sandboxPolicy(conversationID, cwd, *sandboxOpts)
+	}
+	settings, err := GetSettings(context.Background(), db)
+	if err != nil {
+		logger.Warn("failed to load tool settings", "error", err)
+	} else if err := applyToolSettings(&toolSetConfig, settings); err != nil {
+		logger.Warn("invalid tool settings", "error", err)
+	}
+	if toolSetConfig.Sandbox != nil {
+		toolSetConfig.FilePolicy = sandboxFilePolicy(toolSetConfig.FilePolicy, toolSetConfig.Sandbox)
+	}
+	getGitOrigin := gitstate.GetGitOrigin
+	var getGitState func(dir string) *gitstate.GitState
+	if remoteWS != nil {
+		shell := remoteWS.shell()
+		toolSetConfig.Remote = shell
+		// These hooks and the docs index work on the server's files
+		toolSetConfig.OnFileEdit = nil
+		toolSetConfig.TrackBashChanges = nil
+		toolSetConfig.SearchDocs = nil
+		getGitOrigin = func(dir string) string { return gitstate.GetGitOriginWith(shell.Git, dir) }
+		getGitState = func(dir string) *gitstate.GitState { return gitstate.GetGitStateWith(shell.Git, dir) }
+	}
+	if devcontainerFolder != "" {
+		// The folder is mounted at the same path, so the hooks that read
+		// the server's files still see the container's
+		toolSetConfig.Remote = devcontainer.For(devcontainerFolder).Shell()
+		// Outside the folder, the server's files aren't the container's
+		if searchDocs := toolSetConfig.SearchDocs; searchDocs != nil {
+			toolSetConfig.SearchDocs = func(ctx context.Context, workingDir, query string) ([]claudetool.DocSearchResult, error) {
+				if !withinRoot(workingDir, devcontainerFolder) {
+					return nil, fmt.Errorf("only the documentation under the dev container's workspace folder %s is indexed", devcontainerFolder)
+				}
+				return searchDocs(ctx, workingDir, query)
+			}
+		}
+	}
+	// Like guidance files, .shelley.yaml is read from the server's files
+	projectConfig, err := projectconfig.Load(cwd)
+	if err != nil {
+		logger.Warn("invalid project configuration", "error", err)
+	}
+	if projectConfig != nil {
+		toolSetConfig.BashEnv = projectConfig.Environ()
+		toolSetConfig.DeployTargets = projectDeployTargets(toolSetConfig.DeployTargets, projectConfig)
+	}
+	var loopInstance *loop.Loop
 	toolSetConfig.OnWorkingDirChange = func(newDir string) {
 		// Persist working directory and git origin change to database
-		gitOrigin := gitstate.GetGitOrigin(newDir)
+		gitOrigin := getGitOrigin(newDir)
 		if err := db.UpdateConversationCwdAndGitOrigin(context.Background(), conversationID, newDir, gitOrigin); err != nil {
 			logger.Error("failed to persist working directory change", "error", err, "newDir", newDir)
 		}
+		// Guidance files are read from the server's files
+		if remoteWS == nil && loopInstance != nil {
+			cm.loadGuidance(context.Background(), newDir, loopInstance.AddSystem)
+		}
+	}
+
+	// The remember tool files memories under the project of the current
+	// working directory, which change_dir may move after the tool set is built.
+	var toolSet *claudetool.ToolSet
+	toolSetConfig.SaveMemory = func(ctx context.Context, content string) error {
+		return saveAgentMemory(ctx, db, conversationID, toolSet.WorkingDir().Get(), content)
+	}
+
+	// A pull request review conversation submits its review with submit_review
+	if _, err := db.GetPRReview(context.Background(), conversationID); err == nil {
+		toolSetConfig.SubmitReview = func(ctx context.Context, review claudetool.Review) (string, error) {
+			return submitPRReview(ctx, db, conversationID, review)
+		}
+	}
+
+	// A conversation in plan mode proposes its plan and records its progress
+	if _, err := db.GetConversationPlan(context.Background(), conversationID); err == nil {
+		toolSetConfig.ProposePlan = func(ctx context.Context, plan claudetool.Plan) (string, error) {
+			names := make([]string, len(toolSet.Tools()))
+			for i, tool := range toolSet.Tools() {
+				names[i] = tool.Name
+			}
+			return cm.proposePlan(ctx, plan, names)
+		}
+		toolSetConfig.UpdatePlanStep = func(ctx context.Context, step int, status, note string) (string, error) {
+			return updatePlanStep(ctx, db, conversationID, step, status, note)
+		}
 	}
 
 	processCtx, cancel := context.WithTimeout(context.Background(), 12*time.Hour)
-	toolSet := claudetool.NewToolSet(processCtx, toolSetConfig)
+	toolSet = claudetool.NewToolSet(processCtx, toolSetConfig)
+	tools := cm.loopTools(toolSet, allowedTools)
+	if restoredTools != nil {
+		tools = cm.loopTools(toolSet, restoredTools)
+	}
+	cm.recordTools(tools)
+
+	// A new conversation runs the project's setup commands before its first turn
+	var setup func(ctx context.Context) string
+	if len(history) == 0 && projectConfig != nil && len(projectConfig.Setup) > 0 {
+		setup = func(ctx context.Context) string {
+			return cm.runSetup(ctx, toolSet, projectConfig)
+		}
+	}
 
 	// Get fallback LLM service for model errors
 	var fallbackService llm.Service
@@ -302,19 +544,39 @@ func (cm *ConversationManager) ensureLoop(service llm.Service, modelID string) e
 		fallbackService, _ = cm.llmManager.GetService(cm.defaultModel)
 	}
 
-	loopInstance := loop.NewLoop(loop.Config{
+	if settings.Debug.llmLog() {
+		service = &debugService{service: service, model: modelID, cm: cm}
+		if fallbackService != nil {
+			fallbackService = &debugService{service: fallbackService, model: cm.defaultModel, cm: cm}
+		}
+	}
+	service = &streamingService{service: service, cm: cm}
+	if fallbackService != nil {
+		fallbackService = &streamingService{service: fallbackService, cm: cm}
+	}
+
+	loopInstance = loop.NewLoop(loop.Config{
 		LLM:           service,
 		FallbackLLM:   fallbackService,
 		History:       history,
-		Tools:         toolSet.Tools(),
+		Tools:         tools,
 		RecordMessage: recordMessage,
 		Logger:        logger,
 		System:        system,
 		WorkingDir:    cwd,
 		GetWorkingDir: toolSet.WorkingDir().Get,
+		GetGitState:   getGitState,
 		OnGitStateChange: func(ctx context.Context, state *gitstate.GitState) {
+			if cm.onGitStateChange != nil {
+				cm.onGitStateChange(state)
+			}
 			cm.recordGitStateChange(ctx, state)
 		},
+		SummaryLLM:    cm.summaryService(modelID),
+		RecordSummary: cm.recordSummary,
+		Setup:         setup,
+		Sampling:      sampling,
+		Thinking:      thinking,
 	})
 
 	cm.mu.Lock()
@@ -333,6 +595,7 @@ func (cm *ConversationManager) ensureLoop(service llm.Service, modelID string) e
 	cm.loopCtx = processCtx
 	cm.modelID = modelID
 	cm.toolSet = toolSet
+	cm.recovering = restoredTools != nil
 	cm.history = nil
 	cm.system = nil
 	cm.mu.Unlock()
@@ -363,12 +626,140 @@ func (cm *ConversationManager) stopLoop() {
 
 	if cancel != nil {
 		cancel()
+		if cm.releaseLease != nil {
+			cm.releaseLease()
+		}
 	}
 	if toolSet != nil {
 		toolSet.Cleanup()
 	}
 }
 
+// Reset stops the loop and discards the loaded history, so the conversation
+// is reloaded from the database the next time it is used.
+func (cm *ConversationManager) Reset() {
+	cm.stopLoop()
+	cm.mu.Lock()
+	cm.hydrated = false
+	cm.history = nil
+	cm.system = nil
+	cm.mu.Unlock()
+}
+
+// SetAllowedTools changes the tools offered to the conversation. A running
+// loop is offered the new set from its next request.
+func (cm *ConversationManager) SetAllowedTools(names []string) {
+	cm.mu.Lock()
+	cm.allowedTools = names
+	// Drop the set restored on recovery, so the next loop offers what is allowed now
+	cm.restoredTools = nil
+	cm.recovering = false
+	loop, toolSet := cm.loop, cm.toolSet
+	cm.mu.Unlock()
+
+	if loop != nil && toolSet != nil {
+		tools := cm.loopTools(toolSet, names)
+		loop.SetTools(tools)
+		cm.recordTools(tools)
+	}
+}
+
+// SetSampling changes the sampling parameters of the conversation's LLM
+// requests, starting with the running loop's next request.
+func (cm *ConversationManager) SetSampling(sampling *llm.Sampling) {
+	cm.mu.Lock()
+	cm.sampling = sampling
+	loop := cm.loop
+	cm.mu.Unlock()
+
+	if loop != nil {
+		loop.SetSampling(sampling)
+	}
+}
+
+// SetThinking changes the reasoning asked of the conversation's models,
+// starting with the running loop's next request.
+func (cm *ConversationManager) SetThinking(thinking *llm.Thinking) {
+	cm.mu.Lock()
+	cm.thinking = thinking
+	loop := cm.loop
+	cm.mu.Unlock()
+
+	if loop != nil {
+		loop.SetThinking(thinking)
+	}
+}
+
+// loopTools returns the tools of toolSet offered to the loop, limited to
+// allowed, which call beforeTool before they run.
+func (cm *ConversationManager) loopTools(toolSet *claudetool.ToolSet, allowed []string) []*llm.Tool {
+	tools := filterTools(toolSet.Tools(), allowed)
+	if cm.beforeTool == nil {
+		return tools
+	}
+	wrapped := make([]*llm.Tool, len(tools))
+	for i, tool := range tools {
+		t := *tool
+		t.Run = func(ctx context.Context, input json.RawMessage) llm.ToolOut {
+			cm.beforeTool(ctx)
+			return tool.Run(ctx, input)
+		}
+		wrapped[i] = &t
+	}
+	return wrapped
+}
+
+// recordTools stores the names of the tools offered to the loop, so that
+// recovery after a restart can offer the same set.
+func (cm *ConversationManager) recordTools(tools []*llm.Tool) {
+	names := make([]string, len(tools))
+	for i, t := range tools {
+		names[i] = t.Name
+	}
+	data, err := json.Marshal(names)
+	if err != nil {
+		cm.logger.Warn("Failed to marshal conversation tools", "error", err)
+		return
+	}
+	toolsStr := string(data)
+	if err := cm.db.QueriesTx(context.Background(), func(q *generated.Queries) error {
+		return q.UpdateConversationTools(context.Background(), generated.UpdateConversationToolsParams{
+			Tools:          &toolsStr,
+			ConversationID: cm.conversationID,
+		})
+	}); err != nil {
+		cm.logger.Warn("Failed to record conversation tools", "error", err)
+	}
+}
+
+// restoreTools limits the next loop to the named tools, as recorded by
+// recordTools, until the next user message, which is offered the allowed
+// tools again.
+func (cm *ConversationManager) restoreTools(names []string) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	cm.restoredTools = names
+}
+
+// beginResume marks the conversation as being resumed, reporting false if it
+// already runs or is being resumed.
+func (cm *ConversationManager) beginResume() bool {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	if cm.loop != nil || cm.resuming {
+		return false
+	}
+	cm.resuming = true
+	return true
+}
+
+// endResume clears the mark set by beginResume.
+func (cm *ConversationManager) endResume() {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	cm.resuming = false
+}
+
 // Resume restarts an interrupted conversation after server restart.
 // It hydrates the conversation from DB, creates a new loop, and triggers
 // the LLM to continue processing.
@@ -376,6 +767,12 @@ func (cm *ConversationManager) Resume(ctx context.Context, service llm.Service,
 	if service == nil {
 		return fmt.Errorf("llm service is required")
 	}
+	if cm.draining != nil && cm.draining.Load() {
+		return errShuttingDown
+	}
+	if err := cm.claim(ctx); err != nil {
+		return err
+	}
 
 	if err := cm.Hydrate(ctx); err != nil {
 		return err
@@ -532,6 +929,132 @@ func (cm *ConversationManager) CancelConversation(ctx context.Context) error {
 	return nil
 }
 
+// CancelTool cancels a running tool call of the conversation's turn, whose
+// result becomes an error while the turn goes on. It reports whether the
+// call was running.
+func (cm *ConversationManager) CancelTool(toolUseID string) bool {
+	cm.mu.Lock()
+	loopInstance := cm.loop
+	cm.mu.Unlock()
+	return loopInstance != nil && loopInstance.CancelTool(toolUseID)
+}
+
+// summaryModels are cheap models used to summarize history during compaction, in order of preference.
+var summaryModels = []string{"claude-haiku-4.5", "gpt-5-nano", "qwen3-coder-fireworks"}
+
+// summaryService returns the LLM service used to summarize history, or nil to use the conversation's model.
+func (cm *ConversationManager) summaryService(modelID string) llm.Service {
+	if cm.llmManager == nil || modelID == "predictable" {
+		return nil
+	}
+	for _, id := range summaryModels {
+		if service, err := cm.llmManager.GetService(id); err == nil {
+			return service
+		}
+	}
+	return nil
+}
+
+// SummaryUserData is the user_data of a summary message.
+type SummaryUserData struct {
+	// Kept is how many of the messages before the summary are still sent
+	// to the LLM after it, word for word.
+	Kept int `json:"kept,omitempty"`
+}
+
+// summaryKept returns how many of the messages before the summary msg it
+// keeps.
+func summaryKept(msg generated.Message) int {
+	var data SummaryUserData
+	if msg.UserData == nil || json.Unmarshal([]byte(*msg.UserData), &data) != nil {
+		return 0
+	}
+	return data.Kept
+}
+
+// keptMessages returns the stored messages before summary that it keeps,
+// oldest first, counting only those partitionMessages adds to the history.
+func keptMessages(ctx context.Context, q *generated.Queries, summary generated.Message) ([]generated.Message, error) {
+	var kept []generated.Message
+	before := summary.SequenceID
+	for n := summaryKept(summary); n > 0; {
+		page, err := q.ListMessagesBefore(ctx, generated.ListMessagesBeforeParams{
+			ConversationID: summary.ConversationID,
+			SequenceID:     before,
+			Limit:          int64(n),
+		})
+		if err != nil {
+			return nil, err
+		}
+		if len(page) == 0 {
+			break
+		}
+		for _, m := range page {
+			if n == 0 {
+				break
+			}
+			before = m.SequenceID
+			// System messages are loaded with the rest of the context
+			if m.Type == string(db.MessageTypeSystem) {
+				continue
+			}
+			kept = append(kept, m)
+			if inHistory(m) {
+				n--
+			}
+		}
+	}
+	slices.Reverse(kept)
+	return kept, nil
+}
+
+// recordSummary stores a compaction summary. Earlier messages stay in the
+// database for display but, other than the kept ones, are no longer sent to
+// the LLM.
+func (cm *ConversationManager) recordSummary(ctx context.Context, message llm.Message, usage llm.Usage, kept int) error {
+	createdMsg, err := cm.db.CreateMessage(ctx, db.CreateMessageParams{
+		ConversationID: cm.conversationID,
+		Type:           db.MessageTypeSummary,
+		LLMData:        message,
+		UserData:       SummaryUserData{Kept: kept},
+		UsageData:      usage,
+	})
+	if err != nil {
+		return err
+	}
+
+	go cm.notifyMessage(context.WithoutCancel(ctx), createdMsg)
+	return nil
+}
+
+// Compact summarizes the conversation history so far and uses the summary in place
+// of the earlier messages for future LLM requests. It fails with loop.ErrLoopBusy
+// while the agent is working.
+func (cm *ConversationManager) Compact(ctx context.Context, service llm.Service, modelID string) error {
+	if service == nil {
+		return fmt.Errorf("llm service is required")
+	}
+
+	if err := cm.Hydrate(ctx); err != nil {
+		return err
+	}
+
+	if err := cm.ensureLoop(service, modelID); err != nil {
+		return err
+	}
+
+	cm.mu.Lock()
+	loopInstance := cm.loop
+	cm.lastActivity = time.Now()
+	cm.mu.Unlock()
+
+	if loopInstance == nil {
+		return fmt.Errorf("conversation loop not initialized")
+	}
+
+	return loopInstance.Compact(ctx)
+}
+
 // GitInfoUserData is the structured data stored in user_data for gitinfo messages.
 type GitInfoUserData struct {
 	Worktree string `json:"worktree"`
@@ -577,11 +1100,11 @@ func (cm *ConversationManager) recordGitStateChange(ctx context.Context, state *
 	cm.logger.Debug("Recorded git state change", "state", state.String())
 
 	// Notify subscribers so the UI updates
-	go cm.notifyGitStateChange(context.WithoutCancel(ctx), createdMsg)
+	go cm.notifyMessage(context.WithoutCancel(ctx), createdMsg)
 }
 
-// notifyGitStateChange publishes a gitinfo message to subscribers.
-func (cm *ConversationManager) notifyGitStateChange(ctx context.Context, msg *generated.Message) {
+// notifyMessage publishes a message recorded by the manager to subscribers.
+func (cm *ConversationManager) notifyMessage(ctx context.Context, msg *generated.Message) {
 	var conversation generated.Conversation
 	err := cm.db.Queries(ctx, func(q *generated.Queries) error {
 		var err error
@@ -589,7 +1112,7 @@ func (cm *ConversationManager) notifyGitStateChange(ctx context.Context, msg *ge
 		return err
 	})
 	if err != nil {
-		cm.logger.Error("Failed to get conversation for git state notification", "error", err)
+		cm.logger.Error("Failed to get conversation for message notification", "error", err)
 		return
 	}
 
@@ -597,7 +1120,7 @@ func (cm *ConversationManager) notifyGitStateChange(ctx context.Context, msg *ge
 	streamData := StreamResponse{
 		Messages:     apiMessages,
 		Conversation: conversation,
-		AgentWorking: false, // Gitinfo is recorded at end of turn, agent is done
+		AgentWorking: conversation.AgentWorking,
 	}
 	cm.subpub.Publish(msg.SequenceID, streamData)
 }
@@ -0,0 +1,379 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/tools/cover"
+)
+
+// coverageTimeout bounds a coverage run.
+const coverageTimeout = 10 * time.Minute
+
+// CoverageRequest is the body of POST /api/coverage.
+type CoverageRequest struct {
+	Cwd string `json:"cwd"`
+	// Path is the package pattern to test, relative to Cwd; defaults to ./...
+	Path string `json:"path,omitempty"`
+}
+
+// CoverageReport summarizes the stored coverage profile of a repository.
+// File paths are relative to GitRoot, like the git diff endpoints'.
+type CoverageReport struct {
+	GitRoot    string                `json:"gitRoot"`
+	Cwd        string                `json:"cwd"`
+	Path       string                `json:"path"`
+	CreatedAt  time.Time             `json:"createdAt"`
+	Passed     bool                  `json:"passed"`
+	Output     string                `json:"output,omitempty"` // the end of go test's output, if it failed
+	Statements int                   `json:"statements"`
+	Covered    int                   `json:"covered"`
+	Percent    float64               `json:"percent"`
+	Files      []CoverageFileSummary `json:"files"`
+	// Module and ModuleDir map the profile's import paths to files.
+	Module    string `json:"module"`
+	ModuleDir string `json:"moduleDir"`
+}
+
+// CoverageFileSummary is the coverage of one file.
+type CoverageFileSummary struct {
+	Path       string  `json:"path"`
+	Statements int     `json:"statements"`
+	Covered    int     `json:"covered"`
+	Percent    float64 `json:"percent"`
+}
+
+// FileCoverage is the line coverage of one file, for overlaying on its diff.
+// A line is partial when some of the statements on it ran and others didn't.
+type FileCoverage struct {
+	CoverageFileSummary
+	CreatedAt time.Time `json:"createdAt"`
+	// Stale is set if the file changed after the profile was made, so the
+	// lines may no longer match.
+	Stale          bool  `json:"stale"`
+	CoveredLines   []int `json:"coveredLines"`
+	UncoveredLines []int `json:"uncoveredLines"`
+	PartialLines   []int `json:"partialLines"`
+}
+
+// coveragePaths returns where the profile and report of the repository at
+// gitRoot are stored.
+func (s *Server) coveragePaths(gitRoot string) (profile, report string) {
+	sum := sha256.Sum256([]byte(gitRoot))
+	key := hex.EncodeToString(sum[:8])
+	return filepath.Join(s.coverageRoot, key+".out"), filepath.Join(s.coverageRoot, key+".json")
+}
+
+// handleCoverage runs coverage for a repository (POST) or returns the
+// summary of its last run (GET).
+func (s *Server) handleCoverage(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		cwd := r.URL.Query().Get("cwd")
+		if cwd == "" {
+			http.Error(w, "cwd parameter required", http.StatusBadRequest)
+			return
+		}
+		gitRoot, err := getGitRoot(cwd)
+		if err != nil {
+			http.Error(w, "not a git repository", http.StatusBadRequest)
+			return
+		}
+		report, err := s.loadCoverageReport(gitRoot)
+		if errors.Is(err, os.ErrNotExist) {
+			http.Error(w, "no coverage has been run for this repository", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			s.logger.Error("Failed to load coverage report", "error", err)
+			http.Error(w, "failed to load coverage report", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(report)
+
+	case http.MethodPost:
+		var req CoverageRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid JSON", http.StatusBadRequest)
+			return
+		}
+		pattern := req.Path
+		if pattern == "" {
+			pattern = "./..."
+		}
+		if strings.HasPrefix(pattern, "-") {
+			http.Error(w, "path must be a package pattern", http.StatusBadRequest)
+			return
+		}
+		if fi, err := os.Stat(req.Cwd); req.Cwd == "" || err != nil || !fi.IsDir() {
+			http.Error(w, "invalid cwd", http.StatusBadRequest)
+			return
+		}
+		gitRoot, err := getGitRoot(req.Cwd)
+		if err != nil {
+			http.Error(w, "not a git repository", http.StatusBadRequest)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), coverageTimeout)
+		defer cancel()
+		report, err := s.runCoverage(ctx, gitRoot, req.Cwd, pattern)
+		if err != nil {
+			var br *badRequestError
+			if errors.As(err, &br) {
+				http.Error(w, br.Error(), http.StatusUnprocessableEntity)
+				return
+			}
+			s.logger.Error("Failed to run coverage", "error", err)
+			http.Error(w, "failed to run coverage", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(report)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleFileCoverage returns the line coverage of a file from the last
+// coverage run of its repository.
+func (s *Server) handleFileCoverage(w http.ResponseWriter, r *http.Request) {
+	cwd := r.URL.Query().Get("cwd")
+	if cwd == "" {
+		http.Error(w, "cwd parameter required", http.StatusBadRequest)
+		return
+	}
+	gitRoot, err := getGitRoot(cwd)
+	if err != nil {
+		http.Error(w, "not a git repository", http.StatusBadRequest)
+		return
+	}
+	filePath := filepath.ToSlash(filepath.Clean(r.URL.Query().Get("path")))
+	if filePath == "." || strings.HasPrefix(filePath, "../") || filepath.IsAbs(filePath) {
+		http.Error(w, "invalid file path", http.StatusBadRequest)
+		return
+	}
+
+	report, err := s.loadCoverageReport(gitRoot)
+	if errors.Is(err, os.ErrNotExist) {
+		http.Error(w, "no coverage has been run for this repository", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		s.logger.Error("Failed to load coverage report", "error", err)
+		http.Error(w, "failed to load coverage report", http.StatusInternalServerError)
+		return
+	}
+	profilePath, _ := s.coveragePaths(gitRoot)
+	profiles, err := cover.ParseProfiles(profilePath)
+	if err != nil {
+		s.logger.Error("Failed to parse coverage profile", "error", err)
+		http.Error(w, "failed to parse coverage profile", http.StatusInternalServerError)
+		return
+	}
+
+	fc := FileCoverage{
+		CoverageFileSummary: CoverageFileSummary{Path: filePath},
+		CreatedAt:           report.CreatedAt,
+		CoveredLines:        []int{},
+		UncoveredLines:      []int{},
+		PartialLines:        []int{},
+	}
+	for _, p := range profiles {
+		if report.repoPath(p.FileName) == filePath {
+			fc.CoverageFileSummary = summarizeProfile(filePath, p)
+			fc.CoveredLines, fc.UncoveredLines, fc.PartialLines = lineCoverage(p)
+			break
+		}
+	}
+	if fi, err := os.Stat(filepath.Join(gitRoot, filePath)); err == nil && fi.ModTime().After(report.CreatedAt) {
+		fc.Stale = true
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(fc)
+}
+
+// runCoverage runs go test with coverage for pattern in cwd, stores the
+// profile and report for the repository at gitRoot, and returns the report.
+// Failing tests still produce a report; a run that produces no profile,
+// such as one that doesn't build, is a *badRequestError.
+func (s *Server) runCoverage(ctx context.Context, gitRoot, cwd, pattern string) (*CoverageReport, error) {
+	gomodCmd := exec.CommandContext(ctx, "go", "env", "GOMOD")
+	gomodCmd.Dir = cwd
+	gomod, err := gomodCmd.Output()
+	gomodPath := strings.TrimSpace(string(gomod))
+	if err != nil || gomodPath == "" || gomodPath == os.DevNull {
+		return nil, badRequestf("%s is not in a Go module", cwd)
+	}
+	data, err := os.ReadFile(gomodPath)
+	if err != nil {
+		return nil, err
+	}
+	modDir, err := filepath.EvalSymlinks(filepath.Dir(gomodPath))
+	if err != nil {
+		return nil, err
+	}
+	moduleDir, err := filepath.Rel(gitRoot, modDir)
+	if err != nil || strings.HasPrefix(moduleDir, "..") {
+		return nil, badRequestf("the Go module of %s is outside its repository", cwd)
+	}
+
+	if err := os.MkdirAll(s.coverageRoot, 0o755); err != nil {
+		return nil, err
+	}
+	profilePath, reportPath := s.coveragePaths(gitRoot)
+	tmp, err := os.CreateTemp(s.coverageRoot, "profile-*")
+	if err != nil {
+		return nil, err
+	}
+	tmp.Close()
+	defer os.Remove(tmp.Name())
+
+	report := &CoverageReport{
+		GitRoot:   gitRoot,
+		Cwd:       cwd,
+		Path:      pattern,
+		CreatedAt: time.Now(),
+		Module:    modfile.ModulePath(data),
+		ModuleDir: filepath.ToSlash(moduleDir),
+	}
+	cmd := exec.CommandContext(ctx, "go", "test", "-covermode=set", "-coverprofile="+tmp.Name(), pattern)
+	cmd.Dir = cwd
+	out, runErr := cmd.CombinedOutput()
+	report.Passed = runErr == nil
+	if !report.Passed {
+		report.Output = tailLines(string(out), 40)
+	}
+
+	profiles, err := cover.ParseProfiles(tmp.Name())
+	if err != nil || len(profiles) == 0 {
+		if ctx.Err() != nil {
+			return nil, badRequestf("coverage run timed out after %s", coverageTimeout)
+		}
+		return nil, badRequestf("go test produced no coverage profile:\n%s", tailLines(string(out), 40))
+	}
+	report.Files = []CoverageFileSummary{}
+	for _, p := range profiles {
+		file := report.repoPath(p.FileName)
+		if file == "" {
+			continue
+		}
+		fs := summarizeProfile(file, p)
+		report.Statements += fs.Statements
+		report.Covered += fs.Covered
+		report.Files = append(report.Files, fs)
+	}
+	report.Percent = percent(report.Covered, report.Statements)
+
+	reportJSON, err := json.Marshal(report)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmp.Name(), profilePath); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(reportPath, reportJSON, 0o644); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// loadCoverageReport returns the stored coverage report of the repository
+// at gitRoot.
+func (s *Server) loadCoverageReport(gitRoot string) (*CoverageReport, error) {
+	_, reportPath := s.coveragePaths(gitRoot)
+	data, err := os.ReadFile(reportPath)
+	if err != nil {
+		return nil, err
+	}
+	var report CoverageReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, fmt.Errorf("invalid coverage report %s: %w", reportPath, err)
+	}
+	return &report, nil
+}
+
+// repoPath returns the path relative to the repository of a file named by
+// import path in the profile, or "" if it's outside the module.
+func (r *CoverageReport) repoPath(name string) string {
+	rel, ok := strings.CutPrefix(name, r.Module+"/")
+	if !ok {
+		return ""
+	}
+	return path.Join(r.ModuleDir, rel)
+}
+
+// summarizeProfile counts the statements of a file's profile.
+func summarizeProfile(file string, p *cover.Profile) CoverageFileSummary {
+	fs := CoverageFileSummary{Path: file}
+	for _, b := range p.Blocks {
+		fs.Statements += b.NumStmt
+		if b.Count > 0 {
+			fs.Covered += b.NumStmt
+		}
+	}
+	fs.Percent = percent(fs.Covered, fs.Statements)
+	return fs
+}
+
+// lineCoverage returns the lines of a file's profile whose statements all
+// ran, none ran, and some ran.
+func lineCoverage(p *cover.Profile) (covered, uncovered, partial []int) {
+	const ran, notRan = 1, 2
+	lines := make(map[int]int)
+	for _, b := range p.Blocks {
+		if b.NumStmt == 0 {
+			continue
+		}
+		state := notRan
+		if b.Count > 0 {
+			state = ran
+		}
+		for line := b.StartLine; line <= b.EndLine; line++ {
+			lines[line] |= state
+		}
+	}
+	covered, uncovered, partial = []int{}, []int{}, []int{}
+	for line, state := range lines {
+		switch state {
+		case ran:
+			covered = append(covered, line)
+		case notRan:
+			uncovered = append(uncovered, line)
+		default:
+			partial = append(partial, line)
+		}
+	}
+	slices.Sort(covered)
+	slices.Sort(uncovered)
+	slices.Sort(partial)
+	return covered, uncovered, partial
+}
+
+func percent(n, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return 100 * float64(n) / float64(total)
+}
+
+// tailLines returns the last n lines of s.
+func tailLines(s string, n int) string {
+	lines := strings.Split(strings.TrimRight(s, "\n"), "\n")
+	return strings.Join(lines[max(0, len(lines)-n):], "\n")
+}
@@ -0,0 +1,113 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestCoverage(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go not found")
+	}
+	// Don't use a module file meant for this repository.
+	t.Setenv("GOFLAGS", "")
+	h := NewTestHarness(t)
+	defer h.Close()
+	h.server.coverageRoot = t.TempDir()
+
+	repo := t.TempDir()
+	if out, err := exec.Command("git", "init", repo).CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v\n%s", err, out)
+	}
+	repo, _ = filepath.EvalSymlinks(repo)
+	files := map[string]string{
+		"svc/go.mod": "module example.com/svc\n\ngo 1.21\n",
+		"svc/calc.go": `package svc
+
+func Add(a, b int) int {
+	return a + b
+}
+
+func Sub(a, b int) int {
+	return a - b
+}
+`,
+		"svc/calc_test.go": `package svc
+
+import "testing"
+
+func TestAdd(t *testing.T) {
+	if Add(1, 2) != 3 {
+		t.Fatal("bad sum")
+	}
+}
+`,
+	}
+	for name, content := range files {
+		path := filepath.Join(repo, name)
+		os.MkdirAll(filepath.Dir(path), 0o755)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	mux := http.NewServeMux()
+	h.server.RegisterRoutes(mux)
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("X-Shelley-Request", "1")
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w
+	}
+	cwd := url.QueryEscape(filepath.Join(repo, "svc"))
+
+	if w := do("GET", "/api/coverage?cwd="+cwd, ""); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 before coverage has run, got %d", w.Code)
+	}
+
+	w := do("POST", "/api/coverage", `{"cwd": "`+filepath.Join(repo, "svc")+`"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var report CoverageReport
+	json.Unmarshal(w.Body.Bytes(), &report)
+	if !report.Passed || report.Statements != 2 || report.Covered != 1 {
+		t.Errorf("expected 1 of 2 statements covered, got %+v", report)
+	}
+	if len(report.Files) != 1 || report.Files[0].Path != "svc/calc.go" {
+		t.Errorf("expected the file relative to the repository, got %+v", report.Files)
+	}
+
+	if w := do("GET", "/api/coverage?cwd="+cwd, ""); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"svc/calc.go"`) {
+		t.Errorf("expected the stored report, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w = do("GET", "/api/coverage/file?cwd="+cwd+"&path=svc/calc.go", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var fc FileCoverage
+	json.Unmarshal(w.Body.Bytes(), &fc)
+	if !slices.Contains(fc.CoveredLines, 4) || !slices.Contains(fc.UncoveredLines, 8) || fc.Stale {
+		t.Errorf("expected Add covered and Sub not, got %+v", fc)
+	}
+
+	if w := do("GET", "/api/coverage/file?cwd="+cwd+"&path=../etc/passwd", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("expected a path outside the repository to be rejected, got %d", w.Code)
+	}
+	if w := do("POST", "/api/coverage", `{"cwd": "`+repo+`", "path": "-exec=evil"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("expected a flag to be rejected as a path, got %d", w.Code)
+	}
+	if w := do("POST", "/api/coverage", `{"cwd": "`+repo+`"}`); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected 422 outside a Go module, got %d: %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,142 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"shelley.exe.dev/db/generated"
+	"shelley.exe.dev/models"
+)
+
+// Custom models are served by OpenAI-compatible endpoints, such as vLLM,
+// LM Studio or a llama.cpp server, registered at runtime. They are
+// stored in the database and added to the LLM manager at once, and again
+// when the server starts.
+
+// customModelRegistry is implemented by LLM providers that take models
+// registered at runtime.
+type customModelRegistry interface {
+	AddCustomModel(models.CustomModel) error
+	RemoveCustomModel(id string) bool
+}
+
+// CustomModelRequest is the body of POST /api/custom-models.
+type CustomModelRequest struct {
+	ID        string `json:"id"`
+	URL       string `json:"url"`
+	APIKey    string `json:"api_key,omitempty"`
+	ModelName string `json:"model_name"`
+}
+
+// CustomModelResponse describes a custom model. The API key is never
+// returned.
+type CustomModelResponse struct {
+	ID        string    `json:"id"`
+	URL       string    `json:"url"`
+	ModelName string    `json:"model_name"`
+	HasAPIKey bool      `json:"has_api_key"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func customModelResponse(m *generated.CustomModel) CustomModelResponse {
+	return CustomModelResponse{
+		ID:        m.ModelID,
+		URL:       m.Url,
+		ModelName: m.ModelName,
+		HasAPIKey: m.ApiKey != "",
+		CreatedAt: m.CreatedAt,
+	}
+}
+
+// loadCustomModels adds the stored custom models to the LLM manager.
+func (s *Server) loadCustomModels(ctx context.Context) {
+	registry, ok := s.llmManager.(customModelRegistry)
+	if !ok {
+		return
+	}
+	customModels, err := s.db.ListCustomModels(ctx)
+	if err != nil {
+		s.logger.Warn("Failed to load custom models", "error", err)
+		return
+	}
+	for _, m := range customModels {
+		if err := registry.AddCustomModel(models.CustomModel{ID: m.ModelID, URL: m.Url, APIKey: m.ApiKey, ModelName: m.ModelName}); err != nil {
+			s.logger.Warn("Invalid custom model", "model", m.ModelID, "error", err)
+		}
+	}
+}
+
+// handleCustomModels handles GET /api/custom-models, and POST to register
+// a custom model or replace the one with the same ID.
+func (s *Server) handleCustomModels(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	if r.Method == http.MethodGet {
+		customModels, err := s.db.ListCustomModels(ctx)
+		if err != nil {
+			s.logger.Error("Failed to list custom models", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		resp := []CustomModelResponse{}
+		for i := range customModels {
+			resp = append(resp, customModelResponse(&customModels[i]))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+		return
+	}
+
+	registry, ok := s.llmManager.(customModelRegistry)
+	if !ok {
+		http.Error(w, "Custom models are not supported", http.StatusNotImplemented)
+		return
+	}
+	var req CustomModelRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	custom := models.CustomModel{ID: req.ID, URL: req.URL, APIKey: req.APIKey, ModelName: req.ModelName}
+	if err := custom.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	stored, err := s.db.UpsertCustomModel(ctx, req.ID, req.URL, req.APIKey, req.ModelName)
+	if err != nil {
+		s.logger.Error("Failed to store custom model", "model", req.ID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if err := registry.AddCustomModel(custom); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(customModelResponse(stored))
+}
+
+// handleDeleteCustomModel handles DELETE /api/custom-models/{id}.
+// Conversations already using the model keep it until their agent loop
+// stops.
+func (s *Server) handleDeleteCustomModel(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	err := s.db.DeleteCustomModel(r.Context(), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Custom model not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		s.logger.Error("Failed to delete custom model", "model", id, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if registry, ok := s.llmManager.(customModelRegistry); ok {
+		registry.RemoveCustomModel(id)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
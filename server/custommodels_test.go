@@ -0,0 +1,96 @@
+package server
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"shelley.exe.dev/claudetool"
+	"shelley.exe.dev/llm"
+	"shelley.exe.dev/models"
+)
+
+func TestCustomModels(t *testing.T) {
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id": "1", "object": "chat.completion", "model": "local-llama",
+			"choices": [{"index": 0, "finish_reason": "stop", "message": {"role": "assistant", "content": "OK"}}],
+			"usage": {"prompt_tokens": 3, "completion_tokens": 1, "total_tokens": 4}}`))
+	}))
+	defer endpoint.Close()
+
+	database, cleanup := setupTestDB(t)
+	defer cleanup()
+	manager, err := models.NewManager(&models.Config{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+	s := NewServer(database, manager, claudetool.ToolSetConfig{}, logger, false, "", "", "", nil)
+	mux := http.NewServeMux()
+	s.RegisterRoutes(mux)
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("X-Shelley-Request", "1")
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := do("POST", "/api/custom-models", `{"id": "claude-sonnet-4.5", "url": "`+endpoint.URL+`/v1", "model_name": "x"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("expected a built-in model ID to be rejected, got %d", w.Code)
+	}
+	w := do("POST", "/api/custom-models", `{"id": "local", "url": "`+endpoint.URL+`/v1", "api_key": "secret", "model_name": "local-llama"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	if strings.Contains(w.Body.String(), "secret") {
+		t.Errorf("expected the API key to be left out, got %s", w.Body.String())
+	}
+
+	if !manager.HasModel("local") || !strings.Contains(do("GET", "/api/models", "").Body.String(), `"id":"local"`) {
+		t.Fatal("expected the custom model to be available at once")
+	}
+	svc, err := manager.GetService("local")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := svc.Do(t.Context(), &llm.Request{Messages: []llm.Message{llm.UserStringMessage("Reply with OK.")}})
+	if err != nil || len(resp.Content) == 0 || resp.Content[0].Text != "OK" {
+		t.Fatalf("unexpected response %+v: %v", resp, err)
+	}
+
+	// A restarted server offers the stored custom models.
+	restarted, err := models.NewManager(&models.Config{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	NewServer(database, restarted, claudetool.ToolSetConfig{}, logger, false, "", "", "", nil).loadCustomModels(t.Context())
+	if !restarted.HasModel("local") {
+		t.Error("expected the custom model to be loaded")
+	}
+
+	var list []CustomModelResponse
+	json.Unmarshal(do("GET", "/api/custom-models", "").Body.Bytes(), &list)
+	if len(list) != 1 || list[0].ID != "local" || list[0].ModelName != "local-llama" || !list[0].HasAPIKey {
+		t.Errorf("unexpected custom models: %+v", list)
+	}
+
+	if w := do("DELETE", "/api/custom-models/local", ""); w.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", w.Code)
+	}
+	if manager.HasModel("local") {
+		t.Error("expected the custom model to be removed")
+	}
+	if w := do("DELETE", "/api/custom-models/local", ""); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404, got %d", w.Code)
+	}
+}
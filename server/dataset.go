@@ -0,0 +1,325 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+
+	"shelley.exe.dev/db"
+	"shelley.exe.dev/db/generated"
+	"shelley.exe.dev/llm"
+	"shelley.exe.dev/redact"
+)
+
+// Conversations can be exported as JSONL datasets for fine-tuning or
+// evaluating models. Secrets are redacted: credentials in common formats,
+// values assigned to secret-looking names, the values of secret-looking
+// environment variables, and the secret settings. Images, documents and
+// thinking are left out, as is any unfinished turn at the end of a
+// conversation. Tool results are exported whole, and compacted conversations
+// with the turns before their summaries.
+
+// Dataset formats.
+const (
+	// datasetOpenAI is one chat per conversation, in the format of OpenAI's
+	// chat fine-tuning, with tool calls.
+	datasetOpenAI = "openai"
+	// datasetAnthropic is one conversation per line, as a Messages API
+	// request: a system prompt and messages of content blocks.
+	datasetAnthropic = "anthropic"
+	// datasetEval is one sample per turn: the text of the conversation up
+	// to the user's message as input, and the agent's final answer to it
+	// as the ideal response.
+	datasetEval = "eval"
+)
+
+// DatasetRequest is the body of POST /api/conversations/export.
+type DatasetRequest struct {
+	ConversationIDs []string `json:"conversation_ids"`
+	// Format is "openai", "anthropic" or "eval".
+	Format string `json:"format"`
+}
+
+// openAIMessage is a message of an OpenAI chat.
+type openAIMessage struct {
+	Role       string           `json:"role"`
+	Content    string           `json:"content,omitempty"`
+	ToolCalls  []openAIToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string           `json:"tool_call_id,omitempty"`
+}
+
+type openAIToolCall struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+// anthropicMessage is a message of an Anthropic Messages API request.
+type anthropicMessage struct {
+	Role    string           `json:"role"`
+	Content []anthropicBlock `json:"content"`
+}
+
+type anthropicBlock struct {
+	Type      string          `json:"type"`
+	Text      string          `json:"text,omitempty"`
+	ID        string          `json:"id,omitempty"`
+	Name      string          `json:"name,omitempty"`
+	Input     json.RawMessage `json:"input,omitempty"`
+	ToolUseID string          `json:"tool_use_id,omitempty"`
+	Content   string          `json:"content,omitempty"`
+	IsError   bool            `json:"is_error,omitempty"`
+}
+
+// evalSample is a sample of the eval format.
+type evalSample struct {
+	ConversationID string          `json:"conversation_id"`
+	Input          []openAIMessage `json:"input"`
+	Ideal          string          `json:"ideal"`
+}
+
+// handleExportDataset handles POST /api/conversations/export, returning the
+// conversations as a JSONL dataset.
+func (s *Server) handleExportDataset(w http.ResponseWriter, r *http.Request) {
+	var req DatasetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if !slices.Contains([]string{datasetOpenAI, datasetAnthropic, datasetEval}, req.Format) {
+		http.Error(w, `Format must be "openai", "anthropic" or "eval"`, http.StatusBadRequest)
+		return
+	}
+	if len(req.ConversationIDs) == 0 {
+		http.Error(w, "No conversations selected", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	redactor, err := s.exportRedactor(ctx)
+	if err != nil {
+		s.logger.Error("Failed to load secrets to redact", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	var lines []any
+	for _, id := range req.ConversationIDs {
+		if _, err := s.db.GetConversationByID(ctx, id); err != nil {
+			http.Error(w, "Conversation not found: "+id, http.StatusNotFound)
+			return
+		}
+		var messages []generated.Message
+		if err := s.db.Queries(ctx, func(q *generated.Queries) error {
+			var err error
+			messages, err = q.ListMessages(ctx, id)
+			return err
+		}); err != nil {
+			s.logger.Error("Failed to list messages", "conversationID", id, "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		// The turns a summary replaced for the agent are exported, not the
+		// summary
+		messages = slices.DeleteFunc(messages, func(m generated.Message) bool {
+			return m.Type == string(db.MessageTypeSummary)
+		})
+		cm := &ConversationManager{logger: s.logger}
+		history, system := cm.partitionMessages(messages)
+		history = finishedTurns(history)
+		if len(history) == 0 {
+			continue
+		}
+		if err := expandToolResults(ctx, s.db, id, history); err != nil {
+			s.logger.Error("Failed to load tool results", "conversationID", id, "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		systemText := redactor.String(systemPromptText(system))
+
+		switch req.Format {
+		case datasetOpenAI:
+			lines = append(lines, map[string]any{"messages": openAIChat(redactor, systemText, history)})
+		case datasetAnthropic:
+			lines = append(lines, map[string]any{"system": systemText, "messages": anthropicChat(redactor, history)})
+		case datasetEval:
+			for _, sample := range evalSamples(redactor, systemText, history) {
+				sample.ConversationID = id
+				lines = append(lines, sample)
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/jsonl")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="shelley-%s.jsonl"`, req.Format))
+	enc := json.NewEncoder(w)
+	for _, line := range lines {
+		if err := enc.Encode(line); err != nil {
+			s.logger.Error("Failed to write dataset", "error", err)
+			return
+		}
+	}
+}
+
+// exportRedactor returns the redactor for exported datasets and share pages,
+// which also removes the secret settings and environment variables.
+func (s *Server) exportRedactor(ctx context.Context) (*redact.Redactor, error) {
+	settings, err := GetSettings(ctx, s.db)
+	if err != nil {
+		return nil, err
+	}
+	values := redact.EnvSecrets()
+	walkSecrets(reflect.ValueOf(&settings), reflect.Value{}, func(dst, _ reflect.Value) {
+		if dst.Kind() == reflect.String {
+			values = append(values, dst.String())
+		}
+	})
+	return redact.New(values...), nil
+}
+
+// finishedTurns returns history up to the end of its last finished turn.
+func finishedTurns(history []llm.Message) []llm.Message {
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].Role == llm.MessageRoleAssistant && history[i].EndOfTurn {
+			return history[:i+1]
+		}
+	}
+	return nil
+}
+
+// systemPromptText joins the parts of a system prompt.
+func systemPromptText(system []llm.SystemContent) string {
+	var parts []string
+	for _, c := range system {
+		parts = append(parts, c.Text)
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// redactedText returns the redacted text of the text content of contents.
+func redactedText(redactor *redact.Redactor, contents []llm.Content) string {
+	var parts []string
+	for _, c := range contents {
+		if c.Type == llm.ContentTypeText && c.MediaType == "" && c.Text != "" {
+			parts = append(parts, redactor.String(c.Text))
+		}
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// redactJSON returns the JSON value data with the secrets in its strings
+// redacted.
+func redactJSON(redactor *redact.Redactor, data json.RawMessage) json.RawMessage {
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return json.RawMessage(`{}`)
+	}
+	var walk func(v any) any
+	walk = func(v any) any {
+		switch v := v.(type) {
+		case string:
+			return redactor.String(v)
+		case []any:
+			for i := range v {
+				v[i] = walk(v[i])
+			}
+		case map[string]any:
+			for k := range v {
+				v[k] = walk(v[k])
+			}
+		}
+		return v
+	}
+	redacted, _ := json.Marshal(walk(v))
+	return redacted
+}
+
+// openAIChat converts history to the messages of an OpenAI chat.
+func openAIChat(redactor *redact.Redactor, system string, history []llm.Message) []openAIMessage {
+	var chat []openAIMessage
+	if system != "" {
+		chat = append(chat, openAIMessage{Role: "system", Content: system})
+	}
+	for _, msg := range history {
+		if msg.Role == llm.MessageRoleAssistant {
+			out := openAIMessage{Role: "assistant", Content: redactedText(redactor, msg.Content)}
+			for _, c := range msg.Content {
+				if c.Type != llm.ContentTypeToolUse {
+					continue
+				}
+				call := openAIToolCall{ID: c.ID, Type: "function"}
+				call.Function.Name = c.ToolName
+				call.Function.Arguments = string(redactJSON(redactor, c.ToolInput))
+				out.ToolCalls = append(out.ToolCalls, call)
+			}
+			if out.Content != "" || len(out.ToolCalls) > 0 {
+				chat = append(chat, out)
+			}
+			continue
+		}
+		for _, c := range msg.Content {
+			if c.Type == llm.ContentTypeToolResult {
+				chat = append(chat, openAIMessage{Role: "tool", ToolCallID: c.ToolUseID, Content: redactedText(redactor, c.ToolResult)})
+			}
+		}
+		if text := redactedText(redactor, msg.Content); text != "" {
+			chat = append(chat, openAIMessage{Role: "user", Content: text})
+		}
+	}
+	return chat
+}
+
+// anthropicChat converts history to the messages of an Anthropic request.
+func anthropicChat(redactor *redact.Redactor, history []llm.Message) []anthropicMessage {
+	var chat []anthropicMessage
+	for _, msg := range history {
+		out := anthropicMessage{Role: "user"}
+		if msg.Role == llm.MessageRoleAssistant {
+			out.Role = "assistant"
+		}
+		for _, c := range msg.Content {
+			switch {
+			case c.Type == llm.ContentTypeText && c.MediaType == "" && c.Text != "":
+				out.Content = append(out.Content, anthropicBlock{Type: "text", Text: redactor.String(c.Text)})
+			case c.Type == llm.ContentTypeToolUse:
+				out.Content = append(out.Content, anthropicBlock{Type: "tool_use", ID: c.ID, Name: c.ToolName, Input: redactJSON(redactor, c.ToolInput)})
+			case c.Type == llm.ContentTypeToolResult:
+				out.Content = append(out.Content, anthropicBlock{Type: "tool_result", ToolUseID: c.ToolUseID, Content: redactedText(redactor, c.ToolResult), IsError: c.ToolError})
+			}
+		}
+		if len(out.Content) > 0 {
+			chat = append(chat, out)
+		}
+	}
+	return chat
+}
+
+// evalSamples makes a sample of each turn of history that the agent
+// answered with text.
+func evalSamples(redactor *redact.Redactor, system string, history []llm.Message) []evalSample {
+	var samples []evalSample
+	var transcript []openAIMessage
+	if system != "" {
+		transcript = append(transcript, openAIMessage{Role: "system", Content: system})
+	}
+	for _, msg := range history {
+		switch {
+		case isUserText(msg):
+			transcript = append(transcript, openAIMessage{Role: "user", Content: redactedText(redactor, msg.Content)})
+		case msg.Role == llm.MessageRoleAssistant && msg.EndOfTurn:
+			answer := redactedText(redactor, msg.Content)
+			if answer == "" || len(transcript) == 0 || transcript[len(transcript)-1].Role != "user" {
+				continue
+			}
+			samples = append(samples, evalSample{Input: slices.Clone(transcript), Ideal: answer})
+			transcript = append(transcript, openAIMessage{Role: "assistant", Content: answer})
+		}
+	}
+	return samples
+}
@@ -0,0 +1,129 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func (h *TestHarness) exportDataset(format string) []map[string]any {
+	h.t.Helper()
+	body := `{"conversation_ids": ["` + h.convID + `"], "format": "` + format + `"}`
+	w := httptest.NewRecorder()
+	h.server.handleExportDataset(w, httptest.NewRequest("POST", "/api/conversations/export", strings.NewReader(body)))
+	if w.Code != http.StatusOK {
+		h.t.Fatalf("export %s: expected 200, got %d: %s", format, w.Code, w.Body.String())
+	}
+	if strings.Contains(w.Body.String(), "abcdefghijklmnopqrstuvwxyz") {
+		h.t.Errorf("export %s: secret was not redacted: %s", format, w.Body.String())
+	}
+	var lines []map[string]any
+	scanner := bufio.NewScanner(w.Body)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		var line map[string]any
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			h.t.Fatalf("export %s: invalid line %q: %v", format, scanner.Text(), err)
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func TestExportDataset(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+	h.NewConversation("bash: echo sk-ant-REDACTED", t.TempDir())
+	h.WaitResponse()
+	h.Chat("echo: done")
+	h.WaitResponse()
+
+	w := httptest.NewRecorder()
+	h.server.handleExportDataset(w, httptest.NewRequest("POST", "/api/conversations/export", strings.NewReader(`{"conversation_ids": ["x"], "format": "csv"}`)))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("unknown format: expected 400, got %d", w.Code)
+	}
+
+	openai := h.exportDataset("openai")
+	if len(openai) != 1 {
+		t.Fatalf("openai: expected one chat, got %d", len(openai))
+	}
+	var roles []string
+	for _, msg := range openai[0]["messages"].([]any) {
+		roles = append(roles, msg.(map[string]any)["role"].(string))
+	}
+	if got := strings.Join(roles, " "); !strings.HasPrefix(got, "system user assistant tool assistant") || !strings.HasSuffix(got, "user assistant") {
+		t.Errorf("openai: unexpected roles %q", got)
+	}
+
+	anthropic := h.exportDataset("anthropic")
+	if len(anthropic) != 1 || anthropic[0]["system"] == "" {
+		t.Fatalf("anthropic: unexpected export %+v", anthropic)
+	}
+	first := anthropic[0]["messages"].([]any)[1].(map[string]any)
+	blocks := first["content"].([]any)
+	if block := blocks[len(blocks)-1].(map[string]any); first["role"] != "assistant" || block["type"] != "tool_use" {
+		t.Errorf("anthropic: expected the tool call second, got %+v", first)
+	}
+
+	eval := h.exportDataset("eval")
+	if len(eval) != 2 || eval[1]["ideal"] != "done" || eval[1]["conversation_id"] != h.convID {
+		t.Errorf("eval: expected a sample per turn, got %+v", eval)
+	}
+}
+
+func TestExportDatasetWholeHistory(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+	h.server.SetToolResultBlobSize(1000)
+	output := strings.Repeat("x", 5000)
+	h.NewConversation("bash: head -c 5000 /dev/zero | tr '\\0' x", t.TempDir())
+	h.WaitResponse()
+	h.Chat("echo: second")
+	h.WaitResponse()
+	var w *httptest.ResponseRecorder
+	deadline := time.Now().Add(h.timeout)
+	for {
+		w = h.compact()
+		if w.Code != http.StatusConflict || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if w.Code != http.StatusOK {
+		t.Fatalf("compact: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	h.Chat("echo: third")
+	h.WaitResponse()
+
+	// The turns before the summary, with the whole tool result
+	anthropic := h.exportDataset("anthropic")
+	if len(anthropic) != 1 {
+		t.Fatalf("expected one conversation, got %d", len(anthropic))
+	}
+	var texts []string
+	var result string
+	for _, msg := range anthropic[0]["messages"].([]any) {
+		for _, block := range msg.(map[string]any)["content"].([]any) {
+			block := block.(map[string]any)
+			switch block["type"] {
+			case "text":
+				if msg.(map[string]any)["role"] == "user" {
+					texts = append(texts, block["text"].(string))
+				}
+			case "tool_result":
+				result = block["content"].(string)
+			}
+		}
+	}
+	if len(texts) != 3 || !strings.HasPrefix(texts[0], "bash: ") || texts[1] != "echo: second" || texts[2] != "echo: third" {
+		t.Errorf("expected the three user messages, got %q", texts)
+	}
+	if strings.TrimSpace(result) != output {
+		t.Errorf("expected the whole tool result, got %d bytes", len(result))
+	}
+}
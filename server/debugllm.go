@@ -0,0 +1,250 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"shelley.exe.dev/db"
+	"shelley.exe.dev/llm"
+	"shelley.exe.dev/models"
+)
+
+// The LLM debug log, when the debug settings enable it, keeps the request
+// and response behind each agent message: the JSON the provider was sent
+// and answered with, for providers that report it, and the
+// provider-neutral request, which can be replayed against another model
+// to see whether it would have done the same.
+
+// DebugSettings configures debugging aids.
+type DebugSettings struct {
+	// LLMLog keeps the LLM request and response behind each agent
+	// message. Requests hold the whole conversation, so the log grows
+	// quickly.
+	LLMLog bool `json:"llmLog,omitempty"`
+}
+
+// llmLog reports whether the LLM debug log is enabled.
+func (d *DebugSettings) llmLog() bool {
+	return d != nil && d.LLMLog
+}
+
+// llmExchange is an LLM request and its response, waiting for the agent
+// message it is recorded as.
+type llmExchange struct {
+	model    string
+	request  *llm.Request
+	response *llm.Response
+	http     *llm.HTTPExchange
+	err      error
+	duration time.Duration
+}
+
+// debugService keeps a conversation's latest LLM exchange for the debug
+// log.
+type debugService struct {
+	service llm.Service
+	model   string
+	cm      *ConversationManager
+}
+
+// Do sends the request, recording the provider's last HTTP exchange.
+func (d *debugService) Do(ctx context.Context, request *llm.Request) (*llm.Response, error) {
+	exchange := &llmExchange{model: d.model, request: request}
+	ctx = llm.WithHTTPRecorder(ctx, func(h llm.HTTPExchange) {
+		exchange.http = &h
+	})
+	start := time.Now()
+	resp, err := d.service.Do(ctx, request)
+	exchange.response, exchange.err, exchange.duration = resp, err, time.Since(start)
+
+	d.cm.mu.Lock()
+	d.cm.llmExchange = exchange
+	d.cm.mu.Unlock()
+	return resp, err
+}
+
+// TokenContextWindow delegates to the underlying service
+func (d *debugService) TokenContextWindow() int {
+	return d.service.TokenContextWindow()
+}
+
+// MaxImageDimension delegates to the underlying service
+func (d *debugService) MaxImageDimension() int {
+	return d.service.MaxImageDimension()
+}
+
+// UseSimplifiedPatch delegates to the underlying service
+func (d *debugService) UseSimplifiedPatch() bool {
+	return llm.UseSimplifiedPatch(d.service)
+}
+
+// Capabilities delegates to the underlying service
+func (d *debugService) Capabilities() llm.Capabilities {
+	return llm.ModelCapabilities(d.service)
+}
+
+// takeLLMExchange returns and forgets the conversation's latest LLM
+// exchange, if the debug log recorded one.
+func (cm *ConversationManager) takeLLMExchange() *llmExchange {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	exchange := cm.llmExchange
+	cm.llmExchange = nil
+	return exchange
+}
+
+// logLLMExchange stores an LLM exchange as the one behind messageID.
+func (s *Server) logLLMExchange(ctx context.Context, conversationID, messageID string, exchange *llmExchange) {
+	params := db.LLMRequestParams{
+		ConversationID: conversationID,
+		MessageID:      messageID,
+		Model:          exchange.model,
+		DurationMs:     exchange.duration.Milliseconds(),
+	}
+	if model := models.ByID(exchange.model); model != nil {
+		params.Provider = string(model.Provider)
+	}
+	if exchange.http != nil {
+		params.URL = exchange.http.URL
+		params.RequestBody = exchange.http.Request
+		params.ResponseBody = exchange.http.Response
+		params.StatusCode = exchange.http.StatusCode
+	}
+	if exchange.err != nil {
+		params.Error = exchange.err.Error()
+	}
+	var err error
+	if params.LLMRequest, err = json.Marshal(exchange.request); err == nil && exchange.response != nil {
+		params.LLMResponse, err = json.Marshal(exchange.response)
+	}
+	if err == nil {
+		_, err = s.db.CreateLLMRequest(ctx, params)
+	}
+	if err != nil {
+		s.logger.Warn("Failed to log LLM request", "conversationID", conversationID, "messageID", messageID, "error", err)
+	}
+}
+
+// LLMDebugResponse is the LLM request and response behind an agent
+// message. HTTPRequest and HTTPResponse are what the provider was sent
+// and answered with, for providers that report them.
+type LLMDebugResponse struct {
+	MessageID      string          `json:"message_id"`
+	ConversationID string          `json:"conversation_id"`
+	Model          string          `json:"model"`
+	Provider       string          `json:"provider,omitempty"`
+	URL            string          `json:"url,omitempty"`
+	Request        json.RawMessage `json:"request"`
+	Response       json.RawMessage `json:"response,omitempty"`
+	HTTPRequest    json.RawMessage `json:"http_request,omitempty"`
+	HTTPResponse   json.RawMessage `json:"http_response,omitempty"`
+	StatusCode     int64           `json:"status_code,omitempty"`
+	Error          string          `json:"error,omitempty"`
+	DurationMs     int64           `json:"duration_ms"`
+	CreatedAt      time.Time       `json:"created_at"`
+}
+
+// LLMReplayRequest is the body of POST /api/debug/llm/{messageID}/replay.
+type LLMReplayRequest struct {
+	Model string `json:"model"`
+}
+
+// LLMReplayResponse is another model's response to a logged request.
+type LLMReplayResponse struct {
+	Model      string        `json:"model"`
+	Response   *llm.Response `json:"response,omitempty"`
+	Error      string        `json:"error,omitempty"`
+	DurationMs int64         `json:"duration_ms"`
+}
+
+// handleDebugLLMMessage handles GET /api/debug/llm/{messageID}, and POST
+// /api/debug/llm/{messageID}/replay to send the logged request to another
+// model. The replay's response is returned, not added to the
+// conversation, and its tool calls are not run.
+func (s *Server) handleDebugLLMMessage(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	messageID := r.PathValue("messageID")
+	logged, err := s.db.GetLLMRequestByMessageID(ctx, messageID)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "No LLM request was logged for this message; enable the LLM debug log in the debug settings", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		s.logger.Error("Failed to get logged LLM request", "messageID", messageID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		s.replayLLMRequest(w, r, logged.LlmRequest)
+		return
+	}
+
+	resp := LLMDebugResponse{
+		MessageID:    messageID,
+		Model:        logged.Model,
+		Provider:     logged.Provider,
+		URL:          logged.Url,
+		Request:      loggedJSON(logged.LlmRequest),
+		Response:     loggedJSON(logged.LlmResponse),
+		HTTPRequest:  loggedJSON(logged.RequestBody),
+		HTTPResponse: loggedJSON(logged.ResponseBody),
+		CreatedAt:    logged.CreatedAt,
+	}
+	if logged.ConversationID != nil {
+		resp.ConversationID = *logged.ConversationID
+	}
+	if logged.StatusCode != nil {
+		resp.StatusCode = *logged.StatusCode
+	}
+	if logged.Error != nil {
+		resp.Error = *logged.Error
+	}
+	if logged.DurationMs != nil {
+		resp.DurationMs = *logged.DurationMs
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// replayLLMRequest sends a logged request to the model the request body
+// names.
+func (s *Server) replayLLMRequest(w http.ResponseWriter, r *http.Request, logged *string) {
+	var req LLMReplayRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	service, err := s.llmManager.GetService(req.Model)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Unsupported model: %s", req.Model), http.StatusBadRequest)
+		return
+	}
+	var request llm.Request
+	if logged == nil || json.Unmarshal([]byte(*logged), &request) != nil {
+		http.Error(w, "The logged request can't be replayed", http.StatusInternalServerError)
+		return
+	}
+
+	start := time.Now()
+	response, err := service.Do(r.Context(), &request)
+	resp := LLMReplayResponse{Model: req.Model, Response: response, DurationMs: time.Since(start).Milliseconds()}
+	if err != nil {
+		resp.Error = err.Error()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// loggedJSON returns a logged body as JSON, or nil if it isn't JSON.
+func loggedJSON(body *string) json.RawMessage {
+	if body == nil || !json.Valid([]byte(*body)) {
+		return nil
+	}
+	return json.RawMessage(*body)
+}
@@ -0,0 +1,80 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"shelley.exe.dev/db"
+	"shelley.exe.dev/llm"
+)
+
+func TestDebugLLMLog(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+	if err := SaveSettings(t.Context(), h.db, Settings{Debug: &DebugSettings{LLMLog: true}}, ""); err != nil {
+		t.Fatal(err)
+	}
+	h.NewConversation("echo: why?", t.TempDir())
+	h.WaitResponse()
+
+	var messageID string
+	for _, msg := range h.messages() {
+		if msg.Type == string(db.MessageTypeAgent) {
+			messageID = msg.MessageID
+		}
+	}
+	if messageID == "" {
+		t.Fatal("expected an agent message")
+	}
+
+	mux := http.NewServeMux()
+	h.server.RegisterRoutes(mux)
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("X-Shelley-Request", "1")
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w
+	}
+
+	// The exchange is logged just after the message is recorded
+	w := do("GET", "/api/debug/llm/"+messageID, "")
+	deadline := time.Now().Add(h.timeout)
+	for w.Code == http.StatusNotFound && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		w = do("GET", "/api/debug/llm/"+messageID, "")
+	}
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var logged LLMDebugResponse
+	json.Unmarshal(w.Body.Bytes(), &logged)
+	var request llm.Request
+	var response llm.Response
+	json.Unmarshal(logged.Request, &request)
+	json.Unmarshal(logged.Response, &response)
+	if logged.ConversationID != h.convID || logged.Model != "predictable" || len(request.Messages) == 0 || len(request.Tools) == 0 {
+		t.Errorf("unexpected logged request: %+v", logged)
+	}
+	if len(response.Content) == 0 || response.Content[0].Text != "why?" {
+		t.Errorf("unexpected logged response: %s", logged.Response)
+	}
+
+	w = do("POST", "/api/debug/llm/"+messageID+"/replay", `{"model": "predictable"}`)
+	var replay LLMReplayResponse
+	json.Unmarshal(w.Body.Bytes(), &replay)
+	if w.Code != http.StatusOK || replay.Response == nil || replay.Response.Content[0].Text != "why?" {
+		t.Errorf("unexpected replay: %d %s", w.Code, w.Body.String())
+	}
+	if h.userTexts("why?") != 1 {
+		t.Error("expected the replay to leave the conversation as it was")
+	}
+
+	if w := do("GET", "/api/debug/llm/nonexistent", ""); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for a message without a logged request, got %d", w.Code)
+	}
+}
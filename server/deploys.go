@@ -0,0 +1,101 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"shelley.exe.dev/claudetool"
+	"shelley.exe.dev/llm"
+)
+
+// deployPollInterval is how often the records of deploys that restart the
+// server are checked for an outcome.
+const deployPollInterval = 2 * time.Second
+
+// deployRecordTTL is how long the records of deploys of conversations the
+// server doesn't know are kept.
+const deployRecordTTL = 24 * time.Hour
+
+// deployRunDir returns the directory where a conversation's deploys that
+// restart the server leave their records (see claudetool.WatchDeploy).
+func (s *Server) deployRunDir(conversationID string) string {
+	return filepath.Join(s.deployRunRoot, conversationID)
+}
+
+// runDeployReports reports the outcome of deploys that restart the server
+// in their conversations as their watchers record it, whichever server,
+// the new one or the one rolled back to, is running by then.
+func (s *Server) runDeployReports(ctx context.Context) {
+	for {
+		s.reportDeploys(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(deployPollInterval):
+		}
+	}
+}
+
+// reportDeploys reports the deploys that are done in their conversations,
+// as an agent message ending the turn, and removes their records. A
+// conversation whose agent is working gets its report once the turn ends.
+func (s *Server) reportDeploys(ctx context.Context) {
+	conversations, err := os.ReadDir(s.deployRunRoot)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			s.logger.Warn("Failed to read deploy records", "error", err)
+		}
+		return
+	}
+	for _, entry := range conversations {
+		conversationID := entry.Name()
+		runDir := s.deployRunDir(conversationID)
+		deploys, err := os.ReadDir(runDir)
+		if err != nil {
+			continue
+		}
+		if len(deploys) == 0 {
+			os.Remove(runDir)
+			continue
+		}
+		for _, deploy := range deploys {
+			record, err := claudetool.LoadDeployRecord(runDir, deploy.Name())
+			if errors.Is(err, fs.ErrNotExist) {
+				// Still being started
+				continue
+			}
+			if err == nil && !record.Done() {
+				continue
+			}
+			if err != nil {
+				s.logger.Warn("Failed to load deploy record", "conversationID", conversationID, "toolUseID", deploy.Name(), "error", err)
+			} else if conv, err := s.db.GetConversationByID(ctx, conversationID); err != nil {
+				// Another server's, sharing the temporary directory, or
+				// of a deleted conversation
+				if time.Since(record.Started) < deployRecordTTL {
+					continue
+				}
+			} else if conv.AgentWorking {
+				continue
+			} else {
+				s.logger.Info("Reporting deploy", "conversationID", conversationID, "target", record.Target.Name, "status", record.Status)
+				message := llm.Message{
+					Role:      llm.MessageRoleAssistant,
+					Content:   []llm.Content{{Type: llm.ContentTypeText, Text: record.Report()}},
+					EndOfTurn: true,
+				}
+				if err := s.recordMessage(ctx, conversationID, message, llm.Usage{}); err != nil {
+					s.logger.Error("Failed to record deploy outcome", "conversationID", conversationID, "error", err)
+					continue
+				}
+			}
+			if err := claudetool.RemoveDeployRecord(runDir, deploy.Name()); err != nil {
+				s.logger.Warn("Failed to remove deploy record", "conversationID", conversationID, "toolUseID", deploy.Name(), "error", err)
+			}
+		}
+	}
+}
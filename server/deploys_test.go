@@ -0,0 +1,68 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"shelley.exe.dev/claudetool"
+	"shelley.exe.dev/db"
+)
+
+func TestReportDeploys(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+	h.server.deployRunRoot = t.TempDir()
+	h.NewConversation("echo: hi", t.TempDir())
+	h.WaitResponse()
+
+	writeRecord := func(toolUseID string, record claudetool.DeployRecord) {
+		t.Helper()
+		dir := filepath.Join(h.server.deployRunDir(h.convID), toolUseID)
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			t.Fatal(err)
+		}
+		data, _ := json.Marshal(record)
+		if err := os.WriteFile(filepath.Join(dir, "record.json"), data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeRecord("toolu_done", claudetool.DeployRecord{
+		Target:  claudetool.DeployTarget{Name: "self"},
+		Watcher: os.Getpid(),
+		Started: time.Now(),
+		Status:  claudetool.DeployRolledBack,
+		Detail:  "http://localhost:1/version did not answer within 1m0s",
+	})
+	writeRecord("toolu_running", claudetool.DeployRecord{
+		Target:  claudetool.DeployTarget{Name: "staging"},
+		Watcher: os.Getpid(),
+		Started: time.Now(),
+		Status:  claudetool.DeployRunning,
+	})
+
+	h.server.reportDeploys(context.Background())
+	var reports []string
+	for _, msg := range h.messages() {
+		if msg.Type == string(db.MessageTypeAgent) && strings.Contains(messageText(msg), "Deploy to") {
+			reports = append(reports, messageText(msg))
+		}
+	}
+	if len(reports) != 1 || !strings.Contains(reports[0], "Deploy to self failed its health check and was rolled back.") {
+		t.Fatalf("expected the finished deploy reported, got %q", reports)
+	}
+	conv, err := h.db.GetConversationByID(context.Background(), h.convID)
+	if err != nil || conv.AgentWorking {
+		t.Errorf("expected the report to leave the agent idle, got %+v, %v", conv, err)
+	}
+	if _, err := claudetool.LoadDeployRecord(h.server.deployRunDir(h.convID), "toolu_done"); !os.IsNotExist(err) {
+		t.Errorf("expected the reported record removed, got %v", err)
+	}
+	if _, err := claudetool.LoadDeployRecord(h.server.deployRunDir(h.convID), "toolu_running"); err != nil {
+		t.Errorf("expected the running deploy's record kept, got %v", err)
+	}
+}
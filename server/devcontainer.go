@@ -0,0 +1,34 @@
+package server
+
+import (
+	"context"
+
+	"shelley.exe.dev/devcontainer"
+)
+
+// checkDevcontainer checks that a new conversation can run its tools in the
+// dev container of its cwd.
+func checkDevcontainer(req ChatRequest) error {
+	switch {
+	case req.Cwd == "":
+		return badRequestf("A dev container needs a cwd")
+	case req.Remote != nil:
+		return badRequestf("Dev containers can't be used with a remote workspace")
+	case req.Sandbox != nil && req.Sandbox.Enabled:
+		return badRequestf("Dev containers can't be sandboxed")
+	case devcontainer.Find(req.Cwd) == "":
+		return badRequestf("%s has no dev container configuration", req.Cwd)
+	case !devcontainer.Available():
+		return badRequestf("%v", devcontainer.ErrUnavailable)
+	}
+	return nil
+}
+
+// startDevcontainer builds and starts the dev container of folder, so that
+// it's ready by the time the agent first runs a tool. The tools wait for it
+// and report any failure.
+func (s *Server) startDevcontainer(folder string) {
+	if _, err := devcontainer.For(folder).Up(context.Background()); err != nil {
+		s.logger.Warn("Failed to start dev container", "folder", folder, "error", err)
+	}
+}
@@ -0,0 +1,122 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeDevcontainerCLI puts a devcontainer CLI and docker on PATH that start
+// a "container" running commands locally, and returns the file docker logs
+// its arguments to.
+func fakeDevcontainerCLI(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	log := filepath.Join(dir, "log")
+	scripts := map[string]string{
+		"devcontainer": "#!/bin/sh\necho '{\"outcome\":\"success\",\"containerId\":\"c0ffee\",\"remoteUser\":\"dev\"}'\n",
+		"docker": "#!/bin/sh\necho \"$*\" >> " + log + "\nshift\n" +
+			"while case \"$1\" in -*) true;; *) false;; esac; do [ \"$1\" = -u ] && shift; shift; done\n" +
+			"shift\nexec \"$@\"\n",
+	}
+	for name, script := range scripts {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(script), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return log
+}
+
+func TestDevcontainerConversation(t *testing.T) {
+	log := fakeDevcontainerCLI(t)
+	h := NewTestHarness(t)
+	defer h.Close()
+	work := t.TempDir()
+	plain := t.TempDir()
+	if err := os.WriteFile(filepath.Join(work, ".devcontainer.json"), []byte(`{"image": "golang"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for path, want := range map[string]bool{work: true, plain: false} {
+		w := httptest.NewRecorder()
+		h.server.handleValidateCwd(w, httptest.NewRequest("GET", "/api/validate-cwd?path="+path, nil))
+		var resp struct {
+			Valid        bool `json:"valid"`
+			Devcontainer bool `json:"devcontainer"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		if !resp.Valid || resp.Devcontainer != want {
+			t.Errorf("validate-cwd %s: got %s, want devcontainer %v", path, w.Body.String(), want)
+		}
+	}
+
+	newConversation := func(req ChatRequest) *httptest.ResponseRecorder {
+		t.Helper()
+		req.Model = "predictable"
+		req.Devcontainer = true
+		body, _ := json.Marshal(req)
+		w := httptest.NewRecorder()
+		h.server.handleNewConversation(w, httptest.NewRequest("POST", "/api/conversations/new", strings.NewReader(string(body))))
+		return w
+	}
+	for name, req := range map[string]ChatRequest{
+		"no cwd":           {Message: "echo: hi"},
+		"no configuration": {Message: "echo: hi", Cwd: plain},
+		"sandboxed":        {Message: "echo: hi", Cwd: work, Sandbox: &SandboxOptions{Enabled: true}},
+	} {
+		if w := newConversation(req); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d: %s", name, w.Code, w.Body.String())
+		}
+	}
+
+	w := newConversation(ChatRequest{Message: "bash: pwd", Cwd: work})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		ConversationID string `json:"conversation_id"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	h.convID = resp.ConversationID
+	if result := h.WaitToolResult(); strings.TrimSpace(result) != work {
+		t.Errorf("unexpected tool result %q", result)
+	}
+
+	conv, err := h.db.GetConversationByID(context.Background(), h.convID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conv.Devcontainer == nil || *conv.Devcontainer != work {
+		t.Errorf("expected the dev container folder to be recorded, got %v", conv.Devcontainer)
+	}
+	if data, err := os.ReadFile(log); err != nil || !strings.Contains(string(data), "exec -i -u dev c0ffee sh -c") {
+		t.Errorf("expected the command to run in the container, got %q, %v", data, err)
+	}
+
+	// A fork runs its tools in the same container
+	h.WaitResponse()
+	os.Remove(log)
+	cw, fork := h.command("/fork")
+	if cw.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", cw.Code, cw.Body.String())
+	}
+	forked, err := h.db.GetConversationByID(context.Background(), fork.ConversationID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if forked.Devcontainer == nil || *forked.Devcontainer != work {
+		t.Errorf("expected the fork to keep the dev container, got %v", forked.Devcontainer)
+	}
+	h.convID = fork.ConversationID
+	h.Chat("bash: pwd")
+	h.WaitResponse()
+	if data, err := os.ReadFile(log); err != nil || !strings.Contains(string(data), "exec -i -u dev c0ffee sh -c") {
+		t.Errorf("expected the fork's command to run in the container, got %q, %v", data, err)
+	}
+}
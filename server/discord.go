@@ -0,0 +1,552 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+)
+
+const (
+	discordPlatform = "discord"
+	// discordIntents are the gateway events the bot receives: guilds,
+	// guild messages and their reactions, and message content.
+	discordIntents = 1<<0 | 1<<9 | 1<<10 | 1<<15
+	// maxDiscordMessage is Discord's limit on the length of a message.
+	maxDiscordMessage = 2000
+	// maxDiscordText caps the agent's text in a reply, leaving room for
+	// the tool summaries.
+	maxDiscordText = 1400
+	// maxDiscordThreadName caps the names of threads the bot starts.
+	maxDiscordThreadName = 80
+
+	discordStopEmoji    = "🛑"
+	discordApproveEmoji = "✅"
+)
+
+// Gateway opcodes
+const (
+	discordOpDispatch       = 0
+	discordOpHeartbeat      = 1
+	discordOpIdentify       = 2
+	discordOpReconnect      = 7
+	discordOpInvalidSession = 9
+	discordOpHello          = 10
+	discordOpHeartbeatAck   = 11
+)
+
+var (
+	// discordAPIURL is the base URL of the Discord REST API.
+	discordAPIURL = "https://discord.com/api/v10"
+	// discordGatewayURL is the address of the Discord gateway.
+	discordGatewayURL = "wss://gateway.discord.gg/?v=10&encoding=json"
+
+	discordMentionPattern = regexp.MustCompile(`<@!?\d+>`)
+)
+
+// DiscordBotSettings configures the Discord bot. It connects to the Discord
+// gateway, so it needs no public address, but the bot must have the
+// Message Content intent enabled.
+type DiscordBotSettings struct {
+	// Token is the bot's token.
+	Token string `json:"token,omitempty" secret:"true"`
+	// Channels are the IDs of channels where every message starts a
+	// conversation in a new thread; elsewhere the bot has to be @mentioned.
+	Channels []string `json:"channels,omitempty"`
+	// Users are the IDs of the only users the bot answers. Anyone in the
+	// bot's servers could otherwise run commands here, so there must be
+	// some.
+	Users []string `json:"users,omitempty"`
+	BotConversationSettings
+}
+
+func (b *DiscordBotSettings) validate(hasModel func(string) bool) error {
+	if b == nil {
+		return nil
+	}
+	if b.Token == "" || len(b.Users) == 0 {
+		return fmt.Errorf("discord bot needs a token and at least one user ID")
+	}
+	return b.BotConversationSettings.validate(hasModel)
+}
+
+// discordPayload is a gateway message.
+type discordPayload struct {
+	Op int             `json:"op"`
+	D  json.RawMessage `json:"d,omitempty"`
+	S  int64           `json:"s,omitempty"`
+	T  string          `json:"t,omitempty"`
+}
+
+// discordUser is a Discord user or bot.
+type discordUser struct {
+	ID  string `json:"id"`
+	Bot bool   `json:"bot"`
+}
+
+// discordMessage is a MESSAGE_CREATE event.
+type discordMessage struct {
+	ID        string        `json:"id"`
+	ChannelID string        `json:"channel_id"`
+	Content   string        `json:"content"`
+	Author    discordUser   `json:"author"`
+	Mentions  []discordUser `json:"mentions"`
+}
+
+// discordReaction is a MESSAGE_REACTION_ADD event.
+type discordReaction struct {
+	UserID    string `json:"user_id"`
+	ChannelID string `json:"channel_id"`
+	MessageID string `json:"message_id"`
+	Emoji     struct {
+		Name string `json:"name"`
+	} `json:"emoji"`
+}
+
+// runDiscordBot keeps the Discord bot connected while it is configured,
+// reconnecting after errors and when its token changes. It returns when
+// ctx is done.
+func (s *Server) runDiscordBot(ctx context.Context) {
+	backoff := time.Second
+	for ctx.Err() == nil {
+		settings, err := GetSettings(ctx, s.db)
+		if err != nil || settings.Bots == nil || settings.Bots.Discord == nil {
+			sleepContext(ctx, botSettingsPoll)
+			continue
+		}
+		start := time.Now()
+		err = s.runDiscordSession(ctx, settings.Bots.Discord.Token)
+		if ctx.Err() != nil {
+			return
+		}
+		if time.Since(start) > time.Minute {
+			backoff = time.Second
+		}
+		s.logger.Warn("Discord gateway disconnected", "error", err, "retry", backoff)
+		sleepContext(ctx, backoff)
+		backoff = min(backoff*2, time.Minute)
+	}
+}
+
+// sleepContext waits for d or until ctx is done.
+func sleepContext(ctx context.Context, d time.Duration) {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+	case <-ctx.Done():
+	}
+}
+
+// discordSession is a connection to the Discord gateway.
+type discordSession struct {
+	s       *Server
+	token   string
+	conn    net.Conn
+	r       io.Reader
+	writeMu sync.Mutex
+	seq     atomic.Int64 // last dispatch sequence number, 0 before the first
+	acked   atomic.Bool  // whether the last heartbeat was acknowledged
+	userID  atomic.Value // the bot's user ID, from READY
+}
+
+// runDiscordSession connects to the gateway and handles events until the
+// connection fails, the gateway asks for a reconnect, or the token changes.
+func (s *Server) runDiscordSession(ctx context.Context, token string) error {
+	conn, br, _, err := ws.Dial(ctx, discordGatewayURL)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	// Closing the connection unblocks the read loop
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	d := &discordSession{s: s, token: token, conn: conn, r: conn}
+	if br != nil {
+		d.r = br
+	}
+	d.userID.Store("")
+
+	hello, err := d.read()
+	if err != nil {
+		return err
+	}
+	var helloData struct {
+		HeartbeatInterval int64 `json:"heartbeat_interval"`
+	}
+	if hello.Op != discordOpHello || json.Unmarshal(hello.D, &helloData) != nil || helloData.HeartbeatInterval <= 0 {
+		return fmt.Errorf("unexpected gateway hello: op %d", hello.Op)
+	}
+	identify := map[string]any{
+		"token":   token,
+		"intents": discordIntents,
+		"properties": map[string]string{
+			"os":      "linux",
+			"browser": "shelley",
+			"device":  "shelley",
+		},
+	}
+	if err := d.send(discordOpIdentify, identify); err != nil {
+		return err
+	}
+	d.acked.Store(true)
+	go d.heartbeat(ctx, time.Duration(helloData.HeartbeatInterval)*time.Millisecond)
+
+	for {
+		p, err := d.read()
+		if err != nil {
+			return err
+		}
+		switch p.Op {
+		case discordOpDispatch:
+			d.seq.Store(p.S)
+			d.dispatch(ctx, p)
+		case discordOpHeartbeat:
+			if err := d.sendHeartbeat(); err != nil {
+				return err
+			}
+		case discordOpHeartbeatAck:
+			d.acked.Store(true)
+		case discordOpReconnect:
+			return errors.New("gateway asked to reconnect")
+		case discordOpInvalidSession:
+			return errors.New("invalid gateway session")
+		}
+	}
+}
+
+func (d *discordSession) read() (discordPayload, error) {
+	data, _, err := wsutil.ReadServerData(struct {
+		io.Reader
+		io.Writer
+	}{d.r, d})
+	if err != nil {
+		return discordPayload{}, err
+	}
+	var p discordPayload
+	err = json.Unmarshal(data, &p)
+	return p, err
+}
+
+// Write writes control frame replies for the read loop, serialized with
+// send.
+func (d *discordSession) Write(p []byte) (int, error) {
+	d.writeMu.Lock()
+	defer d.writeMu.Unlock()
+	return d.conn.Write(p)
+}
+
+func (d *discordSession) send(op int, data any) error {
+	payload, err := json.Marshal(map[string]any{"op": op, "d": data})
+	if err != nil {
+		return err
+	}
+	d.writeMu.Lock()
+	defer d.writeMu.Unlock()
+	return wsutil.WriteClientText(d.conn, payload)
+}
+
+func (d *discordSession) sendHeartbeat() error {
+	var seq any
+	if n := d.seq.Load(); n > 0 {
+		seq = n
+	}
+	return d.send(discordOpHeartbeat, seq)
+}
+
+// heartbeat keeps the connection alive, closing it if the gateway stops
+// acknowledging heartbeats or the bot's token changes.
+func (d *discordSession) heartbeat(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if !d.acked.Swap(false) {
+			d.s.logger.Warn("Discord gateway stopped acknowledging heartbeats")
+			d.conn.Close()
+			return
+		}
+		if bot := d.s.discordSettings(ctx); bot == nil || bot.Token != d.token {
+			d.conn.Close()
+			return
+		}
+		if err := d.sendHeartbeat(); err != nil {
+			d.conn.Close()
+			return
+		}
+	}
+}
+
+// dispatch handles a gateway event. Messages and reactions are handled in
+// the background so heartbeats aren't held up.
+func (d *discordSession) dispatch(ctx context.Context, p discordPayload) {
+	switch p.T {
+	case "READY":
+		var ready struct {
+			User discordUser `json:"user"`
+		}
+		if err := json.Unmarshal(p.D, &ready); err == nil {
+			d.userID.Store(ready.User.ID)
+		}
+	case "MESSAGE_CREATE":
+		var m discordMessage
+		if err := json.Unmarshal(p.D, &m); err == nil {
+			go d.handleMessage(ctx, m)
+		}
+	case "MESSAGE_REACTION_ADD":
+		var r discordReaction
+		if err := json.Unmarshal(p.D, &r); err == nil {
+			go d.handleReaction(ctx, r)
+		}
+	}
+}
+
+// discordSettings returns the Discord bot settings, or nil if the bot isn't
+// configured.
+func (s *Server) discordSettings(ctx context.Context) *DiscordBotSettings {
+	settings, err := GetSettings(ctx, s.db)
+	if err != nil {
+		s.logger.Warn("Failed to get settings for Discord", "error", err)
+		return nil
+	}
+	if settings.Bots == nil {
+		return nil
+	}
+	return settings.Bots.Discord
+}
+
+// handleMessage sends a Discord message to the agent. Messages count in
+// threads that already have a conversation; in the configured channels and
+// when mentioning the bot they start a thread and a conversation.
+func (d *discordSession) handleMessage(ctx context.Context, m discordMessage) {
+	botID := d.userID.Load().(string)
+	text := discordText(m.Content)
+	if m.Author.Bot || m.Author.ID == botID || text == "" {
+		return
+	}
+	bot := d.s.discordSettings(ctx)
+	if bot == nil || !slices.Contains(bot.Users, m.Author.ID) {
+		return
+	}
+	poster := &discordPoster{token: bot.Token, status: &d.s.discordStatus}
+
+	threadID := m.ChannelID
+	_, err := d.s.db.GetBridgeThread(ctx, discordPlatform, m.ChannelID)
+	if errors.Is(err, sql.ErrNoRows) {
+		mentioned := slices.ContainsFunc(m.Mentions, func(u discordUser) bool { return u.ID == botID })
+		if !mentioned && !slices.Contains(bot.Channels, m.ChannelID) {
+			return
+		}
+		// A message already in a thread can't start another, so the
+		// conversation takes over the thread it was posted in
+		if id, err := poster.startThread(ctx, m.ChannelID, m.ID, discordThreadName(text)); err == nil {
+			threadID = id
+		} else {
+			d.s.logger.Info("Using the message's channel as the conversation thread", "channelID", m.ChannelID, "error", err)
+		}
+	} else if err != nil {
+		d.s.logger.Error("Failed to get Discord thread", "channelID", m.ChannelID, "error", err)
+		return
+	}
+
+	if _, err := d.s.bridgeMessage(ctx, discordPlatform, threadID, text, bot.BotConversationSettings); err != nil {
+		d.s.logger.Error("Failed to send Discord message to the agent", "threadID", threadID, "error", err)
+		reply := bridgeReply{Text: "Failed to send the message to the agent: " + err.Error(), Error: true}
+		if err := poster.postReply(ctx, threadID, reply); err != nil {
+			d.s.logger.Warn("Failed to post Discord error", "threadID", threadID, "error", err)
+		}
+	}
+}
+
+// handleReaction stops the agent or approves its question when a user
+// reacts in a conversation's thread.
+func (d *discordSession) handleReaction(ctx context.Context, r discordReaction) {
+	if r.UserID == d.userID.Load().(string) {
+		return
+	}
+	bot := d.s.discordSettings(ctx)
+	if bot == nil || !slices.Contains(bot.Users, r.UserID) {
+		return
+	}
+	var err error
+	switch r.Emoji.Name {
+	case discordStopEmoji:
+		err = d.s.bridgeStop(ctx, discordPlatform, r.ChannelID)
+	case discordApproveEmoji:
+		err = d.s.bridgeAnswer(ctx, discordPlatform, r.ChannelID, "yes")
+	default:
+		return
+	}
+	if err != nil {
+		d.s.logger.Error("Failed to handle Discord reaction", "threadID", r.ChannelID, "emoji", r.Emoji.Name, "error", err)
+	}
+}
+
+// discordText removes mentions from a message.
+func discordText(content string) string {
+	return strings.TrimSpace(discordMentionPattern.ReplaceAllString(content, ""))
+}
+
+// discordThreadName names a thread after the first line of its message.
+func discordThreadName(text string) string {
+	line, _, _ := strings.Cut(text, "\n")
+	return truncateText(line, maxDiscordThreadName)
+}
+
+// discordReplyText formats a reply as Discord markdown.
+func discordReplyText(reply bridgeReply) string {
+	var b strings.Builder
+	if reply.Error {
+		b.WriteString("⚠️ ")
+	}
+	b.WriteString(truncateText(reply.Text, maxDiscordText))
+	if reply.Question {
+		b.WriteString("\n\nReply in this thread")
+		for i, option := range reply.Options {
+			if i == 0 {
+				b.WriteString(" with one of ")
+			} else {
+				b.WriteString(" / ")
+			}
+			b.WriteString("`" + strings.ReplaceAll(option, "`", "'") + "`")
+		}
+		b.WriteString(", or react " + discordApproveEmoji + " for yes.")
+	}
+	writeDiscordTools(&b, reply.Tools)
+	if reply.URL != "" {
+		b.WriteString("\n\n[Open in Shelley](<" + reply.URL + ">)")
+	}
+	return truncateText(b.String(), maxDiscordMessage-3)
+}
+
+// discordStatusText formats the status shown while the agent works.
+func discordStatusText(status bridgeReply) string {
+	var b strings.Builder
+	b.WriteString("Working... react " + discordStopEmoji + " to stop.")
+	writeDiscordTools(&b, status.Tools)
+	return truncateText(b.String(), maxDiscordMessage-3)
+}
+
+func writeDiscordTools(b *strings.Builder, tools []string) {
+	if len(tools) == 0 {
+		return
+	}
+	b.WriteString("\n\n**Tools**")
+	for _, tool := range tools {
+		b.WriteString("\n- `" + strings.ReplaceAll(tool, "`", "'") + "`")
+	}
+}
+
+// discordPoster posts replies through the Discord REST API. Thread IDs are
+// the threads' channel IDs.
+type discordPoster struct {
+	token  string
+	status *bridgeStatus
+}
+
+func (p *discordPoster) postStatus(ctx context.Context, threadID string, status bridgeReply) error {
+	content := discordStatusText(status)
+	return p.status.show(threadID, status.Seq, func() (string, error) {
+		id, err := p.send(ctx, threadID, content)
+		if err != nil {
+			return "", err
+		}
+		return id, p.react(ctx, threadID, id, discordStopEmoji)
+	}, func(id string) error {
+		return p.request(ctx, http.MethodPatch, "/channels/"+threadID+"/messages/"+id, map[string]any{"content": content}, nil)
+	})
+}
+
+func (p *discordPoster) postReply(ctx context.Context, threadID string, reply bridgeReply) error {
+	return p.status.end(threadID, reply.Seq, func(id string) error {
+		return p.request(ctx, http.MethodDelete, "/channels/"+threadID+"/messages/"+id, nil, nil)
+	}, func() error {
+		id, err := p.send(ctx, threadID, discordReplyText(reply))
+		if err != nil || !reply.Question {
+			return err
+		}
+		return p.react(ctx, threadID, id, discordApproveEmoji)
+	})
+}
+
+// send posts a message to a channel, returning its ID.
+func (p *discordPoster) send(ctx context.Context, channelID, content string) (string, error) {
+	var message struct {
+		ID string `json:"id"`
+	}
+	body := map[string]any{
+		"content":          content,
+		"allowed_mentions": map[string]any{"parse": []string{}},
+	}
+	err := p.request(ctx, http.MethodPost, "/channels/"+channelID+"/messages", body, &message)
+	return message.ID, err
+}
+
+// react adds the bot's reaction to a message, for users to click.
+func (p *discordPoster) react(ctx context.Context, channelID, messageID, emoji string) error {
+	return p.request(ctx, http.MethodPut, "/channels/"+channelID+"/messages/"+messageID+"/reactions/"+url.PathEscape(emoji)+"/@me", nil, nil)
+}
+
+// startThread starts a thread from a message, returning the thread's ID.
+func (p *discordPoster) startThread(ctx context.Context, channelID, messageID, name string) (string, error) {
+	var thread struct {
+		ID string `json:"id"`
+	}
+	err := p.request(ctx, http.MethodPost, "/channels/"+channelID+"/messages/"+messageID+"/threads", map[string]any{"name": name}, &thread)
+	return thread.ID, err
+}
+
+func (p *discordPoster) request(ctx context.Context, method, path string, body, result any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, discordAPIURL+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bot "+p.token)
+	req.Header.Set("User-Agent", "DiscordBot (https://github.com/anoworl/shelley, 1)")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := bridgeHTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("discord: %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	if result == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
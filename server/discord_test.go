@@ -0,0 +1,233 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+)
+
+// discordRequest is a REST request received by a fake Discord API.
+type discordRequest struct {
+	method  string
+	path    string
+	content string
+}
+
+// fakeDiscord is a Discord gateway and REST API. Events sent to events are
+// dispatched to the connected bot.
+type fakeDiscord struct {
+	*httptest.Server
+	events   chan string
+	requests chan discordRequest
+	identify chan map[string]any
+}
+
+func newFakeDiscord(t *testing.T) *fakeDiscord {
+	f := &fakeDiscord{
+		events:   make(chan string, 10),
+		requests: make(chan discordRequest, 100),
+		identify: make(chan map[string]any, 1),
+	}
+	var nextID atomic.Int64
+	f.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/gateway" {
+			f.serveGateway(t, w, r)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bot discord-token" {
+			t.Errorf("unexpected Authorization %q", r.Header.Get("Authorization"))
+		}
+		var body struct {
+			Content string `json:"content"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		path := strings.TrimPrefix(r.URL.Path, "/api")
+		f.requests <- discordRequest{method: r.Method, path: path, content: body.Content}
+		switch {
+		case strings.HasSuffix(path, "/threads"):
+			w.Write([]byte(`{"id": "T1"}`))
+		case r.Method == http.MethodPost:
+			fmt.Fprintf(w, `{"id": "m%d"}`, nextID.Add(1))
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	return f
+}
+
+func (f *fakeDiscord) serveGateway(t *testing.T, w http.ResponseWriter, r *http.Request) {
+	conn, _, _, err := ws.UpgradeHTTP(r, w)
+	if err != nil {
+		t.Errorf("upgrade: %v", err)
+		return
+	}
+	defer conn.Close()
+	wsutil.WriteServerText(conn, []byte(`{"op": 10, "d": {"heartbeat_interval": 45000}}`))
+	data, err := wsutil.ReadClientText(conn)
+	if err != nil {
+		return
+	}
+	var identify struct {
+		Op int            `json:"op"`
+		D  map[string]any `json:"d"`
+	}
+	json.Unmarshal(data, &identify)
+	f.identify <- identify.D
+	wsutil.WriteServerText(conn, []byte(`{"op": 0, "s": 1, "t": "READY", "d": {"user": {"id": "BOT", "bot": true}}}`))
+	seq := 1
+	for {
+		select {
+		case event := <-f.events:
+			seq++
+			wsutil.WriteServerText(conn, []byte(fmt.Sprintf(`{"op": 0, "s": %d, %s}`, seq, event)))
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func TestDiscordBot(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+
+	discord := newFakeDiscord(t)
+	defer discord.Close()
+	defer func(api, gateway string) { discordAPIURL, discordGatewayURL = api, gateway }(discordAPIURL, discordGatewayURL)
+	discordAPIURL = discord.URL + "/api"
+	discordGatewayURL = "ws" + strings.TrimPrefix(discord.URL, "http") + "/gateway"
+
+	// The bot must be limited to some users
+	w := httptest.NewRecorder()
+	h.server.handleSettings(w, httptest.NewRequest("POST", "/api/settings", strings.NewReader(`{"bots": {"discord": {"token": "discord-token"}}}`)))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("settings without users: expected 400, got %d: %s", w.Code, w.Body.String())
+	}
+
+	settings := `{"bots": {"discord": {"token": "discord-token", "channels": ["C1"], "users": ["U1"], "model": "predictable"}}}`
+	w = httptest.NewRecorder()
+	h.server.handleSettings(w, httptest.NewRequest("POST", "/api/settings", strings.NewReader(settings)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("save settings: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go h.server.runDiscordBot(ctx)
+
+	select {
+	case identify := <-discord.identify:
+		if identify["token"] != "discord-token" || identify["intents"] != float64(discordIntents) {
+			t.Errorf("unexpected identify %v", identify)
+		}
+	case <-time.After(h.timeout):
+		t.Fatal("timed out waiting for the bot to connect")
+	}
+
+	// seen collects the requests received so far
+	var seen []discordRequest
+	waitFor := func(match func(discordRequest) bool) discordRequest {
+		t.Helper()
+		for {
+			select {
+			case req := <-discord.requests:
+				seen = append(seen, req)
+				if match(req) {
+					return req
+				}
+			case <-time.After(h.timeout):
+				t.Fatalf("timed out waiting for a Discord request; got %+v", seen)
+				return discordRequest{}
+			}
+		}
+	}
+	reply := func(req discordRequest) bool {
+		return req.method == http.MethodPost && req.path == "/channels/T1/messages" && !strings.HasPrefix(req.content, "Working...")
+	}
+	message := func(id, channel, content string) string {
+		return fmt.Sprintf(`"t": "MESSAGE_CREATE", "d": {"id": %q, "channel_id": %q, "content": %q, "author": {"id": "U1"}}`, id, channel, content)
+	}
+	reaction := func(emoji string) string {
+		return fmt.Sprintf(`"t": "MESSAGE_REACTION_ADD", "d": {"user_id": "U1", "channel_id": "T1", "message_id": "m1", "emoji": {"name": %q}}`, emoji)
+	}
+
+	// Other users are ignored, and a message in a watched channel starts a
+	// thread and a conversation
+	discord.events <- strings.Replace(message("M0", "C1", "echo: stranger"), `"U1"`, `"U2"`, 1)
+	discord.events <- message("M1", "C1", "echo: hello")
+	if req := waitFor(reply); !strings.HasPrefix(req.content, "hello") {
+		t.Errorf("unexpected reply %q", req.content)
+	}
+	if seen[0].method != http.MethodPost || seen[0].path != "/channels/C1/messages/M1/threads" {
+		t.Errorf("expected a thread to be started, got %+v", seen[0])
+	}
+	thread, err := h.db.GetBridgeThread(context.Background(), discordPlatform, "T1")
+	if err != nil {
+		t.Fatalf("thread not mapped: %v", err)
+	}
+
+	// Replies in the thread continue it; the status is replaced by the reply
+	seen = nil
+	discord.events <- message("M2", "T1", "bash: echo hi")
+	if req := waitFor(reply); !strings.Contains(req.content, "**Tools**") || !strings.Contains(req.content, "bash: echo hi") {
+		t.Errorf("unexpected reply %q", req.content)
+	}
+	var created, deleted int
+	for _, req := range seen {
+		switch {
+		case req.method == http.MethodPost && strings.HasPrefix(req.content, "Working..."):
+			created++
+		case req.method == http.MethodDelete:
+			deleted++
+		}
+	}
+	if created != deleted {
+		t.Errorf("%d status messages posted but %d deleted: %+v", created, deleted, seen)
+	}
+
+	// A stop reaction cancels the turn
+	discord.events <- message("M3", "T1", "delay: 30")
+	deadline := time.Now().Add(h.timeout)
+	for busy, _ := h.server.agentBusyNow(context.Background(), thread.ConversationID); !busy; busy, _ = h.server.agentBusyNow(context.Background(), thread.ConversationID) {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the agent to start")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	discord.events <- reaction(discordStopEmoji)
+	if req := waitFor(reply); !strings.Contains(req.content, "[Operation cancelled]") {
+		t.Errorf("unexpected reply after stopping %q", req.content)
+	}
+
+	// An approve reaction answers a question with yes
+	discord.events <- message("M4", "T1", "ask: Deploy now? | yes | no")
+	if req := waitFor(reply); !strings.Contains(req.content, "Deploy now?") {
+		t.Errorf("unexpected question %q", req.content)
+	}
+	waitFor(func(req discordRequest) bool {
+		return req.method == http.MethodPut && strings.Contains(req.path, "/reactions/")
+	})
+	discord.events <- reaction(discordApproveEmoji)
+	waitFor(reply)
+	messages, err := h.server.bridgeMessages(context.Background(), thread.ConversationID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, msg := range messages {
+		if msg.LlmData != nil && strings.Contains(string(*msg.LlmData), `"Text":"yes"`) {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("question wasn't answered with yes")
+	}
+}
@@ -0,0 +1,204 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+
+	"shelley.exe.dev/claudetool"
+	"shelley.exe.dev/db/generated"
+	"shelley.exe.dev/docindex"
+	"shelley.exe.dev/embed"
+)
+
+const docSearchLimit = 5
+
+type pendingDocFile struct {
+	file    docindex.File
+	chunks  []docindex.Chunk
+	vectors [][]float32
+}
+
+// queueDocs has runIndexer bring the documentation index for a repository
+// root up to date.
+func (s *Server) queueDocs(root string) {
+	s.docRootMu.Lock()
+	s.docRoots[root] = true
+	s.docRootMu.Unlock()
+	s.wakeIndexer()
+}
+
+// docsQueued reports whether the documentation index for root is queued or
+// being brought up to date.
+func (s *Server) docsQueued(root string) bool {
+	s.docRootMu.Lock()
+	defer s.docRootMu.Unlock()
+	return s.docRoots[root]
+}
+
+// indexQueuedDocs brings the documentation indexes of the queued roots up to
+// date.
+func (s *Server) indexQueuedDocs(ctx context.Context) {
+	s.docRootMu.Lock()
+	roots := slices.Collect(maps.Keys(s.docRoots))
+	s.docRootMu.Unlock()
+	for _, root := range roots {
+		if err := s.indexDocs(ctx, root); err != nil && ctx.Err() == nil {
+			s.logger.Warn("Failed to index documentation", "root", root, "error", err)
+		}
+		s.docRootMu.Lock()
+		delete(s.docRoots, root)
+		s.docRootMu.Unlock()
+	}
+}
+
+// indexDocs brings the documentation index for a repository root up to date:
+// new and changed files are re-chunked and embedded, deleted files are dropped.
+// Files are embedded and stored a batch at a time.
+func (s *Server) indexDocs(ctx context.Context, root string) error {
+	model := s.embedder.Model()
+	files, err := docindex.Scan(root)
+	if err != nil {
+		return fmt.Errorf("failed to scan %s: %w", root, err)
+	}
+
+	var indexed []generated.ListDocFileHashesRow
+	err = s.db.Queries(ctx, func(q *generated.Queries) error {
+		var err error
+		indexed, err = q.ListDocFileHashes(ctx, generated.ListDocFileHashesParams{Root: root, Model: model})
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list indexed files: %w", err)
+	}
+	stale := make(map[string]string, len(indexed))
+	for _, row := range indexed {
+		stale[row.Path] = row.FileHash
+	}
+
+	var pending []*pendingDocFile
+	for _, f := range files {
+		hash, ok := stale[f.Path]
+		delete(stale, f.Path)
+		if ok && hash == f.Hash {
+			continue
+		}
+		p := &pendingDocFile{file: f, chunks: f.Chunks()}
+		if !ok && len(p.chunks) == 0 {
+			continue
+		}
+		pending = append(pending, p)
+	}
+
+	err = s.db.QueriesTx(ctx, func(q *generated.Queries) error {
+		for path := range stale {
+			if err := q.DeleteDocFileChunks(ctx, generated.DeleteDocFileChunksParams{Root: root, Model: model, Path: path}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Whole files a batch, so a file's chunks are replaced together
+	for len(pending) > 0 {
+		n, chunks := 0, 0
+		for n < len(pending) && (n == 0 || chunks+len(pending[n].chunks) <= embedBatchSize) {
+			chunks += len(pending[n].chunks)
+			n++
+		}
+		if err := s.indexDocFiles(ctx, root, pending[:n]); err != nil {
+			return err
+		}
+		pending = pending[n:]
+	}
+	return nil
+}
+
+// indexDocFiles embeds the chunks of files and replaces theirs in the index.
+func (s *Server) indexDocFiles(ctx context.Context, root string, files []*pendingDocFile) error {
+	s.indexMu.Lock()
+	defer s.indexMu.Unlock()
+
+	model := s.embedder.Model()
+	var texts []string
+	for _, p := range files {
+		for _, c := range p.chunks {
+			texts = append(texts, p.file.Path+"\n"+c.Content)
+		}
+	}
+	vectors, err := s.embedTexts(ctx, texts)
+	if err != nil {
+		return err
+	}
+	for _, p := range files {
+		p.vectors, vectors = vectors[:len(p.chunks)], vectors[len(p.chunks):]
+	}
+
+	return s.db.QueriesTx(ctx, func(q *generated.Queries) error {
+		for _, p := range files {
+			if err := q.DeleteDocFileChunks(ctx, generated.DeleteDocFileChunksParams{Root: root, Model: model, Path: p.file.Path}); err != nil {
+				return err
+			}
+			for i, c := range p.chunks {
+				if err := q.CreateDocChunk(ctx, generated.CreateDocChunkParams{
+					Root:       root,
+					Model:      model,
+					Path:       p.file.Path,
+					ChunkIndex: int64(i),
+					StartLine:  int64(c.StartLine),
+					FileHash:   p.file.Hash,
+					Content:    c.Content,
+					Vector:     embed.Encode(p.vectors[i]),
+				}); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// searchDocs returns the indexed documentation chunks of the repository
+// containing workingDir that are closest in meaning to query, and queues the
+// index to be brought up to date.
+func (s *Server) searchDocs(ctx context.Context, workingDir, query string) ([]claudetool.DocSearchResult, error) {
+	root := docindex.Root(workingDir)
+	s.queueDocs(root)
+	vectors, err := s.embedder.Embed(ctx, []string{query})
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []generated.SearchDocChunksRow
+	err = s.db.Queries(ctx, func(q *generated.Queries) error {
+		var err error
+		rows, err = q.SearchDocChunks(ctx, generated.SearchDocChunksParams{
+			Query: embed.Encode(vectors[0]),
+			Root:  root,
+			Model: s.embedder.Model(),
+			Limit: docSearchLimit,
+		})
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to search docs: %w", err)
+	}
+	if len(rows) == 0 && s.docsQueued(root) {
+		return nil, fmt.Errorf("the documentation of %s is still being indexed; try again shortly", root)
+	}
+
+	results := make([]claudetool.DocSearchResult, len(rows))
+	for i, r := range rows {
+		results[i] = claudetool.DocSearchResult{
+			Path:      r.Path,
+			StartLine: int(r.StartLine),
+			Content:   r.Content,
+			Score:     1 - r.Distance,
+		}
+	}
+	return results, nil
+}
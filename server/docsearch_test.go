@@ -0,0 +1,72 @@
+package server
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"shelley.exe.dev/embed"
+)
+
+func TestSearchDocs(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+	h.server.SetEmbedder(&embed.Hash{})
+	ctx := context.Background()
+
+	dir := t.TempDir()
+	write := func(name, content string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("README.md", "# Project\n\nRun the tests with make check.\n")
+	write("DEPLOY.md", "Deploying to production requires the VPN and a signed release tag.\n")
+	write("retry.go", "package x\n\n// Retries back off exponentially up to thirty seconds because the upstream\n// rate limiter penalizes bursts of requests.\nfunc retry() {}\n")
+
+	// The first search queues the repository to be indexed
+	if _, err := h.server.searchDocs(ctx, dir, "deploying to production"); err == nil || !strings.Contains(err.Error(), "being indexed") {
+		t.Fatalf("expected the index still being built, got %v", err)
+	}
+	h.server.indexQueuedDocs(ctx)
+
+	results, err := h.server.searchDocs(ctx, dir, "deploying to production")
+	if err != nil {
+		t.Fatalf("searchDocs failed: %v", err)
+	}
+	if len(results) != 3 || results[0].Path != "DEPLOY.md" || results[0].StartLine != 1 {
+		t.Fatalf("expected DEPLOY.md first among 3 results, got %+v", results)
+	}
+
+	results, err = h.server.searchDocs(ctx, dir, "why do retries back off")
+	if err != nil {
+		t.Fatalf("searchDocs failed: %v", err)
+	}
+	if results[0].Path != "retry.go" || results[0].StartLine != 3 || strings.Contains(results[0].Content, "//") {
+		t.Errorf("expected retry.go comment first, got %+v", results[0])
+	}
+
+	// Changed and deleted files are reindexed
+	write("README.md", "# Project\n\nThe database schema lives in db/schema.\n")
+	if err := os.Remove(filepath.Join(dir, "DEPLOY.md")); err != nil {
+		t.Fatal(err)
+	}
+	// Searches serve the index as it was until it's brought up to date
+	results, err = h.server.searchDocs(ctx, dir, "database schema")
+	if err != nil {
+		t.Fatalf("searchDocs failed: %v", err)
+	}
+	if len(results) != 3 {
+		t.Errorf("expected the 3 results indexed before, got %+v", results)
+	}
+	h.server.indexQueuedDocs(ctx)
+	results, err = h.server.searchDocs(ctx, dir, "database schema")
+	if err != nil {
+		t.Fatalf("searchDocs failed: %v", err)
+	}
+	if len(results) != 2 || results[0].Path != "README.md" || !strings.Contains(results[0].Content, "db/schema") {
+		t.Errorf("expected updated README first among 2 results, got %+v", results)
+	}
+}
@@ -0,0 +1,165 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+
+	"shelley.exe.dev/db/generated"
+	"shelley.exe.dev/llm"
+)
+
+// Editing a user message reruns the conversation from it with new text:
+// the messages from it on are rolled back, as with rollback, and the edited
+// message is sent in its place. A fork leaves the conversation alone and
+// reruns the turn in a copy of the messages before it.
+
+// EditMessageRequest is the body of POST /api/conversation/<id>/edit.
+type EditMessageRequest struct {
+	// SequenceID is the user message to edit.
+	SequenceID int64 `json:"sequence_id"`
+	// Message replaces the message's text. Its images and documents are
+	// kept.
+	Message string `json:"message"`
+	// Model runs the turn; empty means the conversation's model.
+	Model string `json:"model,omitempty"`
+	// Fork reruns the turn in a new conversation instead.
+	Fork bool `json:"fork,omitempty"`
+	// RestoreWorkspace also restores the worktree to how it was when the
+	// message was first sent. It can't be combined with Fork.
+	RestoreWorkspace bool `json:"restore_workspace,omitempty"`
+}
+
+// handleEditMessage handles POST /conversation/<id>/edit
+func (s *Server) handleEditMessage(w http.ResponseWriter, r *http.Request, conversationID string) {
+	if s.refuseWhileDraining(w) {
+		return
+	}
+	var req EditMessageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if req.Message == "" {
+		http.Error(w, "Message is required", http.StatusBadRequest)
+		return
+	}
+	if req.Fork && req.RestoreWorkspace {
+		http.Error(w, "A fork shares the workspace; restore_workspace only applies to editing in place", http.StatusBadRequest)
+		return
+	}
+	if !req.Fork && s.refuseIfLeasedElsewhere(w, r, conversationID) {
+		return
+	}
+
+	ctx := r.Context()
+	conversation, err := s.db.GetConversationByID(ctx, conversationID)
+	if err != nil {
+		http.Error(w, "Conversation not found", http.StatusNotFound)
+		return
+	}
+	messages, err := s.checkAgentIdle(ctx, conversationID)
+	if errors.Is(err, errAgentBusy) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		s.logger.Error("Failed to list messages", "conversationID", conversationID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	i := slices.IndexFunc(messages, func(m generated.Message) bool { return m.SequenceID == req.SequenceID })
+	if i < 0 {
+		http.Error(w, "Message not found", http.StatusBadRequest)
+		return
+	}
+	original, err := convertToLLMMessage(messages[i])
+	if err != nil || !isUserText(original) {
+		http.Error(w, "Only messages the user sent can be edited", http.StatusBadRequest)
+		return
+	}
+
+	modelID := req.Model
+	if modelID == "" && conversation.ModelID != nil {
+		modelID = *conversation.ModelID
+	}
+	if modelID == "" {
+		modelID = s.defaultModel
+	}
+	llmService, err := s.llmManager.GetService(modelID)
+	if err != nil {
+		http.Error(w, "Unsupported model: "+modelID, http.StatusBadRequest)
+		return
+	}
+
+	response := map[string]string{"status": "accepted", "conversation_id": conversationID}
+	if req.Fork {
+		forkID, err := s.forkConversation(ctx, conversation, messages[:i])
+		if err != nil {
+			s.logger.Error("Failed to fork conversation", "conversationID", conversationID, "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		response["conversation_id"] = forkID
+	} else {
+		if req.RestoreWorkspace {
+			backup, err := s.restoreCheckpoint(ctx, conversationID, req.SequenceID-1)
+			if errors.Is(err, sql.ErrNoRows) {
+				http.Error(w, "No workspace checkpoint to restore", http.StatusConflict)
+				return
+			}
+			if err != nil {
+				s.logger.Error("Failed to restore workspace", "conversationID", conversationID, "error", err)
+				http.Error(w, "Failed to restore workspace", http.StatusInternalServerError)
+				return
+			}
+			response["backup_commit"] = backup
+		}
+		if err := s.db.RollbackConversation(ctx, conversationID, req.SequenceID-1); err != nil {
+			s.logger.Error("Failed to roll back conversation", "conversationID", conversationID, "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		// The loop's in-memory history still has the removed messages
+		s.resetConversationManager(conversationID)
+	}
+
+	manager, err := s.getOrCreateConversationManager(ctx, response["conversation_id"])
+	if err == nil {
+		_, err = manager.AcceptUserMessage(ctx, llmService, modelID, editedMessage(original, req.Message))
+	}
+	if errors.Is(err, errConversationModelMismatch) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, errConversationOwned) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		s.logger.Error("Failed to send edited message", "conversationID", response["conversation_id"], "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	go s.broadcastConversationUpdate(context.WithoutCancel(ctx), response["conversation_id"])
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(response)
+}
+
+// editedMessage returns the user message original with its text replaced
+// by text, keeping its attachments and thinking.
+func editedMessage(original llm.Message, text string) llm.Message {
+	content := []llm.Content{{Type: llm.ContentTypeText, Text: text}}
+	for _, c := range original.Content {
+		if c.Type != llm.ContentTypeText {
+			content = append(content, c)
+		}
+	}
+	return llm.Message{Role: llm.MessageRoleUser, Content: content, Thinking: original.Thinking}
+}
@@ -0,0 +1,119 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"shelley.exe.dev/db"
+	"shelley.exe.dev/db/generated"
+)
+
+func (h *TestHarness) edit(body string) *httptest.ResponseRecorder {
+	h.t.Helper()
+	// The loop may still be finishing the turn after the response is recorded.
+	deadline := time.Now().Add(h.timeout)
+	for {
+		req := httptest.NewRequest("POST", "/api/conversation/"+h.convID+"/edit", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		h.server.handleEditMessage(w, req, h.convID)
+		if w.Code != http.StatusConflict || time.Now().After(deadline) {
+			return w
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// userMessageTexts returns the text of a conversation's user messages.
+func userMessageTexts(t *testing.T, messages []generated.Message) []string {
+	t.Helper()
+	var texts []string
+	for _, msg := range messages {
+		if msg.Type != string(db.MessageTypeUser) {
+			continue
+		}
+		llmMsg, err := convertToLLMMessage(msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if isUserText(llmMsg) {
+			texts = append(texts, llmMsg.Content[0].Text)
+		}
+	}
+	return texts
+}
+
+func TestEditMessage(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+	h.NewConversation("echo: first", t.TempDir())
+	h.WaitResponse()
+	h.Chat("echo: second")
+	h.WaitResponse()
+
+	messages := h.messages()
+	first := messages[slices.IndexFunc(messages, func(m generated.Message) bool { return m.Type == string(db.MessageTypeUser) })]
+	reply := messages[len(messages)-1]
+	if w := h.edit(fmt.Sprintf(`{"sequence_id": %d, "message": "echo: changed"}`, reply.SequenceID)); w.Code != http.StatusBadRequest {
+		t.Errorf("editing an agent message: expected 400, got %d", w.Code)
+	}
+	if w := h.edit(fmt.Sprintf(`{"sequence_id": %d, "message": "echo: changed", "fork": true, "restore_workspace": true}`, first.SequenceID)); w.Code != http.StatusBadRequest {
+		t.Errorf("restoring the workspace of a fork: expected 400, got %d", w.Code)
+	}
+
+	// A fork leaves the conversation alone
+	w := h.edit(fmt.Sprintf(`{"sequence_id": %d, "message": "echo: forked", "fork": true}`, reply.SequenceID-1))
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("fork: expected 202, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp map[string]string
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	forkID := resp["conversation_id"]
+	if forkID == "" || forkID == h.convID {
+		t.Fatalf("expected a new conversation, got %q", forkID)
+	}
+	var forked []string
+	for deadline := time.Now().Add(h.timeout); time.Now().Before(deadline); time.Sleep(20 * time.Millisecond) {
+		var forkMessages []generated.Message
+		if err := h.db.Queries(context.Background(), func(q *generated.Queries) error {
+			var err error
+			forkMessages, err = q.ListMessages(context.Background(), forkID)
+			return err
+		}); err != nil {
+			t.Fatal(err)
+		}
+		if len(forkMessages) == 0 {
+			continue
+		}
+		if last := forkMessages[len(forkMessages)-1]; last.Type == string(db.MessageTypeAgent) && strings.Contains(string(*last.LlmData), `"forked"`) {
+			forked = userMessageTexts(t, forkMessages)
+			break
+		}
+	}
+	if !slices.Equal(forked, []string{"echo: first", "echo: forked"}) {
+		t.Errorf("expected the fork to rerun the edited message, got %q", forked)
+	}
+	if texts := userMessageTexts(t, h.messages()); !slices.Equal(texts, []string{"echo: first", "echo: second"}) {
+		t.Errorf("expected the conversation to be left alone, got %q", texts)
+	}
+
+	// Editing in place replaces the message and everything after it
+	if w := h.edit(fmt.Sprintf(`{"sequence_id": %d, "message": "echo: changed"}`, first.SequenceID)); w.Code != http.StatusAccepted {
+		t.Fatalf("edit: expected 202, got %d: %s", w.Code, w.Body.String())
+	}
+	h.responsesCount = 0
+	if text := h.WaitResponse(); text != "changed" {
+		t.Errorf("expected the edited message to be answered, got %q", text)
+	}
+	if texts := userMessageTexts(t, h.messages()); !slices.Equal(texts, []string{"echo: changed"}) {
+		t.Errorf("expected only the edited message, got %q", texts)
+	}
+}
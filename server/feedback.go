@@ -0,0 +1,160 @@
+package server
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"shelley.exe.dev/db"
+	"shelley.exe.dev/db/generated"
+	"shelley.exe.dev/llm"
+)
+
+// Feedback is a thumbs up or down and/or a comment on an agent message. It
+// is stored with the model that generated the message, so the feedback
+// across conversations can be used to evaluate prompts and models.
+
+// maxListedFeedback caps GET /api/feedback.
+const maxListedFeedback = 1000
+
+// FeedbackRequest is the body of PUT /api/conversation/<id>/feedback.
+// An empty rating or comment keeps the message's current one.
+type FeedbackRequest struct {
+	MessageID string `json:"message_id"`
+	// Rating is "up" or "down".
+	Rating  string `json:"rating,omitempty"`
+	Comment string `json:"comment,omitempty"`
+}
+
+// FeedbackTally counts the ratings of a model's messages.
+type FeedbackTally struct {
+	Up   int `json:"up"`
+	Down int `json:"down"`
+}
+
+// FeedbackResponse is the response to GET /api/feedback.
+type FeedbackResponse struct {
+	Feedback []generated.MessageFeedback `json:"feedback"`
+	// ByModel tallies the ratings in Feedback by model.
+	ByModel map[string]FeedbackTally `json:"by_model"`
+}
+
+// handleConversationFeedback handles GET and PUT /conversation/<id>/feedback
+func (s *Server) handleConversationFeedback(w http.ResponseWriter, r *http.Request, conversationID string) {
+	ctx := r.Context()
+	conversation, err := s.db.GetConversationByID(ctx, conversationID)
+	if err != nil {
+		http.Error(w, "Conversation not found", http.StatusNotFound)
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		feedback, err := s.db.ListConversationFeedback(ctx, conversationID)
+		if err != nil {
+			s.logger.Error("Failed to list feedback", "conversationID", conversationID, "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(feedback)
+		return
+	}
+
+	var req FeedbackRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if req.Rating != "" && req.Rating != "up" && req.Rating != "down" {
+		http.Error(w, `Rating must be "up" or "down"`, http.StatusBadRequest)
+		return
+	}
+	req.Comment = strings.TrimSpace(req.Comment)
+	if req.Rating == "" && req.Comment == "" {
+		http.Error(w, "Feedback needs a rating or a comment", http.StatusBadRequest)
+		return
+	}
+	message, err := s.db.GetMessageByID(ctx, req.MessageID)
+	if err != nil || message.ConversationID != conversationID || message.DeletedAt != nil {
+		http.Error(w, "Message not found", http.StatusBadRequest)
+		return
+	}
+	if message.Type != string(db.MessageTypeAgent) {
+		http.Error(w, "Only agent messages take feedback", http.StatusBadRequest)
+		return
+	}
+
+	params := db.MessageFeedbackParams{
+		MessageID:      message.MessageID,
+		ConversationID: conversationID,
+		Model:          messageModel(message, conversation),
+	}
+	if req.Rating != "" {
+		params.Rating = &req.Rating
+	}
+	if req.Comment != "" {
+		params.Comment = &req.Comment
+	}
+	feedback, err := s.db.UpsertMessageFeedback(ctx, params)
+	if err != nil {
+		s.logger.Error("Failed to record feedback", "conversationID", conversationID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(feedback)
+}
+
+// handleDeleteFeedback handles DELETE /conversation/<id>/feedback/<message_id>
+func (s *Server) handleDeleteFeedback(w http.ResponseWriter, r *http.Request, conversationID string) {
+	err := s.db.DeleteMessageFeedback(r.Context(), conversationID, r.PathValue("message"))
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Feedback not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		s.logger.Error("Failed to delete feedback", "conversationID", conversationID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleFeedback handles GET /api/feedback.
+func (s *Server) handleFeedback(w http.ResponseWriter, r *http.Request) {
+	feedback, err := s.db.ListMessageFeedback(r.Context(), maxListedFeedback)
+	if err != nil {
+		s.logger.Error("Failed to list feedback", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	resp := FeedbackResponse{Feedback: feedback, ByModel: map[string]FeedbackTally{}}
+	for _, f := range feedback {
+		if f.Rating == nil || f.Model == nil {
+			continue
+		}
+		tally := resp.ByModel[*f.Model]
+		if *f.Rating == "up" {
+			tally.Up++
+		} else {
+			tally.Down++
+		}
+		resp.ByModel[*f.Model] = tally
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// messageModel returns the model that generated an agent message: the one
+// its usage records, or else the conversation's.
+func messageModel(message *generated.Message, conversation *generated.Conversation) *string {
+	if message.UsageData != nil {
+		var usage llm.Usage
+		if json.Unmarshal([]byte(*message.UsageData), &usage) == nil && usage.Model != "" {
+			return &usage.Model
+		}
+	}
+	return conversation.ModelID
+}
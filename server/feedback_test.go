@@ -0,0 +1,85 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"shelley.exe.dev/db"
+	"shelley.exe.dev/db/generated"
+)
+
+func (h *TestHarness) feedback(method, path, body string) *httptest.ResponseRecorder {
+	h.t.Helper()
+	req := httptest.NewRequest(method, "/api/conversation/"+h.convID+path, strings.NewReader(body))
+	w := httptest.NewRecorder()
+	http.StripPrefix("/api/conversation", h.server.conversationMux()).ServeHTTP(w, req)
+	return w
+}
+
+func TestMessageFeedback(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+	h.NewConversation("echo: hello", t.TempDir())
+	h.WaitResponse()
+
+	var user, agent generated.Message
+	for _, msg := range h.messages() {
+		switch msg.Type {
+		case string(db.MessageTypeUser):
+			user = msg
+		case string(db.MessageTypeAgent):
+			agent = msg
+		}
+	}
+
+	for name, body := range map[string]string{
+		"bad rating":      `{"message_id": "` + agent.MessageID + `", "rating": "meh"}`,
+		"no feedback":     `{"message_id": "` + agent.MessageID + `", "comment": "  "}`,
+		"user message":    `{"message_id": "` + user.MessageID + `", "rating": "up"}`,
+		"unknown message": `{"message_id": "nope", "rating": "up"}`,
+	} {
+		if w := h.feedback("PUT", "/feedback", body); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", name, w.Code)
+		}
+	}
+
+	if w := h.feedback("PUT", "/feedback", `{"message_id": "`+agent.MessageID+`", "rating": "down"}`); w.Code != http.StatusOK {
+		t.Fatalf("feedback: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	// Later feedback updates what it names, and keeps the rest
+	for _, body := range []string{
+		`{"message_id": "` + agent.MessageID + `", "comment": "Nice"}`,
+		`{"message_id": "` + agent.MessageID + `", "rating": "up"}`,
+	} {
+		if w := h.feedback("PUT", "/feedback", body); w.Code != http.StatusOK {
+			t.Fatalf("feedback: expected 200, got %d: %s", w.Code, w.Body.String())
+		}
+	}
+	var feedback []generated.MessageFeedback
+	if err := json.Unmarshal(h.feedback("GET", "/feedback", "").Body.Bytes(), &feedback); err != nil {
+		t.Fatal(err)
+	}
+	if len(feedback) != 1 || *feedback[0].Rating != "up" || *feedback[0].Comment != "Nice" || feedback[0].Model == nil {
+		t.Fatalf("unexpected feedback: %+v", feedback)
+	}
+
+	w := httptest.NewRecorder()
+	h.server.handleFeedback(w, httptest.NewRequest("GET", "/api/feedback", nil))
+	var all FeedbackResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &all); err != nil {
+		t.Fatal(err)
+	}
+	if len(all.Feedback) != 1 || all.ByModel[*feedback[0].Model] != (FeedbackTally{Up: 1}) {
+		t.Errorf("unexpected feedback across conversations: %+v", all)
+	}
+
+	if w := h.feedback("DELETE", "/feedback/"+agent.MessageID, ""); w.Code != http.StatusNoContent {
+		t.Errorf("delete: expected 204, got %d", w.Code)
+	}
+	if w := h.feedback("DELETE", "/feedback/"+agent.MessageID, ""); w.Code != http.StatusNotFound {
+		t.Errorf("second delete: expected 404, got %d", w.Code)
+	}
+}
@@ -0,0 +1,275 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/pkg/diff"
+	"shelley.exe.dev/claudetool"
+	"shelley.exe.dev/db"
+	"shelley.exe.dev/db/generated"
+	"shelley.exe.dev/gitstate"
+)
+
+// maxTrackedFileSize is the largest file whose changes by bash commands are
+// recorded. Bigger files are usually build outputs or data, not edits.
+const maxTrackedFileSize = 1 << 20
+
+// trackBashChanges returns the TrackBashChanges hook for a conversation's
+// tools. For commands run in a git worktree, the files that git status
+// reports changed are compared before and after, and each that differs is
+// recorded as a bash file edit.
+func (s *Server) trackBashChanges(conversationID string) func(ctx context.Context, dir string) func() {
+	return func(ctx context.Context, dir string) func() {
+		tracker, err := gitstate.TrackChanges(dir, maxTrackedFileSize)
+		if err != nil {
+			if !errors.Is(err, gitstate.ErrNotRepo) {
+				s.logger.Warn("Failed to track workspace changes before bash", "conversationID", conversationID, "dir", dir, "error", err)
+			}
+			return func() {}
+		}
+
+		return func() {
+			// Record changes even if the command was cancelled
+			ctx := context.WithoutCancel(ctx)
+			s.invalidateGitDiffs(dir)
+			changes, err := tracker.Changes()
+			if err != nil {
+				s.logger.Warn("Failed to find bash changes", "conversationID", conversationID, "dir", dir, "error", err)
+				return
+			}
+
+			var toolUseID *string
+			if id := claudetool.ToolUseID(ctx); id != "" {
+				toolUseID = &id
+			}
+			for _, change := range changes {
+				if _, err := s.db.CreateFileEdit(ctx, db.CreateFileEditParams{
+					ConversationID: &conversationID,
+					ToolUseID:      toolUseID,
+					Source:         db.FileEditSourceBash,
+					Path:           change.Path,
+					Before:         change.Before,
+					After:          change.After,
+				}); err != nil {
+					s.logger.Warn("Failed to record bash file edit", "conversationID", conversationID, "path", change.Path, "error", err)
+				}
+			}
+		}
+	}
+}
+
+// ConversationFileChange is the net change a conversation made to a file,
+// over all of its edits that have not been reverted.
+type ConversationFileChange struct {
+	Path      string   `json:"path"`
+	Status    string   `json:"status"` // added, modified, deleted
+	Additions int      `json:"additions"`
+	Deletions int      `json:"deletions"`
+	Sources   []string `json:"sources"`
+	// Turns are the turns the file was edited in (see FileEditInfo.Turn).
+	Turns []int64 `json:"turns"`
+}
+
+// fileChange accumulates the edits to one file.
+type fileChange struct {
+	first, last generated.FileEdit
+	edits       int
+	sources     []string
+	turns       []int64
+}
+
+// foldFileEdits folds a conversation's edits that have not been reverted into
+// one change per file, in the order the files were first edited.
+func foldFileEdits(edits []generated.FileEdit, starts []int64) ([]string, map[string]*fileChange) {
+	var paths []string
+	changes := make(map[string]*fileChange)
+	for _, edit := range edits {
+		if edit.RevertedAt != nil {
+			continue
+		}
+		change, ok := changes[edit.Path]
+		if !ok {
+			change = &fileChange{first: edit}
+			changes[edit.Path] = change
+			paths = append(paths, edit.Path)
+		}
+		change.last = edit
+		change.edits++
+		if !slices.Contains(change.sources, edit.Source) {
+			change.sources = append(change.sources, edit.Source)
+		}
+		if turn := editTurn(edit, starts); !slices.Contains(change.turns, turn) {
+			change.turns = append(change.turns, turn)
+		}
+	}
+	return paths, changes
+}
+
+// netFileChanges is foldFileEdits without the files left as they started.
+func netFileChanges(edits []generated.FileEdit, starts []int64) ([]string, map[string]*fileChange) {
+	paths, changes := foldFileEdits(edits, starts)
+	kept := paths[:0]
+	for _, path := range paths {
+		if changes[path].unchanged() {
+			delete(changes, path)
+			continue
+		}
+		kept = append(kept, path)
+	}
+	return kept, changes
+}
+
+// unchanged reports whether the file ended as it started.
+func (c *fileChange) unchanged() bool {
+	existed, exists := !c.first.NewFile, !c.last.DeletedFile
+	return existed == exists && (!exists || bytes.Equal(c.first.BeforeContent, c.last.AfterContent))
+}
+
+// status describes the file's net change as git_handlers does.
+func (c *fileChange) status() string {
+	switch {
+	case c.first.NewFile:
+		return "added"
+	case c.last.DeletedFile:
+		return "deleted"
+	default:
+		return "modified"
+	}
+}
+
+// lineCounts returns the number of lines added and removed by the change.
+func (c *fileChange) lineCounts(path string) (additions, deletions int) {
+	var d strings.Builder
+	if err := diff.Text(path, path, c.first.BeforeContent, c.last.AfterContent, &d); err != nil {
+		return 0, 0
+	}
+	for _, line := range strings.Split(d.String(), "\n") {
+		switch {
+		case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"):
+		case strings.HasPrefix(line, "+"):
+			additions++
+		case strings.HasPrefix(line, "-"):
+			deletions++
+		}
+	}
+	return additions, deletions
+}
+
+// handleConversationChanges handles GET /conversation/<id>/changes, which
+// lists the files the conversation changed. With ?path=, it returns that
+// file's content before and after the conversation's edits instead.
+func (s *Server) handleConversationChanges(w http.ResponseWriter, r *http.Request, conversationID string) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	messages, edits, err := s.loadFileEdits(r.Context(), conversationID)
+	if err != nil {
+		s.logger.Error("Failed to list file edits", "conversationID", conversationID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	paths, changes := netFileChanges(edits, turnStarts(messages))
+
+	if path := r.URL.Query().Get("path"); path != "" {
+		change, ok := changes[path]
+		if !ok {
+			http.Error(w, "File not changed in this conversation", http.StatusNotFound)
+			return
+		}
+		diff := GitFileDiff{
+			Path:       path,
+			OldContent: string(change.first.BeforeContent),
+			NewContent: string(change.last.AfterContent),
+		}
+		if !change.last.DeletedFile {
+			diff.NewSHA256 = contentHash(change.last.AfterContent)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(diff)
+		return
+	}
+
+	result := make([]ConversationFileChange, len(paths))
+	for i, path := range paths {
+		change := changes[path]
+		additions, deletions := change.lineCounts(path)
+		result[i] = ConversationFileChange{
+			Path:      path,
+			Status:    change.status(),
+			Additions: additions,
+			Deletions: deletions,
+			Sources:   change.sources,
+			Turns:     change.turns,
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// ConversationFile is a file a conversation's tools touched.
+type ConversationFile struct {
+	Path string `json:"path"`
+	// Status is the net change, as in ConversationFileChange, or unchanged
+	// if later edits undid the earlier ones.
+	Status        string    `json:"status"`
+	Edits         int       `json:"edits"`
+	Sources       []string  `json:"sources"`
+	Turns         []int64   `json:"turns"`
+	FirstEditedAt time.Time `json:"first_edited_at"`
+	LastEditedAt  time.Time `json:"last_edited_at"`
+	// DiffURL returns the file's content before and after the
+	// conversation, and ViewURL opens it in the UI's diff viewer. Both are
+	// empty for unchanged files.
+	DiffURL string `json:"diff_url,omitempty"`
+	ViewURL string `json:"view_url,omitempty"`
+}
+
+// handleConversationFiles handles GET /api/conversations/<id>/files, which
+// lists every file the conversation's tools created, modified or deleted,
+// in the order they were first edited. Edits that were reverted don't count.
+func (s *Server) handleConversationFiles(w http.ResponseWriter, r *http.Request, conversationID string) {
+	ctx := r.Context()
+	if _, err := s.db.GetConversationByID(ctx, conversationID); err != nil {
+		http.Error(w, "Conversation not found", http.StatusNotFound)
+		return
+	}
+	messages, edits, err := s.loadFileEdits(ctx, conversationID)
+	if err != nil {
+		s.logger.Error("Failed to list file edits", "conversationID", conversationID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	paths, changes := foldFileEdits(edits, turnStarts(messages))
+
+	result := make([]ConversationFile, len(paths))
+	for i, path := range paths {
+		change := changes[path]
+		file := ConversationFile{
+			Path:          path,
+			Status:        "unchanged",
+			Edits:         change.edits,
+			Sources:       change.sources,
+			Turns:         change.turns,
+			FirstEditedAt: change.first.CreatedAt,
+			LastEditedAt:  change.last.CreatedAt,
+		}
+		if !change.unchanged() {
+			file.Status = change.status()
+			file.DiffURL = "/api/conversation/" + conversationID + "/changes?" + url.Values{"path": {path}}.Encode()
+			file.ViewURL = "/c/" + conversationID + "?diff=conversation&" + url.Values{"file": {path}}.Encode()
+		}
+		result[i] = file
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
@@ -0,0 +1,152 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func (h *TestHarness) conversationChanges(query string) *httptest.ResponseRecorder {
+	h.t.Helper()
+	req := httptest.NewRequest("GET", "/api/conversation/"+h.convID+"/changes"+query, nil)
+	w := httptest.NewRecorder()
+	h.server.handleConversationChanges(w, req, h.convID)
+	return w
+}
+
+func TestBashFileChanges(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+
+	dir := t.TempDir()
+	if out, err := exec.Command("git", "init", dir).CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v: %s", err, out)
+	}
+	for name, content := range map[string]string{"a.txt": "one\n", "b.txt": "gone\n"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	h.NewConversation("bash: printf 'two\\n' >> a.txt && rm b.txt && echo new > c.txt && echo done", dir)
+	h.WaitToolResult()
+	h.WaitResponse()
+
+	edits := h.fileEdits()
+	if len(edits) != 3 {
+		t.Fatalf("expected 3 edits, got %d: %+v", len(edits), edits)
+	}
+	byPath := make(map[string]FileEditInfo)
+	for _, edit := range edits {
+		if edit.Source != "bash" || edit.ToolUseID == nil {
+			t.Errorf("unexpected edit: %+v", edit)
+		}
+		byPath[filepath.Base(edit.Path)] = edit
+	}
+	if !byPath["b.txt"].DeletedFile || !byPath["c.txt"].NewFile || byPath["a.txt"].NewFile || byPath["a.txt"].DeletedFile {
+		t.Errorf("unexpected edits: %+v", byPath)
+	}
+
+	w := h.conversationChanges("")
+	if w.Code != http.StatusOK {
+		t.Fatalf("changes: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var changes []ConversationFileChange
+	if err := json.Unmarshal(w.Body.Bytes(), &changes); err != nil {
+		t.Fatal(err)
+	}
+	statuses := make(map[string]string)
+	for _, c := range changes {
+		statuses[filepath.Base(c.Path)] = fmt.Sprintf("%s +%d -%d", c.Status, c.Additions, c.Deletions)
+	}
+	for name, want := range map[string]string{"a.txt": "modified +1 -0", "b.txt": "deleted +0 -1", "c.txt": "added +1 -0"} {
+		if statuses[name] != want {
+			t.Errorf("%s: got %q, want %q", name, statuses[name], want)
+		}
+	}
+
+	w = h.conversationChanges("?path=" + filepath.Join(dir, "a.txt"))
+	var fileDiff GitFileDiff
+	if err := json.Unmarshal(w.Body.Bytes(), &fileDiff); err != nil {
+		t.Fatalf("file diff: %v: %s", err, w.Body.String())
+	}
+	if fileDiff.OldContent != "one\n" || fileDiff.NewContent != "one\ntwo\n" {
+		t.Errorf("unexpected file diff: %+v", fileDiff)
+	}
+
+	// Reverting the turn undoes the command, deletion included, and leaves
+	// nothing changed
+	if w := h.revertEdits(fmt.Sprintf(`{"turn": %d}`, edits[0].Turn)); w.Code != http.StatusOK {
+		t.Fatalf("revert: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "b.txt")); string(data) != "gone\n" {
+		t.Errorf("b.txt not restored: %q", data)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "c.txt")); !os.IsNotExist(err) {
+		t.Errorf("expected c.txt to be removed, got %v", err)
+	}
+	if w := h.conversationChanges(""); w.Body.String() != "[]\n" {
+		t.Errorf("expected no changes after revert, got %s", w.Body.String())
+	}
+}
+
+func TestConversationFiles(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+
+	dir := t.TempDir()
+	if out, err := exec.Command("git", "init", dir).CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v: %s", err, out)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte("one\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	h.NewConversation("bash: printf 'two\\n' >> a.txt && echo tmp > tmp.txt && echo done", dir)
+	h.WaitToolResult()
+	h.WaitResponse()
+	h.Chat("bash: rm tmp.txt && echo done")
+	h.WaitToolResult()
+	h.WaitResponse()
+
+	w := httptest.NewRecorder()
+	h.server.handleConversationFiles(w, httptest.NewRequest("GET", "/api/conversations/"+h.convID+"/files", nil), h.convID)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var files []ConversationFile
+	if err := json.Unmarshal(w.Body.Bytes(), &files); err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("expected 2 files, got %+v", files)
+	}
+
+	// The file it changed links to its diff
+	a, tmp := files[0], files[1]
+	if filepath.Base(a.Path) != "a.txt" || a.Status != "modified" || a.Edits != 1 || a.DiffURL == "" || a.ViewURL == "" {
+		t.Errorf("unexpected a.txt: %+v", a)
+	}
+	w = httptest.NewRecorder()
+	h.server.handleConversationChanges(w, httptest.NewRequest("GET", a.DiffURL, nil), h.convID)
+	var fileDiff GitFileDiff
+	if err := json.Unmarshal(w.Body.Bytes(), &fileDiff); err != nil || fileDiff.NewContent != "one\ntwo\n" {
+		t.Errorf("expected a.txt's diff at %s, got %s", a.DiffURL, w.Body.String())
+	}
+
+	// The one it created and removed is still listed
+	if filepath.Base(tmp.Path) != "tmp.txt" || tmp.Status != "unchanged" || tmp.Edits != 2 || tmp.DiffURL != "" || len(tmp.Turns) != 2 {
+		t.Errorf("unexpected tmp.txt: %+v", tmp)
+	}
+
+	w = httptest.NewRecorder()
+	h.server.handleConversationFiles(w, httptest.NewRequest("GET", "/", nil), "missing")
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected a missing conversation not found, got %d", w.Code)
+	}
+}
@@ -0,0 +1,226 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// maxFileContentBytes is how much of a file's text a request returns.
+	maxFileContentBytes = 1 << 20
+	// binarySniffBytes is how much of a file is checked to tell binary
+	// files from text.
+	binarySniffBytes = 8000
+)
+
+// FileContentResponse is a file of a workspace, or a range of its lines.
+// Content is left out of binary files. StartLine and EndLine are the
+// 1-based, inclusive range of lines in Content, which is cut short, with
+// Truncated set, at maxFileContentBytes.
+type FileContentResponse struct {
+	Path       string    `json:"path"`
+	Language   string    `json:"language"`
+	Size       int64     `json:"size"`
+	ModTime    time.Time `json:"mod_time"`
+	Binary     bool      `json:"binary"`
+	Content    string    `json:"content,omitempty"`
+	StartLine  int       `json:"start_line,omitempty"`
+	EndLine    int       `json:"end_line,omitempty"`
+	TotalLines int       `json:"total_lines"`
+	Truncated  bool      `json:"truncated,omitempty"`
+}
+
+// handleFileContent handles GET /api/files/content?cwd=&path=&start=&end=,
+// returning the file path, relative to the workspace cwd, for the code
+// viewer. start and end select a 1-based, inclusive range of lines.
+func (s *Server) handleFileContent(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	query := r.URL.Query()
+	cwd, rel, err := workspacePath(query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if rel == "." {
+		http.Error(w, "path is required", http.StatusBadRequest)
+		return
+	}
+	var lines [2]int
+	for i, name := range []string{"start", "end"} {
+		if v := query.Get(name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 {
+				http.Error(w, name+" must be a positive line number", http.StatusBadRequest)
+				return
+			}
+			lines[i] = n
+		}
+	}
+	start, end := max(lines[0], 1), lines[1]
+	if end != 0 && end < start {
+		http.Error(w, "end must not be before start", http.StatusBadRequest)
+		return
+	}
+
+	name := filepath.Join(cwd, rel)
+	f, err := os.Open(name)
+	if err != nil {
+		s.workspaceError(w, "read file", name, err)
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		s.workspaceError(w, "read file", name, err)
+		return
+	}
+	if info.IsDir() {
+		http.Error(w, "path is a directory", http.StatusBadRequest)
+		return
+	}
+
+	resp := FileContentResponse{
+		Path:     filepath.ToSlash(rel),
+		Language: fileLanguage(rel),
+		Size:     info.Size(),
+		ModTime:  info.ModTime(),
+	}
+	br := bufio.NewReader(f)
+	head, _ := br.Peek(binarySniffBytes)
+	if isBinary(head) {
+		resp.Binary = true
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+		return
+	}
+
+	var content strings.Builder
+	for {
+		line, err := br.ReadString('\n')
+		if line != "" {
+			resp.TotalLines++
+			n := resp.TotalLines
+			if n >= start && (end == 0 || n <= end) && !resp.Truncated {
+				if content.Len()+len(line) > maxFileContentBytes {
+					resp.Truncated = true
+				} else {
+					content.WriteString(line)
+					if resp.StartLine == 0 {
+						resp.StartLine = n
+					}
+					resp.EndLine = n
+				}
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			s.workspaceError(w, "read file", name, err)
+			return
+		}
+	}
+	if start > 1 && start > resp.TotalLines {
+		http.Error(w, fmt.Sprintf("start is past the end of the file, which has %d lines", resp.TotalLines), http.StatusBadRequest)
+		return
+	}
+	resp.Content = content.String()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// isBinary reports whether a file starting with head looks binary rather
+// than UTF-8 text.
+func isBinary(head []byte) bool {
+	if bytes.IndexByte(head, 0) >= 0 {
+		return true
+	}
+	// head may end in the middle of a character
+	for i := 0; i < utf8.UTFMax-1 && len(head) > 0 && !utf8.Valid(head); i++ {
+		head = head[:len(head)-1]
+	}
+	return !utf8.Valid(head)
+}
+
+// fileLanguage returns the language of a file for syntax highlighting, as
+// a Monaco language ID, from its name. It is "plaintext" if not known.
+func fileLanguage(name string) string {
+	base := filepath.Base(name)
+	switch strings.ToLower(base) {
+	case "dockerfile", "containerfile":
+		return "dockerfile"
+	case "makefile", "gnumakefile":
+		return "makefile"
+	}
+	if strings.HasPrefix(base, "Dockerfile.") {
+		return "dockerfile"
+	}
+	if lang, ok := extensionLanguages[strings.ToLower(filepath.Ext(base))]; ok {
+		return lang
+	}
+	return "plaintext"
+}
+
+var extensionLanguages = map[string]string{
+	".bash":    "shell",
+	".c":       "c",
+	".cc":      "cpp",
+	".cpp":     "cpp",
+	".cs":      "csharp",
+	".css":     "css",
+	".cxx":     "cpp",
+	".dart":    "dart",
+	".ex":      "elixir",
+	".exs":     "elixir",
+	".go":      "go",
+	".graphql": "graphql",
+	".h":       "c",
+	".hpp":     "cpp",
+	".htm":     "html",
+	".html":    "html",
+	".ini":     "ini",
+	".java":    "java",
+	".js":      "javascript",
+	".json":    "json",
+	".jsx":     "javascript",
+	".kt":      "kotlin",
+	".less":    "less",
+	".lua":     "lua",
+	".m":       "objective-c",
+	".md":      "markdown",
+	".mjs":     "javascript",
+	".php":     "php",
+	".proto":   "proto",
+	".ps1":     "powershell",
+	".py":      "python",
+	".r":       "r",
+	".rb":      "ruby",
+	".rs":      "rust",
+	".scala":   "scala",
+	".scss":    "scss",
+	".sh":      "shell",
+	".sql":     "sql",
+	".swift":   "swift",
+	".tf":      "hcl",
+	".toml":    "ini",
+	".ts":      "typescript",
+	".tsx":     "typescript",
+	".xml":     "xml",
+	".yaml":    "yaml",
+	".yml":     "yaml",
+	".zsh":     "shell",
+}
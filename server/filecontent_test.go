@@ -0,0 +1,87 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileContent(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+	mux := http.NewServeMux()
+	h.server.RegisterRoutes(mux)
+
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"src/main.go": "package main\n\nfunc main() {\n}\n",
+		"Dockerfile":  "FROM scratch\n",
+		"logo.png":    "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR",
+		"notes":       "no newline at the end",
+	} {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0o755)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	get := func(query url.Values) (*httptest.ResponseRecorder, FileContentResponse) {
+		t.Helper()
+		query.Set("cwd", dir)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest("GET", "/api/files/content?"+query.Encode(), nil))
+		var resp FileContentResponse
+		if w.Code == http.StatusOK {
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return w, resp
+	}
+
+	w, resp := get(url.Values{"path": {"src/main.go"}})
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if resp.Language != "go" || resp.Binary || resp.TotalLines != 4 || resp.StartLine != 1 || resp.EndLine != 4 || resp.Size != 30 || resp.Content != "package main\n\nfunc main() {\n}\n" {
+		t.Errorf("unexpected response %+v", resp)
+	}
+
+	_, resp = get(url.Values{"path": {"src/main.go"}, "start": {"3"}, "end": {"10"}})
+	if resp.Content != "func main() {\n}\n" || resp.StartLine != 3 || resp.EndLine != 4 || resp.TotalLines != 4 {
+		t.Errorf("expected lines 3 to 4, got %+v", resp)
+	}
+
+	_, resp = get(url.Values{"path": {"Dockerfile"}})
+	if resp.Language != "dockerfile" {
+		t.Errorf("expected a Dockerfile, got %q", resp.Language)
+	}
+	_, resp = get(url.Values{"path": {"notes"}})
+	if resp.Language != "plaintext" || resp.TotalLines != 1 || resp.Content != "no newline at the end" {
+		t.Errorf("unexpected response %+v", resp)
+	}
+	_, resp = get(url.Values{"path": {"logo.png"}})
+	if !resp.Binary || resp.Content != "" || resp.Size != 16 {
+		t.Errorf("expected a binary file without content, got %+v", resp)
+	}
+
+	for _, tc := range []struct {
+		query url.Values
+		code  int
+	}{
+		{url.Values{"path": {"../secret"}}, http.StatusBadRequest},
+		{url.Values{"path": {"src"}}, http.StatusBadRequest},
+		{url.Values{"path": {"missing.go"}}, http.StatusNotFound},
+		{url.Values{"path": {"src/main.go"}, "start": {"5"}}, http.StatusBadRequest},
+		{url.Values{"path": {"src/main.go"}, "start": {"3"}, "end": {"2"}}, http.StatusBadRequest},
+	} {
+		if w, _ := get(tc.query); w.Code != tc.code {
+			t.Errorf("%v: expected %d, got %d: %s", tc.query, tc.code, w.Code, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,270 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pkg/diff"
+	"shelley.exe.dev/claudetool"
+	"shelley.exe.dev/db"
+	"shelley.exe.dev/db/generated"
+	"shelley.exe.dev/llm"
+)
+
+// errFileChangedSinceEdit is returned when reverting an edit to a file that
+// no longer has the content the edit left it with.
+var errFileChangedSinceEdit = errors.New("file has changed since the edit")
+
+// recordFileEdit returns the OnFileEdit hook for a conversation's tools.
+func (s *Server) recordFileEdit(conversationID string) func(ctx context.Context, edit claudetool.FileEdit) error {
+	return func(ctx context.Context, edit claudetool.FileEdit) error {
+		var toolUseID *string
+		if id := claudetool.ToolUseID(ctx); id != "" {
+			toolUseID = &id
+		}
+		s.invalidateGitDiffs(edit.Path)
+		_, err := s.db.CreateFileEdit(ctx, db.CreateFileEditParams{
+			ConversationID: &conversationID,
+			ToolUseID:      toolUseID,
+			Source:         db.FileEditSourcePatch,
+			Path:           edit.Path,
+			Before:         edit.Before,
+			After:          edit.After,
+		})
+		return err
+	}
+}
+
+// FileEditInfo describes a recorded file edit, with a diff in place of its content.
+type FileEditInfo struct {
+	EditID string `json:"edit_id"`
+	// Turn is the sequence ID of the user message that started the turn
+	// the edit was made in, or 0 if the edit was made before any.
+	Turn      int64   `json:"turn"`
+	ToolUseID *string `json:"tool_use_id,omitempty"`
+	Source    string  `json:"source"`
+	Path      string  `json:"path"`
+	NewFile   bool    `json:"new_file"`
+	// DeletedFile is set if the edit deleted the file.
+	DeletedFile bool       `json:"deleted_file"`
+	Diff        string     `json:"diff"`
+	RevertedAt  *time.Time `json:"reverted_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// RevertEditsRequest is the body of POST /api/conversation/<id>/edits/revert.
+// Exactly one of EditID and Turn must be set.
+type RevertEditsRequest struct {
+	EditID string `json:"edit_id,omitempty"`
+	Turn   int64  `json:"turn,omitempty"`
+}
+
+// turnStarts returns the sequence IDs of the messages typed by the user, in order.
+func turnStarts(messages []generated.Message) []int64 {
+	var starts []int64
+	for _, msg := range messages {
+		if msg.Type != string(db.MessageTypeUser) || msg.LlmData == nil {
+			continue
+		}
+		var llmMsg llm.Message
+		if err := json.Unmarshal([]byte(*msg.LlmData), &llmMsg); err != nil {
+			continue
+		}
+		if isUserText(llmMsg) {
+			starts = append(starts, msg.SequenceID)
+		}
+	}
+	return starts
+}
+
+// editTurn returns the turn an edit was made in, given the turn starts.
+func editTurn(edit generated.FileEdit, starts []int64) int64 {
+	var turn int64
+	if edit.SequenceID == nil {
+		return turn
+	}
+	for _, start := range starts {
+		if start > *edit.SequenceID {
+			break
+		}
+		turn = start
+	}
+	return turn
+}
+
+// loadFileEdits returns a conversation's messages and file edits.
+func (s *Server) loadFileEdits(ctx context.Context, conversationID string) ([]generated.Message, []generated.FileEdit, error) {
+	var messages []generated.Message
+	var edits []generated.FileEdit
+	err := s.db.Queries(ctx, func(q *generated.Queries) error {
+		var err error
+		if messages, err = q.ListMessages(ctx, conversationID); err != nil {
+			return err
+		}
+		edits, err = q.ListFileEdits(ctx, &conversationID)
+		return err
+	})
+	return messages, edits, err
+}
+
+// handleFileEdits handles GET /conversation/<id>/edits
+func (s *Server) handleFileEdits(w http.ResponseWriter, r *http.Request, conversationID string) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	messages, edits, err := s.loadFileEdits(r.Context(), conversationID)
+	if err != nil {
+		s.logger.Error("Failed to list file edits", "conversationID", conversationID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	starts := turnStarts(messages)
+	infos := make([]FileEditInfo, len(edits))
+	for i, edit := range edits {
+		var d strings.Builder
+		if err := diff.Text(edit.Path, edit.Path, edit.BeforeContent, edit.AfterContent, &d); err != nil {
+			fmt.Fprintf(&d, "(diff generation failed: %v)\n", err)
+		}
+		infos[i] = FileEditInfo{
+			EditID:      edit.EditID,
+			Turn:        editTurn(edit, starts),
+			ToolUseID:   edit.ToolUseID,
+			Source:      edit.Source,
+			Path:        edit.Path,
+			NewFile:     edit.NewFile,
+			DeletedFile: edit.DeletedFile,
+			Diff:        d.String(),
+			RevertedAt:  edit.RevertedAt,
+			CreatedAt:   edit.CreatedAt,
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(infos)
+}
+
+// handleRevertFileEdits handles POST /conversation/<id>/edits/revert
+func (s *Server) handleRevertFileEdits(w http.ResponseWriter, r *http.Request, conversationID string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req RevertEditsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if (req.EditID == "") == (req.Turn == 0) {
+		http.Error(w, "Exactly one of edit_id and turn is required", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	messages, edits, err := s.loadFileEdits(ctx, conversationID)
+	if err != nil {
+		s.logger.Error("Failed to list file edits", "conversationID", conversationID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if s.agentBusy(conversationID, messages) {
+		http.Error(w, "Agent is working; cancel or wait for the turn to end", http.StatusConflict)
+		return
+	}
+
+	starts := turnStarts(messages)
+	var selected []generated.FileEdit
+	for _, edit := range edits {
+		if req.EditID != "" && edit.EditID == req.EditID {
+			if edit.RevertedAt != nil {
+				http.Error(w, "Edit was already reverted", http.StatusConflict)
+				return
+			}
+			selected = append(selected, edit)
+		}
+		if req.Turn != 0 && edit.RevertedAt == nil && editTurn(edit, starts) == req.Turn {
+			selected = append(selected, edit)
+		}
+	}
+	if len(selected) == 0 {
+		http.Error(w, "No edits to revert", http.StatusNotFound)
+		return
+	}
+
+	if err := s.revertFileEdits(ctx, selected); err != nil {
+		if errors.Is(err, errFileChangedSinceEdit) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		s.logger.Error("Failed to revert file edits", "conversationID", conversationID, "error", err)
+		http.Error(w, "Failed to revert file edits", http.StatusInternalServerError)
+		return
+	}
+
+	reverted := make([]string, len(selected))
+	for i, edit := range selected {
+		reverted[i] = edit.EditID
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"status": "reverted", "reverted": reverted})
+}
+
+// revertFileEdits restores the files touched by edits, given in the order
+// they were made, to their content before the first of them. Nothing is
+// written unless every file still has the content the edits left it with.
+func (s *Server) revertFileEdits(ctx context.Context, edits []generated.FileEdit) error {
+	type fileState struct {
+		content []byte
+		exists  bool
+	}
+	states := make(map[string]*fileState)
+	for i := len(edits) - 1; i >= 0; i-- {
+		edit := edits[i]
+		state, ok := states[edit.Path]
+		if !ok {
+			content, err := os.ReadFile(edit.Path)
+			if err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return err
+			}
+			state = &fileState{content: content, exists: err == nil}
+			states[edit.Path] = state
+		}
+		if state.exists == edit.DeletedFile || !bytes.Equal(state.content, edit.AfterContent) {
+			return fmt.Errorf("%w: %s", errFileChangedSinceEdit, edit.Path)
+		}
+		state.content, state.exists = edit.BeforeContent, !edit.NewFile
+	}
+
+	for path, state := range states {
+		s.invalidateGitDiffs(path)
+		if !state.exists {
+			if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return fmt.Errorf("failed to remove %s: %w", path, err)
+			}
+			continue
+		}
+		if err := os.WriteFile(path, state.content, 0o644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", path, err)
+		}
+	}
+
+	return s.db.QueriesTx(ctx, func(q *generated.Queries) error {
+		for _, edit := range edits {
+			if err := q.MarkFileEditReverted(ctx, edit.EditID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,134 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func (h *TestHarness) fileEdits() []FileEditInfo {
+	h.t.Helper()
+	req := httptest.NewRequest("GET", "/api/conversation/"+h.convID+"/edits", nil)
+	w := httptest.NewRecorder()
+	h.server.handleFileEdits(w, req, h.convID)
+	if w.Code != http.StatusOK {
+		h.t.Fatalf("list edits: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var edits []FileEditInfo
+	if err := json.Unmarshal(w.Body.Bytes(), &edits); err != nil {
+		h.t.Fatal(err)
+	}
+	return edits
+}
+
+// revertEdits retries while the loop finishes the turn after its response is recorded.
+func (h *TestHarness) revertEdits(body string) *httptest.ResponseRecorder {
+	h.t.Helper()
+	deadline := time.Now().Add(h.timeout)
+	for {
+		req := httptest.NewRequest("POST", "/api/conversation/"+h.convID+"/edits/revert", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		h.server.handleRevertFileEdits(w, req, h.convID)
+		if w.Code != http.StatusConflict || !strings.Contains(w.Body.String(), "Agent is working") || time.Now().After(deadline) {
+			return w
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestRevertFileEdits(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "notes.txt")
+	if err := os.WriteFile(file, []byte("an example\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	read := func() string {
+		t.Helper()
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return "<missing>"
+		}
+		return string(data)
+	}
+
+	h.NewConversation("patch: "+file, dir)
+	h.WaitResponse()
+	h.Chat("patch: " + file)
+	h.WaitResponse()
+	if got := read(); got != "an updated updated example\n" {
+		t.Fatalf("unexpected file content after two patches: %q", got)
+	}
+
+	edits := h.fileEdits()
+	if len(edits) != 2 {
+		t.Fatalf("expected 2 edits, got %d", len(edits))
+	}
+	if edits[0].Source != "patch" || edits[0].ToolUseID == nil || edits[0].NewFile || !strings.Contains(edits[0].Diff, "+an updated example") {
+		t.Errorf("unexpected first edit: %+v", edits[0])
+	}
+	if edits[0].Turn == 0 || edits[1].Turn <= edits[0].Turn {
+		t.Errorf("expected edits in consecutive turns, got %d and %d", edits[0].Turn, edits[1].Turn)
+	}
+
+	// The first edit can't be undone while the second sits on top of it
+	if w := h.revertEdits(`{"edit_id": "` + edits[0].EditID + `"}`); w.Code != http.StatusConflict {
+		t.Errorf("revert of overwritten edit: expected 409, got %d: %s", w.Code, w.Body.String())
+	}
+	if got := read(); got != "an updated updated example\n" {
+		t.Errorf("failed revert changed the file: %q", got)
+	}
+
+	if w := h.revertEdits(fmt.Sprintf(`{"turn": %d}`, edits[1].Turn)); w.Code != http.StatusOK {
+		t.Fatalf("revert turn: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if got := read(); got != "an updated example\n" {
+		t.Errorf("unexpected file content after reverting the second turn: %q", got)
+	}
+	if w := h.revertEdits(`{"edit_id": "` + edits[0].EditID + `"}`); w.Code != http.StatusOK {
+		t.Fatalf("revert edit: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if got := read(); got != "an example\n" {
+		t.Errorf("unexpected file content after reverting both edits: %q", got)
+	}
+	if w := h.revertEdits(`{"edit_id": "` + edits[0].EditID + `"}`); w.Code != http.StatusConflict {
+		t.Errorf("second revert: expected 409, got %d", w.Code)
+	}
+	for _, edit := range h.fileEdits() {
+		if edit.RevertedAt == nil {
+			t.Errorf("expected edit %s to be marked reverted", edit.EditID)
+		}
+	}
+
+	// Edits from the diff viewer are recorded too, and undoing one that
+	// created a file removes it
+	if err := SaveSettings(t.Context(), h.db, Settings{WriteFile: &WriteFileSettings{AllowedRoots: []string{dir}}}, ""); err != nil {
+		t.Fatal(err)
+	}
+	created := filepath.Join(dir, "new.txt")
+	body, _ := json.Marshal(map[string]string{"path": created, "content": "hello\n", "conversation_id": h.convID})
+	w := httptest.NewRecorder()
+	h.server.handleWriteFile(w, httptest.NewRequest("POST", "/api/write-file", strings.NewReader(string(body))))
+	if w.Code != http.StatusOK {
+		t.Fatalf("write-file: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	edits = h.fileEdits()
+	last := edits[len(edits)-1]
+	if last.Source != "write_file" || !last.NewFile || last.Path != created {
+		t.Fatalf("unexpected write-file edit: %+v", last)
+	}
+	if w := h.revertEdits(`{"edit_id": "` + last.EditID + `"}`); w.Code != http.StatusOK {
+		t.Fatalf("revert write-file edit: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if _, err := os.Stat(created); !os.IsNotExist(err) {
+		t.Errorf("expected created file to be removed, stat error: %v", err)
+	}
+}
@@ -0,0 +1,213 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultFileTreeLimit = 200
+	maxFileTreeLimit     = 1000
+)
+
+// FileTreeEntry is a file or directory in a workspace listing.
+type FileTreeEntry struct {
+	Name      string    `json:"name"`
+	Path      string    `json:"path"` // relative to the workspace, slash-separated
+	IsDir     bool      `json:"is_dir"`
+	IsSymlink bool      `json:"is_symlink,omitempty"`
+	Size      int64     `json:"size"`
+	ModTime   time.Time `json:"mod_time"`
+}
+
+// FileTreeResponse is a page of a directory's entries, directories first.
+// NextOffset is the offset of the next page, or 0 if this is the last.
+type FileTreeResponse struct {
+	Cwd        string          `json:"cwd"`
+	Path       string          `json:"path"`
+	Entries    []FileTreeEntry `json:"entries"`
+	Total      int             `json:"total"`
+	NextOffset int             `json:"next_offset,omitempty"`
+}
+
+// handleFileTree handles GET /api/files/tree?cwd=&path=&offset=&limit=,
+// listing the directory path, relative to the workspace cwd, for the
+// workspace browser. Entries git ignores are left out, as is .git.
+func (s *Server) handleFileTree(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	query := r.URL.Query()
+	cwd, rel, err := workspacePath(query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	limit := defaultFileTreeLimit
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			http.Error(w, "limit must be a positive number", http.StatusBadRequest)
+			return
+		}
+		limit = min(n, maxFileTreeLimit)
+	}
+	offset := 0
+	if v := query.Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			http.Error(w, "offset must be a non-negative number", http.StatusBadRequest)
+			return
+		}
+		offset = n
+	}
+
+	dir := filepath.Join(cwd, rel)
+	entries, err := listFileTree(dir)
+	if err != nil {
+		s.workspaceError(w, "list directory", dir, err)
+		return
+	}
+
+	resp := FileTreeResponse{
+		Cwd:     cwd,
+		Path:    filepath.ToSlash(rel),
+		Entries: []FileTreeEntry{},
+		Total:   len(entries),
+	}
+	if resp.Path == "." {
+		resp.Path = ""
+	}
+	if offset < len(entries) {
+		end := min(offset+limit, len(entries))
+		for _, entry := range entries[offset:end] {
+			entry.Path = filepath.ToSlash(filepath.Join(rel, entry.Name))
+			resp.Entries = append(resp.Entries, entry)
+		}
+		if end < len(entries) {
+			resp.NextOffset = end
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// workspacePath returns the workspace and the path relative to it named by
+// the cwd and path parameters of a files request. The path may not leave
+// the workspace.
+func workspacePath(query url.Values) (cwd, rel string, err error) {
+	cwd = query.Get("cwd")
+	if !filepath.IsAbs(cwd) {
+		return "", "", badRequestf("cwd must be an absolute path")
+	}
+	rel = filepath.Clean(filepath.FromSlash(query.Get("path")))
+	if filepath.IsAbs(rel) || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", "", badRequestf("path must be relative to cwd and inside it")
+	}
+	return filepath.Clean(cwd), rel, nil
+}
+
+// workspaceError responds to a failure to op the file name of a workspace.
+func (s *Server) workspaceError(w http.ResponseWriter, op, name string, err error) {
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		http.Error(w, "no such file or directory", http.StatusNotFound)
+	case errors.Is(err, fs.ErrPermission):
+		http.Error(w, "permission denied", http.StatusForbidden)
+	default:
+		s.logger.Error("failed to "+op, "path", name, "error", err)
+		http.Error(w, "failed to "+op, http.StatusInternalServerError)
+	}
+}
+
+// listFileTree returns the entries of dir that git doesn't ignore, other
+// than .git, directories first and then by name.
+func listFileTree(dir string) ([]FileTreeEntry, error) {
+	dirEntries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, entry := range dirEntries {
+		if entry.Name() != ".git" {
+			names = append(names, entry.Name())
+		}
+	}
+	ignored := gitIgnored(dir, names)
+
+	var entries []FileTreeEntry
+	for _, entry := range dirEntries {
+		if entry.Name() == ".git" || ignored[entry.Name()] {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue // Removed since it was read
+		}
+		e := FileTreeEntry{
+			Name:      entry.Name(),
+			IsDir:     entry.IsDir(),
+			IsSymlink: entry.Type()&fs.ModeSymlink != 0,
+			Size:      info.Size(),
+			ModTime:   info.ModTime(),
+		}
+		// A link to a directory can be browsed like one
+		if e.IsSymlink {
+			if target, err := os.Stat(filepath.Join(dir, entry.Name())); err == nil {
+				e.IsDir = target.IsDir()
+			}
+		}
+		if e.IsDir {
+			e.Size = 0
+		}
+		entries = append(entries, e)
+	}
+	slices.SortFunc(entries, func(a, b FileTreeEntry) int {
+		if a.IsDir != b.IsDir {
+			if a.IsDir {
+				return -1
+			}
+			return 1
+		}
+		return strings.Compare(a.Name, b.Name)
+	})
+	return entries, nil
+}
+
+// gitIgnored returns which of names, in dir, git ignores. Outside a git
+// repository, none are.
+func gitIgnored(dir string, names []string) map[string]bool {
+	ignored := make(map[string]bool)
+	if len(names) == 0 {
+		return ignored
+	}
+	var stdin bytes.Buffer
+	for _, name := range names {
+		stdin.WriteString(name)
+		stdin.WriteByte(0)
+	}
+	cmd := exec.Command("git", "check-ignore", "-z", "--stdin")
+	cmd.Dir = dir
+	cmd.Stdin = &stdin
+	// It exits with 1 if nothing is ignored, and 128 outside a repository
+	out, _ := cmd.Output()
+	for _, name := range bytes.Split(out, []byte{0}) {
+		if len(name) > 0 {
+			ignored[string(name)] = true
+		}
+	}
+	return ignored
+}
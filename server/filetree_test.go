@@ -0,0 +1,99 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestFileTree(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+	mux := http.NewServeMux()
+	h.server.RegisterRoutes(mux)
+
+	dir := t.TempDir()
+	if out, err := exec.Command("git", "init", dir).CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v\n%s", err, out)
+	}
+	for name, content := range map[string]string{
+		".gitignore":        "node_modules/\n*.log\n",
+		"b.go":              "package b\n",
+		"a.go":              "package a\n",
+		"debug.log":         "noise",
+		"src/main.go":       "package main\n",
+		"src/gen.log":       "noise",
+		"node_modules/x.js": "",
+	} {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0o755)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	get := func(query url.Values) (*httptest.ResponseRecorder, FileTreeResponse) {
+		t.Helper()
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest("GET", "/api/files/tree?"+query.Encode(), nil))
+		var resp FileTreeResponse
+		if w.Code == http.StatusOK {
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return w, resp
+	}
+	names := func(resp FileTreeResponse) []string {
+		var names []string
+		for _, e := range resp.Entries {
+			names = append(names, e.Path)
+		}
+		return names
+	}
+
+	w, resp := get(url.Values{"cwd": {dir}})
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if got, want := names(resp), []string{"src", ".gitignore", "a.go", "b.go"}; !slices.Equal(got, want) {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+	if resp.Entries[0].IsDir != true || resp.Entries[2].Size != int64(len("package a\n")) || resp.NextOffset != 0 {
+		t.Errorf("unexpected entries %+v", resp)
+	}
+
+	_, resp = get(url.Values{"cwd": {dir}, "path": {"src"}})
+	if got := names(resp); !slices.Equal(got, []string{"src/main.go"}) {
+		t.Errorf("expected the ignored log to be left out of src, got %q", got)
+	}
+
+	_, resp = get(url.Values{"cwd": {dir}, "limit": {"3"}})
+	if resp.Total != 4 || len(resp.Entries) != 3 || resp.NextOffset != 3 {
+		t.Errorf("expected the first page, got %+v", resp)
+	}
+	_, resp = get(url.Values{"cwd": {dir}, "limit": {"3"}, "offset": {"3"}})
+	if got := names(resp); !slices.Equal(got, []string{"b.go"}) || resp.NextOffset != 0 {
+		t.Errorf("expected the last page, got %+v", resp)
+	}
+
+	for _, tc := range []struct {
+		query url.Values
+		code  int
+	}{
+		{url.Values{"cwd": {"relative"}}, http.StatusBadRequest},
+		{url.Values{"cwd": {dir}, "path": {"../.."}}, http.StatusBadRequest},
+		{url.Values{"cwd": {dir}, "path": {"missing"}}, http.StatusNotFound},
+		{url.Values{"cwd": {dir}, "limit": {"0"}}, http.StatusBadRequest},
+	} {
+		if w, _ := get(tc.query); w.Code != tc.code {
+			t.Errorf("%v: expected %d, got %d: %s", tc.query, tc.code, w.Code, w.Body.String())
+		}
+	}
+}
@@ -37,6 +37,9 @@ type GitFileDiff struct {
 	Path       string `json:"path"`
 	OldContent string `json:"oldContent"`
 	NewContent string `json:"newContent"`
+	// NewSHA256 is the hash of NewContent, for saving changes to it, or
+	// empty if the file doesn't exist.
+	NewSHA256 string `json:"newSha256,omitempty"`
 }
 
 // getGitRoot returns the git repository root for the given directory
@@ -73,6 +76,90 @@ func parseDiffStat(output string) (additions, deletions, filesCount int) {
 	return additions, deletions, filesCount
 }
 
+// diffStat is what a diff changed.
+type diffStat struct {
+	additions, deletions, files int
+}
+
+// gitDiffStat returns what git diff of revs changes.
+func gitDiffStat(gitRoot string, revs ...string) diffStat {
+	cmd := exec.Command("git", append(append([]string{"diff"}, revs...), "--numstat")...)
+	cmd.Dir = gitRoot
+	output, _ := cmd.Output()
+	additions, deletions, files := parseDiffStat(string(output))
+	return diffStat{additions, deletions, files}
+}
+
+// gitLog returns the latest commits from head, without what they changed.
+func gitLog(gitRoot, head string) ([]GitDiffInfo, error) {
+	cmd := exec.Command("git", "log", "--oneline", "-20", "--pretty=format:%H%x00%s%x00%an%x00%at", head)
+	cmd.Dir = gitRoot
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+	var commits []GitDiffInfo
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		parts := strings.Split(line, "\x00")
+		if len(parts) < 4 {
+			continue
+		}
+		timestamp, _ := strconv.ParseInt(parts[3], 10, 64)
+		commits = append(commits, GitDiffInfo{
+			ID:        parts[0],
+			Message:   parts[1],
+			Author:    parts[2],
+			Timestamp: time.Unix(timestamp, 0),
+		})
+	}
+	return commits, nil
+}
+
+// gitDiffFiles returns the files that differ between base and the working
+// tree, with their statuses and line counts from a single git diff.
+func gitDiffFiles(gitRoot, base string) ([]GitFileInfo, error) {
+	cmd := exec.Command("git", "diff", "--no-renames", "-z", "--raw", "--numstat", base)
+	cmd.Dir = gitRoot
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+
+	// The raw records, ":<modes and hashes> <status>" then the path, come
+	// before the numstat records, "<additions>\t<deletions>\t<path>"
+	var files []GitFileInfo
+	index := make(map[string]int)
+	fields := strings.Split(strings.TrimSuffix(string(output), "\x00"), "\x00")
+	for i := 0; i < len(fields); i++ {
+		if strings.HasPrefix(fields[i], ":") && i+1 < len(fields) {
+			status := "modified"
+			switch fields[i][strings.LastIndexByte(fields[i], ' ')+1:] {
+			case "A":
+				status = "added"
+			case "D":
+				status = "deleted"
+			}
+			i++
+			index[fields[i]] = len(files)
+			files = append(files, GitFileInfo{Path: fields[i], Status: status})
+			continue
+		}
+		parts := strings.SplitN(fields[i], "\t", 3)
+		if len(parts) < 3 {
+			continue
+		}
+		if j, ok := index[parts[2]]; ok {
+			// Binary files have "-" for both
+			files[j].Additions, _ = strconv.Atoi(parts[0])
+			files[j].Deletions, _ = strconv.Atoi(parts[1])
+		}
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Path < files[j].Path
+	})
+	return files, nil
+}
+
 // handleGitDiffs returns available diffs (working changes + recent commits)
 func (s *Server) handleGitDiffs(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
@@ -99,56 +186,38 @@ func (s *Server) handleGitDiffs(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Working changes, then recent commits. What a commit changed is cached
+	// by its hash, and the working changes and commit list by the revision.
 	var diffs []GitDiffInfo
-
-	// Working changes
-	workingStatCmd := exec.Command("git", "diff", "HEAD", "--numstat")
-	workingStatCmd.Dir = gitRoot
-	workingStatOutput, _ := workingStatCmd.Output()
-	workingAdditions, workingDeletions, workingFilesCount := parseDiffStat(string(workingStatOutput))
-
+	rev, revErr := getGitRevision(gitRoot)
+	var working diffStat
+	if revErr == nil {
+		working, _ = cachedGitDiff(s.gitDiffs, s.workingKey("stat", gitRoot, rev), workingDiffTTL, fixedSize, func() (diffStat, error) {
+			return gitDiffStat(gitRoot, rev.head), nil
+		})
+	} else {
+		working = gitDiffStat(gitRoot, "HEAD")
+	}
 	diffs = append(diffs, GitDiffInfo{
 		ID:         "working",
 		Message:    "Working Changes",
 		Author:     "",
 		Timestamp:  time.Now(),
-		FilesCount: workingFilesCount,
-		Additions:  workingAdditions,
-		Deletions:  workingDeletions,
+		FilesCount: working.files,
+		Additions:  working.additions,
+		Deletions:  working.deletions,
 	})
 
-	// Get commits
-	cmd := exec.Command("git", "log", "--oneline", "-20", "--pretty=format:%H%x00%s%x00%an%x00%at")
-	cmd.Dir = gitRoot
-	output, err := cmd.Output()
-	if err == nil {
-		lines := strings.Split(strings.TrimSpace(string(output)), "\n")
-		for _, line := range lines {
-			if line == "" {
-				continue
-			}
-			parts := strings.Split(line, "\x00")
-			if len(parts) < 4 {
-				continue
-			}
-
-			timestamp, _ := strconv.ParseInt(parts[3], 10, 64)
-
-			// Get diffstat
-			statCmd := exec.Command("git", "diff", parts[0]+"^", parts[0], "--numstat")
-			statCmd.Dir = gitRoot
-			statOutput, _ := statCmd.Output()
-			additions, deletions, filesCount := parseDiffStat(string(statOutput))
-
-			diffs = append(diffs, GitDiffInfo{
-				ID:         parts[0],
-				Message:    parts[1],
-				Author:     parts[2],
-				Timestamp:  time.Unix(timestamp, 0),
-				FilesCount: filesCount,
-				Additions:  additions,
-				Deletions:  deletions,
+	if revErr == nil {
+		commits, _ := cachedGitDiff(s.gitDiffs, commitKey("log", gitRoot, rev.head), 0, gitDiffInfosSize, func() ([]GitDiffInfo, error) {
+			return gitLog(gitRoot, rev.head)
+		})
+		for _, commit := range commits {
+			stat, _ := cachedGitDiff(s.gitDiffs, commitKey("stat", gitRoot, commit.ID), 0, fixedSize, func() (diffStat, error) {
+				return gitDiffStat(gitRoot, commit.ID+"^", commit.ID), nil
 			})
+			commit.FilesCount, commit.Additions, commit.Deletions = stat.files, stat.additions, stat.deletions
+			diffs = append(diffs, commit)
 		}
 	}
 
@@ -187,71 +256,24 @@ func (s *Server) handleGitDiffFiles(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var cmd *exec.Cmd
-	var statBaseArg string
-
-	if diffID == "working" {
-		cmd = exec.Command("git", "diff", "--name-status", "HEAD")
-		statBaseArg = "HEAD"
+	// Files are listed against the working tree, so they're cached like the
+	// working changes
+	base := "HEAD"
+	if diffID != "working" {
+		base = diffID + "^"
+	}
+	compute := func() ([]GitFileInfo, error) { return gitDiffFiles(gitRoot, base) }
+	var files []GitFileInfo
+	if rev, revErr := getGitRevision(gitRoot); revErr == nil {
+		files, err = cachedGitDiff(s.gitDiffs, s.workingKey("files\x00"+diffID, gitRoot, rev), workingDiffTTL, gitFileInfosSize, compute)
 	} else {
-		cmd = exec.Command("git", "diff", "--name-status", diffID+"^")
-		statBaseArg = diffID + "^"
+		files, err = compute()
 	}
-	cmd.Dir = gitRoot
-
-	output, err := cmd.Output()
 	if err != nil {
 		http.Error(w, "failed to get diff files", http.StatusInternalServerError)
 		return
 	}
 
-	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
-	var files []GitFileInfo
-
-	for _, line := range lines {
-		if line == "" {
-			continue
-		}
-		parts := strings.Fields(line)
-		if len(parts) < 2 {
-			continue
-		}
-
-		status := "modified"
-		switch parts[0] {
-		case "A":
-			status = "added"
-		case "D":
-			status = "deleted"
-		case "M":
-			status = "modified"
-		}
-
-		// Get additions/deletions for this file
-		statCmd := exec.Command("git", "diff", statBaseArg, "--numstat", "--", parts[1])
-		statCmd.Dir = gitRoot
-		statOutput, _ := statCmd.Output()
-		additions, deletions := 0, 0
-		if statOutput != nil {
-			statParts := strings.Fields(string(statOutput))
-			if len(statParts) >= 2 {
-				additions, _ = strconv.Atoi(statParts[0])
-				deletions, _ = strconv.Atoi(statParts[1])
-			}
-		}
-
-		files = append(files, GitFileInfo{
-			Path:      parts[1],
-			Status:    status,
-			Additions: additions,
-			Deletions: deletions,
-		})
-	}
-
-	sort.Slice(files, func(i, j int) bool {
-		return files[i].Path < files[j].Path
-	})
-
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(files)
 }
@@ -297,23 +319,29 @@ func (s *Server) handleGitFileDiff(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var oldCmd *exec.Cmd
+	// The old content is at a commit, so it's cached by its hash
+	oldRev := diffID + "^"
 	if diffID == "working" {
-		oldCmd = exec.Command("git", "show", "HEAD:"+filePath)
-	} else {
-		oldCmd = exec.Command("git", "show", diffID+"^:"+filePath)
+		oldRev = "HEAD"
+		if rev, err := getGitRevision(gitRoot); err == nil {
+			oldRev = rev.head
+		}
 	}
-	oldCmd.Dir = gitRoot
-
-	oldOutput, _ := oldCmd.Output()
-	oldContent := string(oldOutput)
+	oldContent, _ := cachedGitDiff(s.gitDiffs, commitKey("show", gitRoot, strings.TrimSuffix(oldRev, "^"), oldRev, filePath), 0, stringSize, func() (string, error) {
+		oldCmd := exec.Command("git", "show", oldRev+":"+filePath)
+		oldCmd.Dir = gitRoot
+		oldOutput, _ := oldCmd.Output()
+		return string(oldOutput), nil
+	})
 
 	// Get new version from working tree
 	newContent := ""
+	newSHA256 := ""
 	fullPath := filepath.Join(gitRoot, cleanPath)
 	if file, err := os.Open(fullPath); err == nil {
 		if fileData, err := io.ReadAll(file); err == nil {
 			newContent = string(fileData)
+			newSHA256 = contentHash(fileData)
 		}
 		file.Close()
 	}
@@ -322,6 +350,7 @@ func (s *Server) handleGitFileDiff(w http.ResponseWriter, r *http.Request) {
 		Path:       filePath,
 		OldContent: oldContent,
 		NewContent: newContent,
+		NewSHA256:  newSHA256,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
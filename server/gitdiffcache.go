@@ -0,0 +1,213 @@
+package server
+
+import (
+	"container/list"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"tailscale.com/util/singleflight"
+)
+
+// The git diff handlers run git, which takes seconds on large repositories,
+// so gitDiffCache keeps what they compute. What a commit changed never
+// changes, so it's kept by commit hash. Working changes are kept by HEAD,
+// the mtime of the index and a generation of the repository that
+// invalidateGitDiffs bumps when the conversations' tools change its files or
+// git state; they expire after workingDiffTTL for changes made elsewhere.
+
+// workingDiffTTL is how long working changes are cached.
+const workingDiffTTL = 10 * time.Second
+
+// maxGitDiffCacheSize is roughly how many bytes the cache holds.
+const maxGitDiffCacheSize = 64 << 20
+
+// commitHashPattern matches full commit hashes, which name the same commit
+// forever, unlike branches.
+var commitHashPattern = regexp.MustCompile(`^([0-9a-f]{40}|[0-9a-f]{64})$`)
+
+// gitDiffCache is a least recently used cache of git diff results.
+type gitDiffCache struct {
+	group singleflight.Group[string, any]
+
+	mu          sync.Mutex
+	entries     map[string]*list.Element // of *gitDiffCacheEntry
+	order       *list.List               // most recently used first
+	size        int
+	generations map[string]int // of repositories, by git root
+}
+
+type gitDiffCacheEntry struct {
+	key     string
+	value   any
+	size    int
+	expires time.Time // zero if it never does
+}
+
+func newGitDiffCache() *gitDiffCache {
+	return &gitDiffCache{
+		entries:     make(map[string]*list.Element),
+		order:       list.New(),
+		generations: make(map[string]int),
+	}
+}
+
+// cachedGitDiff returns the value cached under key, or computes and caches
+// it. Computations of the same key run once at a time, and an empty key
+// isn't cached. size estimates the value's size in bytes; ttl is zero for
+// values that don't expire.
+func cachedGitDiff[T any](c *gitDiffCache, key string, ttl time.Duration, size func(T) int, compute func() (T, error)) (T, error) {
+	if key == "" {
+		return compute()
+	}
+	if value, ok := c.get(key); ok {
+		return value.(T), nil
+	}
+	value, err, _ := c.group.Do(key, func() (any, error) {
+		value, err := compute()
+		if err != nil {
+			return value, err
+		}
+		c.put(key, value, size(value), ttl)
+		return value, nil
+	})
+	return value.(T), err
+}
+
+func (c *gitDiffCache) get(key string) (any, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(*gitDiffCacheEntry)
+	if !entry.expires.IsZero() && time.Now().After(entry.expires) {
+		c.remove(elem)
+		return nil, false
+	}
+	c.order.MoveToFront(elem)
+	return entry.value, true
+}
+
+func (c *gitDiffCache) put(key string, value any, size int, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[key]; ok {
+		c.remove(elem)
+	}
+	entry := &gitDiffCacheEntry{key: key, value: value, size: size + len(key)}
+	if ttl > 0 {
+		entry.expires = time.Now().Add(ttl)
+	}
+	c.entries[key] = c.order.PushFront(entry)
+	c.size += entry.size
+	for c.size > maxGitDiffCacheSize && c.order.Len() > 1 {
+		c.remove(c.order.Back())
+	}
+}
+
+// remove drops an entry; c.mu must be held.
+func (c *gitDiffCache) remove(elem *list.Element) {
+	entry := c.order.Remove(elem).(*gitDiffCacheEntry)
+	delete(c.entries, entry.key)
+	c.size -= entry.size
+}
+
+// generation returns the repository's generation, to key its working
+// changes by.
+func (c *gitDiffCache) generation(gitRoot string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.generations[gitRoot]; !ok {
+		c.generations[gitRoot] = 0
+	}
+	return c.generations[gitRoot]
+}
+
+// invalidate drops the cached working changes of the repositories path is
+// in, or that are in path.
+func (c *gitDiffCache) invalidate(path string) {
+	path = filepath.Clean(path)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for root := range c.generations {
+		if withinRoot(path, root) || withinRoot(root, path) {
+			c.generations[root]++
+		}
+	}
+}
+
+// invalidateGitDiffs drops the cached working changes of the repository
+// containing path, which changed.
+func (s *Server) invalidateGitDiffs(path string) {
+	s.gitDiffs.invalidate(path)
+}
+
+// gitRevision identifies the state of a repository's working changes: the
+// commit HEAD is at and when the index last changed. It fails if HEAD
+// isn't at a commit yet.
+type gitRevision struct {
+	head  string
+	index time.Time
+}
+
+func getGitRevision(gitRoot string) (gitRevision, error) {
+	cmd := exec.Command("git", "rev-parse", "--absolute-git-dir", "--verify", "HEAD")
+	cmd.Dir = gitRoot
+	output, err := cmd.Output()
+	if err != nil {
+		return gitRevision{}, err
+	}
+	lines := strings.Fields(string(output))
+	if len(lines) != 2 {
+		return gitRevision{}, fmt.Errorf("unexpected git rev-parse output %q", output)
+	}
+	rev := gitRevision{head: lines[1]}
+	if info, err := os.Stat(filepath.Join(lines[0], "index")); err == nil {
+		rev.index = info.ModTime()
+	}
+	return rev, nil
+}
+
+// workingKey returns the cache key of a kind of working changes of the
+// repository at rev.
+func (s *Server) workingKey(kind, gitRoot string, rev gitRevision) string {
+	return fmt.Sprintf("%s\x00%s\x00%s\x00%d\x00%d", kind, gitRoot, rev.head, rev.index.UnixNano(), s.gitDiffs.generation(gitRoot))
+}
+
+// commitKey returns the cache key of a kind of result about a commit, or
+// "" if diffID isn't a commit hash and so can't be cached.
+func commitKey(kind, gitRoot, diffID string, more ...string) string {
+	if !commitHashPattern.MatchString(diffID) {
+		return ""
+	}
+	return strings.Join(append([]string{kind, gitRoot, diffID}, more...), "\x00")
+}
+
+// Size estimates of cached values, in bytes.
+
+func fixedSize[T any](T) int { return 64 }
+
+func stringSize(s string) int { return len(s) }
+
+func gitDiffInfosSize(diffs []GitDiffInfo) int {
+	size := 0
+	for _, d := range diffs {
+		size += 128 + len(d.ID) + len(d.Message) + len(d.Author)
+	}
+	return size
+}
+
+func gitFileInfosSize(files []GitFileInfo) int {
+	size := 0
+	for _, f := range files {
+		size += 64 + len(f.Path)
+	}
+	return size
+}
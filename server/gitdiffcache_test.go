@@ -0,0 +1,120 @@
+package server
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"shelley.exe.dev/claudetool"
+	"shelley.exe.dev/loop"
+)
+
+func TestGitDiffCache(t *testing.T) {
+	database, cleanup := setupTestDB(t)
+	defer cleanup()
+	server := NewServer(database, &testLLMManager{service: loop.NewPredictableService()}, claudetool.ToolSetConfig{}, slog.Default(), true, "", "predictable", "", nil)
+
+	dir := t.TempDir()
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v: %s", args, err, out)
+		}
+	}
+	write := func(name, content string) string {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	git("init")
+	write("a.txt", "one\n")
+	git("add", ".")
+	git("commit", "-m", "first")
+	write("a.txt", "one\ntwo\n")
+	write("b c.txt", "new\n")
+	git("add", ".")
+	git("commit", "-m", "second")
+	gitRoot, err := getGitRoot(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	diffs := func() []GitDiffInfo {
+		t.Helper()
+		w := httptest.NewRecorder()
+		server.handleGitDiffs(w, httptest.NewRequest("GET", "/api/git/diffs?cwd="+url.QueryEscape(dir), nil))
+		var response struct{ Diffs []GitDiffInfo }
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatalf("failed to decode %s", w.Body.String())
+		}
+		return response.Diffs
+	}
+	files := func(diffID string) []GitFileInfo {
+		t.Helper()
+		w := httptest.NewRecorder()
+		server.handleGitDiffFiles(w, httptest.NewRequest("GET", "/api/git/diffs/"+diffID+"/files?cwd="+url.QueryEscape(dir), nil))
+		var files []GitFileInfo
+		if err := json.Unmarshal(w.Body.Bytes(), &files); err != nil {
+			t.Fatalf("failed to decode %s", w.Body.String())
+		}
+		return files
+	}
+
+	got := diffs()
+	if len(got) != 3 || got[1].Message != "second" || got[1].FilesCount != 2 || got[1].Additions != 2 || got[0].FilesCount != 0 {
+		t.Fatalf("unexpected diffs: %+v", got)
+	}
+	second := got[1].ID
+	if got := files(second); len(got) != 2 || got[0].Path != "a.txt" || got[0].Additions != 1 || got[1].Path != "b c.txt" || got[1].Status != "added" || got[1].Additions != 1 {
+		t.Errorf("unexpected files of the second commit: %+v", got)
+	}
+	if got := files("working"); len(got) != 0 {
+		t.Errorf("expected no working files, got %+v", got)
+	}
+
+	// Working changes are cached
+	path := write("a.txt", "one\ntwo\nthree\n")
+	if got := diffs(); got[0].FilesCount != 0 {
+		t.Errorf("expected the working changes cached, got %+v", got[0])
+	}
+	if got := files("working"); len(got) != 0 {
+		t.Errorf("expected the working files cached, got %+v", got)
+	}
+
+	// Until the tools change the repository
+	server.invalidateGitDiffs(path)
+	if got := diffs(); got[0].FilesCount != 1 || got[0].Additions != 1 || got[1].FilesCount != 2 {
+		t.Errorf("expected the new working changes, got %+v", got)
+	}
+	if got := files("working"); len(got) != 1 || got[0].Path != "a.txt" || got[0].Additions != 1 {
+		t.Errorf("expected the new working files, got %+v", got)
+	}
+
+	// Or the index changes
+	write("c.txt", "staged\n")
+	git("add", "c.txt")
+	if got := files("working"); len(got) != 2 {
+		t.Errorf("expected the staged file listed, got %+v", got)
+	}
+
+	// File contents at commits are cached by hash
+	w := httptest.NewRecorder()
+	server.handleGitFileDiff(w, httptest.NewRequest("GET", "/api/git/file-diff/"+second+"/a.txt?cwd="+url.QueryEscape(dir), nil))
+	var fileDiff GitFileDiff
+	if err := json.Unmarshal(w.Body.Bytes(), &fileDiff); err != nil || fileDiff.OldContent != "one\n" || fileDiff.NewContent != "one\ntwo\nthree\n" {
+		t.Errorf("unexpected file diff: %s", w.Body.String())
+	}
+	if _, ok := server.gitDiffs.get(commitKey("show", gitRoot, second, second+"^", "a.txt")); !ok {
+		t.Error("expected the old content cached")
+	}
+}
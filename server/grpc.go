@@ -0,0 +1,447 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"shelley.exe.dev/db/generated"
+	"shelley.exe.dev/grpcapi"
+	"shelley.exe.dev/llm"
+)
+
+// grpcUserKey is the context key of the user making a gRPC call.
+type grpcUserKey struct{}
+
+// grpcService implements the gRPC API with the same logic as the HTTP
+// handlers.
+type grpcService struct {
+	grpcapi.UnimplementedShelleyServer
+	s *Server
+}
+
+// SetGRPCAddr makes Start serve the gRPC API on addr as well as the HTTP
+// API.
+func (s *Server) SetGRPCAddr(addr string) {
+	s.grpcAddr = addr
+}
+
+// NewGRPCServer returns a gRPC server for the gRPC API (see package
+// grpcapi). As with the HTTP API, if the server requires a header, calls
+// must carry it as metadata, and its value identifies the user.
+func (s *Server) NewGRPCServer() *grpc.Server {
+	server := grpc.NewServer(
+		grpc.UnaryInterceptor(func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+			ctx, err := s.grpcAuth(ctx)
+			if err != nil {
+				return nil, err
+			}
+			return handler(ctx, req)
+		}),
+		grpc.StreamInterceptor(func(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			ctx, err := s.grpcAuth(stream.Context())
+			if err != nil {
+				return err
+			}
+			return handler(srv, &grpcAuthStream{ServerStream: stream, ctx: ctx})
+		}),
+	)
+	grpcapi.RegisterShelleyServer(server, &grpcService{s: s})
+	return server
+}
+
+// grpcAuth checks a call for the required header and records its user in
+// the context.
+func (s *Server) grpcAuth(ctx context.Context) (context.Context, error) {
+	if s.requireHeader == "" {
+		return ctx, nil
+	}
+	values := metadata.ValueFromIncomingContext(ctx, strings.ToLower(s.requireHeader))
+	if len(values) == 0 || values[0] == "" {
+		return nil, status.Error(codes.PermissionDenied, "missing required header: "+s.requireHeader)
+	}
+	return context.WithValue(ctx, grpcUserKey{}, values[0]), nil
+}
+
+// grpcAuthStream is a stream whose context carries the user.
+type grpcAuthStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *grpcAuthStream) Context() context.Context { return s.ctx }
+
+// grpcUserID returns the user making a gRPC call, as Server.userID does for
+// HTTP requests.
+func grpcUserID(ctx context.Context) string {
+	userID, _ := ctx.Value(grpcUserKey{}).(string)
+	return userID
+}
+
+// grpcError converts an error from the server's logic to a gRPC status.
+func grpcError(err error) error {
+	var badRequest *badRequestError
+	switch {
+	case errors.As(err, &badRequest), errors.Is(err, errConversationModelMismatch):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, sql.ErrNoRows):
+		return status.Error(codes.NotFound, "conversation not found")
+	case errors.Is(err, errNoPendingQuestion), errors.Is(err, errConversationOwned):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, errShuttingDown):
+		return status.Error(codes.Unavailable, err.Error())
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, err.Error())
+	default:
+		return status.Error(codes.Internal, "internal server error")
+	}
+}
+
+func (g *grpcService) ListConversations(ctx context.Context, req *grpcapi.ListConversationsRequest) (*grpcapi.ListConversationsResponse, error) {
+	limit := int64(5000)
+	if req.Limit > 0 {
+		limit = int64(req.Limit)
+	}
+	offset := int64(max(req.Offset, 0))
+	var conversations []generated.Conversation
+	var err error
+	if req.Query != "" {
+		conversations, err = g.s.db.SearchConversations(ctx, req.Query, limit, offset)
+	} else {
+		conversations, err = g.s.db.ListConversations(ctx, limit, offset)
+	}
+	if err != nil {
+		g.s.logger.Error("Failed to get conversations", "error", err)
+		return nil, grpcError(err)
+	}
+	resp := &grpcapi.ListConversationsResponse{}
+	for _, conversation := range conversations {
+		resp.Conversations = append(resp.Conversations, grpcConversation(conversation))
+	}
+	return resp, nil
+}
+
+func (g *grpcService) GetConversation(ctx context.Context, req *grpcapi.GetConversationRequest) (*grpcapi.GetConversationResponse, error) {
+	conversation, messages, err := g.s.conversationWithMessages(ctx, req.ConversationId)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	apiMessages := toAPIMessages(messages)
+	return &grpcapi.GetConversationResponse{
+		Conversation:      grpcConversation(conversation),
+		Messages:          grpcMessages(apiMessages),
+		AgentWorking:      agentWorking(apiMessages),
+		ContextWindowSize: calculateContextWindowSize(apiMessages),
+	}, nil
+}
+
+func (g *grpcService) NewConversation(ctx context.Context, req *grpcapi.NewConversationRequest) (*grpcapi.NewConversationResponse, error) {
+	chat := ChatRequest{
+		Message:      req.Message,
+		Model:        req.Model,
+		Cwd:          req.Cwd,
+		AllowedTools: req.AllowedTools,
+		ToolPreset:   req.ToolPreset,
+		Devcontainer: req.Devcontainer,
+		ProjectID:    req.ProjectId,
+	}
+	if req.Sandbox != nil {
+		chat.Sandbox = &SandboxOptions{
+			Enabled:       req.Sandbox.Enabled,
+			AllowNetwork:  req.Sandbox.AllowNetwork,
+			WritablePaths: req.Sandbox.WritablePaths,
+		}
+	}
+	if req.Remote != nil {
+		chat.Remote = &RemoteWorkspace{
+			Host:         req.Remote.Host,
+			Port:         int(req.Remote.Port),
+			IdentityFile: req.Remote.IdentityFile,
+		}
+	}
+	conversationID, err := g.s.newConversation(ctx, chat, grpcUserID(ctx))
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return &grpcapi.NewConversationResponse{ConversationId: conversationID}, nil
+}
+
+func (g *grpcService) SendMessage(ctx context.Context, req *grpcapi.SendMessageRequest) (*grpcapi.SendMessageResponse, error) {
+	queue, queued, err := g.s.sendMessage(ctx, req.ConversationId, ChatRequest{
+		Message: req.Message,
+		Model:   req.Model,
+		Queue:   req.Queue,
+	})
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	resp := &grpcapi.SendMessageResponse{Queued: queued}
+	if queue != nil {
+		resp.Queue = grpcQueue(queue)
+	}
+	return resp, nil
+}
+
+func (g *grpcService) CancelTurn(ctx context.Context, req *grpcapi.CancelTurnRequest) (*grpcapi.CancelTurnResponse, error) {
+	cancelled, err := g.s.cancelConversation(ctx, req.ConversationId)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return &grpcapi.CancelTurnResponse{Cancelled: cancelled}, nil
+}
+
+func (g *grpcService) AnswerQuestion(ctx context.Context, req *grpcapi.AnswerQuestionRequest) (*grpcapi.AnswerQuestionResponse, error) {
+	if req.ToolUseId == "" || req.Answer == "" {
+		return nil, status.Error(codes.InvalidArgument, "tool_use_id and answer are required")
+	}
+	if err := g.s.answerQuestion(req.ConversationId, req.ToolUseId, req.Answer); err != nil {
+		return nil, grpcError(err)
+	}
+	return &grpcapi.AnswerQuestionResponse{}, nil
+}
+
+func (g *grpcService) GetConversationTools(ctx context.Context, req *grpcapi.GetConversationToolsRequest) (*grpcapi.ConversationTools, error) {
+	conversation, err := g.s.db.GetConversationByID(ctx, req.ConversationId)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return grpcTools(conversationAllowedTools(*conversation), g.s.toolNames()), nil
+}
+
+func (g *grpcService) SetConversationTools(ctx context.Context, req *grpcapi.SetConversationToolsRequest) (*grpcapi.ConversationTools, error) {
+	if _, err := g.s.db.GetConversationByID(ctx, req.ConversationId); err != nil {
+		return nil, grpcError(err)
+	}
+	available := g.s.toolNames()
+	// Unlike JSON, protobuf can't tell an empty list from a missing one
+	allowedTools := req.AllowedTools
+	if allowedTools == nil {
+		allowedTools = []string{}
+	}
+	allowed, err := resolveAllowedTools(allowedTools, req.Preset, available)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if err := g.s.setConversationAllowedTools(ctx, req.ConversationId, allowed); err != nil {
+		g.s.logger.Error("Failed to update conversation tools", "conversationID", req.ConversationId, "error", err)
+		return nil, grpcError(err)
+	}
+	return grpcTools(allowed, available), nil
+}
+
+// StreamEvents sends the same updates as GET /conversation/<id>/stream.
+func (g *grpcService) StreamEvents(req *grpcapi.StreamEventsRequest, stream grpc.ServerStreamingServer[grpcapi.Event]) error {
+	ctx := stream.Context()
+	conversation, messages, err := g.s.conversationWithMessages(ctx, req.ConversationId)
+	if err != nil {
+		return grpcError(err)
+	}
+	apiMessages := toAPIMessages(messages)
+	err = stream.Send(grpcEvent(StreamResponse{
+		Messages:          apiMessages,
+		Conversation:      conversation,
+		AgentWorking:      agentWorking(apiMessages),
+		ContextWindowSize: calculateContextWindowSize(apiMessages),
+	}))
+	if err != nil {
+		return err
+	}
+
+	manager, err := g.s.getOrCreateConversationManager(ctx, req.ConversationId)
+	if err != nil {
+		g.s.logger.Error("Failed to get conversation manager", "conversationID", req.ConversationId, "error", err)
+		return grpcError(err)
+	}
+	last := int64(-1)
+	if len(messages) > 0 {
+		last = messages[len(messages)-1].SequenceID
+	}
+	next, running := manager.subscribe(ctx, last)
+	for _, output := range running {
+		if err := stream.Send(grpcEvent(StreamResponse{ToolOutput: &output, AgentWorking: true})); err != nil {
+			return err
+		}
+	}
+	manager.queueMu.Lock()
+	queued := manager.queuedMessages()
+	manager.queueMu.Unlock()
+	if len(queued) > 0 {
+		if err := stream.Send(grpcEvent(StreamResponse{Queue: &MessageQueue{Messages: queued}})); err != nil {
+			return err
+		}
+	}
+	for {
+		update, cont := next()
+		if !cont {
+			return nil
+		}
+		if err := stream.Send(grpcEvent(update)); err != nil {
+			return err
+		}
+	}
+}
+
+// conversationWithMessages returns a conversation and its messages.
+func (s *Server) conversationWithMessages(ctx context.Context, conversationID string) (generated.Conversation, []generated.Message, error) {
+	var conversation generated.Conversation
+	var messages []generated.Message
+	err := s.db.Queries(ctx, func(q *generated.Queries) error {
+		var err error
+		messages, err = q.ListMessages(ctx, conversationID)
+		if err != nil {
+			return err
+		}
+		conversation, err = q.GetConversation(ctx, conversationID)
+		return err
+	})
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		s.logger.Error("Failed to get conversation data", "conversationID", conversationID, "error", err)
+	}
+	return conversation, messages, err
+}
+
+func grpcConversation(c generated.Conversation) *grpcapi.Conversation {
+	deref := func(s *string) string {
+		if s == nil {
+			return ""
+		}
+		return *s
+	}
+	return &grpcapi.Conversation{
+		ConversationId:       c.ConversationID,
+		Slug:                 deref(c.Slug),
+		Cwd:                  deref(c.Cwd),
+		Model:                deref(c.ModelID),
+		ParentConversationId: deref(c.ParentConversationID),
+		Archived:             c.Archived,
+		Pinned:               c.Pinned,
+		AgentWorking:         c.AgentWorking,
+		AgentError:           c.AgentError,
+		ContextWindowSize:    c.ContextWindowSize,
+		CreatedAt:            timestamppb.New(c.CreatedAt),
+		UpdatedAt:            timestamppb.New(c.UpdatedAt),
+		ProjectId:            deref(c.ProjectID),
+		GuidanceFiles:        conversationGuidanceFiles(c),
+	}
+}
+
+func grpcMessages(messages []APIMessage) []*grpcapi.Message {
+	var out []*grpcapi.Message
+	for _, msg := range messages {
+		m := &grpcapi.Message{
+			MessageId:      msg.MessageID,
+			ConversationId: msg.ConversationID,
+			SequenceId:     msg.SequenceID,
+			Type:           msg.Type,
+			EndOfTurn:      msg.EndOfTurn != nil && *msg.EndOfTurn,
+			CreatedAt:      timestamppb.New(msg.CreatedAt),
+		}
+		if msg.LlmData != nil {
+			var llmMsg llm.Message
+			if err := json.Unmarshal([]byte(*msg.LlmData), &llmMsg); err == nil {
+				m.Role = grpcapi.Role_ROLE_USER
+				if llmMsg.Role == llm.MessageRoleAssistant {
+					m.Role = grpcapi.Role_ROLE_ASSISTANT
+				}
+				m.Content = grpcContents(llmMsg.Content)
+			}
+		}
+		if msg.UsageData != nil {
+			var usage llm.Usage
+			if err := json.Unmarshal([]byte(*msg.UsageData), &usage); err == nil && !usage.IsZero() {
+				m.Usage = &grpcapi.Usage{
+					InputTokens:              usage.InputTokens,
+					CacheCreationInputTokens: usage.CacheCreationInputTokens,
+					CacheReadInputTokens:     usage.CacheReadInputTokens,
+					OutputTokens:             usage.OutputTokens,
+					CostUsd:                  usage.CostUSD,
+					Model:                    usage.Model,
+				}
+			}
+		}
+		if msg.UserData != nil {
+			m.UserDataJson = *msg.UserData
+		}
+		if msg.DisplayData != nil {
+			m.DisplayDataJson = *msg.DisplayData
+		}
+		out = append(out, m)
+	}
+	return out
+}
+
+var grpcContentTypes = map[llm.ContentType]grpcapi.ContentType{
+	llm.ContentTypeText:             grpcapi.ContentType_CONTENT_TYPE_TEXT,
+	llm.ContentTypeThinking:         grpcapi.ContentType_CONTENT_TYPE_THINKING,
+	llm.ContentTypeRedactedThinking: grpcapi.ContentType_CONTENT_TYPE_REDACTED_THINKING,
+	llm.ContentTypeToolUse:          grpcapi.ContentType_CONTENT_TYPE_TOOL_USE,
+	llm.ContentTypeToolResult:       grpcapi.ContentType_CONTENT_TYPE_TOOL_RESULT,
+}
+
+func grpcContents(contents []llm.Content) []*grpcapi.Content {
+	var out []*grpcapi.Content
+	for _, c := range contents {
+		content := &grpcapi.Content{
+			Type:       grpcContentTypes[c.Type],
+			Text:       c.Text,
+			Thinking:   c.Thinking,
+			Id:         c.ID,
+			ToolName:   c.ToolName,
+			ToolUseId:  c.ToolUseID,
+			ToolError:  c.ToolError,
+			ToolResult: grpcContents(c.ToolResult),
+		}
+		if c.Type == llm.ContentTypeToolUse {
+			content.ToolInputJson = string(c.ToolInput)
+		}
+		out = append(out, content)
+	}
+	return out
+}
+
+func grpcQueue(queue *MessageQueue) *grpcapi.MessageQueue {
+	out := &grpcapi.MessageQueue{}
+	for _, msg := range queue.Messages {
+		out.Messages = append(out.Messages, &grpcapi.QueuedMessage{Id: msg.ID, Message: msg.Message})
+	}
+	return out
+}
+
+func grpcTools(allowed, available []string) *grpcapi.ConversationTools {
+	return &grpcapi.ConversationTools{
+		Restricted:   allowed != nil,
+		AllowedTools: allowed,
+		Available:    available,
+	}
+}
+
+// grpcEvent converts an update of the conversation stream.
+func grpcEvent(update StreamResponse) *grpcapi.Event {
+	event := &grpcapi.Event{
+		Messages:          grpcMessages(update.Messages),
+		AgentWorking:      update.AgentWorking,
+		ContextWindowSize: update.ContextWindowSize,
+	}
+	if update.Conversation.ConversationID != "" {
+		event.Conversation = grpcConversation(update.Conversation)
+	}
+	if update.ToolOutput != nil {
+		event.ToolOutput = &grpcapi.ToolOutput{
+			ToolUseId: update.ToolOutput.ToolUseID,
+			Output:    update.ToolOutput.Output,
+			Replace:   update.ToolOutput.Replace,
+		}
+	}
+	if update.Queue != nil {
+		event.Queue = grpcQueue(update.Queue)
+	}
+	return event
+}
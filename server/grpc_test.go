@@ -0,0 +1,207 @@
+package server
+
+import (
+	"context"
+	"net"
+	"slices"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"shelley.exe.dev/grpcapi"
+)
+
+// newGRPCClient serves the gRPC API of the harness's server in memory and
+// returns a client for it.
+func newGRPCClient(t *testing.T, h *TestHarness) grpcapi.ShelleyClient {
+	t.Helper()
+	listener := bufconn.Listen(1 << 20)
+	server := h.server.NewGRPCServer()
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return grpcapi.NewShelleyClient(conn)
+}
+
+// waitEvent receives events until one has a message for which match returns
+// true, and returns that message.
+func waitEvent(t *testing.T, stream grpc.ServerStreamingClient[grpcapi.Event], match func(*grpcapi.Message) bool) *grpcapi.Message {
+	t.Helper()
+	for {
+		event, err := stream.Recv()
+		if err != nil {
+			t.Fatalf("stream ended: %v", err)
+		}
+		for _, msg := range event.Messages {
+			if match(msg) {
+				return msg
+			}
+		}
+	}
+}
+
+func TestGRPC(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+	client := newGRPCClient(t, h)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	created, err := client.NewConversation(ctx, &grpcapi.NewConversationRequest{Message: "echo: hello", Model: "predictable"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := created.ConversationId
+
+	stream, err := client.StreamEvents(ctx, &grpcapi.StreamEventsRequest{ConversationId: id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	reply := waitEvent(t, stream, func(msg *grpcapi.Message) bool {
+		return msg.Type == "agent" && msg.EndOfTurn
+	})
+	if reply.Role != grpcapi.Role_ROLE_ASSISTANT || len(reply.Content) != 1 || reply.Content[0].Text != "hello" || reply.Usage == nil {
+		t.Errorf("unexpected reply %v", reply)
+	}
+
+	sent, err := client.SendMessage(ctx, &grpcapi.SendMessageRequest{ConversationId: id, Message: "bash: echo hi"})
+	if err != nil || sent.Queued {
+		t.Fatalf("SendMessage: %v, %v", sent, err)
+	}
+	result := waitEvent(t, stream, func(msg *grpcapi.Message) bool {
+		return len(msg.Content) > 0 && msg.Content[0].Type == grpcapi.ContentType_CONTENT_TYPE_TOOL_RESULT
+	})
+	if output := result.Content[0].ToolResult; len(output) == 0 || output[0].Text != "hi\n" {
+		t.Errorf("unexpected tool result %v", result)
+	}
+	waitEvent(t, stream, func(msg *grpcapi.Message) bool { return msg.EndOfTurn })
+
+	got, err := client.GetConversation(ctx, &grpcapi.GetConversationRequest{ConversationId: id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Conversation.ConversationId != id || got.Conversation.Model != "predictable" || got.AgentWorking {
+		t.Errorf("unexpected conversation %v", got.Conversation)
+	}
+	var first, use *grpcapi.Content
+	for _, msg := range got.Messages {
+		if msg.Type == "user" && first == nil {
+			first = msg.Content[0]
+		}
+		for _, content := range msg.Content {
+			if content.Type == grpcapi.ContentType_CONTENT_TYPE_TOOL_USE {
+				use = content
+			}
+		}
+	}
+	if first == nil || first.Text != "echo: hello" {
+		t.Errorf("unexpected first message %v", first)
+	}
+	if use == nil || use.ToolName != "bash" || use.ToolInputJson != `{"command":"echo hi"}` {
+		t.Errorf("unexpected tool use %v", use)
+	}
+
+	list, err := client.ListConversations(ctx, &grpcapi.ListConversationsRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Conversations) != 1 || list.Conversations[0].ConversationId != id {
+		t.Errorf("unexpected conversations %v", list.Conversations)
+	}
+
+	tools, err := client.SetConversationTools(ctx, &grpcapi.SetConversationToolsRequest{ConversationId: id, Preset: "read_only"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !tools.Restricted || slices.Contains(tools.AllowedTools, "bash") || !slices.Contains(tools.Available, "bash") {
+		t.Errorf("unexpected tools %v", tools)
+	}
+	tools, err = client.GetConversationTools(ctx, &grpcapi.GetConversationToolsRequest{ConversationId: id})
+	if err != nil || !tools.Restricted || slices.Contains(tools.AllowedTools, "bash") {
+		t.Errorf("GetConversationTools: %v, %v", tools, err)
+	}
+	if tools, err := client.SetConversationTools(ctx, &grpcapi.SetConversationToolsRequest{ConversationId: id}); err != nil || !tools.Restricted || len(tools.AllowedTools) != 0 {
+		t.Errorf("an empty list should allow no tools: %v, %v", tools, err)
+	}
+
+	cancelled, err := client.CancelTurn(ctx, &grpcapi.CancelTurnRequest{ConversationId: id})
+	if err != nil || !cancelled.Cancelled {
+		t.Errorf("CancelTurn: %v, %v", cancelled, err)
+	}
+
+	for _, tc := range []struct {
+		name string
+		call func() error
+		code codes.Code
+	}{
+		{"empty message", func() error {
+			_, err := client.SendMessage(ctx, &grpcapi.SendMessageRequest{ConversationId: id})
+			return err
+		}, codes.InvalidArgument},
+		{"unknown tool", func() error {
+			_, err := client.NewConversation(ctx, &grpcapi.NewConversationRequest{Message: "hi", Model: "predictable", AllowedTools: []string{"nope"}})
+			return err
+		}, codes.InvalidArgument},
+		{"unknown conversation", func() error {
+			_, err := client.GetConversation(ctx, &grpcapi.GetConversationRequest{ConversationId: "nope"})
+			return err
+		}, codes.NotFound},
+		{"no question", func() error {
+			_, err := client.AnswerQuestion(ctx, &grpcapi.AnswerQuestionRequest{ConversationId: id, ToolUseId: "x", Answer: "yes"})
+			return err
+		}, codes.FailedPrecondition},
+	} {
+		if code := status.Code(tc.call()); code != tc.code {
+			t.Errorf("%s: got code %v, want %v", tc.name, code, tc.code)
+		}
+	}
+}
+
+func TestGRPCRequireHeader(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+	h.server.requireHeader = "X-Exedev-Userid"
+	client := newGRPCClient(t, h)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	_, err := client.ListConversations(ctx, &grpcapi.ListConversationsRequest{})
+	if status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("expected PermissionDenied without the header, got %v", err)
+	}
+	stream, err := client.StreamEvents(ctx, &grpcapi.StreamEventsRequest{ConversationId: "x"})
+	if err == nil {
+		_, err = stream.Recv()
+	}
+	if status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("expected PermissionDenied for a stream without the header, got %v", err)
+	}
+
+	ctx = metadata.AppendToOutgoingContext(ctx, "x-exedev-userid", "alice")
+	created, err := client.NewConversation(ctx, &grpcapi.NewConversationRequest{Message: "echo: hi", Model: "predictable"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	conversation, err := h.db.GetConversationByID(ctx, created.ConversationId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conversation.UserID == nil || *conversation.UserID != "alice" {
+		t.Errorf("expected the conversation to belong to the header's user, got %v", conversation.UserID)
+	}
+}
@@ -0,0 +1,100 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"shelley.exe.dev/db"
+	"shelley.exe.dev/db/generated"
+	"shelley.exe.dev/llm"
+)
+
+// conversationGuidanceFiles returns the guidance files whose contents are in
+// the conversation's system prompt.
+func conversationGuidanceFiles(conv generated.Conversation) []string {
+	if conv.GuidanceFiles == nil {
+		return nil
+	}
+	var files []string
+	if err := json.Unmarshal([]byte(*conv.GuidanceFiles), &files); err != nil {
+		return nil
+	}
+	return files
+}
+
+// newGuidance returns the guidance files that apply in dir, other than the
+// ones already loaded, and a block with their contents for the system prompt.
+func newGuidance(dir string, loaded []string) (string, []string) {
+	gitInfo, _ := collectGitInfo(dir)
+	info, err := collectCodebaseInfo(dir, gitInfo)
+	if err != nil {
+		return "", nil
+	}
+	var files []string
+	for _, file := range info.InjectFiles {
+		if !slices.ContainsFunc(loaded, func(f string) bool { return strings.EqualFold(f, file) }) {
+			files = append(files, file)
+		}
+	}
+	if len(files) == 0 {
+		return "", nil
+	}
+
+	var b strings.Builder
+	b.WriteString("<guidance>\n")
+	fmt.Fprintf(&b, "The working directory changed to %s. These guidance files apply there too.\n", dir)
+	for _, file := range files {
+		fmt.Fprintf(&b, "<root_guidance file=%q>\n%s\n</root_guidance>\n", file, info.InjectFileContents[file])
+	}
+	b.WriteString("</guidance>")
+	return b.String(), files
+}
+
+// recordGuidanceFiles records which guidance files are in the conversation's
+// system prompt.
+func (cm *ConversationManager) recordGuidanceFiles(ctx context.Context, files []string) {
+	if files == nil {
+		files = []string{}
+	}
+	data, err := json.Marshal(files)
+	if err != nil {
+		return
+	}
+	if err := cm.db.UpdateConversationGuidanceFiles(ctx, cm.conversationID, string(data)); err != nil {
+		cm.logger.Error("Failed to record guidance files", "error", err)
+	}
+}
+
+// loadGuidance adds the guidance files for dir that the conversation hasn't
+// loaded yet to its system prompt, storing them like the system prompt so
+// they are part of it when the conversation is reloaded.
+func (cm *ConversationManager) loadGuidance(ctx context.Context, dir string, addSystem func(string)) {
+	conv, err := cm.db.GetConversationByID(ctx, cm.conversationID)
+	if err != nil {
+		cm.logger.Error("Failed to load conversation for guidance files", "error", err)
+		return
+	}
+	loaded := conversationGuidanceFiles(*conv)
+	text, files := newGuidance(dir, loaded)
+	if len(files) == 0 {
+		return
+	}
+	if _, err := cm.db.CreateMessage(ctx, db.CreateMessageParams{
+		ConversationID: cm.conversationID,
+		Type:           db.MessageTypeSystem,
+		LLMData: llm.Message{
+			Role:    llm.MessageRoleUser,
+			Content: []llm.Content{{Type: llm.ContentTypeText, Text: text}},
+		},
+		UsageData: llm.Usage{},
+	}); err != nil {
+		cm.logger.Error("Failed to store guidance files", "error", err)
+		return
+	}
+	addSystem(text)
+	cm.recordGuidanceFiles(ctx, append(loaded, files...))
+	cm.logger.Info("Loaded guidance files", "dir", dir, "files", files)
+}
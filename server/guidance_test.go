@@ -0,0 +1,82 @@
+package server
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestGuidanceDirs(t *testing.T) {
+	if dirs := guidanceDirs("/repo", "/repo/a/b"); !slices.Equal(dirs, []string{"/repo", "/repo/a", "/repo/a/b"}) {
+		t.Errorf("unexpected directories %q", dirs)
+	}
+	if dirs := guidanceDirs("/repo", "/repo"); !slices.Equal(dirs, []string{"/repo"}) {
+		t.Errorf("unexpected directories %q", dirs)
+	}
+	if dirs := guidanceDirs("/repo", "/elsewhere"); !slices.Equal(dirs, []string{"/repo", "/elsewhere"}) {
+		t.Errorf("unexpected directories %q", dirs)
+	}
+}
+
+func TestGuidanceFiles(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+
+	repo := t.TempDir()
+	if out, err := exec.Command("git", "init", repo).CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v\n%s", err, out)
+	}
+	sub := filepath.Join(repo, "a", "b")
+	if err := os.MkdirAll(sub, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for path, content := range map[string]string{
+		filepath.Join(repo, "AGENTS.md"):          "Run make before committing.",
+		filepath.Join(repo, "a", "CLAUDE.md"):     "The a package is generated.",
+		filepath.Join(repo, "a", "README.md"):     "An intermediate readme.",
+		filepath.Join(sub, "AGENTS.md"):           "Tests in b need docker.",
+		filepath.Join(repo, "other", "Agents.md"): "Unrelated.",
+	} {
+		os.MkdirAll(filepath.Dir(path), 0o755)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	h.NewConversation("echo: hi", filepath.Join(repo, "a"))
+	h.WaitResponse()
+	ctx := context.Background()
+	guidanceFiles := func() []string {
+		conv, err := h.db.GetConversationByID(ctx, h.convID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return slices.DeleteFunc(conversationGuidanceFiles(*conv), func(file string) bool {
+			return !strings.HasPrefix(file, repo)
+		})
+	}
+	want := []string{filepath.Join(repo, "AGENTS.md"), filepath.Join(repo, "a", "CLAUDE.md"), filepath.Join(repo, "a", "README.md")}
+	if files := guidanceFiles(); !slices.Equal(files, want) {
+		t.Errorf("expected the files from the root down to the working directory, got %q", files)
+	}
+
+	h.server.mu.Lock()
+	manager := h.server.activeConversations[h.convID]
+	h.server.mu.Unlock()
+	var added []string
+	manager.loadGuidance(ctx, sub, func(text string) { added = append(added, text) })
+	if len(added) != 1 || !strings.Contains(added[0], "Tests in b need docker.") || strings.Contains(added[0], "Run make") || strings.Contains(added[0], "intermediate") {
+		t.Errorf("expected only the new directory's guidance to be added, got %q", added)
+	}
+	if files := guidanceFiles(); !slices.Equal(files, append(want, filepath.Join(sub, "AGENTS.md"))) {
+		t.Errorf("expected the new file to be recorded, got %q", files)
+	}
+	manager.loadGuidance(ctx, filepath.Join(repo, "a"), func(text string) { added = append(added, text) })
+	if len(added) != 1 {
+		t.Errorf("expected nothing new going back up, got %q", added[1:])
+	}
+}
@@ -3,9 +3,7 @@ package server
 import (
 	"compress/gzip"
 	"context"
-	"crypto/rand"
 	"database/sql"
-	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -20,10 +18,13 @@ import (
 	"time"
 
 	"shelley.exe.dev/claudetool/browse"
+	"shelley.exe.dev/db"
 	"shelley.exe.dev/db/generated"
 	"shelley.exe.dev/gitstate"
 	"shelley.exe.dev/llm"
+	"shelley.exe.dev/loop"
 	"shelley.exe.dev/models"
+	"shelley.exe.dev/sandbox"
 	"shelley.exe.dev/slug"
 	"shelley.exe.dev/ui"
 	"shelley.exe.dev/version"
@@ -81,7 +82,9 @@ func (s *Server) handleRead(w http.ResponseWriter, r *http.Request) {
 	io.Copy(w, f)
 }
 
-// handleWriteFile writes content to a file (for diff viewer edit mode)
+// handleWriteFile writes content to a file (for diff viewer edit mode).
+// The previous content is kept in the file edit history, so the write can
+// be undone.
 func (s *Server) handleWriteFile(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
@@ -91,6 +94,11 @@ func (s *Server) handleWriteFile(w http.ResponseWriter, r *http.Request) {
 	var req struct {
 		Path    string `json:"path"`
 		Content string `json:"content"`
+		// ConversationID, if set, files the edit under that conversation.
+		ConversationID string `json:"conversation_id,omitempty"`
+		// BaseSHA256, if set, is the hash of the content the change was
+		// made to; the write is refused if the file no longer has it.
+		BaseSHA256 string `json:"base_sha256,omitempty"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "invalid request body", http.StatusBadRequest)
@@ -102,37 +110,83 @@ func (s *Server) handleWriteFile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Security: only allow writing within certain directories
-	// For now, require the path to be within a git repository
 	clean := filepath.Clean(req.Path)
 	if !filepath.IsAbs(clean) {
 		http.Error(w, "absolute path required", http.StatusBadRequest)
 		return
 	}
 
-	// Write the file
-	if err := os.WriteFile(clean, []byte(req.Content), 0o644); err != nil {
+	// Only write where the settings' policy allows
+	settings, err := GetSettings(r.Context(), s.db)
+	if err != nil {
+		s.logger.Error("failed to load settings", "error", err)
+		http.Error(w, "failed to load settings", http.StatusInternalServerError)
+		return
+	}
+	if err := settings.WriteFile.policy(true).CheckWrite(clean, len(req.Content)); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	before, err := os.ReadFile(clean)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		http.Error(w, fmt.Sprintf("failed to read file: %v", err), http.StatusInternalServerError)
+		return
+	}
+	existed := err == nil
+
+	// Don't clobber changes made since the client loaded the file
+	if req.BaseSHA256 != "" && (!existed || contentHash(before) != req.BaseSHA256) {
+		http.Error(w, "file changed since it was loaded; reload it before saving", http.StatusConflict)
+		return
+	}
+
+	if err := writeFileAtomic(clean, []byte(req.Content)); err != nil {
 		http.Error(w, fmt.Sprintf("failed to write file: %v", err), http.StatusInternalServerError)
 		return
 	}
+	s.invalidateGitDiffs(clean)
+
+	// Record the edit so it can be undone
+	edit := db.CreateFileEditParams{
+		Source: db.FileEditSourceWriteFile,
+		Path:   clean,
+		After:  []byte(req.Content),
+	}
+	if existed {
+		edit.Before = before
+	}
+	if req.ConversationID != "" {
+		edit.ConversationID = &req.ConversationID
+	}
+	if _, err := s.db.CreateFileEdit(r.Context(), edit); err != nil {
+		s.logger.Warn("Failed to record file edit", "path", clean, "error", err)
+	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok", "sha256": contentHash([]byte(req.Content))})
 }
 
 // handleUpload handles file uploads via POST /api/upload
-// Files are saved to the ScreenshotDir with a random filename
+// Files are saved to the upload directory, named by their content's hash
 func (s *Server) handleUpload(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	// Limit to 10MB file size
-	r.Body = http.MaxBytesReader(w, r.Body, 10*1024*1024)
+	// Uploads are held to the same size limit as files written from the UI
+	settings, err := GetSettings(r.Context(), s.db)
+	if err != nil {
+		s.logger.Error("failed to load settings", "error", err)
+		http.Error(w, "failed to load settings", http.StatusInternalServerError)
+		return
+	}
+	limit := int64(settings.WriteFile.policy(false).Limit())
+	r.Body = http.MaxBytesReader(w, r.Body, limit)
 
 	// Parse the multipart form
-	if err := r.ParseMultipartForm(10 * 1024 * 1024); err != nil {
+	if err := r.ParseMultipartForm(limit); err != nil {
 		http.Error(w, "failed to parse form: "+err.Error(), http.StatusBadRequest)
 		return
 	}
@@ -145,36 +199,10 @@ func (s *Server) handleUpload(w http.ResponseWriter, r *http.Request) {
 	}
 	defer file.Close()
 
-	// Generate a unique ID (8 random bytes converted to 16 hex chars)
-	randBytes := make([]byte, 8)
-	if _, err := rand.Read(randBytes); err != nil {
-		http.Error(w, "failed to generate random filename: "+err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	// Get file extension from the original filename
-	ext := filepath.Ext(handler.Filename)
-
-	// Create a unique filename in the ScreenshotDir
-	filename := filepath.Join(browse.ScreenshotDir, fmt.Sprintf("upload_%s%s", hex.EncodeToString(randBytes), ext))
-
-	// Ensure the directory exists
-	if err := os.MkdirAll(browse.ScreenshotDir, 0o755); err != nil {
-		http.Error(w, "failed to create directory: "+err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	// Create the destination file
-	destFile, err := os.Create(filename)
+	// Store it by its content's hash, once however often it's uploaded
+	filename, err := s.saveUpload(file, filepath.Ext(handler.Filename))
 	if err != nil {
-		http.Error(w, "failed to create destination file: "+err.Error(), http.StatusInternalServerError)
-		return
-	}
-	defer destFile.Close()
-
-	// Copy the file contents to the destination file
-	if _, err := io.Copy(destFile, file); err != nil {
-		http.Error(w, "failed to save file: "+err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -357,6 +385,66 @@ func generateFaviconSVG(hostname string) string {
 	)
 }
 
+// ModelInfo describes a model offered to the user: what it accepts and
+// costs, as its service reports, and whether it can be used. Health is
+// the latest health check of the model's provider, if it has been
+// checked.
+type ModelInfo struct {
+	ID               string `json:"id"`
+	Ready            bool   `json:"ready"`
+	MaxContextTokens int    `json:"max_context_tokens,omitempty"`
+	Provider         string `json:"provider,omitempty"`
+	Description      string `json:"description,omitempty"`
+	llm.Capabilities
+	Health *ProviderHealth `json:"health,omitempty"`
+}
+
+// usable reports whether the model is ready and its provider isn't
+// failing its health checks.
+func (m ModelInfo) usable() bool {
+	return m.Ready && (m.Health == nil || m.Health.Available)
+}
+
+// modelList returns the models offered to the user.
+func (s *Server) modelList() []ModelInfo {
+	var modelList []ModelInfo
+	if s.predictableOnly {
+		info := ModelInfo{ID: "predictable", Ready: true, MaxContextTokens: 200000}
+		if svc, err := s.llmManager.GetService("predictable"); err == nil {
+			info.Capabilities = llm.ModelCapabilities(svc)
+		}
+		return append(modelList, info)
+	}
+	for _, id := range s.llmManager.GetAvailableModels() {
+		// Skip predictable model unless predictable-only flag is set
+		if id == "predictable" {
+			continue
+		}
+		info := ModelInfo{ID: id, Provider: modelProvider(id), Health: s.providerHealth(id)}
+		if model := models.ByID(id); model != nil {
+			info.Description = model.Description
+		}
+		svc, err := s.llmManager.GetService(id)
+		if err == nil && svc != nil {
+			info.Ready = true
+			info.MaxContextTokens = svc.TokenContextWindow()
+			info.Capabilities = llm.ModelCapabilities(svc)
+		}
+		modelList = append(modelList, info)
+	}
+	return modelList
+}
+
+// handleModels handles GET /api/models.
+func (s *Server) handleModels(w http.ResponseWriter, r *http.Request) {
+	modelList := s.modelList()
+	if modelList == nil {
+		modelList = []ModelInfo{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(modelList)
+}
+
 // serveIndexWithInit serves index.html with injected initialization data
 func (s *Server) serveIndexWithInit(w http.ResponseWriter, r *http.Request, fs http.FileSystem) {
 	// Read index.html from the filesystem
@@ -374,47 +462,27 @@ func (s *Server) serveIndexWithInit(w http.ResponseWriter, r *http.Request, fs h
 	}
 
 	// Build initialization data
-	type ModelInfo struct {
-		ID               string `json:"id"`
-		Ready            bool   `json:"ready"`
-		MaxContextTokens int    `json:"max_context_tokens,omitempty"`
-	}
+	modelList := s.modelList()
 
-	var modelList []ModelInfo
-	if s.predictableOnly {
-		modelList = append(modelList, ModelInfo{ID: "predictable", Ready: true, MaxContextTokens: 200000})
-	} else {
-		modelIDs := s.llmManager.GetAvailableModels()
-		for _, id := range modelIDs {
-			// Skip predictable model unless predictable-only flag is set
-			if id == "predictable" {
-				continue
-			}
-			svc, err := s.llmManager.GetService(id)
-			maxCtx := 0
-			if err == nil && svc != nil {
-				maxCtx = svc.TokenContextWindow()
-			}
-			modelList = append(modelList, ModelInfo{ID: id, Ready: err == nil, MaxContextTokens: maxCtx})
-		}
+	// Select default model - use the user's or the configured default if available, otherwise first ready model
+	defaultModel := s.userDefaultModel(r)
+	if defaultModel == "" {
+		defaultModel = s.defaultModel
 	}
-
-	// Select default model - use configured default if available, otherwise first ready model
-	defaultModel := s.defaultModel
 	if defaultModel == "" {
 		defaultModel = models.Default().ID
 	}
 	defaultModelAvailable := false
 	for _, m := range modelList {
-		if m.ID == defaultModel && m.Ready {
+		if m.ID == defaultModel && m.usable() {
 			defaultModelAvailable = true
 			break
 		}
 	}
 	if !defaultModelAvailable {
-		// Fall back to first ready model
+		// Fall back to first usable model
 		for _, m := range modelList {
-			if m.Ready {
+			if m.usable() {
 				defaultModel = m.ID
 				break
 			}
@@ -453,6 +521,9 @@ func (s *Server) serveIndexWithInit(w http.ResponseWriter, r *http.Request, fs h
 	if len(s.links) > 0 {
 		initData["links"] = s.links
 	}
+	if s.transcriber != nil {
+		initData["voice_input"] = true
+	}
 
 	initJSON, err := json.Marshal(initData)
 	if err != nil {
@@ -535,21 +606,138 @@ func (s *Server) conversationMux() *http.ServeMux {
 	mux.HandleFunc("POST /{id}/chat", func(w http.ResponseWriter, r *http.Request) {
 		s.handleChatConversation(w, r, r.PathValue("id"))
 	})
+	mux.HandleFunc("POST /{id}/voice", func(w http.ResponseWriter, r *http.Request) {
+		s.handleVoice(w, r, r.PathValue("id"))
+	})
 	mux.HandleFunc("POST /{id}/cancel", func(w http.ResponseWriter, r *http.Request) {
 		s.handleCancelConversation(w, r, r.PathValue("id"))
 	})
+	mux.HandleFunc("POST /{id}/tools/{tool}/cancel", func(w http.ResponseWriter, r *http.Request) {
+		s.handleCancelTool(w, r, r.PathValue("id"))
+	})
 	mux.HandleFunc("POST /{id}/archive", func(w http.ResponseWriter, r *http.Request) {
 		s.handleArchiveConversation(w, r, r.PathValue("id"))
 	})
 	mux.HandleFunc("POST /{id}/unarchive", func(w http.ResponseWriter, r *http.Request) {
 		s.handleUnarchiveConversation(w, r, r.PathValue("id"))
 	})
+	mux.HandleFunc("POST /{id}/pin", func(w http.ResponseWriter, r *http.Request) {
+		s.handlePinConversation(w, r, r.PathValue("id"), true)
+	})
+	mux.HandleFunc("POST /{id}/unpin", func(w http.ResponseWriter, r *http.Request) {
+		s.handlePinConversation(w, r, r.PathValue("id"), false)
+	})
+	mux.HandleFunc("POST /{id}/slug/regenerate", func(w http.ResponseWriter, r *http.Request) {
+		s.handleRegenerateSlug(w, r, r.PathValue("id"))
+	})
 	mux.HandleFunc("POST /{id}/delete", func(w http.ResponseWriter, r *http.Request) {
 		s.handleDeleteConversation(w, r, r.PathValue("id"))
 	})
 	mux.HandleFunc("POST /{id}/rename", func(w http.ResponseWriter, r *http.Request) {
 		s.handleRenameConversation(w, r, r.PathValue("id"))
 	})
+	mux.HandleFunc("POST /{id}/compact", func(w http.ResponseWriter, r *http.Request) {
+		s.handleCompactConversation(w, r, r.PathValue("id"))
+	})
+	mux.HandleFunc("POST /{id}/resume", func(w http.ResponseWriter, r *http.Request) {
+		s.handleResumeConversation(w, r, r.PathValue("id"))
+	})
+	mux.HandleFunc("POST /{id}/rollback", func(w http.ResponseWriter, r *http.Request) {
+		s.handleRollbackConversation(w, r, r.PathValue("id"))
+	})
+	mux.HandleFunc("POST /{id}/truncate", func(w http.ResponseWriter, r *http.Request) {
+		s.handleTruncateConversation(w, r, r.PathValue("id"))
+	})
+	mux.HandleFunc("DELETE /{id}/messages/{seq}", func(w http.ResponseWriter, r *http.Request) {
+		s.handleDeleteMessage(w, r, r.PathValue("id"))
+	})
+	mux.HandleFunc("GET /{id}/messages/{message}/full", func(w http.ResponseWriter, r *http.Request) {
+		s.handleFullMessage(w, r, r.PathValue("id"), r.PathValue("message"))
+	})
+	mux.HandleFunc("GET /{id}/feedback", func(w http.ResponseWriter, r *http.Request) {
+		s.handleConversationFeedback(w, r, r.PathValue("id"))
+	})
+	mux.HandleFunc("PUT /{id}/feedback", func(w http.ResponseWriter, r *http.Request) {
+		s.handleConversationFeedback(w, r, r.PathValue("id"))
+	})
+	mux.HandleFunc("DELETE /{id}/feedback/{message}", func(w http.ResponseWriter, r *http.Request) {
+		s.handleDeleteFeedback(w, r, r.PathValue("id"))
+	})
+	mux.HandleFunc("POST /{id}/edit", func(w http.ResponseWriter, r *http.Request) {
+		s.handleEditMessage(w, r, r.PathValue("id"))
+	})
+	mux.HandleFunc("GET /{id}/edits", func(w http.ResponseWriter, r *http.Request) {
+		s.handleFileEdits(w, r, r.PathValue("id"))
+	})
+	mux.HandleFunc("POST /{id}/edits/revert", func(w http.ResponseWriter, r *http.Request) {
+		s.handleRevertFileEdits(w, r, r.PathValue("id"))
+	})
+	mux.HandleFunc("GET /{id}/changes", func(w http.ResponseWriter, r *http.Request) {
+		s.handleConversationChanges(w, r, r.PathValue("id"))
+	})
+	mux.HandleFunc("POST /{id}/queue/remove", func(w http.ResponseWriter, r *http.Request) {
+		s.handleRemoveQueued(w, r, r.PathValue("id"))
+	})
+	mux.HandleFunc("POST /{id}/answer", func(w http.ResponseWriter, r *http.Request) {
+		s.handleAnswer(w, r, r.PathValue("id"))
+	})
+	mux.HandleFunc("GET /{id}/shares", func(w http.ResponseWriter, r *http.Request) {
+		s.handleConversationShares(w, r, r.PathValue("id"))
+	})
+	mux.HandleFunc("POST /{id}/shares", func(w http.ResponseWriter, r *http.Request) {
+		s.handleConversationShares(w, r, r.PathValue("id"))
+	})
+	mux.HandleFunc("POST /{id}/shares/{token}/revoke", func(w http.ResponseWriter, r *http.Request) {
+		s.handleRevokeShare(w, r, r.PathValue("id"), r.PathValue("token"))
+	})
+	mux.HandleFunc("GET /{id}/notifications", func(w http.ResponseWriter, r *http.Request) {
+		s.handleConversationNotifications(w, r, r.PathValue("id"))
+	})
+	mux.HandleFunc("POST /{id}/notifications", func(w http.ResponseWriter, r *http.Request) {
+		s.handleConversationNotifications(w, r, r.PathValue("id"))
+	})
+	mux.HandleFunc("GET /{id}/ci-autofix", func(w http.ResponseWriter, r *http.Request) {
+		s.handleCIAutofix(w, r, r.PathValue("id"))
+	})
+	mux.HandleFunc("POST /{id}/ci-autofix", func(w http.ResponseWriter, r *http.Request) {
+		s.handleCIAutofix(w, r, r.PathValue("id"))
+	})
+	mux.HandleFunc("DELETE /{id}/ci-autofix", func(w http.ResponseWriter, r *http.Request) {
+		s.handleCIAutofix(w, r, r.PathValue("id"))
+	})
+	mux.HandleFunc("GET /{id}/review", func(w http.ResponseWriter, r *http.Request) {
+		s.handlePRReview(w, r, r.PathValue("id"))
+	})
+	mux.HandleFunc("POST /{id}/review/post", func(w http.ResponseWriter, r *http.Request) {
+		s.handlePostPRReview(w, r, r.PathValue("id"))
+	})
+	mux.HandleFunc("GET /{id}/plan", func(w http.ResponseWriter, r *http.Request) {
+		s.handleConversationPlan(w, r, r.PathValue("id"))
+	})
+	mux.HandleFunc("POST /{id}/plan/approve", func(w http.ResponseWriter, r *http.Request) {
+		s.handleApprovePlan(w, r, r.PathValue("id"))
+	})
+	mux.HandleFunc("POST /{id}/compare", func(w http.ResponseWriter, r *http.Request) {
+		s.handleCompare(w, r, r.PathValue("id"))
+	})
+	mux.HandleFunc("GET /{id}/sampling", func(w http.ResponseWriter, r *http.Request) {
+		s.handleConversationSampling(w, r, r.PathValue("id"))
+	})
+	mux.HandleFunc("POST /{id}/sampling", func(w http.ResponseWriter, r *http.Request) {
+		s.handleConversationSampling(w, r, r.PathValue("id"))
+	})
+	mux.HandleFunc("GET /{id}/thinking", func(w http.ResponseWriter, r *http.Request) {
+		s.handleConversationThinking(w, r, r.PathValue("id"))
+	})
+	mux.HandleFunc("POST /{id}/thinking", func(w http.ResponseWriter, r *http.Request) {
+		s.handleConversationThinking(w, r, r.PathValue("id"))
+	})
+	mux.HandleFunc("GET /{id}/tools", func(w http.ResponseWriter, r *http.Request) {
+		s.handleConversationTools(w, r, r.PathValue("id"))
+	})
+	mux.HandleFunc("POST /{id}/tools", func(w http.ResponseWriter, r *http.Request) {
+		s.handleConversationTools(w, r, r.PathValue("id"))
+	})
 	return mux
 }
 
@@ -560,18 +748,25 @@ func (s *Server) handleGetConversation(w http.ResponseWriter, r *http.Request, c
 		return
 	}
 
+	page, err := parseMessagePage(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	ctx := r.Context()
 	var (
 		messages     []generated.Message
+		hasMore      bool
 		conversation generated.Conversation
 	)
-	err := s.db.Queries(ctx, func(q *generated.Queries) error {
+	err = s.db.Queries(ctx, func(q *generated.Queries) error {
 		var err error
-		messages, err = q.ListMessages(ctx, conversationID)
+		conversation, err = q.GetConversation(ctx, conversationID)
 		if err != nil {
 			return err
 		}
-		conversation, err = q.GetConversation(ctx, conversationID)
+		messages, hasMore, err = listMessagePage(ctx, q, conversationID, page)
 		return err
 	})
 	if err != nil {
@@ -579,6 +774,10 @@ func (s *Server) handleGetConversation(w http.ResponseWriter, r *http.Request, c
 			http.Error(w, "Conversation not found", http.StatusNotFound)
 			return
 		}
+		if errors.Is(err, errBadMessagePage) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 		s.logger.Error("Failed to get conversation messages", "conversationID", conversationID, "error", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
@@ -586,12 +785,16 @@ func (s *Server) handleGetConversation(w http.ResponseWriter, r *http.Request, c
 
 	w.Header().Set("Content-Type", "application/json")
 	apiMessages := toAPIMessages(messages)
-	json.NewEncoder(w).Encode(StreamResponse{
-		Messages:          apiMessages,
-		Conversation:      conversation,
-		AgentWorking:      agentWorking(apiMessages),
-		ContextWindowSize: calculateContextWindowSize(apiMessages),
-	})
+	response := StreamResponse{
+		Messages:     apiMessages,
+		Conversation: conversation,
+		HasMore:      hasMore,
+	}
+	if page.tail(hasMore) {
+		response.AgentWorking = agentWorking(apiMessages)
+		response.ContextWindowSize = calculateContextWindowSize(apiMessages)
+	}
+	json.NewEncoder(w).Encode(response)
 }
 
 // ChatRequest represents a chat message from the user
@@ -599,6 +802,39 @@ type ChatRequest struct {
 	Message string `json:"message"`
 	Model   string `json:"model,omitempty"`
 	Cwd     string `json:"cwd,omitempty"`
+	// ProjectID starts a new conversation in a project: Cwd defaults to the
+	// project's root, and Model and the tools to the project's defaults.
+	// Without it, a new conversation joins the project whose root contains Cwd.
+	ProjectID string `json:"project_id,omitempty"`
+	// Sandbox overrides the server's default sandbox options. It only
+	// applies when starting a new conversation.
+	Sandbox *SandboxOptions `json:"sandbox,omitempty"`
+	// Remote runs a new conversation's tools on another host over SSH, in
+	// Cwd there. Such conversations aren't sandboxed.
+	Remote *RemoteWorkspace `json:"remote,omitempty"`
+	// Devcontainer runs a new conversation's tools in the dev container
+	// configured in Cwd, building and starting it if needed.
+	Devcontainer bool `json:"devcontainer,omitempty"`
+	// AllowedTools and ToolPreset restrict the tools a new conversation may
+	// use, as in ConversationToolsRequest.
+	AllowedTools []string `json:"allowed_tools,omitempty"`
+	ToolPreset   string   `json:"tool_preset,omitempty"`
+	// Sampling sets a new conversation's sampling parameters, as POST
+	// /api/conversation/<id>/sampling does.
+	Sampling *llm.Sampling `json:"sampling,omitempty"`
+	// Thinking sets the reasoning asked of the model for the turn this
+	// message starts, instead of the conversation's.
+	Thinking *llm.Thinking `json:"thinking,omitempty"`
+	// Queue holds the message until the agent's current turn ends, if it
+	// is working, instead of handing it to the agent mid-turn.
+	Queue bool `json:"queue,omitempty"`
+	// Plan starts a new conversation in plan mode: the agent proposes a
+	// plan, and may only use read-only tools until it is approved.
+	Plan bool `json:"plan,omitempty"`
+
+	// onCreate, if set, records more about a new conversation before its
+	// manager is created, such as a review conversation's pull request.
+	onCreate func(ctx context.Context, conversationID string) error
 }
 
 // handleChatConversation handles POST /conversation/<id>/chat
@@ -622,8 +858,76 @@ func (s *Server) handleChatConversation(w http.ResponseWriter, r *http.Request,
 		return
 	}
 
-	// Get LLM service for the requested model
+	if cmd, args := parseSlashCommand(req.Message); cmd != nil {
+		s.handleSlashCommand(w, r, conversationID, cmd, args, req)
+		return
+	}
+
+	queue, queued, err := s.sendMessage(ctx, conversationID, req)
+	if err != nil {
+		var badRequest *badRequestError
+		if errors.As(err, &badRequest) || errors.Is(err, errConversationModelMismatch) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, errShuttingDown) {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		if errors.Is(err, errConversationOwned) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if queued {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status": "queued",
+			"queue":  queue,
+		})
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{"status": "accepted"})
+}
+
+// badRequestError is a problem with what a request asked for rather than a
+// failure of the server. Handlers report it with status 400.
+type badRequestError struct {
+	msg string
+}
+
+func (e *badRequestError) Error() string { return e.msg }
+
+func badRequestf(format string, args ...any) error {
+	return &badRequestError{msg: fmt.Sprintf(format, args...)}
+}
+
+// sendMessage sends a user message to a conversation's agent, or queues it
+// if req.Queue is set and the agent is working, in which case it returns
+// the queue. The model defaults to the conversation's, then the server's.
+func (s *Server) sendMessage(ctx context.Context, conversationID string, req ChatRequest) (*MessageQueue, bool, error) {
+	if req.Message == "" {
+		return nil, false, badRequestf("Message is required")
+	}
+	if req.Thinking != nil {
+		if err := req.Thinking.Validate(); err != nil {
+			return nil, false, badRequestf("%v", err)
+		}
+	}
+
+	// Get LLM service for the requested model, falling back to the
+	// conversation's model (which /model changes) and then the default
 	modelID := req.Model
+	if modelID == "" {
+		if conversation, err := s.db.GetConversationByID(ctx, conversationID); err == nil && conversation.ModelID != nil {
+			modelID = *conversation.ModelID
+		}
+	}
 	if modelID == "" {
 		modelID = s.defaultModel
 	}
@@ -631,39 +935,48 @@ func (s *Server) handleChatConversation(w http.ResponseWriter, r *http.Request,
 	llmService, err := s.llmManager.GetService(modelID)
 	if err != nil {
 		s.logger.Error("Unsupported model requested", "model", modelID, "error", err)
-		http.Error(w, fmt.Sprintf("Unsupported model: %s", modelID), http.StatusBadRequest)
-		return
+		return nil, false, badRequestf("Unsupported model: %s", modelID)
 	}
 
 	// Get or create conversation manager
 	manager, err := s.getOrCreateConversationManager(ctx, conversationID)
 	if err != nil {
-		if errors.Is(err, errConversationModelMismatch) {
-			http.Error(w, err.Error(), http.StatusBadRequest)
-			return
+		if !errors.Is(err, errConversationModelMismatch) {
+			s.logger.Error("Failed to get conversation manager", "conversationID", conversationID, "error", err)
 		}
-		s.logger.Error("Failed to get conversation manager", "conversationID", conversationID, "error", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
-		return
+		return nil, false, err
 	}
 
-	// Create user message
-	userMessage := llm.Message{
-		Role: llm.MessageRoleUser,
-		Content: []llm.Content{
-			{Type: llm.ContentTypeText, Text: req.Message},
-		},
+	if req.Queue {
+		queue, queued, err := s.queueUserMessage(ctx, manager, req.Message, modelID, req.Thinking)
+		if err != nil {
+			s.logger.Error("Failed to queue user message", "conversationID", conversationID, "error", err)
+			return nil, false, err
+		}
+		if queued {
+			return queue, true, nil
+		}
 	}
 
+	return nil, false, s.acceptMessage(ctx, manager, llmService, modelID, req.Message, req.Thinking)
+}
+
+// acceptMessage hands a user message to a conversation's agent, naming the
+// conversation in the background if it's the first message. Thinking, if
+// set, applies to the turn the message starts.
+func (s *Server) acceptMessage(ctx context.Context, manager *ConversationManager, llmService llm.Service, modelID, text string, thinking *llm.Thinking) error {
+	conversationID := manager.conversationID
+	userMessage := llm.Message{
+		Role:     llm.MessageRoleUser,
+		Content:  []llm.Content{{Type: llm.ContentTypeText, Text: text}},
+		Thinking: thinking,
+	}
 	firstMessage, err := manager.AcceptUserMessage(ctx, llmService, modelID, userMessage)
 	if err != nil {
-		if errors.Is(err, errConversationModelMismatch) {
-			http.Error(w, err.Error(), http.StatusBadRequest)
-			return
+		if !errors.Is(err, errConversationModelMismatch) && !errors.Is(err, errShuttingDown) && !errors.Is(err, errConversationOwned) {
+			s.logger.Error("Failed to accept user message", "conversationID", conversationID, "error", err)
 		}
-		s.logger.Error("Failed to accept user message", "conversationID", conversationID, "error", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
-		return
+		return err
 	}
 
 	if firstMessage {
@@ -671,7 +984,7 @@ func (s *Server) handleChatConversation(w http.ResponseWriter, r *http.Request,
 		go func() {
 			slugCtx, cancel := context.WithTimeout(ctxNoCancel, 15*time.Second)
 			defer cancel()
-			_, err := slug.GenerateSlug(slugCtx, s.llmManager, s.db, s.logger, conversationID, req.Message, modelID)
+			_, err := slug.GenerateSlug(slugCtx, s.llmManager, s.db, s.logger, conversationID, text, modelID, s.slugConfig(slugCtx))
 			if err != nil {
 				s.logger.Warn("Failed to generate slug for conversation", "conversationID", conversationID, "error", err)
 			} else {
@@ -679,9 +992,7 @@ func (s *Server) handleChatConversation(w http.ResponseWriter, r *http.Request,
 			}
 		}()
 	}
-
-	w.WriteHeader(http.StatusAccepted)
-	json.NewEncoder(w).Encode(map[string]string{"status": "accepted"})
+	return nil
 }
 
 // handleNewConversation handles POST /api/conversations/new - creates conversation implicitly on first message
@@ -691,8 +1002,6 @@ func (s *Server) handleNewConversation(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	ctx := r.Context()
-
 	// Parse request
 	var req ChatRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -700,13 +1009,55 @@ func (s *Server) handleNewConversation(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if req.Message == "" {
-		http.Error(w, "Message is required", http.StatusBadRequest)
+	conversationID, err := s.newConversation(r.Context(), req, s.userID(r))
+	if err != nil {
+		var badRequest *badRequestError
+		if errors.As(err, &badRequest) || errors.Is(err, errConversationModelMismatch) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, errShuttingDown) {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":          "accepted",
+		"conversation_id": conversationID,
+	})
+}
+
+// newConversation starts a conversation for userID with its first message
+// and returns its ID. The model defaults to the project's default model,
+// then the user's.
+func (s *Server) newConversation(ctx context.Context, req ChatRequest, userID string) (string, error) {
+	if req.Message == "" {
+		return "", badRequestf("Message is required")
+	}
+	if s.draining.Load() {
+		return "", errShuttingDown
+	}
+	if req.Plan {
+		req.Message += "\n\n" + planInstructions
+	}
+	project, err := s.conversationProject(ctx, &req)
+	if err != nil {
+		return "", err
+	}
+	if err := s.applyProjectConfig(&req); err != nil {
+		return "", err
+	}
+
 	// Get LLM service for the requested model
 	modelID := req.Model
+	if modelID == "" {
+		modelID = s.defaultModelForUser(ctx, userID)
+	}
 	if modelID == "" {
 		// Default to Qwen3 Coder on Fireworks
 		modelID = "qwen3-coder-fireworks"
@@ -715,8 +1066,41 @@ func (s *Server) handleNewConversation(w http.ResponseWriter, r *http.Request) {
 	llmService, err := s.llmManager.GetService(modelID)
 	if err != nil {
 		s.logger.Error("Unsupported model requested", "model", modelID, "error", err)
-		http.Error(w, fmt.Sprintf("Unsupported model: %s", modelID), http.StatusBadRequest)
-		return
+		return "", badRequestf("Unsupported model: %s", modelID)
+	}
+
+	sandboxOpts := s.defaultSandbox
+	if req.Sandbox != nil {
+		sandboxOpts = *req.Sandbox
+	}
+	if req.Remote != nil {
+		if err := checkRemoteWorkspace(ctx, *req.Remote, req.Cwd, req.Sandbox); err != nil {
+			return "", err
+		}
+		sandboxOpts = SandboxOptions{}
+	}
+	if req.Devcontainer {
+		if err := checkDevcontainer(req); err != nil {
+			return "", err
+		}
+		sandboxOpts = SandboxOptions{}
+	}
+	if sandboxOpts.Enabled && !sandbox.Available() {
+		return "", badRequestf("%v", sandbox.ErrUnavailable)
+	}
+	allowedTools, err := resolveAllowedTools(req.AllowedTools, req.ToolPreset, s.toolNames())
+	if err != nil {
+		return "", badRequestf("%v", err)
+	}
+	if req.Sampling != nil {
+		if err := req.Sampling.Validate(); err != nil {
+			return "", badRequestf("%v", err)
+		}
+	}
+	if req.Thinking != nil {
+		if err := req.Thinking.Validate(); err != nil {
+			return "", badRequestf("%v", err)
+		}
 	}
 
 	// Create new conversation with optional cwd and git origin
@@ -724,69 +1108,92 @@ func (s *Server) handleNewConversation(w http.ResponseWriter, r *http.Request) {
 	var gitOriginPtr *string
 	if req.Cwd != "" {
 		cwdPtr = &req.Cwd
-		if origin := gitstate.GetGitOrigin(req.Cwd); origin != "" {
+		origin := ""
+		if req.Remote != nil {
+			origin = gitstate.GetGitOriginWith(req.Remote.shell().Git, req.Cwd)
+		} else {
+			origin = gitstate.GetGitOrigin(req.Cwd)
+		}
+		if origin != "" {
 			gitOriginPtr = &origin
 		}
 	}
 	conversation, err := s.db.CreateConversation(ctx, nil, true, cwdPtr, gitOriginPtr, &modelID)
 	if err != nil {
 		s.logger.Error("Failed to create conversation", "error", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
-		return
+		return "", err
 	}
 	conversationID := conversation.ConversationID
-
-	// Get or create conversation manager
-	manager, err := s.getOrCreateConversationManager(ctx, conversationID)
-	if err != nil {
-		if errors.Is(err, errConversationModelMismatch) {
-			http.Error(w, err.Error(), http.StatusBadRequest)
-			return
+	if sandboxOpts.Enabled {
+		data, _ := json.Marshal(sandboxOpts)
+		if err := s.db.UpdateConversationSandbox(ctx, conversationID, string(data)); err != nil {
+			s.logger.Error("Failed to record conversation sandbox", "conversationID", conversationID, "error", err)
+			return "", err
 		}
-		s.logger.Error("Failed to get conversation manager", "conversationID", conversationID, "error", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
-		return
 	}
-
-	// Create user message
-	userMessage := llm.Message{
-		Role: llm.MessageRoleUser,
-		Content: []llm.Content{
-			{Type: llm.ContentTypeText, Text: req.Message},
-		},
+	if req.Remote != nil {
+		data, _ := json.Marshal(req.Remote)
+		if err := s.db.UpdateConversationRemote(ctx, conversationID, string(data)); err != nil {
+			s.logger.Error("Failed to record conversation remote", "conversationID", conversationID, "error", err)
+			return "", err
+		}
+	}
+	if req.Devcontainer {
+		if err := s.db.UpdateConversationDevcontainer(ctx, conversationID, req.Cwd); err != nil {
+			s.logger.Error("Failed to record conversation dev container", "conversationID", conversationID, "error", err)
+			return "", err
+		}
+		go s.startDevcontainer(req.Cwd)
+	}
+	if allowedTools != nil {
+		if err := s.setConversationAllowedTools(ctx, conversationID, allowedTools); err != nil {
+			s.logger.Error("Failed to record conversation tools", "conversationID", conversationID, "error", err)
+			return "", err
+		}
+	}
+	if req.Sampling != nil {
+		if err := s.setConversationSampling(ctx, conversationID, req.Sampling); err != nil {
+			s.logger.Error("Failed to record conversation sampling", "conversationID", conversationID, "error", err)
+			return "", err
+		}
+	}
+	if req.Plan {
+		if err := s.startPlan(ctx, conversationID, allowedTools); err != nil {
+			s.logger.Error("Failed to start conversation plan", "conversationID", conversationID, "error", err)
+			return "", err
+		}
+	}
+	if project != nil {
+		if err := s.db.UpdateConversationProject(ctx, conversationID, project.ProjectID); err != nil {
+			s.logger.Error("Failed to record conversation project", "conversationID", conversationID, "error", err)
+			return "", err
+		}
+	}
+	if userID != "" {
+		if err := s.db.UpdateConversationUserID(ctx, conversationID, userID); err != nil {
+			s.logger.Error("Failed to record conversation user", "conversationID", conversationID, "error", err)
+			return "", err
+		}
+	}
+	if req.onCreate != nil {
+		if err := req.onCreate(ctx, conversationID); err != nil {
+			s.logger.Error("Failed to set up conversation", "conversationID", conversationID, "error", err)
+			return "", err
+		}
 	}
 
-	firstMessage, err := manager.AcceptUserMessage(ctx, llmService, modelID, userMessage)
+	// Get or create conversation manager
+	manager, err := s.getOrCreateConversationManager(ctx, conversationID)
 	if err != nil {
-		if errors.Is(err, errConversationModelMismatch) {
-			http.Error(w, err.Error(), http.StatusBadRequest)
-			return
+		if !errors.Is(err, errConversationModelMismatch) {
+			s.logger.Error("Failed to get conversation manager", "conversationID", conversationID, "error", err)
 		}
-		s.logger.Error("Failed to accept user message", "conversationID", conversationID, "error", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
-		return
+		return "", err
 	}
-
-	if firstMessage {
-		ctxNoCancel := context.WithoutCancel(ctx)
-		go func() {
-			slugCtx, cancel := context.WithTimeout(ctxNoCancel, 15*time.Second)
-			defer cancel()
-			_, err := slug.GenerateSlug(slugCtx, s.llmManager, s.db, s.logger, conversationID, req.Message, modelID)
-			if err != nil {
-				s.logger.Warn("Failed to generate slug for conversation", "conversationID", conversationID, "error", err)
-			} else {
-				go s.notifySubscribers(ctxNoCancel, conversationID)
-			}
-		}()
+	if err := s.acceptMessage(ctx, manager, llmService, modelID, req.Message, req.Thinking); err != nil {
+		return "", err
 	}
-
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"status":          "accepted",
-		"conversation_id": conversationID,
-	})
+	return conversationID, nil
 }
 
 // handleCancelConversation handles POST /conversation/<id>/cancel
@@ -796,32 +1203,120 @@ func (s *Server) handleCancelConversation(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	ctx := r.Context()
+	cancelled, err := s.cancelConversation(r.Context(), conversationID)
+	if err != nil {
+		http.Error(w, "Failed to cancel conversation", http.StatusInternalServerError)
+		return
+	}
+	if !cancelled {
+		// No active conversation to cancel
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]string{"status": "no_active_conversation"})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"status": "cancelled"})
+}
 
+// cancelConversation cancels the agent's turn in a conversation and drops
+// its queued messages. It reports false if the conversation isn't active.
+func (s *Server) cancelConversation(ctx context.Context, conversationID string) (bool, error) {
 	// Get the conversation manager if it exists
 	s.mu.Lock()
 	manager, exists := s.activeConversations[conversationID]
 	s.mu.Unlock()
 
 	if !exists {
-		// No active conversation to cancel
-		w.WriteHeader(http.StatusOK)
-		json.NewEncoder(w).Encode(map[string]string{"status": "no_active_conversation"})
-		return
+		return false, nil
 	}
 
+	// Queued messages would otherwise start a new turn as soon as this one ends
+	manager.removeQueued("")
+
 	// Cancel the conversation
 	if err := manager.CancelConversation(ctx); err != nil {
 		s.logger.Error("Failed to cancel conversation", "conversationID", conversationID, "error", err)
-		http.Error(w, "Failed to cancel conversation", http.StatusInternalServerError)
-		return
+		return false, err
 	}
 
 	s.logger.Info("Conversation cancelled", "conversationID", conversationID)
-	w.WriteHeader(http.StatusOK)
+	return true, nil
+}
+
+// handleCancelTool handles POST /conversation/<id>/tools/<tool_use_id>/cancel
+func (s *Server) handleCancelTool(w http.ResponseWriter, r *http.Request, conversationID string) {
+	s.mu.Lock()
+	manager, exists := s.activeConversations[conversationID]
+	s.mu.Unlock()
+
+	if !exists || !manager.CancelTool(r.PathValue("tool")) {
+		http.Error(w, "Tool call not running", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{"status": "cancelled"})
 }
 
+// handleCompactConversation handles POST /conversation/<id>/compact
+func (s *Server) handleCompactConversation(w http.ResponseWriter, r *http.Request, conversationID string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ctx := r.Context()
+
+	conversation, err := s.db.GetConversationByID(ctx, conversationID)
+	if err != nil {
+		http.Error(w, "Conversation not found", http.StatusNotFound)
+		return
+	}
+
+	if err := s.compactConversation(ctx, conversation); err != nil {
+		switch {
+		case errors.Is(err, errUnsupportedModel):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, loop.ErrLoopBusy):
+			http.Error(w, "Agent is working; try again when the turn ends", http.StatusConflict)
+		case errors.Is(err, loop.ErrNothingToCompact):
+			http.Error(w, "Nothing to compact", http.StatusConflict)
+		case errors.Is(err, errConversationModelMismatch):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			s.logger.Error("Failed to compact conversation", "conversationID", conversationID, "error", err)
+			http.Error(w, "Failed to compact conversation", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "compacted"})
+}
+
+var errUnsupportedModel = errors.New("unsupported model")
+
+// compactConversation summarizes a conversation's history using its model.
+func (s *Server) compactConversation(ctx context.Context, conversation *generated.Conversation) error {
+	modelID := s.defaultModel
+	if conversation.ModelID != nil {
+		modelID = *conversation.ModelID
+	}
+
+	llmService, err := s.llmManager.GetService(modelID)
+	if err != nil {
+		s.logger.Error("Unsupported model for compaction", "model", modelID, "error", err)
+		return fmt.Errorf("%w: %s", errUnsupportedModel, modelID)
+	}
+
+	manager, err := s.getOrCreateConversationManager(ctx, conversation.ConversationID)
+	if err != nil {
+		return fmt.Errorf("failed to get conversation manager: %w", err)
+	}
+	return manager.Compact(ctx, llmService, modelID)
+}
+
 // handleStreamConversation handles GET /conversation/<id>/stream
 func (s *Server) handleStreamConversation(w http.ResponseWriter, r *http.Request, conversationID string) {
 	if r.Method != http.MethodGet {
@@ -829,6 +1324,17 @@ func (s *Server) handleStreamConversation(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	// Only the latest limit messages are sent if asked to; the client pages
+	// back for earlier ones.
+	page, err := parseMessagePage(r)
+	if err == nil && (page.before != "" || page.after != "") {
+		err = fmt.Errorf("%w: the stream only takes a limit", errBadMessagePage)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	ctx := r.Context()
 
 	// Set up SSE headers
@@ -840,10 +1346,11 @@ func (s *Server) handleStreamConversation(w http.ResponseWriter, r *http.Request
 
 	// Get current messages and conversation data
 	var messages []generated.Message
+	var hasMore bool
 	var conversation generated.Conversation
-	err := s.db.Queries(ctx, func(q *generated.Queries) error {
+	err = s.db.Queries(ctx, func(q *generated.Queries) error {
 		var err error
-		messages, err = q.ListMessages(ctx, conversationID)
+		messages, hasMore, err = listMessagePage(ctx, q, conversationID, page)
 		if err != nil {
 			return err
 		}
@@ -864,6 +1371,7 @@ func (s *Server) handleStreamConversation(w http.ResponseWriter, r *http.Request
 		AgentWorking:      agentWorking(apiMessages),
 		ContextWindowSize: calculateContextWindowSize(apiMessages),
 		AssetHash:         s.assetHash,
+		HasMore:           hasMore,
 	}
 	data, _ := json.Marshal(streamData)
 	fmt.Fprintf(w, "data: %s\n\n", data)
@@ -881,7 +1389,19 @@ func (s *Server) handleStreamConversation(w http.ResponseWriter, r *http.Request
 	if len(messages) > 0 {
 		last = messages[len(messages)-1].SequenceID
 	}
-	next := manager.subpub.Subscribe(ctx, last)
+	next, running := manager.subscribe(ctx, last)
+	for _, output := range running {
+		data, _ := json.Marshal(StreamResponse{ToolOutput: &output, AgentWorking: true})
+		fmt.Fprintf(w, "data: %s\n\n", data)
+	}
+	manager.queueMu.Lock()
+	queued := manager.queuedMessages()
+	manager.queueMu.Unlock()
+	if len(queued) > 0 {
+		data, _ := json.Marshal(StreamResponse{Queue: &MessageQueue{Messages: queued}})
+		fmt.Fprintf(w, "data: %s\n\n", data)
+	}
+	w.(http.Flusher).Flush()
 	for {
 		streamData, cont := next()
 		if !cont {
@@ -1144,6 +1664,7 @@ func (s *Server) handleArchiveConversation(w http.ResponseWriter, r *http.Reques
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
+	s.dropCheckpoints(ctx, conversation)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(conversation)
@@ -1168,6 +1689,29 @@ func (s *Server) handleUnarchiveConversation(w http.ResponseWriter, r *http.Requ
 	json.NewEncoder(w).Encode(conversation)
 }
 
+// handlePinConversation handles POST /conversation/<id>/pin and /unpin
+func (s *Server) handlePinConversation(w http.ResponseWriter, r *http.Request, conversationID string, pinned bool) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ctx := r.Context()
+	conversation, err := s.db.SetConversationPinned(ctx, conversationID, pinned)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Conversation not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		s.logger.Error("Failed to pin conversation", "conversationID", conversationID, "pinned", pinned, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(conversation)
+}
+
 // handleDeleteConversation handles POST /conversation/<id>/delete
 func (s *Server) handleDeleteConversation(w http.ResponseWriter, r *http.Request, conversationID string) {
 	if r.Method != http.MethodPost {
@@ -1176,6 +1720,10 @@ func (s *Server) handleDeleteConversation(w http.ResponseWriter, r *http.Request
 	}
 
 	ctx := r.Context()
+	// The checkpoints go with the conversation, and their snapshots too
+	if conversation, err := s.db.GetConversationByID(ctx, conversationID); err == nil {
+		s.dropCheckpoints(ctx, conversation)
+	}
 	if err := s.db.DeleteConversation(ctx, conversationID); err != nil {
 		s.logger.Error("Failed to delete conversation", "conversationID", conversationID, "error", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
@@ -1191,9 +1739,11 @@ type RenameRequest struct {
 	Slug string `json:"slug"`
 }
 
-// handleRenameConversation handles POST /conversation/<id>/rename
+// handleRenameConversation handles POST /conversation/<id>/rename and
+// PATCH /conversations/<id>/slug. If another conversation already has the
+// title, a numeric suffix is added as for generated slugs.
 func (s *Server) handleRenameConversation(w http.ResponseWriter, r *http.Request, conversationID string) {
-	if r.Method != http.MethodPost {
+	if r.Method != http.MethodPost && r.Method != http.MethodPatch {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
@@ -1213,12 +1763,22 @@ func (s *Server) handleRenameConversation(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	conversation, err := s.db.UpdateConversationSlug(ctx, conversationID, sanitized)
-	if err != nil {
+	if _, err := slug.SetUnique(ctx, s.db, conversationID, sanitized); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Conversation not found", http.StatusNotFound)
+			return
+		}
 		s.logger.Error("Failed to rename conversation", "conversationID", conversationID, "error", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
+	conversation, err := s.db.GetConversationByID(ctx, conversationID)
+	if err != nil {
+		s.logger.Error("Failed to get renamed conversation", "conversationID", conversationID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	go s.broadcastConversationUpdate(context.WithoutCancel(ctx), conversationID)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(conversation)
@@ -0,0 +1,124 @@
+package server
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"shelley.exe.dev/llm"
+	"shelley.exe.dev/models"
+)
+
+// Provider health checks send each configured provider a tiny request
+// every healthCheckInterval, through the first of its models that is
+// available, and remember whether it answered and how fast. The models
+// API reports the health of each model's provider, so the UI can grey
+// out models that would fail instead of letting the send error out.
+
+const (
+	healthCheckInterval = 5 * time.Minute
+	// healthCheckTimeout bounds a check; a provider that takes longer
+	// counts as failing.
+	healthCheckTimeout = 30 * time.Second
+)
+
+// ProviderHealth is the result of a provider's latest health check.
+type ProviderHealth struct {
+	Available bool      `json:"available"`
+	LatencyMs int64     `json:"latency_ms"`
+	Error     string    `json:"error,omitempty"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// modelProvider returns the provider of a model. Models the models
+// package doesn't know are their own provider.
+func modelProvider(modelID string) string {
+	if model := models.ByID(modelID); model != nil {
+		return string(model.Provider)
+	}
+	return modelID
+}
+
+// providerHealth returns the latest health check of a model's provider,
+// or nil if it hasn't been checked.
+func (s *Server) providerHealth(modelID string) *ProviderHealth {
+	s.healthMu.Lock()
+	defer s.healthMu.Unlock()
+	health, ok := s.health[modelProvider(modelID)]
+	if !ok {
+		return nil
+	}
+	return &health
+}
+
+// runHealthChecks checks the providers now and every healthCheckInterval
+// until ctx is done.
+func (s *Server) runHealthChecks(ctx context.Context) {
+	ticker := time.NewTicker(healthCheckInterval)
+	defer ticker.Stop()
+	for {
+		s.checkProviders(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// checkProviders checks each provider with an available model, at once.
+// The built-in models need no checking.
+func (s *Server) checkProviders(ctx context.Context) {
+	var wg sync.WaitGroup
+	checked := make(map[string]bool)
+	for _, modelID := range s.llmManager.GetAvailableModels() {
+		provider := modelProvider(modelID)
+		if provider == string(models.ProviderBuiltIn) || checked[provider] {
+			continue
+		}
+		checked[provider] = true
+		wg.Go(func() {
+			health := s.checkModel(ctx, modelID)
+			if !health.Available {
+				s.logger.Warn("Provider health check failed", "provider", provider, "model", modelID, "error", health.Error)
+			}
+			s.healthMu.Lock()
+			if s.health == nil {
+				s.health = make(map[string]ProviderHealth)
+			}
+			s.health[provider] = health
+			s.healthMu.Unlock()
+		})
+	}
+	wg.Wait()
+}
+
+// checkModel sends a model a one-line request. Services may keep retrying
+// past the timeout, so the check doesn't wait for them to give up.
+func (s *Server) checkModel(ctx context.Context, modelID string) ProviderHealth {
+	start := time.Now()
+	health := ProviderHealth{CheckedAt: start}
+	service, err := s.llmManager.GetService(modelID)
+	if err == nil {
+		ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+		defer cancel()
+		done := make(chan error, 1)
+		go func() {
+			_, err := service.Do(ctx, &llm.Request{
+				Messages: []llm.Message{llm.UserStringMessage("Reply with OK.")},
+			})
+			done <- err
+		}()
+		select {
+		case err = <-done:
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+	}
+	health.LatencyMs = time.Since(start).Milliseconds()
+	health.Available = err == nil
+	if err != nil {
+		health.Error = err.Error()
+	}
+	return health
+}
@@ -0,0 +1,116 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"shelley.exe.dev/claudetool"
+	"shelley.exe.dev/llm"
+	"shelley.exe.dev/loop"
+	"shelley.exe.dev/models"
+)
+
+// failingService fails every request, like a provider that is down.
+type failingService struct{}
+
+func (failingService) Do(context.Context, *llm.Request) (*llm.Response, error) {
+	return nil, errors.New("status 529: overloaded")
+}
+func (failingService) TokenContextWindow() int { return 200000 }
+func (failingService) MaxImageDimension() int  { return 0 }
+
+// healthTestManager serves an Anthropic model that is down and an OpenAI
+// model that works.
+type healthTestManager struct{}
+
+func (healthTestManager) GetService(modelID string) (llm.Service, error) {
+	if modelID == "claude-sonnet-4.5" {
+		return failingService{}, nil
+	}
+	return loop.NewPredictableService(), nil
+}
+
+func (healthTestManager) GetAvailableModels() []string {
+	return []string{"claude-sonnet-4.5", "gpt-5", "gpt-5-nano", "predictable"}
+}
+
+func (healthTestManager) HasModel(modelID string) bool {
+	return modelID != ""
+}
+
+func TestProviderHealthChecks(t *testing.T) {
+	database, cleanup := setupTestDB(t)
+	defer cleanup()
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+	s := NewServer(database, healthTestManager{}, claudetool.ToolSetConfig{}, logger, false, "", "claude-sonnet-4.5", "", nil)
+
+	mux := http.NewServeMux()
+	s.RegisterRoutes(mux)
+	models := func() map[string]ModelInfo {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest("GET", "/api/models", nil))
+		var list []ModelInfo
+		if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil {
+			t.Fatalf("unexpected models response %q: %v", w.Body.String(), err)
+		}
+		byID := make(map[string]ModelInfo)
+		for _, m := range list {
+			byID[m.ID] = m
+		}
+		return byID
+	}
+
+	if m := models()["gpt-5"]; m.Health != nil || !m.usable() {
+		t.Errorf("expected an unchecked model to be usable, got %+v", m)
+	}
+	s.checkProviders(t.Context())
+	list := models()
+	if m := list["claude-sonnet-4.5"]; m.Health == nil || m.Health.Available || m.Health.Error == "" || m.usable() {
+		t.Errorf("expected the Anthropic model to be unavailable, got %+v", m.Health)
+	}
+	for _, id := range []string{"gpt-5", "gpt-5-nano"} {
+		if m := list[id]; m.Provider != "OpenAI" || m.Health == nil || !m.Health.Available || m.Health.CheckedAt.IsZero() {
+			t.Errorf("expected %s to be available, got %+v", id, m)
+		}
+	}
+	if _, ok := list["predictable"]; ok {
+		t.Error("expected the predictable model to be left out")
+	}
+}
+
+func TestModelsCapabilities(t *testing.T) {
+	database, cleanup := setupTestDB(t)
+	defer cleanup()
+	manager, err := models.NewManager(&models.Config{AnthropicAPIKey: "test", FireworksAPIKey: "test"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+	s := NewServer(database, manager, claudetool.ToolSetConfig{}, logger, false, "", "", "", nil)
+
+	w := httptest.NewRecorder()
+	s.handleModels(w, httptest.NewRequest("GET", "/api/models", nil))
+	var list []ModelInfo
+	json.Unmarshal(w.Body.Bytes(), &list)
+	byID := make(map[string]ModelInfo)
+	for _, m := range list {
+		byID[m.ID] = m
+	}
+
+	haiku := byID["claude-haiku-4.5"]
+	if !haiku.Ready || haiku.Provider != "Anthropic" || !haiku.Vision || !haiku.Tools || haiku.Pricing == nil || haiku.Pricing.Output != 5 || haiku.MaxContextTokens == 0 {
+		t.Errorf("unexpected haiku: %+v", haiku)
+	}
+	if qwen := byID["qwen3-coder-fireworks"]; !qwen.Ready || qwen.Vision || !qwen.Tools || qwen.Pricing == nil {
+		t.Errorf("unexpected qwen: %+v", qwen)
+	}
+	if gpt, ok := byID["gpt-5"]; ok {
+		t.Errorf("expected models without a key to be left out, got %+v", gpt)
+	}
+}
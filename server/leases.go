@@ -0,0 +1,119 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+)
+
+// Several Shelley instances can share a database, e.g. during a rolling
+// restart. Only the instance holding a conversation's lease (see
+// db.AcquireConversationLease) runs its agent loop or recovers it, so that a
+// turn never runs twice. An instance takes the lease when it starts the
+// conversation's loop, renews it while the loop exists, and releases it when
+// the loop stops or the instance shuts down. The lease of an instance that
+// died expires after conversationLeaseTTL.
+
+// conversationLeaseTTL is how long a lease lasts unless it's renewed.
+const conversationLeaseTTL = 30 * time.Second
+
+// leaseRenewInterval is how often the leases of running conversations are
+// renewed.
+const leaseRenewInterval = conversationLeaseTTL / 3
+
+// errConversationOwned refuses to run a conversation another instance runs.
+var errConversationOwned = errors.New("the conversation is running on another Shelley instance")
+
+// newInstanceID returns an ID for the instance, unique among those sharing
+// the database.
+func newInstanceID() string {
+	host, _ := os.Hostname()
+	b := make([]byte, 4)
+	rand.Read(b)
+	return fmt.Sprintf("%s-%d-%s", host, os.Getpid(), hex.EncodeToString(b))
+}
+
+// acquireLease takes or renews the instance's lease of a conversation. It
+// returns errConversationOwned if another instance holds it.
+func (s *Server) acquireLease(ctx context.Context, conversationID string) error {
+	ok, err := s.db.AcquireConversationLease(ctx, conversationID, s.instanceID, conversationLeaseTTL)
+	if err != nil {
+		return fmt.Errorf("failed to acquire conversation lease: %w", err)
+	}
+	if !ok {
+		return errConversationOwned
+	}
+	return nil
+}
+
+// releaseLease gives up the instance's lease of a conversation.
+func (s *Server) releaseLease(conversationID string) {
+	if err := s.db.ReleaseConversationLease(context.Background(), conversationID, s.instanceID); err != nil {
+		s.logger.Warn("Failed to release conversation lease", "conversationID", conversationID, "error", err)
+	}
+}
+
+// leasedElsewhere reports whether another instance holds a conversation's
+// lease, for requests to refuse before they change the conversation.
+func (s *Server) leasedElsewhere(ctx context.Context, conversationID string) bool {
+	lease, err := s.db.GetConversationLease(ctx, conversationID)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			s.logger.Warn("Failed to get conversation lease", "conversationID", conversationID, "error", err)
+		}
+		return false
+	}
+	return lease.Owner != s.instanceID && lease.ExpiresAt > time.Now().UnixMilli()
+}
+
+// refuseIfLeasedElsewhere answers a request that would run a conversation
+// with 409 if another instance runs it, and reports whether it did.
+func (s *Server) refuseIfLeasedElsewhere(w http.ResponseWriter, r *http.Request, conversationID string) bool {
+	if !s.leasedElsewhere(r.Context(), conversationID) {
+		return false
+	}
+	http.Error(w, errConversationOwned.Error(), http.StatusConflict)
+	return true
+}
+
+// runLeaseRenewal renews the leases of the conversations whose loop runs in
+// this instance. A conversation whose lease was lost, because the instance
+// didn't renew it in time and another took it, is stopped here.
+func (s *Server) runLeaseRenewal(ctx context.Context) {
+	ticker := time.NewTicker(leaseRenewInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		s.mu.Lock()
+		managers := make([]*ConversationManager, 0, len(s.activeConversations))
+		for _, manager := range s.activeConversations {
+			managers = append(managers, manager)
+		}
+		s.mu.Unlock()
+		for _, manager := range managers {
+			manager.mu.Lock()
+			running := manager.loop != nil
+			manager.mu.Unlock()
+			if !running {
+				continue
+			}
+			err := s.acquireLease(ctx, manager.conversationID)
+			if errors.Is(err, errConversationOwned) {
+				s.logger.Warn("Lost conversation lease to another instance; stopping its loop", "conversationID", manager.conversationID)
+				manager.Reset()
+			} else if err != nil {
+				s.logger.Warn("Failed to renew conversation lease", "conversationID", manager.conversationID, "error", err)
+			}
+		}
+	}
+}
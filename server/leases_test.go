@@ -0,0 +1,105 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"shelley.exe.dev/claudetool"
+)
+
+// otherInstance returns a second server sharing the harness's database, as
+// during a rolling restart.
+func (h *TestHarness) otherInstance() *Server {
+	return NewServer(h.db, h.server.llmManager, claudetool.ToolSetConfig{}, h.server.logger, true, "", "predictable", "", nil)
+}
+
+func chatOn(s *Server, conversationID, msg string) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(ChatRequest{Message: msg, Model: "predictable"})
+	w := httptest.NewRecorder()
+	s.handleChatConversation(w, httptest.NewRequest("POST", "/api/conversation/"+conversationID+"/chat", strings.NewReader(string(body))), conversationID)
+	return w
+}
+
+func TestConversationLeaseRefusesOtherInstance(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+	h.NewConversation("echo: hi", t.TempDir())
+	h.WaitResponse()
+	other := h.otherInstance()
+
+	// The conversation's loop runs on the first instance
+	if w := chatOn(other, h.convID, "echo: elsewhere"); w.Code != http.StatusConflict {
+		t.Fatalf("expected a message to a conversation running elsewhere refused, got %d: %s", w.Code, w.Body.String())
+	}
+	if n := h.userTexts("echo: elsewhere"); n != 0 {
+		t.Errorf("expected the refused message not recorded, got %d", n)
+	}
+
+	// Once its loop stops there, the other instance takes over
+	h.server.mu.Lock()
+	h.server.activeConversations[h.convID].stopLoop()
+	h.server.mu.Unlock()
+	if w := chatOn(other, h.convID, "echo: taken over"); w.Code != http.StatusAccepted {
+		t.Fatalf("expected the other instance to run the conversation, got %d: %s", w.Code, w.Body.String())
+	}
+	h.WaitResponse()
+	lease, err := h.db.GetConversationLease(context.Background(), h.convID)
+	if err != nil || lease.Owner != other.instanceID {
+		t.Fatalf("expected the other instance to hold the lease, got %+v, %v", lease, err)
+	}
+
+	// The first instance reloads the turns the other ran before it runs the
+	// conversation again
+	other.mu.Lock()
+	other.activeConversations[h.convID].stopLoop()
+	other.mu.Unlock()
+	h.Chat("echo: back")
+	h.WaitResponse()
+	var sent []string
+	for _, msg := range h.llm.GetLastRequest().Messages {
+		for _, c := range msg.Content {
+			sent = append(sent, c.Text)
+		}
+	}
+	if !strings.Contains(strings.Join(sent, "\n"), "taken over") {
+		t.Errorf("expected the request to include the other instance's turn, got %q", sent)
+	}
+}
+
+func TestRecoveryRefusedWhileLeasedElsewhere(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+	h.NewConversation("echo: hi", t.TempDir())
+	h.WaitResponse()
+	h.interruptConversation()
+
+	other := h.otherInstance()
+	if err := other.acquireLease(context.Background(), h.convID); err != nil {
+		t.Fatal(err)
+	}
+	conv, err := h.db.GetConversationByID(context.Background(), h.convID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := h.messages()
+	if err := h.server.recoverConversation(context.Background(), *conv, before); !errors.Is(err, errConversationOwned) {
+		t.Fatalf("expected recovery refused, got %v", err)
+	}
+	if after := h.messages(); len(after) != len(before) {
+		t.Errorf("expected no tool results recorded, got %d messages, want %d", len(after), len(before))
+	}
+
+	// The lease released, recovery proceeds
+	other.releaseLease(h.convID)
+	if err := h.server.recoverConversation(context.Background(), *conv, before); err != nil {
+		t.Fatal(err)
+	}
+	if result := h.WaitToolResult(); !strings.Contains(result, "interrupted by server restart") {
+		t.Errorf("expected interrupted tool result, got %q", result)
+	}
+}
@@ -1,6 +1,10 @@
 package server
 
-import "log/slog"
+import (
+	"log/slog"
+
+	"shelley.exe.dev/models"
+)
 
 // Link represents a custom link to be displayed in the UI
 type Link struct {
@@ -29,5 +33,14 @@ type LLMConfig struct {
 	// Links are custom links to be displayed in the UI (optional)
 	Links []Link
 
+	// ModelLimits caps concurrency and request/token rates per model ID (optional).
+	// The "*" entry applies to models without their own entry.
+	ModelLimits map[string]models.Limits
+
+	// PredictableFixture and PredictableRecordModel make the predictable
+	// model replay or record a fixture file, as in models.Config (optional)
+	PredictableFixture     string
+	PredictableRecordModel string
+
 	Logger *slog.Logger
 }
@@ -0,0 +1,161 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"shelley.exe.dev/db"
+	"shelley.exe.dev/gitstate"
+)
+
+// memoryProject returns the key memories are stored under for a working directory:
+// the git origin if there is one, otherwise the directory itself.
+func memoryProject(cwd string) string {
+	if cwd == "" {
+		return ""
+	}
+	if origin := gitstate.GetGitOrigin(cwd); origin != "" {
+		return origin
+	}
+	return cwd
+}
+
+// loadMemories returns the contents of all memories saved for the project of cwd.
+func loadMemories(ctx context.Context, database *db.DB, cwd string) ([]string, error) {
+	project := memoryProject(cwd)
+	if project == "" {
+		return nil, nil
+	}
+	memories, err := database.ListMemories(ctx, project)
+	if err != nil {
+		return nil, err
+	}
+	contents := make([]string, len(memories))
+	for i, m := range memories {
+		contents[i] = m.Content
+	}
+	return contents, nil
+}
+
+// saveAgentMemory stores a memory saved by the agent in a conversation working in cwd.
+func saveAgentMemory(ctx context.Context, database *db.DB, conversationID, cwd, content string) error {
+	project := memoryProject(cwd)
+	if project == "" {
+		return fmt.Errorf("no working directory to file the memory under")
+	}
+	_, err := database.CreateMemory(ctx, project, content, db.MemorySourceAgent, &conversationID)
+	return err
+}
+
+// CreateMemoryRequest is the body of POST /api/memories.
+// Either Project or Cwd identifies the project; Cwd is resolved the same way
+// as for conversations (git origin, falling back to the directory).
+type CreateMemoryRequest struct {
+	Project string `json:"project"`
+	Cwd     string `json:"cwd"`
+	Content string `json:"content"`
+}
+
+// UpdateMemoryRequest is the body of PATCH /api/memories/{id}.
+type UpdateMemoryRequest struct {
+	Content string `json:"content"`
+}
+
+// handleMemories handles GET/POST /api/memories
+func (s *Server) handleMemories(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		project := r.URL.Query().Get("project")
+		if cwd := r.URL.Query().Get("cwd"); project == "" && cwd != "" {
+			project = memoryProject(cwd)
+		}
+		memories, err := s.db.ListMemories(r.Context(), project)
+		if err != nil {
+			s.logger.Error("Failed to list memories", "error", err)
+			http.Error(w, "failed to list memories", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(memories); err != nil {
+			s.logger.Error("Failed to encode memories", "error", err)
+		}
+
+	case http.MethodPost:
+		var req CreateMemoryRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid JSON", http.StatusBadRequest)
+			return
+		}
+		project := req.Project
+		if project == "" {
+			project = memoryProject(req.Cwd)
+		}
+		content := strings.TrimSpace(req.Content)
+		if project == "" || content == "" {
+			http.Error(w, "project (or cwd) and content are required", http.StatusBadRequest)
+			return
+		}
+		memory, err := s.db.CreateMemory(r.Context(), project, content, db.MemorySourceUser, nil)
+		if err != nil {
+			s.logger.Error("Failed to create memory", "error", err)
+			http.Error(w, "failed to create memory", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		if err := json.NewEncoder(w).Encode(memory); err != nil {
+			s.logger.Error("Failed to encode memory", "error", err)
+		}
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleMemory handles PATCH/DELETE /api/memories/{id}
+func (s *Server) handleMemory(w http.ResponseWriter, r *http.Request) {
+	memoryID := r.PathValue("id")
+	switch r.Method {
+	case http.MethodPatch:
+		var req UpdateMemoryRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid JSON", http.StatusBadRequest)
+			return
+		}
+		content := strings.TrimSpace(req.Content)
+		if content == "" {
+			http.Error(w, "content is required", http.StatusBadRequest)
+			return
+		}
+		memory, err := s.db.UpdateMemory(r.Context(), memoryID, content)
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "memory not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			s.logger.Error("Failed to update memory", "memoryID", memoryID, "error", err)
+			http.Error(w, "failed to update memory", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(memory); err != nil {
+			s.logger.Error("Failed to encode memory", "error", err)
+		}
+
+	case http.MethodDelete:
+		if err := s.db.DeleteMemory(r.Context(), memoryID); err != nil {
+			s.logger.Error("Failed to delete memory", "memoryID", memoryID, "error", err)
+			http.Error(w, "failed to delete memory", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
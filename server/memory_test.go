@@ -0,0 +1,115 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"shelley.exe.dev/db"
+	"shelley.exe.dev/db/generated"
+)
+
+func TestRememberToolInjectsIntoNewConversation(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+
+	dir := t.TempDir()
+	h.NewConversation("remember: run tests with make check", dir)
+	if result := h.WaitToolResult(); result != "remembered" {
+		t.Fatalf("expected tool result 'remembered', got %q", result)
+	}
+
+	memories, err := h.db.ListMemories(context.Background(), dir)
+	if err != nil {
+		t.Fatalf("failed to list memories: %v", err)
+	}
+	if len(memories) != 1 {
+		t.Fatalf("expected 1 memory, got %d", len(memories))
+	}
+	if memories[0].Content != "run tests with make check" || memories[0].Source != string(db.MemorySourceAgent) {
+		t.Errorf("unexpected memory: %+v", memories[0])
+	}
+
+	h.NewConversation("echo: hi", dir)
+	h.WaitResponse()
+	if system := h.systemPrompt(); !strings.Contains(system, "run tests with make check") {
+		t.Errorf("expected memory in system prompt, got:\n%s", system)
+	}
+
+	h.NewConversation("echo: hi", t.TempDir())
+	h.WaitResponse()
+	if system := h.systemPrompt(); strings.Contains(system, "<memories>") {
+		t.Errorf("expected no memories for another project, got:\n%s", system)
+	}
+}
+
+func (h *TestHarness) systemPrompt() string {
+	h.t.Helper()
+	msgs, err := h.db.ListMessagesByType(context.Background(), h.convID, db.MessageTypeSystem)
+	if err != nil {
+		h.t.Fatalf("failed to list system messages: %v", err)
+	}
+	if len(msgs) != 1 || msgs[0].LlmData == nil {
+		h.t.Fatalf("expected 1 system message, got %d", len(msgs))
+	}
+	return string(*msgs[0].LlmData)
+}
+
+func TestMemoriesAPI(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+
+	mux := http.NewServeMux()
+	h.server.RegisterRoutes(mux)
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w
+	}
+
+	dir := t.TempDir()
+	w := do("POST", "/api/memories", `{"cwd":"`+dir+`","content":"  prefers tabs  "}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create: expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var created generated.Memory
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+		t.Fatalf("failed to parse memory: %v", err)
+	}
+	if created.Project != dir || created.Content != "prefers tabs" || created.Source != string(db.MemorySourceUser) {
+		t.Errorf("unexpected memory: %+v", created)
+	}
+
+	if w := do("POST", "/api/memories", `{"content":"no project"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("create without project: expected 400, got %d", w.Code)
+	}
+
+	w = do("PATCH", "/api/memories/"+created.MemoryID, `{"content":"prefers spaces"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("update: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := do("PATCH", "/api/memories/missing", `{"content":"x"}`); w.Code != http.StatusNotFound {
+		t.Errorf("update missing: expected 404, got %d", w.Code)
+	}
+
+	w = do("GET", "/api/memories?cwd="+dir, "")
+	var listed []generated.Memory
+	if err := json.Unmarshal(w.Body.Bytes(), &listed); err != nil {
+		t.Fatalf("failed to parse memories: %v", err)
+	}
+	if len(listed) != 1 || listed[0].Content != "prefers spaces" {
+		t.Fatalf("unexpected memories: %+v", listed)
+	}
+
+	if w := do("DELETE", "/api/memories/"+created.MemoryID, ""); w.Code != http.StatusNoContent {
+		t.Fatalf("delete: expected 204, got %d", w.Code)
+	}
+	w = do("GET", "/api/memories", "")
+	if strings.TrimSpace(w.Body.String()) != "[]" {
+		t.Errorf("expected no memories after delete, got %s", w.Body.String())
+	}
+}
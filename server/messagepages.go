@@ -0,0 +1,117 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"shelley.exe.dev/db/generated"
+)
+
+// maxMessagePage caps how many messages one page of a conversation holds.
+const maxMessagePage = 1000
+
+// errBadMessagePage is wrapped by the errors describing an invalid page
+// request, which are the client's fault.
+var errBadMessagePage = errors.New("invalid message page")
+
+// messagePage selects part of a conversation's messages: the limit messages
+// before or after the message with the cursor ID, or the latest limit
+// messages without one. A zero limit selects them all.
+type messagePage struct {
+	limit  int64
+	before string
+	after  string
+}
+
+// parseMessagePage reads a page from the limit, before and after query
+// parameters.
+func parseMessagePage(r *http.Request) (messagePage, error) {
+	query := r.URL.Query()
+	page := messagePage{before: query.Get("before"), after: query.Get("after")}
+	if page.before != "" && page.after != "" {
+		return page, fmt.Errorf("%w: before and after are exclusive", errBadMessagePage)
+	}
+	if s := query.Get("limit"); s != "" {
+		limit, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || limit <= 0 {
+			return page, fmt.Errorf("%w: limit must be a positive number", errBadMessagePage)
+		}
+		page.limit = min(limit, maxMessagePage)
+	}
+	if page.limit == 0 && (page.before != "" || page.after != "") {
+		page.limit = maxMessagePage
+	}
+	return page, nil
+}
+
+// tail reports whether the page ends with the conversation's latest message,
+// so the state derived from its last messages is the conversation's.
+func (p messagePage) tail(hasMore bool) bool {
+	return p.before == "" && (p.after == "" || !hasMore)
+}
+
+// listMessagePage returns the page's messages in order, and whether there
+// are more in the direction it reads: earlier ones, or later ones for pages
+// after a cursor.
+func listMessagePage(ctx context.Context, q *generated.Queries, conversationID string, page messagePage) ([]generated.Message, bool, error) {
+	if page.limit == 0 {
+		messages, err := q.ListMessages(ctx, conversationID)
+		return messages, false, err
+	}
+
+	cursor := func(id string) (int64, error) {
+		msg, err := q.GetMessage(ctx, id)
+		if errors.Is(err, sql.ErrNoRows) || (err == nil && msg.ConversationID != conversationID) {
+			return 0, fmt.Errorf("%w: message %s isn't in this conversation", errBadMessagePage, id)
+		}
+		return msg.SequenceID, err
+	}
+
+	if page.after != "" {
+		seq, err := cursor(page.after)
+		if err != nil {
+			return nil, false, err
+		}
+		messages, err := q.ListMessagesAfter(ctx, generated.ListMessagesAfterParams{
+			ConversationID: conversationID,
+			SequenceID:     seq,
+			Limit:          page.limit + 1,
+		})
+		if err != nil {
+			return nil, false, err
+		}
+		hasMore := int64(len(messages)) > page.limit
+		if hasMore {
+			messages = messages[:page.limit]
+		}
+		return messages, hasMore, nil
+	}
+
+	seq := int64(math.MaxInt64)
+	if page.before != "" {
+		var err error
+		if seq, err = cursor(page.before); err != nil {
+			return nil, false, err
+		}
+	}
+	messages, err := q.ListMessagesBefore(ctx, generated.ListMessagesBeforeParams{
+		ConversationID: conversationID,
+		SequenceID:     seq,
+		Limit:          page.limit + 1,
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	hasMore := int64(len(messages)) > page.limit
+	if hasMore {
+		messages = messages[:page.limit]
+	}
+	slices.Reverse(messages)
+	return messages, hasMore, nil
+}
@@ -0,0 +1,108 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"shelley.exe.dev/db"
+)
+
+func (h *TestHarness) messagePage(query string) (*httptest.ResponseRecorder, StreamResponse) {
+	h.t.Helper()
+	req := httptest.NewRequest("GET", "/api/conversation/"+h.convID+query, nil)
+	w := httptest.NewRecorder()
+	h.server.handleGetConversation(w, req, h.convID)
+	var resp StreamResponse
+	if w.Code == http.StatusOK {
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			h.t.Fatal(err)
+		}
+	}
+	return w, resp
+}
+
+func TestMessagePages(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+
+	h.NewConversation("echo: first", "")
+	h.WaitResponse()
+	h.Chat("echo: second")
+	h.WaitResponse()
+	all := h.messages()
+	if len(all) < 4 {
+		t.Fatalf("expected at least 4 messages, got %d", len(all))
+	}
+	ids := func(resp StreamResponse) []string {
+		var ids []string
+		for _, msg := range resp.Messages {
+			ids = append(ids, msg.MessageID)
+		}
+		return ids
+	}
+	expect := func(resp StreamResponse, from, to int, hasMore bool) {
+		t.Helper()
+		got := ids(resp)
+		if len(got) != to-from {
+			t.Fatalf("expected messages %d to %d, got %d messages", from, to, len(got))
+		}
+		for i, id := range got {
+			if id != all[from+i].MessageID {
+				t.Errorf("expected message %d at %d, got %s", from+i, i, id)
+			}
+		}
+		if resp.HasMore != hasMore {
+			t.Errorf("expected has_more %v, got %v", hasMore, resp.HasMore)
+		}
+	}
+
+	// Without a page, every message
+	_, resp := h.messagePage("")
+	expect(resp, 0, len(all), false)
+
+	// The latest messages, then the ones before them
+	n := len(all)
+	_, resp = h.messagePage("?limit=2")
+	expect(resp, n-2, n, true)
+	if resp.ContextWindowSize == 0 {
+		t.Error("expected the latest page to carry the context window size")
+	}
+	_, resp = h.messagePage("?limit=2&before=" + resp.Messages[0].MessageID)
+	expect(resp, n-4, n-2, n > 4)
+	if resp.ContextWindowSize != 0 || resp.AgentWorking {
+		t.Error("expected an earlier page to leave the conversation's state unset")
+	}
+	_, resp = h.messagePage("?limit=100&before=" + all[1].MessageID)
+	expect(resp, 0, 1, false)
+
+	// Reading forward
+	_, resp = h.messagePage("?limit=2&after=" + all[0].MessageID)
+	expect(resp, 1, 3, n > 3)
+	_, resp = h.messagePage("?after=" + all[n-2].MessageID)
+	expect(resp, n-1, n, false)
+
+	// Cursors must be messages of the conversation
+	ctx := context.Background()
+	conv, err := h.db.CreateConversation(ctx, nil, true, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	elsewhere, err := h.db.CreateMessage(ctx, db.CreateMessageParams{ConversationID: conv.ConversationID, Type: db.MessageTypeUser})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, query := range []string{
+		"?limit=0",
+		"?limit=x",
+		"?before=a&after=b",
+		"?before=missing",
+		"?after=" + elsewhere.MessageID,
+	} {
+		if w, _ := h.messagePage(query); w.Code != http.StatusBadRequest {
+			t.Errorf("expected %s to be refused, got %d: %s", query, w.Code, w.Body.String())
+		}
+	}
+}
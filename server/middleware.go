@@ -22,14 +22,17 @@ func LoggerMiddleware(logger *slog.Logger) func(http.Handler) http.Handler {
 }
 
 // CSRFMiddleware protects against CSRF attacks by requiring the X-Shelley-Request header
-// on state-changing requests (POST, PUT, DELETE). This works because browsers will not
+// on state-changing requests (POST, PUT, PATCH, DELETE). This works because browsers will not
 // add custom headers to simple cross-origin requests, and CORS preflight will block
 // complex requests from other origins that don't have explicit permission.
 func CSRFMiddleware() func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			// Only check state-changing methods
-			if r.Method == http.MethodPost || r.Method == http.MethodPut || r.Method == http.MethodDelete {
+			// Only check state-changing methods. Webhooks from chat platforms
+			// can't send the header; they verify their own signatures. Nor
+			// can the previewed apps, which aren't Shelley's to protect and
+			// are sandboxed away from its origin (see previewCSP).
+			if !strings.HasPrefix(r.URL.Path, "/hooks/") && !strings.HasPrefix(r.URL.Path, "/preview/") && (r.Method == http.MethodPost || r.Method == http.MethodPut || r.Method == http.MethodPatch || r.Method == http.MethodDelete) {
 				// Require X-Shelley-Request header (value doesn't matter, just presence)
 				if r.Header.Get("X-Shelley-Request") == "" {
 					http.Error(w, "CSRF protection: X-Shelley-Request header required", http.StatusForbidden)
@@ -46,8 +49,8 @@ func CSRFMiddleware() func(http.Handler) http.Handler {
 func RequireHeaderMiddleware(headerName string) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			// Only check API routes
-			if strings.HasPrefix(r.URL.Path, "/api/") {
+			// Only check API routes, and the previews of local ports
+			if strings.HasPrefix(r.URL.Path, "/api/") || strings.HasPrefix(r.URL.Path, "/preview/") {
 				if r.Header.Get(headerName) == "" {
 					http.Error(w, "missing required header: "+headerName, http.StatusForbidden)
 					return
@@ -58,6 +61,15 @@ func RequireHeaderMiddleware(headerName string) func(http.Handler) http.Handler
 	}
 }
 
+// userID returns the identity of the requesting user: the value of the header
+// configured with --require-header, or "" when no such header is required.
+func (s *Server) userID(r *http.Request) string {
+	if s.requireHeader == "" {
+		return ""
+	}
+	return r.Header.Get(s.requireHeader)
+}
+
 // gzipResponseWriter wraps http.ResponseWriter to compress responses
 type gzipResponseWriter struct {
 	http.ResponseWriter
@@ -70,6 +70,21 @@ func TestCSRFMiddleware_BlocksPutWithoutHeader(t *testing.T) {
 	}
 }
 
+func TestCSRFMiddleware_BlocksPatchWithoutHeader(t *testing.T) {
+	handler := CSRFMiddleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	req := httptest.NewRequest("PATCH", "/api/test", nil)
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusForbidden {
+		t.Errorf("expected status 403 for PATCH without X-Shelley-Request, got %d", w.Code)
+	}
+}
+
 func TestCSRFMiddleware_BlocksDeleteWithoutHeader(t *testing.T) {
 	handler := CSRFMiddleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
@@ -199,3 +214,18 @@ func TestGzipHandler_SkipsWhenNoAcceptEncoding(t *testing.T) {
 		t.Errorf("body doesn't contain expected content: %s", w.Body.String())
 	}
 }
+
+func TestCSRFMiddleware_AllowsHooksWithoutHeader(t *testing.T) {
+	handler := CSRFMiddleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	req := httptest.NewRequest("POST", "/hooks/slack/events", nil)
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status 200 for POST to a hook without X-Shelley-Request, got %d", w.Code)
+	}
+}
@@ -0,0 +1,20 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// handleMigrations handles GET /api/admin/migrations: the database schema's
+// version, and the migrations applied, pending, or applied by a newer build.
+// They're run with "shelley migrate".
+func (s *Server) handleMigrations(w http.ResponseWriter, r *http.Request) {
+	status, err := s.db.MigrationStatus(r.Context())
+	if err != nil {
+		s.logger.Error("Failed to get migration status", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
@@ -0,0 +1,33 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"shelley.exe.dev/db"
+)
+
+func TestHandleMigrations(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+	mux := http.NewServeMux()
+	h.server.RegisterRoutes(mux)
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/api/admin/migrations", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var status db.MigrationStatus
+	if err := json.Unmarshal(w.Body.Bytes(), &status); err != nil {
+		t.Fatal(err)
+	}
+	if status.Version == 0 || status.Version != status.Latest || status.Pending != 0 || len(status.Migrations) == 0 {
+		t.Errorf("expected every migration applied, got version %d of %d, %d pending", status.Version, status.Latest, status.Pending)
+	}
+	if last := status.Migrations[len(status.Migrations)-1]; !last.Applied || last.AppliedAt == nil {
+		t.Errorf("expected the latest migration applied, got %+v", last)
+	}
+}
@@ -0,0 +1,235 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"shelley.exe.dev/db"
+	"shelley.exe.dev/db/generated"
+	"shelley.exe.dev/notify"
+)
+
+// maxNotificationMessage caps the text of a notification, which services
+// like Pushover limit.
+const maxNotificationMessage = 1000
+
+// NotificationSettings configures notifications about conversations, sent
+// through every service that is set.
+type NotificationSettings struct {
+	// Events are the kinds of events to notify about; empty means all.
+	// Conversations can override this.
+	Events []notify.Kind `json:"events,omitempty"`
+	// BaseURL is the address Shelley is reached at, e.g.
+	// "https://shelley.example.com", used to link to conversations.
+	BaseURL  string           `json:"baseUrl,omitempty"`
+	Ntfy     *notify.Ntfy     `json:"ntfy,omitempty"`
+	Pushover *notify.Pushover `json:"pushover,omitempty"`
+	Email    *notify.Email    `json:"email,omitempty"`
+	Slack    *notify.Slack    `json:"slack,omitempty"`
+}
+
+// ConversationNotificationsRequest is the body of POST
+// /api/conversation/<id>/notifications. Events replaces the global events
+// for the conversation, an empty list turning notifications off; nil
+// follows the global setting.
+type ConversationNotificationsRequest struct {
+	Events []notify.Kind `json:"events"`
+}
+
+// validateKinds checks that each kind is a known notify.Kind.
+func validateKinds(kinds []notify.Kind) error {
+	for _, kind := range kinds {
+		if !slices.Contains(notify.Kinds, kind) {
+			return fmt.Errorf("unknown notification event: %s", kind)
+		}
+	}
+	return nil
+}
+
+// validate checks that the configured services have what they need.
+func (n *NotificationSettings) validate() error {
+	if n == nil {
+		return nil
+	}
+	if err := validateKinds(n.Events); err != nil {
+		return err
+	}
+	if n.BaseURL != "" {
+		if u, err := url.Parse(n.BaseURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return fmt.Errorf("invalid notification baseUrl %q: must be an http(s) URL", n.BaseURL)
+		}
+	}
+	if n.Ntfy != nil && n.Ntfy.Topic == "" {
+		return fmt.Errorf("ntfy notifications need a topic")
+	}
+	if n.Pushover != nil && (n.Pushover.Token == "" || n.Pushover.User == "") {
+		return fmt.Errorf("pushover notifications need a token and a user key")
+	}
+	if n.Email != nil && (n.Email.Host == "" || n.Email.From == "" || len(n.Email.To) == 0) {
+		return fmt.Errorf("email notifications need a host, a from address and at least one to address")
+	}
+	if n.Slack != nil && !strings.HasPrefix(n.Slack.WebhookURL, "https://") {
+		return fmt.Errorf("slack notifications need an https webhookUrl")
+	}
+	return nil
+}
+
+// notifiers returns the configured services.
+func (n *NotificationSettings) notifiers() []notify.Notifier {
+	if n == nil {
+		return nil
+	}
+	var notifiers []notify.Notifier
+	if n.Ntfy != nil {
+		notifiers = append(notifiers, n.Ntfy)
+	}
+	if n.Pushover != nil {
+		notifiers = append(notifiers, n.Pushover)
+	}
+	if n.Email != nil {
+		notifiers = append(notifiers, n.Email)
+	}
+	if n.Slack != nil {
+		notifiers = append(notifiers, n.Slack)
+	}
+	return notifiers
+}
+
+// conversationNotifyEvents returns the events a conversation is notified
+// about, or nil if it follows the global setting.
+func conversationNotifyEvents(conv generated.Conversation) []notify.Kind {
+	if conv.NotifyEvents == nil {
+		return nil
+	}
+	kinds := []notify.Kind{}
+	if err := json.Unmarshal([]byte(*conv.NotifyEvents), &kinds); err != nil {
+		return nil
+	}
+	return kinds
+}
+
+// notifyKind returns the kind of notification a recorded message calls
+// for, if any: agent messages ending the turn and errors.
+func notifyKind(msg *generated.Message) (notify.Kind, bool) {
+	if !isEndOfTurn(msg) {
+		return "", false
+	}
+	switch db.MessageType(msg.Type) {
+	case db.MessageTypeAgent:
+		return notify.KindTurnEnd, true
+	case db.MessageTypeError:
+		return notify.KindError, true
+	}
+	return "", false
+}
+
+// notifyConversation sends a notification about a conversation, if any
+// services are configured and the conversation is notified about kind.
+// Failures are logged.
+func (s *Server) notifyConversation(ctx context.Context, conversationID string, kind notify.Kind, message string) {
+	settings, err := GetSettings(ctx, s.db)
+	if err != nil {
+		s.logger.Warn("Failed to get settings for notification", "error", err)
+		return
+	}
+	notifiers := settings.Notifications.notifiers()
+	if len(notifiers) == 0 {
+		return
+	}
+	conversation, err := s.db.GetConversationByID(ctx, conversationID)
+	if err != nil {
+		s.logger.Warn("Failed to get conversation for notification", "conversationID", conversationID, "error", err)
+		return
+	}
+	// A conversation's own list is exact; an empty global list means all
+	if events := conversationNotifyEvents(*conversation); events != nil {
+		if !slices.Contains(events, kind) {
+			return
+		}
+	} else if events := settings.Notifications.Events; len(events) > 0 && !slices.Contains(events, kind) {
+		return
+	}
+
+	if len(message) > maxNotificationMessage {
+		message = strings.ToValidUTF8(message[:maxNotificationMessage], "") + "..."
+	}
+	event := notify.Event{
+		Kind:           kind,
+		ConversationID: conversationID,
+		Message:        message,
+	}
+	if conversation.Slug != nil {
+		event.Title = *conversation.Slug
+	}
+	if base := settings.Notifications.BaseURL; base != "" {
+		event.URL = strings.TrimRight(base, "/") + "/c/" + conversationID
+	}
+	if err := notify.Send(ctx, notifiers, event); err != nil {
+		s.logger.Warn("Failed to send notification", "conversationID", conversationID, "kind", kind, "error", err)
+	}
+}
+
+// handleConversationNotifications handles GET and POST
+// /conversation/<id>/notifications, the events the conversation sends
+// notifications about.
+func (s *Server) handleConversationNotifications(w http.ResponseWriter, r *http.Request, conversationID string) {
+	ctx := r.Context()
+	var conversation generated.Conversation
+	err := s.db.Queries(ctx, func(q *generated.Queries) error {
+		var err error
+		conversation, err = q.GetConversation(ctx, conversationID)
+		return err
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Conversation not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		s.logger.Error("Failed to get conversation", "conversationID", conversationID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(ConversationNotificationsRequest{Events: conversationNotifyEvents(conversation)})
+	case http.MethodPost:
+		var req ConversationNotificationsRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+		if err := validateKinds(req.Events); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var data *string
+		if req.Events != nil {
+			encoded, err := json.Marshal(req.Events)
+			if err != nil {
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+			str := string(encoded)
+			data = &str
+		}
+		if err := s.db.UpdateConversationNotifyEvents(ctx, conversationID, data); err != nil {
+			s.logger.Error("Failed to update conversation notifications", "conversationID", conversationID, "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		go s.broadcastConversationUpdate(context.WithoutCancel(ctx), conversationID)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(req)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
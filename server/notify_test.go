@@ -0,0 +1,85 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// notification is a request received by a fake ntfy server.
+type notification struct {
+	title string
+	body  string
+}
+
+func TestNotifications(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+
+	received := make(chan notification, 10)
+	ntfy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- notification{title: r.Header.Get("Title"), body: string(body)}
+	}))
+	defer ntfy.Close()
+
+	settings := `{"notifications": {"ntfy": {"server": "` + ntfy.URL + `", "topic": "shelley"}}}`
+	w := httptest.NewRecorder()
+	h.server.handleSettings(w, httptest.NewRequest("POST", "/api/settings", strings.NewReader(settings)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("save settings: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	wait := func() notification {
+		t.Helper()
+		select {
+		case n := <-received:
+			return n
+		case <-time.After(h.timeout):
+			t.Fatal("timed out waiting for a notification")
+			return notification{}
+		}
+	}
+
+	h.NewConversation("echo: all done", "")
+	h.WaitResponse()
+	if n := wait(); !strings.HasSuffix(n.title, " finished") || n.body != "all done" {
+		t.Errorf("unexpected turn end notification %+v", n)
+	}
+
+	// Turn this conversation's notifications off
+	w = httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/api/conversation/"+h.convID+"/notifications", strings.NewReader(`{"events": []}`))
+	h.server.handleConversationNotifications(w, req, h.convID)
+	if w.Code != http.StatusOK {
+		t.Fatalf("set notifications: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	h.Chat("echo: quietly")
+	h.WaitResponse()
+	select {
+	case n := <-received:
+		t.Errorf("expected no notification, got %+v", n)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	// Other conversations still follow the global setting
+	h.NewConversation("ask: Which database? | sqlite | postgres", t.TempDir())
+	if n := wait(); !strings.HasSuffix(n.title, " needs your answer") || n.body != "Which database?\n\nsqlite / postgres" {
+		t.Errorf("unexpected question notification %+v", n)
+	}
+
+	for _, body := range []string{
+		`{"notifications": {"events": ["sometimes"]}}`,
+		`{"notifications": {"ntfy": {}}}`,
+		`{"notifications": {"slack": {"webhookUrl": "http://example.com"}}}`,
+	} {
+		w := httptest.NewRecorder()
+		h.server.handleSettings(w, httptest.NewRequest("POST", "/api/settings", strings.NewReader(body)))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", body, w.Code)
+		}
+	}
+}
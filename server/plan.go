@@ -0,0 +1,259 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"shelley.exe.dev/claudetool"
+	"shelley.exe.dev/db"
+	"shelley.exe.dev/db/generated"
+)
+
+// A conversation started in plan mode is offered only read-only tools and
+// propose_plan until the user approves the plan the agent proposes. On
+// approval the conversation gets its tools back, along with
+// update_plan_step to record its progress through the plan's steps.
+// Proposing a new plan after approval locks the tools again.
+
+// Plan statuses.
+const (
+	planDrafting = "drafting" // no plan proposed yet
+	planProposed = "proposed"
+	planApproved = "approved"
+)
+
+// planInstructions are added to the first message of a conversation in
+// plan mode.
+const planInstructions = "Before changing anything, investigate and propose a plan with propose_plan, then wait for my approval."
+
+// PlanResponse is a conversation's plan and its progress.
+type PlanResponse struct {
+	// Status is drafting, proposed or approved.
+	Status string           `json:"status"`
+	Plan   *claudetool.Plan `json:"plan"`
+	// Finished counts the steps done or skipped.
+	Finished int `json:"finished"`
+}
+
+// ApprovePlanRequest is the body of POST /api/conversation/<id>/plan/approve.
+type ApprovePlanRequest struct {
+	// Message is passed on to the agent with the approval.
+	Message string `json:"message,omitempty"`
+}
+
+// planningTools returns the allowlist of a conversation while it plans:
+// the read-only tools among allowed, nil meaning available, and
+// propose_plan.
+func planningTools(allowed, available []string) []string {
+	return append(readOnlyAllowed(allowed, available), "propose_plan")
+}
+
+// startPlan puts a new conversation in plan mode. allowed is its
+// allowlist, restored when the plan is approved.
+func (s *Server) startPlan(ctx context.Context, conversationID string, allowed []string) error {
+	var data *string
+	if allowed != nil {
+		encoded, err := json.Marshal(allowed)
+		if err != nil {
+			return err
+		}
+		str := string(encoded)
+		data = &str
+	}
+	if _, err := s.db.CreateConversationPlan(ctx, conversationID, data); err != nil {
+		return err
+	}
+	return s.setConversationAllowedTools(ctx, conversationID, planningTools(allowed, s.toolNames()))
+}
+
+// decodePlan returns the plan and the allowlist to restore on approval.
+func decodePlan(row *generated.ConversationPlan) (*claudetool.Plan, []string, error) {
+	var plan *claudetool.Plan
+	if row.Plan != nil {
+		plan = new(claudetool.Plan)
+		if err := json.Unmarshal([]byte(*row.Plan), plan); err != nil {
+			return nil, nil, fmt.Errorf("invalid plan: %w", err)
+		}
+	}
+	var allowed []string
+	if row.AllowedTools != nil {
+		if err := json.Unmarshal([]byte(*row.AllowedTools), &allowed); err != nil {
+			return nil, nil, fmt.Errorf("invalid allowed tools: %w", err)
+		}
+	}
+	return plan, allowed, nil
+}
+
+// proposePlan records the plan the agent proposed. A plan proposed after
+// another was approved locks the conversation's tools again; available
+// are the names of the tools of its tool set.
+func (cm *ConversationManager) proposePlan(ctx context.Context, plan claudetool.Plan, available []string) (string, error) {
+	row, err := cm.db.GetConversationPlan(ctx, cm.conversationID)
+	if err != nil {
+		return "", err
+	}
+	if row.Status == planApproved {
+		_, allowed, err := decodePlan(row)
+		if err != nil {
+			return "", err
+		}
+		locked := planningTools(allowed, available)
+		data, _ := json.Marshal(locked)
+		str := string(data)
+		if err := cm.db.UpdateConversationAllowedTools(ctx, cm.conversationID, &str); err != nil {
+			return "", err
+		}
+		cm.SetAllowedTools(locked)
+	}
+	data, err := json.Marshal(plan)
+	if err != nil {
+		return "", err
+	}
+	if err := cm.db.UpdateConversationPlan(ctx, cm.conversationID, planProposed, string(data)); err != nil {
+		return "", err
+	}
+	return "Plan proposed. Stop here and wait for the user to approve it or ask for changes.", nil
+}
+
+// updatePlanStep records the status of a step of a conversation's
+// approved plan.
+func updatePlanStep(ctx context.Context, database *db.DB, conversationID string, step int, status, note string) (string, error) {
+	row, err := database.GetConversationPlan(ctx, conversationID)
+	if err != nil {
+		return "", err
+	}
+	if row.Status != planApproved {
+		return "", errors.New("the plan hasn't been approved")
+	}
+	plan, _, err := decodePlan(row)
+	if err != nil {
+		return "", err
+	}
+	if step < 1 || step > len(plan.Steps) {
+		return "", fmt.Errorf("the plan has steps 1 to %d, not %d", len(plan.Steps), step)
+	}
+	plan.Steps[step-1].Status = status
+	plan.Steps[step-1].Note = note
+	data, err := json.Marshal(plan)
+	if err != nil {
+		return "", err
+	}
+	if err := database.UpdateConversationPlan(ctx, conversationID, planApproved, string(data)); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Step %d is %s; %d of %d steps finished.", step, status, finishedSteps(plan), len(plan.Steps)), nil
+}
+
+// finishedSteps counts the steps of plan that are done or skipped.
+func finishedSteps(plan *claudetool.Plan) int {
+	n := 0
+	for _, step := range plan.Steps {
+		if step.Status == "done" || step.Status == "skipped" {
+			n++
+		}
+	}
+	return n
+}
+
+// getPlan returns a conversation's plan, writing an error response if it
+// has none.
+func (s *Server) getPlan(w http.ResponseWriter, r *http.Request, conversationID string) (*generated.ConversationPlan, *claudetool.Plan, []string, bool) {
+	row, err := s.db.GetConversationPlan(r.Context(), conversationID)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "The conversation isn't in plan mode", http.StatusNotFound)
+		return nil, nil, nil, false
+	}
+	if err == nil {
+		var plan *claudetool.Plan
+		var allowed []string
+		if plan, allowed, err = decodePlan(row); err == nil {
+			return row, plan, allowed, true
+		}
+	}
+	s.logger.Error("Failed to get conversation plan", "conversationID", conversationID, "error", err)
+	http.Error(w, "Internal server error", http.StatusInternalServerError)
+	return nil, nil, nil, false
+}
+
+// handleConversationPlan handles GET /conversation/<id>/plan.
+func (s *Server) handleConversationPlan(w http.ResponseWriter, r *http.Request, conversationID string) {
+	row, plan, _, ok := s.getPlan(w, r, conversationID)
+	if !ok {
+		return
+	}
+	resp := PlanResponse{Status: row.Status, Plan: plan}
+	if plan != nil {
+		resp.Finished = finishedSteps(plan)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// handleApprovePlan handles POST /conversation/<id>/plan/approve, which
+// approves the proposed plan, gives the conversation its tools back and
+// tells the agent to carry the plan out.
+func (s *Server) handleApprovePlan(w http.ResponseWriter, r *http.Request, conversationID string) {
+	ctx := r.Context()
+	var req ApprovePlanRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+	}
+	row, plan, allowed, ok := s.getPlan(w, r, conversationID)
+	if !ok {
+		return
+	}
+	if row.Status != planProposed {
+		http.Error(w, "There is no proposed plan to approve", http.StatusConflict)
+		return
+	}
+	conversation, err := s.db.GetConversationByID(ctx, conversationID)
+	if err != nil {
+		http.Error(w, "Conversation not found", http.StatusNotFound)
+		return
+	}
+	modelID := s.bridgeModel("")
+	if conversation.ModelID != nil {
+		modelID = *conversation.ModelID
+	}
+	llmService, err := s.llmManager.GetService(modelID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Unsupported model: %s", modelID), http.StatusBadRequest)
+		return
+	}
+
+	if allowed != nil {
+		allowed = append(allowed, "propose_plan", "update_plan_step")
+	}
+	if err := s.setConversationAllowedTools(ctx, conversationID, allowed); err != nil {
+		s.logger.Error("Failed to unlock conversation tools", "conversationID", conversationID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if err := s.db.UpdateConversationPlan(ctx, conversationID, planApproved, *row.Plan); err != nil {
+		s.logger.Error("Failed to approve plan", "conversationID", conversationID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	text := "The plan is approved. Carry it out step by step, marking each step in_progress when you start it and done when it is finished with update_plan_step."
+	if req.Message != "" {
+		text += "\n\n" + req.Message
+	}
+	manager, err := s.getOrCreateConversationManager(ctx, conversationID)
+	if err == nil {
+		err = s.acceptMessage(ctx, manager, llmService, modelID, text, nil)
+	}
+	if err != nil {
+		http.Error(w, "Failed to send the approval", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(PlanResponse{Status: planApproved, Plan: plan, Finished: finishedSteps(plan)})
+}
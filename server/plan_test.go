@@ -0,0 +1,133 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"shelley.exe.dev/llm"
+)
+
+func TestPlanMode(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+
+	mux := http.NewServeMux()
+	h.server.RegisterRoutes(mux)
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("X-Shelley-Request", "1")
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w
+	}
+
+	body, _ := json.Marshal(ChatRequest{Message: "echo: add a flag", Model: "predictable", Cwd: t.TempDir(), Plan: true})
+	w := do("POST", "/api/conversations/new", string(body))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var created struct {
+		ConversationID string `json:"conversation_id"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &created)
+	h.convID = created.ConversationID
+	h.WaitResponse()
+	id := h.convID
+
+	ctx := context.Background()
+	allowedTools := func() []string {
+		t.Helper()
+		conversation, err := h.db.GetConversationByID(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if conversation.AllowedTools == nil {
+			return nil
+		}
+		var tools []string
+		json.Unmarshal([]byte(*conversation.AllowedTools), &tools)
+		return tools
+	}
+	if tools := allowedTools(); !slices.Contains(tools, "propose_plan") || slices.Contains(tools, "bash") || slices.Contains(tools, "patch") {
+		t.Errorf("expected read-only tools and propose_plan while planning, got %v", tools)
+	}
+	plan := func() PlanResponse {
+		t.Helper()
+		var resp PlanResponse
+		w := do("GET", "/api/conversation/"+id+"/plan", "")
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+		}
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return resp
+	}
+	if resp := plan(); resp.Status != planDrafting || resp.Plan != nil {
+		t.Errorf("expected no plan yet, got %+v", resp)
+	}
+	if w := do("POST", "/api/conversation/"+id+"/plan/approve", ""); w.Code != http.StatusConflict {
+		t.Errorf("expected 409 without a proposed plan, got %d", w.Code)
+	}
+
+	h.server.mu.Lock()
+	manager := h.server.activeConversations[id]
+	h.server.mu.Unlock()
+	run := func(name, input string) llm.ToolOut {
+		t.Helper()
+		manager.mu.Lock()
+		tools := manager.toolSet.Tools()
+		manager.mu.Unlock()
+		for _, tool := range tools {
+			if tool.Name == name {
+				return tool.Run(ctx, json.RawMessage(input))
+			}
+		}
+		t.Fatalf("no %s tool", name)
+		return llm.ToolOut{}
+	}
+
+	proposal := `{"summary": "Add the flag.", "steps": [{"description": "Parse it", "files": ["main.go"]}, {"description": "Document it"}], "risks": ["None"]}`
+	if out := run("propose_plan", proposal); out.Error != nil {
+		t.Fatal(out.Error)
+	}
+	if resp := plan(); resp.Status != planProposed || len(resp.Plan.Steps) != 2 || resp.Plan.Steps[0].Status != "pending" {
+		t.Errorf("expected the proposed plan, got %+v", resp)
+	}
+	if out := run("update_plan_step", `{"step": 1, "status": "done"}`); out.Error == nil {
+		t.Error("expected progress on an unapproved plan to be rejected")
+	}
+
+	w = do("POST", "/api/conversation/"+id+"/plan/approve", `{"message": "Keep it short."}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	h.WaitResponse()
+	if h.userTexts("Keep it short.") != 1 {
+		t.Error("expected the approval to be sent to the agent")
+	}
+	if tools := allowedTools(); tools != nil {
+		t.Errorf("expected every tool once the plan is approved, got %v", tools)
+	}
+
+	if out := run("update_plan_step", `{"step": 1, "status": "done", "note": "Used flag.Bool"}`); out.Error != nil {
+		t.Fatal(out.Error)
+	}
+	if out := run("update_plan_step", `{"step": 3, "status": "done"}`); out.Error == nil {
+		t.Error("expected a step outside the plan to be rejected")
+	}
+	if resp := plan(); resp.Status != planApproved || resp.Finished != 1 || resp.Plan.Steps[0].Note != "Used flag.Bool" {
+		t.Errorf("expected step 1 to be done, got %+v", resp)
+	}
+
+	// A new plan needs approval again.
+	if out := run("propose_plan", proposal); out.Error != nil {
+		t.Fatal(out.Error)
+	}
+	if tools := allowedTools(); !slices.Contains(tools, "propose_plan") || slices.Contains(tools, "bash") {
+		t.Errorf("expected the tools to be locked again, got %v", tools)
+	}
+}
@@ -0,0 +1,98 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+)
+
+// A conversation's dev servers can be previewed through Shelley:
+// /preview/<id>/... is proxied to the port that the newest of the
+// conversation's running managed processes listens on, with the prefix
+// stripped. Only ports the conversation's own processes listen on are
+// reachable, and only on this machine's loopback interface, so it works
+// wherever Shelley runs as long as its own port is reachable. Apps should
+// use relative links, or X-Forwarded-Prefix as their base path.
+//
+// The apps are served from Shelley's own origin, so every response is
+// sandboxed into an opaque origin: their scripts can't read Shelley's
+// storage or call its API with the user's session.
+
+// previewCSP is the Content-Security-Policy of every previewed response.
+// It leaves out allow-same-origin, which would undo the sandbox.
+const previewCSP = "sandbox allow-scripts allow-forms"
+
+// previewPath is where a conversation's processes are previewed.
+func previewPath(conversationID string) string {
+	return "/preview/" + conversationID + "/"
+}
+
+// handlePreview handles /preview/<id>/...
+func (s *Server) handlePreview(w http.ResponseWriter, r *http.Request) {
+	conversationID := r.PathValue("id")
+	s.processMu.Lock()
+	pm := s.processManagers[conversationID]
+	s.processMu.Unlock()
+	if pm == nil {
+		http.Error(w, "The conversation has no processes", http.StatusNotFound)
+		return
+	}
+	port, ok := pm.PreviewPort()
+	if !ok {
+		http.Error(w, "None of the conversation's processes is listening on a port", http.StatusBadGateway)
+		return
+	}
+
+	prefix := strings.TrimSuffix(previewPath(conversationID), "/")
+	target := &url.URL{Scheme: "http", Host: fmt.Sprintf("127.0.0.1:%d", port)}
+	proxy := &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.SetURL(target)
+			pr.Out.URL.Path = "/" + strings.TrimPrefix(strings.TrimPrefix(pr.In.URL.Path, prefix), "/")
+			pr.Out.URL.RawPath = ""
+			pr.Out.Host = target.Host
+			pr.SetXForwarded()
+			pr.Out.Header.Set("X-Forwarded-Prefix", prefix)
+			// Shelley's own credentials are none of the app's business
+			if s.requireHeader != "" {
+				pr.Out.Header.Del(s.requireHeader)
+			}
+		},
+		ModifyResponse: func(resp *http.Response) error {
+			// Added to the app's own policies, which still apply
+			resp.Header.Add("Content-Security-Policy", previewCSP)
+			// Keep the app's redirects within the preview
+			if loc := resp.Header.Get("Location"); loc != "" {
+				resp.Header.Set("Location", previewLocation(loc, target.Host, prefix))
+			}
+			return nil
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			s.logger.Debug("Preview proxy failed", "conversationID", conversationID, "port", port, "error", err)
+			w.Header().Set("Content-Security-Policy", previewCSP)
+			http.Error(w, fmt.Sprintf("Failed to reach port %d: %v", port, err), http.StatusBadGateway)
+		},
+	}
+	proxy.ServeHTTP(w, r)
+}
+
+// previewLocation rewrites a redirect from the app at host to stay under
+// prefix.
+func previewLocation(loc, host, prefix string) string {
+	u, err := url.Parse(loc)
+	if err != nil {
+		return loc
+	}
+	switch {
+	case u.Host == host || u.Host == "localhost"+host[strings.LastIndexByte(host, ':'):]:
+		u.Scheme, u.Host = "", ""
+	case u.Host != "" || !strings.HasPrefix(u.Path, "/"):
+		// Elsewhere, or relative
+		return loc
+	}
+	u.Path = prefix + "/" + strings.TrimPrefix(u.Path, "/")
+	u.RawPath = ""
+	return u.String()
+}
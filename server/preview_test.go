@@ -0,0 +1,84 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"shelley.exe.dev/claudetool"
+)
+
+func TestPreview(t *testing.T) {
+	if _, err := exec.LookPath("python3"); err != nil {
+		t.Skip("python3 not found")
+	}
+	if _, err := os.Stat("/proc/net/tcp"); err != nil {
+		t.Skip("ports are found in /proc")
+	}
+	h := NewTestHarness(t)
+	defer h.Close()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/preview/{id}/", h.server.handlePreview)
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		return w
+	}
+
+	if w := get("/preview/conv/"); w.Code != http.StatusNotFound {
+		t.Errorf("no processes: expected 404, got %d", w.Code)
+	}
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "hello.txt"), []byte("hello from the app"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	pm := h.server.processManager("conv")
+	if w := get("/preview/conv/"); w.Code != http.StatusBadGateway {
+		t.Errorf("nothing listening: expected 502, got %d", w.Code)
+	}
+	tool := &claudetool.ProcessTool{Bash: &claudetool.BashTool{WorkingDir: claudetool.NewMutableWorkingDir(dir)}, Processes: pm}
+	if out := tool.Run(context.Background(), json.RawMessage(`{"action": "start", "command": "exec python3 -m http.server 0 --bind 127.0.0.1"}`)); out.Error != nil {
+		t.Fatal(out.Error)
+	}
+	defer pm.Stop("p1")
+	for deadline := time.Now().Add(10 * time.Second); ; time.Sleep(50 * time.Millisecond) {
+		if _, ok := pm.PreviewPort(); ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the server never listened")
+		}
+	}
+
+	w := get("/preview/conv/hello.txt")
+	body, _ := io.ReadAll(w.Body)
+	if w.Code != http.StatusOK || string(body) != "hello from the app" {
+		t.Errorf("expected the app's file, got %d: %s", w.Code, body)
+	}
+	if csp := w.Header().Get("Content-Security-Policy"); csp != previewCSP {
+		t.Errorf("expected the app sandboxed, got Content-Security-Policy %q", csp)
+	}
+}
+
+func TestPreviewLocation(t *testing.T) {
+	const host, prefix = "127.0.0.1:5173", "/preview/c"
+	for loc, want := range map[string]string{
+		"/login?next=/":                  "/preview/c/login?next=/",
+		"http://127.0.0.1:5173/app/":     "/preview/c/app/",
+		"http://localhost:5173":          "/preview/c/",
+		"https://accounts.example.com/x": "https://accounts.example.com/x",
+		"next":                           "next",
+	} {
+		if got := previewLocation(loc, host, prefix); got != want {
+			t.Errorf("previewLocation(%q) = %q, want %q", loc, got, want)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"shelley.exe.dev/claudetool"
+)
+
+// ConversationProcesses lists the long-running processes a conversation
+// started with the process tool.
+type ConversationProcesses struct {
+	ConversationID string                      `json:"conversation_id"`
+	Processes      []claudetool.ManagedProcess `json:"processes"`
+}
+
+// ProcessLogEvent is an event of GET
+// /api/processes/<conversation_id>/<process_id>/logs.
+type ProcessLogEvent struct {
+	Output string `json:"output,omitempty"`
+	// Process is the process's state, sent first and once it has exited.
+	Process *claudetool.ManagedProcess `json:"process,omitempty"`
+}
+
+// processManager returns the manager of a conversation's long-running
+// processes. It outlives the conversation's loop and tools, which are
+// recreated when the conversation is reset.
+func (s *Server) processManager(conversationID string) *claudetool.ProcessManager {
+	s.processMu.Lock()
+	defer s.processMu.Unlock()
+	if s.processManagers == nil {
+		s.processManagers = make(map[string]*claudetool.ProcessManager)
+	}
+	pm, ok := s.processManagers[conversationID]
+	if !ok {
+		pm = &claudetool.ProcessManager{}
+		s.processManagers[conversationID] = pm
+	}
+	return pm
+}
+
+// handleProcesses handles GET /api/processes, listing the processes of each
+// conversation that started any, or of the one given by ?conversation_id=.
+func (s *Server) handleProcesses(w http.ResponseWriter, r *http.Request) {
+	only := r.URL.Query().Get("conversation_id")
+	s.processMu.Lock()
+	managers := make(map[string]*claudetool.ProcessManager, len(s.processManagers))
+	for id, pm := range s.processManagers {
+		if only == "" || id == only {
+			managers[id] = pm
+		}
+	}
+	s.processMu.Unlock()
+
+	result := []ConversationProcesses{}
+	for id, pm := range managers {
+		if procs := pm.List(); len(procs) > 0 {
+			result = append(result, ConversationProcesses{ConversationID: id, Processes: procs})
+		}
+	}
+	slices.SortFunc(result, func(a, b ConversationProcesses) int {
+		return a.Processes[0].StartedAt.Compare(b.Processes[0].StartedAt)
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// handleProcessLogs handles GET /api/processes/<conversation_id>/<process_id>/logs,
+// streaming a managed process's output as server-sent events: its latest
+// output, then its output as it is written, until it exits.
+func (s *Server) handleProcessLogs(w http.ResponseWriter, r *http.Request) {
+	s.processMu.Lock()
+	pm := s.processManagers[r.PathValue("conversation")]
+	s.processMu.Unlock()
+	if pm == nil {
+		http.Error(w, "Process not found", http.StatusNotFound)
+		return
+	}
+	id := r.PathValue("process")
+	history, output, stop, err := pm.Follow(id)
+	if err != nil {
+		http.Error(w, "Process not found", http.StatusNotFound)
+		return
+	}
+	defer stop()
+	proc, _ := pm.Process(id)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no") // Disable proxy buffering for SSE
+	send := func(event ProcessLogEvent) {
+		data, _ := json.Marshal(event)
+		fmt.Fprintf(w, "data: %s\n\n", data)
+		w.(http.Flusher).Flush()
+	}
+	send(ProcessLogEvent{Output: history, Process: &proc})
+
+	for {
+		select {
+		case chunk, ok := <-output:
+			if !ok {
+				// The process exited, or this follower fell behind and
+				// should reconnect
+				if proc, err := pm.Process(id); err == nil && !proc.Running {
+					send(ProcessLogEvent{Process: &proc})
+				}
+				return
+			}
+			send(ProcessLogEvent{Output: chunk})
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"shelley.exe.dev/claudetool"
+)
+
+func TestProcessesAPI(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+
+	pm := h.server.processManager("conv-a")
+	if h.server.processManager("conv-a") != pm {
+		t.Fatal("expected a conversation to keep its process manager")
+	}
+	h.server.processManager("conv-b") // none started
+	tool := &claudetool.ProcessTool{Bash: &claudetool.BashTool{WorkingDir: claudetool.NewMutableWorkingDir(t.TempDir())}, Processes: pm}
+	if out := tool.Run(context.Background(), json.RawMessage(`{"action": "start", "command": "sleep 30"}`)); out.Error != nil {
+		t.Fatal(out.Error)
+	}
+	defer pm.Stop("p1")
+
+	for _, query := range []string{"", "?conversation_id=conv-a"} {
+		w := httptest.NewRecorder()
+		h.server.handleProcesses(w, httptest.NewRequest("GET", "/api/processes"+query, nil))
+		var got []ConversationProcesses
+		if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != 1 || got[0].ConversationID != "conv-a" || len(got[0].Processes) != 1 || !got[0].Processes[0].Running {
+			t.Errorf("%q: unexpected processes %+v", query, got)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	h.server.handleProcesses(w, httptest.NewRequest("GET", "/api/processes?conversation_id=conv-b", nil))
+	if w.Body.String() != "[]\n" {
+		t.Errorf("expected no processes, got %s", w.Body.String())
+	}
+	if w.Code != http.StatusOK {
+		t.Errorf("expected 200, got %d", w.Code)
+	}
+}
+
+func TestProcessLogs(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/processes/{conversation}/{process}/logs", h.server.handleProcessLogs)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	pm := h.server.processManager("conv")
+	tool := &claudetool.ProcessTool{Bash: &claudetool.BashTool{WorkingDir: claudetool.NewMutableWorkingDir(t.TempDir())}, Processes: pm}
+	if out := tool.Run(context.Background(), json.RawMessage(`{"action": "start", "command": "echo one; sleep 1.5; echo two"}`)); out.Error != nil {
+		t.Fatal(out.Error)
+	}
+
+	resp, err := http.Get(srv.URL + "/api/processes/conv/p1/logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var events []ProcessLogEvent
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		if data, ok := strings.CutPrefix(scanner.Text(), "data: "); ok {
+			var event ProcessLogEvent
+			if err := json.Unmarshal([]byte(data), &event); err != nil {
+				t.Fatal(err)
+			}
+			events = append(events, event)
+		}
+	}
+	if len(events) < 3 {
+		t.Fatalf("expected the history, new output and the exit, got %+v", events)
+	}
+	first, last := events[0], events[len(events)-1]
+	if first.Output != "one\n" || first.Process == nil || !first.Process.Running {
+		t.Errorf("expected the output so far and the running process first, got %+v", first)
+	}
+	var later string
+	for _, event := range events[1:] {
+		later += event.Output
+	}
+	if later != "two\n" {
+		t.Errorf("expected the new output to follow, got %q", later)
+	}
+	if last.Process == nil || last.Process.Running || *last.Process.ExitCode != 0 {
+		t.Errorf("expected the exit last, got %+v", last)
+	}
+
+	resp, err = http.Get(srv.URL + "/api/processes/conv/p9/logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("unknown process: expected 404, got %d", resp.StatusCode)
+	}
+}
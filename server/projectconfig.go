@@ -0,0 +1,113 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"shelley.exe.dev/claudetool"
+	"shelley.exe.dev/db"
+	"shelley.exe.dev/llm"
+	"shelley.exe.dev/projectconfig"
+)
+
+// setupOutputBytes is how much of the end of each setup command's output
+// the agent is shown.
+const setupOutputBytes = 4 * 1024
+
+// applyProjectConfig loads the .shelley.yaml that applies to a new
+// conversation's cwd, returning an error for an invalid file, and restricts
+// the conversation's tools as it says unless the request or the project
+// already does. Tools the server doesn't have are ignored, as the file may
+// be written for another version.
+func (s *Server) applyProjectConfig(req *ChatRequest) error {
+	if req.Cwd == "" {
+		return nil
+	}
+	cfg, err := projectconfig.Load(req.Cwd)
+	if err != nil {
+		return badRequestf("%v", err)
+	}
+	if cfg == nil || cfg.AllowedTools == nil || req.AllowedTools != nil || req.ToolPreset != "" {
+		return nil
+	}
+	available := s.toolNames()
+	req.AllowedTools = slices.DeleteFunc(slices.Clone(cfg.AllowedTools), func(name string) bool {
+		return !slices.Contains(available, name)
+	})
+	return nil
+}
+
+// projectDeployTargets adds the deploy targets of cfg to targets, replacing
+// those of the same name, and returns them sorted by name.
+func projectDeployTargets(targets []claudetool.DeployTarget, cfg *projectconfig.Config) []claudetool.DeployTarget {
+	targets = slices.DeleteFunc(slices.Clone(targets), func(t claudetool.DeployTarget) bool {
+		_, ok := cfg.Deploy[t.Name]
+		return ok
+	})
+	for name, target := range cfg.Deploy {
+		// Checked when the file was parsed
+		timeout, _ := projectconfig.ParseHealthTimeout(target.HealthTimeout)
+		targets = append(targets, claudetool.DeployTarget{
+			Name:          name,
+			Command:       target.Command,
+			Dir:           cfg.DeployDir(target),
+			Env:           projectconfig.Environ(target.Env),
+			Production:    target.Production,
+			Detach:        target.Detach,
+			HealthURL:     target.HealthURL,
+			HealthTimeout: timeout,
+			Rollback:      target.Rollback,
+		})
+	}
+	slices.SortFunc(targets, func(a, b claudetool.DeployTarget) int { return strings.Compare(a.Name, b.Name) })
+	return targets
+}
+
+// runSetup runs the setup commands of cfg with the conversation's tools,
+// stopping at the first that fails, and records and returns a report of
+// them to add to the system prompt.
+func (cm *ConversationManager) runSetup(ctx context.Context, toolSet *claudetool.ToolSet, cfg *projectconfig.Config) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "<project_setup file=%q>\n", cfg.Path)
+	fmt.Fprintf(&b, "These setup commands ran in %s before the conversation started.\n", toolSet.WorkingDir().Get())
+	for i, command := range cfg.Setup {
+		out, err := toolSet.RunCommand(ctx, command)
+		if len(out) > setupOutputBytes {
+			out = "[...]\n" + out[len(out)-setupOutputBytes:]
+		}
+		if out != "" && !strings.HasSuffix(out, "\n") {
+			out += "\n"
+		}
+		status := "succeeded"
+		if err != nil {
+			status = "failed: " + err.Error()
+		}
+		fmt.Fprintf(&b, "<command status=%q>\n$ %s\n%s</command>\n", status, command, out)
+		if err != nil {
+			cm.logger.Warn("Project setup command failed", "command", command, "error", err)
+			if i < len(cfg.Setup)-1 {
+				b.WriteString("Setup stopped at this command; the ones after it were not run.\n")
+			}
+			break
+		}
+	}
+	b.WriteString("</project_setup>")
+	report := b.String()
+
+	// Stored like the system prompt, so the report is part of it when the
+	// conversation is reloaded
+	if _, err := cm.db.CreateMessage(context.WithoutCancel(ctx), db.CreateMessageParams{
+		ConversationID: cm.conversationID,
+		Type:           db.MessageTypeSystem,
+		LLMData: llm.Message{
+			Role:    llm.MessageRoleUser,
+			Content: []llm.Content{{Type: llm.ContentTypeText, Text: report}},
+		},
+		UsageData: llm.Usage{},
+	}); err != nil {
+		cm.logger.Error("Failed to store project setup report", "error", err)
+	}
+	return report
+}
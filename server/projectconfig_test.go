@@ -0,0 +1,125 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"shelley.exe.dev/db"
+	"shelley.exe.dev/db/generated"
+	"shelley.exe.dev/projectconfig"
+)
+
+func TestProjectConfig(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+	work := t.TempDir()
+	config := `prompt: Always answer in haiku.
+allowed_tools: [bash, think, no_such_tool]
+env:
+  GREETING: hello there
+setup:
+  - echo installing; touch deps-installed
+  - exit 3
+  - touch never
+`
+	if err := os.WriteFile(filepath.Join(work, ".shelley.yaml"), []byte(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	newConversation := func(req ChatRequest) *httptest.ResponseRecorder {
+		t.Helper()
+		req.Model = "predictable"
+		body, _ := json.Marshal(req)
+		w := httptest.NewRecorder()
+		h.server.handleNewConversation(w, httptest.NewRequest("POST", "/api/conversations/new", strings.NewReader(string(body))))
+		return w
+	}
+	w := newConversation(ChatRequest{Message: `bash: echo "$GREETING"; ls`, Cwd: work})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		ConversationID string `json:"conversation_id"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	h.convID = resp.ConversationID
+
+	result := h.WaitToolResult()
+	if !strings.Contains(result, "hello there") || !strings.Contains(result, "deps-installed") || strings.Contains(result, "never") {
+		t.Errorf("expected the setup to have run up to the failure and the variable to be set, got %q", result)
+	}
+
+	conv, err := h.db.GetConversationByID(context.Background(), h.convID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tools := conversationAllowedTools(*conv); !slices.Equal(tools, []string{"bash", "think"}) {
+		t.Errorf("expected the configured tools the server has, got %v", tools)
+	}
+	var system []string
+	err = h.db.Queries(context.Background(), func(q *generated.Queries) error {
+		messages, err := q.ListMessages(context.Background(), h.convID)
+		for _, m := range messages {
+			if m.Type == string(db.MessageTypeSystem) && m.LlmData != nil {
+				system = append(system, string(*m.LlmData))
+			}
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	joined := strings.Join(system, "\n")
+	for _, want := range []string{"Always answer in haiku.", "installing", `failed: exit status 3`, "the ones after it were not run"} {
+		if !strings.Contains(joined, want) {
+			t.Errorf("expected the system prompt to contain %q, got %s", want, joined)
+		}
+	}
+
+	if err := os.WriteFile(filepath.Join(work, ".shelley.yaml"), []byte("setup: make\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if w := newConversation(ChatRequest{Message: "echo: hi", Cwd: work}); w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), ".shelley.yaml") {
+		t.Errorf("expected an invalid configuration to be refused, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestProjectDeployTargets(t *testing.T) {
+	settings := &ToolsSettings{Deploy: map[string]DeployTargetSettings{
+		"self":    {Command: "make install-binary", Dir: "/srv/shelley", Detach: true},
+		"staging": {Command: "deploy old", Env: map[string]string{"B": "2", "A": "1"}},
+	}}
+	targets, err := settings.deployTargets()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(targets) != 2 || targets[0].Name != "self" || !slices.Equal(targets[1].Env, []string{"A=1", "B=2"}) {
+		t.Fatalf("unexpected targets %+v", targets)
+	}
+
+	cfg := &projectconfig.Config{Path: "/repo/.shelley.yaml", Deploy: map[string]projectconfig.DeployTarget{
+		"staging":    {Command: "deploy new"},
+		"production": {Command: "deploy", Dir: "infra", Production: true},
+	}}
+	targets = projectDeployTargets(targets, cfg)
+	var names []string
+	for _, target := range targets {
+		names = append(names, target.Name)
+	}
+	if !slices.Equal(names, []string{"production", "self", "staging"}) {
+		t.Fatalf("expected the targets merged and sorted, got %v", names)
+	}
+	if targets[0].Dir != "/repo/infra" || !targets[0].Production {
+		t.Errorf("expected production to run in the project's infra, got %+v", targets[0])
+	}
+	if targets[2].Command != "deploy new" || targets[2].Dir != "/repo" {
+		t.Errorf("expected the project's staging target, got %+v", targets[2])
+	}
+}
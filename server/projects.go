@@ -0,0 +1,260 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"shelley.exe.dev/db"
+	"shelley.exe.dev/db/generated"
+	"shelley.exe.dev/gitstate"
+)
+
+// ProjectRequest is the body of POST /api/projects and PUT /api/projects/{id}.
+// AllowedTools and ToolPreset set the tools new conversations in the project
+// may use, as in ConversationToolsRequest; neither means every tool.
+type ProjectRequest struct {
+	Name         string   `json:"name"`
+	RootPath     string   `json:"root_path"`
+	DefaultModel string   `json:"default_model,omitempty"`
+	AllowedTools []string `json:"allowed_tools,omitempty"`
+	ToolPreset   string   `json:"tool_preset,omitempty"`
+}
+
+// projectParams validates req and returns the project settings it describes.
+// The git origin is that of the root.
+func (s *Server) projectParams(req ProjectRequest) (db.ProjectParams, error) {
+	root := req.RootPath
+	if root == "" || !filepath.IsAbs(root) {
+		return db.ProjectParams{}, badRequestf("root_path must be an absolute path")
+	}
+	root = filepath.Clean(root)
+	if info, err := os.Stat(root); err != nil || !info.IsDir() {
+		return db.ProjectParams{}, badRequestf("%s is not a directory", root)
+	}
+	params := db.ProjectParams{
+		Name:     strings.TrimSpace(req.Name),
+		RootPath: root,
+	}
+	if params.Name == "" {
+		params.Name = filepath.Base(root)
+	}
+	if req.DefaultModel != "" {
+		if !s.llmManager.HasModel(req.DefaultModel) {
+			return db.ProjectParams{}, badRequestf("Unsupported model: %s", req.DefaultModel)
+		}
+		params.DefaultModel = &req.DefaultModel
+	}
+	tools, err := resolveAllowedTools(req.AllowedTools, req.ToolPreset, s.toolNames())
+	if err != nil {
+		return db.ProjectParams{}, badRequestf("%v", err)
+	}
+	if tools != nil {
+		data, _ := json.Marshal(tools)
+		encoded := string(data)
+		params.AllowedTools = &encoded
+	}
+	if origin := gitstate.GetGitOrigin(root); origin != "" {
+		params.GitOrigin = &origin
+	}
+	return params, nil
+}
+
+// checkProjectRoot returns an error if a project other than projectID
+// already has root.
+func (s *Server) checkProjectRoot(ctx context.Context, projectID, root string) error {
+	projects, err := s.db.ListProjects(ctx)
+	if err != nil {
+		return err
+	}
+	for _, p := range projects {
+		if p.RootPath == root && p.ProjectID != projectID {
+			return badRequestf("project %q already has root %s", p.Name, root)
+		}
+	}
+	return nil
+}
+
+// withinRoot reports whether dir is root or below it.
+func withinRoot(dir, root string) bool {
+	rel, err := filepath.Rel(root, filepath.Clean(dir))
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, "../")
+}
+
+// conversationProject returns the project a new conversation belongs to,
+// or nil, and fills in the request's cwd, model and tools from the project
+// where the request leaves them unset. A request without a project joins
+// the project with the deepest root containing its cwd; remote workspaces
+// only join projects they name.
+func (s *Server) conversationProject(ctx context.Context, req *ChatRequest) (*generated.Project, error) {
+	var project *generated.Project
+	if req.ProjectID != "" {
+		p, err := s.db.GetProject(ctx, req.ProjectID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, badRequestf("Unknown project: %s", req.ProjectID)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if req.Cwd == "" {
+			req.Cwd = p.RootPath
+		} else if !withinRoot(req.Cwd, p.RootPath) {
+			return nil, badRequestf("%s is outside project %q (%s)", req.Cwd, p.Name, p.RootPath)
+		}
+		project = p
+	} else if req.Cwd != "" && req.Remote == nil {
+		projects, err := s.db.ListProjects(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for i, p := range projects {
+			if withinRoot(req.Cwd, p.RootPath) && (project == nil || len(p.RootPath) > len(project.RootPath)) {
+				project = &projects[i]
+			}
+		}
+	}
+	if project == nil {
+		return nil, nil
+	}
+
+	if req.Model == "" && project.DefaultModel != nil {
+		req.Model = *project.DefaultModel
+	}
+	if req.AllowedTools == nil && req.ToolPreset == "" && project.AllowedTools != nil {
+		var tools []string
+		if err := json.Unmarshal([]byte(*project.AllowedTools), &tools); err != nil {
+			return nil, err
+		}
+		// Tools that have gone away since the project was saved are dropped
+		available := s.toolNames()
+		req.AllowedTools = slices.DeleteFunc(tools, func(name string) bool {
+			return !slices.Contains(available, name)
+		})
+	}
+	return project, nil
+}
+
+// handleProjects handles GET/POST /api/projects
+func (s *Server) handleProjects(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		projects, err := s.db.ListProjects(r.Context())
+		if err != nil {
+			s.logger.Error("Failed to list projects", "error", err)
+			http.Error(w, "failed to list projects", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(projects); err != nil {
+			s.logger.Error("Failed to encode projects", "error", err)
+		}
+
+	case http.MethodPost:
+		var req ProjectRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid JSON", http.StatusBadRequest)
+			return
+		}
+		params, err := s.projectParams(req)
+		if err == nil {
+			err = s.checkProjectRoot(r.Context(), "", params.RootPath)
+		}
+		if err != nil {
+			s.projectError(w, err)
+			return
+		}
+		project, err := s.db.CreateProject(r.Context(), params)
+		if err != nil {
+			s.logger.Error("Failed to create project", "error", err)
+			http.Error(w, "failed to create project", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		if err := json.NewEncoder(w).Encode(project); err != nil {
+			s.logger.Error("Failed to encode project", "error", err)
+		}
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleProject handles GET/PUT/DELETE /api/projects/{id}
+func (s *Server) handleProject(w http.ResponseWriter, r *http.Request) {
+	projectID := r.PathValue("id")
+	switch r.Method {
+	case http.MethodGet:
+		project, err := s.db.GetProject(r.Context(), projectID)
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "project not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			s.logger.Error("Failed to get project", "projectID", projectID, "error", err)
+			http.Error(w, "failed to get project", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(project); err != nil {
+			s.logger.Error("Failed to encode project", "error", err)
+		}
+
+	case http.MethodPut:
+		var req ProjectRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid JSON", http.StatusBadRequest)
+			return
+		}
+		params, err := s.projectParams(req)
+		if err == nil {
+			err = s.checkProjectRoot(r.Context(), projectID, params.RootPath)
+		}
+		if err != nil {
+			s.projectError(w, err)
+			return
+		}
+		project, err := s.db.UpdateProject(r.Context(), projectID, params)
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "project not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			s.logger.Error("Failed to update project", "projectID", projectID, "error", err)
+			http.Error(w, "failed to update project", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(project); err != nil {
+			s.logger.Error("Failed to encode project", "error", err)
+		}
+
+	case http.MethodDelete:
+		if err := s.db.DeleteProject(r.Context(), projectID); err != nil {
+			s.logger.Error("Failed to delete project", "projectID", projectID, "error", err)
+			http.Error(w, "failed to delete project", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// projectError writes the response for an error validating a project.
+func (s *Server) projectError(w http.ResponseWriter, err error) {
+	var badRequest *badRequestError
+	if errors.As(err, &badRequest) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.logger.Error("Failed to check project", "error", err)
+	http.Error(w, "failed to save project", http.StatusInternalServerError)
+}
@@ -0,0 +1,129 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"shelley.exe.dev/db/generated"
+)
+
+func TestProjects(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+	root := t.TempDir()
+	sub := filepath.Join(root, "sub")
+	if err := os.Mkdir(sub, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	mux := http.NewServeMux()
+	h.server.RegisterRoutes(mux)
+	do := func(method, path string, body any) *httptest.ResponseRecorder {
+		t.Helper()
+		data, _ := json.Marshal(body)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(string(data))))
+		return w
+	}
+
+	for name, req := range map[string]ProjectRequest{
+		"relative root": {RootPath: "work"},
+		"missing root":  {RootPath: filepath.Join(root, "missing")},
+		"unknown model": {RootPath: root, DefaultModel: "nope"},
+		"unknown tool":  {RootPath: root, AllowedTools: []string{"nope"}},
+	} {
+		if w := do("POST", "/api/projects", req); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d: %s", name, w.Code, w.Body.String())
+		}
+	}
+
+	w := do("POST", "/api/projects", ProjectRequest{RootPath: root + "/", DefaultModel: "predictable", ToolPreset: "read_only"})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var project generated.Project
+	json.Unmarshal(w.Body.Bytes(), &project)
+	if project.Name != filepath.Base(root) || project.RootPath != root || project.AllowedTools == nil {
+		t.Errorf("unexpected project %+v", project)
+	}
+	if w := do("POST", "/api/projects", ProjectRequest{RootPath: root}); w.Code != http.StatusBadRequest {
+		t.Errorf("expected a second project with the same root to be refused, got %d", w.Code)
+	}
+
+	newConversation := func(req ChatRequest) generated.Conversation {
+		t.Helper()
+		req.Message = "echo: hi"
+		w := do("POST", "/api/conversations/new", req)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+		}
+		var resp struct {
+			ConversationID string `json:"conversation_id"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		h.convID, h.responsesCount = resp.ConversationID, 0
+		h.WaitResponse()
+		conv, err := h.db.GetConversationByID(context.Background(), resp.ConversationID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return *conv
+	}
+
+	// Named: the root, model and tools come from the project
+	conv := newConversation(ChatRequest{ProjectID: project.ProjectID})
+	if conv.ProjectID == nil || *conv.ProjectID != project.ProjectID || conv.Cwd == nil || *conv.Cwd != root {
+		t.Errorf("expected the conversation in the project's root, got project %v, cwd %v", conv.ProjectID, conv.Cwd)
+	}
+	if conv.ModelID == nil || *conv.ModelID != "predictable" {
+		t.Errorf("expected the project's model, got %v", conv.ModelID)
+	}
+	if tools := conversationTools(conv); len(tools) == 0 || slices.Contains(tools, "bash") {
+		t.Errorf("expected the project's read-only tools, got %v", tools)
+	}
+
+	// Joined by cwd, with the request's tools winning
+	conv = newConversation(ChatRequest{Cwd: sub, ToolPreset: "all"})
+	if conv.ProjectID == nil || *conv.ProjectID != project.ProjectID {
+		t.Errorf("expected a conversation below the root to join the project, got %v", conv.ProjectID)
+	}
+	if tools := conversationTools(conv); !slices.Contains(tools, "bash") {
+		t.Errorf("expected the request's tools, got %v", tools)
+	}
+	joined := conv.ConversationID
+	if w := do("POST", "/api/conversations/new", ChatRequest{Message: "hi", ProjectID: project.ProjectID, Cwd: t.TempDir()}); w.Code != http.StatusBadRequest {
+		t.Errorf("expected a cwd outside the project to be refused, got %d", w.Code)
+	}
+	if conv := newConversation(ChatRequest{Cwd: t.TempDir(), Model: "predictable"}); conv.ProjectID != nil {
+		t.Errorf("expected a conversation outside every project to have none, got %v", *conv.ProjectID)
+	}
+
+	w = do("PUT", "/api/projects/"+project.ProjectID, ProjectRequest{Name: "Renamed", RootPath: root})
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	json.Unmarshal(w.Body.Bytes(), &project)
+	if project.Name != "Renamed" || project.AllowedTools != nil || project.DefaultModel != nil {
+		t.Errorf("expected the project's settings to be replaced, got %+v", project)
+	}
+	if w := do("PUT", "/api/projects/nope", ProjectRequest{RootPath: sub}); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown project, got %d", w.Code)
+	}
+
+	if w := do("DELETE", "/api/projects/"+project.ProjectID, nil); w.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", w.Code)
+	}
+	if w := do("GET", "/api/projects/"+project.ProjectID, nil); w.Code != http.StatusNotFound {
+		t.Errorf("expected the project to be gone, got %d", w.Code)
+	}
+	if conv, err := h.db.GetConversationByID(context.Background(), joined); err != nil || conv.ProjectID != nil {
+		t.Errorf("expected the project's conversations to be kept without it, got %v, %v", conv, err)
+	}
+}
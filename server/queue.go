@@ -0,0 +1,229 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/google/uuid"
+	"shelley.exe.dev/db/generated"
+	"shelley.exe.dev/llm"
+)
+
+// Messages sent with "queue" while the agent is working are held by the
+// conversation's manager, rather than recorded and handed to the loop at
+// once, and delivered one per turn as each turn ends. The queue is kept in
+// memory only, and cancelling the conversation clears it. A message that
+// can't be delivered stays queued with the reason, for the user to see and
+// remove, and later messages are delivered past it. That includes every
+// message still queued when the server shuts down.
+
+// QueuedMessage is a user message waiting for the agent's turn to end.
+type QueuedMessage struct {
+	ID      string `json:"id"`
+	Message string `json:"message"`
+	// Error, if set, is why the message couldn't be sent. It isn't retried.
+	Error    string `json:"error,omitempty"`
+	model    string
+	thinking *llm.Thinking
+}
+
+// MessageQueue is the list of a conversation's queued messages, sent to
+// stream subscribers whenever it changes.
+type MessageQueue struct {
+	Messages []QueuedMessage `json:"messages"`
+}
+
+// RemoveQueuedRequest is the body of POST /api/conversation/<id>/queue/remove.
+// An empty ID removes every queued message.
+type RemoveQueuedRequest struct {
+	ID string `json:"id,omitempty"`
+}
+
+// queuedMessages returns a copy of the queue. The caller must hold queueMu.
+func (cm *ConversationManager) queuedMessages() []QueuedMessage {
+	return append([]QueuedMessage{}, cm.queue...)
+}
+
+// publishQueue sends the queue to subscribers. The caller must hold queueMu.
+func (cm *ConversationManager) publishQueue() {
+	cm.subpub.Broadcast(StreamResponse{Queue: &MessageQueue{Messages: cm.queuedMessages()}})
+}
+
+// hasQueued reports whether any messages are queued.
+func (cm *ConversationManager) hasQueued() bool {
+	cm.queueMu.Lock()
+	defer cm.queueMu.Unlock()
+	return len(cm.queue) > 0
+}
+
+// removeQueued removes the queued message with the given ID, or all of them
+// if id is empty, and reports whether anything was removed.
+func (cm *ConversationManager) removeQueued(id string) bool {
+	cm.queueMu.Lock()
+	defer cm.queueMu.Unlock()
+	n := len(cm.queue)
+	if id == "" {
+		cm.queue = nil
+	} else {
+		cm.queue = slices.DeleteFunc(cm.queue, func(m QueuedMessage) bool { return m.ID == id })
+	}
+	if len(cm.queue) == n {
+		return false
+	}
+	cm.publishQueue()
+	return true
+}
+
+// failQueued marks the queued messages that haven't failed yet as failed
+// for reason, and sends the queue to subscribers if any were. The caller
+// must hold queueMu.
+func (cm *ConversationManager) failQueued(reason string) {
+	failed := false
+	for i := range cm.queue {
+		if cm.queue[i].Error == "" {
+			cm.queue[i].Error = reason
+			failed = true
+		}
+	}
+	if failed {
+		cm.publishQueue()
+	}
+}
+
+// queueUserMessage holds a message until the agent's current turn ends. It
+// returns false, queueing nothing, if the agent isn't working, in which case
+// the message should be sent as usual.
+func (s *Server) queueUserMessage(ctx context.Context, manager *ConversationManager, text, modelID string, thinking *llm.Thinking) (*MessageQueue, bool, error) {
+	// Holding queueMu while checking keeps a turn from ending unnoticed
+	// between the check and the append; see deliverQueuedMessage.
+	manager.queueMu.Lock()
+	defer manager.queueMu.Unlock()
+	busy, err := s.agentBusyNow(ctx, manager.conversationID)
+	if err != nil || !busy {
+		return nil, false, err
+	}
+	manager.queue = append(manager.queue, QueuedMessage{
+		ID:       uuid.New().String(),
+		Message:  text,
+		model:    modelID,
+		thinking: thinking,
+	})
+	manager.publishQueue()
+	return &MessageQueue{Messages: manager.queuedMessages()}, true, nil
+}
+
+// deliverQueuedMessage sends the conversation's next queued message if the
+// agent is idle. It runs whenever a turn ends.
+func (s *Server) deliverQueuedMessage(ctx context.Context, conversationID string) {
+	s.mu.Lock()
+	manager, ok := s.activeConversations[conversationID]
+	s.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	// The message is recorded before queueMu is released, so a turn end
+	// seen twice (e.g. an agent message followed by gitinfo) delivers once.
+	manager.queueMu.Lock()
+	defer manager.queueMu.Unlock()
+	pending := func(m QueuedMessage) bool { return m.Error == "" }
+	if !slices.ContainsFunc(manager.queue, pending) {
+		return
+	}
+	if s.draining.Load() {
+		manager.failQueued(errShuttingDown.Error())
+		return
+	}
+	busy, err := s.agentBusyNow(ctx, conversationID)
+	if err != nil || busy {
+		return
+	}
+
+	// A message is only taken off the queue once its model is found, and
+	// put back if the agent doesn't take it
+	for i := slices.IndexFunc(manager.queue, pending); i >= 0; i = slices.IndexFunc(manager.queue, pending) {
+		next := manager.queue[i]
+		llmService, err := s.llmManager.GetService(next.model)
+		if err != nil {
+			s.logger.Error("Unsupported model for queued message", "conversationID", conversationID, "model", next.model, "error", err)
+			manager.queue[i].Error = fmt.Sprintf("model %s is unavailable: %v", next.model, err)
+			manager.publishQueue()
+			continue
+		}
+		manager.queue = slices.Delete(manager.queue, i, i+1)
+		manager.publishQueue()
+
+		userMessage := llm.Message{
+			Role:     llm.MessageRoleUser,
+			Content:  []llm.Content{{Type: llm.ContentTypeText, Text: next.Message}},
+			Thinking: next.thinking,
+		}
+		if _, err := manager.AcceptUserMessage(ctx, llmService, next.model, userMessage); err != nil {
+			s.logger.Error("Failed to deliver queued message", "conversationID", conversationID, "error", err)
+			next.Error = err.Error()
+			manager.queue = slices.Insert(manager.queue, i, next)
+			manager.publishQueue()
+			continue
+		}
+		return
+	}
+}
+
+// failQueuedMessages marks the messages queued in every conversation as
+// failed for reason.
+func (s *Server) failQueuedMessages(reason string) {
+	s.mu.Lock()
+	managers := make([]*ConversationManager, 0, len(s.activeConversations))
+	for _, manager := range s.activeConversations {
+		managers = append(managers, manager)
+	}
+	s.mu.Unlock()
+
+	for _, manager := range managers {
+		manager.queueMu.Lock()
+		manager.failQueued(reason)
+		manager.queueMu.Unlock()
+	}
+}
+
+// agentBusyNow reports whether the agent is in the middle of a turn.
+func (s *Server) agentBusyNow(ctx context.Context, conversationID string) (bool, error) {
+	var messages []generated.Message
+	err := s.db.Queries(ctx, func(q *generated.Queries) error {
+		var err error
+		messages, err = q.ListMessages(ctx, conversationID)
+		return err
+	})
+	if err != nil {
+		return false, err
+	}
+	return s.agentBusy(conversationID, messages), nil
+}
+
+// handleRemoveQueued handles POST /conversation/<id>/queue/remove
+func (s *Server) handleRemoveQueued(w http.ResponseWriter, r *http.Request, conversationID string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req RemoveQueuedRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	manager, ok := s.activeConversations[conversationID]
+	s.mu.Unlock()
+	if !ok || !manager.removeQueued(req.ID) {
+		http.Error(w, "No such queued message", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "removed"})
+}
@@ -0,0 +1,201 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"shelley.exe.dev/db"
+)
+
+func (h *TestHarness) queueChat(msg string) map[string]any {
+	h.t.Helper()
+	body, _ := json.Marshal(ChatRequest{Message: msg, Model: "predictable", Queue: true})
+	req := httptest.NewRequest("POST", "/api/conversation/"+h.convID+"/chat", strings.NewReader(string(body)))
+	w := httptest.NewRecorder()
+	h.server.handleChatConversation(w, req, h.convID)
+	if w.Code != http.StatusAccepted {
+		h.t.Fatalf("expected status 202, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		h.t.Fatalf("failed to parse response: %v", err)
+	}
+	return resp
+}
+
+// userTexts counts the conversation's user messages containing text.
+func (h *TestHarness) userTexts(text string) int {
+	h.t.Helper()
+	n := 0
+	for _, msg := range h.messages() {
+		if msg.Type == string(db.MessageTypeUser) && msg.LlmData != nil && strings.Contains(string(*msg.LlmData), text) {
+			n++
+		}
+	}
+	return n
+}
+
+func TestQueuedMessageDeliveredAfterTurn(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+
+	h.NewConversation("bash: sleep 1; echo done", "")
+	// Wait for the tool to start so the agent is mid-turn
+	h.waitForToolCall()
+
+	resp := h.queueChat("echo: queued")
+	if resp["status"] != "queued" {
+		t.Fatalf("expected message to be queued, got %v", resp)
+	}
+	if n := h.userTexts("echo: queued"); n != 0 {
+		t.Fatalf("queued message was recorded before the turn ended")
+	}
+
+	h.WaitToolResult()
+	// Both turns may have ended by the time the first response is seen
+	got := h.WaitResponse()
+	if got != "queued" {
+		got = h.WaitResponse()
+	}
+	if got != "queued" {
+		t.Fatalf("expected the queued message to be answered, got %q", got)
+	}
+	if n := h.userTexts("echo: queued"); n != 1 {
+		t.Errorf("expected the queued message to be recorded once, got %d", n)
+	}
+	// The queued message follows the end of the first turn
+	turnEnded := false
+	for _, msg := range h.messages() {
+		if isEndOfTurn(&msg) {
+			turnEnded = true
+		}
+		if msg.Type == string(db.MessageTypeUser) && msg.LlmData != nil && strings.Contains(string(*msg.LlmData), "echo: queued") && !turnEnded {
+			t.Fatal("queued message was recorded within the running turn")
+		}
+	}
+
+	// With the agent idle, queueing sends right away
+	if resp := h.queueChat("echo: direct"); resp["status"] != "accepted" {
+		t.Errorf("expected message to be sent when idle, got %v", resp)
+	}
+	if got := h.WaitResponse(); got != "direct" {
+		t.Errorf("expected direct response, got %q", got)
+	}
+}
+
+func TestRemoveQueuedMessage(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+
+	h.NewConversation("bash: sleep 1; echo done", "")
+	deadline := time.Now().Add(h.timeout)
+	for len(h.messages()) < 3 {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the tool call")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	resp := h.queueChat("echo: never")
+	queue, _ := resp["queue"].(map[string]any)
+	messages, _ := queue["messages"].([]any)
+	if len(messages) != 1 {
+		t.Fatalf("expected one queued message, got %v", resp)
+	}
+	id := messages[0].(map[string]any)["id"].(string)
+
+	remove := func(body string) int {
+		req := httptest.NewRequest("POST", "/api/conversation/"+h.convID+"/queue/remove", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		h.server.handleRemoveQueued(w, req, h.convID)
+		return w.Code
+	}
+	if code := remove(`{"id":"` + id + `"}`); code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", code)
+	}
+	if code := remove(`{"id":"` + id + `"}`); code != http.StatusNotFound {
+		t.Errorf("expected status 404 for removed message, got %d", code)
+	}
+
+	// Once the turn has ended there is nothing left to deliver
+	h.WaitToolResult()
+	h.WaitResponse()
+	if h.server.activeConversations[h.convID].hasQueued() {
+		t.Error("expected the queue to be empty")
+	}
+	if n := h.userTexts("echo: never"); n != 0 {
+		t.Errorf("removed message was delivered")
+	}
+}
+
+// waitForToolCall waits for the conversation's agent to be in the middle of
+// a tool call.
+func (h *TestHarness) waitForToolCall() {
+	h.t.Helper()
+	deadline := time.Now().Add(h.timeout)
+	for !h.server.conversationLoopRunning(h.convID) || len(h.messages()) < 3 {
+		if time.Now().After(deadline) {
+			h.t.Fatal("timed out waiting for the tool call")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// queued returns a copy of the conversation's queue.
+func (h *TestHarness) queued() []QueuedMessage {
+	manager := h.server.activeConversations[h.convID]
+	manager.queueMu.Lock()
+	defer manager.queueMu.Unlock()
+	return manager.queuedMessages()
+}
+
+func TestQueuedMessageUndeliverable(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+
+	h.NewConversation("bash: sleep 1; echo done", "")
+	h.waitForToolCall()
+	h.queueChat("echo: lost")
+	h.queueChat("echo: after")
+	manager := h.server.activeConversations[h.convID]
+	manager.queueMu.Lock()
+	manager.queue[0].model = "no-such-model"
+	manager.queueMu.Unlock()
+
+	// The message the agent refuses, as the conversation uses another model,
+	// stays queued, and the next is sent
+	h.WaitToolResult()
+	got := h.WaitResponse()
+	if got != "after" {
+		got = h.WaitResponse()
+	}
+	if got != "after" {
+		t.Fatalf("expected the next queued message to be answered, got %q", got)
+	}
+	queued := h.queued()
+	if len(queued) != 1 || queued[0].Message != "echo: lost" || !strings.Contains(queued[0].Error, "no-such-model") {
+		t.Errorf("expected the undeliverable message queued with its error, got %+v", queued)
+	}
+	if n := h.userTexts("echo: lost"); n != 0 {
+		t.Errorf("undeliverable message was recorded")
+	}
+}
+
+func TestQueuedMessagesAtShutdown(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+
+	h.NewConversation("bash: sleep 1; echo done", "")
+	h.waitForToolCall()
+	h.queueChat("echo: too late")
+
+	h.server.drainTurns(nil)
+	queued := h.queued()
+	if len(queued) != 1 || queued[0].Error != errShuttingDown.Error() {
+		t.Errorf("expected the queued message marked as not sent, got %+v", queued)
+	}
+}
@@ -3,38 +3,81 @@ package server
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
 
+	"shelley.exe.dev/claudetool"
 	"shelley.exe.dev/db"
 	"shelley.exe.dev/db/generated"
 	"shelley.exe.dev/llm"
 )
 
-// recoverInterruptedConversations finds conversations that were interrupted
-// by server shutdown and resumes them.
-func (s *Server) recoverInterruptedConversations(ctx context.Context) {
-	s.logger.Info("Checking for interrupted conversations to recover")
+// RecoveryPolicy controls what happens at startup to conversations that were
+// interrupted by a server shutdown while the agent was working.
+type RecoveryPolicy string
+
+const (
+	// RecoveryAuto resumes every interrupted conversation at startup.
+	RecoveryAuto RecoveryPolicy = "auto"
+	// RecoveryManual leaves interrupted conversations for the user to resume
+	// individually via POST /api/conversation/<id>/resume.
+	RecoveryManual RecoveryPolicy = "manual"
+	// RecoveryOff skips the startup check entirely.
+	RecoveryOff RecoveryPolicy = "off"
+)
 
-	// Get all non-archived conversations
-	// We check all of them because the agent_working DB flag may be stale
+// ParseRecoveryPolicy parses a recovery policy name.
+func ParseRecoveryPolicy(name string) (RecoveryPolicy, error) {
+	switch p := RecoveryPolicy(name); p {
+	case RecoveryAuto, RecoveryManual, RecoveryOff:
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown recovery policy %q (want auto, manual or off)", name)
+}
+
+// SetRecoveryPolicy sets how interrupted conversations are handled at startup.
+func (s *Server) SetRecoveryPolicy(policy RecoveryPolicy) {
+	s.recoveryPolicy = policy
+}
+
+// interruptedConversation is a conversation whose agent was working when its
+// loop went away, along with its messages since the agent's latest.
+type interruptedConversation struct {
+	conversation generated.Conversation
+	messages     []generated.Message
+}
+
+// findInterruptedConversations returns conversations where the agent was
+// working but no loop in this process is running them.
+func (s *Server) findInterruptedConversations(ctx context.Context) ([]interruptedConversation, error) {
+	// The agent_working flag may be stale, so its candidates are checked
+	// against their latest messages
 	var conversations []generated.Conversation
 	err := s.db.Queries(ctx, func(q *generated.Queries) error {
 		var err error
-		conversations, err = q.ListAllActiveConversations(ctx)
+		conversations, err = q.ListInterruptedConversations(ctx)
 		return err
 	})
 	if err != nil {
-		s.logger.Error("Failed to list conversations for recovery", "error", err)
-		return
+		return nil, fmt.Errorf("failed to list conversations: %w", err)
 	}
 
-	recoveredCount := 0
+	var interrupted []interruptedConversation
 	for _, conv := range conversations {
-		// Get messages for this conversation to check if agent was working
+		if s.conversationLoopRunning(conv.ConversationID) {
+			continue
+		}
+
+		// Get the latest messages for this conversation to check if agent was working
 		var messages []generated.Message
 		err := s.db.Queries(ctx, func(q *generated.Queries) error {
 			var err error
-			messages, err = q.ListMessages(ctx, conv.ConversationID)
+			messages, err = q.ListMessagesSinceLastAgent(ctx, conv.ConversationID)
 			return err
 		})
 		if err != nil {
@@ -43,33 +86,133 @@ func (s *Server) recoverInterruptedConversations(ctx context.Context) {
 		}
 
 		// Check with agentWorking() - this is the source of truth
-		apiMessages := toAPIMessages(messages)
-		if !agentWorking(apiMessages) {
+		if !agentWorking(toAPIMessages(messages)) {
 			continue
 		}
+		interrupted = append(interrupted, interruptedConversation{conversation: conv, messages: messages})
+	}
+	return interrupted, nil
+}
 
-		s.logger.Info("Found interrupted conversation", "conversationID", conv.ConversationID, "slug", conv.Slug)
+// conversationLoopRunning reports whether a loop in this process is running the conversation.
+func (s *Server) conversationLoopRunning(conversationID string) bool {
+	s.mu.Lock()
+	manager, ok := s.activeConversations[conversationID]
+	s.mu.Unlock()
+	if !ok {
+		return false
+	}
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+	return manager.loop != nil
+}
 
-		// Recover in a goroutine so we don't block server startup
-		go s.recoverConversation(context.Background(), conv, messages)
-		recoveredCount++
+// recoverInterruptedConversations finds conversations that were interrupted
+// by server shutdown and handles them according to the recovery policy.
+func (s *Server) recoverInterruptedConversations(ctx context.Context) {
+	if s.recoveryPolicy == RecoveryOff {
+		s.logger.Info("Conversation recovery is off")
+		return
 	}
 
-	if recoveredCount > 0 {
-		s.logger.Info("Started recovery for interrupted conversations", "count", recoveredCount)
-	} else {
+	s.logger.Info("Checking for interrupted conversations to recover")
+
+	interrupted, err := s.findInterruptedConversations(ctx)
+	if err != nil {
+		s.logger.Error("Failed to find interrupted conversations", "error", err)
+		return
+	}
+	if len(interrupted) == 0 {
 		s.logger.Info("No interrupted conversations found")
+		return
+	}
+
+	if s.recoveryPolicy == RecoveryManual {
+		s.logger.Info("Interrupted conversations await manual resume", "count", len(interrupted))
+		return
+	}
+
+	for _, ic := range interrupted {
+		s.logger.Info("Found interrupted conversation", "conversationID", ic.conversation.ConversationID, "slug", ic.conversation.Slug)
+
+		// Recover in a goroutine so we don't block server startup
+		go s.recoverWhenReleased(context.Background(), ic)
 	}
+
+	s.logger.Info("Started recovery for interrupted conversations", "count", len(interrupted))
 }
 
-// recoverConversation resumes a single interrupted conversation.
-func (s *Server) recoverConversation(ctx context.Context, conv generated.Conversation, messages []generated.Message) {
+// recoverWhenReleased recovers an interrupted conversation. If another
+// instance sharing the database holds its lease, e.g. the one a rolling
+// restart replaces, it tries again once the lease could have expired, for as
+// long as the conversation stays interrupted.
+func (s *Server) recoverWhenReleased(ctx context.Context, ic interruptedConversation) {
+	id := ic.conversation.ConversationID
+	for {
+		err := s.recoverConversation(ctx, ic.conversation, ic.messages)
+		if !errors.Is(err, errConversationOwned) {
+			if err != nil && !errors.Is(err, errConversationRunning) {
+				s.logger.Error("Failed to recover conversation", "conversationID", id, "error", err)
+			}
+			return
+		}
+		s.logger.Info("Interrupted conversation is leased by another instance; waiting for it", "conversationID", id)
+		time.Sleep(conversationLeaseTTL)
+
+		if s.conversationLoopRunning(id) {
+			return
+		}
+		err = s.db.Queries(ctx, func(q *generated.Queries) error {
+			var err error
+			if ic.conversation, err = q.GetConversation(ctx, id); err != nil {
+				return err
+			}
+			ic.messages, err = q.ListMessagesSinceLastAgent(ctx, id)
+			return err
+		})
+		if err != nil {
+			s.logger.Error("Failed to reload interrupted conversation", "conversationID", id, "error", err)
+			return
+		}
+		if !agentWorking(toAPIMessages(ic.messages)) {
+			s.logger.Info("Interrupted conversation was recovered elsewhere", "conversationID", id)
+			return
+		}
+	}
+}
+
+// errConversationRunning is returned when resuming a conversation that is
+// already running or being resumed.
+var errConversationRunning = errors.New("conversation is already running")
+
+// recoverConversation resumes a single interrupted conversation, given its
+// messages since the agent's latest. It returns errConversationOwned if
+// another instance holds the conversation's lease, and errConversationRunning
+// if it's running or being resumed here.
+func (s *Server) recoverConversation(ctx context.Context, conv generated.Conversation, messages []generated.Message) error {
 	logger := s.logger.With("conversationID", conv.ConversationID)
 
+	// Get or create the conversation manager
+	manager, err := s.getOrCreateConversationManager(ctx, conv.ConversationID)
+	if err != nil {
+		return fmt.Errorf("failed to create conversation manager: %w", err)
+	}
+
+	// Only one resume at a time, so the missing tool results are recorded once
+	if !manager.beginResume() {
+		return errConversationRunning
+	}
+	defer manager.endResume()
+
+	// Only the instance holding the lease recovers the conversation, so that
+	// two instances sharing the database don't both resume it
+	if err := s.acquireLease(ctx, conv.ConversationID); err != nil {
+		return err
+	}
+
 	// First, record error tool_results for any incomplete tool calls
 	if err := s.recordMissingToolResultsForRecovery(ctx, conv.ConversationID, messages); err != nil {
-		logger.Error("Failed to record missing tool results", "error", err)
-		return
+		return fmt.Errorf("failed to record missing tool results: %w", err)
 	}
 
 	// Get the model from the conversation, fall back to default
@@ -83,24 +226,99 @@ func (s *Server) recoverConversation(ctx context.Context, conv generated.Convers
 	// Get the LLM service
 	service, err := s.llmManager.GetService(modelID)
 	if err != nil {
-		logger.Error("Failed to get LLM service for recovery", "error", err, "model", modelID)
+		return fmt.Errorf("failed to get LLM service for model %s: %w", modelID, err)
+	}
+
+	// Offer the same tools the conversation had before the restart
+	if tools := conversationTools(conv); tools != nil {
+		manager.restoreTools(tools)
+	}
+	if conv.Cwd != nil {
+		if _, err := os.Stat(*conv.Cwd); err != nil {
+			logger.Warn("Working directory of interrupted conversation is unavailable", "cwd", *conv.Cwd, "error", err)
+		}
+	}
+
+	// Resume the conversation
+	if err := manager.Resume(ctx, service, modelID); err != nil {
+		return fmt.Errorf("failed to resume conversation: %w", err)
+	}
+
+	logger.Info("Successfully initiated recovery for conversation")
+	return nil
+}
+
+// handleInterruptedConversations handles GET /api/conversations/interrupted
+func (s *Server) handleInterruptedConversations(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	// Get or create the conversation manager
-	manager, err := s.getOrCreateConversationManager(ctx, conv.ConversationID)
+	interrupted, err := s.findInterruptedConversations(r.Context())
 	if err != nil {
-		logger.Error("Failed to create conversation manager for recovery", "error", err)
+		s.logger.Error("Failed to find interrupted conversations", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
-	// Resume the conversation
-	if err := manager.Resume(ctx, service, modelID); err != nil {
-		logger.Error("Failed to resume conversation", "error", err)
+	conversations := make([]generated.Conversation, len(interrupted))
+	for i, ic := range interrupted {
+		conversations[i] = ic.conversation
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(conversations)
+}
+
+// handleResumeConversation handles POST /conversation/<id>/resume
+func (s *Server) handleResumeConversation(w http.ResponseWriter, r *http.Request, conversationID string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.refuseWhileDraining(w) {
 		return
 	}
 
-	logger.Info("Successfully initiated recovery for conversation")
+	ctx := r.Context()
+	conversation, err := s.db.GetConversationByID(ctx, conversationID)
+	if err != nil {
+		http.Error(w, "Conversation not found", http.StatusNotFound)
+		return
+	}
+	if s.conversationLoopRunning(conversationID) {
+		http.Error(w, "Conversation is already running", http.StatusConflict)
+		return
+	}
+
+	var messages []generated.Message
+	err = s.db.Queries(ctx, func(q *generated.Queries) error {
+		var err error
+		messages, err = q.ListMessagesSinceLastAgent(ctx, conversationID)
+		return err
+	})
+	if err != nil {
+		s.logger.Error("Failed to list messages for resume", "conversationID", conversationID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if !agentWorking(toAPIMessages(messages)) {
+		http.Error(w, "Conversation is not interrupted", http.StatusConflict)
+		return
+	}
+
+	// The loop outlives the request
+	if err := s.recoverConversation(context.WithoutCancel(ctx), *conversation, messages); errors.Is(err, errConversationOwned) || errors.Is(err, errConversationRunning) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	} else if err != nil {
+		s.logger.Error("Failed to resume conversation", "conversationID", conversationID, "error", err)
+		http.Error(w, "Failed to resume conversation", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "resumed"})
 }
 
 // recordMissingToolResultsForRecovery checks if the last assistant message has
@@ -171,16 +389,29 @@ func (s *Server) recordMissingToolResultsForRecovery(ctx context.Context, conver
 		"conversationID", conversationID,
 		"count", len(toolUseIDs))
 
+	runDir := s.bashRunDir(conversationID)
 	var toolResults []llm.Content
 	for id, name := range toolUseIDs {
+		text := fmt.Sprintf("Tool '%s' execution was interrupted by server restart. The operation may or may not have completed. Please check the current state and retry if needed.", name)
+		isError := true
+		// Bash commands leave a record of their PID, output and exit status,
+		// so we can report what actually happened rather than guessing.
+		if run, err := claudetool.LoadBashRun(runDir, id); err == nil {
+			text, isError = run.RecoveryText()
+			// A command that is still running keeps writing to its record
+			if !run.Running {
+				if err := claudetool.RemoveBashRun(runDir, id); err != nil {
+					s.logger.Warn("Failed to remove bash run record", "conversationID", conversationID, "toolUseID", id, "error", err)
+				}
+			}
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			s.logger.Warn("Failed to load bash run record", "conversationID", conversationID, "toolUseID", id, "error", err)
+		}
 		toolResults = append(toolResults, llm.Content{
-			Type:      llm.ContentTypeToolResult,
-			ToolUseID: id,
-			ToolError: true,
-			ToolResult: []llm.Content{{
-				Type: llm.ContentTypeText,
-				Text: fmt.Sprintf("Tool '%s' execution was interrupted by server restart. The operation may or may not have completed. Please check the current state and retry if needed.", name),
-			}},
+			Type:       llm.ContentTypeToolResult,
+			ToolUseID:  id,
+			ToolError:  isError,
+			ToolResult: []llm.Content{{Type: llm.ContentTypeText, Text: text}},
 		})
 	}
 
@@ -199,3 +430,20 @@ func (s *Server) recordMissingToolResultsForRecovery(ctx context.Context, conver
 	return err
 }
 
+// bashRunDir returns the directory where a conversation's bash commands record
+// their progress, so interrupted commands can be inspected on recovery.
+func (s *Server) bashRunDir(conversationID string) string {
+	return filepath.Join(s.bashRunRoot, conversationID)
+}
+
+// conversationTools returns the tool names recorded for a conversation, or nil if none were.
+func conversationTools(conv generated.Conversation) []string {
+	if conv.Tools == nil {
+		return nil
+	}
+	var tools []string
+	if err := json.Unmarshal([]byte(*conv.Tools), &tools); err != nil {
+		return nil
+	}
+	return tools
+}
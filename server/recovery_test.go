@@ -0,0 +1,262 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"shelley.exe.dev/db"
+	"shelley.exe.dev/db/generated"
+	"shelley.exe.dev/llm"
+	"shelley.exe.dev/loop"
+)
+
+func TestRecoveryReportsFinishedBashCommand(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+	h.server.bashRunRoot = t.TempDir()
+	ctx := context.Background()
+
+	h.NewConversation("echo: hi", t.TempDir())
+	h.WaitResponse()
+
+	// The server stopped while a bash command was running; the command went on to finish.
+	toolUse := llm.Message{
+		Role: llm.MessageRoleAssistant,
+		Content: []llm.Content{{
+			Type:      llm.ContentTypeToolUse,
+			ID:        "toolu_interrupted",
+			ToolName:  "bash",
+			ToolInput: json.RawMessage(`{"command":"make test"}`),
+		}},
+	}
+	if _, err := h.db.CreateMessage(ctx, db.CreateMessageParams{
+		ConversationID: h.convID,
+		Type:           db.MessageTypeAgent,
+		LLMData:        toolUse,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	runDir := filepath.Join(h.server.bashRunDir(h.convID), "toolu_interrupted")
+	if err := os.MkdirAll(runDir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{"command": "make test", "output": "PASS\n", "exit": "0\n"} {
+		if err := os.WriteFile(filepath.Join(runDir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var messages []generated.Message
+	if err := h.db.Queries(ctx, func(q *generated.Queries) error {
+		var err error
+		messages, err = q.ListMessages(ctx, h.convID)
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.server.recordMissingToolResultsForRecovery(ctx, h.convID, messages); err != nil {
+		t.Fatal(err)
+	}
+
+	last, err := h.db.GetLatestMessage(ctx, h.convID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var msg llm.Message
+	if err := json.Unmarshal([]byte(*last.LlmData), &msg); err != nil {
+		t.Fatal(err)
+	}
+	if len(msg.Content) != 1 || msg.Content[0].ToolUseID != "toolu_interrupted" {
+		t.Fatalf("expected a tool result for the interrupted call, got %+v", msg.Content)
+	}
+	result := msg.Content[0]
+	if result.ToolError {
+		t.Error("expected a successful tool result for a command that exited 0")
+	}
+	if text := result.ToolResult[0].Text; !strings.Contains(text, "completed") || !strings.Contains(text, "PASS") {
+		t.Errorf("unexpected tool result: %s", text)
+	}
+	if _, err := os.Stat(runDir); !os.IsNotExist(err) {
+		t.Errorf("expected run record to be removed, stat error: %v", err)
+	}
+}
+
+func TestRecoveryRestoresTools(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+	ctx := context.Background()
+
+	h.NewConversation("echo: hi", t.TempDir())
+	h.WaitResponse()
+
+	conv, err := h.db.GetConversationByID(ctx, h.convID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tools := conversationTools(*conv); !slices.Contains(tools, "bash") || !slices.Contains(tools, "patch") {
+		t.Fatalf("expected recorded tools to include bash and patch, got %v", tools)
+	}
+
+	cm := NewConversationManager(h.convID, h.db, nil, h.server.toolSetConfig, nil, nil, "")
+	if err := cm.Hydrate(ctx); err != nil {
+		t.Fatal(err)
+	}
+	cm.restoreTools([]string{"think", "bash"})
+	if err := cm.ensureLoop(loop.NewPredictableService(), "predictable"); err != nil {
+		t.Fatal(err)
+	}
+	defer cm.stopLoop()
+
+	recorded := func() []string {
+		t.Helper()
+		conv, err := h.db.GetConversationByID(ctx, h.convID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return conversationTools(*conv)
+	}
+	if names := recorded(); !slices.Equal(names, []string{"think", "bash"}) {
+		t.Errorf("restored tools = %v, want [think bash]", names)
+	}
+
+	// The next turn is offered the configured tools again
+	if _, err := cm.AcceptUserMessage(ctx, loop.NewPredictableService(), "predictable", llm.UserStringMessage("echo: again")); err != nil {
+		t.Fatal(err)
+	}
+	if names := recorded(); !slices.Contains(names, "patch") {
+		t.Errorf("expected the configured tools offered after the resumed turn, got %v", names)
+	}
+	cm.stopLoop()
+	if err := cm.ensureLoop(loop.NewPredictableService(), "predictable"); err != nil {
+		t.Fatal(err)
+	}
+	if names := recorded(); !slices.Contains(names, "patch") {
+		t.Errorf("expected the configured tools offered to a new loop, got %v", names)
+	}
+}
+
+func TestParseRecoveryPolicy(t *testing.T) {
+	for _, name := range []string{"auto", "manual", "off"} {
+		if p, err := ParseRecoveryPolicy(name); err != nil || string(p) != name {
+			t.Errorf("ParseRecoveryPolicy(%q) = %q, %v", name, p, err)
+		}
+	}
+	if _, err := ParseRecoveryPolicy("sometimes"); err == nil {
+		t.Error("expected error for unknown policy")
+	}
+}
+
+// interruptConversation leaves the harness conversation as a restart would:
+// the agent mid-tool-call with no loop running it.
+func (h *TestHarness) interruptConversation() {
+	h.t.Helper()
+	ctx := context.Background()
+	h.server.mu.Lock()
+	if manager, ok := h.server.activeConversations[h.convID]; ok {
+		manager.stopLoop()
+		delete(h.server.activeConversations, h.convID)
+	}
+	h.server.mu.Unlock()
+
+	toolUse := llm.Message{
+		Role: llm.MessageRoleAssistant,
+		Content: []llm.Content{{
+			Type:      llm.ContentTypeToolUse,
+			ID:        "toolu_" + h.convID,
+			ToolName:  "think",
+			ToolInput: json.RawMessage(`{"thoughts":"hmm"}`),
+		}},
+	}
+	if _, err := h.db.CreateMessage(ctx, db.CreateMessageParams{
+		ConversationID: h.convID,
+		Type:           db.MessageTypeAgent,
+		LLMData:        toolUse,
+	}); err != nil {
+		h.t.Fatal(err)
+	}
+	// As recording the message would
+	if err := h.db.QueriesTx(ctx, func(q *generated.Queries) error {
+		return q.UpdateConversationAgentWorking(ctx, generated.UpdateConversationAgentWorkingParams{AgentWorking: true, ConversationID: h.convID})
+	}); err != nil {
+		h.t.Fatal(err)
+	}
+}
+
+func TestInterruptedConversationsAPI(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+
+	mux := http.NewServeMux()
+	h.server.RegisterRoutes(mux)
+	do := func(method, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w
+	}
+	listInterrupted := func() []generated.Conversation {
+		t.Helper()
+		w := do("GET", "/api/conversations/interrupted")
+		if w.Code != http.StatusOK {
+			t.Fatalf("list interrupted: expected 200, got %d: %s", w.Code, w.Body.String())
+		}
+		var convs []generated.Conversation
+		if err := json.Unmarshal(w.Body.Bytes(), &convs); err != nil {
+			t.Fatal(err)
+		}
+		return convs
+	}
+
+	h.NewConversation("echo: hi", t.TempDir())
+	h.WaitResponse()
+	if convs := listInterrupted(); len(convs) != 0 {
+		t.Fatalf("expected no interrupted conversations, got %d", len(convs))
+	}
+	if w := do("POST", "/api/conversation/"+h.convID+"/resume"); w.Code != http.StatusConflict {
+		t.Errorf("resume of running conversation: expected 409, got %d", w.Code)
+	}
+
+	h.interruptConversation()
+
+	// Manual recovery leaves the conversation for the user
+	h.server.SetRecoveryPolicy(RecoveryManual)
+	h.server.recoverInterruptedConversations(context.Background())
+	convs := listInterrupted()
+	if len(convs) != 1 || convs[0].ConversationID != h.convID {
+		t.Fatalf("expected the interrupted conversation to be listed, got %+v", convs)
+	}
+
+	// Of concurrent resumes, one resumes the conversation
+	codes := make(chan int, 2)
+	for range 2 {
+		go func() { codes <- do("POST", "/api/conversation/"+h.convID+"/resume").Code }()
+	}
+	if got := []int{<-codes, <-codes}; !slices.Contains(got, http.StatusOK) || !slices.Contains(got, http.StatusConflict) {
+		t.Fatalf("concurrent resumes: expected 200 and 409, got %v", got)
+	}
+	if result := h.WaitToolResult(); !strings.Contains(result, "interrupted by server restart") {
+		t.Errorf("expected interrupted tool result, got %q", result)
+	}
+	var results int
+	for _, msg := range h.messages() {
+		if msg.LlmData != nil && strings.Contains(string(*msg.LlmData), "interrupted by server restart") {
+			results++
+		}
+	}
+	if results != 1 {
+		t.Errorf("expected the missing tool result recorded once, got %d", results)
+	}
+	if convs := listInterrupted(); len(convs) != 0 {
+		t.Errorf("expected no interrupted conversations after resume, got %d", len(convs))
+	}
+	if w := do("POST", "/api/conversation/"+h.convID+"/resume"); w.Code != http.StatusConflict {
+		t.Errorf("second resume: expected 409, got %d", w.Code)
+	}
+}
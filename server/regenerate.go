@@ -0,0 +1,137 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+
+	"shelley.exe.dev/db/generated"
+)
+
+// Regenerating reruns the last turn of a conversation: the messages after
+// its user message are rolled back, the files its tools edited are restored
+// from the file-history store, and the loop answers the user message again.
+// Edits to files that have changed since are kept, as they can't be reverted
+// safely; the other side effects of tools, such as bash commands, can't be
+// undone at all.
+
+// RegenerateRequest is the body of POST /api/conversations/<id>/regenerate.
+type RegenerateRequest struct {
+	// Model reruns the turn; empty means the conversation's model.
+	Model string `json:"model,omitempty"`
+}
+
+// RegenerateResponse is the response to a regenerate request.
+type RegenerateResponse struct {
+	Status string `json:"status"`
+	// Reverted lists the file edits of the turn that were reverted.
+	Reverted []string `json:"reverted"`
+	// RevertError says why the turn's file edits were kept, if they were.
+	RevertError string `json:"revert_error,omitempty"`
+}
+
+// handleRegenerate handles POST /api/conversations/<id>/regenerate
+func (s *Server) handleRegenerate(w http.ResponseWriter, r *http.Request, conversationID string) {
+	if s.refuseWhileDraining(w) || s.refuseIfLeasedElsewhere(w, r, conversationID) {
+		return
+	}
+	var req RegenerateRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+	}
+
+	ctx := r.Context()
+	conversation, err := s.db.GetConversationByID(ctx, conversationID)
+	if err != nil {
+		http.Error(w, "Conversation not found", http.StatusNotFound)
+		return
+	}
+	messages, err := s.checkAgentIdle(ctx, conversationID)
+	if errors.Is(err, errAgentBusy) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		s.logger.Error("Failed to list messages", "conversationID", conversationID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	starts := turnStarts(messages)
+	if len(starts) == 0 {
+		http.Error(w, "No turn to regenerate", http.StatusBadRequest)
+		return
+	}
+	turn := starts[len(starts)-1]
+
+	modelID := req.Model
+	if modelID == "" && conversation.ModelID != nil {
+		modelID = *conversation.ModelID
+	}
+	if modelID == "" {
+		modelID = s.defaultModel
+	}
+	llmService, err := s.llmManager.GetService(modelID)
+	if err != nil {
+		http.Error(w, "Unsupported model: "+modelID, http.StatusBadRequest)
+		return
+	}
+
+	var edits []generated.FileEdit
+	if err := s.db.Queries(ctx, func(q *generated.Queries) error {
+		var err error
+		edits, err = q.ListFileEdits(ctx, &conversationID)
+		return err
+	}); err != nil {
+		s.logger.Error("Failed to list file edits", "conversationID", conversationID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	edits = slices.DeleteFunc(edits, func(edit generated.FileEdit) bool {
+		return edit.RevertedAt != nil || editTurn(edit, starts) != turn
+	})
+
+	response := RegenerateResponse{Status: "accepted", Reverted: []string{}}
+	err = s.revertFileEdits(ctx, edits)
+	switch {
+	case errors.Is(err, errFileChangedSinceEdit):
+		response.RevertError = err.Error()
+	case err != nil:
+		s.logger.Error("Failed to revert file edits", "conversationID", conversationID, "error", err)
+		http.Error(w, "Failed to revert file edits", http.StatusInternalServerError)
+		return
+	default:
+		for _, edit := range edits {
+			response.Reverted = append(response.Reverted, edit.EditID)
+		}
+	}
+
+	if err := s.db.RollbackConversation(ctx, conversationID, turn); err != nil {
+		s.logger.Error("Failed to roll back conversation", "conversationID", conversationID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	// The loop's in-memory history still has the removed messages, and is
+	// tied to the model it was started with
+	s.resetConversationManager(conversationID)
+
+	manager, err := s.getOrCreateConversationManager(ctx, conversationID)
+	if err == nil {
+		// The loop outlives the request
+		err = manager.Resume(context.WithoutCancel(ctx), llmService, modelID)
+	}
+	if err != nil {
+		s.logger.Error("Failed to regenerate", "conversationID", conversationID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	go s.broadcastConversationUpdate(context.WithoutCancel(ctx), conversationID)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,70 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func (h *TestHarness) regenerate(body string) *httptest.ResponseRecorder {
+	h.t.Helper()
+	// The loop may still be finishing the turn after the response is recorded.
+	deadline := time.Now().Add(h.timeout)
+	for {
+		req := httptest.NewRequest("POST", "/api/conversations/"+h.convID+"/regenerate", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		h.server.handleRegenerate(w, req, h.convID)
+		if w.Code != http.StatusConflict || time.Now().After(deadline) {
+			return w
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestRegenerate(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "notes.txt")
+	if err := os.WriteFile(file, []byte("an example\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	h.NewConversation("patch: "+file, dir)
+	h.WaitResponse()
+	h.Chat("patch: " + file)
+	h.WaitResponse()
+
+	w := h.regenerate("")
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("regenerate: expected 202, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp RegenerateResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	edits := h.fileEdits()
+	if len(resp.Reverted) != 1 || resp.Reverted[0] != edits[1].EditID {
+		t.Errorf("expected the last turn's edit to be reverted, got %+v", resp)
+	}
+
+	// The turn is rerun, patching the file again
+	h.responsesCount = 1
+	h.WaitResponse()
+	if data, _ := os.ReadFile(file); string(data) != "an updated updated example\n" {
+		t.Errorf("unexpected file content after regenerating: %q", data)
+	}
+	edits = h.fileEdits()
+	if len(edits) != 3 || edits[0].RevertedAt != nil || edits[1].RevertedAt == nil || edits[2].RevertedAt != nil {
+		t.Errorf("expected the regenerated turn to replace the reverted edit, got %+v", edits)
+	}
+	if texts := userMessageTexts(t, h.messages()); !slices.Equal(texts, []string{"patch: " + file, "patch: " + file}) {
+		t.Errorf("expected the user messages to be kept, got %q", texts)
+	}
+}
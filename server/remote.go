@@ -0,0 +1,79 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/fs"
+
+	"shelley.exe.dev/db/generated"
+	"shelley.exe.dev/devcontainer"
+	"shelley.exe.dev/remote"
+)
+
+// RemoteWorkspace is the SSH host a conversation's tools run on, in its cwd
+// there. It is fixed when the conversation is created.
+type RemoteWorkspace struct {
+	// Host is the ssh destination: [user@]host, or a Host from ~/.ssh/config.
+	Host string `json:"host"`
+	// Port overrides the ssh port, if set.
+	Port int `json:"port,omitempty"`
+	// IdentityFile is the private key, on the server, to authenticate with.
+	IdentityFile string `json:"identity_file,omitempty"`
+}
+
+// shell returns the shell that runs the conversation's tools on the host.
+func (w RemoteWorkspace) shell() remote.Shell {
+	host := &remote.Host{Target: w.Host, Port: w.Port, IdentityFile: w.IdentityFile}
+	return host.Shell()
+}
+
+// conversationRemote returns the remote workspace recorded for a
+// conversation, or nil if its tools run on the server.
+func conversationRemote(conv generated.Conversation) *RemoteWorkspace {
+	if conv.Remote == nil {
+		return nil
+	}
+	var ws RemoteWorkspace
+	if err := json.Unmarshal([]byte(*conv.Remote), &ws); err != nil || ws.Host == "" {
+		return nil
+	}
+	return &ws
+}
+
+// workspaceShell returns the shell of the host or dev container a
+// conversation's tools run in, or nil if they run on the server.
+func workspaceShell(conv generated.Conversation) remote.Shell {
+	if ws := conversationRemote(conv); ws != nil {
+		return ws.shell()
+	}
+	if conv.Devcontainer != nil {
+		return devcontainer.For(*conv.Devcontainer).Shell()
+	}
+	return nil
+}
+
+// checkRemoteWorkspace checks that a new conversation can work in cwd on
+// the remote host.
+func checkRemoteWorkspace(ctx context.Context, ws RemoteWorkspace, cwd string, sandboxOpts *SandboxOptions) error {
+	switch {
+	case ws.Host == "":
+		return badRequestf("A remote workspace needs a host")
+	case ws.Port < 0 || ws.Port > 65535:
+		return badRequestf("Invalid port %d", ws.Port)
+	case cwd == "":
+		return badRequestf("A remote workspace needs a cwd on the host")
+	case sandboxOpts != nil && sandboxOpts.Enabled:
+		return badRequestf("Remote workspaces can't be sandboxed")
+	case !remote.Available():
+		return badRequestf("%v", remote.ErrUnavailable)
+	}
+	isDir, err := ws.shell().IsDir(ctx, cwd)
+	switch {
+	case errors.Is(err, fs.ErrNotExist) || (err == nil && !isDir):
+		return badRequestf("%s is not a directory on %s", cwd, ws.Host)
+	case err != nil:
+		return badRequestf("Can't reach %s: %v", ws.Host, err)
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeSSH puts an ssh on PATH that logs its destination to the returned
+// file and runs the script locally.
+func fakeSSH(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	log := filepath.Join(dir, "log")
+	script := "#!/bin/sh\nwhile [ \"$1\" != -- ]; do shift; done\necho \"$2\" >> " + log + "\nexec sh -c \"$3\"\n"
+	if err := os.WriteFile(filepath.Join(dir, "ssh"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return log
+}
+
+func TestRemoteConversation(t *testing.T) {
+	log := fakeSSH(t)
+	h := NewTestHarness(t)
+	defer h.Close()
+	work := t.TempDir()
+
+	newConversation := func(req ChatRequest) *httptest.ResponseRecorder {
+		t.Helper()
+		body, _ := json.Marshal(req)
+		w := httptest.NewRecorder()
+		h.server.handleNewConversation(w, httptest.NewRequest("POST", "/api/conversations/new", strings.NewReader(string(body))))
+		return w
+	}
+
+	for name, req := range map[string]ChatRequest{
+		"no cwd":        {Remote: &RemoteWorkspace{Host: "build-box"}},
+		"no host":       {Cwd: work, Remote: &RemoteWorkspace{}},
+		"sandboxed":     {Cwd: work, Remote: &RemoteWorkspace{Host: "build-box"}, Sandbox: &SandboxOptions{Enabled: true}},
+		"missing cwd":   {Cwd: filepath.Join(work, "nope"), Remote: &RemoteWorkspace{Host: "build-box"}},
+		"invalid port":  {Cwd: work, Remote: &RemoteWorkspace{Host: "build-box", Port: 70000}},
+		"cwd is a file": {Cwd: log, Remote: &RemoteWorkspace{Host: "build-box"}},
+	} {
+		req.Message = "echo: hi"
+		req.Model = "predictable"
+		if w := newConversation(req); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d: %s", name, w.Code, w.Body.String())
+		}
+	}
+	os.Remove(log)
+
+	w := newConversation(ChatRequest{
+		Message: "bash: echo remote > made.txt; pwd",
+		Model:   "predictable",
+		Cwd:     work,
+		Remote:  &RemoteWorkspace{Host: "build-box", Port: 2222},
+	})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		ConversationID string `json:"conversation_id"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	h.convID = resp.ConversationID
+	if result := h.WaitToolResult(); strings.TrimSpace(result) != work {
+		t.Errorf("unexpected tool result %q", result)
+	}
+
+	conv, err := h.db.GetConversationByID(context.Background(), h.convID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ws := conversationRemote(*conv); ws == nil || ws.Host != "build-box" || ws.Port != 2222 {
+		t.Errorf("expected the remote workspace to be recorded, got %v", conv.Remote)
+	}
+	if data, err := os.ReadFile(filepath.Join(work, "made.txt")); err != nil || string(data) != "remote\n" {
+		t.Errorf("expected made.txt to be written: %q, %v", data, err)
+	}
+	if data, err := os.ReadFile(log); err != nil || !strings.Contains(string(data), "build-box") {
+		t.Errorf("expected the command to run over ssh, got %q, %v", data, err)
+	}
+
+	// A fork runs its tools on the same host
+	h.WaitResponse()
+	os.Remove(log)
+	cw, fork := h.command("/fork")
+	if cw.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", cw.Code, cw.Body.String())
+	}
+	forked, err := h.db.GetConversationByID(context.Background(), fork.ConversationID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ws := conversationRemote(*forked); ws == nil || ws.Host != "build-box" || ws.Port != 2222 {
+		t.Errorf("expected the fork to keep the remote workspace, got %v", forked.Remote)
+	}
+	h.convID = fork.ConversationID
+	h.Chat("bash: echo fork > forked.txt")
+	h.WaitResponse()
+	if data, err := os.ReadFile(filepath.Join(work, "forked.txt")); err != nil || string(data) != "fork\n" {
+		t.Errorf("expected forked.txt to be written: %q, %v", data, err)
+	}
+	if data, err := os.ReadFile(log); err != nil || !strings.Contains(string(data), "build-box") {
+		t.Errorf("expected the fork's command to run over ssh, got %q, %v", data, err)
+	}
+
+	// /cwd looks the directory up on the host
+	if err := os.Mkdir(filepath.Join(work, "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	os.Remove(log)
+	if cw, resp := h.command("/cwd sub"); cw.Code != http.StatusOK || resp.Output != "Working directory: "+filepath.Join(work, "sub") {
+		t.Errorf("expected /cwd to change to sub, got %d: %s", cw.Code, cw.Body.String())
+	}
+	if data, err := os.ReadFile(log); err != nil || !strings.Contains(string(data), "build-box") {
+		t.Errorf("expected /cwd to check the directory over ssh, got %q, %v", data, err)
+	}
+	if cw, _ := h.command("/cwd nope"); cw.Code != http.StatusBadRequest {
+		t.Errorf("expected a missing directory to be refused, got %d: %s", cw.Code, cw.Body.String())
+	}
+}
@@ -0,0 +1,64 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"shelley.exe.dev/db/generated"
+)
+
+func TestPatchConversationSlug(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+
+	ctx := context.Background()
+	other, err := h.db.CreateConversation(ctx, nil, true, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.db.UpdateConversationSlug(ctx, other.ConversationID, "my title"); err != nil {
+		t.Fatal(err)
+	}
+	conv, err := h.db.CreateConversation(ctx, nil, true, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mux := http.NewServeMux()
+	h.server.RegisterRoutes(mux)
+	patch := func(id, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("PATCH", "/api/conversations/"+id+"/slug", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w
+	}
+
+	// The title is sanitized and suffixed to avoid the other conversation's slug
+	w := patch(conv.ConversationID, `{"slug":"  my   title "}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var renamed generated.Conversation
+	if err := json.Unmarshal(w.Body.Bytes(), &renamed); err != nil {
+		t.Fatalf("failed to parse response: %v", err)
+	}
+	if renamed.Slug == nil || *renamed.Slug != "my title-1" {
+		t.Errorf("expected slug %q, got %v", "my title-1", renamed.Slug)
+	}
+
+	// Renaming to its own slug keeps it
+	if w := patch(conv.ConversationID, `{"slug":"my title-1"}`); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"slug":"my title-1"`) {
+		t.Errorf("expected slug to be kept, got %d: %s", w.Code, w.Body.String())
+	}
+
+	if w := patch(conv.ConversationID, `{"slug":"   "}`); w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for empty slug, got %d", w.Code)
+	}
+	if w := patch("cmissing", `{"slug":"anything"}`); w.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 for missing conversation, got %d", w.Code)
+	}
+}
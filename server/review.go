@@ -0,0 +1,314 @@
+package server
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os/exec"
+	"strings"
+
+	"shelley.exe.dev/claudetool"
+	"shelley.exe.dev/db"
+	"shelley.exe.dev/db/generated"
+)
+
+// A review conversation is started from a pull request URL. Its first
+// message holds the pull request's description and diff, its tools are the
+// read-only ones plus submit_review, and the review the agent submits is
+// stored with the conversation and posted to GitHub as a pull request
+// review, when the agent submits it or later on request.
+
+// maxReviewDiff caps the diff included in a review conversation's first
+// message, in bytes.
+const maxReviewDiff = 200_000
+
+// PRReviewRequest is the body of POST /api/conversations/review.
+type PRReviewRequest struct {
+	PRURL string `json:"pr_url"`
+	// Cwd is a checkout of the repository the agent may read for context.
+	Cwd   string `json:"cwd,omitempty"`
+	Model string `json:"model,omitempty"`
+	// Post posts the review to GitHub as soon as the agent submits it.
+	Post bool `json:"post,omitempty"`
+	// Instructions are added to the request to review, e.g. what to focus on.
+	Instructions string `json:"instructions,omitempty"`
+}
+
+// PRReviewResponse is a review conversation's pull request and review.
+type PRReviewResponse struct {
+	PRURL     string             `json:"pr_url"`
+	HeadSHA   string             `json:"head_sha"`
+	Post      bool               `json:"post"`
+	Review    *claudetool.Review `json:"review"`
+	PostedURL string             `json:"posted_url,omitempty"`
+}
+
+// pullRequest is what a review needs to know about a pull request, as gh
+// pr view reports it.
+type pullRequest struct {
+	Title  string `json:"title"`
+	Body   string `json:"body"`
+	Author struct {
+		Login string `json:"login"`
+	} `json:"author"`
+	BaseRefName string `json:"baseRefName"`
+	HeadRefName string `json:"headRefName"`
+	HeadRefOid  string `json:"headRefOid"`
+}
+
+// runGH runs the gh CLI with args and stdin, if any, and returns its
+// output. Errors include what gh printed.
+func runGH(ctx context.Context, stdin []byte, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "gh", args...)
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
+	out, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			err = fmt.Errorf("%w: %s", err, strings.TrimSpace(string(exitErr.Stderr)+" "+string(out)))
+		}
+		return nil, fmt.Errorf("gh %s: %w", args[0], err)
+	}
+	return out, nil
+}
+
+// handleReviewConversation handles POST /api/conversations/review, which
+// starts a conversation reviewing a pull request.
+func (s *Server) handleReviewConversation(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	ctx := r.Context()
+	var req PRReviewRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if !githubPullPattern.MatchString(req.PRURL) {
+		http.Error(w, "pr_url must be a GitHub pull request URL", http.StatusBadRequest)
+		return
+	}
+
+	out, err := runGH(ctx, nil, "pr", "view", req.PRURL, "--json", "title,body,author,baseRefName,headRefName,headRefOid")
+	if err != nil {
+		http.Error(w, "failed to fetch the pull request: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+	var pr pullRequest
+	if err := json.Unmarshal(out, &pr); err != nil {
+		http.Error(w, "failed to parse the pull request: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+	diff, err := runGH(ctx, nil, "pr", "diff", req.PRURL)
+	if err != nil {
+		http.Error(w, "failed to fetch the pull request's diff: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	chat := ChatRequest{
+		Message: reviewPrompt(req, pr, string(diff)),
+		Model:   req.Model,
+		Cwd:     req.Cwd,
+		onCreate: func(ctx context.Context, conversationID string) error {
+			if _, err := s.db.CreatePRReview(ctx, conversationID, req.PRURL, pr.HeadRefOid, req.Post); err != nil {
+				return err
+			}
+			tools, _ := resolveAllowedTools(nil, "read_only", s.toolNames())
+			return s.setConversationAllowedTools(ctx, conversationID, append(tools, "submit_review"))
+		},
+	}
+	conversationID, err := s.newConversation(ctx, chat, s.userID(r))
+	if err != nil {
+		var badReq *badRequestError
+		if errors.As(err, &badReq) {
+			http.Error(w, badReq.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "Failed to start review", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]string{"status": "accepted", "conversation_id": conversationID})
+}
+
+// reviewPrompt is the first message of a review conversation.
+func reviewPrompt(req PRReviewRequest, pr pullRequest, diff string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Review the pull request %s, %q by %s, which merges %s into %s (head %s).\n\n",
+		req.PRURL, pr.Title, pr.Author.Login, pr.HeadRefName, pr.BaseRefName, pr.HeadRefOid)
+	b.WriteString("Look for bugs, security problems, missing error handling, missing tests and code that doesn't fit the codebase. ")
+	if req.Cwd != "" {
+		b.WriteString("The working directory is a checkout of the repository you can read for context, but it may not be at the pull request's head. ")
+	}
+	b.WriteString("When you are done, submit the review with submit_review; comments must be on lines of the new version of changed files.")
+	if req.Instructions != "" {
+		b.WriteString("\n\n" + req.Instructions)
+	}
+	body := strings.TrimSpace(pr.Body)
+	if body == "" {
+		body = "(no description)"
+	}
+	b.WriteString("\n\n## Description\n\n" + body)
+	if len(diff) > maxReviewDiff {
+		diff = strings.ToValidUTF8(diff[:maxReviewDiff], "") + fmt.Sprintf("\n[diff truncated: %d more bytes]", len(diff)-maxReviewDiff)
+	}
+	b.WriteString("\n\n## Diff\n\n```diff\n" + strings.TrimRight(diff, "\n") + "\n```")
+	return b.String()
+}
+
+// submitPRReview records the review the agent submitted for a review
+// conversation and posts it if the conversation asked for that. It returns
+// the tool's note for the agent.
+func submitPRReview(ctx context.Context, database *db.DB, conversationID string, review claudetool.Review) (string, error) {
+	data, err := json.Marshal(review)
+	if err != nil {
+		return "", err
+	}
+	if err := database.UpdatePRReview(ctx, conversationID, string(data)); err != nil {
+		return "", err
+	}
+	row, err := database.GetPRReview(ctx, conversationID)
+	if err != nil {
+		return "", err
+	}
+	if !row.Post {
+		return "Review recorded. The user can post it to the pull request.", nil
+	}
+	url, err := postPRReview(ctx, database, row, review)
+	if err != nil {
+		return fmt.Sprintf("Review recorded, but posting it to the pull request failed: %v", err), nil
+	}
+	return "Review recorded and posted: " + url, nil
+}
+
+// postPRReview posts a review to its pull request and records its URL. If
+// GitHub rejects it, for example for a comment on a line outside the diff
+// or a verdict on the author's own pull request, it is posted again as a
+// plain comment with the comments in its body.
+func postPRReview(ctx context.Context, database *db.DB, row *generated.PrReview, review claudetool.Review) (string, error) {
+	match := githubPullPattern.FindStringSubmatch(row.PrUrl)
+	if match == nil {
+		return "", fmt.Errorf("not a pull request URL: %s", row.PrUrl)
+	}
+	endpoint := fmt.Sprintf("repos/%s/pulls/%s/reviews", match[1], match[2])
+	post := func(payload map[string]any) (string, error) {
+		data, _ := json.Marshal(payload)
+		out, err := runGH(ctx, data, "api", "--method", "POST", endpoint, "--input", "-")
+		if err != nil {
+			return "", err
+		}
+		var posted struct {
+			HTMLURL string `json:"html_url"`
+		}
+		json.Unmarshal(out, &posted)
+		return posted.HTMLURL, nil
+	}
+
+	comments := []map[string]any{}
+	for _, c := range review.Comments {
+		comments = append(comments, map[string]any{"path": c.Path, "line": c.Line, "side": "RIGHT", "body": reviewCommentBody(c)})
+	}
+	event := map[string]string{"approve": "APPROVE", "request_changes": "REQUEST_CHANGES"}[review.Verdict]
+	url, err := post(map[string]any{
+		"commit_id": row.HeadSha,
+		"event":     cmp.Or(event, "COMMENT"),
+		"body":      review.Summary,
+		"comments":  comments,
+	})
+	if err != nil {
+		var b strings.Builder
+		b.WriteString(review.Summary)
+		if review.Verdict != "comment" {
+			fmt.Fprintf(&b, "\n\nVerdict: %s", strings.ReplaceAll(review.Verdict, "_", " "))
+		}
+		for _, c := range review.Comments {
+			fmt.Fprintf(&b, "\n\n**%s:%d**: %s", c.Path, c.Line, reviewCommentBody(c))
+		}
+		var retryErr error
+		url, retryErr = post(map[string]any{"commit_id": row.HeadSha, "event": "COMMENT", "body": b.String()})
+		if retryErr != nil {
+			return "", err
+		}
+	}
+	if err := database.UpdatePRReviewPosted(ctx, row.ConversationID, url); err != nil {
+		return "", err
+	}
+	return url, nil
+}
+
+// reviewCommentBody is a comment as posted, led by its severity.
+func reviewCommentBody(c claudetool.ReviewComment) string {
+	return fmt.Sprintf("**%s**: %s", c.Severity, c.Body)
+}
+
+// handlePRReview handles GET /conversation/<id>/review, the pull request a
+// review conversation reviews and the review submitted so far.
+func (s *Server) handlePRReview(w http.ResponseWriter, r *http.Request, conversationID string) {
+	row, err := s.db.GetPRReview(r.Context(), conversationID)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Not a review conversation", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		s.logger.Error("Failed to get pull request review", "conversationID", conversationID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(prReviewResponse(row))
+}
+
+// handlePostPRReview handles POST /conversation/<id>/review/post, which
+// posts the submitted review to the pull request.
+func (s *Server) handlePostPRReview(w http.ResponseWriter, r *http.Request, conversationID string) {
+	ctx := r.Context()
+	row, err := s.db.GetPRReview(ctx, conversationID)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Not a review conversation", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		s.logger.Error("Failed to get pull request review", "conversationID", conversationID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	resp := prReviewResponse(row)
+	switch {
+	case resp.Review == nil:
+		http.Error(w, "The agent hasn't submitted a review yet", http.StatusConflict)
+		return
+	case resp.PostedURL != "":
+		http.Error(w, "The review was already posted", http.StatusConflict)
+		return
+	}
+	resp.PostedURL, err = postPRReview(ctx, s.db, row, *resp.Review)
+	if err != nil {
+		http.Error(w, "failed to post the review: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+func prReviewResponse(row *generated.PrReview) PRReviewResponse {
+	resp := PRReviewResponse{PRURL: row.PrUrl, HeadSHA: row.HeadSha, Post: row.Post}
+	if row.Review != nil {
+		var review claudetool.Review
+		if json.Unmarshal([]byte(*row.Review), &review) == nil {
+			resp.Review = &review
+		}
+	}
+	if row.PostedUrl != nil {
+		resp.PostedURL = *row.PostedUrl
+	}
+	return resp
+}
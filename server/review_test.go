@@ -0,0 +1,147 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"shelley.exe.dev/claudetool"
+	"shelley.exe.dev/db"
+)
+
+// fakeReviewGH is a gh that reports a pull request and its diff, and
+// accepts reviews without line comments, saving each review it is sent in
+// posted.json next to it.
+const fakeReviewGH = `#!/bin/sh
+dir=$(dirname "$0")
+case "$1 $2" in
+"pr view") echo '{"title":"Add sum","body":"Adds a sum helper.","author":{"login":"alice"},"baseRefName":"main","headRefName":"sum","headRefOid":"abc123"}' ;;
+"pr diff") printf '+++ b/sum.go\n+func Sum(a, b int) int { return a - b }\n' ;;
+"api --method")
+	review=$(cat)
+	printf '%s\n' "$review" >>"$dir/posted.json"
+	if printf '%s' "$review" | grep -q '"path"'; then
+		echo "pull request review thread line must be part of the diff" >&2
+		exit 1
+	fi
+	echo '{"html_url":"https://github.com/o/r/pull/7#pullrequestreview-1"}'
+	;;
+*) exit 1 ;;
+esac
+`
+
+func TestPRReview(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+	bin := t.TempDir()
+	if err := os.WriteFile(filepath.Join(bin, "gh"), []byte(fakeReviewGH), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+":"+os.Getenv("PATH"))
+
+	mux := http.NewServeMux()
+	h.server.RegisterRoutes(mux)
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("X-Shelley-Request", "1")
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := do("POST", "/api/conversations/review", `{"pr_url": "https://github.com/o/r/issues/7"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an issue URL, got %d", w.Code)
+	}
+	w := do("POST", "/api/conversations/review", `{"pr_url": "https://github.com/o/r/pull/7", "model": "predictable", "instructions": "Focus on correctness."}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		ConversationID string `json:"conversation_id"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	h.convID = resp.ConversationID
+	h.WaitResponse()
+	id := h.convID
+
+	ctx := context.Background()
+	conversation, err := h.db.GetConversationByID(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tools []string
+	if conversation.AllowedTools != nil {
+		json.Unmarshal([]byte(*conversation.AllowedTools), &tools)
+	}
+	if !slices.Contains(tools, "submit_review") || !slices.Contains(tools, "keyword_search") || slices.Contains(tools, "bash") {
+		t.Errorf("expected read-only tools and submit_review, got %v", tools)
+	}
+	var prompt string
+	for _, msg := range h.messages() {
+		if msg.Type == string(db.MessageTypeUser) && msg.LlmData != nil {
+			prompt = string(*msg.LlmData)
+			break
+		}
+	}
+	for _, want := range []string{"https://github.com/o/r/pull/7", "Add sum", "alice", "Adds a sum helper.", "return a - b", "Focus on correctness."} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("expected %q in the first message: %s", want, prompt)
+		}
+	}
+
+	if w := do("POST", "/api/conversation/"+id+"/review/post", ""); w.Code != http.StatusConflict {
+		t.Errorf("expected 409 before a review is submitted, got %d", w.Code)
+	}
+
+	review := claudetool.Review{
+		Summary:  "Sum subtracts.",
+		Verdict:  "request_changes",
+		Comments: []claudetool.ReviewComment{{Path: "sum.go", Line: 1, Severity: "blocker", Body: "This subtracts."}},
+	}
+	note, err := submitPRReview(ctx, h.db, id, review)
+	if err != nil || !strings.Contains(note, "post it") {
+		t.Fatalf("expected the review to be recorded without posting, got %q, %v", note, err)
+	}
+
+	w = do("GET", "/api/conversation/"+id+"/review", "")
+	var got PRReviewResponse
+	json.Unmarshal(w.Body.Bytes(), &got)
+	if w.Code != http.StatusOK || got.HeadSHA != "abc123" || got.Review == nil || got.Review.Comments[0].Body != "This subtracts." {
+		t.Fatalf("unexpected review: %d %s", w.Code, w.Body.String())
+	}
+
+	// GitHub rejects the line comment, so the review is posted again with
+	// the comments in its body.
+	w = do("POST", "/api/conversation/"+id+"/review/post", "")
+	json.Unmarshal(w.Body.Bytes(), &got)
+	if w.Code != http.StatusOK || got.PostedURL != "https://github.com/o/r/pull/7#pullrequestreview-1" {
+		t.Fatalf("expected the review to be posted, got %d: %s", w.Code, w.Body.String())
+	}
+	data, _ := os.ReadFile(filepath.Join(bin, "posted.json"))
+	posts := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(posts) != 2 {
+		t.Fatalf("expected the review to be posted twice, got %q", posts)
+	}
+	var first, retry map[string]any
+	json.Unmarshal([]byte(posts[0]), &first)
+	json.Unmarshal([]byte(posts[1]), &retry)
+	if first["event"] != "REQUEST_CHANGES" || first["commit_id"] != "abc123" {
+		t.Errorf("unexpected review: %s", posts[0])
+	}
+	if retry["event"] != "COMMENT" || !strings.Contains(retry["body"].(string), "**sum.go:1**: **blocker**: This subtracts.") {
+		t.Errorf("unexpected fallback review: %s", posts[1])
+	}
+
+	if w := do("POST", "/api/conversation/"+id+"/review/post", ""); w.Code != http.StatusConflict {
+		t.Errorf("expected 409 once the review is posted, got %d", w.Code)
+	}
+	if w := do("GET", "/api/conversation/"+id+"/review", ""); w.Code != http.StatusOK {
+		t.Errorf("expected 200, got %d", w.Code)
+	}
+}
@@ -0,0 +1,273 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"shelley.exe.dev/db"
+	"shelley.exe.dev/db/generated"
+	"shelley.exe.dev/gitstate"
+	"shelley.exe.dev/llm"
+)
+
+// Each user message sent in a git worktree gets a checkpoint: a snapshot of
+// the worktree as it was when the message was sent, before the agent acted on
+// it. Rolling back to message N removes the messages after N and can restore
+// the worktree from the checkpoint of the first removed user message.
+
+// checkpointRef is the git ref holding a conversation's snapshot commits.
+func checkpointRef(conversationID string) string {
+	return "refs/shelley/" + conversationID
+}
+
+// isUserText reports whether message was typed by the user, as opposed to
+// carrying tool results.
+func isUserText(message llm.Message) bool {
+	return message.Role == llm.MessageRoleUser && slices.ContainsFunc(message.Content, func(c llm.Content) bool {
+		return c.Type == llm.ContentTypeText
+	})
+}
+
+// recordCheckpoint snapshots the conversation's worktree for the message msg
+// in the background, since it takes seconds in large repositories. The
+// conversation's tools wait for it with waitCheckpoint, so it's taken before
+// the agent acts.
+func (s *Server) recordCheckpoint(ctx context.Context, conversationID string, msg *generated.Message) {
+	ctx = context.WithoutCancel(ctx)
+	done := make(chan struct{})
+	s.checkpointMu.Lock()
+	previous := s.checkpoints[conversationID]
+	s.checkpoints[conversationID] = done
+	s.checkpointMu.Unlock()
+	go func() {
+		defer func() {
+			s.checkpointMu.Lock()
+			if s.checkpoints[conversationID] == done {
+				delete(s.checkpoints, conversationID)
+			}
+			s.checkpointMu.Unlock()
+			close(done)
+		}()
+		// Snapshots under the same ref are taken in order
+		if previous != nil {
+			<-previous
+		}
+		s.takeCheckpoint(ctx, conversationID, msg)
+	}()
+}
+
+// waitCheckpoint waits for the conversation's checkpoint being taken, if
+// any, or for ctx to be done.
+func (s *Server) waitCheckpoint(ctx context.Context, conversationID string) {
+	s.checkpointMu.Lock()
+	done := s.checkpoints[conversationID]
+	s.checkpointMu.Unlock()
+	if done == nil {
+		return
+	}
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+}
+
+// takeCheckpoint snapshots the conversation's worktree for the message msg.
+// Failures are logged; a missing checkpoint only limits what rollback can restore.
+func (s *Server) takeCheckpoint(ctx context.Context, conversationID string, msg *generated.Message) {
+	conversation, err := s.db.GetConversationByID(ctx, conversationID)
+	if err != nil || conversation.Cwd == nil || *conversation.Cwd == "" {
+		return
+	}
+	cwd := *conversation.Cwd
+
+	commit, err := gitstate.Snapshot(cwd, checkpointRef(conversationID), fmt.Sprintf("shelley checkpoint %s/%d", conversationID, msg.SequenceID))
+	if errors.Is(err, gitstate.ErrNotRepo) {
+		return
+	}
+	if err != nil {
+		s.logger.Warn("Failed to snapshot workspace", "conversationID", conversationID, "cwd", cwd, "error", err)
+		return
+	}
+	if err := s.db.QueriesTx(ctx, func(q *generated.Queries) error {
+		return q.CreateCheckpoint(ctx, generated.CreateCheckpointParams{
+			ConversationID: conversationID,
+			SequenceID:     msg.SequenceID,
+			Cwd:            cwd,
+			GitCommit:      commit,
+		})
+	}); err != nil {
+		s.logger.Warn("Failed to record checkpoint", "conversationID", conversationID, "error", err)
+	}
+}
+
+// dropCheckpoints deletes the conversation's checkpoints and the refs
+// holding their snapshots, once the conversation is deleted or archived, so
+// git can collect the snapshots.
+func (s *Server) dropCheckpoints(ctx context.Context, conversation *generated.Conversation) {
+	conversationID := conversation.ConversationID
+	s.waitCheckpoint(ctx, conversationID)
+	var checkpoints []generated.Checkpoint
+	err := s.db.QueriesTx(ctx, func(q *generated.Queries) error {
+		var err error
+		if checkpoints, err = q.ListCheckpoints(ctx, conversationID); err != nil {
+			return err
+		}
+		return q.DeleteCheckpoints(ctx, conversationID)
+	})
+	if err != nil {
+		s.logger.Warn("Failed to delete checkpoints", "conversationID", conversationID, "error", err)
+		return
+	}
+	// The working directory may have snapshots but no checkpoints left
+	var dirs []string
+	if conversation.Cwd != nil {
+		dirs = append(dirs, *conversation.Cwd)
+	}
+	for _, checkpoint := range checkpoints {
+		if !slices.Contains(dirs, checkpoint.Cwd) {
+			dirs = append(dirs, checkpoint.Cwd)
+		}
+	}
+	for _, dir := range dirs {
+		if dir == "" {
+			continue
+		}
+		if err := gitstate.DeleteSnapshots(dir, checkpointRef(conversationID)); err != nil && !errors.Is(err, gitstate.ErrNotRepo) {
+			s.logger.Warn("Failed to delete workspace snapshots", "conversationID", conversationID, "dir", dir, "error", err)
+		}
+	}
+}
+
+// agentBusy reports whether the agent is in the middle of a turn, given the
+// conversation's messages. Interrupted conversations, which have no loop
+// running, are not busy.
+func (s *Server) agentBusy(conversationID string, messages []generated.Message) bool {
+	return s.conversationLoopRunning(conversationID) && agentWorking(toAPIMessages(messages))
+}
+
+// RollbackRequest is the body of POST /api/conversation/<id>/rollback.
+type RollbackRequest struct {
+	// SequenceID is the last message to keep.
+	SequenceID int64 `json:"sequence_id"`
+	// RestoreWorkspace also restores the worktree to how it was when the
+	// first removed user message was sent.
+	RestoreWorkspace bool `json:"restore_workspace"`
+}
+
+// handleRollbackConversation handles POST /conversation/<id>/rollback
+func (s *Server) handleRollbackConversation(w http.ResponseWriter, r *http.Request, conversationID string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req RollbackRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	if _, err := s.db.GetConversationByID(ctx, conversationID); err != nil {
+		http.Error(w, "Conversation not found", http.StatusNotFound)
+		return
+	}
+
+	var messages []generated.Message
+	err := s.db.Queries(ctx, func(q *generated.Queries) error {
+		var err error
+		messages, err = q.ListMessages(ctx, conversationID)
+		return err
+	})
+	if err != nil {
+		s.logger.Error("Failed to list messages for rollback", "conversationID", conversationID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if s.agentBusy(conversationID, messages) {
+		http.Error(w, "Agent is working; cancel or wait for the turn to end", http.StatusConflict)
+		return
+	}
+
+	i := slices.IndexFunc(messages, func(m generated.Message) bool { return m.SequenceID == req.SequenceID })
+	if i < 0 {
+		http.Error(w, "Message not found", http.StatusBadRequest)
+		return
+	}
+	kept := messages[:i+1]
+	if slices.ContainsFunc(kept, func(m generated.Message) bool { return m.Type == string(db.MessageTypeUser) }) && agentWorking(toAPIMessages(kept)) {
+		http.Error(w, "Can only roll back to the end of an agent turn", http.StatusBadRequest)
+		return
+	}
+
+	response := map[string]string{"status": "rolled_back"}
+	if req.RestoreWorkspace {
+		backup, err := s.restoreCheckpoint(ctx, conversationID, req.SequenceID)
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "No workspace checkpoint to restore", http.StatusConflict)
+			return
+		}
+		if err != nil {
+			s.logger.Error("Failed to restore workspace", "conversationID", conversationID, "error", err)
+			http.Error(w, "Failed to restore workspace", http.StatusInternalServerError)
+			return
+		}
+		response["backup_commit"] = backup
+	}
+
+	if err := s.db.RollbackConversation(ctx, conversationID, req.SequenceID); err != nil {
+		s.logger.Error("Failed to roll back conversation", "conversationID", conversationID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	// The loop's in-memory history still has the removed messages
+	s.mu.Lock()
+	manager, ok := s.activeConversations[conversationID]
+	s.mu.Unlock()
+	if ok {
+		manager.Reset()
+	}
+	go s.broadcastConversationUpdate(context.WithoutCancel(ctx), conversationID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// restoreCheckpoint restores the worktree from the first checkpoint after
+// sequenceID, returning a snapshot of the worktree taken just beforehand so
+// the restore itself can be undone. It returns sql.ErrNoRows if there is no
+// such checkpoint.
+func (s *Server) restoreCheckpoint(ctx context.Context, conversationID string, sequenceID int64) (string, error) {
+	var checkpoint generated.Checkpoint
+	err := s.db.Queries(ctx, func(q *generated.Queries) error {
+		var err error
+		checkpoint, err = q.GetCheckpointAfter(ctx, generated.GetCheckpointAfterParams{
+			ConversationID: conversationID,
+			SequenceID:     sequenceID,
+		})
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+
+	backup, err := gitstate.Snapshot(checkpoint.Cwd, checkpointRef(conversationID), fmt.Sprintf("shelley backup before rollback of %s to %d", conversationID, sequenceID))
+	if err != nil {
+		return "", fmt.Errorf("failed to back up workspace: %w", err)
+	}
+	err = gitstate.RestoreSnapshot(checkpoint.Cwd, checkpoint.GitCommit)
+	s.invalidateGitDiffs(checkpoint.Cwd)
+	if err != nil {
+		return "", fmt.Errorf("failed to restore snapshot %s (backup %s): %w", checkpoint.GitCommit, backup, err)
+	}
+	if err := s.db.UpdateConversationCwdAndGitOrigin(ctx, conversationID, checkpoint.Cwd, gitstate.GetGitOrigin(checkpoint.Cwd)); err != nil {
+		return "", fmt.Errorf("failed to restore working directory: %w", err)
+	}
+	return backup, nil
+}
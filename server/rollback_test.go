@@ -0,0 +1,153 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"shelley.exe.dev/db"
+	"shelley.exe.dev/db/generated"
+	"shelley.exe.dev/llm"
+)
+
+func (h *TestHarness) rollback(body string) *httptest.ResponseRecorder {
+	h.t.Helper()
+	req := httptest.NewRequest("POST", "/api/conversation/"+h.convID+"/rollback", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	h.server.handleRollbackConversation(w, req, h.convID)
+	return w
+}
+
+func (h *TestHarness) messages() []generated.Message {
+	h.t.Helper()
+	var messages []generated.Message
+	if err := h.db.Queries(context.Background(), func(q *generated.Queries) error {
+		var err error
+		messages, err = q.ListMessages(context.Background(), h.convID)
+		return err
+	}); err != nil {
+		h.t.Fatal(err)
+	}
+	return messages
+}
+
+func TestRollbackConversation(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+
+	dir := t.TempDir()
+	for _, args := range [][]string{
+		{"init"},
+		{"config", "user.email", "test@example.com"},
+		{"config", "user.name", "Test"},
+	} {
+		if out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	file := filepath.Join(dir, "file.txt")
+	if err := os.WriteFile(file, []byte("v1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	h.NewConversation("echo: first", dir)
+	h.WaitResponse()
+	firstTurn := h.messages()
+	endOfFirstTurn := firstTurn[len(firstTurn)-1].SequenceID
+
+	h.Chat("bash: echo v2 > file.txt")
+	h.WaitResponse()
+	if data, _ := os.ReadFile(file); string(data) != "v2\n" {
+		t.Fatalf("expected the agent to change the file, got %q", data)
+	}
+
+	if w := h.rollback(`{"sequence_id": 999}`); w.Code != http.StatusBadRequest {
+		t.Errorf("rollback to unknown message: expected 400, got %d", w.Code)
+	}
+	if w := h.rollback(fmt.Sprintf(`{"sequence_id": %d}`, endOfFirstTurn+1)); w.Code != http.StatusBadRequest {
+		t.Errorf("rollback into the middle of a turn: expected 400, got %d", w.Code)
+	}
+
+	// The loop may still be finishing the turn after the response is recorded.
+	var w *httptest.ResponseRecorder
+	deadline := time.Now().Add(h.timeout)
+	for {
+		w = h.rollback(fmt.Sprintf(`{"sequence_id": %d, "restore_workspace": true}`, endOfFirstTurn))
+		if w.Code != http.StatusConflict || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if w.Code != http.StatusOK {
+		t.Fatalf("rollback: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp map[string]string
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp["backup_commit"] == "" {
+		t.Error("expected a backup commit of the workspace before the rollback")
+	}
+
+	if data, _ := os.ReadFile(file); string(data) != "v1\n" {
+		t.Errorf("expected the file to be restored, got %q", data)
+	}
+	messages := h.messages()
+	if len(messages) != len(firstTurn) || messages[len(messages)-1].SequenceID != endOfFirstTurn {
+		t.Fatalf("expected %d messages ending at %d, got %d", len(firstTurn), endOfFirstTurn, len(messages))
+	}
+	conv, err := h.db.GetConversationByID(context.Background(), h.convID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conv.AgentWorking {
+		t.Error("expected agent_working to be cleared")
+	}
+
+	// The conversation continues from the rolled-back history
+	h.responsesCount = 1
+	h.Chat("echo: again")
+	if got := h.WaitResponse(); got != "again" {
+		t.Errorf("expected response after rollback, got %q", got)
+	}
+	var userTexts []string
+	for _, msg := range h.messages() {
+		if msg.Type != string(db.MessageTypeUser) || msg.LlmData == nil {
+			continue
+		}
+		var llmMsg llm.Message
+		if err := json.Unmarshal([]byte(*msg.LlmData), &llmMsg); err != nil {
+			t.Fatal(err)
+		}
+		for _, c := range llmMsg.Content {
+			if c.Type == llm.ContentTypeText {
+				userTexts = append(userTexts, c.Text)
+			}
+		}
+	}
+	if strings.Join(userTexts, "|") != "echo: first|echo: again" {
+		t.Errorf("unexpected user messages after rollback: %v", userTexts)
+	}
+
+	// Deleting the conversation deletes its snapshots
+	ref := checkpointRef(h.convID)
+	if out, err := exec.Command("git", "-C", dir, "rev-parse", "--verify", "--quiet", ref).CombinedOutput(); err != nil {
+		t.Fatalf("expected the conversation's snapshots: %v\n%s", err, out)
+	}
+	w = httptest.NewRecorder()
+	h.server.handleDeleteConversation(w, httptest.NewRequest("POST", "/api/conversation/"+h.convID+"/delete", nil), h.convID)
+	if w.Code != http.StatusOK {
+		t.Fatalf("delete: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if out, err := exec.Command("git", "-C", dir, "rev-parse", "--verify", "--quiet", ref).CombinedOutput(); err == nil {
+		t.Errorf("expected the snapshots deleted, got %s", out)
+	}
+}
@@ -0,0 +1,93 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"shelley.exe.dev/db/generated"
+	"shelley.exe.dev/llm"
+)
+
+// conversationSampling returns the sampling parameters of a conversation's
+// LLM requests, or nil if it leaves them to the model.
+func conversationSampling(conv generated.Conversation) *llm.Sampling {
+	if conv.Sampling == nil {
+		return nil
+	}
+	var sampling llm.Sampling
+	if err := json.Unmarshal([]byte(*conv.Sampling), &sampling); err != nil {
+		return nil
+	}
+	return &sampling
+}
+
+// handleConversationSampling handles GET and POST
+// /conversation/<id>/sampling, the sampling parameters of the
+// conversation's LLM requests. POST replaces them; null clears them. A
+// running agent uses them from its next request.
+func (s *Server) handleConversationSampling(w http.ResponseWriter, r *http.Request, conversationID string) {
+	ctx := r.Context()
+	var conversation generated.Conversation
+	err := s.db.Queries(ctx, func(q *generated.Queries) error {
+		var err error
+		conversation, err = q.GetConversation(ctx, conversationID)
+		return err
+	})
+	if err != nil {
+		http.Error(w, "Conversation not found", http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(conversationSampling(conversation))
+	case http.MethodPost:
+		var sampling *llm.Sampling
+		if err := json.NewDecoder(r.Body).Decode(&sampling); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+		if sampling != nil {
+			if err := sampling.Validate(); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		if err := s.setConversationSampling(ctx, conversationID, sampling); err != nil {
+			s.logger.Error("Failed to update conversation sampling", "conversationID", conversationID, "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(sampling)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// setConversationSampling stores a conversation's sampling parameters and
+// passes them to its manager, if one is active.
+func (s *Server) setConversationSampling(ctx context.Context, conversationID string, sampling *llm.Sampling) error {
+	var data *string
+	if sampling != nil {
+		encoded, err := json.Marshal(sampling)
+		if err != nil {
+			return err
+		}
+		str := string(encoded)
+		data = &str
+	}
+	if err := s.db.UpdateConversationSampling(ctx, conversationID, data); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	manager, ok := s.activeConversations[conversationID]
+	s.mu.Unlock()
+	if ok {
+		manager.SetSampling(sampling)
+	}
+	return nil
+}
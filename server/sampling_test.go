@@ -0,0 +1,64 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func (h *TestHarness) setSampling(body string) *httptest.ResponseRecorder {
+	mux := http.NewServeMux()
+	h.server.RegisterRoutes(mux)
+	req := httptest.NewRequest("POST", "/api/conversation/"+h.convID+"/sampling", strings.NewReader(body))
+	req.Header.Set("X-Shelley-Request", "1")
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	return w
+}
+
+func TestConversationSampling(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+	h.NewConversation("echo: first", t.TempDir())
+	h.WaitResponse()
+	if req := h.llm.GetLastRequest(); req.Sampling != nil {
+		t.Fatalf("expected no sampling parameters by default, got %+v", req.Sampling)
+	}
+
+	if w := h.setSampling(`{"temperature": 3}`); w.Code != http.StatusBadRequest {
+		t.Errorf("expected an out-of-range temperature to be rejected, got %d", w.Code)
+	}
+	if w := h.setSampling(`{"temperature": 0, "max_tokens": 100, "stop_sequences": ["END"]}`); w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	conv, err := h.db.GetConversationByID(t.Context(), h.convID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sampling := conversationSampling(*conv); sampling == nil || sampling.Temperature == nil || *sampling.Temperature != 0 || sampling.MaxTokens != 100 {
+		t.Fatalf("expected the sampling parameters to be stored, got %+v", sampling)
+	}
+
+	// The running agent uses them from its next request
+	h.Chat("echo: second")
+	h.WaitResponse()
+	sampling := h.llm.GetLastRequest().Sampling
+	if sampling == nil || sampling.Temperature == nil || *sampling.Temperature != 0 || sampling.MaxTokens != 100 || len(sampling.StopSequences) != 1 {
+		t.Fatalf("unexpected sampling parameters: %+v", sampling)
+	}
+	data, _ := json.Marshal(sampling)
+	if !strings.Contains(string(data), `"temperature":0`) {
+		t.Errorf("expected a zero temperature to be kept, got %s", data)
+	}
+
+	if w := h.setSampling(`null`); w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	h.Chat("echo: third")
+	h.WaitResponse()
+	if req := h.llm.GetLastRequest(); req.Sampling != nil {
+		t.Errorf("expected the sampling parameters to be cleared, got %+v", req.Sampling)
+	}
+}
@@ -0,0 +1,70 @@
+package server
+
+import (
+	"encoding/json"
+
+	"shelley.exe.dev/db/generated"
+	"shelley.exe.dev/filepolicy"
+	"shelley.exe.dev/sandbox"
+)
+
+// SandboxOptions control whether and how a conversation's tools are sandboxed.
+// They are fixed when the conversation is created.
+type SandboxOptions struct {
+	Enabled bool `json:"enabled"`
+	// AllowNetwork lets sandboxed commands reach the network.
+	AllowNetwork bool `json:"allow_network,omitempty"`
+	// WritablePaths are directories the tools can write to besides the workspace.
+	WritablePaths []string `json:"writable_paths,omitempty"`
+	// ReadablePaths are directories the tools can read besides the
+	// workspace, the writable paths and the system directories, such as a
+	// toolchain or module cache in a home directory.
+	ReadablePaths []string `json:"readable_paths,omitempty"`
+}
+
+// SetDefaultSandbox sets the sandbox options of conversations created without any.
+func (s *Server) SetDefaultSandbox(opts SandboxOptions) {
+	s.defaultSandbox = opts
+}
+
+// conversationSandbox returns the sandbox options recorded for a conversation,
+// or nil if it is not sandboxed.
+func conversationSandbox(conv generated.Conversation) *SandboxOptions {
+	if conv.Sandbox == nil {
+		return nil
+	}
+	var opts SandboxOptions
+	if err := json.Unmarshal([]byte(*conv.Sandbox), &opts); err != nil || !opts.Enabled {
+		return nil
+	}
+	return &opts
+}
+
+// sandboxPolicy returns the policy for a sandboxed conversation working in
+// cwd. The workspace is the enclosing git repository, or cwd itself.
+func sandboxPolicy(conversationID, cwd string, opts SandboxOptions) *sandbox.Policy {
+	policy := &sandbox.Policy{Network: opts.AllowNetwork}
+	if cwd != "" {
+		workspace := cwd
+		if root, err := getGitRoot(cwd); err == nil {
+			workspace = root
+		}
+		policy.Writable = append(policy.Writable, workspace)
+	}
+	policy.Writable = append(policy.Writable, opts.WritablePaths...)
+	policy.Readable = opts.ReadablePaths
+	// Without a private /tmp, each command gets an empty one
+	if tmp, err := sandbox.TmpDir(conversationID); err == nil {
+		policy.Tmp = tmp
+	}
+	return policy
+}
+
+// sandboxFilePolicy confines the file tools of a sandboxed conversation,
+// which read and write files themselves rather than by running commands,
+// to the directories sandboxed commands see. The system directories and
+// /tmp are left out: the tools have no need of the former, and the
+// sandbox's /tmp is a different directory from the server's.
+func sandboxFilePolicy(policy *filepolicy.Policy, sb *sandbox.Policy) *filepolicy.Policy {
+	return policy.Within(sb.Roots())
+}
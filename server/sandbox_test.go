@@ -0,0 +1,94 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"shelley.exe.dev/sandbox"
+)
+
+func TestSandboxedConversation(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+
+	dir := t.TempDir()
+	work := filepath.Join(dir, "work")
+	if err := os.Mkdir(work, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	body, _ := json.Marshal(ChatRequest{
+		Message: "bash: echo in > inside.txt; echo out > ../outside.txt; echo done",
+		Model:   "predictable",
+		Cwd:     work,
+		Sandbox: &SandboxOptions{Enabled: true},
+	})
+	w := httptest.NewRecorder()
+	h.server.handleNewConversation(w, httptest.NewRequest("POST", "/api/conversations/new", strings.NewReader(string(body))))
+
+	if !sandbox.Available() {
+		if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "sandbox unavailable") {
+			t.Fatalf("expected 400 without bwrap, got %d: %s", w.Code, w.Body.String())
+		}
+		return
+	}
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		ConversationID string `json:"conversation_id"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	h.convID = resp.ConversationID
+	h.WaitToolResult()
+
+	conv, err := h.db.GetConversationByID(context.Background(), h.convID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts := conversationSandbox(*conv); opts == nil || !opts.Enabled {
+		t.Errorf("expected sandbox options to be recorded, got %v", conv.Sandbox)
+	}
+	if _, err := os.Stat(filepath.Join(work, "inside.txt")); err != nil {
+		t.Errorf("expected inside.txt to be written: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "outside.txt")); !os.IsNotExist(err) {
+		t.Errorf("expected outside.txt not to be written, got %v", err)
+	}
+}
+
+func TestSandboxPolicy(t *testing.T) {
+	dir := t.TempDir()
+	policy := sandboxPolicy("c1", dir, SandboxOptions{Enabled: true, WritablePaths: []string{"/var/cache/x"}, ReadablePaths: []string{"/opt/go"}})
+	if len(policy.Writable) != 2 || policy.Writable[0] != dir || policy.Writable[1] != "/var/cache/x" {
+		t.Errorf("unexpected writable directories: %v", policy.Writable)
+	}
+	if len(policy.Readable) != 1 || policy.Readable[0] != "/opt/go" {
+		t.Errorf("unexpected readable directories: %v", policy.Readable)
+	}
+	// The file tools see what sandboxed commands see
+	files := sandboxFilePolicy(nil, policy)
+	if err := files.CheckRead(filepath.Join(dir, "main.go")); err != nil {
+		t.Errorf("expected the workspace to be readable: %v", err)
+	}
+	if err := files.CheckRead("/opt/go/src/fmt/print.go"); err != nil {
+		t.Errorf("expected a readable path to be readable: %v", err)
+	}
+	if err := files.CheckRead("/etc/shadow"); err == nil {
+		t.Error("expected files outside the sandbox not to be readable")
+	}
+	if policy.Network {
+		t.Error("expected network to be off by default")
+	}
+	if policy.Tmp == "" {
+		t.Error("expected a private /tmp")
+	}
+	os.RemoveAll(policy.Tmp)
+}
@@ -0,0 +1,313 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"shelley.exe.dev/claudetool"
+	"shelley.exe.dev/db"
+	"shelley.exe.dev/db/generated"
+	"shelley.exe.dev/embed"
+	"shelley.exe.dev/llm"
+)
+
+const (
+	embedBatchSize     = 64
+	maxEmbedChars      = 4000
+	defaultSearchLimit = 10
+	maxSearchLimit     = 100
+	recallLimit        = 5
+	indexInterval      = time.Minute
+)
+
+// SetEmbedder enables semantic search with the given embedding provider.
+// Must be called before the server starts handling requests.
+func (s *Server) SetEmbedder(p embed.Provider) {
+	s.embedder = p
+}
+
+// SemanticSearchResult is a single hit returned by GET /api/search.
+type SemanticSearchResult struct {
+	SourceType     string  `json:"source_type"`
+	SourceID       string  `json:"source_id"`
+	ConversationID *string `json:"conversation_id"`
+	Content        string  `json:"content"`
+	Score          float64 `json:"score"`
+}
+
+type pendingEmbedding struct {
+	params generated.CreateEmbeddingParams
+	text   string
+}
+
+// runIndexer embeds new messages, memories and the documentation of the
+// repositories searched, batch by batch, until ctx is done. Searches serve
+// what is already embedded rather than wait for it, so recording messages
+// and searching never wait on the embedding provider.
+func (s *Server) runIndexer(ctx context.Context) {
+	if s.embedder == nil {
+		return
+	}
+	ticker := time.NewTicker(indexInterval)
+	defer ticker.Stop()
+	for {
+		if err := s.indexPending(ctx); err != nil && ctx.Err() == nil {
+			s.logger.Warn("Failed to embed messages and memories", "error", err)
+		}
+		s.indexQueuedDocs(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-s.indexWake:
+		}
+	}
+}
+
+// wakeIndexer has runIndexer look for something to embed now.
+func (s *Server) wakeIndexer() {
+	select {
+	case s.indexWake <- struct{}{}:
+	default:
+	}
+}
+
+// indexPending embeds all messages and memories that have no embedding for the
+// current model yet, a batch at a time.
+func (s *Server) indexPending(ctx context.Context) error {
+	for {
+		done, err := s.indexPendingBatch(ctx)
+		if done || err != nil {
+			return err
+		}
+	}
+}
+
+// indexPendingBatch embeds the next batch of messages and memories without an
+// embedding, reporting whether there were none.
+func (s *Server) indexPendingBatch(ctx context.Context) (bool, error) {
+	s.indexMu.Lock()
+	defer s.indexMu.Unlock()
+
+	model := s.embedder.Model()
+	var messages []generated.Message
+	var memories []generated.Memory
+	err := s.db.Queries(ctx, func(q *generated.Queries) error {
+		var err error
+		messages, err = q.ListUnembeddedMessages(ctx, generated.ListUnembeddedMessagesParams{Model: model, Limit: embedBatchSize})
+		if err != nil {
+			return err
+		}
+		memories, err = q.ListUnembeddedMemories(ctx, generated.ListUnembeddedMemoriesParams{Model: model, Limit: embedBatchSize})
+		return err
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to list unembedded sources: %w", err)
+	}
+	if len(messages) == 0 && len(memories) == 0 {
+		return true, nil
+	}
+
+	var pending []pendingEmbedding
+	for _, m := range messages {
+		conversationID := m.ConversationID
+		pending = append(pending, pendingEmbedding{
+			params: generated.CreateEmbeddingParams{
+				SourceType:     string(db.EmbeddingSourceMessage),
+				SourceID:       m.MessageID,
+				ConversationID: &conversationID,
+			},
+			text: messageText(m),
+		})
+	}
+	for _, m := range memories {
+		pending = append(pending, pendingEmbedding{
+			params: generated.CreateEmbeddingParams{
+				SourceType:     string(db.EmbeddingSourceMemory),
+				SourceID:       m.MemoryID,
+				ConversationID: m.ConversationID,
+			},
+			text: m.Content,
+		})
+	}
+
+	var texts []string
+	for i := range pending {
+		pending[i].text = truncateForEmbedding(pending[i].text)
+		if pending[i].text != "" {
+			texts = append(texts, pending[i].text)
+		}
+	}
+	vectors, err := s.embedTexts(ctx, texts)
+	if err != nil {
+		return false, err
+	}
+	// The triggers copying the vectors to the index come first
+	if len(vectors) > 0 {
+		if err := s.db.EnsureEmbeddingIndex(ctx, len(vectors[0])); err != nil {
+			return false, err
+		}
+	}
+
+	err = s.db.QueriesTx(ctx, func(q *generated.Queries) error {
+		for _, p := range pending {
+			p.params.Model = model
+			p.params.Content = p.text
+			// Sources without text are stored with a NULL vector so they are skipped next time.
+			if p.text != "" {
+				p.params.Vector = embed.Encode(vectors[0])
+				vectors = vectors[1:]
+			}
+			if err := q.CreateEmbedding(ctx, p.params); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to store embeddings: %w", err)
+	}
+	return false, nil
+}
+
+// embedTexts embeds texts, at most embedBatchSize per request.
+func (s *Server) embedTexts(ctx context.Context, texts []string) ([][]float32, error) {
+	var vectors [][]float32
+	for start := 0; start < len(texts); start += embedBatchSize {
+		batch, err := s.embedder.Embed(ctx, texts[start:min(start+embedBatchSize, len(texts))])
+		if err != nil {
+			return nil, err
+		}
+		vectors = append(vectors, batch...)
+	}
+	return vectors, nil
+}
+
+// messageText returns the text content of a stored user or agent message.
+func messageText(msg generated.Message) string {
+	if msg.LlmData == nil {
+		return ""
+	}
+	var m llm.Message
+	if err := json.Unmarshal([]byte(*msg.LlmData), &m); err != nil {
+		return ""
+	}
+	var parts []string
+	for _, c := range m.Content {
+		if c.Type == llm.ContentTypeText && strings.TrimSpace(c.Text) != "" {
+			parts = append(parts, c.Text)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+func truncateForEmbedding(text string) string {
+	text = strings.TrimSpace(text)
+	if len(text) <= maxEmbedChars {
+		return text
+	}
+	// Back up to a rune boundary
+	end := maxEmbedChars
+	for end > 0 && !utf8.RuneStart(text[end]) {
+		end--
+	}
+	return text[:end]
+}
+
+// semanticSearch returns the embedded messages and memories closest in meaning
+// to query, skipping messages from excludeConversationID. Those not embedded
+// yet are left to runIndexer.
+func (s *Server) semanticSearch(ctx context.Context, query, excludeConversationID string, limit int64) ([]SemanticSearchResult, error) {
+	s.wakeIndexer()
+	vectors, err := s.embedder.Embed(ctx, []string{query})
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.SearchEmbeddings(ctx, db.SearchEmbeddingsParams{
+		Query:                 embed.Encode(vectors[0]),
+		Model:                 s.embedder.Model(),
+		ExcludeConversationID: excludeConversationID,
+		Limit:                 limit,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]SemanticSearchResult, len(rows))
+	for i, r := range rows {
+		results[i] = SemanticSearchResult{
+			SourceType:     r.SourceType,
+			SourceID:       r.SourceID,
+			ConversationID: r.ConversationID,
+			Content:        r.Content,
+			Score:          1 - r.Distance,
+		}
+	}
+	return results, nil
+}
+
+// recall adapts semanticSearch for the recall tool of a conversation.
+func (s *Server) recall(conversationID string) func(ctx context.Context, query string) ([]claudetool.RecallResult, error) {
+	return func(ctx context.Context, query string) ([]claudetool.RecallResult, error) {
+		results, err := s.semanticSearch(ctx, query, conversationID, recallLimit)
+		if err != nil {
+			return nil, err
+		}
+		out := make([]claudetool.RecallResult, len(results))
+		for i, r := range results {
+			out[i] = claudetool.RecallResult{
+				Source:  r.SourceType,
+				Content: r.Content,
+				Score:   r.Score,
+			}
+			if r.ConversationID != nil {
+				out[i].ConversationID = *r.ConversationID
+			}
+		}
+		return out, nil
+	}
+}
+
+// handleSemanticSearch handles GET /api/search?q=<query>&limit=<n>
+func (s *Server) handleSemanticSearch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.embedder == nil {
+		http.Error(w, "semantic search is not configured", http.StatusServiceUnavailable)
+		return
+	}
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if query == "" {
+		http.Error(w, "q is required", http.StatusBadRequest)
+		return
+	}
+	limit := int64(defaultSearchLimit)
+	if l := r.URL.Query().Get("limit"); l != "" {
+		n, err := strconv.ParseInt(l, 10, 64)
+		if err != nil || n <= 0 || n > maxSearchLimit {
+			http.Error(w, fmt.Sprintf("limit must be between 1 and %d", maxSearchLimit), http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	results, err := s.semanticSearch(r.Context(), query, "", limit)
+	if err != nil {
+		s.logger.Error("Failed semantic search", "error", err)
+		http.Error(w, "search failed", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(results); err != nil {
+		s.logger.Error("Failed to encode search results", "error", err)
+	}
+}
@@ -0,0 +1,104 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"shelley.exe.dev/db"
+	"shelley.exe.dev/embed"
+)
+
+func TestSemanticSearch(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+	h.server.SetEmbedder(&embed.Hash{})
+
+	h.NewConversation("echo: the sqlite migration failed on the settings table", "")
+	h.WaitResponse()
+	migrationConv := h.ConversationID()
+	h.NewConversation("echo: deploying requires the corporate VPN", "")
+	h.WaitResponse()
+	memory, err := h.db.CreateMemory(context.Background(), "/tmp", "frontend uses pnpm not npm", db.MemorySourceUser, nil)
+	if err != nil {
+		t.Fatalf("failed to create memory: %v", err)
+	}
+
+	search := func(q string) []SemanticSearchResult {
+		t.Helper()
+		req := httptest.NewRequest("GET", "/api/search?limit=3&q="+strings.ReplaceAll(q, " ", "+"), nil)
+		w := httptest.NewRecorder()
+		h.server.handleSemanticSearch(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+		}
+		var results []SemanticSearchResult
+		if err := json.Unmarshal(w.Body.Bytes(), &results); err != nil {
+			t.Fatalf("failed to parse results: %v", err)
+		}
+		return results
+	}
+
+	// Searching serves what the background indexer has embedded so far
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go h.server.runIndexer(ctx)
+	var results []SemanticSearchResult
+	for deadline := time.Now().Add(h.timeout); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		results = search("sqlite migration failed")
+		if len(results) > 0 && results[0].SourceType == string(db.EmbeddingSourceMessage) {
+			break
+		}
+	}
+	if len(results) == 0 || !strings.Contains(results[0].Content, "sqlite migration") {
+		t.Fatalf("expected migration message first, got %+v", results)
+	}
+	if err := h.server.indexPending(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if results[0].ConversationID == nil || *results[0].ConversationID != migrationConv {
+		t.Errorf("expected conversation %s, got %v", migrationConv, results[0].ConversationID)
+	}
+
+	results = search("pnpm or npm")
+	if len(results) == 0 || results[0].SourceType != string(db.EmbeddingSourceMemory) || results[0].SourceID != memory.MemoryID {
+		t.Fatalf("expected memory first, got %+v", results)
+	}
+
+	// Recall from the migration conversation excludes its own messages
+	recalled, err := h.server.recall(migrationConv)(context.Background(), "sqlite migration failed")
+	if err != nil {
+		t.Fatalf("recall failed: %v", err)
+	}
+	for _, r := range recalled {
+		if r.ConversationID == migrationConv {
+			t.Errorf("recall returned a result from the current conversation: %+v", r)
+		}
+	}
+
+	// Deleting a memory removes it from the index
+	if err := h.db.DeleteMemory(context.Background(), memory.MemoryID); err != nil {
+		t.Fatalf("failed to delete memory: %v", err)
+	}
+	for _, r := range search("pnpm or npm") {
+		if r.SourceID == memory.MemoryID {
+			t.Errorf("deleted memory still returned")
+		}
+	}
+}
+
+func TestSemanticSearchNotConfigured(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+
+	req := httptest.NewRequest("GET", "/api/search?q=anything", nil)
+	w := httptest.NewRecorder()
+	h.server.handleSemanticSearch(w, req)
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503, got %d", w.Code)
+	}
+}
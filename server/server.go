@@ -13,17 +13,25 @@ import (
 	"path/filepath"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	"tailscale.com/util/singleflight"
 
+	"shelley.exe.dev/broadcast"
 	"shelley.exe.dev/claudetool"
+	"shelley.exe.dev/claudetool/browse"
 	"shelley.exe.dev/db"
 	"shelley.exe.dev/db/generated"
+	"shelley.exe.dev/devcontainer"
+	"shelley.exe.dev/embed"
+	"shelley.exe.dev/gitstate"
 	"shelley.exe.dev/llm"
 	"shelley.exe.dev/models"
+	"shelley.exe.dev/notify"
 	"shelley.exe.dev/subpub"
+	"shelley.exe.dev/transcribe"
 	"shelley.exe.dev/ui"
 )
 
@@ -49,6 +57,17 @@ type StreamResponse struct {
 	AgentWorking      bool                   `json:"agent_working"`
 	ContextWindowSize uint64                 `json:"context_window_size,omitempty"`
 	AssetHash         string                 `json:"asset_hash,omitempty"`
+	// HasMore is set on pages of a conversation's messages that don't reach
+	// its first message, or its latest for pages read forward. Pages that
+	// don't end with the latest message leave AgentWorking and
+	// ContextWindowSize unset.
+	HasMore bool `json:"has_more,omitempty"`
+	// ToolOutput is set on updates carrying output of a running tool, which
+	// have no messages or conversation.
+	ToolOutput *ToolOutput `json:"tool_output,omitempty"`
+	// Queue is set on updates carrying the conversation's queued messages,
+	// which likewise have no messages or conversation.
+	Queue *MessageQueue `json:"queue,omitempty"`
 }
 
 // LLMProvider is an interface for getting LLM services
@@ -67,7 +86,11 @@ func NewLLMServiceManager(cfg *LLMConfig, history *models.LLMRequestHistory) LLM
 		GeminiAPIKey:    cfg.GeminiAPIKey,
 		FireworksAPIKey: cfg.FireworksAPIKey,
 		Gateway:         cfg.Gateway,
+		Limits:          cfg.ModelLimits,
 		Logger:          cfg.Logger,
+
+		PredictableFixture:     cfg.PredictableFixture,
+		PredictableRecordModel: cfg.PredictableRecordModel,
 	}
 
 	manager, err := models.NewManager(modelConfig, history)
@@ -87,7 +110,7 @@ func toAPIMessages(messages []generated.Message) []APIMessage {
 	for i, msg := range messages {
 		var endOfTurnPtr *bool
 		if msg.LlmData != nil && msg.Type == string(db.MessageTypeAgent) {
-			if endOfTurn, ok := extractEndOfTurn(*msg.LlmData); ok {
+			if endOfTurn, ok := extractEndOfTurn(string(*msg.LlmData)); ok {
 				endOfTurnCopy := endOfTurn
 				endOfTurnPtr = &endOfTurnCopy
 			}
@@ -102,7 +125,7 @@ func toAPIMessages(messages []generated.Message) []APIMessage {
 			ConversationID: msg.ConversationID,
 			SequenceID:     msg.SequenceID,
 			Type:           msg.Type,
-			LlmData:        msg.LlmData,
+			LlmData:        (*string)(msg.LlmData),
 			UserData:       msg.UserData,
 			UsageData:      msg.UsageData,
 			CreatedAt:      msg.CreatedAt,
@@ -130,7 +153,8 @@ func calculateContextWindowSize(messages []APIMessage) uint64 {
 	// Find the last message with usage data
 	for i := len(messages) - 1; i >= 0; i-- {
 		msg := messages[i]
-		if msg.UsageData == nil {
+		// Summary usage describes the summarization request, not this conversation's context
+		if msg.UsageData == nil || msg.Type == string(db.MessageTypeSummary) {
 			continue
 		}
 		var usage llm.Usage
@@ -149,9 +173,10 @@ func agentWorking(messages []APIMessage) bool {
 		return false
 	}
 
-	// Find the last non-gitinfo message (gitinfo messages are passive notifications)
+	// Find the last non-gitinfo, non-summary message (both are passive
+	// notifications). A trailing system prompt has nothing after it to answer.
 	lastIdx := len(messages) - 1
-	for lastIdx >= 0 && messages[lastIdx].Type == string(db.MessageTypeGitInfo) {
+	for lastIdx >= 0 && (messages[lastIdx].Type == string(db.MessageTypeGitInfo) || messages[lastIdx].Type == string(db.MessageTypeSummary) || messages[lastIdx].Type == string(db.MessageTypeSystem)) {
 		lastIdx--
 	}
 	if lastIdx < 0 {
@@ -229,7 +254,7 @@ func isEndOfTurn(msg *generated.Message) bool {
 	if msg.LlmData == nil {
 		return false
 	}
-	endOfTurn, ok := extractEndOfTurn(*msg.LlmData)
+	endOfTurn, ok := extractEndOfTurn(string(*msg.LlmData))
 	if !ok {
 		return false
 	}
@@ -267,6 +292,45 @@ type Server struct {
 	assetHash           string
 	metaSubPub          *subpub.SubPub[generated.Conversation] // broadcasts conversation metadata changes
 	metaSeq             int64                                  // sequence number for metaSubPub
+	broadcaster         broadcast.Broadcaster                  // carries metaSubPub updates to other servers, if they share the database
+	embedder            embed.Provider                         // nil disables semantic search
+	transcriber         transcribe.Provider                    // nil disables voice input
+	indexMu             sync.Mutex                             // serializes embedding of messages, memories and docs
+	indexWake           chan struct{}                          // wakes runIndexer
+	bashRunRoot         string                                 // per-conversation records of running bash commands
+	processes           *claudetool.ProcessRegistry            // process groups of running tool commands
+	processMu           sync.Mutex                             // guards processManagers
+	processManagers     map[string]*claudetool.ProcessManager  // long-running processes, by conversation
+	coverageRoot        string                                 // stored coverage profiles, one per repository
+	deployRunRoot       string                                 // per-conversation records of deploys that restart the server
+	selfHealthURL       string                                 // where the server answers, to verify deploys of it
+	updateRoot          string                                 // record and download of the latest update
+	uploadDir           string                                 // where uploaded files are saved
+	artifactRoot        string                                 // content of published artifacts, by artifact ID
+	artifactMaxSize     int64                                  // largest artifact
+	artifactQuota       int64                                  // most bytes of artifacts a conversation has
+	artifactRetention   time.Duration                          // how long artifacts are kept
+	gitDiffs            *gitDiffCache                          // results of the git diff handlers
+	updateMu            sync.Mutex                             // serializes updates
+	shutdownGrace       time.Duration                          // how long turns in flight get to finish at shutdown
+	toolResultBlobSize  int                                    // tool result text above this is stored as a blob
+	draining            atomic.Bool                            // set at shutdown, when turns are refused
+	instanceID          string                                 // owner of the conversation leases taken here
+	recoveryPolicy      RecoveryPolicy
+	defaultSandbox      SandboxOptions
+	bridgeMu            sync.Mutex           // serializes mapping chat threads to conversations
+	bridgeSeenAt        map[string]time.Time // recently handled chat messages, see bridgeSeen
+	discordStatus       bridgeStatus         // Discord status messages of running turns
+	telegramStatus      bridgeStatus         // Telegram status messages of running turns
+	grpcAddr            string               // address of the gRPC API, if it's served
+	healthMu            sync.Mutex
+	health              map[string]ProviderHealth // latest health check of each provider
+
+	checkpointMu sync.Mutex
+	checkpoints  map[string]chan struct{} // closed when the conversation's latest checkpoint is taken
+
+	docRootMu sync.Mutex
+	docRoots  map[string]bool // repository roots queued for runIndexer to index
 }
 
 // NewServer creates a new server instance
@@ -283,6 +347,24 @@ func NewServer(database *db.DB, llmManager LLMProvider, toolSetConfig claudetool
 		requireHeader:       requireHeader,
 		links:               links,
 		metaSubPub:          subpub.New[generated.Conversation](),
+		bashRunRoot:         filepath.Join(os.TempDir(), "shelley-bash-runs"),
+		processes:           claudetool.NewProcessRegistry(filepath.Join(os.TempDir(), "shelley-processes")),
+		coverageRoot:        filepath.Join(os.TempDir(), "shelley-coverage"),
+		deployRunRoot:       filepath.Join(os.TempDir(), "shelley-deploys"),
+		updateRoot:          filepath.Join(os.TempDir(), "shelley-updates"),
+		uploadDir:           browse.ScreenshotDir,
+		artifactRoot:        filepath.Join(os.TempDir(), "shelley-artifacts"),
+		artifactMaxSize:     DefaultArtifactMaxSize,
+		artifactQuota:       DefaultArtifactQuota,
+		artifactRetention:   DefaultArtifactRetention,
+		gitDiffs:            newGitDiffCache(),
+		checkpoints:         make(map[string]chan struct{}),
+		indexWake:           make(chan struct{}, 1),
+		docRoots:            make(map[string]bool),
+		recoveryPolicy:      RecoveryAuto,
+		shutdownGrace:       DefaultShutdownGrace,
+		toolResultBlobSize:  DefaultToolResultBlobSize,
+		instanceID:          newInstanceID(),
 	}
 }
 
@@ -297,25 +379,108 @@ func (s *Server) RegisterRoutes(mux *http.ServeMux) {
 	mux.Handle("/api/conversations", gzipHandler(http.HandlerFunc(s.handleConversations)))
 	mux.Handle("/api/conversations/archived", gzipHandler(http.HandlerFunc(s.handleArchivedConversations)))
 	mux.Handle("/api/conversations/stream", http.HandlerFunc(s.handleConversationsStream)) // SSE, no gzip
+	mux.Handle("/api/conversations/interrupted", http.HandlerFunc(s.handleInterruptedConversations))
 	mux.Handle("/api/conversations/new", http.HandlerFunc(s.handleNewConversation)) // Small response
+	mux.Handle("POST /api/conversations/export", http.HandlerFunc(s.handleExportDataset))
+	mux.Handle("/api/conversations/review", http.HandlerFunc(s.handleReviewConversation))
+	mux.Handle("PATCH /api/conversations/{id}/slug", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.handleRenameConversation(w, r, r.PathValue("id"))
+	}))
+	mux.Handle("POST /api/conversations/{id}/regenerate", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.handleRegenerate(w, r, r.PathValue("id"))
+	}))
+	mux.Handle("GET /api/conversations/{id}/stats", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.handleConversationStats(w, r, r.PathValue("id"))
+	}))
+	mux.Handle("GET /api/conversations/{id}/timeline", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.handleConversationTimeline(w, r, r.PathValue("id"))
+	}))
+	mux.Handle("GET /api/conversations/{id}/files", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.handleConversationFiles(w, r, r.PathValue("id"))
+	}))
+	mux.Handle("GET /api/conversations/{id}/artifacts", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.handleArtifacts(w, r, r.PathValue("id"))
+	}))
+	mux.Handle("GET /api/conversations/{id}/artifacts/{artifact}", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.handleArtifact(w, r, r.PathValue("id"), r.PathValue("artifact"))
+	}))
+	mux.Handle("DELETE /api/conversations/{id}/artifacts/{artifact}", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.handleArtifact(w, r, r.PathValue("id"), r.PathValue("artifact"))
+	}))
 	mux.Handle("/api/conversation/", http.StripPrefix("/api/conversation", s.conversationMux()))
+	mux.Handle("GET /api/processes", http.HandlerFunc(s.handleProcesses))
+	mux.Handle("GET /api/processes/{conversation}/{process}/logs", http.HandlerFunc(s.handleProcessLogs))
+	mux.Handle("/api/update", http.HandlerFunc(s.handleUpdate))
+	mux.Handle("GET /api/admin/migrations", http.HandlerFunc(s.handleMigrations))
+	mux.Handle("GET /api/admin/uploads/orphaned", http.HandlerFunc(s.handleOrphanedUploads))
+	mux.Handle("/preview/{id}/", http.HandlerFunc(s.handlePreview))
 	mux.Handle("/api/validate-cwd", http.HandlerFunc(s.handleValidateCwd)) // Small response
 	mux.Handle("/api/list-directory", gzipHandler(http.HandlerFunc(s.handleListDirectory)))
+	mux.Handle("/api/files/tree", gzipHandler(http.HandlerFunc(s.handleFileTree)))
+	mux.Handle("/api/files/content", gzipHandler(http.HandlerFunc(s.handleFileContent)))
 	mux.Handle("/api/git/diffs", gzipHandler(http.HandlerFunc(s.handleGitDiffs)))
 	mux.Handle("/api/git/diffs/", gzipHandler(http.HandlerFunc(s.handleGitDiffFiles)))
 	mux.Handle("/api/git/file-diff/", gzipHandler(http.HandlerFunc(s.handleGitFileDiff)))
+	mux.Handle("/api/coverage", gzipHandler(http.HandlerFunc(s.handleCoverage)))
+	mux.Handle("GET /api/coverage/file", gzipHandler(http.HandlerFunc(s.handleFileCoverage)))
 	mux.HandleFunc("/api/upload", s.handleUpload)                      // Binary uploads
+	mux.HandleFunc("GET /api/upload/{hash}", s.handleUploadLookup)     // Uploads stored already
 	mux.HandleFunc("/api/read", s.handleRead)                          // Serves images
 	mux.Handle("/api/write-file", http.HandlerFunc(s.handleWriteFile)) // Small response
 
+	// Models and the health of their providers
+	mux.Handle("GET /api/models", http.HandlerFunc(s.handleModels))
+	mux.Handle("GET /api/custom-models", http.HandlerFunc(s.handleCustomModels))
+	mux.Handle("POST /api/custom-models", http.HandlerFunc(s.handleCustomModels))
+	mux.Handle("DELETE /api/custom-models/{id}", http.HandlerFunc(s.handleDeleteCustomModel))
+
 	// Settings routes
 	mux.Handle("/api/settings", http.HandlerFunc(s.handleSettings))
+	mux.Handle("GET /api/settings/history", http.HandlerFunc(s.handleSettingsHistory))
+	mux.Handle("POST /api/settings/history/{id}/revert", http.HandlerFunc(s.handleRevertSettings))
+	mux.Handle("GET /api/settings/export", http.HandlerFunc(s.handleExportSettings))
+	mux.Handle("POST /api/settings/import", http.HandlerFunc(s.handleImportSettings))
+	mux.Handle("/api/user/settings", http.HandlerFunc(s.handleUserSettings))
+
+	// Usage aggregation
+	mux.Handle("/api/usage/summary", gzipHandler(http.HandlerFunc(s.handleUsageSummary)))
+
+	// Token estimate for a prospective message
+	mux.Handle("/api/tokens/count", http.HandlerFunc(s.handleCountTokens))
+
+	// Semantic search over messages and memories
+	mux.Handle("/api/search", http.HandlerFunc(s.handleSemanticSearch))
+
+	// Memory routes
+	mux.Handle("/api/memories", http.HandlerFunc(s.handleMemories))
+	mux.Handle("/api/memories/{id}", http.HandlerFunc(s.handleMemory))
+
+	// Model comparisons
+	mux.Handle("GET /api/comparisons", http.HandlerFunc(s.handleComparisons))
+	mux.Handle("GET /api/comparisons/{id}", http.HandlerFunc(s.handleComparison))
+	mux.Handle("POST /api/comparisons/{id}/winner", http.HandlerFunc(s.handleComparison))
+
+	// Feedback on agent messages, across conversations
+	mux.Handle("GET /api/feedback", http.HandlerFunc(s.handleFeedback))
+
+	// Project routes
+	mux.Handle("/api/projects", http.HandlerFunc(s.handleProjects))
+	mux.Handle("/api/projects/{id}", http.HandlerFunc(s.handleProject))
+
+	// Read-only shared conversations, outside /api so no auth header is required
+	mux.Handle("GET /share/{token}", gzipHandler(http.HandlerFunc(s.handleSharedConversation)))
+
+	// Chat bot webhooks, outside /api since the platforms authenticate with
+	// signatures rather than the auth header
+	mux.Handle("POST /hooks/slack/events", http.HandlerFunc(s.handleSlackEvents))
 
 	// Version endpoint
 	mux.Handle("/version", http.HandlerFunc(s.handleVersion)) // Small response
 
 	// Debug routes
 	mux.Handle("/debug/llm", gzipHandler(http.HandlerFunc(s.handleDebugLLM)))
+	mux.Handle("GET /api/debug/llm/{messageID}", http.HandlerFunc(s.handleDebugLLMMessage))
+	mux.Handle("POST /api/debug/llm/{messageID}/replay", http.HandlerFunc(s.handleDebugLLMMessage))
 
 	// Serve embedded UI assets
 	mux.Handle("/", s.staticHandler(ui.Assets()))
@@ -364,9 +529,11 @@ func (s *Server) handleValidateCwd(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// The UI offers to run the tools in the directory's dev container
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"valid": true,
+		"valid":        true,
+		"devcontainer": devcontainer.Find(path) != "",
 	})
 }
 
@@ -495,7 +662,40 @@ func (s *Server) getOrCreateConversationManager(ctx context.Context, conversatio
 			return s.recordMessage(ctx, conversationID, message, usage)
 		}
 
-		manager := NewConversationManager(conversationID, s.db, s.logger, s.toolSetConfig, recordMessage, s.llmManager, s.defaultModel)
+		toolSetConfig := s.toolSetConfig
+		toolSetConfig.BashRunDir = s.bashRunDir(conversationID)
+		toolSetConfig.Processes = s.processes
+		toolSetConfig.ProcessManager = s.processManager(conversationID)
+		toolSetConfig.PreviewPath = previewPath(conversationID)
+		toolSetConfig.DeployRunDir = s.deployRunDir(conversationID)
+		toolSetConfig.SelfHealthURL = s.selfHealthURL
+		toolSetConfig.PublishArtifact = s.publishArtifact(conversationID)
+		toolSetConfig.OnFileEdit = s.recordFileEdit(conversationID)
+		toolSetConfig.TrackBashChanges = s.trackBashChanges(conversationID)
+		if s.embedder != nil {
+			toolSetConfig.Recall = s.recall(conversationID)
+			toolSetConfig.SearchDocs = s.searchDocs
+		}
+
+		manager := NewConversationManager(conversationID, s.db, s.logger, toolSetConfig, recordMessage, s.llmManager, s.defaultModel)
+		manager.draining = &s.draining
+		manager.acquireLease = func(ctx context.Context) error { return s.acquireLease(ctx, conversationID) }
+		manager.releaseLease = func() { s.releaseLease(conversationID) }
+		// The agent acts once the user's message is checkpointed
+		manager.beforeTool = func(ctx context.Context) { s.waitCheckpoint(ctx, conversationID) }
+		manager.onGitStateChange = func(state *gitstate.GitState) {
+			if state.IsRepo {
+				s.invalidateGitDiffs(state.Worktree)
+			}
+		}
+		manager.onQuestion = func(question claudetool.Question) {
+			message := question.Question
+			if len(question.Options) > 0 {
+				message += "\n\n" + strings.Join(question.Options, " / ")
+			}
+			s.notifyConversation(context.Background(), conversationID, notify.KindQuestion, message)
+			s.bridgeQuestion(context.Background(), conversationID, question)
+		}
 		if err := manager.Hydrate(ctx); err != nil {
 			return nil, err
 		}
@@ -556,19 +756,45 @@ func (s *Server) recordMessage(ctx context.Context, conversationID string, messa
 	// Extract display data from content items
 	displayDataToStore := ExtractDisplayData(message)
 
-	// Create message
-	createdMsg, err := s.db.CreateMessage(ctx, db.CreateMessageParams{
+	// Store oversized tool results apart; the message keeps previews
+	if stored, err := s.storeToolResultBlobs(ctx, conversationID, message); err != nil {
+		s.logger.Warn("Failed to store tool result blobs; keeping them in the message", "conversation_id", conversationID, "error", err)
+	} else {
+		message = stored
+	}
+
+	// Create message, or complete the partial message the response streamed to
+	params := db.CreateMessageParams{
 		ConversationID: conversationID,
 		Type:           messageType,
 		LLMData:        message,
 		UserData:       nil,
 		UsageData:      usage,
 		DisplayData:    displayDataToStore,
-	})
+	}
+	var partial *generated.Message
+	if message.Role == llm.MessageRoleAssistant {
+		s.mu.Lock()
+		if mgr, ok := s.activeConversations[conversationID]; ok {
+			partial = mgr.takePartial()
+		}
+		s.mu.Unlock()
+	}
+	var createdMsg *generated.Message
+	if partial != nil {
+		createdMsg, err = s.db.ReplaceMessage(ctx, partial.MessageID, params)
+	} else {
+		createdMsg, err = s.db.CreateMessage(ctx, params)
+	}
 	if err != nil {
 		return fmt.Errorf("failed to create message: %w", err)
 	}
 
+	// Snapshot the workspace before the agent acts on the user's message
+	if isUserText(message) {
+		s.recordCheckpoint(ctx, conversationID, createdMsg)
+	}
+
 	// Update conversation timestamp, agent_working status, and context window size
 	if err := s.db.QueriesTx(ctx, func(q *generated.Queries) error {
 		if err := q.UpdateConversationTimestamp(ctx, conversationID); err != nil {
@@ -607,12 +833,39 @@ func (s *Server) recordMessage(ctx context.Context, conversationID string, messa
 	}
 
 	// Touch active manager activity time if present
+	var exchange *llmExchange
 	s.mu.Lock()
 	mgr, ok := s.activeConversations[conversationID]
 	if ok {
 		mgr.Touch()
+		mgr.endToolOutput(message)
+		if message.Role == llm.MessageRoleAssistant {
+			exchange = mgr.takeLLMExchange()
+		}
 	}
 	s.mu.Unlock()
+	if exchange != nil {
+		s.logLLMExchange(ctx, conversationID, createdMsg.MessageID, exchange)
+	}
+
+	// Notify about the end of the turn and post it to the chat thread the
+	// conversation came from, unless a queued message is about to start the
+	// next one
+	if kind, notifies := notifyKind(createdMsg); notifies && !(ok && mgr.hasQueued()) {
+		go s.notifyConversation(context.WithoutCancel(ctx), conversationID, kind, messageText(*createdMsg))
+		go s.bridgeTurnEnd(context.WithoutCancel(ctx), conversationID)
+	} else if db.MessageType(createdMsg.Type) == db.MessageTypeAgent {
+		go s.bridgeProgress(context.WithoutCancel(ctx), conversationID)
+	}
+
+	if ok && isEndOfTurn(createdMsg) {
+		go s.deliverQueuedMessage(context.WithoutCancel(ctx), conversationID)
+	}
+
+	// Embed the turn's messages for semantic search
+	if isEndOfTurn(createdMsg) {
+		s.wakeIndexer()
+	}
 
 	// Notify subscribers with only the new message - use WithoutCancel because
 	// the HTTP request context may be cancelled after the handler returns, but
@@ -780,12 +1033,7 @@ func (s *Server) broadcastConversationUpdate(ctx context.Context, conversationID
 		return
 	}
 
-	s.mu.Lock()
-	s.metaSeq++
-	seq := s.metaSeq
-	s.mu.Unlock()
-
-	s.metaSubPub.Publish(seq, conversation)
+	s.publishConversation(ctx, conversation)
 }
 
 // Cleanup removes inactive conversation managers
@@ -795,11 +1043,13 @@ func (s *Server) Cleanup() {
 
 	now := time.Now()
 	for id, manager := range s.activeConversations {
-		// Remove managers that have been inactive for more than 30 minutes
+		// Remove managers that have been inactive for more than 30 minutes,
+		// unless the agent is waiting for the user to answer a question
 		manager.mu.Lock()
 		lastActivity := manager.lastActivity
+		asking := len(manager.questions) > 0
 		manager.mu.Unlock()
-		if now.Sub(lastActivity) > 30*time.Minute {
+		if now.Sub(lastActivity) > 30*time.Minute && !asking {
 			manager.stopLoop()
 			delete(s.activeConversations, id)
 			s.logger.Debug("Cleaned up inactive conversation", "conversationID", id)
@@ -807,6 +1057,19 @@ func (s *Server) Cleanup() {
 	}
 }
 
+// compressStoredMessages compresses the LLM data of the messages stored
+// before it was compressed on write.
+func (s *Server) compressStoredMessages(ctx context.Context) {
+	n, err := s.db.CompressMessages(ctx)
+	if err != nil && ctx.Err() == nil {
+		s.logger.Warn("Failed to compress stored messages", "compressed", n, "error", err)
+		return
+	}
+	if n > 0 {
+		s.logger.Info("Compressed stored messages; VACUUM the database to shrink its file", "compressed", n)
+	}
+}
+
 // Start starts the HTTP server and handles the complete lifecycle
 func (s *Server) Start(port string) error {
 	listener, err := net.Listen("tcp", ":"+port)
@@ -820,6 +1083,9 @@ func (s *Server) Start(port string) error {
 // StartWithListener starts the HTTP server using the provided listener.
 // This is useful for systemd socket activation where the listener is created externally.
 func (s *Server) StartWithListener(listener net.Listener) error {
+	// Offer the custom models before serving
+	s.loadCustomModels(context.Background())
+
 	// Set up HTTP server with routes and middleware
 	mux := http.NewServeMux()
 	s.RegisterRoutes(mux)
@@ -846,9 +1112,10 @@ func (s *Server) StartWithListener(listener net.Listener) error {
 
 	// Get actual port from listener
 	actualPort := listener.Addr().(*net.TCPAddr).Port
+	s.selfHealthURL = fmt.Sprintf("http://localhost:%d/version", actualPort)
 
 	// Start server in goroutine
-	serverErrCh := make(chan error, 1)
+	serverErrCh := make(chan error, 2)
 	go func() {
 		s.logger.Info("Server starting", "port", actualPort, "url", fmt.Sprintf("http://localhost:%d", actualPort))
 		if err := httpServer.Serve(listener); err != nil && err != http.ErrServerClosed {
@@ -856,9 +1123,57 @@ func (s *Server) StartWithListener(listener net.Listener) error {
 		}
 	}()
 
+	// Serve the gRPC API on its own listener
+	if s.grpcAddr != "" {
+		grpcListener, err := net.Listen("tcp", s.grpcAddr)
+		if err != nil {
+			s.logger.Error("Failed to create gRPC listener", "error", err, "addr", s.grpcAddr)
+			return err
+		}
+		grpcServer := s.NewGRPCServer()
+		defer grpcServer.Stop()
+		go func() {
+			s.logger.Info("gRPC server starting", "addr", grpcListener.Addr().String())
+			if err := grpcServer.Serve(grpcListener); err != nil {
+				serverErrCh <- err
+			}
+		}()
+	}
+
+	// Kill what a crashed server left running, before recovery inspects the
+	// commands it interrupted
+	if reaped, err := s.processes.Reap(); err != nil {
+		s.logger.Warn("Failed to reap orphaned processes", "error", err)
+	} else if len(reaped) > 0 {
+		s.logger.Info("Reaped orphaned processes", "commands", reaped)
+	}
+
 	// Recover interrupted conversations after server starts accepting requests
 	go s.recoverInterruptedConversations(context.Background())
 
+	// Connect the chat bots that need a persistent connection, watch pull
+	// requests' checks for CI auto-fix, check the providers' health, report
+	// the outcome of deploys that restarted the server, install updates, keep
+	// the leases of the conversations running here, and receive the other
+	// servers' conversation updates. Compress the messages older versions
+	// stored uncompressed meanwhile, remove the uploads no message
+	// mentions, delete expired artifacts, and embed new messages, memories
+	// and documentation for semantic search.
+	botCtx, stopBots := context.WithCancel(context.Background())
+	defer stopBots()
+	go s.runDiscordBot(botCtx)
+	go s.runTelegramBot(botCtx)
+	go s.runCIAutofix(botCtx)
+	go s.runHealthChecks(botCtx)
+	go s.runDeployReports(botCtx)
+	go s.runUpdates(botCtx)
+	go s.runLeaseRenewal(botCtx)
+	go s.receiveBroadcasts(botCtx)
+	go s.compressStoredMessages(botCtx)
+	go s.runUploadGC(botCtx)
+	go s.runArtifactRetention(botCtx)
+	go s.runIndexer(botCtx)
+
 	// Wait for shutdown signal or server error
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
@@ -871,11 +1186,23 @@ func (s *Server) StartWithListener(listener net.Listener) error {
 		s.logger.Info("Shutting down server")
 	}
 
+	// Let the turns in flight record their results rather than leave them
+	// to recovery; a second signal stops waiting
+	s.drainTurns(quit)
+
 	// Graceful shutdown
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	if err := httpServer.Shutdown(ctx); err != nil {
+	err := httpServer.Shutdown(ctx)
+
+	// Let another instance sharing the database take over the conversations
+	// that ran here, without waiting for their leases to expire
+	if err := s.db.ReleaseOwnerLeases(context.Background(), s.instanceID); err != nil {
+		s.logger.Warn("Failed to release conversation leases", "error", err)
+	}
+
+	if err != nil {
 		s.logger.Error("Server forced to shutdown", "error", err)
 		return err
 	}
@@ -1,19 +1,130 @@
 package server
 
 import (
+	"bytes"
 	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
 
+	"shelley.exe.dev/claudetool"
 	"shelley.exe.dev/db"
 	"shelley.exe.dev/db/generated"
+	"shelley.exe.dev/projectconfig"
+	"shelley.exe.dev/slug"
 )
 
-// Settings represents the application settings stored as JSON
+// Settings represents the server-wide settings stored as JSON
 type Settings struct {
 	Guardian *GuardianSettings `json:"guardian,omitempty"`
-	UI       *UISettings       `json:"ui,omitempty"`
+	Tools    *ToolsSettings    `json:"tools,omitempty"`
+	Slug     *SlugSettings     `json:"slug,omitempty"`
+	// WriteFile is the policy for files written from the UI and used by
+	// the tools
+	WriteFile *WriteFileSettings `json:"writeFile,omitempty"`
+
+	Notifications *NotificationSettings `json:"notifications,omitempty"`
+	Bots          *BotSettings          `json:"bots,omitempty"`
+	Compare       *CompareSettings      `json:"compare,omitempty"`
+	Debug         *DebugSettings        `json:"debug,omitempty"`
+	Update        *UpdateSettings       `json:"update,omitempty"`
+}
+
+// UserSettings represents one user's preferences stored as JSON
+type UserSettings struct {
+	UI *UISettings `json:"ui,omitempty"`
+	// DefaultModel is the model new conversations start with; empty means
+	// the server default.
+	DefaultModel string `json:"defaultModel,omitempty"`
+}
+
+// SlugSettings controls how conversation titles are generated.
+type SlugSettings struct {
+	// Template arranges the generated title and conversation details,
+	// e.g. "{date}-{branch}-{title}" or "{repo}/{title}". Variables are
+	// {title}, {date}, {branch}, {repo} and {dir}; empty means the title alone.
+	Template string `json:"template,omitempty"`
+	// Models are tried in order to generate titles; empty means
+	// slug.DefaultModels.
+	Models []string `json:"models,omitempty"`
+	// Prompt replaces slug.DefaultPrompt. {message} in it is replaced by
+	// the first message.
+	Prompt string `json:"prompt,omitempty"`
+}
+
+// config returns the slug options.
+func (s *SlugSettings) config() slug.Config {
+	if s == nil {
+		return slug.Config{}
+	}
+	return slug.Config{Template: s.Template, Models: s.Models, Prompt: s.Prompt}
+}
+
+// validate checks the slug settings, using hasModel to check the models.
+func (s *SlugSettings) validate(hasModel func(string) bool) error {
+	if s == nil {
+		return nil
+	}
+	for _, model := range s.Models {
+		if !hasModel(model) {
+			return fmt.Errorf("unknown slug model: %s", model)
+		}
+	}
+	return slug.ValidateTemplate(s.Template)
+}
+
+// ToolsSettings controls the tools offered to conversations. Changes apply
+// to conversations whose loop starts afterwards.
+type ToolsSettings struct {
+	// Enabled maps tool names to whether the tool is offered.
+	// Tools not listed are offered.
+	Enabled map[string]bool `json:"enabled,omitempty"`
+	// Bash holds options of the bash tool
+	Bash *BashToolSettings `json:"bash,omitempty"`
+	// Deploy holds the targets of the deploy tool, by name. A project's
+	// .shelley.yaml can add more, or replace them by using the same names.
+	Deploy map[string]DeployTargetSettings `json:"deploy,omitempty"`
+}
+
+// DeployTargetSettings is a deploy target of the deploy tool.
+type DeployTargetSettings struct {
+	Command string `json:"command"`
+	// Dir is the absolute path of the directory Command runs in; empty
+	// means the conversation's working directory.
+	Dir string            `json:"dir,omitempty"`
+	Env map[string]string `json:"env,omitempty"`
+	// Production targets are deployed to only once the user confirms.
+	Production bool `json:"production,omitempty"`
+	// Detach runs Command without waiting for it, for deploys that
+	// restart Shelley itself.
+	Detach bool `json:"detach,omitempty"`
+	// HealthURL must answer with a 2xx status within HealthTimeout, a Go
+	// duration, of Command finishing, or Rollback runs. Detached deploys
+	// default to the server's own.
+	HealthURL     string `json:"healthUrl,omitempty"`
+	HealthTimeout string `json:"healthTimeout,omitempty"`
+	Rollback      string `json:"rollback,omitempty"`
+}
+
+// BashToolSettings contains options of the bash tool. Timeouts are Go
+// durations such as "30s" or "15m"; empty means the default.
+type BashToolSettings struct {
+	FastTimeout       string `json:"fastTimeout,omitempty"`
+	SlowTimeout       string `json:"slowTimeout,omitempty"`
+	BackgroundTimeout string `json:"backgroundTimeout,omitempty"`
+	// MaxOutputBytes is how much of a command's output the agent sees;
+	// zero means output over 128kB is cut to its first and last 4kB.
+	MaxOutputBytes int `json:"maxOutputBytes,omitempty"`
+	// Truncate is the part of longer output to keep: "head_tail" (default),
+	// "head" or "tail".
+	Truncate string `json:"truncate,omitempty"`
 }
 
 // UISettings contains UI-related settings
@@ -30,9 +141,31 @@ type UISettings struct {
 	// EnterBehavior controls what happens when Enter is pressed while agent is working
 	// "send" (default): normal send, button disabled while agent is working
 	// "stop_and_send": automatically stop agent and send new message
+	// "queue": hold the message and send it when the agent's turn ends
 	EnterBehavior string `json:"enterBehavior,omitempty"`
 }
 
+// validate checks that the UI settings use known values.
+func (u *UISettings) validate() error {
+	if u == nil {
+		return nil
+	}
+	for _, opt := range []struct {
+		name    string
+		value   string
+		allowed []string
+	}{
+		{"indicatorMode", u.IndicatorMode, []string{"inline", "block", "hidden"}},
+		{"expansionBehavior", u.ExpansionBehavior, []string{"single", "all"}},
+		{"enterBehavior", u.EnterBehavior, []string{"send", "stop_and_send", "queue"}},
+	} {
+		if opt.value != "" && !slices.Contains(opt.allowed, opt.value) {
+			return fmt.Errorf("invalid ui %s %q: must be one of %s", opt.name, opt.value, strings.Join(opt.allowed, ", "))
+		}
+	}
+	return nil
+}
+
 // GuardianSettings contains settings for the guardian AI
 type GuardianSettings struct {
 	Stream    *GuardianCheckSettings `json:"stream,omitempty"`
@@ -46,6 +179,25 @@ type GuardianCheckSettings struct {
 	Prompt  string `json:"prompt"`
 }
 
+// validate checks that enabled guardian checks use an available model.
+func (g *GuardianSettings) validate(hasModel func(string) bool) error {
+	if g == nil {
+		return nil
+	}
+	for _, check := range []struct {
+		name     string
+		settings *GuardianCheckSettings
+	}{
+		{"stream", g.Stream},
+		{"toolCheck", g.ToolCheck},
+	} {
+		if check.settings != nil && check.settings.Enabled && !hasModel(check.settings.Model) {
+			return fmt.Errorf("unknown guardian %s model: %s", check.name, check.settings.Model)
+		}
+	}
+	return nil
+}
+
 // DefaultSettings returns the default settings
 func DefaultSettings() Settings {
 	return Settings{
@@ -61,6 +213,12 @@ func DefaultSettings() Settings {
 				Prompt:  "",
 			},
 		},
+	}
+}
+
+// DefaultUserSettings returns the default user settings
+func DefaultUserSettings() UserSettings {
+	return UserSettings{
 		UI: &UISettings{
 			IndicatorMode:     "inline",
 			ExpansionBehavior: "single",
@@ -69,6 +227,150 @@ func DefaultSettings() Settings {
 	}
 }
 
+// disabledTools returns the names of the tools turned off.
+func (t *ToolsSettings) disabledTools() []string {
+	if t == nil {
+		return nil
+	}
+	var names []string
+	for name, enabled := range t.Enabled {
+		if !enabled {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+// timeouts returns the bash timeouts, or nil if none are overridden.
+func (b *BashToolSettings) timeouts() (*claudetool.Timeouts, error) {
+	if b == nil || (b.FastTimeout == "" && b.SlowTimeout == "" && b.BackgroundTimeout == "") {
+		return nil, nil
+	}
+	t := &claudetool.Timeouts{
+		Fast:       claudetool.DefaultFastTimeout,
+		Slow:       claudetool.DefaultSlowTimeout,
+		Background: claudetool.DefaultBackgroundTimeout,
+	}
+	for _, opt := range []struct {
+		name  string
+		value string
+		dst   *time.Duration
+	}{
+		{"fastTimeout", b.FastTimeout, &t.Fast},
+		{"slowTimeout", b.SlowTimeout, &t.Slow},
+		{"backgroundTimeout", b.BackgroundTimeout, &t.Background},
+	} {
+		if opt.value == "" {
+			continue
+		}
+		d, err := time.ParseDuration(opt.value)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid bash %s %q: must be a positive duration such as 30s", opt.name, opt.value)
+		}
+		*opt.dst = d
+	}
+	return t, nil
+}
+
+// outputLimits returns the bash output limits, or nil if none are overridden.
+func (b *BashToolSettings) outputLimits() (*claudetool.OutputLimits, error) {
+	if b == nil || (b.MaxOutputBytes == 0 && b.Truncate == "") {
+		return nil, nil
+	}
+	if b.MaxOutputBytes < 0 {
+		return nil, fmt.Errorf("invalid bash maxOutputBytes %d: must be positive", b.MaxOutputBytes)
+	}
+	if b.Truncate != "" && !claudetool.ValidTruncate(b.Truncate) {
+		return nil, fmt.Errorf("invalid bash truncate %q: must be head_tail, head or tail", b.Truncate)
+	}
+	return &claudetool.OutputLimits{MaxBytes: b.MaxOutputBytes, Truncate: b.Truncate}, nil
+}
+
+// deployTargets returns the deploy targets, sorted by name.
+func (t *ToolsSettings) deployTargets() ([]claudetool.DeployTarget, error) {
+	if t == nil {
+		return nil, nil
+	}
+	var targets []claudetool.DeployTarget
+	for name, target := range t.Deploy {
+		if err := projectconfig.CheckDeployTargetName(name); err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(target.Command) == "" {
+			return nil, fmt.Errorf("deploy target %s has no command", name)
+		}
+		if target.Dir != "" && !filepath.IsAbs(target.Dir) {
+			return nil, fmt.Errorf("deploy target %s: dir %q is not an absolute path", name, target.Dir)
+		}
+		if err := projectconfig.CheckEnv(target.Env); err != nil {
+			return nil, fmt.Errorf("deploy target %s: %w", name, err)
+		}
+		timeout, err := projectconfig.ParseHealthTimeout(target.HealthTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("deploy target %s: %w", name, err)
+		}
+		targets = append(targets, claudetool.DeployTarget{
+			Name:          name,
+			Command:       target.Command,
+			Dir:           target.Dir,
+			Env:           projectconfig.Environ(target.Env),
+			Production:    target.Production,
+			Detach:        target.Detach,
+			HealthURL:     target.HealthURL,
+			HealthTimeout: timeout,
+			Rollback:      target.Rollback,
+		})
+	}
+	slices.SortFunc(targets, func(a, b claudetool.DeployTarget) int { return strings.Compare(a.Name, b.Name) })
+	return targets, nil
+}
+
+// applyToolSettings turns off disabled tools and sets tool options in cfg.
+func applyToolSettings(cfg *claudetool.ToolSetConfig, settings Settings) error {
+	cfg.FilePolicy = settings.WriteFile.policy(false)
+	if settings.Tools == nil {
+		return nil
+	}
+	cfg.DisabledTools = settings.Tools.disabledTools()
+	timeouts, err := settings.Tools.Bash.timeouts()
+	if err != nil {
+		return err
+	}
+	cfg.BashTimeouts = timeouts
+	limits, err := settings.Tools.Bash.outputLimits()
+	if err != nil {
+		return err
+	}
+	cfg.BashOutput = limits
+	targets, err := settings.Tools.deployTargets()
+	if err != nil {
+		return err
+	}
+	cfg.DeployTargets = targets
+	return nil
+}
+
+// toolNames returns the names of the tools conversations can be offered.
+func (s *Server) toolNames() []string {
+	cfg := s.toolSetConfig
+	cfg.SaveMemory = func(context.Context, string) error { return nil }
+	cfg.PublishArtifact = s.publishArtifact("")
+	// Projects can configure deploy targets
+	cfg.DeployTargets = []claudetool.DeployTarget{{Name: "deploy", Command: "true"}}
+	if s.embedder != nil {
+		cfg.Recall = s.recall("")
+		cfg.SearchDocs = s.searchDocs
+	}
+	toolSet := claudetool.NewToolSet(context.Background(), cfg)
+	defer toolSet.Cleanup()
+	names := make([]string, len(toolSet.Tools()))
+	for i, tool := range toolSet.Tools() {
+		names[i] = tool.Name
+	}
+	return names
+}
+
 // GetSettings retrieves the current settings from the database
 func GetSettings(ctx context.Context, database *db.DB) (Settings, error) {
 	var data string
@@ -92,15 +394,107 @@ func GetSettings(ctx context.Context, database *db.DB) (Settings, error) {
 	return settings, nil
 }
 
-// SaveSettings saves the settings to the database
-func SaveSettings(ctx context.Context, database *db.DB, settings Settings) error {
+// validateSettings checks settings before they are saved.
+func (s *Server) validateSettings(settings Settings) error {
+	if err := applyToolSettings(&claudetool.ToolSetConfig{}, settings); err != nil {
+		return err
+	}
+	if err := settings.Guardian.validate(s.llmManager.HasModel); err != nil {
+		return err
+	}
+	if err := settings.Slug.validate(s.llmManager.HasModel); err != nil {
+		return err
+	}
+	if err := settings.WriteFile.validate(); err != nil {
+		return err
+	}
+	if err := settings.Notifications.validate(); err != nil {
+		return err
+	}
+	if err := settings.Compare.validate(s.llmManager.HasModel); err != nil {
+		return err
+	}
+	if err := settings.Update.validate(); err != nil {
+		return err
+	}
+	return settings.Bots.validate(s.llmManager.HasModel)
+}
+
+// validateUserSettings checks user settings before they are saved.
+func (s *Server) validateUserSettings(settings UserSettings) error {
+	if settings.DefaultModel != "" && !s.llmManager.HasModel(settings.DefaultModel) {
+		return fmt.Errorf("unknown default model: %s", settings.DefaultModel)
+	}
+	return settings.UI.validate()
+}
+
+// readSettingsUpdate decodes a settings update from the request into dst,
+// rejecting unknown fields. A POST body replaces the settings; a PATCH body
+// is a JSON merge patch (RFC 7396) applied to current: objects are merged
+// recursively, null removes a field, and anything else replaces it.
+func readSettingsUpdate(r *http.Request, current, dst any) error {
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+	if r.Method == http.MethodPatch {
+		var changes map[string]any
+		if err := json.Unmarshal(data, &changes); err != nil {
+			return err
+		}
+		if data, err = json.Marshal(current); err != nil {
+			return err
+		}
+		var merged map[string]any
+		if err := json.Unmarshal(data, &merged); err != nil {
+			return err
+		}
+		if data, err = json.Marshal(mergePatch(merged, changes)); err != nil {
+			return err
+		}
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	return dec.Decode(dst)
+}
+
+// mergePatch merges patch into target as described by RFC 7396.
+func mergePatch(target, patch any) any {
+	changes, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+	merged, ok := target.(map[string]any)
+	if !ok {
+		merged = make(map[string]any)
+	}
+	for key, value := range changes {
+		if value == nil {
+			delete(merged, key)
+			continue
+		}
+		merged[key] = mergePatch(merged[key], value)
+	}
+	return merged
+}
+
+// SaveSettings saves the settings to the database and records them in the
+// settings history as saved by userID, which may be empty
+func SaveSettings(ctx context.Context, database *db.DB, settings Settings, userID string) error {
 	data, err := json.Marshal(settings)
 	if err != nil {
 		return fmt.Errorf("failed to serialize settings: %w", err)
 	}
 
+	var author *string
+	if userID != "" {
+		author = &userID
+	}
 	err = database.QueriesTx(ctx, func(q *generated.Queries) error {
-		return q.UpdateSettings(ctx, string(data))
+		if err := q.UpdateSettings(ctx, string(data)); err != nil {
+			return err
+		}
+		return q.CreateSettingsHistory(ctx, generated.CreateSettingsHistoryParams{Data: string(data), UserID: author})
 	})
 	if err != nil {
 		return fmt.Errorf("failed to save settings: %w", err)
@@ -109,7 +503,68 @@ func SaveSettings(ctx context.Context, database *db.DB, settings Settings) error
 	return nil
 }
 
-// handleSettings handles GET/POST /api/settings
+// GetUserSettings retrieves a user's settings from the database, or the
+// defaults if they have none
+func GetUserSettings(ctx context.Context, database *db.DB, userID string) (UserSettings, error) {
+	var data string
+	err := database.Queries(ctx, func(q *generated.Queries) error {
+		var err error
+		data, err = q.GetUserSettings(ctx, userID)
+		return err
+	})
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return UserSettings{}, fmt.Errorf("failed to get user settings: %w", err)
+	}
+
+	settings := DefaultUserSettings()
+	if data != "" && data != "{}" {
+		if err := json.Unmarshal([]byte(data), &settings); err != nil {
+			return UserSettings{}, fmt.Errorf("failed to parse user settings: %w", err)
+		}
+	}
+
+	return settings, nil
+}
+
+// SaveUserSettings saves a user's settings to the database
+func SaveUserSettings(ctx context.Context, database *db.DB, userID string, settings UserSettings) error {
+	data, err := json.Marshal(settings)
+	if err != nil {
+		return fmt.Errorf("failed to serialize user settings: %w", err)
+	}
+
+	err = database.QueriesTx(ctx, func(q *generated.Queries) error {
+		return q.UpsertUserSettings(ctx, generated.UpsertUserSettingsParams{UserID: userID, Data: string(data)})
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save user settings: %w", err)
+	}
+
+	return nil
+}
+
+// userDefaultModel returns the requesting user's default model, or "" if
+// they haven't set one or it isn't available.
+func (s *Server) userDefaultModel(r *http.Request) string {
+	return s.defaultModelForUser(r.Context(), s.userID(r))
+}
+
+// defaultModelForUser is userDefaultModel for the user with the given ID.
+func (s *Server) defaultModelForUser(ctx context.Context, userID string) string {
+	settings, err := GetUserSettings(ctx, s.db, userID)
+	if err != nil {
+		s.logger.Warn("Failed to get user settings", "error", err)
+		return ""
+	}
+	if settings.DefaultModel == "" || !s.llmManager.HasModel(settings.DefaultModel) {
+		return ""
+	}
+	return settings.DefaultModel
+}
+
+// handleSettings handles GET/POST/PATCH /api/settings, the server-wide
+// settings. POST replaces the settings; PATCH merges a partial document into
+// them.
 func (s *Server) handleSettings(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case http.MethodGet:
@@ -119,18 +574,40 @@ func (s *Server) handleSettings(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, "failed to get settings", http.StatusInternalServerError)
 			return
 		}
+		// List every tool, so they can all be turned off
+		if settings.Tools == nil {
+			settings.Tools = &ToolsSettings{}
+		}
+		if settings.Tools.Enabled == nil {
+			settings.Tools.Enabled = make(map[string]bool)
+		}
+		for _, name := range s.toolNames() {
+			if _, ok := settings.Tools.Enabled[name]; !ok {
+				settings.Tools.Enabled[name] = true
+			}
+		}
 		w.Header().Set("Content-Type", "application/json")
 		if err := json.NewEncoder(w).Encode(settings); err != nil {
 			s.logger.Error("failed to encode settings", "error", err)
 		}
 
-	case http.MethodPost:
+	case http.MethodPost, http.MethodPatch:
+		current, err := GetSettings(r.Context(), s.db)
+		if err != nil {
+			s.logger.Error("failed to get settings", "error", err)
+			http.Error(w, "failed to get settings", http.StatusInternalServerError)
+			return
+		}
 		var settings Settings
-		if err := json.NewDecoder(r.Body).Decode(&settings); err != nil {
-			http.Error(w, "invalid JSON", http.StatusBadRequest)
+		if err := readSettingsUpdate(r, current, &settings); err != nil {
+			http.Error(w, "invalid settings: "+err.Error(), http.StatusBadRequest)
 			return
 		}
-		if err := SaveSettings(r.Context(), s.db, settings); err != nil {
+		if err := s.validateSettings(settings); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := SaveSettings(r.Context(), s.db, settings, s.userID(r)); err != nil {
 			s.logger.Error("failed to save settings", "error", err)
 			http.Error(w, "failed to save settings", http.StatusInternalServerError)
 			return
@@ -144,3 +621,47 @@ func (s *Server) handleSettings(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 	}
 }
+
+// handleUserSettings handles GET/POST/PATCH /api/user/settings, the settings
+// of the requesting user. POST replaces the settings; PATCH merges a partial
+// document into them.
+func (s *Server) handleUserSettings(w http.ResponseWriter, r *http.Request) {
+	userID := s.userID(r)
+	current, err := GetUserSettings(r.Context(), s.db, userID)
+	if err != nil {
+		s.logger.Error("failed to get user settings", "error", err)
+		http.Error(w, "failed to get user settings", http.StatusInternalServerError)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(current); err != nil {
+			s.logger.Error("failed to encode user settings", "error", err)
+		}
+
+	case http.MethodPost, http.MethodPatch:
+		var settings UserSettings
+		if err := readSettingsUpdate(r, current, &settings); err != nil {
+			http.Error(w, "invalid settings: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := s.validateUserSettings(settings); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := SaveUserSettings(r.Context(), s.db, userID, settings); err != nil {
+			s.logger.Error("failed to save user settings", "error", err)
+			http.Error(w, "failed to save user settings", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(settings); err != nil {
+			s.logger.Error("failed to encode user settings", "error", err)
+		}
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
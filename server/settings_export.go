@@ -0,0 +1,169 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"time"
+)
+
+// settingsExportVersion is the format of exported settings documents.
+const settingsExportVersion = 1
+
+// SettingsExport is the document returned by GET /api/settings/export and
+// accepted by POST /api/settings/import. Fields tagged secret:"true" in the
+// settings are left out of exports.
+type SettingsExport struct {
+	Version    int           `json:"version"`
+	ExportedAt time.Time     `json:"exportedAt"`
+	Settings   Settings      `json:"settings"`
+	User       *UserSettings `json:"user,omitempty"`
+}
+
+// walkSecrets calls fn for each field tagged secret:"true" in dst, a pointer
+// to a settings struct, passing the matching field of src (which may be
+// invalid if src doesn't have it set). It follows nested structs and
+// pointers to structs.
+func walkSecrets(dst, src reflect.Value, fn func(dst, src reflect.Value)) {
+	if dst.Kind() == reflect.Pointer {
+		if dst.IsNil() {
+			return
+		}
+		dst = dst.Elem()
+		if src.IsValid() {
+			if src.IsNil() {
+				src = reflect.Value{}
+			} else {
+				src = src.Elem()
+			}
+		}
+	}
+	if dst.Kind() != reflect.Struct {
+		return
+	}
+	for i := 0; i < dst.NumField(); i++ {
+		var other reflect.Value
+		if src.IsValid() {
+			other = src.Field(i)
+		}
+		if dst.Type().Field(i).Tag.Get("secret") == "true" {
+			fn(dst.Field(i), other)
+			continue
+		}
+		walkSecrets(dst.Field(i), other, fn)
+	}
+}
+
+// stripSecrets clears the secret fields of v, a pointer to a settings struct.
+func stripSecrets(v any) {
+	walkSecrets(reflect.ValueOf(v), reflect.Value{}, func(dst, _ reflect.Value) {
+		dst.SetZero()
+	})
+}
+
+// keepSecrets fills in the secret fields left empty in dst from current, so
+// importing a stripped export doesn't wipe this instance's secrets. Both are
+// pointers to the same settings type.
+func keepSecrets(dst, current any) {
+	walkSecrets(reflect.ValueOf(dst), reflect.ValueOf(current), func(dst, src reflect.Value) {
+		if dst.IsZero() && src.IsValid() {
+			dst.Set(src)
+		}
+	})
+}
+
+// handleExportSettings handles GET /api/settings/export, returning the
+// server-wide settings and the requesting user's settings without secrets.
+func (s *Server) handleExportSettings(w http.ResponseWriter, r *http.Request) {
+	settings, err := GetSettings(r.Context(), s.db)
+	if err != nil {
+		s.logger.Error("failed to get settings", "error", err)
+		http.Error(w, "failed to get settings", http.StatusInternalServerError)
+		return
+	}
+	userSettings, err := GetUserSettings(r.Context(), s.db, s.userID(r))
+	if err != nil {
+		s.logger.Error("failed to get user settings", "error", err)
+		http.Error(w, "failed to get user settings", http.StatusInternalServerError)
+		return
+	}
+	stripSecrets(&settings)
+	stripSecrets(&userSettings)
+
+	export := SettingsExport{
+		Version:    settingsExportVersion,
+		ExportedAt: time.Now().UTC(),
+		Settings:   settings,
+		User:       &userSettings,
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", `attachment; filename="shelley-settings.json"`)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(export); err != nil {
+		s.logger.Error("failed to encode settings export", "error", err)
+	}
+}
+
+// handleImportSettings handles POST /api/settings/import, replacing the
+// server-wide settings, and the requesting user's if included, with an
+// exported document. Secrets missing from it are kept.
+func (s *Server) handleImportSettings(w http.ResponseWriter, r *http.Request) {
+	var export SettingsExport
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&export); err != nil {
+		http.Error(w, "invalid settings export: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if export.Version != settingsExportVersion {
+		http.Error(w, fmt.Sprintf("unsupported settings export version %d", export.Version), http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	userID := s.userID(r)
+	current, err := GetSettings(ctx, s.db)
+	if err != nil {
+		s.logger.Error("failed to get settings", "error", err)
+		http.Error(w, "failed to get settings", http.StatusInternalServerError)
+		return
+	}
+	keepSecrets(&export.Settings, &current)
+	if err := s.validateSettings(export.Settings); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if export.User != nil {
+		currentUser, err := GetUserSettings(ctx, s.db, userID)
+		if err != nil {
+			s.logger.Error("failed to get user settings", "error", err)
+			http.Error(w, "failed to get user settings", http.StatusInternalServerError)
+			return
+		}
+		keepSecrets(export.User, &currentUser)
+		if err := s.validateUserSettings(*export.User); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if err := SaveSettings(ctx, s.db, export.Settings, userID); err != nil {
+		s.logger.Error("failed to save settings", "error", err)
+		http.Error(w, "failed to save settings", http.StatusInternalServerError)
+		return
+	}
+	if export.User != nil {
+		if err := SaveUserSettings(ctx, s.db, userID, *export.User); err != nil {
+			s.logger.Error("failed to save user settings", "error", err)
+			http.Error(w, "failed to save user settings", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(export.Settings); err != nil {
+		s.logger.Error("failed to encode settings", "error", err)
+	}
+}
@@ -0,0 +1,100 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSettingsExportImport(t *testing.T) {
+	src := NewTestHarness(t)
+	defer src.Close()
+	dst := NewTestHarness(t)
+	defer dst.Close()
+
+	do := func(h *TestHarness, method, path, body string) *httptest.ResponseRecorder {
+		mux := http.NewServeMux()
+		h.server.RegisterRoutes(mux)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return w
+	}
+
+	if w := do(src, "POST", "/api/settings", `{"slug": {"template": "{repo}/{title}"}, "tools": {"enabled": {"think": false}}}`); w.Code != http.StatusOK {
+		t.Fatalf("save settings: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := do(src, "POST", "/api/user/settings", `{"ui": {"enterBehavior": "queue"}}`); w.Code != http.StatusOK {
+		t.Fatalf("save user settings: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w := do(src, "GET", "/api/settings/export", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("export: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	export := w.Body.String()
+
+	if w := do(dst, "POST", "/api/settings/import", export); w.Code != http.StatusOK {
+		t.Fatalf("import: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	settings, err := GetSettings(context.Background(), dst.db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if settings.Slug == nil || settings.Slug.Template != "{repo}/{title}" || settings.Tools.Enabled["think"] {
+		t.Errorf("expected the exported settings, got %+v", settings)
+	}
+	userSettings, err := GetUserSettings(context.Background(), dst.db, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if userSettings.UI.EnterBehavior != "queue" {
+		t.Errorf("expected the exported user settings, got %+v", userSettings.UI)
+	}
+
+	for _, body := range []string{
+		`{"version": 2, "settings": {}}`,
+		`{"version": 1, "settings": {"colour": "red"}}`,
+		`{"version": 1, "settings": {"slug": {"models": ["no-such-model"]}}}`,
+	} {
+		if w := do(dst, "POST", "/api/settings/import", body); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", body, w.Code)
+		}
+	}
+}
+
+func TestSettingsSecrets(t *testing.T) {
+	type webhook struct {
+		URL   string `json:"url"`
+		Token string `json:"token" secret:"true"`
+	}
+	type settings struct {
+		Name    string   `json:"name"`
+		Key     string   `json:"key" secret:"true"`
+		Webhook *webhook `json:"webhook"`
+	}
+
+	s := settings{Name: "a", Key: "k", Webhook: &webhook{URL: "u", Token: "t"}}
+	stripSecrets(&s)
+	got, _ := json.Marshal(s)
+	if want := `{"name":"a","key":"","webhook":{"url":"u","token":""}}`; string(got) != want {
+		t.Errorf("stripSecrets: got %s, want %s", got, want)
+	}
+
+	current := settings{Key: "old-key", Webhook: &webhook{Token: "old-token"}}
+	imported := settings{Name: "b", Webhook: &webhook{URL: "v", Token: "new-token"}}
+	keepSecrets(&imported, &current)
+	got, _ = json.Marshal(imported)
+	if want := `{"name":"b","key":"old-key","webhook":{"url":"v","token":"new-token"}}`; string(got) != want {
+		t.Errorf("keepSecrets: got %s, want %s", got, want)
+	}
+
+	// Nothing to keep when the current settings don't have the section
+	imported = settings{Webhook: &webhook{URL: "v"}}
+	keepSecrets(&imported, &settings{})
+	if imported.Webhook.Token != "" {
+		t.Errorf("keepSecrets: expected no token, got %q", imported.Webhook.Token)
+	}
+}
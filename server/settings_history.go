@@ -0,0 +1,119 @@
+package server
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"shelley.exe.dev/db/generated"
+)
+
+// defaultSettingsHistoryLimit is how many versions GET /api/settings/history
+// returns unless asked for more.
+const defaultSettingsHistoryLimit = 50
+
+// SettingsVersion is a saved version of the server-wide settings.
+type SettingsVersion struct {
+	ID        int64           `json:"id"`
+	Settings  json.RawMessage `json:"settings"`
+	UserID    *string         `json:"user_id,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+func newSettingsVersion(h generated.SettingsHistory) SettingsVersion {
+	return SettingsVersion{
+		ID:        h.ID,
+		Settings:  json.RawMessage(h.Data),
+		UserID:    h.UserID,
+		CreatedAt: h.CreatedAt,
+	}
+}
+
+// handleSettingsHistory handles GET /api/settings/history, listing previous
+// versions of the settings, newest first. ?limit=N caps how many.
+func (s *Server) handleSettingsHistory(w http.ResponseWriter, r *http.Request) {
+	limit := defaultSettingsHistoryLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			http.Error(w, "limit must be a positive number", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	ctx := r.Context()
+	var history []generated.SettingsHistory
+	err := s.db.Queries(ctx, func(q *generated.Queries) error {
+		var err error
+		history, err = q.ListSettingsHistory(ctx, int64(limit))
+		return err
+	})
+	if err != nil {
+		s.logger.Error("failed to list settings history", "error", err)
+		http.Error(w, "failed to list settings history", http.StatusInternalServerError)
+		return
+	}
+
+	versions := make([]SettingsVersion, len(history))
+	for i, h := range history {
+		versions[i] = newSettingsVersion(h)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(versions); err != nil {
+		s.logger.Error("failed to encode settings history", "error", err)
+	}
+}
+
+// handleRevertSettings handles POST /api/settings/history/{id}/revert,
+// restoring a previous version of the settings. The revert is itself
+// recorded in the history.
+func (s *Server) handleRevertSettings(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid settings version", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	var version generated.SettingsHistory
+	err = s.db.Queries(ctx, func(q *generated.Queries) error {
+		var err error
+		version, err = q.GetSettingsHistory(ctx, id)
+		return err
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "settings version not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		s.logger.Error("failed to get settings version", "id", id, "error", err)
+		http.Error(w, "failed to get settings version", http.StatusInternalServerError)
+		return
+	}
+
+	var settings Settings
+	if err := json.Unmarshal([]byte(version.Data), &settings); err != nil {
+		s.logger.Error("failed to parse settings version", "id", id, "error", err)
+		http.Error(w, "failed to parse settings version", http.StatusInternalServerError)
+		return
+	}
+	// Models may have been removed since, so check it still applies
+	if err := s.validateSettings(settings); err != nil {
+		http.Error(w, "cannot revert: "+err.Error(), http.StatusConflict)
+		return
+	}
+	if err := SaveSettings(ctx, s.db, settings, s.userID(r)); err != nil {
+		s.logger.Error("failed to save settings", "error", err)
+		http.Error(w, "failed to save settings", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(settings); err != nil {
+		s.logger.Error("failed to encode settings", "error", err)
+	}
+}
@@ -0,0 +1,78 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSettingsHistory(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+	h.server.requireHeader = "X-Exedev-Userid"
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("X-Exedev-Userid", "alice")
+		w := httptest.NewRecorder()
+		mux := http.NewServeMux()
+		h.server.RegisterRoutes(mux)
+		mux.ServeHTTP(w, req)
+		return w
+	}
+	history := func() []SettingsVersion {
+		t.Helper()
+		w := do("GET", "/api/settings/history", "")
+		if w.Code != http.StatusOK {
+			t.Fatalf("get history: expected 200, got %d: %s", w.Code, w.Body.String())
+		}
+		var versions []SettingsVersion
+		if err := json.Unmarshal(w.Body.Bytes(), &versions); err != nil {
+			t.Fatal(err)
+		}
+		return versions
+	}
+
+	initial := len(history())
+	for _, prompt := range []string{"first", "second"} {
+		body := fmt.Sprintf(`{"guardian": {"stream": {"prompt": %q}}}`, prompt)
+		if w := do("POST", "/api/settings", body); w.Code != http.StatusOK {
+			t.Fatalf("save settings: expected 200, got %d: %s", w.Code, w.Body.String())
+		}
+	}
+	versions := history()
+	if len(versions) != initial+2 {
+		t.Fatalf("expected 2 new versions, got %d", len(versions)-initial)
+	}
+	if versions[0].UserID == nil || *versions[0].UserID != "alice" {
+		t.Errorf("expected the author recorded, got %v", versions[0].UserID)
+	}
+	if !strings.Contains(string(versions[0].Settings), "second") || !strings.Contains(string(versions[1].Settings), "first") {
+		t.Errorf("expected the newest version first, got %s and %s", versions[0].Settings, versions[1].Settings)
+	}
+
+	if w := do("POST", fmt.Sprintf("/api/settings/history/%d/revert", versions[1].ID), ""); w.Code != http.StatusOK {
+		t.Fatalf("revert: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	settings, err := GetSettings(context.Background(), h.db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if settings.Guardian.Stream.Prompt != "first" {
+		t.Errorf("expected the reverted prompt, got %q", settings.Guardian.Stream.Prompt)
+	}
+	if got := len(history()); got != initial+3 {
+		t.Errorf("expected the revert recorded in the history, got %d new versions", got-initial)
+	}
+
+	if w := do("POST", "/api/settings/history/999999/revert", ""); w.Code != http.StatusNotFound {
+		t.Errorf("unknown version: expected 404, got %d", w.Code)
+	}
+	if w := do("GET", "/api/settings/history?limit=1", ""); !strings.HasPrefix(strings.TrimSpace(w.Body.String()), "[{") || strings.Count(w.Body.String(), `"id"`) != 1 {
+		t.Errorf("expected one version with limit=1, got %s", w.Body.String())
+	}
+}
@@ -0,0 +1,235 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestToolSettings(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+
+	post := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.server.handleSettings(w, httptest.NewRequest("POST", "/api/settings", strings.NewReader(body)))
+		return w
+	}
+
+	w := httptest.NewRecorder()
+	h.server.handleSettings(w, httptest.NewRequest("GET", "/api/settings", nil))
+	var settings Settings
+	if err := json.Unmarshal(w.Body.Bytes(), &settings); err != nil {
+		t.Fatal(err)
+	}
+	if settings.Tools == nil || !settings.Tools.Enabled["bash"] || !settings.Tools.Enabled["think"] {
+		t.Fatalf("expected all tools listed as enabled, got %+v", settings.Tools)
+	}
+
+	if w := post(`{"tools": {"bash": {"fastTimeout": "soon"}}}`); w.Code != http.StatusBadRequest {
+		t.Errorf("invalid timeout: expected 400, got %d", w.Code)
+	}
+	if w := post(`{"tools": {"bash": {"truncate": "middle"}}}`); w.Code != http.StatusBadRequest {
+		t.Errorf("invalid truncate: expected 400, got %d", w.Code)
+	}
+	if w := post(`{"tools": {"enabled": {"think": false, "bash": true}, "bash": {"fastTimeout": "1s", "maxOutputBytes": 5, "truncate": "tail"}}}`); w.Code != http.StatusOK {
+		t.Fatalf("save settings: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	h.NewConversation("bash: echo 0123456789; sleep 5", t.TempDir())
+	result := h.WaitToolResult()
+	if !strings.Contains(result, "timed out after 1s") {
+		t.Errorf("expected the configured bash timeout, got %q", result)
+	}
+	if !strings.Contains(result, "output truncated") || !strings.HasSuffix(result, "6789\n") {
+		t.Errorf("expected the output to be cut to its last 5 bytes, got %q", result)
+	}
+
+	conv, err := h.db.GetConversationByID(context.Background(), h.convID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tools := conversationTools(*conv)
+	if slices.Contains(tools, "think") || !slices.Contains(tools, "bash") {
+		t.Errorf("expected think to be disabled, got tools %v", tools)
+	}
+}
+
+func TestSlugSettings(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+
+	post := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.server.handleSettings(w, httptest.NewRequest("POST", "/api/settings", strings.NewReader(body)))
+		return w
+	}
+
+	if w := post(`{"slug": {"template": "{date}-{user}-{title}"}}`); w.Code != http.StatusBadRequest {
+		t.Errorf("unknown variable: expected 400, got %d", w.Code)
+	}
+	if w := post(`{"slug": {"template": "{date}"}}`); w.Code != http.StatusBadRequest {
+		t.Errorf("missing title: expected 400, got %d", w.Code)
+	}
+	if w := post(`{"slug": {"template": "{dir}/{title}"}}`); w.Code != http.StatusOK {
+		t.Fatalf("save settings: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if got := h.server.slugConfig(context.Background()).Template; got != "{dir}/{title}" {
+		t.Errorf("expected the saved template, got %q", got)
+	}
+
+	if w := post(`{"slug": {"models": ["no-such-model"]}}`); w.Code != http.StatusBadRequest {
+		t.Errorf("unknown model: expected 400, got %d", w.Code)
+	}
+	if w := post(`{"slug": {"models": ["predictable"], "prompt": "Title this: {message}"}}`); w.Code != http.StatusOK {
+		t.Fatalf("save settings: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	cfg := h.server.slugConfig(context.Background())
+	if !slices.Equal(cfg.Models, []string{"predictable"}) || cfg.Prompt != "Title this: {message}" {
+		t.Errorf("expected the saved models and prompt, got %+v", cfg)
+	}
+}
+
+func TestSettingsValidation(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+
+	for _, tt := range []struct {
+		name string
+		body string
+	}{
+		{"unknown field", `{"tools": {"enabled": {"bash": true}, "colour": "red"}}`},
+		{"user setting", `{"ui": {"indicatorMode": "inline"}}`},
+		{"unknown guardian model", `{"guardian": {"stream": {"enabled": true, "model": "no-such-model"}}}`},
+		{"deploy target without command", `{"tools": {"deploy": {"prod": {"dir": "/srv"}}}}`},
+		{"relative deploy dir", `{"tools": {"deploy": {"prod": {"command": "make deploy", "dir": "srv"}}}}`},
+		{"bad deploy target name", `{"tools": {"deploy": {"my prod": {"command": "make deploy"}}}}`},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			h.server.handleSettings(w, httptest.NewRequest("POST", "/api/settings", strings.NewReader(tt.body)))
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("expected 400, got %d: %s", w.Code, w.Body.String())
+			}
+		})
+	}
+
+	// A disabled check's model isn't used, so it isn't checked
+	w := httptest.NewRecorder()
+	body := `{"guardian": {"stream": {"enabled": false, "model": "no-such-model"}}}`
+	h.server.handleSettings(w, httptest.NewRequest("POST", "/api/settings", strings.NewReader(body)))
+	if w.Code != http.StatusOK {
+		t.Errorf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestPatchSettings(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+
+	patch := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.server.handleSettings(w, httptest.NewRequest("PATCH", "/api/settings", strings.NewReader(body)))
+		return w
+	}
+
+	if w := patch(`{"guardian": {"stream": {"prompt": "stop if stuck"}}, "tools": {"enabled": {"think": false}}}`); w.Code != http.StatusOK {
+		t.Fatalf("patch settings: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := patch(`{"tools": {"enabled": {"bash": false}}, "slug": {"template": "{repo}/{title}"}}`); w.Code != http.StatusOK {
+		t.Fatalf("patch settings: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := patch(`{"tools": {"bash": {"truncate": "middle"}}}`); w.Code != http.StatusBadRequest {
+		t.Errorf("invalid patch: expected 400, got %d", w.Code)
+	}
+	if w := patch(`["not", "an", "object"]`); w.Code != http.StatusBadRequest {
+		t.Errorf("non-object patch: expected 400, got %d", w.Code)
+	}
+
+	settings, err := GetSettings(context.Background(), h.db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stream := settings.Guardian.Stream; stream.Prompt != "stop if stuck" || stream.Model != "claude-haiku-4-5-20251001" {
+		t.Errorf("expected the prompt patched and the model kept, got %+v", stream)
+	}
+	if settings.Tools.Enabled["think"] || settings.Tools.Enabled["bash"] {
+		t.Errorf("expected both patches to the tool map kept, got %v", settings.Tools.Enabled)
+	}
+	if settings.Slug == nil || settings.Slug.Template != "{repo}/{title}" {
+		t.Errorf("expected the patched slug template, got %+v", settings.Slug)
+	}
+
+	// null removes a setting
+	if w := patch(`{"slug": null}`); w.Code != http.StatusOK {
+		t.Fatalf("patch settings: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if settings, err = GetSettings(context.Background(), h.db); err != nil {
+		t.Fatal(err)
+	}
+	if settings.Slug != nil || settings.Guardian.Stream.Prompt != "stop if stuck" {
+		t.Errorf("expected only the slug settings removed, got %+v", settings)
+	}
+}
+
+func TestUserSettings(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+	h.server.requireHeader = "X-Exedev-Userid"
+
+	request := func(method, user, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/api/user/settings", strings.NewReader(body))
+		req.Header.Set("X-Exedev-Userid", user)
+		w := httptest.NewRecorder()
+		h.server.handleUserSettings(w, req)
+		return w
+	}
+	get := func(user string) UserSettings {
+		t.Helper()
+		w := request("GET", user, "")
+		if w.Code != http.StatusOK {
+			t.Fatalf("get user settings: expected 200, got %d: %s", w.Code, w.Body.String())
+		}
+		var settings UserSettings
+		if err := json.Unmarshal(w.Body.Bytes(), &settings); err != nil {
+			t.Fatal(err)
+		}
+		return settings
+	}
+
+	for _, body := range []string{
+		`{"ui": {"indicatorMode": "sideways"}}`,
+		`{"ui": {"enterBehavior": "shout"}}`,
+		`{"defaultModel": "no-such-model"}`,
+		`{"tools": {"enabled": {"bash": false}}}`,
+	} {
+		if w := request("POST", "alice", body); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", body, w.Code)
+		}
+	}
+
+	if w := request("PATCH", "alice", `{"ui": {"enterBehavior": "queue"}, "defaultModel": "predictable"}`); w.Code != http.StatusOK {
+		t.Fatalf("patch user settings: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	alice := get("alice")
+	if alice.UI.EnterBehavior != "queue" || alice.UI.IndicatorMode != "inline" || alice.DefaultModel != "predictable" {
+		t.Errorf("expected enterBehavior and defaultModel patched and indicatorMode kept, got %+v %+v", alice, alice.UI)
+	}
+	if bob := get("bob"); bob.UI.EnterBehavior != "send" || bob.DefaultModel != "" {
+		t.Errorf("expected another user to keep the defaults, got %+v %+v", bob, bob.UI)
+	}
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Exedev-Userid", "alice")
+	if got := h.server.userDefaultModel(req); got != "predictable" {
+		t.Errorf("expected alice's default model, got %q", got)
+	}
+	req.Header.Set("X-Exedev-Userid", "bob")
+	if got := h.server.userDefaultModel(req); got != "" {
+		t.Errorf("expected no default model for bob, got %q", got)
+	}
+}
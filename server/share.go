@@ -0,0 +1,260 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"html/template"
+	"net/http"
+	"strings"
+	"time"
+
+	"shelley.exe.dev/db"
+	"shelley.exe.dev/db/generated"
+	"shelley.exe.dev/llm"
+)
+
+// A share token exposes a read-only rendering of a conversation at
+// /share/<token> to anyone who has the link. The page shows the messages
+// and tool calls but offers no way to send messages, run tools, or change
+// settings, and none of the /api routes accept the token. Revoking a share
+// makes its link stop working. Like exported datasets, the page has its
+// secrets redacted.
+//
+// Tokens are 130 random bits from crypto/rand (rand.Text) rather than signed
+// values: every request looks its token up in the database, which is what
+// makes it valid and lets it be revoked, so a signature would check nothing
+// the lookup doesn't, and the token can't be guessed.
+
+//go:embed share.html
+var shareTemplateText string
+
+var shareTemplate = template.Must(template.New("share").Parse(shareTemplateText))
+
+// maxSharedToolOutput caps the tool output shown on a share page.
+const maxSharedToolOutput = 8 << 10
+
+// ConversationShare is a share token and the path of its read-only page.
+type ConversationShare struct {
+	generated.ConversationShare
+	Path string `json:"path"`
+}
+
+func newConversationShare(share generated.ConversationShare) ConversationShare {
+	return ConversationShare{ConversationShare: share, Path: "/share/" + share.Token}
+}
+
+// handleConversationShares handles GET and POST /conversation/<id>/shares.
+// POST mints a new share token for the conversation.
+func (s *Server) handleConversationShares(w http.ResponseWriter, r *http.Request, conversationID string) {
+	ctx := r.Context()
+	if _, err := s.db.GetConversationByID(ctx, conversationID); err != nil {
+		http.Error(w, "Conversation not found", http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		shares, err := s.db.ListConversationShares(ctx, conversationID)
+		if err != nil {
+			s.logger.Error("Failed to list shares", "conversationID", conversationID, "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		result := make([]ConversationShare, 0, len(shares))
+		for _, share := range shares {
+			result = append(result, newConversationShare(share))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+	case http.MethodPost:
+		var userID *string
+		if id := s.userID(r); id != "" {
+			userID = &id
+		}
+		share, err := s.db.CreateConversationShare(ctx, conversationID, userID)
+		if err != nil {
+			s.logger.Error("Failed to create share", "conversationID", conversationID, "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(newConversationShare(*share))
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleRevokeShare handles POST /conversation/<id>/shares/<token>/revoke
+func (s *Server) handleRevokeShare(w http.ResponseWriter, r *http.Request, conversationID, token string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	err := s.db.RevokeConversationShare(r.Context(), conversationID, token)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Share not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		s.logger.Error("Failed to revoke share", "conversationID", conversationID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "revoked"})
+}
+
+// sharePage is the data rendered by share.html.
+type sharePage struct {
+	Title     string
+	CreatedAt time.Time
+	Entries   []shareEntry
+}
+
+// shareEntry is one message on a share page.
+type shareEntry struct {
+	Role  string
+	Text  []string
+	Tools []*shareTool
+}
+
+// shareTool is a tool call and, once it has finished, its output.
+type shareTool struct {
+	Name   string
+	Input  string
+	Output string
+	Error  bool
+}
+
+// handleSharedConversation handles GET /share/<token>, rendering the shared
+// conversation as a standalone read-only page.
+func (s *Server) handleSharedConversation(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ctx := r.Context()
+	share, err := s.db.GetConversationShare(ctx, r.PathValue("token"))
+	if err != nil {
+		http.Error(w, "Share not found", http.StatusNotFound)
+		return
+	}
+	page, err := s.buildSharePage(ctx, share.ConversationID)
+	if err != nil {
+		s.logger.Error("Failed to render share", "conversationID", share.ConversationID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := shareTemplate.Execute(&buf, page); err != nil {
+		s.logger.Error("Failed to render share", "conversationID", share.ConversationID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Robots-Tag", "noindex")
+	w.Write(buf.Bytes())
+}
+
+// buildSharePage collects the user-visible parts of a conversation, with
+// their secrets redacted. System prompts, thinking, and git status messages
+// are left out.
+func (s *Server) buildSharePage(ctx context.Context, conversationID string) (*sharePage, error) {
+	conversation, err := s.db.GetConversationByID(ctx, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	redactor, err := s.exportRedactor(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var messages []generated.Message
+	err = s.db.Queries(ctx, func(q *generated.Queries) error {
+		var err error
+		messages, err = q.ListMessages(ctx, conversationID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	page := &sharePage{Title: conversationID, CreatedAt: conversation.CreatedAt}
+	if conversation.Slug != nil {
+		page.Title = *conversation.Slug
+	}
+	tools := make(map[string]*shareTool)
+	for _, msg := range messages {
+		switch db.MessageType(msg.Type) {
+		case db.MessageTypeUser, db.MessageTypeAgent, db.MessageTypeError, db.MessageTypeSummary:
+		default:
+			continue
+		}
+		if msg.LlmData == nil {
+			continue
+		}
+		var m llm.Message
+		if err := json.Unmarshal([]byte(*msg.LlmData), &m); err != nil {
+			continue
+		}
+		entry := shareEntry{Role: msg.Type}
+		for _, c := range m.Content {
+			switch c.Type {
+			case llm.ContentTypeText:
+				if strings.TrimSpace(c.Text) != "" {
+					entry.Text = append(entry.Text, redactor.String(c.Text))
+				}
+			case llm.ContentTypeToolUse:
+				tool := &shareTool{Name: c.ToolName, Input: formatToolInput(redactJSON(redactor, c.ToolInput))}
+				tools[c.ID] = tool
+				entry.Tools = append(entry.Tools, tool)
+			case llm.ContentTypeToolResult:
+				// Shown with the call it answers
+				if tool, ok := tools[c.ToolUseID]; ok {
+					tool.Output = truncateSharedOutput(redactor.String(toolResultText(c.ToolResult)))
+					tool.Error = c.ToolError
+				}
+			}
+		}
+		if len(entry.Text) > 0 || len(entry.Tools) > 0 {
+			page.Entries = append(page.Entries, entry)
+		}
+	}
+	return page, nil
+}
+
+// formatToolInput indents a tool's JSON input for display.
+func formatToolInput(input json.RawMessage) string {
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, input, "", "  "); err != nil {
+		return string(input)
+	}
+	return buf.String()
+}
+
+// toolResultText joins the text parts of a tool result.
+func toolResultText(result []llm.Content) string {
+	var parts []string
+	for _, c := range result {
+		if c.Type == llm.ContentTypeText {
+			parts = append(parts, c.Text)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+func truncateSharedOutput(output string) string {
+	if len(output) <= maxSharedToolOutput {
+		return output
+	}
+	return strings.ToValidUTF8(output[:maxSharedToolOutput], "") + "\n[output truncated]"
+}
@@ -0,0 +1,83 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestConversationShares(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+
+	h.NewConversation("bash: echo shared-output token=sharedsecret123", "")
+	h.WaitToolResult()
+	h.WaitResponse()
+
+	req := httptest.NewRequest("POST", "/api/conversation/"+h.convID+"/shares", nil)
+	w := httptest.NewRecorder()
+	h.server.handleConversationShares(w, req, h.convID)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var share ConversationShare
+	if err := json.Unmarshal(w.Body.Bytes(), &share); err != nil {
+		t.Fatalf("failed to parse share: %v", err)
+	}
+	if share.Token == "" || share.Path != "/share/"+share.Token {
+		t.Fatalf("unexpected share: %+v", share)
+	}
+
+	view := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", share.Path, nil)
+		req.SetPathValue("token", share.Token)
+		w := httptest.NewRecorder()
+		h.server.handleSharedConversation(w, req)
+		return w
+	}
+	w = view()
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	body := w.Body.String()
+	for _, want := range []string{"bash: echo shared-output token=[REDACTED]", "shared-output token=[REDACTED]\n", "<summary>bash"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("share page missing %q", want)
+		}
+	}
+	if strings.Contains(body, "sharedsecret123") {
+		t.Error("share page should redact secrets")
+	}
+	if strings.Contains(body, "<form") || strings.Contains(body, "<script") {
+		t.Error("share page should be static and read-only")
+	}
+
+	req = httptest.NewRequest("GET", "/api/conversation/"+h.convID+"/shares", nil)
+	w = httptest.NewRecorder()
+	h.server.handleConversationShares(w, req, h.convID)
+	var shares []ConversationShare
+	if err := json.Unmarshal(w.Body.Bytes(), &shares); err != nil {
+		t.Fatalf("failed to parse shares: %v", err)
+	}
+	if len(shares) != 1 || shares[0].Token != share.Token {
+		t.Fatalf("expected the new share to be listed, got %+v", shares)
+	}
+
+	revoke := func(conversationID string) int {
+		req := httptest.NewRequest("POST", "/api/conversation/"+conversationID+"/shares/"+share.Token+"/revoke", nil)
+		w := httptest.NewRecorder()
+		h.server.handleRevokeShare(w, req, conversationID, share.Token)
+		return w.Code
+	}
+	if code := revoke("cother"); code != http.StatusNotFound {
+		t.Errorf("expected status 404 revoking through another conversation, got %d", code)
+	}
+	if code := revoke(h.convID); code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", code)
+	}
+	if w := view(); w.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 for a revoked share, got %d", w.Code)
+	}
+}
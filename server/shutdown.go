@@ -0,0 +1,110 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"slices"
+	"time"
+)
+
+// At shutdown the server drains: it stops starting turns, and gives the
+// turns in flight, their LLM calls and tools, a grace period to finish and
+// record their results. Only the turns still running once it's over are
+// left to recovery at the next start, which has to make up results for the
+// tools they were running.
+
+// DefaultShutdownGrace is how long the turns in flight get to finish at
+// shutdown, unless SetShutdownGrace says otherwise. A service manager's stop
+// timeout should be longer.
+const DefaultShutdownGrace = 30 * time.Second
+
+// drainPollInterval is how often the turns in flight are checked at shutdown.
+const drainPollInterval = 100 * time.Millisecond
+
+// errShuttingDown refuses turns while the server drains.
+var errShuttingDown = errors.New("the server is shutting down; try again once it's back")
+
+// SetShutdownGrace sets how long the turns in flight get to finish when the
+// server shuts down. Zero doesn't wait for them.
+func (s *Server) SetShutdownGrace(grace time.Duration) {
+	s.shutdownGrace = grace
+}
+
+// refuseWhileDraining answers a request that would start a turn with 503 if
+// the server is draining, and reports whether it did.
+func (s *Server) refuseWhileDraining(w http.ResponseWriter) bool {
+	if !s.draining.Load() {
+		return false
+	}
+	http.Error(w, errShuttingDown.Error(), http.StatusServiceUnavailable)
+	return true
+}
+
+// drainTurns stops new turns and waits for the turns in flight to end, for
+// up to the shutdown grace, or until stop receives another signal. Queued
+// messages are marked as not sent, as they won't be.
+func (s *Server) drainTurns(stop <-chan os.Signal) {
+	s.draining.Store(true)
+	s.failQueuedMessages(errShuttingDown.Error())
+	if s.shutdownGrace <= 0 {
+		return
+	}
+	deadline := time.NewTimer(s.shutdownGrace)
+	defer deadline.Stop()
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+	for waited := false; ; waited = true {
+		working := s.workingConversations(context.Background())
+		if len(working) == 0 {
+			if waited {
+				s.logger.Info("Turns in flight finished")
+			}
+			return
+		}
+		if !waited {
+			s.logger.Info("Waiting for turns in flight to finish", "conversations", working, "grace", s.shutdownGrace)
+		}
+		select {
+		case <-deadline.C:
+			s.logger.Warn("Shutdown grace period is over; unfinished turns will be recovered at the next start", "conversations", working)
+			return
+		case <-stop:
+			s.logger.Warn("Shutting down without waiting for turns in flight", "conversations", working)
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// workingConversations returns the conversations whose loop is in the middle
+// of a turn, other than those waiting for the user to answer a question,
+// which won't finish without them.
+func (s *Server) workingConversations(ctx context.Context) []string {
+	s.mu.Lock()
+	managers := make([]*ConversationManager, 0, len(s.activeConversations))
+	for _, manager := range s.activeConversations {
+		managers = append(managers, manager)
+	}
+	s.mu.Unlock()
+
+	var working []string
+	for _, manager := range managers {
+		manager.mu.Lock()
+		running := manager.loop != nil
+		manager.mu.Unlock()
+		if !running {
+			continue
+		}
+		if _, waiting := manager.pendingQuestion(); waiting {
+			continue
+		}
+		conv, err := s.db.GetConversationByID(ctx, manager.conversationID)
+		if err == nil && conv.AgentWorking {
+			working = append(working, manager.conversationID)
+		}
+	}
+	slices.Sort(working)
+	return working
+}
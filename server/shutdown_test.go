@@ -0,0 +1,64 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"shelley.exe.dev/db"
+)
+
+func TestDrainTurns(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+	h.server.SetShutdownGrace(h.timeout)
+	h.NewConversation("delay: 0.3", t.TempDir())
+
+	h.server.drainTurns(nil)
+	var replied bool
+	for _, msg := range h.messages() {
+		replied = replied || msg.Type == string(db.MessageTypeAgent) && strings.Contains(messageText(msg), "Delayed for 0.3 seconds")
+	}
+	if !replied {
+		t.Fatal("expected the turn in flight to finish before the drain returned")
+	}
+	if working := h.server.workingConversations(context.Background()); len(working) != 0 {
+		t.Errorf("expected no turns in flight, got %v", working)
+	}
+
+	// No turns start once the server drains
+	body, _ := json.Marshal(ChatRequest{Message: "echo: again", Model: "predictable"})
+	w := httptest.NewRecorder()
+	h.server.handleChatConversation(w, httptest.NewRequest("POST", "/api/conversation/"+h.convID+"/chat", strings.NewReader(string(body))), h.convID)
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected a message refused, got %d: %s", w.Code, w.Body.String())
+	}
+	w = httptest.NewRecorder()
+	h.server.handleNewConversation(w, httptest.NewRequest("POST", "/api/conversations/new", strings.NewReader(string(body))))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected a new conversation refused, got %d: %s", w.Code, w.Body.String())
+	}
+	if n := h.userTexts("again"); n != 0 {
+		t.Errorf("expected the refused message not recorded, got %d", n)
+	}
+}
+
+func TestDrainTurnsGracePeriod(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+	h.server.SetShutdownGrace(200 * time.Millisecond)
+	h.NewConversation("delay: 3", t.TempDir())
+
+	start := time.Now()
+	h.server.drainTurns(nil)
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("expected the drain to stop after the grace period, took %s", elapsed)
+	}
+	if working := h.server.workingConversations(context.Background()); len(working) != 1 || working[0] != h.convID {
+		t.Errorf("expected the turn still in flight, got %v", working)
+	}
+}
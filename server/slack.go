@@ -0,0 +1,288 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	slackPlatform = "slack"
+	// maxSlackEvent caps the size of an event request body.
+	maxSlackEvent = 1 << 20
+	// maxSlackText caps the agent's text in a reply; Slack truncates
+	// messages at 40,000 characters.
+	maxSlackText = 3000
+	// slackSignatureMaxAge is how old a signed request may be, limiting replays.
+	slackSignatureMaxAge = 5 * time.Minute
+)
+
+// slackAPIURL is the base URL of the Slack Web API.
+var slackAPIURL = "https://slack.com/api"
+
+var (
+	slackMentionPattern = regexp.MustCompile(`<@[A-Z0-9]+(\|[^>]*)?>`)
+	slackLinkPattern    = regexp.MustCompile(`<([^@#!>|][^>|]*)(\|[^>]*)?>`)
+)
+
+// SlackBotSettings configures the Slack bot. Events are sent to
+// /hooks/slack/events; the Slack app needs the app_mention and
+// message.channels events and the chat:write scope.
+type SlackBotSettings struct {
+	// BotToken is the bot's xoxb- token, used to post replies.
+	BotToken string `json:"botToken,omitempty" secret:"true"`
+	// SigningSecret verifies that events come from Slack.
+	SigningSecret string `json:"signingSecret,omitempty" secret:"true"`
+	// Channels are the IDs of channels where every message starts or
+	// continues a conversation; elsewhere the bot has to be @mentioned.
+	Channels []string `json:"channels,omitempty"`
+	// Users are the IDs of the only users the bot answers. Anyone in the
+	// bot's channels could otherwise run commands here, so there must be
+	// some.
+	Users []string `json:"users,omitempty"`
+	BotConversationSettings
+}
+
+func (b *SlackBotSettings) validate(hasModel func(string) bool) error {
+	if b == nil {
+		return nil
+	}
+	if b.BotToken == "" || b.SigningSecret == "" || len(b.Users) == 0 {
+		return fmt.Errorf("slack bot needs a botToken, a signingSecret and at least one user ID")
+	}
+	return b.BotConversationSettings.validate(hasModel)
+}
+
+// slackEnvelope is an Events API request.
+type slackEnvelope struct {
+	Type      string     `json:"type"`
+	Challenge string     `json:"challenge"`
+	Event     slackEvent `json:"event"`
+}
+
+// slackEvent is a message or app_mention event.
+type slackEvent struct {
+	Type     string `json:"type"`
+	Subtype  string `json:"subtype"`
+	User     string `json:"user"`
+	BotID    string `json:"bot_id"`
+	Text     string `json:"text"`
+	Channel  string `json:"channel"`
+	TS       string `json:"ts"`
+	ThreadTS string `json:"thread_ts"`
+}
+
+// handleSlackEvents handles POST /hooks/slack/events, the Slack Events API
+// endpoint. Messages are sent to the agent after responding, since Slack
+// expects an answer within three seconds.
+func (s *Server) handleSlackEvents(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	settings, err := GetSettings(ctx, s.db)
+	if err != nil {
+		s.logger.Error("Failed to get settings", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if settings.Bots == nil || settings.Bots.Slack == nil {
+		http.Error(w, "Slack bot is not configured", http.StatusNotFound)
+		return
+	}
+	bot := settings.Bots.Slack
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxSlackEvent))
+	if err != nil {
+		http.Error(w, "Failed to read body", http.StatusBadRequest)
+		return
+	}
+	if err := verifySlackSignature(bot.SigningSecret, r.Header, body, time.Now()); err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	var envelope slackEnvelope
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	switch envelope.Type {
+	case "url_verification":
+		w.Header().Set("Content-Type", "text/plain")
+		io.WriteString(w, envelope.Challenge)
+		return
+	case "event_callback":
+		if threadID, ok := s.slackThread(ctx, bot, envelope.Event); ok {
+			go s.handleSlackMessage(context.WithoutCancel(ctx), bot, threadID, slackText(envelope.Event.Text))
+		}
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// slackThread decides whether an event is a message for the agent,
+// returning the thread it belongs to. Messages count in the configured
+// channels and in threads that already have a conversation; elsewhere
+// only mentions of the bot do.
+func (s *Server) slackThread(ctx context.Context, bot *SlackBotSettings, event slackEvent) (string, bool) {
+	if event.Subtype != "" || event.BotID != "" || event.User == "" || strings.TrimSpace(event.Text) == "" {
+		return "", false
+	}
+	if !slices.Contains(bot.Users, event.User) {
+		return "", false
+	}
+	ts := event.ThreadTS
+	if ts == "" {
+		ts = event.TS
+	}
+	threadID := event.Channel + ":" + ts
+
+	switch event.Type {
+	case "app_mention":
+	case "message":
+		if !slices.Contains(bot.Channels, event.Channel) {
+			if _, err := s.db.GetBridgeThread(ctx, slackPlatform, threadID); err != nil {
+				return "", false
+			}
+		}
+	default:
+		return "", false
+	}
+	// A mention in a watched channel arrives as both kinds of event
+	if s.bridgeSeen(slackPlatform + ":" + event.Channel + ":" + event.TS) {
+		return "", false
+	}
+	return threadID, true
+}
+
+// handleSlackMessage sends a Slack message to the agent, reporting failures
+// in the thread.
+func (s *Server) handleSlackMessage(ctx context.Context, bot *SlackBotSettings, threadID, text string) {
+	if text == "" {
+		return
+	}
+	if _, err := s.bridgeMessage(ctx, slackPlatform, threadID, text, bot.BotConversationSettings); err != nil {
+		s.logger.Error("Failed to send Slack message to the agent", "threadID", threadID, "error", err)
+		poster := &slackPoster{token: bot.BotToken}
+		reply := bridgeReply{Text: "Failed to send the message to the agent: " + err.Error(), Error: true}
+		if err := poster.postReply(ctx, threadID, reply); err != nil {
+			s.logger.Warn("Failed to post Slack error", "threadID", threadID, "error", err)
+		}
+	}
+}
+
+// verifySlackSignature checks a request's v0 signature, an HMAC of its
+// timestamp and body keyed with the signing secret.
+func verifySlackSignature(secret string, header http.Header, body []byte, now time.Time) error {
+	timestamp := header.Get("X-Slack-Request-Timestamp")
+	sec, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return errors.New("missing or invalid Slack request timestamp")
+	}
+	if age := now.Sub(time.Unix(sec, 0)); age > slackSignatureMaxAge || age < -slackSignatureMaxAge {
+		return errors.New("stale Slack request timestamp")
+	}
+	if !hmac.Equal([]byte(slackSignature(secret, timestamp, body)), []byte(header.Get("X-Slack-Signature"))) {
+		return errors.New("invalid Slack signature")
+	}
+	return nil
+}
+
+func slackSignature(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("v0:" + timestamp + ":"))
+	mac.Write(body)
+	return "v0=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// slackText turns Slack's message markup into plain text: mentions are
+// dropped, links reduced to their URL and entities unescaped.
+func slackText(text string) string {
+	text = slackMentionPattern.ReplaceAllString(text, "")
+	text = slackLinkPattern.ReplaceAllString(text, "$1")
+	text = strings.NewReplacer("&lt;", "<", "&gt;", ">", "&amp;", "&").Replace(text)
+	return strings.TrimSpace(text)
+}
+
+// slackEscape escapes the characters Slack treats as markup.
+func slackEscape(text string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(text)
+}
+
+// slackReplyText formats a reply as Slack mrkdwn.
+func slackReplyText(reply bridgeReply) string {
+	var b strings.Builder
+	if reply.Error {
+		b.WriteString(":warning: ")
+	}
+	b.WriteString(slackEscape(truncateText(reply.Text, maxSlackText)))
+	if len(reply.Options) > 0 {
+		b.WriteString("\n\n_Reply with one of:_ ")
+		for i, option := range reply.Options {
+			if i > 0 {
+				b.WriteString(" / ")
+			}
+			b.WriteString("`" + slackEscape(option) + "`")
+		}
+	}
+	if len(reply.Tools) > 0 {
+		b.WriteString("\n\n*Tools*")
+		for _, tool := range reply.Tools {
+			b.WriteString("\n• `" + slackEscape(strings.ReplaceAll(tool, "`", "'")) + "`")
+		}
+	}
+	if reply.URL != "" {
+		b.WriteString("\n\n<" + reply.URL + "|Open in Shelley>")
+	}
+	return b.String()
+}
+
+// slackPoster posts replies with chat.postMessage. Thread IDs are
+// "<channel>:<thread ts>".
+type slackPoster struct {
+	token string
+}
+
+func (p *slackPoster) postReply(ctx context.Context, threadID string, reply bridgeReply) error {
+	channel, ts, _ := strings.Cut(threadID, ":")
+	body, err := json.Marshal(map[string]any{
+		"channel":      channel,
+		"thread_ts":    ts,
+		"text":         slackReplyText(reply),
+		"unfurl_links": false,
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, slackAPIURL+"/chat.postMessage", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("Authorization", "Bearer "+p.token)
+	resp, err := bridgeHTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	var result struct {
+		OK    bool   `json:"ok"`
+		Error string `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("slack: %s", resp.Status)
+	}
+	if !result.OK {
+		return fmt.Errorf("slack: %s", result.Error)
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// slackPost is a chat.postMessage request received by a fake Slack API.
+type slackPost struct {
+	Channel  string `json:"channel"`
+	ThreadTS string `json:"thread_ts"`
+	Text     string `json:"text"`
+}
+
+func TestSlackBot(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+
+	posts := make(chan slackPost, 10)
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/chat.postMessage" || r.Header.Get("Authorization") != "Bearer xoxb-test" {
+			t.Errorf("unexpected Slack API request %s %q", r.URL.Path, r.Header.Get("Authorization"))
+		}
+		var post slackPost
+		json.NewDecoder(r.Body).Decode(&post)
+		posts <- post
+		w.Write([]byte(`{"ok": true}`))
+	}))
+	defer api.Close()
+	defer func(old string) { slackAPIURL = old }(slackAPIURL)
+	slackAPIURL = api.URL
+
+	settings := `{"bots": {"slack": {"botToken": "xoxb-test", "signingSecret": "secret", "channels": ["C1"], "users": ["U1"], "model": "predictable"}}}`
+	w := httptest.NewRecorder()
+	h.server.handleSettings(w, httptest.NewRequest("POST", "/api/settings", strings.NewReader(strings.Replace(settings, `"users": ["U1"], `, "", 1))))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("save settings without users: expected 400, got %d", w.Code)
+	}
+	w = httptest.NewRecorder()
+	h.server.handleSettings(w, httptest.NewRequest("POST", "/api/settings", strings.NewReader(settings)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("save settings: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	mux := http.NewServeMux()
+	h.server.RegisterRoutes(mux)
+	send := func(body, secret string) *httptest.ResponseRecorder {
+		t.Helper()
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		req := httptest.NewRequest("POST", "/hooks/slack/events", strings.NewReader(body))
+		req.Header.Set("X-Slack-Request-Timestamp", timestamp)
+		req.Header.Set("X-Slack-Signature", slackSignature(secret, timestamp, []byte(body)))
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w
+	}
+	wait := func() slackPost {
+		t.Helper()
+		select {
+		case post := <-posts:
+			return post
+		case <-time.After(h.timeout):
+			t.Fatal("timed out waiting for a Slack reply")
+			return slackPost{}
+		}
+	}
+
+	if w := send(`{"type": "url_verification", "challenge": "abc"}`, "secret"); w.Code != http.StatusOK || w.Body.String() != "abc" {
+		t.Fatalf("url_verification: got %d %q", w.Code, w.Body.String())
+	}
+	if w := send(`{"type": "url_verification", "challenge": "abc"}`, "wrong"); w.Code != http.StatusUnauthorized {
+		t.Fatalf("bad signature: expected 401, got %d", w.Code)
+	}
+
+	// A message in a watched channel starts a conversation
+	send(`{"type": "event_callback", "event": {"type": "message", "user": "U1", "text": "echo: hello &amp; welcome", "channel": "C1", "ts": "100.1"}}`, "secret")
+	post := wait()
+	if post.Channel != "C1" || post.ThreadTS != "100.1" || !strings.HasPrefix(post.Text, "hello &amp; welcome") {
+		t.Errorf("unexpected reply %+v", post)
+	}
+	thread, err := h.db.GetBridgeThread(context.Background(), slackPlatform, "C1:100.1")
+	if err != nil {
+		t.Fatalf("thread not mapped: %v", err)
+	}
+
+	// Replies in the thread continue it, listing the tools used
+	send(`{"type": "event_callback", "event": {"type": "message", "user": "U1", "text": "bash: echo hi", "channel": "C1", "ts": "100.2", "thread_ts": "100.1"}}`, "secret")
+	post = wait()
+	if post.ThreadTS != "100.1" || !strings.Contains(post.Text, "*Tools*") || !strings.Contains(post.Text, "bash: echo hi") {
+		t.Errorf("unexpected reply %+v", post)
+	}
+	if next, err := h.db.GetBridgeThreadByConversation(context.Background(), thread.ConversationID); err != nil || next.ThreadID != "C1:100.1" {
+		t.Errorf("second message went to another conversation: %+v, %v", next, err)
+	}
+}
+
+func TestSlackThread(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+
+	bot := &SlackBotSettings{Channels: []string{"C1"}, Users: []string{"U1", "U2"}}
+	tests := []struct {
+		name   string
+		event  slackEvent
+		thread string
+	}{
+		{"watched channel", slackEvent{Type: "message", User: "U1", Text: "hi", Channel: "C1", TS: "1.0"}, "C1:1.0"},
+		{"thread reply", slackEvent{Type: "message", User: "U1", Text: "hi", Channel: "C1", TS: "1.1", ThreadTS: "1.0"}, "C1:1.0"},
+		{"redelivered", slackEvent{Type: "app_mention", User: "U1", Text: "hi", Channel: "C1", TS: "1.1", ThreadTS: "1.0"}, ""},
+		{"other channel", slackEvent{Type: "message", User: "U1", Text: "hi", Channel: "C2", TS: "2.0"}, ""},
+		{"mention", slackEvent{Type: "app_mention", User: "U2", Text: "<@UBOT> hi", Channel: "C2", TS: "2.1"}, "C2:2.1"},
+		{"other user", slackEvent{Type: "app_mention", User: "U3", Text: "hi", Channel: "C2", TS: "2.2"}, ""},
+		{"bot", slackEvent{Type: "message", User: "U1", BotID: "B1", Text: "hi", Channel: "C1", TS: "3.0"}, ""},
+		{"edit", slackEvent{Type: "message", Subtype: "message_changed", Channel: "C1", TS: "3.1"}, ""},
+	}
+	for _, tt := range tests {
+		thread, _ := h.server.slackThread(context.Background(), bot, tt.event)
+		if thread != tt.thread {
+			t.Errorf("%s: got thread %q, want %q", tt.name, thread, tt.thread)
+		}
+	}
+
+	if got := slackText("<@U123> look at <https://example.com|example> &lt;now&gt;"); got != "look at https://example.com <now>" {
+		t.Errorf("slackText: got %q", got)
+	}
+}
+
+func TestVerifySlackSignature(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	body := []byte(`{"type": "event_callback"}`)
+	header := func(timestamp, signature string) http.Header {
+		h := http.Header{}
+		h.Set("X-Slack-Request-Timestamp", timestamp)
+		h.Set("X-Slack-Signature", signature)
+		return h
+	}
+	if err := verifySlackSignature("secret", header("1700000000", slackSignature("secret", "1700000000", body)), body, now); err != nil {
+		t.Errorf("valid signature rejected: %v", err)
+	}
+	if err := verifySlackSignature("secret", header("1700000000", slackSignature("other", "1700000000", body)), body, now); err == nil {
+		t.Error("signature with the wrong secret accepted")
+	}
+	if err := verifySlackSignature("secret", header("1699999000", slackSignature("secret", "1699999000", body)), body, now); err == nil {
+		t.Error("stale signature accepted")
+	}
+}
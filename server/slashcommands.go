@@ -0,0 +1,506 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"shelley.exe.dev/db"
+	"shelley.exe.dev/db/generated"
+	"shelley.exe.dev/gitstate"
+	"shelley.exe.dev/llm"
+	"shelley.exe.dev/loop"
+)
+
+// Slash commands are chat messages like "/model gpt-5" that the server acts
+// on instead of sending them to the LLM. Messages starting with a slash that
+// don't name a registered command (such as paths) are sent as usual.
+
+// slashCommand is a command that can be typed into a conversation.
+type slashCommand struct {
+	Name        string
+	Args        string // argument synopsis shown by /help, e.g. "[model]"
+	Description string
+	Run         func(ctx context.Context, s *Server, call *slashCommandCall) (*SlashCommandResponse, error)
+}
+
+// slashCommandCall is one invocation of a slash command.
+type slashCommandCall struct {
+	ConversationID string
+	Args           string
+	// Model is the model the chat request asked for.
+	Model string
+}
+
+// SlashCommandResponse is the response to a chat message that ran a slash command.
+type SlashCommandResponse struct {
+	Status  string `json:"status"` // always "command"
+	Command string `json:"command"`
+	// Output is shown to the user; it is not added to the conversation.
+	Output string `json:"output"`
+	// Model is the conversation's model, set by /model.
+	Model string `json:"model,omitempty"`
+	// ConversationID is set when the command created a conversation, as /fork does.
+	ConversationID string `json:"conversation_id,omitempty"`
+}
+
+// slashCommandError is a failure reported to the user with an HTTP status.
+type slashCommandError struct {
+	Status  int
+	Message string
+}
+
+func (e *slashCommandError) Error() string { return e.Message }
+
+func slashErrorf(status int, format string, args ...any) error {
+	return &slashCommandError{Status: status, Message: fmt.Sprintf(format, args...)}
+}
+
+var errAgentBusy = errors.New("agent is working; cancel or wait for the turn to end")
+
+// slashCommands holds the registered commands by name.
+var slashCommands = map[string]*slashCommand{}
+
+// registerSlashCommand adds a command, replacing any with the same name.
+func registerSlashCommand(cmd *slashCommand) {
+	slashCommands[cmd.Name] = cmd
+}
+
+func init() {
+	registerSlashCommand(&slashCommand{
+		Name:        "help",
+		Description: "List the available commands",
+		Run:         runHelpCommand,
+	})
+	registerSlashCommand(&slashCommand{
+		Name:        "compact",
+		Description: "Summarize the conversation so far to free up context",
+		Run:         runCompactCommand,
+	})
+	registerSlashCommand(&slashCommand{
+		Name:        "model",
+		Args:        "[model]",
+		Description: "Show or change the conversation's model",
+		Run:         runModelCommand,
+	})
+	registerSlashCommand(&slashCommand{
+		Name:        "retry",
+		Description: "Discard the last turn and send its message again",
+		Run:         runRetryCommand,
+	})
+	registerSlashCommand(&slashCommand{
+		Name:        "fork",
+		Description: "Copy the conversation into a new one",
+		Run:         runForkCommand,
+	})
+	registerSlashCommand(&slashCommand{
+		Name:        "cwd",
+		Args:        "[dir]",
+		Description: "Show or change the working directory",
+		Run:         runCwdCommand,
+	})
+}
+
+// parseSlashCommand returns the registered command a message invokes and
+// its arguments, or nil if the message isn't a command.
+func parseSlashCommand(message string) (*slashCommand, string) {
+	message = strings.TrimSpace(message)
+	if !strings.HasPrefix(message, "/") {
+		return nil, ""
+	}
+	name, args, _ := strings.Cut(message[1:], " ")
+	cmd, ok := slashCommands[name]
+	if !ok {
+		return nil, ""
+	}
+	return cmd, strings.TrimSpace(args)
+}
+
+// handleSlashCommand runs a command sent to POST /conversation/<id>/chat.
+func (s *Server) handleSlashCommand(w http.ResponseWriter, r *http.Request, conversationID string, cmd *slashCommand, args string, req ChatRequest) {
+	ctx := r.Context()
+	if _, err := s.db.GetConversationByID(ctx, conversationID); err != nil {
+		http.Error(w, "Conversation not found", http.StatusNotFound)
+		return
+	}
+
+	resp, err := cmd.Run(ctx, s, &slashCommandCall{
+		ConversationID: conversationID,
+		Args:           args,
+		Model:          req.Model,
+	})
+	var cmdErr *slashCommandError
+	switch {
+	case err == nil:
+	case errors.As(err, &cmdErr):
+		http.Error(w, cmdErr.Message, cmdErr.Status)
+		return
+	case errors.Is(err, errAgentBusy), errors.Is(err, loop.ErrLoopBusy):
+		http.Error(w, "Agent is working; cancel or wait for the turn to end", http.StatusConflict)
+		return
+	default:
+		s.logger.Error("Slash command failed", "conversationID", conversationID, "command", cmd.Name, "error", err)
+		http.Error(w, fmt.Sprintf("/%s failed", cmd.Name), http.StatusInternalServerError)
+		return
+	}
+
+	resp.Status = "command"
+	resp.Command = cmd.Name
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// checkAgentIdle returns the conversation's messages, or errAgentBusy if the
+// agent is in the middle of a turn.
+func (s *Server) checkAgentIdle(ctx context.Context, conversationID string) ([]generated.Message, error) {
+	var messages []generated.Message
+	err := s.db.Queries(ctx, func(q *generated.Queries) error {
+		var err error
+		messages, err = q.ListMessages(ctx, conversationID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if s.agentBusy(conversationID, messages) {
+		return nil, errAgentBusy
+	}
+	return messages, nil
+}
+
+// resetConversationManager makes an active conversation reload its state
+// from the database before it is next used.
+func (s *Server) resetConversationManager(conversationID string) {
+	s.mu.Lock()
+	manager, ok := s.activeConversations[conversationID]
+	s.mu.Unlock()
+	if ok {
+		manager.Reset()
+	}
+}
+
+func runHelpCommand(ctx context.Context, s *Server, call *slashCommandCall) (*SlashCommandResponse, error) {
+	var b strings.Builder
+	b.WriteString("Commands:\n")
+	for _, name := range slices.Sorted(maps.Keys(slashCommands)) {
+		cmd := slashCommands[name]
+		usage := "/" + cmd.Name
+		if cmd.Args != "" {
+			usage += " " + cmd.Args
+		}
+		fmt.Fprintf(&b, "  %-16s %s\n", usage, cmd.Description)
+	}
+	return &SlashCommandResponse{Output: strings.TrimSuffix(b.String(), "\n")}, nil
+}
+
+func runCompactCommand(ctx context.Context, s *Server, call *slashCommandCall) (*SlashCommandResponse, error) {
+	conversation, err := s.db.GetConversationByID(ctx, call.ConversationID)
+	if err != nil {
+		return nil, err
+	}
+	err = s.compactConversation(ctx, conversation)
+	switch {
+	case errors.Is(err, errUnsupportedModel):
+		return nil, slashErrorf(http.StatusBadRequest, "%s", err.Error())
+	case errors.Is(err, loop.ErrNothingToCompact):
+		return nil, slashErrorf(http.StatusConflict, "Nothing to compact")
+	case errors.Is(err, errConversationModelMismatch):
+		return nil, slashErrorf(http.StatusBadRequest, "%s", err.Error())
+	case err != nil:
+		return nil, err
+	}
+	return &SlashCommandResponse{Output: "Conversation compacted."}, nil
+}
+
+func runModelCommand(ctx context.Context, s *Server, call *slashCommandCall) (*SlashCommandResponse, error) {
+	conversation, err := s.db.GetConversationByID(ctx, call.ConversationID)
+	if err != nil {
+		return nil, err
+	}
+	current := s.defaultModel
+	if conversation.ModelID != nil {
+		current = *conversation.ModelID
+	}
+	if call.Args == "" {
+		return &SlashCommandResponse{
+			Output: fmt.Sprintf("Model: %s\nAvailable: %s", current, strings.Join(s.llmManager.GetAvailableModels(), ", ")),
+			Model:  current,
+		}, nil
+	}
+
+	model := call.Args
+	if !s.llmManager.HasModel(model) {
+		return nil, slashErrorf(http.StatusBadRequest, "Unknown model: %s", model)
+	}
+	if _, err := s.checkAgentIdle(ctx, call.ConversationID); err != nil {
+		return nil, err
+	}
+	if err := s.db.QueriesTx(ctx, func(q *generated.Queries) error {
+		return q.UpdateConversationModelID(ctx, generated.UpdateConversationModelIDParams{
+			ModelID:        &model,
+			ConversationID: call.ConversationID,
+		})
+	}); err != nil {
+		return nil, err
+	}
+	// The loop is tied to the model it was started with
+	s.resetConversationManager(call.ConversationID)
+	go s.broadcastConversationUpdate(context.WithoutCancel(ctx), call.ConversationID)
+
+	return &SlashCommandResponse{
+		Output: fmt.Sprintf("Model changed from %s to %s.", current, model),
+		Model:  model,
+	}, nil
+}
+
+func runRetryCommand(ctx context.Context, s *Server, call *slashCommandCall) (*SlashCommandResponse, error) {
+	messages, err := s.checkAgentIdle(ctx, call.ConversationID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Find the last message the user typed, skipping tool results
+	var last *generated.Message
+	var userMessage llm.Message
+	for i := len(messages) - 1; i >= 0 && last == nil; i-- {
+		if messages[i].Type != string(db.MessageTypeUser) {
+			continue
+		}
+		msg, err := convertToLLMMessage(messages[i])
+		if err == nil && isUserText(msg) {
+			last, userMessage = &messages[i], msg
+		}
+	}
+	if last == nil {
+		return nil, slashErrorf(http.StatusConflict, "No message to retry")
+	}
+
+	modelID := call.Model
+	if modelID == "" {
+		modelID = s.defaultModel
+	}
+	llmService, err := s.llmManager.GetService(modelID)
+	if err != nil {
+		return nil, slashErrorf(http.StatusBadRequest, "Unsupported model: %s", modelID)
+	}
+
+	if err := s.db.RollbackConversation(ctx, call.ConversationID, last.SequenceID-1); err != nil {
+		return nil, err
+	}
+	s.resetConversationManager(call.ConversationID)
+
+	manager, err := s.getOrCreateConversationManager(ctx, call.ConversationID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := manager.AcceptUserMessage(ctx, llmService, modelID, userMessage); err != nil {
+		if errors.Is(err, errConversationModelMismatch) {
+			return nil, slashErrorf(http.StatusBadRequest, "%s", err.Error())
+		}
+		return nil, err
+	}
+	go s.broadcastConversationUpdate(context.WithoutCancel(ctx), call.ConversationID)
+
+	return &SlashCommandResponse{Output: "Retrying the last message."}, nil
+}
+
+func runForkCommand(ctx context.Context, s *Server, call *slashCommandCall) (*SlashCommandResponse, error) {
+	messages, err := s.checkAgentIdle(ctx, call.ConversationID)
+	if err != nil {
+		return nil, err
+	}
+	conversation, err := s.db.GetConversationByID(ctx, call.ConversationID)
+	if err != nil {
+		return nil, err
+	}
+	forkID, err := s.forkConversation(ctx, conversation, messages)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SlashCommandResponse{
+		Output:         fmt.Sprintf("Forked into a new conversation (%d messages copied).", len(messages)),
+		ConversationID: forkID,
+	}, nil
+}
+
+// forkConversation creates a conversation with copies of messages and the
+// settings of conversation, and returns its ID.
+func (s *Server) forkConversation(ctx context.Context, conversation *generated.Conversation, messages []generated.Message) (string, error) {
+	fork, err := s.db.CreateConversation(ctx, nil, true, conversation.Cwd, conversation.GitOrigin, conversation.ModelID)
+	if err != nil {
+		return "", err
+	}
+	if err := s.copyMessages(ctx, fork.ConversationID, messages); err != nil {
+		return "", err
+	}
+	if conversation.Slug != nil {
+		// Slugs are unique; keep the fork unnamed if this one is taken
+		if _, err := s.db.UpdateConversationSlug(ctx, fork.ConversationID, *conversation.Slug+"-fork"); err != nil {
+			s.logger.Debug("Failed to name forked conversation", "conversationID", fork.ConversationID, "error", err)
+		}
+	}
+	if err := s.copyConversationWorkspace(ctx, conversation, fork.ConversationID); err != nil {
+		return "", err
+	}
+	if conversation.AllowedTools != nil {
+		if err := s.db.UpdateConversationAllowedTools(ctx, fork.ConversationID, conversation.AllowedTools); err != nil {
+			return "", err
+		}
+	}
+	go s.notifySubscribers(context.WithoutCancel(ctx), fork.ConversationID)
+	return fork.ConversationID, nil
+}
+
+// copyConversationWorkspace gives the conversation conversationID the
+// settings of conversation that decide where its tools run and how its
+// models are asked: its sandbox, remote host, dev container, project,
+// sampling and thinking. Forks and comparison shadows take them, so that their agents
+// work on the same workspace.
+func (s *Server) copyConversationWorkspace(ctx context.Context, conversation *generated.Conversation, conversationID string) error {
+	if conversation.Sandbox != nil {
+		if err := s.db.UpdateConversationSandbox(ctx, conversationID, *conversation.Sandbox); err != nil {
+			return err
+		}
+	}
+	if conversation.Remote != nil {
+		if err := s.db.UpdateConversationRemote(ctx, conversationID, *conversation.Remote); err != nil {
+			return err
+		}
+	}
+	if conversation.Devcontainer != nil {
+		if err := s.db.UpdateConversationDevcontainer(ctx, conversationID, *conversation.Devcontainer); err != nil {
+			return err
+		}
+	}
+	if conversation.ProjectID != nil {
+		if err := s.db.UpdateConversationProject(ctx, conversationID, *conversation.ProjectID); err != nil {
+			return err
+		}
+	}
+	if conversation.Sampling != nil {
+		if err := s.db.UpdateConversationSampling(ctx, conversationID, conversation.Sampling); err != nil {
+			return err
+		}
+	}
+	if conversation.Thinking != nil {
+		if err := s.db.UpdateConversationThinking(ctx, conversationID, conversation.Thinking); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyMessages appends copies of messages to a conversation.
+func (s *Server) copyMessages(ctx context.Context, conversationID string, messages []generated.Message) error {
+	for _, msg := range messages {
+		if _, err := s.db.CreateMessage(ctx, db.CreateMessageParams{
+			ConversationID: conversationID,
+			Type:           db.MessageType(msg.Type),
+			LLMData:        rawJSON((*string)(msg.LlmData)),
+			UserData:       rawJSON(msg.UserData),
+			UsageData:      rawJSON(msg.UsageData),
+			DisplayData:    rawJSON(msg.DisplayData),
+		}); err != nil {
+			return fmt.Errorf("failed to copy message %s: %w", msg.MessageID, err)
+		}
+	}
+	// And the blobs of their tool results
+	from := make(map[string]bool)
+	for _, msg := range messages {
+		from[msg.ConversationID] = true
+	}
+	return s.db.QueriesTx(ctx, func(q *generated.Queries) error {
+		for id := range from {
+			if err := q.CopyToolResultBlobs(ctx, generated.CopyToolResultBlobsParams{ToConversationID: conversationID, FromConversationID: id}); err != nil {
+				return fmt.Errorf("failed to copy tool result blobs: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// rawJSON passes a stored JSON column through CreateMessage unchanged.
+func rawJSON(data *string) any {
+	if data == nil {
+		return nil
+	}
+	return json.RawMessage(*data)
+}
+
+func runCwdCommand(ctx context.Context, s *Server, call *slashCommandCall) (*SlashCommandResponse, error) {
+	conversation, err := s.db.GetConversationByID(ctx, call.ConversationID)
+	if err != nil {
+		return nil, err
+	}
+	current := ""
+	if conversation.Cwd != nil {
+		current = *conversation.Cwd
+	}
+	if call.Args == "" {
+		if current == "" {
+			return &SlashCommandResponse{Output: "No working directory set."}, nil
+		}
+		return &SlashCommandResponse{Output: "Working directory: " + current}, nil
+	}
+
+	// The directory is looked up where the tools run
+	shell := workspaceShell(*conversation)
+	dir := call.Args
+	if strings.HasPrefix(dir, "~") {
+		home, err := os.UserHomeDir()
+		if shell != nil {
+			home, err = shell.Home(ctx)
+		}
+		if err == nil {
+			dir = filepath.Join(home, dir[1:])
+		}
+	}
+	if !filepath.IsAbs(dir) {
+		if current == "" {
+			return nil, slashErrorf(http.StatusBadRequest, "Working directory must be an absolute path")
+		}
+		dir = filepath.Join(current, dir)
+	}
+	dir = filepath.Clean(dir)
+	var isDir bool
+	var origin string
+	if shell != nil {
+		isDir, err = shell.IsDir(ctx, dir)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, slashErrorf(http.StatusBadRequest, "Can't check %s: %v", dir, err)
+		}
+		if isDir {
+			origin = gitstate.GetGitOriginWith(shell.Git, dir)
+		}
+	} else {
+		info, err := os.Stat(dir)
+		isDir = err == nil && info.IsDir()
+		if isDir {
+			origin = gitstate.GetGitOrigin(dir)
+		}
+	}
+	if !isDir {
+		return nil, slashErrorf(http.StatusBadRequest, "Not a directory: %s", dir)
+	}
+
+	if _, err := s.checkAgentIdle(ctx, call.ConversationID); err != nil {
+		return nil, err
+	}
+	if err := s.db.UpdateConversationCwdAndGitOrigin(ctx, call.ConversationID, dir, origin); err != nil {
+		return nil, err
+	}
+	// Tools are built for the working directory the loop started in
+	s.resetConversationManager(call.ConversationID)
+	go s.broadcastConversationUpdate(context.WithoutCancel(ctx), call.ConversationID)
+
+	return &SlashCommandResponse{Output: "Working directory: " + dir}, nil
+}
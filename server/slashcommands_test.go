@@ -0,0 +1,197 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"shelley.exe.dev/db"
+)
+
+// command sends a slash command to the current conversation, retrying while
+// the agent finishes its turn.
+func (h *TestHarness) command(msg string) (*httptest.ResponseRecorder, SlashCommandResponse) {
+	h.t.Helper()
+	body, _ := json.Marshal(ChatRequest{Message: msg, Model: "predictable"})
+	var w *httptest.ResponseRecorder
+	deadline := time.Now().Add(h.timeout)
+	for {
+		req := httptest.NewRequest("POST", "/api/conversation/"+h.convID+"/chat", strings.NewReader(string(body)))
+		w = httptest.NewRecorder()
+		h.server.handleChatConversation(w, req, h.convID)
+		if w.Code != http.StatusConflict || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	var resp SlashCommandResponse
+	if w.Code == http.StatusOK {
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			h.t.Fatalf("failed to parse command response: %v", err)
+		}
+	}
+	return w, resp
+}
+
+func TestParseSlashCommand(t *testing.T) {
+	tests := []struct {
+		message string
+		command string
+		args    string
+	}{
+		{"/help", "help", ""},
+		{"  /model  gpt-5 ", "model", "gpt-5"},
+		{"/cwd /tmp/a b", "cwd", "/tmp/a b"},
+		{"/usr/bin/env is missing", "", ""},
+		{"/unknown", "", ""},
+		{"please /help", "", ""},
+	}
+	for _, tt := range tests {
+		cmd, args := parseSlashCommand(tt.message)
+		name := ""
+		if cmd != nil {
+			name = cmd.Name
+		}
+		if name != tt.command || args != tt.args {
+			t.Errorf("parseSlashCommand(%q) = %q, %q; want %q, %q", tt.message, name, args, tt.command, tt.args)
+		}
+	}
+}
+
+func TestSlashCommands(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	h.NewConversation("echo: first", dir)
+	h.WaitResponse()
+
+	t.Run("help", func(t *testing.T) {
+		w, resp := h.command("/help")
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+		if resp.Status != "command" || resp.Command != "help" {
+			t.Errorf("unexpected response: %+v", resp)
+		}
+		for _, name := range []string{"/compact", "/model [model]", "/retry", "/fork", "/cwd [dir]"} {
+			if !strings.Contains(resp.Output, name) {
+				t.Errorf("help output missing %q:\n%s", name, resp.Output)
+			}
+		}
+		// Commands are not recorded in the conversation
+		for _, msg := range h.messages() {
+			if msg.LlmData != nil && strings.Contains(string(*msg.LlmData), "/help") {
+				t.Errorf("command was recorded as a message: %s", *msg.LlmData)
+			}
+		}
+	})
+
+	t.Run("model", func(t *testing.T) {
+		if w, _ := h.command("/model gpt-nonexistent"); w.Code != http.StatusBadRequest {
+			t.Errorf("expected status 400 for unknown model, got %d", w.Code)
+		}
+		w, resp := h.command("/model predictable")
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+		if resp.Model != "predictable" {
+			t.Errorf("expected model predictable, got %q", resp.Model)
+		}
+		conv, err := h.db.GetConversationByID(context.Background(), h.convID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if conv.ModelID == nil || *conv.ModelID != "predictable" {
+			t.Errorf("expected conversation model to be recorded, got %v", conv.ModelID)
+		}
+	})
+
+	t.Run("cwd", func(t *testing.T) {
+		if w, _ := h.command("/cwd missing"); w.Code != http.StatusBadRequest {
+			t.Errorf("expected status 400 for missing directory, got %d", w.Code)
+		}
+		w, resp := h.command("/cwd sub")
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+		want := filepath.Join(dir, "sub")
+		if !strings.Contains(resp.Output, want) {
+			t.Errorf("expected output to name %s, got %q", want, resp.Output)
+		}
+		conv, err := h.db.GetConversationByID(context.Background(), h.convID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if conv.Cwd == nil || *conv.Cwd != want {
+			t.Errorf("expected cwd %s, got %v", want, conv.Cwd)
+		}
+
+		h.Chat("bash: pwd")
+		if got := strings.TrimSpace(h.WaitToolResult()); got != want {
+			t.Errorf("expected bash to run in %s, got %q", want, got)
+		}
+		h.WaitResponse()
+	})
+
+	t.Run("fork", func(t *testing.T) {
+		w, resp := h.command("/fork")
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+		if resp.ConversationID == "" || resp.ConversationID == h.convID {
+			t.Fatalf("expected a new conversation ID, got %q", resp.ConversationID)
+		}
+		original := h.messages()
+		forked, err := h.db.ListMessagesByConversationPaginated(context.Background(), resp.ConversationID, 1000, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(forked) != len(original) {
+			t.Fatalf("expected %d messages in fork, got %d", len(original), len(forked))
+		}
+		for i := range original {
+			if forked[i].Type != original[i].Type || *forked[i].LlmData != *original[i].LlmData {
+				t.Errorf("message %d differs: %s vs %s", i, *forked[i].LlmData, *original[i].LlmData)
+			}
+		}
+	})
+
+	t.Run("retry", func(t *testing.T) {
+		h.Chat("echo: again")
+		h.WaitResponse()
+		before := len(h.messages())
+
+		w, _ := h.command("/retry")
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+		h.responsesCount--
+		if got := h.WaitResponse(); got != "again" {
+			t.Errorf("expected retried response, got %q", got)
+		}
+
+		messages := h.messages()
+		if len(messages) != before {
+			t.Errorf("expected %d messages after retry, got %d", before, len(messages))
+		}
+		var users int
+		for _, msg := range messages {
+			if msg.Type == string(db.MessageTypeUser) && msg.LlmData != nil && strings.Contains(string(*msg.LlmData), "echo: again") {
+				users++
+			}
+		}
+		if users != 1 {
+			t.Errorf("expected the retried message once, got %d", users)
+		}
+	})
+}
@@ -0,0 +1,160 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"shelley.exe.dev/db"
+	"shelley.exe.dev/db/generated"
+	"shelley.exe.dev/slug"
+)
+
+const (
+	// maxSlugTranscript caps the transcript sent to the LLM when regenerating
+	// a slug. The first user message is always kept; the rest of the budget
+	// goes to the most recent messages.
+	maxSlugTranscript = 12000
+	// maxSlugTranscriptMessage caps each message in the transcript.
+	maxSlugTranscriptMessage = 1000
+)
+
+// RegenerateSlugRequest is the body of POST /api/conversation/<id>/slug/regenerate.
+// Recent, if set, bases the slug on only that many of the latest messages.
+type RegenerateSlugRequest struct {
+	Recent int `json:"recent,omitempty"`
+}
+
+// handleRegenerateSlug handles POST /conversation/<id>/slug/regenerate,
+// retitling the conversation from its message history.
+func (s *Server) handleRegenerateSlug(w http.ResponseWriter, r *http.Request, conversationID string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req RegenerateSlugRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if req.Recent < 0 {
+		http.Error(w, "recent must not be negative", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	var conversation generated.Conversation
+	err := s.db.Queries(ctx, func(q *generated.Queries) error {
+		var err error
+		conversation, err = q.GetConversation(ctx, conversationID)
+		return err
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Conversation not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		s.logger.Error("Failed to get conversation", "conversationID", conversationID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	var messages []generated.Message
+	err = s.db.Queries(ctx, func(q *generated.Queries) error {
+		var err error
+		messages, err = q.ListMessages(ctx, conversationID)
+		return err
+	})
+	if err != nil {
+		s.logger.Error("Failed to list messages", "conversationID", conversationID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	transcript := slugTranscript(messages, req.Recent)
+	if transcript == "" {
+		http.Error(w, "Conversation has no messages to title", http.StatusBadRequest)
+		return
+	}
+
+	modelID := ""
+	if conversation.ModelID != nil {
+		modelID = *conversation.ModelID
+	}
+	if _, err := slug.RegenerateSlug(ctx, s.llmManager, s.db, s.logger, conversationID, transcript, modelID, s.slugConfig(ctx)); err != nil {
+		s.logger.Error("Failed to regenerate slug", "conversationID", conversationID, "error", err)
+		http.Error(w, "Failed to regenerate slug: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	renamed, err := s.db.GetConversationByID(ctx, conversationID)
+	if err != nil {
+		s.logger.Error("Failed to get renamed conversation", "conversationID", conversationID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	go s.broadcastConversationUpdate(context.WithoutCancel(ctx), conversationID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(renamed)
+}
+
+// slugTranscript renders the text of a conversation's user and agent
+// messages for slug generation, limited to the last recent messages if
+// recent is positive. Long transcripts keep the first message and as many
+// of the latest as fit.
+func slugTranscript(messages []generated.Message, recent int) string {
+	var lines []string
+	for _, msg := range messages {
+		var role string
+		switch db.MessageType(msg.Type) {
+		case db.MessageTypeUser:
+			role = "User"
+		case db.MessageTypeAgent:
+			role = "Assistant"
+		default:
+			continue
+		}
+		text := strings.TrimSpace(messageText(msg))
+		if text == "" {
+			continue
+		}
+		if len(text) > maxSlugTranscriptMessage {
+			text = strings.ToValidUTF8(text[:maxSlugTranscriptMessage], "") + "..."
+		}
+		lines = append(lines, role+": "+text)
+	}
+	if recent > 0 && len(lines) > recent {
+		lines = lines[len(lines)-recent:]
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+
+	// Keep the first line, then fill the budget from the end
+	budget := maxSlugTranscript - len(lines[0])
+	start := len(lines)
+	for start > 1 && budget-len(lines[start-1]) >= 0 {
+		start--
+		budget -= len(lines[start])
+	}
+	kept := []string{lines[0]}
+	if start > 1 {
+		kept = append(kept, "[...]")
+	}
+	kept = append(kept, lines[start:]...)
+	return strings.Join(kept, "\n\n")
+}
+
+// slugConfig returns the slug options from the settings.
+func (s *Server) slugConfig(ctx context.Context) slug.Config {
+	settings, err := GetSettings(ctx, s.db)
+	if err != nil {
+		s.logger.Warn("Failed to get settings for slug generation", "error", err)
+		return slug.Config{}
+	}
+	return settings.Slug.config()
+}
@@ -0,0 +1,95 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"shelley.exe.dev/db/compressed"
+	"shelley.exe.dev/db/generated"
+	"shelley.exe.dev/llm"
+)
+
+func textMessage(t *testing.T, typ, text string) generated.Message {
+	t.Helper()
+	role := llm.MessageRoleUser
+	if typ == "agent" {
+		role = llm.MessageRoleAssistant
+	}
+	data, err := json.Marshal(llm.Message{Role: role, Content: []llm.Content{llm.StringContent(text)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	str := compressed.Text(data)
+	return generated.Message{Type: typ, LlmData: &str}
+}
+
+func TestSlugTranscript(t *testing.T) {
+	messages := []generated.Message{
+		textMessage(t, "user", "fix the login bug"),
+		textMessage(t, "agent", "Fixed."),
+		textMessage(t, "system", "system prompt"),
+		textMessage(t, "user", "now add dark mode"),
+		textMessage(t, "agent", "Added dark mode."),
+	}
+
+	got := slugTranscript(messages, 0)
+	want := "User: fix the login bug\n\nAssistant: Fixed.\n\nUser: now add dark mode\n\nAssistant: Added dark mode."
+	if got != want {
+		t.Errorf("slugTranscript() = %q, want %q", got, want)
+	}
+
+	if got := slugTranscript(messages, 2); got != "User: now add dark mode\n\nAssistant: Added dark mode." {
+		t.Errorf("slugTranscript(recent=2) = %q", got)
+	}
+
+	// Long histories keep the first message and the latest ones
+	long := []generated.Message{textMessage(t, "user", "first")}
+	for i := 0; i < 50; i++ {
+		long = append(long, textMessage(t, "agent", strings.Repeat("x", 2000)))
+	}
+	long = append(long, textMessage(t, "user", "last"))
+	got = slugTranscript(long, 0)
+	if len(got) > maxSlugTranscript+100 {
+		t.Errorf("transcript too long: %d", len(got))
+	}
+	if !strings.HasPrefix(got, "User: first\n\n[...]") || !strings.HasSuffix(got, "User: last") {
+		t.Errorf("expected first and last messages around an elision, got %q...%q", got[:30], got[len(got)-30:])
+	}
+}
+
+func TestRegenerateSlug(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+
+	h.NewConversation("echo: first topic", "")
+	h.WaitResponse()
+
+	regenerate := func(id, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/conversation/"+id+"/slug/regenerate", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		h.server.handleRegenerateSlug(w, req, id)
+		return w
+	}
+
+	w := regenerate(h.convID, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var conv generated.Conversation
+	if err := json.Unmarshal(w.Body.Bytes(), &conv); err != nil {
+		t.Fatalf("failed to parse response: %v", err)
+	}
+	if conv.Slug == nil || *conv.Slug == "" {
+		t.Errorf("expected a slug, got %v", conv.Slug)
+	}
+
+	if w := regenerate(h.convID, `{"recent":-1}`); w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for negative recent, got %d", w.Code)
+	}
+	if w := regenerate("cmissing", ""); w.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 for missing conversation, got %d", w.Code)
+	}
+}
@@ -0,0 +1,102 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"time"
+
+	"shelley.exe.dev/db"
+	"shelley.exe.dev/db/generated"
+	"shelley.exe.dev/llm"
+)
+
+// ConversationStats summarizes a conversation, for GET
+// /api/conversations/<id>/stats.
+type ConversationStats struct {
+	// Turns counts the messages the user sent.
+	Turns int `json:"turns"`
+	// ToolCalls counts the tool calls by tool.
+	ToolCalls                map[string]int `json:"tool_calls"`
+	InputTokens              uint64         `json:"input_tokens"`
+	CacheCreationInputTokens uint64         `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     uint64         `json:"cache_read_input_tokens"`
+	OutputTokens             uint64         `json:"output_tokens"`
+	CostUSD                  float64        `json:"cost_usd"`
+	// StartedAt and EndedAt are when the first and last messages were
+	// recorded; DurationSeconds is the wall-clock time between them.
+	StartedAt       *time.Time `json:"started_at,omitempty"`
+	EndedAt         *time.Time `json:"ended_at,omitempty"`
+	DurationSeconds float64    `json:"duration_seconds"`
+	// FilesTouched are the files edited and not reverted, in the order
+	// they were first edited.
+	FilesTouched []string `json:"files_touched"`
+}
+
+// handleConversationStats handles GET /api/conversations/<id>/stats
+func (s *Server) handleConversationStats(w http.ResponseWriter, r *http.Request, conversationID string) {
+	ctx := r.Context()
+	if _, err := s.db.GetConversationByID(ctx, conversationID); err != nil {
+		http.Error(w, "Conversation not found", http.StatusNotFound)
+		return
+	}
+	messages, edits, err := s.loadFileEdits(ctx, conversationID)
+	if err != nil {
+		s.logger.Error("Failed to load conversation", "conversationID", conversationID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(conversationStats(messages, edits))
+}
+
+// conversationStats summarizes a conversation's messages and file edits.
+func conversationStats(messages []generated.Message, edits []generated.FileEdit) ConversationStats {
+	stats := ConversationStats{ToolCalls: map[string]int{}, FilesTouched: []string{}}
+	for _, msg := range messages {
+		if msg.Type == string(db.MessageTypeGitInfo) || isPartial(msg) {
+			continue
+		}
+		if stats.StartedAt == nil {
+			stats.StartedAt = &msg.CreatedAt
+		}
+		stats.EndedAt = &msg.CreatedAt
+
+		if msg.UsageData != nil {
+			var usage llm.Usage
+			if json.Unmarshal([]byte(*msg.UsageData), &usage) == nil {
+				stats.InputTokens += usage.InputTokens
+				stats.CacheCreationInputTokens += usage.CacheCreationInputTokens
+				stats.CacheReadInputTokens += usage.CacheReadInputTokens
+				stats.OutputTokens += usage.OutputTokens
+				stats.CostUSD += usage.CostUSD
+			}
+		}
+		if msg.Type != string(db.MessageTypeUser) && msg.Type != string(db.MessageTypeAgent) {
+			continue
+		}
+		llmMsg, err := convertToLLMMessage(msg)
+		if err != nil {
+			continue
+		}
+		if isUserText(llmMsg) {
+			stats.Turns++
+		}
+		for _, c := range llmMsg.Content {
+			if c.Type == llm.ContentTypeToolUse {
+				stats.ToolCalls[c.ToolName]++
+			}
+		}
+	}
+	if stats.StartedAt != nil {
+		stats.DurationSeconds = stats.EndedAt.Sub(*stats.StartedAt).Seconds()
+	}
+
+	for _, edit := range edits {
+		if edit.RevertedAt == nil && !slices.Contains(stats.FilesTouched, edit.Path) {
+			stats.FilesTouched = append(stats.FilesTouched, edit.Path)
+		}
+	}
+	return stats
+}
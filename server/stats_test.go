@@ -0,0 +1,59 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestConversationStats(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "notes.txt")
+	if err := os.WriteFile(file, []byte("an example\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	h.NewConversation("patch: "+file, dir)
+	h.WaitResponse()
+	h.Chat("bash: echo hi")
+	h.WaitResponse()
+	h.Chat("patch: " + file)
+	h.WaitResponse()
+
+	w := httptest.NewRecorder()
+	h.server.handleConversationStats(w, httptest.NewRequest("GET", "/api/conversations/"+h.convID+"/stats", nil), h.convID)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var stats ConversationStats
+	if err := json.Unmarshal(w.Body.Bytes(), &stats); err != nil {
+		t.Fatal(err)
+	}
+	if stats.Turns != 3 {
+		t.Errorf("expected 3 turns, got %d", stats.Turns)
+	}
+	if stats.ToolCalls["patch"] != 2 || stats.ToolCalls["bash"] != 1 {
+		t.Errorf("unexpected tool calls: %v", stats.ToolCalls)
+	}
+	if stats.InputTokens == 0 || stats.OutputTokens == 0 {
+		t.Errorf("expected token usage, got %+v", stats)
+	}
+	if stats.StartedAt == nil || stats.EndedAt.Before(*stats.StartedAt) || stats.DurationSeconds < 0 {
+		t.Errorf("unexpected duration: %+v", stats)
+	}
+	if !slices.Equal(stats.FilesTouched, []string{file}) {
+		t.Errorf("expected %s to be touched, got %v", file, stats.FilesTouched)
+	}
+
+	w = httptest.NewRecorder()
+	h.server.handleConversationStats(w, httptest.NewRequest("GET", "/api/conversations/nope/stats", nil), "nope")
+	if w.Code != http.StatusNotFound {
+		t.Errorf("unknown conversation: expected 404, got %d", w.Code)
+	}
+}
@@ -0,0 +1,169 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"shelley.exe.dev/db"
+	"shelley.exe.dev/db/compressed"
+	"shelley.exe.dev/db/generated"
+	"shelley.exe.dev/llm"
+)
+
+// Responses are streamed to a partial agent message as they are generated,
+// so that a client loading the conversation mid-response sees the text so
+// far. The response replaces the partial message when it is recorded. A
+// partial message whose response failed keeps the text it got to, and is
+// not sent to the LLM.
+
+// streamingInterval is how often the text of a streaming response is
+// written to its partial message.
+const streamingInterval = 250 * time.Millisecond
+
+// PartialUserData is the user_data of a partial message.
+type PartialUserData struct {
+	Partial bool `json:"partial"`
+}
+
+// isPartial reports whether msg is a partial message.
+func isPartial(msg generated.Message) bool {
+	if msg.UserData == nil || msg.Type != string(db.MessageTypeAgent) {
+		return false
+	}
+	var data PartialUserData
+	return json.Unmarshal([]byte(*msg.UserData), &data) == nil && data.Partial
+}
+
+// partialMessage is the message a response is streaming to.
+type partialMessage struct {
+	message *generated.Message
+	text    string    // text of the response so far
+	written time.Time // when the partial message was last written
+}
+
+// streamingService streams the text of a conversation's responses to a
+// partial message.
+type streamingService struct {
+	service llm.Service
+	cm      *ConversationManager
+}
+
+// Do sends the request, streaming the text of the response.
+func (s *streamingService) Do(ctx context.Context, request *llm.Request) (*llm.Response, error) {
+	resp, err := s.service.Do(llm.WithTextStream(ctx, func(text string) {
+		s.cm.streamText(ctx, text)
+	}), request)
+	if err != nil {
+		// Keep what was generated; the next response starts a new message
+		s.cm.endPartial(ctx)
+	}
+	return resp, err
+}
+
+// TokenContextWindow delegates to the underlying service
+func (s *streamingService) TokenContextWindow() int {
+	return s.service.TokenContextWindow()
+}
+
+// MaxImageDimension delegates to the underlying service
+func (s *streamingService) MaxImageDimension() int {
+	return s.service.MaxImageDimension()
+}
+
+// UseSimplifiedPatch delegates to the underlying service
+func (s *streamingService) UseSimplifiedPatch() bool {
+	return llm.UseSimplifiedPatch(s.service)
+}
+
+// Capabilities delegates to the underlying service
+func (s *streamingService) Capabilities() llm.Capabilities {
+	return llm.ModelCapabilities(s.service)
+}
+
+// streamText writes the text of the streaming response to its partial
+// message, creating it for the first text, and sends it to subscribers.
+// Writes are at most every streamingInterval; the recorded response
+// replaces whatever was written last.
+func (cm *ConversationManager) streamText(ctx context.Context, text string) {
+	cm.partialMu.Lock()
+	defer cm.partialMu.Unlock()
+	if cm.partial == nil {
+		msg, err := cm.db.CreateMessage(ctx, db.CreateMessageParams{
+			ConversationID: cm.conversationID,
+			Type:           db.MessageTypeAgent,
+			LLMData:        partialLLMMessage(text),
+			UserData:       PartialUserData{Partial: true},
+		})
+		if err != nil {
+			cm.logger.Warn("Failed to create partial message", "error", err)
+			return
+		}
+		cm.partial = &partialMessage{message: msg, text: text, written: time.Now()}
+		cm.publishPartial()
+		return
+	}
+	cm.partial.text = text
+	if time.Since(cm.partial.written) >= streamingInterval {
+		cm.writePartial(ctx)
+	}
+}
+
+// writePartial writes the text so far to the partial message and sends it
+// to subscribers. partialMu must be held.
+func (cm *ConversationManager) writePartial(ctx context.Context) {
+	p := cm.partial
+	message := partialLLMMessage(p.text)
+	if err := cm.db.UpdateMessageLLMData(ctx, p.message.MessageID, message); err != nil {
+		cm.logger.Warn("Failed to update partial message", "error", err)
+		return
+	}
+	data, _ := json.Marshal(message)
+	llmData := compressed.Text(data)
+	p.message.LlmData = &llmData
+	p.written = time.Now()
+	cm.publishPartial()
+}
+
+// publishPartial sends the partial message to subscribers. Broadcast leaves
+// their place in the conversation alone, so that they still get the
+// recorded response, which takes the next sequence ID.
+func (cm *ConversationManager) publishPartial() {
+	cm.subpub.Broadcast(StreamResponse{
+		Messages:     toAPIMessages([]generated.Message{*cm.partial.message}),
+		AgentWorking: true,
+	})
+}
+
+// takePartial returns and forgets the partial message of the response
+// being recorded, if it has one.
+func (cm *ConversationManager) takePartial() *generated.Message {
+	cm.partialMu.Lock()
+	defer cm.partialMu.Unlock()
+	if cm.partial == nil {
+		return nil
+	}
+	msg := cm.partial.message
+	cm.partial = nil
+	return msg
+}
+
+// endPartial writes the last text of a response that failed to its partial
+// message, which is kept as it is.
+func (cm *ConversationManager) endPartial(ctx context.Context) {
+	cm.partialMu.Lock()
+	defer cm.partialMu.Unlock()
+	if cm.partial == nil {
+		return
+	}
+	cm.writePartial(context.WithoutCancel(ctx))
+	cm.partial = nil
+}
+
+// partialLLMMessage is the LLM data of a partial message.
+func partialLLMMessage(text string) llm.Message {
+	return llm.Message{
+		Role:    llm.MessageRoleAssistant,
+		Content: []llm.Content{{Type: llm.ContentTypeText, Text: text}},
+	}
+}
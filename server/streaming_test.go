@@ -0,0 +1,66 @@
+package server
+
+import (
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"shelley.exe.dev/db"
+	"shelley.exe.dev/db/compressed"
+	"shelley.exe.dev/db/generated"
+)
+
+func TestStreamingPartialMessage(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+	h.NewConversation("stream: one two three four five six", t.TempDir())
+
+	// While the response streams, the text so far is in a partial message
+	var partialID string
+	for deadline := time.Now().Add(h.timeout); partialID == "" && time.Now().Before(deadline); time.Sleep(20 * time.Millisecond) {
+		for _, msg := range h.messages() {
+			if isPartial(msg) && strings.Contains(string(*msg.LlmData), "one") {
+				partialID = msg.MessageID
+			}
+		}
+	}
+	if partialID == "" {
+		t.Fatal("expected a partial message while the response streams")
+	}
+
+	if text := h.WaitResponse(); text != "one two three four five six" {
+		t.Errorf("expected the whole response, got %q", text)
+	}
+	// The response replaced the partial message, and moved it to the end
+	messages := h.messages()
+	last := messages[len(messages)-1]
+	if last.MessageID != partialID || isPartial(last) {
+		t.Errorf("expected the response to complete the partial message, got %+v", last)
+	}
+	agentMessages := 0
+	for _, msg := range messages {
+		if msg.Type == string(db.MessageTypeAgent) {
+			agentMessages++
+		}
+	}
+	if agentMessages != 1 {
+		t.Errorf("expected one agent message, got %d", agentMessages)
+	}
+}
+
+func TestPartialMessagesLeftOutOfHistory(t *testing.T) {
+	llmData := func(text string) *compressed.Text {
+		s := compressed.Text(`{"Role":1,"Content":[{"Type":2,"Text":"` + text + `"}]}`)
+		return &s
+	}
+	partial := `{"partial":true}`
+	cm := &ConversationManager{logger: slog.Default()}
+	history, _ := cm.partitionMessages([]generated.Message{
+		{Type: string(db.MessageTypeAgent), LlmData: llmData("cut off"), UserData: &partial},
+		{Type: string(db.MessageTypeAgent), LlmData: llmData("complete")},
+	})
+	if len(history) != 1 || history[0].Content[0].Text != "complete" {
+		t.Errorf("expected only the complete message, got %+v", history)
+	}
+}
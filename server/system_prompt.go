@@ -6,8 +6,11 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
 	"strings"
 	"text/template"
+
+	"shelley.exe.dev/projectconfig"
 )
 
 //go:embed system_prompt.txt
@@ -22,6 +25,8 @@ type SystemPromptData struct {
 	IsSudoAvailable  bool
 	Hostname         string // For exe.dev, the public hostname (e.g., "vmname.exe.xyz")
 	ShelleyDBPath    string // Path to the shelley database
+	Memories         []string
+	ProjectConfig    *projectconfig.Config // The working directory's .shelley.yaml, if it has a prompt
 }
 
 // DBPath is the path to the shelley database, set at startup
@@ -39,24 +44,37 @@ type CodebaseInfo struct {
 
 // GenerateSystemPrompt generates the system prompt using the embedded template.
 // If workingDir is empty, it uses the current working directory.
-func GenerateSystemPrompt(workingDir string) (string, error) {
+// memories are saved notes for the working directory's project.
+func GenerateSystemPrompt(workingDir string, memories []string) (string, error) {
+	prompt, _, err := generateSystemPrompt(workingDir, memories)
+	return prompt, err
+}
+
+// generateSystemPrompt is GenerateSystemPrompt, also returning the guidance
+// files whose contents the prompt includes.
+func generateSystemPrompt(workingDir string, memories []string) (string, []string, error) {
 	data, err := collectSystemData(workingDir)
 	if err != nil {
-		return "", fmt.Errorf("failed to collect system data: %w", err)
+		return "", nil, fmt.Errorf("failed to collect system data: %w", err)
 	}
+	data.Memories = memories
 
 	tmpl, err := template.New("system_prompt").Parse(systemPromptTemplate)
 	if err != nil {
-		return "", fmt.Errorf("failed to parse template: %w", err)
+		return "", nil, fmt.Errorf("failed to parse template: %w", err)
 	}
 
 	var buf strings.Builder
 	err = tmpl.Execute(&buf, data)
 	if err != nil {
-		return "", fmt.Errorf("failed to execute template: %w", err)
+		return "", nil, fmt.Errorf("failed to execute template: %w", err)
 	}
 
-	return buf.String(), nil
+	var files []string
+	if data.Codebase != nil {
+		files = data.Codebase.InjectFiles
+	}
+	return buf.String(), files, nil
 }
 
 func collectSystemData(workingDir string) (*SystemPromptData, error) {
@@ -74,7 +92,7 @@ func collectSystemData(workingDir string) (*SystemPromptData, error) {
 	}
 
 	// Try to collect git info
-	gitInfo, err := collectGitInfo()
+	gitInfo, err := collectGitInfo(wd)
 	if err == nil {
 		data.GitInfo = gitInfo
 	}
@@ -85,6 +103,11 @@ func collectSystemData(workingDir string) (*SystemPromptData, error) {
 		data.Codebase = codebaseInfo
 	}
 
+	// Invalid files are refused when the conversation is created
+	if cfg, err := projectconfig.Load(wd); err == nil && cfg != nil && strings.TrimSpace(cfg.Prompt) != "" {
+		data.ProjectConfig = cfg
+	}
+
 	// Check if running on exe.dev
 	data.IsExeDev = isExeDev()
 
@@ -119,9 +142,10 @@ func collectSystemData(workingDir string) (*SystemPromptData, error) {
 	return data, nil
 }
 
-func collectGitInfo() (*GitInfo, error) {
+func collectGitInfo(wd string) (*GitInfo, error) {
 	// Find git root
 	rootCmd := exec.Command("git", "rev-parse", "--show-toplevel")
+	rootCmd.Dir = wd
 	rootOutput, err := rootCmd.Output()
 	if err != nil {
 		return nil, err
@@ -170,30 +194,19 @@ func collectCodebaseInfo(wd string, gitInfo *GitInfo) (*CodebaseInfo, error) {
 		searchRoot = gitInfo.Root
 	}
 
-	// Find root-level guidance files (case-insensitive)
-	rootGuidanceFiles := findGuidanceFilesInDir(searchRoot)
-	for _, file := range rootGuidanceFiles {
-		lowerPath := strings.ToLower(file)
-		if seenFiles[lowerPath] {
-			continue
-		}
-		seenFiles[lowerPath] = true
-
-		content, err := os.ReadFile(file)
-		if err == nil && len(content) > 0 {
-			info.InjectFiles = append(info.InjectFiles, file)
-			info.InjectFileContents[file] = string(content)
-		}
-	}
-
-	// If working directory is different from root, also check working directory
-	if wd != searchRoot {
-		wdGuidanceFiles := findGuidanceFilesInDir(wd)
-		for _, file := range wdGuidanceFiles {
+	// Include the guidance files (case-insensitive) of the root, the working
+	// directory and the directories between them, outermost first
+	dirs := guidanceDirs(searchRoot, wd)
+	for i, dir := range dirs {
+		for _, file := range findGuidanceFilesInDir(dir) {
 			lowerPath := strings.ToLower(file)
 			if seenFiles[lowerPath] {
 				continue
 			}
+			// READMEs only count at the root and in the working directory
+			if strings.EqualFold(filepath.Base(file), "readme.md") && i != 0 && i != len(dirs)-1 {
+				continue
+			}
 			seenFiles[lowerPath] = true
 
 			content, err := os.ReadFile(file)
@@ -211,6 +224,24 @@ func collectCodebaseInfo(wd string, gitInfo *GitInfo) (*CodebaseInfo, error) {
 	return info, nil
 }
 
+// guidanceDirs returns root, wd and the directories between them, from
+// root down. If wd isn't below root, it returns just the two.
+func guidanceDirs(root, wd string) []string {
+	if root == wd {
+		return []string{root}
+	}
+	var dirs []string
+	for dir := wd; dir != root; dir = filepath.Dir(dir) {
+		if dir == filepath.Dir(dir) {
+			return []string{root, wd}
+		}
+		dirs = append(dirs, dir)
+	}
+	dirs = append(dirs, root)
+	slices.Reverse(dirs)
+	return dirs
+}
+
 func findGuidanceFilesInDir(dir string) []string {
 	// Read directory entries to handle case-insensitive file systems
 	entries, err := os.ReadDir(dir)
@@ -220,6 +251,7 @@ func findGuidanceFilesInDir(dir string) []string {
 
 	guidanceNames := map[string]bool{
 		"agent.md":    true,
+		"agents.md":   true,
 		"claude.md":   true,
 		"dear_llm.md": true,
 		"readme.md":   true,
@@ -244,6 +276,7 @@ func findGuidanceFilesInDir(dir string) []string {
 func findAllGuidanceFiles(root string) []string {
 	guidanceNames := map[string]bool{
 		"agent.md":    true,
+		"agents.md":   true,
 		"claude.md":   true,
 		"dear_llm.md": true,
 	}
@@ -0,0 +1,365 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	telegramPlatform = "telegram"
+	// telegramPollTimeout is how long a getUpdates call waits for updates.
+	telegramPollTimeout = 25 * time.Second
+	// maxTelegramMessage is Telegram's limit on the length of a message.
+	maxTelegramMessage = 4096
+	// maxTelegramText caps the agent's text in a reply, leaving room for
+	// the tool summaries.
+	maxTelegramText = 3000
+	// maxTelegramCallbackData is Telegram's limit on a button's data.
+	maxTelegramCallbackData = 64
+
+	// Button data: stop the turn, or answer a question
+	telegramStopData   = "stop"
+	telegramAnswerData = "a:"
+)
+
+var (
+	// telegramAPIURL is the base URL of the Telegram Bot API.
+	telegramAPIURL = "https://api.telegram.org"
+	// telegramHTTPClient allows for long polls.
+	telegramHTTPClient = &http.Client{Timeout: telegramPollTimeout + 30*time.Second}
+)
+
+// TelegramBotSettings configures the Telegram bot, a personal assistant
+// that runs one conversation per chat. It polls Telegram for messages, so
+// it needs no public address.
+type TelegramBotSettings struct {
+	// Token is the token BotFather gave the bot.
+	Token string `json:"token,omitempty" secret:"true"`
+	// Users are the IDs of the Telegram users allowed to use the bot.
+	// Anyone else is told their ID and ignored.
+	Users []int64 `json:"users,omitempty"`
+	BotConversationSettings
+}
+
+func (b *TelegramBotSettings) validate(hasModel func(string) bool) error {
+	if b == nil {
+		return nil
+	}
+	if b.Token == "" || len(b.Users) == 0 {
+		return fmt.Errorf("telegram bot needs a token and at least one user ID")
+	}
+	return b.BotConversationSettings.validate(hasModel)
+}
+
+// telegramUpdate is an update from getUpdates.
+type telegramUpdate struct {
+	UpdateID      int64                  `json:"update_id"`
+	Message       *telegramMessage       `json:"message"`
+	CallbackQuery *telegramCallbackQuery `json:"callback_query"`
+}
+
+type telegramMessage struct {
+	MessageID int64 `json:"message_id"`
+	From      *struct {
+		ID int64 `json:"id"`
+	} `json:"from"`
+	Chat struct {
+		ID int64 `json:"id"`
+	} `json:"chat"`
+	Text string `json:"text"`
+}
+
+// telegramCallbackQuery is a press of an inline button.
+type telegramCallbackQuery struct {
+	ID   string `json:"id"`
+	From struct {
+		ID int64 `json:"id"`
+	} `json:"from"`
+	Message *telegramMessage `json:"message"`
+	Data    string           `json:"data"`
+}
+
+// telegramButton is an inline keyboard button.
+type telegramButton struct {
+	Text         string `json:"text"`
+	CallbackData string `json:"callback_data"`
+}
+
+// runTelegramBot polls Telegram for updates while the bot is configured.
+// It returns when ctx is done.
+func (s *Server) runTelegramBot(ctx context.Context) {
+	var token string
+	var offset int64
+	backoff := time.Second
+	for ctx.Err() == nil {
+		bot := s.telegramSettings(ctx)
+		if bot == nil {
+			sleepContext(ctx, botSettingsPoll)
+			continue
+		}
+		if bot.Token != token {
+			// Update IDs belong to the bot
+			token, offset = bot.Token, 0
+		}
+		api := &telegramPoster{token: bot.Token, status: &s.telegramStatus}
+		updates, err := api.getUpdates(ctx, offset)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			s.logger.Warn("Failed to get Telegram updates", "error", err, "retry", backoff)
+			sleepContext(ctx, backoff)
+			backoff = min(backoff*2, time.Minute)
+			continue
+		}
+		backoff = time.Second
+		for _, update := range updates {
+			offset = update.UpdateID + 1
+			s.handleTelegramUpdate(ctx, bot, api, update)
+		}
+	}
+}
+
+// telegramSettings returns the Telegram bot settings, or nil if the bot
+// isn't configured.
+func (s *Server) telegramSettings(ctx context.Context) *TelegramBotSettings {
+	settings, err := GetSettings(ctx, s.db)
+	if err != nil {
+		s.logger.Warn("Failed to get settings for Telegram", "error", err)
+		return nil
+	}
+	if settings.Bots == nil {
+		return nil
+	}
+	return settings.Bots.Telegram
+}
+
+// handleTelegramUpdate handles a message or a button press from an allowed
+// user. Each chat is a thread whose conversation /new replaces.
+func (s *Server) handleTelegramUpdate(ctx context.Context, bot *TelegramBotSettings, api *telegramPoster, update telegramUpdate) {
+	if q := update.CallbackQuery; q != nil && q.Message != nil {
+		if !slices.Contains(bot.Users, q.From.ID) {
+			return
+		}
+		threadID := strconv.FormatInt(q.Message.Chat.ID, 10)
+		var err error
+		switch {
+		case q.Data == telegramStopData:
+			err = s.bridgeStop(ctx, telegramPlatform, threadID)
+		case strings.HasPrefix(q.Data, telegramAnswerData):
+			err = s.bridgeAnswer(ctx, telegramPlatform, threadID, strings.TrimPrefix(q.Data, telegramAnswerData))
+		}
+		if err != nil {
+			s.logger.Error("Failed to handle Telegram button", "threadID", threadID, "data", q.Data, "error", err)
+		}
+		if err := api.call(ctx, "answerCallbackQuery", map[string]any{"callback_query_id": q.ID}, nil); err != nil {
+			s.logger.Warn("Failed to answer Telegram button", "error", err)
+		}
+		return
+	}
+
+	m := update.Message
+	if m == nil || m.From == nil || strings.TrimSpace(m.Text) == "" {
+		return
+	}
+	threadID := strconv.FormatInt(m.Chat.ID, 10)
+	notice := func(text string) {
+		if _, err := api.send(ctx, threadID, text, nil); err != nil {
+			s.logger.Warn("Failed to post Telegram message", "threadID", threadID, "error", err)
+		}
+	}
+	if !slices.Contains(bot.Users, m.From.ID) {
+		notice(fmt.Sprintf("This bot is private. Your user ID is %d.", m.From.ID))
+		return
+	}
+
+	command, _, _ := strings.Cut(strings.TrimSpace(m.Text), " ")
+	command, _, _ = strings.Cut(command, "@")
+	switch command {
+	case "/start", "/help":
+		notice("Send a message to start a conversation with the agent; later messages continue it. /new starts a new conversation and /stop stops the agent.")
+		return
+	case "/new":
+		if err := s.db.DeleteBridgeThread(ctx, telegramPlatform, threadID); err != nil {
+			s.logger.Error("Failed to unmap Telegram chat", "threadID", threadID, "error", err)
+			notice("Failed to start a new conversation.")
+			return
+		}
+		notice("Your next message starts a new conversation.")
+		return
+	case "/stop":
+		if err := s.bridgeStop(ctx, telegramPlatform, threadID); err != nil {
+			s.logger.Error("Failed to stop Telegram conversation", "threadID", threadID, "error", err)
+		}
+		return
+	}
+
+	if _, err := s.bridgeMessage(ctx, telegramPlatform, threadID, m.Text, bot.BotConversationSettings); err != nil {
+		s.logger.Error("Failed to send Telegram message to the agent", "threadID", threadID, "error", err)
+		notice("Failed to send the message to the agent: " + err.Error())
+	}
+}
+
+// telegramReplyText formats a reply as plain text.
+func telegramReplyText(reply bridgeReply) string {
+	var b strings.Builder
+	if reply.Error {
+		b.WriteString("⚠️ ")
+	}
+	b.WriteString(truncateText(reply.Text, maxTelegramText))
+	writeTelegramTools(&b, reply.Tools)
+	if reply.URL != "" {
+		b.WriteString("\n\n" + reply.URL)
+	}
+	return truncateText(b.String(), maxTelegramMessage-3)
+}
+
+// telegramStatusText formats the status shown while the agent works.
+func telegramStatusText(status bridgeReply) string {
+	var b strings.Builder
+	b.WriteString("Working...")
+	writeTelegramTools(&b, status.Tools)
+	return truncateText(b.String(), maxTelegramMessage-3)
+}
+
+func writeTelegramTools(b *strings.Builder, tools []string) {
+	if len(tools) == 0 {
+		return
+	}
+	b.WriteString("\n\nTools:")
+	for _, tool := range tools {
+		b.WriteString("\n• " + tool)
+	}
+}
+
+// telegramQuestionButtons offers a question's options as buttons, or an
+// approve button if it has none. Options too long for a button's data have
+// to be typed.
+func telegramQuestionButtons(options []string) [][]telegramButton {
+	var rows [][]telegramButton
+	for _, option := range options {
+		if data := telegramAnswerData + option; len(data) <= maxTelegramCallbackData {
+			rows = append(rows, []telegramButton{{Text: option, CallbackData: data}})
+		}
+	}
+	if len(options) == 0 {
+		rows = append(rows, []telegramButton{{Text: "✅ Approve", CallbackData: telegramAnswerData + "yes"}})
+	}
+	return rows
+}
+
+// telegramPoster posts replies through the Telegram Bot API. Thread IDs are
+// chat IDs.
+type telegramPoster struct {
+	token  string
+	status *bridgeStatus
+}
+
+func (p *telegramPoster) postStatus(ctx context.Context, threadID string, status bridgeReply) error {
+	text := telegramStatusText(status)
+	stop := [][]telegramButton{{{Text: "🛑 Stop", CallbackData: telegramStopData}}}
+	return p.status.show(threadID, status.Seq, func() (string, error) {
+		return p.send(ctx, threadID, text, stop)
+	}, func(id string) error {
+		return p.call(ctx, "editMessageText", map[string]any{
+			"chat_id":      telegramID(threadID),
+			"message_id":   telegramID(id),
+			"text":         text,
+			"reply_markup": map[string]any{"inline_keyboard": stop},
+		}, nil)
+	})
+}
+
+func (p *telegramPoster) postReply(ctx context.Context, threadID string, reply bridgeReply) error {
+	return p.status.end(threadID, reply.Seq, func(id string) error {
+		return p.call(ctx, "deleteMessage", map[string]any{"chat_id": telegramID(threadID), "message_id": telegramID(id)}, nil)
+	}, func() error {
+		var buttons [][]telegramButton
+		if reply.Question {
+			buttons = telegramQuestionButtons(reply.Options)
+		}
+		_, err := p.send(ctx, threadID, telegramReplyText(reply), buttons)
+		return err
+	})
+}
+
+// send posts a message to a chat, with buttons if any, returning its ID.
+func (p *telegramPoster) send(ctx context.Context, chatID, text string, buttons [][]telegramButton) (string, error) {
+	params := map[string]any{
+		"chat_id":              telegramID(chatID),
+		"text":                 text,
+		"link_preview_options": map[string]any{"is_disabled": true},
+	}
+	if len(buttons) > 0 {
+		params["reply_markup"] = map[string]any{"inline_keyboard": buttons}
+	}
+	var message telegramMessage
+	if err := p.call(ctx, "sendMessage", params, &message); err != nil {
+		return "", err
+	}
+	return strconv.FormatInt(message.MessageID, 10), nil
+}
+
+// telegramID parses a chat or message ID kept as a string.
+func telegramID(id string) int64 {
+	n, _ := strconv.ParseInt(id, 10, 64)
+	return n
+}
+
+// getUpdates waits for updates after offset.
+func (p *telegramPoster) getUpdates(ctx context.Context, offset int64) ([]telegramUpdate, error) {
+	var updates []telegramUpdate
+	err := p.call(ctx, "getUpdates", map[string]any{
+		"offset":          offset,
+		"timeout":         int(telegramPollTimeout / time.Second),
+		"allowed_updates": []string{"message", "callback_query"},
+	}, &updates)
+	return updates, err
+}
+
+// call calls a Bot API method, decoding its result into result if not nil.
+func (p *telegramPoster) call(ctx context.Context, method string, params, result any) error {
+	body, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, telegramAPIURL+"/bot"+p.token+"/"+method, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := telegramHTTPClient.Do(req)
+	if err != nil {
+		// Leave out the URL, which includes the token
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("telegram %s: %w", method, err)
+	}
+	defer resp.Body.Close()
+	var response struct {
+		OK          bool            `json:"ok"`
+		Description string          `json:"description"`
+		Result      json.RawMessage `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return fmt.Errorf("telegram %s: %s", method, resp.Status)
+	}
+	if !response.OK {
+		return fmt.Errorf("telegram %s: %s", method, response.Description)
+	}
+	if result == nil {
+		return nil
+	}
+	return json.Unmarshal(response.Result, result)
+}
@@ -0,0 +1,182 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// telegramCall is a Bot API call received by a fake Telegram API.
+type telegramCall struct {
+	method string
+	params map[string]any
+}
+
+func (c telegramCall) text() string {
+	text, _ := c.params["text"].(string)
+	return text
+}
+
+func TestTelegramBot(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+
+	updates := make(chan string, 10)
+	calls := make(chan telegramCall, 100)
+	var nextID atomic.Int64
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, ok := strings.CutPrefix(r.URL.Path, "/bottg-token/")
+		if !ok {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		var params map[string]any
+		json.NewDecoder(r.Body).Decode(&params)
+		if method == "getUpdates" {
+			select {
+			case update := <-updates:
+				fmt.Fprintf(w, `{"ok": true, "result": [{"update_id": %d, %s}]}`, nextID.Add(1), update)
+			case <-time.After(20 * time.Millisecond):
+				w.Write([]byte(`{"ok": true, "result": []}`))
+			}
+			return
+		}
+		calls <- telegramCall{method: method, params: params}
+		fmt.Fprintf(w, `{"ok": true, "result": {"message_id": %d}}`, nextID.Add(1))
+	}))
+	defer api.Close()
+	defer func(old string) { telegramAPIURL = old }(telegramAPIURL)
+	telegramAPIURL = api.URL
+
+	settings := `{"bots": {"telegram": {"token": "tg-token", "users": [42], "model": "predictable"}}}`
+	w := httptest.NewRecorder()
+	h.server.handleSettings(w, httptest.NewRequest("POST", "/api/settings", strings.NewReader(settings)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("save settings: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go h.server.runTelegramBot(ctx)
+
+	var seen []telegramCall
+	waitFor := func(match func(telegramCall) bool) telegramCall {
+		t.Helper()
+		for {
+			select {
+			case call := <-calls:
+				seen = append(seen, call)
+				if match(call) {
+					return call
+				}
+			case <-time.After(h.timeout):
+				t.Fatalf("timed out waiting for a Telegram call; got %+v", seen)
+				return telegramCall{}
+			}
+		}
+	}
+	reply := func(call telegramCall) bool {
+		return call.method == "sendMessage" && !strings.HasPrefix(call.text(), "Working...")
+	}
+	message := func(from int, text string) string {
+		return fmt.Sprintf(`"message": {"message_id": 1, "from": {"id": %d}, "chat": {"id": %d}, "text": %q}`, from, from, text)
+	}
+	button := func(data string) string {
+		return fmt.Sprintf(`"callback_query": {"id": "cb", "from": {"id": 42}, "message": {"message_id": 2, "chat": {"id": 42}}, "data": %q}`, data)
+	}
+	conversationID := func() string {
+		t.Helper()
+		thread, err := h.db.GetBridgeThread(context.Background(), telegramPlatform, "42")
+		if err != nil {
+			t.Fatalf("chat not mapped: %v", err)
+		}
+		return thread.ConversationID
+	}
+
+	// Strangers are told their ID
+	updates <- message(7, "echo: hi")
+	if call := waitFor(reply); call.params["chat_id"] != float64(7) || !strings.Contains(call.text(), "Your user ID is 7") {
+		t.Errorf("unexpected reply to a stranger %+v", call)
+	}
+
+	updates <- message(42, "echo: hello")
+	if call := waitFor(reply); call.params["chat_id"] != float64(42) || !strings.HasPrefix(call.text(), "hello") {
+		t.Errorf("unexpected reply %+v", call)
+	}
+	first := conversationID()
+
+	// Progress is shown with a stop button, then replaced by the summary
+	seen = nil
+	updates <- message(42, "bash: echo hi")
+	if call := waitFor(reply); !strings.Contains(call.text(), "bash: echo hi") {
+		t.Errorf("unexpected summary %q", call.text())
+	}
+	var created, deleted int
+	for _, call := range seen {
+		switch {
+		case call.method == "sendMessage" && strings.HasPrefix(call.text(), "Working..."):
+			created++
+			if !strings.Contains(fmt.Sprint(call.params["reply_markup"]), telegramStopData) {
+				t.Errorf("status without a stop button: %+v", call)
+			}
+		case call.method == "deleteMessage":
+			deleted++
+		}
+	}
+	if created != deleted {
+		t.Errorf("%d status messages posted but %d deleted: %+v", created, deleted, seen)
+	}
+
+	// The stop button cancels the turn
+	updates <- message(42, "delay: 30")
+	deadline := time.Now().Add(h.timeout)
+	for busy, _ := h.server.agentBusyNow(context.Background(), first); !busy; busy, _ = h.server.agentBusyNow(context.Background(), first) {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the agent to start")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	updates <- button(telegramStopData)
+	var stopped, answered bool
+	waitFor(func(call telegramCall) bool {
+		stopped = stopped || (reply(call) && strings.Contains(call.text(), "[Operation cancelled]"))
+		answered = answered || call.method == "answerCallbackQuery"
+		return stopped && answered
+	})
+
+	// Questions offer their options as buttons
+	updates <- message(42, "ask: Deploy now? | yes | no")
+	call := waitFor(reply)
+	if !strings.Contains(call.text(), "Deploy now?") || !strings.Contains(fmt.Sprint(call.params["reply_markup"]), "a:no") {
+		t.Errorf("unexpected question %+v", call)
+	}
+	updates <- button("a:no")
+	waitFor(func(call telegramCall) bool { return reply(call) && !strings.HasPrefix(call.text(), "Deploy now?") })
+	messages, err := h.server.bridgeMessages(context.Background(), first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, msg := range messages {
+		if msg.LlmData != nil && strings.Contains(string(*msg.LlmData), `"Text":"no"`) {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("question wasn't answered with the button's option")
+	}
+
+	// /new starts over
+	updates <- message(42, "/new")
+	waitFor(reply)
+	updates <- message(42, "echo: again")
+	waitFor(reply)
+	if conversationID() == first {
+		t.Error("/new didn't start a new conversation")
+	}
+}
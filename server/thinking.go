@@ -0,0 +1,93 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"shelley.exe.dev/db/generated"
+	"shelley.exe.dev/llm"
+)
+
+// conversationThinking returns the reasoning asked of a conversation's
+// models, or nil if it is left to them.
+func conversationThinking(conv generated.Conversation) *llm.Thinking {
+	if conv.Thinking == nil {
+		return nil
+	}
+	var thinking llm.Thinking
+	if err := json.Unmarshal([]byte(*conv.Thinking), &thinking); err != nil {
+		return nil
+	}
+	return &thinking
+}
+
+// handleConversationThinking handles GET and POST
+// /conversation/<id>/thinking, the thinking effort or budget asked of the
+// conversation's models that take one. POST replaces it; null clears it.
+// A message's own thinking takes precedence for the turn it starts.
+func (s *Server) handleConversationThinking(w http.ResponseWriter, r *http.Request, conversationID string) {
+	ctx := r.Context()
+	var conversation generated.Conversation
+	err := s.db.Queries(ctx, func(q *generated.Queries) error {
+		var err error
+		conversation, err = q.GetConversation(ctx, conversationID)
+		return err
+	})
+	if err != nil {
+		http.Error(w, "Conversation not found", http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(conversationThinking(conversation))
+	case http.MethodPost:
+		var thinking *llm.Thinking
+		if err := json.NewDecoder(r.Body).Decode(&thinking); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+		if thinking != nil {
+			if err := thinking.Validate(); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		if err := s.setConversationThinking(ctx, conversationID, thinking); err != nil {
+			s.logger.Error("Failed to update conversation thinking", "conversationID", conversationID, "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(thinking)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// setConversationThinking stores the reasoning asked of a conversation's
+// models and passes it to its manager, if one is active.
+func (s *Server) setConversationThinking(ctx context.Context, conversationID string, thinking *llm.Thinking) error {
+	var data *string
+	if thinking != nil {
+		encoded, err := json.Marshal(thinking)
+		if err != nil {
+			return err
+		}
+		str := string(encoded)
+		data = &str
+	}
+	if err := s.db.UpdateConversationThinking(ctx, conversationID, data); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	manager, ok := s.activeConversations[conversationID]
+	s.mu.Unlock()
+	if ok {
+		manager.SetThinking(thinking)
+	}
+	return nil
+}
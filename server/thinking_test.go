@@ -0,0 +1,67 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"shelley.exe.dev/db"
+	"shelley.exe.dev/llm"
+)
+
+func TestConversationThinking(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+	h.NewConversation("echo: first", t.TempDir())
+	h.WaitResponse()
+
+	mux := http.NewServeMux()
+	h.server.RegisterRoutes(mux)
+	post := func(path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", path, strings.NewReader(body))
+		req.Header.Set("X-Shelley-Request", "1")
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w
+	}
+	lastUsage := func() llm.Usage {
+		var usage llm.Usage
+		for _, msg := range h.messages() {
+			if msg.Type == string(db.MessageTypeAgent) && msg.UsageData != nil {
+				usage = llm.Usage{}
+				json.Unmarshal([]byte(*msg.UsageData), &usage)
+			}
+		}
+		return usage
+	}
+
+	if w := post("/api/conversation/"+h.convID+"/thinking", `{"effort": "extreme"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("expected an unknown effort to be rejected, got %d", w.Code)
+	}
+	if w := post("/api/conversation/"+h.convID+"/thinking", `{"effort": "low"}`); w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	h.Chat("echo: second")
+	h.WaitResponse()
+	if thinking := h.llm.GetLastRequest().Thinking; thinking == nil || thinking.Effort != "low" {
+		t.Fatalf("expected the conversation's thinking, got %+v", thinking)
+	}
+	if usage := lastUsage(); usage.Thinking == nil || usage.Thinking.Effort != "low" {
+		t.Errorf("expected the thinking to be recorded in the usage, got %+v", usage)
+	}
+
+	// A message's own thinking applies to its turn
+	w := post("/api/conversation/"+h.convID+"/chat", `{"message": "echo: third", "model": "predictable", "thinking": {"budget_tokens": 20000}}`)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d: %s", w.Code, w.Body.String())
+	}
+	h.WaitResponse()
+	if thinking := h.llm.GetLastRequest().Thinking; thinking == nil || thinking.BudgetTokens != 20000 {
+		t.Errorf("expected the message's thinking, got %+v", thinking)
+	}
+	if w := post("/api/conversation/"+h.convID+"/chat", `{"message": "echo: fourth", "thinking": {"budget_tokens": 10}}`); w.Code != http.StatusBadRequest {
+		t.Errorf("expected a tiny budget to be rejected, got %d", w.Code)
+	}
+}
@@ -0,0 +1,157 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"shelley.exe.dev/db"
+	"shelley.exe.dev/db/generated"
+	"shelley.exe.dev/llm"
+)
+
+// The timeline of a conversation breaks each turn down into the LLM calls
+// and tool executions it spent its time on. The loop times both: LLM calls
+// in the usage of the agent messages they produce, and tool executions in
+// their results.
+
+// Timeline span kinds.
+const (
+	spanLLM  = "llm"
+	spanTool = "tool"
+)
+
+// TimelineSpan is an LLM call or a tool execution.
+type TimelineSpan struct {
+	// Kind is "llm" or "tool".
+	Kind string `json:"kind"`
+	// Label is the model of an LLM call, or the tool and its main argument.
+	Label     string `json:"label"`
+	ToolUseID string `json:"tool_use_id,omitempty"`
+	// SequenceID is the message that recorded the span.
+	SequenceID int64     `json:"sequence_id"`
+	StartedAt  time.Time `json:"started_at"`
+	EndedAt    time.Time `json:"ended_at"`
+	DurationMs int64     `json:"duration_ms"`
+	Error      bool      `json:"error,omitempty"`
+}
+
+// TimelineTurn is a turn of a conversation and its spans.
+type TimelineTurn struct {
+	// SequenceID is the user message that started the turn.
+	SequenceID int64     `json:"sequence_id"`
+	StartedAt  time.Time `json:"started_at"`
+	EndedAt    time.Time `json:"ended_at"`
+	DurationMs int64     `json:"duration_ms"`
+	// LLMMs and ToolMs total the durations of the turn's spans by kind.
+	LLMMs  int64          `json:"llm_ms"`
+	ToolMs int64          `json:"tool_ms"`
+	Spans  []TimelineSpan `json:"spans"`
+}
+
+// ConversationTimeline is the response to GET
+// /api/conversations/<id>/timeline.
+type ConversationTimeline struct {
+	Turns []TimelineTurn `json:"turns"`
+}
+
+// handleConversationTimeline handles GET /api/conversations/<id>/timeline
+func (s *Server) handleConversationTimeline(w http.ResponseWriter, r *http.Request, conversationID string) {
+	ctx := r.Context()
+	if _, err := s.db.GetConversationByID(ctx, conversationID); err != nil {
+		http.Error(w, "Conversation not found", http.StatusNotFound)
+		return
+	}
+	var messages []generated.Message
+	if err := s.db.Queries(ctx, func(q *generated.Queries) error {
+		var err error
+		messages, err = q.ListMessages(ctx, conversationID)
+		return err
+	}); err != nil {
+		s.logger.Error("Failed to list messages", "conversationID", conversationID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(conversationTimeline(messages))
+}
+
+// conversationTimeline groups the timed LLM calls and tool executions of a
+// conversation's messages by turn.
+func conversationTimeline(messages []generated.Message) ConversationTimeline {
+	timeline := ConversationTimeline{Turns: []TimelineTurn{}}
+	// toolLabels labels the tool uses seen so far by ID
+	toolLabels := map[string]string{}
+	var turn *TimelineTurn
+	extend := func(t time.Time) {
+		if t.After(turn.EndedAt) {
+			turn.EndedAt = t
+		}
+	}
+	addSpan := func(span TimelineSpan) {
+		span.DurationMs = span.EndedAt.Sub(span.StartedAt).Milliseconds()
+		if span.Kind == spanLLM {
+			turn.LLMMs += span.DurationMs
+		} else {
+			turn.ToolMs += span.DurationMs
+		}
+		turn.Spans = append(turn.Spans, span)
+		extend(span.EndedAt)
+	}
+
+	for _, msg := range messages {
+		if (msg.Type != string(db.MessageTypeUser) && msg.Type != string(db.MessageTypeAgent)) || isPartial(msg) {
+			continue
+		}
+		llmMsg, err := convertToLLMMessage(msg)
+		if err != nil {
+			continue
+		}
+		if isUserText(llmMsg) || turn == nil {
+			timeline.Turns = append(timeline.Turns, TimelineTurn{
+				SequenceID: msg.SequenceID,
+				StartedAt:  msg.CreatedAt,
+				EndedAt:    msg.CreatedAt,
+				Spans:      []TimelineSpan{},
+			})
+			turn = &timeline.Turns[len(timeline.Turns)-1]
+		}
+		extend(msg.CreatedAt)
+
+		if msg.Type == string(db.MessageTypeAgent) && msg.UsageData != nil {
+			var usage llm.Usage
+			if json.Unmarshal([]byte(*msg.UsageData), &usage) == nil && usage.StartTime != nil && usage.EndTime != nil {
+				addSpan(TimelineSpan{
+					Kind:       spanLLM,
+					Label:      usage.Model,
+					SequenceID: msg.SequenceID,
+					StartedAt:  *usage.StartTime,
+					EndedAt:    *usage.EndTime,
+				})
+			}
+		}
+		for _, c := range llmMsg.Content {
+			switch {
+			case c.Type == llm.ContentTypeToolUse:
+				toolLabels[c.ID] = toolSummary(c.ToolName, c.ToolInput)
+			case c.Type == llm.ContentTypeToolResult && c.ToolUseStartTime != nil && c.ToolUseEndTime != nil:
+				addSpan(TimelineSpan{
+					Kind:       spanTool,
+					Label:      toolLabels[c.ToolUseID],
+					ToolUseID:  c.ToolUseID,
+					SequenceID: msg.SequenceID,
+					StartedAt:  *c.ToolUseStartTime,
+					EndedAt:    *c.ToolUseEndTime,
+					Error:      c.ToolError,
+				})
+			}
+		}
+	}
+
+	for i := range timeline.Turns {
+		t := &timeline.Turns[i]
+		t.DurationMs = t.EndedAt.Sub(t.StartedAt).Milliseconds()
+	}
+	return timeline
+}
@@ -0,0 +1,61 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestConversationTimeline(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+
+	h.NewConversation("echo: hello", "")
+	h.WaitResponse()
+	h.Chat("bash: sleep 0.3")
+	h.WaitResponse()
+
+	w := httptest.NewRecorder()
+	h.server.handleConversationTimeline(w, httptest.NewRequest("GET", "/api/conversations/"+h.convID+"/timeline", nil), h.convID)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var timeline ConversationTimeline
+	if err := json.Unmarshal(w.Body.Bytes(), &timeline); err != nil {
+		t.Fatal(err)
+	}
+	if len(timeline.Turns) != 2 {
+		t.Fatalf("expected 2 turns, got %+v", timeline.Turns)
+	}
+	first, second := timeline.Turns[0], timeline.Turns[1]
+	if len(first.Spans) != 1 || first.Spans[0].Kind != spanLLM || first.ToolMs != 0 {
+		t.Errorf("expected one LLM call in the first turn, got %+v", first)
+	}
+
+	var tool *TimelineSpan
+	llmCalls := 0
+	for i, span := range second.Spans {
+		switch span.Kind {
+		case spanTool:
+			tool = &second.Spans[i]
+		case spanLLM:
+			llmCalls++
+		}
+	}
+	if tool == nil || llmCalls != 2 {
+		t.Fatalf("expected a tool execution between two LLM calls, got %+v", second.Spans)
+	}
+	if tool.Label != "bash: sleep 0.3" || tool.DurationMs < 250 {
+		t.Errorf("unexpected tool span: %+v", tool)
+	}
+	if second.ToolMs != tool.DurationMs || second.DurationMs < second.ToolMs+second.LLMMs {
+		t.Errorf("unexpected turn totals: %+v", second)
+	}
+
+	w = httptest.NewRecorder()
+	h.server.handleConversationTimeline(w, httptest.NewRequest("GET", "/api/conversations/nope/timeline", nil), "nope")
+	if w.Code != http.StatusNotFound {
+		t.Errorf("unknown conversation: expected 404, got %d", w.Code)
+	}
+}
@@ -0,0 +1,191 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"shelley.exe.dev/db"
+	"shelley.exe.dev/db/generated"
+	"shelley.exe.dev/llm"
+)
+
+// TokenCountRequest is the body of POST /api/tokens/count.
+// ConversationID is empty for a message that would start a new conversation,
+// in which case Cwd is used to estimate the system prompt.
+type TokenCountRequest struct {
+	ConversationID string `json:"conversation_id,omitempty"`
+	Message        string `json:"message"`
+	Model          string `json:"model,omitempty"`
+	Cwd            string `json:"cwd,omitempty"`
+}
+
+// TokenCountResponse estimates the context window usage of sending a message.
+type TokenCountResponse struct {
+	Model                string `json:"model"`
+	ConversationTokens   uint64 `json:"conversation_tokens"`
+	MessageTokens        uint64 `json:"message_tokens"`
+	TotalTokens          uint64 `json:"total_tokens"`
+	ContextWindow        uint64 `json:"context_window"`
+	ExceedsContextWindow bool   `json:"exceeds_context_window"`
+}
+
+// estimateTokens approximates the token count of text at ~4 bytes per token.
+func estimateTokens(text string) uint64 {
+	return uint64(len(text)+3) / 4
+}
+
+// estimateMessageTokens approximates the tokens a message occupies in a request.
+func estimateMessageTokens(msg llm.Message) uint64 {
+	var tokens uint64
+	for _, c := range msg.Content {
+		tokens += estimateTokens(c.Text) + estimateTokens(c.Thinking) + estimateTokens(string(c.ToolInput))
+		for _, r := range c.ToolResult {
+			tokens += estimateTokens(r.Text)
+		}
+	}
+	return tokens
+}
+
+// inHistory reports whether the stored message m is one of the history
+// messages partitionMessages returns, which a summary's kept count counts.
+func inHistory(m generated.Message) bool {
+	switch db.MessageType(m.Type) {
+	case db.MessageTypeGitInfo, db.MessageTypeSystem, db.MessageTypeSummary:
+		return false
+	}
+	return !isPartial(m)
+}
+
+// conversationTokens returns the tokens the conversation history will occupy in
+// the next request: the usage reported by the provider for the last request
+// since the last summary when known, otherwise an estimate from the stored
+// messages since then.
+func (s *Server) conversationTokens(ctx context.Context, conversation *generated.Conversation) (uint64, error) {
+	var messages []generated.Message
+	err := s.db.Queries(ctx, func(q *generated.Queries) error {
+		var err error
+		messages, err = q.ListMessages(ctx, conversation.ConversationID)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	// Compaction replaces the history before the latest summary, but for
+	// the messages it keeps
+	var kept []generated.Message
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Type == string(db.MessageTypeSummary) {
+			start := i
+			for n := summaryKept(messages[i]); n > 0 && start > 0; {
+				start--
+				if inHistory(messages[start]) {
+					n--
+				}
+			}
+			kept, messages = messages[start:i], messages[i:]
+			break
+		}
+	}
+	// The usage reported for the last request since is exact. It is read
+	// from the messages rather than the conversation's context_window_size,
+	// which is updated after the message is recorded.
+	if size := calculateContextWindowSize(toAPIMessages(messages)); size > 0 {
+		return size, nil
+	}
+	var tokens uint64
+	for _, m := range slices.Concat(kept, messages) {
+		switch db.MessageType(m.Type) {
+		case db.MessageTypeGitInfo, db.MessageTypeError:
+			continue
+		}
+		if m.LlmData == nil {
+			continue
+		}
+		var msg llm.Message
+		if err := json.Unmarshal([]byte(*m.LlmData), &msg); err != nil {
+			return 0, fmt.Errorf("failed to parse message %s: %w", m.MessageID, err)
+		}
+		tokens += estimateMessageTokens(msg)
+	}
+	return tokens, nil
+}
+
+// handleCountTokens handles POST /api/tokens/count
+func (s *Server) handleCountTokens(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	ctx := r.Context()
+
+	var req TokenCountRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	var conversation *generated.Conversation
+	if req.ConversationID != "" {
+		var err error
+		conversation, err = s.db.GetConversationByID(ctx, req.ConversationID)
+		if err != nil {
+			http.Error(w, "conversation not found", http.StatusNotFound)
+			return
+		}
+	}
+
+	modelID := req.Model
+	if modelID == "" && conversation != nil && conversation.ModelID != nil {
+		modelID = *conversation.ModelID
+	}
+	if modelID == "" {
+		modelID = s.defaultModel
+	}
+	service, err := s.llmManager.GetService(modelID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Unsupported model: %s", modelID), http.StatusBadRequest)
+		return
+	}
+
+	var convTokens uint64
+	if conversation != nil {
+		convTokens, err = s.conversationTokens(ctx, conversation)
+		if err != nil {
+			s.logger.Error("Failed to count conversation tokens", "conversationID", req.ConversationID, "error", err)
+			http.Error(w, "failed to count tokens", http.StatusInternalServerError)
+			return
+		}
+	} else {
+		memories, err := loadMemories(ctx, s.db, req.Cwd)
+		if err != nil {
+			s.logger.Error("Failed to load memories", "error", err)
+			http.Error(w, "failed to count tokens", http.StatusInternalServerError)
+			return
+		}
+		systemPrompt, err := GenerateSystemPrompt(req.Cwd, memories)
+		if err != nil {
+			s.logger.Error("Failed to generate system prompt", "error", err)
+			http.Error(w, "failed to count tokens", http.StatusInternalServerError)
+			return
+		}
+		convTokens = estimateTokens(systemPrompt)
+	}
+
+	msgTokens := estimateTokens(req.Message)
+	resp := TokenCountResponse{
+		Model:              modelID,
+		ConversationTokens: convTokens,
+		MessageTokens:      msgTokens,
+		TotalTokens:        convTokens + msgTokens,
+		ContextWindow:      uint64(service.TokenContextWindow()),
+	}
+	resp.ExceedsContextWindow = resp.TotalTokens > resp.ContextWindow
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		s.logger.Error("Failed to encode token count", "error", err)
+	}
+}
@@ -0,0 +1,99 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func (h *TestHarness) countTokens(body string) (*httptest.ResponseRecorder, TokenCountResponse) {
+	h.t.Helper()
+	req := httptest.NewRequest("POST", "/api/tokens/count", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	h.server.handleCountTokens(w, req)
+	var resp TokenCountResponse
+	if w.Code == http.StatusOK {
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			h.t.Fatalf("failed to parse response: %v", err)
+		}
+	}
+	return w, resp
+}
+
+func TestCountTokens(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+
+	// New conversation: the system prompt counts against the window
+	w, resp := h.countTokens(`{"message":"` + strings.Repeat("a", 400) + `","cwd":"/tmp"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if resp.Model != "predictable" || resp.MessageTokens != 100 || resp.ConversationTokens == 0 {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+	if resp.TotalTokens != resp.ConversationTokens+resp.MessageTokens || resp.ContextWindow == 0 {
+		t.Errorf("inconsistent totals: %+v", resp)
+	}
+
+	// Existing conversation: uses the usage reported for the last request
+	h.NewConversation("echo: hello there", "")
+	h.WaitResponse()
+	contextSize := h.GetContextWindowSize()
+	w, resp = h.countTokens(`{"conversation_id":"` + h.ConversationID() + `","message":"hi"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if resp.ConversationTokens != contextSize {
+		t.Errorf("expected conversation tokens %d, got %d", contextSize, resp.ConversationTokens)
+	}
+
+	// A message larger than the window is flagged
+	huge := strings.Repeat("x", int(resp.ContextWindow)*4+4)
+	_, resp = h.countTokens(`{"conversation_id":"` + h.ConversationID() + `","message":"` + huge + `"}`)
+	if !resp.ExceedsContextWindow {
+		t.Errorf("expected exceeds_context_window, got %+v", resp)
+	}
+
+	if w, _ := h.countTokens(`{"conversation_id":"missing","message":"hi"}`); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for unknown conversation, got %d", w.Code)
+	}
+}
+
+func TestCountTokensAfterCompaction(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+
+	h.NewConversation("echo: "+strings.Repeat("long history ", 500), "")
+	h.WaitResponse()
+	_, before := h.countTokens(`{"conversation_id":"` + h.ConversationID() + `","message":"hi"}`)
+
+	deadline := time.Now().Add(h.timeout)
+	for {
+		w := h.compact()
+		if w.Code == http.StatusOK {
+			break
+		}
+		if w.Code != http.StatusConflict || time.Now().After(deadline) {
+			t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// The usage reported before the summary no longer applies
+	_, after := h.countTokens(`{"conversation_id":"` + h.ConversationID() + `","message":"hi"}`)
+	if after.ConversationTokens == 0 || after.ConversationTokens >= before.ConversationTokens/2 {
+		t.Errorf("expected the summary estimated, well under the %d tokens before, got %d", before.ConversationTokens, after.ConversationTokens)
+	}
+
+	// Until the next request reports its usage
+	h.Chat("echo: after")
+	h.WaitResponse()
+	contextSize := h.GetContextWindowSize()
+	if _, resp := h.countTokens(`{"conversation_id":"` + h.ConversationID() + `","message":"hi"}`); resp.ConversationTokens != contextSize {
+		t.Errorf("expected conversation tokens %d, got %d", contextSize, resp.ConversationTokens)
+	}
+}
@@ -0,0 +1,171 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"unicode/utf8"
+
+	"shelley.exe.dev/db"
+	"shelley.exe.dev/db/compressed"
+	"shelley.exe.dev/db/generated"
+	"shelley.exe.dev/llm"
+)
+
+// Tool results whose text is larger than the server's blob size are stored
+// apart, in tool_result_blobs, so that listing a conversation's messages
+// doesn't load them: their messages keep a preview, with the blob's hash in
+// the content's Blob. The agent still gets the whole text once it's loaded
+// from the database, and clients get it through
+// GET /api/conversation/<id>/messages/<message>/full.
+
+// DefaultToolResultBlobSize is the size in bytes above which a tool result's
+// text is stored as a blob, unless SetToolResultBlobSize says otherwise.
+const DefaultToolResultBlobSize = 16 << 10
+
+// toolResultPreviewSize is how much of a blob's text its message keeps.
+const toolResultPreviewSize = 2 << 10
+
+// SetToolResultBlobSize sets the size in bytes above which a tool result's
+// text is stored as a blob. Zero keeps tool results in their messages.
+func (s *Server) SetToolResultBlobSize(size int) {
+	s.toolResultBlobSize = size
+}
+
+// storeToolResultBlobs stores the text of the message's tool results larger
+// than the blob size as blobs, and returns a copy of the message with
+// previews in their place.
+func (s *Server) storeToolResultBlobs(ctx context.Context, conversationID string, message llm.Message) (llm.Message, error) {
+	if s.toolResultBlobSize <= 0 {
+		return message, nil
+	}
+	oversized := func(c llm.Content) bool {
+		return c.Type == llm.ContentTypeText && c.Blob == "" && len(c.Text) > s.toolResultBlobSize
+	}
+	var blobs []generated.PutToolResultBlobParams
+	for i, content := range message.Content {
+		if content.Type != llm.ContentTypeToolResult || !slices.ContainsFunc(content.ToolResult, oversized) {
+			continue
+		}
+		// The loop keeps the original, so only change copies
+		if len(blobs) == 0 {
+			message.Content = slices.Clone(message.Content)
+		}
+		content.ToolResult = slices.Clone(content.ToolResult)
+		for j, result := range content.ToolResult {
+			if !oversized(result) {
+				continue
+			}
+			sum := sha256.Sum256([]byte(result.Text))
+			hash := hex.EncodeToString(sum[:])
+			blobs = append(blobs, generated.PutToolResultBlobParams{
+				ConversationID: conversationID,
+				Hash:           hash,
+				Content:        compressed.Text(result.Text),
+				Size:           int64(len(result.Text)),
+			})
+			result.Text = toolResultPreview(result.Text)
+			result.Blob = hash
+			content.ToolResult[j] = result
+		}
+		message.Content[i] = content
+	}
+	if len(blobs) == 0 {
+		return message, nil
+	}
+	err := s.db.QueriesTx(ctx, func(q *generated.Queries) error {
+		for _, blob := range blobs {
+			if err := q.PutToolResultBlob(ctx, blob); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return message, fmt.Errorf("failed to store tool result blobs: %w", err)
+	}
+	return message, nil
+}
+
+// toolResultPreview returns the start of a tool result's text, saying how
+// much is left out.
+func toolResultPreview(text string) string {
+	n := min(toolResultPreviewSize, len(text))
+	for n > 0 && n < len(text) && !utf8.RuneStart(text[n]) {
+		n--
+	}
+	return fmt.Sprintf("%s\n\n[%d more bytes not shown]", text[:n], len(text)-n)
+}
+
+// expandToolResults puts the whole text of the tool results stored as blobs
+// back into messages. A missing blob leaves its preview.
+func expandToolResults(ctx context.Context, database *db.DB, conversationID string, messages []llm.Message) error {
+	return database.Queries(ctx, func(q *generated.Queries) error {
+		for i := range messages {
+			for j := range messages[i].Content {
+				results := messages[i].Content[j].ToolResult
+				for k := range results {
+					if results[k].Blob == "" {
+						continue
+					}
+					blob, err := q.GetToolResultBlob(ctx, generated.GetToolResultBlobParams{
+						ConversationID: conversationID,
+						Hash:           results[k].Blob,
+					})
+					if errors.Is(err, sql.ErrNoRows) {
+						continue
+					}
+					if err != nil {
+						return err
+					}
+					results[k].Text = string(blob.Content)
+					results[k].Blob = ""
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// handleFullMessage handles GET /conversation/<id>/messages/<message>/full,
+// which returns a message with the whole text of its tool results.
+func (s *Server) handleFullMessage(w http.ResponseWriter, r *http.Request, conversationID, messageID string) {
+	ctx := r.Context()
+	msg, err := s.db.GetMessageByID(ctx, messageID)
+	if err != nil || msg.ConversationID != conversationID {
+		http.Error(w, "Message not found", http.StatusNotFound)
+		return
+	}
+
+	if msg.LlmData != nil {
+		var message llm.Message
+		if err := json.Unmarshal([]byte(*msg.LlmData), &message); err != nil {
+			s.logger.Error("Failed to decode message", "messageID", messageID, "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		messages := []llm.Message{message}
+		if err := expandToolResults(ctx, s.db, conversationID, messages); err != nil {
+			s.logger.Error("Failed to load tool result blobs", "messageID", messageID, "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		data, err := json.Marshal(messages[0])
+		if err != nil {
+			s.logger.Error("Failed to encode message", "messageID", messageID, "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		full := compressed.Text(data)
+		msg.LlmData = &full
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(toAPIMessages([]generated.Message{*msg})[0])
+}
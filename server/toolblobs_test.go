@@ -0,0 +1,85 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"shelley.exe.dev/llm"
+)
+
+// toolResultTexts returns the text of the tool results in messages.
+func toolResultTexts(messages []llm.Message) []llm.Content {
+	var texts []llm.Content
+	for _, msg := range messages {
+		for _, content := range msg.Content {
+			for _, result := range content.ToolResult {
+				if result.Type == llm.ContentTypeText {
+					texts = append(texts, result)
+				}
+			}
+		}
+	}
+	return texts
+}
+
+func TestToolResultBlobs(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+	h.server.SetToolResultBlobSize(1000)
+	output := strings.Repeat("x", 5000)
+
+	h.NewConversation("bash: head -c 5000 /dev/zero | tr '\\0' x", t.TempDir())
+	h.WaitToolResult()
+	h.WaitResponse()
+
+	// The message keeps a preview
+	var stored llm.Content
+	var messageID string
+	for _, msg := range h.messages() {
+		var message llm.Message
+		if msg.LlmData == nil || json.Unmarshal([]byte(*msg.LlmData), &message) != nil {
+			continue
+		}
+		if texts := toolResultTexts([]llm.Message{message}); len(texts) > 0 {
+			stored, messageID = texts[0], msg.MessageID
+		}
+	}
+	if stored.Blob == "" || len(stored.Text) >= len(output) || !strings.HasPrefix(stored.Text, "xxx") {
+		t.Fatalf("expected a preview of the tool result, got %d bytes, blob %q", len(stored.Text), stored.Blob)
+	}
+
+	// Which the whole result replaces on request
+	w := httptest.NewRecorder()
+	h.server.handleFullMessage(w, httptest.NewRequest("GET", "/", nil), h.convID, messageID)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var full APIMessage
+	var message llm.Message
+	if err := json.Unmarshal(w.Body.Bytes(), &full); err != nil || json.Unmarshal([]byte(*full.LlmData), &message) != nil {
+		t.Fatalf("failed to decode %s", w.Body.String())
+	}
+	if texts := toolResultTexts([]llm.Message{message}); len(texts) != 1 || strings.TrimSpace(texts[0].Text) != output || texts[0].Blob != "" {
+		t.Errorf("expected the whole tool result, got %+v", texts)
+	}
+	w = httptest.NewRecorder()
+	h.server.handleFullMessage(w, httptest.NewRequest("GET", "/", nil), "other", messageID)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected another conversation's message not found, got %d", w.Code)
+	}
+
+	// And the agent gets it whole once the conversation is reloaded
+	h.server.mu.Lock()
+	h.server.activeConversations[h.convID].stopLoop()
+	delete(h.server.activeConversations, h.convID)
+	h.server.mu.Unlock()
+	h.Chat("echo: again")
+	h.WaitResponse()
+	texts := toolResultTexts(h.llm.GetLastRequest().Messages)
+	if len(texts) != 1 || strings.TrimSpace(texts[0].Text) != output {
+		t.Errorf("expected the agent to get the whole tool result, got %d results", len(texts))
+	}
+}
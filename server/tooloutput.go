@@ -0,0 +1,77 @@
+package server
+
+import (
+	"context"
+	"unicode/utf8"
+
+	"shelley.exe.dev/claudetool"
+	"shelley.exe.dev/llm"
+)
+
+// maxToolOutputReplay is how much of a running tool's output is kept for
+// clients that connect while it runs.
+const maxToolOutputReplay = 64 * 1024
+
+// ToolOutput is output of a running tool, streamed to clients before the
+// tool's result is recorded.
+type ToolOutput struct {
+	ToolUseID string `json:"tool_use_id"`
+	Output    string `json:"output"`
+	// Replace is set if Output is the output so far, possibly cut to its
+	// end, rather than what followed the last update.
+	Replace bool `json:"replace,omitempty"`
+}
+
+// publishToolOutput is the OnToolOutput hook of the manager's tools. It
+// keeps the end of each running tool's output and streams the chunk to
+// subscribers.
+func (cm *ConversationManager) publishToolOutput(ctx context.Context, chunk string) {
+	toolUseID := claudetool.ToolUseID(ctx)
+	if toolUseID == "" {
+		return
+	}
+
+	cm.outputMu.Lock()
+	defer cm.outputMu.Unlock()
+	if cm.toolOutputs == nil {
+		cm.toolOutputs = make(map[string]string)
+	}
+	output := cm.toolOutputs[toolUseID] + chunk
+	if len(output) > maxToolOutputReplay {
+		start := len(output) - maxToolOutputReplay
+		for start < len(output) && !utf8.RuneStart(output[start]) {
+			start++
+		}
+		output = output[start:]
+	}
+	cm.toolOutputs[toolUseID] = output
+	cm.subpub.Broadcast(StreamResponse{
+		ToolOutput:   &ToolOutput{ToolUseID: toolUseID, Output: chunk},
+		AgentWorking: true,
+	})
+}
+
+// endToolOutput drops the output kept for tools whose results are in message.
+func (cm *ConversationManager) endToolOutput(message llm.Message) {
+	cm.outputMu.Lock()
+	defer cm.outputMu.Unlock()
+	for _, content := range message.Content {
+		if content.Type == llm.ContentTypeToolResult {
+			delete(cm.toolOutputs, content.ToolUseID)
+		}
+	}
+}
+
+// subscribe subscribes to the conversation's updates after the message with
+// sequence ID last, and returns the output of the tools running now. Output
+// published later is delivered through the subscription.
+func (cm *ConversationManager) subscribe(ctx context.Context, last int64) (func() (StreamResponse, bool), []ToolOutput) {
+	cm.outputMu.Lock()
+	defer cm.outputMu.Unlock()
+	next := cm.subpub.Subscribe(ctx, last)
+	var running []ToolOutput
+	for toolUseID, output := range cm.toolOutputs {
+		running = append(running, ToolOutput{ToolUseID: toolUseID, Output: output, Replace: true})
+	}
+	return next, running
+}
@@ -0,0 +1,56 @@
+package server
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestToolOutputStreaming(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+
+	h.NewConversation("bash: echo first; sleep 1; echo second", t.TempDir())
+	h.server.mu.Lock()
+	manager := h.server.activeConversations[h.convID]
+	h.server.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), h.timeout)
+	defer cancel()
+	next, running := manager.subscribe(ctx, 1<<62)
+
+	// Output written before subscribing is replayed, the rest is streamed
+	var output strings.Builder
+	for _, o := range running {
+		output.WriteString(o.Output)
+	}
+	for !strings.Contains(output.String(), "second") {
+		resp, ok := next()
+		if !ok {
+			t.Fatalf("stream ended before the command's output; got %q", output.String())
+		}
+		if resp.ToolOutput != nil {
+			if resp.ToolOutput.Replace {
+				output.Reset()
+			}
+			output.WriteString(resp.ToolOutput.Output)
+		}
+	}
+	if got := output.String(); got != "first\nsecond\n" {
+		t.Errorf("expected the command's output, got %q", got)
+	}
+
+	h.WaitToolResult()
+	deadline := time.Now().Add(h.timeout)
+	for {
+		_, running := manager.subscribe(ctx, 1<<62)
+		if len(running) == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the output to be dropped once the result was recorded, got %v", running)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,119 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"shelley.exe.dev/db"
+	"shelley.exe.dev/db/generated"
+	"shelley.exe.dev/llm"
+)
+
+// Messages can be soft-deleted one at a time, or a conversation truncated
+// after any message. Unlike rollback, neither has to leave the conversation
+// at the end of a turn: if what is left ends mid-turn, an end-turn message
+// is recorded so the conversation is idle rather than interrupted. The loop
+// repairs the history it sends, adding results for tool uses whose results
+// were deleted and dropping results whose tool uses were.
+
+// TruncateRequest is the body of POST /api/conversation/<id>/truncate.
+type TruncateRequest struct {
+	// SequenceID is the last message to keep.
+	SequenceID int64 `json:"sequence_id"`
+}
+
+// handleTruncateConversation handles POST /conversation/<id>/truncate
+func (s *Server) handleTruncateConversation(w http.ResponseWriter, r *http.Request, conversationID string) {
+	var req TruncateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	s.removeMessages(w, r, conversationID, req.SequenceID, func(ctx context.Context) error {
+		return s.db.RollbackConversation(ctx, conversationID, req.SequenceID)
+	})
+}
+
+// handleDeleteMessage handles DELETE /conversation/<id>/messages/<sequence_id>
+func (s *Server) handleDeleteMessage(w http.ResponseWriter, r *http.Request, conversationID string) {
+	sequenceID, err := strconv.ParseInt(r.PathValue("seq"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid sequence ID", http.StatusBadRequest)
+		return
+	}
+	s.removeMessages(w, r, conversationID, sequenceID, func(ctx context.Context) error {
+		return s.db.DeleteMessage(ctx, conversationID, sequenceID)
+	})
+}
+
+// removeMessages checks that the agent is idle and that the message with
+// sequenceID may be removed, then runs remove and ends the turn it leaves
+// the conversation in, if any.
+func (s *Server) removeMessages(w http.ResponseWriter, r *http.Request, conversationID string, sequenceID int64, remove func(ctx context.Context) error) {
+	ctx := r.Context()
+	if _, err := s.db.GetConversationByID(ctx, conversationID); err != nil {
+		http.Error(w, "Conversation not found", http.StatusNotFound)
+		return
+	}
+	messages, err := s.checkAgentIdle(ctx, conversationID)
+	if errors.Is(err, errAgentBusy) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		s.logger.Error("Failed to list messages", "conversationID", conversationID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	i := slices.IndexFunc(messages, func(m generated.Message) bool { return m.SequenceID == sequenceID })
+	if i < 0 {
+		http.Error(w, "Message not found", http.StatusBadRequest)
+		return
+	}
+	if messages[i].Type == string(db.MessageTypeSystem) && r.Method == http.MethodDelete {
+		http.Error(w, "The system prompt can't be deleted", http.StatusBadRequest)
+		return
+	}
+
+	if err := remove(ctx); err != nil {
+		s.logger.Error("Failed to remove messages", "conversationID", conversationID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	// The loop's in-memory history still has the removed messages
+	s.resetConversationManager(conversationID)
+	if err := s.endDanglingTurn(ctx, conversationID); err != nil {
+		s.logger.Error("Failed to end turn", "conversationID", conversationID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	go s.broadcastConversationUpdate(context.WithoutCancel(ctx), conversationID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "removed"})
+}
+
+// endDanglingTurn records an end-turn message if the conversation's messages
+// end mid-turn, which would otherwise make it look interrupted.
+func (s *Server) endDanglingTurn(ctx context.Context, conversationID string) error {
+	var messages []generated.Message
+	if err := s.db.Queries(ctx, func(q *generated.Queries) error {
+		var err error
+		messages, err = q.ListMessages(ctx, conversationID)
+		return err
+	}); err != nil {
+		return err
+	}
+	if !agentWorking(toAPIMessages(messages)) {
+		return nil
+	}
+	return s.recordMessage(ctx, conversationID, llm.Message{
+		Role:      llm.MessageRoleAssistant,
+		Content:   []llm.Content{{Type: llm.ContentTypeText, Text: "[Messages removed]"}},
+		EndOfTurn: true,
+	}, llm.Usage{})
+}
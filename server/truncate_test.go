@@ -0,0 +1,115 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"shelley.exe.dev/db"
+	"shelley.exe.dev/db/generated"
+	"shelley.exe.dev/llm"
+)
+
+// removeMessages retries while the loop finishes the turn after its response is recorded.
+func (h *TestHarness) removeMessages(method, path, body string) *httptest.ResponseRecorder {
+	h.t.Helper()
+	mux := http.StripPrefix("/api/conversation", h.server.conversationMux())
+	deadline := time.Now().Add(h.timeout)
+	for {
+		req := httptest.NewRequest(method, "/api/conversation/"+h.convID+path, strings.NewReader(body))
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		if w.Code != http.StatusConflict || time.Now().After(deadline) {
+			return w
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// lastAgentText returns the text of the conversation's last agent message.
+func lastAgentText(t *testing.T, messages []generated.Message) string {
+	t.Helper()
+	last := messages[len(messages)-1]
+	if last.Type != string(db.MessageTypeAgent) {
+		t.Fatalf("expected the last message to be from the agent, got %s", last.Type)
+	}
+	llmMsg, err := convertToLLMMessage(last)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return llmMsg.Content[0].Text
+}
+
+func TestTruncateAndDeleteMessages(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+	h.NewConversation("bash: echo hi", t.TempDir())
+	h.WaitResponse()
+
+	messages := h.messages()
+	var system, toolUse generated.Message
+	for _, msg := range messages {
+		switch {
+		case msg.Type == string(db.MessageTypeSystem):
+			system = msg
+		case msg.Type == string(db.MessageTypeAgent) && toolUse.MessageID == "":
+			toolUse = msg
+		}
+	}
+
+	if w := h.removeMessages("POST", "/truncate", `{"sequence_id": 999}`); w.Code != http.StatusBadRequest {
+		t.Errorf("truncate after unknown message: expected 400, got %d", w.Code)
+	}
+	if w := h.removeMessages("DELETE", fmt.Sprintf("/messages/%d", system.SequenceID), ""); w.Code != http.StatusBadRequest {
+		t.Errorf("delete system prompt: expected 400, got %d", w.Code)
+	}
+
+	// Truncating after the tool use leaves the turn dangling, so it is ended
+	if w := h.removeMessages("POST", "/truncate", fmt.Sprintf(`{"sequence_id": %d}`, toolUse.SequenceID)); w.Code != http.StatusOK {
+		t.Fatalf("truncate: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	messages = h.messages()
+	if agentWorking(toAPIMessages(messages)) || lastAgentText(t, messages) != "[Messages removed]" {
+		t.Errorf("expected the truncated conversation to be idle, got %d messages", len(messages))
+	}
+
+	// The loop answers for the tool use that lost its result
+	h.Chat("echo: after")
+	if text := h.WaitResponse(); text != "after" {
+		t.Errorf("expected a response after truncating, got %q", text)
+	}
+	var toolResult *llm.Content
+	for _, msg := range h.llm.GetLastRequest().Messages {
+		for _, c := range msg.Content {
+			if c.Type == llm.ContentTypeToolResult {
+				toolResult = &c
+			}
+		}
+	}
+	if toolResult == nil || !toolResult.ToolError {
+		t.Errorf("expected an error result for the dangling tool use, got %+v", toolResult)
+	}
+
+	// Deleting the response leaves the question unanswered, so the turn is ended
+	messages = h.messages()
+	last := messages[len(messages)-1]
+	if w := h.removeMessages("DELETE", fmt.Sprintf("/messages/%d", last.SequenceID), ""); w.Code != http.StatusOK {
+		t.Fatalf("delete: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	messages = h.messages()
+	if messages[len(messages)-1].SequenceID == last.SequenceID || agentWorking(toAPIMessages(messages)) {
+		t.Errorf("expected the response to be deleted and the conversation idle")
+	}
+
+	// Deleting a message from the middle leaves the end of the conversation alone
+	count := len(messages)
+	if w := h.removeMessages("DELETE", fmt.Sprintf("/messages/%d", toolUse.SequenceID), ""); w.Code != http.StatusOK {
+		t.Fatalf("delete: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if messages = h.messages(); len(messages) != count-1 {
+		t.Errorf("expected one message fewer, got %d instead of %d", len(messages), count-1)
+	}
+}
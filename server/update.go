@@ -0,0 +1,291 @@
+package server
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"shelley.exe.dev/claudetool"
+	"shelley.exe.dev/update"
+	"shelley.exe.dev/version"
+)
+
+// Shelley updates itself from its releases (see package update) the way the
+// deploy tool's self target deploys it: the downloaded binary is swapped in
+// by a watcher (see claudetool.StartDeployWatcher), which restarts the
+// service, checks that it comes back, and rolls back to the running binary
+// if it doesn't. The record of the latest update is kept for the server that
+// comes up to show.
+
+// updateCheckInterval is how often releases are checked when updates are
+// automatic.
+const updateCheckInterval = 6 * time.Hour
+
+// updateRecordID names the record of the latest update in s.updateRoot.
+const updateRecordID = "latest"
+
+// UpdateBinaryEnv names the variable that holds the path of the downloaded
+// binary, for the install command.
+const UpdateBinaryEnv = "SHELLEY_UPDATE_BINARY"
+
+const (
+	// defaultUpdateInstall installs the update with the running binary's
+	// deploy-daemon, which stops the service, copies the binary and starts
+	// the service again.
+	defaultUpdateInstall  = `"$SHELLEY_PREVIOUS_BINARY" deploy-daemon "$SHELLEY_UPDATE_BINARY"`
+	defaultUpdateRollback = `"$SHELLEY_PREVIOUS_BINARY" deploy-daemon "$SHELLEY_PREVIOUS_BINARY"`
+)
+
+var (
+	errUpdatesOff     = errors.New("updates aren't configured: set a release URL or GitHub repository in the update settings")
+	errUpdateRunning  = errors.New("an update is already running")
+	errUpdateUpToDate = errors.New("the release is the running version")
+)
+
+// UpdateSettings configures updating Shelley from its releases.
+type UpdateSettings struct {
+	// Repo is the GitHub repository, "owner/name", whose latest release is
+	// installed, unless URL is set.
+	Repo string `json:"repo,omitempty"`
+	// URL serves the latest release as JSON in the shape of GitHub's
+	// release API.
+	URL string `json:"url,omitempty"`
+	// PublicKey is the base64 ed25519 key releases' checksums must be
+	// signed with. It's required unless AllowUnsigned is set.
+	PublicKey string `json:"publicKey,omitempty"`
+	// AllowUnsigned, without PublicKey, installs releases verified only
+	// against their checksums, which the release's host can change.
+	AllowUnsigned bool `json:"allowUnsigned,omitempty"`
+	// Auto installs newer releases as they're found.
+	Auto bool `json:"auto,omitempty"`
+	// Install is the shell command that installs the downloaded binary,
+	// $SHELLEY_UPDATE_BINARY, and restarts Shelley, and Rollback the one
+	// that reinstalls the binary it replaced, $SHELLEY_PREVIOUS_BINARY, if
+	// the update doesn't come up. Empty means Shelley's deploy-daemon.
+	Install  string `json:"install,omitempty"`
+	Rollback string `json:"rollback,omitempty"`
+}
+
+// source returns where the releases are found, or nil if updates aren't
+// configured.
+func (u *UpdateSettings) source() *update.Source {
+	if u == nil || (u.URL == "" && u.Repo == "") {
+		return nil
+	}
+	return &update.Source{URL: u.URL, Repo: u.Repo, PublicKey: u.PublicKey, AllowUnsigned: u.AllowUnsigned}
+}
+
+// validate checks the update settings.
+func (u *UpdateSettings) validate() error {
+	if u == nil {
+		return nil
+	}
+	source := u.source()
+	if source == nil {
+		if u.Auto {
+			return errUpdatesOff
+		}
+		return nil
+	}
+	return source.Validate()
+}
+
+// UpdateStatus is the response of GET /api/update.
+type UpdateStatus struct {
+	Current    version.Info `json:"current"`
+	Configured bool         `json:"configured"`
+	Auto       bool         `json:"auto,omitempty"`
+	// Latest is the latest release, unless checking for it failed with
+	// Error.
+	Latest    *update.Release `json:"latest,omitempty"`
+	Error     string          `json:"error,omitempty"`
+	Available bool            `json:"available"`
+	// Last is the record of the latest update installed, if there is one.
+	Last *claudetool.DeployRecord `json:"last,omitempty"`
+}
+
+// runUpdates installs newer releases as they're found, when updates are
+// automatic.
+func (s *Server) runUpdates(ctx context.Context) {
+	ticker := time.NewTicker(updateCheckInterval)
+	defer ticker.Stop()
+	for {
+		s.autoUpdate(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// autoUpdate installs the latest release if updates are automatic and it's
+// newer than the running version. A release that was installed before, or
+// tried and rolled back, isn't installed again.
+func (s *Server) autoUpdate(ctx context.Context) {
+	settings, err := GetSettings(ctx, s.db)
+	if err != nil || settings.Update == nil || !settings.Update.Auto {
+		return
+	}
+	source := settings.Update.source()
+	if source == nil {
+		return
+	}
+	release, err := source.Latest(ctx)
+	if err != nil {
+		s.logger.Warn("Failed to check for updates", "error", err)
+		return
+	}
+	if !release.NewerThan(version.GetInfo()) {
+		return
+	}
+	if last := s.lastUpdate(); last != nil && last.Target.Name == updateTargetName(release) {
+		return
+	}
+	err = s.installUpdate(ctx, settings.Update, source, release, true)
+	if errors.Is(err, errUpdateUpToDate) || errors.Is(err, errUpdateRunning) {
+		return
+	}
+	if err != nil {
+		s.logger.Error("Failed to update", "version", release.Version, "error", err)
+		return
+	}
+	s.logger.Info("Updating", "version", release.Version)
+}
+
+// lastUpdate returns the record of the latest update, if there is one.
+func (s *Server) lastUpdate() *claudetool.DeployRecord {
+	record, err := claudetool.LoadDeployRecord(s.updateRoot, updateRecordID)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			s.logger.Warn("Failed to load update record", "error", err)
+		}
+		return nil
+	}
+	return record
+}
+
+// updateTargetName names the deploy that installs release.
+func updateTargetName(release *update.Release) string {
+	return "Shelley " + release.Version
+}
+
+// installUpdate downloads and verifies release, and starts the watcher that
+// installs it, which restarts the server. If onlyNewer, a release whose
+// binary is of the running version is skipped with errUpdateUpToDate.
+func (s *Server) installUpdate(ctx context.Context, settings *UpdateSettings, source *update.Source, release *update.Release, onlyNewer bool) error {
+	s.updateMu.Lock()
+	defer s.updateMu.Unlock()
+	if last := s.lastUpdate(); last != nil && !last.Done() {
+		return errUpdateRunning
+	}
+	if err := claudetool.RemoveDeployRecord(s.updateRoot, updateRecordID); err != nil {
+		return err
+	}
+	dir := filepath.Join(s.updateRoot, updateRecordID)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("failed to create update directory: %w", err)
+	}
+	binary, err := source.Download(ctx, release, dir)
+	if err != nil {
+		os.RemoveAll(dir)
+		return err
+	}
+	// A binary that doesn't run here would leave the service down until
+	// it's rolled back
+	runCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	out, err := exec.CommandContext(runCtx, binary, "version").Output()
+	if err != nil {
+		os.RemoveAll(dir)
+		return fmt.Errorf("release %s: the downloaded binary doesn't run: %w", release.Version, err)
+	}
+	if onlyNewer {
+		var info version.Info
+		current := version.GetInfo()
+		if json.Unmarshal(out, &info) == nil && info.Commit != "" && info.Commit == current.Commit {
+			os.RemoveAll(dir)
+			return errUpdateUpToDate
+		}
+	}
+
+	target := claudetool.DeployTarget{
+		Name:     updateTargetName(release),
+		Command:  cmp.Or(settings.Install, defaultUpdateInstall),
+		Dir:      dir,
+		Env:      []string{UpdateBinaryEnv + "=" + binary},
+		Detach:   true,
+		Rollback: cmp.Or(settings.Rollback, defaultUpdateRollback),
+	}
+	// The watcher's command and arguments are set when it starts
+	cmd := exec.Command(binary)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), target.Env...)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	return claudetool.StartDeployWatcher(dir, target, s.selfHealthURL, cmd)
+}
+
+// handleUpdate handles /api/update. GET checks for the latest release and
+// returns an UpdateStatus. POST installs the latest release, restarting the
+// server, and returns the status once the install has started.
+func (s *Server) handleUpdate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	ctx := r.Context()
+	settings, err := GetSettings(ctx, s.db)
+	if err != nil {
+		s.logger.Error("Failed to get settings", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	status := UpdateStatus{Current: version.GetInfo()}
+	source := settings.Update.source()
+	if source != nil {
+		status.Configured = true
+		status.Auto = settings.Update.Auto
+		release, err := source.Latest(ctx)
+		if err != nil {
+			status.Error = err.Error()
+		} else {
+			status.Latest = release
+			status.Available = release.NewerThan(status.Current)
+		}
+	}
+
+	if r.Method == http.MethodPost {
+		switch {
+		case source == nil:
+			http.Error(w, errUpdatesOff.Error(), http.StatusBadRequest)
+			return
+		case status.Latest == nil:
+			http.Error(w, "Failed to check for updates: "+status.Error, http.StatusBadGateway)
+			return
+		}
+		err := s.installUpdate(ctx, settings.Update, source, status.Latest, false)
+		if errors.Is(err, errUpdateRunning) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		if err != nil {
+			s.logger.Error("Failed to update", "version", status.Latest.Version, "error", err)
+			http.Error(w, "Failed to update: "+err.Error(), http.StatusBadGateway)
+			return
+		}
+		s.logger.Info("Updating", "version", status.Latest.Version)
+	}
+	status.Last = s.lastUpdate()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
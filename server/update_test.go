@@ -0,0 +1,101 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"shelley.exe.dev/claudetool"
+	"shelley.exe.dev/update"
+)
+
+func TestHandleUpdate(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+	h.server.updateRoot = t.TempDir()
+	mux := http.NewServeMux()
+	h.server.RegisterRoutes(mux)
+	do := func(method string) (*httptest.ResponseRecorder, UpdateStatus) {
+		req := httptest.NewRequest(method, "/api/update", nil)
+		req.Header.Set("X-Shelley-Request", "1")
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		var status UpdateStatus
+		json.Unmarshal(w.Body.Bytes(), &status)
+		return w, status
+	}
+
+	if w, status := do("GET"); w.Code != http.StatusOK || status.Configured {
+		t.Fatalf("expected updates not configured, got %d %+v", w.Code, status)
+	}
+	if w, _ := do("POST"); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected an update without a source refused, got %d: %s", w.Code, w.Body.String())
+	}
+
+	// A release whose binary doesn't match its checksum
+	releases := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/release":
+			json.NewEncoder(w).Encode(map[string]any{
+				"tag_name":     "v2.0.0",
+				"published_at": time.Now().Format(time.RFC3339),
+				"assets": []map[string]string{
+					{"name": update.AssetName(), "browser_download_url": "http://" + r.Host + "/binary"},
+					{"name": update.ChecksumsAsset, "browser_download_url": "http://" + r.Host + "/checksums"},
+				},
+			})
+		case "/binary":
+			w.Write([]byte("tampered"))
+		case "/checksums":
+			w.Write([]byte(strings.Repeat("0", 64) + "  " + update.AssetName() + "\n"))
+		}
+	}))
+	defer releases.Close()
+	if err := SaveSettings(t.Context(), h.db, Settings{Update: &UpdateSettings{URL: releases.URL + "/release", AllowUnsigned: true}}, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	w, status := do("GET")
+	if w.Code != http.StatusOK || !status.Configured || status.Latest == nil || status.Latest.Version != "v2.0.0" || status.Error != "" {
+		t.Fatalf("expected the latest release, got %d %+v", w.Code, status)
+	}
+	if w, _ := do("POST"); w.Code != http.StatusBadGateway || !strings.Contains(w.Body.String(), "checksum") {
+		t.Fatalf("expected a failed checksum, got %d: %s", w.Code, w.Body.String())
+	}
+	if _, status := do("GET"); status.Last != nil {
+		t.Errorf("expected no update recorded, got %+v", status.Last)
+	}
+
+	// One update at a time
+	dir := filepath.Join(h.server.updateRoot, updateRecordID)
+	os.MkdirAll(dir, 0o700)
+	data, _ := json.Marshal(claudetool.DeployRecord{
+		Target:  claudetool.DeployTarget{Name: "Shelley v1.9.0"},
+		Watcher: os.Getpid(),
+		Started: time.Now(),
+		Status:  claudetool.DeployRunning,
+	})
+	os.WriteFile(filepath.Join(dir, "record.json"), data, 0o600)
+	if w, _ := do("POST"); w.Code != http.StatusConflict {
+		t.Fatalf("expected a running update to refuse another, got %d: %s", w.Code, w.Body.String())
+	}
+	if _, status := do("GET"); status.Last == nil || status.Last.Target.Name != "Shelley v1.9.0" {
+		t.Errorf("expected the running update shown, got %+v", status.Last)
+	}
+}
+
+func TestUpdateSettingsValidate(t *testing.T) {
+	for _, settings := range []UpdateSettings{{Auto: true}, {Repo: "shelley", AllowUnsigned: true}, {URL: "example.com/release", AllowUnsigned: true}, {Repo: "boldsoftware/shelley", Auto: true}} {
+		if err := settings.validate(); err == nil {
+			t.Errorf("expected %+v to be invalid", settings)
+		}
+	}
+	if err := (&UpdateSettings{Repo: "boldsoftware/shelley", Auto: true, AllowUnsigned: true}).validate(); err != nil {
+		t.Error(err)
+	}
+}
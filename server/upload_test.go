@@ -2,6 +2,8 @@ package server
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"io"
 	"log/slog"
@@ -262,3 +264,77 @@ func TestUploadPreservesFileExtension(t *testing.T) {
 		})
 	}
 }
+
+func TestUploadDeduplication(t *testing.T) {
+	database, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	llmManager := &testLLMManager{service: loop.NewPredictableService()}
+	server := NewServer(database, llmManager, claudetool.ToolSetConfig{}, slog.Default(), true, "", "predictable", "", nil)
+	server.uploadDir = t.TempDir()
+
+	upload := func(name, content string) string {
+		t.Helper()
+		body := &bytes.Buffer{}
+		writer := multipart.NewWriter(body)
+		part, err := writer.CreateFormFile("file", name)
+		if err != nil {
+			t.Fatalf("failed to create form file: %v", err)
+		}
+		part.Write([]byte(content))
+		writer.Close()
+
+		req := httptest.NewRequest("POST", "/api/upload", body)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		w := httptest.NewRecorder()
+		server.handleUpload(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+		var response map[string]string
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatalf("failed to parse response: %v", err)
+		}
+		return response["path"]
+	}
+	lookup := func(hash, name string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/api/upload/"+hash+"?name="+name, nil)
+		req.SetPathValue("hash", hash)
+		w := httptest.NewRecorder()
+		server.handleUploadLookup(w, req)
+		return w
+	}
+
+	first := upload("a.png", "same screenshot")
+	if again := upload("b.png", "same screenshot"); again != first {
+		t.Errorf("expected the same content stored once, got %s and %s", first, again)
+	}
+	if other := upload("c.png", "another screenshot"); other == first {
+		t.Error("expected other content stored apart")
+	}
+	entries, err := os.ReadDir(server.uploadDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("expected 2 files stored, got %d", len(entries))
+	}
+
+	// Clients find it by its hash without sending it again
+	sum := sha256.Sum256([]byte("same screenshot"))
+	hash := hex.EncodeToString(sum[:])
+	w := lookup(hash, "d.png")
+	var response map[string]string
+	if w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &response) != nil || response["path"] != first {
+		t.Errorf("expected %s found, got %d: %s", first, w.Code, w.Body.String())
+	}
+	if w := lookup(hash, "d.jpg"); w.Code != http.StatusNotFound {
+		t.Errorf("expected another extension not found, got %d", w.Code)
+	}
+	if w := lookup(hash[:16]+strings.Repeat("0", 48), "d.png"); w.Code != http.StatusNotFound {
+		t.Errorf("expected a hash sharing only its prefix not found, got %d", w.Code)
+	}
+	if w := lookup("../etc", "d.png"); w.Code != http.StatusBadRequest {
+		t.Errorf("expected an invalid hash refused, got %d", w.Code)
+	}
+}
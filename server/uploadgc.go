@@ -0,0 +1,122 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"shelley.exe.dev/db"
+)
+
+// Uploaded files are kept while a message mentions them, as counted in
+// upload_refs. Deleting a conversation deletes its messages, and so its
+// uploads' references. Uploads too recent to have been sent yet are kept
+// regardless.
+
+// uploadGCInterval is how often orphaned uploads are removed.
+const uploadGCInterval = 6 * time.Hour
+
+// uploadGCGrace is how long an upload is kept before a message mentions it.
+const uploadGCGrace = 24 * time.Hour
+
+// OrphanedUpload is an uploaded file no message mentions.
+type OrphanedUpload struct {
+	Path       string    `json:"path"`
+	Size       int64     `json:"size"`
+	ModifiedAt time.Time `json:"modified_at"`
+}
+
+// UploadGCReport describes the orphaned uploads found, and removed unless
+// it's a dry run.
+type UploadGCReport struct {
+	Orphaned      []OrphanedUpload `json:"orphaned"`
+	OrphanedBytes int64            `json:"orphaned_bytes"`
+	// Kept counts the uploads mentioned by a message or too recent to remove.
+	Kept   int  `json:"kept"`
+	DryRun bool `json:"dry_run"`
+}
+
+// runUploadGC removes orphaned uploads periodically until ctx is done.
+func (s *Server) runUploadGC(ctx context.Context) {
+	ticker := time.NewTicker(uploadGCInterval)
+	defer ticker.Stop()
+	for {
+		report, err := s.collectUploads(ctx, false)
+		if err != nil && ctx.Err() == nil {
+			s.logger.Warn("Failed to remove orphaned uploads", "error", err)
+		} else if len(report.Orphaned) > 0 {
+			s.logger.Info("Removed orphaned uploads", "files", len(report.Orphaned), "bytes", report.OrphanedBytes)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// collectUploads finds the uploads no message mentions that are older than
+// the grace period, and removes them unless dryRun is set.
+func (s *Server) collectUploads(ctx context.Context, dryRun bool) (UploadGCReport, error) {
+	report := UploadGCReport{Orphaned: []OrphanedUpload{}, DryRun: dryRun}
+	entries, err := os.ReadDir(s.uploadDir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return report, nil
+	}
+	if err != nil {
+		return report, err
+	}
+	// References are recorded as messages are written, but for those
+	// stored before they were
+	if _, err := s.db.IndexUploadRefs(ctx); err != nil {
+		return report, err
+	}
+	refs, err := s.db.UploadRefCounts(ctx)
+	if err != nil {
+		return report, err
+	}
+
+	for _, entry := range entries {
+		id := db.UploadIDPattern.FindString(entry.Name())
+		if !entry.Type().IsRegular() || id == "" || !strings.HasPrefix(entry.Name(), id) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		if refs[id] > 0 || time.Since(info.ModTime()) < uploadGCGrace {
+			report.Kept++
+			continue
+		}
+		path := filepath.Join(s.uploadDir, entry.Name())
+		if !dryRun {
+			if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				s.logger.Warn("Failed to remove orphaned upload", "path", path, "error", err)
+				continue
+			}
+		}
+		report.Orphaned = append(report.Orphaned, OrphanedUpload{Path: path, Size: info.Size(), ModifiedAt: info.ModTime()})
+		report.OrphanedBytes += info.Size()
+	}
+	return report, nil
+}
+
+// handleOrphanedUploads handles GET /api/admin/uploads/orphaned: the uploads
+// the periodic collection would remove, without removing them.
+func (s *Server) handleOrphanedUploads(w http.ResponseWriter, r *http.Request) {
+	report, err := s.collectUploads(r.Context(), true)
+	if err != nil {
+		s.logger.Error("Failed to find orphaned uploads", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,73 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestUploadGC(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+	h.server.uploadDir = t.TempDir()
+
+	old := time.Now().Add(-2 * uploadGCGrace)
+	upload := func(name string, modified time.Time) string {
+		path := filepath.Join(h.server.uploadDir, name)
+		if err := os.WriteFile(path, []byte("data"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, modified, modified); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	mentioned := upload("upload_0123456789abcdef.png", old)
+	orphaned := upload("upload_fedcba9876543210.png", old)
+	recent := upload("upload_1111111111111111.txt", time.Now())
+	screenshot := upload("screenshot.png", old)
+
+	h.NewConversation("echo: see "+mentioned, "")
+	h.WaitResponse()
+
+	// The dry run reports the orphan without removing it
+	w := httptest.NewRecorder()
+	h.server.handleOrphanedUploads(w, httptest.NewRequest("GET", "/api/admin/uploads/orphaned", nil))
+	var report UploadGCReport
+	if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+		t.Fatalf("failed to decode %s", w.Body.String())
+	}
+	if len(report.Orphaned) != 1 || report.Orphaned[0].Path != orphaned || report.Kept != 2 || !report.DryRun {
+		t.Fatalf("expected only %s orphaned, got %+v", orphaned, report)
+	}
+	if _, err := os.Stat(orphaned); err != nil {
+		t.Errorf("expected the dry run to keep the orphan: %v", err)
+	}
+
+	// Which the collection removes
+	if _, err := h.server.collectUploads(t.Context(), false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(orphaned); !os.IsNotExist(err) {
+		t.Errorf("expected the orphan removed, got %v", err)
+	}
+	for _, path := range []string{mentioned, recent, screenshot} {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("expected %s kept: %v", path, err)
+		}
+	}
+
+	// Until its conversation is deleted
+	if err := h.db.DeleteConversation(t.Context(), h.convID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.server.collectUploads(t.Context(), false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(mentioned); !os.IsNotExist(err) {
+		t.Errorf("expected the deleted conversation's upload removed, got %v", err)
+	}
+}
@@ -0,0 +1,92 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+)
+
+// Uploads are named by the SHA-256 of their content, so the same file
+// uploaded again, in any conversation, is stored once. Each message that
+// mentions an upload is a reference to it, recorded in upload_refs when the
+// message is written and deleted with it, and runUploadGC removes the
+// upload once none is left.
+
+// uploadHashPattern matches the SHA-256 clients send to find an upload.
+var uploadHashPattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// uploadPath returns where the upload with the content hash and extension is
+// stored. The whole hash names it, so no other content can be found by it.
+func (s *Server) uploadPath(hash, ext string) string {
+	return filepath.Join(s.uploadDir, fmt.Sprintf("upload_%s%s", hash, ext))
+}
+
+// saveUpload stores the content of src as an upload, unless an upload with
+// the same content and extension is stored already, and returns its path.
+func (s *Server) saveUpload(src io.Reader, ext string) (string, error) {
+	if err := os.MkdirAll(s.uploadDir, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create directory: %w", err)
+	}
+	// Written apart until its hash is known, under a name collectUploads skips
+	tmp, err := os.CreateTemp(s.uploadDir, ".receiving-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create destination file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	hash := sha256.New()
+	_, err = io.Copy(io.MultiWriter(tmp, hash), src)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to save file: %w", err)
+	}
+
+	path := s.uploadPath(hex.EncodeToString(hash.Sum(nil)), ext)
+	if s.touchUpload(path) {
+		return path, nil
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return "", fmt.Errorf("failed to save file: %w", err)
+	}
+	return path, nil
+}
+
+// touchUpload reports whether the upload at path is stored, restarting its
+// grace period if so: it's about to be mentioned again, but may not be yet
+// when collectUploads next runs.
+func (s *Server) touchUpload(path string) bool {
+	now := time.Now()
+	err := os.Chtimes(path, now, now)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		s.logger.Warn("Failed to touch upload", "path", path, "error", err)
+	}
+	return err == nil
+}
+
+// handleUploadLookup handles GET /api/upload/{hash}?name=<file name>, which
+// returns the path of the upload with the content's SHA-256 and the name's
+// extension if it's stored, so that clients needn't send it again.
+func (s *Server) handleUploadLookup(w http.ResponseWriter, r *http.Request) {
+	hash := r.PathValue("hash")
+	if !uploadHashPattern.MatchString(hash) {
+		http.Error(w, "invalid hash", http.StatusBadRequest)
+		return
+	}
+	path := s.uploadPath(hash, filepath.Ext(r.URL.Query().Get("name")))
+	if !s.touchUpload(path) {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"path": path})
+}
@@ -0,0 +1,147 @@
+package server
+
+import (
+	"cmp"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"time"
+
+	"shelley.exe.dev/db/generated"
+)
+
+// UsageTotals sums the llm.Usage reported by LLM responses.
+type UsageTotals struct {
+	Requests                 int64   `json:"requests"`
+	InputTokens              int64   `json:"input_tokens"`
+	CacheCreationInputTokens int64   `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int64   `json:"cache_read_input_tokens"`
+	OutputTokens             int64   `json:"output_tokens"`
+	CostUSD                  float64 `json:"cost_usd"`
+}
+
+func (t *UsageTotals) add(row generated.ListUsageRow) {
+	t.Requests += row.Requests
+	t.InputTokens += row.InputTokens
+	t.CacheCreationInputTokens += row.CacheCreationInputTokens
+	t.CacheReadInputTokens += row.CacheReadInputTokens
+	t.OutputTokens += row.OutputTokens
+	t.CostUSD += row.CostUsd
+}
+
+// UsageBucket is the usage for one day, model, user or conversation.
+type UsageBucket struct {
+	Key   string `json:"key"`
+	Label string `json:"label,omitempty"` // conversation slug
+	UsageTotals
+}
+
+// UsageSummary is the response of GET /api/usage/summary.
+// Days are UTC dates; From and To are inclusive and empty when unbounded.
+type UsageSummary struct {
+	From           string        `json:"from,omitempty"`
+	To             string        `json:"to,omitempty"`
+	Total          UsageTotals   `json:"total"`
+	ByDay          []UsageBucket `json:"by_day"`
+	ByModel        []UsageBucket `json:"by_model"`
+	ByUser         []UsageBucket `json:"by_user"`
+	ByConversation []UsageBucket `json:"by_conversation"`
+}
+
+// usageBuckets accumulates rows into buckets, preserving first-seen order.
+type usageBuckets struct {
+	index   map[string]int
+	buckets []UsageBucket
+}
+
+func (b *usageBuckets) add(key, label string, row generated.ListUsageRow) {
+	if b.index == nil {
+		b.index = make(map[string]int)
+	}
+	i, ok := b.index[key]
+	if !ok {
+		i = len(b.buckets)
+		b.index[key] = i
+		b.buckets = append(b.buckets, UsageBucket{Key: key, Label: label})
+	}
+	b.buckets[i].add(row)
+}
+
+// byCost returns the buckets with the most expensive first.
+func (b *usageBuckets) byCost() []UsageBucket {
+	out := slices.Clone(b.buckets)
+	slices.SortStableFunc(out, func(x, y UsageBucket) int {
+		return cmp.Or(cmp.Compare(y.CostUSD, x.CostUSD), cmp.Compare(y.InputTokens+y.OutputTokens, x.InputTokens+x.OutputTokens))
+	})
+	if out == nil {
+		out = []UsageBucket{}
+	}
+	return out
+}
+
+// usageDay normalizes a day key; the driver may return SQLite date() values
+// as RFC 3339 timestamps.
+func usageDay(day string) string {
+	if t, err := time.Parse(time.RFC3339, day); err == nil {
+		return t.Format(time.DateOnly)
+	}
+	return day
+}
+
+// handleUsageSummary handles GET /api/usage/summary?from=YYYY-MM-DD&to=YYYY-MM-DD
+func (s *Server) handleUsageSummary(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	summary := UsageSummary{From: r.URL.Query().Get("from"), To: r.URL.Query().Get("to")}
+	for _, day := range []string{summary.From, summary.To} {
+		if day == "" {
+			continue
+		}
+		if _, err := time.Parse(time.DateOnly, day); err != nil {
+			http.Error(w, "from and to must be dates in YYYY-MM-DD format", http.StatusBadRequest)
+			return
+		}
+	}
+	params := generated.ListUsageParams{FromDay: cmp.Or(summary.From, "0000-01-01"), ToDay: cmp.Or(summary.To, "9999-12-31")}
+
+	var rows []generated.ListUsageRow
+	err := s.db.Queries(r.Context(), func(q *generated.Queries) error {
+		var err error
+		rows, err = q.ListUsage(r.Context(), params)
+		return err
+	})
+	if err != nil {
+		s.logger.Error("Failed to list usage", "error", err)
+		http.Error(w, "failed to list usage", http.StatusInternalServerError)
+		return
+	}
+
+	var days, models, users, conversations usageBuckets
+	for _, row := range rows {
+		summary.Total.add(row)
+		days.add(usageDay(row.Day), "", row)
+		models.add(row.Model, "", row)
+		users.add(row.UserID, "", row)
+		label := ""
+		if row.Slug != nil {
+			label = *row.Slug
+		}
+		conversations.add(row.ConversationID, label, row)
+	}
+	// Rows are ordered by day, so days are already chronological
+	summary.ByDay = days.buckets
+	if summary.ByDay == nil {
+		summary.ByDay = []UsageBucket{}
+	}
+	summary.ByModel = models.byCost()
+	summary.ByUser = users.byCost()
+	summary.ByConversation = conversations.byCost()
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(summary); err != nil {
+		s.logger.Error("Failed to encode usage summary", "error", err)
+	}
+}
@@ -0,0 +1,108 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func (h *TestHarness) usageSummary(query string) (*httptest.ResponseRecorder, UsageSummary) {
+	h.t.Helper()
+	req := httptest.NewRequest("GET", "/api/usage/summary"+query, nil)
+	w := httptest.NewRecorder()
+	h.server.handleUsageSummary(w, req)
+	var summary UsageSummary
+	if w.Code == http.StatusOK {
+		if err := json.Unmarshal(w.Body.Bytes(), &summary); err != nil {
+			h.t.Fatalf("failed to parse summary: %v", err)
+		}
+	}
+	return w, summary
+}
+
+func TestUsageSummary(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+
+	h.NewConversation("echo: first", "")
+	h.WaitResponse()
+	first := h.ConversationID()
+	h.NewConversation("echo: second", "")
+	h.WaitResponse()
+	h.Chat("echo: again")
+	h.WaitResponse()
+	second := h.ConversationID()
+	if err := h.db.UpdateConversationUserID(context.Background(), second, "alice"); err != nil {
+		t.Fatalf("failed to set user: %v", err)
+	}
+
+	w, summary := h.usageSummary("")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if summary.Total.Requests != 3 || summary.Total.OutputTokens == 0 {
+		t.Errorf("unexpected totals: %+v", summary.Total)
+	}
+	today := time.Now().UTC().Format(time.DateOnly)
+	if len(summary.ByDay) != 1 || summary.ByDay[0].Key != today {
+		t.Errorf("expected one day %s, got %+v", today, summary.ByDay)
+	}
+	if len(summary.ByModel) != 1 || summary.ByModel[0].Requests != 3 {
+		t.Errorf("unexpected by_model: %+v", summary.ByModel)
+	}
+	if len(summary.ByConversation) != 2 || summary.ByConversation[0].Key != second || summary.ByConversation[1].Key != first {
+		t.Errorf("expected conversations ordered by cost, got %+v", summary.ByConversation)
+	}
+	users := map[string]int64{}
+	for _, b := range summary.ByUser {
+		users[b.Key] = b.Requests
+	}
+	if users["alice"] != 2 || users[""] != 1 {
+		t.Errorf("unexpected by_user: %+v", summary.ByUser)
+	}
+
+	tomorrow := time.Now().UTC().AddDate(0, 0, 1).Format(time.DateOnly)
+	_, summary = h.usageSummary("?from=" + tomorrow)
+	if summary.Total.Requests != 0 || len(summary.ByDay) != 0 {
+		t.Errorf("expected no usage from %s, got %+v", tomorrow, summary)
+	}
+	_, summary = h.usageSummary("?from=" + today + "&to=" + today)
+	if summary.Total.Requests != 3 {
+		t.Errorf("expected 3 requests today, got %d", summary.Total.Requests)
+	}
+
+	if w, _ := h.usageSummary("?from=yesterday"); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for bad date, got %d", w.Code)
+	}
+}
+
+func TestNewConversationRecordsUser(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+	h.server.requireHeader = "X-Exedev-Userid"
+
+	req := httptest.NewRequest("POST", "/api/conversations/new", strings.NewReader(`{"message":"echo: hi","model":"predictable"}`))
+	req.Header.Set("X-Exedev-Userid", "bob")
+	w := httptest.NewRecorder()
+	h.server.handleNewConversation(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		ConversationID string `json:"conversation_id"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	conv, err := h.db.GetConversationByID(context.Background(), resp.ConversationID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conv.UserID == nil || *conv.UserID != "bob" {
+		t.Errorf("expected user bob, got %v", conv.UserID)
+	}
+}
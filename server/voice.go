@@ -0,0 +1,90 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"shelley.exe.dev/transcribe"
+)
+
+// maxVoiceBytes is the largest recording accepted for voice input, the
+// limit of OpenAI's transcription API.
+const maxVoiceBytes = 25 << 20
+
+// SetTranscriber enables voice input with the given transcription provider.
+// Must be called before the server starts handling requests.
+func (s *Server) SetTranscriber(p transcribe.Provider) {
+	s.transcriber = p
+}
+
+// handleVoice handles POST /conversation/<id>/voice. It transcribes the
+// recording in the multipart "audio" field and sends the text to the agent
+// as a user message, as /chat does. The optional "model" and "queue" fields
+// are as in ChatRequest; the response adds the transcript to /chat's.
+func (s *Server) handleVoice(w http.ResponseWriter, r *http.Request, conversationID string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.transcriber == nil {
+		http.Error(w, "voice input is not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxVoiceBytes)
+	if err := r.ParseMultipartForm(maxVoiceBytes); err != nil {
+		http.Error(w, "failed to parse form: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	audio, header, err := r.FormFile("audio")
+	if err != nil {
+		http.Error(w, "failed to get recording: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer audio.Close()
+
+	ctx := r.Context()
+	transcript, err := s.transcriber.Transcribe(ctx, audio, header.Filename)
+	if err != nil {
+		s.logger.Error("failed to transcribe recording", "conversationID", conversationID, "error", err)
+		http.Error(w, "failed to transcribe recording", http.StatusBadGateway)
+		return
+	}
+	if transcript == "" {
+		http.Error(w, "no speech was recognized", http.StatusUnprocessableEntity)
+		return
+	}
+
+	queue, queued, err := s.sendMessage(ctx, conversationID, ChatRequest{
+		Message: transcript,
+		Model:   r.FormValue("model"),
+		Queue:   r.FormValue("queue") == "true",
+	})
+	if err != nil {
+		var badRequest *badRequestError
+		if errors.As(err, &badRequest) || errors.Is(err, errConversationModelMismatch) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, errShuttingDown) {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		if errors.Is(err, errConversationOwned) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	resp := map[string]any{"status": "accepted", "transcript": transcript}
+	if queued {
+		resp["status"] = "queued"
+		resp["queue"] = queue
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(resp)
+}
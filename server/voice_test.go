@@ -0,0 +1,59 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"shelley.exe.dev/transcribe"
+)
+
+func TestVoice(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+	h.NewConversation("echo: first", t.TempDir())
+	h.WaitResponse()
+
+	voice := func(recording string) *httptest.ResponseRecorder {
+		body := &bytes.Buffer{}
+		mw := multipart.NewWriter(body)
+		part, _ := mw.CreateFormFile("audio", "voice.webm")
+		part.Write([]byte(recording))
+		mw.Close()
+		req := httptest.NewRequest("POST", "/api/conversation/"+h.convID+"/voice", body)
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+		w := httptest.NewRecorder()
+		mux := http.NewServeMux()
+		h.server.RegisterRoutes(mux)
+		mux.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := voice("echo: spoken"); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 without a transcriber, got %d: %s", w.Code, w.Body.String())
+	}
+
+	h.server.SetTranscriber(transcribe.Text{})
+	if w := voice("  \n"); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected 422 for a silent recording, got %d: %s", w.Code, w.Body.String())
+	}
+	w := voice("echo: spoken")
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Status     string `json:"status"`
+		Transcript string `json:"transcript"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp.Status != "accepted" || resp.Transcript != "echo: spoken" {
+		t.Errorf("unexpected response %+v", resp)
+	}
+	if reply := h.WaitResponse(); !strings.Contains(reply, "spoken") {
+		t.Errorf("expected the transcript to be sent to the agent, got %q", reply)
+	}
+}
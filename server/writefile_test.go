@@ -0,0 +1,94 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	script := filepath.Join(dir, "run.sh")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(dir, "link.sh")
+	if err := os.Symlink(script, link); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := writeFileAtomic(link, []byte("#!/bin/sh\necho hi\n")); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(script); string(data) != "#!/bin/sh\necho hi\n" {
+		t.Errorf("expected the link's target to be written, got %q", data)
+	}
+	if info, _ := os.Lstat(link); info.Mode()&os.ModeSymlink == 0 {
+		t.Error("expected the link to stay a link")
+	}
+	if info, _ := os.Stat(script); info.Mode().Perm() != 0o755 {
+		t.Errorf("expected the permissions to be kept, got %v", info.Mode().Perm())
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 2 {
+		t.Errorf("expected no temporary files to be left, got %v", entries)
+	}
+}
+
+func TestWriteFileConflict(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+	dir := t.TempDir()
+	if err := SaveSettings(t.Context(), h.db, Settings{WriteFile: &WriteFileSettings{AllowedRoots: []string{dir}}}, ""); err != nil {
+		t.Fatal(err)
+	}
+	h.NewConversation("echo: hi", dir)
+	h.WaitResponse()
+	name := filepath.Join(dir, "notes.txt")
+	if err := os.WriteFile(name, []byte("v1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	write := func(content, base string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(map[string]string{"path": name, "content": content, "base_sha256": base, "conversation_id": h.convID})
+		w := httptest.NewRecorder()
+		h.server.handleWriteFile(w, httptest.NewRequest("POST", "/api/write-file", strings.NewReader(string(body))))
+		return w
+	}
+
+	w := write("v2\n", contentHash([]byte("v1\n")))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		SHA256 string `json:"sha256"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp.SHA256 != contentHash([]byte("v2\n")) {
+		t.Errorf("expected the hash of the new content, got %q", resp.SHA256)
+	}
+
+	// Someone else changes the file; a save based on v2 is refused
+	if err := os.WriteFile(name, []byte("v3\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if w := write("v2 edited\n", resp.SHA256); w.Code != http.StatusConflict {
+		t.Errorf("expected 409, got %d: %s", w.Code, w.Body.String())
+	}
+	if data, _ := os.ReadFile(name); string(data) != "v3\n" {
+		t.Errorf("expected the file to be left alone, got %q", data)
+	}
+
+	// The previous content is kept for undoing the write
+	if w := write("v4\n", contentHash([]byte("v3\n"))); w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	edits := h.fileEdits()
+	if len(edits) != 2 || !strings.Contains(edits[1].Diff, "-v3") || !strings.Contains(edits[1].Diff, "+v4") {
+		t.Errorf("expected the writes to be recorded with their previous content, got %+v", edits)
+	}
+}
@@ -0,0 +1,48 @@
+package server
+
+import (
+	"fmt"
+
+	"shelley.exe.dev/filepolicy"
+)
+
+// WriteFileSettings is the policy for files written from the UI, as in the
+// diff viewer's edit mode, and uploaded, and for the files the tools write
+// and read. Nothing may be written inside a .git directory.
+type WriteFileSettings struct {
+	// AllowedRoots are the directories files may be written and read under.
+	// Empty means files may be read anywhere, and written anywhere by the
+	// tools but only in the working trees of git repositories from the UI.
+	AllowedRoots []string `json:"allowedRoots,omitempty"`
+	// DeniedGlobs are paths that may not be written or read. A glob without a
+	// slash matches any element of the path (".env", "*.pem"); one with a
+	// slash matches the whole path, with ** for any number of directories
+	// ("/srv/**/secrets/*").
+	DeniedGlobs []string `json:"deniedGlobs,omitempty"`
+	// MaxBytes is the largest file that may be written or uploaded; zero
+	// means filepolicy.DefaultMaxBytes.
+	MaxBytes int `json:"maxBytes,omitempty"`
+}
+
+// validate checks that the roots are absolute and the globs well formed.
+func (p *WriteFileSettings) validate() error {
+	if err := p.policy(false).Validate(); err != nil {
+		return fmt.Errorf("writeFile: %w", err)
+	}
+	return nil
+}
+
+// policy returns the filepolicy.Policy the settings describe, the one policy
+// for the UI, uploads and the tools. With requireRepository, as for writes
+// from the UI, an empty AllowedRoots confines writes to git working trees.
+func (p *WriteFileSettings) policy(requireRepository bool) *filepolicy.Policy {
+	if p == nil {
+		return &filepolicy.Policy{RequireRepository: requireRepository}
+	}
+	return &filepolicy.Policy{
+		AllowedRoots:      p.AllowedRoots,
+		RequireRepository: requireRepository,
+		DeniedGlobs:       p.DeniedGlobs,
+		MaxBytes:          p.MaxBytes,
+	}
+}
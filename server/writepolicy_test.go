@@ -0,0 +1,38 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteFileDenied(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+	dir := t.TempDir()
+
+	write := func(name string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(map[string]string{"path": name, "content": "hello\n"})
+		w := httptest.NewRecorder()
+		h.server.handleWriteFile(w, httptest.NewRequest("POST", "/api/write-file", strings.NewReader(string(body))))
+		return w
+	}
+	if w := write(filepath.Join(dir, "notes.txt")); w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), "not in a git repository") {
+		t.Errorf("expected a file outside a repository to be refused, got %d: %s", w.Code, w.Body.String())
+	}
+	if _, err := os.Stat(filepath.Join(dir, "notes.txt")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected nothing to be written, got %v", err)
+	}
+
+	if err := SaveSettings(t.Context(), h.db, Settings{WriteFile: &WriteFileSettings{AllowedRoots: []string{dir}}}, ""); err != nil {
+		t.Fatal(err)
+	}
+	if w := write(filepath.Join(dir, "notes.txt")); w.Code != http.StatusOK {
+		t.Errorf("expected a file under an allowed root to be written, got %d: %s", w.Code, w.Body.String())
+	}
+}
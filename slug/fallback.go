@@ -0,0 +1,73 @@
+package slug
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+const (
+	// fallbackWords is how many keywords a fallback slug keeps.
+	fallbackWords = 5
+	// fallbackWordRunes caps each keyword, for languages written without spaces.
+	fallbackWordRunes = 20
+)
+
+var (
+	codeBlockPattern = regexp.MustCompile("(?s)```.*?```")
+	urlPattern       = regexp.MustCompile(`\b[a-z][a-z0-9+.-]*://\S+`)
+)
+
+// stopWords are left out of fallback slugs.
+var stopWords = map[string]bool{
+	"a": true, "about": true, "an": true, "and": true, "are": true, "as": true,
+	"at": true, "be": true, "but": true, "by": true, "can": true, "could": true,
+	"do": true, "does": true, "for": true, "from": true, "have": true, "hi": true,
+	"hello": true, "how": true, "i": true, "id": true, "im": true, "in": true,
+	"into": true, "is": true, "it": true, "its": true, "lets": true, "me": true,
+	"my": true, "of": true, "on": true, "or": true, "please": true, "so": true,
+	"some": true, "that": true, "thats": true, "the": true, "there": true,
+	"this": true, "to": true, "up": true, "us": true, "want": true, "we": true,
+	"what": true, "whats": true, "when": true, "where": true, "which": true,
+	"why": true, "will": true, "with": true, "would": true, "you": true,
+	"your": true,
+}
+
+// fallbackSlugText derives a slug from a user message without an LLM: the
+// first few words that aren't stop words, skipping code blocks and URLs.
+// It returns "untitled" if the message has no usable words.
+func fallbackSlugText(userMessage string) string {
+	text := codeBlockPattern.ReplaceAllString(userMessage, " ")
+	text = urlPattern.ReplaceAllString(text, " ")
+
+	var words []string
+	for _, field := range strings.Fields(strings.ToLower(text)) {
+		// Drop apostrophes ("what's" -> "whats") and turn other punctuation
+		// into hyphens ("auth.go" -> "auth-go")
+		word := strings.Map(func(r rune) rune {
+			switch {
+			case unicode.IsLetter(r) || unicode.IsDigit(r):
+				return r
+			case r == '\'' || r == '’':
+				return -1
+			default:
+				return '-'
+			}
+		}, field)
+		word = strings.Trim(word, "-")
+		if word == "" || stopWords[word] {
+			continue
+		}
+		if runes := []rune(word); len(runes) > fallbackWordRunes {
+			word = strings.TrimRight(string(runes[:fallbackWordRunes]), "-")
+		}
+		words = append(words, word)
+		if len(words) == fallbackWords {
+			break
+		}
+	}
+	if len(words) == 0 {
+		return "untitled"
+	}
+	return Sanitize(strings.Join(words, "-"))
+}
@@ -2,6 +2,7 @@ package slug
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log/slog"
 	"regexp"
@@ -17,21 +18,123 @@ type LLMServiceProvider interface {
 	GetService(modelID string) (llm.Service, error)
 }
 
+// Config controls how slugs are generated.
+type Config struct {
+	// Template arranges the generated title and conversation details into
+	// the slug, e.g. "{date}-{branch}-{title}" or "{repo}/{title}". Empty
+	// means just the title. See TemplateVars for the available variables.
+	Template string
+	// Models are tried in order to generate titles; empty means DefaultModels.
+	Models []string
+	// Prompt replaces DefaultPrompt. {message} in it is replaced by the
+	// first message, or when regenerating, the conversation's transcript;
+	// without {message} the text is appended.
+	Prompt string
+}
+
+// DefaultModels are the models tried for slug generation, in order, when
+// Config.Models is empty.
+var DefaultModels = []string{"qwen3-coder-fireworks", "gpt5-mini", "gpt-5-thinking-mini", "claude-sonnet-4.5", "predictable"}
+
+// DefaultPrompt asks for a slug for a new conversation. {message} is
+// replaced by the conversation's first message.
+const DefaultPrompt = `Generate a short, descriptive slug (2-6 words, lowercase, hyphen-separated) for a conversation that starts with this user message:
+
+{message}
+
+The slug should:
+- Be concise and descriptive
+- Use only lowercase letters, numbers, and hyphens
+- Capture the main topic or intent
+- Be suitable as a filename or URL path
+
+Respond with only the slug, nothing else.`
+
+// regeneratePrompt asks for a slug for a conversation's whole transcript.
+const regeneratePrompt = `Generate a short, descriptive slug (2-6 words, lowercase, hyphen-separated) for a conversation with this transcript:
+
+{message}
+
+The slug should:
+- Be concise and descriptive
+- Use only lowercase letters, numbers, and hyphens
+- Capture what the conversation is mostly about now, even if it started elsewhere
+- Be suitable as a filename or URL path
+
+Respond with only the slug, nothing else.`
+
+// slugFormat asks for the slug as a JSON object, so that it doesn't
+// matter what else the model would have said.
+var slugFormat = &llm.ResponseFormat{
+	Name: "slug",
+	Schema: llm.MustSchema(`{
+		"type": "object",
+		"properties": {"slug": {"type": "string", "description": "The slug"}},
+		"required": ["slug"],
+		"additionalProperties": false
+	}`),
+}
+
+// slugPrompt fills in a prompt, appending the message if the prompt
+// doesn't say where it goes.
+func slugPrompt(prompt, message string) string {
+	if !strings.Contains(prompt, "{message}") {
+		return prompt + "\n\n" + message
+	}
+	return strings.ReplaceAll(prompt, "{message}", message)
+}
+
 // GenerateSlug generates a slug for a conversation and updates the database
 // If conversationModelID is provided, it will try to use that model first before falling back to the default list
-func GenerateSlug(ctx context.Context, llmProvider LLMServiceProvider, database *db.DB, logger *slog.Logger, conversationID, userMessage, conversationModelID string) (string, error) {
-	baseSlug, err := generateSlugText(ctx, llmProvider, logger, userMessage, conversationModelID)
+// If no model can generate one, the slug is made from the first words of userMessage
+func GenerateSlug(ctx context.Context, llmProvider LLMServiceProvider, database *db.DB, logger *slog.Logger, conversationID, userMessage, conversationModelID string, cfg Config) (string, error) {
+	title, err := generateSlugText(ctx, llmProvider, logger, userMessage, conversationModelID, cfg)
+	if err != nil {
+		// Without an LLM, derive a title from the message so the
+		// conversation isn't left untitled
+		logger.Warn("Falling back to a title from the first message", "conversationID", conversationID, "error", err)
+		title = fallbackSlugText(userMessage)
+	}
+	baseSlug := ApplyTemplate(cfg.Template, templateVars(ctx, database, conversationID, title, cfg.Template))
+
+	slug, err := SetUnique(ctx, database, conversationID, baseSlug)
 	if err != nil {
 		return "", err
 	}
+	logger.Info("Generated slug for conversation", "conversationID", conversationID, "slug", slug)
+	return slug, nil
+}
+
+// RegenerateSlug generates a new slug for an existing conversation from a
+// transcript of its messages, for when the conversation has drifted from the
+// topic of its first message, and updates the database.
+func RegenerateSlug(ctx context.Context, llmProvider LLMServiceProvider, database *db.DB, logger *slog.Logger, conversationID, transcript, conversationModelID string, cfg Config) (string, error) {
+	prompt := regeneratePrompt
+	if cfg.Prompt != "" {
+		prompt = cfg.Prompt
+	}
+	title, err := requestSlug(ctx, llmProvider, logger, slugPrompt(prompt, transcript), conversationModelID, cfg.Models)
+	if err != nil {
+		return "", err
+	}
+	baseSlug := ApplyTemplate(cfg.Template, templateVars(ctx, database, conversationID, title, cfg.Template))
+	slug, err := SetUnique(ctx, database, conversationID, baseSlug)
+	if err != nil {
+		return "", err
+	}
+	logger.Info("Regenerated slug for conversation", "conversationID", conversationID, "slug", slug)
+	return slug, nil
+}
 
+// SetUnique sets a conversation's slug to baseSlug, or if another
+// conversation already has it, to baseSlug with the first free numeric
+// suffix ("-1", "-2", ...). It returns the slug that was set.
+func SetUnique(ctx context.Context, database *db.DB, conversationID, baseSlug string) (string, error) {
 	// Try to update with the base slug first, then with numeric suffixes if needed
 	slug := baseSlug
 	for attempt := 0; attempt < 100; attempt++ {
-		_, err = database.UpdateConversationSlug(ctx, conversationID, slug)
+		_, err := database.UpdateConversationSlug(ctx, conversationID, slug)
 		if err == nil {
-			// Success!
-			logger.Info("Generated slug for conversation", "conversationID", conversationID, "slug", slug)
 			return slug, nil
 		}
 
@@ -54,13 +157,24 @@ func GenerateSlug(ctx context.Context, llmProvider LLMServiceProvider, database
 
 // generateSlugText generates a human-readable slug for a conversation based on the user message
 // If conversationModelID is "predictable", it will be used instead of the default preferred models
-func generateSlugText(ctx context.Context, llmProvider LLMServiceProvider, logger *slog.Logger, userMessage, conversationModelID string) (string, error) {
+func generateSlugText(ctx context.Context, llmProvider LLMServiceProvider, logger *slog.Logger, userMessage, conversationModelID string, cfg Config) (string, error) {
+	prompt := DefaultPrompt
+	if cfg.Prompt != "" {
+		prompt = cfg.Prompt
+	}
+	return requestSlug(ctx, llmProvider, logger, slugPrompt(prompt, userMessage), conversationModelID, cfg.Models)
+}
+
+// requestSlug asks an LLM for a slug using the given prompt and sanitizes the reply.
+// preferredModels are tried in order; if empty, DefaultModels are.
+func requestSlug(ctx context.Context, llmProvider LLMServiceProvider, logger *slog.Logger, prompt, conversationModelID string, preferredModels []string) (string, error) {
 	// Try different models in order of preference
 	var llmService llm.Service
 	var err error
 
-	// Preferred models in order of preference
-	preferredModels := []string{"qwen3-coder-fireworks", "gpt5-mini", "gpt-5-thinking-mini", "claude-sonnet-4.5", "predictable"}
+	if len(preferredModels) == 0 {
+		preferredModels = DefaultModels
+	}
 
 	// If conversation is using predictable model, use it for slug generation too
 	if conversationModelID == "predictable" {
@@ -88,28 +202,16 @@ func generateSlugText(ctx context.Context, llmProvider LLMServiceProvider, logge
 		return "", fmt.Errorf("no suitable model available for slug generation")
 	}
 
-	// Create a focused prompt for slug generation
-	slugPrompt := fmt.Sprintf(`Generate a short, descriptive slug (2-6 words, lowercase, hyphen-separated) for a conversation that starts with this user message:
-
-%s
-
-The slug should:
-- Be concise and descriptive
-- Use only lowercase letters, numbers, and hyphens
-- Capture the main topic or intent
-- Be suitable as a filename or URL path
-
-Respond with only the slug, nothing else.`, userMessage)
-
 	message := llm.Message{
 		Role: llm.MessageRoleUser,
 		Content: []llm.Content{
-			{Type: llm.ContentTypeText, Text: slugPrompt},
+			{Type: llm.ContentTypeText, Text: prompt},
 		},
 	}
 
 	request := &llm.Request{
-		Messages: []llm.Message{message},
+		Messages:       []llm.Message{message},
+		ResponseFormat: slugFormat,
 	}
 
 	// Make LLM request with timeout
@@ -121,12 +223,19 @@ Respond with only the slug, nothing else.`, userMessage)
 		return "", fmt.Errorf("failed to generate slug: %w", err)
 	}
 
-	// Extract text from response
-	if len(response.Content) == 0 {
+	// Extract the slug from the response. Services that ignore the
+	// response format, like the predictable one, answer with just the slug.
+	text := response.JSONText()
+	if text == "" {
 		return "", fmt.Errorf("empty response from LLM")
 	}
-
-	slug := strings.TrimSpace(response.Content[0].Text)
+	var reply struct {
+		Slug string `json:"slug"`
+	}
+	slug := text
+	if err := json.Unmarshal([]byte(text), &reply); err == nil {
+		slug = reply.Slug
+	}
 
 	// Clean and validate the slug
 	slug = Sanitize(slug)
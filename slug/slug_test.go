@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"log/slog"
 	"os"
+	"slices"
+	"strings"
 	"testing"
 
 	"shelley.exe.dev/db"
@@ -123,7 +125,7 @@ func TestGenerateSlug_DatabaseIntegration(t *testing.T) {
 	}
 
 	// Generate first slug - should succeed with "test title"
-	slug1, err := GenerateSlug(ctx, mockLLM, database, logger, conv1.ConversationID, "Test message", "")
+	slug1, err := GenerateSlug(ctx, mockLLM, database, logger, conv1.ConversationID, "Test message", "", Config{})
 	if err != nil {
 		t.Fatalf("Failed to generate first slug: %v", err)
 	}
@@ -138,7 +140,7 @@ func TestGenerateSlug_DatabaseIntegration(t *testing.T) {
 	}
 
 	// Generate second slug - should get "test title-1" due to conflict
-	slug2, err := GenerateSlug(ctx, mockLLM, database, logger, conv2.ConversationID, "Test message", "")
+	slug2, err := GenerateSlug(ctx, mockLLM, database, logger, conv2.ConversationID, "Test message", "", Config{})
 	if err != nil {
 		t.Fatalf("Failed to generate second slug: %v", err)
 	}
@@ -153,7 +155,7 @@ func TestGenerateSlug_DatabaseIntegration(t *testing.T) {
 	}
 
 	// Generate third slug - should get "test title-2" due to conflict
-	slug3, err := GenerateSlug(ctx, mockLLM, database, logger, conv3.ConversationID, "Test message", "")
+	slug3, err := GenerateSlug(ctx, mockLLM, database, logger, conv3.ConversationID, "Test message", "", Config{})
 	if err != nil {
 		t.Fatalf("Failed to generate third slug: %v", err)
 	}
@@ -168,3 +170,192 @@ func TestGenerateSlug_DatabaseIntegration(t *testing.T) {
 
 	t.Logf("Successfully generated unique slugs: %q, %q, %q", slug1, slug2, slug3)
 }
+
+// recordingLLMService records the prompt it was sent
+type recordingLLMService struct {
+	MockLLMService
+	prompt  string
+	request *llm.Request
+}
+
+func (m *recordingLLMService) Do(ctx context.Context, req *llm.Request) (*llm.Response, error) {
+	m.prompt = req.Messages[0].Content[0].Text
+	m.request = req
+	return m.MockLLMService.Do(ctx, req)
+}
+
+type recordingLLMProvider struct {
+	Service *recordingLLMService
+}
+
+func (m *recordingLLMProvider) GetService(modelID string) (llm.Service, error) {
+	return m.Service, nil
+}
+
+func TestRegenerateSlug(t *testing.T) {
+	database, err := db.New(db.Config{DSN: t.TempDir() + "/slug_test.db"})
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	defer database.Close()
+	ctx := context.Background()
+	if err := database.Migrate(ctx); err != nil {
+		t.Fatalf("Failed to migrate database: %v", err)
+	}
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelWarn}))
+
+	other, err := database.CreateConversation(ctx, nil, true, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := database.UpdateConversationSlug(ctx, other.ConversationID, "dark mode"); err != nil {
+		t.Fatal(err)
+	}
+	title := "login bug"
+	conv, err := database.CreateConversation(ctx, &title, true, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	service := &recordingLLMService{MockLLMService: MockLLMService{ResponseText: "dark mode"}}
+	transcript := "User: fix the login bug\n\nUser: now add dark mode"
+	slug, err := RegenerateSlug(ctx, &recordingLLMProvider{Service: service}, database, logger, conv.ConversationID, transcript, "", Config{})
+	if err != nil {
+		t.Fatalf("RegenerateSlug() error = %v", err)
+	}
+	if slug != "dark mode-1" {
+		t.Errorf("Expected slug %q, got %q", "dark mode-1", slug)
+	}
+	if !strings.Contains(service.prompt, transcript) {
+		t.Errorf("Expected prompt to contain the transcript, got %q", service.prompt)
+	}
+}
+
+func TestFallbackSlugText(t *testing.T) {
+	tests := []struct {
+		message string
+		want    string
+	}{
+		{"Please fix the login bug in auth.go", "fix-login-bug-auth-go"},
+		{"Hi! Can you help me refactor the database layer so that it uses pooling?", "help-refactor-database-layer-uses"},
+		{"see https://example.com/issue/12 and fix it", "see-fix"},
+		{"```\ncode only\n```", "untitled"},
+		{"???", "untitled"},
+		{"What's the weather?", "weather"},
+		{"データベースの接続エラーを直してください", "データベースの接続エラーを直してください"},
+		{"データベースの接続エラーを直してくださいね", "データベースの接続エラーを直してください"},
+	}
+	for _, tt := range tests {
+		if got := fallbackSlugText(tt.message); got != tt.want {
+			t.Errorf("fallbackSlugText(%q) = %q, want %q", tt.message, got, tt.want)
+		}
+	}
+}
+
+// failingLLMProvider has no models available
+type failingLLMProvider struct{}
+
+func (failingLLMProvider) GetService(modelID string) (llm.Service, error) {
+	return nil, fmt.Errorf("model %s not available", modelID)
+}
+
+func TestGenerateSlug_FallbackWithoutLLM(t *testing.T) {
+	database, err := db.New(db.Config{DSN: t.TempDir() + "/slug_test.db"})
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	defer database.Close()
+	ctx := context.Background()
+	if err := database.Migrate(ctx); err != nil {
+		t.Fatalf("Failed to migrate database: %v", err)
+	}
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+
+	var slugs []string
+	for i := 0; i < 2; i++ {
+		conv, err := database.CreateConversation(ctx, nil, true, nil, nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		slug, err := GenerateSlug(ctx, failingLLMProvider{}, database, logger, conv.ConversationID, "Fix the flaky test", "", Config{})
+		if err != nil {
+			t.Fatalf("GenerateSlug() error = %v", err)
+		}
+		slugs = append(slugs, slug)
+	}
+	if slugs[0] != "fix-flaky-test" || slugs[1] != "fix-flaky-test-1" {
+		t.Errorf("Expected unique fallback slugs, got %q", slugs)
+	}
+}
+
+func TestGenerateSlug_Config(t *testing.T) {
+	database, err := db.New(db.Config{DSN: t.TempDir() + "/slug_test.db"})
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	defer database.Close()
+	ctx := context.Background()
+	if err := database.Migrate(ctx); err != nil {
+		t.Fatalf("Failed to migrate database: %v", err)
+	}
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelWarn}))
+	conv, err := database.CreateConversation(ctx, nil, true, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	service := &recordingLLMService{MockLLMService: MockLLMService{ResponseText: "custom"}}
+	provider := &modelsLLMProvider{models: map[string]llm.Service{"only-model": service}}
+	cfg := Config{Models: []string{"only-model"}, Prompt: "Name this chat: {message}"}
+	slug, err := GenerateSlug(ctx, provider, database, logger, conv.ConversationID, "fix the tests", "", cfg)
+	if err != nil {
+		t.Fatalf("GenerateSlug() error = %v", err)
+	}
+	if slug != "custom" {
+		t.Errorf("Expected slug from the configured model, got %q", slug)
+	}
+	if service.prompt != "Name this chat: fix the tests" {
+		t.Errorf("Expected the configured prompt, got %q", service.prompt)
+	}
+	if !slices.Equal(provider.requested, []string{"only-model"}) {
+		t.Errorf("Expected only the configured model to be tried, got %v", provider.requested)
+	}
+}
+
+func TestGenerateSlug_ResponseFormat(t *testing.T) {
+	service := &recordingLLMService{MockLLMService: MockLLMService{ResponseText: "```json\n{\"slug\": \"fix the tests\"}\n```"}}
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelWarn}))
+	slug, err := requestSlug(context.Background(), &recordingLLMProvider{Service: service}, logger, "prompt", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if slug != "fix the tests" {
+		t.Errorf("Expected the slug from the JSON reply, got %q", slug)
+	}
+	if service.request.ResponseFormat == nil || service.request.ResponseFormat.Name != "slug" {
+		t.Errorf("Expected the slug response format to be asked for, got %+v", service.request.ResponseFormat)
+	}
+}
+
+func TestSlugPrompt(t *testing.T) {
+	if got := slugPrompt("Title: {message}!", "hi"); got != "Title: hi!" {
+		t.Errorf("slugPrompt() = %q", got)
+	}
+	if got := slugPrompt("Give a title.", "hi"); got != "Give a title.\n\nhi" {
+		t.Errorf("slugPrompt() without placeholder = %q", got)
+	}
+}
+
+// modelsLLMProvider serves a fixed set of models and records which were requested
+type modelsLLMProvider struct {
+	models    map[string]llm.Service
+	requested []string
+}
+
+func (m *modelsLLMProvider) GetService(modelID string) (llm.Service, error) {
+	m.requested = append(m.requested, modelID)
+	if service, ok := m.models[modelID]; ok {
+		return service, nil
+	}
+	return nil, fmt.Errorf("model %s not available", modelID)
+}
@@ -0,0 +1,126 @@
+package slug
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"shelley.exe.dev/db"
+	"shelley.exe.dev/gitstate"
+)
+
+// TemplateVars lists the variables a slug template may use.
+var TemplateVars = []string{"title", "date", "branch", "repo", "dir"}
+
+var templateVarPattern = regexp.MustCompile(`\{([a-z]+)\}`)
+
+// templateSeparators are the characters dropped along with empty variables.
+const templateSeparators = "-_/. "
+
+// ValidateTemplate checks that a slug template uses only known variables
+// and includes {title}.
+func ValidateTemplate(template string) error {
+	if template == "" {
+		return nil
+	}
+	hasTitle := false
+	for _, m := range templateVarPattern.FindAllStringSubmatch(template, -1) {
+		name := m[1]
+		if !slices.Contains(TemplateVars, name) {
+			return fmt.Errorf("unknown slug template variable {%s}", name)
+		}
+		hasTitle = hasTitle || name == "title"
+	}
+	if !hasTitle {
+		return fmt.Errorf("slug template must include {title}")
+	}
+	return nil
+}
+
+// ApplyTemplate fills in a slug template. A variable with no value is left
+// out together with the separator (any of templateSeparators) that follows
+// it, or at the end of the template the one before it, so "{date}-{branch}-{title}" without a
+// branch gives "2025-01-02-title" rather than "2025-01-02--title".
+func ApplyTemplate(template string, vars map[string]string) string {
+	if template == "" {
+		return vars["title"]
+	}
+
+	// Split into alternating literals and variables: parts[0] is a literal,
+	// then a variable name, then a literal, and so on.
+	var parts []string
+	last := 0
+	for _, loc := range templateVarPattern.FindAllStringSubmatchIndex(template, -1) {
+		parts = append(parts, template[last:loc[0]], template[loc[2]:loc[3]])
+		last = loc[1]
+	}
+	parts = append(parts, template[last:])
+
+	var b strings.Builder
+	b.WriteString(parts[0])
+	for i := 1; i < len(parts); i += 2 {
+		value, after := vars[parts[i]], parts[i+1]
+		if value != "" {
+			b.WriteString(value)
+			b.WriteString(after)
+			continue
+		}
+		switch {
+		case i+2 >= len(parts):
+			// Last variable: drop the separator written before it instead
+			out := strings.TrimRight(b.String(), templateSeparators)
+			b.Reset()
+			b.WriteString(out)
+			b.WriteString(after)
+		case strings.Trim(after, templateSeparators) != "":
+			// Not just a separator, so keep it
+			b.WriteString(after)
+		}
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// templateVars collects the values of the template variables for a
+// conversation, looking up its git state when the template needs it.
+func templateVars(ctx context.Context, database *db.DB, conversationID, title, template string) map[string]string {
+	vars := map[string]string{"title": title}
+	if template == "" {
+		return vars
+	}
+	conversation, err := database.GetConversationByID(ctx, conversationID)
+	if err != nil {
+		return vars
+	}
+	vars["date"] = conversation.CreatedAt.Local().Format("2006-01-02")
+	if conversation.Cwd == nil || *conversation.Cwd == "" {
+		return vars
+	}
+	cwd := *conversation.Cwd
+	vars["dir"] = filepath.Base(cwd)
+	if conversation.GitOrigin != nil {
+		vars["repo"] = repoName(*conversation.GitOrigin)
+	}
+	if strings.Contains(template, "{branch}") || (vars["repo"] == "" && strings.Contains(template, "{repo}")) {
+		state := gitstate.GetGitState(cwd)
+		if state.IsRepo {
+			vars["branch"] = state.Branch
+			if vars["repo"] == "" {
+				vars["repo"] = filepath.Base(state.Worktree)
+			}
+		}
+	}
+	return vars
+}
+
+// repoName returns the repository name from a git remote URL, e.g.
+// "shelley" for "git@github.com:user/shelley.git".
+func repoName(origin string) string {
+	origin = strings.TrimSuffix(strings.TrimRight(origin, "/"), ".git")
+	if i := strings.LastIndexAny(origin, "/:"); i >= 0 {
+		origin = origin[i+1:]
+	}
+	return origin
+}
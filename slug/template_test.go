@@ -0,0 +1,96 @@
+package slug
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"shelley.exe.dev/db"
+)
+
+func TestApplyTemplate(t *testing.T) {
+	vars := map[string]string{
+		"title":  "fix login",
+		"date":   "2025-01-02",
+		"branch": "main",
+		"repo":   "shelley",
+	}
+	tests := []struct {
+		template string
+		vars     map[string]string
+		want     string
+	}{
+		{"", vars, "fix login"},
+		{"{title}", vars, "fix login"},
+		{"{date}-{branch}-{title}", vars, "2025-01-02-main-fix login"},
+		{"{repo}/{title}", vars, "shelley/fix login"},
+		// Empty variables drop their separator
+		{"{date}-{dir}-{title}", vars, "2025-01-02-fix login"},
+		{"{title} ({dir})", vars, "fix login ()"},
+		{"{title}-{dir}", vars, "fix login"},
+		{"{dir}/{title}", vars, "fix login"},
+		{"[{branch}] {title}", map[string]string{"title": "t"}, "[] t"},
+	}
+	for _, tt := range tests {
+		if got := ApplyTemplate(tt.template, tt.vars); got != tt.want {
+			t.Errorf("ApplyTemplate(%q) = %q, want %q", tt.template, got, tt.want)
+		}
+	}
+}
+
+func TestValidateTemplate(t *testing.T) {
+	for _, template := range []string{"", "{title}", "{date}-{branch}-{title}", "{repo}/{title} in {dir}"} {
+		if err := ValidateTemplate(template); err != nil {
+			t.Errorf("ValidateTemplate(%q) = %v, want nil", template, err)
+		}
+	}
+	for _, template := range []string{"{date}", "{title}-{user}", "static"} {
+		if err := ValidateTemplate(template); err == nil {
+			t.Errorf("ValidateTemplate(%q) = nil, want error", template)
+		}
+	}
+}
+
+func TestRepoName(t *testing.T) {
+	for origin, want := range map[string]string{
+		"git@github.com:user/shelley.git":  "shelley",
+		"https://github.com/user/shelley":  "shelley",
+		"https://github.com/user/shelley/": "shelley",
+		"git@host:shelley.git":             "shelley",
+	} {
+		if got := repoName(origin); got != want {
+			t.Errorf("repoName(%q) = %q, want %q", origin, got, want)
+		}
+	}
+}
+
+func TestGenerateSlug_Template(t *testing.T) {
+	database, err := db.New(db.Config{DSN: t.TempDir() + "/slug_test.db"})
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	defer database.Close()
+	ctx := context.Background()
+	if err := database.Migrate(ctx); err != nil {
+		t.Fatalf("Failed to migrate database: %v", err)
+	}
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelWarn}))
+
+	cwd := t.TempDir()
+	conv, err := database.CreateConversation(ctx, nil, true, &cwd, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	provider := &MockLLMProvider{Service: &MockLLMService{ResponseText: "fix login"}}
+	slug, err := GenerateSlug(ctx, provider, database, logger, conv.ConversationID, "msg", "", Config{Template: "{date} {dir} {title}"})
+	if err != nil {
+		t.Fatalf("GenerateSlug() error = %v", err)
+	}
+	want := conv.CreatedAt.Local().Format("2006-01-02") + " " + filepath.Base(cwd) + " fix login"
+	if slug != want {
+		t.Errorf("GenerateSlug() = %q, want %q", slug, want)
+	}
+}
@@ -106,3 +106,27 @@ func (sp *SubPub[K]) Publish(idx int64, message K) {
 	}
 	sp.subscribers = remaining
 }
+
+// Broadcast sends a transient message, such as the output of a running tool,
+// to all subscribers without advancing their index. Subscribers that are
+// behind miss it rather than being disconnected.
+func (sp *SubPub[K]) Broadcast(message K) {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+
+	remaining := sp.subscribers[:0]
+	for _, sub := range sp.subscribers {
+		select {
+		case <-sub.ctx.Done():
+			close(sub.ch)
+			continue
+		default:
+		}
+		select {
+		case sub.ch <- message:
+		default:
+		}
+		remaining = append(remaining, sub)
+	}
+	sp.subscribers = remaining
+}
@@ -260,3 +260,38 @@ func TestSubPubMultiplePublishes(t *testing.T) {
 		}
 	})
 }
+
+func TestSubPubBroadcast(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		sp := New[string]()
+		ctx := context.Background()
+
+		// A subscriber that already has index 5 still gets broadcasts
+		next := sp.Subscribe(ctx, 5)
+		sp.Broadcast("progress")
+		msg, ok := next()
+		if !ok || msg != "progress" {
+			t.Fatalf("Expected 'progress', got %q (ok=%v)", msg, ok)
+		}
+
+		// Broadcasts do not advance the index
+		sp.Publish(6, "message")
+		msg, ok = next()
+		if !ok || msg != "message" {
+			t.Fatalf("Expected 'message', got %q (ok=%v)", msg, ok)
+		}
+
+		// A full subscriber misses broadcasts but stays connected
+		for i := range 20 {
+			sp.Broadcast(fmt.Sprintf("progress %d", i))
+		}
+		for range 10 {
+			next()
+		}
+		sp.Publish(7, "after")
+		msg, ok = next()
+		if !ok || msg != "after" {
+			t.Fatalf("Expected 'after', got %q (ok=%v)", msg, ok)
+		}
+	})
+}
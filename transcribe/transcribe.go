@@ -0,0 +1,67 @@
+// Package transcribe turns recorded speech into text for voice input.
+package transcribe
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// Provider transcribes recorded speech.
+type Provider interface {
+	// Transcribe returns the text spoken in audio. name is the file name the
+	// recording was uploaded with; its extension tells the audio format.
+	Transcribe(ctx context.Context, audio io.Reader, name string) (string, error)
+}
+
+// OpenAI transcribes speech with an OpenAI-compatible /audio/transcriptions endpoint.
+type OpenAI struct {
+	HTTPC     *http.Client // defaults to http.DefaultClient if nil
+	APIKey    string       // required
+	URL       string       // optional, overrides the OpenAI base URL
+	ModelName string       // defaults to whisper-1
+	Language  string       // optional ISO-639-1 hint, such as "en"
+}
+
+var _ Provider = (*OpenAI)(nil)
+
+func (o *OpenAI) Transcribe(ctx context.Context, audio io.Reader, name string) (string, error) {
+	config := openai.DefaultConfig(o.APIKey)
+	if o.URL != "" {
+		config.BaseURL = o.URL
+	}
+	config.HTTPClient = cmp.Or(o.HTTPC, http.DefaultClient)
+	client := openai.NewClientWithConfig(config)
+
+	resp, err := client.CreateTranscription(ctx, openai.AudioRequest{
+		Model:    cmp.Or(o.ModelName, openai.Whisper1),
+		FilePath: name,
+		Reader:   audio,
+		Language: o.Language,
+		Format:   openai.AudioResponseFormatJSON,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to transcribe audio: %w", err)
+	}
+	return strings.TrimSpace(resp.Text), nil
+}
+
+// Text is a local provider that reads the recording as UTF-8 text. It needs
+// no network access, which makes it suitable for tests and predictable-only
+// mode.
+type Text struct{}
+
+var _ Provider = Text{}
+
+func (Text) Transcribe(ctx context.Context, audio io.Reader, name string) (string, error) {
+	data, err := io.ReadAll(audio)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
@@ -0,0 +1,52 @@
+package transcribe
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestOpenAI(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/audio/transcriptions" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			t.Errorf("expected the recording as a file: %v", err)
+			return
+		}
+		data, _ := io.ReadAll(file)
+		if header.Filename != "voice.webm" || string(data) != "RIFF" {
+			t.Errorf("unexpected upload %q: %q", header.Filename, data)
+		}
+		if model := r.FormValue("model"); model != "whisper-1" {
+			t.Errorf("expected the default model, got %q", model)
+		}
+		json.NewEncoder(w).Encode(map[string]string{"text": " run the tests \n"})
+	}))
+	defer srv.Close()
+
+	p := &OpenAI{APIKey: "key", URL: srv.URL}
+	text, err := p.Transcribe(context.Background(), strings.NewReader("RIFF"), "voice.webm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text != "run the tests" {
+		t.Errorf("expected the trimmed transcript, got %q", text)
+	}
+}
+
+func TestText(t *testing.T) {
+	text, err := Text{}.Transcribe(context.Background(), strings.NewReader("  hello\n"), "voice.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text != "hello" {
+		t.Errorf("expected %q, got %q", "hello", text)
+	}
+}
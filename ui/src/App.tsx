@@ -99,7 +99,7 @@ function App() {
 
     // Otherwise, try to fetch by ID (might be archived)
     try {
-      const response = await api.getConversation(urlId);
+      const response = await api.getConversation(urlId, { limit: 1 });
       if (response?.conversation) {
         return response.conversation.conversation_id;
       }
@@ -349,6 +349,12 @@ function App() {
     );
   };
 
+  const handleConversationPinned = (conversation: Conversation) => {
+    setConversations((prev) =>
+      prev.map((c) => (c.conversation_id === conversation.conversation_id ? conversation : c)),
+    );
+  };
+
   if (loading && conversations.length === 0) {
     return (
       <div className="loading-container">
@@ -382,9 +388,19 @@ function App() {
   // For new conversation pane (focusedConversationId === null), use inherited cwd from previous focus
   const mostRecentCwd = focusedConversation?.cwd ?? inheritedCwd ?? window.__SHELLEY_INIT__?.home_dir ?? null;
 
-  const handleFirstMessage = async (message: string, model: string, cwd?: string) => {
+  const handleFirstMessage = async (
+    message: string,
+    model: string,
+    cwd?: string,
+    devcontainer?: boolean,
+  ) => {
     try {
-      const response = await api.sendMessageWithNewConversation({ message, model, cwd });
+      const response = await api.sendMessageWithNewConversation({
+        message,
+        model,
+        cwd,
+        devcontainer: devcontainer || undefined,
+      });
       const newConversationId = response.conversation_id;
 
       const updatedConvs = await api.getConversations();
@@ -413,6 +429,7 @@ function App() {
         onConversationArchived={handleConversationArchived}
         onConversationUnarchived={handleConversationUnarchived}
         onConversationRenamed={handleConversationRenamed}
+        onConversationPinned={handleConversationPinned}
         columnCount={columnCount}
         rowCount={rowCount}
         onColumnCountChange={setColumnCount}
@@ -0,0 +1,109 @@
+import React, { useState } from "react";
+import { LLMContent } from "../types";
+import { api } from "../services/api";
+import { useConversationId } from "../hooks/useConversationId";
+
+interface AskUserToolProps {
+  // For tool_use (pending state)
+  toolUseId?: string;
+  toolInput?: unknown; // { question: string, options?: string[] }
+  isRunning?: boolean;
+
+  // For tool_result (completed state)
+  toolResult?: LLMContent[];
+  hasError?: boolean;
+  executionTime?: string;
+}
+
+function AskUserTool({ toolUseId, toolInput, isRunning, toolResult, hasError }: AskUserToolProps) {
+  const conversationId = useConversationId();
+  const [answer, setAnswer] = useState("");
+  const [sending, setSending] = useState(false);
+  const [error, setError] = useState<string | null>(null);
+
+  const input = typeof toolInput === "object" && toolInput !== null ? toolInput : {};
+  const question = "question" in input && typeof input.question === "string" ? input.question : "";
+  const options =
+    "options" in input && Array.isArray(input.options)
+      ? input.options.filter((o): o is string => typeof o === "string")
+      : [];
+
+  const isComplete = !isRunning && toolResult !== undefined;
+  const result = toolResult && toolResult.length > 0 && toolResult[0].Text ? toolResult[0].Text : "";
+  const canAnswer = !isComplete && Boolean(conversationId && toolUseId);
+
+  const send = async (text: string) => {
+    if (!conversationId || !toolUseId || !text.trim()) return;
+    setSending(true);
+    setError(null);
+    try {
+      await api.answerQuestion(conversationId, toolUseId, text.trim());
+    } catch (err) {
+      setError(err instanceof Error ? err.message : "Failed to send answer");
+      setSending(false);
+    }
+  };
+
+  return (
+    <div
+      className="tool ask-user-tool"
+      data-testid={isComplete ? "tool-call-completed" : "tool-call-running"}
+    >
+      <div className="tool-header">
+        <div className="tool-summary">
+          <span className="tool-emoji">❓</span>
+          <span className="ask-user-question">{question}</span>
+          {isComplete && hasError && <span className="tool-error">✗</span>}
+        </div>
+      </div>
+
+      <div className="tool-details">
+        {isComplete ? (
+          <div className={`tool-code ${hasError ? "error" : ""}`}>{result || "(no answer)"}</div>
+        ) : canAnswer ? (
+          <>
+            {options.length > 0 && (
+              <div className="ask-user-options">
+                {options.map((option) => (
+                  <button
+                    key={option}
+                    className="btn-secondary"
+                    disabled={sending}
+                    onClick={() => send(option)}
+                  >
+                    {option}
+                  </button>
+                ))}
+              </div>
+            )}
+            <form
+              className="ask-user-form"
+              onSubmit={(e) => {
+                e.preventDefault();
+                send(answer);
+              }}
+            >
+              <input
+                type="text"
+                className="ask-user-input"
+                value={answer}
+                onChange={(e) => setAnswer(e.target.value)}
+                placeholder={options.length > 0 ? "Or type an answer..." : "Type your answer..."}
+                disabled={sending}
+                autoFocus
+              />
+              <button type="submit" className="btn-primary" disabled={sending || !answer.trim()}>
+                Answer
+              </button>
+            </form>
+            {error && <div className="tool-error">{error}</div>}
+          </>
+        ) : (
+          <div className="tool-label">Waiting for an answer...</div>
+        )}
+      </div>
+    </div>
+  );
+}
+
+export default AskUserTool;
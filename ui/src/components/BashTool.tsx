@@ -1,10 +1,12 @@
 import React, { useState, useRef, useLayoutEffect } from "react";
 import { LLMContent } from "../types";
+import { useToolOutput } from "../hooks/useToolOutput";
 import MarkdownRenderer from "./MarkdownRenderer";
 import { HighlightedCode } from "./HighlightedCode";
 
 interface BashToolProps {
   // For tool_use (pending state)
+  toolUseId?: string;
   toolInput?: unknown;
   isRunning?: boolean;
 
@@ -14,10 +16,27 @@ interface BashToolProps {
   executionTime?: string;
 }
 
-function BashTool({ toolInput, isRunning, toolResult, hasError, executionTime }: BashToolProps) {
+function BashTool({
+  toolUseId,
+  toolInput,
+  isRunning,
+  toolResult,
+  hasError,
+  executionTime,
+}: BashToolProps) {
   const [isExpanded, setIsExpanded] = useState(false);
   const [isTruncated, setIsTruncated] = useState(false);
   const commandRef = useRef<HTMLSpanElement>(null);
+  const liveOutputRef = useRef<HTMLPreElement>(null);
+
+  // Output streamed while the command runs
+  const liveOutput = useToolOutput(isRunning && toolResult === undefined ? toolUseId : undefined);
+  useLayoutEffect(() => {
+    const el = liveOutputRef.current;
+    if (el) {
+      el.scrollTop = el.scrollHeight;
+    }
+  }, [liveOutput]);
 
   // Extract command from toolInput
   const command =
@@ -140,6 +159,12 @@ function BashTool({ toolInput, isRunning, toolResult, hasError, executionTime }:
         </div>
       </div>
 
+      {liveOutput && (
+        <pre className="bash-tool-code bash-tool-live-output" ref={liveOutputRef}>
+          {liveOutput}
+        </pre>
+      )}
+
       {isExpanded && (
         <div className="bash-tool-details">
           {showFullCommand && (
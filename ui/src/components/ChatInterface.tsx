@@ -1,18 +1,19 @@
 import React, { useState, useEffect, useRef, useMemo, useCallback, useLayoutEffect } from "react";
 import { Virtualizer, VirtualizerHandle } from "virtua";
-import { Message, Conversation, StreamResponse, LLMContent, ToolCallData, MessageSegment } from "../types";
+import { Message, Conversation, StreamResponse, LLMContent, ToolCallData, MessageSegment, EnterBehavior, QueuedMessage } from "../types";
 import { api } from "../services/api";
 
 import { buildVSCodeFolderUrl } from "../services/vscode";
 import { VSCodeIcon } from "./icons/VSCodeIcon";
 import MessageComponent from "./Message";
-import MessageInput from "./MessageInput";
+import MessageInput, { SendOptions } from "./MessageInput";
 import { InputModal } from "./InputModal";
 import DiffViewer from "./DiffViewer";
 import BashTool from "./BashTool";
 import PatchTool from "./PatchTool";
 import ScreenshotTool from "./ScreenshotTool";
 import ThinkTool from "./ThinkTool";
+import AskUserTool from "./AskUserTool";
 import KeywordSearchTool from "./KeywordSearchTool";
 import BrowserNavigateTool from "./BrowserNavigateTool";
 import BrowserEvalTool from "./BrowserEvalTool";
@@ -20,12 +21,19 @@ import ReadImageTool from "./ReadImageTool";
 import BrowserConsoleLogsTool from "./BrowserConsoleLogsTool";
 import ChangeDirTool from "./ChangeDirTool";
 import BrowserResizeTool from "./BrowserResizeTool";
-import DeploySelfTool from "./DeploySelfTool";
+import DeployTool from "./DeployTool";
 import ToolGroup from "./ToolGroup";
+import { ToolOutputContext, MAX_LIVE_TOOL_OUTPUT } from "../hooks/useToolOutput";
+import { ConversationIdContext } from "../hooks/useConversationId";
 import DirectoryPickerModal from "./DirectoryPickerModal";
+import ShareModal from "./ShareModal";
 
 import { getContextBarColor, formatTokens } from "../utils/context";
 
+// How many messages a conversation loads at a time; earlier ones load as the
+// user scrolls up to them
+const MESSAGE_PAGE_SIZE = 100;
+
 interface ContextUsageBarProps {
   contextWindowSize: number;
   maxContextTokens: number;
@@ -160,7 +168,9 @@ const TOOL_COMPONENTS: Record<string, React.ComponentType<any>> = {
   patch: PatchTool,
   screenshot: ScreenshotTool,
   browser_take_screenshot: ScreenshotTool,
+  browser_visual_diff: ScreenshotTool,
   think: ThinkTool,
+  ask_user: AskUserTool,
   keyword_search: KeywordSearchTool,
   browser_navigate: BrowserNavigateTool,
   browser_eval: BrowserEvalTool,
@@ -169,7 +179,7 @@ const TOOL_COMPONENTS: Record<string, React.ComponentType<any>> = {
   browser_clear_console_logs: BrowserConsoleLogsTool,
   change_dir: ChangeDirTool,
   browser_resize: BrowserResizeTool,
-  deploy_self: DeploySelfTool,
+  deploy: DeployTool,
 };
 
 function CoalescedToolCall({
@@ -375,7 +385,12 @@ interface ChatInterfaceProps {
   currentConversation?: Conversation;
   onConversationUpdate?: (conversation: Conversation) => void;
   onConversationArchived?: (conversationId: string) => void;
-  onFirstMessage?: (message: string, model: string, cwd?: string) => Promise<void>;
+  onFirstMessage?: (
+    message: string,
+    model: string,
+    cwd?: string,
+    devcontainer?: boolean,
+  ) => Promise<void>;
   mostRecentCwd?: string | null;
   compact?: boolean;
   isFocused?: boolean;
@@ -405,10 +420,17 @@ function ChatInterface({
 }: ChatInterfaceProps) {
   const [messages, setMessages] = useState<Message[]>([]);
   const [pendingUserMessage, setPendingUserMessage] = useState<Message | null>(null);
+  const [toolOutputs, setToolOutputs] = useState<Record<string, string>>({});
   const [loading, setLoading] = useState(true);
+  const [hasEarlierMessages, setHasEarlierMessages] = useState(false);
+  const [loadingEarlier, setLoadingEarlier] = useState(false);
+  // Set while earlier messages are prepended, so the view stays in place
+  const [prepending, setPrepending] = useState(false);
   const [sending, setSending] = useState(false);
   const [error, setError] = useState<string | null>(null);
-  const models = window.__SHELLEY_INIT__?.models || [];
+  const [commandOutput, setCommandOutput] = useState<string | null>(null);
+  const [queuedMessages, setQueuedMessages] = useState<QueuedMessage[]>([]);
+  const [models, setModels] = useState(() => window.__SHELLEY_INIT__?.models || []);
   const [selectedModel, setSelectedModelState] = useState<string>(() => {
     // First check localStorage for a sticky model preference
     const storedModel = localStorage.getItem("shelley_selected_model");
@@ -476,19 +498,59 @@ function ChatInterface({
   }, [conversationId, mostRecentCwd]);
 
   const [cwdError, setCwdError] = useState<string | null>(null);
+  // Whether the selected cwd has a dev container configuration, and whether
+  // the new conversation should run its tools in that container
+  const [devcontainerFound, setDevcontainerFound] = useState(false);
+  const [useDevcontainer, setUseDevcontainer] = useState(false);
+
+  useEffect(() => {
+    setUseDevcontainer(false);
+    if (conversationId !== null || !selectedCwd) {
+      setDevcontainerFound(false);
+      return;
+    }
+    let cancelled = false;
+    api
+      .validateCwd(selectedCwd)
+      .then((result) => {
+        if (!cancelled) setDevcontainerFound(result.valid && !!result.devcontainer);
+      })
+      .catch(() => {
+        if (!cancelled) setDevcontainerFound(false);
+      });
+    return () => {
+      cancelled = true;
+    };
+  }, [conversationId, selectedCwd]);
 
   const [showDirectoryPicker, setShowDirectoryPicker] = useState(false);
   const [showDiffViewer, setShowDiffViewer] = useState(false);
+  const [showShareModal, setShowShareModal] = useState(false);
   const [indicatorMode, setIndicatorMode] = useState<"inline" | "block" | "hidden">("inline");
   const [expansionBehavior, setExpansionBehavior] = useState<"single" | "all">("single");
-  const [enterBehavior, setEnterBehavior] = useState<"send" | "stop_and_send">("send");
+  const [enterBehavior, setEnterBehavior] = useState<EnterBehavior>("send");
   const [diffViewerInitialCommit, setDiffViewerInitialCommit] = useState<string | undefined>(
     undefined,
   );
+  const [diffViewerInitialFile, setDiffViewerInitialFile] = useState<string | undefined>(
+    undefined,
+  );
   const [diffCommentText, setDiffCommentText] = useState("");
   const [agentWorking, setAgentWorking] = useState(false);
   const [mobileInputVisible, setMobileInputVisible] = useState(false);
   
+  // Open the diff viewer on the file the URL names, as the view_url of
+  // /api/conversations/<id>/files does: /c/<id>?diff=conversation&file=<path>
+  useEffect(() => {
+    const params = new URLSearchParams(window.location.search);
+    const diff = params.get("diff");
+    if (!conversationId || !diff || window.location.pathname !== `/c/${conversationId}`) return;
+    setDiffViewerInitialCommit(diff);
+    setDiffViewerInitialFile(params.get("file") ?? undefined);
+    setShowDiffViewer(true);
+    window.history.replaceState({}, "", `/c/${conversationId}`);
+  }, [conversationId]);
+
   // Close modal when focus changes to another pane
   useEffect(() => {
     if (compact && !isFocused) {
@@ -596,10 +658,35 @@ function ChatInterface({
   const eventSourceRef = useRef<EventSource | null>(null);
   const reconnectTimeoutRef = useRef<number | null>(null);
 
+  // A conversation's own empty event list mutes its notifications
+  const notificationsMuted = currentConversation?.notify_events === "[]";
+  const toggleNotifications = async () => {
+    if (!conversationId) return;
+    try {
+      await api.setConversationNotifications(conversationId, notificationsMuted ? null : []);
+    } catch (err) {
+      console.error("Failed to update notifications:", err);
+    }
+  };
+
+  // Refresh the models' provider health, which the server checks every few minutes
+  useEffect(() => {
+    const refresh = () => {
+      api
+        .getModels()
+        .then((list) => {
+          if (list.length > 0) setModels(list);
+        })
+        .catch((err) => console.error("Failed to load models:", err));
+    };
+    const interval = setInterval(refresh, 60000);
+    return () => clearInterval(interval);
+  }, []);
+
   // Load settings on mount and when settings modal closes
   const loadSettings = async () => {
     try {
-      const settings = await api.getSettings();
+      const settings = await api.getUserSettings();
       setIndicatorMode(settings.ui?.indicatorMode ?? "inline");
       setExpansionBehavior(settings.ui?.expansionBehavior ?? "single");
       setEnterBehavior(settings.ui?.enterBehavior ?? "send");
@@ -620,6 +707,8 @@ function ChatInterface({
   useEffect(() => {
     // Clear pending user message when conversation changes
     setPendingUserMessage(null);
+    setToolOutputs({});
+    setQueuedMessages([]);
 
     if (conversationId) {
       setAgentWorking(false);
@@ -628,6 +717,7 @@ function ChatInterface({
     } else {
       // No conversation yet, show empty state
       setMessages([]);
+      setHasEarlierMessages(false);
       setContextWindowSize(0);
       setLoading(false);
     }
@@ -649,8 +739,9 @@ function ChatInterface({
     try {
       setLoading(true);
       setError(null);
-      const response = await api.getConversation(conversationId);
+      const response = await api.getConversation(conversationId, { limit: MESSAGE_PAGE_SIZE });
       setMessages(response.messages ?? []);
+      setHasEarlierMessages(Boolean(response.has_more));
       setAgentWorking(Boolean(response.agent_working));
       // Always update context window size when loading a conversation.
       // If omitted from response (due to omitempty when 0), default to 0.
@@ -671,6 +762,32 @@ function ChatInterface({
     }
   };
 
+  const loadEarlierMessages = async () => {
+    if (!conversationId || loadingEarlier || !hasEarlierMessages || messages.length === 0) return;
+    try {
+      setLoadingEarlier(true);
+      const response = await api.getConversation(conversationId, {
+        limit: MESSAGE_PAGE_SIZE,
+        before: messages[0].message_id,
+      });
+      const earlier = response.messages ?? [];
+      setPrepending(true);
+      setMessages((prev) => {
+        const known = new Set(prev.map((m) => m.message_id));
+        return [...earlier.filter((m) => !known.has(m.message_id)), ...prev];
+      });
+      setHasEarlierMessages(Boolean(response.has_more));
+    } catch (err) {
+      console.error("Failed to load earlier messages:", err);
+    } finally {
+      setLoadingEarlier(false);
+    }
+  };
+
+  useLayoutEffect(() => {
+    if (prepending) setPrepending(false);
+  }, [messages]);
+
   const setupMessageStream = () => {
     if (!conversationId) return;
 
@@ -678,12 +795,31 @@ function ChatInterface({
       eventSourceRef.current.close();
     }
 
-    const eventSource = api.createMessageStream(conversationId);
+    const eventSource = api.createMessageStream(conversationId, MESSAGE_PAGE_SIZE);
     eventSourceRef.current = eventSource;
 
     eventSource.onmessage = (event) => {
       try {
         const streamResponse: StreamResponse = JSON.parse(event.data);
+
+        // Output of a running tool carries nothing else
+        if (streamResponse.tool_output) {
+          const { tool_use_id, output, replace } = streamResponse.tool_output;
+          setToolOutputs((prev) => ({
+            ...prev,
+            [tool_use_id]: ((replace ? "" : (prev[tool_use_id] ?? "")) + output).slice(
+              -MAX_LIVE_TOOL_OUTPUT,
+            ),
+          }));
+          return;
+        }
+
+        // So do updates of the queued messages
+        if (streamResponse.queue) {
+          setQueuedMessages(streamResponse.queue.messages);
+          return;
+        }
+
         const incomingMessages = Array.isArray(streamResponse.messages)
           ? streamResponse.messages
           : [];
@@ -784,7 +920,7 @@ function ChatInterface({
       // Don't call loadMessages() here - it would race with SSE messages
       // and potentially overwrite newer messages with an older snapshot
       if (conversationId) {
-        api.getConversation(conversationId).then((response) => {
+        api.getConversation(conversationId, { limit: MESSAGE_PAGE_SIZE }).then((response) => {
           setAgentWorking(Boolean(response.agent_working));
           if (response.conversation.model_id) {
             setSelectedModelState(response.conversation.model_id);
@@ -794,7 +930,7 @@ function ChatInterface({
     };
   };
 
-  const sendMessage = async (message: string) => {
+  const sendMessage = async (message: string, options?: SendOptions) => {
     if (!message.trim()) return;
     if (sending) {
       throw new Error("Already sending");
@@ -836,12 +972,31 @@ function ChatInterface({
             throw new Error(`Invalid working directory: ${validation.error}`);
           }
         }
-        await onFirstMessage(message.trim(), selectedModel, selectedCwd || undefined);
+        await onFirstMessage(
+          message.trim(),
+          selectedModel,
+          selectedCwd || undefined,
+          devcontainerFound && useDevcontainer,
+        );
       } else if (conversationId) {
-        await api.sendMessage(conversationId, {
+        const response = await api.sendMessage(conversationId, {
           message: message.trim(),
           model: selectedModel,
+          queue: options?.queue,
         });
+        setCommandOutput(response.status === "command" ? response.output : null);
+        if (response.status === "command") {
+          // Commands run on the server and don't start a turn
+          setPendingUserMessage(null);
+          setAgentWorking(false);
+          if (response.model) {
+            setSelectedModel(response.model);
+          }
+        } else if (response.status === "queued") {
+          // Shown in the queue until the turn ends; the agent is still working
+          setPendingUserMessage(null);
+          setQueuedMessages(response.queue.messages);
+        }
       }
     } catch (err) {
       console.error("Failed to send message:", err);
@@ -855,6 +1010,42 @@ function ChatInterface({
     }
   };
 
+  // sendVoice sends a recording, which the server transcribes into the message
+  const sendVoice = async (audio: Blob, options?: SendOptions) => {
+    if (!conversationId) return;
+    if (sending) {
+      throw new Error("Already sending");
+    }
+    if (isDisconnected) {
+      handleManualReconnect();
+    }
+    shouldStickToBottom.current = true;
+
+    try {
+      setSending(true);
+      setError(null);
+      const response = await api.sendVoice(conversationId, audio, {
+        model: selectedModel,
+        queue: options?.queue,
+      });
+      setCommandOutput(null);
+      if (response.status === "queued") {
+        setQueuedMessages(response.queue.messages);
+      } else {
+        setAgentWorking(true);
+      }
+    } catch (err) {
+      console.error("Failed to send voice message:", err);
+      setError(err instanceof Error ? err.message : "Unknown error");
+      throw err;
+    } finally {
+      setSending(false);
+    }
+  };
+
+  // Voice messages need the server to transcribe them and a conversation to go to
+  const voiceHandler = conversationId && window.__SHELLEY_INIT__?.voice_input ? sendVoice : undefined;
+
   // scrollToBottom is defined after coalescedItems
 
   const handleManualReconnect = () => {
@@ -903,6 +1094,8 @@ function ChatInterface({
           if (enterBehavior === 'stop_and_send') {
             await handleCancel();
             await sendMessage(msg);
+          } else if (enterBehavior === 'queue') {
+            await sendMessage(msg, { queue: true });
           }
           // If enterBehavior is 'send', do nothing while agent is working
           return;
@@ -1514,6 +1707,57 @@ function ChatInterface({
             </svg>
           </button>
 
+          {/* Share button */}
+          {conversationId && (
+            <button
+              onClick={() => setShowShareModal(true)}
+              className="btn-tool-toggle"
+              title="Share read-only link"
+              aria-label="Share read-only link"
+            >
+              <svg
+                fill="none"
+                stroke="currentColor"
+                viewBox="0 0 24 24"
+                style={{ width: "1rem", height: "1rem" }}
+              >
+                <path
+                  strokeLinecap="round"
+                  strokeLinejoin="round"
+                  strokeWidth={2}
+                  d="M13.828 10.172a4 4 0 00-5.656 0l-4 4a4 4 0 105.656 5.656l1.102-1.101m-.758-4.899a4 4 0 005.656 0l4-4a4 4 0 00-5.656-5.656l-1.1 1.1"
+                />
+              </svg>
+            </button>
+          )}
+
+          {/* Notifications button - mutes notifications for this conversation */}
+          {conversationId && (
+            <button
+              onClick={toggleNotifications}
+              className="btn-tool-toggle"
+              title={notificationsMuted ? "Unmute notifications" : "Mute notifications"}
+              aria-label={notificationsMuted ? "Unmute notifications" : "Mute notifications"}
+            >
+              <svg
+                fill="none"
+                stroke="currentColor"
+                viewBox="0 0 24 24"
+                style={{ width: "1rem", height: "1rem" }}
+              >
+                <path
+                  strokeLinecap="round"
+                  strokeLinejoin="round"
+                  strokeWidth={2}
+                  d="M15 17h5l-1.405-1.405A2.032 2.032 0 0118 14.158V11a6.002 6.002 0 00-4-5.659V5a2 2 0 10-4 0v.341C7.67 6.165 6 8.388 6 11v3.159c0 .538-.214 1.055-.595 1.436L4 17h5m6 0v1a3 3 0 11-6 0v-1m6 0H9"
+                />
+                {notificationsMuted && (
+                  <path strokeLinecap="round" strokeLinejoin="round" strokeWidth={2} d="M4 4l16 16" />
+                )}
+              </svg>
+            </button>
+          )}
+
           {/* Diffs button - show when we have a CWD */}
           {/* Diffs button */}
           {(currentConversation?.cwd || selectedCwd) && (
@@ -1559,22 +1803,37 @@ function ChatInterface({
             }}
           >
             <div style={{ flexGrow: 1 }} />
-            <Virtualizer
-              ref={virtualizerRef}
-              onScroll={(offset) => {
-                if (!virtualizerRef.current) return;
-                const atBottom =
-                  offset - virtualizerRef.current.scrollSize + virtualizerRef.current.viewportSize >= -1.5;
-                shouldStickToBottom.current = atBottom;
-                setShowScrollToBottom((prev) => (prev === !atBottom ? prev : !atBottom));
-              }}
-            >
-              {coalescedItems.map((item, index) => (
-                <div key={computeItemKey(index, item)}>
-                  {renderItem(index, item)}
-                </div>
-              ))}
-            </Virtualizer>
+            <ConversationIdContext.Provider value={conversationId}>
+            <ToolOutputContext.Provider value={toolOutputs}>
+              {hasEarlierMessages && (
+                <button
+                  className="load-earlier-button"
+                  onClick={loadEarlierMessages}
+                  disabled={loadingEarlier}
+                >
+                  {loadingEarlier ? "Loading earlier messages…" : "Load earlier messages"}
+                </button>
+              )}
+              <Virtualizer
+                ref={virtualizerRef}
+                shift={prepending}
+                onScroll={(offset) => {
+                  if (!virtualizerRef.current) return;
+                  if (offset < 200) loadEarlierMessages();
+                  const atBottom =
+                    offset - virtualizerRef.current.scrollSize + virtualizerRef.current.viewportSize >= -1.5;
+                  shouldStickToBottom.current = atBottom;
+                  setShowScrollToBottom((prev) => (prev === !atBottom ? prev : !atBottom));
+                }}
+              >
+                {coalescedItems.map((item, index) => (
+                  <div key={computeItemKey(index, item)}>
+                    {renderItem(index, item)}
+                  </div>
+                ))}
+              </Virtualizer>
+            </ToolOutputContext.Provider>
+            </ConversationIdContext.Provider>
           </div>
         )}
 
@@ -1602,6 +1861,43 @@ function ChatInterface({
         )}
       </div>
 
+      {/* Messages waiting for the agent's turn to end */}
+      {conversationId && queuedMessages.length > 0 && (
+        <div className="queued-messages" data-testid="queued-messages">
+          {queuedMessages.map((queued) => (
+            <div
+              key={queued.id}
+              className={queued.error ? "queued-message queued-message-failed" : "queued-message"}
+              title={queued.error}
+            >
+              <span className="queued-message-label">{queued.error ? "Not sent" : "Queued"}</span>
+              <span className="queued-message-text">{queued.message}</span>
+              <button
+                className="queued-message-remove"
+                onClick={() => api.removeQueuedMessage(conversationId, queued.id).catch((err) => setError(err.message))}
+                aria-label="Remove queued message"
+              >
+                ×
+              </button>
+            </div>
+          ))}
+        </div>
+      )}
+
+      {/* Output of the last slash command */}
+      {conversationId && commandOutput && (
+        <div className="command-output" data-testid="command-output">
+          <pre>{commandOutput}</pre>
+          <button
+            className="command-output-close"
+            onClick={() => setCommandOutput(null)}
+            aria-label="Dismiss"
+          >
+            ×
+          </button>
+        </div>
+      )}
+
       {/* Status Bar - only shown for new conversations (model/cwd selection) */}
       {!conversationId && (
         <div className="status-bar">
@@ -1641,11 +1937,19 @@ function ChatInterface({
                   disabled={sending}
                   className="status-select"
                 >
-                  {models.map((model) => (
-                    <option key={model.id} value={model.id} disabled={!model.ready}>
-                      {model.id} {!model.ready ? "(not ready)" : ""}
-                    </option>
-                  ))}
+                  {models.map((model) => {
+                    const failing = model.health?.available === false;
+                    return (
+                      <option
+                        key={model.id}
+                        value={model.id}
+                        disabled={!model.ready || failing}
+                        title={failing ? `${model.provider} is failing: ${model.health?.error}` : undefined}
+                      >
+                        {model.id} {!model.ready ? "(not ready)" : failing ? "(unavailable)" : ""}
+                      </option>
+                    );
+                  })}
                 </select>
               </div>
 
@@ -1667,6 +1971,23 @@ function ChatInterface({
                   {selectedCwd || "(no cwd)"}
                 </button>
               </div>
+
+              {/* Offer the cwd's dev container */}
+              {devcontainerFound && (
+                <label
+                  className="status-field status-field-devcontainer"
+                  title="Build and start the project's dev container and run the tools inside it"
+                  onClick={(e) => e.stopPropagation()}
+                >
+                  <input
+                    type="checkbox"
+                    checked={useDevcontainer}
+                    onChange={(e) => setUseDevcontainer(e.target.checked)}
+                    disabled={sending}
+                  />
+                  <span className="status-field-label">Dev container</span>
+                </label>
+              )}
             </div>
           </div>
         </div>
@@ -1678,8 +1999,8 @@ function ChatInterface({
         <InputModal
           isOpen={mobileInputVisible}
           onClose={() => setMobileInputVisible(false)}
-          onSend={async (msg) => {
-            await sendMessage(msg);
+          onSend={async (msg, options) => {
+            await sendMessage(msg, options);
           }}
           sending={sending}
           agentWorking={agentWorking}
@@ -1687,13 +2008,14 @@ function ChatInterface({
           conversationTitle={currentConversation?.slug || undefined}
           enterBehavior={enterBehavior}
           persistKey={conversationId || "new-conversation"}
+          onSendVoice={voiceHandler}
         />
       ) : (
         // Normal mode: inline input
         <MessageInput
           key={conversationId || "new"}
-          onSend={async (msg) => {
-            await sendMessage(msg);
+          onSend={async (msg, options) => {
+            await sendMessage(msg, options);
           }}
           disabled={loading}
           autoFocus={true}
@@ -1704,6 +2026,7 @@ function ChatInterface({
           agentWorking={agentWorking}
           onCancel={handleCancel}
           enterBehavior={enterBehavior}
+          onSendVoice={voiceHandler}
         />
       )}
 
@@ -1725,10 +2048,22 @@ function ChatInterface({
         onClose={() => {
           setShowDiffViewer(false);
           setDiffViewerInitialCommit(undefined);
+          setDiffViewerInitialFile(undefined);
         }}
         onCommentTextChange={setDiffCommentText}
         initialCommit={diffViewerInitialCommit}
+        initialFile={diffViewerInitialFile}
+        conversationId={conversationId ?? undefined}
       />
+
+      {/* Share links */}
+      {conversationId && (
+        <ShareModal
+          isOpen={showShareModal}
+          onClose={() => setShowShareModal(false)}
+          conversationId={conversationId}
+        />
+      )}
     </div>
   );
 }
@@ -1,5 +1,5 @@
 import React, { useState, useEffect, useMemo, useRef } from "react";
-import { Conversation } from "../types";
+import { Conversation, Project } from "../types";
 import { api } from "../services/api";
 import { getContextBarColor, formatTokens } from "../utils/context";
 import { GridSelector } from "./ColumnSelector";
@@ -25,6 +25,7 @@ function extractRepoName(gitOrigin: string | null): string | null {
 
 interface GroupedConversations {
   repoName: string | null;
+  pinned?: boolean;
   conversations: Conversation[];
 }
 
@@ -40,6 +41,7 @@ interface ConversationDrawerProps {
   onConversationArchived?: (id: string) => void;
   onConversationUnarchived?: (conversation: Conversation) => void;
   onConversationRenamed?: (conversation: Conversation) => void;
+  onConversationPinned?: (conversation: Conversation) => void;
   columnCount: number;
   rowCount: number;
   onColumnCountChange: (count: number) => void;
@@ -58,6 +60,7 @@ function ConversationDrawer({
   onConversationArchived,
   onConversationUnarchived,
   onConversationRenamed,
+  onConversationPinned,
   columnCount,
   rowCount,
   onColumnCountChange,
@@ -74,8 +77,18 @@ function ConversationDrawer({
   const [loadingArchived, setLoadingArchived] = useState(false);
   const [editingId, setEditingId] = useState<string | null>(null);
   const [editingSlug, setEditingSlug] = useState("");
+  const [regeneratingId, setRegeneratingId] = useState<string | null>(null);
   const renameInputRef = React.useRef<HTMLInputElement>(null);
   const drawerBodyRef = useRef<HTMLDivElement>(null);
+  const [projects, setProjects] = useState<Project[]>([]);
+
+  useEffect(() => {
+    if (!isOpen) return;
+    api
+      .getProjects()
+      .then(setProjects)
+      .catch((err) => console.error("Failed to load projects:", err));
+  }, [isOpen]);
 
   useEffect(() => {
     if (showArchived && archivedConversations.length === 0) {
@@ -158,6 +171,29 @@ function ConversationDrawer({
     }
   };
 
+  const handleTogglePin = async (e: React.MouseEvent, conversation: Conversation) => {
+    e.stopPropagation();
+    try {
+      const updated = await api.pinConversation(conversation.conversation_id, !conversation.pinned);
+      onConversationPinned?.(updated);
+    } catch (err) {
+      console.error("Failed to pin conversation:", err);
+    }
+  };
+
+  const handleRegenerateSlug = async (e: React.MouseEvent, conversationId: string) => {
+    e.stopPropagation();
+    setRegeneratingId(conversationId);
+    try {
+      const conversation = await api.regenerateSlug(conversationId);
+      onConversationRenamed?.(conversation);
+    } catch (err) {
+      console.error("Failed to regenerate title:", err);
+    } finally {
+      setRegeneratingId(null);
+    }
+  };
+
   const handleDelete = async (e: React.MouseEvent, conversationId: string) => {
     e.stopPropagation();
     if (!confirm("Are you sure you want to permanently delete this conversation?")) {
@@ -228,12 +264,19 @@ function ConversationDrawer({
 
   const displayedConversations = showArchived ? archivedConversations : conversations;
 
-  // Group conversations by repository name
+  // Group conversations by project, or by repository name outside projects
   const groupedConversations = useMemo((): GroupedConversations[] => {
     const groups = new Map<string | null, Conversation[]>();
+    const pinned: Conversation[] = [];
+    const projectNames = new Map(projects.map((p) => [p.project_id, p.name]));
     
     for (const conv of displayedConversations) {
-      const repoName = extractRepoName(conv.git_origin);
+      if (conv.pinned && !showArchived) {
+        pinned.push(conv);
+        continue;
+      }
+      const repoName =
+        (conv.project_id && projectNames.get(conv.project_id)) || extractRepoName(conv.git_origin);
       const existing = groups.get(repoName) || [];
       existing.push(conv);
       groups.set(repoName, existing);
@@ -256,12 +299,17 @@ function ConversationDrawer({
       const bLatest = b.conversations[0]?.created_at || '';
       return bLatest.localeCompare(aLatest);
     });
+
+    // Pinned conversations go above every repository group
+    if (pinned.length > 0) {
+      result.unshift({ repoName: null, pinned: true, conversations: pinned });
+    }
     
     return result;
-  }, [displayedConversations]);
+  }, [displayedConversations, showArchived, projects]);
 
   // Scroll to top when the first group changes (most recent conversation updated)
-  const firstGroupName = groupedConversations[0]?.repoName;
+  const firstGroupName = groupedConversations.find((g) => !g.pinned)?.repoName;
   useEffect(() => {
     if (drawerBodyRef.current) {
       drawerBodyRef.current.scrollTop = 0;
@@ -430,6 +478,26 @@ function ConversationDrawer({
             </>
           ) : (
             <>
+              <button
+                onClick={(e) => handleTogglePin(e, conversation)}
+                className={`btn-icon-sm ${conversation.pinned ? "pinned" : ""}`}
+                title={conversation.pinned ? "Unpin" : "Pin"}
+                aria-label={conversation.pinned ? "Unpin conversation" : "Pin conversation"}
+              >
+                <svg
+                  fill={conversation.pinned ? "currentColor" : "none"}
+                  stroke="currentColor"
+                  viewBox="0 0 24 24"
+                  style={{ width: "1rem", height: "1rem" }}
+                >
+                  <path
+                    strokeLinecap="round"
+                    strokeLinejoin="round"
+                    strokeWidth={2}
+                    d="M5 5a2 2 0 012-2h10a2 2 0 012 2v16l-7-3.5L5 21V5z"
+                  />
+                </svg>
+              </button>
               <button
                 onClick={(e) => handleStartRename(e, conversation)}
                 className="btn-icon-sm"
@@ -450,6 +518,28 @@ function ConversationDrawer({
                   />
                 </svg>
               </button>
+              <button
+                onClick={(e) => handleRegenerateSlug(e, conversation.conversation_id)}
+                className="btn-icon-sm"
+                title="Regenerate title from conversation"
+                aria-label="Regenerate title"
+                disabled={regeneratingId === conversation.conversation_id}
+              >
+                <svg
+                  fill="none"
+                  stroke="currentColor"
+                  viewBox="0 0 24 24"
+                  style={{ width: "1rem", height: "1rem" }}
+                  className={regeneratingId === conversation.conversation_id ? "icon-spin" : undefined}
+                >
+                  <path
+                    strokeLinecap="round"
+                    strokeLinejoin="round"
+                    strokeWidth={2}
+                    d="M4 4v5h.582m15.356 2A8.001 8.001 0 004.582 9m0 0H9m11 11v-5h-.581m0 0a8.003 8.003 0 01-15.357-2m15.357 2H15"
+                  />
+                </svg>
+              </button>
               <button
                 onClick={(e) => handleArchive(e, conversation.conversation_id)}
                 className="btn-icon-sm"
@@ -599,9 +689,12 @@ function ConversationDrawer({
           ) : (
             <div className="conversation-list">
               {groupedConversations.map((group) => (
-                <div key={group.repoName || "__no_repo__"} className="conversation-group">
+                <div
+                  key={group.pinned ? "__pinned__" : group.repoName || "__no_repo__"}
+                  className={`conversation-group ${group.pinned ? "pinned" : ""}`}
+                >
                   <div className="conversation-group-header">
-                    {group.repoName || "other"}
+                    {group.pinned ? "pinned" : group.repoName || "other"}
                   </div>
                   {group.conversations.map(renderConversationItem)}
                 </div>
@@ -1,9 +1,12 @@
 import React, { useState } from "react";
 import { LLMContent } from "../types";
+import { api } from "../services/api";
+import { useConversationId } from "../hooks/useConversationId";
 
-interface DeploySelfToolProps {
+interface DeployToolProps {
   // For tool_use (pending state)
-  toolInput?: unknown;
+  toolUseId?: string;
+  toolInput?: unknown; // { target: string }
   isRunning?: boolean;
 
   // For tool_result (completed state)
@@ -12,22 +15,25 @@ interface DeploySelfToolProps {
   executionTime?: string;
 }
 
-function DeploySelfTool({
+function DeployTool({
+  toolUseId,
   toolInput,
   isRunning,
   toolResult,
   hasError,
   executionTime,
-}: DeploySelfToolProps) {
+}: DeployToolProps) {
+  const conversationId = useConversationId();
   const [isExpanded, setIsExpanded] = useState(false);
+  const [sending, setSending] = useState(false);
+  const [error, setError] = useState<string | null>(null);
 
-  // Extract source_binary from toolInput
-  const sourceBinary =
+  const target =
     typeof toolInput === "object" &&
     toolInput !== null &&
-    "source_binary" in toolInput &&
-    typeof (toolInput as { source_binary: unknown }).source_binary === "string"
-      ? (toolInput as { source_binary: string }).source_binary
+    "target" in toolInput &&
+    typeof (toolInput as { target: unknown }).target === "string"
+      ? (toolInput as { target: string }).target
       : "";
 
   // Extract output from toolResult
@@ -35,6 +41,20 @@ function DeploySelfTool({
     toolResult && toolResult.length > 0 && toolResult[0].Text ? toolResult[0].Text : "";
 
   const isComplete = !isRunning && toolResult !== undefined;
+  const canConfirm = !isComplete && Boolean(conversationId && toolUseId);
+
+  // Deploys to production targets wait for the user's answer
+  const answer = async (text: string) => {
+    if (!conversationId || !toolUseId) return;
+    setSending(true);
+    setError(null);
+    try {
+      await api.answerQuestion(conversationId, toolUseId, text);
+    } catch (err) {
+      setError(err instanceof Error ? err.message : "Failed to send answer");
+      setSending(false);
+    }
+  };
 
   return (
     <div
@@ -69,7 +89,7 @@ function DeploySelfTool({
             </svg>
           </button>
           <span className={`bash-tool-emoji ${isRunning ? "running" : ""}`}>🚀</span>
-          <span className="bash-tool-command">deploy_self</span>
+          <span className="bash-tool-command">deploy {target}</span>
         </div>
         <div className="bash-tool-header-right">
           {isComplete && (
@@ -87,6 +107,18 @@ function DeploySelfTool({
         </div>
       </div>
 
+      {canConfirm && (
+        <div className="ask-user-options">
+          <button className="btn-primary" disabled={sending} onClick={() => answer("Deploy")}>
+            Confirm production deploy
+          </button>
+          <button className="btn-secondary" disabled={sending} onClick={() => answer("Cancel")}>
+            Cancel
+          </button>
+          {error && <div className="tool-error">{error}</div>}
+        </div>
+      )}
+
       {isExpanded && isComplete && (
         <div className="bash-tool-details">
           <div className="bash-tool-section">
@@ -100,4 +132,4 @@ function DeploySelfTool({
   );
 }
 
-export default DeploySelfTool;
+export default DeployTool;
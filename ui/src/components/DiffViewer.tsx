@@ -2,7 +2,7 @@ import React, { useState, useEffect, useCallback, useRef } from "react";
 import type * as Monaco from "monaco-editor";
 import { api } from "../services/api";
 import { isDarkModeActive } from "../services/theme";
-import { GitDiffInfo, GitFileInfo, GitFileDiff } from "../types";
+import { GitDiffInfo, GitFileInfo, GitFileDiff, FileCoverage } from "../types";
 
 interface DiffViewerProps {
   cwd: string;
@@ -10,6 +10,8 @@ interface DiffViewerProps {
   onClose: () => void;
   onCommentTextChange: (text: string) => void;
   initialCommit?: string; // If set, select this commit when opening
+  initialFile?: string; // If set, select this file (absolute or repository-relative) when opening
+  conversationId?: string; // If set, edits are recorded under this conversation
 }
 
 // Icon components for cleaner JSX
@@ -79,7 +81,18 @@ function loadMonaco(): Promise<typeof Monaco> {
 
 type ViewMode = "comment" | "edit";
 
-function DiffViewer({ cwd, isOpen, onClose, onCommentTextChange, initialCommit }: DiffViewerProps) {
+// Pseudo diff ID for the files changed over the whole conversation
+const CONVERSATION_DIFF_ID = "conversation";
+
+function DiffViewer({
+  cwd,
+  isOpen,
+  onClose,
+  onCommentTextChange,
+  initialCommit,
+  initialFile,
+  conversationId,
+}: DiffViewerProps) {
   const [diffs, setDiffs] = useState<GitDiffInfo[]>([]);
   const [gitRoot, setGitRoot] = useState<string | null>(null);
   const [selectedDiff, setSelectedDiff] = useState<string | null>(null);
@@ -90,7 +103,12 @@ function DiffViewer({ cwd, isOpen, onClose, onCommentTextChange, initialCommit }
   const [error, setError] = useState<string | null>(null);
   const [monacoLoaded, setMonacoLoaded] = useState(false);
   const [currentChangeIndex, setCurrentChangeIndex] = useState<number>(-1);
-  const [saveStatus, setSaveStatus] = useState<"idle" | "saving" | "saved" | "error">("idle");
+  const [saveStatus, setSaveStatus] = useState<
+    "idle" | "saving" | "saved" | "error" | "conflict"
+  >("idle");
+  // Hash of the file's content as last loaded or saved, so saving doesn't
+  // overwrite changes made on disk in the meantime
+  const baseHashRef = useRef<string | undefined>(undefined);
   const saveTimeoutRef = useRef<number | null>(null);
   const pendingSaveRef = useRef<(() => Promise<void>) | null>(null);
   const scheduleSaveRef = useRef<(() => void) | null>(null);
@@ -106,6 +124,10 @@ function DiffViewer({ cwd, isOpen, onClose, onCommentTextChange, initialCommit }
   const [mode, setMode] = useState<ViewMode>("comment");
   const [showKeyboardHint, setShowKeyboardHint] = useState(false);
   const hasShownKeyboardHint = useRef(false);
+  // Coverage overlay: "running" while go test runs, "on" once there is a
+  // profile to show for the selected file
+  const [coverageMode, setCoverageMode] = useState<"off" | "running" | "on">("off");
+  const [coverage, setCoverage] = useState<FileCoverage | null>(null);
 
   const [isMobile, setIsMobile] = useState(window.innerWidth < 768);
   const editorContainerRef = useRef<HTMLDivElement>(null);
@@ -363,6 +385,57 @@ function DiffViewer({ cwd, isOpen, onClose, onCommentTextChange, initialCommit }
     };
   }, [monacoLoaded, fileDiff, isMobile]);
 
+  // Load the selected file's coverage while the overlay is on
+  useEffect(() => {
+    setCoverage(null);
+    if (coverageMode !== "on" || !selectedFile) return;
+    let cancelled = false;
+    api
+      .getFileCoverage(cwd, selectedFile)
+      .then((fc) => !cancelled && setCoverage(fc))
+      .catch(() => !cancelled && setCoverage(null));
+    return () => {
+      cancelled = true;
+    };
+  }, [coverageMode, selectedFile, cwd]);
+
+  // Mark covered, uncovered and partly covered lines in the gutter of the new version
+  useEffect(() => {
+    const editor = editorRef.current?.getModifiedEditor();
+    const monaco = monacoRef.current;
+    if (!coverage || !editor || !monaco || coverage.path !== fileDiff?.path) return;
+    const decorate = (lines: number[], className: string) =>
+      lines.map((lineNumber) => ({
+        range: new monaco.Range(lineNumber, 1, lineNumber, 1),
+        options: { isWholeLine: true, linesDecorationsClassName: className },
+      }));
+    const decorations = editor.createDecorationsCollection([
+      ...decorate(coverage.coveredLines, "diff-viewer-coverage-covered"),
+      ...decorate(coverage.uncoveredLines, "diff-viewer-coverage-uncovered"),
+      ...decorate(coverage.partialLines, "diff-viewer-coverage-partial"),
+    ]);
+    return () => decorations.clear();
+  }, [coverage, fileDiff, monacoLoaded, isMobile]);
+
+  const toggleCoverage = async () => {
+    if (coverageMode !== "off") {
+      setCoverageMode("off");
+      return;
+    }
+    try {
+      setCoverageMode("running");
+      setError(null);
+      const report = await api.runCoverage(cwd);
+      setCoverageMode("on");
+      if (!report.passed) {
+        setError("Some tests failed; coverage is from the tests that ran.");
+      }
+    } catch (err) {
+      setCoverageMode("off");
+      setError(`Failed to run coverage: ${err}`);
+    }
+  };
+
   const loadDiffs = async () => {
     try {
       setLoading(true);
@@ -372,6 +445,10 @@ function DiffViewer({ cwd, isOpen, onClose, onCommentTextChange, initialCommit }
       setGitRoot(response.gitRoot);
 
       // If initialCommit is set, try to select that commit
+      if (initialCommit === CONVERSATION_DIFF_ID && conversationId) {
+        setSelectedDiff(CONVERSATION_DIFF_ID);
+        return;
+      }
       if (initialCommit) {
         const matchingDiff = response.diffs.find(
           (d) => d.id === initialCommit || d.id.startsWith(initialCommit),
@@ -403,13 +480,28 @@ function DiffViewer({ cwd, isOpen, onClose, onCommentTextChange, initialCommit }
     }
   };
 
+  // Conversation changes have absolute paths; show them relative to the
+  // repository like the git diffs, so edit mode can rebuild the full path.
+  const toRepoPath = (path: string) =>
+    gitRoot && path.startsWith(gitRoot + "/") ? path.slice(gitRoot.length + 1) : path;
+  const toFullPath = (path: string) => (path.startsWith("/") ? path : gitRoot + "/" + path);
+
   const loadFiles = async (diffId: string) => {
     try {
       setLoading(true);
       setError(null);
-      const filesData = await api.getGitDiffFiles(diffId, cwd);
+      const filesData =
+        diffId === CONVERSATION_DIFF_ID && conversationId
+          ? (await api.getConversationChanges(conversationId)).map((change) => ({
+              ...change,
+              path: toRepoPath(change.path),
+            }))
+          : await api.getGitDiffFiles(diffId, cwd);
       setFiles(filesData || []);
-      if (filesData && filesData.length > 0) {
+      const initial = initialFile && filesData?.find((f) => f.path === toRepoPath(initialFile));
+      if (initial) {
+        setSelectedFile(initial.path);
+      } else if (filesData && filesData.length > 0) {
         setSelectedFile(filesData[0].path);
       } else {
         setSelectedFile(null);
@@ -426,7 +518,14 @@ function DiffViewer({ cwd, isOpen, onClose, onCommentTextChange, initialCommit }
     try {
       setLoading(true);
       setError(null);
-      const diffData = await api.getGitFileDiff(diffId, filePath, cwd);
+      const diffData =
+        diffId === CONVERSATION_DIFF_ID && conversationId
+          ? {
+              ...(await api.getConversationFileChange(conversationId, toFullPath(filePath))),
+              path: filePath,
+            }
+          : await api.getGitFileDiff(diffId, filePath, cwd);
+      baseHashRef.current = diffData.newSha256;
       setFileDiff(diffData);
     } catch (err) {
       setError(`Failed to load file diff: ${err}`);
@@ -564,19 +663,29 @@ function DiffViewer({ cwd, isOpen, onClose, onCommentTextChange, initialCommit }
     if (!model) return;
 
     const content = model.getValue();
-    const fullPath = gitRoot + "/" + selectedFile;
+    const fullPath = selectedFile.startsWith("/") ? selectedFile : gitRoot + "/" + selectedFile;
 
     try {
       setSaveStatus("saving");
       const response = await fetch("/api/write-file", {
         method: "POST",
         headers: { "Content-Type": "application/json" },
-        body: JSON.stringify({ path: fullPath, content }),
+        body: JSON.stringify({
+          path: fullPath,
+          content,
+          conversation_id: conversationId,
+          base_sha256: baseHashRef.current,
+        }),
       });
 
       if (response.ok) {
+        const result: { sha256: string } = await response.json();
+        baseHashRef.current = result.sha256;
         setSaveStatus("saved");
         setTimeout(() => setSaveStatus("idle"), 2000);
+      } else if (response.status === 409) {
+        setSaveStatus("conflict");
+        setTimeout(() => setSaveStatus("idle"), 5000);
       } else {
         setSaveStatus("error");
         setTimeout(() => setSaveStatus("idle"), 3000);
@@ -586,7 +695,7 @@ function DiffViewer({ cwd, isOpen, onClose, onCommentTextChange, initialCommit }
       setSaveStatus("error");
       setTimeout(() => setSaveStatus("idle"), 3000);
     }
-  }, [selectedFile, fileDiff, gitRoot]);
+  }, [selectedFile, fileDiff, gitRoot, conversationId]);
 
   // Debounced auto-save
   const scheduleSave = useCallback(() => {
@@ -748,6 +857,9 @@ function DiffViewer({ cwd, isOpen, onClose, onCommentTextChange, initialCommit }
       className="diff-viewer-select"
     >
       <option value="">Choose base...</option>
+      {conversationId && (
+        <option value={CONVERSATION_DIFF_ID}>Changes in this conversation</option>
+      )}
       {diffs.map((diff) => {
         const stats = `${diff.filesCount} files, +${diff.additions}/-${diff.deletions}`;
         return (
@@ -804,6 +916,33 @@ function DiffViewer({ cwd, isOpen, onClose, onCommentTextChange, initialCommit }
     </div>
   );
 
+  const coverageButton = (
+    <div className="diff-viewer-coverage">
+      <button
+        className={`diff-viewer-mode-btn ${coverageMode === "on" ? "active" : ""}`}
+        onClick={toggleCoverage}
+        disabled={coverageMode === "running"}
+        title={
+          coverageMode === "off"
+            ? "Run the tests and show which lines they cover"
+            : coverageMode === "running"
+              ? "Running tests with coverage..."
+              : "Hide coverage"
+        }
+      >
+        {coverageMode === "running" ? "⏳" : "🧪"}
+      </button>
+      {coverage && (
+        <span
+          className="diff-viewer-coverage-summary"
+          title={`${coverage.covered} of ${coverage.statements} statements covered`}
+        >
+          {coverage.percent.toFixed(0)}%{coverage.stale && " (stale)"}
+        </span>
+      )}
+    </div>
+  );
+
   const navButtons = (
     <div className="diff-viewer-nav-buttons">
       <button
@@ -850,6 +989,7 @@ function DiffViewer({ cwd, isOpen, onClose, onCommentTextChange, initialCommit }
             {saveStatus === "saving" && "💾 Saving..."}
             {saveStatus === "saved" && "✅ Saved"}
             {saveStatus === "error" && "❌ Error saving"}
+            {saveStatus === "conflict" && "⚠️ File changed on disk; reopen it to edit"}
           </div>
         )}
         {showKeyboardHint && (
@@ -879,6 +1019,7 @@ function DiffViewer({ cwd, isOpen, onClose, onCommentTextChange, initialCommit }
                 {fileSelector}
               </div>
               <div className="diff-viewer-controls-row">
+                {coverageButton}
                 {navButtons}
                 {modeToggle}
                 <button className="diff-viewer-close" onClick={onClose} title="Close (Esc)">
@@ -1,16 +1,18 @@
 import { useRef, useEffect } from "react";
-import MessageInput from "./MessageInput";
+import MessageInput, { SendOptions } from "./MessageInput";
+import { EnterBehavior } from "../types";
 
 interface InputModalProps {
   isOpen: boolean;
   onClose: () => void;
-  onSend: (message: string) => Promise<void>;
+  onSend: (message: string, options?: SendOptions) => Promise<void>;
   sending: boolean;
   agentWorking: boolean;
   onCancel?: () => Promise<void>;
   conversationTitle?: string;
-  enterBehavior?: "send" | "stop_and_send";
+  enterBehavior?: EnterBehavior;
   persistKey?: string;
+  onSendVoice?: (audio: Blob, options?: SendOptions) => Promise<void>;
 }
 
 export function InputModal({
@@ -23,6 +25,7 @@ export function InputModal({
   conversationTitle,
   enterBehavior,
   persistKey,
+  onSendVoice,
 }: InputModalProps) {
   const backdropRef = useRef<HTMLDivElement>(null);
 
@@ -59,9 +62,14 @@ export function InputModal({
     }
   };
 
-  const handleSend = async (message: string) => {
+  const handleSend = async (message: string, options?: SendOptions) => {
     onClose();  // Close immediately when sending
-    await onSend(message);
+    await onSend(message, options);
+  };
+
+  const handleSendVoice = async (audio: Blob, options?: SendOptions) => {
+    onClose();
+    await onSendVoice?.(audio, options);
   };
 
   return (
@@ -83,6 +91,7 @@ export function InputModal({
           mobileVisible={true}
           enterBehavior={enterBehavior}
           persistKey={persistKey}
+          onSendVoice={onSendVoice && handleSendVoice}
         />
       </div>
     </div>
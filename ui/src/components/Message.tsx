@@ -6,6 +6,7 @@ import PatchTool from "./PatchTool";
 import ScreenshotTool from "./ScreenshotTool";
 import GenericTool from "./GenericTool";
 import ThinkTool from "./ThinkTool";
+import AskUserTool from "./AskUserTool";
 import KeywordSearchTool from "./KeywordSearchTool";
 import BrowserNavigateTool from "./BrowserNavigateTool";
 import BrowserEvalTool from "./BrowserEvalTool";
@@ -13,10 +14,11 @@ import ReadImageTool from "./ReadImageTool";
 import BrowserConsoleLogsTool from "./BrowserConsoleLogsTool";
 import ChangeDirTool from "./ChangeDirTool";
 import BrowserResizeTool from "./BrowserResizeTool";
-import DeploySelfTool from "./DeploySelfTool";
+import DeployTool from "./DeployTool";
 import ToolGroup from "./ToolGroup";
 import ContextMenu from "./ContextMenu";
 import UsageDetailModal from "./UsageDetailModal";
+import { api } from "../services/api";
 
 // Display data types from different tools
 interface ToolDisplay {
@@ -102,6 +104,39 @@ function Message({ message, followingTools, showTools = true, mergedSegments, in
     return null;
   }
 
+  // Render summary messages as a collapsed compaction marker
+  if (message.type === "summary") {
+    let summaryText = "";
+    if (message.llm_data) {
+      try {
+        const llmData =
+          typeof message.llm_data === "string" ? JSON.parse(message.llm_data) : message.llm_data;
+        summaryText = llmData?.Content?.[0]?.Text || "";
+      } catch (err) {
+        console.error("Failed to parse summary llm_data:", err);
+      }
+    }
+
+    return (
+      <div
+        className="message message-summary"
+        data-testid="message-summary"
+        style={{
+          padding: "0.4rem 1rem",
+          fontSize: "0.8rem",
+          color: "var(--text-secondary)",
+        }}
+      >
+        <details>
+          <summary style={{ textAlign: "center", fontStyle: "italic", cursor: "pointer" }}>
+            Conversation compacted; earlier messages are no longer sent to the model
+          </summary>
+          <div style={{ whiteSpace: "pre-wrap", marginTop: "0.4rem" }}>{summaryText}</div>
+        </details>
+      </div>
+    );
+  }
+
   // Render gitinfo messages as compact status updates
   if (message.type === "gitinfo") {
     // Parse user_data which contains structured git state info
@@ -365,6 +400,37 @@ function Message({ message, followingTools, showTools = true, mergedSegments, in
     });
   }
 
+  // Feedback on agent messages, for evaluating prompts and models
+  if (message.type === "agent") {
+    const sendFeedback = (feedback: { rating?: "up" | "down"; comment?: string }) => {
+      api
+        .setMessageFeedback(message.conversation_id, { message_id: message.message_id, ...feedback })
+        .catch((err) => console.error("Failed to record feedback:", err));
+    };
+    contextMenuItems.push(
+      {
+        label: "Good Response",
+        icon: <span aria-hidden="true">👍</span>,
+        onClick: () => sendFeedback({ rating: "up" }),
+      },
+      {
+        label: "Bad Response",
+        icon: <span aria-hidden="true">👎</span>,
+        onClick: () => sendFeedback({ rating: "down" }),
+      },
+      {
+        label: "Comment...",
+        icon: <span aria-hidden="true">💬</span>,
+        onClick: () => {
+          const comment = window.prompt("Feedback on this response:");
+          if (comment?.trim()) {
+            sendFeedback({ comment });
+          }
+        },
+      },
+    );
+  }
+
   // Build a map of tool use IDs to their inputs for linking tool_result back to tool_use
   const toolUseMap: Record<string, { name: string; input: unknown }> = {};
   if (llmMessage && llmMessage.Content) {
@@ -416,7 +482,7 @@ function Message({ message, followingTools, showTools = true, mergedSegments, in
 
         // Use specialized component for bash tool
         if (content.ToolName === "bash") {
-          return <BashTool toolInput={content.ToolInput} isRunning={true} />;
+          return <BashTool toolUseId={content.ID} toolInput={content.ToolInput} isRunning={true} />;
         }
         // Use specialized component for patch tool
         if (content.ToolName === "patch") {
@@ -430,6 +496,9 @@ function Message({ message, followingTools, showTools = true, mergedSegments, in
         if (content.ToolName === "think") {
           return <ThinkTool toolInput={content.ToolInput} isRunning={true} />;
         }
+        if (content.ToolName === "ask_user") {
+          return <AskUserTool toolUseId={content.ID} toolInput={content.ToolInput} isRunning={true} />;
+        }
         // Use specialized component for change_dir tool
         if (content.ToolName === "change_dir") {
           return <ChangeDirTool toolInput={content.ToolInput} isRunning={true} />;
@@ -467,9 +536,9 @@ function Message({ message, followingTools, showTools = true, mergedSegments, in
             />
           );
         }
-        // Use specialized component for deploy_self tool
-        if (content.ToolName === "deploy_self") {
-          return <DeploySelfTool toolInput={content.ToolInput} isRunning={true} />;
+        // Use specialized component for deploy tool
+        if (content.ToolName === "deploy") {
+          return <DeployTool toolUseId={content.ID} toolInput={content.ToolInput} isRunning={true} />;
         }
         // Default rendering for other tools using GenericTool
         return (
@@ -578,6 +647,19 @@ function Message({ message, followingTools, showTools = true, mergedSegments, in
           );
         }
 
+        // Use specialized component for ask_user tool
+        if (toolName === "ask_user") {
+          return (
+            <AskUserTool
+              toolInput={toolInput}
+              isRunning={false}
+              toolResult={content.ToolResult}
+              hasError={hasError}
+              executionTime={executionTime}
+            />
+          );
+        }
+
         // Use specialized component for change_dir tool
         if (toolName === "change_dir") {
           return (
@@ -674,10 +756,10 @@ function Message({ message, followingTools, showTools = true, mergedSegments, in
           );
         }
 
-        // Use specialized component for deploy_self tool
-        if (toolName === "deploy_self") {
+        // Use specialized component for deploy tool
+        if (toolName === "deploy") {
           return (
-            <DeploySelfTool
+            <DeployTool
               toolInput={toolInput}
               isRunning={false}
               toolResult={content.ToolResult}
@@ -702,8 +784,22 @@ function Message({ message, followingTools, showTools = true, mergedSegments, in
       case "redacted_thinking":
         return <div className="text-tertiary italic text-sm">[Thinking content hidden]</div>;
       case "thinking":
-        // Hide thinking content by default in main flow, but could be made expandable
-        return null;
+        if (!content.Thinking?.trim()) {
+          return null;
+        }
+        return (
+          <details className="text-xs" data-testid="thinking-content">
+            <summary className="text-secondary" style={{ cursor: "pointer", fontStyle: "italic" }}>
+              Thinking…
+            </summary>
+            <div
+              className="text-secondary"
+              style={{ whiteSpace: "pre-wrap", marginTop: "0.4rem" }}
+            >
+              {content.Thinking}
+            </div>
+          </details>
+        );
       default: {
         // For unknown content types, show the type and try to display useful content
         const displayText = content.Text || content.Data || "";
@@ -941,13 +1037,13 @@ function Message({ message, followingTools, showTools = true, mergedSegments, in
     return null;
   }
 
-  // Filter out thinking content, empty content, tool_use, and tool_result
+  // Filter out empty thinking, redacted thinking, empty content, tool_use, and tool_result
   const meaningfulContent =
     llmMessage?.Content?.filter((c) => {
       const contentType = c.Type;
-      // Filter out thinking (3), redacted thinking (4), tool_use (5), tool_result (6), and empty text content
+      // Filter out empty thinking (3), redacted thinking (4), tool_use (5), tool_result (6), and empty text content
       return (
-        contentType !== 3 &&
+        (contentType !== 3 || c.Thinking?.trim()) &&
         contentType !== 4 &&
         contentType !== 5 &&
         contentType !== 6 &&
@@ -1,4 +1,5 @@
 import React, { useState, useRef, useEffect, useCallback } from "react";
+import { EnterBehavior } from "../types";
 
 // Web Speech API types
 interface SpeechRecognitionEvent extends Event {
@@ -43,8 +44,38 @@ declare global {
   }
 }
 
+export interface SendOptions {
+  /** Hold the message until the agent's current turn ends */
+  queue?: boolean;
+}
+
+// invertEnterBehavior is the behavior of Ctrl+Enter for a given setting
+function invertEnterBehavior(behavior: EnterBehavior): EnterBehavior {
+  return behavior === "stop_and_send" ? "send" : "stop_and_send";
+}
+
+// findUpload returns the path of the file's upload if the server has it, by
+// its content's SHA-256. Hashing needs a secure context; without one, or on
+// any error, the file is just uploaded.
+async function findUpload(file: File): Promise<string | null> {
+  if (!window.crypto?.subtle) {
+    return null;
+  }
+  try {
+    const digest = await window.crypto.subtle.digest("SHA-256", await file.arrayBuffer());
+    const hash = Array.from(new Uint8Array(digest), (b) => b.toString(16).padStart(2, "0")).join("");
+    const response = await fetch(`/api/upload/${hash}?name=${encodeURIComponent(file.name)}`);
+    if (!response.ok) {
+      return null;
+    }
+    return (await response.json()).path;
+  } catch {
+    return null;
+  }
+}
+
 interface MessageInputProps {
-  onSend: (message: string) => Promise<void>;
+  onSend: (message: string, options?: SendOptions) => Promise<void>;
   disabled?: boolean;
   autoFocus?: boolean;
   onFocus?: () => void;
@@ -61,9 +92,11 @@ interface MessageInputProps {
   /** Callback to cancel the current agent work */
   onCancel?: () => Promise<void>;
   /** Enter key behavior setting */
-  enterBehavior?: "send" | "stop_and_send";
+  enterBehavior?: EnterBehavior;
   /** Compact mode (multi-pane layout) */
   compact?: boolean;
+  /** If set, the voice button records audio and sends it for transcription on the server */
+  onSendVoice?: (audio: Blob, options?: SendOptions) => Promise<void>;
 }
 
 const PERSIST_KEY_PREFIX = "shelley_draft_";
@@ -82,6 +115,7 @@ function MessageInput({
   onCancel,
   enterBehavior = "send",
   compact = false,
+  onSendVoice,
 }: MessageInputProps) {
   const [message, setMessage] = useState(() => {
     // Load persisted draft if persistKey is set
@@ -101,6 +135,8 @@ function MessageInput({
   // Track the base text (before speech recognition started) and finalized speech text
   const baseTextRef = useRef<string>("");
   const finalizedTextRef = useRef<string>("");
+  const [isRecording, setIsRecording] = useState(false);
+  const recorderRef = useRef<MediaRecorder | null>(null);
 
   // Check if speech recognition is available
   const speechRecognitionAvailable =
@@ -181,12 +217,67 @@ function MessageInput({
     }
   }, [isListening, startListening, stopListening]);
 
+  // Record on the server where the browser can't transcribe, and on phones,
+  // where a recording is sent straight to the agent without typing
+  const recordingAvailable =
+    !!onSendVoice &&
+    typeof MediaRecorder !== "undefined" &&
+    (!speechRecognitionAvailable || window.matchMedia("(max-width: 768px)").matches);
+
+  const startRecording = useCallback(async () => {
+    if (!onSendVoice) return;
+    let stream: MediaStream;
+    try {
+      stream = await navigator.mediaDevices.getUserMedia({ audio: true });
+    } catch (err) {
+      console.error("Failed to access microphone:", err);
+      return;
+    }
+    const recorder = new MediaRecorder(stream);
+    const chunks: Blob[] = [];
+    recorder.ondataavailable = (e) => chunks.push(e.data);
+    recorder.onstop = async () => {
+      stream.getTracks().forEach((track) => track.stop());
+      recorderRef.current = null;
+      setIsRecording(false);
+      const audio = new Blob(chunks, { type: recorder.mimeType });
+      if (audio.size === 0) return;
+      setSubmitting(true);
+      try {
+        await onSendVoice(audio, { queue: agentWorking && enterBehavior === "queue" });
+      } catch {
+        // The error is shown by the caller
+      } finally {
+        setSubmitting(false);
+      }
+    };
+    recorderRef.current = recorder;
+    recorder.start();
+    setIsRecording(true);
+  }, [onSendVoice, agentWorking, enterBehavior]);
+
+  const toggleVoice = useCallback(() => {
+    if (!recordingAvailable) {
+      toggleListening();
+    } else if (recorderRef.current) {
+      recorderRef.current.stop();
+    } else {
+      startRecording();
+    }
+  }, [recordingAvailable, toggleListening, startRecording]);
+
   // Cleanup on unmount
   useEffect(() => {
     return () => {
       if (recognitionRef.current) {
         recognitionRef.current.abort();
       }
+      if (recorderRef.current) {
+        // Stop the microphone without sending the recording
+        recorderRef.current.onstop = null;
+        recorderRef.current.stream.getTracks().forEach((track) => track.stop());
+        recorderRef.current.stop();
+      }
     };
   }, []);
 
@@ -227,23 +318,27 @@ function MessageInput({
     setUploadsInProgress((prev) => prev + 1);
 
     try {
-      const formData = new FormData();
-      formData.append("file", file);
-
-      const response = await fetch("/api/upload", {
-        method: "POST",
-        headers: { "X-Shelley-Request": "1" },
-        body: formData,
-      });
+      // Files stored already needn't be sent again
+      let path = await findUpload(file);
+      if (!path) {
+        const formData = new FormData();
+        formData.append("file", file);
+
+        const response = await fetch("/api/upload", {
+          method: "POST",
+          headers: { "X-Shelley-Request": "1" },
+          body: formData,
+        });
+
+        if (!response.ok) {
+          throw new Error(`Upload failed: ${response.statusText}`);
+        }
 
-      if (!response.ok) {
-        throw new Error(`Upload failed: ${response.statusText}`);
+        path = (await response.json()).path;
       }
 
-      const data = await response.json();
-
       // Replace the loading placeholder with the actual file path
-      setMessage((currentMessage) => currentMessage.replace(loadingText, `[${data.path}]`));
+      setMessage((currentMessage) => currentMessage.replace(loadingText, `[${path}]`));
     } catch (error) {
       console.error("Failed to upload file:", error);
       // Replace loading indicator with error message
@@ -346,13 +441,11 @@ function MessageInput({
   const handleSubmit = async (e: React.FormEvent, invertBehavior = false) => {
     e.preventDefault();
     // Determine effective behavior (Ctrl+Enter inverts the setting)
-    const effectiveBehavior = invertBehavior
-      ? (enterBehavior === "send" ? "stop_and_send" : "send")
-      : enterBehavior;
+    const effectiveBehavior = invertBehavior ? invertEnterBehavior(enterBehavior) : enterBehavior;
     
-    // In stop_and_send mode, allow submit even while agent is working
+    // In stop_and_send and queue modes, allow submit even while agent is working
     const canSubmitNow = message.trim() && !submitting && uploadsInProgress === 0 &&
-      (!disabled || (agentWorking && effectiveBehavior === "stop_and_send"));
+      (!disabled || (agentWorking && effectiveBehavior !== "send"));
     
     if (!canSubmitNow) return;
     
@@ -368,7 +461,7 @@ function MessageInput({
       if (agentWorking && effectiveBehavior === "stop_and_send" && onCancel) {
         await onCancel();
       }
-      await onSend(messageToSend);
+      await onSend(messageToSend, { queue: agentWorking && effectiveBehavior === "queue" });
       // Only clear on success
       setMessage("");
       // Clear persisted draft on successful send
@@ -431,13 +524,11 @@ function MessageInput({
 
   const isDisabled = disabled || uploadsInProgress > 0;
   // Determine effective behavior based on Ctrl key state
-  const effectiveBehavior = ctrlPressed
-    ? (enterBehavior === "send" ? "stop_and_send" : "send")
-    : enterBehavior;
+  const effectiveBehavior = ctrlPressed ? invertEnterBehavior(enterBehavior) : enterBehavior;
   
-  // In stop_and_send mode, allow submit even while agent is working
+  // In stop_and_send and queue modes, allow submit even while agent is working
   const canSubmit = message.trim() && !submitting && uploadsInProgress === 0 && 
-    (!disabled || (agentWorking && effectiveBehavior === "stop_and_send"));
+    (!disabled || (agentWorking && effectiveBehavior !== "send"));
 
   const isDraggingOver = dragCounter > 0;
   // Note: injectedText is auto-inserted via useEffect, no manual UI needed
@@ -516,16 +607,22 @@ function MessageInput({
             <path strokeLinecap="round" strokeLinejoin="round" d="M18.375 12.739l-7.693 7.693a4.5 4.5 0 01-6.364-6.364l10.94-10.94A3 3 0 1119.5 7.372L8.552 18.32m.009-.01l-.01.01m5.699-9.941l-7.81 7.81a1.5 1.5 0 002.112 2.13" />
           </svg>
         </button>
-        {speechRecognitionAvailable && (
+        {(speechRecognitionAvailable || recordingAvailable) && (
           <button
             type="button"
-            onClick={toggleListening}
-            disabled={isDisabled}
-            className={`message-voice-btn ${isListening ? "listening" : ""}`}
-            aria-label={isListening ? "Stop voice input" : "Start voice input"}
+            onClick={toggleVoice}
+            disabled={isDisabled && !isRecording}
+            className={`message-voice-btn ${isListening || isRecording ? "listening" : ""}`}
+            aria-label={
+              isRecording
+                ? "Stop and send recording"
+                : isListening
+                  ? "Stop voice input"
+                  : "Start voice input"
+            }
             data-testid="voice-button"
           >
-            {isListening ? (
+            {isListening || isRecording ? (
               <svg fill="currentColor" viewBox="0 0 24 24" width="20" height="20">
                 <circle cx="12" cy="12" r="6" />
               </svg>
@@ -540,7 +637,7 @@ function MessageInput({
           type="submit"
           disabled={!canSubmit}
           className="message-send-btn"
-          aria-label="Send message"
+          aria-label={agentWorking && effectiveBehavior === "queue" ? "Queue message" : "Send message"}
           data-testid="send-button"
           onMouseDown={(e) => e.preventDefault()} // Prevent focus from moving to button
         >
@@ -554,6 +651,12 @@ function MessageInput({
               <path strokeLinecap="round" strokeLinejoin="round" d="m4.5 18.75 7.5-7.5 7.5 7.5" />
               <path strokeLinecap="round" strokeLinejoin="round" d="m4.5 12.75 7.5-7.5 7.5 7.5" />
             </svg>
+          ) : agentWorking && effectiveBehavior === "queue" ? (
+            // Clock icon for queue mode: sent when the agent's turn ends
+            <svg fill="none" stroke="currentColor" viewBox="0 0 24 24" width="20" height="20" strokeWidth="2">
+              <circle cx="12" cy="12" r="8" />
+              <path strokeLinecap="round" strokeLinejoin="round" d="M12 8v4l2.5 2.5" />
+            </svg>
           ) : (
             // Normal arrow icon
             <svg fill="currentColor" viewBox="0 0 24 24" width="20" height="20">
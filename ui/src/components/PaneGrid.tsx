@@ -19,7 +19,12 @@ interface PaneGridProps {
   onNewConversation: () => void;
   onConversationUpdate: (conversation: Conversation) => void;
   onConversationArchived: (conversationId: string) => void;
-  onFirstMessage: (message: string, model: string, cwd?: string) => Promise<void>;
+  onFirstMessage: (
+    message: string,
+    model: string,
+    cwd?: string,
+    devcontainer?: boolean,
+  ) => Promise<void>;
   mostRecentCwd: string | null;
 }
 
@@ -1,6 +1,18 @@
-import React, { useState, useEffect } from "react";
+import React, { useState, useEffect, useRef } from "react";
 import Modal from "./Modal";
-import { Settings, GuardianCheckSettings } from "../types";
+import {
+  Settings,
+  UserSettings,
+  SettingsVersion,
+  GuardianCheckSettings,
+  BashToolSettings,
+  EnterBehavior,
+  SlugSettings,
+  WriteFileSettings,
+  NotificationSettings,
+  NotifyKind,
+  Model,
+} from "../types";
 import { api } from "../services/api";
 
 interface SettingsModalProps {
@@ -8,20 +20,35 @@ interface SettingsModalProps {
   onClose: () => void;
 }
 
-const defaultCheckSettings: GuardianCheckSettings = {
-  enabled: false,
-  model: "claude-haiku-4-5-20251001",
-  prompt: "",
+// Guardian checks default to the cheapest ready model, since they run often
+const defaultCheckSettings = (models: Model[]): GuardianCheckSettings => {
+  const priced = models.filter((m) => m.ready && m.pricing);
+  priced.sort((a, b) => a.pricing!.output - b.pricing!.output);
+  return {
+    enabled: false,
+    model: priced[0]?.id ?? models.find((m) => m.ready)?.id ?? "",
+    prompt: "",
+  };
 };
 
-// Get available models from server init data
-const getAvailableModels = () => {
-  const models = window.__SHELLEY_INIT__?.models ?? [];
-  return models.filter((m) => m.ready).map((m) => ({ id: m.id, name: m.id }));
-};
+const notifyKinds: { kind: NotifyKind; label: string }[] = [
+  { kind: "turn_end", label: "Turn finished" },
+  { kind: "error", label: "Error" },
+  { kind: "question", label: "Question asked" },
+];
+
+// modelName labels a model with its list price
+const modelName = (m: Model) =>
+  m.pricing ? `${m.id} ($${m.pricing.input} / $${m.pricing.output} per M tokens)` : m.id;
 
 function SettingsModal({ isOpen, onClose }: SettingsModalProps) {
   const [settings, setSettings] = useState<Settings>({});
+  const [models, setModels] = useState<Model[]>(() => window.__SHELLEY_INIT__?.models ?? []);
+  const getAvailableModels = () =>
+    models.filter((m) => m.ready).map((m) => ({ id: m.id, name: modelName(m) }));
+  const [userSettings, setUserSettings] = useState<UserSettings>({});
+  const [history, setHistory] = useState<SettingsVersion[]>([]);
+  const importInputRef = useRef<HTMLInputElement>(null);
   const [loading, setLoading] = useState(true);
   const [saving, setSaving] = useState(false);
   const [error, setError] = useState<string | null>(null);
@@ -36,8 +63,16 @@ function SettingsModal({ isOpen, onClose }: SettingsModalProps) {
     setLoading(true);
     setError(null);
     try {
-      const data = await api.getSettings();
+      const [data, userData, versions, modelList] = await Promise.all([
+        api.getSettings(),
+        api.getUserSettings(),
+        api.getSettingsHistory(10),
+        api.getModels(),
+      ]);
       setSettings(data);
+      setUserSettings(userData);
+      setHistory(versions);
+      setModels(modelList);
     } catch (err) {
       setError(err instanceof Error ? err.message : "Failed to load settings");
     } finally {
@@ -49,7 +84,19 @@ function SettingsModal({ isOpen, onClose }: SettingsModalProps) {
     setSaving(true);
     setError(null);
     try {
-      await api.updateSettings(settings);
+      // Drop blank entries left by the comma-separated models field
+      const slug = settings.slug && {
+        ...settings.slug,
+        models: settings.slug.models?.filter((m) => m !== ""),
+      };
+      // Drop blank lines of the write policy fields
+      const writeFile = settings.writeFile && {
+        ...settings.writeFile,
+        allowedRoots: settings.writeFile.allowedRoots?.filter((r) => r !== ""),
+        deniedGlobs: settings.writeFile.deniedGlobs?.filter((g) => g !== ""),
+      };
+      await api.updateSettings({ ...settings, slug, writeFile });
+      await api.updateUserSettings(userSettings);
       // Notify all ChatInterface instances to reload settings
       window.dispatchEvent(new CustomEvent("shelley-settings-changed"));
       onClose();
@@ -60,13 +107,53 @@ function SettingsModal({ isOpen, onClose }: SettingsModalProps) {
     }
   };
 
+  const handleRevert = async (id: number) => {
+    setError(null);
+    try {
+      await api.revertSettings(id);
+      window.dispatchEvent(new CustomEvent("shelley-settings-changed"));
+      await loadSettings();
+    } catch (err) {
+      setError(err instanceof Error ? err.message : "Failed to revert settings");
+    }
+  };
+
+  const handleExport = async () => {
+    setError(null);
+    try {
+      const blob = await api.exportSettings();
+      const url = URL.createObjectURL(blob);
+      const link = document.createElement("a");
+      link.href = url;
+      link.download = "shelley-settings.json";
+      link.click();
+      URL.revokeObjectURL(url);
+    } catch (err) {
+      setError(err instanceof Error ? err.message : "Failed to export settings");
+    }
+  };
+
+  const handleImport = async (e: React.ChangeEvent<HTMLInputElement>) => {
+    const file = e.target.files?.[0];
+    e.target.value = "";
+    if (!file) return;
+    setError(null);
+    try {
+      await api.importSettings(await file.text());
+      window.dispatchEvent(new CustomEvent("shelley-settings-changed"));
+      await loadSettings();
+    } catch (err) {
+      setError(err instanceof Error ? err.message : "Failed to import settings");
+    }
+  };
+
   const updateStreamSettings = (updates: Partial<GuardianCheckSettings>) => {
     setSettings((prev) => ({
       ...prev,
       guardian: {
         ...prev.guardian,
         stream: {
-          ...(prev.guardian?.stream ?? defaultCheckSettings),
+          ...(prev.guardian?.stream ?? defaultCheckSettings(models)),
           ...updates,
         },
       },
@@ -79,15 +166,67 @@ function SettingsModal({ isOpen, onClose }: SettingsModalProps) {
       guardian: {
         ...prev.guardian,
         toolCheck: {
-          ...(prev.guardian?.toolCheck ?? defaultCheckSettings),
+          ...(prev.guardian?.toolCheck ?? defaultCheckSettings(models)),
           ...updates,
         },
       },
     }));
   };
 
-  const streamSettings = settings.guardian?.stream ?? defaultCheckSettings;
-  const toolCheckSettings = settings.guardian?.toolCheck ?? defaultCheckSettings;
+  const updateBashSettings = (updates: Partial<BashToolSettings>) => {
+    setSettings((prev) => ({
+      ...prev,
+      tools: { ...prev.tools, bash: { ...prev.tools?.bash, ...updates } },
+    }));
+  };
+
+  const updateSlugSettings = (updates: Partial<SlugSettings>) => {
+    setSettings((prev) => ({ ...prev, slug: { ...prev.slug, ...updates } }));
+  };
+
+  const updateWriteFileSettings = (updates: Partial<WriteFileSettings>) => {
+    setSettings((prev) => ({ ...prev, writeFile: { ...prev.writeFile, ...updates } }));
+  };
+
+  const updateNotifications = (updates: Partial<NotificationSettings>) => {
+    setSettings((prev) => ({ ...prev, notifications: { ...prev.notifications, ...updates } }));
+  };
+
+  // An empty list means every kind, so the last kind can't be turned off
+  const setNotifyKind = (kind: NotifyKind, enabled: boolean) => {
+    const current = settings.notifications?.events?.length
+      ? settings.notifications.events
+      : notifyKinds.map((k) => k.kind);
+    const events = enabled ? [...current, kind] : current.filter((k) => k !== kind);
+    updateNotifications({ events: events.length === notifyKinds.length ? undefined : events });
+  };
+
+  const setToolEnabled = (name: string, enabled: boolean) => {
+    setSettings((prev) => ({
+      ...prev,
+      tools: { ...prev.tools, enabled: { ...prev.tools?.enabled, [name]: enabled } },
+    }));
+  };
+
+  const toolNames = Object.keys(settings.tools?.enabled ?? {}).sort();
+  const bashSettings = settings.tools?.bash ?? {};
+  const bashTimeoutFields: {
+    key: "fastTimeout" | "slowTimeout" | "backgroundTimeout";
+    label: string;
+    placeholder: string;
+  }[] = [
+    { key: "fastTimeout", label: "Timeout", placeholder: "30s" },
+    { key: "slowTimeout", label: "Slow Timeout", placeholder: "15m" },
+    { key: "backgroundTimeout", label: "Background Timeout", placeholder: "24h" },
+  ];
+
+  const notifications = settings.notifications ?? {};
+  const notifyEvents = notifications.events?.length
+    ? notifications.events
+    : notifyKinds.map((k) => k.kind);
+
+  const streamSettings = settings.guardian?.stream ?? defaultCheckSettings(models);
+  const toolCheckSettings = settings.guardian?.toolCheck ?? defaultCheckSettings(models);
 
   return (
     <Modal isOpen={isOpen} onClose={onClose} title="Settings" className="settings-modal">
@@ -99,13 +238,34 @@ function SettingsModal({ isOpen, onClose }: SettingsModalProps) {
 
           <div className="settings-section">
             <h3 className="settings-section-title">Display</h3>
+            <div className="settings-row">
+              <label className="settings-label">Default Model</label>
+              <select
+                className="settings-select"
+                value={userSettings.defaultModel ?? ""}
+                onChange={(e) =>
+                  setUserSettings((prev) => ({ ...prev, defaultModel: e.target.value || undefined }))
+                }
+              >
+                <option value="">Server default</option>
+                {getAvailableModels().map((m) => (
+                  <option key={m.id} value={m.id}>
+                    {m.name}
+                  </option>
+                ))}
+              </select>
+            </div>
+            <p className="settings-field-description">
+              The model new conversations start with, unless you pick another one.
+            </p>
+
             <div className="settings-row">
               <label className="settings-label">Tool Indicator Mode</label>
               <select
                 className="settings-select"
-                value={settings.ui?.indicatorMode ?? "inline"}
+                value={userSettings.ui?.indicatorMode ?? "inline"}
                 onChange={(e) =>
-                  setSettings((prev) => ({
+                  setUserSettings((prev) => ({
                     ...prev,
                     ui: { ...prev.ui, indicatorMode: e.target.value as "inline" | "block" | "hidden" },
                   }))
@@ -120,15 +280,15 @@ function SettingsModal({ isOpen, onClose }: SettingsModalProps) {
               Controls how tool execution indicators are displayed when tools are collapsed.
             </p>
 
-            {settings.ui?.indicatorMode === "inline" && (
+            {userSettings.ui?.indicatorMode === "inline" && (
               <>
                 <div className="settings-row">
                   <label className="settings-label">Expansion Behavior</label>
                   <select
                     className="settings-select"
-                    value={settings.ui?.expansionBehavior ?? "single"}
+                    value={userSettings.ui?.expansionBehavior ?? "single"}
                     onChange={(e) =>
-                      setSettings((prev) => ({
+                      setUserSettings((prev) => ({
                         ...prev,
                         ui: { ...prev.ui, expansionBehavior: e.target.value as "single" | "all" },
                       }))
@@ -148,16 +308,17 @@ function SettingsModal({ isOpen, onClose }: SettingsModalProps) {
               <label className="settings-label">Enter Key Behavior</label>
               <select
                 className="settings-select"
-                value={settings.ui?.enterBehavior ?? "send"}
+                value={userSettings.ui?.enterBehavior ?? "send"}
                 onChange={(e) =>
-                  setSettings((prev) => ({
+                  setUserSettings((prev) => ({
                     ...prev,
-                    ui: { ...prev.ui, enterBehavior: e.target.value as "send" | "stop_and_send" },
+                    ui: { ...prev.ui, enterBehavior: e.target.value as EnterBehavior },
                   }))
                 }
               >
                 <option value="send">Send (wait for agent to finish)</option>
                 <option value="stop_and_send">Stop & Send (interrupt and send immediately)</option>
+                <option value="queue">Queue (send when the agent finishes its turn)</option>
               </select>
             </div>
             <p className="settings-field-description">
@@ -165,6 +326,292 @@ function SettingsModal({ isOpen, onClose }: SettingsModalProps) {
             </p>
           </div>
 
+          <div className="settings-section">
+            <h3 className="settings-section-title">Titles</h3>
+            <div className="settings-row">
+              <label className="settings-label">Title Template</label>
+              <input
+                type="text"
+                className="settings-input"
+                value={settings.slug?.template ?? ""}
+                onChange={(e) => updateSlugSettings({ template: e.target.value })}
+                placeholder="{title}"
+              />
+            </div>
+            <p className="settings-field-description">
+              How generated conversation titles are laid out, e.g. {"{date}-{branch}-{title}"} or{" "}
+              {"{repo}/{title}"}. Variables: {"{title}"}, {"{date}"}, {"{branch}"}, {"{repo}"},{" "}
+              {"{dir}"}. Leave empty for the title alone.
+            </p>
+            <div className="settings-row">
+              <label className="settings-label">Title Models</label>
+              <input
+                type="text"
+                className="settings-input"
+                value={settings.slug?.models?.join(", ") ?? ""}
+                onChange={(e) =>
+                  updateSlugSettings({
+                    models: e.target.value ? e.target.value.split(",").map((m) => m.trim()) : [],
+                  })
+                }
+                placeholder="Default models"
+              />
+            </div>
+            <p className="settings-field-description">
+              Comma-separated models to try, in order, when generating titles. Leave empty for the built-in
+              list. Available: {getAvailableModels()
+                .map((m) => m.id)
+                .join(", ")}
+            </p>
+            <div className="settings-row">
+              <label className="settings-label">Title Prompt</label>
+              <textarea
+                className="settings-textarea"
+                value={settings.slug?.prompt ?? ""}
+                onChange={(e) => updateSlugSettings({ prompt: e.target.value })}
+                placeholder="Default prompt"
+                rows={4}
+              />
+            </div>
+            <p className="settings-field-description">
+              Instructions for generating a title. {"{message}"} is replaced by the first message, which
+              is otherwise appended.
+            </p>
+          </div>
+
+          <div className="settings-section">
+            <h3 className="settings-section-title">Notifications</h3>
+            <p className="settings-section-description">
+              Get notified through any of these services while you&apos;re away. Email can be set up
+              through the settings API. Each conversation can mute its notifications.
+            </p>
+
+            <div className="settings-tool-list">
+              {notifyKinds.map(({ kind, label }) => (
+                <label key={kind} className="settings-checkbox-label">
+                  <input
+                    type="checkbox"
+                    checked={notifyEvents.includes(kind)}
+                    disabled={notifyEvents.length === 1 && notifyEvents[0] === kind}
+                    onChange={(e) => setNotifyKind(kind, e.target.checked)}
+                  />
+                  <span>{label}</span>
+                </label>
+              ))}
+            </div>
+
+            <div className="settings-row">
+              <label className="settings-label">Shelley URL</label>
+              <input
+                type="text"
+                className="settings-input"
+                value={notifications.baseUrl ?? ""}
+                onChange={(e) => updateNotifications({ baseUrl: e.target.value || undefined })}
+                placeholder="https://shelley.example.com"
+              />
+            </div>
+            <p className="settings-field-description">
+              Where Shelley is reached, so notifications can link to the conversation.
+            </p>
+
+            <div className="settings-row">
+              <label className="settings-label">ntfy Topic</label>
+              <input
+                type="text"
+                className="settings-input"
+                value={notifications.ntfy?.topic ?? ""}
+                onChange={(e) =>
+                  updateNotifications({
+                    ntfy: e.target.value ? { ...notifications.ntfy, topic: e.target.value } : undefined,
+                  })
+                }
+                placeholder="my-shelley-alerts"
+              />
+            </div>
+            {notifications.ntfy && (
+              <div className="settings-row">
+                <label className="settings-label">ntfy Server</label>
+                <input
+                  type="text"
+                  className="settings-input"
+                  value={notifications.ntfy.server ?? ""}
+                  onChange={(e) =>
+                    updateNotifications({
+                      ntfy: { ...notifications.ntfy!, server: e.target.value || undefined },
+                    })
+                  }
+                  placeholder="https://ntfy.sh"
+                />
+              </div>
+            )}
+
+            <div className="settings-row">
+              <label className="settings-label">Slack Webhook URL</label>
+              <input
+                type="text"
+                className="settings-input"
+                value={notifications.slack?.webhookUrl ?? ""}
+                onChange={(e) =>
+                  updateNotifications({
+                    slack: e.target.value ? { webhookUrl: e.target.value } : undefined,
+                  })
+                }
+                placeholder="https://hooks.slack.com/services/..."
+              />
+            </div>
+
+            <div className="settings-row">
+              <label className="settings-label">Pushover App Token</label>
+              <input
+                type="text"
+                className="settings-input"
+                value={notifications.pushover?.token ?? ""}
+                onChange={(e) => {
+                  const pushover = { user: "", ...notifications.pushover, token: e.target.value };
+                  updateNotifications({ pushover: pushover.token || pushover.user ? pushover : undefined });
+                }}
+              />
+            </div>
+            <div className="settings-row">
+              <label className="settings-label">Pushover User Key</label>
+              <input
+                type="text"
+                className="settings-input"
+                value={notifications.pushover?.user ?? ""}
+                onChange={(e) => {
+                  const pushover = { token: "", ...notifications.pushover, user: e.target.value };
+                  updateNotifications({ pushover: pushover.token || pushover.user ? pushover : undefined });
+                }}
+              />
+            </div>
+          </div>
+
+          <div className="settings-section">
+            <h3 className="settings-section-title">Tools</h3>
+            <p className="settings-section-description">
+              Tools offered to the agent. Changes apply to conversations started or resumed afterwards.
+            </p>
+
+            <div className="settings-tool-list">
+              {toolNames.map((name) => (
+                <label key={name} className="settings-checkbox-label">
+                  <input
+                    type="checkbox"
+                    checked={settings.tools?.enabled?.[name] ?? true}
+                    onChange={(e) => setToolEnabled(name, e.target.checked)}
+                  />
+                  <span>{name}</span>
+                </label>
+              ))}
+            </div>
+
+            <div className="settings-subsection">
+              {bashTimeoutFields.map(({ key, label, placeholder }) => (
+                <div key={key} className="settings-row">
+                  <label className="settings-label">Bash {label}</label>
+                  <input
+                    type="text"
+                    className="settings-input"
+                    value={bashSettings[key] ?? ""}
+                    onChange={(e) => updateBashSettings({ [key]: e.target.value })}
+                    placeholder={placeholder}
+                  />
+                </div>
+              ))}
+              <p className="settings-field-description">
+                Durations such as 30s, 5m or 2h. Leave empty for the default.
+              </p>
+              <div className="settings-row">
+                <label className="settings-label">Bash Output Limit</label>
+                <input
+                  type="number"
+                  min={0}
+                  className="settings-input"
+                  value={bashSettings.maxOutputBytes || ""}
+                  onChange={(e) =>
+                    updateBashSettings({ maxOutputBytes: parseInt(e.target.value, 10) || undefined })
+                  }
+                  placeholder="32768"
+                />
+              </div>
+              <div className="settings-row">
+                <label className="settings-label">Bash Output Truncation</label>
+                <select
+                  className="settings-select"
+                  value={bashSettings.truncate ?? "head_tail"}
+                  onChange={(e) =>
+                    updateBashSettings({ truncate: e.target.value as BashToolSettings["truncate"] })
+                  }
+                >
+                  <option value="head_tail">Keep start and end</option>
+                  <option value="head">Keep start</option>
+                  <option value="tail">Keep end</option>
+                </select>
+              </div>
+              <p className="settings-field-description">
+                Longer output is cut to this many bytes before the agent sees it. The agent can ask
+                for more per command.
+              </p>
+            </div>
+          </div>
+
+          <div className="settings-section">
+            <h3 className="settings-section-title">File Editing</h3>
+            <p className="settings-section-description">
+              Where files may be saved from the diff viewer and written by the patch tool. Nothing is ever
+              written inside .git.
+            </p>
+            <div className="settings-row">
+              <label className="settings-label">Allowed Directories</label>
+              <textarea
+                className="settings-textarea"
+                value={settings.writeFile?.allowedRoots?.join("\n") ?? ""}
+                onChange={(e) =>
+                  updateWriteFileSettings({
+                    allowedRoots: e.target.value ? e.target.value.split("\n").map((r) => r.trim()) : [],
+                  })
+                }
+                placeholder="Any git repository"
+                rows={3}
+              />
+            </div>
+            <p className="settings-field-description">
+              Absolute paths, one per line. Leave empty to allow the working trees of git repositories
+              from the diff viewer, and anywhere from the patch tool.
+            </p>
+            <div className="settings-row">
+              <label className="settings-label">Denied Patterns</label>
+              <textarea
+                className="settings-textarea"
+                value={settings.writeFile?.deniedGlobs?.join("\n") ?? ""}
+                onChange={(e) =>
+                  updateWriteFileSettings({
+                    deniedGlobs: e.target.value ? e.target.value.split("\n").map((g) => g.trim()) : [],
+                  })
+                }
+                placeholder={".env\n*.pem"}
+                rows={3}
+              />
+            </div>
+            <p className="settings-field-description">
+              Globs, one per line. A pattern without a slash matches any part of the path; one with a
+              slash matches the whole path, with ** for any number of directories.
+            </p>
+            <div className="settings-row">
+              <label className="settings-label">Max File Size</label>
+              <input
+                type="number"
+                min={0}
+                className="settings-input"
+                value={settings.writeFile?.maxBytes || ""}
+                onChange={(e) =>
+                  updateWriteFileSettings({ maxBytes: parseInt(e.target.value, 10) || undefined })
+                }
+                placeholder="10485760"
+              />
+            </div>
+          </div>
+
           <div className="settings-section">
             <h3 className="settings-section-title">Guardian AI</h3>
             <p className="settings-section-description">
@@ -264,6 +711,55 @@ function SettingsModal({ isOpen, onClose }: SettingsModalProps) {
             </div>
           </div>
 
+          <div className="settings-section">
+            <h3 className="settings-section-title">Export</h3>
+            <p className="settings-section-description">
+              Copy these settings to another Shelley. Secrets are left out of exports, and importing
+              keeps this server&apos;s own.
+            </p>
+            <div className="settings-buttons">
+              <button className="btn-secondary" onClick={handleExport}>
+                Export
+              </button>
+              <button className="btn-secondary" onClick={() => importInputRef.current?.click()}>
+                Import
+              </button>
+              <input
+                ref={importInputRef}
+                type="file"
+                accept="application/json,.json"
+                onChange={handleImport}
+                style={{ display: "none" }}
+              />
+            </div>
+          </div>
+
+          {history.length > 1 && (
+            <div className="settings-section">
+              <h3 className="settings-section-title">History</h3>
+              <p className="settings-section-description">
+                Previous versions of these settings. Reverting discards unsaved changes.
+              </p>
+              <ul className="settings-history-list">
+                {history.map((version, i) => (
+                  <li key={version.id} className="settings-history-item">
+                    <span>
+                      {new Date(version.created_at).toLocaleString()}
+                      {version.user_id && <span className="text-secondary"> by {version.user_id}</span>}
+                    </span>
+                    {i === 0 ? (
+                      <span className="text-secondary text-sm">Current</span>
+                    ) : (
+                      <button className="btn-secondary" onClick={() => handleRevert(version.id)}>
+                        Revert
+                      </button>
+                    )}
+                  </li>
+                ))}
+              </ul>
+            </div>
+          )}
+
           <div className="settings-actions">
             <button className="btn-secondary" onClick={onClose} disabled={saving}>
               Cancel
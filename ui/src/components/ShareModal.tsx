@@ -0,0 +1,107 @@
+import React, { useState, useEffect } from "react";
+import Modal from "./Modal";
+import { ConversationShare } from "../types";
+import { api } from "../services/api";
+
+interface ShareModalProps {
+  isOpen: boolean;
+  onClose: () => void;
+  conversationId: string;
+}
+
+const shareUrl = (share: ConversationShare) => `${window.location.origin}${share.path}`;
+
+function ShareModal({ isOpen, onClose, conversationId }: ShareModalProps) {
+  const [shares, setShares] = useState<ConversationShare[]>([]);
+  const [loading, setLoading] = useState(true);
+  const [creating, setCreating] = useState(false);
+  const [copied, setCopied] = useState<string | null>(null);
+  const [error, setError] = useState<string | null>(null);
+
+  useEffect(() => {
+    if (isOpen) {
+      loadShares();
+    }
+  }, [isOpen, conversationId]);
+
+  const loadShares = async () => {
+    setLoading(true);
+    setError(null);
+    try {
+      setShares(await api.getShares(conversationId));
+    } catch (err) {
+      setError(err instanceof Error ? err.message : "Failed to load share links");
+    } finally {
+      setLoading(false);
+    }
+  };
+
+  const copy = async (share: ConversationShare) => {
+    try {
+      await navigator.clipboard.writeText(shareUrl(share));
+      setCopied(share.token);
+    } catch (err) {
+      console.error("Failed to copy share link:", err);
+    }
+  };
+
+  const handleCreate = async () => {
+    setCreating(true);
+    setError(null);
+    try {
+      const share = await api.createShare(conversationId);
+      setShares((prev) => [share, ...prev]);
+      copy(share);
+    } catch (err) {
+      setError(err instanceof Error ? err.message : "Failed to create share link");
+    } finally {
+      setCreating(false);
+    }
+  };
+
+  const handleRevoke = async (token: string) => {
+    setError(null);
+    try {
+      await api.revokeShare(conversationId, token);
+      setShares((prev) => prev.filter((s) => s.token !== token));
+    } catch (err) {
+      setError(err instanceof Error ? err.message : "Failed to revoke share link");
+    }
+  };
+
+  return (
+    <Modal isOpen={isOpen} onClose={onClose} title="Share" className="share-modal">
+      <p className="text-secondary text-sm share-help">
+        Anyone with a link can read this conversation, including tool output. They can&apos;t send
+        messages or change anything. Revoke a link to stop it working.
+      </p>
+      {error && <div className="share-error">{error}</div>}
+      {loading ? (
+        <div className="share-empty text-secondary">Loading...</div>
+      ) : shares.length === 0 ? (
+        <div className="share-empty text-secondary">No share links</div>
+      ) : (
+        <ul className="share-list">
+          {shares.map((share) => (
+            <li key={share.token} className="share-item">
+              <a href={shareUrl(share)} target="_blank" rel="noopener noreferrer" className="share-url">
+                {shareUrl(share)}
+              </a>
+              <button className="btn-secondary" onClick={() => copy(share)}>
+                {copied === share.token ? "Copied" : "Copy"}
+              </button>
+              <button className="btn-secondary" onClick={() => handleRevoke(share.token)}>
+                Revoke
+              </button>
+            </li>
+          ))}
+        </ul>
+      )}
+      <button className="btn-primary" onClick={handleCreate} disabled={creating}>
+        {creating ? "Creating..." : "Create link"}
+      </button>
+    </Modal>
+  );
+}
+
+export default ShareModal;
@@ -4,6 +4,7 @@ import BashTool from "./BashTool";
 import PatchTool from "./PatchTool";
 import ScreenshotTool from "./ScreenshotTool";
 import ThinkTool from "./ThinkTool";
+import AskUserTool from "./AskUserTool";
 import KeywordSearchTool from "./KeywordSearchTool";
 import BrowserNavigateTool from "./BrowserNavigateTool";
 import BrowserEvalTool from "./BrowserEvalTool";
@@ -11,7 +12,7 @@ import ReadImageTool from "./ReadImageTool";
 import BrowserConsoleLogsTool from "./BrowserConsoleLogsTool";
 import ChangeDirTool from "./ChangeDirTool";
 import BrowserResizeTool from "./BrowserResizeTool";
-import DeploySelfTool from "./DeploySelfTool";
+import DeployTool from "./DeployTool";
 import GenericTool from "./GenericTool";
 
 interface ToolGroupProps {
@@ -27,7 +28,9 @@ const TOOL_COMPONENTS: Record<string, React.ComponentType<any>> = {
   patch: PatchTool,
   screenshot: ScreenshotTool,
   browser_take_screenshot: ScreenshotTool,
+  browser_visual_diff: ScreenshotTool,
   think: ThinkTool,
+  ask_user: AskUserTool,
   keyword_search: KeywordSearchTool,
   browser_navigate: BrowserNavigateTool,
   browser_eval: BrowserEvalTool,
@@ -36,7 +39,7 @@ const TOOL_COMPONENTS: Record<string, React.ComponentType<any>> = {
   browser_clear_console_logs: BrowserConsoleLogsTool,
   change_dir: ChangeDirTool,
   browser_resize: BrowserResizeTool,
-  deploy_self: DeploySelfTool,
+  deploy: DeployTool,
 };
 
 function ToolGroup({ tools, defaultExpanded = false, compact = false }: ToolGroupProps) {
@@ -93,6 +96,7 @@ function ToolGroup({ tools, defaultExpanded = false, compact = false }: ToolGrou
 
     if (ToolComponent) {
       const props = {
+        toolUseId: tool.toolUseId,
         toolInput: tool.toolInput,
         isRunning: !tool.hasResult,
         toolResult: tool.toolResult,
@@ -11,12 +11,41 @@ export interface Conversation {
 	updated_at: string;
 	cwd: string | null;
 	archived: boolean;
+	parent_conversation_id: string | null;
 	agent_working: boolean;
 	context_window_size: number;
 	agent_error: boolean;
 	github_urls: string | null;
 	git_origin: string | null;
 	model_id: string | null;
+	user_id: string | null;
+	tools: string | null;
+	sandbox: string | null;
+	allowed_tools: string | null;
+	pinned: boolean;
+	notify_events: string | null;
+	remote: string | null;
+	devcontainer: string | null;
+	project_id: string | null;
+	guidance_files: string | null;
+	sampling: string | null;
+	thinking: string | null;
+}
+
+export interface Project {
+	project_id: string;
+	name: string;
+	root_path: string;
+	default_model: string | null;
+	allowed_tools: string | null;
+	git_origin: string | null;
+	created_at: string;
+	updated_at: string;
+}
+
+export interface Thinking {
+	effort?: string;
+	budget_tokens?: number;
 }
 
 export interface Usage {
@@ -25,6 +54,8 @@ export interface Usage {
 	cache_read_input_tokens: number;
 	output_tokens: number;
 	cost_usd: number;
+	reasoning_tokens?: number;
+	thinking?: Thinking | null;
 	model?: string;
 	start_time?: string | null;
 	end_time?: string | null;
@@ -49,4 +80,4 @@ export interface StreamResponseForTS {
 	agent_working: boolean;
 }
 
-export type MessageType = 'user' | 'agent' | 'tool' | 'error' | 'system' | 'gitinfo';
+export type MessageType = 'user' | 'agent' | 'tool' | 'error' | 'system' | 'gitinfo' | 'summary';
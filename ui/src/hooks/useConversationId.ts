@@ -0,0 +1,8 @@
+import { createContext, useContext } from "react";
+
+// ID of the conversation being shown, for components that act on it
+export const ConversationIdContext = createContext<string | null>(null);
+
+export function useConversationId(): string | null {
+  return useContext(ConversationIdContext);
+}
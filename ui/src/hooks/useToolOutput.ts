@@ -0,0 +1,12 @@
+import { createContext, useContext } from "react";
+
+// Output of running tools by tool use ID, streamed before their results arrive
+export const ToolOutputContext = createContext<Record<string, string>>({});
+
+// Keep at most this much live output per tool
+export const MAX_LIVE_TOOL_OUTPUT = 256 * 1024;
+
+export function useToolOutput(toolUseId?: string): string | undefined {
+  const outputs = useContext(ToolOutputContext);
+  return toolUseId ? outputs[toolUseId] : undefined;
+}
@@ -1,13 +1,53 @@
 import {
   Conversation,
+  Project,
   StreamResponse,
+  MessagePage,
+  Message,
   ChatRequest,
+  ChatResponse,
+  VoiceResponse,
   GitDiffInfo,
   GitFileInfo,
   GitFileDiff,
+  CoverageReport,
+  FileCoverage,
+  FileTree,
+  FileContent,
   Settings,
+  UserSettings,
+  SettingsVersion,
+  TokenCountRequest,
+  TokenCountResponse,
+  FileEdit,
+  MessageFeedback,
+  ConversationStats,
+  ConversationTimeline,
+  Artifact,
+  ConversationProcesses,
+  UpdateStatus,
+  MigrationStatus,
+  UploadGCReport,
+  ConversationFileChange,
+  ConversationFile,
+  ConversationTools,
+  ConversationToolsRequest,
+  ConversationShare,
+  NotifyKind,
+  Model,
+  Sampling,
+  Thinking,
 } from "../types";
 
+// audioExtension returns the file extension for a recording's MIME type,
+// which the transcription service uses to tell its format
+function audioExtension(mimeType: string): string {
+  if (mimeType.startsWith("audio/mp4")) return ".mp4";
+  if (mimeType.startsWith("audio/ogg")) return ".ogg";
+  if (mimeType.startsWith("audio/wav")) return ".wav";
+  return ".webm";
+}
+
 class ApiService {
   private baseUrl = "/api";
 
@@ -37,27 +77,153 @@ class ApiService {
     return response.json();
   }
 
-  async getConversation(conversationId: string): Promise<StreamResponse> {
-    const response = await fetch(`${this.baseUrl}/conversation/${conversationId}`);
+  async getConversation(conversationId: string, page?: MessagePage): Promise<StreamResponse> {
+    const params = new URLSearchParams();
+    if (page?.limit) params.set("limit", String(page.limit));
+    if (page?.before) params.set("before", page.before);
+    if (page?.after) params.set("after", page.after);
+    const query = params.toString() ? `?${params}` : "";
+    const response = await fetch(`${this.baseUrl}/conversation/${conversationId}${query}`);
     if (!response.ok) {
       throw new Error(`Failed to get messages: ${response.statusText}`);
     }
     return response.json();
   }
 
-  async sendMessage(conversationId: string, request: ChatRequest): Promise<void> {
+  // getFullMessage returns a message with the whole text of its tool results,
+  // of which the conversation's messages only carry previews (see LLMContent.Blob)
+  async getFullMessage(conversationId: string, messageId: string): Promise<Message> {
+    const response = await fetch(`${this.baseUrl}/conversation/${conversationId}/messages/${messageId}/full`);
+    if (!response.ok) {
+      throw new Error(`Failed to get message: ${response.statusText}`);
+    }
+    return response.json();
+  }
+
+  async sendMessage(conversationId: string, request: ChatRequest): Promise<ChatResponse> {
     const response = await fetch(`${this.baseUrl}/conversation/${conversationId}/chat`, {
       method: "POST",
       headers: this.postHeaders,
       body: JSON.stringify(request),
     });
     if (!response.ok) {
-      throw new Error(`Failed to send message: ${response.statusText}`);
+      const text = await response.text();
+      throw new Error(`Failed to send message: ${text.trim() || response.statusText}`);
     }
+    return response.json();
   }
 
-  createMessageStream(conversationId: string): EventSource {
-    return new EventSource(`${this.baseUrl}/conversation/${conversationId}/stream`);
+  // sendVoice transcribes a recording on the server and sends it as a message
+  async sendVoice(
+    conversationId: string,
+    audio: Blob,
+    options: { model?: string; queue?: boolean } = {},
+  ): Promise<VoiceResponse> {
+    const form = new FormData();
+    form.append("audio", audio, "voice" + audioExtension(audio.type));
+    if (options.model) form.append("model", options.model);
+    if (options.queue) form.append("queue", "true");
+    const response = await fetch(`${this.baseUrl}/conversation/${conversationId}/voice`, {
+      method: "POST",
+      headers: { "X-Shelley-Request": "1" },
+      body: form,
+    });
+    if (!response.ok) {
+      const text = await response.text();
+      throw new Error(`Failed to send voice message: ${text.trim() || response.statusText}`);
+    }
+    return response.json();
+  }
+
+  // removeQueuedMessage removes a queued message, or all of them if id is omitted
+  async removeQueuedMessage(conversationId: string, id?: string): Promise<void> {
+    const response = await fetch(`${this.baseUrl}/conversation/${conversationId}/queue/remove`, {
+      method: "POST",
+      headers: this.postHeaders,
+      body: JSON.stringify({ id }),
+    });
+    if (!response.ok && response.status !== 404) {
+      throw new Error(`Failed to remove queued message: ${response.statusText}`);
+    }
+  }
+
+  async getShares(conversationId: string): Promise<ConversationShare[]> {
+    const response = await fetch(`${this.baseUrl}/conversation/${conversationId}/shares`);
+    if (!response.ok) {
+      throw new Error(`Failed to get shares: ${response.statusText}`);
+    }
+    return response.json();
+  }
+
+  async createShare(conversationId: string): Promise<ConversationShare> {
+    const response = await fetch(`${this.baseUrl}/conversation/${conversationId}/shares`, {
+      method: "POST",
+      headers: this.postHeaders,
+    });
+    if (!response.ok) {
+      throw new Error(`Failed to create share: ${response.statusText}`);
+    }
+    return response.json();
+  }
+
+  async revokeShare(conversationId: string, token: string): Promise<void> {
+    const response = await fetch(
+      `${this.baseUrl}/conversation/${conversationId}/shares/${token}/revoke`,
+      {
+        method: "POST",
+        headers: this.postHeaders,
+      },
+    );
+    if (!response.ok && response.status !== 404) {
+      throw new Error(`Failed to revoke share: ${response.statusText}`);
+    }
+  }
+
+  // events: the events to notify about for this conversation, [] for none,
+  // or null to follow the global setting
+  async setConversationNotifications(
+    conversationId: string,
+    events: NotifyKind[] | null,
+  ): Promise<void> {
+    const response = await fetch(`${this.baseUrl}/conversation/${conversationId}/notifications`, {
+      method: "POST",
+      headers: this.postHeaders,
+      body: JSON.stringify({ events }),
+    });
+    if (!response.ok) {
+      throw new Error(`Failed to update notifications: ${response.statusText}`);
+    }
+  }
+
+  // sampling: the conversation's sampling parameters, or null for the
+  // model's defaults
+  async setConversationSampling(conversationId: string, sampling: Sampling | null): Promise<void> {
+    const response = await fetch(`${this.baseUrl}/conversation/${conversationId}/sampling`, {
+      method: "POST",
+      headers: this.postHeaders,
+      body: JSON.stringify(sampling),
+    });
+    if (!response.ok) {
+      throw new Error(`Failed to update sampling: ${response.statusText}`);
+    }
+  }
+
+  // thinking: the reasoning asked of the conversation's models, or null to
+  // leave it to them
+  async setConversationThinking(conversationId: string, thinking: Thinking | null): Promise<void> {
+    const response = await fetch(`${this.baseUrl}/conversation/${conversationId}/thinking`, {
+      method: "POST",
+      headers: this.postHeaders,
+      body: JSON.stringify(thinking),
+    });
+    if (!response.ok) {
+      throw new Error(`Failed to update thinking: ${response.statusText}`);
+    }
+  }
+
+  createMessageStream(conversationId: string, limit?: number): EventSource {
+    const query = limit ? `?limit=${limit}` : "";
+    return new EventSource(`${this.baseUrl}/conversation/${conversationId}/stream${query}`);
   }
 
   createConversationsStream(): EventSource {
@@ -74,7 +240,329 @@ class ApiService {
     }
   }
 
-  async validateCwd(path: string): Promise<{ valid: boolean; error?: string }> {
+  async cancelToolCall(conversationId: string, toolUseId: string): Promise<void> {
+    const response = await fetch(
+      `${this.baseUrl}/conversation/${conversationId}/tools/${encodeURIComponent(toolUseId)}/cancel`,
+      { method: "POST", headers: { "X-Shelley-Request": "1" } },
+    );
+    if (!response.ok) {
+      throw new Error(`Failed to cancel tool call: ${await response.text()}`);
+    }
+  }
+
+  async answerQuestion(conversationId: string, toolUseId: string, answer: string): Promise<void> {
+    const response = await fetch(`${this.baseUrl}/conversation/${conversationId}/answer`, {
+      method: "POST",
+      headers: this.postHeaders,
+      body: JSON.stringify({ tool_use_id: toolUseId, answer }),
+    });
+    if (!response.ok) {
+      const text = await response.text();
+      throw new Error(`Failed to answer question: ${text || response.statusText}`);
+    }
+  }
+
+  async getInterruptedConversations(): Promise<Conversation[]> {
+    const response = await fetch(`${this.baseUrl}/conversations/interrupted`);
+    if (!response.ok) {
+      throw new Error(`Failed to get interrupted conversations: ${response.statusText}`);
+    }
+    return response.json();
+  }
+
+  async resumeConversation(conversationId: string): Promise<void> {
+    const response = await fetch(`${this.baseUrl}/conversation/${conversationId}/resume`, {
+      method: "POST",
+      headers: { "X-Shelley-Request": "1" },
+    });
+    if (!response.ok) {
+      throw new Error(`Failed to resume conversation: ${response.statusText}`);
+    }
+  }
+
+  async rollbackConversation(
+    conversationId: string,
+    sequenceId: number,
+    restoreWorkspace: boolean,
+  ): Promise<{ status: string; backup_commit?: string }> {
+    const response = await fetch(`${this.baseUrl}/conversation/${conversationId}/rollback`, {
+      method: "POST",
+      headers: { "Content-Type": "application/json", "X-Shelley-Request": "1" },
+      body: JSON.stringify({ sequence_id: sequenceId, restore_workspace: restoreWorkspace }),
+    });
+    if (!response.ok) {
+      throw new Error(`Failed to roll back conversation: ${await response.text()}`);
+    }
+    return response.json();
+  }
+
+  async setMessageFeedback(
+    conversationId: string,
+    feedback: { message_id: string; rating?: "up" | "down"; comment?: string },
+  ): Promise<MessageFeedback> {
+    const response = await fetch(`${this.baseUrl}/conversation/${conversationId}/feedback`, {
+      method: "PUT",
+      headers: { "Content-Type": "application/json", "X-Shelley-Request": "1" },
+      body: JSON.stringify(feedback),
+    });
+    if (!response.ok) {
+      throw new Error(`Failed to record feedback: ${await response.text()}`);
+    }
+    return response.json();
+  }
+
+  async getConversationFeedback(conversationId: string): Promise<MessageFeedback[]> {
+    const response = await fetch(`${this.baseUrl}/conversation/${conversationId}/feedback`);
+    if (!response.ok) {
+      throw new Error(`Failed to get feedback: ${response.statusText}`);
+    }
+    return response.json();
+  }
+
+  async exportDataset(
+    conversationIds: string[],
+    format: "openai" | "anthropic" | "eval",
+  ): Promise<Blob> {
+    const response = await fetch(`${this.baseUrl}/conversations/export`, {
+      method: "POST",
+      headers: { "Content-Type": "application/json", "X-Shelley-Request": "1" },
+      body: JSON.stringify({ conversation_ids: conversationIds, format }),
+    });
+    if (!response.ok) {
+      throw new Error(`Failed to export dataset: ${await response.text()}`);
+    }
+    return response.blob();
+  }
+
+  async truncateConversation(conversationId: string, sequenceId: number): Promise<void> {
+    const response = await fetch(`${this.baseUrl}/conversation/${conversationId}/truncate`, {
+      method: "POST",
+      headers: { "Content-Type": "application/json", "X-Shelley-Request": "1" },
+      body: JSON.stringify({ sequence_id: sequenceId }),
+    });
+    if (!response.ok) {
+      throw new Error(`Failed to truncate conversation: ${await response.text()}`);
+    }
+  }
+
+  async deleteMessage(conversationId: string, sequenceId: number): Promise<void> {
+    const response = await fetch(
+      `${this.baseUrl}/conversation/${conversationId}/messages/${sequenceId}`,
+      {
+        method: "DELETE",
+        headers: { "X-Shelley-Request": "1" },
+      },
+    );
+    if (!response.ok) {
+      throw new Error(`Failed to delete message: ${await response.text()}`);
+    }
+  }
+
+  async editMessage(
+    conversationId: string,
+    request: {
+      sequence_id: number;
+      message: string;
+      model?: string;
+      fork?: boolean;
+      restore_workspace?: boolean;
+    },
+  ): Promise<{ status: string; conversation_id: string; backup_commit?: string }> {
+    const response = await fetch(`${this.baseUrl}/conversation/${conversationId}/edit`, {
+      method: "POST",
+      headers: { "Content-Type": "application/json", "X-Shelley-Request": "1" },
+      body: JSON.stringify(request),
+    });
+    if (!response.ok) {
+      throw new Error(`Failed to edit message: ${await response.text()}`);
+    }
+    return response.json();
+  }
+
+  async regenerate(
+    conversationId: string,
+    model?: string,
+  ): Promise<{ status: string; reverted: string[]; revert_error?: string }> {
+    const response = await fetch(`${this.baseUrl}/conversations/${conversationId}/regenerate`, {
+      method: "POST",
+      headers: { "Content-Type": "application/json", "X-Shelley-Request": "1" },
+      body: JSON.stringify(model ? { model } : {}),
+    });
+    if (!response.ok) {
+      throw new Error(`Failed to regenerate: ${await response.text()}`);
+    }
+    return response.json();
+  }
+
+  async getConversationStats(conversationId: string): Promise<ConversationStats> {
+    const response = await fetch(`${this.baseUrl}/conversations/${conversationId}/stats`);
+    if (!response.ok) {
+      throw new Error(`Failed to get conversation stats: ${response.statusText}`);
+    }
+    return response.json();
+  }
+
+  async getConversationTimeline(conversationId: string): Promise<ConversationTimeline> {
+    const response = await fetch(`${this.baseUrl}/conversations/${conversationId}/timeline`);
+    if (!response.ok) {
+      throw new Error(`Failed to get conversation timeline: ${response.statusText}`);
+    }
+    return response.json();
+  }
+
+  async getArtifacts(conversationId: string): Promise<Artifact[]> {
+    const response = await fetch(`${this.baseUrl}/conversations/${conversationId}/artifacts`);
+    if (!response.ok) {
+      throw new Error(`Failed to get artifacts: ${response.statusText}`);
+    }
+    return response.json();
+  }
+
+  async deleteArtifact(conversationId: string, artifactId: string): Promise<void> {
+    const response = await fetch(`${this.baseUrl}/conversations/${conversationId}/artifacts/${artifactId}`, {
+      method: "DELETE",
+      headers: { "X-Shelley-Request": "1" },
+    });
+    if (!response.ok) {
+      throw new Error(`Failed to delete artifact: ${response.statusText}`);
+    }
+  }
+
+  async getUpdate(): Promise<UpdateStatus> {
+    const response = await fetch(`${this.baseUrl}/update`);
+    if (!response.ok) {
+      throw new Error(`Failed to check for updates: ${response.statusText}`);
+    }
+    return response.json();
+  }
+
+  // Installs the latest release; the server restarts once it's downloaded
+  async installUpdate(): Promise<UpdateStatus> {
+    const response = await fetch(`${this.baseUrl}/update`, {
+      method: "POST",
+      headers: this.postHeaders,
+    });
+    if (!response.ok) {
+      throw new Error(await response.text());
+    }
+    return response.json();
+  }
+
+  async getMigrations(): Promise<MigrationStatus> {
+    const response = await fetch(`${this.baseUrl}/admin/migrations`);
+    if (!response.ok) {
+      throw new Error(`Failed to get migrations: ${response.statusText}`);
+    }
+    return response.json();
+  }
+
+  async getOrphanedUploads(): Promise<UploadGCReport> {
+    const response = await fetch(`${this.baseUrl}/admin/uploads/orphaned`);
+    if (!response.ok) {
+      throw new Error(`Failed to get orphaned uploads: ${response.statusText}`);
+    }
+    return response.json();
+  }
+
+  async getProcesses(conversationId?: string): Promise<ConversationProcesses[]> {
+    const query = conversationId ? `?conversation_id=${encodeURIComponent(conversationId)}` : "";
+    const response = await fetch(`${this.baseUrl}/processes${query}`);
+    if (!response.ok) {
+      throw new Error(`Failed to get processes: ${response.statusText}`);
+    }
+    return response.json();
+  }
+
+  // Follows a managed process's output as ProcessLogEvents: its latest
+  // output, then its output as it is written, until it exits
+  streamProcessLogs(conversationId: string, processId: string): EventSource {
+    return new EventSource(`${this.baseUrl}/processes/${conversationId}/${processId}/logs`);
+  }
+
+  async getFileEdits(conversationId: string): Promise<FileEdit[]> {
+    const response = await fetch(`${this.baseUrl}/conversation/${conversationId}/edits`);
+    if (!response.ok) {
+      throw new Error(`Failed to get file edits: ${response.statusText}`);
+    }
+    return response.json();
+  }
+
+  async revertFileEdits(
+    conversationId: string,
+    target: { edit_id: string } | { turn: number },
+  ): Promise<{ status: string; reverted: string[] }> {
+    const response = await fetch(`${this.baseUrl}/conversation/${conversationId}/edits/revert`, {
+      method: "POST",
+      headers: { "Content-Type": "application/json", "X-Shelley-Request": "1" },
+      body: JSON.stringify(target),
+    });
+    if (!response.ok) {
+      throw new Error(`Failed to revert file edits: ${await response.text()}`);
+    }
+    return response.json();
+  }
+
+  async getConversationChanges(conversationId: string): Promise<ConversationFileChange[]> {
+    const response = await fetch(`${this.baseUrl}/conversation/${conversationId}/changes`);
+    if (!response.ok) {
+      throw new Error(`Failed to get conversation changes: ${response.statusText}`);
+    }
+    return response.json();
+  }
+
+  async getConversationFiles(conversationId: string): Promise<ConversationFile[]> {
+    const response = await fetch(`${this.baseUrl}/conversations/${conversationId}/files`);
+    if (!response.ok) {
+      throw new Error(`Failed to get conversation files: ${response.statusText}`);
+    }
+    return response.json();
+  }
+
+  async getConversationFileChange(conversationId: string, path: string): Promise<GitFileDiff> {
+    const response = await fetch(
+      `${this.baseUrl}/conversation/${conversationId}/changes?path=${encodeURIComponent(path)}`,
+    );
+    if (!response.ok) {
+      throw new Error(`Failed to get file change: ${response.statusText}`);
+    }
+    return response.json();
+  }
+
+  async getModels(): Promise<Model[]> {
+    const response = await fetch(`${this.baseUrl}/models`);
+    if (!response.ok) {
+      throw new Error(`Failed to get models: ${response.statusText}`);
+    }
+    return response.json();
+  }
+
+  async getConversationTools(conversationId: string): Promise<ConversationTools> {
+    const response = await fetch(`${this.baseUrl}/conversation/${conversationId}/tools`);
+    if (!response.ok) {
+      throw new Error(`Failed to get conversation tools: ${response.statusText}`);
+    }
+    return response.json();
+  }
+
+  async setConversationTools(
+    conversationId: string,
+    request: ConversationToolsRequest,
+  ): Promise<ConversationTools> {
+    const response = await fetch(`${this.baseUrl}/conversation/${conversationId}/tools`, {
+      method: "POST",
+      headers: this.postHeaders,
+      body: JSON.stringify(request),
+    });
+    if (!response.ok) {
+      const text = await response.text();
+      throw new Error(`Failed to set conversation tools: ${text || response.statusText}`);
+    }
+    return response.json();
+  }
+
+  async validateCwd(
+    path: string,
+  ): Promise<{ valid: boolean; error?: string; devcontainer?: boolean }> {
     const response = await fetch(`${this.baseUrl}/validate-cwd?path=${encodeURIComponent(path)}`);
     if (!response.ok) {
       throw new Error(`Failed to validate cwd: ${response.statusText}`);
@@ -98,6 +586,34 @@ class ApiService {
     return response.json();
   }
 
+  // Lists a directory of a workspace, leaving out what git ignores
+  async getFileTree(cwd: string, path = "", offset = 0): Promise<FileTree> {
+    const params = new URLSearchParams({ cwd, path, offset: String(offset) });
+    const response = await fetch(`${this.baseUrl}/files/tree?${params}`);
+    if (!response.ok) {
+      const text = await response.text();
+      throw new Error(`Failed to list files: ${text || response.statusText}`);
+    }
+    return response.json();
+  }
+
+  // Reads a file of a workspace, or the lines start to end of it
+  async getFileContent(
+    cwd: string,
+    path: string,
+    range?: { start?: number; end?: number },
+  ): Promise<FileContent> {
+    const params = new URLSearchParams({ cwd, path });
+    if (range?.start) params.set("start", String(range.start));
+    if (range?.end) params.set("end", String(range.end));
+    const response = await fetch(`${this.baseUrl}/files/content?${params}`);
+    if (!response.ok) {
+      const text = await response.text();
+      throw new Error(`Failed to read file: ${text || response.statusText}`);
+    }
+    return response.json();
+  }
+
   async getArchivedConversations(): Promise<Conversation[]> {
     const response = await fetch(`${this.baseUrl}/conversations/archived`);
     if (!response.ok) {
@@ -106,6 +622,14 @@ class ApiService {
     return response.json();
   }
 
+  async getProjects(): Promise<Project[]> {
+    const response = await fetch(`${this.baseUrl}/projects`);
+    if (!response.ok) {
+      throw new Error(`Failed to get projects: ${response.statusText}`);
+    }
+    return response.json();
+  }
+
   async archiveConversation(conversationId: string): Promise<Conversation> {
     const response = await fetch(`${this.baseUrl}/conversation/${conversationId}/archive`, {
       method: "POST",
@@ -117,6 +641,18 @@ class ApiService {
     return response.json();
   }
 
+  async pinConversation(conversationId: string, pinned: boolean): Promise<Conversation> {
+    const action = pinned ? "pin" : "unpin";
+    const response = await fetch(`${this.baseUrl}/conversation/${conversationId}/${action}`, {
+      method: "POST",
+      headers: { "X-Shelley-Request": "1" },
+    });
+    if (!response.ok) {
+      throw new Error(`Failed to ${action} conversation: ${response.statusText}`);
+    }
+    return response.json();
+  }
+
   async unarchiveConversation(conversationId: string): Promise<Conversation> {
     const response = await fetch(`${this.baseUrl}/conversation/${conversationId}/unarchive`, {
       method: "POST",
@@ -168,10 +704,35 @@ class ApiService {
     return response.json();
   }
 
-  async renameConversation(conversationId: string, slug: string): Promise<Conversation> {
-    const response = await fetch(`${this.baseUrl}/conversation/${conversationId}/rename`, {
+  // Runs go test with coverage for a package pattern (default ./...) and
+  // stores the profile for the repository containing cwd
+  async runCoverage(cwd: string, path?: string): Promise<CoverageReport> {
+    const response = await fetch(`${this.baseUrl}/coverage`, {
       method: "POST",
       headers: this.postHeaders,
+      body: JSON.stringify({ cwd, path }),
+    });
+    if (!response.ok) {
+      const text = await response.text();
+      throw new Error(text || response.statusText);
+    }
+    return response.json();
+  }
+
+  async getFileCoverage(cwd: string, filePath: string): Promise<FileCoverage> {
+    const response = await fetch(
+      `${this.baseUrl}/coverage/file?cwd=${encodeURIComponent(cwd)}&path=${encodeURIComponent(filePath)}`,
+    );
+    if (!response.ok) {
+      throw new Error(`Failed to get file coverage: ${response.statusText}`);
+    }
+    return response.json();
+  }
+
+  async renameConversation(conversationId: string, slug: string): Promise<Conversation> {
+    const response = await fetch(`${this.baseUrl}/conversations/${conversationId}/slug`, {
+      method: "PATCH",
+      headers: this.postHeaders,
       body: JSON.stringify({ slug }),
     });
     if (!response.ok) {
@@ -180,6 +741,29 @@ class ApiService {
     return response.json();
   }
 
+  async regenerateSlug(conversationId: string): Promise<Conversation> {
+    const response = await fetch(`${this.baseUrl}/conversation/${conversationId}/slug/regenerate`, {
+      method: "POST",
+      headers: this.postHeaders,
+    });
+    if (!response.ok) {
+      throw new Error(`Failed to regenerate title: ${response.statusText}`);
+    }
+    return response.json();
+  }
+
+  async countTokens(request: TokenCountRequest): Promise<TokenCountResponse> {
+    const response = await fetch(`${this.baseUrl}/tokens/count`, {
+      method: "POST",
+      headers: this.postHeaders,
+      body: JSON.stringify(request),
+    });
+    if (!response.ok) {
+      throw new Error(`Failed to count tokens: ${response.statusText}`);
+    }
+    return response.json();
+  }
+
   // Settings APIs
   async getSettings(): Promise<Settings> {
     const response = await fetch(`${this.baseUrl}/settings`);
@@ -196,7 +780,67 @@ class ApiService {
       body: JSON.stringify(settings),
     });
     if (!response.ok) {
-      throw new Error(`Failed to update settings: ${response.statusText}`);
+      throw new Error(`Failed to update settings: ${(await response.text()) || response.statusText}`);
+    }
+    return response.json();
+  }
+
+  async getSettingsHistory(limit?: number): Promise<SettingsVersion[]> {
+    const query = limit ? `?limit=${limit}` : "";
+    const response = await fetch(`${this.baseUrl}/settings/history${query}`);
+    if (!response.ok) {
+      throw new Error(`Failed to get settings history: ${response.statusText}`);
+    }
+    return response.json();
+  }
+
+  async revertSettings(id: number): Promise<Settings> {
+    const response = await fetch(`${this.baseUrl}/settings/history/${id}/revert`, {
+      method: "POST",
+      headers: this.postHeaders,
+    });
+    if (!response.ok) {
+      throw new Error(`Failed to revert settings: ${(await response.text()) || response.statusText}`);
+    }
+    return response.json();
+  }
+
+  async exportSettings(): Promise<Blob> {
+    const response = await fetch(`${this.baseUrl}/settings/export`);
+    if (!response.ok) {
+      throw new Error(`Failed to export settings: ${response.statusText}`);
+    }
+    return response.blob();
+  }
+
+  async importSettings(data: string): Promise<Settings> {
+    const response = await fetch(`${this.baseUrl}/settings/import`, {
+      method: "POST",
+      headers: this.postHeaders,
+      body: data,
+    });
+    if (!response.ok) {
+      throw new Error(`Failed to import settings: ${(await response.text()) || response.statusText}`);
+    }
+    return response.json();
+  }
+
+  async getUserSettings(): Promise<UserSettings> {
+    const response = await fetch(`${this.baseUrl}/user/settings`);
+    if (!response.ok) {
+      throw new Error(`Failed to get user settings: ${response.statusText}`);
+    }
+    return response.json();
+  }
+
+  async updateUserSettings(settings: UserSettings): Promise<UserSettings> {
+    const response = await fetch(`${this.baseUrl}/user/settings`, {
+      method: "POST",
+      headers: this.postHeaders,
+      body: JSON.stringify(settings),
+    });
+    if (!response.ok) {
+      throw new Error(`Failed to update user settings: ${(await response.text()) || response.statusText}`);
     }
     return response.json();
   }
@@ -1,6 +1,7 @@
 // Types for Shelley UI
 import {
   Conversation as GeneratedConversation,
+  Project as GeneratedProject,
   ApiMessageForTS,
   StreamResponseForTS,
   Usage as GeneratedUsage,
@@ -9,6 +10,7 @@ import {
 
 // Re-export generated types
 export type Conversation = GeneratedConversation;
+export type Project = GeneratedProject;
 export type Usage = GeneratedUsage;
 export type MessageType = GeneratedMessageType;
 
@@ -42,25 +44,302 @@ export interface LLMContent {
   ToolUseEndTime?: string | null;
   Display?: unknown;
   Cache?: boolean;
+  Blob?: string; // set when Text is only a preview; getFullMessage loads the whole
 }
 
 // API types
+// ProviderHealth is the latest health check of a model's provider
+export interface ProviderHealth {
+  available: boolean;
+  latency_ms: number;
+  error?: string;
+  checked_at: string;
+}
+
+// ModelPricing is in USD per million tokens
+export interface ModelPricing {
+  input: number;
+  output: number;
+  cache_read?: number;
+  cache_write?: number;
+}
+
 export interface Model {
   id: string;
   ready: boolean;
   max_context_tokens?: number;
+  provider?: string;
+  description?: string;
+  vision?: boolean;
+  tools?: boolean;
+  thinking?: boolean; // takes a thinking effort or budget
+  pricing?: ModelPricing;
+  health?: ProviderHealth;
+}
+
+export interface SandboxOptions {
+  enabled: boolean;
+  allow_network?: boolean;
+  writable_paths?: string[];
+  readable_paths?: string[];
+}
+
+export interface RemoteWorkspace {
+  host: string; // ssh destination: [user@]host or a Host from ~/.ssh/config
+  port?: number;
+  identity_file?: string;
 }
 
 export interface ChatRequest {
   message: string;
   model?: string;
   cwd?: string;
+  project_id?: string; // new conversations only; cwd, model and tools default to the project's
+  sandbox?: SandboxOptions; // new conversations only; overrides the server default
+  remote?: RemoteWorkspace; // new conversations only; runs the tools over SSH in cwd on the host
+  devcontainer?: boolean; // new conversations only; runs the tools in cwd's dev container
+  allowed_tools?: string[]; // new conversations only
+  tool_preset?: ToolPreset; // new conversations only; replaces allowed_tools
+  queue?: boolean; // hold the message until the agent's current turn ends
+  sampling?: Sampling; // new conversations only
+  thinking?: Thinking; // for the turn this message starts
+}
+
+// Reasoning asked of models that take it: Anthropic's take a token budget,
+// OpenAI's an effort, each derived from the other if unset
+export interface Thinking {
+  effort?: "low" | "medium" | "high";
+  budget_tokens?: number; // at least 1024
+}
+
+// Sampling parameters of a conversation's LLM requests; unset fields keep
+// the model's defaults
+export interface Sampling {
+  temperature?: number; // 0 for deterministic runs
+  top_p?: number;
+  max_tokens?: number; // maximum output tokens
+  stop_sequences?: string[];
+}
+
+// Response to POST /conversation/<id>/chat
+export type ChatResponse =
+  | { status: "accepted" }
+  | { status: "queued"; queue: MessageQueue }
+  | SlashCommandResponse;
+
+// Response to a voice message: the chat response and what was heard
+export type VoiceResponse = (
+  | { status: "accepted" }
+  | { status: "queued"; queue: MessageQueue }
+) & { transcript: string };
+
+// Response to a chat message that ran a slash command such as /help
+export interface SlashCommandResponse {
+  status: "command";
+  command: string;
+  output: string;
+  model?: string; // the conversation's model, set by /model
+  conversation_id?: string; // the conversation created by /fork
+}
+
+export type ToolPreset = "all" | "read_only";
+
+export interface ConversationToolsRequest {
+  allowed_tools?: string[] | null; // null allows every tool
+  preset?: ToolPreset;
+}
+
+export interface ConversationTools {
+  allowed_tools: string[] | null; // null if every tool is allowed
+  available: string[];
+}
+
+// A read-only link to a conversation, served at path
+export interface ConversationShare {
+  token: string;
+  conversation_id: string;
+  user_id: string | null;
+  created_at: string;
+  path: string;
+}
+
+export interface TokenCountRequest {
+  conversation_id?: string;
+  message: string;
+  model?: string;
+  cwd?: string;
+}
+
+export interface TokenCountResponse {
+  model: string;
+  conversation_tokens: number;
+  message_tokens: number;
+  total_tokens: number;
+  context_window: number;
+  exceeds_context_window: boolean;
 }
+
+// FileEdit is a recorded change to a file made by the patch tool or the diff viewer
+export interface FileEdit {
+  edit_id: string;
+  turn: number; // sequence ID of the user message that started the turn
+  tool_use_id?: string;
+  source: "patch" | "write_file" | "bash";
+  path: string;
+  new_file: boolean;
+  deleted_file: boolean;
+  diff: string;
+  reverted_at?: string;
+  created_at: string;
+}
+
+// ConversationStats summarizes a conversation
+export interface ConversationStats {
+  turns: number;
+  tool_calls: Record<string, number>;
+  input_tokens: number;
+  cache_creation_input_tokens: number;
+  cache_read_input_tokens: number;
+  output_tokens: number;
+  cost_usd: number;
+  started_at?: string;
+  ended_at?: string;
+  duration_seconds: number;
+  files_touched: string[];
+}
+
+// TimelineSpan is a timed LLM call or tool execution
+export interface TimelineSpan {
+  kind: "llm" | "tool";
+  label: string; // the model, or the tool and its main argument
+  tool_use_id?: string;
+  sequence_id: number;
+  started_at: string;
+  ended_at: string;
+  duration_ms: number;
+  error?: boolean;
+}
+
+// TimelineTurn is a turn of a conversation and where its time went
+export interface TimelineTurn {
+  sequence_id: number;
+  started_at: string;
+  ended_at: string;
+  duration_ms: number;
+  llm_ms: number;
+  tool_ms: number;
+  spans: TimelineSpan[];
+}
+
+// ConversationTimeline breaks a conversation's turns down into timed spans
+export interface ConversationTimeline {
+  turns: TimelineTurn[];
+}
+
+// Artifact is a file the agent published to a conversation for download
+export interface Artifact {
+  id: string;
+  name: string;
+  content_type: string;
+  size: number;
+  sha256: string;
+  created_at: string;
+  expires_at: string;
+  url: string; // where it's downloaded
+}
+
+// ManagedProcess is a long-running process started with the process tool
+export interface ManagedProcess {
+  id: string;
+  name: string;
+  command: string;
+  dir: string;
+  pid: number;
+  log_file: string;
+  started_at: string;
+  running: boolean;
+  ports?: number[]; // TCP ports it listens on; see /preview/<conversation>/
+  exited_at?: string;
+  exit_code?: number; // -1 if killed by a signal
+  stopped?: boolean; // stopped with the tool
+}
+
+// ConversationProcesses lists a conversation's long-running processes
+export interface ConversationProcesses {
+  conversation_id: string;
+  processes: ManagedProcess[];
+}
+
+// ProcessLogEvent is an event of a managed process's log stream
+export interface ProcessLogEvent {
+  output?: string;
+  process?: ManagedProcess; // sent first, and once the process has exited
+}
+
+// MessageFeedback is a rating and/or comment on an agent message
+export interface MessageFeedback {
+  message_id: string;
+  conversation_id: string;
+  model: string | null; // the model that generated the message, if known
+  rating: "up" | "down" | null;
+  comment: string | null;
+  created_at: string;
+  updated_at: string;
+}
+
+// ConversationFileChange is the net change a conversation made to a file
+export interface ConversationFileChange extends GitFileInfo {
+  sources: FileEdit["source"][];
+  turns: number[];
+}
+
+// ConversationFile is a file a conversation's tools touched, from
+// /api/conversations/<id>/files
+export interface ConversationFile {
+  path: string;
+  status: "added" | "modified" | "deleted" | "unchanged";
+  edits: number;
+  sources: FileEdit["source"][];
+  turns: number[];
+  first_edited_at: string;
+  last_edited_at: string;
+  diff_url?: string; // content before and after, unless unchanged
+  view_url?: string; // opens the diff viewer on it, unless unchanged
+}
+// ToolOutput is output of a running tool, streamed before its result
+export interface ToolOutput {
+  tool_use_id: string;
+  output: string;
+  replace?: boolean; // output is everything so far rather than what is new
+}
+
 // StreamResponse represents the streaming response format
 export interface StreamResponse extends Omit<StreamResponseForTS, "messages"> {
   messages: Message[];
   context_window_size?: number;
   asset_hash?: string;
+  tool_output?: ToolOutput; // set on updates carrying only a running tool's output
+  queue?: MessageQueue; // set on updates carrying only the queued messages
+  has_more?: boolean; // set on pages of messages with more in the direction read
+}
+
+// MessagePage selects part of a conversation's messages: the limit messages
+// before or after a message, or the latest ones without either
+export interface MessagePage {
+  limit?: number;
+  before?: string;
+  after?: string;
+}
+
+// QueuedMessage is a message waiting for the agent's turn to end
+export interface QueuedMessage {
+  id: string;
+  message: string;
+  error?: string; // why the message couldn't be sent; it won't be retried
+}
+
+export interface MessageQueue {
+  messages: QueuedMessage[];
 }
 
 // Link represents a custom link that can be added to the UI
@@ -79,6 +358,7 @@ export interface InitData {
   hostname?: string;
   terminal_url?: string;
   links?: Link[];
+  voice_input?: boolean;
 }
 
 // Extend Window interface to include our init data
@@ -99,6 +379,39 @@ export interface GitDiffInfo {
   deletions: number;
 }
 
+// Entry of a workspace listing, from /api/files/tree
+export interface FileTreeEntry {
+  name: string;
+  path: string; // relative to the workspace
+  is_dir: boolean;
+  is_symlink?: boolean;
+  size: number;
+  mod_time: string;
+}
+
+// A page of a directory's entries, directories first
+export interface FileTree {
+  cwd: string;
+  path: string;
+  entries: FileTreeEntry[];
+  total: number;
+  next_offset?: number; // absent on the last page
+}
+
+// A file of a workspace, or a range of its lines, from /api/files/content
+export interface FileContent {
+  path: string;
+  language: string; // Monaco language ID
+  size: number;
+  mod_time: string;
+  binary: boolean;
+  content?: string; // absent for binary files
+  start_line?: number;
+  end_line?: number;
+  total_lines: number;
+  truncated?: boolean;
+}
+
 export interface GitFileInfo {
   path: string;
   status: "added" | "modified" | "deleted";
@@ -110,6 +423,36 @@ export interface GitFileDiff {
   path: string;
   oldContent: string;
   newContent: string;
+  newSha256?: string; // absent if the file doesn't exist
+}
+
+// Coverage of one file, from the last coverage run of its repository
+export interface CoverageFileSummary {
+  path: string; // relative to the repository, like GitFileInfo.path
+  statements: number;
+  covered: number;
+  percent: number;
+}
+
+export interface CoverageReport {
+  gitRoot: string;
+  cwd: string;
+  path: string;
+  createdAt: string;
+  passed: boolean;
+  output?: string; // the end of go test's output, if it failed
+  statements: number;
+  covered: number;
+  percent: number;
+  files: CoverageFileSummary[];
+}
+
+export interface FileCoverage extends CoverageFileSummary {
+  createdAt: string;
+  stale: boolean; // the file changed after coverage was run
+  coveredLines: number[];
+  uncoveredLines: number[];
+  partialLines: number[];
 }
 
 // Comment for diff viewer
@@ -148,12 +491,209 @@ export interface UISettings {
   expansionBehavior?: "single" | "all";
   // "send" (default): normal send, button disabled while agent is working
   // "stop_and_send": automatically stop agent and send new message
-  enterBehavior?: "send" | "stop_and_send";
+  // "queue": hold the message and send it when the agent's turn ends
+  enterBehavior?: EnterBehavior;
+}
+
+export type EnterBehavior = "send" | "stop_and_send" | "queue";
+
+// Tool settings; changes apply to conversations whose loop starts afterwards
+export interface BashToolSettings {
+  // Go durations such as "30s"; empty means the default
+  fastTimeout?: string;
+  slowTimeout?: string;
+  backgroundTimeout?: string;
+  maxOutputBytes?: number; // 0 or unset means the default
+  truncate?: "head_tail" | "head" | "tail";
+}
+
+export interface ToolsSettings {
+  enabled?: Record<string, boolean>; // tools not listed are enabled
+  bash?: BashToolSettings;
+  deploy?: Record<string, DeployTargetSettings>; // targets of the deploy tool, by name
 }
 
+export interface DeployTargetSettings {
+  command: string;
+  dir?: string; // absolute; empty means the conversation's working directory
+  env?: Record<string, string>;
+  production?: boolean; // deploys wait for the user to confirm
+  detach?: boolean; // run without waiting, for deploys that restart Shelley
+  healthUrl?: string; // must answer within healthTimeout, or rollback runs
+  healthTimeout?: string; // Go duration; empty means 1m
+  rollback?: string; // $SHELLEY_PREVIOUS_BINARY is the binary a detached deploy replaced
+}
+
+// Policy for files written from the UI; nothing is written inside .git
+export interface WriteFileSettings {
+  allowedRoots?: string[]; // empty means git working trees
+  deniedGlobs?: string[]; // without a slash, match any path element
+  maxBytes?: number; // 0 means 10MB
+}
+
+// Server-wide settings
 export interface Settings {
   guardian?: GuardianSettings;
+  tools?: ToolsSettings;
+  slug?: SlugSettings;
+  writeFile?: WriteFileSettings;
+  notifications?: NotificationSettings;
+  bots?: BotSettings;
+  update?: UpdateSettings;
+}
+
+// Updating Shelley from its releases, checked at repo's or url's latest release
+export interface UpdateSettings {
+  repo?: string; // GitHub repository, "owner/name"
+  url?: string; // serves the latest release in the shape of GitHub's release API
+  publicKey?: string; // base64 ed25519 key the checksums must be signed with
+  allowUnsigned?: boolean; // without publicKey, verify releases by their checksums only
+  auto?: boolean; // install newer releases as they're found
+  install?: string; // installs $SHELLEY_UPDATE_BINARY; empty means deploy-daemon
+  rollback?: string; // reinstalls $SHELLEY_PREVIOUS_BINARY
+}
+
+// A Shelley release with a binary for the server's platform
+export interface Release {
+  version: string;
+  commit?: string;
+  published: string;
+  notes?: string;
+  page?: string;
+  asset: string;
+}
+
+// Record of a deploy, or update, that restarted Shelley
+export interface DeployRecord {
+  target: { name: string; command: string };
+  health_url: string;
+  started: string;
+  status: "running" | "healthy" | "failed" | "unhealthy" | "rolled_back" | "rollback_failed";
+  detail?: string;
+  finished?: string;
+}
+
+// Response of /api/update
+export interface UpdateStatus {
+  current: { commit?: string; commit_time?: string; modified?: boolean };
+  configured: boolean;
+  auto?: boolean;
+  latest?: Release;
+  error?: string; // of checking for the latest release
+  available: boolean;
+  last?: DeployRecord; // the latest update installed
+}
+
+// A database migration, from /api/admin/migrations
+export interface Migration {
+  number: number;
+  name: string;
+  applied: boolean;
+  applied_at?: string;
+  reversible: boolean; // has a down path
+  unknown?: boolean; // applied by a newer build
+}
+
+// Response of /api/admin/migrations
+export interface MigrationStatus {
+  version: number; // latest migration applied
+  latest: number; // latest migration this build has
+  pending: number;
+  migrations: Migration[];
+}
+
+// An uploaded file no message mentions
+export interface OrphanedUpload {
+  path: string;
+  size: number;
+  modified_at: string;
+}
+
+// Response of /api/admin/uploads/orphaned
+export interface UploadGCReport {
+  orphaned: OrphanedUpload[];
+  orphaned_bytes: number;
+  kept: number; // mentioned, or too recent to remove
+  dry_run: boolean;
+}
+
+// Events notifications are sent about
+export type NotifyKind = "turn_end" | "error" | "question";
+
+// Notifications about conversations, sent through every service that is set
+export interface NotificationSettings {
+  events?: NotifyKind[]; // empty means all
+  baseUrl?: string; // address Shelley is reached at, for links
+  ntfy?: { server?: string; topic: string; token?: string };
+  pushover?: { token: string; user: string };
+  email?: {
+    host: string;
+    port?: number;
+    username?: string;
+    password?: string;
+    from: string;
+    to: string[];
+  };
+  slack?: { webhookUrl: string };
+}
+
+// Chat bots that run conversations from chat apps
+export interface BotSettings {
+  slack?: SlackBotSettings;
+  discord?: DiscordBotSettings;
+  telegram?: TelegramBotSettings;
+}
+
+// How a bot starts conversations
+export interface BotConversationSettings {
+  cwd?: string;
+  model?: string; // empty means the server default
+}
+
+// Slack bot, receiving events at /hooks/slack/events
+export interface SlackBotSettings extends BotConversationSettings {
+  botToken: string;
+  signingSecret: string;
+  channels?: string[]; // channel IDs where every message goes to the agent
+  users: string[]; // the only user IDs the bot answers
+}
+
+// Discord bot, connected through the Discord gateway
+export interface DiscordBotSettings extends BotConversationSettings {
+  token: string;
+  channels?: string[]; // channel IDs where every message starts a thread
+  users: string[]; // the only user IDs the bot answers
+}
+
+// Telegram bot for personal use, polling Telegram for messages
+export interface TelegramBotSettings extends BotConversationSettings {
+  token: string;
+  users: number[]; // the only user IDs the bot answers
+}
+
+// A saved version of the server-wide settings
+export interface SettingsVersion {
+  id: number;
+  settings: Settings;
+  user_id?: string;
+  created_at: string;
+}
+
+// Settings of the current user
+export interface UserSettings {
   ui?: UISettings;
+  defaultModel?: string; // empty means the server default
+}
+
+// Conversation title generation
+export interface SlugSettings {
+  // e.g. "{date}-{branch}-{title}"; variables are {title}, {date}, {branch},
+  // {repo} and {dir}. Empty means the title alone.
+  template?: string;
+  // Models tried in order; empty means the built-in list.
+  models?: string[];
+  // Replaces the built-in prompt; {message} is the first message.
+  prompt?: string;
 }
 
 // Tool call data for grouping tools
@@ -0,0 +1,390 @@
+// Package update finds newer Shelley releases and downloads them, verifying
+// them against the release's checksums and the checksums' signature with the
+// configured public key. Without a key, releases are only checked against
+// their checksums, which must be allowed explicitly: whoever can change the
+// release can change its checksums too.
+//
+// A release is described as GitHub's release API describes it, so that a
+// GitHub repository's releases work as they are, and any other URL serving
+// the same JSON does too. A release carries, as assets:
+//
+//	shelley-<GOOS>-<GOARCH>  the binary, e.g. shelley-linux-amd64
+//	checksums.txt            sha256sum output covering the binaries
+//	checksums.txt.sig        ed25519 signature of checksums.txt, raw or base64
+//
+// The release's JSON isn't signed, so checksums.txt also describes the
+// release, in comment lines sha256sum -c skips:
+//
+//	# version: v1.2.0
+//	# published: 2026-10-01T12:00:00Z
+//	# commit: 0123abc (optional)
+//
+// With a public key, the version must match the release's tag, and whether
+// a release is newer is decided from these lines only, so a signed older
+// release can't be offered as a newer one.
+package update
+
+import (
+	"bufio"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"time"
+
+	"shelley.exe.dev/version"
+)
+
+const (
+	// ChecksumsAsset is the release asset holding the binaries' checksums.
+	ChecksumsAsset = "checksums.txt"
+	// SignatureAsset is the release asset holding the checksums' signature.
+	SignatureAsset = ChecksumsAsset + ".sig"
+	// maxManifestBytes caps the release description and checksums.
+	maxManifestBytes = 1 << 20
+)
+
+// Source is where releases are found.
+type Source struct {
+	// URL serves the latest release as JSON in the shape of GitHub's
+	// release API. If empty, Repo's latest GitHub release is used.
+	URL string
+	// Repo is a GitHub repository, "owner/name".
+	Repo string
+	// PublicKey is a base64 ed25519 public key. Releases must carry a
+	// signature of their checksums made with it.
+	PublicKey string
+	// AllowUnsigned allows a source without PublicKey, whose releases are
+	// only verified against their checksums.
+	AllowUnsigned bool
+	// Client makes the requests; nil means one with a timeout.
+	Client *http.Client
+}
+
+// Release is a Shelley release with a binary for this platform.
+type Release struct {
+	Version   string    `json:"version"`
+	Commit    string    `json:"commit,omitempty"`
+	Published time.Time `json:"published"`
+	Notes     string    `json:"notes,omitempty"`
+	// Page is the release's web page, if it has one.
+	Page string `json:"page,omitempty"`
+	// Asset is the name of the binary for this platform.
+	Asset string `json:"asset"`
+
+	assets    map[string]string // download URLs by name
+	checksums []byte            // checksums.txt, verified as Latest found it
+}
+
+// githubRelease is the part of GitHub's release API response that's used.
+type githubRelease struct {
+	TagName         string    `json:"tag_name"`
+	TargetCommitish string    `json:"target_commitish"`
+	PublishedAt     time.Time `json:"published_at"`
+	Body            string    `json:"body"`
+	HTMLURL         string    `json:"html_url"`
+	Assets          []struct {
+		Name string `json:"name"`
+		URL  string `json:"browser_download_url"`
+	} `json:"assets"`
+}
+
+var (
+	repoPattern   = regexp.MustCompile(`^[A-Za-z0-9_.-]+/[A-Za-z0-9_.-]+$`)
+	commitPattern = regexp.MustCompile(`^[0-9a-f]{7,40}$`)
+)
+
+// AssetName returns the name of the release asset holding the binary for
+// this platform.
+func AssetName() string {
+	return fmt.Sprintf("shelley-%s-%s", runtime.GOOS, runtime.GOARCH)
+}
+
+// Validate checks the source's settings.
+func (s *Source) Validate() error {
+	if s.URL == "" && s.Repo == "" {
+		return errors.New("update source needs a URL or a GitHub repository")
+	}
+	if s.URL != "" && !strings.HasPrefix(s.URL, "https://") && !strings.HasPrefix(s.URL, "http://") {
+		return fmt.Errorf("invalid update URL %q: must be http or https", s.URL)
+	}
+	if s.URL == "" && !repoPattern.MatchString(s.Repo) {
+		return fmt.Errorf("invalid update repository %q: must be owner/name", s.Repo)
+	}
+	if s.PublicKey == "" && !s.AllowUnsigned {
+		return errors.New("update source needs a public key to verify releases' signatures, unless unsigned releases are allowed")
+	}
+	if s.PublicKey != "" {
+		if _, err := s.publicKey(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Source) publicKey() (ed25519.PublicKey, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s.PublicKey))
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return nil, errors.New("invalid update public key: must be a base64 ed25519 public key")
+	}
+	return ed25519.PublicKey(key), nil
+}
+
+func (s *Source) client() *http.Client {
+	if s.Client != nil {
+		return s.Client
+	}
+	return &http.Client{Timeout: 5 * time.Minute}
+}
+
+// Latest returns the latest release. It fails if the release has no binary
+// for this platform or no checksums.
+func (s *Source) Latest(ctx context.Context) (*Release, error) {
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	url := s.URL
+	if url == "" {
+		url = "https://api.github.com/repos/" + s.Repo + "/releases/latest"
+	}
+	data, err := s.get(ctx, url, maxManifestBytes)
+	if err != nil {
+		return nil, err
+	}
+	var gh githubRelease
+	if err := json.Unmarshal(data, &gh); err != nil {
+		return nil, fmt.Errorf("failed to parse release: %w", err)
+	}
+	if gh.TagName == "" {
+		return nil, errors.New("release has no tag")
+	}
+	release := &Release{
+		Version:   gh.TagName,
+		Published: gh.PublishedAt,
+		Notes:     gh.Body,
+		Page:      gh.HTMLURL,
+		Asset:     AssetName(),
+		assets:    make(map[string]string),
+	}
+	if commitPattern.MatchString(gh.TargetCommitish) {
+		release.Commit = gh.TargetCommitish
+	}
+	for _, asset := range gh.Assets {
+		release.assets[asset.Name] = asset.URL
+	}
+	for _, name := range []string{release.Asset, ChecksumsAsset} {
+		if release.assets[name] == "" {
+			return nil, fmt.Errorf("release %s has no %s", release.Version, name)
+		}
+	}
+	if s.PublicKey != "" && release.assets[SignatureAsset] == "" {
+		return nil, fmt.Errorf("release %s has no %s", release.Version, SignatureAsset)
+	}
+	if err := s.verifyChecksums(ctx, release); err != nil {
+		return nil, fmt.Errorf("release %s: %w", release.Version, err)
+	}
+	return release, nil
+}
+
+// verifyChecksums fetches the release's checksums, verifies their signature
+// if the source has a public key, and takes the release's version, commit
+// and publication time from them. A signed release must have them all but
+// the commit; an unsigned one keeps what its JSON says for the ones missing.
+func (s *Source) verifyChecksums(ctx context.Context, release *Release) error {
+	checksums, err := s.get(ctx, release.assets[ChecksumsAsset], maxManifestBytes)
+	if err != nil {
+		return err
+	}
+	signed := s.PublicKey != ""
+	if signed {
+		key, err := s.publicKey()
+		if err != nil {
+			return err
+		}
+		sig, err := s.get(ctx, release.assets[SignatureAsset], maxManifestBytes)
+		if err != nil {
+			return err
+		}
+		if !ed25519.Verify(key, checksums, decodeSignature(sig)) {
+			return fmt.Errorf("%s has an invalid signature", ChecksumsAsset)
+		}
+	}
+	fields := manifestFields(checksums)
+	switch v := fields["version"]; {
+	case v != "" && v != release.Version:
+		return fmt.Errorf("%s is of version %s", ChecksumsAsset, v)
+	case v == "" && signed:
+		return fmt.Errorf("%s has no version", ChecksumsAsset)
+	}
+	if published := fields["published"]; published != "" {
+		t, err := time.Parse(time.RFC3339, published)
+		if err != nil {
+			return fmt.Errorf("%s has an invalid publication time %q", ChecksumsAsset, published)
+		}
+		release.Published = t
+	} else if signed {
+		return fmt.Errorf("%s has no publication time", ChecksumsAsset)
+	}
+	switch commit := fields["commit"]; {
+	case commitPattern.MatchString(commit):
+		release.Commit = commit
+	case commit != "":
+		return fmt.Errorf("%s has an invalid commit %q", ChecksumsAsset, commit)
+	case signed:
+		release.Commit = ""
+	}
+	release.checksums = checksums
+	return nil
+}
+
+// manifestFields returns the "# key: value" lines of checksums.
+func manifestFields(checksums []byte) map[string]string {
+	fields := make(map[string]string)
+	scanner := bufio.NewScanner(strings.NewReader(string(checksums)))
+	for scanner.Scan() {
+		line, ok := strings.CutPrefix(strings.TrimSpace(scanner.Text()), "#")
+		if !ok {
+			continue
+		}
+		if key, value, ok := strings.Cut(line, ":"); ok {
+			fields[strings.ToLower(strings.TrimSpace(key))] = strings.TrimSpace(value)
+		}
+	}
+	return fields
+}
+
+// NewerThan reports whether the release is newer than the build described
+// by info: it was published after the build's commit, and isn't of the same
+// commit. With a public key, both come from the signed checksums. Builds
+// without version information are never older.
+func (r *Release) NewerThan(info version.Info) bool {
+	if info.Commit == "" || info.CommitTime == "" {
+		return false
+	}
+	if r.Commit != "" && (strings.HasPrefix(info.Commit, r.Commit) || strings.HasPrefix(r.Commit, info.Commit)) {
+		return false
+	}
+	built, err := time.Parse(time.RFC3339, info.CommitTime)
+	if err != nil {
+		return false
+	}
+	return r.Published.After(built)
+}
+
+// Download downloads the release's binary into dir, verifies it against the
+// checksums Latest verified, and returns the path of the executable.
+func (s *Source) Download(ctx context.Context, release *Release, dir string) (string, error) {
+	if err := s.Validate(); err != nil {
+		return "", err
+	}
+	if release.checksums == nil {
+		return "", fmt.Errorf("release %s has no verified checksums", release.Version)
+	}
+	want, err := findChecksum(release.checksums, release.Asset)
+	if err != nil {
+		return "", fmt.Errorf("release %s: %w", release.Version, err)
+	}
+
+	path := filepath.Join(dir, release.Asset)
+	tmp := path + ".tmp"
+	out, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o755)
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp)
+	hash := sha256.New()
+	err = s.fetch(ctx, release.assets[release.Asset], func(body io.Reader) error {
+		_, err := io.Copy(io.MultiWriter(out, hash), body)
+		return err
+	})
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to download %s: %w", release.Asset, err)
+	}
+	if got := hex.EncodeToString(hash.Sum(nil)); got != want {
+		return "", fmt.Errorf("release %s: %s has checksum %s, want %s", release.Version, release.Asset, got, want)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// findChecksum returns the SHA-256 checksum of name in sha256sum output.
+func findChecksum(checksums []byte, name string) (string, error) {
+	scanner := bufio.NewScanner(strings.NewReader(string(checksums)))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		// Binary mode marks names with *
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == name {
+			sum := strings.ToLower(fields[0])
+			if _, err := hex.DecodeString(sum); err != nil || len(sum) != sha256.Size*2 {
+				return "", fmt.Errorf("invalid checksum of %s: %q", name, fields[0])
+			}
+			return sum, nil
+		}
+	}
+	return "", fmt.Errorf("%s has no checksum of %s", ChecksumsAsset, name)
+}
+
+// decodeSignature accepts raw and base64 signatures.
+func decodeSignature(sig []byte) []byte {
+	if len(sig) == ed25519.SignatureSize {
+		return sig
+	}
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(sig)))
+	if err != nil {
+		return sig
+	}
+	return decoded
+}
+
+// get returns the body of url, failing if it's longer than limit.
+func (s *Source) get(ctx context.Context, url string, limit int64) ([]byte, error) {
+	var data []byte
+	err := s.fetch(ctx, url, func(body io.Reader) error {
+		var err error
+		data, err = io.ReadAll(io.LimitReader(body, limit+1))
+		if err == nil && int64(len(data)) > limit {
+			err = fmt.Errorf("response is larger than %d bytes", limit)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", url, err)
+	}
+	return data, nil
+}
+
+// fetch requests url and passes its body to read if the request succeeds.
+func (s *Source) fetch(ctx context.Context, url string, read func(io.Reader) error) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	if strings.HasPrefix(url, "https://api.github.com/") {
+		req.Header.Set("Accept", "application/vnd.github+json")
+	}
+	resp, err := s.client().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("status %s", resp.Status)
+	}
+	return read(resp.Body)
+}
@@ -0,0 +1,161 @@
+package update
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"shelley.exe.dev/version"
+)
+
+// releaseServer serves a release of binary, whose checksums are signed
+// with key if it's set.
+func releaseServer(t *testing.T, binary []byte, checksums string, key ed25519.PrivateKey) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	assets := []map[string]string{
+		{"name": AssetName(), "browser_download_url": srv.URL + "/binary"},
+		{"name": ChecksumsAsset, "browser_download_url": srv.URL + "/checksums"},
+	}
+	if key != nil {
+		assets = append(assets, map[string]string{"name": SignatureAsset, "browser_download_url": srv.URL + "/sig"})
+	}
+	mux.HandleFunc("/release", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"tag_name":         "v1.2.0",
+			"target_commitish": "main",
+			"published_at":     "2026-10-01T12:00:00Z",
+			"html_url":         "https://example.com/releases/v1.2.0",
+			"assets":           assets,
+		})
+	})
+	mux.HandleFunc("/binary", func(w http.ResponseWriter, r *http.Request) { w.Write(binary) })
+	mux.HandleFunc("/checksums", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte(checksums)) })
+	mux.HandleFunc("/sig", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(base64.StdEncoding.EncodeToString(ed25519.Sign(key, []byte(checksums)))))
+	})
+	return srv
+}
+
+func checksumLine(data []byte, name string) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]) + "  " + name + "\n"
+}
+
+func TestDownload(t *testing.T) {
+	binary := []byte("#!/bin/sh\necho shelley\n")
+	public, private, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, otherKey, _ := ed25519.GenerateKey(nil)
+	// The release's JSON says it was published on October 1st
+	manifest := "# version: v1.2.0\n# published: 2026-09-01T00:00:00Z\n"
+	lines := checksumLine([]byte("other"), "shelley-plan9-386") + checksumLine(binary, AssetName())
+	checksums := manifest + lines
+	publicKey := base64.StdEncoding.EncodeToString(public)
+
+	tests := []struct {
+		name      string
+		checksums string
+		signer    ed25519.PrivateKey
+		publicKey string
+		unsigned  bool
+		wantErr   string
+	}{
+		{name: "checksum", checksums: checksums, unsigned: true},
+		{name: "no key", checksums: checksums, wantErr: "needs a public key"},
+		{name: "signed", checksums: checksums, signer: private, publicKey: publicKey},
+		{name: "wrong checksum", checksums: manifest + checksumLine([]byte("tampered"), AssetName()), signer: private, publicKey: publicKey, wantErr: "has checksum"},
+		{name: "no checksum", checksums: manifest + checksumLine(binary, "shelley-plan9-386"), signer: private, publicKey: publicKey, wantErr: "no checksum"},
+		{name: "older release", checksums: "# version: v1.1.0\n# published: 2026-11-01T00:00:00Z\n" + lines, signer: private, publicKey: publicKey, wantErr: "is of version v1.1.0"},
+		{name: "no version", checksums: "# published: 2026-09-01T00:00:00Z\n" + lines, signer: private, publicKey: publicKey, wantErr: "has no version"},
+		{name: "no publication time", checksums: "# version: v1.2.0\n" + lines, signer: private, publicKey: publicKey, wantErr: "has no publication time"},
+		{name: "unsigned without manifest", checksums: lines, unsigned: true},
+		{name: "wrong signer", checksums: checksums, signer: otherKey, publicKey: publicKey, wantErr: "invalid signature"},
+		{name: "unsigned", checksums: checksums, publicKey: publicKey, wantErr: "has no " + SignatureAsset},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := releaseServer(t, binary, tt.checksums, tt.signer)
+			source := &Source{URL: srv.URL + "/release", PublicKey: tt.publicKey, AllowUnsigned: tt.unsigned}
+			dir := t.TempDir()
+			release, err := source.Latest(context.Background())
+			var path string
+			if err == nil {
+				published := time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC)
+				if tt.checksums == lines {
+					published = time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+				}
+				if release.Version != "v1.2.0" || release.Commit != "" || release.Page == "" || !release.Published.Equal(published) {
+					t.Errorf("unexpected release %+v", release)
+				}
+				path, err = source.Download(context.Background(), release, dir)
+			}
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected an error containing %q, got %v", tt.wantErr, err)
+				}
+				if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+					t.Errorf("expected nothing left in the download directory, got %v", entries)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, err := os.ReadFile(path)
+			if err != nil || string(data) != string(binary) {
+				t.Errorf("expected the binary downloaded, got %q, %v", data, err)
+			}
+			if info, err := os.Stat(path); err != nil || info.Mode().Perm()&0o100 == 0 {
+				t.Errorf("expected an executable, got %v, %v", info, err)
+			}
+		})
+	}
+}
+
+func TestSourceValidate(t *testing.T) {
+	key := base64.StdEncoding.EncodeToString(make([]byte, ed25519.PublicKeySize))
+	for _, source := range []Source{{}, {Repo: "shelley", AllowUnsigned: true}, {URL: "ftp://example.com/release", AllowUnsigned: true}, {Repo: "a/b", PublicKey: "bm90IGEga2V5"}, {Repo: "a/b"}} {
+		if err := source.Validate(); err == nil {
+			t.Errorf("expected %+v to be invalid", source)
+		}
+	}
+	for _, source := range []Source{{Repo: "boldsoftware/shelley", PublicKey: key}, {Repo: "boldsoftware/shelley", AllowUnsigned: true}} {
+		if err := source.Validate(); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestNewerThan(t *testing.T) {
+	published := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		release Release
+		info    version.Info
+		want    bool
+	}{
+		{"published after the build", Release{Published: published}, version.Info{Commit: "abc1234", CommitTime: "2026-09-01T00:00:00Z"}, true},
+		{"published before the build", Release{Published: published}, version.Info{Commit: "abc1234", CommitTime: "2026-10-02T00:00:00Z"}, false},
+		{"same commit", Release{Published: published, Commit: "abc1234"}, version.Info{Commit: "abc1234def", CommitTime: "2026-09-01T00:00:00Z"}, false},
+		{"development build", Release{Published: published}, version.Info{}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.release.NewerThan(tt.info); got != tt.want {
+			t.Errorf("%s: NewerThan = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}